@@ -0,0 +1,168 @@
+"""
+Query results summarized across files: `query --group-by` counts captures per capture
+name, per distinct text, per file, or per directory, or per any combination of these
+in the order given. So "how many call sites of X are there per package" becomes
+`--group-by dir --group-by text` rather than a pipe through awk.
+
+Identical texts are counted once in `distinct`, and a group's `files` is the number of
+files it was seen in. Groups come most frequent first (`sort="count"`) or ordered by
+their key (`sort="key"`).
+"""
+
+from __future__ import annotations
+
+import posixpath
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Callable, Collection, Dict, Iterable, List, Optional, Sequence, Set, Tuple
+
+from tree_sitter import Query
+
+from . import failures, redact
+from .core import detect_language, iter_source_files, load_language, parse_file, query_tree
+
+GROUP_KEYS = ("capture", "text", "file", "dir")
+SORTS = ("count", "key")
+
+# The matches of one file, labelled with its path relative to the queried directory.
+FileMatches = Tuple[str, Sequence[dict]]
+
+
+def check_group_by(keys: Sequence[str]) -> List[str]:
+    """`keys` if each is one of GROUP_KEYS and none repeats; ValueError otherwise."""
+    for key in keys:
+        if key not in GROUP_KEYS:
+            raise ValueError(f"Unknown group key '{key}' (expected {', '.join(GROUP_KEYS)})")
+    if len(set(keys)) != len(keys):
+        raise ValueError("Each --group-by key may be given once")
+    return list(keys)
+
+
+@dataclass
+class CaptureGroup:
+    key: Dict[str, str]
+    count: int = 0
+    texts: Set[str] = field(default_factory=set)
+    files: Set[str] = field(default_factory=set)
+
+    def to_dict(self) -> dict:
+        return {**self.key, "count": self.count, "distinct": len(self.texts), "files": len(self.files)}
+
+
+def _key_value(key: str, path: str, capture: dict) -> str:
+    if key == "capture":
+        return capture["name"]
+    if key == "text":
+        return capture["text"]
+    if key == "file":
+        return path
+    return posixpath.dirname(path) or "."
+
+
+def aggregate_captures(
+    results: Iterable[FileMatches],
+    group_by: Sequence[str],
+    captures: Collection[str] = (),
+    sort: str = "count",
+) -> List[CaptureGroup]:
+    """
+    The captures of `results` grouped by the `group_by` keys, counting only the
+    captures named in `captures` when it is not empty.
+    """
+    group_by = check_group_by(group_by)
+    if not group_by:
+        raise ValueError("Group by at least one of " + ", ".join(GROUP_KEYS))
+    if sort not in SORTS:
+        raise ValueError(f"Unknown sort '{sort}' (expected {' or '.join(SORTS)})")
+    groups: Dict[Tuple[str, ...], CaptureGroup] = {}
+    for path, matches in results:
+        for match in matches:
+            for capture in match["captures"]:
+                if captures and capture["name"] not in captures:
+                    continue
+                values = tuple(_key_value(key, path, capture) for key in group_by)
+                group = groups.get(values)
+                if group is None:
+                    group = groups[values] = CaptureGroup(dict(zip(group_by, values)))
+                group.count += 1
+                group.texts.add(capture["text"])
+                group.files.add(path)
+    ordered = sorted(groups.items())
+    if sort == "count":
+        ordered.sort(key=lambda item: -item[1].count)  # stable: ties stay in key order
+    return [group for _, group in ordered]
+
+
+def _cell(value: str) -> str:
+    return value.replace("\\", "\\\\").replace("\t", "\\t").replace("\n", "\\n")
+
+
+def groups_to_text(groups: Sequence[CaptureGroup], group_by: Sequence[str]) -> str:
+    """A tab-separated table: count, distinct texts, files, then the key columns."""
+    rows = ["\t".join(["count", "distinct", "files", *group_by])]
+    for group in groups:
+        cells = [str(group.count), str(len(group.texts)), str(len(group.files))]
+        rows.append("\t".join(cells + [_cell(group.key[key]) for key in group_by]))
+    return "\n".join(rows)
+
+
+def query_directory(
+    root: Path,
+    query_for: Callable[[str], str],
+    language: Optional[str] = None,
+    include: Sequence[str] | None = None,
+    exclude: Sequence[str] | None = None,
+    cache=None,
+) -> Tuple[List[FileMatches], Dict[str, str]]:
+    """
+    Run a query over every source file under `root` (only those in `language`, when
+    given), with `query_for(language)` giving the query text for each language seen.
+    Returns the matches per file, and the languages whose files were skipped because
+    the query does not compile for them, with the error. ValueError when it compiles
+    for none of the languages found.
+    """
+    base = Path(root).resolve()
+    compiled: Dict[str, Optional[Tuple[str, Query]]] = {}
+    skipped: Dict[str, str] = {}
+    results: List[FileMatches] = []
+    for path in iter_source_files(base, include, exclude):
+        detected = detect_language(path)
+        if detected is None or (language and detected != language):
+            continue
+        if detected not in compiled:
+            try:
+                text = query_for(detected)
+                compiled[detected] = (text, Query(load_language(detected), text))
+            except (ValueError, RuntimeError) as exc:
+                compiled[detected] = None
+                skipped[detected] = str(exc)
+        if compiled[detected] is None:
+            continue
+        text, query = compiled[detected]
+        try:
+            if cache is not None:
+                from .cache import run_query_cached
+
+                matches = run_query_cached(cache, path, text, detected)
+            else:
+                parsed = parse_file(path, detected)
+                matches = query_tree(parsed.root, redact.apply(parsed.source, parsed.root), query)
+        except (ValueError, RuntimeError, OSError) as exc:
+            failures.record(path, exc)
+            continue
+        results.append((path.relative_to(base).as_posix(), matches))
+    if compiled and not any(compiled.values()):
+        name, error = next(iter(skipped.items()))
+        raise ValueError(f"Query does not compile for {name}: {error}")
+    return results, skipped
+
+
+__all__ = [
+    "GROUP_KEYS",
+    "SORTS",
+    "CaptureGroup",
+    "aggregate_captures",
+    "check_group_by",
+    "groups_to_text",
+    "query_directory",
+]
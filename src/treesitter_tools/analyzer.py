@@ -0,0 +1,168 @@
+"""
+An embeddable analyzer: extraction and queries for services that would otherwise shell out.
+
+One `Analyzer` may be shared by any number of threads. It holds only its (frozen)
+options, and every parser it uses belongs to the thread using it (see
+`core.get_parser`). Each long-running call takes a `Context`, which can be cancelled
+from any thread or given a deadline. Cancellation is checked before each file is
+parsed. A file already being parsed finishes, and the call then raises `Cancelled`
+(`DeadlineExceeded` once the deadline has passed). Nothing of a cancelled call is
+returned.
+
+Files a walk skips go to the `failures.collect_failures` block of the calling thread (the
+block is context-local, and worker threads run in the caller's context). Process-wide
+settings still apply to every analyzer: `--encoding` (`charsets.FORCED`), overlays,
+redaction, and the ignore and generated-file flags.
+"""
+
+from __future__ import annotations
+
+import contextvars
+import threading
+import time
+from collections import deque
+from concurrent.futures import Future, ThreadPoolExecutor
+from dataclasses import dataclass
+from pathlib import Path
+from typing import Deque, Iterator, List, Optional, Sequence
+
+from .core import CodeSymbol, FileSymbols, extract_symbols, finish_report, iter_source_files, run_query, scan_file
+
+
+class Cancelled(RuntimeError):
+    """A call stopped because its `Context` was cancelled."""
+
+
+class DeadlineExceeded(Cancelled, TimeoutError):
+    """A call stopped because its `Context` reached its deadline."""
+
+
+class Context:
+    """
+    Cancellation and an optional deadline (`timeout` seconds from now) for one or more
+    calls. A context made with a `parent` is done when the parent is, and its deadline
+    is never later than the parent's.
+    """
+
+    def __init__(self, timeout: Optional[float] = None, parent: Optional["Context"] = None):
+        self._cancelled = threading.Event()
+        self.parent = parent
+        deadline = time.monotonic() + timeout if timeout is not None else None
+        if parent is not None and parent.deadline is not None:
+            deadline = parent.deadline if deadline is None else min(deadline, parent.deadline)
+        self.deadline = deadline  # in `time.monotonic()` seconds
+
+    def cancel(self) -> None:
+        """Stop every call using this context (or a child of it); safe to call from any thread, more than once."""
+        self._cancelled.set()
+
+    def err(self) -> Optional[Cancelled]:
+        """Why the context is done, or None while it is not."""
+        if self._cancelled.is_set():
+            return Cancelled("Operation cancelled")
+        if self.deadline is not None and time.monotonic() >= self.deadline:
+            return DeadlineExceeded("Operation deadline exceeded")
+        return self.parent.err() if self.parent is not None else None
+
+    @property
+    def done(self) -> bool:
+        return self.err() is not None
+
+    def check(self) -> None:
+        """Raise `Cancelled` (or `DeadlineExceeded`) once the context is done."""
+        error = self.err()
+        if error is not None:
+            raise error
+
+
+@dataclass(frozen=True)
+class AnalyzerOptions:
+    include: Sequence[str] = ("**/*",)
+    exclude: Sequence[str] = ()
+    max_chunk_size: Optional[int] = None
+    max_file_size: Optional[int] = None
+    jobs: int = 1  # worker threads per directory walk
+    max_in_flight: Optional[int] = None  # parsed-but-unconsumed reports per walk (default 2 * jobs)
+
+    def __post_init__(self):
+        if self.jobs < 1:
+            raise ValueError("jobs must be at least 1")
+
+
+class Analyzer:
+    """Symbol extraction and queries with `AnalyzerOptions`, safe for concurrent use."""
+
+    def __init__(self, options: Optional[AnalyzerOptions] = None):
+        self.options = options or AnalyzerOptions()
+
+    def extract_file(
+        self, path: Path, ctx: Optional[Context] = None, language: Optional[str] = None
+    ) -> List[CodeSymbol]:
+        """Symbols of one file; raises like `core.extract_symbols`."""
+        _check(ctx)
+        symbols = extract_symbols(path, language, self.options.max_chunk_size, self.options.max_file_size)
+        _check(ctx)
+        return symbols
+
+    def query_file(
+        self, path: Path, query: str, ctx: Optional[Context] = None, language: Optional[str] = None
+    ) -> List[dict]:
+        """Matches of a query in one file, shaped like `core.run_query` results."""
+        _check(ctx)
+        matches = run_query(path, query, language)
+        _check(ctx)
+        return matches
+
+    def iter_extract_dir(self, root: Path, ctx: Optional[Context] = None) -> Iterator[FileSymbols]:
+        """
+        Reports of the files under `root` in walk order, as `core.iter_scan_directory`
+        yields them, with `jobs` files parsed at a time. Failures are captured in the
+        reports; only cancellation raises.
+        """
+        options = self.options
+        base = Path(root).resolve()
+        paths = iter_source_files(root, options.include, options.exclude)
+        if options.jobs == 1:
+            for path in paths:
+                _check(ctx)
+                report = scan_file(path, options.max_chunk_size, max_file_size=options.max_file_size)
+                if report is not None:
+                    yield finish_report(report, base)
+            _check(ctx)
+            return
+        limit = max(options.max_in_flight or options.jobs * 2, 1)
+        pending: Deque[Future] = deque()
+        with ThreadPoolExecutor(max_workers=options.jobs, thread_name_prefix="treesitter-tools") as pool:
+            try:
+                for path in paths:
+                    _check(ctx)
+                    if len(pending) >= limit:
+                        report = pending.popleft().result()
+                        if report is not None:
+                            yield finish_report(report, base)
+                    pending.append(pool.submit(contextvars.copy_context().run, self._scan, path, ctx))
+                while pending:
+                    report = pending.popleft().result()
+                    if report is not None:
+                        yield finish_report(report, base)
+                _check(ctx)
+            finally:
+                # Files not started yet are dropped; the `with` waits for those mid-parse.
+                for future in pending:
+                    future.cancel()
+
+    def extract_dir(self, root: Path, ctx: Optional[Context] = None) -> List[FileSymbols]:
+        """`iter_extract_dir` collected: every report, or `Cancelled` and none."""
+        return list(self.iter_extract_dir(root, ctx))
+
+    def _scan(self, path: Path, ctx: Optional[Context]) -> Optional[FileSymbols]:
+        _check(ctx)
+        return scan_file(path, self.options.max_chunk_size, max_file_size=self.options.max_file_size)
+
+
+def _check(ctx: Optional[Context]) -> None:
+    if ctx is not None:
+        ctx.check()
+
+
+__all__ = ["Analyzer", "AnalyzerOptions", "Cancelled", "Context", "DeadlineExceeded"]
@@ -3,9 +3,44 @@
 from __future__ import annotations
 
 from pathlib import Path
-from typing import List, Optional
+from typing import ContextManager, Iterator, List, Mapping, Optional, Union
 
-from .core import CodeSymbol, extract_symbols, run_query
+from .analyzer import Analyzer, AnalyzerOptions, Cancelled, Context, DeadlineExceeded
+from .bench import BenchReport, run_bench
+from .callgraph import CallGraph, build_call_graph
+from .cfg import ControlFlowGraph, file_cfgs
+from .core import CodeSymbol, FileSymbols, extract_symbols, run_query
+from .dataflow import FlowSummary, analyze_flows
+from .docs import DocSite, build_site
+from .editor import (
+    DocumentSymbol,
+    FoldingRange,
+    SelectionRange,
+    file_document_symbols,
+    file_folding_ranges,
+    file_selection_range,
+)
+from .export.graphdb import PropertyGraph, build_property_graph
+from .export.records import read_records as _read_records
+from .failures import FailureReport, collect_failures
+from .grammars import GrammarReport, read_lock, verify_grammars
+from .headers import FileHeader, check_headers
+from .hierarchy import TypeHierarchy, build_hierarchy
+from .history import History, symbol_history
+from .imports import FileImports, check_imports
+from .incremental import IncrementalSession
+from .index import GcStats, SymbolIndex, gc_index
+from .manifest import Manifest, build_manifest
+from .normalize import SymbolInfo, file_symbol_infos
+from .overlay import Overlay, applied
+from .patch import PatchPlan, apply_patch, load_edits
+from .positions import LineIndex, Position
+from .redact import Redactor
+from .regions import LineAttribution, SymbolMap, extract_regions, parse_position, parse_range
+from .schema import schema_for, validate as _validate
+from .summarize import SummarizerConfig, summarize_reports
+from .workspace import Workspace, find_workspace, load_workspace, scan_workspace, workspace_from_paths
+from .querylib import QueryFile, list_queries as _list_queries, load_query as _load_query
 
 
 def list_symbols(path: Path, language: Optional[str] = None, max_chunk_size: Optional[int] = None) -> List[CodeSymbol]:
@@ -27,4 +62,279 @@ def query_file(path: Path, query: str, language: Optional[str] = None):
     return run_query(path, query, language)
 
 
-__all__ = ["list_symbols", "query_file", "CodeSymbol"]
+def call_graph(root: Path, include: Optional[List[str]] = None, exclude: Optional[List[str]] = None) -> CallGraph:
+    """Build a resolved caller -> callee graph for a file or directory."""
+    return build_call_graph(root, include, exclude)
+
+
+def list_queries(language: Optional[str] = None) -> List[QueryFile]:
+    """Queries in the library (bundled tags/highlights/locals/folds and user overrides), optionally for one language."""
+    return _list_queries(language)
+
+
+def load_query(language: str, name: str) -> str:
+    """Source of a library query, e.g. `load_query("python", "highlights")`; raises ValueError if missing."""
+    return _load_query(language, name)
+
+
+def type_hierarchy(root: Path, include: Optional[List[str]] = None, exclude: Optional[List[str]] = None) -> TypeHierarchy:
+    """Subtype -> supertype relations for a file or directory; query with `.supertypes(name)` / `.subtypes(name)`."""
+    return build_hierarchy(root, include, exclude)
+
+
+def control_flow_graphs(path: Path, function: Optional[str] = None, language: Optional[str] = None) -> List[ControlFlowGraph]:
+    """Basic-block control-flow graph of each function in a file (or just `function`); `.to_dot()` renders one."""
+    return file_cfgs(path, function, language)
+
+
+def parameter_flows(
+    root: Path, include: Optional[List[str]] = None, exclude: Optional[List[str]] = None, function: Optional[str] = None
+) -> List[FlowSummary]:
+    """Per-function summaries of which parameters reach return values, call arguments, and global writes."""
+    return analyze_flows(root, include, exclude, function)
+
+
+def folding_ranges(path: Path, language: Optional[str] = None, encoding: str = "utf-16") -> List[FoldingRange]:
+    """Foldable regions of a file; `.to_dict()` gives LSP `FoldingRange` JSON."""
+    return file_folding_ranges(path, language, encoding)
+
+
+def document_symbols(path: Path, language: Optional[str] = None, encoding: str = "utf-16") -> List[DocumentSymbol]:
+    """Nested outline of a file; `.to_dict()` gives LSP `DocumentSymbol` JSON."""
+    return file_document_symbols(path, language, encoding)
+
+
+def selection_range(
+    path: Path, line: int, character: int, language: Optional[str] = None, encoding: str = "utf-16"
+) -> SelectionRange:
+    """Enclosing nodes of a position, innermost first via `.chain()`; `.to_dict()` gives LSP `SelectionRange` JSON."""
+    return file_selection_range(path, line, character, language, encoding)
+
+
+def repo_manifest(root: Path, include: Optional[List[str]] = None, exclude: Optional[List[str]] = None) -> Manifest:
+    """Per-file and per-package content, syntax, and public-API hashes; compare two with `manifest.diff_manifests`."""
+    return build_manifest(root, include, exclude)
+
+
+def normalized_symbols(path: Path, language: Optional[str] = None, rel_path: Optional[str] = None) -> List[SymbolInfo]:
+    """Declarations of a file in the cross-language schema; `rel_path` (its path in the repository) names the module."""
+    return file_symbol_infos(path, language, rel_path)
+
+
+def property_graph(root: Path, include: Optional[List[str]] = None, exclude: Optional[List[str]] = None) -> PropertyGraph:
+    """Files, symbols, calls, imports, and inheritance as one graph; `.to_cypher()` / `.to_jgf()` serialize it."""
+    return build_property_graph(root, include, exclude)
+
+
+def redact_secrets(text: str, patterns: Optional[List[str]] = None, builtin: bool = True) -> str:
+    """`text` with the built-in secret patterns (and any extra regexes) masked, length preserved."""
+    return Redactor.from_options(patterns or [], builtin).redact_text(text)
+
+
+def open_workspace(roots: Optional[List[Path]] = None, workspace_file: Optional[Path] = None) -> Workspace:
+    """A multi-root workspace from explicit `roots` (each with its own config) or a workspace file (default: the nearest)."""
+    if roots:
+        return workspace_from_paths(roots)
+    path = workspace_file or find_workspace(Path.cwd())
+    if path is None:
+        raise ValueError("No workspace file found; pass roots or workspace_file")
+    return load_workspace(path)
+
+
+def workspace_symbols(workspace: Workspace, max_chunk_size: Optional[int] = None) -> List[FileSymbols]:
+    """Symbol reports for every root of `workspace`, paths labelled `<root name>/<path>`."""
+    return list(scan_workspace(workspace, max_chunk_size))
+
+
+def benchmark(
+    root: Path, languages: Optional[List[str]] = None, repeat: int = 1, jobs: Optional[List[int]] = None
+) -> BenchReport:
+    """Parse/query timings and peak RSS per language under `root`; `.to_text()` / `.to_json()` render them."""
+    return run_bench(root, languages=languages, repeat=repeat, jobs=jobs or ())
+
+
+def output_schema(record: str, version: Optional[str] = None) -> dict:
+    """JSON Schema of an output record (`file`, `chunk`, `index-reference`, ...) at a schema version."""
+    return schema_for(record, version)
+
+
+def validate_output(record: str, data, version: Optional[str] = None) -> List[str]:
+    """Problems with `data` as `record` at a schema version; empty when it conforms."""
+    return _validate(record, data, version)
+
+
+def apply_edit_sets(paths: List[Path], dry_run: bool = False) -> PatchPlan:
+    """Apply `--format edits` files all-or-nothing; `.conflicts` lists why nothing was written, `.diff()` previews."""
+    files = [e for path in paths for e in load_edits(Path(path).read_text(encoding="utf-8"), source=str(path))]
+    return apply_patch(files, dry_run=dry_run)
+
+
+def import_problems(
+    root: Path, include: Optional[List[str]] = None, exclude: Optional[List[str]] = None
+) -> List[FileImports]:
+    """Files with unused or duplicate imports; `.problems` lists them and `.fix()` removes them."""
+    return check_imports(root, include, exclude)
+
+
+def license_headers(
+    root: Path, include: Optional[List[str]] = None, exclude: Optional[List[str]] = None, expected: Optional[str] = None
+) -> List[FileHeader]:
+    """License header of every file; `.problem` is "missing" or "mismatch" (with `expected`), `.fix()` inserts one."""
+    return check_headers(root, include, exclude, expected)
+
+
+def function_history(
+    path: Path,
+    revisions: str = "HEAD",
+    symbols: Optional[List[str]] = None,
+    max_count: Optional[int] = None,
+) -> History:
+    """Per-function metrics at each commit of a git range; `.symbols` holds one series per function."""
+    return symbol_history(path, revisions, symbols=symbols, max_count=max_count)
+
+
+def read_records(path: Path) -> Iterator:
+    """`FileSymbols` and `Chunk` objects from a `--format proto` file (gzip-compressed or not)."""
+    return _read_records(path)
+
+
+def documentation(index: SymbolIndex, private: bool = False, source_url: Optional[str] = None) -> DocSite:
+    """Cross-linked reference docs for an updated index; `.pages("markdown"|"html")` / `.write(dir)` render them."""
+    return build_site(index, private=private, source_url=source_url)
+
+
+def summarize_functions(
+    reports: List[FileSymbols],
+    config: Optional[SummarizerConfig] = None,
+    cache_dir: Optional[Path] = None,
+    jobs: int = 4,
+) -> List[FileSymbols]:
+    """`reports` with a one-line `summary` on each function from a chat endpoint; failures leave it unset."""
+    return summarize_reports(reports, config, cache_dir, jobs)
+
+
+def check_grammars(lock_path: Optional[Path] = None, languages: Optional[List[str]] = None) -> GrammarReport:
+    """Grammars that fail to load, drift from a `grammars pin` lock, or lack node kinds the queries use."""
+    return verify_grammars(read_lock(lock_path) if lock_path is not None else None, languages)
+
+
+def open_index(db_path: Path) -> SymbolIndex:
+    """Open (creating if needed) a SQLite symbol index; call `.update(root)` to populate it."""
+    return SymbolIndex(db_path)
+
+
+def compact_index(db_path: Path, root: Optional[Path] = None, vacuum: bool = True) -> GcStats:
+    """Drop index and embedding rows of deleted files and unused or duplicate vectors, then VACUUM."""
+    return gc_index(db_path, root, vacuum)
+
+
+def declarations_at(location: str, context: int = 0, doc: bool = False, enclosing: bool = False) -> List[dict]:
+    """Smallest declaration(s) around `FILE:LINE[:COLUMN]` or `FILE:START-END`, shaped like `get` results."""
+    tail = location.rpartition(":")[2]
+    target = parse_range(location) if "-" in tail or "," in tail else parse_position(location)
+    return extract_regions(target, context, doc, enclosing)
+
+
+def symbol_at(path: Path, line: int, language: Optional[str] = None) -> Optional[CodeSymbol]:
+    """The innermost declaration containing 1-based `line` of `path`, or None outside every declaration."""
+    return SymbolMap.load(Path(path), language).symbol_at(line)
+
+
+def attribute_lines(path: Path, lines: List[int], language: Optional[str] = None) -> List[LineAttribution]:
+    """Enclosing declaration, function, and type of each of `lines`, parsing `path` once."""
+    return SymbolMap.load(Path(path), language).attribute_lines(lines)
+
+
+def overlays(files: Mapping[Union[str, Path], Union[str, bytes]]) -> ContextManager[Overlay]:
+    """
+    Inside `with api.overlays({path: unsaved_text}):`, every call analyzes those paths
+    with the given contents instead of what is on disk.
+    """
+    return applied(files)
+
+
+def new_analyzer(options: Optional[AnalyzerOptions] = None, **settings) -> Analyzer:
+    """
+    An `Analyzer` to share across a service's threads: `new_analyzer(jobs=4, exclude=["vendor/**"])`.
+    Its calls take a `Context` for cancellation and deadlines (`Context(timeout=30)`).
+    """
+    if options is not None and settings:
+        raise ValueError("Pass AnalyzerOptions or keyword settings, not both")
+    return Analyzer(options or AnalyzerOptions(**settings))
+
+
+__all__ = [
+    "new_analyzer",
+    "list_symbols",
+    "query_file",
+    "call_graph",
+    "type_hierarchy",
+    "control_flow_graphs",
+    "parameter_flows",
+    "list_queries",
+    "load_query",
+    "folding_ranges",
+    "document_symbols",
+    "selection_range",
+    "repo_manifest",
+    "normalized_symbols",
+    "property_graph",
+    "redact_secrets",
+    "open_workspace",
+    "workspace_symbols",
+    "benchmark",
+    "output_schema",
+    "validate_output",
+    "apply_edit_sets",
+    "import_problems",
+    "license_headers",
+    "function_history",
+    "read_records",
+    "documentation",
+    "summarize_functions",
+    "check_grammars",
+    "open_index",
+    "compact_index",
+    "declarations_at",
+    "collect_failures",
+    "symbol_at",
+    "attribute_lines",
+    "overlays",
+    "CodeSymbol",
+    "Analyzer",
+    "AnalyzerOptions",
+    "BenchReport",
+    "CallGraph",
+    "Cancelled",
+    "Context",
+    "ControlFlowGraph",
+    "DeadlineExceeded",
+    "DocSite",
+    "DocumentSymbol",
+    "FailureReport",
+    "FileHeader",
+    "FileImports",
+    "FileSymbols",
+    "FlowSummary",
+    "FoldingRange",
+    "GcStats",
+    "GrammarReport",
+    "History",
+    "IncrementalSession",
+    "LineAttribution",
+    "LineIndex",
+    "Manifest",
+    "Overlay",
+    "PatchPlan",
+    "Position",
+    "PropertyGraph",
+    "QueryFile",
+    "Redactor",
+    "SelectionRange",
+    "SummarizerConfig",
+    "SymbolIndex",
+    "SymbolInfo",
+    "SymbolMap",
+    "TypeHierarchy",
+    "Workspace",
+]
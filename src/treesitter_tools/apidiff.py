@@ -0,0 +1,232 @@
+"""Compare the public API surface between two git revisions and flag breaking changes."""
+
+from __future__ import annotations
+
+import json
+import subprocess
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Dict, Iterator, List, Optional, Sequence, Tuple
+
+from .apisurface import ApiModule, collect_api, group_api
+from .core import ParsedFile, _match_any, detect_language, parse_source
+from .gitdiff import _git, repo_root
+
+# Exit codes for `api-diff`: 1 stays reserved for errors, as everywhere else in the CLI.
+EXIT_BREAKING = 2
+EXIT_ADDITIVE = 3
+
+
+@dataclass
+class ApiChange:
+    change: str  # "removed", "changed", or "added"
+    module: str
+    language: str
+    kind: str
+    name: str
+    before: Optional[str] = None
+    after: Optional[str] = None
+    path: Optional[str] = None  # where the declaration lives now (or lived, when removed)
+
+    @property
+    def breaking(self) -> bool:
+        return self.change != "added"
+
+    def to_dict(self) -> dict:
+        return {
+            "change": self.change,
+            "module": self.module,
+            "language": self.language,
+            "kind": self.kind,
+            "name": self.name,
+            "before": self.before,
+            "after": self.after,
+            "path": self.path,
+        }
+
+
+@dataclass
+class ApiDiff:
+    base: str
+    head: str
+    changes: List[ApiChange] = field(default_factory=list)
+
+    @property
+    def breaking(self) -> List[ApiChange]:
+        return [c for c in self.changes if c.breaking]
+
+    @property
+    def additive(self) -> List[ApiChange]:
+        return [c for c in self.changes if not c.breaking]
+
+    def exit_code(self, strict: bool = False) -> int:
+        """0 when compatible, EXIT_BREAKING on breaking changes, EXIT_ADDITIVE on additions with `strict`."""
+        if self.breaking:
+            return EXIT_BREAKING
+        if strict and self.additive:
+            return EXIT_ADDITIVE
+        return 0
+
+    def summary(self) -> dict:
+        return {"breaking": len(self.breaking), "additive": len(self.additive)}
+
+    def to_json(self) -> str:
+        return json.dumps(
+            {
+                "base": self.base,
+                "head": self.head,
+                "summary": self.summary(),
+                "breaking": [c.to_dict() for c in self.breaking],
+                "additive": [c.to_dict() for c in self.additive],
+            },
+            indent=2,
+        )
+
+    def to_text(self) -> str:
+        lines = []
+        markers = {"removed": "-", "changed": "~", "added": "+"}
+        for title, changes in (("Breaking changes", self.breaking), ("Additive changes", self.additive)):
+            if not changes:
+                continue
+            lines.append(f"{title} ({len(changes)}):")
+            for c in changes:
+                lines.append(f"  {markers[c.change]} {c.change:<7} {c.module}: {c.kind} {c.name}")
+                if c.change == "changed":
+                    lines.append(f"      before: {c.before}")
+                    lines.append(f"      after:  {c.after}")
+                else:
+                    lines.append(f"      {c.before if c.change == 'removed' else c.after}")
+        summary = self.summary()
+        lines.append(f"{self.base} -> {self.head}: {summary['breaking']} breaking, {summary['additive']} additive")
+        return "\n".join(lines) + "\n"
+
+
+def _read_blobs(repo: Path, commit: str, paths: Sequence[str]) -> Iterator[Tuple[str, bytes]]:
+    """Contents of `paths` at `commit`, read through one `git cat-file --batch` process."""
+    if not paths:
+        return
+    request = "".join(f"{commit}:{p}\n" for p in paths).encode("utf-8", "surrogateescape")
+    try:
+        result = subprocess.run(["git", "cat-file", "--batch"], cwd=repo, input=request, capture_output=True, check=False)
+    except FileNotFoundError:
+        raise RuntimeError("api-diff needs the 'git' executable on PATH") from None
+    if result.returncode != 0:
+        raise ValueError(result.stderr.decode("utf-8", "replace").strip() or "git cat-file failed")
+    out, pos = result.stdout, 0
+    for rel in paths:
+        end = out.index(b"\n", pos)
+        header = out[pos:end].split()
+        pos = end + 1
+        if len(header) != 3 or header[1] != b"blob":
+            continue  # missing, or a submodule commit
+        size = int(header[2])
+        yield rel, out[pos : pos + size]
+        pos += size + 1
+
+
+def api_at_ref(
+    path: Path,
+    ref: str,
+    include: Sequence[str] | None = None,
+    exclude: Sequence[str] | None = None,
+    include_tests: bool = False,
+) -> List[ApiModule]:
+    """The API surface of directory `path` as committed at `ref`, without touching the working tree."""
+    path = Path(path).resolve()
+    repo = repo_root(path)
+    try:
+        commit = _git(repo, "rev-parse", "--verify", "--quiet", f"{ref}^{{commit}}").decode("utf-8").strip()
+    except ValueError:
+        raise ValueError(f"Unknown git revision: {ref}") from None
+    prefix = path.relative_to(repo).as_posix()
+    prefix = "" if prefix == "." else prefix + "/"
+    listing = _git(repo, "ls-tree", "-r", "-z", "--name-only", commit, "--", prefix or ".")
+    include = include or ["**/*"]
+    wanted: Dict[str, str] = {}
+    for rel in sorted(listing.decode("utf-8", "surrogateescape").split("\0")):
+        if not rel.startswith(prefix) or not rel:
+            continue
+        label = rel[len(prefix):]
+        if not _match_any(include, label) or (exclude and _match_any(exclude, label)):
+            continue
+        if detect_language(Path(label)):
+            wanted[rel] = label
+
+    def parsed_files() -> Iterator[Tuple[ParsedFile, str]]:
+        for rel, source in _read_blobs(repo, commit, list(wanted)):
+            label = wanted[rel]
+            if b"\x00" in source[:8192]:
+                continue
+            language = detect_language(Path(label))
+            try:
+                yield ParsedFile(repo / rel, language, source, parse_source(source, language)), label
+            except (ValueError, RuntimeError):
+                continue
+
+    return group_api(parsed_files(), include_tests)
+
+
+def _index(modules: Sequence[ApiModule]) -> Dict[Tuple[str, str, str], list]:
+    index: Dict[Tuple[str, str, str], list] = {}
+    for module in modules:
+        for decl in module.declarations:
+            index.setdefault((module.module, module.language, decl.name), []).append(decl)
+    return index
+
+
+def diff_api(before: Sequence[ApiModule], after: Sequence[ApiModule], base: str = "base", head: str = "head") -> ApiDiff:
+    """
+    Match declarations by (module, language, qualified name). A declaration that
+    disappeared or whose signature changed is breaking; a new one is additive.
+    Moving a declaration between files of the same package is not a change.
+    """
+    old, new = _index(before), _index(after)
+    diff = ApiDiff(base, head)
+    for key in sorted(set(old) | set(new)):
+        module, language, name = key
+        was, now = old.get(key, []), new.get(key, [])
+        removed = sorted({d.signature for d in was} - {d.signature for d in now})
+        added = sorted({d.signature for d in now} - {d.signature for d in was})
+        if not removed and not added:
+            continue
+        latest = (now or was)[0]
+        if removed and added:
+            change = "changed"
+        elif removed:
+            # The symbol is gone, or one of its overloads was dropped.
+            change = "removed"
+        else:
+            change = "added"
+        diff.changes.append(ApiChange(
+            change, module, language, latest.kind, name,
+            " | ".join(removed) or None, " | ".join(added) or None, latest.path,
+        ))
+    return diff
+
+
+def compare_refs(
+    path: Path,
+    base: str,
+    head: Optional[str] = None,
+    include: Sequence[str] | None = None,
+    exclude: Sequence[str] | None = None,
+    include_tests: bool = False,
+) -> ApiDiff:
+    """API changes under `path` from `base` to `head` (the working tree when `head` is None)."""
+    before = api_at_ref(path, base, include, exclude, include_tests)
+    if head is None:
+        after = collect_api(path, include, exclude, include_tests)
+    else:
+        after = api_at_ref(path, head, include, exclude, include_tests)
+    return diff_api(before, after, base, head or "working tree")
+
+
+__all__ = [
+    "EXIT_ADDITIVE",
+    "EXIT_BREAKING",
+    "ApiChange",
+    "ApiDiff",
+    "api_at_ref",
+    "compare_refs",
+    "diff_api",
+]
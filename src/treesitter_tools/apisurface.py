@@ -0,0 +1,298 @@
+"""Public API surface: exported declarations with their signatures, grouped by package or module."""
+
+from __future__ import annotations
+
+import json
+import re
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Dict, Iterable, Iterator, List, Optional, Sequence, Tuple
+
+from tree_sitter import Node
+
+from . import failures
+from .core import ParsedFile, class_kind, iter_class_nodes, iter_function_nodes, iter_source_files, parse_file
+from .testmap import is_test_file
+from .unused import _constants, _is_exported
+
+_JS_LANGUAGES = {"javascript", "typescript", "tsx"}
+# Languages whose package is the directory; everywhere else each file is a module.
+_DIRECTORY_PACKAGES = {"go", "java", "csharp", "kotlin", "scala"}
+_MODIFIER_VISIBILITY = {"java", "csharp", "kotlin", "scala"}
+
+# Class-like nodes whose members are reported separately: only the header is their signature.
+# Other types (structs, interfaces, traits, enums) are rendered whole.
+_HEADER_ONLY = {"class_definition", "class_declaration", "abstract_class_declaration", "class"}
+
+# Constant values longer than this are cut; the API is the name and type, not the literal.
+MAX_CONSTANT_SIGNATURE = 200
+
+
+@dataclass
+class ApiDeclaration:
+    kind: str
+    name: str  # qualified: `Class.method`, `Type.Method`
+    signature: str
+    path: str
+    line: int
+
+    def to_dict(self) -> dict:
+        return {"kind": self.kind, "name": self.name, "signature": self.signature, "path": self.path, "line": self.line}
+
+
+@dataclass
+class ApiModule:
+    module: str
+    language: str
+    declarations: List[ApiDeclaration] = field(default_factory=list)
+
+    def to_dict(self) -> dict:
+        return {
+            "module": self.module,
+            "language": self.language,
+            "declarations": [d.to_dict() for d in self.declarations],
+        }
+
+
+def module_name(rel_path: str, language: str) -> str:
+    """The package a file belongs to: its directory for Go/Java-style languages, else a module path."""
+    stem, _, _ = rel_path.rpartition(".")
+    if language in _DIRECTORY_PACKAGES:
+        return rel_path.rpartition("/")[0] or "."
+    parts = (stem or rel_path).split("/")
+    if language == "python":
+        if parts[0] == "src" and len(parts) > 1:
+            parts = parts[1:]
+        if parts[-1] == "__init__" and len(parts) > 1:
+            parts = parts[:-1]
+        return ".".join(parts)
+    if language in _JS_LANGUAGES and parts[-1] == "index" and len(parts) > 1:
+        parts = parts[:-1]
+    return "/".join(parts)
+
+
+def _collapse(text: str) -> str:
+    text = " ".join(text.split())
+    text = re.sub(r"([(\[]) ", r"\1", text)
+    text = re.sub(r",? ([)\]])", r"\1", text)
+    return text
+
+
+def _hidden_field(node: Node, parsed: ParsedFile) -> bool:
+    """Struct fields that are not part of the API: unexported Go fields, Rust fields without `pub`."""
+    if node.type != "field_declaration":
+        return False
+    if parsed.language == "go":
+        names = node.children_by_field_name("name")
+        return bool(names) and not any(parsed.text(n)[:1].isupper() for n in names)
+    if parsed.language == "rust":
+        return node.parent is not None and node.parent.type == "field_declaration_list" and not any(
+            child.type == "visibility_modifier" for child in node.children
+        )
+    return False
+
+
+def _render(node: Node, parsed: ParsedFile, start: int, end: int, separate_lines: bool = False) -> str:
+    """
+    Source between `start` and `end` without comments, hidden fields, or nested
+    function bodies, on one line. `separate_lines` joins lines with `; ` (Go
+    struct fields and interface methods are newline-terminated).
+    """
+    edits: List[Tuple[int, int, str]] = []
+    stack = list(reversed(node.children))
+    while stack:
+        child = stack.pop()
+        if child.end_byte <= start or child.start_byte >= end:
+            continue
+        if "comment" in child.type or _hidden_field(child, parsed):
+            edits.append((child.start_byte, child.end_byte, ""))
+            continue
+        body = child.child_by_field_name("body")
+        if body is not None and body.type in {"block", "statement_block", "compound_statement"}:
+            stack.extend(c for c in reversed(child.children) if c is not body)
+            edits.append((body.start_byte, body.end_byte, "{ ... }"))
+            continue
+        stack.extend(reversed(child.children))
+    edits.sort()
+    out, cursor = [], start
+    for lo, hi, replacement in edits:
+        if lo < cursor or lo >= end:
+            continue
+        out.append(parsed.source[cursor:lo].decode("utf-8", "replace"))
+        out.append(replacement)
+        cursor = min(hi, end)
+    out.append(parsed.source[cursor:end].decode("utf-8", "replace"))
+    text = "".join(out)
+    if separate_lines:
+        text = re.sub(r"\n\s*(?=\S)", "; ", text.strip()).replace("{; ", "{ ").replace("; }", " }")
+    return _collapse(text)
+
+
+def _header(node: Node, parsed: ParsedFile, outer: Optional[Node] = None) -> str:
+    """Declaration text up to its body; `outer` widens the start (decorators, `const f = ...`)."""
+    outer = outer or node
+    body = node.child_by_field_name("body")
+    end = body.start_byte if body is not None else node.end_byte
+    return _render(outer, parsed, outer.start_byte, end).rstrip(":;{ ")
+
+
+def _modifier_public(node: Node, parsed: ParsedFile) -> bool:
+    for child in node.children:
+        if child.type == "modifiers" or child.type == "modifier":
+            if "public" in parsed.text(child).split():
+                return True
+    return False
+
+
+def _public(node: Node, name: str, parsed: ParsedFile) -> bool:
+    language = parsed.language
+    if language in _MODIFIER_VISIBILITY:
+        return _modifier_public(node, parsed)
+    if language == "python" and name.startswith("__") and name.endswith("__"):
+        return True  # dunder methods are part of a class's interface
+    return _is_exported(node, name, language)
+
+
+def _inside(node: Node, spans: Sequence[Tuple[int, int]]) -> bool:
+    return any(lo <= node.start_byte and node.end_byte <= hi for lo, hi in spans)
+
+
+def file_api(parsed: ParsedFile, label: str) -> List[ApiDeclaration]:
+    """Exported functions, methods, types, and constants declared in `parsed`, with signatures."""
+    language = parsed.language
+    functions = [fn for fn in iter_function_nodes(parsed) if not fn.anonymous]
+    # Declarations inside a function body are implementation details.
+    bodies = [(b.start_byte, b.end_byte) for b in (fn.node.child_by_field_name("body") for fn in functions) if b]
+    found: List[ApiDeclaration] = []
+    public_types: Dict[str, bool] = {}
+    whole: List[Tuple[int, int]] = []  # types rendered with their members
+
+    for cls in iter_class_nodes(parsed):
+        node = cls.node
+        if cls.name == "<anonymous>" or _inside(node, bodies):
+            continue
+        decl = node.parent if node.parent is not None and node.parent.type == "decorated_definition" else node
+        public = _public(node, cls.name, parsed) and public_types.get(cls.container or "", True)
+        public_types[cls.qualified_name] = public
+        if not public:
+            continue
+        kind = class_kind(node)
+        if node.type in _HEADER_ONLY:
+            signature = _header(node, parsed, decl)
+        else:
+            signature = _render(decl, parsed, decl.start_byte, decl.end_byte, language == "go").rstrip(";")
+            whole.append((node.start_byte, node.end_byte))
+        if node.type == "type_spec":
+            signature = f"type {signature}"
+        found.append(ApiDeclaration(kind, cls.qualified_name, signature, label, node.start_point[0] + 1))
+
+    for fn in functions:
+        node = fn.node
+        if _inside(node, bodies) or _inside(node, whole):
+            continue
+        outer = node
+        parent = node.parent
+        if parent is not None and parent.type == "decorated_definition":
+            outer = parent
+        elif language in _JS_LANGUAGES and node.type == "arrow_function" and parent is not None:
+            outer = parent.parent if parent.type == "variable_declarator" and parent.parent is not None else parent
+        if not _public(outer if node.type == "arrow_function" else node, fn.name, parsed):
+            continue
+        if fn.container is not None:
+            container_public = public_types.get(fn.container)
+            if container_public is None and language == "go":
+                container_public = fn.container[:1].isupper()
+            if container_public is False:
+                continue
+        kind = "method" if fn.container else "function"
+        found.append(ApiDeclaration(kind, fn.qualified_name, _header(node, parsed, outer), label, node.start_point[0] + 1))
+
+    for kind, node, name_node in _constants(parsed):
+        name = parsed.text(name_node)
+        if _inside(node, bodies) or _inside(node, whole) or not _public(node, name, parsed):
+            continue
+        if language in _JS_LANGUAGES and node.type == "lexical_declaration":
+            declarator = name_node.parent
+            signature = f"const {_render(declarator, parsed, declarator.start_byte, declarator.end_byte)}"
+        else:
+            signature = _render(node, parsed, node.start_byte, node.end_byte).rstrip(";")
+            if node.type == "const_spec":
+                signature = f"const {signature}"
+            elif node.type == "type_spec":
+                signature = f"type {signature}"
+        if kind == "constant" and len(signature) > MAX_CONSTANT_SIGNATURE:
+            signature = signature[: MAX_CONSTANT_SIGNATURE - 4].rstrip() + " ..."
+        found.append(ApiDeclaration(kind, name, signature, label, node.start_point[0] + 1))
+    return found
+
+
+def group_api(files: Iterable[Tuple[ParsedFile, str]], include_tests: bool = False) -> List[ApiModule]:
+    """Build per-package/module entries from (parsed file, root-relative label) pairs."""
+    modules: Dict[Tuple[str, str], ApiModule] = {}
+    for parsed, label in files:
+        if not include_tests and is_test_file(label, parsed.language):
+            continue
+        declarations = file_api(parsed, label)
+        if not declarations:
+            continue
+        name = module_name(label, parsed.language)
+        module = modules.setdefault((name, parsed.language), ApiModule(name, parsed.language))
+        module.declarations.extend(declarations)
+    for module in modules.values():
+        # Sorted by name so `Type.method` follows `Type` and reordering a file is not an API change.
+        module.declarations.sort(key=lambda d: (d.name, d.kind, d.path, d.line))
+    return [modules[key] for key in sorted(modules)]
+
+
+def collect_api(
+    root: Path,
+    include: Sequence[str] | None = None,
+    exclude: Sequence[str] | None = None,
+    include_tests: bool = False,
+) -> List[ApiModule]:
+    """The public API of a file or every recognised file under a directory, one entry per package/module."""
+    root = Path(root)
+    if root.is_file():
+        targets = [(root, root.name)]
+    else:
+        base = root.resolve()
+        targets = [(p, p.relative_to(base).as_posix()) for p in iter_source_files(base, include, exclude)]
+
+    def parsed_files() -> Iterator[Tuple[ParsedFile, str]]:
+        for path, label in targets:
+            try:
+                yield parse_file(path), label
+            except (ValueError, RuntimeError, OSError) as exc:
+                failures.record(path, exc)
+                continue
+
+    return group_api(parsed_files(), include_tests)
+
+
+def api_to_json(modules: Sequence[ApiModule]) -> str:
+    return json.dumps([m.to_dict() for m in modules], indent=2)
+
+
+def api_to_text(modules: Sequence[ApiModule]) -> str:
+    """One declaration per line with no line numbers, so two releases can be compared with `diff`."""
+    lines = []
+    for module in modules:
+        heading = "package" if module.language in _DIRECTORY_PACKAGES else "module"
+        lines.append(f"{heading} {module.module} ({module.language})")
+        for decl in module.declarations:
+            depth = decl.name.count(".") if decl.kind != "constant" else 0
+            lines.append(f"{'  ' * (depth + 1)}{decl.signature}")
+    return "".join(line + "\n" for line in lines)
+
+
+__all__ = [
+    "ApiDeclaration",
+    "ApiModule",
+    "MAX_CONSTANT_SIGNATURE",
+    "api_to_json",
+    "api_to_text",
+    "collect_api",
+    "file_api",
+    "group_api",
+    "module_name",
+]
@@ -0,0 +1,265 @@
+"""Syntax-aware diff between two versions of a file, reported per declaration."""
+
+from __future__ import annotations
+
+import json
+from collections import Counter
+from dataclasses import dataclass
+from pathlib import Path
+from typing import Dict, List, Optional, Sequence, Set, Tuple
+
+from tree_sitter import Node
+
+from .core import ParsedFile, iter_class_nodes, iter_function_nodes, parse_file
+
+# Bodies at least this similar (Dice coefficient over tokens) are treated as the same
+# declaration when names differ.
+RENAME_SIMILARITY = 0.6
+# Declarations smaller than this (e.g. `func f() {}`) are too generic to pair as renames.
+MIN_RENAME_TOKENS = 8
+
+_BODY_FIELDS = ("body", "block")
+_NESTED_PLACEHOLDER = "<decl>"
+
+
+@dataclass
+class Declaration:
+    kind: str
+    name: str
+    qualified_name: str
+    container: Optional[str]
+    node: Node
+    signature: Tuple[str, ...]
+    body: Tuple[str, ...]
+
+    @property
+    def lines(self) -> dict:
+        return {"start_line": self.node.start_point[0] + 1, "end_line": self.node.end_point[0] + 1}
+
+
+@dataclass
+class Change:
+    change: str
+    kind: str
+    name: str
+    old_name: Optional[str] = None
+    old: Optional[dict] = None
+    new: Optional[dict] = None
+    signature_changed: bool = False
+    body_changed: bool = False
+    similarity: Optional[float] = None
+
+    def to_dict(self) -> dict:
+        data = {"change": self.change, "kind": self.kind, "name": self.name}
+        if self.old_name is not None:
+            data["old_name"] = self.old_name
+        if self.old is not None:
+            data["old"] = self.old
+        if self.new is not None:
+            data["new"] = self.new
+        if self.change in {"renamed", "signature_changed", "body_changed"}:
+            data["signature_changed"] = self.signature_changed
+            data["body_changed"] = self.body_changed
+        if self.similarity is not None:
+            data["similarity"] = self.similarity
+        return data
+
+
+def _tokens(node: Node, source: bytes, skip: Set[int], nested: Set[int]) -> List[str]:
+    """Leaf tokens in source order, ignoring comments/whitespace; nested declarations collapse."""
+    out: List[str] = []
+    stack = [node]
+    while stack:
+        current = stack.pop()
+        if current.id in skip or "comment" in current.type:
+            continue
+        if current.id in nested:
+            out.append(_NESTED_PLACEHOLDER)
+            continue
+        if current.child_count == 0:
+            out.append(source[current.start_byte:current.end_byte].decode("utf-8", "replace"))
+        else:
+            stack.extend(reversed(current.children))
+    return out
+
+
+def _body_node(node: Node) -> Optional[Node]:
+    if node.type == "type_spec":
+        # Go `type Foo struct {...}`: the struct/interface type is the body.
+        return node.child_by_field_name("type")
+    for name in _BODY_FIELDS:
+        child = node.child_by_field_name(name)
+        if child is not None:
+            return child
+    return None
+
+
+def declarations(parsed: ParsedFile) -> List[Declaration]:
+    """Functions and classes in `parsed`, tokenized into signature and body parts."""
+    found: List[tuple] = []
+    for cls in iter_class_nodes(parsed):
+        found.append(("class", cls.name, cls.qualified_name, cls.container, cls.node))
+    for fn in iter_function_nodes(parsed):
+        found.append(("function", fn.name, fn.qualified_name, fn.container, fn.node))
+    ids = {item[4].id for item in found}
+    result = []
+    for kind, name, qualified, container, node in found:
+        nested = ids - {node.id}
+        name_node = node.child_by_field_name("name")
+        body = _body_node(node)
+        skip = {n.id for n in (name_node, body) if n is not None}
+        signature = tuple(_tokens(node, parsed.source, skip, nested))
+        body_tokens = tuple(_tokens(body, parsed.source, set(), nested)) if body is not None else ()
+        result.append(Declaration(kind, name, qualified, container, node, signature, body_tokens))
+    result.sort(key=lambda d: d.node.start_byte)
+    return result
+
+
+def _similarity(a: Sequence[str], b: Sequence[str]) -> float:
+    if not a and not b:
+        return 1.0
+    common = sum((Counter(a) & Counter(b)).values())
+    return 2 * common / (len(a) + len(b))
+
+
+def diff_declarations(old: List[Declaration], new: List[Declaration]) -> List[Change]:
+    """
+    Match declarations GumTree-style: first by identical qualified name, then by
+    identical bodies (renames), then by best token similarity above
+    `RENAME_SIMILARITY`. Whatever is left is added or removed.
+    """
+    matches: List[Tuple[Declaration, Declaration, Optional[float]]] = []
+    old_left = list(old)
+    new_by_key: Dict[Tuple[str, str], List[Declaration]] = {}
+    for decl in new:
+        new_by_key.setdefault((decl.kind, decl.qualified_name), []).append(decl)
+    unmatched_old = []
+    for decl in old_left:
+        bucket = new_by_key.get((decl.kind, decl.qualified_name))
+        if bucket:
+            matches.append((decl, bucket.pop(0), None))
+        else:
+            unmatched_old.append(decl)
+    unmatched_new = [d for bucket in new_by_key.values() for d in bucket]
+    unmatched_new.sort(key=lambda d: d.node.start_byte)
+
+    # Identical body + same container, different name: a pure rename.
+    remaining_old = []
+    for decl in unmatched_old:
+        if len(decl.signature) + len(decl.body) < MIN_RENAME_TOKENS:
+            remaining_old.append(decl)
+            continue
+        twin = next(
+            (n for n in unmatched_new
+             if n.kind == decl.kind and n.container == decl.container and n.body == decl.body and n.body),
+            None,
+        )
+        if twin is not None:
+            unmatched_new.remove(twin)
+            matches.append((decl, twin, 1.0))
+        else:
+            remaining_old.append(decl)
+
+    # Best-similarity pairing for renames that also edited the body.
+    candidates = []
+    for o in remaining_old:
+        if len(o.signature) + len(o.body) < MIN_RENAME_TOKENS:
+            continue
+        for n in unmatched_new:
+            if n.kind != o.kind or n.container != o.container:
+                continue
+            score = _similarity(o.signature + o.body, n.signature + n.body)
+            if score >= RENAME_SIMILARITY:
+                candidates.append((-score, o.node.start_byte, n.node.start_byte, o, n))
+    used_old: Set[int] = set()
+    used_new: Set[int] = set()
+    for neg_score, _, _, o, n in sorted(candidates, key=lambda c: c[:3]):
+        if id(o) in used_old or id(n) in used_new:
+            continue
+        used_old.add(id(o))
+        used_new.add(id(n))
+        matches.append((o, n, round(-neg_score, 3)))
+
+    changes: List[Change] = []
+    for o, n, score in matches:
+        signature_changed = o.signature != n.signature
+        body_changed = o.body != n.body
+        if o.qualified_name != n.qualified_name:
+            change = "renamed"
+        elif signature_changed:
+            change = "signature_changed"
+        elif body_changed:
+            change = "body_changed"
+        else:
+            continue
+        changes.append(
+            Change(
+                change=change,
+                kind=n.kind,
+                name=n.qualified_name,
+                old_name=o.qualified_name if change == "renamed" else None,
+                old=o.lines,
+                new=n.lines,
+                signature_changed=signature_changed,
+                body_changed=body_changed,
+                similarity=score if change == "renamed" else None,
+            )
+        )
+    for o in remaining_old:
+        if id(o) not in used_old:
+            changes.append(Change("removed", o.kind, o.qualified_name, old=o.lines))
+    for n in unmatched_new:
+        if id(n) not in used_new:
+            changes.append(Change("added", n.kind, n.qualified_name, new=n.lines))
+
+    def order(c: Change) -> tuple:
+        position = (c.new or c.old)["start_line"]
+        return (0 if c.new else 1, position, c.name)
+
+    return sorted(changes, key=order)
+
+
+def diff_files(old_path: Path, new_path: Path, language: Optional[str] = None) -> List[Change]:
+    """Semantic changes between two versions of a source file (formatting/comments ignored)."""
+    new_parsed = parse_file(new_path, language)
+    old_parsed = parse_file(old_path, language or new_parsed.language)
+    if old_parsed.language != new_parsed.language:
+        raise ValueError(f"Cannot diff {old_parsed.language} against {new_parsed.language}; pass --language")
+    return diff_declarations(declarations(old_parsed), declarations(new_parsed))
+
+
+def changes_to_json(changes: Sequence[Change]) -> str:
+    return json.dumps([c.to_dict() for c in changes], indent=2)
+
+
+_SYMBOLS = {"added": "+", "removed": "-", "renamed": ">", "signature_changed": "~", "body_changed": "~"}
+
+
+def changes_to_text(changes: Sequence[Change]) -> str:
+    lines = []
+    for c in changes:
+        line = (c.new or c.old)["start_line"]
+        if c.change == "renamed":
+            detail = f"{c.old_name} -> {c.name}"
+            extra = [label for flag, label in ((c.signature_changed, "signature"), (c.body_changed, "body")) if flag]
+            if extra:
+                detail += f" ({', '.join(extra)} changed)"
+        elif c.change == "signature_changed":
+            detail = f"{c.name} (signature{' and body' if c.body_changed else ''} changed)"
+        elif c.change == "body_changed":
+            detail = f"{c.name} (body changed)"
+        else:
+            detail = c.name
+        lines.append(f"{_SYMBOLS[c.change]} {c.kind} {detail}  [line {line}]")
+    return "\n".join(lines) + ("\n" if lines else "")
+
+
+__all__ = [
+    "Change",
+    "Declaration",
+    "changes_to_json",
+    "changes_to_text",
+    "declarations",
+    "diff_declarations",
+    "diff_files",
+]
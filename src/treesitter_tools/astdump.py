@@ -0,0 +1,137 @@
+"""Serialize syntax trees as stable JSON, S-expressions, or GraphViz DOT."""
+
+from __future__ import annotations
+
+import json
+from typing import List, Optional
+
+from tree_sitter import Node
+
+from .core import ParsedFile
+
+SCHEMA = "treesitter-tools/ast"
+SCHEMA_VERSION = 1
+
+# Leaf text longer than this is cut with "..." in JSON/DOT output.
+MAX_TEXT = 80
+
+
+def _position(point, byte: int) -> dict:
+    return {"line": point[0] + 1, "column": point[1] + 1, "byte": byte}
+
+
+def _clip(text: str) -> str:
+    return text if len(text) <= MAX_TEXT else text[: MAX_TEXT - 3] + "..."
+
+
+def node_to_dict(
+    node: Node,
+    source: bytes,
+    max_depth: Optional[int] = None,
+    named_only: bool = False,
+    field: Optional[str] = None,
+    depth: int = 0,
+) -> dict:
+    """
+    One node in the documented JSON shape (see README "AST Export"). Leaves carry
+    their `text`; nodes whose children were cut by `max_depth` carry `truncated`.
+    """
+    data = {
+        "type": node.type,
+        "named": node.is_named,
+        "field": field,
+        "start": _position(node.start_point, node.start_byte),
+        "end": _position(node.end_point, node.end_byte),
+    }
+    if node.is_error:
+        data["error"] = True
+    if node.is_missing:
+        data["missing"] = True
+    if node.child_count == 0:
+        data["text"] = _clip(source[node.start_byte : node.end_byte].decode("utf-8", "replace"))
+    kept = [(i, child) for i, child in enumerate(node.children) if child.is_named or not named_only]
+    if max_depth is not None and depth >= max_depth and kept:
+        data["truncated"] = True
+        data["child_count"] = len(kept)
+        data["children"] = []
+        return data
+    data["children"] = [
+        node_to_dict(child, source, max_depth, named_only, node.field_name_for_child(i), depth + 1)
+        for i, child in kept
+    ]
+    return data
+
+
+def tree_to_dict(parsed: ParsedFile, label: str, max_depth: Optional[int] = None, named_only: bool = False) -> dict:
+    return {
+        "schema": SCHEMA,
+        "version": SCHEMA_VERSION,
+        "path": label,
+        "language": parsed.language,
+        "root": node_to_dict(parsed.root, parsed.source, max_depth, named_only),
+    }
+
+
+def tree_to_json(tree: dict) -> str:
+    return json.dumps(tree, indent=2)
+
+
+def _sexp_lines(node: dict, indent: int, out: List[str]) -> None:
+    pad = "  " * indent
+    label = f"{node['field']}: " if node["field"] else ""
+    start, end = node["start"], node["end"]
+    # Same 0-based [row, column] ranges as `tree-sitter parse`.
+    span = f"[{start['line'] - 1}, {start['column'] - 1}] - [{end['line'] - 1}, {end['column'] - 1}]"
+    if not node["named"]:
+        out.append(f"{pad}{label}{json.dumps(node['type'])} {span}")
+        return
+    kind = f"MISSING {node['type']}" if node.get("missing") else node["type"]
+    out.append(f"{pad}{label}({kind} {span}")
+    for child in node["children"]:
+        _sexp_lines(child, indent + 1, out)
+    if node.get("truncated"):
+        out.append(f"{pad}  ...")
+    out[-1] += ")"
+
+
+def tree_to_sexp(tree: dict) -> str:
+    out: List[str] = []
+    _sexp_lines(tree["root"], 0, out)
+    return "\n".join(out) + "\n"
+
+
+def _dot_escape(text: str) -> str:
+    return text.replace("\\", "\\\\").replace('"', '\\"').replace("\n", "\\n")
+
+
+def tree_to_dot(tree: dict) -> str:
+    lines = ["digraph ast {", "  node [shape=box, fontname=monospace];"]
+    counter = [0]
+
+    def visit(node: dict) -> str:
+        node_id = f"n{counter[0]}"
+        counter[0] += 1
+        start, end = node["start"], node["end"]
+        label = _dot_escape(node["type"]) + f"\\n{start['line']}:{start['column']}-{end['line']}:{end['column']}"
+        if "text" in node and node["named"]:
+            label += "\\n" + _dot_escape(node["text"])
+        if node.get("truncated"):
+            label += f"\\n(+{node['child_count']} children)"
+        attrs = [f'label="{label}"']
+        if not node["named"]:
+            attrs.append("style=dashed")
+        if node.get("error") or node.get("missing"):
+            attrs.append("color=red")
+        lines.append(f"  {node_id} [{', '.join(attrs)}];")
+        for child in node["children"]:
+            child_id = visit(child)
+            edge = f' [label="{child["field"]}"]' if child["field"] else ""
+            lines.append(f"  {node_id} -> {child_id}{edge};")
+        return node_id
+
+    visit(tree["root"])
+    lines.append("}")
+    return "\n".join(lines) + "\n"
+
+
+__all__ = ["SCHEMA", "SCHEMA_VERSION", "node_to_dict", "tree_to_dict", "tree_to_dot", "tree_to_json", "tree_to_sexp"]
@@ -0,0 +1,227 @@
+"""
+Batch mode over stdin/stdout (`serve --stdin-batch`) for editors and agents.
+
+Every message is framed like the Language Server Protocol: a `Content-Length: N`
+header line, a blank line, then N bytes of UTF-8 JSON. A request names a `command`,
+a `path`, and optionally the buffer `content`, so unsaved buffers are analysed
+without temp files; without `content` the file is read from disk under the root.
+Responses go to stdout in request order, one per request, echoing its `id`.
+Trees are kept per path, so re-sending an edited buffer re-parses incrementally.
+"""
+
+from __future__ import annotations
+
+import json
+import sys
+from pathlib import Path
+from typing import Any, BinaryIO, Callable, Dict, Optional
+
+from tree_sitter import Query
+
+from . import __version__, overlay
+from .core import ParsedFile, detect_language, is_binary_file, load_language, query_tree, symbols_from_tree
+from .diagnostics import file_diagnostics
+from .directives import file_directives
+from .editor import document_symbols, folding_ranges, selection_ranges
+from .incremental import IncrementalSession
+from .literals import file_literals
+from .metrics import function_metrics
+from .querylib import compile_query
+from .skeleton import skeleton_source
+
+# Requests larger than this are refused (and the stream abandoned) rather than buffered.
+MAX_MESSAGE_BYTES = 64 * 1024 * 1024
+
+# Error codes in responses.
+INVALID_FRAME = "invalid-frame"
+INVALID_REQUEST = "invalid-request"
+UNKNOWN_COMMAND = "unknown-command"
+FAILED = "failed"
+
+
+class BatchError(Exception):
+    def __init__(self, code: str, message: str):
+        super().__init__(message)
+        self.code = code
+
+
+def read_message(stream: BinaryIO) -> Optional[Any]:
+    """The next framed JSON message, or None at end of input between messages."""
+    length = None
+    while True:
+        line = stream.readline(1024)
+        if not line:
+            if length is None:
+                return None
+            raise BatchError(INVALID_FRAME, "End of input inside a message header")
+        line = line.strip()
+        if not line:
+            if length is None:
+                continue  # blank lines between messages are tolerated
+            break
+        name, _, value = line.decode("ascii", "replace").partition(":")
+        if name.strip().lower() == "content-length":
+            try:
+                length = int(value.strip())
+            except ValueError:
+                raise BatchError(INVALID_FRAME, f"Invalid Content-Length: {value.strip()!r}") from None
+    if length < 0 or length > MAX_MESSAGE_BYTES:
+        raise BatchError(INVALID_FRAME, f"Message of {length} bytes exceeds the {MAX_MESSAGE_BYTES} byte limit")
+    body = stream.read(length)
+    if len(body) < length:
+        raise BatchError(INVALID_FRAME, f"End of input after {len(body)} of {length} bytes")
+    try:
+        return json.loads(body)
+    except (json.JSONDecodeError, UnicodeDecodeError) as exc:
+        raise BatchError(INVALID_REQUEST, f"Invalid JSON: {exc}") from exc
+
+
+def write_message(stream: BinaryIO, message: Any) -> None:
+    body = json.dumps(message).encode("utf-8")
+    stream.write(b"Content-Length: %d\r\n\r\n" % len(body) + body)
+    stream.flush()
+
+
+def _symbols(parsed: ParsedFile, label: str, options: dict) -> Any:
+    symbols = symbols_from_tree(parsed.root, parsed.source, parsed.language, options.get("max_chunk_size"))
+    rows = []
+    for symbol in symbols:
+        data = symbol.to_dict()
+        if not options.get("include_content"):
+            data.pop("content", None)
+        rows.append(data)
+    return rows
+
+
+def _parse(parsed: ParsedFile, label: str, options: dict) -> Any:
+    root = parsed.root
+    result = {
+        "type": root.type,
+        "has_error": root.has_error,
+        "start_line": root.start_point[0] + 1,
+        "end_line": root.end_point[0] + 1,
+        "child_count": root.child_count,
+    }
+    if options.get("sexp"):
+        result["sexp"] = str(root)
+    return result
+
+
+def _skeleton(parsed: ParsedFile, label: str, options: dict) -> Any:
+    text, elided = skeleton_source(parsed)
+    return {"text": text, "elided": elided}
+
+
+COMMANDS: Dict[str, Callable[[ParsedFile, str, dict], Any]] = {
+    "parse": _parse,
+    "symbols": _symbols,
+    "diagnostics": lambda parsed, label, options: [d.to_dict() for d in file_diagnostics(parsed, label)],
+    "metrics": lambda parsed, label, options: [m.to_dict() for m in function_metrics(parsed, label)],
+    "skeleton": _skeleton,
+    "directives": lambda parsed, label, options: [d.to_dict() for d in file_directives(parsed, label)],
+    "strings": lambda parsed, label, options: [s.to_dict() for s in file_literals(parsed, label)],
+    "folding-ranges": lambda parsed, label, options: [
+        r.to_dict() for r in folding_ranges(parsed, options.get("encoding", "utf-16"))
+    ],
+    "document-symbols": lambda parsed, label, options: [
+        s.to_dict() for s in document_symbols(parsed, options.get("encoding", "utf-16"))
+    ],
+    "selection-range": lambda parsed, label, options: [
+        r.to_dict() for r in selection_ranges(
+            parsed, [(p["line"], p["character"]) for p in options.get("positions", [])], options.get("encoding", "utf-16")
+        )
+    ],
+}
+
+
+class BatchSession:
+    """Answers batch requests; `root` bounds the files read from disk."""
+
+    def __init__(self, root: Path):
+        self.root = Path(root).resolve()
+        self.trees = IncrementalSession()
+
+    def _source(self, request: dict, label: str) -> bytes:
+        content = request.get("content")
+        if content is not None:
+            if not isinstance(content, str):
+                raise BatchError(INVALID_REQUEST, "'content' must be a string")
+            return content.encode("utf-8")
+        path = (self.root / label).resolve()
+        try:
+            path.relative_to(self.root)
+        except ValueError:
+            raise BatchError(INVALID_REQUEST, f"Path is outside the root and no 'content' was sent: {label}") from None
+        if not overlay.is_file(path):
+            raise BatchError(FAILED, f"No such file: {label}")
+        if is_binary_file(path):
+            raise BatchError(FAILED, f"Refusing to parse binary file: {label}")
+        return overlay.read_bytes(path)
+
+    def _run(self, request: Any) -> Any:
+        if not isinstance(request, dict):
+            raise BatchError(INVALID_REQUEST, "Request must be a JSON object")
+        command = request.get("command")
+        if command == "ping":
+            return {"version": __version__, "commands": sorted([*COMMANDS, "query", "forget"])}
+        label = request.get("path")
+        if not isinstance(label, str) or not label:
+            raise BatchError(INVALID_REQUEST, "'path' is required")
+        if command == "forget":
+            self.trees.forget(Path(label))
+            return None
+        if command != "query" and command not in COMMANDS:
+            raise BatchError(UNKNOWN_COMMAND, f"Unknown command: {command!r}")
+        options = request.get("options") or {}
+        if not isinstance(options, dict):
+            raise BatchError(INVALID_REQUEST, "'options' must be an object")
+        language = detect_language(Path(label), request.get("language"))
+        if not language:
+            raise BatchError(FAILED, f"Cannot detect Tree-sitter language for {label}; pass 'language'")
+        source = self._source(request, label)
+        tree = self.trees.parse(Path(label), source, language)
+        parsed = ParsedFile(Path(label), language, source, tree.root_node)
+        if command == "query":
+            return self._query(parsed, options)
+        return COMMANDS[command](parsed, label, options)
+
+    def _query(self, parsed: ParsedFile, options: dict) -> Any:
+        if options.get("named"):
+            return query_tree(parsed.root, parsed.source, compile_query(parsed.language, options["named"]))
+        text = options.get("query")
+        if not isinstance(text, str) or not text.strip():
+            raise BatchError(INVALID_REQUEST, "query needs options.query or options.named")
+        return query_tree(parsed.root, parsed.source, Query(load_language(parsed.language), text))
+
+    def handle(self, request: Any) -> dict:
+        """One response: `{"id", "ok": true, "result"}` or `{"id", "ok": false, "error"}`."""
+        request_id = request.get("id") if isinstance(request, dict) else None
+        try:
+            result = self._run(request)
+        except BatchError as exc:
+            return {"id": request_id, "ok": False, "error": {"code": exc.code, "message": str(exc)}}
+        except (ValueError, RuntimeError, OSError) as exc:
+            return {"id": request_id, "ok": False, "error": {"code": FAILED, "message": str(exc)}}
+        return {"id": request_id, "ok": True, "result": result}
+
+    def serve(self, stdin: Optional[BinaryIO] = None, stdout: Optional[BinaryIO] = None) -> None:
+        """Answer requests until end of input or a `shutdown` command; framing errors end the session."""
+        stdin = stdin or sys.stdin.buffer
+        stdout = stdout or sys.stdout.buffer
+        while True:
+            try:
+                request = read_message(stdin)
+            except BatchError as exc:
+                write_message(stdout, {"id": None, "ok": False, "error": {"code": exc.code, "message": str(exc)}})
+                if exc.code == INVALID_FRAME:
+                    return  # the stream position is lost
+                continue
+            if request is None:
+                return
+            if isinstance(request, dict) and request.get("command") == "shutdown":
+                write_message(stdout, {"id": request.get("id"), "ok": True, "result": None})
+                return
+            write_message(stdout, self.handle(request))
+
+
+__all__ = ["COMMANDS", "MAX_MESSAGE_BYTES", "BatchError", "BatchSession", "read_message", "write_message"]
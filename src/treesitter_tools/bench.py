@@ -0,0 +1,393 @@
+"""
+Benchmarks: parse throughput, query time, and memory per language over a corpus.
+
+Files are grouped by language and each language is measured in a fresh worker process
+(so its peak RSS is its own, not whatever an earlier language left behind). Sources are
+read up front, so the timings cover parsing, symbol extraction, and query execution
+only, never disk I/O; with `repeat` above one the fastest pass counts.
+
+`jobs` additionally times a parallel scan of the whole corpus at each worker count,
+for tuning `--jobs`. With a profile interval set, the per-language passes are sampled
+and the stacks can be written as a pprof profile (see `export.pprof`).
+"""
+
+from __future__ import annotations
+
+import json
+import platform
+import sys
+import threading
+import time
+from concurrent.futures import ProcessPoolExecutor
+from contextlib import nullcontext
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Dict, List, Optional, Sequence, Tuple
+
+from tree_sitter import Query, QueryCursor
+
+from . import reproducible
+from .core import detect_language, get_parser, is_binary_file, iter_source_files, load_language, symbols_from_tree
+from .memory import format_size, peak_rss
+
+DEFAULT_QUERIES = ("tags",)
+PROFILE_INTERVAL = 0.005  # seconds between stack samples
+
+
+@dataclass
+class LanguageBench:
+    language: str
+    files: int = 0
+    bytes: int = 0
+    errors: int = 0  # files whose tree has syntax errors (still timed)
+    load_seconds: float = 0.0  # grammar load and parser setup
+    parse_seconds: float = 0.0
+    extract_seconds: float = 0.0
+    query_seconds: Dict[str, float] = field(default_factory=dict)
+    peak_rss: Optional[int] = None
+    baseline_rss: Optional[int] = None  # peak RSS before the grammar was loaded
+    grammar: Dict[str, object] = field(default_factory=dict)
+    baseline: Optional[Dict[str, object]] = None  # the same language in an earlier report
+
+    @property
+    def parse_mb_per_s(self) -> float:
+        return self.bytes / (1 << 20) / self.parse_seconds if self.parse_seconds else 0.0
+
+    @property
+    def files_per_s(self) -> float:
+        return self.files / self.parse_seconds if self.parse_seconds else 0.0
+
+    def to_dict(self) -> dict:
+        data = {
+            "language": self.language,
+            "grammar": self.grammar,
+            "files": self.files,
+            "bytes": self.bytes,
+            "errors": self.errors,
+            "load_seconds": round(self.load_seconds, 6),
+            "parse_seconds": round(self.parse_seconds, 6),
+            "parse_mb_per_s": round(self.parse_mb_per_s, 3),
+            "files_per_s": round(self.files_per_s, 1),
+            "extract_seconds": round(self.extract_seconds, 6),
+            "query_seconds": {name: round(s, 6) for name, s in self.query_seconds.items()},
+            "peak_rss": self.peak_rss,
+            "baseline_rss": self.baseline_rss,
+        }
+        if self.baseline is not None:
+            data["baseline"] = self.baseline
+        return data
+
+
+@dataclass
+class WorkerBench:
+    jobs: int
+    files: int
+    bytes: int
+    seconds: float
+    speedup: float = 1.0  # relative to the first worker count measured
+
+    def to_dict(self) -> dict:
+        return {
+            "jobs": self.jobs,
+            "seconds": round(self.seconds, 6),
+            "files_per_s": round(self.files / self.seconds, 1) if self.seconds else 0.0,
+            "mb_per_s": round(self.bytes / (1 << 20) / self.seconds, 3) if self.seconds else 0.0,
+            "speedup": round(self.speedup, 2),
+        }
+
+
+@dataclass
+class BenchReport:
+    root: Path
+    repeat: int = 1
+    languages: List[LanguageBench] = field(default_factory=list)
+    workers: List[WorkerBench] = field(default_factory=list)
+    samples: Dict[Tuple, List[int]] = field(default_factory=dict)  # stack -> [count, wall ns]
+    profile_interval: Optional[float] = None
+
+    def totals(self) -> dict:
+        files = sum(b.files for b in self.languages)
+        size = sum(b.bytes for b in self.languages)
+        seconds = sum(b.parse_seconds for b in self.languages)
+        return {
+            "files": files,
+            "bytes": size,
+            "parse_seconds": round(seconds, 6),
+            "parse_mb_per_s": round(size / (1 << 20) / seconds, 3) if seconds else 0.0,
+            "files_per_s": round(files / seconds, 1) if seconds else 0.0,
+            "peak_rss": max((b.peak_rss for b in self.languages if b.peak_rss is not None), default=None),
+        }
+
+    def to_dict(self) -> dict:
+        return {
+            "root": self.root.as_posix(),
+            "repeat": self.repeat,
+            "python": platform.python_version(),
+            "platform": platform.platform(),
+            "languages": [b.to_dict() for b in self.languages],
+            "total": self.totals(),
+            "workers": [w.to_dict() for w in self.workers],
+        }
+
+    def to_json(self) -> str:
+        return json.dumps(self.to_dict(), indent=2)
+
+    def to_pprof(self) -> bytes:
+        from .export.pprof import encode_profile
+
+        interval = int((self.profile_interval or PROFILE_INTERVAL) * 1e9)
+        duration = sum(values[1] for values in self.samples.values())
+        return encode_profile(self.samples, interval, duration, reproducible.time_ns() - duration)
+
+    def to_text(self) -> str:
+        queries = sorted({name for b in self.languages for name in b.query_seconds})
+        header = ["language", "files", "size", "parse", "MB/s", "files/s", "extract", *queries, "peak RSS"]
+        if any(b.baseline for b in self.languages):
+            header.append("vs baseline")
+        rows = [header]
+        for b in self.languages:
+            row = [
+                b.language, str(b.files), format_size(b.bytes), f"{b.parse_seconds:.3f}s", f"{b.parse_mb_per_s:.2f}",
+                f"{b.files_per_s:.0f}", f"{b.extract_seconds:.3f}s",
+                *(f"{b.query_seconds[q]:.3f}s" if q in b.query_seconds else "-" for q in queries),
+                format_size(b.peak_rss) if b.peak_rss is not None else "-",
+            ]
+            if len(header) > len(row):
+                row.append(_change(b.baseline) if b.baseline else "-")
+            rows.append(row)
+        widths = [max(len(row[i]) for row in rows) for i in range(len(header))]
+        runs = f"best of {self.repeat} runs" if self.repeat > 1 else "1 run"
+        lines = [f"Benchmark of {self.root.as_posix()} ({runs})", ""]
+        for row in rows:
+            cells = [row[0].ljust(widths[0])] + [cell.rjust(width) for cell, width in zip(row[1:], widths[1:])]
+            lines.append("  ".join(cells).rstrip())
+        total = self.totals()
+        lines.append("")
+        lines.append(
+            f"Total: {total['files']} files, {format_size(total['bytes'])} parsed in {total['parse_seconds']:.3f}s "
+            f"({total['parse_mb_per_s']:.2f} MB/s, {total['files_per_s']:.0f} files/s)"
+        )
+        if self.workers:
+            lines.append("")
+            lines.append("Workers (parallel scan of the whole corpus):")
+            for worker in self.workers:
+                data = worker.to_dict()
+                lines.append(
+                    f"  jobs={worker.jobs:<3} {data['seconds']:.3f}s  {data['files_per_s']:.0f} files/s  "
+                    f"{data['mb_per_s']:.2f} MB/s  x{data['speedup']:.2f}"
+                )
+        return "\n".join(lines)
+
+
+def _change(baseline: Dict[str, object]) -> str:
+    change = baseline.get("parse_change")
+    text = f"{change:+.1%}" if isinstance(change, float) else "-"
+    return text + (" (grammar changed)" if baseline.get("grammar_changed") else "")
+
+
+class Sampler:
+    """
+    Samples one thread's Python stack every `interval` seconds from a background
+    thread. Each sample is weighted by the wall time since the previous one, so time
+    spent in C (the parser itself) is not undercounted when it delays a sample.
+    """
+
+    def __init__(self, interval: float = PROFILE_INTERVAL):
+        self.interval = interval
+        self.samples: Dict[Tuple, List[int]] = {}
+        self._target = threading.get_ident()
+        self._stop = threading.Event()
+        self._thread = threading.Thread(target=self._run, daemon=True)
+
+    def _run(self) -> None:
+        last = time.perf_counter_ns()
+        while not self._stop.wait(self.interval):
+            frame = sys._current_frames().get(self._target)
+            now = time.perf_counter_ns()
+            stack = []
+            while frame is not None and len(stack) < 128:
+                code = frame.f_code
+                name = getattr(code, "co_qualname", code.co_name)  # 3.11+
+                stack.append((name, code.co_filename, code.co_firstlineno, frame.f_lineno or 0))
+                frame = frame.f_back
+            if stack:
+                values = self.samples.setdefault(tuple(stack), [0, 0])
+                values[0] += 1
+                values[1] += now - last
+            last = now
+
+    def __enter__(self) -> "Sampler":
+        self._thread.start()
+        return self
+
+    def __exit__(self, *exc) -> None:
+        self._stop.set()
+        self._thread.join()
+
+
+def collect_corpus(
+    root: Path, include: Optional[Sequence[str]] = None, exclude: Optional[Sequence[str]] = None,
+    languages: Optional[Sequence[str]] = None,
+) -> Dict[str, List[Path]]:
+    """Source files under `root` grouped by detected language (restricted to `languages` when given)."""
+    root = Path(root)
+    paths = [root] if root.is_file() else iter_source_files(root, include, exclude)
+    corpus: Dict[str, List[Path]] = {}
+    for path in paths:
+        language = detect_language(path)
+        if language is None or is_binary_file(path) or (languages and language not in languages):
+            continue
+        corpus.setdefault(language, []).append(path)
+    return dict(sorted(corpus.items()))
+
+
+def _grammar_info(language: str) -> Dict[str, object]:
+    from .cache import grammar_version
+
+    grammar = load_language(language)
+    version = getattr(grammar, "semantic_version", None)
+    return {
+        "fingerprint": grammar_version(language),
+        "abi": getattr(grammar, "abi_version", None),
+        "version": ".".join(str(part) for part in version) if version else None,
+    }
+
+
+def bench_language(
+    language: str,
+    paths: Sequence[Path],
+    queries: Dict[str, str],
+    repeat: int = 1,
+    profile_interval: Optional[float] = None,
+) -> Tuple[LanguageBench, Dict[Tuple, List[int]]]:
+    """
+    Time parsing, symbol extraction, and each query (name -> source) over `paths`.
+    Returns the measurements and the sampled stacks (empty without `profile_interval`).
+    """
+    result = LanguageBench(language, baseline_rss=reproducible.measured(peak_rss()))
+    sources = [Path(path).read_bytes() for path in paths]
+    result.files, result.bytes = len(sources), sum(len(s) for s in sources)
+    start = reproducible.perf_counter()
+    parser = get_parser(language)
+    compiled = {name: Query(load_language(language), text) for name, text in queries.items()}
+    result.load_seconds = reproducible.perf_counter() - start
+    result.grammar = _grammar_info(language)
+    sampler = Sampler(profile_interval) if profile_interval else None
+    with sampler or nullcontext():
+        for run in range(max(repeat, 1)):
+            parse = extract = 0.0
+            query_times = dict.fromkeys(compiled, 0.0)
+            errors = 0
+            for source in sources:
+                start = reproducible.perf_counter()
+                tree = parser.parse(source)
+                parse += reproducible.perf_counter() - start
+                errors += tree.root_node.has_error
+                start = reproducible.perf_counter()
+                symbols_from_tree(tree.root_node, source, language)
+                extract += reproducible.perf_counter() - start
+                for name, query in compiled.items():
+                    start = reproducible.perf_counter()
+                    QueryCursor(query).matches(tree.root_node)
+                    query_times[name] += reproducible.perf_counter() - start
+            result.parse_seconds = parse if run == 0 else min(result.parse_seconds, parse)
+            result.extract_seconds = extract if run == 0 else min(result.extract_seconds, extract)
+            for name, seconds in query_times.items():
+                result.query_seconds[name] = seconds if run == 0 else min(result.query_seconds[name], seconds)
+            result.errors = errors
+    result.peak_rss = reproducible.measured(peak_rss())
+    return result, sampler.samples if sampler is not None else {}
+
+
+def _queries_for(language: str, names: Sequence[str], strict: bool) -> Dict[str, str]:
+    """Query sources by name for `language`; missing ones raise when `strict`, else are skipped."""
+    from .querylib import find_query, load_query
+
+    found = {}
+    for name in names:
+        if find_query(language, name) is not None:
+            found[name] = load_query(language, name)
+        elif strict:
+            load_query(language, name)  # raises with the available names
+    return found
+
+
+def bench_workers(paths: Sequence[Path], jobs: Sequence[int]) -> List[WorkerBench]:
+    """Wall time of a parallel scan of `paths` at each worker count."""
+    from .parallel import scan_parallel
+
+    size = sum(Path(p).stat().st_size for p in paths)
+    results: List[WorkerBench] = []
+    for count in jobs:
+        start = reproducible.perf_counter()
+        for _ in scan_parallel(paths, count):
+            pass
+        results.append(WorkerBench(count, len(paths), size, reproducible.perf_counter() - start))
+    for worker in results:
+        worker.speedup = results[0].seconds / worker.seconds if worker.seconds else 0.0
+    return results
+
+
+def compare(report: BenchReport, baseline: dict) -> None:
+    """Attach per-language changes against `baseline` (an earlier report's `to_dict`)."""
+    earlier = {entry["language"]: entry for entry in baseline.get("languages", [])}
+    for bench in report.languages:
+        before = earlier.get(bench.language)
+        if before is None:
+            continue
+        rate = before.get("parse_mb_per_s") or 0.0
+        bench.baseline = {
+            "parse_mb_per_s": rate,
+            "parse_change": bench.parse_mb_per_s / rate - 1 if rate else None,
+            "grammar_changed": before.get("grammar", {}).get("fingerprint") != bench.grammar.get("fingerprint"),
+        }
+
+
+def run_bench(
+    root: Path,
+    include: Optional[Sequence[str]] = None,
+    exclude: Optional[Sequence[str]] = None,
+    languages: Optional[Sequence[str]] = None,
+    queries: Optional[Sequence[str]] = None,
+    repeat: int = 1,
+    jobs: Sequence[int] = (),
+    isolate: bool = True,
+    profile_interval: Optional[float] = None,
+) -> BenchReport:
+    """
+    Benchmark every language found under `root`. `queries` names library queries to
+    time (default: `tags` wherever a language has one; named queries a language lacks
+    raise ValueError). Without `isolate` every language runs in this process, and
+    peak RSS is then cumulative.
+    """
+    corpus = collect_corpus(root, include, exclude, languages)
+    report = BenchReport(Path(root), repeat=max(repeat, 1), profile_interval=profile_interval)
+    for language, paths in corpus.items():
+        sources = _queries_for(language, queries if queries is not None else DEFAULT_QUERIES, queries is not None)
+        if isolate:
+            with ProcessPoolExecutor(max_workers=1) as pool:
+                bench, stacks = pool.submit(bench_language, language, paths, sources, repeat, profile_interval).result()
+        else:
+            bench, stacks = bench_language(language, paths, sources, repeat, profile_interval)
+        report.languages.append(bench)
+        for stack, (count, wall) in stacks.items():
+            values = report.samples.setdefault(stack, [0, 0])
+            values[0] += count
+            values[1] += wall
+    if jobs:
+        report.workers = bench_workers([p for paths in corpus.values() for p in paths], jobs)
+    return report
+
+
+__all__ = [
+    "DEFAULT_QUERIES",
+    "BenchReport",
+    "LanguageBench",
+    "Sampler",
+    "WorkerBench",
+    "bench_language",
+    "bench_workers",
+    "collect_corpus",
+    "compare",
+    "run_bench",
+]
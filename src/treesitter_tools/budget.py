@@ -0,0 +1,174 @@
+"""Token budgeting: fit extracted symbols into an LLM context window."""
+
+from __future__ import annotations
+
+from dataclasses import dataclass
+from typing import Callable, Dict, List, Optional, Sequence, Tuple
+
+from .chunker import TokenCounter, estimate_tokens
+from .core import CodeSymbol
+
+# Bodies are only truncated (rather than omitted) when at least this many tokens are left.
+MIN_TRUNCATED_TOKENS = 16
+
+_TOKENIZERS: Dict[str, Callable[[Optional[str]], TokenCounter]] = {}
+
+
+def register_tokenizer(name: str, factory: Callable[[Optional[str]], TokenCounter]) -> None:
+    """Make `--tokenizer NAME[:ARG]` available; `factory(ARG)` returns a text -> count function."""
+    _TOKENIZERS[name] = factory
+
+
+def _heuristic(_: Optional[str]) -> TokenCounter:
+    return estimate_tokens
+
+
+def _tiktoken(arg: Optional[str]) -> TokenCounter:
+    try:
+        import tiktoken
+    except ImportError as exc:
+        raise RuntimeError("The tiktoken tokenizer requires the optional 'tiktoken' package") from exc
+    name = arg or "cl100k_base"
+    try:
+        encoding = tiktoken.get_encoding(name)
+    except ValueError:
+        try:
+            encoding = tiktoken.encoding_for_model(name)
+        except KeyError:
+            raise ValueError(f"Unknown tiktoken encoding or model: {name}") from None
+    return lambda text: len(encoding.encode(text, disallowed_special=()))
+
+
+register_tokenizer("heuristic", _heuristic)
+register_tokenizer("tiktoken", _tiktoken)
+
+
+def get_tokenizer(spec: str = "heuristic") -> TokenCounter:
+    """Resolve `heuristic`, `tiktoken`, `tiktoken:o200k_base`, `tiktoken:gpt-4o`, or a registered name."""
+    name, _, arg = spec.partition(":")
+    factory = _TOKENIZERS.get(name)
+    if factory is None:
+        raise ValueError(f"Unknown tokenizer '{name}' (available: {', '.join(sorted(_TOKENIZERS))})")
+    return factory(arg or None)
+
+
+@dataclass
+class BudgetReport:
+    max_tokens: int
+    used_tokens: int = 0
+    full_bodies: int = 0
+    truncated_bodies: int = 0
+    omitted_bodies: int = 0
+    dropped_docs: bool = False
+    dropped_symbols: int = 0
+
+    @property
+    def within_budget(self) -> bool:
+        return self.used_tokens <= self.max_tokens
+
+    def to_dict(self) -> dict:
+        return {
+            "max_tokens": self.max_tokens,
+            "used_tokens": self.used_tokens,
+            "full_bodies": self.full_bodies,
+            "truncated_bodies": self.truncated_bodies,
+            "omitted_bodies": self.omitted_bodies,
+            "dropped_docs": self.dropped_docs,
+            "dropped_symbols": self.dropped_symbols,
+        }
+
+    def summary(self) -> str:
+        parts = [f"{self.full_bodies} full", f"{self.truncated_bodies} truncated", f"{self.omitted_bodies} omitted"]
+        text = f"Budget: {self.used_tokens}/{self.max_tokens} tokens; bodies {', '.join(parts)}"
+        if self.dropped_docs:
+            text += "; docs dropped"
+        if self.dropped_symbols:
+            text += f"; {self.dropped_symbols} symbols dropped"
+        return text
+
+
+def _header(sym: CodeSymbol, with_docs: bool) -> str:
+    parts = [f"{sym.kind} {sym.name} {sym.start_line}-{sym.end_line}", sym.signature or ""]
+    if with_docs:
+        parts.append(sym.doc or sym.docstring or "")
+    return "\n".join(parts)
+
+
+def truncate_text(text: str, budget: int, count: TokenCounter) -> Tuple[str, int]:
+    """Leading lines of `text` that fit in `budget` tokens, plus a `... (N more lines)` marker."""
+    lines = text.splitlines(keepends=True)
+    kept: List[str] = []
+    for i, line in enumerate(lines):
+        marker = f"... ({len(lines) - i - 1} more lines)\n"
+        candidate = "".join(kept) + line
+        if count(candidate + marker) > budget:
+            break
+        kept.append(line)
+    remaining = len(lines) - len(kept)
+    if not remaining:
+        return text, 0
+    return "".join(kept) + f"... ({remaining} more lines)\n", remaining
+
+
+def fit_symbols(groups: Sequence[List[CodeSymbol]], max_tokens: int, count: TokenCounter = estimate_tokens) -> BudgetReport:
+    """
+    Trim symbol bodies in place so everything fits in `max_tokens`.
+
+    Every symbol's signature (and doc) is kept before any body is. Remaining budget
+    goes to full bodies, smallest first, so as many as possible survive intact; the
+    next body is truncated to fit and the rest are omitted (`elided` marks both). If
+    signatures alone overflow, docs are dropped and then trailing symbols.
+    """
+    report = BudgetReport(max_tokens=max_tokens)
+    flat = [(g, sym) for g in groups for sym in g]
+    headers = [count(_header(sym, True)) for _, sym in flat]
+    if sum(headers) > max_tokens:
+        report.dropped_docs = True
+        for _, sym in flat:
+            sym.doc = None
+            sym.docstring = None
+        headers = [count(_header(sym, False)) for _, sym in flat]
+    while flat and sum(headers) > max_tokens:
+        group, sym = flat.pop()
+        headers.pop()
+        group.remove(sym)
+        report.dropped_symbols += 1
+
+    used = sum(headers)
+    costs = [count(sym.content) if sym.content else 0 for _, sym in flat]
+    # Sort by (cost, source position) for a deterministic smallest-first fill.
+    order = sorted(range(len(flat)), key=lambda i: (costs[i], i))
+    truncated_one = False
+    for i in order:
+        sym = flat[i][1]
+        if not sym.content:
+            continue
+        if used + costs[i] <= max_tokens:
+            used += costs[i]
+            report.full_bodies += 1
+            continue
+        left = max_tokens - used
+        if not truncated_one and left >= MIN_TRUNCATED_TOKENS:
+            text, remaining = truncate_text(sym.content, left, count)
+            if remaining and count(text) <= left:
+                sym.content = text
+                sym.elided = "truncated"
+                used += count(text)
+                report.truncated_bodies += 1
+                truncated_one = True
+                continue
+        sym.content = None
+        sym.elided = "omitted"
+        report.omitted_bodies += 1
+    report.used_tokens = used
+    return report
+
+
+__all__ = [
+    "BudgetReport",
+    "MIN_TRUNCATED_TOKENS",
+    "fit_symbols",
+    "get_tokenizer",
+    "register_tokenizer",
+    "truncate_text",
+]
@@ -0,0 +1,299 @@
+"""
+Extraction as a build action, for Bazel, Buck, and other caching build systems.
+
+`action` extracts one source file per invocation into one output whose bytes depend only
+on the file's content, its path as given (relative to the execution root in a sandbox),
+and the options, never on timestamps or on the files around it, so a remote cache can
+serve it. `--out-dir` names the output by the SHA-256 of its bytes instead of a
+declared path.
+
+With `--persistent_worker` (the flag Bazel adds when it starts a worker), `action`
+answers WorkRequests on stdin, each holding the arguments of one action, so interpreter
+start-up and grammar loading are paid once per worker rather than once per file. Both
+of Bazel's worker protocols are spoken: varint-delimited protobuf (the default) and JSON
+(`requires-worker-protocol: json`); the first byte of input tells them apart. Requests
+are answered one at a time and in order, which multiplex workers allow as well;
+a request's `sandbox_dir` is the directory its paths are relative to.
+
+Long argument lists go in a parameter file holding one argument per line (Bazel's
+`multiline` format). `--param-file FILE` (or `--param-file=FILE`) is replaced by the
+arguments it holds, on any command line; the Bazel-style `@FILE` is expanded in the
+arguments of `action` and of WorkRequests.
+"""
+
+from __future__ import annotations
+
+import hashlib
+import io
+import json
+import os
+import sys
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import BinaryIO, Callable, Collection, List, Optional, Sequence, Tuple
+
+from .export.protowire import VARINT, delimited, int_field, iter_fields, read_delimited, str_field
+
+PARAM_FILE_FLAG = "--param-file"
+WORKER_FLAG = "--persistent_worker"
+
+KINDS = ("symbols", "chunks")
+EXTENSIONS = {"json": ".json", "proto": ".pb"}
+
+
+def read_param_file(path: str) -> List[str]:
+    """The arguments of a parameter file, one per line."""
+    try:
+        return Path(path).read_text(encoding="utf-8").splitlines()
+    except (OSError, UnicodeDecodeError) as exc:
+        raise ValueError(f"Cannot read parameter file {path}: {exc}") from exc
+
+
+def expand_params(argv: Sequence[str], at_files: bool = False) -> List[str]:
+    """`argv` with every `--param-file FILE` (and, with `at_files`, every `@FILE`) replaced by the file's arguments."""
+    expanded: List[str] = []
+    args = iter(argv)
+    for arg in args:
+        if arg == PARAM_FILE_FLAG:
+            path = next(args, None)
+            if path is None:
+                raise ValueError(f"{PARAM_FILE_FLAG} needs a FILE")
+            expanded.extend(read_param_file(path))
+        elif arg.startswith(PARAM_FILE_FLAG + "="):
+            expanded.extend(read_param_file(arg.split("=", 1)[1]))
+        elif at_files and arg.startswith("@") and len(arg) > 1:
+            expanded.extend(read_param_file(arg[1:]))
+        else:
+            expanded.append(arg)
+    return expanded
+
+
+def expand_command_line(argv: Sequence[str], value_options: Collection[str] = ()) -> List[str]:
+    """
+    `expand_params` for the CLI: `@FILE` counts after the `action` subcommand, as Bazel
+    runs it the same way outside a worker. `value_options` are the global options that
+    take a value (`--encoding NAME`), which is then not mistaken for the subcommand.
+    """
+    args = expand_params(argv)
+    command = _subcommand(args, value_options)
+    if command is None or args[command] != "action":
+        return args
+    return args[: command + 1] + expand_params(args[command + 1 :], at_files=True)
+
+
+def _subcommand(args: Sequence[str], value_options: Collection[str]) -> Optional[int]:
+    """The index of the first argument that is neither a global option nor an option's value."""
+    index = 0
+    while index < len(args):
+        arg = args[index]
+        if arg == "--":
+            return index + 1 if index + 1 < len(args) else None
+        if not arg.startswith("-"):
+            return index
+        index += 2 if arg in value_options else 1
+    return None
+
+
+def extract_action(
+    source: Path,
+    kind: str = "symbols",
+    fmt: str = "json",
+    language: Optional[str] = None,
+    max_tokens: int = 512,
+) -> bytes:
+    """
+    The output of one action: the `scan` record of `source` (kind "symbols") or its
+    chunks, as JSON or binary records. Extraction errors raise instead of being recorded,
+    so the action fails.
+    """
+    from . import schema
+    from .chunker import ChunkOptions, chunk_file, chunks_to_json
+    from .core import FileSymbols, extract_symbols, source_language
+
+    if kind not in KINDS:
+        raise ValueError(f"Unsupported kind '{kind}' (expected {' or '.join(KINDS)})")
+    if fmt not in EXTENSIONS:
+        raise ValueError(f"Unsupported format '{fmt}' (expected json or proto)")
+    source = Path(source)
+    if kind == "chunks":
+        chunks = chunk_file(source, ChunkOptions(max_tokens=max_tokens), language)
+        if fmt == "proto":
+            from .export.records import encode_chunks
+
+            return encode_chunks(chunks)
+        return (chunks_to_json(chunks) + "\n").encode("utf-8")
+    language = source_language(source, language)
+    report = FileSymbols(path=source, language=language, symbols=extract_symbols(source, language))
+    if fmt == "proto":
+        from .export.records import encode_files
+
+        return encode_files([report])
+    return (json.dumps(schema.conform("file", [report.to_dict()]), indent=2) + "\n").encode("utf-8")
+
+
+def content_path(out_dir: Path, payload: bytes, fmt: str) -> Path:
+    """Where `--out-dir` stores `payload`: named by the SHA-256 of its bytes."""
+    return Path(out_dir) / f"{hashlib.sha256(payload).hexdigest()}{EXTENSIONS[fmt]}"
+
+
+def write_output(path: Path, payload: bytes) -> None:
+    """Write `payload` through a temporary file, so an interrupted action leaves no partial output."""
+    path = Path(path)
+    path.parent.mkdir(parents=True, exist_ok=True)
+    partial = path.with_name(f".{path.name}.partial")
+    partial.write_bytes(payload)
+    os.replace(partial, path)
+
+
+@dataclass
+class WorkRequest:
+    arguments: List[str] = field(default_factory=list)
+    request_id: int = 0  # nonzero from multiplex workers
+    cancel: bool = False
+    verbosity: int = 0
+    sandbox_dir: Optional[str] = None
+
+
+@dataclass
+class WorkResponse:
+    exit_code: int = 0
+    output: str = ""
+    request_id: int = 0
+    was_cancelled: bool = False
+
+
+def decode_request(data: bytes) -> WorkRequest:
+    """A `blaze.worker.WorkRequest` message (inputs and their digests are not needed)."""
+    request = WorkRequest()
+    for number, wire_type, value in iter_fields(data):
+        if number == 1 and wire_type != VARINT:
+            request.arguments.append(value.decode("utf-8"))
+        elif number == 3 and wire_type == VARINT:
+            request.request_id = value - (1 << 64) if value >= 1 << 63 else value
+        elif number == 4 and wire_type == VARINT:
+            request.cancel = bool(value)
+        elif number == 5 and wire_type == VARINT:
+            request.verbosity = value
+        elif number == 6 and wire_type != VARINT:
+            request.sandbox_dir = value.decode("utf-8") or None
+    return request
+
+
+def encode_response(response: WorkResponse) -> bytes:
+    return (
+        int_field(1, response.exit_code)
+        + str_field(2, response.output)
+        + int_field(3, response.request_id)
+        + int_field(4, int(response.was_cancelled))
+    )
+
+
+def request_from_json(data: dict) -> WorkRequest:
+    """A WorkRequest as Bazel's JSON protocol writes it (proto3 JSON: camelCase, defaults omitted)."""
+    if not isinstance(data, dict):
+        raise ValueError("WorkRequest must be a JSON object")
+    arguments = data.get("arguments") or []
+    if not isinstance(arguments, list) or not all(isinstance(a, str) for a in arguments):
+        raise ValueError("'arguments' must be a list of strings")
+    return WorkRequest(
+        arguments=arguments,
+        request_id=int(data.get("requestId") or 0),
+        cancel=bool(data.get("cancel")),
+        verbosity=int(data.get("verbosity") or 0),
+        sandbox_dir=data.get("sandboxDir") or None,
+    )
+
+
+def response_to_json(response: WorkResponse) -> dict:
+    data = {"exitCode": response.exit_code, "output": response.output, "requestId": response.request_id}
+    if response.was_cancelled:
+        data["wasCancelled"] = True
+    return data
+
+
+def _read_json(stream: BinaryIO) -> Optional[dict]:
+    """The next JSON WorkRequest, which may span lines; None at end of input."""
+    text = ""
+    while True:
+        line = stream.readline()
+        if not line:
+            if text.strip():
+                raise ValueError("End of input inside a JSON WorkRequest")
+            return None
+        text += line.decode("utf-8")
+        if not text.strip():
+            text = ""
+            continue
+        try:
+            return json.loads(text)
+        except json.JSONDecodeError:
+            continue  # not complete yet
+
+
+# Runs one action's arguments in this process: (exit status, everything it printed).
+Runner = Callable[[List[str]], Tuple[int, str]]
+
+
+class WorkerSession:
+    """Answers WorkRequests with `run` until end of input, speaking whichever protocol the first request uses."""
+
+    def __init__(self, run: Runner):
+        self.run = run
+
+    def handle(self, request: WorkRequest) -> WorkResponse:
+        cwd = os.getcwd()
+        try:
+            if request.sandbox_dir:
+                os.chdir(request.sandbox_dir)
+            code, output = self.run(expand_params(request.arguments, at_files=True))
+        except ValueError as exc:
+            code, output = 1, f"Error: {exc}\n"
+        finally:
+            os.chdir(cwd)
+        return WorkResponse(exit_code=code, output=output, request_id=request.request_id)
+
+    def serve(self, stdin: Optional[BinaryIO] = None, stdout: Optional[BinaryIO] = None) -> None:
+        stdin = stdin or sys.stdin.buffer
+        stdout = stdout or sys.stdout.buffer
+        if not hasattr(stdin, "peek"):
+            stdin = io.BufferedReader(stdin)
+        use_json = stdin.peek(1)[:1] in (b"{", b" ", b"\n", b"\r", b"\t")
+        while True:
+            if use_json:
+                message = _read_json(stdin)
+                if message is None:
+                    return
+                request = request_from_json(message)
+            else:
+                data = read_delimited(stdin)
+                if data is None:
+                    return
+                request = decode_request(data)
+            if request.cancel:
+                continue  # requests are answered in order, so the one to cancel is already done
+            response = self.handle(request)
+            if use_json:
+                stdout.write(json.dumps(response_to_json(response)).encode("utf-8") + b"\n")
+            else:
+                stdout.write(delimited(encode_response(response)))
+            stdout.flush()
+
+
+__all__ = [
+    "KINDS",
+    "PARAM_FILE_FLAG",
+    "WORKER_FLAG",
+    "WorkRequest",
+    "WorkResponse",
+    "WorkerSession",
+    "content_path",
+    "decode_request",
+    "encode_response",
+    "expand_command_line",
+    "expand_params",
+    "extract_action",
+    "read_param_file",
+    "request_from_json",
+    "response_to_json",
+    "write_output",
+]
@@ -0,0 +1,400 @@
+"""Context bundles: a symbol with its docs, referenced types, callers, callees, and imports, fitted to a token budget."""
+
+from __future__ import annotations
+
+import json
+import re
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Dict, List, Optional, Sequence, Set, Tuple
+
+from tree_sitter import Node
+
+from . import failures
+from .budget import truncate_text
+from .callgraph import CallGraph
+from .chunker import TokenCounter, estimate_tokens
+from .core import (
+    IMPORT_NODE_TYPES,
+    ParsedFile,
+    _signature_snippet,
+    class_kind,
+    is_anonymous,
+    iter_class_nodes,
+    iter_function_nodes,
+    iter_source_files,
+    parse_file,
+    symbol_doc,
+)
+
+ROLES = ("definition", "type", "callee", "caller")
+MAX_HOPS = 2
+
+# Words in import statements that are syntax, not names the code can use.
+_IMPORT_KEYWORDS = {"import", "from", "as", "use", "using", "static", "include", "extern", "crate", "require", "type"}
+_IDENTIFIER_TYPES = {"identifier", "type_identifier", "field_identifier", "property_identifier", "constant"}
+
+
+@dataclass(eq=False)
+class _Definition:
+    parsed: ParsedFile
+    label: str
+    node: Node  # the declaration, widened to decorators
+    inner: Node  # the function/class node itself
+    name: str
+    qualified_name: str
+    kind: str
+
+    @property
+    def key(self) -> Tuple[str, int]:
+        return self.label, self.inner.start_point[0] + 1
+
+
+@dataclass
+class BundleItem:
+    role: str  # "definition", "type", "callee", or "caller"
+    hops: int  # call-graph distance from the symbol (0 for the definition and its types)
+    kind: str
+    name: str  # qualified
+    path: str
+    language: str
+    start_line: int
+    end_line: int
+    signature: str
+    doc: Optional[str] = None
+    content: Optional[str] = None
+    elided: Optional[str] = None  # "truncated" or "omitted" when the budget cut the body
+
+    def to_dict(self) -> dict:
+        return {
+            "role": self.role,
+            "hops": self.hops,
+            "kind": self.kind,
+            "name": self.name,
+            "path": self.path,
+            "language": self.language,
+            "start_line": self.start_line,
+            "end_line": self.end_line,
+            "signature": self.signature,
+            "doc": self.doc,
+            "content": self.content,
+            "elided": self.elided,
+        }
+
+
+@dataclass
+class ContextBundle:
+    symbol: str
+    items: List[BundleItem] = field(default_factory=list)
+    imports: Dict[str, List[str]] = field(default_factory=dict)  # path -> relevant import statements
+    max_tokens: Optional[int] = None
+    used_tokens: int = 0
+    dropped: int = 0  # items left out entirely because not even their signature fit
+
+    def to_dict(self) -> dict:
+        return {
+            "symbol": self.symbol,
+            "max_tokens": self.max_tokens,
+            "used_tokens": self.used_tokens,
+            "dropped": self.dropped,
+            "imports": self.imports,
+            "items": [item.to_dict() for item in self.items],
+        }
+
+    def to_json(self) -> str:
+        return json.dumps(self.to_dict(), indent=2)
+
+    def to_markdown(self) -> str:
+        parts = [f"# Context for `{self.symbol}`\n"]
+        headings = {"definition": "Definition", "type": "Referenced types", "callee": "Callees", "caller": "Callers"}
+        for role in ROLES:
+            items = [item for item in self.items if item.role == role]
+            if items:
+                parts.append(f"## {headings[role]}\n")
+                parts.extend(_item_markdown(item) for item in items)
+            if role == "definition" and self.imports:
+                parts.append(_imports_markdown(self.imports, self.items))
+        return "\n".join(parts)
+
+
+def _item_markdown(item: BundleItem) -> str:
+    hops = f", {item.hops} hops" if item.hops > 1 else ""
+    lines = [f"### `{item.name}` ({item.kind}{hops}) — {item.path}:{item.start_line}-{item.end_line}\n"]
+    if item.doc:
+        lines.append(item.doc + "\n")
+    if item.content is not None:
+        body = item.content if item.content.endswith("\n") else item.content + "\n"
+        lines.append(f"```{item.language}\n{body}```\n")
+    else:
+        lines.append(f"```{item.language}\n{item.signature}\n```\n")
+    return "\n".join(lines)
+
+
+def _imports_markdown(imports: Dict[str, List[str]], items: Sequence[BundleItem]) -> str:
+    languages = {item.path: item.language for item in items}
+    lines = ["## Imports\n"]
+    for path, statements in imports.items():
+        lines.append(f"{path}:\n\n```{languages.get(path, '')}\n" + "\n".join(statements) + "\n```\n")
+    return "\n".join(lines)
+
+
+class _Project:
+    """Definitions and call edges of every file under a root."""
+
+    def __init__(self, files: Sequence[Tuple[ParsedFile, str]]):
+        self.graph = CallGraph()
+        self.definitions: List[_Definition] = []
+        self.by_key: Dict[Tuple[str, int], _Definition] = {}
+        self.types: Dict[str, List[_Definition]] = {}
+        for parsed, label in files:
+            self.graph.add_file(parsed, label)
+            for fn in iter_function_nodes(parsed):
+                kind = "method" if fn.container else "function"
+                self._add(_Definition(parsed, label, _outer(fn.node), fn.node, fn.name, fn.qualified_name, kind))
+            for cls in iter_class_nodes(parsed):
+                definition = _Definition(
+                    parsed, label, _outer(cls.node), cls.node, cls.name, cls.qualified_name, class_kind(cls.node)
+                )
+                self._add(definition)
+                self.types.setdefault(cls.name, []).append(definition)
+        self.graph.resolve()
+
+    def _add(self, definition: _Definition) -> None:
+        if is_anonymous(definition.name):
+            return
+        self.definitions.append(definition)
+        self.by_key.setdefault(definition.key, definition)
+
+    def find(self, symbol: str, path: Optional[str] = None) -> _Definition:
+        matches = [d for d in self.definitions if symbol in (d.qualified_name, d.name)]
+        if path is not None:
+            matches = [d for d in matches if d.label == path or d.label.endswith("/" + path)]
+        exact = [d for d in matches if d.qualified_name == symbol]
+        matches = exact or matches
+        if not matches:
+            raise ValueError(f"No definition of '{symbol}' found" + (f" in {path}" if path else ""))
+        if len(matches) > 1:
+            where = ", ".join(f"{d.qualified_name} ({d.label}:{d.key[1]})" for d in matches[:5])
+            raise ValueError(f"'{symbol}' is ambiguous: {where}; pass --file to choose one")
+        return matches[0]
+
+    def callees(self, definition: _Definition) -> List[_Definition]:
+        found = []
+        for edge in self.graph.edges:
+            if edge.file == definition.label and edge.caller == definition.qualified_name and edge.resolved:
+                target = self.by_key.get((edge.callee_file, edge.callee_line))
+                if target is not None and target not in found:
+                    found.append(target)
+        return found
+
+    def callers(self, definition: _Definition) -> List[_Definition]:
+        found = []
+        for edge in self.graph.edges:
+            if (edge.callee_file, edge.callee_line) != definition.key:
+                continue
+            for candidate in self.definitions:
+                if candidate.label == edge.file and candidate.qualified_name == edge.caller and candidate not in found:
+                    found.append(candidate)
+                    break
+        return found
+
+    def referenced_types(self, definition: _Definition) -> List[_Definition]:
+        names = _identifiers(definition.node, definition.parsed)
+        found: List[_Definition] = []
+        for name in sorted(names):
+            candidates = [d for d in self.types.get(name, ()) if d is not definition and d.parsed.language == definition.parsed.language]
+            # Prefer a declaration in the same file, then the same directory.
+            candidates.sort(key=lambda d: (d.label != definition.label, Path(d.label).parent != Path(definition.label).parent, d.label))
+            if candidates and candidates[0] not in found:
+                found.append(candidates[0])
+        container = definition.qualified_name.rpartition(".")[0]
+        if container:
+            owner = next((d for d in self.types.get(container.rpartition(".")[2], ()) if d.label == definition.label), None)
+            if owner is not None and owner not in found:
+                found.insert(0, owner)
+        return found
+
+
+def _outer(node: Node) -> Node:
+    parent = node.parent
+    return parent if parent is not None and parent.type == "decorated_definition" else node
+
+
+def _identifiers(node: Node, parsed: ParsedFile) -> Set[str]:
+    names: Set[str] = set()
+    stack = [node]
+    while stack:
+        current = stack.pop()
+        if current.type in _IDENTIFIER_TYPES:
+            names.add(parsed.text(current))
+        stack.extend(current.children)
+    return names
+
+
+def relevant_imports(parsed: ParsedFile, used: Set[str]) -> List[str]:
+    """Import statements of `parsed` that bind a name in `used` (and the package clause, which is always kept)."""
+    types = IMPORT_NODE_TYPES.get(parsed.language, set())
+    kept: List[str] = []
+    for node in parsed.root.named_children:
+        if node.type not in types:
+            continue
+        if "package" in node.type or "namespace" in node.type:
+            kept.append(parsed.text(node).strip())
+            continue
+        # Go groups imports: judge each spec on its own.
+        specs = [n for n in node.named_children if n.type == "import_spec_list"]
+        entries = [s for group in specs for s in group.named_children if s.type == "import_spec"] or [node]
+        for entry in entries:
+            tokens = set(re.findall(r"[A-Za-z_$][\w$]*", parsed.text(entry))) - _IMPORT_KEYWORDS
+            if tokens & used:
+                text = parsed.text(entry).strip()
+                kept.append(f"import {text}" if entry is not node else text)
+    return kept
+
+
+def _item(definition: _Definition, role: str, hops: int) -> BundleItem:
+    parsed, node = definition.parsed, definition.node
+    inner = definition.inner if definition.inner is not node else None
+    return BundleItem(
+        role=role,
+        hops=hops,
+        kind=definition.kind,
+        name=definition.qualified_name,
+        path=definition.label,
+        language=parsed.language,
+        start_line=node.start_point[0] + 1,
+        end_line=node.end_point[0] + 1,
+        signature=_signature_snippet(definition.inner, parsed.source),
+        doc=symbol_doc(node, parsed.source, parsed.language, inner),
+        content=parsed.text(node),
+    )
+
+
+def _fit(bundle: ContextBundle, max_tokens: int, count: TokenCounter) -> None:
+    """
+    Trim the bundle to `max_tokens` in priority order (the order of its items):
+    every signature is kept before any body, then bodies are restored while they
+    fit, one more is truncated, and the rest stay signatures only. If signatures
+    alone overflow, docs are dropped and then the furthest items (never the definition).
+    """
+    bundle.max_tokens = max_tokens
+    bodies = [item.content for item in bundle.items]
+    for item in bundle.items:
+        item.content = None
+    used = count(bundle.to_markdown())
+    if used > max_tokens:
+        for item in bundle.items[1:]:
+            item.doc = None
+        used = count(bundle.to_markdown())
+    while used > max_tokens and len(bundle.items) > 1:
+        bundle.items.pop()
+        bodies.pop()
+        bundle.dropped += 1
+        used = count(bundle.to_markdown())
+    truncated = False
+    for item, body in zip(bundle.items, bodies):
+        if body is None:
+            continue
+        item.content = body
+        cost = count(bundle.to_markdown())
+        if cost <= max_tokens:
+            used = cost
+            continue
+        item.content = None
+        if not truncated:
+            # The body replaces the signature in the rendered bundle.
+            text, remaining = truncate_text(body, max_tokens - used + count(item.signature), count)
+            if remaining and remaining < len(body.splitlines()):
+                item.content = text
+                cost = count(bundle.to_markdown())
+                if cost <= max_tokens:
+                    item.elided = "truncated"
+                    used = cost
+                    truncated = True
+                    continue
+                item.content = None
+        item.elided = "omitted"
+    bundle.used_tokens = used
+
+
+def parse_sources(
+    root: Path, include: Sequence[str] | None = None, exclude: Sequence[str] | None = None
+) -> List[Tuple[ParsedFile, str]]:
+    """Every source file under `root` (or `root` itself), parsed, with its label relative to `root`."""
+    root = Path(root)
+    if root.is_file():
+        targets = [(root, root.name)]
+    else:
+        base = root.resolve()
+        targets = [(p, p.relative_to(base).as_posix()) for p in iter_source_files(base, include, exclude)]
+    files: List[Tuple[ParsedFile, str]] = []
+    for path, label in targets:
+        try:
+            files.append((parse_file(path), label))
+        except (ValueError, RuntimeError, OSError) as exc:
+            failures.record(path, exc)
+            continue
+    return files
+
+
+def build_bundle(
+    root: Path,
+    symbol: str,
+    file: Optional[str] = None,
+    hops: int = 1,
+    max_tokens: Optional[int] = None,
+    count: TokenCounter = estimate_tokens,
+    include: Sequence[str] | None = None,
+    exclude: Sequence[str] | None = None,
+) -> ContextBundle:
+    """
+    Everything relevant to `symbol` (a name or `Type.method`) under `root`: its
+    definition and doc, the types it references, its callees and callers up to
+    `hops` call-graph steps away, and the imports those use. With `max_tokens` the
+    bundle is trimmed to fit, nearest items first (see `_fit`).
+    """
+    if not 1 <= hops <= MAX_HOPS:
+        raise ValueError(f"hops must be between 1 and {MAX_HOPS}")
+    project = _Project(parse_sources(root, include, exclude))
+    target = project.find(symbol, file)
+
+    bundle = ContextBundle(target.qualified_name)
+    seen = {target.key}
+    bundle.items.append(_item(target, "definition", 0))
+    for definition in project.referenced_types(target):
+        if definition.key not in seen:
+            seen.add(definition.key)
+            bundle.items.append(_item(definition, "type", 0))
+
+    callees, callers = [target], [target]
+    for distance in range(1, hops + 1):
+        found = {
+            "callee": [c for d in callees for c in project.callees(d)],
+            "caller": [c for d in callers for c in project.callers(d)],
+        }
+        frontiers: Dict[str, List[_Definition]] = {"callee": [], "caller": []}
+        for role in ("callee", "caller"):
+            for definition in found[role]:
+                if definition.key in seen:
+                    continue
+                seen.add(definition.key)
+                frontiers[role].append(definition)
+                bundle.items.append(_item(definition, role, distance))
+        callees, callers = frontiers["callee"], frontiers["caller"]
+    # Nearest first: the definition, its types, then each hop's callees before its callers.
+    order = {"definition": 0, "type": 1, "callee": 2, "caller": 3}
+    bundle.items.sort(key=lambda item: (item.hops, order[item.role]))
+
+    used = _identifiers(target.node, target.parsed)
+    imports = relevant_imports(target.parsed, used)
+    if imports:
+        bundle.imports[target.label] = imports
+
+    if max_tokens is not None:
+        _fit(bundle, max_tokens, count)
+    else:
+        bundle.used_tokens = count(bundle.to_markdown())
+    return bundle
+
+
+__all__ = ["MAX_HOPS", "ROLES", "BundleItem", "ContextBundle", "build_bundle", "parse_sources", "relevant_imports"]
@@ -0,0 +1,212 @@
+"""
+Content-addressed cache for extraction and query results.
+
+An entry's key is a SHA-256 over the file content and everything else that shapes the
+result: the cache format, the package version, the kind of result and its options, and
+the language's grammar version and query version. Upgrading the language pack, swapping
+a grammar file, changing a language's node tables, or editing a query therefore misses
+the old entries instead of serving stale results; nothing needs to be cleared by hand.
+
+Entries live at `<cache_dir>/<key[:2]>/<key>.json` and carry a checksum of their
+result. An entry that fails verification (a truncated write, disk corruption, a hand
+edit) is deleted and recomputed.
+"""
+
+from __future__ import annotations
+
+import hashlib
+import json
+import os
+import tempfile
+from dataclasses import dataclass, fields
+from importlib import metadata
+from pathlib import Path
+from typing import Any, Dict, List, Optional, Sequence, Tuple
+
+from tree_sitter import Query
+
+from . import __version__, overlay, preproc, redact
+from .core import detect_language, get_language_spec, load_language, parse_source, query_tree, run_query
+
+CACHE_FORMAT_VERSION = 8
+
+_GRAMMAR_VERSIONS: Dict[Tuple[str, int], str] = {}
+
+
+def _package_version(name: str) -> str:
+    try:
+        return metadata.version(name)
+    except metadata.PackageNotFoundError:
+        return "unknown"
+
+
+def _file_digest(path: Path) -> str:
+    digest = hashlib.sha256()
+    try:
+        with Path(path).open("rb") as f:
+            for block in iter(lambda: f.read(1 << 20), b""):
+                digest.update(block)
+    except OSError:
+        return "missing"
+    return digest.hexdigest()
+
+
+def grammar_version(language: str) -> str:
+    """
+    Fingerprint of the grammar `language` parses with: the language pack (or grammar
+    file) it comes from plus the grammar's ABI, version, and table sizes.
+    """
+    spec = get_language_spec(language)
+    key = (language, id(spec))
+    if key not in _GRAMMAR_VERSIONS:
+        grammar = load_language(language)
+        parts = [language, spec.grammar if spec is not None and spec.grammar else language, _package_version("tree-sitter")]
+        path = getattr(spec.loader, "path", None) if spec is not None else None
+        if path is not None:
+            parts.append(_file_digest(path))
+        elif spec is None or spec.loader is None:
+            parts.append(_package_version("tree-sitter-language-pack"))
+        for attr in ("abi_version", "semantic_version", "node_kind_count", "field_count", "parse_state_count"):
+            parts.append(repr(getattr(grammar, attr, None)))
+        _GRAMMAR_VERSIONS[key] = hashlib.sha256("\0".join(parts).encode("utf-8")).hexdigest()[:16]
+    return _GRAMMAR_VERSIONS[key]
+
+
+def query_version(language: str, queries: Sequence[str] = ()) -> str:
+    """
+    Fingerprint of what extraction looks for in `language`: its spec's node tables
+    (which drive symbol extraction) and the text of any `queries` run over the tree.
+    """
+    spec = get_language_spec(language)
+    tables = {}
+    if spec is not None:
+        for f in fields(spec):
+            if f.name in {"loader", "query_dir"}:
+                continue
+            value = getattr(spec, f.name)
+            tables[f.name] = sorted(value) if isinstance(value, frozenset) else value
+    digest = hashlib.sha256(json.dumps(tables, sort_keys=True, default=str).encode("utf-8"))
+    for query in queries:
+        digest.update(b"\0")
+        digest.update(query.encode("utf-8"))
+    return digest.hexdigest()[:16]
+
+
+def _checksum(result: Any) -> str:
+    canonical = json.dumps(result, sort_keys=True, separators=(",", ":"), ensure_ascii=False)
+    return hashlib.sha256(canonical.encode("utf-8")).hexdigest()
+
+
+@dataclass
+class CacheStats:
+    hits: int = 0
+    misses: int = 0
+    invalid: int = 0  # entries discarded because their checksum did not verify
+
+    def to_dict(self) -> dict:
+        return {"hits": self.hits, "misses": self.misses, "invalid": self.invalid}
+
+
+class ResultCache:
+    """JSON results stored by content-addressed key; see the module docstring for what a key covers."""
+
+    def __init__(self, cache_dir: Path):
+        self.cache_dir = Path(cache_dir)
+        self.stats = CacheStats()
+
+    def key(
+        self,
+        kind: str,
+        source: bytes,
+        language: str,
+        queries: Sequence[str] = (),
+        options: Optional[dict] = None,
+    ) -> str:
+        """Key for the `kind` result (e.g. "symbols", "query") of `source` parsed as `language`."""
+        salt = json.dumps(
+            {
+                "format": CACHE_FORMAT_VERSION,
+                "version": __version__,
+                "kind": kind,
+                "options": options or {},
+                "language": language,
+                "grammar": grammar_version(language),
+                "queries": query_version(language, queries),
+                "redact": redact.fingerprint(),
+                # Only when on, so enabling it leaves the keys of every other entry as they were.
+                **({"preprocessor": True} if preproc.ENABLED and language in preproc.LANGUAGES else {}),
+            },
+            sort_keys=True,
+        )
+        digest = hashlib.sha256(salt.encode("utf-8"))
+        digest.update(b"\0")
+        digest.update(source)  # no `salt + source` copy of a mapped file
+        return digest.hexdigest()
+
+    def path(self, key: str) -> Path:
+        return self.cache_dir / key[:2] / f"{key}.json"
+
+    def get(self, key: str) -> Optional[Any]:
+        """The stored result, or None on a miss or when the entry fails verification."""
+        path = self.path(key)
+        try:
+            entry = json.loads(path.read_text(encoding="utf-8"))
+        except FileNotFoundError:
+            self.stats.misses += 1
+            return None
+        except (OSError, ValueError):
+            entry = None
+        if not isinstance(entry, dict) or entry.get("key") != key or entry.get("sha256") != _checksum(entry.get("result")):
+            self.stats.invalid += 1
+            self.stats.misses += 1
+            try:
+                path.unlink()
+            except OSError:
+                pass
+            return None
+        self.stats.hits += 1
+        return entry["result"]
+
+    def put(self, key: str, result: Any) -> None:
+        """Store `result` (JSON-serializable); write failures are ignored, as the cache is optional."""
+        path = self.path(key)
+        entry = {"key": key, "sha256": _checksum(result), "result": result}
+        try:
+            path.parent.mkdir(parents=True, exist_ok=True)
+            fd, tmp = tempfile.mkstemp(dir=path.parent, prefix=".tmp-", suffix=".json")
+            try:
+                with os.fdopen(fd, "w", encoding="utf-8") as f:
+                    json.dump(entry, f, ensure_ascii=False)
+                os.replace(tmp, path)  # readers never see a half-written entry
+            except BaseException:
+                os.unlink(tmp)
+                raise
+        except OSError:
+            pass
+
+
+def run_query_cached(cache: ResultCache, path: Path, query: str, language: Optional[str] = None) -> List[dict]:
+    """`core.run_query`, served from `cache` while the file, grammar, and query text are unchanged."""
+    path = Path(path)
+    detected = detect_language(path, language)
+    if not detected:
+        return run_query(path, query, language)  # raises the usual error
+    source = overlay.read_bytes(path)
+    key = cache.key("query", source, detected, queries=[query])
+    cached = cache.get(key)
+    if cached is not None:
+        return cached
+    root = parse_source(source, detected)
+    matches = query_tree(root, redact.apply(source, root), Query(load_language(detected), query))
+    cache.put(key, matches)
+    return matches
+
+
+__all__ = [
+    "CACHE_FORMAT_VERSION",
+    "CacheStats",
+    "ResultCache",
+    "grammar_version",
+    "query_version",
+    "run_query_cached",
+]
@@ -0,0 +1,286 @@
+"""Cross-file call graph extraction (caller -> callee edges)."""
+
+from __future__ import annotations
+
+import json
+from dataclasses import dataclass
+from pathlib import Path
+from typing import Dict, Iterable, List, Optional, Sequence
+
+from tree_sitter import Node
+
+from . import failures, schema
+from .core import (
+    CLOSURE_NODE_TYPES,
+    FUNCTION_NODE_TYPES,
+    DEFAULT_FUNCTION_NODE_TYPES,
+    LANGUAGE_SPECS,
+    RECEIVER_NAMES,
+    FunctionNode,
+    ParsedFile,
+    _go_instantiation,
+    closure_captures,
+    iter_function_nodes,
+    iter_source_files,
+    parse_file,
+)
+
+CALL_NODE_TYPES = {
+    "python": {"call"},
+    "javascript": {"call_expression"},
+    "typescript": {"call_expression"},
+    "go": {"call_expression"},
+    "rust": {"call_expression"},
+    "c": {"call_expression"},
+    "cpp": {"call_expression"},
+    "java": {"method_invocation"},
+    "csharp": {"invocation_expression"},
+    "php": {"function_call_expression", "member_call_expression"},
+    "ruby": {"call"},
+}
+
+# Field names holding the member name / receiver of a `recv.member(...)` callee.
+_MEMBER_FIELDS = ("attribute", "property", "field", "name")
+_RECEIVER_FIELDS = ("object", "operand", "value", "argument", "path", "receiver")
+
+
+@dataclass
+class FunctionDef:
+    name: str
+    qualified_name: str
+    receiver_type: Optional[str]
+    file: str
+    line: int
+    # Closures: the enclosing function and the variables captured from it
+    parent: Optional[str] = None
+    captures: Optional[List[str]] = None
+
+    def to_dict(self) -> dict:
+        data = {
+            "name": self.name,
+            "qualified_name": self.qualified_name,
+            "receiver_type": self.receiver_type,
+            "file": self.file,
+            "line": self.line,
+        }
+        if self.parent:
+            data["parent"] = self.parent
+        if self.captures:
+            data["captures"] = self.captures
+        return data
+
+
+@dataclass
+class CallEdge:
+    caller: str
+    callee: str
+    file: str
+    line: int
+    receiver: Optional[str] = None
+    receiver_type: Optional[str] = None
+    callee_file: Optional[str] = None
+    callee_line: Optional[int] = None
+    column: Optional[int] = None
+
+    @property
+    def resolved(self) -> bool:
+        return self.callee_file is not None
+
+    def to_dict(self) -> dict:
+        return {
+            "caller": self.caller,
+            "callee": self.callee,
+            "file": self.file,
+            "line": self.line,
+            "column": self.column,
+            "receiver": self.receiver,
+            "receiver_type": self.receiver_type,
+            "callee_file": self.callee_file,
+            "callee_line": self.callee_line,
+        }
+
+
+@dataclass
+class CallSite:
+    """A single call expression inside a function body, before cross-file resolution."""
+
+    name: str
+    receiver: Optional[str]
+    node: Node
+    name_node: Optional[Node] = None
+
+
+def _first_field(node: Node, fields: Sequence[str]) -> Optional[Node]:
+    for name in fields:
+        child = node.child_by_field_name(name)
+        if child is not None:
+            return child
+    return None
+
+
+def _go_generic_callee(target: Node, parsed: ParsedFile) -> Node:
+    """The function of an instantiated Go call, `Map` in `Map[int](xs)` or `pkg.Map[K, V](m)`."""
+    instantiation = _go_instantiation(target, parsed.source)
+    if instantiation is None:
+        return target
+    return target.child_by_field_name("type") or target.child_by_field_name("operand") or target
+
+
+def _callee_parts(call: Node, parsed: ParsedFile) -> tuple[Optional[Node], Optional[Node]]:
+    """Split a call into (member name node, receiver expression node)."""
+    target = call.child_by_field_name("function")
+    if target is not None and parsed.language == "go" and target.type in {"generic_type", "index_expression"}:
+        target = _go_generic_callee(target, parsed)
+    if target is None:
+        # Java-style invocations carry name/object directly on the call node.
+        return call.child_by_field_name("name"), call.child_by_field_name("object")
+    if target.named_child_count == 0:
+        return target, None
+    member = _first_field(target, _MEMBER_FIELDS)
+    if member is None:
+        return target, None
+    return member, _first_field(target, _RECEIVER_FIELDS)
+
+
+def iter_call_sites(func: Node, parsed: ParsedFile, closures: bool = False) -> Iterable[CallSite]:
+    """
+    Yield calls lexically inside `func`, skipping nested function definitions (and, with
+    `closures`, nested lambdas and closures, whose calls are their own).
+    """
+    spec = LANGUAGE_SPECS.get(parsed.language)
+    if spec is not None and spec.call_nodes:
+        call_nodes = spec.call_nodes
+    else:
+        call_nodes = CALL_NODE_TYPES.get(parsed.language, {"call_expression", "call"})
+    func_nodes = FUNCTION_NODE_TYPES.get(parsed.language, DEFAULT_FUNCTION_NODE_TYPES)
+    if closures:
+        func_nodes = func_nodes | CLOSURE_NODE_TYPES.get(parsed.language, set())
+    stack = list(reversed(func.children))
+    while stack:
+        node = stack.pop()
+        if node.type in func_nodes:
+            continue
+        if node.type in call_nodes:
+            name_node, receiver = _callee_parts(node, parsed)
+            if name_node is not None:
+                yield CallSite(
+                    name=parsed.text(name_node),
+                    receiver=parsed.text(receiver) if receiver is not None else None,
+                    node=node,
+                    name_node=name_node,
+                )
+        stack.extend(reversed(node.children))
+
+
+def _receiver_type(fn: FunctionNode, receiver: Optional[str], language: str) -> Optional[str]:
+    if receiver is None or fn.container is None:
+        return None
+    if receiver == fn.receiver or receiver in RECEIVER_NAMES.get(language, set()):
+        return fn.container
+    return None
+
+
+class CallGraph:
+    """Function definitions and call edges collected across many files."""
+
+    def __init__(self) -> None:
+        self.definitions: List[FunctionDef] = []
+        self.edges: List[CallEdge] = []
+        self._by_name: Dict[str, List[FunctionDef]] = {}
+
+    def add_file(self, parsed: ParsedFile, label: Optional[str] = None) -> None:
+        label = label or parsed.path.as_posix()
+        functions = list(iter_function_nodes(parsed, closures=True))
+        captures = closure_captures(parsed, functions)
+        by_node = {fn.node.id: fn for fn in functions}
+        for fn in functions:
+            definition = FunctionDef(
+                name=fn.name,
+                qualified_name=fn.qualified_name,
+                receiver_type=fn.container,
+                file=label,
+                line=fn.node.start_point[0] + 1,
+                parent=fn.parent,
+                captures=captures.get(fn.node.id),
+            )
+            self.definitions.append(definition)
+            self._by_name.setdefault(fn.name, []).append(definition)
+            for site in iter_call_sites(fn.node, parsed, closures=True):
+                invoked = by_node.get(site.name_node.id)  # `func() { ... }()` calls the closure
+                self.edges.append(
+                    CallEdge(
+                        caller=fn.qualified_name,
+                        callee=invoked.name if invoked is not None else site.name,
+                        file=label,
+                        line=site.name_node.start_point[0] + 1,
+                        column=site.name_node.start_point[1] + 1,
+                        receiver=site.receiver,
+                        receiver_type=_receiver_type(fn, site.receiver, parsed.language),
+                    )
+                )
+
+    def resolve(self) -> None:
+        """Link edges to definitions when the callee name (and receiver type) is unambiguous."""
+        for edge in self.edges:
+            candidates = self._by_name.get(edge.callee, [])
+            if edge.receiver_type:
+                typed = [c for c in candidates if c.receiver_type == edge.receiver_type]
+                candidates = typed or candidates
+            elif edge.receiver is None:
+                candidates = [c for c in candidates if c.receiver_type is None] or candidates
+            if len(candidates) == 1:
+                target = candidates[0]
+                edge.callee = target.qualified_name
+                edge.callee_file = target.file
+                edge.callee_line = target.line
+
+    def to_dict(self, resolved_only: bool = False) -> dict:
+        edges = [e for e in self.edges if e.resolved] if resolved_only else self.edges
+        return {
+            "functions": [d.to_dict() for d in self.definitions],
+            "edges": [e.to_dict() for e in edges],
+        }
+
+    def to_json(self, resolved_only: bool = False) -> str:
+        return json.dumps(schema.conform("callgraph", self.to_dict(resolved_only)), indent=2)
+
+    def to_dot(self, resolved_only: bool = False) -> str:
+        lines = ["digraph callgraph {"]
+        seen = set()
+        for edge in self.edges:
+            if resolved_only and not edge.resolved:
+                continue
+            key = (edge.caller, edge.callee)
+            if key in seen:
+                continue
+            seen.add(key)
+            lines.append(f"  {json.dumps(edge.caller)} -> {json.dumps(edge.callee)};")
+        lines.append("}")
+        return "\n".join(lines) + "\n"
+
+
+def build_call_graph(
+    root: Path,
+    include: Sequence[str] | None = None,
+    exclude: Sequence[str] | None = None,
+) -> CallGraph:
+    """Parse every recognised file under `root` (or a single file) and link call edges."""
+    root = Path(root)
+    graph = CallGraph()
+    if root.is_file():
+        graph.add_file(parse_file(root))
+    else:
+        base = root.resolve()
+        for path in iter_source_files(base, include, exclude):
+            try:
+                parsed = parse_file(path)
+            except (ValueError, RuntimeError, OSError) as exc:
+                # Unknown or binary files are skipped (and recorded), matching `scan`.
+                failures.record(path, exc)
+                continue
+            graph.add_file(parsed, path.relative_to(base).as_posix())
+    graph.resolve()
+    return graph
+
+
+__all__ = ["CALL_NODE_TYPES", "CallEdge", "CallGraph", "CallSite", "FunctionDef", "build_call_graph", "iter_call_sites"]
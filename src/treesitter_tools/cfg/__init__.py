@@ -0,0 +1,78 @@
+"""
+Control-flow graphs: one graph of basic blocks per function, with the edges control
+can take between them, for reachability and path-based analyses and DOT export.
+"""
+
+from __future__ import annotations
+
+from pathlib import Path
+from typing import List, Optional
+
+from ..core import FunctionNode, ParsedFile, iter_function_nodes, parse_file
+from .builder import SUPPORTED_LANGUAGES, CFGBuilder
+from .graph import (
+    BLOCK_KINDS,
+    EDGE_KINDS,
+    BasicBlock,
+    ControlFlowGraph,
+    Edge,
+    Statement,
+    graphs_to_dot,
+    graphs_to_json,
+    graphs_to_text,
+)
+
+
+def build_cfg(parsed: ParsedFile, fn: FunctionNode, label: Optional[str] = None) -> ControlFlowGraph:
+    """The control-flow graph of one function of `parsed`."""
+    builder = CFGBuilder(parsed.source)
+    builder.build(fn.node.child_by_field_name("body"))
+    node = fn.node
+    return builder.finish(
+        fn.qualified_name, label or parsed.path.as_posix(), parsed.language, node.start_point[0] + 1, node.end_point[0] + 1
+    )
+
+
+def function_cfgs(parsed: ParsedFile, function: Optional[str] = None, label: Optional[str] = None) -> List[ControlFlowGraph]:
+    """
+    Graphs of the functions in `parsed` that have a body, in source order; `function`
+    keeps those whose name or qualified name (`Class.method`) matches.
+    """
+    if parsed.language not in SUPPORTED_LANGUAGES:
+        raise ValueError(
+            f"Control-flow graphs are not supported for '{parsed.language}' (supported: {', '.join(SUPPORTED_LANGUAGES)})"
+        )
+    graphs = []
+    for fn in iter_function_nodes(parsed):
+        if fn.node.child_by_field_name("body") is None:
+            continue  # a declaration (prototype, abstract or interface method)
+        if function is not None and function not in (fn.name, fn.qualified_name):
+            continue
+        graphs.append(build_cfg(parsed, fn, label))
+    return graphs
+
+
+def file_cfgs(path: Path, function: Optional[str] = None, language: Optional[str] = None) -> List[ControlFlowGraph]:
+    """Parse `path` and build its functions' graphs; ValueError when `function` matches none."""
+    graphs = function_cfgs(parse_file(Path(path), language), function)
+    if function is not None and not graphs:
+        raise ValueError(f"No function named '{function}' in {Path(path).as_posix()}")
+    return graphs
+
+
+__all__ = [
+    "BLOCK_KINDS",
+    "EDGE_KINDS",
+    "SUPPORTED_LANGUAGES",
+    "BasicBlock",
+    "CFGBuilder",
+    "ControlFlowGraph",
+    "Edge",
+    "Statement",
+    "build_cfg",
+    "file_cfgs",
+    "function_cfgs",
+    "graphs_to_dot",
+    "graphs_to_json",
+    "graphs_to_text",
+]
@@ -0,0 +1,449 @@
+"""
+Build a basic-block control-flow graph from a function's syntax tree.
+
+Statements run in order within a block; branches, loops, `switch`/`match`, jumps
+(`return`, `break`, `continue`, `goto`, `raise`/`throw`), and `try` start new blocks.
+The rules are keyed by node type, which the supported grammars share closely enough for
+one table to serve them all. Approximations:
+
+- Conditions are not evaluated, except that `while True`/`for {}`/`loop` never exit
+  through their condition.
+- Every block of a `try` body gets an "exception" edge to each handler (or to the
+  `finally` block when there is none); a `return` inside a `try` goes straight to the exit.
+- Closures and nested functions are single statements; their bodies get their own graphs.
+- Expressions that branch (`a && b`, ternaries, `?`) do not split blocks.
+"""
+
+from __future__ import annotations
+
+from dataclasses import dataclass
+from typing import Dict, List, Optional, Sequence, Tuple
+
+from tree_sitter import Node
+
+from .graph import BasicBlock, ControlFlowGraph, Edge, Statement
+
+SUPPORTED_LANGUAGES = ("python", "javascript", "typescript", "tsx", "go", "rust", "java", "c", "cpp")
+
+BLOCK_NODE_TYPES = {"block", "statement_block", "compound_statement", "statement_list"}
+IF_NODE_TYPES = {"if_statement", "if_expression"}
+WHILE_NODE_TYPES = {"while_statement", "while_expression"}
+FOR_NODE_TYPES = {"for_statement", "for_in_statement", "enhanced_for_statement", "for_expression", "for_range_loop"}
+DO_NODE_TYPES = {"do_statement"}
+FOREVER_NODE_TYPES = {"loop_expression"}
+SWITCH_NODE_TYPES = {
+    "switch_statement", "expression_switch_statement", "type_switch_statement", "select_statement",
+    "match_statement", "match_expression", "switch_expression",
+}
+# Switches that always take one of their cases (Rust's match is exhaustive; select blocks).
+EXHAUSTIVE_SWITCH_TYPES = {"match_expression", "select_statement"}
+CASE_NODE_TYPES = {
+    "switch_case", "switch_default", "expression_case", "type_case", "default_case", "communication_case",
+    "case_clause", "match_arm", "switch_block_statement_group", "switch_rule", "case_statement",
+}
+# Cases whose end runs into the next case's body unless they break.
+FALLTHROUGH_CASE_TYPES = {"switch_case", "switch_default", "switch_block_statement_group", "case_statement"}
+TRY_NODE_TYPES = {"try_statement", "try_with_resources_statement"}
+HANDLER_NODE_TYPES = {"except_clause", "except_group_clause", "catch_clause"}
+RETURN_NODE_TYPES = {"return_statement", "return_expression"}
+RAISE_NODE_TYPES = {"raise_statement", "throw_statement", "throw_expression"}
+BREAK_NODE_TYPES = {"break_statement", "break_expression"}
+CONTINUE_NODE_TYPES = {"continue_statement", "continue_expression"}
+GOTO_NODE_TYPES = {"goto_statement"}
+LABELED_NODE_TYPES = {"labeled_statement"}
+# Statements that run their header and then their body once.
+WRAPPER_NODE_TYPES = {"with_statement", "synchronized_statement"}
+LABEL_NODE_TYPES = {"statement_identifier", "label_name", "label"}
+# Case-clause parts that select the case rather than run in it.
+_CASE_SELECTOR_FIELDS = {"value", "pattern", "guard", "type", "communication"}
+_CASE_SELECTOR_TYPES = {"switch_label", "case_pattern"}
+_TRUE_CONDITIONS = {"", "True", "true", "1", "(true)", "(1)"}  # "" is C's `for (;;)`
+
+_CONTROL_NODE_TYPES = (
+    IF_NODE_TYPES | WHILE_NODE_TYPES | FOR_NODE_TYPES | FOREVER_NODE_TYPES | SWITCH_NODE_TYPES
+    | RETURN_NODE_TYPES | RAISE_NODE_TYPES | BREAK_NODE_TYPES | CONTINUE_NODE_TYPES
+)
+MAX_STATEMENT_TEXT = 80
+
+
+@dataclass
+class _Jump:
+    """An enclosing loop or switch: where `break` and `continue` inside it go."""
+
+    label: Optional[str]
+    break_to: BasicBlock
+    continue_to: Optional[BasicBlock]  # None for switches
+
+
+def _is_comment(node: Node) -> bool:
+    return "comment" in node.type
+
+
+def _always(condition: str) -> bool:
+    return condition.strip().rstrip(";").strip() in _TRUE_CONDITIONS
+
+
+def _body_nodes(node: Node) -> List[Node]:
+    return [child for child in node.named_children if not _is_comment(child)]
+
+
+class CFGBuilder:
+    def __init__(self, source: bytes):
+        self.source = source
+        self.blocks: List[BasicBlock] = []
+        self.edges: List[Edge] = []
+        self.entry = self._new("entry")
+        self.exit = self._new("exit")
+        self.jumps: List[_Jump] = []
+        self.handlers: List[List[BasicBlock]] = []  # raise targets of the enclosing try statements
+        self.labels: Dict[str, BasicBlock] = {}
+        self.gotos: List[Tuple[BasicBlock, str]] = []
+
+    # -- graph primitives ---------------------------------------------------
+
+    def _new(self, kind: str = "block") -> BasicBlock:
+        block = BasicBlock(len(self.blocks), kind)
+        self.blocks.append(block)
+        return block
+
+    def _edge(self, source: BasicBlock, target: BasicBlock, kind: str, label: Optional[str] = None) -> None:
+        self.edges.append(Edge(source.id, target.id, kind, label))
+
+    def _text(self, node: Node, end: Optional[int] = None) -> str:
+        text = self.source[node.start_byte : node.end_byte if end is None else end].decode("utf-8", "replace")
+        first = " ".join(text.strip().splitlines()[0].split()) if text.strip() else ""
+        return first if len(first) <= MAX_STATEMENT_TEXT else first[: MAX_STATEMENT_TEXT - 3] + "..."
+
+    def _add(self, block: BasicBlock, node: Node) -> None:
+        block.statements.append(Statement(node.type, node.start_point[0] + 1, self._text(node)))
+
+    # -- statements ---------------------------------------------------------
+
+    def build(self, body: Optional[Node]) -> None:
+        start = self._new()
+        self._edge(self.entry, start, "next")
+        end = self.statement(body, start) if body is not None else start
+        self._edge(end, self.exit, "next")
+        for block, label in self.gotos:
+            if label in self.labels:
+                self._edge(block, self.labels[label], "goto")
+
+    def sequence(self, nodes: Sequence[Node], current: BasicBlock) -> BasicBlock:
+        for node in nodes:
+            current = self.statement(node, current)
+        return current
+
+    def statement(self, node: Node, current: BasicBlock, label: Optional[str] = None) -> BasicBlock:
+        """Add `node` after `current`; returns the block control continues in (without predecessors when it cannot)."""
+        kind = node.type
+        if kind == "expression_statement" and node.named_child_count == 1:
+            inner = node.named_children[0]
+            if inner.type in _CONTROL_NODE_TYPES:
+                return self.statement(inner, current, label)
+        if _is_comment(node):
+            return current
+        if kind in BLOCK_NODE_TYPES:
+            return self.sequence(_body_nodes(node), current)
+        if kind in IF_NODE_TYPES:
+            return self._if(node, current)
+        if kind in WHILE_NODE_TYPES or kind in FOR_NODE_TYPES or kind in FOREVER_NODE_TYPES:
+            return self._loop(node, current, label)
+        if kind in DO_NODE_TYPES:
+            return self._do(node, current, label)
+        if kind in SWITCH_NODE_TYPES:
+            return self._switch(node, current, label)
+        if kind in TRY_NODE_TYPES:
+            return self._try(node, current)
+        if kind in LABELED_NODE_TYPES:
+            return self._labeled(node, current)
+        if kind in WRAPPER_NODE_TYPES:
+            self._add(current, node)
+            body = node.child_by_field_name("body")
+            return self.statement(body, current) if body is not None else current
+        self._add(current, node)
+        if kind in RETURN_NODE_TYPES:
+            self._edge(current, self.exit, "return")
+        elif kind in RAISE_NODE_TYPES:
+            for target in self.handlers[-1] if self.handlers else [self.exit]:
+                self._edge(current, target, "raise")
+        elif kind in BREAK_NODE_TYPES or kind in CONTINUE_NODE_TYPES:
+            self._jump(node, current, "break" if kind in BREAK_NODE_TYPES else "continue")
+        elif kind in GOTO_NODE_TYPES:
+            target = node.child_by_field_name("label") or next(iter(_body_nodes(node)), None)
+            if target is None:
+                return current
+            self.gotos.append((current, self._text(target)))
+        else:
+            return current
+        return self._new()  # whatever follows a jump is unreachable from here
+
+    def _if(self, node: Node, current: BasicBlock) -> BasicBlock:
+        self._add(current, node)
+        ends: List[Tuple[BasicBlock, str]] = []
+        then = self._new()
+        self._edge(current, then, "true")
+        consequence = node.child_by_field_name("consequence")
+        ends.append((self.statement(consequence, then) if consequence is not None else then, "next"))
+        test: Optional[BasicBlock] = current
+        for alternative in node.children_by_field_name("alternative"):
+            if alternative.type == "elif_clause":
+                elif_test = self._new()
+                self._add(elif_test, alternative)
+                self._edge(test, elif_test, "false")
+                then = self._new()
+                self._edge(elif_test, then, "true")
+                consequence = alternative.child_by_field_name("consequence")
+                ends.append((self.statement(consequence, then) if consequence is not None else then, "next"))
+                test = elif_test
+                continue
+            body = alternative
+            if alternative.type == "else_clause":
+                body = alternative.child_by_field_name("body") or next(iter(_body_nodes(alternative)), None)
+            otherwise = self._new()
+            self._edge(test, otherwise, "false")
+            ends.append((self.statement(body, otherwise) if body is not None else otherwise, "next"))
+            test = None
+        if test is not None:
+            ends.append((test, "false"))
+        after = self._new()
+        for block, kind in ends:
+            self._edge(block, after, kind)
+        return after
+
+    def _label_of(self, node: Node) -> Optional[str]:
+        for child in node.children:
+            if child.type in LABEL_NODE_TYPES:
+                return self._text(child)
+        return None
+
+    def _infinite(self, node: Node) -> bool:
+        if node.type in FOREVER_NODE_TYPES:
+            return True
+        condition = node.child_by_field_name("condition")
+        if condition is not None:
+            return _always(self._text(condition))
+        if node.type != "for_statement" or node.child_by_field_name("left") is not None:
+            return False
+        for i, child in enumerate(node.children):
+            if child.type == "for_clause":
+                clause = child.child_by_field_name("condition")
+                return clause is None or _always(self._text(clause))
+            if child.type == "range_clause":
+                return False
+            if child.is_named and not _is_comment(child) and node.field_name_for_child(i) is None:
+                return _always(self._text(child))  # Go's `for cond {}`
+        return True  # `for (;;)`, Go's bare `for {}`
+
+    def _loop(self, node: Node, current: BasicBlock, label: Optional[str]) -> BasicBlock:
+        head = self._new()
+        self._edge(current, head, "next")
+        self._add(head, node)
+        after = self._new()
+        body_block = self._new()
+        self._edge(head, body_block, "true")
+        self.jumps.append(_Jump(label or self._label_of(node), after, head))
+        body = node.child_by_field_name("body")
+        end = self.statement(body, body_block) if body is not None else body_block
+        self.jumps.pop()
+        self._edge(end, head, "back")
+        if not self._infinite(node):
+            alternative = node.child_by_field_name("alternative")  # Python's `for ... else`
+            if alternative is not None:
+                otherwise = self._new()
+                self._edge(head, otherwise, "false")
+                inner = alternative.child_by_field_name("body")
+                self._edge(self.statement(inner, otherwise) if inner is not None else otherwise, after, "next")
+            else:
+                self._edge(head, after, "false")
+        return after
+
+    def _do(self, node: Node, current: BasicBlock, label: Optional[str]) -> BasicBlock:
+        body_block = self._new()
+        self._edge(current, body_block, "next")
+        test = self._new()
+        after = self._new()
+        condition = node.child_by_field_name("condition")
+        self._add(test, condition if condition is not None else node)
+        self.jumps.append(_Jump(label, after, test))
+        body = node.child_by_field_name("body")
+        end = self.statement(body, body_block) if body is not None else body_block
+        self.jumps.pop()
+        self._edge(end, test, "next")
+        self._edge(test, body_block, "back")
+        if condition is None or not _always(self._text(condition)):
+            self._edge(test, after, "false")
+        return after
+
+    def _jump(self, node: Node, current: BasicBlock, kind: str) -> None:
+        label = self._label_of(node)
+        if label is None and node.type.endswith("_statement"):
+            # Java names the label with a plain identifier.
+            label = next((self._text(c) for c in node.named_children if c.type == "identifier"), None)
+        for jump in reversed(self.jumps):
+            if kind == "continue" and jump.continue_to is None:
+                continue
+            if label is None or jump.label == label:
+                self._edge(current, jump.break_to if kind == "break" else jump.continue_to, kind)
+                return
+
+    def _labeled(self, node: Node, current: BasicBlock) -> BasicBlock:
+        parts = _body_nodes(node)
+        label_node = node.child_by_field_name("label") or (parts[0] if parts else None)
+        target = self._new()
+        self._edge(current, target, "next")
+        if label_node is None:
+            return target
+        label = self._text(label_node)
+        self.labels[label] = target
+        inner = node.child_by_field_name("body") or (parts[-1] if parts[-1].id != label_node.id else None)
+        return self.statement(inner, target, label) if inner is not None else target
+
+    def _case_label(self, case: Node, body: Sequence[Node]) -> str:
+        text = self._text(case, body[0].start_byte if body else None)
+        for suffix in (":", "=>", "->"):
+            text = text.rstrip().removesuffix(suffix).rstrip()
+        return text.removeprefix("case ").strip() or text
+
+    def _case_body(self, case: Node) -> List[Node]:
+        if case.type == "match_arm":
+            value = case.child_by_field_name("value")
+            return [value] if value is not None else []
+        consequence = case.child_by_field_name("consequence")
+        if consequence is not None:
+            return [consequence]
+        body = [c for c in case.children_by_field_name("body") if not _is_comment(c)]
+        if body:
+            return body
+        parts = []
+        for i, child in enumerate(case.children):
+            if not child.is_named or _is_comment(child) or child.type in _CASE_SELECTOR_TYPES:
+                continue
+            if case.field_name_for_child(i) in _CASE_SELECTOR_FIELDS:
+                continue
+            parts.append(child)
+        return parts
+
+    def _switch(self, node: Node, current: BasicBlock, label: Optional[str]) -> BasicBlock:
+        self._add(current, node)
+        container = node.child_by_field_name("body") or node  # Go lists its cases directly
+        cases = [c for c in container.named_children if c.type in CASE_NODE_TYPES]
+        after = self._new()
+        self.jumps.append(_Jump(label, after, None))
+        falling: Optional[BasicBlock] = None
+        has_default = node.type in EXHAUSTIVE_SWITCH_TYPES
+        for case in cases:
+            body = self._case_body(case)
+            case_label = self._case_label(case, body)
+            if case_label in {"_", "else"} or case_label.startswith("default"):
+                has_default = True
+            entry = self._new()
+            self._edge(current, entry, "case", case_label)
+            if falling is not None:
+                self._edge(falling, entry, "next")
+            end = self.sequence(body, entry)
+            if case.type in FALLTHROUGH_CASE_TYPES or (body and body[-1].type == "fallthrough_statement"):
+                falling = end
+            else:
+                falling = None
+                self._edge(end, after, "next")
+        if falling is not None:
+            self._edge(falling, after, "next")
+        self.jumps.pop()
+        if not has_default:
+            self._edge(current, after, "no_match")
+        return after
+
+    def _try(self, node: Node, current: BasicBlock) -> BasicBlock:
+        body = node.child_by_field_name("body")
+        handlers = [c for c in node.named_children if c.type in HANDLER_NODE_TYPES]
+        handler = node.child_by_field_name("handler")
+        if handler is not None and handler not in handlers:
+            handlers.append(handler)
+        final = node.child_by_field_name("finalizer") or next(
+            (c for c in node.named_children if c.type == "finally_clause"), None
+        )
+        otherwise = next((c for c in node.named_children if c.type == "else_clause"), None)
+        after = self._new()
+        final_block = self._new() if final is not None else None
+        handler_blocks = []
+        for clause in handlers:
+            block = self._new()
+            self._add(block, clause)
+            handler_blocks.append(block)
+        targets = handler_blocks or ([final_block] if final_block is not None else [])
+        normal = final_block or after
+
+        start = self._new()
+        self._edge(current, start, "next")
+        first = len(self.blocks) - 1
+        self.handlers.append(targets or (self.handlers[-1] if self.handlers else [self.exit]))
+        end = self.statement(body, start) if body is not None else start
+        self.handlers.pop()
+        linked = {(e.source, e.target) for e in self.edges}
+        for block in self.blocks[first:]:
+            if block.statements:
+                for target in targets:
+                    if (block.id, target.id) not in linked:  # a `raise` already goes there
+                        self._edge(block, target, "exception")
+        if otherwise is not None:  # Python's `try ... else`: runs when the body raised nothing
+            inner = otherwise.child_by_field_name("body") or next(iter(_body_nodes(otherwise)), None)
+            else_block = self._new()
+            self._edge(end, else_block, "next")
+            end = self.statement(inner, else_block) if inner is not None else else_block
+        self._edge(end, normal, "next")
+        for clause, block in zip(handlers, handler_blocks):
+            inner = _clause_body(clause)
+            self._edge(self.statement(inner, block) if inner is not None else block, normal, "next")
+        if final_block is not None:
+            self._add(final_block, final)
+            inner = _clause_body(final)
+            final_end = self.statement(inner, final_block) if inner is not None else final_block
+            self._edge(final_end, after, "next")
+            if not handler_blocks:  # an exception runs the finally block and propagates
+                for target in self.handlers[-1] if self.handlers else [self.exit]:
+                    self._edge(final_end, target, "raise")
+        return after
+
+    # -- cleanup ------------------------------------------------------------
+
+    def finish(self, name: str, path: str, language: str, start_line: int, end_line: int) -> ControlFlowGraph:
+        """Drop empty pass-through and orphaned blocks, number the rest in source order, and return the graph."""
+        removed = set()
+        changed = True
+        while changed:
+            changed = False
+            for block in self.blocks:
+                if block.kind != "block" or block.id in removed or block.statements:
+                    continue
+                incoming = [e for e in self.edges if e.target == block.id]
+                outgoing = [e for e in self.edges if e.source == block.id]
+                if incoming and not (len(outgoing) == 1 and outgoing[0].kind == "next" and outgoing[0].target != block.id):
+                    continue
+                for edge in incoming:
+                    edge.target = outgoing[0].target
+                self.edges = [e for e in self.edges if e.source != block.id]
+                removed.add(block.id)
+                changed = True
+        kept = [b for b in self.blocks if b.id not in removed]
+        kept[2:] = sorted(kept[2:], key=lambda b: (b.start_line is None, b.start_line or 0, b.id))  # source order
+        numbering = {b.id: i for i, b in enumerate(kept)}
+        for block in kept:
+            block.id = numbering[block.id]
+        edges, seen = [], set()
+        for edge in self.edges:
+            key = (numbering[edge.source], numbering[edge.target], edge.kind, edge.label)
+            if key not in seen:
+                seen.add(key)
+                edges.append(Edge(*key))
+        return ControlFlowGraph(name, path, language, start_line, end_line, kept, edges)
+
+
+def _clause_body(clause: Node) -> Optional[Node]:
+    body = clause.child_by_field_name("body")
+    if body is not None:
+        return body
+    blocks = [c for c in clause.named_children if c.type in BLOCK_NODE_TYPES]
+    return blocks[-1] if blocks else None
+
+
+__all__ = ["SUPPORTED_LANGUAGES", "CFGBuilder"]
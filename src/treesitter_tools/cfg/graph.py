@@ -0,0 +1,227 @@
+"""Basic blocks, edges, and the analyses and exports of one function's control-flow graph."""
+
+from __future__ import annotations
+
+import json
+from dataclasses import dataclass, field
+from typing import Iterator, List, Optional, Sequence, Set
+
+# Why control moves along an edge. "back" closes a loop; "exception" leaves a try body
+# for a handler at any point, "raise" at a raise/throw statement.
+EDGE_KINDS = (
+    "next", "true", "false", "case", "no_match", "back",
+    "break", "continue", "return", "raise", "exception", "goto",
+)
+BLOCK_KINDS = ("entry", "exit", "block")
+
+_DOT_EDGE_STYLES = {"back": "bold", "exception": "dashed", "raise": "dashed", "goto": "dotted"}
+_DOT_EDGE_COLORS = {"true": "darkgreen", "false": "red3", "exception": "gray40", "raise": "gray40"}
+
+
+@dataclass
+class Statement:
+    kind: str  # node type of the statement (or of a compound statement's header)
+    line: int
+    text: str  # first line of the statement, trimmed
+
+    def to_dict(self) -> dict:
+        return {"kind": self.kind, "line": self.line, "text": self.text}
+
+
+@dataclass
+class BasicBlock:
+    id: int
+    kind: str = "block"  # one of BLOCK_KINDS
+    statements: List[Statement] = field(default_factory=list)
+
+    @property
+    def start_line(self) -> Optional[int]:
+        return min((s.line for s in self.statements), default=None)
+
+    @property
+    def end_line(self) -> Optional[int]:
+        return max((s.line for s in self.statements), default=None)
+
+    def to_dict(self) -> dict:
+        return {
+            "id": self.id,
+            "kind": self.kind,
+            "start_line": self.start_line,
+            "end_line": self.end_line,
+            "statements": [s.to_dict() for s in self.statements],
+        }
+
+
+@dataclass
+class Edge:
+    source: int
+    target: int
+    kind: str  # one of EDGE_KINDS
+    label: Optional[str] = None  # the case value of "case" edges
+
+    def to_dict(self) -> dict:
+        data = {"source": self.source, "target": self.target, "kind": self.kind}
+        if self.label is not None:
+            data["label"] = self.label
+        return data
+
+
+@dataclass
+class ControlFlowGraph:
+    """Basic blocks of one function; block 0 is the entry and block 1 the exit."""
+
+    name: str
+    path: str
+    language: str
+    start_line: int
+    end_line: int
+    blocks: List[BasicBlock] = field(default_factory=list)
+    edges: List[Edge] = field(default_factory=list)
+
+    ENTRY = 0
+    EXIT = 1
+
+    def block(self, block_id: int) -> BasicBlock:
+        return self.blocks[block_id]
+
+    def successors(self, block_id: int) -> List[Edge]:
+        return [e for e in self.edges if e.source == block_id]
+
+    def predecessors(self, block_id: int) -> List[Edge]:
+        return [e for e in self.edges if e.target == block_id]
+
+    def reachable(self, start: int = ENTRY) -> Set[int]:
+        """Ids of the blocks reachable from `start` (including it)."""
+        seen = {start}
+        stack = [start]
+        while stack:
+            for edge in self.successors(stack.pop()):
+                if edge.target not in seen:
+                    seen.add(edge.target)
+                    stack.append(edge.target)
+        return seen
+
+    def unreachable(self) -> List[BasicBlock]:
+        """Blocks holding statements that no path from the entry reaches (dead code)."""
+        live = self.reachable()
+        return [b for b in self.blocks if b.id not in live and b.statements]
+
+    def paths(self, limit: int = 100) -> Iterator[List[int]]:
+        """
+        Entry-to-exit paths as block-id lists, each block at most once per path (so loop
+        bodies are taken at most once), depth-first, stopping after `limit` paths.
+        """
+        found = 0
+        stack = [(self.ENTRY, [self.ENTRY])]
+        while stack and found < limit:
+            block_id, path = stack.pop()
+            if block_id == self.EXIT:
+                found += 1
+                yield path
+                continue
+            targets = []
+            for edge in self.successors(block_id):
+                if edge.target not in path and edge.target not in targets:
+                    targets.append(edge.target)
+            stack.extend((target, path + [target]) for target in reversed(targets))
+
+    def to_dict(self) -> dict:
+        live = self.reachable()
+        return {
+            "name": self.name,
+            "path": self.path,
+            "language": self.language,
+            "start_line": self.start_line,
+            "end_line": self.end_line,
+            "entry": self.ENTRY,
+            "exit": self.EXIT,
+            "blocks": [{**b.to_dict(), "reachable": b.id in live} for b in self.blocks],
+            "edges": [e.to_dict() for e in self.edges],
+        }
+
+    def to_text(self) -> str:
+        live = self.reachable()
+        lines = [f"{self.name}  ({self.path}:{self.start_line}-{self.end_line})"]
+        for block in self.blocks:
+            head = _block_name(block)
+            if block.kind == "block" and block.id not in live:
+                head += " (unreachable)"
+            lines.append(f"  {head}:")
+            for s in block.statements:
+                lines.append(f"    {s.line}: {s.text}")
+            for e in self.successors(block.id):
+                kind = e.kind if e.label is None else f"{e.kind} {e.label}"
+                lines.append(f"    -> {_block_name(self.blocks[e.target])} [{kind}]")
+        return "\n".join(lines) + "\n"
+
+    def dot_lines(self, prefix: str = "") -> List[str]:
+        """Node and edge statements of the DOT export, node ids prefixed by `prefix`."""
+        live = self.reachable()
+        lines = []
+        for block in self.blocks:
+            node = _quote(f"{prefix}{_block_name(block)}")
+            if block.kind != "block":
+                lines.append(f"  {node} [label={block.kind}, shape=ellipse];")
+                continue
+            rows = [f"B{block.id}"] + [f"{s.line}: {s.text}" for s in block.statements]
+            label = '"' + "".join(_quote(row)[1:-1] + "\\l" for row in rows) + '"'  # \l: left-aligned lines
+            style = "" if block.id in live else ", style=dashed, fontcolor=gray50"
+            lines.append(f"  {node} [label={label}{style}];")
+        for e in self.edges:
+            attrs = []
+            if e.kind != "next":
+                attrs.append(f"label={_quote(e.kind if e.label is None else e.label)}")
+            if e.kind in _DOT_EDGE_STYLES:
+                attrs.append(f"style={_DOT_EDGE_STYLES[e.kind]}")
+            if e.kind in _DOT_EDGE_COLORS:
+                attrs.append(f"color={_DOT_EDGE_COLORS[e.kind]}")
+            source = _quote(f"{prefix}{_block_name(self.blocks[e.source])}")
+            target = _quote(f"{prefix}{_block_name(self.blocks[e.target])}")
+            lines.append(f"  {source} -> {target}" + (f" [{', '.join(attrs)}]" if attrs else "") + ";")
+        return lines
+
+    def to_dot(self) -> str:
+        """Graphviz digraph; unreachable blocks are dashed and gray."""
+        head = [f"digraph {_quote(self.name)} {{", '  node [shape=box, fontname="monospace"];']
+        return "\n".join(head + self.dot_lines() + ["}"]) + "\n"
+
+
+def _quote(text: str) -> str:
+    return json.dumps(text, ensure_ascii=False)
+
+
+def _block_name(block: BasicBlock) -> str:
+    return block.kind if block.kind != "block" else f"B{block.id}"
+
+
+def graphs_to_json(graphs: Sequence[ControlFlowGraph]) -> str:
+    return json.dumps([g.to_dict() for g in graphs], indent=2)
+
+
+def graphs_to_text(graphs: Sequence[ControlFlowGraph]) -> str:
+    return "\n".join(g.to_text() for g in graphs)
+
+
+def graphs_to_dot(graphs: Sequence[ControlFlowGraph]) -> str:
+    """One digraph with a cluster per function."""
+    lines = ["digraph cfg {", '  node [shape=box, fontname="monospace"];']
+    for i, graph in enumerate(graphs):
+        lines.append(f"  subgraph cluster_{i} {{")
+        lines.append(f"    label={_quote(f'{graph.name} ({graph.path}:{graph.start_line})')};")
+        lines.extend("  " + line for line in graph.dot_lines(prefix=f"f{i}."))
+        lines.append("  }")
+    lines.append("}")
+    return "\n".join(lines) + "\n"
+
+
+__all__ = [
+    "BLOCK_KINDS",
+    "EDGE_KINDS",
+    "BasicBlock",
+    "ControlFlowGraph",
+    "Edge",
+    "Statement",
+    "graphs_to_dot",
+    "graphs_to_json",
+    "graphs_to_text",
+]
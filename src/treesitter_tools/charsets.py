@@ -0,0 +1,212 @@
+"""
+Source encodings: detecting how a file is encoded and transcoding it to UTF-8 for parsing.
+
+UTF-8 is the canonical encoding. Tree-sitter parses bytes, and every byte offset this
+package emits (query captures, chunk and edit ranges, LSP positions) is into a file's
+UTF-8 text. Files in another encoding are transcoded when their source is read (see
+`overlay.read_bytes` and `memory.read_source`), so names and text come out right instead
+of as mojibake, and UTF-16 files are no longer skipped as binary. The encoding is, in
+order:
+
+1. `FORCED` (`--encoding NAME`), without detection; `--encoding utf-8` reads bytes as they are;
+2. the one a byte order mark names (UTF-8, UTF-16 LE/BE, UTF-32 LE/BE), the mark dropped;
+3. UTF-16 without a mark, when NUL bytes fill every other byte of the first 8 KiB;
+4. UTF-8 when the bytes are valid UTF-8 (ASCII included);
+5. a coding declaration in the first two lines (`# -*- coding: latin-1 -*-`,
+   `vim: set fileencoding=cp1252:`);
+6. otherwise Windows-1252 when every byte is defined there, else Latin-1.
+
+Scan reports name the `encoding` of every transcoded file. Edits are computed on the
+UTF-8 text and written back in the file's own encoding (see `patch.write_files`), and
+`source_offset` maps a canonical offset to one in the original bytes.
+"""
+
+from __future__ import annotations
+
+import codecs
+import mmap
+import re
+from dataclasses import dataclass
+from pathlib import Path
+from typing import Optional, Tuple, Union
+
+CANONICAL = "utf-8"
+
+# Set by the CLI (`--encoding`); None detects each file's encoding.
+FORCED: Optional[str] = None
+
+# Longest marks first: the UTF-32 LE mark starts with the UTF-16 LE one.
+BOMS = (
+    (codecs.BOM_UTF32_LE, "utf-32-le"),
+    (codecs.BOM_UTF32_BE, "utf-32-be"),
+    (codecs.BOM_UTF8, "utf-8-sig"),
+    (codecs.BOM_UTF16_LE, "utf-16-le"),
+    (codecs.BOM_UTF16_BE, "utf-16-be"),
+)
+
+SAMPLE_BYTES = 8192
+_CHUNK = 1 << 20
+_COOKIE = re.compile(rb"(?:coding[:=]|fileencoding=|fenc=)\s*([-\w.]+)")
+# Bytes Windows-1252 leaves undefined; text containing them is read as Latin-1.
+_CP1252_HOLES = (b"\x81", b"\x8d", b"\x8f", b"\x90", b"\x9d")
+
+Source = Union[bytes, mmap.mmap]
+
+
+@dataclass(frozen=True)
+class Decoded:
+    data: Source  # the canonical UTF-8 text, without a byte order mark (a mapping only from `canonical`)
+    encoding: str  # what the original bytes were read as
+    bom: bytes = b""  # the original's byte order mark
+
+    @property
+    def transcoded(self) -> bool:
+        return self.encoding != CANONICAL
+
+
+def normalize(name: str) -> str:
+    """`name` as this module reports it (`utf-16-le`, `cp1252`, `latin-1`); ValueError for an unknown codec."""
+    try:
+        codec = codecs.lookup(name).name
+    except LookupError:
+        raise ValueError(f"Unknown encoding '{name}'") from None
+    return "latin-1" if codec == "iso8859-1" else codec
+
+
+def _bom(head: bytes) -> Tuple[Optional[str], bytes]:
+    for mark, encoding in BOMS:
+        if head.startswith(mark):
+            return encoding, mark
+    return None, b""
+
+
+def wide_encoding(head: bytes) -> Optional[str]:
+    """
+    The UTF-16/UTF-32 encoding of text starting with `head` (a mark, or NULs in every
+    other byte), or None. Text in these encodings is full of NUL bytes, so binary
+    detection asks this first.
+    """
+    if FORCED is not None:
+        return FORCED if FORCED.startswith(("utf-16", "utf-32")) else None
+    encoding, _ = _bom(head)
+    if encoding is not None:
+        return encoding if encoding != "utf-8-sig" else None
+    sample = head[:SAMPLE_BYTES]
+    sample = sample[: len(sample) - len(sample) % 2]
+    pairs = len(sample) // 2
+    if pairs < 2:
+        return None
+    even, odd = sample[0::2].count(0), sample[1::2].count(0)
+    for zeros, other, encoding in ((odd, even, "utf-16-le"), (even, odd, "utf-16-be")):
+        if zeros >= pairs * 0.3 and other <= pairs * 0.02:
+            try:
+                sample.decode(encoding)
+            except UnicodeDecodeError:
+                return None
+            return encoding
+    return None
+
+
+def _is_utf8(data: Source) -> bool:
+    decoder = codecs.getincrementaldecoder("utf-8")()
+    try:
+        for start in range(0, len(data), _CHUNK):
+            decoder.decode(data[start : start + _CHUNK])
+        decoder.decode(b"", final=True)
+    except UnicodeDecodeError:
+        return False
+    return True
+
+
+def _declared(data: Source) -> Optional[str]:
+    for line in data[:SAMPLE_BYTES].split(b"\n", 2)[:2]:
+        match = _COOKIE.search(line)
+        if match is not None:
+            try:
+                return normalize(match.group(1).decode("ascii"))
+            except (ValueError, UnicodeDecodeError):
+                return None
+    return None
+
+
+def detect(data: Source) -> Tuple[str, bytes]:
+    """The encoding `data` is read as and its byte order mark (b"" when there is none)."""
+    if FORCED is not None:
+        return FORCED, b""
+    head = data[:SAMPLE_BYTES]
+    encoding, mark = _bom(head)
+    if encoding is not None:
+        return ("utf-8" if encoding == "utf-8-sig" else encoding), mark
+    if b"\x00" in head:
+        return wide_encoding(head) or CANONICAL, b""
+    if _is_utf8(data):
+        return CANONICAL, b""
+    declared = _declared(data)
+    if declared is not None:
+        return declared, b""
+    return ("latin-1" if any(data.find(hole) >= 0 for hole in _CP1252_HOLES) else "cp1252"), b""
+
+
+def decode(data: Source) -> Decoded:
+    """`data` as canonical UTF-8 (the same bytes when they already are)."""
+    return _transcode(data, *detect(data))
+
+
+def _transcode(data: Source, encoding: str, mark: bytes) -> Decoded:
+    if encoding == CANONICAL and not mark:
+        return Decoded(data if isinstance(data, bytes) else data[:], CANONICAL)
+    if encoding == CANONICAL:  # UTF-8 with a byte order mark
+        return Decoded(data[len(mark):], "utf-8-sig", mark)
+    text = data[len(mark):].decode(encoding, "replace")
+    return Decoded(text.encode(CANONICAL, "surrogatepass"), encoding, mark)
+
+
+def encode(data: bytes, encoding: str, bom: bytes = b"") -> bytes:
+    """Canonical `data` back in `encoding` (with its mark); ValueError for text the encoding cannot hold."""
+    if encoding == CANONICAL and not bom:
+        return data
+    codec = CANONICAL if encoding == "utf-8-sig" else encoding
+    try:
+        return bom + data.decode(CANONICAL, "surrogatepass").encode(codec)
+    except UnicodeEncodeError as exc:
+        raise ValueError(f"The new text cannot be encoded as {encoding}: {exc.reason}") from exc
+
+
+def source_offset(decoded: Decoded, offset: int) -> int:
+    """The offset in the original bytes of canonical byte `offset` of `decoded`."""
+    if not decoded.transcoded:
+        return offset
+    text = decoded.data[:offset].decode(CANONICAL, "replace")
+    codec = CANONICAL if decoded.encoding == "utf-8-sig" else decoded.encoding
+    return len(decoded.bom) + len(text.encode(codec, "replace"))
+
+
+def canonical(data: Source) -> Decoded:
+    """
+    What to parse for source `data`, with the encoding it was read as: `data` itself
+    (a mapping stays one) when it is UTF-8, else its transcoding.
+    """
+    encoding, mark = detect(data)
+    if encoding == CANONICAL and not mark:
+        return Decoded(data, CANONICAL)
+    return _transcode(data, encoding, mark)
+
+
+def read_file(path) -> Decoded:
+    """The saved contents of `path`, decoded (for commands that write files back)."""
+    return decode(Path(path).read_bytes())
+
+
+__all__ = [
+    "BOMS",
+    "CANONICAL",
+    "Decoded",
+    "canonical",
+    "decode",
+    "detect",
+    "encode",
+    "normalize",
+    "read_file",
+    "source_offset",
+    "wide_encoding",
+]
@@ -0,0 +1,177 @@
+"""Checkpoints, graceful shutdown, and throttling for long streamed extraction jobs."""
+
+from __future__ import annotations
+
+import json
+import os
+import signal
+import threading
+import time
+from pathlib import Path
+from typing import Callable, Iterable, Iterator, List, Optional, Set, TypeVar
+
+T = TypeVar("T")
+
+CHECKPOINT_VERSION = 1
+
+
+class CheckpointError(ValueError):
+    """The checkpoint file is unreadable or belongs to a different job."""
+
+
+class Checkpoint:
+    """
+    The progress file of a streamed scan.
+
+    Line 1 is a JSON header describing the job (its root, output, format, and
+    options). Each later line is a commit, `{"offset": N, "paths": [...]}`, written
+    only after the output was flushed and fsynced: the listed paths are done, and
+    the first N bytes of the output hold their records. A resumed job cuts the
+    output back to the last offset, so records written after the last commit are
+    dropped and redone rather than duplicated. A torn final line (a crash while
+    committing) is ignored. `{"complete": true}` marks a finished job.
+    """
+
+    def __init__(self, path: Path, header: dict, completed: Optional[Set[str]] = None, offset: int = 0):
+        self.path = Path(path)
+        self.header = header
+        self.completed: Set[str] = completed or set()
+        self.offset = offset
+        self.complete = False
+        self._pending: List[str] = []
+
+    @classmethod
+    def create(cls, path: Path, header: dict) -> "Checkpoint":
+        checkpoint = cls(path, {"version": CHECKPOINT_VERSION, **header})
+        checkpoint._append(checkpoint.header, mode="w")
+        return checkpoint
+
+    @classmethod
+    def load(cls, path: Path) -> "Checkpoint":
+        try:
+            lines = Path(path).read_text(encoding="utf-8").splitlines()
+        except OSError as exc:
+            raise CheckpointError(f"Cannot read checkpoint {path}: {exc}") from None
+        try:
+            header = json.loads(lines[0]) if lines else None
+        except json.JSONDecodeError:
+            header = None
+        if not isinstance(header, dict) or header.get("version") != CHECKPOINT_VERSION:
+            raise CheckpointError(f"{path} is not a treesitter-tools checkpoint (version {CHECKPOINT_VERSION})")
+        checkpoint = cls(path, header)
+        for number, line in enumerate(lines[1:], start=2):
+            try:
+                commit = json.loads(line)
+            except json.JSONDecodeError:
+                if number == len(lines):
+                    break  # interrupted mid-write; the previous commit stands
+                raise CheckpointError(f"{path}:{number}: corrupt checkpoint line") from None
+            if commit.get("complete"):
+                checkpoint.complete = True
+                continue
+            checkpoint.completed.update(commit.get("paths", []))
+            checkpoint.offset = int(commit.get("offset", checkpoint.offset))
+        return checkpoint
+
+    def check(self, header: dict) -> None:
+        """Raise unless this checkpoint was written by the job `header` describes."""
+        expected = {"version": CHECKPOINT_VERSION, **header}
+        for key in sorted(set(expected) | set(self.header)):
+            if self.header.get(key) != expected.get(key):
+                raise CheckpointError(
+                    f"{self.path} belongs to a different job ({key}: {self.header.get(key)!r}, "
+                    f"now {expected.get(key)!r}); delete it to start over"
+                )
+
+    def done(self, path: str) -> bool:
+        return path in self.completed
+
+    def mark(self, path: str) -> None:
+        """Record that every record of `path` has been written; it is committed with the next `commit`."""
+        self._pending.append(path)
+
+    def commit(self, offset: int) -> None:
+        """Append the marked paths with the durable output size `offset`."""
+        if not self._pending and offset == self.offset:
+            return
+        self._append({"offset": offset, "paths": self._pending})
+        self.completed.update(self._pending)
+        self._pending = []
+        self.offset = offset
+
+    def finish(self, offset: int) -> None:
+        self.commit(offset)
+        self._append({"complete": True})
+        self.complete = True
+
+    def _append(self, entry: dict, mode: str = "a") -> None:
+        with self.path.open(mode, encoding="utf-8") as handle:
+            handle.write(json.dumps(entry, ensure_ascii=False) + "\n")
+            handle.flush()
+            os.fsync(handle.fileno())
+
+
+class GracefulStop:
+    """
+    While active, SIGTERM and SIGINT set `signum` instead of killing the process,
+    so a job can finish the file in hand, flush, and exit. A second signal
+    restores the default behaviour and delivers it. Outside the main thread
+    (where handlers cannot be installed) this does nothing.
+    """
+
+    SIGNALS = (signal.SIGTERM, signal.SIGINT)
+
+    def __init__(self):
+        self.signum: Optional[int] = None
+        self._previous: dict = {}
+
+    @property
+    def requested(self) -> bool:
+        return self.signum is not None
+
+    def _handle(self, signum, frame) -> None:
+        if self.signum is not None:
+            signal.signal(signum, signal.SIG_DFL)
+            os.kill(os.getpid(), signum)
+            return
+        self.signum = signum
+
+    def __enter__(self) -> "GracefulStop":
+        if threading.current_thread() is threading.main_thread():
+            for sig in self.SIGNALS:
+                self._previous[sig] = signal.signal(sig, self._handle)
+        return self
+
+    def __exit__(self, exc_type, exc, tb) -> None:
+        for sig, handler in self._previous.items():
+            signal.signal(sig, handler)
+        self._previous = {}
+
+    @property
+    def exit_code(self) -> int:
+        """The shell convention for death by signal: 128 + its number."""
+        return 128 + (self.signum or 0)
+
+
+def throttle(
+    items: Iterable[T],
+    rate: Optional[float],
+    clock: Callable[[], float] = time.monotonic,
+    sleep: Callable[[float], None] = time.sleep,
+) -> Iterator[T]:
+    """Yield `items` no faster than `rate` per second (all at once when `rate` is None)."""
+    if not rate:
+        yield from items
+        return
+    interval = 1.0 / rate
+    due = clock()
+    for item in items:
+        now = clock()
+        if now < due:
+            sleep(due - now)
+            now = due
+        due = max(due, now) + interval
+        yield item
+
+
+__all__ = ["CHECKPOINT_VERSION", "Checkpoint", "CheckpointError", "GracefulStop", "throttle"]
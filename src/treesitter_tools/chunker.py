@@ -0,0 +1,460 @@
+"""
+Semantic chunking along Tree-sitter node boundaries for RAG pipelines.
+
+With `ChunkOptions(links=True)` (`chunk --links`) chunks form a graph a retriever can
+walk instead of going back to the files: each file starts with a `file` chunk (its
+imports and the first line of each top-level declaration), a class too large for one
+chunk gets a `class` chunk outlining its members before the members themselves, and
+every chunk names its `parent_id` (the class outline, else the file chunk), its
+`prev_id`/`next_id` siblings under the same parent in file order, and the `file_id`.
+Outline chunks are summaries, not source slices: their lines span the declaration
+they outline.
+"""
+
+from __future__ import annotations
+
+import json
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Callable, Collection, Iterable, List, Optional, Sequence
+
+from tree_sitter import Node
+
+from . import failures, schema
+from .core import (
+    CLASS_NODE_TYPES,
+    DEFAULT_FUNCTION_NODE_TYPES,
+    FUNCTION_NODE_TYPES,
+    IMPORT_NODE_TYPES,
+    ParsedFile,
+    _go_receiver,
+    _layout_free,
+    content_id,
+    function_name,
+    iter_source_files,
+    parse_file,
+)
+
+TokenCounter = Callable[[str], int]
+
+COMMENT_NODE_TYPES = {"comment", "line_comment", "block_comment"}
+
+
+def estimate_tokens(text: str) -> int:
+    """Cheap tokenizer-free estimate (~4 characters per token for source code)."""
+    return (len(text) + 3) // 4
+
+
+@dataclass
+class Chunk:
+    path: str
+    language: str
+    kind: str
+    name: str
+    start_line: int
+    end_line: int
+    content: str
+    context: str = ""
+    token_count: int = 0
+    index: int = 0
+    part: Optional[int] = None
+    part_count: Optional[int] = None
+    id: Optional[str] = None  # see `core.content_id`; unlike `index`, unchanged by edits to other chunks
+    # Links (ChunkOptions.links): IDs of the enclosing outline chunk, the neighbouring siblings, and the file chunk
+    parent_id: Optional[str] = None
+    prev_id: Optional[str] = None
+    next_id: Optional[str] = None
+    file_id: Optional[str] = None
+
+    @property
+    def text(self) -> str:
+        """Context header plus content, i.e. what should be embedded."""
+        return f"{self.context}\n{self.content}" if self.context else self.content
+
+    def to_dict(self) -> dict:
+        data = {
+            **({"id": self.id} if self.id else {}),
+            "path": self.path,
+            "language": self.language,
+            "index": self.index,
+            "kind": self.kind,
+            "name": self.name,
+            "start_line": self.start_line,
+            "end_line": self.end_line,
+            "token_count": self.token_count,
+            "context": self.context,
+            "content": self.content,
+        }
+        if self.part_count:
+            data["part"] = self.part
+            data["part_count"] = self.part_count
+        for name in ("parent_id", "prev_id", "next_id", "file_id"):
+            if getattr(self, name):
+                data[name] = getattr(self, name)
+        return data
+
+
+@dataclass
+class ChunkOptions:
+    max_tokens: int = 512
+    overlap_lines: int = 0
+    include_context: bool = True
+    count_tokens: TokenCounter = estimate_tokens
+    links: bool = False  # add file and class outline chunks, and parent/sibling/file IDs
+
+
+@dataclass
+class _Span:
+    """A contiguous byte range of the source that maps to one logical unit."""
+
+    start: int
+    end: int
+    kind: str = "module"
+    name: str = "<module>"
+    node: Optional[Node] = None
+    members: List["_Span"] = field(default_factory=list)
+
+
+class _Chunker:
+    def __init__(self, parsed: ParsedFile, label: str, options: ChunkOptions):
+        self.parsed = parsed
+        self.source = parsed.source
+        self.label = label
+        self.options = options
+        self.func_nodes = FUNCTION_NODE_TYPES.get(parsed.language, DEFAULT_FUNCTION_NODE_TYPES)
+        self.class_nodes = CLASS_NODE_TYPES.get(parsed.language, set()) - {"decorated_definition"}
+        self.chunks: List[Chunk] = []
+        self.parents: List[Optional[int]] = []  # with links, the index of each chunk's parent chunk
+        self._parent: Optional[int] = None
+
+    # -- helpers ---------------------------------------------------------
+
+    def _text(self, start: int, end: int) -> str:
+        return self.source[start:end].decode("utf-8", "replace")
+
+    def _line(self, offset: int) -> int:
+        return self.source.count(b"\n", 0, offset) + 1
+
+    def _tokens(self, text: str) -> int:
+        return self.options.count_tokens(text)
+
+    def _declaration(self, node: Node, depth: int = 0) -> Optional[tuple[str, Node]]:
+        """Find the function/class a top-level node declares (looking through wrappers)."""
+        if node.type in self.func_nodes and node.type != "decorated_definition":
+            return "function", node
+        if node.type in self.class_nodes:
+            if node.type == "type_spec":
+                type_node = node.child_by_field_name("type")
+                if type_node is None or type_node.type not in {"struct_type", "interface_type"}:
+                    return None
+            return "class", node
+        if depth >= 2:
+            return None
+        for child in node.named_children:
+            found = self._declaration(child, depth + 1)
+            if found:
+                return found
+        return None
+
+    def _body(self, node: Node) -> Optional[Node]:
+        body = node.child_by_field_name("body")
+        if body is None and node.type == "type_spec":
+            body = node.child_by_field_name("type")
+        return body
+
+    def _spans(self, nodes: Sequence[Node], container: Optional[str]) -> List[_Span]:
+        """Turn sibling nodes into spans, attaching leading comments to the next declaration."""
+        spans: List[_Span] = []
+        pending_comment: Optional[int] = None
+        for node in nodes:
+            if node.type in COMMENT_NODE_TYPES:
+                if pending_comment is None:
+                    pending_comment = node.start_byte
+                continue
+            start = pending_comment if pending_comment is not None else node.start_byte
+            pending_comment = None
+            decl = self._declaration(node)
+            if decl is None:
+                spans.append(_Span(start, node.end_byte))
+                continue
+            kind, inner = decl
+            owner = container
+            if kind == "function":
+                name = function_name(inner, self.source)
+                if inner.type == "method_declaration" and self.parsed.language == "go":
+                    owner = _go_receiver(inner, self.source)[1] or container
+                kind = "method" if owner else "function"
+            else:
+                name_node = inner.child_by_field_name("name")
+                if name_node is None and inner.type == "impl_item":
+                    name_node = inner.child_by_field_name("type")
+                name = self._text(name_node.start_byte, name_node.end_byte) if name_node is not None else "<anonymous>"
+            qualified = f"{owner}.{name}" if owner else name
+            span = _Span(start, node.end_byte, kind, qualified, inner)
+            if kind == "class":
+                body = self._body(inner)
+                if body is not None:
+                    span.members = self._spans(body.named_children, qualified)
+            spans.append(span)
+        if pending_comment is not None and nodes:
+            spans.append(_Span(pending_comment, nodes[-1].end_byte))
+        return spans
+
+    def _first_lines(self, spans: List[_Span], indent: str = "") -> List[str]:
+        """The first line of each declaration in `spans` (its decorators and leading comments skipped)."""
+        lines = []
+        for span in spans:
+            if span.node is None:
+                continue
+            text = self._header_line(span.node) if span.kind == "class" else self._text(span.node.start_byte, span.end)
+            lines.append(indent + text.split("\n", 1)[0].rstrip())
+        return lines
+
+    def _header_line(self, node: Node) -> str:
+        """Declaration text up to its body, e.g. `class Foo(Base):` or `impl Cache {`."""
+        body = self._body(node)
+        end = body.start_byte if body is not None else node.end_byte
+        text = self._text(node.start_byte, end).rstrip()
+        if node.type == "type_spec":
+            text = f"type {text}"
+        if body is not None and self.source[body.start_byte:body.start_byte + 1] == b"{":
+            text += " {"
+        return text
+
+    # -- emission --------------------------------------------------------
+
+    def _emit(self, span_kind: str, name: str, start: int, end: int, context: str,
+              part: Optional[int] = None, part_count: Optional[int] = None, overlap_start: Optional[int] = None) -> None:
+        begin = overlap_start if overlap_start is not None else start
+        content = self._text(begin, end)
+        if not content.strip():
+            return
+        chunk = Chunk(
+            path=self.label,
+            language=self.parsed.language,
+            kind=span_kind,
+            name=name,
+            start_line=self._line(begin),
+            end_line=self._line(max(begin, end - 1)),
+            content=content,
+            context=context if self.options.include_context else "",
+            part=part,
+            part_count=part_count,
+        )
+        self._add(chunk)
+
+    def _add(self, chunk: Chunk) -> None:
+        chunk.token_count = self._tokens(chunk.text)
+        self.chunks.append(chunk)
+        self.parents.append(self._parent)
+
+    def _emit_outline(self, kind: str, name: str, start: int, end: int, content: str, context: str = "") -> None:
+        """A summary chunk (links only); chunks emitted after it are its children until the caller resets `_parent`."""
+        chunk = Chunk(
+            path=self.label,
+            language=self.parsed.language,
+            kind=kind,
+            name=name,
+            start_line=self._line(start),
+            end_line=self._line(max(start, end - 1)),
+            content=content,
+            context=context if self.options.include_context else "",
+        )
+        self._add(chunk)
+        self._parent = len(self.chunks) - 1
+
+    def _budget(self, context: str) -> int:
+        used = self._tokens(context + "\n") if (context and self.options.include_context) else 0
+        return max(self.options.max_tokens - used, self.options.max_tokens // 4, 1)
+
+    def _overlap_start(self, offset: int) -> int:
+        """Byte offset `overlap_lines` lines before `offset`."""
+        start = offset
+        for _ in range(self.options.overlap_lines):
+            prev = self.source.rfind(b"\n", 0, max(start - 1, 0))
+            start = prev + 1 if prev >= 0 else 0
+            if start == 0:
+                break
+        return start
+
+    def _split_ranges(self, ranges: List[tuple[int, int]], budget: int) -> List[tuple[int, int]]:
+        """Greedily merge consecutive byte ranges while they fit in `budget` tokens."""
+        pieces: List[tuple[int, int]] = []
+        for start, end in ranges:
+            if pieces and self._tokens(self._text(pieces[-1][0], end)) <= budget:
+                pieces[-1] = (pieces[-1][0], end)
+            else:
+                pieces.append((start, end))
+        result: List[tuple[int, int]] = []
+        for start, end in pieces:
+            if self._tokens(self._text(start, end)) <= budget:
+                result.append((start, end))
+            else:
+                result.extend(self._split_lines(start, end, budget))
+        return result
+
+    def _split_lines(self, start: int, end: int, budget: int) -> List[tuple[int, int]]:
+        """Last resort for a single oversized statement: split on line boundaries."""
+        lines: List[tuple[int, int]] = []
+        cursor = start
+        while cursor < end:
+            nl = self.source.find(b"\n", cursor, end)
+            stop = end if nl < 0 else nl + 1
+            lines.append((cursor, stop))
+            cursor = stop
+        pieces: List[tuple[int, int]] = []
+        for line_start, line_end in lines:
+            if pieces and self._tokens(self._text(pieces[-1][0], line_end)) <= budget:
+                pieces[-1] = (pieces[-1][0], line_end)
+            else:
+                pieces.append((line_start, line_end))
+        return pieces
+
+    def _statement_ranges(self, node: Node, start: int) -> List[tuple[int, int]]:
+        body = self._body(node)
+        if body is None:
+            return [(start, node.end_byte)]
+        statements = body.named_children
+        while len(statements) == 1 and statements[0].named_child_count:
+            statements = statements[0].named_children
+        if not statements:
+            return [(start, node.end_byte)]
+        # Cut at the start of each statement's line so parts never begin mid-line.
+        boundaries = [start]
+        for stmt in statements:
+            line_start = self.source.rfind(b"\n", 0, stmt.start_byte) + 1
+            if line_start > boundaries[-1]:
+                boundaries.append(line_start)
+        boundaries.append(self._line_end(node.end_byte))
+        return [(a, b) for a, b in zip(boundaries, boundaries[1:]) if b > a]
+
+    def _line_end(self, offset: int) -> int:
+        """Past the newline ending `offset`'s line when only whitespace follows `offset` on it, else `offset`."""
+        newline = self.source.find(b"\n", offset)
+        end = newline + 1 if newline >= 0 else len(self.source)
+        return end if not self.source[offset:end].strip() else offset
+
+    def _emit_span(self, span: _Span, context: str) -> None:
+        text = self._text(span.start, span.end)
+        budget = self._budget(context)
+        if self._tokens(text) <= budget or span.node is None:
+            if self._tokens(text) > budget:
+                self._emit_parts(span, context, self._split_lines(span.start, span.end, budget))
+            else:
+                self._emit(span.kind, span.name, span.start, span.end, context)
+            return
+        if span.kind == "class" and span.members:
+            header = self._header_line(span.node)
+            member_context = "\n".join(filter(None, [context, header]))
+            parent = self._parent
+            if self.options.links:
+                closing = ["}"] if header.endswith("{") else []
+                outline = "\n".join([header, *self._first_lines(span.members, "    "), *closing])
+                self._emit_outline("class", span.name, span.start, span.end, outline, context)
+            self._emit_group(span.members, member_context)
+            self._parent = parent
+            return
+        signature = self._header_line(span.node)
+        part_context = "\n".join(filter(None, [context, signature]))
+        ranges = self._statement_ranges(span.node, span.start)
+        self._emit_parts(span, part_context, self._split_ranges(ranges, self._budget(part_context)))
+
+    def _emit_parts(self, span: _Span, context: str, pieces: List[tuple[int, int]]) -> None:
+        for i, (start, end) in enumerate(pieces):
+            overlap = self._overlap_start(start) if i and self.options.overlap_lines else None
+            self._emit(span.kind, span.name, start, end, context, part=i, part_count=len(pieces), overlap_start=overlap)
+
+    def _emit_group(self, spans: List[_Span], context: str) -> None:
+        """Emit declarations one per chunk; merge runs of loose statements up to the budget."""
+        budget = self._budget(context)
+        loose: List[tuple[int, int]] = []
+
+        def flush() -> None:
+            for start, end in self._split_ranges(loose, budget):
+                self._emit("module", "<module>", start, end, context)
+            loose.clear()
+
+        for span in spans:
+            if span.node is None:
+                loose.append((span.start, span.end))
+                continue
+            flush()
+            self._emit_span(span, context)
+        flush()
+
+    def run(self) -> List[Chunk]:
+        imports = IMPORT_NODE_TYPES.get(self.parsed.language, set())
+        header_nodes = [n for n in self.parsed.root.named_children if n.type in imports]
+        body_nodes = [n for n in self.parsed.root.children if n.type not in imports and n.is_named]
+        context = "\n".join(self._text(n.start_byte, n.end_byte) for n in header_nodes)
+        spans = self._spans(body_nodes, None)
+        if self.options.links:
+            summary = "\n".join(([context, ""] if context else []) + self._first_lines(spans))
+            if summary.strip():
+                self._emit_outline("file", self.label, 0, len(self.source), summary)
+        self._emit_group(spans, context)
+        seen: dict = {}
+        for i, chunk in enumerate(self.chunks):
+            chunk.index = i
+            key = (chunk.kind, chunk.name, chunk.part, _layout_free(chunk.content))
+            occurrence = seen[key] = seen.get(key, -1) + 1
+            chunk.id = content_id(self.label, chunk.kind, chunk.name, chunk.content, chunk.part, occurrence)
+        if self.options.links:
+            self._link()
+        return self.chunks
+
+    def _link(self) -> None:
+        file_id = self.chunks[0].id if self.chunks and self.chunks[0].kind == "file" else None
+        siblings: dict = {}
+        for i, chunk in enumerate(self.chunks):
+            parent = self.parents[i]
+            chunk.parent_id = self.chunks[parent].id if parent is not None else None
+            chunk.file_id = file_id if i else None
+            siblings.setdefault(parent, []).append(chunk)
+        for group in siblings.values():
+            for before, after in zip(group, group[1:]):
+                before.next_id, after.prev_id = after.id, before.id
+
+
+def chunk_parsed(parsed: ParsedFile, options: Optional[ChunkOptions] = None, label: Optional[str] = None) -> List[Chunk]:
+    return _Chunker(parsed, label or parsed.path.as_posix(), options or ChunkOptions()).run()
+
+
+def chunk_file(path: Path, options: Optional[ChunkOptions] = None, language: Optional[str] = None) -> List[Chunk]:
+    """Split one source file into semantic chunks."""
+    return chunk_parsed(parse_file(path, language), options)
+
+
+def chunk_directory(
+    root: Path,
+    options: Optional[ChunkOptions] = None,
+    include: Sequence[str] | None = None,
+    exclude: Sequence[str] | None = None,
+    only: Optional[Collection[Path]] = None,
+) -> List[Chunk]:
+    """Chunk every recognised file under `root`; unparseable files are skipped."""
+    root = Path(root).resolve()
+    chunks: List[Chunk] = []
+    for path in iter_source_files(root, include, exclude, only):
+        try:
+            parsed = parse_file(path)
+        except (ValueError, RuntimeError, OSError) as exc:
+            failures.record(path, exc)
+            continue
+        chunks.extend(chunk_parsed(parsed, options, path.relative_to(root).as_posix()))
+    return chunks
+
+
+def chunks_to_json(chunks: Iterable[Chunk]) -> str:
+    return json.dumps(schema.conform("chunk", [chunk.to_dict() for chunk in chunks]), indent=2)
+
+
+__all__ = [
+    "Chunk",
+    "ChunkOptions",
+    "chunk_directory",
+    "chunk_file",
+    "chunk_parsed",
+    "chunks_to_json",
+    "estimate_tokens",
+]
@@ -1,38 +1,292 @@
 from __future__ import annotations
 
+import contextlib
+import glob
+import io
 import json
+import os
+import signal
+import sqlite3
+import sys
+import threading
+from collections import Counter
 from pathlib import Path
-from typing import List, Optional
+from typing import List, Optional, Sequence, Tuple
 
+import click
 import typer
+from typer.core import TyperGroup
 
 from .core import (
+    LANGUAGE_MAPPINGS,
+    LANGUAGE_SPECS,
     CodeSymbol,
+    FileSymbols,
+    assign_symbol_ids,
+    detect_language,
     extract_symbols,
+    iter_scan_directory,
+    iter_source_files,
     outline_markdown,
+    outline_section,
+    parse_file,
     run_query,
-    scan_directory,
     symbols_to_json,
 )
+from .apidiff import compare_refs
+from .apisurface import api_to_json, api_to_text, collect_api
+from .astdiff import changes_to_json, changes_to_text, diff_files
+from .astdump import tree_to_dict, tree_to_dot, tree_to_json, tree_to_sexp
+from .budget import fit_symbols, get_tokenizer
+from .bundle import MAX_HOPS, build_bundle
+from .callgraph import build_call_graph
+from .checkpoint import Checkpoint, CheckpointError, GracefulStop, throttle
+from .chunker import ChunkOptions, chunk_directory, chunk_file, chunks_to_json
+from .clones import DEFAULT_MIN_NODES, clones_to_json, clones_to_sarif, clones_to_text, find_clones
+from .config import CONFIG_NAMES, ConfigError, ProjectConfig, find_config, load_config
+from .deps import build_dependency_graph, deps_to_text
+from .detect import detect_file
+from .diagnostics import MAX_EXPECTED, check_paths
+from .directives import collect_directives, directives_to_json, directives_to_text
+from . import buildaction, charsets, failures, generated, ignore, overlay, preproc, redact, reproducible, schema
+from .embeddings import DEFAULT_ENDPOINT, DEFAULT_MODEL, EndpointConfig, embed_directory, http_embedder
+from .summarize import DEFAULT_ENDPOINT as SUMMARY_ENDPOINT, DEFAULT_MODEL as SUMMARY_MODEL
+from .gitdiff import ChangeSet, changed_since
+from .export.scip import build_index as build_scip_index, encode_index, index_to_json
+from .goimpl import implementers, load_universe, results_to_json, results_to_text, satisfied_interfaces
+from .grammars import (
+    LOCK_NAME,
+    MANIFEST_NAME,
+    list_grammars,
+    load_grammar_dir,
+    pin_grammars,
+    read_lock,
+    verify_grammars,
+)
+from .history import symbol_history
+from .hotspots import METRICS, collect_hotspots, hotspots_to_json, hotspots_to_text
+from .incremental import IncrementalSession
+from .literals import iter_literals, literals_to_json, literals_to_ndjson, literals_to_text
+from .imports import check_imports, import_fixes, imports_to_json, imports_to_sarif, imports_to_text
+from .index import QUERY_KINDS, SymbolIndex, gc_index, snippet_to_text
+from .mcp_server import MCPServer
+from .memory import format_size, memory_watermark, parse_size
+from .metrics import (
+    collect_metrics,
+    find_violations,
+    metrics_to_csv,
+    metrics_to_json,
+    parse_thresholds,
+    violations_to_sarif,
+)
+from .ndjson import NDJSONWriter, report_records
+from .playground import render_matches
+from .plugins import (
+    PluginError,
+    SubprocessAnalyzer,
+    command_analyzer,
+    entry_point_analyzers,
+    import_analyzer,
+    run_analyzers,
+)
+from .patch import apply_patch, cwd_label, edit_set, edits_to_json, load_edits, write_files
+from .rename import parse_location, rename_symbol
+from .querylib import add_query_dir, check_queries, list_queries, load_query
+from .resolver import resolve_at
+from .rewrite import rewrite_paths
+from .server import ToolService, make_grpc_server, make_http_server, make_metrics_server
+from .sinks import FileSink, local_path, open_sink
+from .sources import SourceError, is_input_source, materialize
+from .skeleton import collect_skeletons, skeletons_to_json, skeletons_to_markdown, skeletons_to_text
+from .tags import collect_tags, to_ctags, to_etags
+from .telemetry import configure_tracing
+from .testmap import map_tests
+from .treemap import collect_treemap, treemap_to_html, treemap_to_json
+from .unused import find_unused, load_allowlist, unused_to_json, unused_to_sarif, unused_to_text
+from .watch import SymbolWatcher, watch as watch_directory
+
+class _InputSourcePath(click.ParamType):
+    """A directory argument that also accepts archives and git+ URLs (see `sources`)."""
+
+    def __init__(self, path_type: click.Path):
+        self.path_type = path_type
+        self.name = path_type.name
+
+    def convert(self, value, param, ctx):
+        if isinstance(value, str) and is_input_source(value):
+            try:
+                value = materialize(value)
+            except SourceError as e:
+                self.fail(str(e), param, ctx)
+        return self.path_type.convert(value, param, ctx)
+
+
+def _accept_input_sources(command: click.Command) -> None:
+    for sub in getattr(command, "commands", {}).values():
+        _accept_input_sources(sub)
+    for param in command.params:
+        if isinstance(param, click.Argument) and isinstance(param.type, click.Path) and param.type.dir_okay:
+            param.type = _InputSourcePath(param.type)
+
+
+class _Group(TyperGroup):
+    def get_command(self, ctx, cmd_name):
+        command = super().get_command(ctx, cmd_name)
+        if command is not None:
+            _accept_input_sources(command)
+        return command
+
+
+app = typer.Typer(cls=_Group, add_completion=False, help="Tree-sitter helpers for inspecting local code.")
+index_app = typer.Typer(help="Build and query a persistent SQLite symbol index.")
+config_app = typer.Typer(help="Inspect and validate the project config file.")
+workspace_app = typer.Typer(help="Scan or index several project roots as one tree.")
+schema_app = typer.Typer(help="Print the versioned JSON Schemas of the JSON/NDJSON output records.")
+grammars_app = typer.Typer(help="List, pin, and verify the tree-sitter grammars in use.")
+app.add_typer(index_app, name="index")
+app.add_typer(config_app, name="config")
+app.add_typer(workspace_app, name="workspace")
+app.add_typer(schema_app, name="schema")
+app.add_typer(grammars_app, name="grammars")
+
+DEFAULT_INDEX_DB = Path(".treesitter-tools") / "index.db"
+
+# Accepted --format values per command, used to validate `format:` in the project config.
+FORMAT_CHOICES = {
+    "symbols": ("json", "pretty", "template"),
+    "scan": ("json", "ndjson", "proto", "pretty", "template"),
+    "workspace scan": ("json", "ndjson"),
+    "chunk": ("json", "proto"),
+    "action": ("json", "proto"),
+    "decode": ("ndjson", "json"),
+    "manifest-diff": ("text", "json"),
+    "callgraph": ("json", "dot"),
+    "get": ("text", "json"),
+    "attribute": ("text", "json"),
+    "extract": ("text", "json"),
+    "scip": ("scip", "json"),
+    "graph-export": ("cypher", "jgf"),
+    "metrics": ("json", "csv", "sarif"),
+    "tags": ("ctags", "etags"),
+    "diff": ("json", "text"),
+    "unused": ("json", "text", "sarif"),
+    "imports": ("text", "json", "sarif", "diff", "edits"),
+    "headers": ("text", "json", "sarif", "diff", "edits"),
+    "go-impl": ("json", "text"),
+    "skeleton": ("text", "markdown", "json"),
+    "query": ("json", "text", "template"),
+    "deps": ("json", "dot", "mermaid", "text"),
+    "diagnostics": ("text", "json", "sarif"),
+    "ast": ("json", "sexp", "dot"),
+    "hotspots": ("text", "json"),
+    "history": ("text", "json", "csv"),
+    "clones": ("text", "json", "sarif"),
+    "tests": ("text", "json"),
+    "api": ("text", "json"),
+    "api-diff": ("text", "json"),
+    "resolve": ("text", "json"),
+    "strings": ("text", "json", "ndjson"),
+    "directives": ("text", "json"),
+    "context": ("markdown", "json"),
+    "detect": ("text", "json"),
+    "analyze": ("text", "json", "sarif"),
+    "security": ("text", "json", "sarif"),
+    "queries": ("text", "json"),
+    "stats": ("text", "json"),
+    "hierarchy": ("json", "text", "dot"),
+    "cfg": ("json", "text", "dot"),
+    "dataflow": ("text", "json"),
+    "selection-range": ("json", "text", "lsp"),
+    "bench": ("text", "json"),
+    "rewrite": ("diff", "edits"),
+    "rename": ("patch", "edits"),
+    "apply": ("diff", "json"),
+    "docs": ("markdown", "html"),
+    "schema list": ("text", "json"),
+    "grammars list": ("text", "json"),
+    "grammars verify": ("text", "json"),
+}
+
+TOKENIZER_HELP = "Token counter: heuristic (~4 chars/token), tiktoken, or tiktoken:<encoding|model>"
+PRETTY_HIGHLIGHT_HELP = "ANSI syntax-highlight pretty output (default: when stdout is a terminal)"
+PRETTY_LINES_HELP = "With pretty output, collapse bodies longer than N lines (0: show every line)"
+OUTPUT_HELP = "Write to a file (.gz to compress), s3://BUCKET/KEY, or an http(s):// webhook instead of stdout"
+MAX_FILE_SIZE_HELP = "Skip files larger than this (e.g. 50MB); they are reported as errors instead of parsed"
+NO_CACHE_HELP = "Ignore --cache-dir (and the config's cache_dir) for this run"
+NORMALIZE_HELP = (
+    "Use the cross-language symbol schema: a precise kind (method, struct, interface, ...), "
+    "container chain, qualified name, and visibility"
+)
+VISIBILITY_HELP = (
+    "Only symbols with this visibility: public, protected, internal, or private (repeatable; adds `visibility`)"
+)
+ONLY_PUBLIC_HELP = "Only the public API surface (same as --visibility public)"
+SINCE_HELP = "Only analyse files changed between the merge base of this git ref and HEAD (e.g. origin/main)"
+SUMMARIZE_HELP = "Add a one-line `summary` to each function from an OpenAI-compatible chat endpoint"
+SUMMARY_ENDPOINT_HELP = "Chat endpoint base URL for --summarize (POSTs to /chat/completions; default: local Ollama)"
+TEMPLATE_HELP = "With --format template, a Go text/template per symbol, e.g. '{{.Kind}} {{.Name}} {{.File}}:{{.Line}}'"
 
-app = typer.Typer(add_completion=False, help="Tree-sitter helpers for inspecting local code.")
+# Project config loaded by the app callback (None when no config file applies).
+_CONFIG: Optional[ProjectConfig] = None
 
 
-def _echo_symbols(symbols: list[CodeSymbol], output: Optional[Path], include_content: bool = False) -> None:
+def _echo_symbols(symbols: list[CodeSymbol], output: Optional[str], include_content: bool = False) -> None:
     if not include_content:
         # Strip content if not requested
         for sym in symbols:
             sym.content = None
     payload = symbols_to_json(symbols)
-    if output:
+    if output and output != "-":
+        _emit(payload, output, f"{len(symbols)} symbols")
+    else:
+        typer.echo(payload)
+
+
+def _template(text: Optional[str], fmt: str, record: str):
+    """The parsed `--template` (checked against `record`), or None without --format template; exits on errors."""
+    from .template import Template, check_template
+
+    try:
+        check_template(text, fmt)
+        return Template(text, record) if fmt == "template" else None
+    except ValueError as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+
+
+def _changes_since(ref: Optional[str], path: Path) -> Optional[ChangeSet]:
+    """Resolve `--since`; exits with an error when git or the ref is unavailable."""
+    if ref is None:
+        return None
+    try:
+        changes = changed_since(ref, path)
+    except (ValueError, RuntimeError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    typer.secho(f"Changed since {ref}: {len(changes)} files", err=True)
+    return changes
+
+
+def _emit(payload: str | bytes, output: Optional[str], summary: str) -> None:
+    """Write `payload` to the `output` sink (reporting `summary`) or echo it to stdout."""
+    if output and output != "-":
         try:
-            output.write_text(payload, encoding="utf-8")
-            typer.echo(f"Wrote {len(symbols)} symbols -> {output}")
+            with open_sink(output) as sink:
+                if isinstance(payload, bytes):
+                    sink.write_bytes(payload)
+                else:
+                    sink.write(payload)
+            typer.echo(f"Wrote {summary} -> {sink}")
         except OSError as e:
             typer.secho(f"I/O Error writing output: {e}", err=True, fg=typer.colors.RED)
             raise typer.Exit(1)
     else:
-        typer.echo(payload)
+        # Binary payloads are written as they are; text gets a final newline if it lacks one.
+        typer.echo(payload, nl=isinstance(payload, str) and not payload.endswith("\n"))
+
+
+# Commands that serve requests until stopped; their per-request errors are answered, not collected.
+_LONG_RUNNING = {"serve", "watch", "repl"}
 
 
 def version_callback(value: bool):
@@ -42,29 +296,235 @@ def version_callback(value: bool):
 
 @app.callback()
 def main(
+    ctx: typer.Context,
     version: Optional[bool] = typer.Option(
         None, "--version", callback=version_callback, is_eager=True, help="Show version and exit"
     ),
+    grammar_dir: Optional[Path] = typer.Option(
+        None,
+        "--grammar-dir",
+        envvar="TREESITTER_TOOLS_GRAMMAR_DIR",
+        file_okay=False,
+        help=f"Load extra .wasm/.so grammars listed in DIR/{MANIFEST_NAME}",
+    ),
+    config: Optional[Path] = typer.Option(
+        None,
+        "--config",
+        envvar="TREESITTER_TOOLS_CONFIG",
+        dir_okay=False,
+        help=f"Project config file (default: nearest {CONFIG_NAMES[0]} in the current directory or its parents)",
+    ),
+    query_dir: List[Path] = typer.Option(
+        [],
+        "--query-dir",
+        envvar="TREESITTER_TOOLS_QUERY_DIR",
+        file_okay=False,
+        help="Directory of DIR/<language>/<name>.scm queries overriding the bundled ones (repeatable)",
+    ),
+    skip_generated: bool = typer.Option(
+        False, "--skip-generated", help="Leave generated files (DO NOT EDIT markers, *.pb.go, minified JS) out of directory walks"
+    ),
+    skip_vendored: bool = typer.Option(
+        False, "--skip-vendored", help="Leave files under vendor/, node_modules/, third_party/, ... out of directory walks"
+    ),
+    no_ignore: bool = typer.Option(
+        False, "--no-ignore", help="Walk files that .gitignore, git's exclude files, and .tstoolsignore would skip"
+    ),
+    redact_secrets: Optional[bool] = typer.Option(
+        None, "--redact/--no-redact", envvar="TREESITTER_TOOLS_REDACT",
+        help="Mask secrets (API keys, tokens, JWTs, private keys, connection-string passwords) in string literals",
+    ),
+    redact_pattern: List[str] = typer.Option(
+        [], "--redact-pattern", help="Extra secret regex to mask (repeatable; implies --redact)"
+    ),
+    schema_version: Optional[str] = typer.Option(
+        None, "--schema-version", envvar="TREESITTER_TOOLS_SCHEMA_VERSION",
+        help=f"Emit JSON records as schema version MAJOR.MINOR describes them (default: {schema.SCHEMA_VERSION})",
+    ),
+    strict: bool = typer.Option(
+        False, "--strict", help="Exit non-zero when a directory walk could not read or parse some of its files"
+    ),
+    error_report: Optional[Path] = typer.Option(
+        None, "--error-report", dir_okay=False,
+        help="Write the files a directory walk could not read or parse, with their category, to FILE as JSON",
+    ),
+    preprocessor: bool = typer.Option(
+        False, "--preprocessor",
+        help="C/C++: parse every #if/#ifdef branch and tag each symbol with the condition guarding it",
+    ),
+    deterministic: bool = typer.Option(
+        False, reproducible.FLAG, envvar=reproducible.ENV_VAR,
+        help="Byte-identical output across runs: fixed clock (SOURCE_DATE_EPOCH), zeroed durations, fixed hashing",
+    ),
+    overlays: List[str] = typer.Option(
+        [], "--overlay",
+        help="PATH=CONTENTS_FILE: analyze PATH as if it held CONTENTS_FILE's text, e.g. an unsaved buffer (repeatable)",
+    ),
+    encoding: str = typer.Option(
+        "auto", "--encoding", envvar="TREESITTER_TOOLS_ENCODING",
+        help="Source encoding: auto (detect BOMs, UTF-16, Latin-1/Windows-1252), or a codec to read every file with",
+    ),
 ):
     """
     Tree-sitter helpers for inspecting local code.
     """
-    pass
+    global _CONFIG
+    _CONFIG = None  # in-process runs (tests, embedding) must not see the previous run's config
+    generated.SKIP_GENERATED = skip_generated
+    generated.SKIP_VENDORED = skip_vendored
+    ignore.RESPECT_IGNORES = not no_ignore
+    preproc.ENABLED = preprocessor
+    reproducible.ENABLED = deterministic
+    if ctx.invoked_subcommand not in _LONG_RUNNING:
+        failures.ACTIVE = failures.FailureReport()
+        ctx.call_on_close(lambda: _finish_failures(strict, error_report))
+    try:
+        schema.PINNED = schema.check_version(schema_version) if schema_version else None
+        if deterministic:
+            reproducible.source_date_epoch()  # reject a malformed value before any output
+        overlay.ACTIVE = overlay.Overlay.from_specs(overlays) if overlays else None
+        charsets.FORCED = charsets.normalize(encoding) if encoding != "auto" else None
+        if grammar_dir is not None:
+            load_grammar_dir(grammar_dir)
+        for directory in reversed(query_dir):  # the first one given wins
+            add_query_dir(directory)
+        if ctx.invoked_subcommand == "config":
+            return
+        path = config or find_config(Path.cwd())
+        if path is not None:
+            _CONFIG = load_config(path, FORMAT_CHOICES)
+            ctx.with_resource(_CONFIG.language_overrides())  # undone when the command finishes
+            ctx.default_map = _config_default_map(ctx.command, _CONFIG)
+        configured = _CONFIG or ProjectConfig()
+        enabled = redact_secrets if redact_secrets is not None else bool(redact_pattern) or configured.redact
+        redact.ACTIVE = (
+            redact.Redactor.from_options([*configured.redact_patterns, *redact_pattern], configured.redact_builtin)
+            if enabled else None
+        )
+    except (ValueError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+
+
+def _finish_failures(strict: bool, error_report: Optional[Path]) -> None:
+    """
+    When a command ends: summarize the files its walks skipped (unless it already did),
+    write `--error-report`, and under `--strict` turn any failure into exit status 1.
+    """
+    report, failures.ACTIVE = failures.ACTIVE, None
+    if report is None:
+        return
+    if error_report is not None:
+        try:
+            report.write(error_report)
+        except OSError as e:
+            typer.secho(f"Error: Cannot write --error-report: {e}", err=True, fg=typer.colors.RED)
+            raise typer.Exit(1)
+    if len(report) > report.announced:
+        hint = "" if error_report is not None else " (--error-report FILE lists them)"
+        typer.secho(f"Skipped {len(report)} files: {report.summary()}{hint}.", err=True, fg=typer.colors.YELLOW)
+    if strict and len(report) and sys.exc_info()[0] is None:  # an error exit of the command itself wins
+        typer.secho(f"Error: --strict: {len(report)} files could not be read or parsed", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+
+
+def _config_default_map(group, config: ProjectConfig, prefix: str = "") -> dict:
+    """Click `default_map` so config values apply only where a flag wasn't given."""
+    defaults = {}
+    for name, command in getattr(group, "commands", {}).items():
+        full_name = f"{prefix}{name}"
+        if getattr(command, "commands", None):
+            nested = _config_default_map(command, config, f"{full_name} ")
+        else:
+            nested = config.command_defaults(full_name, [p.name for p in command.params])
+        if nested:
+            defaults[name] = nested
+    return defaults
 
 @app.command()
 def symbols(
     path: Path = typer.Argument(..., exists=True, readable=True, help="Path to the source file to inspect"),
     language: Optional[str] = typer.Option(None, help="Override detected language"),
-    output: Optional[Path] = typer.Option(None, help="Optional path to JSON output"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
     content: bool = typer.Option(False, "--content", "-c", help="Include full source code of symbols"),
     max_chunk_size: Optional[int] = typer.Option(None, help="Max size in chars for content chunks"),
+    max_tokens: Optional[int] = typer.Option(
+        None, min=1, help="Fit the output into N tokens, trimming bodies before signatures (implies --content)"
+    ),
+    tokenizer: str = typer.Option("heuristic", help=TOKENIZER_HELP),
+    since: Optional[str] = typer.Option(None, help="Mark symbols added/modified since this git ref"),
+    max_file_size: Optional[str] = typer.Option(None, help=MAX_FILE_SIZE_HELP),
+    fmt: str = typer.Option(
+        "json",
+        "--format",
+        "-f",
+        help="json, pretty (highlighted source with line numbers, for reading in a terminal), or template (--template)",
+    ),
+    template: Optional[str] = typer.Option(None, help=TEMPLATE_HELP),
+    highlight: Optional[bool] = typer.Option(None, "--highlight/--no-highlight", help=PRETTY_HIGHLIGHT_HELP),
+    max_lines: int = typer.Option(20, "--max-lines", min=0, help=PRETTY_LINES_HELP),
+    normalize: bool = typer.Option(False, "--normalize", help=NORMALIZE_HELP),
+    visibility: List[str] = typer.Option([], "--visibility", help=VISIBILITY_HELP),
+    only_public: bool = typer.Option(False, "--only-public", help=ONLY_PUBLIC_HELP),
+    summarize: bool = typer.Option(False, "--summarize", help=SUMMARIZE_HELP),
+    summary_endpoint: str = typer.Option(
+        SUMMARY_ENDPOINT, envvar="TREESITTER_TOOLS_SUMMARY_ENDPOINT", help=SUMMARY_ENDPOINT_HELP
+    ),
+    summary_model: str = typer.Option(
+        SUMMARY_MODEL, envvar="TREESITTER_TOOLS_SUMMARY_MODEL", help="Model for --summarize"
+    ),
+    summary_jobs: int = typer.Option(4, min=1, help="Concurrent --summarize requests"),
+    cache_dir: Optional[Path] = typer.Option(None, help="Reuse --summarize results cached here, by function body hash"),
 ):
     """List functions/classes detected in the file."""
+    if fmt not in FORMAT_CHOICES["symbols"]:
+        typer.secho(
+            f"Error: Unsupported format '{fmt}' (expected json, pretty, or template)", err=True, fg=typer.colors.RED
+        )
+        raise typer.Exit(1)
+    if fmt != "json" and max_tokens is not None:
+        typer.secho("Error: --max-tokens shapes JSON output; use --format json", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    renderer = _template(template, fmt, "symbol-record")
+    levels = _visibility_levels(visibility, only_public)
+    changes = _changes_since(since, path)
     try:
-        items = extract_symbols(path, language, max_chunk_size)
+        items = extract_symbols(path, language, max_chunk_size, _size_limit(max_file_size))
+        assign_symbol_ids(items, _module_label(path))
         if not items:
             typer.secho(f"Warning: No symbols found in {path}", err=True, fg=typer.colors.YELLOW)
-            
+        if changes is not None:
+            changes.annotate(path, items, detect_language(path, language))
+        if normalize or levels:
+            from .normalize import annotate_visibility, filter_visibility, normalize_symbols
+
+            rewrite = normalize_symbols if normalize else annotate_visibility
+            rewrite(items, parse_file(path, language), _module_label(path))
+            if levels:
+                items = filter_visibility(items, levels)
+        if fmt == "pretty":
+            from .pretty import render_symbols
+
+            use_color = highlight if highlight is not None else (output is None and sys.stdout.isatty())
+            payload = render_symbols(items, parse_file(path, language), str(path), use_color, max_lines)
+            _emit(payload, output, f"{len(items)} symbols")
+            return
+        summarizer = _summarizer(summarize, summary_endpoint, summary_model, summary_jobs, cache_dir)
+        if summarizer is not None:
+            list(summarizer.summarize([FileSymbols(path, detect_language(path, language) or "", items)]))
+            _summary_report(summarizer)
+        if renderer is not None:
+            if not content:
+                for sym in items:
+                    sym.content = None
+            report = FileSymbols(path, detect_language(path, language) or "", items)
+            _emit(renderer.render_all(report_records(report, per_symbol=True)), output, f"{len(items)} symbols")
+            return
+        if max_tokens is not None:
+            report = fit_symbols([items], max_tokens, get_tokenizer(tokenizer))
+            typer.secho(report.summary(), err=True)
+            content = True
+
         _echo_symbols(items, output, content)
     except ValueError as e:
         typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
@@ -80,24 +540,119 @@ def symbols(
 
 @app.command()
 def query(
-    path: Path = typer.Argument(..., exists=True, readable=True, help="Path to the source file to inspect"),
-    query: str = typer.Argument(..., help="Tree-sitter query to execute"),
-    language: Optional[str] = typer.Option(None, help="Override detected language"),
-    output: Optional[Path] = typer.Option(None, help="Optional path to JSON output"),
+    path: Path = typer.Argument(..., exists=True, readable=True, help="Source file, or a directory of them"),
+    query: Optional[str] = typer.Argument(None, help="Tree-sitter query to execute"),
+    language: Optional[str] = typer.Option(None, "--language", "--lang", help="Override detected language"),
+    named: Optional[str] = typer.Option(
+        None, help="Run a named query from the project config or the query library (e.g. tags, highlights, locals, folds)"
+    ),
+    query_file: Optional[Path] = typer.Option(
+        None, exists=True, dir_okay=False, help="Read the query from a .scm file instead of the QUERY argument"
+    ),
+    fmt: str = typer.Option(
+        "json", "--format", "-f", help="Output format: json, text (captures with source context), or template"
+    ),
+    template: Optional[str] = typer.Option(
+        None, help="With --format template, a Go text/template rendered per match (e.g. '{{.Path}} {{len .Captures}}')"
+    ),
+    highlight: Optional[bool] = typer.Option(
+        None, "--highlight/--no-highlight", help="ANSI-colour captures in text output (default: when stdout is a terminal)"
+    ),
+    context: int = typer.Option(0, min=0, help="With text output, source lines to show around each match"),
+    include: List[str] = typer.Option(["**/*"], help="With a directory, glob patterns to include"),
+    exclude: List[str] = typer.Option([], help="With a directory, glob patterns to exclude"),
+    group_by: List[str] = typer.Option(
+        [], "--group-by", help="Summarize captures by capture, text, file, or dir (repeat to combine keys)"
+    ),
+    capture: List[str] = typer.Option([], "--capture", help="With --group-by, count only captures of this name"),
+    sort: str = typer.Option("count", help="With --group-by, order groups by count (most first) or key"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+    cache_dir: Optional[Path] = typer.Option(
+        None, help="Reuse cached captures while the file, grammar, and query are unchanged"
+    ),
+    no_cache: bool = typer.Option(False, "--no-cache", help=NO_CACHE_HELP),
 ):
-    """Execute a Tree-sitter query and return the captures."""
+    """Execute a Tree-sitter query and return the captures, or a summary of them with --group-by."""
+    if fmt not in FORMAT_CHOICES["query"]:
+        typer.secho(
+            f"Error: Unsupported format '{fmt}' (expected json, text, or template)", err=True, fg=typer.colors.RED
+        )
+        raise typer.Exit(1)
+    renderer = _template(template, fmt, "query-match")
+    if renderer is not None and group_by:
+        typer.secho("Error: --group-by output is json or text, not a template", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
     try:
-        matches = run_query(path, query, language)
-        payload = json.dumps(matches, indent=2)
-        if output:
-            try:
-                output.write_text(payload, encoding="utf-8")
-                typer.echo(f"Wrote {len(matches)} matches -> {output}")
-            except OSError as e:
-                typer.secho(f"I/O Error writing output: {e}", err=True, fg=typer.colors.RED)
-                raise typer.Exit(1)
+        if sum(1 for given in (query, named, query_file) if given) > 1:
+            raise ValueError("Pass only one of QUERY, --named, or --query-file")
+        if query_file:
+            query = query_file.read_text(encoding="utf-8")
+        elif not query and not named:
+            raise ValueError("Provide a QUERY argument, --query-file, or --named")
+
+        def query_for(detected: Optional[str]) -> str:
+            if not named:
+                return query
+            text = _CONFIG.query_for(named, detected) if _CONFIG is not None else None
+            if text is None:
+                if not detected:
+                    raise ValueError(f"Cannot detect Tree-sitter language for {path}")
+                text = load_query(detected, named)
+            return text
+
+        if group_by:
+            from .aggregate import SORTS, aggregate_captures, check_group_by, groups_to_text
+
+            check_group_by(group_by)
+            if sort not in SORTS:
+                raise ValueError(f"Unknown sort '{sort}' (expected count or key)")
+        cache = None
+        if cache_dir and not no_cache:
+            from .cache import ResultCache, run_query_cached
+
+            cache = ResultCache(cache_dir)
+        if path.is_dir():
+            from .aggregate import query_directory
+
+            results, skipped = query_directory(path, query_for, language, include, exclude, cache)
+            for name, error in skipped.items():
+                typer.secho(f"Warning: Skipped {name} files: {error}", err=True, fg=typer.colors.YELLOW)
         else:
-            typer.echo(payload)
+            text = query_for(detect_language(path, language))
+            if cache is not None:
+                matches = run_query_cached(cache, path, text, language)
+            else:
+                matches = run_query(path, text, language)
+            results = [(path.as_posix(), matches)]
+        if group_by:
+            groups = aggregate_captures(results, group_by, capture, sort)
+            if fmt == "text":
+                payload = groups_to_text(groups, group_by)
+            else:
+                payload = json.dumps([group.to_dict() for group in groups], indent=2)
+            _emit(payload, output, f"{len(groups)} groups")
+            return
+        if fmt == "text":
+            use_color = highlight if highlight is not None else (output is None and sys.stdout.isatty())
+            if not path.is_dir():
+                payload = render_matches(matches, overlay.read_bytes(path), highlight=use_color, context=context)
+            else:
+                payload = "\n\n".join(
+                    f"# {label}\n"
+                    + render_matches(found, overlay.read_bytes(path / label), highlight=use_color, context=context)
+                    for label, found in results
+                    if found
+                )
+        elif renderer is not None:
+            labelled = [{"path": label, **match} for label, found in results for match in found]
+            payload = renderer.render_all(schema.conform("query-match", labelled))
+        elif not path.is_dir():
+            payload = json.dumps(schema.conform("query-match", matches), indent=2)
+        else:
+            labelled = [{"path": label, **match} for label, found in results for match in found]
+            payload = json.dumps(schema.conform("query-match", labelled), indent=2)
+        total = sum(len(found) for _, found in results)
+        _emit(payload, output, f"{total} matches" + (f" in {len(results)} files" if path.is_dir() else ""))
     except ValueError as e:
         typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
         typer.secho("Hint: Try using --language to manually specify the language.", err=True, fg=typer.colors.YELLOW)
@@ -115,58 +670,2802 @@ def scan(
     root: Path = typer.Argument(Path.cwd(), help="Directory to walk"),
     include: List[str] = typer.Option(["**/*"], help="Glob patterns to include"),
     exclude: List[str] = typer.Option([], help="Glob patterns to exclude"),
-    output: Optional[Path] = typer.Option(None, help="Write JSON report to this path"),
-    outline: Optional[Path] = typer.Option(None, help="Optional markdown outline destination"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+    outline: Optional[str] = typer.Option(None, help="Optional markdown outline destination"),
     content: bool = typer.Option(False, "--content", "-c", help="Include full source code of symbols"),
     verbose: bool = typer.Option(False, "--verbose", "-v", help="Print errors and skipped files"),
     max_chunk_size: Optional[int] = typer.Option(None, help="Max size in chars for content chunks"),
+    cache_dir: Optional[Path] = typer.Option(
+        None, help="Reuse cached results for files whose content hash is unchanged"
+    ),
+    no_cache: bool = typer.Option(False, "--no-cache", help=NO_CACHE_HELP),
+    jobs: int = typer.Option(1, "--jobs", "-j", min=1, help="Parse files in N worker processes (output order is unchanged)"),
+    max_in_flight: Optional[int] = typer.Option(
+        None, help="With --jobs, max files parsed but not yet collected (default 2 x jobs)"
+    ),
+    fmt: str = typer.Option(
+        "json",
+        "--format",
+        "-f",
+        help="json (one array at the end), ndjson (one record per line, streamed), proto (binary records, streamed), "
+        "pretty (highlighted source), or template (one --template line per symbol)",
+    ),
+    template: Optional[str] = typer.Option(None, help=TEMPLATE_HELP),
+    per_symbol: bool = typer.Option(False, help="With ndjson, emit one record per symbol instead of per file"),
+    flush_every: int = typer.Option(1000, min=1, help="With ndjson or proto, flush output after this many records"),
+    max_tokens: Optional[int] = typer.Option(
+        None, min=1, help="Fit the whole report into N tokens, trimming bodies before signatures (implies --content)"
+    ),
+    tokenizer: str = typer.Option("heuristic", help=TOKENIZER_HELP),
+    since: Optional[str] = typer.Option(None, help=SINCE_HELP + "; symbols get a change marker"),
+    max_file_size: Optional[str] = typer.Option(None, help=MAX_FILE_SIZE_HELP),
+    memory_report: bool = typer.Option(False, "--memory-report", help="Print peak resident memory to stderr when done"),
+    highlight: Optional[bool] = typer.Option(None, "--highlight/--no-highlight", help=PRETTY_HIGHLIGHT_HELP),
+    max_lines: int = typer.Option(20, "--max-lines", min=0, help=PRETTY_LINES_HELP),
+    normalize: bool = typer.Option(False, "--normalize", help=NORMALIZE_HELP),
+    visibility: List[str] = typer.Option([], "--visibility", help=VISIBILITY_HELP),
+    only_public: bool = typer.Option(False, "--only-public", help=ONLY_PUBLIC_HELP),
+    checkpoint: Optional[Path] = typer.Option(
+        None, dir_okay=False, help="With ndjson or proto to a local --output, record finished files here for --resume"
+    ),
+    resume: bool = typer.Option(False, "--resume", help="Continue the job in --checkpoint, skipping finished files"),
+    rate: Optional[float] = typer.Option(None, min=0.001, help="Scan at most this many files per second"),
+    summarize: bool = typer.Option(False, "--summarize", help=SUMMARIZE_HELP + " (cached in --cache-dir)"),
+    summary_endpoint: str = typer.Option(
+        SUMMARY_ENDPOINT, envvar="TREESITTER_TOOLS_SUMMARY_ENDPOINT", help=SUMMARY_ENDPOINT_HELP
+    ),
+    summary_model: str = typer.Option(
+        SUMMARY_MODEL, envvar="TREESITTER_TOOLS_SUMMARY_MODEL", help="Model for --summarize"
+    ),
+    summary_jobs: int = typer.Option(4, min=1, help="Concurrent --summarize requests"),
 ):
     """Walk a directory and summarize symbols per file."""
-    
-    reports = scan_directory(root, include, exclude, max_chunk_size)
-    
+    if fmt not in FORMAT_CHOICES["scan"]:
+        typer.secho(
+            f"Error: Unsupported format '{fmt}' (expected json, ndjson, proto, pretty, or template)",
+            err=True,
+            fg=typer.colors.RED,
+        )
+        raise typer.Exit(1)
+    renderer = _template(template, fmt, "symbol-record")
+    if resume and checkpoint is None:
+        typer.secho("Error: --resume needs --checkpoint FILE", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    if checkpoint is not None and (fmt not in {"ndjson", "proto"} or outline):
+        typer.secho(
+            "Error: --checkpoint needs --format ndjson or proto (and no --outline)", err=True, fg=typer.colors.RED
+        )
+        raise typer.Exit(1)
+    if max_tokens is not None and fmt != "json":
+        typer.secho("Error: --max-tokens needs the whole report; use --format json", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    _refuse_binary_terminal(fmt, output)
+    levels = _visibility_levels(visibility, only_public)
+    try:
+        count_tokens = get_tokenizer(tokenizer) if max_tokens is not None else None
+        size_limit = _size_limit(max_file_size)
+    except (ValueError, RuntimeError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+
+    changes = _changes_since(since, root)
+    session = IncrementalSession(cache_dir, max_chunk_size, keep_trees=False) if cache_dir and not no_cache else None
+    summarizer = _summarizer(
+        summarize, summary_endpoint, summary_model, summary_jobs, cache_dir if not no_cache else None
+    )
+    scan_args = dict(
+        session=session, jobs=jobs, max_in_flight=max_in_flight, only=changes.paths if changes is not None else None,
+        max_file_size=size_limit,
+    )
+    if fmt in {"ndjson", "proto"}:
+        # Files are written while the walk is running; keep the walk from picking them up.
+        own_files = []
+        for path in (local_path(output), local_path(outline), checkpoint):
+            if path is not None:
+                try:
+                    own_files.append(glob.escape(path.resolve().relative_to(root.resolve()).as_posix()))
+                except ValueError:
+                    pass
+        job = None
+        if checkpoint is not None:
+            options = dict(
+                include=list(include), exclude=list(exclude), content=content, per_symbol=per_symbol,
+                max_chunk_size=max_chunk_size, normalize=normalize, visibility=levels, since=since,
+                max_file_size=max_file_size, summary_model=summary_model if summarize else None,
+            )
+            job = _scan_checkpoint(checkpoint, resume, root, output, fmt, options)
+            if job[0].completed:
+                typer.secho(f"Resuming: {len(job[0].completed)} files already done.", err=True)
+            scan_args["skip"] = {root.resolve() / rel for rel in job[0].completed}
+        reports = iter_scan_directory(root, include, list(exclude) + own_files, max_chunk_size, **scan_args)
+        _scan_stream(
+            _summarized(throttle(_normalized(_annotated(reports, changes), root, normalize, levels), rate), summarizer),
+            fmt, output, outline, content, per_symbol, flush_every, session, verbose, job, root,
+        )
+        if summarizer is not None:
+            _summary_report(summarizer)
+        if memory_report:
+            typer.secho(memory_watermark(), err=True)
+        return
+
+    reports = list(_summarized(_normalized(
+        _annotated(throttle(iter_scan_directory(root, include, exclude, max_chunk_size, **scan_args), rate), changes),
+        root,
+        normalize,
+        levels,
+    ), summarizer))
+    if summarizer is not None:
+        _summary_report(summarizer)
+    budget = None
+    if count_tokens is not None:
+        budget = fit_symbols([r.symbols for r in reports], max_tokens, count_tokens)
+        content = True
+
     # Strip content if not requested
     if not content:
         for report in reports:
             for sym in report.symbols:
                 sym.content = None
     
-    # Calculate stats
-    total_files = len(reports)
-    files_with_symbols = sum(1 for r in reports if r.symbols)
-    total_symbols = sum(len(r.symbols) for r in reports)
-    errors = [r for r in reports if r.error]
-    
-    # Print summary to stderr
+    _scan_summary(
+        len(reports),
+        sum(len(r.symbols) for r in reports),
+        sum(1 for r in reports if r.symbols),
+        [r for r in reports if r.error],
+        session,
+        verbose,
+    )
+    if budget is not None:
+        typer.secho(budget.summary(), err=True)
+
+    if fmt == "pretty":
+        from .pretty import render_reports
+
+        use_color = highlight if highlight is not None else (output is None and sys.stdout.isatty())
+        payload = render_reports(reports, root, use_color, max_lines)
+    elif renderer is not None:
+        payload = renderer.render_all(
+            record for report in reports for record in report_records(report, per_symbol=True) if "error" not in record
+        )
+    else:
+        payload = json.dumps(schema.conform("file", [report.to_dict() for report in reports]), indent=2)
+    if output and output != "-":
+        _emit(payload, output, f"symbol report ({len(reports)} files)")
+    else:
+        typer.echo(payload)
+    if outline:
+        _emit(outline_markdown(reports), outline, "outline")
+    if memory_report:
+        typer.secho(memory_watermark(), err=True)
+
+
+def _refuse_binary_terminal(fmt: str, output: Optional[str]) -> None:
+    """Exit with an error instead of writing binary records to an interactive terminal."""
+    if fmt == "proto" and (output is None or output == "-") and sys.stdout.isatty():
+        typer.secho("Error: --format proto is binary; pass --output FILE or redirect stdout", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+
+
+def _size_limit(max_file_size: Optional[str]) -> Optional[int]:
+    return parse_size(max_file_size) if max_file_size is not None else None
+
+
+def _sarif_root(root: Path) -> Path:
+    """The directory SARIF result paths are relative to: the walked root, or the cwd for a single file."""
+    return root if root.is_dir() else Path.cwd()
+
+
+def _annotated(reports, changes: Optional[ChangeSet]):
+    """Pass reports through, marking added/modified symbols when `--since` is active."""
+    for report in reports:
+        if changes is not None:
+            changes.annotate(report.path, report.symbols, report.language)
+        yield report
+
+
+def _summarizer(enabled: bool, endpoint: str, model: str, jobs: int, cache_dir: Optional[Path]):
+    """The `--summarize` stage, or None when it is off; the API key comes from TREESITTER_TOOLS_SUMMARY_API_KEY."""
+    if not enabled:
+        return None
+    from .summarize import Summarizer, SummarizerConfig, http_completer
+
+    config = SummarizerConfig(endpoint, model, os.environ.get("TREESITTER_TOOLS_SUMMARY_API_KEY"))
+    return Summarizer(http_completer(config), model, cache_dir, jobs)
+
+
+def _summarized(reports, summarizer):
+    """Pass reports through, adding function summaries when `--summarize` is set."""
+    return summarizer.summarize(reports) if summarizer is not None else reports
+
+
+def _summary_report(summarizer) -> None:
+    stats = summarizer.stats
+    typer.secho(
+        f"Summaries: {stats.summarized} new, {stats.cached} cached, {stats.failed} failed, {stats.skipped} skipped",
+        err=True,
+    )
+    if stats.last_error:
+        given_up = "; gave up on the endpoint" if summarizer.gave_up else ""
+        typer.secho(f"Warning: last summary error: {stats.last_error}{given_up}", err=True, fg=typer.colors.YELLOW)
+
+
+def _normalized(reports, root: Path, normalize: bool, levels: List[str] = ()):
+    """
+    Pass reports through, rewriting their symbols into the cross-language schema when
+    `--normalize` is set and keeping only the `--visibility` levels when any are given.
+    """
+    if not normalize and not levels:
+        yield from reports
+        return
+    from .normalize import annotate_visibility, filter_visibility, normalize_symbols
+
+    base = Path(root).resolve()
+    for report in reports:
+        if not report.error and report.symbols:
+            path = report.path if report.path.is_absolute() else base / report.path
+            try:
+                rel = path.resolve().relative_to(base).as_posix()
+            except ValueError:
+                rel = path.name
+            rewrite = normalize_symbols if normalize else annotate_visibility
+            rewrite(report.symbols, parse_file(path, report.language), rel)
+            if levels:
+                report.symbols = filter_visibility(report.symbols, levels)
+        yield report
+
+
+def _module_label(path: Path) -> str:
+    """A file's path for module names: relative to the current directory when it is under it."""
+    try:
+        return path.resolve().relative_to(Path.cwd()).as_posix()
+    except ValueError:
+        return path.name
+
+
+def _visibility_levels(visibility: List[str], only_public: bool) -> List[str]:
+    """The `--visibility` levels to keep (`--only-public` is "public"), most visible first."""
+    from .normalize import VISIBILITIES
+
+    levels = {v.lower() for v in visibility} | ({"public"} if only_public else set())
+    unknown = sorted(levels - set(VISIBILITIES))
+    if unknown:
+        typer.secho(
+            f"Error: Unknown --visibility {', '.join(unknown)} (expected {', '.join(VISIBILITIES)})",
+            err=True,
+            fg=typer.colors.RED,
+        )
+        raise typer.Exit(1)
+    return [v for v in VISIBILITIES if v in levels]
+
+
+def _scan_summary(total_files, total_symbols, files_with_symbols, errors, session, verbose) -> None:
+    """Print the scan summary (and error details with --verbose) to stderr."""
     summary_color = typer.colors.GREEN if not errors else typer.colors.YELLOW
     typer.secho(
         f"Scanned {total_files} files. Found {total_symbols} symbols in {files_with_symbols} files.",
         err=True,
         fg=summary_color
     )
+    if session is not None:
+        stats = session.stats
+        invalid = session.cache.stats.invalid if session.cache is not None else 0
+        typer.secho(
+            f"Cache: {stats.cache_hits} hits, {stats.reparsed} re-parsed"
+            + (f", {invalid} corrupt entries discarded." if invalid else "."),
+            err=True,
+            fg=summary_color,
+        )
     
     if errors:
-        typer.secho(f"Encountered {len(errors)} errors.", err=True, fg=typer.colors.YELLOW)
+        kinds = Counter(err.error_kind or failures.PARSE_FAILURE for err in errors)
+        detail = ", ".join(f"{kinds[c]} {c}" for c in failures.KINDS if kinds[c])
+        typer.secho(f"Encountered {len(errors)} errors ({detail}).", err=True, fg=typer.colors.YELLOW)
         if verbose:
             typer.secho("\nError details:", err=True, fg=typer.colors.YELLOW)
             for err in errors:
-                typer.secho(f"  {err.path}: {err.error}", err=True, fg=typer.colors.YELLOW)
+                typer.secho(f"  {err.path}: [{err.error_kind}] {err.error}", err=True, fg=typer.colors.YELLOW)
         else:
             typer.secho("Use --verbose to see error details.", err=True, fg=typer.colors.YELLOW)
+    if failures.ACTIVE is not None:
+        failures.ACTIVE.announced = len(failures.ACTIVE)
+
 
+def _scan_checkpoint(
+    path: Path, resume: bool, root: Path, output: Optional[str], fmt: str, options: dict
+) -> Tuple[Checkpoint, FileSink]:
+    """The checkpoint for a streamed scan and its output file, reopened at the last commit when resuming."""
+    target = local_path(output)
+    if target is None or target.name.endswith(".gz"):
+        typer.secho(
+            "Error: --checkpoint needs --output to be a local, uncompressed file", err=True, fg=typer.colors.RED
+        )
+        raise typer.Exit(1)
+    header = {
+        "command": "scan", "root": str(root.resolve()), "output": str(target.resolve()), "format": fmt,
+        "options": options,
+    }
     try:
-        payload = json.dumps([report.to_dict() for report in reports], indent=2)
-        if output:
-            output.write_text(payload, encoding="utf-8")
-            typer.echo(f"Wrote symbol report ({len(reports)} files) -> {output}")
-        else:
-            typer.echo(payload)
-        if outline:
-            outline.write_text(outline_markdown(reports), encoding="utf-8")
-            typer.echo(f"Wrote outline -> {outline}")
+        if resume and path.exists():
+            job = Checkpoint.load(path)
+            job.check(header)
+            if job.complete:
+                typer.secho(f"{path} records a finished job; nothing to resume.", err=True)
+                raise typer.Exit(0)
+            size = target.stat().st_size if target.exists() else 0
+            if size < job.offset:
+                raise CheckpointError(
+                    f"{output} has {size} bytes but {path} committed {job.offset}; delete it to start over"
+                )
+            return job, FileSink(target, append_at=job.offset) if target.exists() else FileSink(target)
+        if path.exists():
+            raise CheckpointError(f"{path} already exists; pass --resume to continue that job or delete it")
+        return Checkpoint.create(path, header), FileSink(target)
+    except (CheckpointError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+
+
+def _scan_stream(
+    reports, fmt, output, outline, content, per_symbol, flush_every, session, verbose, job=None, root=None
+) -> None:
+    """
+    Stream reports as NDJSON or binary records; only counters and error stubs are kept in memory.
+    SIGTERM/SIGINT stop the walk after the current file and close the output normally. With a
+    checkpoint `job` (from `_scan_checkpoint`), each flush of the output commits the files written.
+    """
+    total_files = total_symbols = files_with_symbols = 0
+    errors = []
+    checkpoint, sink = job if job is not None else (None, None)
+    base = Path(root).resolve() if root is not None else Path.cwd()
+
+    def commit() -> None:
+        sink.sync()
+        checkpoint.commit(sink.tell())
+
+    on_flush = commit if checkpoint is not None else None
+    try:
+        stream = sink if sink is not None else open_sink(output)
+        outline_stream = open_sink(outline) if outline else None
+        try:
+            if fmt == "proto":
+                from .export.records import RecordWriter
+
+                header = checkpoint is None or checkpoint.offset == 0
+                writer = RecordWriter(stream, flush_every=flush_every, on_flush=on_flush, header=header)
+            else:
+                writer = NDJSONWriter(stream, flush_every=flush_every, on_flush=on_flush)
+            with GracefulStop() as stop:
+                for report in reports:
+                    if not content:
+                        for sym in report.symbols:
+                            sym.content = None
+                    if fmt == "proto":
+                        writer.write_file(report)
+                    else:
+                        for record in report_records(report, per_symbol):
+                            writer.write(record)
+                    if checkpoint is not None:
+                        checkpoint.mark(_relative_label(report.path, base))
+                    if outline_stream is not None:
+                        outline_stream.write(("\n" if total_files else "") + outline_section(report))
+                    total_files += 1
+                    total_symbols += len(report.symbols)
+                    files_with_symbols += 1 if report.symbols else 0
+                    if report.error:
+                        errors.append(report)
+                    if stop.requested:
+                        close = getattr(reports, "close", None)
+                        if close is not None:
+                            close()  # shuts down worker processes
+                        break
+            writer.flush()
+            if checkpoint is not None and not stop.requested:
+                checkpoint.finish(sink.tell())
+        except BaseException:
+            stream.abort()
+            if outline_stream is not None:
+                outline_stream.abort()
+            raise
+        # Remote sinks upload here, once the whole stream has been written.
+        stream.close()
+        if outline_stream is not None:
+            outline_stream.close()
     except OSError as e:
         typer.secho(f"I/O Error writing output: {e}", err=True, fg=typer.colors.RED)
         raise typer.Exit(1)
+    _scan_summary(total_files, total_symbols, files_with_symbols, errors, session, verbose)
+    if output and output != "-":
+        typer.echo(f"Wrote {writer.count} records ({total_files} files) -> {stream}")
+    if outline:
+        typer.echo(f"Wrote outline -> {outline_stream}")
+    if stop.requested:
+        hint = "; rerun with --resume to continue" if checkpoint is not None else ""
+        typer.secho(
+            f"Stopped by {signal.Signals(stop.signum).name} after {total_files} files{hint}.",
+            err=True,
+            fg=typer.colors.YELLOW,
+        )
+        raise typer.Exit(stop.exit_code)
+
+
+def _relative_label(path: Path, base: Path) -> str:
+    try:
+        return Path(path).resolve().relative_to(base).as_posix()
+    except ValueError:
+        return Path(path).as_posix()
+
+
+@app.command()
+def callgraph(
+    root: Path = typer.Argument(..., exists=True, help="File or directory to analyze"),
+    include: List[str] = typer.Option(["**/*"], help="Glob patterns to include"),
+    exclude: List[str] = typer.Option([], help="Glob patterns to exclude"),
+    fmt: str = typer.Option("json", "--format", "-f", help="Output format: json or dot"),
+    resolved_only: bool = typer.Option(False, help="Only emit edges whose callee was found in the parsed files"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+):
+    """Emit caller -> callee edges (with file, line, and receiver type) across all parsed files."""
+    if fmt not in {"json", "dot"}:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected json or dot)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    try:
+        graph = build_call_graph(root, include, exclude)
+    except (ValueError, RuntimeError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    payload = graph.to_dot(resolved_only) if fmt == "dot" else graph.to_json(resolved_only)
+    _emit(payload, output, f"call graph ({len(graph.edges)} edges)")
+
+
+@app.command()
+def scip(
+    root: Path = typer.Argument(..., exists=True, file_okay=False, help="Project root to index"),
+    include: List[str] = typer.Option(["**/*"], help="Glob patterns to include"),
+    exclude: List[str] = typer.Option([], help="Glob patterns to exclude"),
+    package_name: Optional[str] = typer.Option(None, help="Package name for symbol monikers (default: root dir name)"),
+    package_version: Optional[str] = typer.Option(None, help="Package version for symbol monikers"),
+    fmt: str = typer.Option("scip", "--format", "-f", help="Output format: scip (protobuf) or json"),
+    output: Optional[str] = typer.Option(None, help="Output path, s3://BUCKET/KEY, or http(s):// URL (default: index.scip for protobuf, stdout for json)"),
+):
+    """Export definitions and resolved references as a SCIP index for code-intel tooling."""
+    if fmt not in {"scip", "json"}:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected scip or json)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    index = build_scip_index(root, include, exclude, package_name, package_version)
+    documents = len(index["documents"])
+    if fmt == "json":
+        _emit(index_to_json(index), output, f"SCIP index ({documents} documents)")
+        return
+    _emit(encode_index(index), output or "index.scip", f"SCIP index ({documents} documents)")
+
+
+@app.command("graph-export")
+def graph_export(
+    root: Path = typer.Argument(..., exists=True, help="File or directory to export"),
+    include: List[str] = typer.Option(["**/*"], help="Glob patterns to include"),
+    exclude: List[str] = typer.Option([], help="Glob patterns to exclude"),
+    fmt: str = typer.Option("cypher", "--format", "-f", help="Output format: cypher or jgf (JSON Graph Format)"),
+    dialect: str = typer.Option("neo4j", help="Cypher dialect for the constraint/index statements: neo4j or memgraph"),
+    schema: bool = typer.Option(True, "--schema/--no-schema", help="Emit the id constraint and name index before the data"),
+    output: Optional[str] = typer.Option(None, help="Output path, s3://BUCKET/KEY, or http(s):// URL (default: stdout)"),
+):
+    """Export files, symbols, calls, imports, and inheritance as a property graph for Neo4j/Memgraph."""
+    from .export.graphdb import DIALECTS, build_property_graph
+
+    if fmt not in {"cypher", "jgf"}:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected cypher or jgf)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    if dialect not in DIALECTS:
+        typer.secho(f"Error: Unsupported dialect '{dialect}' (expected neo4j or memgraph)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    graph = build_property_graph(root, include, exclude)
+    payload = graph.to_cypher(dialect, schema) if fmt == "cypher" else graph.to_jgf_json(Path(root).resolve().name)
+    _emit(payload, output, f"property graph ({len(graph.nodes)} nodes, {len(graph.edges)} edges)")
+
+
+@app.command()
+def chunk(
+    path: Path = typer.Argument(..., exists=True, help="File or directory to chunk"),
+    max_tokens: int = typer.Option(512, help="Token budget per chunk (context header included)"),
+    overlap: int = typer.Option(0, help="Lines of overlap between parts of a split declaration"),
+    context: bool = typer.Option(True, "--context/--no-context", help="Prefix chunks with package/imports/enclosing type"),
+    links: bool = typer.Option(
+        False, "--links", help="Add file and class outline chunks, and parent/prev/next/file chunk IDs to follow"
+    ),
+    language: Optional[str] = typer.Option(None, help="Override detected language (single file only)"),
+    include: List[str] = typer.Option(["**/*"], help="Glob patterns to include"),
+    exclude: List[str] = typer.Option([], help="Glob patterns to exclude"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+    tokenizer: str = typer.Option("heuristic", help=TOKENIZER_HELP),
+    since: Optional[str] = typer.Option(None, help=SINCE_HELP),
+    fmt: str = typer.Option("json", "--format", "-f", help="json, or proto (binary records; see `decode`)"),
+):
+    """Split source along function/type/method boundaries for embedding pipelines."""
+    if fmt not in FORMAT_CHOICES["chunk"]:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected json or proto)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    _refuse_binary_terminal(fmt, output)
+    changes = _changes_since(since, path)
+    try:
+        options = ChunkOptions(
+            max_tokens=max_tokens,
+            overlap_lines=overlap,
+            include_context=context,
+            count_tokens=get_tokenizer(tokenizer),
+            links=links,
+        )
+        if path.is_dir():
+            chunks = chunk_directory(path, options, include, exclude, changes.paths if changes is not None else None)
+        elif changes is not None and path not in changes:
+            chunks = []
+        else:
+            chunks = chunk_file(path, options, language)
+    except ValueError as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        typer.secho("Hint: Try using --language to manually specify the language.", err=True, fg=typer.colors.YELLOW)
+        raise typer.Exit(1)
+    except (RuntimeError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    if fmt == "proto":
+        from .export.records import encode_chunks
+
+        _emit(encode_chunks(chunks), output, f"{len(chunks)} chunks")
+        return
+    _emit(chunks_to_json(chunks), output, f"{len(chunks)} chunks")
+
+
+@app.command()
+def action(
+    source: Optional[Path] = typer.Argument(None, dir_okay=False, help="Source file to extract (one per invocation)"),
+    out: Optional[Path] = typer.Option(None, "--out", dir_okay=False, help="Write the output to FILE"),
+    out_dir: Optional[Path] = typer.Option(
+        None, "--out-dir", file_okay=False, help="Write it to DIR/<sha256 of the output>.json|.pb and print that path"
+    ),
+    kind: str = typer.Option("symbols", "--kind", help="symbols (the file's `scan` record) or chunks"),
+    fmt: str = typer.Option("json", "--format", "-f", help="json, or proto (binary records; see `decode`)"),
+    language: Optional[str] = typer.Option(None, "--language", "-l", help="Override detected language"),
+    max_tokens: int = typer.Option(512, help="Token budget per chunk (--kind chunks)"),
+    persistent_worker: bool = typer.Option(
+        False, buildaction.WORKER_FLAG, help="Answer Bazel WorkRequests (protobuf or JSON) on stdin instead"
+    ),
+):
+    """Extract one file as a cacheable build action (Bazel/Buck), or serve them as a persistent worker."""
+    if persistent_worker:
+        buildaction.WorkerSession(_run_action).serve()
+        return
+    if fmt not in FORMAT_CHOICES["action"]:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected json or proto)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    if source is None or (out is None) == (out_dir is None):
+        typer.secho("Error: Pass a SOURCE file and one of --out or --out-dir", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    try:
+        payload = buildaction.extract_action(source, kind, fmt, language, max_tokens)
+        target = out if out is not None else buildaction.content_path(out_dir, payload, fmt)
+        buildaction.write_output(target, payload)
+    except (ValueError, RuntimeError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    if out_dir is not None:
+        typer.echo(target.as_posix())
+
+
+def _run_action(args: List[str]) -> Tuple[int, str]:
+    """One worker request: `action` run in this process on `args`, with its exit status and what it printed."""
+    command = typer.main.get_command(app).commands["action"]
+    captured = io.StringIO()
+    with contextlib.redirect_stdout(captured), contextlib.redirect_stderr(captured):
+        try:
+            code = command.main(args, prog_name="treesitter-tools action", standalone_mode=False)
+        except click.ClickException as e:
+            captured.write(f"Error: {e.format_message()}\n")
+            code = e.exit_code
+        except click.exceptions.Abort:
+            code = 1
+        except Exception as e:  # a failed action must not stop the worker
+            captured.write(f"Error: {type(e).__name__}: {e}\n")
+            code = 1
+    return code if isinstance(code, int) else 0, captured.getvalue()
+
+
+@app.command("watch")
+def watch_command(
+    root: Path = typer.Argument(..., exists=True, file_okay=False, help="Directory to watch"),
+    include: List[str] = typer.Option(["**/*"], help="Glob patterns to include"),
+    exclude: List[str] = typer.Option([], help="Glob patterns to exclude"),
+    interval: float = typer.Option(0.5, help="Seconds between change batches (polling interval without watchdog)"),
+    initial: bool = typer.Option(True, "--initial/--no-initial", help="Emit `added` events for the starting state"),
+    cache_dir: Optional[Path] = typer.Option(None, help="Reuse the on-disk result cache used by `scan --cache-dir`"),
+    no_cache: bool = typer.Option(False, "--no-cache", help=NO_CACHE_HELP),
+):
+    """Stream added/removed/changed symbol events as NDJSON while files change."""
+    watcher = SymbolWatcher(root, include, exclude, IncrementalSession(None if no_cache else cache_dir))
+
+    def sink(event: dict) -> None:
+        typer.echo(json.dumps(event))
+        sys.stdout.flush()
+
+    try:
+        watch_directory(watcher, sink, interval=interval, initial=initial)
+    except KeyboardInterrupt:
+        pass
+
+
+@app.command()
+def rewrite(
+    root: Path = typer.Argument(..., exists=True, help="File or directory to rewrite"),
+    query: str = typer.Option(..., "--query", "-q", help="Tree-sitter query selecting the code to replace"),
+    replace: str = typer.Option(..., "--replace", "-r", help="Replacement template; @name inserts capture text"),
+    language: str = typer.Option(..., "--language", "-l", help="Language the query is written for"),
+    target: str = typer.Option("match", help="Capture naming the node to replace (default: span of all captures)"),
+    include: List[str] = typer.Option(["**/*"], help="Glob patterns to include"),
+    exclude: List[str] = typer.Option([], help="Glob patterns to exclude"),
+    in_place: bool = typer.Option(False, "--in-place", "-i", help="Write changes to disk instead of printing a diff"),
+    fmt: str = typer.Option("diff", "--format", "-f", help="diff, or edits (JSON for `apply`)"),
+):
+    """Apply a query + template rewrite across files, printing a unified diff or editing in place."""
+    if fmt not in FORMAT_CHOICES["rewrite"]:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected diff or edits)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    try:
+        results = rewrite_paths(root, language, query, replace, include, exclude, target)
+    except (ValueError, RuntimeError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    base = root.resolve() if root.is_dir() else root.resolve().parent
+    edits = sum(len(r.edits) for r in results)
+    if in_place:
+        try:
+            write_files(results)
+        except OSError as e:
+            typer.secho(f"I/O Error: {e}", err=True, fg=typer.colors.RED)
+            raise typer.Exit(1)
+    elif fmt == "edits":
+        typer.echo(edits_to_json(edit_set(results)))
+    else:
+        for result in results:
+            typer.echo(result.diff(result.path.resolve().relative_to(base).as_posix()), nl=False)
+    typer.secho(f"Rewrote {edits} matches in {len(results)} files.", err=True, fg=typer.colors.GREEN)
+
+
+@app.command()
+def metrics(
+    root: Path = typer.Argument(..., exists=True, help="File or directory to measure"),
+    include: List[str] = typer.Option(["**/*"], help="Glob patterns to include"),
+    exclude: List[str] = typer.Option([], help="Glob patterns to exclude"),
+    fmt: str = typer.Option("json", "--format", "-f", help="Output format: json, csv, or sarif (threshold violations)"),
+    threshold: List[str] = typer.Option(
+        [], help="Fail (exit 1) when a function exceeds METRIC=LIMIT, e.g. cognitive=15; a bare number limits cyclomatic"
+    ),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+    since: Optional[str] = typer.Option(None, help=SINCE_HELP),
+):
+    """Report cyclomatic/cognitive complexity, nesting depth, and LOC for every function."""
+    if fmt not in {"json", "csv", "sarif"}:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected json, csv, or sarif)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    changes = _changes_since(since, root)
+    try:
+        limits = parse_thresholds(threshold)
+        results = collect_metrics(root, include, exclude, changes.paths if changes is not None else None)
+    except (ValueError, RuntimeError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    violations = find_violations(results, limits)
+    if fmt == "sarif":
+        _emit(violations_to_sarif(violations, _sarif_root(root)), output, f"{len(violations)} violations")
+    else:
+        payload = metrics_to_csv(results) if fmt == "csv" else metrics_to_json(results)
+        _emit(payload, output, f"metrics for {len(results)} functions")
+    if violations:
+        for v in violations:
+            m = v.metrics
+            typer.secho(
+                f"{m.path}:{m.start_line}: {m.name} {v.metric}={v.value} exceeds {v.limit}",
+                err=True,
+                fg=typer.colors.RED,
+            )
+        raise typer.Exit(1)
+
+
+@app.command()
+def tags(
+    root: Path = typer.Argument(..., exists=True, help="File or directory to tag"),
+    include: List[str] = typer.Option(["**/*"], help="Glob patterns to include"),
+    exclude: List[str] = typer.Option([], help="Glob patterns to exclude"),
+    fmt: str = typer.Option("ctags", "--format", "-f", help="Output format: ctags or etags"),
+    output: Optional[str] = typer.Option(
+        None,
+        "--output",
+        "-o",
+        help="Tags file, s3://BUCKET/KEY, or http(s):// URL to write, '-' for stdout (default: tags/TAGS inside ROOT)",
+    ),
+):
+    """Write a universal-ctags or Emacs TAGS file for vim/emacs navigation."""
+    if fmt not in {"ctags", "etags"}:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected ctags or etags)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    try:
+        entries = collect_tags(root, include, exclude)
+    except (ValueError, RuntimeError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    payload = to_etags(entries) if fmt == "etags" else to_ctags(entries)
+    default_name = "TAGS" if fmt == "etags" else "tags"
+    destination = output or str((root if root.is_dir() else Path(".")) / default_name)
+    _emit(payload, destination, f"{len(entries)} tags")
+
+
+@index_app.command("build")
+def index_build(
+    root: Path = typer.Argument(..., exists=True, file_okay=False, help="Project root to index"),
+    db: Path = typer.Option(DEFAULT_INDEX_DB, help="SQLite database to create or update"),
+    include: List[str] = typer.Option(["**/*"], help="Glob patterns to include"),
+    exclude: List[str] = typer.Option([], help="Glob patterns to exclude"),
+):
+    """Index symbols, call references, and type relationships; unchanged files are skipped."""
+    try:
+        with SymbolIndex(db) as index:
+            stats = index.update(root, include, exclude)
+    except (sqlite3.Error, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    typer.echo(json.dumps(stats.to_dict()))
+
+
+@index_app.command("query")
+def index_query(
+    kind: str = typer.Argument(..., help=f"One of: {', '.join(QUERY_KINDS)}"),
+    name: str = typer.Argument(..., help="Symbol name, or Type.method to narrow by receiver type"),
+    db: Path = typer.Option(DEFAULT_INDEX_DB, help="SQLite database written by `index build`"),
+):
+    """Look up definitions, references, callers, or implementations without re-parsing."""
+    if not db.exists():
+        typer.secho(f"Error: Index not found at {db}; run `treesitter-tools index build` first", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    try:
+        with SymbolIndex(db) as index:
+            rows = index.query(kind, name)
+    except (ValueError, sqlite3.Error) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    typer.echo(json.dumps(schema.conform(schema.INDEX_RECORDS[kind], rows), indent=2))
+
+
+@index_app.command("gc")
+def index_gc(
+    db: Path = typer.Option(DEFAULT_INDEX_DB, help="SQLite database written by `index build` and `embed`"),
+    root: Optional[Path] = typer.Option(
+        None, file_okay=False, help="Tree the stored paths are relative to (default: the one `index build` recorded)"
+    ),
+    vacuum: bool = typer.Option(True, "--vacuum/--no-vacuum", help="Rewrite the file so freed space is returned"),
+):
+    """Drop rows for deleted files, unreferenced and duplicate vectors, and vacuum the database."""
+    if not db.exists():
+        typer.secho(
+            f"Error: Index not found at {db}; run `treesitter-tools index build` first", err=True, fg=typer.colors.RED
+        )
+        raise typer.Exit(1)
+    try:
+        stats = gc_index(db, root, vacuum)
+    except (ValueError, sqlite3.Error, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    typer.echo(json.dumps(stats.to_dict()))
+    typer.secho(
+        f"Removed {stats.files} files, {stats.embeddings} embeddings, {stats.vectors + stats.merged} vectors; "
+        f"reclaimed {format_size(stats.reclaimed)}.",
+        err=True,
+    )
+
+
+WORKSPACE_ROOTS_HELP = "Project roots (default: the roots of --workspace, or of the nearest workspace file)"
+WORKSPACE_FILE_HELP = "Workspace file listing roots and their settings"
+
+
+def _workspace(roots: Optional[List[Path]], workspace_file: Optional[Path]):
+    from .workspace import WORKSPACE_NAMES, find_workspace, load_workspace, workspace_from_paths
+
+    try:
+        if roots:
+            return workspace_from_paths(roots)
+        path = workspace_file or find_workspace(Path.cwd())
+        if path is None:
+            raise ValueError(f"No {WORKSPACE_NAMES[0]} found; pass ROOT... or --workspace FILE")
+        return load_workspace(path)
+    except (ValueError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+
+
+@workspace_app.command("scan")
+def workspace_scan(
+    roots: Optional[List[Path]] = typer.Argument(None, exists=True, file_okay=False, help=WORKSPACE_ROOTS_HELP),
+    workspace_file: Optional[Path] = typer.Option(None, "--workspace", "-w", exists=True, dir_okay=False, help=WORKSPACE_FILE_HELP),
+    extra_exclude: List[str] = typer.Option([], "--exclude", help="Glob patterns to exclude in every root, on top of its own"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+    content: bool = typer.Option(False, "--content", "-c", help="Include full source code of symbols"),
+    verbose: bool = typer.Option(False, "--verbose", "-v", help="Print errors and skipped files"),
+    max_chunk_size: Optional[int] = typer.Option(None, help="Max size in chars for content chunks"),
+    max_file_size: Optional[str] = typer.Option(None, help=MAX_FILE_SIZE_HELP),
+    fmt: str = typer.Option("json", "--format", "-f", help="json (one array) or ndjson (one record per file, streamed)"),
+):
+    """Scan every root with its own settings into one report, paths labelled ROOT/PATH."""
+    from .workspace import scan_workspace
+
+    if fmt not in FORMAT_CHOICES["workspace scan"]:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected json or ndjson)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    workspace = _workspace(roots, workspace_file)
+    try:
+        size_limit = _size_limit(max_file_size)
+    except ValueError as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    reports = scan_workspace(workspace, max_chunk_size, size_limit, exclude=extra_exclude)
+    if fmt == "ndjson":
+        _scan_stream(reports, fmt, output, None, content, False, 1000, None, verbose)
+        return
+    reports = list(reports)
+    if not content:
+        for report in reports:
+            for sym in report.symbols:
+                sym.content = None
+    _scan_summary(
+        len(reports),
+        sum(len(r.symbols) for r in reports),
+        sum(1 for r in reports if r.symbols),
+        [r for r in reports if r.error],
+        None,
+        verbose,
+    )
+    _emit(
+        json.dumps(schema.conform("file", [report.to_dict() for report in reports]), indent=2), output,
+        f"symbol report ({len(reports)} files, {len(workspace.roots)} roots)",
+    )
+
+
+@workspace_app.command("index")
+def workspace_index(
+    roots: Optional[List[Path]] = typer.Argument(None, exists=True, file_okay=False, help=WORKSPACE_ROOTS_HELP),
+    workspace_file: Optional[Path] = typer.Option(None, "--workspace", "-w", exists=True, dir_okay=False, help=WORKSPACE_FILE_HELP),
+    db: Path = typer.Option(DEFAULT_INDEX_DB, help="SQLite database to create or update"),
+):
+    """Index every root into one database (query it with `index query` and `get`); unchanged files are skipped."""
+    from .workspace import index_workspace
+
+    workspace = _workspace(roots, workspace_file)
+    try:
+        with SymbolIndex(db) as index:
+            stats = index_workspace(index, workspace)
+    except (sqlite3.Error, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    typer.echo(json.dumps(stats.to_dict()))
+
+
+@workspace_app.command("show")
+def workspace_show(
+    roots: Optional[List[Path]] = typer.Argument(None, exists=True, file_okay=False, help=WORKSPACE_ROOTS_HELP),
+    workspace_file: Optional[Path] = typer.Option(None, "--workspace", "-w", exists=True, dir_okay=False, help=WORKSPACE_FILE_HELP),
+):
+    """Print the resolved roots (name, path, include/exclude, languages) as JSON."""
+    typer.echo(_workspace(roots, workspace_file).to_json())
+
+
+@app.command()
+def serve(
+    mcp: bool = typer.Option(False, "--mcp", help="Speak the Model Context Protocol (JSON-RPC) over stdio"),
+    http: bool = typer.Option(False, "--http", help="Serve parse/extract/query as JSON over HTTP"),
+    grpc: bool = typer.Option(False, "--grpc", help="Serve parse/extract/query over gRPC (requires grpcio)"),
+    stdin_batch: bool = typer.Option(
+        False, "--stdin-batch", help="Answer Content-Length framed JSON requests on stdin (unsaved buffers, no temp files)"
+    ),
+    lsp: bool = typer.Option(False, "--lsp", help="Run a minimal Language Server over stdio (symbols, folds, definitions)"),
+    root: Path = typer.Option(Path("."), exists=True, file_okay=False, help="Directory tool paths are resolved against"),
+    host: str = typer.Option("127.0.0.1", help="Interface for --http/--grpc to bind"),
+    port: int = typer.Option(8765, help="HTTP port (0 picks a free port)"),
+    grpc_port: int = typer.Option(50051, help="gRPC port"),
+    pool_size: int = typer.Option(4, min=1, help="Warm parsers kept per language"),
+    db: Path = typer.Option(DEFAULT_INDEX_DB, help="Symbol index for --lsp definitions (relative to the workspace root)"),
+    metrics_port: Optional[int] = typer.Option(
+        None, help="Also serve Prometheus /metrics on this port (--http serves it on its own port too)"
+    ),
+    otel: bool = typer.Option(
+        False, "--otel", help="Export an OpenTelemetry span per --http/--grpc request (OTLP; OTEL_* variables apply)"
+    ),
+):
+    """Run a long-lived server so agents and editors can call the extraction tools directly."""
+    stdio_modes = [flag for flag, on in (("--mcp", mcp), ("--stdin-batch", stdin_batch), ("--lsp", lsp)) if on]
+    if len(stdio_modes) > 1 or (stdio_modes and (http or grpc)):
+        typer.secho(f"Error: {stdio_modes[0]} cannot be combined with other server modes", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    if not (stdio_modes or http or grpc):
+        typer.secho("Error: Choose a server mode (--mcp, --http, --grpc, --stdin-batch, --lsp)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    if lsp:
+        from .lsp_server import LSPServer
+
+        try:
+            code = LSPServer(root, db).serve()
+        except KeyboardInterrupt:
+            code = 0
+        raise typer.Exit(code)
+    if stdin_batch:
+        from .batch import BatchSession
+
+        try:
+            BatchSession(root).serve()
+        except KeyboardInterrupt:
+            pass
+        return
+    if mcp:
+        try:
+            MCPServer(root).serve()
+        except KeyboardInterrupt:
+            pass
+        return
+
+    try:
+        tracing = configure_tracing() if otel else None
+    except (RuntimeError, ValueError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    service = ToolService(root, pool_size=pool_size)
+    try:
+        grpc_server = make_grpc_server(service, host, grpc_port) if grpc else None
+        http_server = make_http_server(service, host, port) if http else None
+        metrics_server = make_metrics_server(service, host, metrics_port) if metrics_port is not None else None
+    except (RuntimeError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    if grpc_server is not None:
+        grpc_server.start()
+        typer.secho(f"gRPC listening on {host}:{grpc_server.bound_port}", err=True)
+    if metrics_server is not None:
+        threading.Thread(target=metrics_server.serve_forever, daemon=True).start()
+        typer.secho(f"Metrics on http://{host}:{metrics_server.server_address[1]}/metrics", err=True)
+    try:
+        if http_server is not None:
+            typer.secho(f"HTTP listening on http://{host}:{http_server.server_address[1]}", err=True)
+            http_server.serve_forever()
+        else:
+            grpc_server.wait_for_termination()
+    except KeyboardInterrupt:
+        pass
+    finally:
+        if http_server is not None:
+            http_server.server_close()
+        if grpc_server is not None:
+            grpc_server.stop(grace=1)
+        if metrics_server is not None:
+            metrics_server.shutdown()
+            metrics_server.server_close()
+        if tracing is not None:
+            tracing.shutdown()  # flush batched spans
+
+
+@app.command()
+def languages():
+    """List registered language specs (extensions, node kinds, bundled queries) as JSON."""
+    typer.echo(json.dumps([LANGUAGE_SPECS[name].to_dict() for name in sorted(LANGUAGE_SPECS)], indent=2))
+
+
+@app.command()
+def queries(
+    language: Optional[str] = typer.Argument(None, help="Only this language's queries"),
+    show: Optional[str] = typer.Option(None, help="Print the source of this query (needs LANGUAGE)"),
+    check: bool = typer.Option(False, "--check", help="Compile every listed query; exits 1 if any fails"),
+    fmt: str = typer.Option("text", "--format", "-f", help="Output format: text or json"),
+):
+    """List the query library: bundled tags/highlights/locals/folds and --query-dir overrides."""
+    if fmt not in {"text", "json"}:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected text or json)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    try:
+        if show:
+            if not language:
+                raise ValueError("--show needs a LANGUAGE")
+            typer.echo(load_query(language, show), nl=False)
+            return
+        found = list_queries(language)
+        problems = {}
+        if check:
+            problems = {(q.language, q.name): error for q, error in check_queries([language] if language else None)}
+    except (ValueError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    rows = []
+    for q in found:
+        row = q.to_dict()
+        if (q.language, q.name) in problems:
+            row["error"] = problems[(q.language, q.name)]
+        rows.append(row)
+    if fmt == "json":
+        typer.echo(json.dumps(rows, indent=2))
+    else:
+        for row in rows:
+            error = f"\tERROR: {row['error']}" if "error" in row else ""
+            typer.echo(f"{row['language']}\t{row['name']}\t{row['origin']}\t{row['path']}{error}")
+    if problems:
+        raise typer.Exit(1)
+
+
+@app.command()
+def diff(
+    old: Path = typer.Argument(..., exists=True, dir_okay=False, help="Original version of the file"),
+    new: Path = typer.Argument(..., exists=True, dir_okay=False, help="Changed version of the file"),
+    language: Optional[str] = typer.Option(None, help="Override detected language"),
+    fmt: str = typer.Option("json", "--format", "-f", help="Output format: json or text"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+):
+    """Report declarations added, removed, renamed, or changed in signature/body, ignoring formatting."""
+    if fmt not in {"json", "text"}:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected json or text)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    try:
+        changes = diff_files(old, new, language)
+    except ValueError as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        typer.secho("Hint: Try using --language to manually specify the language.", err=True, fg=typer.colors.YELLOW)
+        raise typer.Exit(1)
+    except (RuntimeError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    payload = changes_to_text(changes) if fmt == "text" else changes_to_json(changes)
+    _emit(payload, output, f"{len(changes)} changes")
+
+
+@app.command()
+def unused(
+    root: Path = typer.Argument(..., exists=True, help="File or directory to analyse"),
+    include: List[str] = typer.Option(["**/*"], help="Glob patterns to include"),
+    exclude: List[str] = typer.Option([], help="Glob patterns to exclude"),
+    allow: List[str] = typer.Option([], help="Never report names matching this glob (name, Type.name, or path:name)"),
+    allowlist: Optional[Path] = typer.Option(
+        None, exists=True, dir_okay=False, help="File of allow patterns, one per line ('#' comments)"
+    ),
+    entry_points: bool = typer.Option(
+        True, "--entry-points/--no-entry-points", help="Treat main/init/test functions and dunders as used"
+    ),
+    fmt: str = typer.Option("json", "--format", "-f", help="Output format: json, text, or sarif"),
+    check: bool = typer.Option(False, "--check", help="Exit 1 when anything unused is found"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+):
+    """Report unreferenced functions, types, and constants per package."""
+    if fmt not in {"json", "text", "sarif"}:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected json, text, or sarif)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    try:
+        patterns = list(allow) + (load_allowlist(allowlist) if allowlist else [])
+        found = find_unused(root, include, exclude, patterns, use_default_entry_points=entry_points)
+    except (ValueError, RuntimeError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    if fmt == "sarif":
+        payload = unused_to_sarif(found, _sarif_root(root))
+    else:
+        payload = unused_to_text(found) if fmt == "text" else unused_to_json(found)
+    _emit(payload, output, f"{len(found)} unused definitions")
+    if check and found:
+        raise typer.Exit(1)
+
+
+@app.command()
+def imports(
+    root: Path = typer.Argument(..., exists=True, help="File or directory to check"),
+    include: List[str] = typer.Option(["**/*"], help="Glob patterns to include"),
+    exclude: List[str] = typer.Option([], help="Glob patterns to exclude"),
+    fmt: str = typer.Option(
+        "text", "--format", "-f", help="text, json, sarif, diff (the fixes as a patch), or edits (JSON for `apply`)"
+    ),
+    fix: bool = typer.Option(False, "--fix", help="Remove the unused and duplicate imports in place"),
+    check: bool = typer.Option(False, "--check", help="Exit 1 when anything is found"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+):
+    """Report unused and duplicate imports (Python, JavaScript/TypeScript, Go, Java), with fixes."""
+    if fmt not in FORMAT_CHOICES["imports"]:
+        typer.secho(
+            f"Error: Unsupported format '{fmt}' (expected text, json, sarif, diff, or edits)",
+            err=True,
+            fg=typer.colors.RED,
+        )
+        raise typer.Exit(1)
+    if fix or fmt in {"diff", "edits"}:
+        redact.ACTIVE = None  # fixes are built from the parsed source; never write masks back
+    if fix:
+        overlay.ACTIVE = None  # files are rewritten whole, so fix what is saved
+    try:
+        reports = check_imports(root, include, exclude)
+    except (ValueError, RuntimeError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    problems = sum(len(r.problems) for r in reports)
+    if fix:
+        fixes = import_fixes(reports)
+        try:
+            write_files(fixes)
+        except OSError as e:
+            typer.secho(f"I/O Error: {e}", err=True, fg=typer.colors.RED)
+            raise typer.Exit(1)
+        typer.secho(f"Removed {problems} imports in {len(fixes)} files.", err=True, fg=typer.colors.GREEN)
+        return
+    if fmt == "diff":
+        payload = "".join(f.diff(cwd_label(f.path)) for f in import_fixes(reports))
+    elif fmt == "edits":
+        payload = edits_to_json(edit_set(import_fixes(reports)))
+    elif fmt == "sarif":
+        payload = imports_to_sarif(reports, _sarif_root(root))
+    else:
+        payload = imports_to_text(reports) if fmt == "text" else imports_to_json(reports)
+    _emit(payload, output, f"{problems} unused or duplicate imports")
+    if check and problems:
+        raise typer.Exit(1)
+
+
+@app.command()
+def headers(
+    root: Path = typer.Argument(..., exists=True, help="File or directory to check"),
+    include: List[str] = typer.Option(["**/*"], help="Glob patterns to include"),
+    exclude: List[str] = typer.Option([], help="Glob patterns to exclude"),
+    license: Optional[str] = typer.Option(
+        None, "--license", help="Expected SPDX identifier: report headers naming another, and insert it as {license}"
+    ),
+    holder: Optional[str] = typer.Option(None, "--holder", help="Copyright holder inserted as {holder}"),
+    template: Optional[Path] = typer.Option(
+        None, exists=True, dir_okay=False,
+        help="Header text to insert, without comment markers ({year}, {holder}, {license}, {path} are filled in)",
+    ),
+    year: Optional[int] = typer.Option(
+        None, help="Year inserted as {year} (default: the current year, or SOURCE_DATE_EPOCH's with --deterministic)"
+    ),
+    all_files: bool = typer.Option(False, "--all", help="List every file's header, not only the problems"),
+    fmt: str = typer.Option(
+        "text", "--format", "-f", help="text, json, sarif, diff (insertions as a patch), or edits (JSON for `apply`)"
+    ),
+    fix: bool = typer.Option(False, "--fix", help="Insert the header into the files that have none, in place"),
+    check: bool = typer.Option(False, "--check", help="Exit 1 when a header is missing or names another license"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+):
+    """Report files without a license header (or with another license's), and insert a templated one."""
+    from .headers import (
+        check_headers, default_template, header_fixes, headers_to_json, headers_to_sarif, headers_to_text,
+    )
+
+    if fmt not in FORMAT_CHOICES["headers"]:
+        typer.secho(
+            f"Error: Unsupported format '{fmt}' (expected text, json, sarif, diff, or edits)",
+            err=True,
+            fg=typer.colors.RED,
+        )
+        raise typer.Exit(1)
+    inserting = fix or fmt in {"diff", "edits"}
+    if inserting:
+        redact.ACTIVE = None  # headers are inserted into the parsed source; never write masks back
+    if fix:
+        overlay.ACTIVE = None  # files are rewritten whole, so fix what is saved
+    try:
+        text = template.read_text(encoding="utf-8") if template is not None else default_template(holder, license)
+        if inserting and not text:
+            raise ValueError("Nothing to insert: pass --template, or --holder and/or --license")
+        reports = check_headers(root, include, exclude, license)
+        fixes = header_fixes(reports, text, holder, year) if inserting else []
+    except (ValueError, RuntimeError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    problems = sum(1 for r in reports if r.problem)
+    if fix:
+        try:
+            write_files(fixes)
+        except OSError as e:
+            typer.secho(f"I/O Error: {e}", err=True, fg=typer.colors.RED)
+            raise typer.Exit(1)
+        typer.secho(f"Inserted a license header into {len(fixes)} files.", err=True, fg=typer.colors.GREEN)
+        return
+    if fmt == "diff":
+        payload = "".join(f.diff(cwd_label(f.path)) for f in fixes)
+    elif fmt == "edits":
+        payload = edits_to_json(edit_set(fixes))
+    elif fmt == "sarif":
+        payload = headers_to_sarif(reports, _sarif_root(root))
+    else:
+        payload = headers_to_text(reports, all_files) if fmt == "text" else headers_to_json(reports, all_files)
+    _emit(payload, output, f"{problems} files without the expected license header")
+    if check and problems:
+        raise typer.Exit(1)
+
+
+@app.command("go-impl")
+def go_impl(
+    root: Path = typer.Argument(..., exists=True, help="Go file or directory to analyse"),
+    interface: Optional[str] = typer.Option(
+        None, "--interface", "-i", help="List types implementing this interface (Name or pkg.Name, e.g. io.Reader)"
+    ),
+    type_name: Optional[str] = typer.Option(
+        None, "--type", "-t", help="List interfaces satisfied by this type (Name, pkg.Name, or *Name)"
+    ),
+    partial: bool = typer.Option(False, help="Also list near misses that match some but not all methods"),
+    stdlib: bool = typer.Option(True, "--stdlib/--no-stdlib", help="Include common standard library interfaces"),
+    include: List[str] = typer.Option(["**/*.go"], help="Glob patterns to include"),
+    exclude: List[str] = typer.Option([], help="Glob patterns to exclude"),
+    fmt: str = typer.Option("json", "--format", "-f", help="Output format: json or text"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+):
+    """Match Go method sets against interfaces, with method-by-method details."""
+    if (interface is None) == (type_name is None):
+        typer.secho("Error: Pass exactly one of --interface or --type", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    if fmt not in {"json", "text"}:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected json or text)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    try:
+        universe = load_universe(root, include, exclude, stdlib=stdlib)
+        if interface is not None:
+            results = implementers(universe, interface, partial)
+        else:
+            results = satisfied_interfaces(universe, type_name, partial)
+    except (ValueError, RuntimeError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    payload = results_to_text(results) if fmt == "text" else results_to_json(results)
+    _emit(payload, output, f"{len(results)} matches")
+
+
+@config_app.command("validate")
+def config_validate(
+    path: Optional[Path] = typer.Argument(
+        None, dir_okay=False, help=f"Config file to check (default: nearest {CONFIG_NAMES[0]})"
+    ),
+):
+    """Check a config file and report every problem; exits 1 if it is invalid."""
+    path = path or find_config(Path.cwd())
+    if path is None:
+        typer.secho(f"Error: No {CONFIG_NAMES[0]} found", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    try:
+        load_config(path, FORMAT_CHOICES)
+    except ConfigError as e:
+        for problem in e.problems:
+            typer.secho(f"{path}: {problem}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    except OSError as e:
+        typer.secho(f"I/O Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    typer.echo(f"{path}: OK")
+
+
+@config_app.command("show")
+def config_show(
+    path: Optional[Path] = typer.Argument(
+        None, dir_okay=False, help=f"Config file to show (default: nearest {CONFIG_NAMES[0]})"
+    ),
+):
+    """Print the parsed config as JSON."""
+    path = path or find_config(Path.cwd())
+    try:
+        config = load_config(path, FORMAT_CHOICES) if path is not None else ProjectConfig()
+    except (ValueError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    typer.echo(json.dumps(config.to_dict(), indent=2))
+
+
+@app.command()
+def skeleton(
+    path: Path = typer.Argument(..., exists=True, help="File or directory to outline"),
+    language: Optional[str] = typer.Option(None, help="Override detected language (single file only)"),
+    include: List[str] = typer.Option(["**/*"], help="Glob patterns to include"),
+    exclude: List[str] = typer.Option([], help="Glob patterns to exclude"),
+    fmt: str = typer.Option("text", "--format", "-f", help="Output format: text, markdown, or json"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+    since: Optional[str] = typer.Option(None, help=SINCE_HELP),
+):
+    """Print source with function bodies elided, keeping signatures, types, constants, and docs."""
+    renderers = {"text": skeletons_to_text, "markdown": skeletons_to_markdown, "json": skeletons_to_json}
+    if fmt not in renderers:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected text, markdown, or json)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    changes = _changes_since(since, path)
+    try:
+        skeletons = collect_skeletons(path, include, exclude, language, changes.paths if changes is not None else None)
+    except (ValueError, RuntimeError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    _emit(renderers[fmt](skeletons), output, f"skeletons for {len(skeletons)} files")
+
+
+@app.command()
+def deps(
+    root: Path = typer.Argument(..., exists=True, help="File or directory to analyse"),
+    include: List[str] = typer.Option(["**/*"], help="Glob patterns to include"),
+    exclude: List[str] = typer.Option([], help="Glob patterns to exclude"),
+    fmt: str = typer.Option("json", "--format", "-f", help="Output format: json, dot, mermaid, or text"),
+    external: bool = typer.Option(False, help="Also draw edges to external modules in dot/mermaid output"),
+    check: bool = typer.Option(False, "--check", help="Exit 1 when an import cycle is found"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+):
+    """Build a package-level import graph with cycles and fan-in/fan-out metrics."""
+    if fmt not in {"json", "dot", "mermaid", "text"}:
+        typer.secho(
+            f"Error: Unsupported format '{fmt}' (expected json, dot, mermaid, or text)", err=True, fg=typer.colors.RED
+        )
+        raise typer.Exit(1)
+    try:
+        graph = build_dependency_graph(root, include, exclude)
+    except (ValueError, RuntimeError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    if fmt == "dot":
+        payload = graph.to_dot(external)
+    elif fmt == "mermaid":
+        payload = graph.to_mermaid(external)
+    elif fmt == "text":
+        payload = deps_to_text(graph)
+    else:
+        payload = graph.to_json()
+    cycles = graph.cycles()
+    summary = f"dependency graph ({len(graph.packages)} packages, {len(graph.edges)} edges, {len(cycles)} cycles)"
+    _emit(payload, output, summary)
+    if check and cycles:
+        raise typer.Exit(1)
+
+
+@app.command()
+def diagnostics(
+    paths: List[Path] = typer.Argument(..., exists=True, help="Files and/or directories to check"),
+    include: List[str] = typer.Option(["**/*"], help="Glob patterns to include (directories)"),
+    exclude: List[str] = typer.Option([], help="Glob patterns to exclude (directories)"),
+    language: Optional[str] = typer.Option(None, help="Override detected language for file arguments"),
+    fmt: str = typer.Option("text", "--format", "-f", help="Output format: text (path:line:col: ...), json, or sarif"),
+    max_expected: int = typer.Option(MAX_EXPECTED, min=0, help="Expected tokens to list per error (0 to skip)"),
+    exit_zero: bool = typer.Option(False, "--exit-zero", help="Exit 0 even when syntax errors are found"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+    since: Optional[str] = typer.Option(None, help=SINCE_HELP),
+):
+    """Report syntax errors (ERROR and MISSING nodes); exits 1 when any are found."""
+    if fmt not in {"text", "json", "sarif"}:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected text, json, or sarif)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    changes = _changes_since(since, paths[0])
+    report = check_paths(paths, include, exclude, language, max_expected, changes.paths if changes is not None else None)
+    if fmt == "sarif":
+        payload = report.to_sarif(Path.cwd())  # labels are the paths as given
+    else:
+        payload = report.to_json() if fmt == "json" else report.to_text()
+    if payload:
+        _emit(payload, output, f"{len(report.diagnostics)} diagnostics")
+    typer.echo(report.summary(), err=True)
+    if report.diagnostics and not exit_zero:
+        raise typer.Exit(1)
+
+
+@app.command("ast")
+def ast_command(
+    path: Path = typer.Argument(..., exists=True, dir_okay=False, help="Source file to dump"),
+    language: Optional[str] = typer.Option(None, help="Override detected language"),
+    fmt: str = typer.Option("json", "--format", "-f", help="Output format: json, sexp, or dot"),
+    depth: Optional[int] = typer.Option(None, min=0, help="Only descend this many levels below the root"),
+    named_only: bool = typer.Option(False, help="Omit anonymous nodes (punctuation and keywords)"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+):
+    """Dump the syntax tree with node kinds, ranges, and field names."""
+    if fmt not in {"json", "sexp", "dot"}:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected json, sexp, or dot)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    try:
+        parsed = parse_file(path, language)
+    except (ValueError, RuntimeError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    tree = tree_to_dict(parsed, path.as_posix(), depth, named_only)
+    if fmt == "sexp":
+        payload = tree_to_sexp(tree)
+    elif fmt == "dot":
+        payload = tree_to_dot(tree)
+    else:
+        payload = tree_to_json(tree)
+    _emit(payload, output, f"{parsed.language} syntax tree")
+
+
+@app.command()
+def hotspots(
+    root: Path = typer.Argument(Path("."), exists=True, help="File or directory inside a git repository"),
+    include: List[str] = typer.Option(["**/*"], help="Glob patterns to include"),
+    exclude: List[str] = typer.Option([], help="Glob patterns to exclude"),
+    metric: str = typer.Option("cyclomatic", help="Complexity metric to weigh churn by: cyclomatic or cognitive"),
+    days: Optional[int] = typer.Option(None, min=1, help="Only count churn from commits in the last N days"),
+    top: int = typer.Option(20, min=0, help="Show the N highest-scoring functions (0 for all)"),
+    fmt: str = typer.Option("text", "--format", "-f", help="Output format: text or json"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+):
+    """Rank functions by complexity x churn, with blame-based owner and last change."""
+    if fmt not in {"text", "json"}:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected text or json)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    if metric not in METRICS:
+        typer.secho(f"Error: Unknown metric '{metric}' (expected {' or '.join(METRICS)})", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    try:
+        results = collect_hotspots(root, include, exclude, metric, days)
+    except (ValueError, RuntimeError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    shown = results[:top] if top else results
+    payload = hotspots_to_json(shown) if fmt == "json" else hotspots_to_text(shown, metric)
+    _emit(payload, output, f"{len(shown)} hotspots")
+
+
+@app.command()
+def report(
+    root: Path = typer.Argument(Path("."), exists=True, help="File or directory to map"),
+    include: List[str] = typer.Option(["**/*"], help="Glob patterns to include"),
+    exclude: List[str] = typer.Option([], help="Glob patterns to exclude"),
+    html: bool = typer.Option(False, "--html", help="Render an interactive, self-contained HTML treemap, not JSON"),
+    color: str = typer.Option(
+        "complexity", help="Color functions by complexity or churn (git log -L per function, as `hotspots`)"
+    ),
+    metric: str = typer.Option("cyclomatic", help="Complexity metric: cyclomatic or cognitive"),
+    days: Optional[int] = typer.Option(None, min=1, help="With --color churn, only count commits from the last N days"),
+    title: Optional[str] = typer.Option(None, help="Page title (default: '<directory> code health')"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+):
+    """Treemap of the repository (directory -> file -> function) sized by lines, colored by complexity or churn."""
+    try:
+        tree = collect_treemap(root, include, exclude, color, metric, days)
+    except (ValueError, RuntimeError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    functions = sum(1 for _ in tree.functions())
+    payload = treemap_to_html(tree, color, metric, title) if html else treemap_to_json(tree)
+    _emit(payload, output, f"{'HTML ' if html else ''}treemap of {functions} functions")
+
+
+@app.command()
+def history(
+    revisions: str = typer.Argument("HEAD", help="git log range to walk, e.g. v1.0..HEAD (default: all of HEAD)"),
+    path: Path = typer.Option(Path("."), exists=True, help="File or directory inside the repository to measure"),
+    include: List[str] = typer.Option(["**/*"], help="Glob patterns to include"),
+    exclude: List[str] = typer.Option([], help="Glob patterns to exclude"),
+    symbol: List[str] = typer.Option(
+        [], "--symbol", "-s", help="Only functions whose name, qualified name, or path:name matches (glob, repeatable)"
+    ),
+    max_count: Optional[int] = typer.Option(None, "--max-count", "-n", min=1, help="Walk only the newest N commits"),
+    fmt: str = typer.Option("text", "--format", "-f", help="Output format: text, json, or csv (one row per commit)"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+):
+    """Time series of per-function size, complexity, and existence across a range of commits."""
+    if fmt not in FORMAT_CHOICES["history"]:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected text, json, or csv)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    try:
+        result = symbol_history(path, revisions, include, exclude, symbol, max_count)
+    except (ValueError, RuntimeError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    if fmt == "json":
+        payload = result.to_json()
+    else:
+        payload = result.to_csv() if fmt == "csv" else result.to_text()
+    _emit(payload, output, f"{len(result.symbols)} functions over {len(result.revisions)} revisions")
+
+
+@app.command()
+def embed(
+    root: Path = typer.Argument(..., exists=True, file_okay=False, help="Project root to chunk and embed"),
+    db: Path = typer.Option(DEFAULT_INDEX_DB, help="SQLite database (shared with `index build`)"),
+    include: List[str] = typer.Option(["**/*"], help="Glob patterns to include"),
+    exclude: List[str] = typer.Option([], help="Glob patterns to exclude"),
+    max_tokens: int = typer.Option(512, help="Token budget per chunk (context header included)"),
+    overlap: int = typer.Option(0, help="Lines of overlap between parts of a split declaration"),
+    tokenizer: str = typer.Option("heuristic", help=TOKENIZER_HELP),
+    endpoint: str = typer.Option(
+        DEFAULT_ENDPOINT, envvar="TREESITTER_TOOLS_EMBED_ENDPOINT", help="OpenAI-compatible base URL (POSTs to /embeddings)"
+    ),
+    model: str = typer.Option(DEFAULT_MODEL, envvar="TREESITTER_TOOLS_EMBED_MODEL", help="Embedding model name"),
+    api_key: Optional[str] = typer.Option(
+        None, envvar=["TREESITTER_TOOLS_EMBED_API_KEY", "OPENAI_API_KEY"], help="Bearer token for the endpoint",
+        show_default=False,
+    ),
+    dimensions: Optional[int] = typer.Option(None, min=1, help="Request vectors of this size (if the model supports it)"),
+    batch_size: int = typer.Option(64, min=1, help="Chunks per request"),
+    retries: int = typer.Option(3, min=0, help="Retries per batch on 429/5xx/network errors (exponential backoff)"),
+    timeout: float = typer.Option(60.0, min=1, help="Seconds to wait for each request"),
+):
+    """Chunk code, embed changed chunks via an HTTP endpoint, and store vectors in the index database."""
+    try:
+        options = ChunkOptions(max_tokens=max_tokens, overlap_lines=overlap, count_tokens=get_tokenizer(tokenizer))
+    except (ValueError, RuntimeError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    embedder = http_embedder(EndpointConfig(endpoint, model, api_key, dimensions, timeout, retries))
+
+    def progress(stats) -> None:
+        typer.secho(f"Embedded {stats.embedded} chunks ({stats.batches} batches)", err=True)
+
+    try:
+        stats = embed_directory(root, db, embedder, model, options, include, exclude, batch_size, progress)
+    except (sqlite3.Error, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    typer.echo(json.dumps(stats.to_dict()))
+    if stats.failed:
+        typer.secho(f"Error: {stats.failed}", err=True, fg=typer.colors.RED)
+        typer.secho("Vectors stored so far are kept; re-run the same command to resume.", err=True, fg=typer.colors.YELLOW)
+        raise typer.Exit(1)
+
+
+@app.command()
+def clones(
+    root: Path = typer.Argument(..., exists=True, help="File or directory to analyse"),
+    include: List[str] = typer.Option(["**/*"], help="Glob patterns to include"),
+    exclude: List[str] = typer.Option([], help="Glob patterns to exclude"),
+    min_nodes: int = typer.Option(DEFAULT_MIN_NODES, min=1, help="Smallest subtree (in syntax nodes) worth reporting"),
+    fmt: str = typer.Option("text", "--format", "-f", help="Output format: text, json, or sarif"),
+    check: bool = typer.Option(False, "--check", help="Exit 1 when any clone group is found"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+):
+    """Find Type-1/Type-2 code clones by hashing normalized syntax subtrees."""
+    if fmt not in {"text", "json", "sarif"}:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected text, json, or sarif)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    try:
+        groups = find_clones(root, include, exclude, min_nodes)
+    except (ValueError, RuntimeError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    if fmt == "sarif":
+        payload = clones_to_sarif(groups, _sarif_root(root))
+    else:
+        payload = clones_to_json(groups) if fmt == "json" else clones_to_text(groups)
+    _emit(payload, output, f"{len(groups)} clone groups")
+    if check and groups:
+        raise typer.Exit(1)
+
+
+@app.command()
+def rename(
+    location: str = typer.Argument(..., help="Declaration or any reference to it, as PATH:LINE[:COLUMN] (1-based)"),
+    new_name: str = typer.Argument(..., help="New identifier"),
+    language: Optional[str] = typer.Option(None, "--language", "-l", help="Override language detection"),
+    in_place: bool = typer.Option(False, "--in-place", "-i", help="Write changes to disk instead of printing a patch"),
+    fmt: str = typer.Option("patch", "--format", "-f", help="patch, or edits (JSON for `apply`)"),
+):
+    """Rename a declaration and its same-package references using scope analysis, printing a patch."""
+    if fmt not in FORMAT_CHOICES["rename"]:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected patch or edits)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    if in_place or fmt == "edits":
+        redact.ACTIVE = None  # the rewritten files are built from the parsed source; never write masks back
+    if in_place:
+        overlay.ACTIVE = None  # files are rewritten whole, so rename in what is saved
+    try:
+        path, line, column = parse_location(location)
+        if not path.is_file():
+            raise ValueError(f"No such file: {path}")
+        result = rename_symbol(path, line, column, new_name, language)
+    except (ValueError, RuntimeError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    if in_place:
+        try:
+            write_files(result.files)
+        except OSError as e:
+            typer.secho(f"I/O Error: {e}", err=True, fg=typer.colors.RED)
+            raise typer.Exit(1)
+    elif fmt == "edits":
+        typer.echo(edits_to_json(edit_set(result.files)))
+    else:
+        typer.echo(result.patch(), nl=False)
+    typer.secho(
+        f"Renamed '{result.old_name}' -> '{result.new_name}': {result.occurrences} occurrences in {len(result.files)} files.",
+        err=True,
+        fg=typer.colors.GREEN,
+    )
+
+
+@app.command("apply")
+def apply_command(
+    edits: List[str] = typer.Argument(..., help="Edit sets from `rewrite`/`rename --format edits` (- reads stdin)"),
+    dry_run: bool = typer.Option(False, "--dry-run", "-n", help="Print the diff and conflicts without writing"),
+    base: Optional[Path] = typer.Option(
+        None, "--base", file_okay=False, help="Resolve relative paths against DIR (default: the working directory)"
+    ),
+    fmt: str = typer.Option("diff", "--format", "-f", help="Dry-run output: diff, or json (files and conflicts)"),
+):
+    """Apply structured edits to files, refusing on overlapping or stale edits and writing all files or none."""
+    if fmt not in FORMAT_CHOICES["apply"]:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected diff or json)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    try:
+        files = []
+        for name in edits:
+            text = sys.stdin.read() if name == "-" else Path(name).read_text(encoding="utf-8")
+            files.extend(load_edits(text, base, "<stdin>" if name == "-" else name))
+        plan = apply_patch(files, dry_run=dry_run)
+    except ValueError as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    except OSError as e:
+        typer.secho(f"I/O Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    if dry_run and fmt == "json":
+        typer.echo(json.dumps({
+            "files": [{"path": cwd_label(f.path), "edits": len(f.edits)} for f in plan.files],
+            "conflicts": [c.to_dict() for c in plan.conflicts],
+        }, indent=2))
+    elif dry_run:
+        typer.echo(plan.diff(), nl=False)
+    for conflict in plan.conflicts:
+        typer.secho(f"Conflict: {conflict}", err=True, fg=typer.colors.RED)
+    if plan.conflicts:
+        typer.secho(f"{len(plan.conflicts)} conflicts; no files were written.", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    verb = "Would apply" if dry_run else "Applied"
+    typer.secho(f"{verb} {plan.edits} edits to {len(plan.files)} files.", err=True, fg=typer.colors.GREEN)
+
+
+@app.command()
+def resolve(
+    location: str = typer.Argument(..., help="Identifier to resolve, as PATH:LINE[:COLUMN] (1-based)"),
+    language: Optional[str] = typer.Option(None, "--language", "-l", help="Override language detection"),
+    references: bool = typer.Option(False, "--references", "-r", help="Also list every reference to the definition"),
+    fmt: str = typer.Option("text", "--format", "-f", help="Output format: text or json"),
+):
+    """Resolve an identifier to its definition: a parameter, local, package-level, or imported declaration."""
+    if fmt not in {"text", "json"}:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected text or json)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    try:
+        path, line, column = parse_location(location)
+        if not path.is_file():
+            raise ValueError(f"No such file: {path}")
+        resolution = resolve_at(path, line, column, language, references)
+    except (ValueError, RuntimeError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    typer.echo(resolution.to_json() if fmt == "json" else resolution.to_text(), nl=fmt == "json")
+
+
+@app.command()
+def references(
+    name: str = typer.Argument(..., help="Name to find references to, e.g. a field, variable, or function"),
+    root: Path = typer.Argument(Path("."), exists=True, help="File or directory to search"),
+    include: List[str] = typer.Option(["**/*"], help="Glob patterns to include"),
+    exclude: List[str] = typer.Option([], help="Glob patterns to exclude"),
+    access: List[str] = typer.Option([], "--access", "-a", help="Only read, write, or call (repeatable)"),
+    member: Optional[bool] = typer.Option(
+        None, "--member/--no-member", help="Only accesses through an object (obj.NAME), or only plain names"
+    ),
+    fmt: str = typer.Option("text", "--format", "-f", help="Output format: text, json, or ndjson"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+):
+    """List references to a name, each classified as a read, write, or call (e.g. every mutation of a field)."""
+    from .references import iter_references, references_to_json, references_to_ndjson, references_to_text
+
+    renderers = {"text": references_to_text, "json": references_to_json, "ndjson": references_to_ndjson}
+    if fmt not in renderers:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected text, json, or ndjson)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    try:
+        found = list(iter_references(root, [name], include, exclude, access, member))
+    except (ValueError, RuntimeError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    _emit(renderers[fmt](found), output, f"{len(found)} references")
+
+
+@app.command("strings")
+def strings_command(
+    root: Path = typer.Argument(..., exists=True, help="File or directory to scan"),
+    include: List[str] = typer.Option(["**/*"], help="Glob patterns to include"),
+    exclude: List[str] = typer.Option([], help="Glob patterns to exclude"),
+    min_length: int = typer.Option(0, "--min-length", min=0, help="Skip literals shorter than this (unquoted)"),
+    match: Optional[str] = typer.Option(None, "--match", "-m", help="Only literals whose value matches this regex"),
+    docstrings: bool = typer.Option(True, "--docstrings/--no-docstrings", help="Include Python docstrings"),
+    fmt: str = typer.Option("text", "--format", "-f", help="Output format: text, json, or ndjson"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+):
+    """Extract string literals with their location, enclosing function, and context (call, assignment, key, ...)."""
+    renderers = {"text": literals_to_text, "json": literals_to_json, "ndjson": literals_to_ndjson}
+    if fmt not in renderers:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected text, json, or ndjson)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    try:
+        literals = list(iter_literals(root, include, exclude, min_length, match, docstrings))
+    except (ValueError, RuntimeError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    _emit(renderers[fmt](literals), output, f"{len(literals)} string literals")
+
+
+@app.command()
+def directives(
+    root: Path = typer.Argument(..., exists=True, help="File or directory to scan"),
+    include: List[str] = typer.Option(["**/*"], help="Glob patterns to include"),
+    exclude: List[str] = typer.Option([], help="Glob patterns to exclude"),
+    category: List[str] = typer.Option([], help="Only these categories: marker, suppression, pragma (repeatable)"),
+    tag: List[str] = typer.Option([], help="Only these tags, e.g. TODO, FIXME, nolint, noqa (repeatable)"),
+    git: bool = typer.Option(True, "--git/--no-git", help="Add the owner and age of each directive from git blame"),
+    fmt: str = typer.Option("text", "--format", "-f", help="Output format: text or json"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+):
+    """List TODO/FIXME/HACK markers, lint suppressions, and pragmas with owner, age, and enclosing symbol."""
+    if fmt not in {"text", "json"}:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected text or json)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    unknown = sorted(set(category) - {"marker", "suppression", "pragma"})
+    if unknown:
+        typer.secho(
+            f"Error: Unknown category '{unknown[0]}' (expected marker, suppression, or pragma)", err=True, fg=typer.colors.RED
+        )
+        raise typer.Exit(1)
+    try:
+        found = collect_directives(root, include, exclude, category, tag, git=git)
+    except (ValueError, RuntimeError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    payload = directives_to_json(found) if fmt == "json" else directives_to_text(found)
+    _emit(payload, output, f"{len(found)} directives")
+
+
+@app.command()
+def context(
+    symbol: str = typer.Argument(..., help="Function, method (Type.method), or type to gather context for"),
+    root: Path = typer.Argument(Path("."), exists=True, help="Project root (or a single file) to search"),
+    file: Optional[str] = typer.Option(None, "--file", help="Path (or path suffix) of the file defining the symbol"),
+    hops: int = typer.Option(1, min=1, max=MAX_HOPS, help="Call-graph distance of callers and callees to include"),
+    max_tokens: Optional[int] = typer.Option(None, min=1, help="Fit the bundle into N tokens, nearest items first"),
+    tokenizer: str = typer.Option("heuristic", help=TOKENIZER_HELP),
+    include: List[str] = typer.Option(["**/*"], help="Glob patterns to include"),
+    exclude: List[str] = typer.Option([], help="Glob patterns to exclude"),
+    fmt: str = typer.Option("markdown", "--format", "-f", help="Output format: markdown or json"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+):
+    """Bundle a symbol's definition, docs, referenced types, callers, callees, and imports for an LLM prompt."""
+    if fmt not in {"markdown", "json"}:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected markdown or json)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    try:
+        bundle = build_bundle(root, symbol, file, hops, max_tokens, get_tokenizer(tokenizer), include, exclude)
+    except (ValueError, RuntimeError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    if max_tokens is not None:
+        elided = sum(1 for item in bundle.items if item.elided)
+        typer.secho(
+            f"Budget: {bundle.used_tokens}/{max_tokens} tokens; {elided} bodies trimmed, {bundle.dropped} items dropped",
+            err=True,
+        )
+    payload = bundle.to_json() if fmt == "json" else bundle.to_markdown()
+    _emit(payload, output, f"context for {bundle.symbol} ({len(bundle.items)} items)")
+
+
+@app.command("slice")
+def slice_command(
+    symbol: str = typer.Argument(..., help="Function, method (Type.method), or type to slice out"),
+    root: Path = typer.Argument(Path("."), exists=True, help="Project root (or a single file) to search"),
+    file: Optional[str] = typer.Option(None, "--file", help="Path (or path suffix) of the file defining the symbol"),
+    depth: Optional[int] = typer.Option(None, min=1, help="Follow references at most N steps (default: all the way)"),
+    include: List[str] = typer.Option(["**/*"], help="Glob patterns to include"),
+    exclude: List[str] = typer.Option([], help="Glob patterns to exclude"),
+    fmt: str = typer.Option("source", "--format", "-f", help="Output format: source, markdown, or json"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+):
+    """Extract a symbol with every type, constant, and helper it transitively uses, as one source bundle."""
+    if fmt not in {"source", "markdown", "json"}:
+        typer.secho(
+            f"Error: Unsupported format '{fmt}' (expected source, markdown, or json)", err=True, fg=typer.colors.RED
+        )
+        raise typer.Exit(1)
+    from .slicer import build_slice
+
+    try:
+        sliced = build_slice(root, symbol, file, depth, include, exclude)
+    except (ValueError, RuntimeError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    payload = {"source": sliced.to_source, "markdown": sliced.to_markdown, "json": sliced.to_json}[fmt]()
+    _emit(payload, output, f"slice of {sliced.symbol} ({len(sliced.items)} definitions)")
+
+
+@app.command()
+def detect(
+    root: Path = typer.Argument(Path("."), exists=True, help="File or directory to inspect"),
+    include: List[str] = typer.Option(["**/*"], help="Glob patterns to include"),
+    exclude: List[str] = typer.Option([], help="Glob patterns to exclude"),
+    show_all: bool = typer.Option(False, "--all", help="Also list files no language was detected for"),
+    fmt: str = typer.Option("text", "--format", "-f", help="Output format: text or json"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+):
+    """Show the language detected for each file and how (extension, filename, shebang, modeline, or content), marking generated and vendored files and the source encoding."""
+    if fmt not in {"text", "json"}:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected text or json)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    if root.is_file():
+        targets = [(root, root.as_posix())]
+    else:
+        base = root.resolve()
+        targets = [(p, p.relative_to(base).as_posix()) for p in iter_source_files(base, include, exclude)]
+    rows = []
+    for path, label in targets:
+        detection = detect_file(path, LANGUAGE_MAPPINGS)
+        if detection is not None or show_all:
+            marks = {"generated": generated.generated_reason(path), "vendored": generated.vendored_reason(label)}
+            encoding = _source_encoding(path) if detection is not None else None
+            rows.append((label, detection, {k: v for k, v in marks.items() if v}, encoding))
+    if fmt == "json":
+        payload = json.dumps(
+            [
+                {
+                    "path": label,
+                    **(d.to_dict() if d else {"language": None, "method": None}),
+                    "encoding": encoding,
+                    **{k: True for k in marks},
+                }
+                for label, d, marks, encoding in rows
+            ],
+            indent=2,
+        )
+    else:
+        payload = "".join(
+            (f"{label}: {d.language} ({d.method})" if d else f"{label}: unknown")
+            + (f" [encoding: {encoding}]" if encoding not in (None, charsets.CANONICAL) else "")
+            + "".join(f" [{k}: {reason}]" for k, reason in marks.items()) + "\n"
+            for label, d, marks, encoding in rows
+        )
+    detected = sum(1 for _, d, _, _ in rows if d is not None)
+    _emit(payload, output, f"languages of {detected} files")
+
+
+def _source_encoding(path: Path) -> Optional[str]:
+    """The encoding `path`'s source is read as (see `charsets`), or None when it cannot be read."""
+    data = overlay.contents(path)
+    try:
+        return charsets.decode(data if data is not None else path.read_bytes()).encoding
+    except OSError:
+        return None
+
+
+def _load_analyzers(targets: List[str], commands: List[str], entry_points: bool) -> list:
+    """Analyzers from the config's `analyzers:`, then --analyzer / --command, then entry points."""
+    analyzers = []
+    if _CONFIG is not None and _CONFIG.path is not None:
+        base = _CONFIG.path.parent
+        for entry in _CONFIG.analyzers:
+            if entry.module:
+                analyzer = import_analyzer(entry.module, entry.options, base)
+                analyzer.name = entry.name or analyzer.name
+            else:
+                analyzer = command_analyzer(entry.command, entry.name, entry.options, base)
+            analyzers.append(analyzer)
+    analyzers.extend(import_analyzer(target, search_path=Path.cwd()) for target in targets)
+    analyzers.extend(command_analyzer(command) for command in commands)
+    if entry_points:
+        analyzers.extend(entry_point_analyzers())
+    return analyzers
+
+
+@app.command()
+def analyze(
+    paths: List[Path] = typer.Argument(..., exists=True, help="Files and/or directories to analyse"),
+    analyzer: List[str] = typer.Option(
+        [], "--analyzer", "-a", help="Python analyzer to run, as package.module:Attribute (repeatable)"
+    ),
+    command: List[str] = typer.Option(
+        [], "--command", help="Subprocess analyzer speaking JSON-RPC on stdin/stdout, e.g. './checks --strict'"
+    ),
+    entry_points: bool = typer.Option(
+        True, "--entry-points/--no-entry-points", help="Also run analyzers installed as treesitter_tools.analyzers entry points"
+    ),
+    include: List[str] = typer.Option(["**/*"], help="Glob patterns to include (directories)"),
+    exclude: List[str] = typer.Option([], help="Glob patterns to exclude (directories)"),
+    language: Optional[str] = typer.Option(None, help="Override detected language for file arguments"),
+    fmt: str = typer.Option("text", "--format", "-f", help="Output format: text (path:line:col: ...), json, or sarif"),
+    max_file_size: Optional[str] = typer.Option(None, help=MAX_FILE_SIZE_HELP),
+    list_only: bool = typer.Option(False, "--list", help="Print the analyzers that would run and exit"),
+    exit_zero: bool = typer.Option(False, "--exit-zero", help="Exit 0 even when error or warning findings are reported"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+):
+    """
+    Run custom analyzers over parsed files. Exits 1 on error or warning findings and
+    2 when an analyzer fails.
+    """
+    if fmt not in {"text", "json", "sarif"}:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected text, json, or sarif)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    try:
+        analyzers = _load_analyzers(analyzer, command, entry_points)
+        limit = _size_limit(max_file_size)
+    except (PluginError, ValueError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    if list_only:
+        for item in analyzers:
+            where = " ".join(item.command) if isinstance(item, SubprocessAnalyzer) else type(item).__module__
+            typer.echo(f"{item.name}\t{where}")
+        return
+    if not analyzers:
+        typer.secho(
+            "Error: No analyzers configured (use --analyzer, --command, or 'analyzers:' in the config)",
+            err=True,
+            fg=typer.colors.RED,
+        )
+        raise typer.Exit(1)
+    report = run_analyzers(paths, analyzers, include, exclude, language, limit)
+    if fmt == "sarif":
+        payload = report.to_sarif(Path.cwd())  # labels are the paths as given
+    else:
+        payload = report.to_json() if fmt == "json" else report.to_text()
+    if payload:
+        _emit(payload, output, f"{len(report.findings)} findings")
+    for error in report.errors:
+        typer.secho(f"Error: {error.to_text()}", err=True, fg=typer.colors.RED)
+    typer.echo(report.summary(), err=True)
+    if report.errors:
+        raise typer.Exit(2)
+    if report.failed and not exit_zero:
+        raise typer.Exit(1)
+
+
+@app.command()
+def security(
+    root: Path = typer.Argument(Path("."), exists=True, help="File or directory to check"),
+    include: List[str] = typer.Option(["**/*"], help="Glob patterns to include"),
+    exclude: List[str] = typer.Option([], help="Glob patterns to exclude"),
+    rules: List[Path] = typer.Option(
+        [], "--rules", exists=True, dir_okay=False, help="Extra YAML rule pack (repeatable)"
+    ),
+    default_rules: bool = typer.Option(
+        True, "--default-rules/--no-default-rules", help="Use the bundled Go, Python, and JavaScript packs"
+    ),
+    rule: List[str] = typer.Option([], "--rule", "-r", help="Only rules whose id matches this glob, e.g. 'go/*'"),
+    severity: str = typer.Option("low", "--severity", "-s", help="Minimum severity reported: low, medium, or high"),
+    fmt: str = typer.Option("text", "--format", "-f", help="Output format: text (path:line:col: ...), json, or sarif"),
+    list_only: bool = typer.Option(False, "--list", help="Print the selected rules and exit"),
+    exit_zero: bool = typer.Option(False, "--exit-zero", help="Exit 0 even when unsuppressed findings are reported"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+):
+    """
+    Flag risky patterns (eval/exec, shell commands, SQL built from strings, weak hashes,
+    disabled TLS checks) with query rule packs. Exits 1 on unsuppressed findings.
+    """
+    from .security import bundled_rules, load_pack, scan_security, select_rules
+
+    if fmt not in FORMAT_CHOICES["security"]:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected text, json, or sarif)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    try:
+        available = bundled_rules() if default_rules else []
+        for pack in rules:
+            available.extend(load_pack(pack))
+        selected = select_rules(available, rule, severity)
+    except ValueError as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    if list_only:
+        for item in selected:
+            typer.echo(f"{item.id}\t{item.severity}\t{','.join(item.languages)}\t{item.message}")
+        return
+    if not selected:
+        typer.secho("Error: No rules selected", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    try:
+        report = scan_security(root, selected, include, exclude)
+    except (ValueError, RuntimeError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    if fmt == "sarif":
+        payload = report.to_sarif(_sarif_root(root))
+    else:
+        payload = report.to_json() if fmt == "json" else report.to_text()
+    if payload:
+        _emit(payload, output, f"{len(report.active)} findings")
+    typer.echo(report.summary(), err=True)
+    if report.active and not exit_zero:
+        raise typer.Exit(1)
+
+
+@app.command("folding-ranges")
+def folding_ranges_command(
+    path: Path = typer.Argument(..., exists=True, dir_okay=False, help="Source file"),
+    language: Optional[str] = typer.Option(None, help="Override detected language"),
+    encoding: str = typer.Option("utf-16", help="Character offsets in utf-16 code units (LSP default), utf-8 bytes, or utf-32 code points"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+):
+    """Foldable regions as LSP FoldingRange JSON (blocks, literals, comment runs, imports)."""
+    from .editor import file_folding_ranges
+
+    try:
+        ranges = file_folding_ranges(path, language, encoding)
+    except (ValueError, RuntimeError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    _emit(json.dumps([r.to_dict() for r in ranges], indent=2), output, f"{len(ranges)} folding ranges")
+
+
+@app.command("document-symbols")
+def document_symbols_command(
+    path: Path = typer.Argument(..., exists=True, dir_okay=False, help="Source file"),
+    language: Optional[str] = typer.Option(None, help="Override detected language"),
+    encoding: str = typer.Option("utf-16", help="Character offsets in utf-16 code units (LSP default), utf-8 bytes, or utf-32 code points"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+):
+    """Nested class/function/method outline as LSP DocumentSymbol JSON."""
+    from .editor import file_document_symbols
+
+    try:
+        symbols = file_document_symbols(path, language, encoding)
+    except (ValueError, RuntimeError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    _emit(json.dumps([s.to_dict() for s in symbols], indent=2), output, f"{len(symbols)} top-level symbols")
+
+
+@app.command("selection-range")
+def selection_range_command(
+    path: Path = typer.Argument(..., exists=True, dir_okay=False, help="Source file"),
+    line: int = typer.Option(..., "--line", min=0, help="Zero-based line of the cursor"),
+    character: int = typer.Option(0, "--character", min=0, help="Zero-based character of the cursor on --line, in --encoding"),
+    language: Optional[str] = typer.Option(None, help="Override detected language"),
+    encoding: str = typer.Option("utf-16", help="Character offsets in utf-16 code units (LSP default), utf-8 bytes, or utf-32 code points"),
+    fmt: str = typer.Option("json", "--format", "-f", help="Output format: json (flat list, innermost first), text, or lsp (nested SelectionRange)"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+):
+    """Enclosing syntax nodes of a position, innermost to file, as editors' "expand selection" steps."""
+    from .editor import file_selection_range
+
+    fmt = fmt.lower()
+    if fmt not in FORMAT_CHOICES["selection-range"]:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected json, text, or lsp)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    try:
+        selection = file_selection_range(path, line, character, language, encoding)
+    except (ValueError, RuntimeError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    steps = selection.chain() if selection is not None else []
+    if fmt == "lsp":
+        payload = json.dumps(selection.to_dict() if selection is not None else None, indent=2)
+    elif fmt == "text":
+        payload = "".join(
+            f"{s.range['start']['line']}:{s.range['start']['character']}-"
+            f"{s.range['end']['line']}:{s.range['end']['character']}  {s.node_type}\n"
+            for s in steps
+        )
+    else:
+        payload = json.dumps([{"type": s.node_type, "range": s.range} for s in steps], indent=2)
+    _emit(payload, output, f"{len(steps)} selection ranges")
+
+
+@app.command()
+def stats(
+    root: Path = typer.Argument(Path("."), exists=True, help="File or directory to summarise"),
+    include: List[str] = typer.Option(["**/*"], help="Glob patterns to include"),
+    exclude: List[str] = typer.Option([], help="Glob patterns to exclude"),
+    fmt: str = typer.Option("text", "--format", "-f", help="Output format: text (tables and histograms) or json"),
+    top: int = typer.Option(10, min=0, help="Entries in the deepest-nesting, longest-function, and largest-file lists"),
+    tokenizer: str = typer.Option("heuristic", help=TOKENIZER_HELP),
+    max_file_size: Optional[str] = typer.Option(None, help=MAX_FILE_SIZE_HELP),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+    since: Optional[str] = typer.Option(None, help=SINCE_HELP),
+):
+    """Summarise a codebase: files per language, function size percentiles and histograms, deepest nesting."""
+    if fmt not in {"text", "json"}:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected text or json)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    from .stats import collect_stats
+
+    changes = _changes_since(since, root)
+    try:
+        report = collect_stats(
+            root, include, exclude, get_tokenizer(tokenizer), top,
+            changes.paths if changes is not None else None, _size_limit(max_file_size),
+        )
+    except (ValueError, RuntimeError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    payload = report.to_json() if fmt == "json" else report.to_text()
+    _emit(payload, output, f"stats for {len(report.files)} files")
+
+
+@app.command()
+def hierarchy(
+    root: Path = typer.Argument(Path("."), exists=True, help="File or directory to analyse"),
+    supertypes: Optional[str] = typer.Option(
+        None, "--supertypes", help="Only what this type extends, implements, or embeds"
+    ),
+    subtypes: Optional[str] = typer.Option(
+        None, "--subtypes", help="Only the types that extend, implement, or embed this one"
+    ),
+    transitive: bool = typer.Option(
+        False, "--transitive", help="With --supertypes/--subtypes, follow relations all the way up or down"
+    ),
+    include: List[str] = typer.Option(["**/*"], help="Glob patterns to include"),
+    exclude: List[str] = typer.Option([], help="Glob patterns to exclude"),
+    fmt: str = typer.Option("json", "--format", "-f", help="Output format: json, text, or dot"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+):
+    """Type hierarchy: class inheritance, implemented interfaces, and Go struct/interface embedding."""
+    if fmt not in {"json", "text", "dot"}:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected json, text, or dot)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    if supertypes is not None and subtypes is not None:
+        typer.secho("Error: Pass at most one of --supertypes or --subtypes", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    from .hierarchy import build_hierarchy, walk_to_json, walk_to_text
+
+    try:
+        graph = build_hierarchy(root, include, exclude)
+        if supertypes is None and subtypes is None:
+            payload = {"json": graph.to_json, "text": graph.to_text, "dot": graph.to_dot}[fmt]()
+            summary = f"{len(graph.relations)} relations among {len(graph.types)} types"
+        else:
+            name, direction = (supertypes, "supertypes") if supertypes is not None else (subtypes, "subtypes")
+            if fmt == "json":
+                payload = walk_to_json(graph, name, direction, transitive)
+            elif fmt == "text":
+                payload = walk_to_text(graph, name, direction, transitive)
+            else:
+                payload = graph.to_dot([r for _, r in graph.walk(name, direction, transitive)])
+            summary = f"{direction} of {name}"
+    except (ValueError, RuntimeError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    _emit(payload, output, summary)
+
+
+@app.command()
+def position(
+    path: Path = typer.Argument(..., exists=True, dir_okay=False, help="Source file"),
+    offset: Optional[int] = typer.Option(None, "--offset", min=0, help="Byte offset to convert"),
+    line: Optional[int] = typer.Option(None, "--line", min=0, help="Zero-based line to convert (with --character)"),
+    character: int = typer.Option(0, "--character", min=0, help="Zero-based character on --line, in --unit"),
+    unit: str = typer.Option("utf-16", help="Unit of --character: utf-16, utf-8, or utf-32"),
+):
+    """Convert a byte offset or line/character position to every other unit (JSON)."""
+    from .positions import UNITS, LineIndex, normalize_unit
+
+    if (offset is None) == (line is None):
+        typer.secho("Error: Pass exactly one of --offset or --line", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    try:
+        index = LineIndex(overlay.read_bytes(path))
+        byte = offset if offset is not None else index.offset(line, character, normalize_unit(unit))
+    except (ValueError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    byte = min(byte, len(index.source))
+    row, column = index.point(byte)
+    payload = {
+        "offset": byte,
+        "point": {"row": row, "column": column},
+        "positions": {u: index.position(byte, u).to_dict() for u in UNITS},
+        "offsets": {u: index.from_byte(byte, u) for u in UNITS},
+    }
+    typer.echo(json.dumps(payload))
+
+
+@app.command()
+def cfg(
+    path: Path = typer.Argument(..., exists=True, dir_okay=False, help="Source file"),
+    function: Optional[str] = typer.Option(
+        None, "--function", "-n", help="Only this function (name or qualified name such as Class.method)"
+    ),
+    language: Optional[str] = typer.Option(None, help="Override detected language"),
+    fmt: str = typer.Option("json", "--format", "-f", help="Output format: json, text, or dot"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+):
+    """Control-flow graphs (basic blocks and branch edges) of a file's functions."""
+    if fmt not in {"json", "text", "dot"}:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected json, text, or dot)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    from .cfg import file_cfgs, graphs_to_dot, graphs_to_json, graphs_to_text
+
+    try:
+        graphs = file_cfgs(path, function, language)
+    except (ValueError, RuntimeError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    payload = {"json": graphs_to_json, "text": graphs_to_text, "dot": graphs_to_dot}[fmt](graphs)
+    dead = sum(len(g.unreachable()) for g in graphs)
+    _emit(payload, output, f"{len(graphs)} control-flow graphs ({dead} unreachable blocks)")
+
+
+@app.command()
+def dataflow(
+    root: Path = typer.Argument(Path("."), exists=True, help="File or directory to analyse"),
+    function: Optional[str] = typer.Option(
+        None, "--function", "-n", help="Only this function (name or qualified name such as Class.method)"
+    ),
+    flows_only: bool = typer.Option(False, "--flows-only", help="Omit functions whose parameters reach no sink"),
+    include: List[str] = typer.Option(["**/*"], help="Glob patterns to include"),
+    exclude: List[str] = typer.Option([], help="Glob patterns to exclude"),
+    fmt: str = typer.Option("text", "--format", "-f", help="Output format: text or json"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+):
+    """Which parameters of each function flow into return values, call arguments, or global writes."""
+    if fmt not in {"text", "json"}:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected text or json)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    from .dataflow import analyze_flows, flows_to_json, flows_to_text
+
+    try:
+        summaries = analyze_flows(root, include, exclude, function)
+    except (ValueError, RuntimeError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    if flows_only:
+        summaries = [s for s in summaries if s.has_flows]
+    payload = flows_to_json(summaries) if fmt == "json" else flows_to_text(summaries)
+    _emit(payload, output, f"parameter flows for {len(summaries)} functions")
+
+
+@app.command()
+def decode(
+    path: Path = typer.Argument(..., exists=True, dir_okay=False, help="Binary record file (.gz is decompressed)"),
+    fmt: str = typer.Option("ndjson", "--format", "-f", help="ndjson (one record per line) or json (one array)"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+):
+    """Convert `--format proto` records back to the JSON that scan/chunk print."""
+    from .export.records import read_records
+
+    if fmt not in FORMAT_CHOICES["decode"]:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected ndjson or json)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    try:
+        records = [record.to_dict() for record in read_records(path)]
+        records = [schema.conform("file" if "symbols" in r else "chunk", r) for r in records]
+    except (ValueError, OSError, EOFError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    if fmt == "json":
+        payload = json.dumps(records, indent=2)
+    else:
+        payload = "".join(json.dumps(record, ensure_ascii=False) + "\n" for record in records)
+    _emit(payload, output, f"{len(records)} records")
+
+
+@app.command()
+def manifest(
+    root: Path = typer.Argument(Path("."), exists=True, help="File or directory to fingerprint"),
+    include: List[str] = typer.Option(["**/*"], help="Glob patterns to include"),
+    exclude: List[str] = typer.Option([], help="Glob patterns to exclude"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+):
+    """Repo manifest JSON: per-file hash, language, symbol counts, syntax and public-API hashes, per package too."""
+    from .manifest import build_manifest
+
+    try:
+        result = build_manifest(root, include, exclude)
+    except (ValueError, RuntimeError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    _emit(result.to_json(), output, f"manifest ({len(result.files)} files, {len(result.packages)} packages)")
+
+
+@app.command("manifest-diff")
+def manifest_diff_command(
+    before: Path = typer.Argument(..., exists=True, help="Manifest JSON of the old snapshot, or a directory to fingerprint"),
+    after: Path = typer.Argument(..., exists=True, help="Manifest JSON of the new snapshot, or a directory to fingerprint"),
+    fmt: str = typer.Option("text", "--format", "-f", help="Output format: text or json"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+):
+    """Files and packages added, removed, or changed between two manifests, by level: formatting, code, or api."""
+    from .manifest import build_manifest, diff_manifests, load_manifest
+
+    if fmt not in FORMAT_CHOICES["manifest-diff"]:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected text or json)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    try:
+        before_manifest, after_manifest = (
+            build_manifest(side) if side.is_dir() else load_manifest(side) for side in (before, after)
+        )
+        diff = diff_manifests(before_manifest, after_manifest)
+    except (ValueError, RuntimeError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    payload = diff.to_json() if fmt == "json" else diff.to_text()
+    _emit(payload, output, f"manifest diff ({len(diff.changed_packages)} packages changed)")
+
+
+@app.command()
+def repl(
+    path: Optional[Path] = typer.Argument(None, exists=True, dir_okay=False, help="File to load at start"),
+    language: Optional[str] = typer.Option(None, help="Override detected language"),
+    history: Optional[Path] = typer.Option(
+        None, envvar="TREESITTER_TOOLS_HISTORY", dir_okay=False, help="History file (default: ~/.treesitter_tools_history)"
+    ),
+    no_history: bool = typer.Option(False, "--no-history", help="Don't read or write a history file"),
+    highlight: Optional[bool] = typer.Option(
+        None, "--highlight/--no-highlight", help="ANSI-colour query captures (default: when stdout is a terminal)"
+    ),
+):
+    """Interactive shell: load files, run queries, inspect the node at a position, print subtrees."""
+    from .repl import DEFAULT_HISTORY, run_repl
+
+    use_color = highlight if highlight is not None else sys.stdout.isatty()
+    run_repl(path, language, None if no_history else history or DEFAULT_HISTORY, use_color)
+
+
+@app.command("get")
+def get_symbol(
+    name: str = typer.Argument(..., help="Symbol name or Type.method, optionally prefixed with FILE: or MODULE:"),
+    db: Path = typer.Option(DEFAULT_INDEX_DB, help="SQLite database written by `index build`"),
+    kind: Optional[str] = typer.Option(None, help="Only definitions of this kind (function, method, class, ...)"),
+    context: int = typer.Option(0, "--context", "-C", min=0, help="Lines of surrounding source to include on each side"),
+    doc: bool = typer.Option(False, "--doc", help="Include the comments and decorators directly above the definition"),
+    enclosing: bool = typer.Option(False, "--enclosing", help="Prefix a method with its enclosing type's declaration line"),
+    fmt: str = typer.Option("text", "--format", "-f", help="Output format: text or json"),
+):
+    """Print the exact source of a symbol looked up in the index, without re-parsing."""
+    if fmt not in {"text", "json"}:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected text or json)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    if not db.exists():
+        typer.secho(f"Error: Index not found at {db}; run `treesitter-tools index build` first", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    try:
+        with SymbolIndex(db) as index:
+            results = index.get(name, kind, context, doc, enclosing)
+    except (ValueError, sqlite3.Error) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    if not results:
+        typer.secho(f"Error: No definition of '{name}' in {db}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    for result in results:
+        if result["stale"]:
+            typer.secho(
+                f"Warning: {result['path']} changed since it was indexed; run `treesitter-tools index build`",
+                err=True, fg=typer.colors.YELLOW,
+            )
+    if fmt == "json":
+        typer.echo(json.dumps(results, indent=2))
+        return
+    for i, result in enumerate(result for result in results if result["source"] is not None):
+        if len(results) > 1:
+            prefix = "\n" if i else ""
+            typer.echo(f"{prefix}==> {result['path']}:{result['start_line']}-{result['end_line']} {result['qualified_name']} <==")
+        text = snippet_to_text(result)
+        typer.echo(text, nl=not text.endswith("\n"))
+
+
+@app.command()
+def extract(
+    ranges: List[str] = typer.Option(
+        [], "--range", help="Lines as FILE:START-END, or a diff hunk's FILE:START,COUNT (repeatable)"
+    ),
+    at: List[str] = typer.Option(
+        [], "--at", help="Position as FILE:LINE[:COLUMN], e.g. a stack-trace frame (1-based, repeatable)"
+    ),
+    context: int = typer.Option(
+        0, "--context", "-C", min=0, help="Lines of surrounding source to include on each side"
+    ),
+    doc: bool = typer.Option(False, "--doc", help="Include the comments and decorators directly above the declaration"),
+    enclosing: bool = typer.Option(
+        False, "--enclosing", help="Prefix a method with its enclosing type's declaration line"
+    ),
+    language: Optional[str] = typer.Option(None, "--language", "-l", help="Override language detection"),
+    fmt: str = typer.Option("text", "--format", "-f", help="Output format: text or json"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+):
+    """Print the smallest declaration(s) enclosing a line range or position, parsing the file on the fly."""
+    from .regions import extract_regions, parse_position, parse_range
+
+    if fmt not in FORMAT_CHOICES["extract"]:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected text or json)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    if not ranges and not at:
+        typer.secho("Error: Pass at least one --range or --at", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    found = []
+    try:
+        targets = [parse_range(spec) for spec in ranges] + [parse_position(spec) for spec in at]
+        for target in targets:
+            if not target.path.is_file():
+                raise ValueError(f"No such file: {target.path}")
+            regions = extract_regions(target, context, doc, enclosing, language)
+            if not regions:
+                typer.secho(f"Warning: No declaration encloses {target.label}", err=True, fg=typer.colors.YELLOW)
+            found.append({"target": target.label, "regions": regions})
+    except (ValueError, RuntimeError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    results = [region for entry in found for region in entry["regions"]]
+    if not results:
+        raise typer.Exit(1)
+    if fmt == "json":
+        payload = json.dumps(found, indent=2)
+    else:
+        parts = []
+        for result in results:
+            text = snippet_to_text(result)
+            if len(results) > 1:
+                where = f"{result['path']}:{result['start_line']}-{result['end_line']}"
+                text = f"==> {where} {result['qualified_name']} <==\n{text}"
+            parts.append(text if text.endswith("\n") else text + "\n")
+        payload = "\n".join(parts).rstrip("\n")
+    _emit(payload, output, f"{len(results)} declarations")
+
+
+@app.command()
+def attribute(
+    locations: Optional[List[str]] = typer.Argument(None, help="Lines as FILE:LINE (a column after it is ignored)"),
+    stdin: bool = typer.Option(False, "--stdin", help="Read more FILE:LINE locations from stdin, one per line"),
+    group: bool = typer.Option(False, "--group", help="Aggregate by symbol: hit count and lines per declaration"),
+    language: Optional[str] = typer.Option(None, "--language", "-l", help="Override language detection"),
+    fmt: str = typer.Option("text", "--format", "-f", help="Output format: text or json"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+):
+    """Map lines to their enclosing declaration, function, and type (coverage and crash reports by symbol)."""
+    from .regions import LineAttribution, SymbolMap, group_attributions, parse_position
+
+    if fmt not in FORMAT_CHOICES["attribute"]:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected text or json)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    specs = list(locations or [])
+    if stdin:
+        specs += [line.strip() for line in sys.stdin if line.strip()]
+    if not specs:
+        typer.secho("Error: Pass FILE:LINE locations, or --stdin", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    maps = {}
+    attributions = []
+    try:
+        for target in [parse_position(spec) for spec in specs]:
+            label = target.path.as_posix()
+            if label not in maps:
+                maps[label] = SymbolMap.load(target.path, language, label=label) if target.path.is_file() else None
+                if maps[label] is None:
+                    typer.secho(f"Warning: No such file: {label}", err=True, fg=typer.colors.YELLOW)
+            symbol_map = maps[label]
+            attributions.append(
+                symbol_map.attribute(target.start_line) if symbol_map is not None
+                else LineAttribution(label, target.start_line, None, None, None)
+            )
+    except (ValueError, RuntimeError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    if group:
+        groups = group_attributions(attributions)
+        if fmt == "json":
+            payload = json.dumps(groups, indent=2)
+        else:
+            payload = "\n".join(
+                f"{g['count']}\t{g['path']}:{g['symbol']['start_line'] if g['symbol'] else '-'}\t"
+                f"{g['symbol']['qualified_name'] if g['symbol'] else '(outside declarations)'}"
+                for g in groups
+            )
+        _emit(payload, output, f"{len(groups)} symbols")
+        return
+    if fmt == "json":
+        payload = json.dumps([a.to_dict() for a in attributions], indent=2)
+    else:
+        payload = "\n".join(
+            f"{a.path}:{a.line}\t{a.symbol.qualified_name if a.symbol is not None else '-'}" for a in attributions
+        )
+    _emit(payload, output, f"{len(attributions)} attributed lines")
+
+
+@app.command()
+def bench(
+    root: Path = typer.Argument(Path("."), exists=True, help="Corpus to benchmark (file or directory)"),
+    include: List[str] = typer.Option(["**/*"], help="Glob patterns to include"),
+    exclude: List[str] = typer.Option([], help="Glob patterns to exclude"),
+    language: List[str] = typer.Option([], "--language", "-l", help="Only benchmark this language (repeatable)"),
+    query: Optional[List[str]] = typer.Option(
+        None, "--query", "-q", help="Library query to time per language (repeatable; default: tags where available)"
+    ),
+    repeat: int = typer.Option(1, "--repeat", "-n", min=1, help="Passes per language; the fastest counts"),
+    jobs: List[int] = typer.Option([], "--jobs", "-j", min=1, help="Also time a parallel scan with N workers (repeatable)"),
+    isolate: bool = typer.Option(
+        True, "--isolate/--no-isolate", help="Measure each language in its own process so peak RSS is per language"
+    ),
+    profile: Optional[Path] = typer.Option(None, help="Sample the per-language passes and write a pprof profile here"),
+    profile_interval: float = typer.Option(5.0, min=0.1, help="Milliseconds between profile samples"),
+    baseline: Optional[Path] = typer.Option(
+        None, exists=True, dir_okay=False, help="Earlier `bench --format json` report to compare parse throughput with"
+    ),
+    fmt: str = typer.Option("text", "--format", "-f", help="Output format: text or json"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+):
+    """Measure parse throughput, query time, and peak memory per language over a corpus."""
+    from .bench import compare, run_bench
+
+    if fmt not in FORMAT_CHOICES["bench"]:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected text or json)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    try:
+        previous = json.loads(baseline.read_text(encoding="utf-8")) if baseline is not None else None
+    except json.JSONDecodeError as e:
+        typer.secho(f"Error: {baseline} is not a bench JSON report: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    try:
+        report = run_bench(
+            root, include, exclude, language or None, query or None, repeat, jobs, isolate,
+            profile_interval / 1000 if profile is not None else None,
+        )
+    except ValueError as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    if not report.languages:
+        typer.secho(f"Error: No source files to benchmark under {root}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    if previous is not None:
+        compare(report, previous)
+    if profile is not None:
+        profile.write_bytes(report.to_pprof())
+        typer.echo(f"Wrote pprof profile ({sum(v[0] for v in report.samples.values())} samples) -> {profile}", err=True)
+    payload = report.to_json() if fmt == "json" else report.to_text()
+    _emit(payload, output, f"benchmark of {len(report.languages)} languages")
+
+
+@app.command()
+def docs(
+    root: Path = typer.Argument(Path("."), exists=True, file_okay=False, help="Project root to document"),
+    output_dir: Path = typer.Option(Path("api-docs"), "--output-dir", "-o", file_okay=False, help="Directory for the pages"),
+    fmt: str = typer.Option("markdown", "--format", "-f", help="Output format: markdown or html"),
+    include: List[str] = typer.Option(["**/*"], help="Glob patterns to include"),
+    exclude: List[str] = typer.Option([], help="Glob patterns to exclude"),
+    private: bool = typer.Option(False, "--private", help="Also document unexported declarations"),
+    tests: bool = typer.Option(False, "--tests", help="Also document test files"),
+    title: str = typer.Option("API reference", help="Title of the index page"),
+    source_url: Optional[str] = typer.Option(
+        None, help="Link declarations to their source, e.g. https://example.com/repo/blob/main/{path}#L{line}"
+    ),
+    db: Optional[Path] = typer.Option(
+        None, help="Refresh and reuse this symbol index (default: a temporary one)", dir_okay=False
+    ),
+):
+    """Render symbols, signatures, and doc comments into static Markdown/HTML pages per package, cross-linked."""
+    import tempfile
+
+    from .docs import build_site
+
+    if fmt not in FORMAT_CHOICES["docs"]:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected markdown or html)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    try:
+        with tempfile.TemporaryDirectory() as scratch:
+            with SymbolIndex(db or Path(scratch) / "index.db") as index:
+                index.update(root, include, exclude)
+                site = build_site(index, private=private, tests=tests, title=title, source_url=source_url)
+        written = site.write(output_dir, fmt)
+    except (sqlite3.Error, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    symbols = sum(p.symbols for p in site.packages)
+    typer.secho(
+        f"Wrote {len(written)} pages ({len(site.packages)} packages, {symbols} symbols) to {output_dir}",
+        err=True, fg=typer.colors.GREEN,
+    )
+
+
+@schema_app.command("print")
+def schema_print(
+    record: Optional[str] = typer.Argument(None, help="Record to print (default: all of them, under $defs)"),
+    version: Optional[str] = typer.Option(
+        None, "--version", help="Schema version (default: --schema-version, else the current one)"
+    ),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+):
+    """Print the JSON Schema (draft 2020-12) of an output record."""
+    try:
+        version = schema.check_version(version) if version else schema.PINNED
+        payload = schema.schema_for(record, version) if record else schema.bundle(version)
+    except ValueError as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    _emit(json.dumps(payload, indent=2), output, f"schema {payload['x-schema-version']}")
+
+
+@schema_app.command("list")
+def schema_list(
+    fmt: str = typer.Option("text", "--format", "-f", help="Output format: text or json"),
+):
+    """List the output records, the commands that emit them, and the supported schema versions."""
+    if fmt not in FORMAT_CHOICES["schema list"]:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected text or json)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    records = schema.list_records()
+    if fmt == "json":
+        typer.echo(json.dumps({
+            "current": schema.SCHEMA_VERSION,
+            "versions": [{"version": v, "changes": schema.CHANGES[v]} for v in schema.VERSIONS],
+            "records": records,
+        }, indent=2))
+        return
+    width = max(len(r["record"]) for r in records)
+    for r in records:
+        typer.echo(f"{r['record']:<{width}}  {', '.join(r['commands'])}")
+    typer.echo("")
+    for v in schema.VERSIONS:
+        marker = " (current)" if v == schema.SCHEMA_VERSION else ""
+        typer.echo(f"{v}{marker}: {schema.CHANGES[v]}")
+
+
+GRAMMAR_LANGUAGES_HELP = "Only these languages (default: every registered language)"
+
+
+@grammars_app.command("list")
+def grammars_list(
+    languages: Optional[List[str]] = typer.Argument(None, help=GRAMMAR_LANGUAGES_HELP),
+    fmt: str = typer.Option("text", "--format", "-f", help="Output format: text or json"),
+):
+    """Show each language's grammar: where it comes from, ABI, version, node kinds, and fingerprint."""
+    if fmt not in FORMAT_CHOICES["grammars list"]:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected text or json)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    try:
+        grammars = list_grammars(languages)
+    except ValueError as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    if fmt == "json":
+        typer.echo(json.dumps([g.to_dict() for g in grammars], indent=2))
+        return
+    width = max((len(g.language) for g in grammars), default=0)
+    for g in grammars:
+        if g.error is not None:
+            typer.echo(f"{g.language:<{width}}  unavailable: {g.error}")
+            continue
+        build = f"{g.version or '-':<8}  abi {g.abi}  {g.node_kinds:>4} kinds"
+        typer.echo(f"{g.language:<{width}}  {build}  {g.fingerprint}  {g.source}")
+
+
+@grammars_app.command("pin")
+def grammars_pin(
+    languages: Optional[List[str]] = typer.Argument(None, help=GRAMMAR_LANGUAGES_HELP),
+    output: str = typer.Option(LOCK_NAME, "--output", "-o", help="Lock file to write ('-' for stdout)"),
+):
+    """Record the exact grammar versions and ABIs in use in a lock file that `grammars verify` checks."""
+    try:
+        lock = pin_grammars(languages)
+    except ValueError as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    for name in sorted(set(languages or LANGUAGE_SPECS) - set(lock["grammars"])):
+        typer.secho(f"Warning: not pinning {name}: its grammar is unavailable", err=True, fg=typer.colors.YELLOW)
+    _emit(json.dumps(lock, indent=2) + "\n", output, f"grammar lock ({len(lock['grammars'])} grammars)")
+
+
+@grammars_app.command("verify")
+def grammars_verify(
+    languages: Optional[List[str]] = typer.Argument(None, help="Only these languages (default: the pinned ones)"),
+    lock: Optional[Path] = typer.Option(
+        None, dir_okay=False, help=f"Grammar lock to compare against (default: ./{LOCK_NAME} when it exists)"
+    ),
+    strict: bool = typer.Option(False, "--strict", help="Also exit 1 on warnings (node-table kinds a grammar lacks)"),
+    fmt: str = typer.Option("text", "--format", "-f", help="Output format: text or json"),
+):
+    """Check grammars against the lock and the node kinds the queries use; exits 1 on any error."""
+    if fmt not in FORMAT_CHOICES["grammars verify"]:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected text or json)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    if lock is None and Path(LOCK_NAME).is_file():
+        lock = Path(LOCK_NAME)
+    try:
+        report = verify_grammars(read_lock(lock) if lock is not None else None, languages)
+    except ValueError as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    if fmt == "json":
+        typer.echo(json.dumps({"lock": lock.as_posix() if lock else None, **report.to_dict()}, indent=2))
+    else:
+        for p in report.problems:
+            color = typer.colors.RED if p.severity == "error" else typer.colors.YELLOW
+            typer.secho(f"{p.language}: {p.severity}: {p.message}", fg=color)
+        warnings = len(report.problems) - len(report.errors)
+        against = f" against {lock}" if lock is not None else ""
+        counts = f"{len(report.errors)} errors, {warnings} warnings"
+        typer.echo(f"{len(report.grammars)} grammars checked{against}: {counts}")
+    if report.errors or (strict and report.problems):
+        raise typer.Exit(1)
+
+
+@app.command("tests")
+def tests_command(
+    root: Path = typer.Argument(Path("."), exists=True, file_okay=False, help="Project root to analyse"),
+    include: List[str] = typer.Option(["**/*"], help="Glob patterns to include"),
+    exclude: List[str] = typer.Option([], help="Glob patterns to exclude"),
+    fmt: str = typer.Option("text", "--format", "-f", help="Output format: text or json"),
+    check: bool = typer.Option(False, "--check", help="Exit 1 when an exported symbol has no referencing test"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+):
+    """Discover Go, pytest, and Jest tests and report exported symbols no test references."""
+    if fmt not in {"text", "json"}:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected text or json)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    try:
+        report = map_tests(root, include, exclude)
+    except (ValueError, RuntimeError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    payload = report.to_json() if fmt == "json" else report.to_text()
+    _emit(payload, output, f"test map ({len(report.tests)} tests, {len(report.untested)} untested symbols)")
+    if check and report.untested:
+        raise typer.Exit(1)
+
+
+@app.command("api")
+def api_command(
+    root: Path = typer.Argument(..., exists=True, help="File or directory to summarise"),
+    include: List[str] = typer.Option(["**/*"], help="Glob patterns to include"),
+    exclude: List[str] = typer.Option([], help="Glob patterns to exclude"),
+    tests: bool = typer.Option(False, "--tests", help="Also report declarations in test files"),
+    fmt: str = typer.Option("text", "--format", "-f", help="Output format: text (diffable manifest) or json"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+):
+    """List exported functions, methods, types, and constants with their signatures, per package/module."""
+    if fmt not in {"text", "json"}:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected text or json)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    try:
+        modules = collect_api(root, include, exclude, include_tests=tests)
+    except (ValueError, RuntimeError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    payload = api_to_json(modules) if fmt == "json" else api_to_text(modules)
+    declarations = sum(len(m.declarations) for m in modules)
+    _emit(payload, output, f"API surface ({declarations} declarations in {len(modules)} modules)")
+
+
+@app.command("api-diff")
+def api_diff_command(
+    base: str = typer.Argument(..., help="Git revision of the previous release"),
+    head: Optional[str] = typer.Argument(None, help="Git revision to compare against it (default: the working tree)"),
+    path: Path = typer.Option(Path("."), exists=True, file_okay=False, help="Directory inside the repository to compare"),
+    include: List[str] = typer.Option(["**/*"], help="Glob patterns to include"),
+    exclude: List[str] = typer.Option([], help="Glob patterns to exclude"),
+    tests: bool = typer.Option(False, "--tests", help="Also compare declarations in test files"),
+    strict: bool = typer.Option(False, "--strict", help="Exit 3 when there are additive changes only"),
+    fmt: str = typer.Option("text", "--format", "-f", help="Output format: text or json"),
+    output: Optional[str] = typer.Option(None, help=OUTPUT_HELP),
+):
+    """Classify API changes between two revisions; exits 2 on breaking changes (removed or changed signatures)."""
+    if fmt not in {"text", "json"}:
+        typer.secho(f"Error: Unsupported format '{fmt}' (expected text or json)", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    try:
+        diff = compare_refs(path, base, head, include, exclude, include_tests=tests)
+    except (ValueError, RuntimeError, OSError) as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise typer.Exit(1)
+    payload = diff.to_json() if fmt == "json" else diff.to_text()
+    summary = diff.summary()
+    _emit(payload, output, f"API diff ({summary['breaking']} breaking, {summary['additive']} additive)")
+    code = diff.exit_code(strict)
+    if code:
+        raise typer.Exit(code)
+
+
+def _global_value_options() -> List[str]:
+    """The global options that take a value, so `command_line` can tell their values from the subcommand."""
+    group = typer.main.get_command(app)
+    options = [param for param in group.params if isinstance(param, click.Option) and not param.is_flag]
+    return [name for option in options for name in option.opts]
+
+
+def command_line(argv: Sequence[str]) -> List[str]:
+    """The arguments `app` runs on for `argv`: `--param-file`s (and `@FILE`s after `action`) expanded."""
+    return buildaction.expand_command_line(argv, _global_value_options())
+
+
+def run() -> None:
+    """
+    Console entry point: `app`, restarted with fixed string hashing first when the run
+    must be deterministic, on the arguments with `--param-file`s expanded.
+    """
+    try:
+        args = command_line(sys.argv[1:])
+    except ValueError as e:
+        typer.secho(f"Error: {e}", err=True, fg=typer.colors.RED)
+        raise SystemExit(1)
+    if reproducible.requested(args):
+        reproducible.pin_hash_seed(sys.orig_argv[1:])
+    app(args=args)
+
 
 if __name__ == "__main__":
-    app()
+    run()
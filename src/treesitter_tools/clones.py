@@ -0,0 +1,271 @@
+"""Duplicate code detection by fingerprinting normalized syntax subtrees."""
+
+from __future__ import annotations
+
+import hashlib
+import json
+from collections import defaultdict
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Dict, List, Optional, Sequence, Tuple
+
+from tree_sitter import Node
+
+from . import failures
+from .core import ParsedFile, iter_source_files, parse_file
+from .export.sarif import SarifLocation, SarifResult, SarifRule, sarif_to_json
+
+# Subtrees smaller than this (counted after normalization) are too common to be interesting.
+DEFAULT_MIN_NODES = 40
+
+SARIF_RULES = {
+    1: SarifRule(
+        "clone/type-1", "ExactClone", "Code is duplicated verbatim",
+        "The same syntax tree appears elsewhere, differing only in layout and comments; "
+        "consider extracting it into a shared function.",
+        tags=("maintainability", "duplication"),
+    ),
+    2: SarifRule(
+        "clone/type-2", "RenamedClone", "Code is duplicated with renamed identifiers or literals",
+        "The same syntax tree appears elsewhere with different identifiers or literal values; "
+        "consider extracting it into a function parameterized by what differs.",
+        "note", ("maintainability", "duplication"),
+    ),
+}
+
+# Leaves renamed to a placeholder for Type-2 matching.
+IDENTIFIER_NODE_TYPES = {
+    "identifier",
+    "type_identifier",
+    "field_identifier",
+    "property_identifier",
+    "shorthand_property_identifier",
+    "shorthand_property_identifier_pattern",
+    "package_identifier",
+    "namespace_identifier",
+    "statement_identifier",
+    "constant",
+}
+
+# Literal nodes are abstracted whole (strings have children in most grammars).
+LITERAL_NODE_TYPES = {
+    "string",
+    "string_literal",
+    "raw_string_literal",
+    "interpreted_string_literal",
+    "template_string",
+    "char_literal",
+    "character_literal",
+    "rune_literal",
+    "concatenated_string",
+    "integer",
+    "integer_literal",
+    "int_literal",
+    "float",
+    "float_literal",
+    "imaginary_literal",
+    "number",
+    "number_literal",
+    "decimal_integer_literal",
+    "decimal_floating_point_literal",
+    "hex_integer_literal",
+    "true",
+    "false",
+    "none",
+    "null",
+    "nil",
+    "null_literal",
+    "boolean",
+    "boolean_literal",
+}
+
+
+@dataclass
+class CloneLocation:
+    path: str
+    start_line: int
+    end_line: int
+    node_type: str
+
+    def to_dict(self) -> dict:
+        return {"path": self.path, "start_line": self.start_line, "end_line": self.end_line, "node_type": self.node_type}
+
+
+@dataclass
+class CloneGroup:
+    fingerprint: str
+    clone_type: int  # 1: identical apart from layout/comments, 2: identifiers or literals differ
+    nodes: int
+    language: str
+    locations: List[CloneLocation] = field(default_factory=list)
+
+    @property
+    def lines(self) -> int:
+        return max(loc.end_line - loc.start_line + 1 for loc in self.locations)
+
+    def to_dict(self) -> dict:
+        return {
+            "fingerprint": self.fingerprint,
+            "type": self.clone_type,
+            "nodes": self.nodes,
+            "lines": self.lines,
+            "language": self.language,
+            "locations": [loc.to_dict() for loc in self.locations],
+        }
+
+
+@dataclass
+class _Candidate:
+    normalized: str
+    exact: str
+    nodes: int
+    location: CloneLocation
+
+
+def _digest(text: str) -> str:
+    return hashlib.blake2b(text.encode("utf-8"), digest_size=12).hexdigest()
+
+
+def fingerprint_file(parsed: ParsedFile, label: str, min_nodes: int = DEFAULT_MIN_NODES) -> List[_Candidate]:
+    """
+    Hash every subtree bottom-up twice: exactly (Type-1) and with identifiers and
+    literals replaced by placeholders (Type-2). Comments are ignored in both.
+    Named subtrees with at least `min_nodes` nodes are returned as candidates.
+    """
+    source = parsed.source
+    candidates: List[_Candidate] = []
+    # Post-order walk without recursion; results holds (normalized, exact, nodes) per child.
+    stack: List[Tuple[Node, bool]] = [(parsed.root, False)]
+    results: Dict[int, Tuple[str, str, int]] = {}
+    while stack:
+        node, visited = stack.pop()
+        if "comment" in node.type:
+            continue
+        if node.type in LITERAL_NODE_TYPES or node.type in IDENTIFIER_NODE_TYPES or node.child_count == 0:
+            text = source[node.start_byte : node.end_byte].decode("utf-8", "replace")
+            if node.type in LITERAL_NODE_TYPES:
+                normalized = "LIT"
+            elif node.type in IDENTIFIER_NODE_TYPES:
+                normalized = "ID"
+            else:
+                normalized = node.type if not node.is_named else f"{node.type}={text}"
+            results[node.id] = (normalized, _digest(f"{node.type}={text}"), 1)
+            continue
+        if not visited:
+            stack.append((node, True))
+            stack.extend((child, False) for child in reversed(node.children))
+            continue
+        parts = [results.pop(child.id) for child in node.children if child.id in results]
+        normalized = _digest(f"{node.type}({','.join(p[0] for p in parts)})")
+        exact = _digest(f"{node.type}({','.join(p[1] for p in parts)})")
+        nodes = 1 + sum(p[2] for p in parts)
+        results[node.id] = (normalized, exact, nodes)
+        if node.is_named and nodes >= min_nodes and node.parent is not None:
+            location = CloneLocation(label, node.start_point[0] + 1, node.end_point[0] + 1, node.type)
+            candidates.append(_Candidate(normalized, exact, nodes, location))
+    return candidates
+
+
+def _contains(outer: CloneLocation, inner: CloneLocation) -> bool:
+    return outer.path == inner.path and outer.start_line <= inner.start_line and inner.end_line <= outer.end_line
+
+
+def group_clones(candidates: Sequence[Tuple[str, _Candidate]]) -> List[CloneGroup]:
+    """
+    Group (language, candidate) pairs by normalized fingerprint. A group whose
+    every copy lies inside copies of a larger reported group is dropped, so a
+    duplicated function is not also reported once per duplicated statement.
+    """
+    buckets: Dict[Tuple[str, str], List[_Candidate]] = defaultdict(list)
+    for language, candidate in candidates:
+        buckets[(language, candidate.normalized)].append(candidate)
+    groups = []
+    for (language, fingerprint), members in buckets.items():
+        if len(members) < 2:
+            continue
+        members.sort(key=lambda c: (c.location.path, c.location.start_line))
+        clone_type = 1 if len({m.exact for m in members}) == 1 else 2
+        groups.append(CloneGroup(fingerprint, clone_type, members[0].nodes, language, [m.location for m in members]))
+    groups.sort(key=lambda g: (-g.nodes, g.locations[0].path, g.locations[0].start_line))
+    kept: List[CloneGroup] = []
+    covered: List[CloneLocation] = []
+    for group in groups:
+        if all(any(_contains(outer, loc) for outer in covered) for loc in group.locations):
+            continue
+        kept.append(group)
+        covered.extend(group.locations)
+    return kept
+
+
+def find_clones(
+    root: Path,
+    include: Sequence[str] | None = None,
+    exclude: Sequence[str] | None = None,
+    min_nodes: int = DEFAULT_MIN_NODES,
+) -> List[CloneGroup]:
+    """Clone groups across a file or every recognised file under a directory, largest first."""
+    if min_nodes < 1:
+        raise ValueError("min_nodes must be at least 1")
+    root = Path(root)
+    if root.is_file():
+        targets = [(root, root.as_posix())]
+    else:
+        base = root.resolve()
+        targets = [(p, p.relative_to(base).as_posix()) for p in iter_source_files(base, include, exclude)]
+    candidates: List[Tuple[str, _Candidate]] = []
+    for path, label in targets:
+        try:
+            parsed = parse_file(path)
+        except (ValueError, RuntimeError, OSError) as exc:
+            failures.record(path, exc)
+            continue
+        candidates.extend((parsed.language, c) for c in fingerprint_file(parsed, label, min_nodes))
+    return group_clones(candidates)
+
+
+def clones_to_json(groups: Sequence[CloneGroup]) -> str:
+    return json.dumps([g.to_dict() for g in groups], indent=2)
+
+
+def clones_to_sarif(groups: Sequence[CloneGroup], root: Optional[Path] = None) -> str:
+    """One result per copy, with the group's other copies as related locations."""
+    results = []
+    for group in groups:
+        for loc in group.locations:
+            others = [o for o in group.locations if o is not loc]
+            results.append(SarifResult(
+                SARIF_RULES[group.clone_type],
+                f"{loc.node_type} ({group.lines} lines) is duplicated in {len(others)} other "
+                f"place{'s' if len(others) != 1 else ''}",
+                SarifLocation(loc.path, loc.start_line, end_line=loc.end_line),
+                (group.fingerprint,),
+                related=[SarifLocation(o.path, o.start_line, end_line=o.end_line, message="copy") for o in others],
+                properties={"nodes": group.nodes},
+            ))
+    return sarif_to_json(results, list(SARIF_RULES.values()), root)
+
+
+def clones_to_text(groups: Sequence[CloneGroup]) -> str:
+    lines = []
+    for number, group in enumerate(groups, 1):
+        lines.append(
+            f"Clone group {number}: type-{group.clone_type}, {len(group.locations)} copies, "
+            f"{group.nodes} nodes, {group.lines} lines"
+        )
+        for loc in group.locations:
+            lines.append(f"  {loc.path}:{loc.start_line}-{loc.end_line} {loc.node_type}")
+    return "".join(line + "\n" for line in lines)
+
+
+__all__ = [
+    "CloneGroup",
+    "CloneLocation",
+    "DEFAULT_MIN_NODES",
+    "SARIF_RULES",
+    "clones_to_json",
+    "clones_to_sarif",
+    "clones_to_text",
+    "find_clones",
+    "fingerprint_file",
+    "group_clones",
+]
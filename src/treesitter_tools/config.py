@@ -0,0 +1,354 @@
+"""Project configuration (`.treesitter-tools.yaml`) with flag > config > default precedence."""
+
+from __future__ import annotations
+
+import re
+import shlex
+from contextlib import contextmanager
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Any, ContextManager, Dict, Iterable, Iterator, List, Optional
+
+import yaml
+
+from .core import LANGUAGE_MAPPINGS
+
+CONFIG_NAMES = (".treesitter-tools.yaml", ".treesitter-tools.yml")
+
+TOP_LEVEL_KEYS = {
+    "include", "exclude", "languages", "max_chunk_size", "chunk", "queries", "format", "analyzers", "cache_dir",
+    "redact",
+}
+CHUNK_KEYS = {"max_tokens", "overlap"}
+REDACT_KEYS = {"enabled", "builtin", "patterns"}
+ANALYZER_KEYS = {"module", "command", "name", "options"}
+
+# Command-line parameter each config key fills in when the flag isn't given.
+PARAM_KEYS = {
+    "include": "include",
+    "exclude": "exclude",
+    "max_chunk_size": "max_chunk_size",
+    "cache_dir": "cache_dir",
+    "max_tokens": "chunk.max_tokens",
+    "overlap": "chunk.overlap",
+}
+
+
+class ConfigError(ValueError):
+    """The config file is unreadable or fails validation; `problems` lists every issue."""
+
+    def __init__(self, path: Path, problems: List[str]):
+        super().__init__(f"{path}: " + "; ".join(problems))
+        self.path = path
+        self.problems = problems
+
+
+@dataclass
+class ConfigQuery:
+    name: str
+    query: str
+    language: Optional[str] = None
+
+
+@dataclass
+class ConfigAnalyzer:
+    module: Optional[str] = None  # "package.module:Attribute"
+    command: Optional[List[str]] = None  # subprocess speaking JSON-RPC
+    name: Optional[str] = None
+    options: Dict[str, Any] = field(default_factory=dict)
+
+    def to_dict(self) -> dict:
+        data: Dict[str, Any] = {"module": self.module} if self.module else {"command": self.command}
+        if self.name:
+            data["name"] = self.name
+        if self.options:
+            data["options"] = self.options
+        return data
+
+
+@dataclass
+class ProjectConfig:
+    path: Optional[Path] = None
+    include: Optional[List[str]] = None
+    exclude: Optional[List[str]] = None
+    languages: Dict[str, str] = field(default_factory=dict)
+    max_chunk_size: Optional[int] = None
+    cache_dir: Optional[str] = None  # relative paths are resolved against the config file's directory
+    chunk: Dict[str, int] = field(default_factory=dict)
+    queries: Dict[str, ConfigQuery] = field(default_factory=dict)
+    formats: Dict[str, str] = field(default_factory=dict)
+    analyzers: List[ConfigAnalyzer] = field(default_factory=list)
+    redact: bool = False
+    redact_builtin: bool = True
+    redact_patterns: List[str] = field(default_factory=list)
+
+    def _lookup(self, dotted: str) -> Any:
+        if dotted.startswith("chunk."):
+            return self.chunk.get(dotted.split(".", 1)[1])
+        return getattr(self, dotted)
+
+    def command_defaults(self, command: str, params: Iterable[str]) -> Dict[str, Any]:
+        """Defaults for `command` (e.g. "scan", "index build") limited to the params it accepts."""
+        params = set(params)
+        defaults: Dict[str, Any] = {}
+        for param, key in PARAM_KEYS.items():
+            if param in params:
+                value = self._lookup(key)
+                if value is not None:
+                    defaults[param] = value
+        if "fmt" in params and command in self.formats:
+            defaults["fmt"] = self.formats[command]
+        return defaults
+
+    def query_for(self, name: str, language: Optional[str]) -> Optional[str]:
+        entry = self.queries.get(name)
+        if entry is None or (entry.language and language and entry.language != language):
+            return None
+        return entry.query
+
+    def language_overrides(self) -> ContextManager[None]:
+        """This config's extension -> language overrides, applied for the duration of a `with` block."""
+        return language_overrides(self.languages)
+
+    def to_dict(self) -> dict:
+        return {
+            "path": self.path.as_posix() if self.path else None,
+            "include": self.include,
+            "exclude": self.exclude,
+            "languages": dict(sorted(self.languages.items())),
+            "max_chunk_size": self.max_chunk_size,
+            "cache_dir": self.cache_dir,
+            "chunk": dict(sorted(self.chunk.items())),
+            "queries": {
+                name: {"language": q.language, "query": q.query} for name, q in sorted(self.queries.items())
+            },
+            "format": dict(sorted(self.formats.items())),
+            "analyzers": [a.to_dict() for a in self.analyzers],
+            "redact": {"enabled": self.redact, "builtin": self.redact_builtin, "patterns": self.redact_patterns},
+        }
+
+
+@contextmanager
+def language_overrides(languages: Dict[str, str]) -> Iterator[None]:
+    """Apply extension -> language overrides for the duration of the block."""
+    saved = dict(LANGUAGE_MAPPINGS)
+    for ext, language in languages.items():
+        LANGUAGE_MAPPINGS[ext.lstrip(".").lower()] = language
+    try:
+        yield
+    finally:
+        LANGUAGE_MAPPINGS.clear()
+        LANGUAGE_MAPPINGS.update(saved)
+
+
+def find_config(start: Path) -> Optional[Path]:
+    """Nearest config file in `start` or one of its parents."""
+    start = Path(start).resolve()
+    for directory in (start, *start.parents):
+        for name in CONFIG_NAMES:
+            candidate = directory / name
+            if candidate.is_file():
+                return candidate
+    return None
+
+
+def _string_list(value: Any, key: str, problems: List[str]) -> Optional[List[str]]:
+    if isinstance(value, str):
+        value = [value]
+    if not isinstance(value, list) or not all(isinstance(v, str) and v for v in value):
+        problems.append(f"'{key}' must be a glob or a list of globs")
+        return None
+    return value
+
+
+def _positive_int(value: Any, key: str, problems: List[str], minimum: int = 1) -> Optional[int]:
+    if isinstance(value, bool) or not isinstance(value, int) or value < minimum:
+        problems.append(f"'{key}' must be an integer >= {minimum}")
+        return None
+    return value
+
+
+def _analyzer(entry: Any, context: str, problems: List[str]) -> Optional[ConfigAnalyzer]:
+    if isinstance(entry, str):
+        entry = {"module": entry}
+    if not isinstance(entry, dict):
+        problems.append(f"'{context}' must be a 'module:attr' string or a mapping")
+        return None
+    for key in sorted(set(entry) - ANALYZER_KEYS):
+        problems.append(f"unknown key '{context}.{key}'")
+    module, command = entry.get("module"), entry.get("command")
+    if (module is None) == (command is None):
+        problems.append(f"'{context}' needs exactly one of 'module' or 'command'")
+        return None
+    if module is not None and (not isinstance(module, str) or ":" not in module):
+        problems.append(f"'{context}.module' must look like 'package.module:Attribute'")
+        return None
+    if isinstance(command, str):
+        command = shlex.split(command)
+    if command is not None and (not isinstance(command, list) or not command or not all(isinstance(a, str) for a in command)):
+        problems.append(f"'{context}.command' must be a command line or a list of arguments")
+        return None
+    name, options = entry.get("name"), entry.get("options", {})
+    if name is not None and not isinstance(name, str):
+        problems.append(f"'{context}.name' must be a string")
+        return None
+    if not isinstance(options, dict):
+        problems.append(f"'{context}.options' must be a mapping")
+        return None
+    return ConfigAnalyzer(module, command, name, options)
+
+
+def parse_config(data: Any, path: Path, format_commands: Optional[Dict[str, Iterable[str]]] = None) -> ProjectConfig:
+    """
+    Validate raw config data. `format_commands` maps command names to their accepted
+    `--format` values so `format:` entries can be checked; all problems are reported at once.
+    """
+    problems: List[str] = []
+    config = ProjectConfig(path=path)
+    if data is None:
+        return config
+    if not isinstance(data, dict):
+        raise ConfigError(path, ["top level must be a mapping"])
+    for key in sorted(set(data) - TOP_LEVEL_KEYS):
+        problems.append(f"unknown key '{key}' (expected one of {', '.join(sorted(TOP_LEVEL_KEYS))})")
+
+    if "include" in data:
+        config.include = _string_list(data["include"], "include", problems)
+    if "exclude" in data:
+        config.exclude = _string_list(data["exclude"], "exclude", problems)
+    if "max_chunk_size" in data:
+        config.max_chunk_size = _positive_int(data["max_chunk_size"], "max_chunk_size", problems)
+    if "cache_dir" in data:
+        if isinstance(data["cache_dir"], str) and data["cache_dir"]:
+            config.cache_dir = (path.parent / data["cache_dir"]).as_posix()
+        else:
+            problems.append("'cache_dir' must be a directory path")
+
+    languages = data.get("languages", {})
+    if not isinstance(languages, dict):
+        problems.append("'languages' must map file extensions to language names")
+    else:
+        for ext, language in languages.items():
+            if not isinstance(ext, str) or not isinstance(language, str) or not language:
+                problems.append(f"'languages.{ext}' must be a language name")
+            else:
+                config.languages[ext] = language
+
+    chunk = data.get("chunk", {})
+    if not isinstance(chunk, dict):
+        problems.append("'chunk' must be a mapping with max_tokens/overlap")
+    else:
+        for key in sorted(set(chunk) - CHUNK_KEYS):
+            problems.append(f"unknown key 'chunk.{key}'")
+        if "max_tokens" in chunk:
+            value = _positive_int(chunk["max_tokens"], "chunk.max_tokens", problems)
+            if value is not None:
+                config.chunk["max_tokens"] = value
+        if "overlap" in chunk:
+            value = _positive_int(chunk["overlap"], "chunk.overlap", problems, minimum=0)
+            if value is not None:
+                config.chunk["overlap"] = value
+
+    queries = data.get("queries", {})
+    if not isinstance(queries, dict):
+        problems.append("'queries' must map names to {query|file, language}")
+    else:
+        for name, entry in queries.items():
+            context = f"queries.{name}"
+            if isinstance(entry, str):
+                entry = {"query": entry}
+            if not isinstance(entry, dict):
+                problems.append(f"'{context}' must be a query string or a mapping")
+                continue
+            text = entry.get("query")
+            if "file" in entry:
+                query_path = path.parent / str(entry["file"])
+                if not query_path.is_file():
+                    problems.append(f"'{context}.file' not found: {query_path}")
+                    continue
+                text = query_path.read_text(encoding="utf-8")
+            if not isinstance(text, str) or not text.strip():
+                problems.append(f"'{context}' needs 'query' or 'file'")
+                continue
+            language = entry.get("language")
+            if language is not None and not isinstance(language, str):
+                problems.append(f"'{context}.language' must be a string")
+                continue
+            config.queries[str(name)] = ConfigQuery(str(name), text, language)
+
+    formats = data.get("format", {})
+    if not isinstance(formats, dict):
+        problems.append("'format' must map command names to output formats, e.g. {scan: ndjson}")
+    else:
+        for command, fmt in formats.items():
+            allowed = None if format_commands is None else format_commands.get(command)
+            if format_commands is not None and allowed is None:
+                problems.append(f"'format.{command}': no such command with --format")
+            elif not isinstance(fmt, str) or (allowed and fmt not in allowed):
+                expected = f" (expected one of {', '.join(allowed)})" if allowed else ""
+                problems.append(f"'format.{command}': invalid format {fmt!r}{expected}")
+            else:
+                config.formats[command] = fmt
+
+    analyzers = data.get("analyzers", [])
+    if not isinstance(analyzers, list):
+        problems.append("'analyzers' must be a list of {module|command, name, options}")
+    else:
+        for i, entry in enumerate(analyzers):
+            analyzer = _analyzer(entry, f"analyzers[{i}]", problems)
+            if analyzer is not None:
+                config.analyzers.append(analyzer)
+
+    redact = data.get("redact", False)
+    if isinstance(redact, bool):
+        config.redact = redact
+    elif not isinstance(redact, dict):
+        problems.append("'redact' must be true/false or a mapping with enabled/builtin/patterns")
+    else:
+        for key in sorted(set(redact) - REDACT_KEYS):
+            problems.append(f"unknown key 'redact.{key}'")
+        for key in ("enabled", "builtin"):
+            if key in redact and not isinstance(redact[key], bool):
+                problems.append(f"'redact.{key}' must be true or false")
+        config.redact = redact.get("enabled", True) is True
+        config.redact_builtin = redact.get("builtin", True) is not False
+        patterns = redact.get("patterns", [])
+        if isinstance(patterns, str):
+            patterns = [patterns]
+        if not isinstance(patterns, list) or not all(isinstance(p, str) and p for p in patterns):
+            problems.append("'redact.patterns' must be a regex or a list of regexes")
+            patterns = []
+        for pattern in patterns:
+            try:
+                re.compile(pattern)
+            except re.error as exc:
+                problems.append(f"'redact.patterns': invalid regex {pattern!r}: {exc}")
+            else:
+                config.redact_patterns.append(pattern)
+
+    if problems:
+        raise ConfigError(path, problems)
+    return config
+
+
+def load_config(path: Path, format_commands: Optional[Dict[str, Iterable[str]]] = None) -> ProjectConfig:
+    """Read and validate a YAML config file."""
+    path = Path(path)
+    try:
+        data = yaml.safe_load(path.read_text(encoding="utf-8"))
+    except yaml.YAMLError as exc:
+        raise ConfigError(path, [f"invalid YAML: {exc}"]) from exc
+    return parse_config(data, path, format_commands)
+
+
+__all__ = [
+    "CONFIG_NAMES",
+    "ConfigAnalyzer",
+    "ConfigError",
+    "ConfigQuery",
+    "ProjectConfig",
+    "find_config",
+    "language_overrides",
+    "load_config",
+    "parse_config",
+]
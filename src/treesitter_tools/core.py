@@ -2,16 +2,27 @@
 
 from __future__ import annotations
 
+import bisect
+import inspect
 import json
 import os
 import fnmatch
+import hashlib
+import re
+import threading
 from dataclasses import dataclass
 from pathlib import Path
-from typing import Iterable, List, Optional, Sequence
+from typing import Collection, Dict, Iterable, Iterator, List, Optional, Sequence, Tuple
 
 from tree_sitter import Language, Node, Parser, Query, QueryCursor
 import tree_sitter_language_pack as tlp
 
+from . import charsets, failures, generated, ignore, overlay, preproc, redact, schema
+from .detect import detect_file
+from .failures import PARSE_FAILURE, BinaryFileError, GrammarUnavailableError, NotSourceError, category_of
+from .languages import BUILTIN_SPECS, LanguageSpec
+from .memory import check_file_size, parse_mapped, read_source
+
 LANGUAGE_MAPPINGS = {
     # Scripting & shells
     "py": "python",
@@ -31,10 +42,12 @@ LANGUAGE_MAPPINGS = {
     "js": "javascript",
     "jsx": "javascript",
     "ts": "typescript",
-    "tsx": "typescript",
+    "tsx": "tsx",
     "css": "css",
     "scss": "scss",
     "html": "html",
+    "htm": "html",
+    "gohtml": "html",  # Go html/template sources
     # Systems / compiled
     "c": "c",
     "h": "c",
@@ -68,6 +81,7 @@ LANGUAGE_MAPPINGS = {
     "toml": "toml",
     "ini": "ini",
     "md": "markdown",
+    "markdown": "markdown",
     "xml": "xml",
     "sql": "sql",
     # Misc
@@ -92,12 +106,9 @@ DEFAULT_FUNCTION_NODE_TYPES = {
     "impl_item",
 }
 
+# Python, JavaScript, TypeScript/TSX, and Rust come from `languages.BUILTIN_SPECS`.
 FUNCTION_NODE_TYPES = {
-    "python": {"function_definition", "async_function_definition", "decorated_definition"},
-    "javascript": {"function_declaration", "method_definition", "arrow_function"},
-    "typescript": {"function_declaration", "method_definition", "generator_function_declaration"},
     "go": {"function_declaration", "method_declaration"},
-    "rust": {"function_item"},  # Removed impl_item to avoid duplication with classes
     "java": {"method_declaration"},
     "kotlin": {"function_declaration"},
     "php": {"function_definition", "method_declaration"},
@@ -111,32 +122,62 @@ FUNCTION_NODE_TYPES = {
 }
 
 CLASS_NODE_TYPES = {
-    "python": {"class_definition", "decorated_definition"},
-    "javascript": {"class_declaration"},
-    "typescript": {"class_declaration", "interface_declaration"},
     "java": {"class_declaration", "interface_declaration"},
     "kotlin": {"class_declaration"},
     "php": {"class_declaration"},
     "go": {"type_spec"},  # Captures type Foo struct { ... }
     "c": {"struct_specifier"},  # Note: C structs are data-only, no methods
-    "rust": {"struct_item", "impl_item"},
     "cpp": {"class_specifier", "struct_specifier"},
     "objc": {"class_declaration"},
     "csharp": {"class_declaration", "interface_declaration"},
     "scala": {"class_definition", "trait_definition"},
 }
 
+# Lambdas, closures, and function expressions, which `iter_function_nodes(closures=True)`
+# and `extract_symbols` report as functions of their own. Python, JavaScript,
+# TypeScript/TSX, and Rust come from `languages.BUILTIN_SPECS`.
+CLOSURE_NODE_TYPES = {
+    "go": {"func_literal"},
+    "java": {"lambda_expression"},
+    "kotlin": {"lambda_literal", "anonymous_function"},
+    "php": {"anonymous_function", "anonymous_function_creation_expression", "arrow_function"},
+    "cpp": {"lambda_expression"},
+    "csharp": {"lambda_expression", "anonymous_method_expression"},
+    "scala": {"lambda_expression"},
+}
+
+# Synthetic names of anonymous functions are `<parent>$anon<N>` (`$anon<N>` at top level).
+ANONYMOUS_SUFFIX = "$anon"
+
 NAME_NODE_TYPES = {"identifier", "name", "property_identifier", "type_identifier"}
 
+# Top-level nodes that declare the package/module or pull in dependencies.
+IMPORT_NODE_TYPES = {
+    "go": {"package_clause", "import_declaration"},
+    "java": {"package_declaration", "import_declaration"},
+    "kotlin": {"package_header", "import_list"},
+    "scala": {"package_clause", "import_declaration"},
+    "c": {"preproc_include"},
+    "cpp": {"preproc_include", "using_declaration"},
+    "objc": {"preproc_include", "preproc_import"},
+    "csharp": {"using_directive"},
+    "php": {"namespace_definition", "namespace_use_declaration"},
+    "ruby": set(),
+}
+
 
 def is_binary_file(path: Path) -> bool:
-    """Check if file is binary by looking for NUL bytes in first 8KB."""
-    try:
-        with path.open("rb") as f:
-            chunk = f.read(8192)
-            return b"\x00" in chunk
-    except OSError:
-        return False
+    """Check if file is binary by looking for NUL bytes in first 8KB (UTF-16 and UTF-32 text is not)."""
+    overlaid = overlay.contents(path)
+    if overlaid is not None:
+        chunk = overlaid[:8192]
+    else:
+        try:
+            with path.open("rb") as f:
+                chunk = f.read(8192)
+        except OSError:
+            return False
+    return b"\x00" in chunk and charsets.wide_encoding(chunk) is None
 @dataclass
 class CodeSymbol:
     kind: str
@@ -146,22 +187,90 @@ class CodeSymbol:
     signature: Optional[str]
     docstring: Optional[str]
     content: Optional[str] = None
+    # Normalized leading doc comment / docstring, and a same-line trailing comment
+    doc: Optional[str] = None
+    trailing_comment: Optional[str] = None
+    # Set by token budgeting when the body was "truncated" or "omitted"
+    elided: Optional[str] = None
+    # Set by `--since` when the symbol was "added" or "modified" relative to the base ref
+    change: Optional[str] = None
     # Chunking fields
     chunk_index: Optional[int] = None
     chunk_count: Optional[int] = None
     parent_symbol: Optional[str] = None
     overflow: Optional[bool] = None
+    # Set for symbols of embedded code (a function in an HTML <script>), to its language
+    language: Optional[str] = None
+    # Functions only: `body_hash` of the whole function (shared by its chunks)
+    body_hash: Optional[str] = None
+    # Set by `normalize.normalize_symbols` (the cross-language schema; `kind` is then one of its KINDS)
+    qualified_name: Optional[str] = None
+    container: Optional[List[str]] = None
+    visibility: Optional[str] = None
+    # Go generics: `{"name", "constraint"}` per type parameter, and the instantiated
+    # generics (`{"name", "qualifier", "type_arguments"}`) its signature or type uses
+    type_parameters: Optional[List[Dict[str, Optional[str]]]] = None
+    instantiations: Optional[List[dict]] = None
+    # Closures only: the qualified name of the enclosing function, and the variables of
+    # enclosing functions the body uses (languages with scope rules only)
+    parent: Optional[str] = None
+    captures: Optional[List[str]] = None
+    # Set by `--summarize` (see the `summarize` module): a one-line description of a function
+    summary: Optional[str] = None
+    # Symbols of code in documents (see `documents`): the code block's index in the
+    # Markdown file or notebook, and the notebook cell's id
+    block: Optional[int] = None
+    cell_id: Optional[str] = None
+    # Set by directory scans and `symbols` (see `assign_symbol_ids`): stable across edits elsewhere in the file
+    id: Optional[str] = None
+    # C/C++ with `--preprocessor` (see `preproc`): the `#if` condition guarding the declaration
+    condition: Optional[str] = None
 
     def to_dict(self) -> dict:
         data = {
+            **({"id": self.id} if self.id else {}),
             "kind": self.kind,
             "name": self.name,
             "start_line": self.start_line,
             "end_line": self.end_line,
             "signature": self.signature,
             "docstring": self.docstring,
+            "doc": self.doc,
+            "trailing_comment": self.trailing_comment,
             "content": self.content,
         }
+        if self.elided:
+            data["elided"] = self.elided
+        if self.change:
+            data["change"] = self.change
+        if self.language:
+            data["language"] = self.language
+        if self.body_hash:
+            data["body_hash"] = self.body_hash
+        if self.type_parameters:
+            data["type_parameters"] = self.type_parameters
+        if self.instantiations:
+            data["instantiations"] = self.instantiations
+        if self.parent:
+            data["parent"] = self.parent
+        if self.captures:
+            data["captures"] = self.captures
+        if self.summary:
+            data["summary"] = self.summary
+        if self.block is not None:
+            data["block"] = self.block
+        if self.cell_id:
+            data["cell_id"] = self.cell_id
+        if self.condition:
+            data["condition"] = self.condition
+        if self.qualified_name is not None:
+            data.update({
+                "qualified_name": self.qualified_name,
+                "container": self.container or [],
+                "visibility": self.visibility,
+            })
+        elif self.visibility:
+            data["visibility"] = self.visibility  # `--visibility` without `--normalize`
         if self.overflow:
             data.update({
                 "chunk_index": self.chunk_index,
@@ -171,30 +280,100 @@ class CodeSymbol:
             })
         return data
 
+    @classmethod
+    def from_dict(cls, data: dict) -> "CodeSymbol":
+        """Rebuild a symbol from its `to_dict` payload (used by on-disk caches)."""
+        return cls(
+            kind=data["kind"],
+            name=data["name"],
+            start_line=data["start_line"],
+            end_line=data["end_line"],
+            signature=data.get("signature"),
+            docstring=data.get("docstring"),
+            content=data.get("content"),
+            doc=data.get("doc"),
+            trailing_comment=data.get("trailing_comment"),
+            elided=data.get("elided"),
+            change=data.get("change"),
+            chunk_index=data.get("chunk_index"),
+            chunk_count=data.get("chunk_count"),
+            parent_symbol=data.get("parent_symbol"),
+            overflow=data.get("overflow"),
+            language=data.get("language"),
+            body_hash=data.get("body_hash"),
+            type_parameters=data.get("type_parameters"),
+            instantiations=data.get("instantiations"),
+            parent=data.get("parent"),
+            captures=data.get("captures"),
+            summary=data.get("summary"),
+            block=data.get("block"),
+            cell_id=data.get("cell_id"),
+            id=data.get("id"),
+            condition=data.get("condition"),
+            qualified_name=data.get("qualified_name"),
+            container=data.get("container"),
+            visibility=data.get("visibility"),
+        )
+
 
 def detect_language(path: Path, override: Optional[str] = None) -> Optional[str]:
+    """
+    `override`, else the language of `path`'s extension, else one detected from its
+    name or content (shebang, modeline, ...; see the `detect` package).
+    """
     if override:
         return override
-    ext = path.suffix.lstrip(".").lower()
-    return LANGUAGE_MAPPINGS.get(ext)
-PARSER_CACHE = {}
+    detection = detect_file(path, LANGUAGE_MAPPINGS)
+    return detection.language if detection is not None else None
+
+
+def source_language(path: Path, language: Optional[str] = None) -> str:
+    """
+    The language to parse `path` as (`language`, else the detected one). Raises
+    BinaryFileError for a binary file in a known language and NotSourceError for a
+    file that is not source code: no language detected, binary or not.
+    """
+    detected = detect_language(path, language)
+    if is_binary_file(path):
+        raise (BinaryFileError if detected else NotSourceError)(f"Refusing to parse binary file: {path}")
+    if not detected:
+        raise NotSourceError(f"Cannot detect Tree-sitter language for {path}")
+    return detected
+
+
+# Each thread's parsers by language: one `Parser` must not parse in two threads at once, and
+# thread-local storage frees a thread's parsers when it exits (pool threads come and go).
+_PARSERS = threading.local()
+# Bumped by `register_language`, so every thread replaces its parser for that language.
+_GRAMMAR_GENERATIONS: Dict[str, int] = {}
 
 def load_language(language: str) -> Language:
+    spec = LANGUAGE_SPECS.get(language)
     try:
-        return tlp.get_language(language)
+        if spec is not None and spec.loader is not None:
+            return spec.loader()
+        return tlp.get_language(spec.grammar if spec is not None and spec.grammar else language)
     except Exception as exc:  # pragma: no cover - pass through message
-        raise RuntimeError(f"Tree-sitter grammar for '{language}' is unavailable: {exc}") from exc
+        raise GrammarUnavailableError(f"Tree-sitter grammar for '{language}' is unavailable: {exc}") from exc
+
+
+def get_parser(language: str) -> Parser:
+    """The calling thread's parser for `language`, so every function here is safe to call from several threads."""
+    parsers: Optional[Dict[str, Tuple[int, Parser]]] = getattr(_PARSERS, "by_language", None)
+    if parsers is None:
+        parsers = _PARSERS.by_language = {}
+    generation = _GRAMMAR_GENERATIONS.get(language, 0)
+    cached = parsers.get(language)
+    if cached is not None and cached[0] == generation:
+        return cached[1]
+    parser = Parser()
+    parser.language = load_language(language)
+    parsers[language] = (generation, parser)
+    return parser
 
 
 def parse_source(source: bytes, language: str) -> Node:
-    if language not in PARSER_CACHE:
-        parser = Parser()
-        parser.language = load_language(language)
-        PARSER_CACHE[language] = parser
-    else:
-        parser = PARSER_CACHE[language]
-    
-    tree = parser.parse(source)
+    tree = get_parser(language).parse(source)
     return tree.root_node
 
 
@@ -202,19 +381,89 @@ def _node_text(node: Node, source: bytes) -> str:
     return source[node.start_byte : node.end_byte].decode("utf-8", "replace")
 
 
-def _identifier_from(node: Node, source: bytes) -> Optional[str]:
+def _identifier_node(node: Node) -> Optional[Node]:
     field = node.child_by_field_name("name")
     if field is not None:
-        return _node_text(field, source)
+        return field
     if node.type in NAME_NODE_TYPES:
-        return _node_text(node, source)
+        return node
     for child in node.children:
-        name = _identifier_from(child, source)
-        if name:
+        name = _identifier_node(child)
+        if name is not None:
             return name
     return None
 
 
+def _identifier_from(node: Node, source: bytes) -> Optional[str]:
+    name = _identifier_node(node)
+    return _node_text(name, source) if name is not None else None
+
+
+def body_hash(node: Node, source: bytes, name_node: Optional[Node] = None) -> str:
+    """
+    Hash of `node`'s tokens without its name, comments, or layout: unchanged when a
+    function is renamed, moved, re-indented, or re-commented. `name_node` is where
+    the name is looked up (the definition inside a decorated node).
+    """
+    name = _identifier_node(name_node or node)
+    skip = name.id if name is not None else None
+    digest = hashlib.blake2b(digest_size=16)
+    stack = [node]
+    while stack:
+        current = stack.pop()
+        if current.id == skip or "comment" in current.type:
+            continue
+        if current.child_count == 0:
+            digest.update(source[current.start_byte : current.end_byte])
+            digest.update(b"\0")  # keeps `a b` and `ab` apart
+            continue
+        stack.extend(reversed(current.children))
+    return digest.hexdigest()
+
+
+def _layout_free(content: str) -> str:
+    """`content` without indentation, trailing whitespace, or blank lines."""
+    return "\n".join(line.strip() for line in content.splitlines() if line.strip())
+
+
+def content_id(path: str, kind: str, name: str, content: str, part: Optional[int] = None, occurrence: int = 0) -> str:
+    """
+    Stable ID of one symbol or chunk: a hash of its file, kind, qualified name, part, and
+    content with layout normalized, never its offsets. Editing, adding, or removing another
+    declaration leaves it unchanged, and so does re-indenting this one. `occurrence`
+    tells identical declarations of one file apart.
+    """
+    digest = hashlib.blake2b(digest_size=16)
+    for value in (path, kind, name, "" if part is None else str(part), str(occurrence), _layout_free(content)):
+        digest.update(value.encode("utf-8"))
+        digest.update(b"\0")
+    return digest.hexdigest()
+
+
+def _enclosing_names(symbols: Sequence[CodeSymbol]) -> List[str]:
+    """Per symbol, its name qualified by the declarations whose lines contain it (`Cache.get`)."""
+    names: List[str] = [""] * len(symbols)
+    stack: List[CodeSymbol] = []
+    for i in sorted(range(len(symbols)), key=lambda i: (symbols[i].start_line, -symbols[i].end_line)):
+        symbol = symbols[i]
+        span = (symbol.start_line, symbol.end_line)
+        while stack and (stack[-1].end_line < symbol.end_line or (stack[-1].start_line, stack[-1].end_line) == span):
+            stack.pop()
+        names[i] = ".".join([*(o.name for o in stack), symbol.name])
+        if not symbol.overflow:  # the pieces of a split function contain nothing
+            stack.append(symbol)
+    return names
+
+
+def assign_symbol_ids(symbols: Sequence[CodeSymbol], path: str) -> None:
+    """Set `id` (see `content_id`) on the symbols of the file labelled `path`."""
+    seen: Dict[tuple, int] = {}
+    for symbol, name in zip(symbols, _enclosing_names(symbols)):
+        key = (symbol.kind, name, symbol.chunk_index, _layout_free(symbol.content or ""))
+        occurrence = seen[key] = seen.get(key, -1) + 1
+        symbol.id = content_id(path, symbol.kind, name, symbol.content or "", symbol.chunk_index, occurrence)
+
+
 def _python_docstring(node: Node, source: bytes) -> Optional[str]:
     """Extract Python docstring from a function or class node."""
     if not node.children:
@@ -312,17 +561,206 @@ def _rust_doc_comment(node: Node, source: bytes, root: Node) -> Optional[str]:
 
 def _extract_docstring(node: Node, source: bytes, language: str, root: Node) -> Optional[str]:
     """Extract documentation string/comment for a node based on language."""
-    if language == "python":
+    spec = LANGUAGE_SPECS.get(language)
+    style = spec.doc_style if spec is not None else None
+    if style == "docstring" or language == "python":
         return _python_docstring(node, source)
-    elif language == "rust":
+    elif style == "rust_doc" or language == "rust":
         return _rust_doc_comment(node, source, root)
-    elif language in {"javascript", "typescript", "java", "go", "c", "cpp", "php"}:
+    elif style == "leading_comment" or language in {"javascript", "typescript", "java", "go", "c", "cpp", "php"}:
         return _extract_comment_before_node(node, source, root)
     
     return None
 
 
-def _signature_snippet(node: Node, source: bytes) -> str:
+# Wrappers whose leading comments document the declaration inside them.
+DOC_WRAPPER_TYPES = {
+    "decorated_definition",
+    "export_statement",
+    "type_declaration",
+    "const_declaration",
+    "var_declaration",
+    "lexical_declaration",
+    "variable_declaration",
+    "variable_declarator",
+    "field_declaration",
+}
+# Nodes allowed between a doc comment and its declaration (Rust attributes, Java/C# annotations).
+_DOC_SKIP_TYPES = {"attribute_item", "attribute", "annotation", "marker_annotation", "attribute_list", "decorator"}
+_LINE_COMMENT_PREFIXES = ("///", "//!", "//", "#", "--", ";")
+
+
+def _end_row(node: Node) -> int:
+    # Some grammars include the trailing newline in line comments.
+    row, column = node.end_point
+    return row - 1 if column == 0 and row > node.start_point[0] else row
+
+
+def normalize_comment(text: str) -> str:
+    """Strip comment markers (`//`, `#`, `/** */`, leading `*`) and common indentation."""
+    text = text.strip()
+    if text.startswith("/*"):
+        text = text[3:] if text.startswith("/**") or text.startswith("/*!") else text[2:]
+        if text.endswith("*/"):
+            text = text[:-2]
+        lines = []
+        for line in text.splitlines():
+            stripped = line.strip()
+            if stripped.startswith("*"):
+                stripped = stripped[1:]
+                line = stripped[1:] if stripped.startswith(" ") else stripped
+            lines.append(line.rstrip())
+    else:
+        lines = []
+        for line in text.splitlines():
+            stripped = line.strip()
+            for prefix in _LINE_COMMENT_PREFIXES:
+                if stripped.startswith(prefix):
+                    stripped = stripped[len(prefix):]
+                    break
+            lines.append(stripped.rstrip())
+    return inspect.cleandoc("\n".join(lines))
+
+
+def _is_doc_comment(node: Node, source: bytes, language: str) -> bool:
+    if "comment" not in node.type:
+        return False
+    spec = LANGUAGE_SPECS.get(language)
+    if (spec.doc_style if spec is not None else None) == "rust_doc" or language == "rust":
+        text = _node_text(node, source)
+        return (text.startswith("///") and not text.startswith("////")) or (
+            text.startswith("/**") and not text.startswith("/**/")
+        )
+    return True
+
+
+def _comment_block_before(node: Node, source: bytes, language: str) -> List[Node]:
+    """Adjacent comments directly above `node` (no blank lines, not trailing other code)."""
+    comments: List[Node] = []
+    top = node.start_point[0]
+    sibling = node.prev_sibling
+    while sibling is not None and sibling.type in _DOC_SKIP_TYPES:
+        top = sibling.start_point[0]
+        sibling = sibling.prev_sibling
+    while sibling is not None and _is_doc_comment(sibling, source, language):
+        if top - _end_row(sibling) > 1:
+            break
+        before = sibling.prev_sibling
+        if before is not None and _end_row(before) == sibling.start_point[0] and "comment" not in before.type:
+            break  # trailing comment of the previous statement
+        comments.insert(0, sibling)
+        top = sibling.start_point[0]
+        sibling = before
+    return comments
+
+
+def leading_comments(node: Node, source: bytes, language: str) -> List[Node]:
+    """Comment nodes documenting `node`, looking through export/decorator/declaration wrappers."""
+    anchor = node
+    while True:
+        comments = _comment_block_before(anchor, source, language)
+        if comments:
+            return comments
+        parent = anchor.parent
+        if parent is None or parent.type not in DOC_WRAPPER_TYPES:
+            return []
+        anchor = parent
+
+
+def trailing_comment(node: Node, source: bytes) -> Optional[str]:
+    """Comment on the same line as the declaration's header or its last line."""
+    row = node.start_point[0]
+    current = node
+    while current is not None:
+        found = None
+        for child in current.children:
+            if child.start_point[0] > row:
+                break
+            if "comment" in child.type and child.start_point[0] == row and child.start_byte > node.start_byte:
+                return normalize_comment(_node_text(child, source)) or None
+            if child.start_point[0] <= row <= child.end_point[0] and child.child_count:
+                found = child
+        current = found
+    anchor = node
+    while anchor is not None:
+        sibling = anchor.next_sibling
+        if sibling is not None and "comment" in sibling.type and sibling.start_point[0] == _end_row(node):
+            return normalize_comment(_node_text(sibling, source)) or None
+        if anchor.parent is None or anchor.parent.type not in DOC_WRAPPER_TYPES:
+            break
+        anchor = anchor.parent
+    return None
+
+
+def symbol_doc(node: Node, source: bytes, language: str, definition: Optional[Node] = None) -> Optional[str]:
+    """
+    Normalized documentation for a declaration: the docstring for Python (falling back
+    to `#` comments above it), otherwise the doc comment block directly above it
+    (Go `//` blocks, JSDoc/Javadoc `/** */`, Rust `///`).
+    """
+    spec = LANGUAGE_SPECS.get(language)
+    if (spec.doc_style if spec is not None else None) == "docstring" or language == "python":
+        docstring = _python_docstring_node(definition or node)
+        if docstring is not None:
+            text = _node_text(docstring, source)
+            return _clean_docstring(text)
+    comments = leading_comments(node, source, language)
+    if not comments:
+        return None
+    return "\n".join(normalize_comment(_node_text(c, source)) for c in comments).strip() or None
+
+
+def _python_docstring_node(node: Node) -> Optional[Node]:
+    body = node.child_by_field_name("body")
+    if body is None or not body.named_children:
+        return None
+    first = body.named_children[0]
+    if first.type == "expression_statement" and first.named_children:
+        first = first.named_children[0]
+    return first if first.type in {"string", "string_literal", "concatenated_string"} else None
+
+
+def _clean_docstring(text: str) -> Optional[str]:
+    text = text.strip()
+    while text[:1].lower() in {"r", "u", "b", "f"}:
+        text = text[1:]
+    for quote in ('"""', "'''", '"', "'"):
+        if text.startswith(quote) and text.endswith(quote) and len(text) >= 2 * len(quote):
+            text = text[len(quote):-len(quote)]
+            break
+    return inspect.cleandoc(text) or None
+
+
+def _collapse_whitespace(text: str) -> str:
+    """`text` on one line, without the spaces a line break left inside brackets or a trailing comma."""
+    text = " ".join(text.split())
+    text = re.sub(r"([(\[]) ", r"\1", text)
+    return re.sub(r",? ([)\]])", r"\1", text)
+
+
+def _go_header(node: Node, source: bytes) -> Optional[str]:
+    """
+    A Go declaration whose header spans lines (a type parameter or parameter list
+    broken up, one entry per line) joined onto one line, followed by the first line of
+    the body or type; None when the header already fits on its first line.
+    """
+    split = node.child_by_field_name("type" if node.type == "type_spec" else "body")
+    end = split.start_byte if split is not None else node.end_byte
+    header = source[node.start_byte:end].decode("utf-8", "replace")
+    if "\n" not in header.strip():
+        return None
+    signature = _collapse_whitespace(header)
+    if split is not None:
+        rest = _node_text(split, source).splitlines()
+        signature += " " + rest[0].strip() + (" ..." if len(rest) > 1 else "")
+    return signature
+
+
+def _signature_snippet(node: Node, source: bytes, language: Optional[str] = None) -> str:
+    if language == "go" and node.type in {"function_declaration", "method_declaration", "type_spec"}:
+        header = _go_header(node, source)
+        if header is not None:
+            return header
     lines = _node_text(node, source).splitlines()
     if not lines:
         return ""
@@ -332,19 +770,63 @@ def _signature_snippet(node: Node, source: bytes) -> str:
     return first.strip()
 
 
-def extract_symbols(path: Path, language: Optional[str] = None, max_chunk_size: Optional[int] = None) -> List[CodeSymbol]:
+def extract_symbols(
+    path: Path, language: Optional[str] = None, max_chunk_size: Optional[int] = None,
+    max_file_size: Optional[int] = None,
+) -> List[CodeSymbol]:
+    """
+    Symbols of one file. Large files are memory-mapped rather than read (see
+    `memory.read_source`), and files over `max_file_size` bytes are refused.
+    """
+    return extract_with_encoding(path, language, max_chunk_size, max_file_size)[0]
+
+
+def extract_with_encoding(
+    path: Path, language: Optional[str] = None, max_chunk_size: Optional[int] = None,
+    max_file_size: Optional[int] = None,
+) -> Tuple[List[CodeSymbol], str]:
+    """`extract_symbols` and the encoding the file was read as (see `charsets`)."""
     path = Path(path)
-    if is_binary_file(path):
-        raise ValueError(f"Refusing to parse binary file: {path}")
+    check_file_size(path, max_file_size)
+    language = source_language(path, language)
+    with read_source(path) as decoded:
+        source = decoded.data
+        tree = parse_mapped(get_parser(language), source)
+        symbols = symbols_from_tree(tree.root_node, source, language, max_chunk_size)
+        # Symbols hold copies of their text, so the tree (often larger than the file) can go now.
+        del tree
+    return symbols, decoded.encoding
 
-    language = detect_language(path, language)
-    if not language:
-        raise ValueError(f"Cannot detect Tree-sitter language for {path}")
-    source = path.read_bytes()
-    root = parse_source(source, language)
+
+def symbols_from_tree(
+    root: Node, source: bytes, language: str, max_chunk_size: Optional[int] = None, injections: bool = True
+) -> List[CodeSymbol]:
+    """
+    Extract symbols from an already-parsed tree (shared by file and session parsing).
+    Symbols of embedded code (see `injections`) follow the host's, tagged with their language;
+    Markdown and notebook documents yield the symbols of their code blocks (see `documents`),
+    and C/C++ with `--preprocessor` those of every `#if` branch (see `preproc`).
+    """
+    if preproc.ENABLED and language in preproc.LANGUAGES:
+        branched = preproc.conditional_symbols(source, language, max_chunk_size)
+        if branched is not None:
+            return branched
+    source = redact.apply(source, root)
+    if language in ("markdown", "notebook"):
+        from .documents import document_symbols
+
+        return document_symbols(root, source, language, max_chunk_size)
     symbols: List[CodeSymbol] = []
     func_nodes = FUNCTION_NODE_TYPES.get(language, DEFAULT_FUNCTION_NODE_TYPES)
     class_nodes = CLASS_NODE_TYPES.get(language, set())
+    closure_nodes = CLOSURE_NODE_TYPES.get(language, set())
+    # Closures take their names from the same walk as `iter_function_nodes`.
+    closures: Dict[int, FunctionNode] = {}
+    captures: Dict[int, List[str]] = {}
+    if closure_nodes:
+        functions = [fn for fn in _walk_functions(root, source, language, True) if fn.closure]
+        closures = {fn.node.id: fn for fn in functions}
+        captures = closure_captures(ParsedFile(Path(), language, source, root), functions)
 
     # Track visited nodes to avoid duplicates (e.g. decorated function inside decorated_definition)
     visited_nodes = set()
@@ -382,6 +864,8 @@ def extract_symbols(path: Path, language: Optional[str] = None, max_chunk_size:
             process_node(node, "function")
         elif node.type in class_nodes:
             process_node(node, "class")
+        elif node.type in closure_nodes:
+            process_node(node, "function")
         
         visited_nodes.add(node.id)
         for child in node.children:
@@ -395,14 +879,26 @@ def extract_symbols(path: Path, language: Optional[str] = None, max_chunk_size:
                 name = _node_text(name_node, source)
             else:
                 name = "<anonymous>"
+        elif node.id in closures:
+            name = closures[node.id].name
         else:
             # Use the node itself or a specific child for name extraction
             target = name_source_node or node
             name = _identifier_from(target, source) or "<anonymous>"
         
         doc = _extract_docstring(node, source, language, root)
+        normalized_doc = symbol_doc(node, source, language, name_source_node)
+        trailing = trailing_comment(node, source)
         content = _node_text(node, source)
-        signature = _signature_snippet(node, source)
+        signature = _signature_snippet(node, source, language)
+        digest = body_hash(node, source, name_source_node) if kind == "function" else None
+        type_parameters = instantiations = None
+        if language == "go":
+            type_parameters = go_type_parameters(node, source) or None
+            instantiations = [i.to_dict() for i in go_signature_instantiations(node, source)] or None
+        closure = closures.get(node.id)
+        parent = closure.parent if closure is not None else None
+        captured = captures.get(node.id)
         start_line = node.start_point[0] + 1
         end_line = node.end_point[0] + 1
 
@@ -458,10 +954,17 @@ def extract_symbols(path: Path, language: Optional[str] = None, max_chunk_size:
                         signature=signature, # Copy signature to all chunks
                         docstring=doc, # Copy docstring to all chunks
                         content=chunk["content"],
+                        doc=normalized_doc,
+                        trailing_comment=trailing,
                         chunk_index=i,
                         chunk_count=len(chunks),
                         parent_symbol=name,
-                        overflow=True
+                        overflow=True,
+                        body_hash=digest,
+                        type_parameters=type_parameters,
+                        instantiations=instantiations,
+                        parent=parent,
+                        captures=captured,
                     )
                 )
         else:
@@ -475,10 +978,24 @@ def extract_symbols(path: Path, language: Optional[str] = None, max_chunk_size:
                     signature=signature,
                     docstring=doc,
                     content=content,
+                    doc=normalized_doc,
+                    trailing_comment=trailing,
+                    body_hash=digest,
+                    type_parameters=type_parameters,
+                    instantiations=instantiations,
+                    parent=parent,
+                    captures=captured,
                 )
             )
 
     visit(root)
+    if injections:
+        from .injections import injected_trees
+
+        for embedded_language, embedded in injected_trees(root, source, language):
+            for symbol in symbols_from_tree(embedded, source, embedded_language, max_chunk_size, injections=False):
+                symbol.language = embedded_language
+                symbols.append(symbol)
     return symbols
 
 
@@ -486,11 +1003,14 @@ def run_query(path: Path, query: str, language: Optional[str] = None) -> List[di
     path = Path(path)
     language = detect_language(path, language)
     if not language:
-        raise ValueError(f"Cannot detect Tree-sitter language for {path}")
-    source = path.read_bytes()
-    language_obj = load_language(language)
+        raise NotSourceError(f"Cannot detect Tree-sitter language for {path}")
+    source = overlay.read_bytes(path)
     root = parse_source(source, language)
-    ts_query = Query(language_obj, query)
+    return query_tree(root, redact.apply(source, root), Query(load_language(language), query))
+
+
+def query_tree(root: Node, source: bytes, ts_query: Query) -> List[dict]:
+    """Run a compiled query over an already-parsed tree (shared by `run_query` and the server)."""
     cursor = QueryCursor(ts_query)
     results: List[dict] = []
     for pattern_index, captures in cursor.matches(root):
@@ -500,9 +1020,14 @@ def run_query(path: Path, query: str, language: Optional[str] = None) -> List[di
                 capture_items.append(
                     {
                         "name": name,
+                        "type": node.type,
                         "text": _node_text(node, source),
                         "start_line": node.start_point[0] + 1,
                         "end_line": node.end_point[0] + 1,
+                        "start_column": node.start_point[1] + 1,
+                        "end_column": node.end_point[1] + 1,
+                        "start_byte": node.start_byte,
+                        "end_byte": node.end_byte,
                     }
                 )
         results.append({"pattern_index": pattern_index, "captures": capture_items})
@@ -510,7 +1035,7 @@ def run_query(path: Path, query: str, language: Optional[str] = None) -> List[di
 
 
 def symbols_to_json(symbols: Iterable[CodeSymbol]) -> str:
-    return json.dumps([sym.to_dict() for sym in symbols], indent=2)
+    return json.dumps(schema.conform("symbol", [sym.to_dict() for sym in symbols]), indent=2)
 
 
 def _match_any(patterns: Sequence[str], rel_path: str) -> bool:
@@ -526,56 +1051,671 @@ def _match_any(patterns: Sequence[str], rel_path: str) -> bool:
     return False
 
 
-def scan_directory(
+def iter_source_files(
     root: Path,
     include: Sequence[str] | None = None,
     exclude: Sequence[str] | None = None,
-    max_chunk_size: Optional[int] = None,
-) -> List[FileSymbols]:
-    root = root.resolve()
+    only: Optional[Collection[Path]] = None,
+    skip: Optional[Collection[Path]] = None,
+) -> Iterator[Path]:
+    """
+    Yield files under `root` matching the include/exclude globs, in sorted order.
+    `only` (resolved absolute paths, e.g. files changed since a git ref) narrows the walk further
+    and `skip` (likewise, e.g. files a resumed job already finished) leaves paths out;
+    paths hidden by .gitignore/.tstoolsignore are skipped (see `ignore`), and generated and
+    vendored files are dropped when the `generated` skip flags are set.
+    """
+    root = Path(root).resolve()
     include = include or ["**/*"]
     exclude = exclude or []
-    reports: List[FileSymbols] = []
-    for path in root.rglob("*"):
-        if not path.is_file():
+    if only is not None:
+        candidates = ignore.filter_ignored(root, sorted(p for p in only if root in p.parents))
+    else:
+        candidates = ignore.walk_files(root)
+        added = overlay.added_under(root)  # unsaved new files
+        if added:
+            candidates = sorted([*candidates, *ignore.filter_ignored(root, added)])
+    for path in candidates:
+        if (skip and path in skip) or not overlay.is_file(path):
             continue
         rel = path.relative_to(root).as_posix()
         if not _match_any(include, rel):
             continue
         if exclude and _match_any(exclude, rel):
             continue
-        
-        try:
-            symbols = extract_symbols(path, max_chunk_size=max_chunk_size)
-            language = detect_language(path) or "unknown"
-            if symbols:
-                reports.append(FileSymbols(path=path, language=language, symbols=symbols))
-        except Exception as exc:
-            # Capture error in the report
-            reports.append(FileSymbols(path=path, language="unknown", symbols=[], error=str(exc)))
+        if generated.skipped(path, rel):
             continue
-            
-    return reports
+        yield path
+
+
+@dataclass
+class ParsedFile:
+    """A source file together with its Tree-sitter root node."""
+
+    path: Path
+    language: str
+    source: bytes
+    root: Node
+
+    def text(self, node: Node) -> str:
+        return _node_text(node, self.source)
+
+
+# Implicit receiver names that refer to the enclosing type inside a method body.
+RECEIVER_NAMES = {
+    "java": {"this"},
+    "cpp": {"this"},
+    "csharp": {"this"},
+    "php": {"$this"},
+}
+
+
+# Registered language specs; built-ins are applied below and can be replaced.
+LANGUAGE_SPECS: Dict[str, LanguageSpec] = {}
+
+
+def register_language(spec: LanguageSpec) -> None:
+    """Add or replace a language: its extensions and node tables apply to every command."""
+    LANGUAGE_SPECS[spec.name] = spec
+    for ext in spec.extensions:
+        LANGUAGE_MAPPINGS[ext.lstrip(".").lower()] = spec.name
+    FUNCTION_NODE_TYPES[spec.name] = set(spec.function_nodes)
+    CLASS_NODE_TYPES[spec.name] = set(spec.class_nodes)
+    IMPORT_NODE_TYPES[spec.name] = set(spec.import_nodes)
+    CLOSURE_NODE_TYPES[spec.name] = set(spec.closure_nodes)
+    if spec.receiver_names:
+        RECEIVER_NAMES[spec.name] = set(spec.receiver_names)
+    _GRAMMAR_GENERATIONS[spec.name] = _GRAMMAR_GENERATIONS.get(spec.name, 0) + 1
+
+
+def get_language_spec(language: str) -> Optional[LanguageSpec]:
+    return LANGUAGE_SPECS.get(language)
+
+
+for _spec in BUILTIN_SPECS:
+    register_language(_spec)
+
+
+@dataclass
+class FunctionNode:
+    """A function/method node plus the naming context needed to qualify it."""
+
+    node: Node
+    name: str
+    container: Optional[str] = None
+    receiver: Optional[str] = None
+    # Closures: set when the node is a lambda/closure, with the enclosing function's qualified name
+    closure: bool = False
+    parent: Optional[str] = None
+
+    @property
+    def qualified_name(self) -> str:
+        return f"{self.container}.{self.name}" if self.container else self.name
+
+    @property
+    def anonymous(self) -> bool:
+        return is_anonymous(self.name)
+
+
+def _go_receiver(node: Node, source: bytes) -> tuple[Optional[str], Optional[str]]:
+    """Return (receiver variable, receiver type) for a Go method declaration."""
+    receiver = node.child_by_field_name("receiver")
+    if receiver is None:
+        return None, None
+    for param in receiver.named_children:
+        if param.type != "parameter_declaration":
+            continue
+        name_node = param.child_by_field_name("name")
+        type_node = param.child_by_field_name("type")
+        type_name = None
+        if type_node is not None:
+            type_name = _node_text(type_node, source).lstrip("*").split("[")[0].strip()
+        return (_node_text(name_node, source) if name_node else None), type_name
+    return None, None
+
+
+GO_PREDECLARED_TYPES = {
+    "any", "bool", "byte", "comparable", "complex64", "complex128", "error", "float32", "float64", "int", "int8",
+    "int16", "int32", "int64", "rune", "string", "uint", "uint8", "uint16", "uint32", "uint64", "uintptr",
+}
+# Nodes that can only be types, so `f[X]` indexed by one of them is an instantiation, not an index.
+_GO_TYPE_NODES = {
+    "type_identifier", "generic_type", "qualified_type", "pointer_type", "slice_type", "array_type", "map_type",
+    "channel_type", "function_type", "interface_type", "struct_type",
+}
+
+
+@dataclass
+class GoInstantiation:
+    """A generic Go type or function used with type arguments: `List[int]`, `slices.Map[T, U](xs, f)`."""
+
+    name: str
+    qualifier: Optional[str]  # package of `pkg.List[int]`
+    type_arguments: List[str]
+    node: Node  # the node naming the generic
+
+    def to_dict(self) -> dict:
+        return {"name": self.name, "qualifier": self.qualifier, "type_arguments": self.type_arguments}
+
+
+def go_type_parameters(node: Node, source: bytes) -> List[Dict[str, Optional[str]]]:
+    """
+    `[{"name": "K", "constraint": "comparable"}, ...]` for a generic Go function or
+    type (`T, U any` gives both names the constraint). For a method on a generic type,
+    the receiver's parameter names, with no constraint (those are declared on the type).
+    """
+    found: List[Dict[str, Optional[str]]] = []
+    params = node.child_by_field_name("type_parameters")
+    if params is not None:
+        for decl in params.named_children:
+            if "comment" in decl.type:
+                continue
+            constraint = decl.child_by_field_name("type")
+            text = _collapse_whitespace(_node_text(constraint, source)) if constraint is not None else None
+            for name in decl.children_by_field_name("name"):
+                found.append({"name": _node_text(name, source), "constraint": text})
+        return found
+    receiver = node.child_by_field_name("receiver") if node.type == "method_declaration" else None
+    stack = [receiver] if receiver is not None else []
+    while stack:
+        current = stack.pop()
+        if current.type == "generic_type":
+            arguments = current.child_by_field_name("type_arguments")
+            for argument in arguments.named_children if arguments is not None else ():
+                found.append({"name": _collapse_whitespace(_node_text(argument, source)), "constraint": None})
+            break
+        stack.extend(reversed(current.named_children))
+    return found
+
+
+def _go_type_like(node: Node, source: bytes) -> bool:
+    if node.type in _GO_TYPE_NODES:
+        return True
+    # In expression position a type name parses as an identifier: trust predeclared and exported names.
+    text = _node_text(node, source)
+    return node.type == "identifier" and (text in GO_PREDECLARED_TYPES or text[:1].isupper())
+
+
+def _go_generic_name(node: Node, source: bytes) -> Optional[Tuple[Optional[str], Node]]:
+    """(package qualifier, name node) of `List`, `pkg.List`, or `pkg.Map` naming a generic."""
+    if node.type in {"identifier", "type_identifier"}:
+        return None, node
+    if node.type == "selector_expression":
+        operand, field = node.child_by_field_name("operand"), node.child_by_field_name("field")
+        if operand is not None and field is not None and operand.type == "identifier":
+            return _node_text(operand, source), field
+    if node.type == "qualified_type":
+        package, name = node.child_by_field_name("package"), node.child_by_field_name("name")
+        if package is not None and name is not None:
+            return _node_text(package, source), name
+    return None
+
+
+def _go_instantiation(node: Node, source: bytes) -> Optional[GoInstantiation]:
+    if node.type == "generic_type":
+        target, arguments = node.child_by_field_name("type"), [node.child_by_field_name("type_arguments")]
+    elif node.type == "call_expression" and node.child_by_field_name("type_arguments") is not None:
+        target, arguments = node.child_by_field_name("function"), [node.child_by_field_name("type_arguments")]
+        if target is not None and target.type == "generic_type":
+            return None  # reported by the generic_type itself
+    elif node.type == "index_expression":
+        target, index = node.child_by_field_name("operand"), node.child_by_field_name("index")
+        if index is None or not _go_type_like(index, source):
+            return None
+        arguments = [None]
+        type_arguments = [_collapse_whitespace(_node_text(index, source))]
+    else:
+        return None
+    named = _go_generic_name(target, source) if target is not None else None
+    if named is None:
+        return None
+    qualifier, name_node = named
+    if arguments[0] is not None:
+        type_arguments = [
+            _collapse_whitespace(_node_text(argument, source))
+            for argument in arguments[0].named_children
+            if "comment" not in argument.type
+        ]
+    return GoInstantiation(_node_text(name_node, source), qualifier, type_arguments, name_node)
+
+
+def iter_go_instantiations(node: Node, source: bytes, skip: Collection[int] = ()) -> Iterator[GoInstantiation]:
+    """Instantiations of generics under `node`, in source order; subtrees whose ids are in `skip` are not entered."""
+    stack = [node]
+    while stack:
+        current = stack.pop()
+        if current.id in skip:
+            continue
+        found = _go_instantiation(current, source)
+        if found is not None:
+            yield found
+        stack.extend(reversed(current.children))
+
+
+def go_signature_instantiations(node: Node, source: bytes) -> List[GoInstantiation]:
+    """
+    Instantiations in a Go declaration's signature: parameter, result, and constraint
+    types of a function (not its body or receiver), the whole type of a type_spec.
+    Each generic is listed once per distinct set of type arguments.
+    """
+    skip = {child.id for child in (node.child_by_field_name("body"), node.child_by_field_name("receiver")) if child}
+    found: List[GoInstantiation] = []
+    seen = set()
+    for instantiation in iter_go_instantiations(node, source, skip):
+        key = (instantiation.qualifier, instantiation.name, tuple(instantiation.type_arguments))
+        if key not in seen:
+            seen.add(key)
+            found.append(instantiation)
+    return found
+
+
+def _container_name(node: Node, source: bytes, language: str) -> Optional[str]:
+    class_nodes = CLASS_NODE_TYPES.get(language, set()) - {"decorated_definition"}
+    parent = node.parent
+    while parent is not None:
+        if parent.type in class_nodes:
+            if parent.type == "impl_item":
+                type_node = parent.child_by_field_name("type")
+                if type_node is not None:
+                    return _node_text(type_node, source).split("<")[0].strip()
+            return _identifier_from(parent, source)
+        parent = parent.parent
+    return None
+
+
+def is_anonymous(name: str) -> bool:
+    """True for `<anonymous>` and the synthetic `<parent>$anon<N>` names of closures."""
+    return name == "<anonymous>" or ANONYMOUS_SUFFIX in name
+
+
+def _go_binding_name(node: Node, source: bytes) -> Optional[str]:
+    """The variable a Go func literal is assigned to: `f := func() {}`, `var f = func() {}`."""
+    values = node.parent
+    statement = values.parent if values is not None else None
+    if statement is None or values.type != "expression_list":
+        return None
+    if statement.type in {"short_var_declaration", "assignment_statement"}:
+        left = statement.child_by_field_name("left")
+        names = left.named_children if left is not None and left.id != values.id else []
+    elif statement.type == "var_spec":
+        names = statement.children_by_field_name("name")
+    else:
+        return None
+    position = [v.id for v in values.named_children].index(node.id)
+    return _node_text(names[position], source) if position < len(names) else None
+
+
+def function_name(node: Node, source: bytes) -> str:
+    """Best-effort name for a function node, including `const f = () => {}` and `f = lambda: 0` bindings."""
+    field = node.child_by_field_name("name")
+    if field is not None:
+        return _node_text(field, source)
+    parent = node.parent
+    if parent is not None and parent.type in {
+        "variable_declarator", "assignment_expression", "pair", "assignment", "let_declaration"
+    }:
+        target = (
+            parent.child_by_field_name("name") or parent.child_by_field_name("left")
+            or parent.child_by_field_name("key") or parent.child_by_field_name("pattern")
+        )
+        if target is not None and target.id != node.id:
+            return _node_text(target, source)
+    if node.type == "func_literal":
+        return _go_binding_name(node, source) or "<anonymous>"
+    declarator = node.child_by_field_name("declarator")
+    if declarator is not None:
+        return _identifier_from(declarator, source) or "<anonymous>"
+    return "<anonymous>"
+
+
+def _walk_functions(root: Node, source: bytes, language: str, closures: bool) -> Iterator[FunctionNode]:
+    func_nodes = FUNCTION_NODE_TYPES.get(language, DEFAULT_FUNCTION_NODE_TYPES) - {"decorated_definition"}
+    closure_nodes = CLOSURE_NODE_TYPES.get(language, set())
+    anonymous: Dict[str, int] = {}  # per parent, to number its anonymous functions
+    stack: List[Tuple[Node, Optional[FunctionNode]]] = [(root, None)]
+    while stack:
+        node, enclosing = stack.pop()
+        if node.type in func_nodes or node.type in closure_nodes:
+            name = function_name(node, source)
+            if language == "go" and node.type == "method_declaration":
+                receiver, container = _go_receiver(node, source)
+            else:
+                container = _container_name(node, source, language)
+                receiver = None
+            closure = node.type in closure_nodes
+            if closure and enclosing is not None:
+                # A closure reads its method's receiver: `c.get()` in a Go func literal is `c`'s type.
+                container, receiver = enclosing.container, enclosing.receiver
+            if name == "<anonymous>":
+                key = enclosing.qualified_name if enclosing is not None else container or ""
+                anonymous[key] = anonymous.get(key, 0) + 1
+                name = f"{enclosing.name if enclosing is not None else ''}{ANONYMOUS_SUFFIX}{anonymous[key]}"
+            fn = FunctionNode(
+                node=node, name=name, container=container, receiver=receiver, closure=closure,
+                parent=enclosing.qualified_name if closure and enclosing is not None else None,
+            )
+            if closures or node.type in func_nodes:
+                yield fn
+            enclosing = fn
+        stack.extend((child, enclosing) for child in reversed(node.children))
+
+
+def iter_function_nodes(parsed: ParsedFile, closures: bool = False) -> Iterator[FunctionNode]:
+    """
+    Yield every function/method in `parsed` in source order; with `closures`, lambdas and
+    closures too (see `CLOSURE_NODE_TYPES`). Anonymous functions get synthetic names,
+    numbered per enclosing function: `NewCache$anon1`, `NewCache$anon1$anon1`, ...
+    """
+    return _walk_functions(parsed.root, parsed.source, parsed.language, closures)
+
+
+def closure_captures(parsed: ParsedFile, functions: Iterable[FunctionNode]) -> Dict[int, List[str]]:
+    """
+    The variables of enclosing functions (parameters and locals, not globals or fields)
+    each closure among `functions` uses, keyed by node id. Empty for languages without
+    scope rules (see `resolver.scopes`).
+    """
+    closures = [fn for fn in functions if fn.closure]
+    if not closures:
+        return {}
+    from .resolver.scopes import SCOPE_RULES, FileScopes
+
+    if parsed.language not in SCOPE_RULES:
+        return {}
+    occurrences = FileScopes(parsed).occurrences  # sorted by position
+    starts = [o.node.start_byte for o in occurrences]
+    found: Dict[int, List[str]] = {}
+    for fn in closures:
+        lo, hi = fn.node.start_byte, fn.node.end_byte
+        names: List[str] = []
+        for occurrence in occurrences[bisect.bisect_left(starts, lo):bisect.bisect_left(starts, hi)]:
+            binding = occurrence.binding
+            if occurrence.declaration or binding is None or binding.scope.kind in {"module", "class"}:
+                continue
+            if not lo <= binding.node.start_byte < hi and binding.name not in names:
+                names.append(binding.name)
+        found[fn.node.id] = names
+    return found
+
+
+@dataclass
+class ClassNode:
+    """A class/struct/interface node with its name and enclosing type (if nested)."""
+
+    node: Node
+    name: str
+    container: Optional[str] = None
+
+    @property
+    def qualified_name(self) -> str:
+        return f"{self.container}.{self.name}" if self.container else self.name
+
+
+def iter_class_nodes(parsed: ParsedFile) -> Iterator[ClassNode]:
+    """Yield class-like declarations using the same node tables as `extract_symbols`."""
+    class_nodes = CLASS_NODE_TYPES.get(parsed.language, set()) - {"decorated_definition", "impl_item"}
+    stack = [parsed.root]
+    while stack:
+        node = stack.pop()
+        if node.type in class_nodes:
+            if parsed.language == "go" and node.type == "type_spec":
+                type_node = node.child_by_field_name("type")
+                if type_node is None or type_node.type not in {"struct_type", "interface_type"}:
+                    stack.extend(reversed(node.children))
+                    continue
+            name_node = node.child_by_field_name("name")
+            name = _node_text(name_node, parsed.source) if name_node is not None else "<anonymous>"
+            yield ClassNode(node=node, name=name, container=_container_name(node, parsed.source, parsed.language))
+        stack.extend(reversed(node.children))
+
+
+def class_kind(node: Node) -> str:
+    """Coarse kind for a class-like node: class, struct, interface, or trait."""
+    if node.type == "type_spec":
+        type_node = node.child_by_field_name("type")
+        if type_node is not None and type_node.type == "interface_type":
+            return "interface"
+        return "struct"
+    if "interface" in node.type:
+        return "interface"
+    if "struct" in node.type:
+        return "struct"
+    if "trait" in node.type:
+        return "trait"
+    return "class"
+
+
+# Child nodes that list a class's base classes / implemented interfaces.
+HERITAGE_NODE_TYPES = {
+    "superclass",
+    "super_interfaces",
+    "extends_interfaces",
+    "extends_clause",
+    "implements_clause",
+    "class_heritage",
+    "base_list",
+    "base_class_clause",
+    "delegation_specifiers",
+    "extends_type_clause",
+}
+_HERITAGE_NAME_TYPES = {"identifier", "type_identifier", "name", "qualified_name", "scoped_type_identifier"}
+# Dotted references (`React.Component`, `ns.Base`) name their last component.
+_HERITAGE_PATH_TYPES = {"member_expression", "nested_type_identifier", "nested_identifier"}
+_IMPLEMENTS_NODE_TYPES = {"implements_clause", "super_interfaces"}
+
+
+def class_heritage(cls: ClassNode, parsed: ParsedFile) -> List[Tuple[str, str]]:
+    """(name, "extends" or "implements") for each base class/interface a class declares."""
+    roots: List[Node] = []
+    if parsed.language == "python":
+        superclasses = cls.node.child_by_field_name("superclasses")
+        if superclasses is not None:
+            roots = [c for c in superclasses.named_children if c.type != "keyword_argument"]
+    else:
+        roots = [c for c in cls.node.children if c.type in HERITAGE_NODE_TYPES]
+    found: List[Tuple[str, str]] = []
+    for root in roots:
+        stack = [(root, "extends")]
+        while stack:
+            node, relation = stack.pop()
+            if node.type in {"type_arguments", "type_parameters", "type_argument_list"}:
+                continue
+            if node.type in _IMPLEMENTS_NODE_TYPES:
+                relation = "implements"
+            if parsed.language == "python" and node.type == "subscript":  # Generic[T], Base[int]
+                node = node.child_by_field_name("value") or node
+            if (
+                node.type in _HERITAGE_NAME_TYPES
+                or node.type in _HERITAGE_PATH_TYPES
+                or (node.type == "attribute" and parsed.language == "python")
+            ):
+                name = parsed.text(node).split(".")[-1].split("::")[-1].split("\\")[-1].strip()
+                if (name, relation) not in found:
+                    found.append((name, relation))
+                continue
+            stack.extend((child, relation) for child in reversed(node.named_children))
+    return found
+
+
+def class_supertypes(cls: ClassNode, parsed: ParsedFile) -> List[str]:
+    """Names of the base classes/interfaces a class declares (generic arguments ignored)."""
+    names: List[str] = []
+    for name, _ in class_heritage(cls, parsed):
+        if name not in names:
+            names.append(name)
+    return names
+
+
+def go_interface_methods(cls: ClassNode, parsed: ParsedFile) -> List[str]:
+    """Method names declared by a Go interface type_spec (empty for structs)."""
+    type_node = cls.node.child_by_field_name("type")
+    if type_node is None or type_node.type != "interface_type":
+        return []
+    names = []
+    for child in type_node.named_children:
+        if child.type in {"method_elem", "method_spec"}:
+            name_node = child.child_by_field_name("name")
+            if name_node is not None:
+                names.append(parsed.text(name_node))
+    return names
+
+
+def parse_file(path: Path, language: Optional[str] = None, max_file_size: Optional[int] = None) -> ParsedFile:
+    """Read and parse `path` with the same safety checks as `extract_symbols`."""
+    path = Path(path)
+    check_file_size(path, max_file_size)
+    language = source_language(path, language)
+    source = overlay.read_bytes(path)
+    root = parse_source(source, language)
+    return ParsedFile(path=path, language=language, source=redact.apply(source, root), root=root)
+
+
+def scan_file(
+    path: Path, max_chunk_size: Optional[int] = None, session=None, max_file_size: Optional[int] = None
+) -> Optional[FileSymbols]:
+    """
+    Extract one file for a directory scan; errors are captured in the report (with their
+    `failures` category as `error_kind`), never raised.
+    """
+    try:
+        check_file_size(path, max_file_size)
+        if session is not None:
+            symbols, encoding = session.extract_with_encoding(path)
+        else:
+            symbols, encoding = extract_with_encoding(path, max_chunk_size=max_chunk_size)
+        language = detect_language(path) or "unknown"
+        if symbols:
+            return FileSymbols(
+                path=path, language=language, symbols=symbols, encoding=encoding if encoding != "utf-8" else None
+            )
+        return None
+    except Exception as exc:
+        # Capture error in the report
+        return FileSymbols(path=path, language="unknown", symbols=[], error=str(exc), error_kind=category_of(exc))
+
+
+def scan_directory(
+    root: Path,
+    include: Sequence[str] | None = None,
+    exclude: Sequence[str] | None = None,
+    max_chunk_size: Optional[int] = None,
+    session=None,
+    jobs: int = 1,
+    max_in_flight: Optional[int] = None,
+    only: Optional[Collection[Path]] = None,
+    max_file_size: Optional[int] = None,
+) -> List[FileSymbols]:
+    """
+    Walk `root` and extract symbols per file.
+
+    When an `IncrementalSession` is passed, files whose content is unchanged
+    since the previous run are served from its cache instead of being re-parsed.
+    With `jobs > 1` files are parsed in worker processes; at most `max_in_flight`
+    files (default `2 * jobs`) are outstanding at once and reports keep walk order.
+    Files over `max_file_size` bytes are reported as errors without being read.
+    """
+    return list(
+        iter_scan_directory(root, include, exclude, max_chunk_size, session, jobs, max_in_flight, only, max_file_size)
+    )
+
+
+def iter_scan_directory(
+    root: Path,
+    include: Sequence[str] | None = None,
+    exclude: Sequence[str] | None = None,
+    max_chunk_size: Optional[int] = None,
+    session=None,
+    jobs: int = 1,
+    max_in_flight: Optional[int] = None,
+    only: Optional[Collection[Path]] = None,
+    max_file_size: Optional[int] = None,
+    skip: Optional[Collection[Path]] = None,
+) -> Iterator[FileSymbols]:
+    """Lazy form of `scan_directory`: reports are yielded as each file finishes; `skip` files are left out."""
+    base = Path(root).resolve()
+    paths = iter_source_files(root, include, exclude, only, skip)
+    if jobs > 1:
+        from .parallel import scan_parallel
+
+        outcomes = scan_parallel(paths, jobs, max_chunk_size, session, max_in_flight, max_file_size)
+    else:
+        outcomes = (scan_file(path, max_chunk_size, session, max_file_size) for path in paths)
+    for report in outcomes:
+        if report is not None:
+            yield finish_report(report, base)
+
+
+def finish_report(report: FileSymbols, base: Path) -> FileSymbols:
+    """Complete a `scan_file` report from a walk of `base`: failure record, symbol IDs, and generated/vendored flags."""
+    if report.error:
+        failures.add(report.path, report.error_kind, report.error)
+    label = report.path.relative_to(base).as_posix()
+    assign_symbol_ids(report.symbols, label)
+    report.generated = generated.is_generated(report.path)
+    report.vendored = generated.is_vendored(label)
+    return report
+
+
+def outline_section(report: FileSymbols) -> str:
+    """Markdown outline for one file (`outline_markdown` joins these)."""
+    lines = [f"## {report.path.as_posix()} ({report.language})"]
+    for sym in report.symbols:
+        lines.append(f"- {sym.kind}: {sym.name} (lines {sym.start_line}-{sym.end_line})")
+    return "\n".join(lines) + "\n"
 
 
 def outline_markdown(reports: Iterable[FileSymbols]) -> str:
-    lines: List[str] = []
-    for report in reports:
-        lines.append(f"## {report.path.as_posix()} ({report.language})")
-        for sym in report.symbols:
-            lines.append(f"- {sym.kind}: {sym.name} (lines {sym.start_line}-{sym.end_line})")
-        lines.append("")
-    return "\n".join(lines).strip() + "\n"
+    return "\n".join(outline_section(report) for report in reports).strip() + "\n"
 
 
 __all__ = [
+    "ANONYMOUS_SUFFIX",
+    "CLOSURE_NODE_TYPES",
     "LANGUAGE_MAPPINGS",
+    "LANGUAGE_SPECS",
+    "IMPORT_NODE_TYPES",
+    "LanguageSpec",
     "CodeSymbol",
     "FileSymbols",
+    "ParsedFile",
+    "FunctionNode",
+    "ClassNode",
+    "GoInstantiation",
+    "assign_symbol_ids",
+    "body_hash",
+    "class_kind",
+    "class_heritage",
+    "class_supertypes",
+    "closure_captures",
+    "content_id",
     "extract_symbols",
+    "extract_with_encoding",
+    "finish_report",
+    "function_name",
+    "get_language_spec",
+    "go_interface_methods",
+    "go_signature_instantiations",
+    "go_type_parameters",
+    "is_anonymous",
+    "iter_class_nodes",
+    "iter_function_nodes",
+    "iter_go_instantiations",
+    "iter_scan_directory",
+    "iter_source_files",
+    "leading_comments",
+    "normalize_comment",
+    "parse_file",
+    "register_language",
+    "symbol_doc",
+    "symbols_from_tree",
+    "trailing_comment",
+    "query_tree",
     "run_query",
     "scan_directory",
+    "scan_file",
+    "source_language",
     "outline_markdown",
+    "outline_section",
     "symbols_to_json",
 ]
 @dataclass
@@ -584,13 +1724,27 @@ class FileSymbols:
     language: str
     symbols: List[CodeSymbol]
     error: Optional[str] = None
+    # Set by directory scans for generated files and files under a vendor directory
+    generated: bool = False
+    vendored: bool = False
+    root: Optional[str] = None  # name of the workspace root the file came from
+    error_kind: Optional[str] = None  # the `failures` category of `error`
+    encoding: Optional[str] = None  # set when the file was transcoded to UTF-8 (see `charsets`)
+
+    def provenance(self) -> dict:
+        """The `generated`/`vendored` markers of the report's records (only those that are set)."""
+        return {k: True for k in ("generated", "vendored") if getattr(self, k)}
 
     def to_dict(self) -> dict:
         data = {
             "path": self.path.as_posix(),
+            **({"root": self.root} if self.root is not None else {}),
             "language": self.language,
+            **({"encoding": self.encoding} if self.encoding is not None else {}),
+            **self.provenance(),
             "symbols": [sym.to_dict() for sym in self.symbols],
         }
         if self.error:
             data["error"] = self.error
+            data["error_kind"] = self.error_kind or PARSE_FAILURE
         return data
@@ -0,0 +1,335 @@
+"""
+Intra-procedural parameter flow ("taint lite"): for each function, which parameters
+reach its return values, the arguments of the calls it makes, and writes to global
+(module-level or undeclared) variables.
+
+A variable is tainted by a parameter when an assignment to it mentions the parameter
+or another variable it taints, in any order (so flows through loops are found). The
+analysis is deliberately coarse:
+
+- Any mention counts: `f(p)`, `p.x`, `p[0]`, and `len(p)` all carry `p`'s taint, and a
+  write to `obj.x` or `arr[i]` taints the whole `obj`/`arr`.
+- Only assignments propagate: a mutating call such as `items.append(p)` does not taint
+  `items`, and flows are not followed into the functions being called.
+- Nested functions and lambdas get their own summaries; closures they form are seen
+  only where the outer function mentions them.
+
+Names are resolved with the lexical scopes of `resolver.scopes`, so a local that
+shadows a parameter is a different variable.
+"""
+
+from __future__ import annotations
+
+import json
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Dict, Iterator, List, Optional, Sequence, Set, Tuple
+
+from tree_sitter import Node
+
+from . import failures
+from .callgraph import iter_call_sites
+from .core import FunctionNode, ParsedFile, iter_function_nodes, iter_source_files, parse_file
+from .resolver.resolve import PARAMETER_NODE_TYPES
+from .resolver.scopes import SCOPE_RULES, FileScopes, Occurrence
+
+SUPPORTED_LANGUAGES = tuple(SCOPE_RULES)
+
+# Assignment-like nodes: (target field, value field). The target's variables take the
+# taint of everything the value mentions.
+ASSIGNMENT_FIELDS: Dict[str, Tuple[str, str]] = {
+    # Python
+    "assignment": ("left", "right"),
+    "augmented_assignment": ("left", "right"),
+    "named_expression": ("name", "value"),
+    "for_statement": ("left", "right"),
+    "for_in_clause": ("left", "right"),
+    # JavaScript / TypeScript / Java / C
+    "variable_declarator": ("name", "value"),
+    "assignment_expression": ("left", "right"),
+    "augmented_assignment_expression": ("left", "right"),
+    "for_in_statement": ("left", "right"),
+    "enhanced_for_statement": ("name", "value"),
+    "init_declarator": ("declarator", "value"),
+    # Go
+    "short_var_declaration": ("left", "right"),
+    "assignment_statement": ("left", "right"),
+    "var_spec": ("name", "value"),
+    "range_clause": ("left", "right"),
+    # Rust
+    "let_declaration": ("pattern", "value"),
+    "compound_assignment_expr": ("left", "right"),
+    "for_expression": ("pattern", "value"),
+}
+RETURN_NODE_TYPES = {"return_statement", "return_expression"}
+# Function scopes that are expressions of the enclosing function rather than functions of their own.
+_INLINE_SCOPES = {"list_comprehension", "set_comprehension", "dictionary_comprehension", "generator_expression"}
+# Parts of an assignment target that select inside a variable rather than name one.
+_INDEX_FIELDS = {"subscript", "index", "indices"}
+_SELF_NAMES = {"self", "cls"}
+
+
+@dataclass
+class CallFlow:
+    callee: str  # as written: `os.system`, `cursor.execute`, `print`
+    line: int
+    argument: int  # zero-based position among the call's arguments
+    parameters: List[str]
+
+    def to_dict(self) -> dict:
+        return {"callee": self.callee, "line": self.line, "argument": self.argument, "parameters": self.parameters}
+
+
+@dataclass
+class GlobalWrite:
+    name: str  # the assignment target as written: `CACHE`, `config.url`, `os.environ[key]`
+    line: int
+    parameters: List[str]
+
+    def to_dict(self) -> dict:
+        return {"name": self.name, "line": self.line, "parameters": self.parameters}
+
+
+@dataclass
+class FlowSummary:
+    path: str
+    function: str
+    line: int
+    parameters: List[str]
+    returns: List[str] = field(default_factory=list)  # parameters that reach a return value
+    calls: List[CallFlow] = field(default_factory=list)
+    global_writes: List[GlobalWrite] = field(default_factory=list)
+
+    @property
+    def has_flows(self) -> bool:
+        return bool(self.returns or self.calls or self.global_writes)
+
+    def to_dict(self) -> dict:
+        return {
+            "path": self.path,
+            "function": self.function,
+            "line": self.line,
+            "parameters": self.parameters,
+            "returns": self.returns,
+            "calls": [c.to_dict() for c in self.calls],
+            "global_writes": [w.to_dict() for w in self.global_writes],
+        }
+
+
+def _key(occurrence: Occurrence) -> tuple:
+    """The variable an occurrence names; free names are keyed by name alone."""
+    return occurrence.binding.key if occurrence.binding is not None else ("<free>", occurrence.name)
+
+
+def _under(node: Node, types: set, stop: Node) -> bool:
+    current = node.parent
+    while current is not None and current.id != stop.id:
+        if current.type in types:
+            return True
+        current = current.parent
+    return False
+
+
+class _FunctionFlow:
+    def __init__(self, scopes: FileScopes, occurrences: Dict[int, Occurrence], fn: FunctionNode):
+        self.scopes = scopes
+        self.occurrences = occurrences
+        self.fn = fn
+        self.rules = scopes.rules
+        self.own_scope = scopes.scopes.get(fn.node.id)
+        self.params: List[str] = []
+        # binding key -> parameters it carries
+        self.taint: Dict[tuple, Set[str]] = {}
+        receiver = fn.node.child_by_field_name("receiver")
+        for occurrence in self._occurrences_in(fn.node):
+            binding = occurrence.binding
+            if not occurrence.declaration or binding is None or binding.scope is not self.own_scope:
+                continue
+            if not _under(occurrence.node, PARAMETER_NODE_TYPES, fn.node):
+                continue
+            if receiver is not None and receiver.start_byte <= occurrence.node.start_byte < receiver.end_byte:
+                continue  # a Go method's receiver is not an input
+            if scopes.parsed.language == "python" and occurrence.name in _SELF_NAMES and not self.params:
+                continue
+            if occurrence.name not in self.params:
+                self.params.append(occurrence.name)
+                self.taint[binding.key] = {occurrence.name}
+
+    def _occurrences_in(self, node: Node, skip: Sequence[str] = ()) -> Iterator[Occurrence]:
+        stack = [node]
+        while stack:
+            current = stack.pop()
+            if current.type in self.rules.identifiers:
+                occurrence = self.occurrences.get(current.id)
+                if occurrence is not None:
+                    yield occurrence
+            stack.extend(
+                child for i, child in reversed(list(enumerate(current.children)))
+                if current.field_name_for_child(i) not in skip
+            )
+
+    def _own_nodes(self) -> Iterator[Node]:
+        """Nodes of the function itself, not of functions nested in it."""
+        stack = list(reversed(self.fn.node.children))
+        while stack:
+            node = stack.pop()
+            if node.type not in _INLINE_SCOPES and self.rules.scopes.get(node.type) == "function":
+                continue
+            yield node
+            stack.extend(reversed(node.children))
+
+    def carried(self, node: Optional[Node]) -> Set[str]:
+        """Parameters whose taint `node` mentions."""
+        found: Set[str] = set()
+        if node is None:
+            return found
+        for occurrence in self._occurrences_in(node):
+            found |= self.taint.get(_key(occurrence), set())
+        return found
+
+    def _targets(self, node: Node) -> List[Occurrence]:
+        return [o for o in self._occurrences_in(node, _INDEX_FIELDS) if o.name != "_"]
+
+    def summarize(self, label: str) -> FlowSummary:
+        nodes = list(self._own_nodes())
+        assignments = []
+        for node in nodes:
+            fields_ = ASSIGNMENT_FIELDS.get(node.type)
+            if fields_ is not None:
+                target, value = node.child_by_field_name(fields_[0]), node.child_by_field_name(fields_[1])
+                if target is not None and value is not None:
+                    assignments.append((node, target, value))
+        changed = True
+        while changed:
+            changed = False
+            for _, target, value in assignments:
+                carried = self.carried(value)
+                if not carried:
+                    continue
+                for occurrence in self._targets(target):
+                    known = self.taint.setdefault(_key(occurrence), set())
+                    if not carried <= known:
+                        known |= carried
+                        changed = True
+
+        order = {name: i for i, name in enumerate(self.params)}
+        ordered = lambda names: sorted(names, key=order.__getitem__)  # noqa: E731
+        summary = FlowSummary(label, self.fn.qualified_name, self.fn.node.start_point[0] + 1, list(self.params))
+        returned: Set[str] = set()
+        for node in nodes:
+            if node.type in RETURN_NODE_TYPES:
+                returned |= self.carried(node)
+        returned |= self.carried(self._implicit_result())
+        summary.returns = ordered(returned)
+
+        for site in iter_call_sites(self.fn.node, self.scopes.parsed):
+            arguments = site.node.child_by_field_name("arguments")
+            if arguments is None:
+                continue
+            callee = f"{site.receiver}.{site.name}" if site.receiver else site.name
+            position = 0
+            for argument in arguments.named_children:
+                if "comment" in argument.type:
+                    continue
+                carried = self.carried(argument)
+                if carried:
+                    summary.calls.append(CallFlow(callee, argument.start_point[0] + 1, position, ordered(carried)))
+                position += 1
+
+        for node, target, value in assignments:
+            carried = self.carried(value)
+            if not carried:
+                continue
+            for occurrence in self._targets(target):
+                binding = occurrence.binding
+                if binding is None or binding.scope is self.scopes.root:
+                    name = " ".join(self.scopes.parsed.text(target).split())
+                    summary.global_writes.append(GlobalWrite(name, node.start_point[0] + 1, ordered(carried)))
+                    break
+        return summary
+
+    def _implicit_result(self) -> Optional[Node]:
+        """An expression-bodied lambda/arrow function's body, or a Rust block's tail expression."""
+        body = self.fn.node.child_by_field_name("body")
+        if body is None:
+            return None
+        if body.type not in {"block", "statement_block", "compound_statement"}:
+            return body
+        if self.scopes.parsed.language != "rust" or not body.named_children:
+            return None
+        tail = body.named_children[-1]
+        if tail.type.endswith(("_statement", "_declaration", "_item")) or "comment" in tail.type:
+            return None
+        return tail
+
+
+def file_flows(parsed: ParsedFile, label: Optional[str] = None, function: Optional[str] = None) -> List[FlowSummary]:
+    """Summaries for the functions of `parsed`; `function` keeps those with that name or qualified name."""
+    if parsed.language not in SCOPE_RULES:
+        raise ValueError(
+            f"Parameter flow analysis is not supported for '{parsed.language}' (supported: {', '.join(SUPPORTED_LANGUAGES)})"
+        )
+    label = label or parsed.path.as_posix()
+    scopes = FileScopes(parsed)
+    occurrences = {o.node.id: o for o in scopes.occurrences}
+    summaries = []
+    for fn in iter_function_nodes(parsed):
+        if function is not None and function not in (fn.name, fn.qualified_name):
+            continue
+        if fn.node.child_by_field_name("body") is None:
+            continue
+        summaries.append(_FunctionFlow(scopes, occurrences, fn).summarize(label))
+    return summaries
+
+
+def analyze_flows(
+    root: Path,
+    include: Sequence[str] | None = None,
+    exclude: Sequence[str] | None = None,
+    function: Optional[str] = None,
+) -> List[FlowSummary]:
+    """Summaries for one file or every supported file under a directory."""
+    root = Path(root)
+    if root.is_file():
+        return file_flows(parse_file(root), function=function)
+    base = root.resolve()
+    summaries: List[FlowSummary] = []
+    for path in iter_source_files(base, include, exclude):
+        try:
+            parsed = parse_file(path)
+        except (ValueError, RuntimeError, OSError) as exc:
+            failures.record(path, exc)
+            continue
+        if parsed.language in SCOPE_RULES:
+            summaries.extend(file_flows(parsed, path.relative_to(base).as_posix(), function))
+    return summaries
+
+
+def flows_to_json(summaries: Sequence[FlowSummary]) -> str:
+    return json.dumps([s.to_dict() for s in summaries], indent=2)
+
+
+def flows_to_text(summaries: Sequence[FlowSummary]) -> str:
+    lines = []
+    for s in summaries:
+        lines.append(f"{s.path}:{s.line} {s.function}({', '.join(s.parameters)})")
+        for name in s.returns:
+            lines.append(f"  {name} -> return")
+        for call in s.calls:
+            lines.append(f"  {', '.join(call.parameters)} -> {call.callee} arg {call.argument}  (line {call.line})")
+        for write in s.global_writes:
+            lines.append(f"  {', '.join(write.parameters)} -> global {write.name}  (line {write.line})")
+    return "\n".join(lines) + ("\n" if lines else "")
+
+
+__all__ = [
+    "ASSIGNMENT_FIELDS",
+    "SUPPORTED_LANGUAGES",
+    "CallFlow",
+    "FlowSummary",
+    "GlobalWrite",
+    "analyze_flows",
+    "file_flows",
+    "flows_to_json",
+    "flows_to_text",
+]
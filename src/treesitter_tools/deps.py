@@ -0,0 +1,507 @@
+"""Package-level import graph with cycle detection and fan-in/fan-out metrics."""
+
+from __future__ import annotations
+
+import json
+import re
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Dict, Iterator, List, Optional, Sequence, Set, Tuple
+
+from tree_sitter import Node
+
+from . import failures
+from .core import ParsedFile, iter_source_files, parse_file
+
+SUPPORTED_LANGUAGES = ("python", "go", "javascript", "typescript", "tsx", "rust", "java", "c", "cpp")
+
+_JS_LANGUAGES = {"javascript", "typescript", "tsx"}
+_JS_SUFFIXES = (".ts", ".tsx", ".js", ".jsx", ".mjs", ".cjs")
+_C_LANGUAGES = {"c", "cpp"}
+
+
+@dataclass
+class Import:
+    """One import statement: the raw specifier and where it resolved to."""
+
+    spec: str
+    line: int
+    package: Optional[str] = None  # local package (directory), when resolved
+    external: Optional[str] = None  # third-party/stdlib module otherwise
+
+
+@dataclass
+class Package:
+    name: str
+    files: List[str] = field(default_factory=list)
+    imports: Set[str] = field(default_factory=set)
+    importers: Set[str] = field(default_factory=set)
+    external: Set[str] = field(default_factory=set)
+
+    @property
+    def fan_in(self) -> int:
+        return len(self.importers)
+
+    @property
+    def fan_out(self) -> int:
+        return len(self.imports)
+
+    @property
+    def instability(self) -> float:
+        """fan_out / (fan_in + fan_out): 0 is maximally stable, 1 depends only outward."""
+        total = self.fan_in + self.fan_out
+        return round(self.fan_out / total, 3) if total else 0.0
+
+    def to_dict(self) -> dict:
+        return {
+            "name": self.name,
+            "files": sorted(self.files),
+            "fan_in": self.fan_in,
+            "fan_out": self.fan_out,
+            "instability": self.instability,
+            "imports": sorted(self.imports),
+            "imported_by": sorted(self.importers),
+            "external": sorted(self.external),
+        }
+
+
+def _package_of(rel_path: str) -> str:
+    return rel_path.rpartition("/")[0] or "."
+
+
+def _unquote(text: str) -> str:
+    return text.strip().strip("\"'`<>")
+
+
+def _walk(node: Node) -> Iterator[Node]:
+    stack = [node]
+    while stack:
+        current = stack.pop()
+        yield current
+        stack.extend(reversed(current.children))
+
+
+def _python_specs(parsed: ParsedFile) -> Iterator[Tuple[str, Node]]:
+    for node in _walk(parsed.root):
+        if node.type == "import_statement":
+            for name in node.children_by_field_name("name"):
+                target = name.child_by_field_name("name") if name.type == "aliased_import" else name
+                if target is not None:
+                    yield parsed.text(target), node
+        elif node.type == "import_from_statement":
+            module = node.child_by_field_name("module_name")
+            if module is not None:
+                yield parsed.text(module), node
+
+
+def _go_specs(parsed: ParsedFile) -> Iterator[Tuple[str, Node]]:
+    for node in _walk(parsed.root):
+        if node.type == "import_spec":
+            path = node.child_by_field_name("path")
+            if path is not None:
+                yield _unquote(parsed.text(path)), node
+
+
+def _js_specs(parsed: ParsedFile) -> Iterator[Tuple[str, Node]]:
+    for node in _walk(parsed.root):
+        if node.type in {"import_statement", "export_statement"}:
+            source = node.child_by_field_name("source")
+            if source is not None:
+                yield _unquote(parsed.text(source)), node
+        elif node.type == "call_expression":
+            fn = node.child_by_field_name("function")
+            args = node.child_by_field_name("arguments")
+            if fn is None or args is None or parsed.text(fn) not in {"require", "import"}:
+                continue
+            strings = [a for a in args.named_children if a.type in {"string", "template_string"}]
+            if len(strings) == 1 and len(args.named_children) == 1:
+                yield _unquote(parsed.text(strings[0])), node
+
+
+def _rust_specs(parsed: ParsedFile) -> Iterator[Tuple[str, Node]]:
+    for node in _walk(parsed.root):
+        if node.type == "use_declaration":
+            argument = node.child_by_field_name("argument")
+            if argument is not None:
+                yield re.split(r"::\{|\s+as\s+", parsed.text(argument))[0], node
+        elif node.type == "extern_crate_declaration":
+            name = node.child_by_field_name("name")
+            if name is not None:
+                yield parsed.text(name), node
+
+
+def _java_specs(parsed: ParsedFile) -> Iterator[Tuple[str, Node]]:
+    for node in parsed.root.named_children:
+        if node.type == "import_declaration":
+            names = [c for c in node.named_children if c.type in {"scoped_identifier", "identifier"}]
+            if names:
+                yield parsed.text(names[0]), node
+
+
+def _c_specs(parsed: ParsedFile) -> Iterator[Tuple[str, Node]]:
+    for node in _walk(parsed.root):
+        if node.type == "preproc_include":
+            path = node.child_by_field_name("path")
+            if path is not None:
+                # Keep the delimiters: "local.h" vs <system.h> decides how it resolves.
+                yield parsed.text(path).strip(), node
+
+
+_EXTRACTORS = {
+    "python": _python_specs,
+    "go": _go_specs,
+    "javascript": _js_specs,
+    "typescript": _js_specs,
+    "tsx": _js_specs,
+    "rust": _rust_specs,
+    "java": _java_specs,
+    "c": _c_specs,
+    "cpp": _c_specs,
+}
+
+
+def import_specs(parsed: ParsedFile) -> List[Tuple[str, int]]:
+    """Raw import specifiers in `parsed` with their 1-based line numbers."""
+    extractor = _EXTRACTORS.get(parsed.language)
+    if extractor is None:
+        return []
+    return [(spec, node.start_point[0] + 1) for spec, node in extractor(parsed) if spec]
+
+
+def _java_package(parsed: ParsedFile) -> Optional[str]:
+    for node in parsed.root.named_children:
+        if node.type == "package_declaration":
+            names = [c for c in node.named_children if c.type in {"scoped_identifier", "identifier"}]
+            if names:
+                return parsed.text(names[0])
+    return None
+
+
+def _go_module(base: Path) -> Optional[str]:
+    go_mod = base / "go.mod"
+    if not go_mod.is_file():
+        return None
+    match = re.search(r"^module\s+(\S+)", go_mod.read_text(encoding="utf-8", errors="replace"), re.MULTILINE)
+    return match.group(1) if match else None
+
+
+class _Resolver:
+    """Maps import specifiers to local package directories (relative to the root)."""
+
+    def __init__(self, base: Path, files: Dict[str, ParsedFile]):
+        self.base = base
+        self.files = set(files)
+        self.dirs = {_package_of(rel) for rel in files}
+        self.go_module = _go_module(base)
+        self.java_packages: Dict[str, str] = {}
+        for rel, parsed in files.items():
+            if parsed.language == "java":
+                declared = _java_package(parsed)
+                if declared:
+                    self.java_packages.setdefault(declared, _package_of(rel))
+
+    def _local(self, rel: str) -> Optional[str]:
+        """Package for a root-relative module path, trying it as a file then as a directory."""
+        rel = rel.strip("/") or "."
+        if rel in self.files:
+            return _package_of(rel)
+        if rel in self.dirs:
+            return rel
+        return None
+
+    def _join(self, package: str, relative: str) -> Optional[str]:
+        parts = [] if package == "." else package.split("/")
+        for part in relative.split("/"):
+            if part in {"", "."}:
+                continue
+            if part == "..":
+                if not parts:
+                    return None
+                parts.pop()
+            else:
+                parts.append(part)
+        return "/".join(parts) or "."
+
+    def resolve(self, spec: str, language: str, rel_path: str) -> Tuple[Optional[str], Optional[str]]:
+        """(local package, external module) for one specifier; exactly one is set."""
+        here = _package_of(rel_path)
+        if language == "python":
+            return self._python(spec, here)
+        if language == "go":
+            if self.go_module and (spec == self.go_module or spec.startswith(self.go_module + "/")):
+                local = self._local(spec[len(self.go_module):].lstrip("/"))
+                if local:
+                    return local, None
+            return None, spec
+        if language in _JS_LANGUAGES:
+            if spec.startswith("."):
+                target = self._join(here, spec)
+                for candidate in [target] + [f"{target}{s}" for s in _JS_SUFFIXES] + [f"{target}/index{s}" for s in _JS_SUFFIXES]:
+                    local = self._local(candidate) if candidate else None
+                    if local:
+                        return local, None
+                return None, spec
+            parts = spec.split("/")
+            return None, "/".join(parts[:2]) if spec.startswith("@") else parts[0]
+        if language == "rust":
+            return self._rust(spec, here)
+        if language == "java":
+            # Longest declared package prefixing the import (a.b.C, a.b.*, static a.b.C.m).
+            package = spec[:-2] if spec.endswith(".*") else spec
+            while package:
+                if package in self.java_packages:
+                    return self.java_packages[package], None
+                if "." not in package:
+                    break
+                package = package.rsplit(".", 1)[0]
+            return None, spec
+        if language in _C_LANGUAGES:
+            name = _unquote(spec)
+            if spec.startswith('"'):
+                for candidate in (self._join(here, name), self._join(".", name)):
+                    if candidate and candidate in self.files:
+                        return _package_of(candidate), None
+            return None, name
+        return None, spec
+
+    def _python(self, spec: str, here: str) -> Tuple[Optional[str], Optional[str]]:
+        dots = len(spec) - len(spec.lstrip("."))
+        module = spec[dots:].replace(".", "/")
+        if dots:
+            anchor = self._join(here, "/".join([".."] * (dots - 1)))
+            if anchor is None:
+                return None, spec
+            target = self._join(anchor, module) if module else anchor
+            candidates = [target]
+        else:
+            # Packages are often laid out under src/.
+            candidates = [module, f"src/{module}"]
+        for candidate in candidates:
+            if candidate is None:
+                continue
+            for option in (f"{candidate}.py", f"{candidate}/__init__.py", candidate):
+                local = self._local(option)
+                if local:
+                    return local, None
+        return None, spec.split(".")[0] if not dots else spec
+
+    def _rust(self, spec: str, here: str) -> Tuple[Optional[str], Optional[str]]:
+        segments = [s for s in spec.split("::") if s]
+        if not segments:
+            return None, None
+        head = segments[0]
+        if head not in {"crate", "self", "super"}:
+            return None, head
+        crate_root = self._crate_root(here)
+        if head == "crate":
+            anchor = crate_root
+        else:
+            anchor = here
+            for _ in range(sum(1 for s in segments if s == "super")):
+                anchor = self._join(anchor, "..") or "."
+        module_parts = [s for s in segments[1:] if s not in {"self", "super"}]
+        # Longest module prefix that exists as a file or directory under the anchor.
+        for length in range(len(module_parts), 0, -1):
+            target = self._join(anchor, "/".join(module_parts[:length]))
+            if target is None:
+                continue
+            local = self._local(f"{target}.rs") or self._local(f"{target}/mod.rs") or self._local(target)
+            if local:
+                return local, None
+        return anchor, None
+
+    def _crate_root(self, here: str) -> str:
+        package = here
+        while True:
+            for entry in ("lib.rs", "main.rs"):
+                if (f"{entry}" if package == "." else f"{package}/{entry}") in self.files:
+                    return package
+            if package == ".":
+                return "src" if "src" in self.dirs else "."
+            package = _package_of(package)
+
+
+@dataclass
+class DependencyGraph:
+    packages: Dict[str, Package] = field(default_factory=dict)
+    # (from, to) -> number of import statements behind the edge
+    edges: Dict[Tuple[str, str], int] = field(default_factory=dict)
+    file_imports: Dict[str, List[Import]] = field(default_factory=dict)
+
+    def cycles(self) -> List[List[str]]:
+        """Strongly connected components with more than one package (Tarjan), sorted."""
+        index: Dict[str, int] = {}
+        low: Dict[str, int] = {}
+        on_stack: Set[str] = set()
+        stack: List[str] = []
+        result: List[List[str]] = []
+        counter = [0]
+
+        def visit(name: str) -> None:
+            index[name] = low[name] = counter[0]
+            counter[0] += 1
+            stack.append(name)
+            on_stack.add(name)
+            for target in sorted(self.packages[name].imports):
+                if target not in index:
+                    visit(target)
+                    low[name] = min(low[name], low[target])
+                elif target in on_stack:
+                    low[name] = min(low[name], index[target])
+            if low[name] == index[name]:
+                component = []
+                while True:
+                    member = stack.pop()
+                    on_stack.discard(member)
+                    component.append(member)
+                    if member == name:
+                        break
+                if len(component) > 1:
+                    result.append(sorted(component))
+
+        for name in sorted(self.packages):
+            if name not in index:
+                visit(name)
+        return sorted(result)
+
+    def to_dict(self) -> dict:
+        return {
+            "packages": [self.packages[name].to_dict() for name in sorted(self.packages)],
+            "edges": [
+                {"from": source, "to": target, "imports": count}
+                for (source, target), count in sorted(self.edges.items())
+            ],
+            "cycles": self.cycles(),
+        }
+
+    def to_json(self) -> str:
+        return json.dumps(self.to_dict(), indent=2)
+
+    def _in_cycle(self) -> Set[Tuple[str, str]]:
+        edges = set()
+        for component in self.cycles():
+            members = set(component)
+            edges.update((s, t) for s, t in self.edges if s in members and t in members)
+        return edges
+
+    def to_dot(self, external: bool = False) -> str:
+        lines = ["digraph deps {", "  rankdir=LR;", "  node [shape=box];"]
+        cyclic = self._in_cycle()
+        for name in sorted(self.packages):
+            pkg = self.packages[name]
+            lines.append(f"  {json.dumps(name)} [label={json.dumps(f'{name} (in {pkg.fan_in}, out {pkg.fan_out})')}];")
+        for (source, target), count in sorted(self.edges.items()):
+            attrs = [f"label={count}"] if count > 1 else []
+            if (source, target) in cyclic:
+                attrs.append("color=red")
+            suffix = f" [{', '.join(attrs)}]" if attrs else ""
+            lines.append(f"  {json.dumps(source)} -> {json.dumps(target)}{suffix};")
+        if external:
+            for name in sorted(self.packages):
+                for module in sorted(self.packages[name].external):
+                    lines.append(f"  {json.dumps(name)} -> {json.dumps(module)} [style=dashed];")
+        lines.append("}")
+        return "\n".join(lines) + "\n"
+
+    def to_mermaid(self, external: bool = False) -> str:
+        ids: Dict[str, str] = {}
+
+        def node_id(name: str) -> str:
+            if name not in ids:
+                ids[name] = f"n{len(ids)}"
+            return ids[name]
+
+        lines = ["graph LR"]
+        for name in sorted(self.packages):
+            lines.append(f'  {node_id(name)}["{name}"]')
+        if external:
+            for module in sorted({m for pkg in self.packages.values() for m in pkg.external}):
+                lines.append(f'  {node_id("ext:" + module)}(["{module}"])')
+        cyclic = self._in_cycle()
+        link_styles = []
+        links = 0
+        for (source, target), count in sorted(self.edges.items()):
+            label = f"|{count}|" if count > 1 else ""
+            lines.append(f"  {node_id(source)} -->{label} {node_id(target)}")
+            if (source, target) in cyclic:
+                link_styles.append(links)
+            links += 1
+        if external:
+            for name in sorted(self.packages):
+                for module in sorted(self.packages[name].external):
+                    lines.append(f"  {node_id(name)} -.-> {node_id('ext:' + module)}")
+        if link_styles:
+            lines.append(f"  linkStyle {','.join(map(str, link_styles))} stroke:red")
+        return "\n".join(lines) + "\n"
+
+
+def build_dependency_graph(
+    root: Path,
+    include: Sequence[str] | None = None,
+    exclude: Sequence[str] | None = None,
+) -> DependencyGraph:
+    """Parse imports under `root` and aggregate them into directory-level package edges."""
+    base = Path(root).resolve()
+    if base.is_file():
+        paths = [base]
+        base = base.parent
+    else:
+        paths = list(iter_source_files(base, include, exclude))
+    files: Dict[str, ParsedFile] = {}
+    for path in paths:
+        try:
+            parsed = parse_file(path)
+        except (ValueError, RuntimeError, OSError) as exc:
+            failures.record(path, exc)
+            continue
+        if parsed.language in _EXTRACTORS:
+            files[path.relative_to(base).as_posix()] = parsed
+
+    resolver = _Resolver(base, files)
+    graph = DependencyGraph()
+    for rel in sorted(files):
+        parsed = files[rel]
+        name = _package_of(rel)
+        package = graph.packages.setdefault(name, Package(name))
+        package.files.append(rel)
+        imports = []
+        for spec, line in import_specs(parsed):
+            local, external = resolver.resolve(spec, parsed.language, rel)
+            imports.append(Import(spec, line, package=local, external=external))
+            if local is not None and local != name:
+                graph.packages.setdefault(local, Package(local))
+                package.imports.add(local)
+                graph.edges[(name, local)] = graph.edges.get((name, local), 0) + 1
+            elif external is not None:
+                package.external.add(external)
+        graph.file_imports[rel] = imports
+    for (source, target) in graph.edges:
+        graph.packages[target].importers.add(source)
+    return graph
+
+
+def deps_to_text(graph: DependencyGraph) -> str:
+    lines = []
+    for name in sorted(graph.packages):
+        pkg = graph.packages[name]
+        lines.append(f"{name}  (fan-in {pkg.fan_in}, fan-out {pkg.fan_out}, instability {pkg.instability})")
+        for target in sorted(pkg.imports):
+            lines.append(f"  -> {target}")
+    cycles = graph.cycles()
+    if cycles:
+        lines.append("")
+        lines.append(f"{len(cycles)} import cycle{'s' if len(cycles) != 1 else ''}:")
+        for component in cycles:
+            lines.append("  " + " <-> ".join(component))
+    return "\n".join(lines) + "\n"
+
+
+__all__ = [
+    "DependencyGraph",
+    "Import",
+    "Package",
+    "SUPPORTED_LANGUAGES",
+    "build_dependency_graph",
+    "deps_to_text",
+    "import_specs",
+]
@@ -0,0 +1,92 @@
+"""
+Language detection for files the extension table does not cover: well-known names
+(`Dockerfile`, `Makefile`, Bazel `BUILD` files), `#!` interpreter lines, editor
+modelines, and telltale openings such as `<?php`.
+"""
+
+from __future__ import annotations
+
+from dataclasses import dataclass
+from pathlib import Path
+from typing import Collection, Mapping, Optional
+
+from .content import (
+    INTERPRETERS,
+    MODELINE_ALIASES,
+    OPENINGS,
+    modeline_language,
+    opening_language,
+    read_ends,
+    shebang_language,
+)
+from .filenames import FILENAME_LANGUAGES, FILENAME_PATTERNS, filename_language
+
+# Detection methods, in the order they are tried.
+METHODS = ("extension", "filename", "shebang", "modeline", "content")
+
+
+@dataclass(frozen=True)
+class Detection:
+    language: str
+    method: str  # one of METHODS
+
+    def to_dict(self) -> dict:
+        return {"language": self.language, "method": self.method}
+
+
+def _known(extra: Collection[str]) -> set:
+    known = set(extra)
+    known.update(FILENAME_LANGUAGES.values(), (language for _, language in FILENAME_PATTERNS))
+    known.update(INTERPRETERS.values(), MODELINE_ALIASES.values(), (language for _, language in OPENINGS))
+    return known
+
+
+def guess_language(path: Path, known: Collection[str] = ()) -> Optional[Detection]:
+    """
+    Detect `path`'s language without its extension: well-known file names first,
+    then the file's content. A modeline naming a language outside `known` (plus the
+    languages of this package's own tables) is ignored, so `ft=conf` does not turn
+    into an unavailable grammar. Missing, unreadable, and binary files give None.
+    """
+    language = filename_language(path)
+    if language is not None:
+        return Detection(language, "filename")
+    ends = read_ends(path)
+    if ends is None:
+        return None
+    head, tail = ends
+    language = shebang_language(head)
+    if language is not None:
+        return Detection(language, "shebang")
+    language = modeline_language(head, tail)
+    if language is not None and language in _known(known):
+        return Detection(language, "modeline")
+    language = opening_language(head)
+    if language is not None:
+        return Detection(language, "content")
+    return None
+
+
+def detect_file(path: Path, extensions: Mapping[str, str]) -> Optional[Detection]:
+    """The extension table (`ext -> language`) first, then `guess_language`."""
+    ext = Path(path).suffix.lstrip(".").lower()
+    if ext in extensions:
+        return Detection(extensions[ext], "extension")
+    return guess_language(path, set(extensions.values()))
+
+
+__all__ = [
+    "FILENAME_LANGUAGES",
+    "FILENAME_PATTERNS",
+    "INTERPRETERS",
+    "METHODS",
+    "MODELINE_ALIASES",
+    "OPENINGS",
+    "Detection",
+    "detect_file",
+    "filename_language",
+    "guess_language",
+    "modeline_language",
+    "opening_language",
+    "shebang_language",
+]
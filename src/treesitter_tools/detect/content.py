@@ -0,0 +1,176 @@
+"""Language hints inside a file: `#!` interpreter lines, editor modelines, and telltale openings."""
+
+from __future__ import annotations
+
+import re
+from pathlib import Path
+from typing import Dict, List, Optional, Tuple
+
+from .. import charsets, overlay
+
+# Bytes read from each end of a file; modelines live in the first or last few lines.
+HEAD_BYTES = 4096
+TAIL_BYTES = 1024
+MODELINE_LINES = 5
+
+# Interpreter (as named on a `#!` line, version suffix stripped) -> language.
+INTERPRETERS: Dict[str, str] = {
+    "python": "python",
+    "pypy": "python",
+    "node": "javascript",
+    "nodejs": "javascript",
+    "deno": "typescript",
+    "bun": "javascript",
+    "ts-node": "typescript",
+    "tsx": "typescript",
+    "sh": "bash",
+    "bash": "bash",
+    "zsh": "bash",
+    "dash": "bash",
+    "ksh": "bash",
+    "ash": "bash",
+    "ruby": "ruby",
+    "perl": "perl",
+    "php": "php",
+    "lua": "lua",
+    "luajit": "lua",
+    "rscript": "r",
+    "make": "make",
+    "pwsh": "powershell",
+    "groovy": "groovy",
+    "julia": "julia",
+}
+
+# Editor names for a language -> ours (vim `ft=`, Emacs `mode:`); unlisted names are used as-is.
+MODELINE_ALIASES: Dict[str, str] = {
+    "sh": "bash",
+    "zsh": "bash",
+    "shell-script": "bash",
+    "js": "javascript",
+    "ts": "typescript",
+    "py": "python",
+    "python3": "python",
+    "rb": "ruby",
+    "cperl": "perl",
+    "makefile": "make",
+    "bzl": "starlark",
+    "c++": "cpp",
+    "objc": "objc",
+    "objective-c": "objc",
+    "cs": "csharp",
+    "rs": "rust",
+    "golang": "go",
+    "yml": "yaml",
+}
+
+_SHEBANG = re.compile(rb"^#!\s*(\S+)(?:[ \t]+(.*))?")
+_VIM = re.compile(r"(?:^|\s)(?:vim?|ex)(?:\d*):\s*(?:set?\s+)?(?P<opts>.*)")
+_VIM_FILETYPE = re.compile(r"(?:^|[\s:])(?:ft|filetype|syntax|syn)=(?P<ft>[\w+.-]+)")
+_EMACS = re.compile(r"-\*-\s*(?P<body>.*?)\s*-\*-")
+_EMACS_MODE = re.compile(r"(?:^|;)\s*mode:\s*(?P<mode>[\w+.-]+)", re.IGNORECASE)
+
+# Openings that identify a file regardless of its name: (prefix, language).
+OPENINGS: List[Tuple[bytes, str]] = [
+    (b"<?php", "php"),
+    (b"<?xml", "xml"),
+]
+
+
+def _ends(data: bytes) -> Tuple[bytes, bytes]:
+    return data[:HEAD_BYTES], data[max(len(data) - TAIL_BYTES, HEAD_BYTES):]
+
+
+def read_ends(path: Path) -> Optional[Tuple[bytes, bytes]]:
+    """
+    (head, tail) samples of a file; None for unreadable or binary (NUL-containing) files.
+    UTF-16 and UTF-32 files are sampled from their UTF-8 transcoding.
+    """
+    overlaid = overlay.contents(path)
+    if overlaid is not None:
+        head, tail = _ends(overlaid)
+    else:
+        try:
+            with Path(path).open("rb") as handle:
+                head = handle.read(HEAD_BYTES)
+                size = handle.seek(0, 2)
+                tail = b""
+                if size > HEAD_BYTES:
+                    handle.seek(max(size - TAIL_BYTES, HEAD_BYTES))
+                    tail = handle.read()
+        except OSError:
+            return None
+    if b"\x00" in head:
+        if charsets.wide_encoding(head) is None:
+            return None
+        try:
+            return _ends(overlay.read_bytes(path))
+        except OSError:
+            return None
+    return head, tail
+
+
+def shebang_language(head: bytes) -> Optional[str]:
+    """The language of a `#!/usr/bin/env python3`-style first line."""
+    match = _SHEBANG.match(head.split(b"\n", 1)[0].rstrip(b"\r"))
+    if match is None:
+        return None
+    program = match.group(1).decode("utf-8", "replace").rsplit("/", 1)[-1]
+    arguments = (match.group(2) or b"").decode("utf-8", "replace").split()
+    if program == "env":
+        # `env [-S] [VAR=value ...] interpreter [args]`
+        arguments = [a for a in arguments if not a.startswith("-") and "=" not in a]
+        if not arguments:
+            return None
+        program = arguments[0].rsplit("/", 1)[-1]
+    name = re.sub(r"[\d.]+$", "", program.lower())  # python3.12 -> python
+    return INTERPRETERS.get(name)
+
+
+def modeline_language(head: bytes, tail: bytes = b"") -> Optional[str]:
+    """
+    The language named by a vim modeline (`vim: set ft=python:`) in the first or last
+    few lines, or an Emacs `-*- mode: python -*-` line in the first two.
+    """
+    first = head.decode("utf-8", "replace").splitlines()
+    last = (tail or head).decode("utf-8", "replace").splitlines()
+    for line in first[:2]:
+        emacs = _EMACS.search(line)
+        if emacs is not None:
+            body = emacs.group("body")
+            mode = _EMACS_MODE.search(body)
+            name = mode.group("mode") if mode else (body if ":" not in body else None)
+            if name:
+                return _alias(name)
+    for line in first[:MODELINE_LINES] + last[-MODELINE_LINES:]:
+        vim = _VIM.search(line)
+        if vim is not None:
+            filetype = _VIM_FILETYPE.search(" " + vim.group("opts"))
+            if filetype is not None:
+                return _alias(filetype.group("ft"))
+    return None
+
+
+def _alias(name: str) -> str:
+    name = name.strip().lower()
+    if name.endswith("-mode"):
+        name = name[: -len("-mode")]
+    return MODELINE_ALIASES.get(name, name)
+
+
+def opening_language(head: bytes) -> Optional[str]:
+    stripped = head.lstrip(b"\xef\xbb\xbf").lstrip()
+    for prefix, language in OPENINGS:
+        if stripped.startswith(prefix):
+            return language
+    return None
+
+
+__all__ = [
+    "INTERPRETERS",
+    "MODELINE_ALIASES",
+    "OPENINGS",
+    "modeline_language",
+    "opening_language",
+    "read_ends",
+    "shebang_language",
+]
@@ -0,0 +1,72 @@
+"""Languages of well-known files that have no (or no telling) extension."""
+
+from __future__ import annotations
+
+import fnmatch
+from pathlib import Path
+from typing import Dict, List, Optional, Tuple
+
+# Exact base names.
+FILENAME_LANGUAGES: Dict[str, str] = {
+    "Dockerfile": "dockerfile",
+    "Containerfile": "dockerfile",
+    "Makefile": "make",
+    "makefile": "make",
+    "GNUmakefile": "make",
+    "BUILD": "starlark",
+    "WORKSPACE": "starlark",
+    "Tiltfile": "starlark",
+    "CMakeLists.txt": "cmake",
+    "Gemfile": "ruby",
+    "Rakefile": "ruby",
+    "Vagrantfile": "ruby",
+    "Podfile": "ruby",
+    "Guardfile": "ruby",
+    "Brewfile": "ruby",
+    "Jenkinsfile": "groovy",
+    "SConstruct": "python",
+    "SConscript": "python",
+    "Snakefile": "python",
+    ".bashrc": "bash",
+    ".bash_profile": "bash",
+    ".bash_logout": "bash",
+    ".profile": "bash",
+    ".zshrc": "bash",
+    ".zprofile": "bash",
+    ".zshenv": "bash",
+    "PKGBUILD": "bash",
+    "APKBUILD": "bash",
+}
+
+# Glob patterns on the base name, checked in order after the exact names.
+FILENAME_PATTERNS: List[Tuple[str, str]] = [
+    ("Dockerfile.*", "dockerfile"),
+    ("*.Dockerfile", "dockerfile"),
+    ("*.dockerfile", "dockerfile"),
+    ("Containerfile.*", "dockerfile"),
+    ("Makefile.*", "make"),
+    ("*.mk", "make"),
+    ("*.mak", "make"),
+    ("*.bzl", "starlark"),
+    ("*.bazel", "starlark"),  # BUILD.bazel, WORKSPACE.bazel, MODULE.bazel
+    ("*.star", "starlark"),
+    ("*.cmake", "cmake"),
+    ("*.gemspec", "ruby"),
+    ("*.podspec", "ruby"),
+    ("Jenkinsfile.*", "groovy"),
+]
+
+
+def filename_language(path: Path) -> Optional[str]:
+    """The language implied by `path`'s base name alone, if it is a well-known one."""
+    name = Path(path).name
+    language = FILENAME_LANGUAGES.get(name)
+    if language is not None:
+        return language
+    for pattern, language in FILENAME_PATTERNS:
+        if fnmatch.fnmatchcase(name, pattern):
+            return language
+    return None
+
+
+__all__ = ["FILENAME_LANGUAGES", "FILENAME_PATTERNS", "filename_language"]
@@ -0,0 +1,260 @@
+"""Syntax diagnostics from Tree-sitter ERROR and MISSING nodes."""
+
+from __future__ import annotations
+
+import json
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Collection, Iterator, List, Optional, Sequence
+
+from tree_sitter import Language, Node
+
+from . import failures
+from .core import ParsedFile, iter_source_files, load_language, parse_file
+from .export.sarif import SarifLocation, SarifResult, SarifRule, sarif_to_json
+
+# Expected-token lists longer than this are cut (the count is kept) to stay readable.
+MAX_EXPECTED = 12
+
+SARIF_RULES = {
+    "error": SarifRule(
+        "syntax-error", "SyntaxError", "Input the grammar cannot parse",
+        "Tree-sitter produced an ERROR node: the code does not parse as the detected language.", "error",
+        ("syntax",),
+    ),
+    "missing": SarifRule(
+        "missing-token", "MissingToken", "A required token is missing",
+        "Tree-sitter recovered by inserting a MISSING token, such as a closing bracket or semicolon.", "error",
+        ("syntax",),
+    ),
+}
+
+
+@dataclass
+class Diagnostic:
+    path: str
+    kind: str  # "error" (unparseable input) or "missing" (inserted token)
+    message: str
+    line: int
+    column: int
+    end_line: int
+    end_column: int
+    context: Optional[str] = None
+    expected: List[str] = field(default_factory=list)
+    # Set when the problem is in embedded code (SQL in a string, a <script> element)
+    language: Optional[str] = None
+
+    def to_dict(self) -> dict:
+        data = {
+            "path": self.path,
+            "kind": self.kind,
+            "message": self.message,
+            "line": self.line,
+            "column": self.column,
+            "end_line": self.end_line,
+            "end_column": self.end_column,
+            "context": self.context,
+            "expected": self.expected,
+        }
+        if self.language:
+            data["language"] = self.language
+        return data
+
+    def to_text(self) -> str:
+        text = f"{self.path}:{self.line}:{self.column}: {self.kind}: {self.message}"
+        if self.expected:
+            text += f" (expected one of: {', '.join(self.expected)})"
+        if self.language:
+            text += f" [{self.language}]"
+        return text
+
+
+def _previous_leaf(node: Node) -> Optional[Node]:
+    current = node
+    while current is not None and current.prev_sibling is None:
+        current = current.parent
+    if current is None:
+        return None
+    leaf = current.prev_sibling
+    while leaf.child_count:
+        leaf = leaf.children[-1]
+    return leaf
+
+
+def expected_tokens(language: Language, node: Node, limit: int = MAX_EXPECTED) -> List[str]:
+    """Visible symbols the grammar accepts right before `node`, from the parse state of the previous leaf."""
+    leaf = _previous_leaf(node)
+    if leaf is None:
+        return []
+    try:
+        iterator = language.lookahead_iterator(leaf.next_parse_state)
+        if iterator is None:
+            return []
+        names = set()
+        for symbol in iterator.symbols():
+            if symbol == 0:
+                names.add("end of file")
+            elif language.node_kind_is_visible(symbol):
+                names.add(language.node_kind_for_id(symbol))
+    except (AttributeError, ValueError, RuntimeError):
+        # Older bindings without lookahead support: report the location only.
+        return []
+    # Literal tokens first (what a user would type), then node kinds.
+    ordered = sorted((n for n in names if n and n != "ERROR"), key=lambda n: (n.isidentifier(), n))
+    if len(ordered) > limit:
+        ordered = ordered[:limit] + [f"... {len(ordered) - limit} more"]
+    return ordered
+
+
+def _snippet(parsed: ParsedFile, node: Node) -> str:
+    text = parsed.text(node).strip().splitlines()
+    first = text[0] if text else ""
+    return first if len(first) <= 40 else first[:37] + "..."
+
+
+def _iter_problem_nodes(root: Node) -> Iterator[Node]:
+    stack = [root]
+    while stack:
+        node = stack.pop()
+        if node.is_missing or node.type == "ERROR":
+            yield node
+            continue  # nested errors inside an ERROR add noise, not information
+        if node.has_error:
+            stack.extend(reversed(node.children))
+
+
+def file_diagnostics(
+    parsed: ParsedFile, label: Optional[str] = None, max_expected: int = MAX_EXPECTED, injections: bool = True
+) -> List[Diagnostic]:
+    """
+    Every ERROR / MISSING node in `parsed`, in source order, followed by those of its
+    embedded code (see `injections`), which are tagged with their language.
+    """
+    label = label or parsed.path.as_posix()
+    found = _tree_diagnostics(parsed, parsed.root, parsed.language, label, max_expected)
+    if injections:
+        from .injections import injected_trees
+
+        for language, root in injected_trees(parsed.root, parsed.source, parsed.language):
+            found.extend(_tree_diagnostics(parsed, root, language, label, max_expected, embedded=True))
+    return found
+
+
+def _tree_diagnostics(
+    parsed: ParsedFile, root: Node, language: str, label: str, max_expected: int, embedded: bool = False
+) -> List[Diagnostic]:
+    if not root.has_error:
+        return []
+    grammar = load_language(language)
+    found = []
+    for node in _iter_problem_nodes(root):
+        if node.is_missing:
+            kind, message = "missing", f"missing {node.type!r}"
+            expected: List[str] = []
+        else:
+            snippet = _snippet(parsed, node)
+            kind = "error"
+            message = f"unexpected {snippet!r}" if snippet else "syntax error"
+            expected = expected_tokens(grammar, node, max_expected) if max_expected else []
+        parent = node.parent
+        found.append(
+            Diagnostic(
+                path=label,
+                kind=kind,
+                message=message,
+                line=node.start_point[0] + 1,
+                column=node.start_point[1] + 1,
+                end_line=node.end_point[0] + 1,
+                end_column=node.end_point[1] + 1,
+                context=parent.type if parent is not None and parent.parent is not None else None,
+                expected=expected,
+                language=language if embedded else None,
+            )
+        )
+    return found
+
+
+@dataclass
+class DiagnosticsReport:
+    checked: int = 0
+    diagnostics: List[Diagnostic] = field(default_factory=list)
+
+    @property
+    def files_with_errors(self) -> int:
+        return len({d.path for d in self.diagnostics})
+
+    def to_json(self) -> str:
+        return json.dumps(
+            {
+                "checked": self.checked,
+                "files_with_errors": self.files_with_errors,
+                "diagnostics": [d.to_dict() for d in self.diagnostics],
+            },
+            indent=2,
+        )
+
+    def to_text(self) -> str:
+        return "".join(d.to_text() + "\n" for d in self.diagnostics)
+
+    def to_sarif(self, root: Optional[Path] = None) -> str:
+        results = []
+        for d in self.diagnostics:
+            message = d.message + (f" (expected one of: {', '.join(d.expected)})" if d.expected else "")
+            location = SarifLocation(d.path, d.line, d.column, d.end_line, d.end_column)
+            properties = {"language": d.language} if d.language else {}
+            results.append(
+                SarifResult(SARIF_RULES[d.kind], message, location, (d.message, d.context or ""), properties=properties)
+            )
+        return sarif_to_json(results, list(SARIF_RULES.values()), root)
+
+    def summary(self) -> str:
+        return (
+            f"Checked {self.checked} files: {len(self.diagnostics)} syntax problems "
+            f"in {self.files_with_errors} files"
+        )
+
+
+def check_paths(
+    paths: Sequence[Path],
+    include: Sequence[str] | None = None,
+    exclude: Sequence[str] | None = None,
+    language: Optional[str] = None,
+    max_expected: int = MAX_EXPECTED,
+    only: Optional[Collection[Path]] = None,
+) -> DiagnosticsReport:
+    """
+    Diagnose files and directories. Files of unknown languages are skipped so the
+    checker can be handed every staged file (e.g. from a pre-commit hook); `only`
+    limits both walks and file arguments to the given resolved paths.
+    """
+    report = DiagnosticsReport()
+    for path in paths:
+        path = Path(path)
+        if path.is_dir():
+            base = path.resolve()
+            files = iter_source_files(base, include, exclude, only)
+            targets = [(p, (path / p.relative_to(base)).as_posix()) for p in files]
+        elif only is not None and path.resolve() not in only:
+            continue
+        else:
+            targets = [(path, path.as_posix())]
+        for target, label in targets:
+            try:
+                parsed = parse_file(target, language if not path.is_dir() else None)
+            except (ValueError, RuntimeError, OSError) as exc:
+                failures.record(target, exc)
+                continue
+            report.checked += 1
+            report.diagnostics.extend(file_diagnostics(parsed, label, max_expected))
+    return report
+
+
+__all__ = [
+    "Diagnostic",
+    "DiagnosticsReport",
+    "MAX_EXPECTED",
+    "SARIF_RULES",
+    "check_paths",
+    "expected_tokens",
+    "file_diagnostics",
+]
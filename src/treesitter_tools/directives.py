@@ -0,0 +1,308 @@
+"""Comment directives (TODO/FIXME/HACK markers, lint suppressions, pragmas) attached to the code they annotate."""
+
+from __future__ import annotations
+
+import json
+import re
+from dataclasses import dataclass, field
+from datetime import datetime, timezone
+from pathlib import Path
+from typing import Callable, Iterator, List, Optional, Sequence, Tuple
+
+from tree_sitter import Node
+
+from . import failures, reproducible
+from .core import ParsedFile, _identifier_from, iter_class_nodes, iter_function_nodes, iter_source_files, parse_file
+from .gitdiff import repo_root
+from .hotspots import _UNCOMMITTED, BlameLine, blame_file
+
+MARKER_TAGS = ("TODO", "FIXME", "HACK", "XXX", "BUG")
+
+# `TODO(alice): ...`, `FIXME[bob] ...`, `HACK @carol - ...`, `XXX: ...`
+_MARKER = re.compile(
+    r"(?P<tag>" + "|".join(MARKER_TAGS) + r")\b"
+    r"(?:\((?P<paren>[^)]*)\)|\[(?P<bracket>[^\]]*)\]|\s+@(?P<at>[\w.-]+))?"
+    r"\s*[:\-]?\s*(?P<text>.*)"
+)
+
+# Suppressions: (tag, pattern); `rules` is a comma- or space-separated list, `text` a reason,
+# and `name` completes the tag (`ts-` + `expect-error`).
+_SUPPRESSIONS: List[Tuple[str, re.Pattern]] = [
+    ("nolint", re.compile(r"^//\s*nolint(?::(?P<rules>[\w,-]+))?\s*(?://\s*(?P<text>.*))?$")),
+    ("noqa", re.compile(r"#\s*noqa\b(?::\s*(?P<rules>[\w, ]+))?")),
+    ("type-ignore", re.compile(r"#\s*type:\s*ignore\b(?:\[(?P<rules>[\w, -]+)\])?")),
+    ("pylint", re.compile(r"pylint:\s*disable(?:-next)?=(?P<rules>[\w, -]+)")),
+    (
+        "eslint-",
+        re.compile(r"eslint-(?P<name>disable(?:-next-line|-line)?)\b(?:[ \t]+(?P<rules>[^\s*][^*]*?))?(?:\s+--\s*(?P<text>.*?))?\s*(?:\*/)?$"),
+    ),
+    ("ts-", re.compile(r"@ts-(?P<name>ignore|expect-error|nocheck)\b\s*(?P<text>.*)")),
+]
+
+# Tool directives written as comments: `//go:generate ...`, `//go:build ...`, `//go:embed ...`.
+_GO_DIRECTIVE = re.compile(r"^//go:(?P<name>\w+)\s*(?P<text>.*)$")
+
+_COMMENT_WRAPPERS = ("/**", "/*", "*/", "///", "//!", "//", "#", "--", ";", "*")
+
+_NAMED_NODE_TYPES = {
+    "function_definition", "function_declaration", "method_declaration", "method_definition", "class_definition",
+    "class_declaration", "function_item", "impl_item", "struct_item", "type_declaration", "decorated_definition",
+}
+
+
+@dataclass
+class AttachedNode:
+    type: str
+    line: int
+    name: Optional[str] = None
+
+    def to_dict(self) -> dict:
+        return {"type": self.type, "line": self.line, "name": self.name}
+
+
+@dataclass
+class Directive:
+    path: str
+    line: int  # 1-based
+    column: int  # 1-based
+    category: str  # "marker", "suppression", or "pragma"
+    tag: str  # TODO, FIXME, nolint, noqa, eslint-disable-next-line, pragma, go:generate, ...
+    text: str
+    assignee: Optional[str] = None  # markers: TODO(alice), TODO @alice
+    rules: List[str] = field(default_factory=list)  # suppressions: nolint:errcheck,gosec
+    symbol: Optional[str] = None  # innermost enclosing function or type
+    node: Optional[AttachedNode] = None  # the code the comment annotates
+    owner: Optional[str] = None  # git blame author of the comment line
+    committed: Optional[str] = None  # UTC date the line was last committed
+    age_days: Optional[int] = None
+
+    def to_dict(self) -> dict:
+        return {
+            "path": self.path,
+            "line": self.line,
+            "column": self.column,
+            "category": self.category,
+            "tag": self.tag,
+            "text": self.text,
+            "assignee": self.assignee,
+            "rules": self.rules,
+            "symbol": self.symbol,
+            "node": self.node.to_dict() if self.node else None,
+            "owner": self.owner,
+            "committed": self.committed,
+            "age_days": self.age_days,
+        }
+
+
+def _strip_comment(line: str) -> str:
+    line = line.strip()
+    for wrapper in _COMMENT_WRAPPERS:
+        if line.startswith(wrapper):
+            line = line[len(wrapper):]
+            break
+    if line.endswith("*/"):
+        line = line[:-2]
+    return line.strip()
+
+
+def _split_rules(rules: Optional[str]) -> List[str]:
+    return [r for r in re.split(r"[,\s]+", rules or "") if r]
+
+
+def parse_directives(comment: str) -> Iterator[Tuple[int, int, str, str, str, Optional[str], List[str]]]:
+    """(line offset, column offset, category, tag, text, assignee, rules) for each directive in a comment."""
+    for offset, raw in enumerate(comment.splitlines() or [comment]):
+        stripped = raw.strip()
+        indent = len(raw) - len(raw.lstrip())
+        go = _GO_DIRECTIVE.match(stripped)
+        if go:
+            yield offset, indent, "pragma", f"go:{go.group('name')}", go.group("text").strip(), None, []
+            continue
+        found = False
+        for tag, pattern in _SUPPRESSIONS:
+            match = pattern.search(stripped)
+            if match:
+                groups = match.groupdict()
+                text = (groups.get("text") or "").strip()
+                tag += groups.get("name") or ""
+                yield offset, indent + match.start(), "suppression", tag, text, None, _split_rules(groups.get("rules"))
+                found = True
+                break
+        if found:
+            continue
+        # Markers open the comment line, so prose that mentions "TODO" is not one.
+        marker = _MARKER.match(_strip_comment(raw))
+        if marker:
+            assignee = marker.group("paren") or marker.group("bracket") or marker.group("at")
+            column = raw.find(marker.group("tag"))
+            text = marker.group("text").strip().rstrip("*/").strip()
+            yield offset, max(column, 0), "marker", marker.group("tag"), text, (assignee or "").strip() or None, []
+
+
+def _attached(comment: Node, parsed: ParsedFile) -> Optional[Node]:
+    """
+    The code a comment annotates: the statement it trails on the same line, else
+    the next statement (`// eslint-disable-next-line`, a TODO above a function),
+    else the block it closes.
+    """
+    previous = comment.prev_named_sibling
+    if previous is not None and "comment" not in previous.type and previous.end_point[0] == comment.start_point[0]:
+        return previous
+    following = comment.next_named_sibling
+    while following is not None and "comment" in following.type:
+        following = following.next_named_sibling
+    if following is not None:
+        return following
+    return comment.parent if comment.parent is not None and comment.parent != parsed.root else None
+
+
+def _describe(node: Node, parsed: ParsedFile) -> AttachedNode:
+    if node.type == "decorated_definition":
+        node = node.child_by_field_name("definition") or node
+    name = _identifier_from(node, parsed.source) if node.type in _NAMED_NODE_TYPES else None
+    return AttachedNode(node.type, node.start_point[0] + 1, name)
+
+
+def _symbol_spans(parsed: ParsedFile) -> List[Tuple[int, int, str]]:
+    spans = [(fn.node.start_byte, fn.node.end_byte, fn.qualified_name) for fn in iter_function_nodes(parsed)]
+    spans += [(cls.node.start_byte, cls.node.end_byte, cls.qualified_name) for cls in iter_class_nodes(parsed)]
+    return sorted(spans, key=lambda span: (span[0], -span[1]))
+
+
+def _enclosing(spans: Sequence[Tuple[int, int, str]], node: Node) -> Optional[str]:
+    found = None
+    for start, end, name in spans:
+        if start > node.start_byte:
+            break
+        if node.end_byte <= end:
+            found = name
+    return found
+
+
+def _pragma(node: Node, parsed: ParsedFile) -> Optional[Tuple[str, str]]:
+    """C/C++ `#pragma ...` lines, which tree-sitter parses as preprocessor calls rather than comments."""
+    if node.type != "preproc_call":
+        return None
+    directive = node.child_by_field_name("directive")
+    if directive is None or parsed.text(directive).strip() != "#pragma":
+        return None
+    argument = node.child_by_field_name("argument")
+    return "pragma", parsed.text(argument).strip() if argument is not None else ""
+
+
+def file_directives(parsed: ParsedFile, label: str) -> List[Directive]:
+    """Every directive in `parsed`'s comments (and C/C++ pragmas), in source order."""
+    spans = _symbol_spans(parsed)
+    found: List[Directive] = []
+    stack = [parsed.root]
+    while stack:
+        node = stack.pop()
+        pragma = _pragma(node, parsed)
+        if pragma is not None:
+            tag, text = pragma
+            attached = node.next_named_sibling
+            found.append(Directive(
+                label, node.start_point[0] + 1, node.start_point[1] + 1, "pragma", tag, text,
+                symbol=_enclosing(spans, node), node=_describe(attached, parsed) if attached is not None else None,
+            ))
+            continue
+        if "comment" not in node.type:
+            stack.extend(reversed(node.children))
+            continue
+        text = parsed.text(node)
+        for offset, column, category, tag, message, assignee, rules in parse_directives(text):
+            attached = _attached(node, parsed)
+            line = node.start_point[0] + offset + 1
+            col = (node.start_point[1] if offset == 0 else 0) + column + 1
+            found.append(Directive(
+                label, line, col, category, tag, message, assignee, rules,
+                symbol=_enclosing(spans, node), node=_describe(attached, parsed) if attached is not None else None,
+            ))
+    return found
+
+
+def _apply_blame(directives: Sequence[Directive], blame: Sequence[BlameLine], now: float) -> None:
+    for directive in directives:
+        if directive.line > len(blame):
+            continue
+        line = blame[directive.line - 1]
+        if line.commit == _UNCOMMITTED:
+            continue  # not committed yet: no owner or age
+        directive.owner = line.author
+        directive.committed = datetime.fromtimestamp(line.time, timezone.utc).strftime("%Y-%m-%d")
+        directive.age_days = max(int((now - line.time) // 86400), 0)
+
+
+def collect_directives(
+    root: Path,
+    include: Sequence[str] | None = None,
+    exclude: Sequence[str] | None = None,
+    categories: Sequence[str] | None = None,
+    tags: Sequence[str] | None = None,
+    git: bool = True,
+    now: Callable[[], float] = reproducible.now,
+) -> List[Directive]:
+    """
+    Directives under a file or directory, optionally limited to `categories` and
+    `tags` (case-insensitive). With `git`, each gets the blame author and age of its
+    line; files outside a git work tree (and uncommitted lines) get none.
+    """
+    root = Path(root)
+    if root.is_file():
+        targets = [(root.resolve(), root.as_posix())]
+    else:
+        base = root.resolve()
+        targets = [(p, p.relative_to(base).as_posix()) for p in iter_source_files(base, include, exclude)]
+    wanted_tags = {t.lower() for t in tags or ()}
+    repo: Optional[Path] = None
+    if git:
+        try:
+            repo = repo_root(root)
+        except (ValueError, RuntimeError):
+            repo = None
+    found: List[Directive] = []
+    timestamp = now()
+    for path, label in targets:
+        try:
+            parsed = parse_file(path)
+        except (ValueError, RuntimeError, OSError) as exc:
+            failures.record(path, exc)
+            continue
+        directives = [
+            d for d in file_directives(parsed, label)
+            if (not categories or d.category in categories) and (not wanted_tags or d.tag.lower() in wanted_tags)
+        ]
+        if directives and repo is not None:
+            try:
+                _apply_blame(directives, blame_file(repo, path.relative_to(repo).as_posix()), timestamp)
+            except ValueError:
+                pass  # untracked file
+        found.extend(directives)
+    return found
+
+
+def directives_to_json(directives: Sequence[Directive]) -> str:
+    return json.dumps([d.to_dict() for d in directives], indent=2)
+
+
+def directives_to_text(directives: Sequence[Directive]) -> str:
+    """`path:line:column TAG(assignee) [symbol] text`, with owner and age when committed."""
+    lines = []
+    for d in directives:
+        tag = d.tag + (f"({d.assignee})" if d.assignee else "") + (f":{','.join(d.rules)}" if d.rules else "")
+        symbol = f" [{d.symbol}]" if d.symbol else ""
+        blame = f" ({d.owner}, {d.age_days}d)" if d.owner is not None else ""
+        lines.append(f"{d.path}:{d.line}:{d.column} {tag}{symbol} {d.text}".rstrip() + blame)
+    return "".join(line + "\n" for line in lines)
+
+
+__all__ = [
+    "MARKER_TAGS",
+    "AttachedNode",
+    "Directive",
+    "collect_directives",
+    "directives_to_json",
+    "directives_to_text",
+    "file_directives",
+    "parse_directives",
+]
@@ -0,0 +1,384 @@
+"""
+Static reference docs: one Markdown or HTML page per package/module listing its
+declarations with signatures and doc comments, cross-linked through the symbol index.
+
+Names in signatures, `code spans` and Go-style `[Name]` links in doc comments, and
+the references the index recorded (calls and generic instantiations) link to the
+declarations they name when the name resolves to one documented declaration,
+preferring the page's own package.
+"""
+
+from __future__ import annotations
+
+import html
+import re
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Callable, Dict, List, Optional, Sequence
+
+from .apisurface import module_name
+from .core import is_anonymous
+from .index import SymbolIndex
+from .normalize import FUNCTION_KINDS
+from .testmap import is_test_file
+
+FORMATS = ("markdown", "html")
+EXTENSIONS = {"markdown": ".md", "html": ".html"}
+INDEX_PAGE = "index"
+SUMMARY_LENGTH = 100
+
+_IDENTIFIER = re.compile(r"[A-Za-z_][A-Za-z0-9_]*(?:\.[A-Za-z_][A-Za-z0-9_]*)*")
+# A `code span`, or a Go-style [Name] doc link (not a Markdown [text](url) link).
+_DOC_REFERENCE = re.compile(r"`([^`\n]+)`|\[([A-Za-z_][\w.]*)\](?!\()")
+_ANCHOR_UNSAFE = re.compile(r"[^A-Za-z0-9_.-]")
+_STYLE = (
+    "body{font:15px/1.5 system-ui,sans-serif;max-width:60rem;margin:2rem auto;padding:0 1rem;color:#222}"
+    "pre{background:#f5f5f5;padding:.6rem .8rem;overflow-x:auto}code{font:13px ui-monospace,monospace}"
+    "a{color:#0550ae;text-decoration:none}a:hover{text-decoration:underline}"
+    "table{border-collapse:collapse}td,th{padding:.2rem .8rem .2rem 0;text-align:left;vertical-align:top}"
+    ".refs,.source{font-size:13px;color:#555}h2,h3{margin-top:2rem}"
+)
+
+
+@dataclass
+class DocEntry:
+    kind: str
+    name: str
+    qualified_name: str
+    container: Optional[str]
+    path: str
+    language: str
+    line: int
+    signature: Optional[str]
+    docstring: Optional[str]
+    package: str = ""  # slug of the page the entry is on
+    members: List["DocEntry"] = field(default_factory=list)
+    uses: List["DocEntry"] = field(default_factory=list)
+    used_by: List["DocEntry"] = field(default_factory=list)
+
+    @property
+    def anchor(self) -> str:
+        return _ANCHOR_UNSAFE.sub("-", self.qualified_name)
+
+    @property
+    def summary(self) -> str:
+        """First sentence of the doc comment."""
+        text = " ".join((self.docstring or "").split())
+        match = re.match(r"(.+?[.!?])(\s|$)", text)
+        text = match.group(1) if match else text
+        return text if len(text) <= SUMMARY_LENGTH else text[: SUMMARY_LENGTH - 3].rstrip() + "..."
+
+
+@dataclass
+class DocPackage:
+    name: str
+    language: str
+    slug: str
+    files: List[str] = field(default_factory=list)
+    entries: List[DocEntry] = field(default_factory=list)  # top level; members hang off their container
+
+    def walk(self) -> List[DocEntry]:
+        """Every entry, members after their container."""
+        found: List[DocEntry] = []
+        stack = list(reversed(self.entries))
+        while stack:
+            entry = stack.pop()
+            found.append(entry)
+            stack.extend(reversed(entry.members))
+        return found
+
+    @property
+    def symbols(self) -> int:
+        return len(self.walk())
+
+
+def _exported(name: str, language: str) -> bool:
+    """The language-agnostic notion of public: capitalized in Go, no leading underscore elsewhere."""
+    if is_anonymous(name):
+        return False
+    if language == "go":
+        return name[:1].isupper()
+    return not name.startswith("_") or (name.startswith("__") and name.endswith("__"))
+
+
+def _short_name(package: str) -> str:
+    """How code refers to a package: `coll` for `internal/coll`, `db` for `app.db`."""
+    return re.split(r"[/.]", package)[-1]
+
+
+def _slug(name: str, language: str, taken: set) -> str:
+    slug = "_root" if name == "." else _ANCHOR_UNSAFE.sub("-", name.replace("/", "-"))
+    if slug == INDEX_PAGE or slug in taken:
+        slug = f"{slug}-{language}"
+    taken.add(slug)
+    return slug
+
+
+class DocSite:
+    """Packages of documented entries and the links between them; `.pages(fmt)` renders them."""
+
+    def __init__(self, packages: List[DocPackage], title: str = "API reference", source_url: Optional[str] = None):
+        self.packages = packages
+        self.title = title
+        self.source_url = source_url  # template with {path} and {line}
+        self._by_name: Dict[str, List[DocEntry]] = {}
+        self._packages = {p.slug: p for p in packages}
+        for entry in self.entries():
+            self._by_name.setdefault(entry.name, []).append(entry)
+            if entry.qualified_name != entry.name:
+                self._by_name.setdefault(entry.qualified_name, []).append(entry)
+
+    def entries(self) -> List[DocEntry]:
+        return [e for p in self.packages for e in p.walk()]
+
+    def resolve(self, name: str, package: str, exclude: Optional[DocEntry] = None) -> Optional[DocEntry]:
+        """The one documented entry `name` (`Name`, `Type.member`, or `pkg.Name`) means on `package`'s page."""
+        candidates = [e for e in self._by_name.get(name, []) if e is not exclude]
+        if not candidates and "." in name:
+            qualifier, _, member = name.rpartition(".")
+            candidates = [
+                e for e in self._by_name.get(member, [])
+                if e is not exclude and _short_name(self._packages[e.package].name) == qualifier
+            ]
+        local = [e for e in candidates if e.package == package]
+        candidates = local or candidates
+        return candidates[0] if len(candidates) == 1 else None
+
+    def link_references(self, references: Sequence[dict]) -> None:
+        """
+        Fill `uses`/`used_by` from index references (`SymbolIndex.references()`), resolving
+        each name the way the call graph resolves callees.
+        """
+        callers = {(e.path, e.qualified_name): e for e in self.entries()}
+        for ref in references:
+            caller = callers.get((ref["path"], ref["caller"]))
+            if caller is None:
+                continue
+            candidates = [e for e in self._by_name.get(ref["name"], []) if e.name == ref["name"] and e is not caller]
+            if ref["receiver_type"]:
+                candidates = [c for c in candidates if c.container == ref["receiver_type"]] or candidates
+            elif ref["receiver"] is None:
+                candidates = [c for c in candidates if c.container is None] or candidates
+            candidates = [c for c in candidates if c.package == caller.package] or candidates
+            if len(candidates) == 1 and candidates[0] not in caller.uses:
+                caller.uses.append(candidates[0])
+                candidates[0].used_by.append(caller)
+
+    # -- rendering ----------------------------------------------------------
+
+    def _href(self, target: DocEntry, package: str, fmt: str) -> str:
+        page = "" if target.package == package else target.package + EXTENSIONS[fmt]
+        return f"{page}#{target.anchor}"
+
+    def _source(self, entry: DocEntry) -> tuple[str, Optional[str]]:
+        label = f"{entry.path}:{entry.line}"
+        url = self.source_url.format(path=entry.path, line=entry.line) if self.source_url else None
+        return label, url
+
+    def _link_doc(self, text: str, entry: DocEntry, fmt: str) -> str:
+        """Doc comment text with resolvable code spans and `[Name]` links turned into links."""
+        markdown = fmt == "markdown"
+
+        def link(match: re.Match) -> str:
+            name = match.group(1) or match.group(2)
+            target = self.resolve(name, entry.package, entry)
+            label = (f"`{name}`" if markdown else f"<code>{name}</code>") if match.group(1) else name
+            if target is None:
+                return label if match.group(1) else match.group(0)
+            href = self._href(target, entry.package, fmt)
+            return f"[{label}]({href})" if markdown else f'<a href="{href}">{label}</a>'
+
+        if not markdown:
+            text = html.escape(text, quote=False)
+        return _DOC_REFERENCE.sub(link, text)
+
+    def _signature_html(self, entry: DocEntry) -> str:
+        out = []
+        cursor = 0
+        signature = entry.signature or ""
+        for match in _IDENTIFIER.finditer(signature):
+            target = self.resolve(match.group(0), entry.package, entry)
+            if target is None:
+                continue
+            out.append(html.escape(signature[cursor:match.start()], quote=False))
+            out.append(f'<a href="{self._href(target, entry.package, "html")}">{html.escape(match.group(0))}</a>')
+            cursor = match.end()
+        out.append(html.escape(signature[cursor:], quote=False))
+        return "".join(out)
+
+    def _signature_links(self, entry: DocEntry) -> List[DocEntry]:
+        """Documented declarations the signature names, for Markdown (links do not render in code blocks)."""
+        found: List[DocEntry] = []
+        for match in _IDENTIFIER.finditer(entry.signature or ""):
+            target = self.resolve(match.group(0), entry.package, entry)
+            if target is not None and target not in found:
+                found.append(target)
+        return found
+
+    def _markdown_entry(self, entry: DocEntry, level: int) -> List[str]:
+        heading = "#" * min(level, 6)
+        lines = [f'<a id="{entry.anchor}"></a>', "", f"{heading} {entry.kind} `{entry.qualified_name}`", ""]
+        if entry.signature:
+            lines += [f"```{entry.language}", entry.signature, "```", ""]
+        if entry.docstring:
+            lines += [self._link_doc(entry.docstring, entry, "markdown"), ""]
+
+        def links(targets: Sequence[DocEntry]) -> str:
+            return ", ".join(f"[`{t.qualified_name}`]({self._href(t, entry.package, 'markdown')})" for t in targets)
+
+        uses = self._signature_links(entry)
+        uses += [t for t in entry.uses if t not in uses]
+        for label, targets in (("Uses", uses), ("Used by", entry.used_by)):
+            if targets:
+                lines += [f"{label}: {links(targets)}", ""]
+        label, url = self._source(entry)
+        lines += [f"Source: [{label}]({url})" if url else f"Source: `{label}`", ""]
+        for member in entry.members:
+            lines += self._markdown_entry(member, level + 1)
+        return lines
+
+    def _html_entry(self, entry: DocEntry, level: int) -> List[str]:
+        level = min(level, 6)
+        name = html.escape(entry.qualified_name)
+        lines = [f'<h{level} id="{entry.anchor}">{entry.kind} <code>{name}</code></h{level}>']
+        if entry.signature:
+            lines.append(f"<pre><code>{self._signature_html(entry)}</code></pre>")
+        if entry.docstring:
+            paragraphs = re.split(r"\n\s*\n", self._link_doc(entry.docstring, entry, "html").strip())
+            lines += [f"<p>{p}</p>" for p in paragraphs]
+        for label, targets in (("Uses", entry.uses), ("Used by", entry.used_by)):
+            if targets:
+                refs = ", ".join(
+                    f'<a href="{self._href(t, entry.package, "html")}"><code>{html.escape(t.qualified_name)}</code></a>'
+                    for t in targets
+                )
+                lines.append(f'<p class="refs">{label}: {refs}</p>')
+        label, url = self._source(entry)
+        source = f'<a href="{html.escape(url)}">{html.escape(label)}</a>' if url else html.escape(label)
+        lines.append(f'<p class="source">Source: {source}</p>')
+        for member in entry.members:
+            lines += self._html_entry(member, level + 1)
+        return lines
+
+    def _markdown_page(self, package: DocPackage) -> str:
+        lines = [f"# `{package.name}` ({package.language})", "", f"[{self.title}]({INDEX_PAGE}.md)", ""]
+        lines += ["Files: " + ", ".join(f"`{f}`" for f in package.files), ""]
+        lines += ["| Symbol | Kind | Summary |", "| --- | --- | --- |"]
+        for entry in package.entries:
+            summary = entry.summary.replace("|", "\\|")
+            lines.append(f"| [`{entry.qualified_name}`](#{entry.anchor}) | {entry.kind} | {summary} |")
+        lines.append("")
+        for entry in package.entries:
+            lines += self._markdown_entry(entry, 2)
+        return "\n".join(lines).rstrip() + "\n"
+
+    def _html_document(self, title: str, body: List[str]) -> str:
+        head = [
+            "<!DOCTYPE html>", '<html lang="en">', "<head>", '<meta charset="utf-8">',
+            f"<title>{html.escape(title)}</title>", f"<style>{_STYLE}</style>", "</head>", "<body>",
+        ]
+        return "\n".join([*head, *body, "</body>", "</html>"]) + "\n"
+
+    def _html_page(self, package: DocPackage) -> str:
+        body = [
+            f'<p><a href="{INDEX_PAGE}.html">{html.escape(self.title)}</a></p>',
+            f"<h1><code>{html.escape(package.name)}</code> ({package.language})</h1>",
+            "<p>Files: " + ", ".join(f"<code>{html.escape(f)}</code>" for f in package.files) + "</p>",
+            "<table>",
+        ]
+        for entry in package.entries:
+            body.append(
+                f'<tr><td><a href="#{entry.anchor}"><code>{html.escape(entry.qualified_name)}</code></a></td>'
+                f"<td>{entry.kind}</td><td>{html.escape(entry.summary)}</td></tr>"
+            )
+        body.append("</table>")
+        for entry in package.entries:
+            body += self._html_entry(entry, 2)
+        return self._html_document(f"{package.name} - {self.title}", body)
+
+    def _index(self, fmt: str) -> str:
+        ext = EXTENSIONS[fmt]
+        if fmt == "markdown":
+            lines = [f"# {self.title}", "", "| Package | Language | Symbols |", "| --- | --- | --- |"]
+            lines += [f"| [`{p.name}`]({p.slug}{ext}) | {p.language} | {p.symbols} |" for p in self.packages]
+            return "\n".join(lines) + "\n"
+        rows = [
+            f'<tr><td><a href="{p.slug}{ext}"><code>{html.escape(p.name)}</code></a></td>'
+            f"<td>{p.language}</td><td>{p.symbols}</td></tr>"
+            for p in self.packages
+        ]
+        header = "<tr><th>Package</th><th>Language</th><th>Symbols</th></tr>"
+        body = [f"<h1>{html.escape(self.title)}</h1>", "<table>", header, *rows, "</table>"]
+        return self._html_document(self.title, body)
+
+    def pages(self, fmt: str = "markdown") -> Dict[str, str]:
+        """File name -> content: the index page and one page per package."""
+        if fmt not in FORMATS:
+            raise ValueError(f"Unsupported docs format '{fmt}' (expected {' or '.join(FORMATS)})")
+        render: Callable[[DocPackage], str] = self._markdown_page if fmt == "markdown" else self._html_page
+        pages = {INDEX_PAGE + EXTENSIONS[fmt]: self._index(fmt)}
+        for package in self.packages:
+            pages[package.slug + EXTENSIONS[fmt]] = render(package)
+        return pages
+
+    def write(self, out_dir: Path, fmt: str = "markdown") -> List[Path]:
+        out_dir = Path(out_dir)
+        out_dir.mkdir(parents=True, exist_ok=True)
+        written = []
+        for name, content in self.pages(fmt).items():
+            path = out_dir / name
+            path.write_text(content, encoding="utf-8")
+            written.append(path)
+        return written
+
+
+def build_site(
+    index: SymbolIndex,
+    private: bool = False,
+    tests: bool = False,
+    title: str = "API reference",
+    source_url: Optional[str] = None,
+) -> DocSite:
+    """
+    Group the index's definitions by package. Only exported declarations (and members
+    of exported types) are documented unless `private`; test files only with `tests`.
+    """
+    grouped: Dict[tuple, List[DocEntry]] = {}
+    for row in index.definitions():
+        if not tests and is_test_file(row["path"], row["language"]):
+            continue
+        names = [*(row["container"] or "").split("."), row["name"]] if row["container"] else [row["name"]]
+        if not private and not all(_exported(n, row["language"]) for n in names):
+            continue
+        if is_anonymous(row["name"]):
+            continue
+        entry = DocEntry(
+            kind=row["kind"], name=row["name"], qualified_name=row["qualified_name"], container=row["container"],
+            path=row["path"], language=row["language"], line=row["start_line"],
+            signature=row["signature"], docstring=row["docstring"],
+        )
+        grouped.setdefault((module_name(row["path"], row["language"]), row["language"]), []).append(entry)
+
+    taken: set = set()
+    packages = []
+    for (name, language), entries in sorted(grouped.items()):
+        package = DocPackage(name, language, _slug(name, language, taken))
+        package.files = sorted({e.path for e in entries})
+        containers: Dict[str, DocEntry] = {}
+        for entry in entries:
+            if entry.kind not in FUNCTION_KINDS:
+                containers.setdefault(entry.qualified_name, entry)
+        for entry in sorted(entries, key=lambda e: (e.qualified_name, e.path, e.line)):
+            entry.package = package.slug
+            parent = containers.get(entry.container) if entry.container else None
+            if parent is not None and parent is not entry:
+                parent.members.append(entry)
+            else:
+                package.entries.append(entry)
+        packages.append(package)
+    site = DocSite(packages, title, source_url)
+    site.link_references(index.references())
+    return site
+
+
+__all__ = ["DocEntry", "DocPackage", "DocSite", "EXTENSIONS", "FORMATS", "build_site"]
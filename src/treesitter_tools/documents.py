@@ -0,0 +1,153 @@
+"""
+Code inside documents: fenced code blocks of Markdown files and code cells of
+Jupyter notebooks (`.ipynb`).
+
+Each block is parsed on its own with the grammar its info string (```` ```python ````)
+or the notebook's kernel names, and its symbols carry their provenance: `block`, the
+block's position in the document (fences counted in order, notebook cells by their
+index, markdown cells included), and for notebooks the cell's `cell_id`. Markdown
+symbols keep document line numbers; notebook symbols are numbered from the top of
+their cell. Blocks in a language without a grammar (```` ```text ````, ```` ```console ````)
+are skipped.
+"""
+
+from __future__ import annotations
+
+import json
+import re
+from dataclasses import dataclass
+from typing import List, Optional
+
+from tree_sitter import Node
+
+from .core import LANGUAGE_MAPPINGS, CodeSymbol, get_parser, symbols_from_tree
+from .detect import MODELINE_ALIASES
+
+DOCUMENT_LANGUAGES = ("markdown", "notebook")
+
+# Cell magics that switch a notebook cell to another language (`%%bash` ...).
+CELL_MAGICS = {
+    "bash": "bash",
+    "sh": "bash",
+    "html": "html",
+    "javascript": "javascript",
+    "js": "javascript",
+    "ruby": "ruby",
+    "sql": "sql",
+}
+
+# IPython line magics and shell escapes, which are not Python.
+_IPYTHON_LINE = re.compile(r"^\s*[%!]")
+
+
+@dataclass
+class CodeBlock:
+    index: int
+    language: Optional[str]
+    code: str
+    start_line: int = 1  # document line of the block's first line (1 for notebook cells)
+    cell_id: Optional[str] = None
+
+
+def block_language(info: str) -> Optional[str]:
+    """The language of a fence info string: `python`, `{python}` (Quarto), `js title="x"`."""
+    word = re.split(r"[\s,{}=]+", info.strip().strip("{}"), maxsplit=1)[0].lower()
+    if not word:
+        return None
+    word = MODELINE_ALIASES.get(word, word)
+    return LANGUAGE_MAPPINGS.get(word, word)
+
+
+def markdown_blocks(root: Node, source: bytes) -> List[CodeBlock]:
+    """Fenced code blocks of a Markdown tree, in document order (indented code blocks have no language)."""
+    blocks: List[CodeBlock] = []
+    stack = [root]
+    while stack:
+        node = stack.pop()
+        if node.type != "fenced_code_block":
+            stack.extend(reversed(node.children))
+            continue
+        info = next((c for c in node.children if c.type == "info_string"), None)
+        content = next((c for c in node.children if c.type == "code_fence_content"), None)
+        language = block_language(source[info.start_byte : info.end_byte].decode("utf-8", "replace")) if info else None
+        code, start_line = "", node.start_point[0] + 2
+        if content is not None:
+            # Inside lists and blockquotes every line starts with a `block_continuation` (`> `, indentation).
+            parts, offset = [], content.start_byte
+            for child in content.children:
+                if child.type == "block_continuation":
+                    parts.append(source[offset : child.start_byte])
+                    offset = child.end_byte
+            parts.append(source[offset : content.end_byte])
+            code = b"".join(parts).decode("utf-8", "replace")
+            start_line = content.start_point[0] + 1
+        blocks.append(CodeBlock(len(blocks), language, code, start_line))
+    return blocks
+
+
+def _cell_source(cell: dict) -> str:
+    source = cell.get("source", "")
+    return "".join(source) if isinstance(source, list) else str(source)
+
+
+def notebook_cells(source: bytes) -> List[CodeBlock]:
+    """Code cells of a Jupyter notebook, with cell magics resolved and IPython-only lines blanked."""
+    try:
+        notebook = json.loads(source.decode("utf-8", "replace"))
+    except ValueError as exc:
+        raise ValueError(f"Not a Jupyter notebook: {exc}") from None
+    if not isinstance(notebook, dict) or not isinstance(notebook.get("cells"), list):
+        raise ValueError("Not a Jupyter notebook: no 'cells' list")
+    metadata = notebook.get("metadata") or {}
+    kernel = (metadata.get("kernelspec") or {}).get("language") or (metadata.get("language_info") or {}).get("name")
+    kernel = block_language(kernel or "python")
+    blocks: List[CodeBlock] = []
+    for index, cell in enumerate(notebook["cells"]):
+        if not isinstance(cell, dict) or cell.get("cell_type") != "code":
+            continue
+        language = block_language((cell.get("metadata") or {}).get("vscode", {}).get("languageId") or "") or kernel
+        lines = _cell_source(cell).splitlines(keepends=True)
+        if lines and lines[0].startswith("%%"):
+            magic = lines[0][2:].split(maxsplit=1)
+            if magic and magic[0] in CELL_MAGICS:
+                language = CELL_MAGICS[magic[0]]
+            lines[0] = "\n"
+        if language == "python":
+            lines = ["\n" if _IPYTHON_LINE.match(line) else line for line in lines]
+        blocks.append(CodeBlock(index, language, "".join(lines), cell_id=cell.get("id")))
+    return blocks
+
+
+def document_symbols(
+    root: Node, source: bytes, language: str, max_chunk_size: Optional[int] = None
+) -> List[CodeSymbol]:
+    """Symbols of every code block of a Markdown (`root` is its tree) or notebook document."""
+    blocks = markdown_blocks(root, source) if language == "markdown" else notebook_cells(source)
+    symbols: List[CodeSymbol] = []
+    for block in blocks:
+        if not block.language or block.language in DOCUMENT_LANGUAGES or not block.code.strip():
+            continue
+        try:
+            parser = get_parser(block.language)
+        except RuntimeError:
+            continue  # no grammar for the info string (`text`, `console`, `mermaid`, ...)
+        code = block.code.encode("utf-8")
+        for symbol in symbols_from_tree(parser.parse(code).root_node, code, block.language, max_chunk_size):
+            symbol.start_line += block.start_line - 1
+            symbol.end_line += block.start_line - 1
+            symbol.language = symbol.language or block.language
+            symbol.block = block.index
+            symbol.cell_id = block.cell_id
+            symbols.append(symbol)
+    return symbols
+
+
+__all__ = [
+    "CELL_MAGICS",
+    "DOCUMENT_LANGUAGES",
+    "CodeBlock",
+    "block_language",
+    "document_symbols",
+    "markdown_blocks",
+    "notebook_cells",
+]
@@ -0,0 +1,301 @@
+"""
+Editor-protocol views of a parsed file: folding ranges, a document-symbol outline, and
+"expand selection" chains, in the JSON shapes of LSP's `textDocument/foldingRange`,
+`textDocument/documentSymbol`, and `textDocument/selectionRange` so an LSP shim or
+editor plugin can return them unchanged.
+
+Lines and characters are zero-based. Characters count UTF-16 code units by default,
+as LSP clients expect; pass `encoding="utf-8"` for byte columns or "utf-32" for code
+points. Conversions follow `positions` (CRLF line breaks, a leading BOM not counted).
+"""
+
+from __future__ import annotations
+
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Iterable, List, Optional, Tuple
+
+from tree_sitter import Node, QueryCursor
+
+from .core import ParsedFile, class_kind, iter_class_nodes, iter_function_nodes, parse_file
+from .positions import BOM, LineIndex, units
+from .querylib import compile_query, find_query
+
+# LSP SymbolKind values.
+SYMBOL_KINDS = {
+    "class": 5,
+    "method": 6,
+    "constructor": 9,
+    "interface": 11,
+    "trait": 11,
+    "function": 12,
+    "object": 19,
+    "struct": 23,
+}
+CONSTRUCTOR_NAMES = {"__init__", "constructor", "new", "initialize"}
+ENCODINGS = ("utf-16", "utf-8", "utf-32")
+
+
+def _character(source: bytes, row_start: int, byte: int, encoding: str) -> int:
+    text = source[row_start:byte]
+    if text.endswith(b"\r") and source[byte : byte + 1] == b"\n":
+        text = text[:-1]  # a CRLF's \r is part of the line break
+    if row_start == 0:
+        text = text.removeprefix(BOM)
+    return units(text, encoding)
+
+
+def byte_offset(source: bytes, line: int, character: int, encoding: str = "utf-16") -> int:
+    """The byte offset of an LSP position; positions past a line's end clamp to it."""
+    return LineIndex(source).offset(line, character, encoding)
+
+
+def _position(parsed: ParsedFile, point: Tuple[int, int], byte: int, encoding: str) -> dict:
+    return {"line": point[0], "character": _character(parsed.source, byte - point[1], byte, encoding)}
+
+
+def _range(parsed: ParsedFile, node: Node, encoding: str) -> dict:
+    return {
+        "start": _position(parsed, node.start_point, node.start_byte, encoding),
+        "end": _position(parsed, node.end_point, node.end_byte, encoding),
+    }
+
+
+@dataclass
+class FoldingRange:
+    start_line: int
+    end_line: int
+    start_character: Optional[int] = None
+    end_character: Optional[int] = None
+    kind: Optional[str] = None  # "comment", "imports", or "region"; None for code blocks
+
+    def to_dict(self) -> dict:
+        data = {"startLine": self.start_line, "endLine": self.end_line}
+        if self.start_character is not None:
+            data["startCharacter"] = self.start_character
+        if self.end_character is not None:
+            data["endCharacter"] = self.end_character
+        if self.kind:
+            data["kind"] = self.kind
+        return data
+
+
+@dataclass
+class DocumentSymbol:
+    name: str
+    kind: int
+    range: dict
+    selection_range: dict
+    detail: Optional[str] = None
+    children: List["DocumentSymbol"] = field(default_factory=list)
+    start_byte: int = 0
+    end_byte: int = 0
+
+    def to_dict(self) -> dict:
+        data = {"name": self.name, "kind": self.kind, "range": self.range, "selectionRange": self.selection_range}
+        if self.detail:
+            data["detail"] = self.detail
+        if self.children:
+            data["children"] = [child.to_dict() for child in self.children]
+        return data
+
+
+@dataclass
+class SelectionRange:
+    """One step of "expand selection": a node's range and the next larger range enclosing it."""
+
+    range: dict
+    node_type: str
+    parent: Optional["SelectionRange"] = None
+
+    def chain(self) -> List["SelectionRange"]:
+        """This range and its ancestors, innermost first."""
+        steps = []
+        step: Optional[SelectionRange] = self
+        while step is not None:
+            steps.append(step)
+            step = step.parent
+        return steps
+
+    def to_dict(self) -> dict:
+        """LSP's nested `{range, parent}` shape."""
+        data = None
+        for step in reversed(self.chain()):
+            data = {"range": step.range, **({"parent": data} if data else {})}
+        return data
+
+
+def _fold_kind(node: Node) -> Optional[str]:
+    if "comment" in node.type:
+        return "comment"
+    if "import" in node.type or node.type in {"use_declaration", "preproc_include"}:
+        return "imports"
+    return None
+
+
+def _fold_nodes(parsed: ParsedFile) -> List[Node]:
+    """Nodes captured by the language's `folds` query, else its functions and classes."""
+    if find_query(parsed.language, "folds") is not None:
+        captures = QueryCursor(compile_query(parsed.language, "folds")).captures(parsed.root)
+        return [node for name, nodes in captures.items() if name.startswith("fold") for node in nodes]
+    nodes = [fn.node for fn in iter_function_nodes(parsed)]
+    return nodes + [cls.node for cls in iter_class_nodes(parsed)]
+
+
+def _comment_runs(parsed: ParsedFile) -> List[Tuple[int, int]]:
+    """(first, last) rows of each run of comments on consecutive lines spanning two or more lines."""
+    rows: List[Tuple[int, int]] = []
+    stack = [parsed.root]
+    while stack:
+        node = stack.pop()
+        if "comment" in node.type:
+            rows.append((node.start_point[0], node.end_point[0]))
+            continue
+        stack.extend(node.children)
+    runs: List[Tuple[int, int]] = []
+    for start, end in sorted(rows):
+        if runs and start <= runs[-1][1] + 1:
+            runs[-1] = (runs[-1][0], max(end, runs[-1][1]))
+        else:
+            runs.append((start, end))
+    return [run for run in runs if run[1] > run[0]]
+
+
+def folding_ranges(parsed: ParsedFile, encoding: str = "utf-16") -> List[FoldingRange]:
+    """Foldable multi-line regions (blocks, literals, comment runs, imports), sorted by position."""
+    if encoding not in ENCODINGS:
+        raise ValueError(f"Unsupported encoding '{encoding}' (expected {', '.join(ENCODINGS)})")
+    found = {}
+    for node in _fold_nodes(parsed):
+        if node.end_point[0] <= node.start_point[0]:
+            continue
+        start = _position(parsed, node.start_point, node.start_byte, encoding)
+        end = _position(parsed, node.end_point, node.end_byte, encoding)
+        key = (start["line"], end["line"])
+        if key not in found:
+            found[key] = FoldingRange(start["line"], end["line"], start["character"], end["character"], _fold_kind(node))
+    for start, end in _comment_runs(parsed):
+        found.setdefault((start, end), FoldingRange(start, end, kind="comment"))
+    return sorted(found.values(), key=lambda r: (r.start_line, -r.end_line))
+
+
+def _detail(node: Node, parsed: ParsedFile) -> Optional[str]:
+    params = node.child_by_field_name("parameters")
+    if params is None:
+        declarator = node.child_by_field_name("declarator")
+        params = declarator.child_by_field_name("parameters") if declarator is not None else None
+    return " ".join(parsed.text(params).split()) if params is not None else None
+
+
+def _outer(node: Node) -> Node:
+    parent = node.parent
+    return parent if parent is not None and parent.type == "decorated_definition" else node
+
+
+def _symbol(parsed: ParsedFile, node: Node, name: str, kind: str, detail: Optional[str], encoding: str) -> DocumentSymbol:
+    outer = _outer(node)
+    name_node = node.child_by_field_name("name") or node
+    return DocumentSymbol(
+        name=name,
+        kind=SYMBOL_KINDS[kind],
+        range=_range(parsed, outer, encoding),
+        selection_range=_range(parsed, name_node, encoding),
+        detail=detail,
+        start_byte=outer.start_byte,
+        end_byte=outer.end_byte,
+    )
+
+
+def _flat_symbols(parsed: ParsedFile, encoding: str) -> List[DocumentSymbol]:
+    symbols = []
+    for cls in iter_class_nodes(parsed):
+        symbols.append(_symbol(parsed, cls.node, cls.name, class_kind(cls.node), None, encoding))
+    stack = [parsed.root]
+    while stack:  # Rust impl blocks group their methods, as rust-analyzer shows them
+        node = stack.pop()
+        if node.type == "impl_item":
+            type_node = node.child_by_field_name("type")
+            trait = node.child_by_field_name("trait")
+            name = f"impl {parsed.text(trait)} for {parsed.text(type_node)}" if trait is not None else f"impl {parsed.text(type_node)}"
+            symbols.append(_symbol(parsed, node, name, "object", None, encoding))
+        stack.extend(node.named_children)
+    for fn in iter_function_nodes(parsed):
+        kind = "method" if fn.container or fn.receiver else "function"
+        if kind == "method" and fn.name in CONSTRUCTOR_NAMES:
+            kind = "constructor"
+        name = f"({fn.container}).{fn.name}" if parsed.language == "go" and fn.container else fn.name
+        symbols.append(_symbol(parsed, fn.node, name, kind, _detail(fn.node, parsed), encoding))
+    return symbols
+
+
+def document_symbols(parsed: ParsedFile, encoding: str = "utf-16") -> List[DocumentSymbol]:
+    """Classes, functions, and methods as a tree: each symbol nests under the smallest one enclosing it."""
+    if encoding not in ENCODINGS:
+        raise ValueError(f"Unsupported encoding '{encoding}' (expected {', '.join(ENCODINGS)})")
+    roots: List[DocumentSymbol] = []
+    open_symbols: List[DocumentSymbol] = []
+    for symbol in sorted(_flat_symbols(parsed, encoding), key=lambda s: (s.start_byte, -s.end_byte)):
+        while open_symbols and open_symbols[-1].end_byte < symbol.end_byte:
+            open_symbols.pop()
+        (open_symbols[-1].children if open_symbols else roots).append(symbol)
+        open_symbols.append(symbol)
+    return roots
+
+
+def selection_range(parsed: ParsedFile, line: int, character: int, encoding: str = "utf-16") -> SelectionRange:
+    """
+    The named nodes enclosing a position, from the smallest (usually an identifier or
+    literal) out to the file; nodes spanning the same bytes as the one inside them are
+    skipped, so every step grows the selection.
+    """
+    if encoding not in ENCODINGS:
+        raise ValueError(f"Unsupported encoding '{encoding}' (expected {', '.join(ENCODINGS)})")
+    offset = byte_offset(parsed.source, line, character, encoding)
+    node: Optional[Node] = parsed.root.named_descendant_for_byte_range(offset, offset)
+    nodes: List[Node] = []
+    while node is not None:
+        if not nodes or (node.start_byte, node.end_byte) != (nodes[-1].start_byte, nodes[-1].end_byte):
+            nodes.append(node)
+        node = node.parent
+    result = None
+    for node in reversed(nodes):
+        result = SelectionRange(_range(parsed, node, encoding), node.type, result)
+    return result
+
+
+def selection_ranges(
+    parsed: ParsedFile, positions: Iterable[Tuple[int, int]], encoding: str = "utf-16"
+) -> List[SelectionRange]:
+    """`selection_range` for each (line, character) position, as `textDocument/selectionRange` answers."""
+    return [selection_range(parsed, line, character, encoding) for line, character in positions]
+
+
+def file_folding_ranges(path: Path, language: Optional[str] = None, encoding: str = "utf-16") -> List[FoldingRange]:
+    return folding_ranges(parse_file(path, language), encoding)
+
+
+def file_document_symbols(path: Path, language: Optional[str] = None, encoding: str = "utf-16") -> List[DocumentSymbol]:
+    return document_symbols(parse_file(path, language), encoding)
+
+
+def file_selection_range(
+    path: Path, line: int, character: int, language: Optional[str] = None, encoding: str = "utf-16"
+) -> SelectionRange:
+    return selection_range(parse_file(path, language), line, character, encoding)
+
+
+__all__ = [
+    "ENCODINGS",
+    "SYMBOL_KINDS",
+    "DocumentSymbol",
+    "FoldingRange",
+    "SelectionRange",
+    "byte_offset",
+    "document_symbols",
+    "file_document_symbols",
+    "file_folding_ranges",
+    "file_selection_range",
+    "folding_ranges",
+    "selection_range",
+    "selection_ranges",
+]
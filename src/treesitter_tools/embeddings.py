@@ -0,0 +1,319 @@
+"""Embed semantic chunks through an OpenAI-compatible endpoint and store vectors in the SQLite index."""
+
+from __future__ import annotations
+
+import hashlib
+import json
+import sqlite3
+import struct
+import time
+import urllib.error
+import urllib.request
+from dataclasses import dataclass
+from pathlib import Path
+from typing import Callable, Dict, List, Optional, Sequence, Tuple
+
+from .chunker import Chunk, ChunkOptions, chunk_directory
+
+DEFAULT_ENDPOINT = "https://api.openai.com/v1"
+DEFAULT_MODEL = "text-embedding-3-small"
+
+EMBEDDINGS_TABLE = """
+CREATE TABLE IF NOT EXISTS embeddings (
+    id INTEGER PRIMARY KEY,
+    model TEXT NOT NULL,
+    path TEXT NOT NULL,
+    chunk_index INTEGER NOT NULL,
+    kind TEXT NOT NULL,
+    name TEXT NOT NULL,
+    start_line INTEGER NOT NULL,
+    end_line INTEGER NOT NULL,
+    token_count INTEGER NOT NULL,
+    sha256 TEXT NOT NULL,
+    dimensions INTEGER NOT NULL,
+    UNIQUE (model, path, chunk_index)
+)"""
+# One vector per model and chunk text: identical chunks (copied code, vendored files) share it.
+VECTORS_TABLE = """
+CREATE TABLE IF NOT EXISTS vectors (
+    model TEXT NOT NULL,
+    sha256 TEXT NOT NULL,
+    vector BLOB NOT NULL,
+    PRIMARY KEY (model, sha256)
+)"""
+PATH_INDEX = "CREATE INDEX IF NOT EXISTS embeddings_path ON embeddings(path)"
+SCHEMA = ";\n".join((EMBEDDINGS_TABLE, VECTORS_TABLE, PATH_INDEX)) + ";\n"
+_COLUMNS = "model, path, chunk_index, kind, name, start_line, end_line, token_count, sha256, dimensions"
+
+# Takes a batch of texts, returns one vector per text in the same order.
+EmbedFn = Callable[[List[str]], List[List[float]]]
+
+
+class EmbeddingError(RuntimeError):
+    """The endpoint kept failing; vectors stored before the failure are kept for the next run."""
+
+
+def pack_vector(vector: Sequence[float]) -> bytes:
+    """Little-endian float32, the layout sqlite-vec and numpy.frombuffer(dtype="<f4") expect."""
+    return struct.pack(f"<{len(vector)}f", *vector)
+
+
+def unpack_vector(blob: bytes) -> List[float]:
+    return list(struct.unpack(f"<{len(blob) // 4}f", blob))
+
+
+@dataclass
+class EndpointConfig:
+    url: str = DEFAULT_ENDPOINT
+    model: str = DEFAULT_MODEL
+    api_key: Optional[str] = None
+    dimensions: Optional[int] = None
+    timeout: float = 60.0
+    retries: int = 3
+    backoff: float = 1.0  # seconds; doubles after every failed attempt
+
+
+def http_embedder(config: EndpointConfig, sleep: Callable[[float], None] = time.sleep) -> EmbedFn:
+    """POST `{"model", "input"}` to `<url>/embeddings`, retrying 429/5xx and network errors."""
+    url = config.url.rstrip("/")
+    if not url.endswith("/embeddings"):
+        url += "/embeddings"
+    headers = {"Content-Type": "application/json"}
+    if config.api_key:
+        headers["Authorization"] = f"Bearer {config.api_key}"
+
+    def embed(texts: List[str]) -> List[List[float]]:
+        body: Dict[str, object] = {"model": config.model, "input": texts}
+        if config.dimensions:
+            body["dimensions"] = config.dimensions
+        payload = json.dumps(body).encode("utf-8")
+        delay = config.backoff
+        for attempt in range(config.retries + 1):
+            request = urllib.request.Request(url, data=payload, headers=headers, method="POST")
+            try:
+                with urllib.request.urlopen(request, timeout=config.timeout) as response:
+                    data = json.loads(response.read().decode("utf-8"))
+                items = sorted(data["data"], key=lambda item: item.get("index", 0))
+                if len(items) != len(texts):
+                    raise EmbeddingError(f"Endpoint returned {len(items)} embeddings for {len(texts)} inputs")
+                return [item["embedding"] for item in items]
+            except urllib.error.HTTPError as exc:
+                retryable = exc.code == 429 or exc.code >= 500
+                detail = exc.read().decode("utf-8", "replace")[:200]
+                error = EmbeddingError(f"HTTP {exc.code} from {url}: {detail}")
+                if not retryable:
+                    raise error from exc
+            except (urllib.error.URLError, TimeoutError, ConnectionError) as exc:
+                error = EmbeddingError(f"Cannot reach {url}: {exc}")
+            except (KeyError, TypeError, ValueError) as exc:
+                raise EmbeddingError(f"Unexpected response from {url}: {exc}") from exc
+            if attempt < config.retries:
+                sleep(delay)
+                delay *= 2
+        raise error
+
+    return embed
+
+
+@dataclass
+class EmbedStats:
+    embedded: int = 0
+    skipped: int = 0
+    removed: int = 0
+    batches: int = 0
+    failed: Optional[str] = None
+
+    def to_dict(self) -> dict:
+        data = {"embedded": self.embedded, "skipped": self.skipped, "removed": self.removed, "batches": self.batches}
+        if self.failed:
+            data["failed"] = self.failed
+        return data
+
+
+def _digest(chunk: Chunk) -> str:
+    return hashlib.sha256(chunk.text.encode("utf-8")).hexdigest()
+
+
+class EmbeddingStore:
+    """The `embeddings` and `vectors` tables, kept in the same database as the symbol index."""
+
+    def __init__(self, db_path: Path):
+        self.db_path = Path(db_path)
+        self.db_path.parent.mkdir(parents=True, exist_ok=True)
+        self.conn = sqlite3.connect(str(self.db_path))
+        self.conn.row_factory = sqlite3.Row
+        self.conn.executescript(SCHEMA)
+        # Duplicate vectors dropped while moving an older database's inline vectors to `vectors`
+        self.merged = self._share_inline_vectors()
+
+    def close(self) -> None:
+        self.conn.close()
+
+    def __enter__(self) -> "EmbeddingStore":
+        return self
+
+    def __exit__(self, *exc) -> None:
+        self.close()
+
+    def _share_inline_vectors(self) -> int:
+        """
+        Move vectors stored on each row (databases written before the `vectors` table)
+        into it, one per model and chunk text; returns how many duplicate copies were dropped.
+        """
+        columns = {row["name"] for row in self.conn.execute("PRAGMA table_info(embeddings)")}
+        if "vector" not in columns:
+            return 0
+        with self.conn:
+            rows = self.conn.execute("SELECT COUNT(*) FROM embeddings").fetchone()[0]
+            before = self.conn.execute("SELECT COUNT(*) FROM vectors").fetchone()[0]
+            self.conn.execute(
+                "INSERT OR IGNORE INTO vectors(model, sha256, vector) SELECT model, sha256, vector FROM embeddings"
+            )
+            moved = self.conn.execute("SELECT COUNT(*) FROM vectors").fetchone()[0] - before
+            self.conn.execute("DROP INDEX IF EXISTS embeddings_path")
+            self.conn.execute("ALTER TABLE embeddings RENAME TO embeddings_inline")
+            self.conn.execute(EMBEDDINGS_TABLE)
+            self.conn.execute(PATH_INDEX)
+            self.conn.execute(f"INSERT INTO embeddings({_COLUMNS}) SELECT {_COLUMNS} FROM embeddings_inline")
+            self.conn.execute("DROP TABLE embeddings_inline")
+        return rows - moved
+
+    def existing(self, model: str) -> Dict[Tuple[str, int], str]:
+        rows = self.conn.execute("SELECT path, chunk_index, sha256 FROM embeddings WHERE model = ?", (model,))
+        return {(row["path"], row["chunk_index"]): row["sha256"] for row in rows}
+
+    def store(self, model: str, chunks: Sequence[Chunk], vectors: Sequence[Sequence[float]]) -> None:
+        with self.conn:
+            for chunk, vector in zip(chunks, vectors):
+                digest = _digest(chunk)
+                self.conn.execute(
+                    f"INSERT OR REPLACE INTO embeddings({_COLUMNS}) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+                    (
+                        model, chunk.path, chunk.index, chunk.kind, chunk.name, chunk.start_line, chunk.end_line,
+                        chunk.token_count, digest, len(vector),
+                    ),
+                )
+                self.conn.execute(
+                    "INSERT OR IGNORE INTO vectors(model, sha256, vector) VALUES (?, ?, ?)",
+                    (model, digest, pack_vector(vector)),
+                )
+
+    def prune(self, model: str, keep: set) -> int:
+        """Delete rows for chunks that no longer exist (their vectors stay until `collect_vectors`)."""
+        stale = [key for key in self.existing(model) if key not in keep]
+        with self.conn:
+            for path, index in stale:
+                self.conn.execute(
+                    "DELETE FROM embeddings WHERE model = ? AND path = ? AND chunk_index = ?", (model, path, index)
+                )
+        return len(stale)
+
+    def drop_paths(self, paths: Sequence[str]) -> int:
+        """Delete the rows of every model for `paths` (files that were deleted); returns how many."""
+        with self.conn:
+            return sum(
+                self.conn.execute("DELETE FROM embeddings WHERE path = ?", (path,)).rowcount for path in paths
+            )
+
+    def paths(self) -> List[str]:
+        return [row["path"] for row in self.conn.execute("SELECT DISTINCT path FROM embeddings ORDER BY path")]
+
+    def collect_vectors(self) -> int:
+        """Delete vectors no row refers to any more; returns how many."""
+        with self.conn:
+            return self.conn.execute(
+                "DELETE FROM vectors WHERE NOT EXISTS (SELECT 1 FROM embeddings e"
+                " WHERE e.model = vectors.model AND e.sha256 = vectors.sha256)"
+            ).rowcount
+
+    def shared(self) -> int:
+        """Rows whose vector another row with the same text already holds (copies not stored)."""
+        rows = self.conn.execute("SELECT COUNT(*) FROM embeddings").fetchone()[0]
+        distinct = self.conn.execute(
+            "SELECT COUNT(*) FROM (SELECT DISTINCT model, sha256 FROM embeddings)"
+        ).fetchone()[0]
+        return rows - distinct
+
+    def vectors(self, model: str) -> List[dict]:
+        rows = self.conn.execute(
+            "SELECT e.path, e.chunk_index, e.kind, e.name, e.start_line, e.end_line, v.vector FROM embeddings e"
+            " JOIN vectors v ON v.model = e.model AND v.sha256 = e.sha256"
+            " WHERE e.model = ? ORDER BY e.path, e.chunk_index",
+            (model,),
+        )
+        results = []
+        for row in rows:
+            item = {key: row[key] for key in row.keys() if key != "vector"}
+            item["vector"] = unpack_vector(row["vector"])
+            results.append(item)
+        return results
+
+
+def embed_chunks(
+    chunks: Sequence[Chunk],
+    store: EmbeddingStore,
+    embed: EmbedFn,
+    model: str,
+    batch_size: int = 64,
+    prune: bool = True,
+    progress: Optional[Callable[[EmbedStats], None]] = None,
+) -> EmbedStats:
+    """
+    Embed chunks whose text changed since the last run, committing after every batch.
+    When the endpoint fails the stats carry `failed` and re-running resumes from there.
+    """
+    stats = EmbedStats()
+    existing = store.existing(model)
+    pending = []
+    for chunk in chunks:
+        if existing.get((chunk.path, chunk.index)) == _digest(chunk):
+            stats.skipped += 1
+        else:
+            pending.append(chunk)
+    for start in range(0, len(pending), batch_size):
+        batch = list(pending[start : start + batch_size])
+        try:
+            vectors = embed([chunk.text for chunk in batch])
+        except EmbeddingError as exc:
+            stats.failed = str(exc)
+            return stats
+        store.store(model, batch, vectors)
+        stats.embedded += len(batch)
+        stats.batches += 1
+        if progress is not None:
+            progress(stats)
+    if prune:
+        stats.removed = store.prune(model, {(chunk.path, chunk.index) for chunk in chunks})
+    return stats
+
+
+def embed_directory(
+    root: Path,
+    db_path: Path,
+    embed: EmbedFn,
+    model: str,
+    options: Optional[ChunkOptions] = None,
+    include: Sequence[str] | None = None,
+    exclude: Sequence[str] | None = None,
+    batch_size: int = 64,
+    progress: Optional[Callable[[EmbedStats], None]] = None,
+) -> EmbedStats:
+    """Chunk `root` and embed what changed into `db_path` (the `index build` database)."""
+    chunks = chunk_directory(root, options, include, exclude)
+    with EmbeddingStore(db_path) as store:
+        return embed_chunks(chunks, store, embed, model, batch_size, progress=progress)
+
+
+__all__ = [
+    "DEFAULT_ENDPOINT",
+    "DEFAULT_MODEL",
+    "EmbedStats",
+    "EmbeddingError",
+    "EmbeddingStore",
+    "EndpointConfig",
+    "embed_chunks",
+    "embed_directory",
+    "http_embedder",
+    "pack_vector",
+    "unpack_vector",
+]
@@ -0,0 +1 @@
+"""Exporters that convert extracted symbols into external code-intelligence formats."""
@@ -0,0 +1,262 @@
+"""
+Property-graph export for graph databases (Neo4j, Memgraph, ...).
+
+One parse of the tree yields a single graph of files, packages, functions, and types,
+linked by the relations the other analyses already compute:
+
+- `(:Package)-[:CONTAINS]->(:File)`, `(:File)-[:DEFINES]->(:Function|:Type)`, and
+  `(:Type)-[:HAS_METHOD]->(:Function)` for methods whose receiver type is declared;
+- `(:Function)-[:CALLS]->(:Function)` for call edges `callgraph` resolved;
+- `(:File)-[:IMPORTS]->(:Package|:External)` from `deps`;
+- `(:Type)-[:EXTENDS|IMPLEMENTS|EMBEDS]->(:Type)` from `hierarchy`; supertypes declared
+  outside the tree become `:Type` nodes with `external: true`.
+
+Every node also carries the `Code` label and a unique `id` property, so the Cypher
+output can `MERGE` on one constraint and be re-run against an existing database.
+`to_jgf` writes the same graph as JSON Graph Format (https://jsongraphformat.info, v2).
+"""
+
+from __future__ import annotations
+
+import json
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Dict, List, Optional, Sequence, Tuple
+
+from .. import failures
+from ..callgraph import CallGraph
+from ..core import ParsedFile, iter_source_files, parse_file
+from ..deps import _EXTRACTORS, _Resolver, _package_of, import_specs
+from ..hierarchy import TypeHierarchy
+
+BASE_LABEL = "Code"
+DIALECTS = ("neo4j", "memgraph")
+NODE_LABELS = ("Package", "File", "Function", "Type", "External")
+EDGE_TYPES = ("CONTAINS", "DEFINES", "HAS_METHOD", "CALLS", "IMPORTS", "EXTENDS", "IMPLEMENTS", "EMBEDS")
+
+
+@dataclass
+class GraphNode:
+    id: str
+    label: str  # one of NODE_LABELS
+    properties: Dict[str, object] = field(default_factory=dict)
+
+
+@dataclass
+class GraphEdge:
+    source: str
+    target: str
+    type: str  # one of EDGE_TYPES
+    properties: Dict[str, object] = field(default_factory=dict)
+
+
+def _literal(value: object) -> str:
+    """A Cypher literal; JSON string escapes are valid Cypher escapes."""
+    if isinstance(value, bool):
+        return "true" if value else "false"
+    if isinstance(value, (list, tuple)):
+        return "[" + ", ".join(_literal(v) for v in value) + "]"
+    if isinstance(value, (int, float)):
+        return str(value)
+    return json.dumps(str(value), ensure_ascii=False)
+
+
+def _map(properties: Dict[str, object]) -> str:
+    return "{" + ", ".join(f"{key}: {_literal(value)}" for key, value in properties.items() if value is not None) + "}"
+
+
+class PropertyGraph:
+    """Nodes keyed by id plus typed, directed edges between them."""
+
+    def __init__(self) -> None:
+        self.nodes: Dict[str, GraphNode] = {}
+        self.edges: List[GraphEdge] = []
+
+    def add_node(self, node_id: str, label: str, **properties: object) -> str:
+        if node_id not in self.nodes:
+            self.nodes[node_id] = GraphNode(node_id, label, {k: v for k, v in properties.items() if v is not None})
+        return node_id
+
+    def add_edge(self, source: str, target: str, edge_type: str, **properties: object) -> None:
+        self.edges.append(GraphEdge(source, target, edge_type, {k: v for k, v in properties.items() if v is not None}))
+
+    def counts(self) -> Dict[str, int]:
+        counts: Dict[str, int] = {}
+        for node in self.nodes.values():
+            counts[node.label] = counts.get(node.label, 0) + 1
+        for edge in self.edges:
+            counts[edge.type] = counts.get(edge.type, 0) + 1
+        return counts
+
+    def to_cypher(self, dialect: str = "neo4j", schema: bool = True) -> str:
+        """Idempotent `MERGE` statements, one per line, preceded by the id constraint and name index."""
+        if dialect not in DIALECTS:
+            raise ValueError(f"Unknown Cypher dialect '{dialect}' (expected {' or '.join(DIALECTS)})")
+        lines = []
+        if schema and dialect == "neo4j":
+            lines.append(f"CREATE CONSTRAINT code_id IF NOT EXISTS FOR (n:{BASE_LABEL}) REQUIRE n.id IS UNIQUE;")
+            lines.append(f"CREATE INDEX code_name IF NOT EXISTS FOR (n:{BASE_LABEL}) ON (n.name);")
+        elif schema:
+            lines.append(f"CREATE CONSTRAINT ON (n:{BASE_LABEL}) ASSERT n.id IS UNIQUE;")
+            lines.append(f"CREATE INDEX ON :{BASE_LABEL}(id);")
+            lines.append(f"CREATE INDEX ON :{BASE_LABEL}(name);")
+        for node in self.nodes.values():
+            statement = f"MERGE (n:{BASE_LABEL}:{node.label} {{id: {_literal(node.id)}}})"
+            if node.properties:
+                statement += f" SET n += {_map(node.properties)}"
+            lines.append(statement + ";")
+        for edge in self.edges:
+            lines.append(
+                f"MATCH (a:{BASE_LABEL} {{id: {_literal(edge.source)}}}), (b:{BASE_LABEL} {{id: {_literal(edge.target)}}}) "
+                f"MERGE (a)-[:{edge.type}{' ' + _map(edge.properties) if edge.properties else ''}]->(b);"
+            )
+        return "\n".join(lines) + "\n"
+
+    def to_jgf(self, label: Optional[str] = None) -> dict:
+        graph: dict = {"directed": True, "type": "code"}
+        if label:
+            graph["label"] = label
+        graph["nodes"] = {
+            node.id: {
+                "label": str(node.properties.get("name", node.id)),
+                "metadata": {"type": node.label, **node.properties},
+            }
+            for node in self.nodes.values()
+        }
+        graph["edges"] = [
+            {"source": e.source, "target": e.target, "relation": e.type, "metadata": dict(e.properties)}
+            for e in self.edges
+        ]
+        return {"graph": graph}
+
+    def to_jgf_json(self, label: Optional[str] = None) -> str:
+        return json.dumps(self.to_jgf(label), indent=2)
+
+
+def _function_id(file: str, line: int, qualified_name: str) -> str:
+    return f"function:{file}:{line}:{qualified_name}"
+
+
+def _type_id(path: Optional[str], line: Optional[int], name: str) -> str:
+    return f"type:{path}:{line}:{name}" if path is not None else f"type:{name}"
+
+
+def _parse_tree(root: Path, include: Sequence[str] | None, exclude: Sequence[str] | None) -> Tuple[Path, Dict[str, ParsedFile]]:
+    base = Path(root).resolve()
+    if base.is_file():
+        paths = [base]
+        base = base.parent
+    else:
+        paths = list(iter_source_files(base, include, exclude))
+    files: Dict[str, ParsedFile] = {}
+    for path in paths:
+        try:
+            files[path.relative_to(base).as_posix()] = parse_file(path)
+        except (ValueError, RuntimeError, OSError) as exc:
+            failures.record(path, exc)
+            continue
+    return base, files
+
+
+def build_property_graph(
+    root: Path,
+    include: Sequence[str] | None = None,
+    exclude: Sequence[str] | None = None,
+) -> PropertyGraph:
+    """Parse every recognised file under `root` (or a single file) into one property graph."""
+    base, files = _parse_tree(root, include, exclude)
+    graph = PropertyGraph()
+    calls = CallGraph()
+    hierarchy = TypeHierarchy()
+    for rel in sorted(files):
+        parsed = files[rel]
+        package = _package_of(rel)
+        graph.add_node(f"package:{package}", "Package", name=package)
+        graph.add_node(f"file:{rel}", "File", name=rel.rpartition("/")[2], path=rel, language=parsed.language)
+        graph.add_edge(f"package:{package}", f"file:{rel}", "CONTAINS")
+        calls.add_file(parsed, rel)
+        hierarchy.add_file(parsed, rel)
+    calls.resolve()
+    hierarchy.resolve()
+
+    types_by_name: Dict[str, List[str]] = {}
+    for decl in hierarchy.types:
+        type_id = graph.add_node(
+            _type_id(decl.path, decl.line, decl.name), "Type",
+            name=decl.name, kind=decl.kind, path=decl.path, line=decl.line, language=decl.language,
+        )
+        graph.add_edge(f"file:{decl.path}", type_id, "DEFINES")
+        types_by_name.setdefault(decl.name, []).append(type_id)
+
+    def type_for(name: str, path: Optional[str]) -> Optional[str]:
+        """A declared type by name: the one in `path` if any, else the only one."""
+        candidates = types_by_name.get(name, [])
+        local = [c for c in candidates if c.startswith(f"type:{path}:")] if path else []
+        if len(local) == 1:
+            return local[0]
+        return candidates[0] if len(candidates) == 1 else None
+
+    functions: Dict[Tuple[str, str], str] = {}
+    for definition in calls.definitions:
+        function_id = graph.add_node(
+            _function_id(definition.file, definition.line, definition.qualified_name), "Function",
+            name=definition.name, qualified_name=definition.qualified_name, receiver_type=definition.receiver_type,
+            path=definition.file, line=definition.line, language=files[definition.file].language,
+        )
+        functions.setdefault((definition.file, definition.qualified_name), function_id)
+        graph.add_edge(f"file:{definition.file}", function_id, "DEFINES")
+        if definition.receiver_type:
+            owner = type_for(definition.receiver_type, definition.file)
+            if owner is not None:
+                graph.add_edge(owner, function_id, "HAS_METHOD")
+
+    by_location = {(d.file, d.line): _function_id(d.file, d.line, d.qualified_name) for d in calls.definitions}
+    seen_calls = set()
+    for edge in calls.edges:
+        if not edge.resolved:
+            continue
+        caller = functions.get((edge.file, edge.caller))
+        callee = by_location.get((edge.callee_file, edge.callee_line))
+        if caller is None or callee is None or (caller, callee, edge.line) in seen_calls:
+            continue
+        seen_calls.add((caller, callee, edge.line))
+        graph.add_edge(caller, callee, "CALLS", path=edge.file, line=edge.line, column=edge.column)
+
+    for relation in hierarchy.relations:
+        subtype = type_for(relation.subtype, relation.path)
+        if subtype is None:
+            continue
+        if relation.supertype_path is not None:
+            supertype = _type_id(relation.supertype_path, relation.supertype_line, relation.supertype)
+        else:
+            supertype = type_for(relation.supertype, relation.path) or graph.add_node(
+                _type_id(None, None, relation.supertype), "Type", name=relation.supertype, external=True,
+            )
+        graph.add_edge(subtype, supertype, relation.relation.upper(), path=relation.path, line=relation.line)
+
+    importable = {rel: parsed for rel, parsed in files.items() if parsed.language in _EXTRACTORS}
+    resolver = _Resolver(base, importable)
+    for rel in sorted(importable):
+        parsed = importable[rel]
+        for spec, line in import_specs(parsed):
+            local, external = resolver.resolve(spec, parsed.language, rel)
+            if local is not None:
+                target = graph.add_node(f"package:{local}", "Package", name=local)
+            elif external is not None:
+                target = graph.add_node(f"external:{external}", "External", name=external)
+            else:
+                continue
+            graph.add_edge(f"file:{rel}", target, "IMPORTS", spec=spec, line=line)
+    return graph
+
+
+__all__ = [
+    "BASE_LABEL",
+    "DIALECTS",
+    "EDGE_TYPES",
+    "NODE_LABELS",
+    "GraphEdge",
+    "GraphNode",
+    "PropertyGraph",
+    "build_property_graph",
+]
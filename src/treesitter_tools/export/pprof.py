@@ -0,0 +1,90 @@
+"""
+pprof profiles (`profile.proto`, gzipped) from sampled Python stacks, readable by
+`go tool pprof`, speedscope, Pyroscope, and other pprof-speaking tools.
+
+Each stack is a tuple of frames, innermost first; a frame is `(function, filename,
+first line of the function, current line)`. Every sample carries two values: the
+number of samples and the wall time they stand for.
+"""
+
+from __future__ import annotations
+
+import gzip
+from typing import Dict, List, Mapping, Sequence, Tuple
+
+from .protowire import bytes_field as _bytes
+from .protowire import int_field as _int
+from .protowire import packed_field as _packed
+
+Frame = Tuple[str, str, int, int]
+Stack = Tuple[Frame, ...]
+
+SAMPLE_TYPES = (("samples", "count"), ("wall", "nanoseconds"))
+
+
+class _Strings:
+    """The profile's string table; index 0 is always the empty string."""
+
+    def __init__(self) -> None:
+        self.table: List[str] = [""]
+        self.index: Dict[str, int] = {"": 0}
+
+    def __call__(self, text: str) -> int:
+        if text not in self.index:
+            self.index[text] = len(self.table)
+            self.table.append(text)
+        return self.index[text]
+
+
+def _value_type(strings: _Strings, kind: str, unit: str) -> bytes:
+    return _int(1, strings(kind)) + _int(2, strings(unit))
+
+
+def encode_profile(
+    samples: Mapping[Stack, Sequence[int]], period_ns: int, duration_ns: int = 0, time_ns: int = 0
+) -> bytes:
+    """
+    A gzipped pprof profile of `samples` (stack -> `[count, wall nanoseconds]`),
+    taken every `period_ns` nanoseconds over `duration_ns` starting at `time_ns`.
+    """
+    strings = _Strings()
+    functions: Dict[Tuple[str, str, int], int] = {}
+    locations: Dict[Tuple[int, int], int] = {}
+    out = bytearray()
+    for kind, unit in SAMPLE_TYPES:
+        out += _bytes(1, _value_type(strings, kind, unit))
+    location_messages: List[bytes] = []
+    function_messages: List[bytes] = []
+    for stack, values in samples.items():
+        ids: List[int] = []
+        for name, filename, start_line, line in stack:
+            function_key = (name, filename, start_line)
+            if function_key not in functions:
+                functions[function_key] = len(functions) + 1
+                function_messages.append(
+                    _int(1, functions[function_key])
+                    + _int(2, strings(name))
+                    + _int(3, strings(name))
+                    + _int(4, strings(filename))
+                    + _int(5, start_line)
+                )
+            location_key = (functions[function_key], line)
+            if location_key not in locations:
+                locations[location_key] = len(locations) + 1
+                line_message = _int(1, location_key[0]) + _int(2, line)
+                location_messages.append(_int(1, locations[location_key]) + _bytes(4, line_message))
+            ids.append(locations[location_key])
+        out += _bytes(2, _packed(1, ids) + _packed(2, list(values)))
+    for message in location_messages:
+        out += _bytes(4, message)
+    for message in function_messages:
+        out += _bytes(5, message)
+    period_type = _value_type(strings, *SAMPLE_TYPES[1])
+    for text in strings.table:
+        out += _bytes(6, text.encode("utf-8"))  # empty strings too: indexes are positional
+    out += _int(9, time_ns) + _int(10, duration_ns)
+    out += _bytes(11, period_type) + _int(12, period_ns)
+    return gzip.compress(bytes(out))
+
+
+__all__ = ["SAMPLE_TYPES", "encode_profile"]
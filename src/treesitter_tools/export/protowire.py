@@ -0,0 +1,130 @@
+"""
+Protocol Buffers wire format, by hand: just the varint and length-delimited encodings
+the SCIP index and the binary record stream use, so no generated bindings or
+protobuf runtime are needed.
+"""
+
+from __future__ import annotations
+
+from typing import BinaryIO, Iterator, Optional, Sequence, Tuple, Union
+
+VARINT = 0
+FIXED64 = 1
+LENGTH_DELIMITED = 2
+FIXED32 = 5
+
+
+def varint(value: int) -> bytes:
+    value &= (1 << 64) - 1
+    out = bytearray()
+    while True:
+        byte = value & 0x7F
+        value >>= 7
+        if value:
+            out.append(byte | 0x80)
+        else:
+            out.append(byte)
+            return bytes(out)
+
+
+def key(field: int, wire_type: int) -> bytes:
+    return varint((field << 3) | wire_type)
+
+
+def int_field(field: int, value: int) -> bytes:
+    """A varint field, omitted when zero (proto3 default)."""
+    return key(field, VARINT) + varint(value) if value else b""
+
+
+def bytes_field(field: int, payload: bytes) -> bytes:
+    return key(field, LENGTH_DELIMITED) + varint(len(payload)) + payload
+
+
+def str_field(field: int, value: Optional[str]) -> bytes:
+    """A string field, omitted when empty or None."""
+    return bytes_field(field, value.encode("utf-8")) if value else b""
+
+
+def packed_field(field: int, values: Sequence[int]) -> bytes:
+    return bytes_field(field, b"".join(varint(v) for v in values)) if values else b""
+
+
+def read_varint(data: bytes, pos: int) -> Tuple[int, int]:
+    """The varint at `data[pos:]` and the position after it."""
+    result = shift = 0
+    while True:
+        if pos >= len(data):
+            raise ValueError("Truncated varint")
+        byte = data[pos]
+        pos += 1
+        result |= (byte & 0x7F) << shift
+        if not byte & 0x80:
+            return result, pos
+        shift += 7
+        if shift >= 64:
+            raise ValueError("Varint longer than 64 bits")
+
+
+def iter_fields(data: bytes) -> Iterator[Tuple[int, int, Union[int, bytes]]]:
+    """(field number, wire type, value) of each field of a message; fixed-width values are returned as bytes."""
+    pos = 0
+    while pos < len(data):
+        tag, pos = read_varint(data, pos)
+        field, wire_type = tag >> 3, tag & 7
+        if wire_type == VARINT:
+            value, pos = read_varint(data, pos)
+        elif wire_type == LENGTH_DELIMITED:
+            size, pos = read_varint(data, pos)
+            if pos + size > len(data):
+                raise ValueError(f"Truncated field {field}")
+            value, pos = data[pos : pos + size], pos + size
+        elif wire_type in (FIXED64, FIXED32):
+            width = 8 if wire_type == FIXED64 else 4
+            value, pos = data[pos : pos + width], pos + width
+        else:
+            raise ValueError(f"Unsupported wire type {wire_type} for field {field}")
+        yield field, wire_type, value
+
+
+def delimited(message: bytes) -> bytes:
+    """`message` prefixed with its varint length, as protobuf's writeDelimitedTo frames it."""
+    return varint(len(message)) + message
+
+
+def read_delimited(stream: BinaryIO) -> Optional[bytes]:
+    """The next length-prefixed message of `stream`, or None at a clean end of input."""
+    size = shift = 0
+    while True:
+        byte = stream.read(1)
+        if not byte:
+            if shift:
+                raise ValueError("Truncated message length")
+            return None
+        size |= (byte[0] & 0x7F) << shift
+        if not byte[0] & 0x80:
+            break
+        shift += 7
+        if shift >= 64:
+            raise ValueError("Message length longer than 64 bits")
+    message = stream.read(size)
+    if len(message) != size:
+        raise ValueError(f"Truncated message: expected {size} bytes, got {len(message)}")
+    return message
+
+
+__all__ = [
+    "FIXED32",
+    "FIXED64",
+    "LENGTH_DELIMITED",
+    "VARINT",
+    "bytes_field",
+    "delimited",
+    "int_field",
+    "iter_fields",
+    "key",
+    "packed_field",
+    "read_delimited",
+    "read_varint",
+    "str_field",
+    "varint",
+]
@@ -0,0 +1,329 @@
+"""
+Compact binary output for symbol and chunk records: length-delimited protobuf
+messages of the versioned `records.proto` schema (shipped next to this module), a
+writer that streams them to a sink, and a reader that turns them back into
+`FileSymbols` and `Chunk` objects.
+
+Encoding skips building JSON dicts and strings altogether, which matters when a scan
+of a very large tree is mostly serialization. Any protobuf runtime can read the
+stream with bindings generated from the schema (`parseDelimitedFrom` per record).
+"""
+
+from __future__ import annotations
+
+import gzip
+import time
+from pathlib import Path
+from typing import BinaryIO, Callable, Iterable, Iterator, Optional, Union
+
+from .. import __version__
+from ..chunker import Chunk
+from ..core import CodeSymbol, FileSymbols
+from .protowire import (
+    LENGTH_DELIMITED,
+    VARINT,
+    bytes_field,
+    delimited,
+    int_field,
+    iter_fields,
+    key,
+    read_delimited,
+    str_field,
+    varint,
+)
+
+FORMAT_VERSION = 1
+SCHEMA_PATH = Path(__file__).with_name("records.proto")
+TOOL_NAME = "treesitter-tools"
+
+# Record.record oneof field numbers.
+HEADER_FIELD = 1
+FILE_FIELD = 2
+CHUNK_FIELD = 3
+
+_GZIP_MAGIC = b"\x1f\x8b"
+
+# Symbol message: CodeSymbol attribute by field number, for the optional fields.
+_SYMBOL_OPTIONAL_STRINGS = {
+    5: "signature", 6: "docstring", 7: "content", 8: "doc", 9: "trailing_comment",
+    10: "elided", 11: "change", 14: "parent_symbol", 16: "language", 17: "body_hash", 18: "qualified_name",
+    20: "visibility", 23: "id", 24: "condition",
+}
+_SYMBOL_CONTAINER = 19
+_SYMBOL_TYPE_PARAMETER = 21
+_SYMBOL_INSTANTIATION = 22
+_SYMBOL_OPTIONAL_INTS = {12: "chunk_index", 13: "chunk_count"}
+
+
+def _opt_str(field: int, value: Optional[str]) -> bytes:
+    """An explicitly optional string: present (even if empty) unless None."""
+    return b"" if value is None else bytes_field(field, value.encode("utf-8"))
+
+
+def _opt_int(field: int, value: Optional[int]) -> bytes:
+    return b"" if value is None else key(field, VARINT) + varint(value)
+
+
+def encode_symbol(symbol: CodeSymbol) -> bytes:
+    out = [
+        str_field(1, symbol.kind),
+        str_field(2, symbol.name),
+        int_field(3, symbol.start_line),
+        int_field(4, symbol.end_line),
+    ]
+    for field, attr in _SYMBOL_OPTIONAL_STRINGS.items():
+        out.append(_opt_str(field, getattr(symbol, attr)))
+    for field, attr in _SYMBOL_OPTIONAL_INTS.items():
+        out.append(_opt_int(field, getattr(symbol, attr)))
+    if symbol.overflow is not None:
+        out.append(_opt_int(15, int(symbol.overflow)))
+    out.extend(bytes_field(_SYMBOL_CONTAINER, name.encode("utf-8")) for name in symbol.container or ())
+    for param in symbol.type_parameters or ():
+        out.append(bytes_field(_SYMBOL_TYPE_PARAMETER, str_field(1, param["name"]) + _opt_str(2, param["constraint"])))
+    for inst in symbol.instantiations or ():
+        message = str_field(1, inst["name"]) + _opt_str(2, inst["qualifier"])
+        message += b"".join(bytes_field(3, argument.encode("utf-8")) for argument in inst["type_arguments"])
+        out.append(bytes_field(_SYMBOL_INSTANTIATION, message))
+    return b"".join(out)
+
+
+def encode_file(report: FileSymbols) -> bytes:
+    out = [str_field(1, report.path.as_posix()), str_field(2, report.language)]
+    out.extend(bytes_field(3, encode_symbol(symbol)) for symbol in report.symbols)
+    out.append(_opt_str(4, report.error))
+    out.append(int_field(5, int(report.generated)))
+    out.append(int_field(6, int(report.vendored)))
+    out.append(_opt_str(7, report.error_kind if report.error else None))
+    out.append(_opt_str(8, report.encoding))
+    return b"".join(out)
+
+
+def encode_chunk(chunk: Chunk) -> bytes:
+    return b"".join((
+        str_field(1, chunk.path),
+        str_field(2, chunk.language),
+        int_field(3, chunk.index),
+        str_field(4, chunk.kind),
+        str_field(5, chunk.name),
+        int_field(6, chunk.start_line),
+        int_field(7, chunk.end_line),
+        int_field(8, chunk.token_count),
+        str_field(9, chunk.context),
+        str_field(10, chunk.content),
+        _opt_int(11, chunk.part),
+        _opt_int(12, chunk.part_count),
+        _opt_str(13, chunk.id),
+        _opt_str(14, chunk.parent_id),
+        _opt_str(15, chunk.prev_id),
+        _opt_str(16, chunk.next_id),
+        _opt_str(17, chunk.file_id),
+    ))
+
+
+def encode_header() -> bytes:
+    return int_field(1, FORMAT_VERSION) + str_field(2, TOOL_NAME) + str_field(3, __version__)
+
+
+def _record(field: int, message: bytes) -> bytes:
+    return delimited(bytes_field(field, message))
+
+
+class RecordWriter:
+    """
+    Write a header and then one delimited record per file or chunk to `stream` (a
+    sink or any binary file object). Flushes like `NDJSONWriter`: every
+    `flush_every` records and at least every `flush_interval` seconds, calling
+    `on_flush` after each. `header=False` continues a stream that already has one.
+    """
+
+    def __init__(
+        self,
+        stream,
+        flush_every: int = 1000,
+        flush_interval: float = 1.0,
+        clock: Callable[[], float] = time.monotonic,
+        on_flush: Optional[Callable[[], None]] = None,
+        header: bool = True,
+    ):
+        self.stream = stream
+        self._write = getattr(stream, "write_bytes", None) or stream.write
+        self.flush_every = max(flush_every, 1)
+        self.flush_interval = flush_interval
+        self.clock = clock
+        self.on_flush = on_flush
+        self.count = 0
+        self._pending = 0
+        self._last_flush = clock()
+        if header:
+            self._write(_record(HEADER_FIELD, encode_header()))
+
+    def _emit(self, data: bytes) -> None:
+        self._write(data)
+        self.count += 1
+        self._pending += 1
+        if self._pending >= self.flush_every or self.clock() - self._last_flush >= self.flush_interval:
+            self.flush()
+
+    def write_file(self, report: FileSymbols) -> None:
+        self._emit(_record(FILE_FIELD, encode_file(report)))
+
+    def write_chunk(self, chunk: Chunk) -> None:
+        self._emit(_record(CHUNK_FIELD, encode_chunk(chunk)))
+
+    def flush(self) -> None:
+        flush = getattr(self.stream, "flush", None)
+        if flush is not None:
+            flush()
+        self._pending = 0
+        self._last_flush = self.clock()
+        if self.on_flush is not None:
+            self.on_flush()
+
+
+def encode_files(reports: Iterable[FileSymbols]) -> bytes:
+    """A whole stream of file records, in memory."""
+    return _record(HEADER_FIELD, encode_header()) + b"".join(_record(FILE_FIELD, encode_file(r)) for r in reports)
+
+
+def encode_chunks(chunks: Iterable[Chunk]) -> bytes:
+    """A whole stream of chunk records, in memory."""
+    return _record(HEADER_FIELD, encode_header()) + b"".join(_record(CHUNK_FIELD, encode_chunk(c)) for c in chunks)
+
+
+# --- reading -----------------------------------------------------------------
+
+
+def _text(value: bytes) -> str:
+    return value.decode("utf-8")
+
+
+def decode_symbol(data: bytes) -> CodeSymbol:
+    symbol = CodeSymbol(kind="", name="", start_line=0, end_line=0, signature=None, docstring=None)
+    for field, wire_type, value in iter_fields(data):
+        if field == 1 and wire_type == LENGTH_DELIMITED:
+            symbol.kind = _text(value)
+        elif field == 2 and wire_type == LENGTH_DELIMITED:
+            symbol.name = _text(value)
+        elif field == 3 and wire_type == VARINT:
+            symbol.start_line = value
+        elif field == 4 and wire_type == VARINT:
+            symbol.end_line = value
+        elif field in _SYMBOL_OPTIONAL_STRINGS and wire_type == LENGTH_DELIMITED:
+            setattr(symbol, _SYMBOL_OPTIONAL_STRINGS[field], _text(value))
+        elif field in _SYMBOL_OPTIONAL_INTS and wire_type == VARINT:
+            setattr(symbol, _SYMBOL_OPTIONAL_INTS[field], value)
+        elif field == 15 and wire_type == VARINT:
+            symbol.overflow = bool(value)
+        elif field == _SYMBOL_CONTAINER and wire_type == LENGTH_DELIMITED:
+            symbol.container = [*(symbol.container or []), _text(value)]
+        elif field == _SYMBOL_TYPE_PARAMETER and wire_type == LENGTH_DELIMITED:
+            param = {"name": "", "constraint": None}
+            for number, _, part in iter_fields(value):
+                param["name" if number == 1 else "constraint"] = _text(part)
+            symbol.type_parameters = [*(symbol.type_parameters or []), param]
+        elif field == _SYMBOL_INSTANTIATION and wire_type == LENGTH_DELIMITED:
+            inst: dict = {"name": "", "qualifier": None, "type_arguments": []}
+            for number, _, part in iter_fields(value):
+                if number == 3:
+                    inst["type_arguments"].append(_text(part))
+                else:
+                    inst["name" if number == 1 else "qualifier"] = _text(part)
+            symbol.instantiations = [*(symbol.instantiations or []), inst]
+    if symbol.qualified_name is not None and symbol.container is None:
+        symbol.container = []
+    return symbol
+
+
+def decode_file(data: bytes) -> FileSymbols:
+    report = FileSymbols(path=Path(), language="", symbols=[])
+    for field, wire_type, value in iter_fields(data):
+        if field == 1 and wire_type == LENGTH_DELIMITED:
+            report.path = Path(_text(value))
+        elif field == 2 and wire_type == LENGTH_DELIMITED:
+            report.language = _text(value)
+        elif field == 3 and wire_type == LENGTH_DELIMITED:
+            report.symbols.append(decode_symbol(value))
+        elif field == 4 and wire_type == LENGTH_DELIMITED:
+            report.error = _text(value)
+        elif field == 5 and wire_type == VARINT:
+            report.generated = bool(value)
+        elif field == 6 and wire_type == VARINT:
+            report.vendored = bool(value)
+        elif field == 7 and wire_type == LENGTH_DELIMITED:
+            report.error_kind = _text(value)
+        elif field == 8 and wire_type == LENGTH_DELIMITED:
+            report.encoding = _text(value)
+    return report
+
+
+_CHUNK_STRINGS = {
+    1: "path", 2: "language", 4: "kind", 5: "name", 9: "context", 10: "content", 13: "id",
+    14: "parent_id", 15: "prev_id", 16: "next_id", 17: "file_id",
+}
+_CHUNK_INTS = {3: "index", 6: "start_line", 7: "end_line", 8: "token_count", 11: "part", 12: "part_count"}
+
+
+def decode_chunk(data: bytes) -> Chunk:
+    chunk = Chunk(path="", language="", kind="", name="", start_line=0, end_line=0, content="")
+    for field, wire_type, value in iter_fields(data):
+        if field in _CHUNK_STRINGS and wire_type == LENGTH_DELIMITED:
+            setattr(chunk, _CHUNK_STRINGS[field], _text(value))
+        elif field in _CHUNK_INTS and wire_type == VARINT:
+            setattr(chunk, _CHUNK_INTS[field], value)
+    return chunk
+
+
+def _check_header(data: bytes) -> None:
+    version = 0
+    for field, wire_type, value in iter_fields(data):
+        if field == 1 and wire_type == VARINT:
+            version = value
+    if version != FORMAT_VERSION:
+        raise ValueError(f"Unsupported record format version {version} (this reader understands {FORMAT_VERSION})")
+
+
+def iter_records(stream: BinaryIO) -> Iterator[Union[FileSymbols, Chunk]]:
+    """The file reports and chunks of a record stream, in order; ValueError for a stream this reader cannot read."""
+    header = read_delimited(stream)
+    fields = [(field, value) for field, wire_type, value in iter_fields(header or b"") if wire_type == LENGTH_DELIMITED]
+    if not fields or fields[0][0] != HEADER_FIELD:
+        raise ValueError("Not a treesitter-tools record stream (it does not start with a header record)")
+    _check_header(fields[0][1])
+    while True:
+        message = read_delimited(stream)
+        if message is None:
+            return
+        for field, wire_type, value in iter_fields(message):
+            if wire_type != LENGTH_DELIMITED:
+                continue
+            if field == FILE_FIELD:
+                yield decode_file(value)
+            elif field == CHUNK_FIELD:
+                yield decode_chunk(value)
+            # Unknown record types (from a newer writer of the same version) are skipped.
+
+
+def read_records(path: Path) -> Iterator[Union[FileSymbols, Chunk]]:
+    """`iter_records` over a file, gzip-compressed (as `--output x.pb.gz` writes) or not."""
+    with open(path, "rb") as raw:
+        compressed = raw.read(2) == _GZIP_MAGIC
+        raw.seek(0)
+        stream: BinaryIO = gzip.GzipFile(fileobj=raw) if compressed else raw
+        yield from iter_records(stream)
+
+
+__all__ = [
+    "FORMAT_VERSION",
+    "SCHEMA_PATH",
+    "RecordWriter",
+    "decode_chunk",
+    "decode_file",
+    "decode_symbol",
+    "encode_chunk",
+    "encode_chunks",
+    "encode_file",
+    "encode_files",
+    "encode_symbol",
+    "iter_records",
+    "read_records",
+]
@@ -0,0 +1,167 @@
+"""
+SARIF 2.1.0 log emitter.
+
+Analysis results (syntax diagnostics, complexity violations, unused symbols, code
+clones) are converted into SARIF (https://sarifweb.azurewebsites.net) so they render
+in GitHub code scanning and other SARIF viewers. Each result carries a partial
+fingerprint built from what it is about rather than where it is, so viewers can
+match findings across runs after unrelated edits move them.
+"""
+
+from __future__ import annotations
+
+import hashlib
+import json
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Dict, List, Optional, Sequence
+from urllib.parse import quote
+
+from .. import __version__
+
+SARIF_VERSION = "2.1.0"
+SARIF_SCHEMA = "https://json.schemastore.org/sarif-2.1.0.json"
+TOOL_NAME = "treesitter-tools"
+TOOL_URI = "https://github.com/grahama1970/treesitter-tools"
+FINGERPRINT_KEY = "treesitterTools/v1"
+SRCROOT = "%SRCROOT%"
+
+
+@dataclass(frozen=True)
+class SarifRule:
+    id: str
+    name: str  # PascalCase, as SARIF viewers display it
+    description: str
+    help: Optional[str] = None
+    level: str = "warning"  # default level of the rule's results
+    tags: Sequence[str] = ()
+    security_severity: Optional[float] = None  # 0.1-10, how GitHub code scanning ranks security results
+
+    def to_dict(self) -> dict:
+        rule = {
+            "id": self.id,
+            "name": self.name,
+            "shortDescription": {"text": self.description},
+            "fullDescription": {"text": self.help or self.description},
+            "defaultConfiguration": {"level": self.level},
+        }
+        if self.help:
+            rule["help"] = {"text": self.help}
+        properties: Dict[str, object] = {}
+        if self.tags:
+            properties["tags"] = list(self.tags)
+        if self.security_severity is not None:
+            properties["security-severity"] = f"{self.security_severity:.1f}"
+        if properties:
+            rule["properties"] = properties
+        return rule
+
+
+@dataclass(frozen=True)
+class SarifLocation:
+    path: str  # relative to the analysed root
+    start_line: int
+    start_column: Optional[int] = None
+    end_line: Optional[int] = None
+    end_column: Optional[int] = None
+    message: Optional[str] = None  # for related locations
+
+    def to_dict(self) -> dict:
+        region = {"startLine": self.start_line}
+        if self.start_column is not None:
+            region["startColumn"] = self.start_column
+        if self.end_line is not None:
+            region["endLine"] = self.end_line
+        if self.end_column is not None:
+            region["endColumn"] = self.end_column
+        path = Path(self.path)
+        if path.is_absolute():
+            artifact = {"uri": path.as_uri()}
+        else:
+            artifact = {"uri": quote(path.as_posix()), "uriBaseId": SRCROOT}
+        location = {"physicalLocation": {"artifactLocation": artifact, "region": region}}
+        if self.message:
+            location["message"] = {"text": self.message}
+        return location
+
+
+@dataclass
+class SarifResult:
+    rule: SarifRule
+    message: str
+    location: SarifLocation
+    identity: Sequence[str]  # what the finding is about; hashed into the fingerprint
+    level: Optional[str] = None  # overrides the rule's default level
+    related: List[SarifLocation] = field(default_factory=list)
+    properties: Dict[str, object] = field(default_factory=dict)
+    suppression: Optional[str] = None  # set for a result suppressed in the source: the suppressing comment
+
+    @property
+    def fingerprint(self) -> str:
+        text = "\0".join([self.rule.id, self.location.path, *self.identity])
+        return hashlib.sha256(text.encode("utf-8")).hexdigest()[:32]
+
+    def to_dict(self, rule_index: int, fingerprint: Optional[str] = None) -> dict:
+        result = {
+            "ruleId": self.rule.id,
+            "ruleIndex": rule_index,
+            "level": self.level or self.rule.level,
+            "message": {"text": self.message},
+            "locations": [self.location.to_dict()],
+            "partialFingerprints": {FINGERPRINT_KEY: fingerprint or self.fingerprint},
+        }
+        if self.related:
+            result["relatedLocations"] = [dict(loc.to_dict(), id=i) for i, loc in enumerate(self.related, 1)]
+        if self.properties:
+            result["properties"] = dict(self.properties)
+        if self.suppression is not None:
+            result["suppressions"] = [{"kind": "inSource", "justification": self.suppression}]
+        return result
+
+
+def sarif_log(results: Sequence[SarifResult], rules: Sequence[SarifRule], root: Optional[Path] = None) -> dict:
+    """
+    A one-run SARIF log. Every rule in `rules` is described, whether or not it
+    fired, so viewers can show what was checked; `root`, when given, is the
+    directory relative result paths are anchored to (`%SRCROOT%`).
+    """
+    index = {rule.id: i for i, rule in enumerate(rules)}
+    # Identical findings in one file are told apart by their order.
+    seen: Dict[str, int] = {}
+    entries = []
+    for result in results:
+        fingerprint = result.fingerprint
+        seen[fingerprint] = seen.get(fingerprint, 0) + 1
+        if seen[fingerprint] > 1:
+            fingerprint = f"{fingerprint}:{seen[fingerprint]}"
+        entries.append(result.to_dict(index[result.rule.id], fingerprint))
+    run: dict = {
+        "tool": {
+            "driver": {
+                "name": TOOL_NAME,
+                "version": __version__,
+                "informationUri": TOOL_URI,
+                "rules": [rule.to_dict() for rule in rules],
+            }
+        },
+        "results": entries,
+    }
+    if root is not None:
+        run["originalUriBaseIds"] = {SRCROOT: {"uri": Path(root).resolve().as_uri().rstrip("/") + "/"}}
+    return {"$schema": SARIF_SCHEMA, "version": SARIF_VERSION, "runs": [run]}
+
+
+def sarif_to_json(results: Sequence[SarifResult], rules: Sequence[SarifRule], root: Optional[Path] = None) -> str:
+    return json.dumps(sarif_log(results, rules, root), indent=2)
+
+
+__all__ = [
+    "FINGERPRINT_KEY",
+    "SARIF_SCHEMA",
+    "SARIF_VERSION",
+    "SarifLocation",
+    "SarifResult",
+    "SarifRule",
+    "sarif_log",
+    "sarif_to_json",
+]
@@ -0,0 +1,238 @@
+"""
+SCIP index emitter.
+
+Builds a SCIP (https://github.com/sourcegraph/scip) index from extracted
+definitions and resolved call references and serializes it to the protobuf
+wire format expected by `index.scip` consumers. The protobuf encoding is done
+by hand (see `protowire`) for the handful of message types used here, so no
+generated bindings or extra dependencies are required.
+"""
+
+from __future__ import annotations
+
+import json
+import re
+from dataclasses import dataclass
+from pathlib import Path
+from typing import Dict, List, Optional, Sequence, Tuple
+
+from tree_sitter import Node
+
+from .. import __version__, failures
+from ..callgraph import CallGraph
+from ..core import (
+    ParsedFile,
+    _extract_docstring,
+    iter_class_nodes,
+    iter_function_nodes,
+    iter_source_files,
+    parse_file,
+)
+from .protowire import bytes_field as _bytes
+from .protowire import int_field as _int
+from .protowire import packed_field as _packed
+from .protowire import str_field as _str
+
+SCHEME = "scip-treesitter"
+
+# scip.proto enum values used by this emitter.
+PROTOCOL_VERSION_UNSPECIFIED = 0
+TEXT_ENCODING_UTF8 = 1
+POSITION_ENCODING_UTF8 = 1
+SYMBOL_ROLE_DEFINITION = 1
+SYMBOL_ROLE_READ_ACCESS = 8
+KIND_CLASS = 7
+KIND_FUNCTION = 17
+KIND_INTERFACE = 21
+KIND_METHOD = 26
+
+_SIMPLE_NAME = re.compile(r"^[A-Za-z0-9_+\-$]+$")
+
+
+@dataclass
+class PackageInfo:
+    """SCIP package descriptor (`<manager> <name> <version>`)."""
+
+    name: str
+    version: str = "."
+    manager: str = "."
+
+    def moniker(self) -> str:
+        return " ".join(part.replace(" ", "  ") or "." for part in (self.manager, self.name, self.version))
+
+
+def escape_descriptor(name: str) -> str:
+    if _SIMPLE_NAME.match(name):
+        return name
+    return "`" + name.replace("`", "``") + "`"
+
+
+def make_symbol(package: PackageInfo, rel_path: str, descriptors: Sequence[Tuple[str, str]]) -> str:
+    """Global SCIP symbol: one namespace descriptor per path segment, then the given descriptors."""
+    parts = [escape_descriptor(segment) + "/" for segment in rel_path.split("/") if segment]
+    parts.extend(escape_descriptor(name) + suffix for name, suffix in descriptors)
+    return f"{SCHEME} {package.moniker()} {''.join(parts)}"
+
+
+def _range(node: Node) -> List[int]:
+    (start_row, start_col), (end_row, end_col) = node.start_point, node.end_point
+    if start_row == end_row:
+        return [start_row, start_col, end_col]
+    return [start_row, start_col, end_row, end_col]
+
+
+def _definition(
+    parsed: ParsedFile, node: Node, symbol: str, display_name: str, kind: int, enclosing: Optional[str]
+) -> Tuple[dict, dict]:
+    name_node = node.child_by_field_name("name")
+    if name_node is None:
+        name_node = node
+    occurrence = {
+        "range": _range(name_node),
+        "symbol": symbol,
+        "symbol_roles": SYMBOL_ROLE_DEFINITION,
+        "enclosing_range": _range(node),
+    }
+    info = {"symbol": symbol, "kind": kind, "display_name": display_name}
+    doc = _extract_docstring(node, parsed.source, parsed.language, parsed.root)
+    if doc:
+        info["documentation"] = [doc]
+    if enclosing:
+        info["enclosing_symbol"] = enclosing
+    return occurrence, info
+
+
+def _document(parsed: ParsedFile, rel_path: str, package: PackageInfo, defs: Dict[Tuple[str, int], str]) -> dict:
+    occurrences: List[dict] = []
+    symbols: List[dict] = []
+    class_symbols: Dict[str, str] = {}
+    for cls in iter_class_nodes(parsed):
+        descriptors = [(part, "#") for part in cls.qualified_name.split(".")]
+        symbol = make_symbol(package, rel_path, descriptors)
+        class_symbols[cls.qualified_name] = symbol
+        kind = KIND_INTERFACE if "interface" in cls.node.type or _is_go_interface(cls.node) else KIND_CLASS
+        occurrence, info = _definition(parsed, cls.node, symbol, cls.name, kind, class_symbols.get(cls.container or ""))
+        occurrences.append(occurrence)
+        symbols.append(info)
+    for fn in iter_function_nodes(parsed):
+        descriptors = [(part, "#") for part in fn.container.split(".")] if fn.container else []
+        descriptors.append((fn.name, "()."))
+        symbol = make_symbol(package, rel_path, descriptors)
+        defs[(rel_path, fn.node.start_point[0] + 1)] = symbol
+        enclosing = make_symbol(package, rel_path, descriptors[:-1]) if fn.container else None
+        kind = KIND_METHOD if fn.container else KIND_FUNCTION
+        occurrence, info = _definition(parsed, fn.node, symbol, fn.name, kind, enclosing)
+        occurrences.append(occurrence)
+        symbols.append(info)
+    return {
+        "language": parsed.language,
+        "relative_path": rel_path,
+        "occurrences": occurrences,
+        "symbols": symbols,
+        "position_encoding": POSITION_ENCODING_UTF8,
+    }
+
+
+def _is_go_interface(node: Node) -> bool:
+    type_node = node.child_by_field_name("type")
+    return type_node is not None and type_node.type == "interface_type"
+
+
+def build_index(
+    root: Path,
+    include: Sequence[str] | None = None,
+    exclude: Sequence[str] | None = None,
+    package_name: Optional[str] = None,
+    package_version: Optional[str] = None,
+) -> dict:
+    """Build a SCIP index (as a JSON-shaped dict mirroring scip.proto field names)."""
+    root = Path(root).resolve()
+    package = PackageInfo(name=package_name or root.name, version=package_version or ".")
+    graph = CallGraph()
+    documents: Dict[str, dict] = {}
+    defs: Dict[Tuple[str, int], str] = {}
+    for path in iter_source_files(root, include, exclude):
+        try:
+            parsed = parse_file(path)
+        except (ValueError, RuntimeError, OSError) as exc:
+            failures.record(path, exc)
+            continue
+        rel_path = path.relative_to(root).as_posix()
+        documents[rel_path] = _document(parsed, rel_path, package, defs)
+        graph.add_file(parsed, rel_path)
+    graph.resolve()
+
+    for edge in graph.edges:
+        if not edge.resolved or edge.column is None:
+            continue
+        symbol = defs.get((edge.callee_file, edge.callee_line))
+        if symbol is None:
+            continue
+        name = edge.callee.rsplit(".", 1)[-1]
+        start = edge.column - 1
+        documents[edge.file]["occurrences"].append(
+            {
+                "range": [edge.line - 1, start, start + len(name.encode("utf-8"))],
+                "symbol": symbol,
+                "symbol_roles": SYMBOL_ROLE_READ_ACCESS,
+            }
+        )
+
+    return {
+        "metadata": {
+            "version": PROTOCOL_VERSION_UNSPECIFIED,
+            "tool_info": {"name": "treesitter-tools", "version": __version__},
+            "project_root": root.as_uri(),
+            "text_document_encoding": TEXT_ENCODING_UTF8,
+        },
+        "documents": [documents[key] for key in sorted(documents)],
+    }
+
+
+def _encode_occurrence(occ: dict) -> bytes:
+    return (
+        _packed(1, occ["range"])
+        + _str(2, occ.get("symbol"))
+        + _int(3, occ.get("symbol_roles", 0))
+        + _packed(7, occ.get("enclosing_range", []))
+    )
+
+
+def _encode_symbol_information(info: dict) -> bytes:
+    out = _str(1, info["symbol"])
+    for doc in info.get("documentation", []):
+        out += _str(3, doc)
+    return out + _int(5, info.get("kind", 0)) + _str(6, info.get("display_name")) + _str(8, info.get("enclosing_symbol"))
+
+
+def _encode_document(doc: dict) -> bytes:
+    out = _str(4, doc["language"]) + _str(1, doc["relative_path"])
+    for occ in doc["occurrences"]:
+        out += _bytes(2, _encode_occurrence(occ))
+    for info in doc["symbols"]:
+        out += _bytes(3, _encode_symbol_information(info))
+    return out + _int(6, doc.get("position_encoding", 0))
+
+
+def encode_index(index: dict) -> bytes:
+    """Serialize a `build_index` result to SCIP protobuf bytes."""
+    meta = index["metadata"]
+    tool = meta["tool_info"]
+    tool_bytes = _str(1, tool["name"]) + _str(2, tool["version"])
+    meta_bytes = (
+        _int(1, meta["version"])
+        + _bytes(2, tool_bytes)
+        + _str(3, meta["project_root"])
+        + _int(4, meta["text_document_encoding"])
+    )
+    out = _bytes(1, meta_bytes)
+    for doc in index["documents"]:
+        out += _bytes(2, _encode_document(doc))
+    return out
+
+
+def index_to_json(index: dict) -> str:
+    return json.dumps(index, indent=2)
+
+
+__all__ = ["PackageInfo", "build_index", "encode_index", "escape_descriptor", "index_to_json", "make_symbol"]
@@ -0,0 +1,183 @@
+"""
+Per-file failures of directory walks, by category.
+
+A walk that meets a file it cannot use keeps going and records the file here instead:
+`unreadable` (an I/O or decoding error), `too_large` (over `--max-file-size`), `binary`
+(NUL bytes in a file of a known language), `unsupported_language` (no grammar for the
+file's language), or `parse_failure` (parsing or extraction raised). `scan` reports
+carry the category as their `error_kind`. The CLI prints the counts by category when a
+command finishes, writes the list with `--error-report FILE`, and exits non-zero under
+`--strict`.
+
+Files that are not source code at all (no language detected: READMEs, images, ...)
+get the kind `not_source` and are not failures: walks skip them without recording.
+
+The typed exceptions subclass ValueError (RuntimeError for a missing grammar), which
+is what these failures raised before, so existing `except` clauses still catch them.
+"""
+
+from __future__ import annotations
+
+import json
+from collections import Counter
+from contextlib import contextmanager
+from contextvars import ContextVar
+from dataclasses import dataclass
+from pathlib import Path
+from typing import Dict, Iterator, List, Optional
+
+UNREADABLE = "unreadable"
+TOO_LARGE = "too_large"
+BINARY = "binary"
+UNSUPPORTED_LANGUAGE = "unsupported_language"
+PARSE_FAILURE = "parse_failure"
+NOT_SOURCE = "not_source"
+
+CATEGORIES = (UNREADABLE, TOO_LARGE, BINARY, UNSUPPORTED_LANGUAGE, PARSE_FAILURE)
+# Every `error_kind` of a scan report: the failure categories and `not_source`.
+KINDS = (*CATEGORIES, NOT_SOURCE)
+
+
+class FileTooLargeError(ValueError):
+    """The file is over the `--max-file-size` limit."""
+
+    category = TOO_LARGE
+
+
+class BinaryFileError(ValueError):
+    """The file looks binary (NUL bytes near the start)."""
+
+    category = BINARY
+
+
+class NotSourceError(ValueError):
+    """No Tree-sitter language could be detected for the file: it is not source code."""
+
+    category = NOT_SOURCE
+
+
+class GrammarUnavailableError(RuntimeError):
+    """The file's language is known but its grammar cannot be loaded."""
+
+    category = UNSUPPORTED_LANGUAGE
+
+
+def category_of(exc: BaseException) -> str:
+    """The kind (one of `KINDS`) of an exception raised while reading or parsing one file."""
+    category = getattr(exc, "category", None)
+    if category in KINDS:
+        return category
+    if isinstance(exc, (OSError, UnicodeError)):
+        return UNREADABLE
+    return PARSE_FAILURE
+
+
+@dataclass
+class FileFailure:
+    path: str
+    category: str
+    message: str
+
+    def to_dict(self) -> dict:
+        return {"path": self.path, "category": self.category, "message": self.message}
+
+
+class FailureReport:
+    """The files a command could not use, one entry per file (the first failure wins; `not_source` is dropped)."""
+
+    def __init__(self) -> None:
+        self._failures: Dict[str, FileFailure] = {}
+        self.announced = 0  # failures already summarized by the command itself
+
+    def __len__(self) -> int:
+        return len(self._failures)
+
+    @property
+    def failures(self) -> List[FileFailure]:
+        return list(self._failures.values())
+
+    def add(self, path, category: str, message: str) -> None:
+        label = Path(path).as_posix()
+        if category != NOT_SOURCE and label not in self._failures:
+            self._failures[label] = FileFailure(label, category if category in CATEGORIES else PARSE_FAILURE, message)
+
+    def record(self, path, exc: BaseException) -> None:
+        self.add(path, category_of(exc), str(exc))
+
+    def counts(self) -> Dict[str, int]:
+        """Failures per category, in `CATEGORIES` order (categories without failures left out)."""
+        counter = Counter(f.category for f in self._failures.values())
+        return {c: counter[c] for c in CATEGORIES if counter[c]}
+
+    def summary(self) -> str:
+        """`2 too_large, 1 unreadable`."""
+        return ", ".join(f"{n} {category}" for category, n in self.counts().items())
+
+    def to_dict(self) -> dict:
+        return {"total": len(self), "counts": self.counts(), "failures": [f.to_dict() for f in self.failures]}
+
+    def write(self, path: Path) -> None:
+        Path(path).write_text(json.dumps(self.to_dict(), indent=2) + "\n", encoding="utf-8")
+
+
+# Set by the CLI for the duration of a command; None (library use) records nothing.
+ACTIVE: Optional[FailureReport] = None
+# The report of the innermost `collect_failures` block in this thread (or task), ahead of `ACTIVE`.
+_COLLECTING: ContextVar[Optional[FailureReport]] = ContextVar("treesitter_tools_failures", default=None)
+
+
+def current() -> Optional[FailureReport]:
+    """The report failures go to right now: this context's `collect_failures` block, else `ACTIVE`."""
+    collecting = _COLLECTING.get()
+    return collecting if collecting is not None else ACTIVE
+
+
+def record(path, exc: BaseException) -> None:
+    """Record that a walk skipped `path` because of `exc`."""
+    report = current()
+    if report is not None:
+        report.record(path, exc)
+
+
+def add(path, category: str, message: str) -> None:
+    report = current()
+    if report is not None:
+        report.add(path, category, message)
+
+
+@contextmanager
+def collect_failures() -> Iterator[FailureReport]:
+    """
+    Record the failures of the walks run inside the block (library counterpart of the CLI's
+    report). The block is context-local, so threads collecting at once keep separate reports.
+    """
+    report = FailureReport()
+    token = _COLLECTING.set(report)
+    try:
+        yield report
+    finally:
+        _COLLECTING.reset(token)
+
+
+__all__ = [
+    "ACTIVE",
+    "BINARY",
+    "CATEGORIES",
+    "KINDS",
+    "NOT_SOURCE",
+    "PARSE_FAILURE",
+    "TOO_LARGE",
+    "UNREADABLE",
+    "UNSUPPORTED_LANGUAGE",
+    "BinaryFileError",
+    "FailureReport",
+    "FileFailure",
+    "FileTooLargeError",
+    "GrammarUnavailableError",
+    "NotSourceError",
+    "add",
+    "category_of",
+    "collect_failures",
+    "current",
+    "record",
+]
@@ -0,0 +1,123 @@
+"""
+Generated-code and vendored-path detection.
+
+A file is generated when its name follows a code generator's convention (`*.pb.go`,
+`*_pb2.py`, `*.min.js`, lockfiles, ...), when a comment near the top says so
+(`// Code generated ... DO NOT EDIT.`, `@generated`, `<auto-generated>`, ...), or when
+it is minified JavaScript/CSS. A path is vendored when one of its directories is a
+conventional home for third-party code (`vendor/`, `node_modules/`, `third_party/`, ...).
+
+Directory walks drop such files when `SKIP_GENERATED`/`SKIP_VENDORED` are set (the
+global `--skip-generated`/`--skip-vendored` flags); otherwise scan reports mark them.
+"""
+
+from __future__ import annotations
+
+import fnmatch
+import re
+from pathlib import Path, PurePosixPath
+from typing import Optional
+
+from . import overlay
+
+# Set by the CLI's global flags; consulted by `core.iter_source_files`.
+SKIP_GENERATED = False
+SKIP_VENDORED = False
+
+# How much of a file is read to look for markers and minification.
+HEAD_BYTES = 8192
+MARKER_LINES = 30
+
+VENDOR_DIRS = frozenset({
+    "vendor", "vendors", "third_party", "third-party", "thirdparty", "3rdparty", "external", "extern",
+    "node_modules", "bower_components", "jspm_packages", "Pods", "Carthage", "site-packages", ".venv",
+})
+
+GENERATED_NAMES = (
+    "*.pb.go", "*.pb.gw.go", "*_pb2.py", "*_pb2_grpc.py", "*_pb2.pyi", "*.pb.cc", "*.pb.h", "*_pb.js", "*_pb.d.ts",
+    "*.g.dart", "*.freezed.dart", "*.designer.cs", "*.g.cs", "*.generated.*", "*_generated.*", "zz_generated*.go",
+    "*.min.js", "*.min.css", "*.min.mjs", "*-min.js", "*.bundle.js",
+    "package-lock.json", "yarn.lock", "pnpm-lock.yaml", "Cargo.lock", "poetry.lock", "Pipfile.lock", "go.sum",
+    "composer.lock", "Gemfile.lock", "uv.lock",
+)
+
+_COMMENT = re.compile(r"\s*(?://+!?|#+!?|/\*+!?|\*+|--+|;+|<!--|%+|\"\"\"|''')\s*(.*)")
+_MARKERS = (
+    re.compile(r"Code generated .* DO NOT EDIT"),
+    re.compile(r"@generated\b"),
+    re.compile(r"<auto-generated\b", re.I),
+    re.compile(r"^(?:auto-?generated|automatically generated|generated by\b|this (?:file|code) (?:is|was|has been) "
+               r"(?:auto(?:matically)?[- ]?)?generated)", re.I),
+    re.compile(r"\bgenerated\b.*\bdo not (?:edit|modify)\b|\bdo not (?:edit|modify)\b.*\bgenerated\b", re.I),
+)
+_MINIFIABLE = {".js", ".mjs", ".cjs", ".css"}
+MINIFIED_LINE_LENGTH = 500  # a line this long, in a file averaging over a fifth of it, means minified
+
+
+def vendored_reason(rel_path: str) -> Optional[str]:
+    """The vendor directory in `rel_path` (relative to the walked root), or None."""
+    for part in PurePosixPath(rel_path).parts[:-1]:
+        if part in VENDOR_DIRS:
+            return f"{part}/ directory"
+    return None
+
+
+def is_vendored(rel_path: str) -> bool:
+    return vendored_reason(rel_path) is not None
+
+
+def _head(path: Path) -> bytes:
+    overlaid = overlay.contents(path)
+    if overlaid is not None:
+        return overlaid[:HEAD_BYTES]
+    try:
+        with Path(path).open("rb") as f:
+            return f.read(HEAD_BYTES)
+    except OSError:
+        return b""
+
+
+def generated_reason(path: Path, head: Optional[bytes] = None) -> Optional[str]:
+    """Why `path` looks generated (file name, marker comment, or minification), or None."""
+    path = Path(path)
+    for pattern in GENERATED_NAMES:
+        if fnmatch.fnmatchcase(path.name, pattern):
+            return f"file name matches {pattern}"
+    text = (_head(path) if head is None else head[:HEAD_BYTES]).decode("utf-8", "replace")
+    lines = text.splitlines()
+    for line in lines[:MARKER_LINES]:
+        comment = _COMMENT.match(line)
+        if comment is None:
+            continue
+        for marker in _MARKERS:
+            if marker.search(comment.group(1)):
+                return f"marker comment: {comment.group(1).strip()[:80]}"
+    if path.suffix.lower() in _MINIFIABLE and lines:
+        longest = max(len(line) for line in lines)
+        if longest >= MINIFIED_LINE_LENGTH and len(text) / len(lines) >= MINIFIED_LINE_LENGTH / 5:
+            return "minified"
+    return None
+
+
+def is_generated(path: Path, head: Optional[bytes] = None) -> bool:
+    return generated_reason(path, head) is not None
+
+
+def skipped(path: Path, rel_path: str) -> bool:
+    """Whether a directory walk should drop `path` under the current skip flags."""
+    if SKIP_VENDORED and is_vendored(rel_path):
+        return True
+    return SKIP_GENERATED and is_generated(path)
+
+
+__all__ = [
+    "GENERATED_NAMES",
+    "SKIP_GENERATED",
+    "SKIP_VENDORED",
+    "VENDOR_DIRS",
+    "generated_reason",
+    "is_generated",
+    "is_vendored",
+    "skipped",
+    "vendored_reason",
+]
@@ -0,0 +1,156 @@
+"""Restrict analysis to files changed since a git ref and mark changed symbols."""
+
+from __future__ import annotations
+
+import re
+import subprocess
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Dict, Iterable, List, Optional, Set, Tuple
+
+from .core import CodeSymbol, detect_language, parse_source, symbols_from_tree
+
+_HUNK = re.compile(r"^@@ -\d+(?:,\d+)? \+(\d+)(?:,(\d+))? @@")
+
+
+def _git(cwd: Path, *args: str) -> bytes:
+    try:
+        result = subprocess.run(["git", "-c", "core.quotepath=off", *args], cwd=cwd, capture_output=True, check=False)
+    except FileNotFoundError:
+        raise RuntimeError("--since needs the 'git' executable on PATH") from None
+    if result.returncode != 0:
+        message = result.stderr.decode("utf-8", "replace").strip().splitlines()
+        raise ValueError(message[-1] if message else f"git {' '.join(args)} failed")
+    return result.stdout
+
+
+def repo_root(path: Path) -> Path:
+    """Top-level directory of the git work tree containing `path`."""
+    path = Path(path).resolve()
+    cwd = path.parent if path.is_file() else path
+    return Path(_git(cwd, "rev-parse", "--show-toplevel").decode("utf-8").strip()).resolve()
+
+
+@dataclass
+class FileChange:
+    path: Path  # absolute path in the working tree
+    status: str  # "added", "modified", or "renamed"
+    old_path: Optional[str] = None  # repo-relative path at the base commit
+    # Changed line ranges in the new file (1-based, inclusive) and lines after which lines were deleted.
+    hunks: List[Tuple[int, int]] = field(default_factory=list)
+    deletions: List[int] = field(default_factory=list)
+
+    def touches(self, start: int, end: int) -> bool:
+        if any(lo <= end and start <= hi for lo, hi in self.hunks):
+            return True
+        # A deletion counts when code was removed strictly inside the range.
+        return any(start <= line < end for line in self.deletions)
+
+
+class ChangeSet:
+    """Files changed between the merge base of `ref` and HEAD (as in `git diff ref...HEAD`)."""
+
+    def __init__(self, repo: Path, ref: str, base: str, files: Dict[Path, FileChange]):
+        self.repo = repo
+        self.ref = ref
+        self.base = base
+        self.files = files
+        self._base_names: Dict[Path, Set[Tuple[str, str]]] = {}
+
+    @property
+    def paths(self) -> Set[Path]:
+        return set(self.files)
+
+    def __contains__(self, path: Path) -> bool:
+        return Path(path).resolve() in self.files
+
+    def __len__(self) -> int:
+        return len(self.files)
+
+    def _names_at_base(self, change: FileChange, language: str) -> Set[Tuple[str, str]]:
+        if change.path not in self._base_names:
+            names: Set[Tuple[str, str]] = set()
+            if change.old_path is not None:
+                try:
+                    source = _git(self.repo, "show", f"{self.base}:{change.old_path}")
+                    symbols = symbols_from_tree(parse_source(source, language), source, language)
+                    names = {(sym.kind, sym.name) for sym in symbols}
+                except (ValueError, RuntimeError):
+                    pass
+            self._base_names[change.path] = names
+        return self._base_names[change.path]
+
+    def symbol_change(self, path: Path, symbol: CodeSymbol, language: Optional[str] = None) -> Optional[str]:
+        """Classify a symbol: "added" if absent at the base, "modified" if a hunk touches it, else None."""
+        change = self.files.get(Path(path).resolve())
+        if change is None:
+            return None
+        if change.status == "added":
+            return "added"
+        language = language or detect_language(change.path)
+        if language and (symbol.kind, symbol.name) not in self._names_at_base(change, language):
+            return "added"
+        return "modified" if change.touches(symbol.start_line, symbol.end_line) else None
+
+    def annotate(self, path: Path, symbols: Iterable[CodeSymbol], language: Optional[str] = None) -> None:
+        for sym in symbols:
+            sym.change = self.symbol_change(path, sym, language)
+
+
+def _parse_name_status(output: bytes) -> List[Tuple[str, Optional[str], str]]:
+    """(status letter, old path, new path) from `git diff --name-status -z` output."""
+    fields = output.decode("utf-8", "surrogateescape").split("\0")
+    entries = []
+    i = 0
+    while i < len(fields) and fields[i]:
+        status = fields[i][0]
+        if status in "RC":
+            entries.append((status, fields[i + 1], fields[i + 2]))
+            i += 3
+        else:
+            entries.append((status, fields[i + 1], fields[i + 1]))
+            i += 2
+    return entries
+
+
+def _parse_hunks(output: bytes) -> Dict[str, Tuple[List[Tuple[int, int]], List[int]]]:
+    hunks: Dict[str, Tuple[List[Tuple[int, int]], List[int]]] = {}
+    current = None
+    for line in output.decode("utf-8", "surrogateescape").splitlines():
+        if line.startswith("+++ "):
+            target = line[4:]
+            current = hunks.setdefault(target[2:], ([], [])) if target.startswith("b/") else None
+            continue
+        match = _HUNK.match(line)
+        if match and current is not None:
+            start = int(match.group(1))
+            length = int(match.group(2)) if match.group(2) is not None else 1
+            if length:
+                current[0].append((start, start + length - 1))
+            else:
+                current[1].append(start)
+    return hunks
+
+
+def changed_since(ref: str, cwd: Path) -> ChangeSet:
+    """Collect files added, modified, or renamed between `ref`'s merge base with HEAD and HEAD."""
+    repo = repo_root(cwd)
+    try:
+        _git(repo, "rev-parse", "--verify", "--quiet", f"{ref}^{{commit}}")
+    except ValueError:
+        raise ValueError(f"Unknown git ref: {ref}") from None
+    base = _git(repo, "merge-base", ref, "HEAD").decode("utf-8").strip()
+    entries = _parse_name_status(_git(repo, "diff", "--name-status", "-z", "-M", base, "HEAD"))
+    hunks = _parse_hunks(_git(repo, "diff", "-U0", "--no-color", "--no-ext-diff", "-M", base, "HEAD"))
+    files: Dict[Path, FileChange] = {}
+    for status, old, new in entries:
+        if status == "D":
+            continue
+        kind = {"A": "added", "R": "renamed", "C": "added"}.get(status, "modified")
+        added, deleted = hunks.get(new, ([], []))
+        path = (repo / new).resolve()
+        files[path] = FileChange(path, kind, old if kind != "added" else None, added, deleted)
+    return ChangeSet(repo, ref, base, files)
+
+
+__all__ = ["ChangeSet", "FileChange", "changed_since", "repo_root"]
@@ -0,0 +1,496 @@
+"""Go interface satisfaction: match method sets against interface declarations."""
+
+from __future__ import annotations
+
+import json
+import re
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Dict, Iterable, List, Optional, Sequence, Set, Tuple
+
+from tree_sitter import Node
+
+from . import failures
+from .core import ParsedFile, _node_text, iter_source_files, parse_file, parse_source
+
+# Commonly asked-about standard library interfaces, parsed like repo code so the
+# same signature normalisation applies. Repo declarations with the same qualified
+# name take precedence.
+STDLIB_INTERFACES = {
+    "io": """
+package io
+type Reader interface { Read(p []byte) (n int, err error) }
+type Writer interface { Write(p []byte) (n int, err error) }
+type Closer interface { Close() error }
+type Seeker interface { Seek(offset int64, whence int) (int64, error) }
+type ReaderAt interface { ReadAt(p []byte, off int64) (n int, err error) }
+type WriterAt interface { WriteAt(p []byte, off int64) (n int, err error) }
+type ReaderFrom interface { ReadFrom(r Reader) (n int64, err error) }
+type WriterTo interface { WriteTo(w Writer) (n int64, err error) }
+type ByteReader interface { ReadByte() (byte, error) }
+type ByteWriter interface { WriteByte(c byte) error }
+type RuneReader interface { ReadRune() (r rune, size int, err error) }
+type StringWriter interface { WriteString(s string) (n int, err error) }
+type ReadWriter interface { Reader; Writer }
+type ReadCloser interface { Reader; Closer }
+type WriteCloser interface { Writer; Closer }
+type ReadWriteCloser interface { Reader; Writer; Closer }
+type ReadSeeker interface { Reader; Seeker }
+type ReadSeekCloser interface { Reader; Seeker; Closer }
+""",
+    "fmt": """
+package fmt
+type Stringer interface { String() string }
+type GoStringer interface { GoString() string }
+""",
+    "builtin": """
+package builtin
+type error interface { Error() string }
+""",
+    "sort": """
+package sort
+type Interface interface { Len() int; Less(i, j int) bool; Swap(i, j int) }
+""",
+    "http": """
+package http
+type Handler interface { ServeHTTP(ResponseWriter, *Request) }
+""",
+    "json": """
+package json
+type Marshaler interface { MarshalJSON() ([]byte, error) }
+type Unmarshaler interface { UnmarshalJSON([]byte) error }
+""",
+    "encoding": """
+package encoding
+type TextMarshaler interface { MarshalText() (text []byte, err error) }
+type TextUnmarshaler interface { UnmarshalText(text []byte) error }
+type BinaryMarshaler interface { MarshalBinary() (data []byte, err error) }
+type BinaryUnmarshaler interface { UnmarshalBinary(data []byte) error }
+""",
+    "context": """
+package context
+type Context interface {
+    Deadline() (deadline time.Time, ok bool)
+    Done() <-chan struct{}
+    Err() error
+    Value(key any) any
+}
+""",
+}
+
+_QUALIFIER = re.compile(r"\b[A-Za-z_]\w*\.(?=[A-Za-z_])")
+_TYPE_NAME_NODES = {"type_identifier", "qualified_type", "generic_type"}
+
+
+def _normalize_type(text: str) -> str:
+    """Collapse whitespace and drop package qualifiers (`*store.Item` -> `*Item`)."""
+    text = " ".join(text.split())
+    text = _QUALIFIER.sub("", text)
+    return text.replace("interface {}", "any").replace("interface{}", "any")
+
+
+def _param_types(node: Optional[Node], source: bytes) -> Tuple[str, ...]:
+    if node is None:
+        return ()
+    if node.type != "parameter_list":
+        return (_normalize_type(_node_text(node, source)),)
+    types: List[str] = []
+    for param in node.named_children:
+        if param.type not in {"parameter_declaration", "variadic_parameter_declaration"}:
+            continue
+        type_node = param.child_by_field_name("type")
+        if type_node is None:
+            continue
+        rendered = _normalize_type(_node_text(type_node, source))
+        if param.type == "variadic_parameter_declaration":
+            rendered = "..." + rendered
+        count = max(len(param.children_by_field_name("name")), 1)
+        types.extend([rendered] * count)
+    return tuple(types)
+
+
+@dataclass(frozen=True)
+class MethodSig:
+    name: str
+    params: Tuple[str, ...]
+    results: Tuple[str, ...]
+
+    @classmethod
+    def from_node(cls, node: Node, source: bytes) -> "MethodSig":
+        name_node = node.child_by_field_name("name")
+        return cls(
+            name=_node_text(name_node, source) if name_node is not None else "<anonymous>",
+            params=_param_types(node.child_by_field_name("parameters"), source),
+            results=_param_types(node.child_by_field_name("result"), source),
+        )
+
+    def render(self) -> str:
+        text = f"{self.name}({', '.join(self.params)})"
+        if len(self.results) == 1:
+            text += f" {self.results[0]}"
+        elif self.results:
+            text += f" ({', '.join(self.results)})"
+        return text
+
+
+@dataclass
+class GoInterface:
+    name: str
+    package: str
+    directory: str
+    path: str
+    line: int
+    methods: Dict[str, MethodSig] = field(default_factory=dict)
+    embeds: List[str] = field(default_factory=list)
+    constraint: bool = False
+
+    @property
+    def qualified_name(self) -> str:
+        return self.name if self.package == "builtin" else f"{self.package}.{self.name}"
+
+
+@dataclass
+class GoType:
+    name: str
+    package: str
+    directory: str
+    path: str
+    line: int
+    # method name -> (signature, has pointer receiver)
+    methods: Dict[str, Tuple[MethodSig, bool]] = field(default_factory=dict)
+    # (package qualifier or None, type name, embedded through a pointer)
+    embedded: List[Tuple[Optional[str], str, bool]] = field(default_factory=list)
+
+    @property
+    def qualified_name(self) -> str:
+        return f"{self.package}.{self.name}"
+
+
+def _package_name(parsed: ParsedFile) -> str:
+    for child in parsed.root.named_children:
+        if child.type == "package_clause":
+            for ident in child.named_children:
+                if ident.type == "package_identifier":
+                    return parsed.text(ident)
+    return "main"
+
+
+def _type_ref(node: Node, source: bytes) -> Tuple[Optional[str], str]:
+    """(package qualifier, name) for a type_identifier / qualified_type / generic_type."""
+    if node.type == "generic_type":
+        inner = node.child_by_field_name("type")
+        if inner is not None:
+            return _type_ref(inner, source)
+    text = _node_text(node, source).split("[")[0].strip()
+    qualifier, sep, name = text.rpartition(".")
+    return (qualifier if sep else None), name
+
+
+def _interface_from(node: Node, iface: GoInterface, source: bytes) -> None:
+    for child in node.named_children:
+        if child.type in {"method_elem", "method_spec"}:
+            sig = MethodSig.from_node(child, source)
+            iface.methods[sig.name] = sig
+        elif child.type in {"type_elem", "constraint_elem"}:
+            parts = child.named_children
+            if len(parts) == 1 and parts[0].type in _TYPE_NAME_NODES:
+                qualifier, name = _type_ref(parts[0], source)
+                iface.embeds.append(f"{qualifier}.{name}" if qualifier else name)
+            else:
+                iface.constraint = True
+        elif child.type in _TYPE_NAME_NODES:
+            qualifier, name = _type_ref(child, source)
+            iface.embeds.append(f"{qualifier}.{name}" if qualifier else name)
+
+
+def _struct_embeds(node: Node, go_type: GoType, source: bytes) -> None:
+    for field_list in node.named_children:
+        if field_list.type != "field_declaration_list":
+            continue
+        for decl in field_list.named_children:
+            if decl.type != "field_declaration" or decl.child_by_field_name("name") is not None:
+                continue
+            type_node = decl.child_by_field_name("type")
+            if type_node is None:
+                continue
+            pointer = any(c.type == "*" for c in decl.children)
+            if type_node.type == "pointer_type":
+                pointer = True
+                type_node = type_node.named_children[0] if type_node.named_children else type_node
+            qualifier, name = _type_ref(type_node, source)
+            go_type.embedded.append((qualifier, name, pointer))
+
+
+def _receiver(node: Node, source: bytes) -> Tuple[Optional[str], bool]:
+    receiver = node.child_by_field_name("receiver")
+    if receiver is None:
+        return None, False
+    for param in receiver.named_children:
+        type_node = param.child_by_field_name("type")
+        if type_node is None:
+            continue
+        text = _node_text(type_node, source).strip()
+        return text.lstrip("*").split("[")[0].strip(), text.startswith("*")
+    return None, False
+
+
+class GoUniverse:
+    """Interfaces and named types collected from Go sources (plus `STDLIB_INTERFACES`)."""
+
+    def __init__(self) -> None:
+        self.interfaces: List[GoInterface] = []
+        self.types: List[GoType] = []
+        self._pending_methods: List[Tuple[str, str, MethodSig, bool]] = []
+
+    def add_file(self, parsed: ParsedFile, label: str, stdlib: bool = False) -> None:
+        package = _package_name(parsed)
+        directory = f"<stdlib>/{package}" if stdlib else (label.rpartition("/")[0] or ".")
+        stack = [parsed.root]
+        while stack:
+            node = stack.pop()
+            if node.type == "type_spec":
+                name_node = node.child_by_field_name("name")
+                type_node = node.child_by_field_name("type")
+                if name_node is not None and type_node is not None:
+                    name, line = parsed.text(name_node), node.start_point[0] + 1
+                    if type_node.type == "interface_type":
+                        iface = GoInterface(name, package, directory, label, line)
+                        _interface_from(type_node, iface, parsed.source)
+                        self.interfaces.append(iface)
+                    else:
+                        go_type = GoType(name, package, directory, label, line)
+                        if type_node.type == "struct_type":
+                            _struct_embeds(type_node, go_type, parsed.source)
+                        self.types.append(go_type)
+                continue
+            if node.type == "method_declaration":
+                type_name, pointer = _receiver(node, parsed.source)
+                if type_name:
+                    self._pending_methods.append(
+                        (directory, type_name, MethodSig.from_node(node, parsed.source), pointer)
+                    )
+                continue
+            stack.extend(reversed(node.children))
+
+    def finalize(self) -> None:
+        by_key = {(t.directory, t.name): t for t in self.types}
+        for directory, type_name, sig, pointer in self._pending_methods:
+            target = by_key.get((directory, type_name))
+            if target is not None:
+                target.methods[sig.name] = (sig, pointer)
+        self._pending_methods = []
+
+    # -- resolution ----------------------------------------------------------
+
+    def find_interfaces(self, query: str) -> List[GoInterface]:
+        qualifier, _, name = query.rpartition(".")
+        matches = [i for i in self.interfaces if i.name == name and (not qualifier or i.package == qualifier)]
+        repo = [i for i in matches if not i.directory.startswith("<stdlib>")]
+        stdlib = [i for i in matches if i.directory.startswith("<stdlib>")
+                  and not any(r.qualified_name == i.qualified_name for r in repo)]
+        return repo + stdlib
+
+    def find_types(self, query: str) -> List[Tuple[GoType, bool]]:
+        pointer = query.startswith("*")
+        qualifier, _, name = query.lstrip("*").rpartition(".")
+        return [(t, pointer) for t in self.types if t.name == name and (not qualifier or t.package == qualifier)]
+
+    def _lookup_interface(self, ref: str, context: GoInterface) -> Optional[GoInterface]:
+        qualifier, _, name = ref.rpartition(".")
+        for iface in self.interfaces:
+            if iface.name != name:
+                continue
+            if qualifier and iface.package == qualifier:
+                return iface
+            if not qualifier and iface.directory == context.directory:
+                return iface
+        return None
+
+    def interface_methods(self, iface: GoInterface, seen: Optional[Set[int]] = None) -> Optional[Dict[str, MethodSig]]:
+        """Full method set including embedded interfaces; None for type-constraint interfaces."""
+        if iface.constraint:
+            return None
+        seen = seen if seen is not None else set()
+        seen.add(id(iface))
+        methods = dict(iface.methods)
+        for ref in iface.embeds:
+            embedded = self._lookup_interface(ref, iface)
+            if embedded is None:
+                if ref in {"any", "comparable"}:
+                    continue
+                return None  # unknown embedded interface (or a type constraint): can't decide
+            if id(embedded) in seen:
+                continue
+            inner = self.interface_methods(embedded, seen)
+            if inner is None:
+                return None
+            for key, sig in inner.items():
+                methods.setdefault(key, sig)
+        return methods
+
+    def method_set(self, go_type: GoType, seen: Optional[Set[int]] = None) -> Dict[str, Tuple[MethodSig, bool]]:
+        """Declared and promoted methods; the flag marks methods only the pointer type has."""
+        seen = seen if seen is not None else set()
+        seen.add(id(go_type))
+        methods = dict(go_type.methods)
+        by_name = {(t.directory, t.name): t for t in self.types}
+        for qualifier, name, via_pointer in go_type.embedded:
+            if qualifier:
+                embedded = next((t for t in self.types if t.package == qualifier and t.name == name), None)
+            else:
+                embedded = by_name.get((go_type.directory, name))
+            if embedded is None or id(embedded) in seen:
+                continue
+            for key, (sig, pointer_only) in self.method_set(embedded, seen).items():
+                # Embedding *T promotes T's pointer methods to the value type too.
+                methods.setdefault(key, (sig, pointer_only and not via_pointer))
+        return methods
+
+
+def load_universe(
+    root: Path,
+    include: Sequence[str] | None = None,
+    exclude: Sequence[str] | None = None,
+    stdlib: bool = True,
+) -> GoUniverse:
+    """Collect Go interfaces and types under `root` (a file or directory)."""
+    universe = GoUniverse()
+    root = Path(root)
+    files: Iterable[Tuple[Path, str]]
+    if root.is_file():
+        files = [(root, root.name)]
+    else:
+        base = root.resolve()
+        files = ((p, p.relative_to(base).as_posix()) for p in iter_source_files(base, include or ["**/*.go"], exclude))
+    for path, label in files:
+        try:
+            parsed = parse_file(path)
+        except (ValueError, RuntimeError, OSError) as exc:
+            failures.record(path, exc)
+            continue
+        if parsed.language == "go":
+            universe.add_file(parsed, label)
+    if stdlib:
+        for package in sorted(STDLIB_INTERFACES):
+            source = STDLIB_INTERFACES[package].encode("utf-8")
+            parsed = ParsedFile(Path(f"{package}.go"), "go", source, parse_source(source, "go"))
+            universe.add_file(parsed, f"<stdlib>/{package}", stdlib=True)
+    universe.finalize()
+    return universe
+
+
+def match_details(
+    required: Dict[str, MethodSig], available: Dict[str, Tuple[MethodSig, bool]], pointer: bool
+) -> List[dict]:
+    """Per-method comparison: `match`, `missing`, `signature_mismatch`, or `pointer_receiver`."""
+    details = []
+    for name in sorted(required):
+        want = required[name]
+        entry = {"method": name, "interface": want.render(), "status": "match"}
+        have = available.get(name)
+        if have is None:
+            entry["status"] = "missing"
+        else:
+            sig, pointer_only = have
+            entry["type"] = sig.render()
+            if (sig.params, sig.results) != (want.params, want.results):
+                entry["status"] = "signature_mismatch"
+            elif pointer_only and not pointer:
+                entry["status"] = "pointer_receiver"
+        details.append(entry)
+    return details
+
+
+def _type_entry(go_type: GoType, pointer: bool) -> dict:
+    return {
+        "type": ("*" if pointer else "") + go_type.qualified_name,
+        "path": go_type.path,
+        "line": go_type.line,
+    }
+
+
+def _iface_entry(iface: GoInterface) -> dict:
+    return {"interface": iface.qualified_name, "path": iface.path, "line": iface.line}
+
+
+def _check(
+    universe: GoUniverse, iface: GoInterface, required: Dict[str, MethodSig], go_type: GoType, partial: bool
+) -> List[dict]:
+    """Entries for `go_type` against `iface`: the value type if it satisfies, else the pointer type."""
+    methods = universe.method_set(go_type)
+    value = match_details(required, methods, pointer=False)
+    if all(d["status"] == "match" for d in value):
+        return [{**_type_entry(go_type, False), **_iface_entry(iface), "implements": True, "methods": value}]
+    pointer = match_details(required, methods, pointer=True)
+    if all(d["status"] == "match" for d in pointer):
+        return [{**_type_entry(go_type, True), **_iface_entry(iface), "implements": True, "methods": pointer}]
+    if partial and any(d["status"] != "missing" for d in pointer):
+        return [{**_type_entry(go_type, True), **_iface_entry(iface), "implements": False, "methods": pointer}]
+    return []
+
+
+def implementers(universe: GoUniverse, interface: str, partial: bool = False) -> List[dict]:
+    """Types whose method set satisfies `interface` (`Name` or `pkg.Name`)."""
+    interfaces = universe.find_interfaces(interface)
+    if not interfaces:
+        raise ValueError(f"No Go interface named '{interface}'")
+    results = []
+    for iface in interfaces:
+        required = universe.interface_methods(iface)
+        if not required:
+            continue  # empty or constraint interface: everything / nothing matches
+        for go_type in universe.types:
+            results.extend(_check(universe, iface, required, go_type, partial))
+    return sorted(results, key=lambda r: (not r["implements"], r["path"], r["line"], r["type"]))
+
+
+def satisfied_interfaces(universe: GoUniverse, type_name: str, partial: bool = False) -> List[dict]:
+    """Interfaces satisfied by `type_name` (`Name`, `pkg.Name`, or `*Name` for the pointer type)."""
+    types = universe.find_types(type_name)
+    if not types:
+        raise ValueError(f"No Go type named '{type_name.lstrip('*')}'")
+    results = []
+    for go_type, pointer in types:
+        methods = universe.method_set(go_type)
+        for iface in universe.interfaces:
+            required = universe.interface_methods(iface)
+            if not required:
+                continue
+            details = match_details(required, methods, pointer)
+            ok = all(d["status"] == "match" for d in details)
+            if ok or (partial and any(d["status"] != "missing" for d in details)):
+                results.append({**_type_entry(go_type, pointer), **_iface_entry(iface), "implements": ok,
+                                "methods": details})
+    return sorted(results, key=lambda r: (not r["implements"], r["interface"], r["path"], r["type"]))
+
+
+def results_to_json(results: Sequence[dict]) -> str:
+    return json.dumps(list(results), indent=2)
+
+
+def results_to_text(results: Sequence[dict]) -> str:
+    lines = []
+    for r in results:
+        verdict = "implements" if r["implements"] else "partially implements"
+        lines.append(f"{r['type']} ({r['path']}:{r['line']}) {verdict} {r['interface']}")
+        for d in r["methods"]:
+            line = f"  {d['status']:<18} {d['interface']}"
+            if d["status"] == "signature_mismatch":
+                line += f"  (has {d['type']})"
+            lines.append(line)
+    return "\n".join(lines) + ("\n" if lines else "")
+
+
+__all__ = [
+    "GoInterface",
+    "GoType",
+    "GoUniverse",
+    "MethodSig",
+    "STDLIB_INTERFACES",
+    "implementers",
+    "load_universe",
+    "match_details",
+    "results_to_json",
+    "results_to_text",
+    "satisfied_interfaces",
+]
@@ -0,0 +1,357 @@
+"""
+Load extra grammars at runtime (WebAssembly or shared libraries) from a manifest,
+and pin the grammars in use: `pin_grammars` records each grammar's source, ABI,
+version, and fingerprint in a lock file, and `verify_grammars` reports grammars
+that drifted from it or lack node kinds the bundled queries and node tables name.
+"""
+
+from __future__ import annotations
+
+import ctypes
+import json
+import re
+import threading
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Callable, Dict, List, Optional, Sequence
+
+from tree_sitter import Language
+
+from .cache import _package_version, grammar_version
+from .core import LANGUAGE_SPECS, get_language_spec, load_language, register_language
+from .languages import LanguageSpec
+from .querylib import list_queries
+
+MANIFEST_NAME = "grammars.json"
+LOCK_NAME = "grammars.lock"
+LOCK_VERSION = 1
+NATIVE_SUFFIXES = {".so", ".dylib", ".dll"}
+
+_DOC_STYLES = {"docstring", "rust_doc", "leading_comment"}
+
+
+def _load_wasm(path: Path, name: str) -> Language:
+    # py-tree-sitter only exposes WASM loading when built with it; wasmtime provides the engine.
+    from_wasm = getattr(Language, "from_wasm", None)
+    try:
+        import wasmtime
+    except ImportError:
+        wasmtime = None
+    if from_wasm is None or wasmtime is None:
+        raise RuntimeError(
+            f"Loading {path.name} needs py-tree-sitter built with WASM support and the 'wasmtime' package; "
+            "alternatively build the grammar as a shared library (.so/.dylib/.dll)"
+        )
+    return from_wasm(name, wasmtime.Engine(), path.read_bytes())
+
+
+def _load_native(path: Path, symbol: str) -> Language:
+    library = ctypes.cdll.LoadLibrary(str(path))
+    try:
+        entry = getattr(library, symbol)
+    except AttributeError:
+        raise RuntimeError(f"{path.name} does not export '{symbol}'") from None
+    entry.restype = ctypes.c_void_p
+    return Language(entry())
+
+
+def grammar_loader(path: Path, name: str, symbol: Optional[str] = None) -> Callable[[], Language]:
+    """Lazy, memoized loader for a `.wasm` or native grammar file."""
+    path = Path(path)
+    lock = threading.Lock()
+    cache: List[Language] = []
+
+    def load() -> Language:
+        with lock:
+            if not cache:
+                if not path.is_file():
+                    raise RuntimeError(f"Grammar file not found: {path}")
+                if path.suffix == ".wasm":
+                    cache.append(_load_wasm(path, name))
+                elif path.suffix in NATIVE_SUFFIXES:
+                    cache.append(_load_native(path, symbol or f"tree_sitter_{name.replace('-', '_')}"))
+                else:
+                    raise RuntimeError(f"Unsupported grammar file type: {path.name}")
+            return cache[0]
+
+    load.path = path  # lets the result cache fingerprint the grammar file
+    return load
+
+
+def _names(entry: dict, key: str, context: str) -> frozenset:
+    value = entry.get(key, [])
+    if not isinstance(value, list) or not all(isinstance(v, str) for v in value):
+        raise ValueError(f"{context}: '{key}' must be a list of strings")
+    return frozenset(value)
+
+
+def spec_from_manifest_entry(entry: dict, base: Path) -> LanguageSpec:
+    """Build a `LanguageSpec` from one manifest entry; relative paths resolve against `base`."""
+    if not isinstance(entry, dict):
+        raise ValueError("Each grammar entry must be an object")
+    name = entry.get("name")
+    if not isinstance(name, str) or not name:
+        raise ValueError("Grammar entry needs a 'name'")
+    context = f"grammar '{name}'"
+    file_name = entry.get("file")
+    if not isinstance(file_name, str) or not file_name:
+        raise ValueError(f"{context}: 'file' is required")
+    extensions = entry.get("extensions")
+    if not isinstance(extensions, list) or not extensions or not all(isinstance(e, str) for e in extensions):
+        raise ValueError(f"{context}: 'extensions' must be a non-empty list of strings")
+    doc_style = entry.get("doc_style")
+    if doc_style is not None and doc_style not in _DOC_STYLES:
+        raise ValueError(f"{context}: unknown doc_style '{doc_style}' (expected one of {', '.join(sorted(_DOC_STYLES))})")
+    queries = entry.get("queries")
+    return LanguageSpec(
+        name=name,
+        extensions=tuple(e.lstrip(".").lower() for e in extensions),
+        function_nodes=_names(entry, "function_nodes", context),
+        class_nodes=_names(entry, "class_nodes", context),
+        import_nodes=_names(entry, "import_nodes", context),
+        call_nodes=_names(entry, "call_nodes", context),
+        receiver_names=_names(entry, "receiver_names", context),
+        closure_nodes=_names(entry, "closure_nodes", context),
+        loader=grammar_loader(base / file_name, name, entry.get("symbol")),
+        doc_style=doc_style,
+        query_dir=(base / queries) if isinstance(queries, str) else None,
+    )
+
+
+def read_manifest(grammar_dir: Path) -> List[LanguageSpec]:
+    """Parse `<grammar_dir>/grammars.json` into language specs (grammars load on first use)."""
+    grammar_dir = Path(grammar_dir)
+    manifest = grammar_dir / MANIFEST_NAME
+    if not manifest.is_file():
+        raise ValueError(f"No {MANIFEST_NAME} in {grammar_dir}")
+    try:
+        data = json.loads(manifest.read_text(encoding="utf-8"))
+    except json.JSONDecodeError as exc:
+        raise ValueError(f"Invalid {manifest}: {exc}") from exc
+    entries = data.get("grammars") if isinstance(data, dict) else None
+    if not isinstance(entries, list):
+        raise ValueError(f"{manifest}: expected an object with a 'grammars' list")
+    return [spec_from_manifest_entry(entry, grammar_dir) for entry in entries]
+
+
+# The directories `load_grammar_dir` has registered, in order (worker processes load them again).
+GRAMMAR_DIRS: List[Path] = []
+
+
+def load_grammar_dir(grammar_dir: Path) -> List[LanguageSpec]:
+    """Register every grammar in the directory's manifest, replacing same-named languages."""
+    specs = read_manifest(grammar_dir)
+    for spec in specs:
+        register_language(spec)
+    if Path(grammar_dir) not in GRAMMAR_DIRS:
+        GRAMMAR_DIRS.append(Path(grammar_dir))
+    return specs
+
+
+# -- pinning -----------------------------------------------------------------
+
+# The spec tables whose node kinds are looked up in the grammar.
+_SPEC_TABLES = ("function_nodes", "class_nodes", "import_nodes", "call_nodes", "closure_nodes")
+# Parenthesised names in a query that are not node kinds.
+_NOT_KINDS = {"_", "ERROR", "MISSING"}
+_QUERY_KIND = re.compile(r"\(\s*([A-Za-z_][A-Za-z0-9_]*)")
+_QUERY_NOISE = re.compile(r'"(?:[^"\\]|\\.)*"|;[^\n]*')
+
+
+@dataclass
+class GrammarInfo:
+    """What a language parses with: where the grammar comes from and its exact build."""
+
+    language: str
+    grammar: str
+    source: str  # "tree-sitter-language-pack X.Y.Z", a grammar file path, or "custom loader"
+    abi: Optional[int] = None
+    version: Optional[str] = None
+    node_kinds: Optional[int] = None
+    fingerprint: Optional[str] = None
+    error: Optional[str] = None  # why the grammar could not be loaded
+
+    def to_dict(self) -> dict:
+        data = {
+            "language": self.language,
+            "grammar": self.grammar,
+            "source": self.source,
+            "abi": self.abi,
+            "version": self.version,
+            "node_kinds": self.node_kinds,
+            "fingerprint": self.fingerprint,
+        }
+        if self.error:
+            data["error"] = self.error
+        return data
+
+
+@dataclass
+class GrammarProblem:
+    language: str
+    kind: str  # "unavailable", "unpinned", "mismatch", "query", or "node_kind"
+    message: str
+    severity: str = "error"  # node-table gaps are warnings: extraction just finds nothing there
+
+    def to_dict(self) -> dict:
+        return {"language": self.language, "kind": self.kind, "severity": self.severity, "message": self.message}
+
+
+@dataclass
+class GrammarReport:
+    grammars: List[GrammarInfo] = field(default_factory=list)
+    problems: List[GrammarProblem] = field(default_factory=list)
+
+    @property
+    def errors(self) -> List[GrammarProblem]:
+        return [p for p in self.problems if p.severity == "error"]
+
+    def to_dict(self) -> dict:
+        return {
+            "grammars": [g.to_dict() for g in self.grammars],
+            "problems": [p.to_dict() for p in self.problems],
+        }
+
+
+def grammar_info(language: str) -> GrammarInfo:
+    """Source, ABI, version, size, and fingerprint (`cache.grammar_version`) of `language`'s grammar."""
+    spec = get_language_spec(language)
+    grammar = spec.grammar if spec is not None and spec.grammar else language
+    path = getattr(spec.loader, "path", None) if spec is not None else None
+    if path is not None:
+        source = Path(path).as_posix()
+    elif spec is not None and spec.loader is not None:
+        source = "custom loader"
+    else:
+        source = f"tree-sitter-language-pack {_package_version('tree-sitter-language-pack')}"
+    info = GrammarInfo(language, grammar, source)
+    try:
+        loaded = load_language(language)
+        info.fingerprint = grammar_version(language)
+    except RuntimeError as exc:
+        info.error = str(exc)
+        return info
+    version = getattr(loaded, "semantic_version", None)
+    info.abi = getattr(loaded, "abi_version", None)
+    info.version = ".".join(str(part) for part in version) if version else None
+    info.node_kinds = getattr(loaded, "node_kind_count", None)
+    return info
+
+
+def list_grammars(languages: Optional[Sequence[str]] = None) -> List[GrammarInfo]:
+    """`grammar_info` for `languages` (default: every registered language), sorted by name."""
+    names = sorted(languages) if languages else sorted(LANGUAGE_SPECS)
+    unknown = [name for name in names if name not in LANGUAGE_SPECS]
+    if unknown:
+        raise ValueError(f"Unknown language: {', '.join(unknown)}")
+    return [grammar_info(name) for name in names]
+
+
+def pin_grammars(languages: Optional[Sequence[str]] = None) -> dict:
+    """Lock file contents pinning the grammars of `languages`; grammars that fail to load are left out."""
+    from . import __version__
+
+    return {
+        "version": LOCK_VERSION,
+        "treesitter_tools": __version__,
+        "tree_sitter": _package_version("tree-sitter"),
+        "grammars": {g.language: g.to_dict() for g in list_grammars(languages) if g.error is None},
+    }
+
+
+def read_lock(path: Path) -> dict:
+    path = Path(path)
+    try:
+        data = json.loads(path.read_text(encoding="utf-8"))
+    except FileNotFoundError:
+        raise ValueError(f"Grammar lock not found: {path} (create it with `treesitter-tools grammars pin`)") from None
+    except json.JSONDecodeError as exc:
+        raise ValueError(f"Invalid {path}: {exc}") from exc
+    if not isinstance(data, dict) or data.get("version") != LOCK_VERSION or not isinstance(data.get("grammars"), dict):
+        raise ValueError(f"{path} is not a version {LOCK_VERSION} grammar lock")
+    return data
+
+
+def _has_kind(grammar: Language, kind: str) -> bool:
+    return any(grammar.id_for_node_kind(kind, named) for named in (True, False))
+
+
+def query_node_kinds(text: str) -> List[str]:
+    """Named node kinds a query pattern matches on, ignoring strings, comments, and predicates."""
+    kinds = {m.group(1) for m in _QUERY_KIND.finditer(_QUERY_NOISE.sub(" ", text))}
+    return sorted(kinds - _NOT_KINDS)
+
+
+def node_kind_problems(language: str) -> List[GrammarProblem]:
+    """Node kinds the effective queries and the spec's node tables use that the grammar does not define."""
+    problems: List[GrammarProblem] = []
+    try:
+        grammar = load_language(language)
+    except RuntimeError:
+        return problems
+    for query in list_queries(language):
+        missing = [k for k in query_node_kinds(query.path.read_text(encoding="utf-8")) if not _has_kind(grammar, k)]
+        if missing:
+            problems.append(GrammarProblem(
+                language, "query", f"{query.name} query ({query.origin}) uses unknown node kinds: {', '.join(missing)}"
+            ))
+    spec = get_language_spec(language)
+    for table in _SPEC_TABLES if spec is not None else ():
+        missing = sorted(k for k in getattr(spec, table) if not _has_kind(grammar, k))
+        if missing:
+            problems.append(GrammarProblem(
+                language, "node_kind", f"{table} lists unknown node kinds: {', '.join(missing)}", severity="warning"
+            ))
+    return problems
+
+
+def verify_grammars(lock: Optional[dict] = None, languages: Optional[Sequence[str]] = None) -> GrammarReport:
+    """
+    Check the grammars of `languages` (default: the pinned ones, or every registered
+    language without a `lock`): each must load, match its pinned fingerprint, and
+    define every node kind the queries and node tables name.
+    """
+    pins: Dict[str, dict] = lock["grammars"] if lock is not None else {}
+    names = languages or (sorted(pins) if lock is not None else None)
+    report = GrammarReport(grammars=list_grammars(names))
+    for info in report.grammars:
+        if info.error is not None:
+            report.problems.append(GrammarProblem(info.language, "unavailable", info.error))
+            continue
+        pinned = pins.get(info.language)
+        if lock is not None and pinned is None:
+            report.problems.append(GrammarProblem(info.language, "unpinned", "not in the grammar lock"))
+        elif pinned is not None and pinned.get("fingerprint") != info.fingerprint:
+            changed = [
+                f"{key} {pinned.get(key)!r} -> {getattr(info, key)!r}"
+                for key in ("source", "abi", "version", "node_kinds")
+                if pinned.get(key) != getattr(info, key)
+            ]
+            detail = "; ".join(changed) or "grammar tables differ"
+            report.problems.append(GrammarProblem(
+                info.language, "mismatch",
+                f"fingerprint {pinned.get('fingerprint')} pinned, {info.fingerprint} installed ({detail})",
+            ))
+        report.problems.extend(node_kind_problems(info.language))
+    return report
+
+
+__all__ = [
+    "LOCK_NAME",
+    "LOCK_VERSION",
+    "MANIFEST_NAME",
+    "GrammarInfo",
+    "GrammarProblem",
+    "GrammarReport",
+    "grammar_info",
+    "grammar_loader",
+    "list_grammars",
+    "load_grammar_dir",
+    "node_kind_problems",
+    "pin_grammars",
+    "query_node_kinds",
+    "read_lock",
+    "read_manifest",
+    "spec_from_manifest_entry",
+    "verify_grammars",
+]
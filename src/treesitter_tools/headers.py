@@ -0,0 +1,334 @@
+"""
+License and copyright headers, per file, with fixes that insert a missing one.
+
+A file's header is the run of comment nodes before its first line of code (after a
+shebang, an encoding cookie, `<?php`, or an XML declaration). It counts as a license
+header when it names a license: an `SPDX-License-Identifier:` line, a copyright line,
+or the opening words of a well-known license text. A file without one is `missing`;
+with an expected license, a header naming another one is a `mismatch`. Fixes insert
+a templated header, written in the file's comment syntax, only into files that have
+none: replacing a license is left to people.
+"""
+
+from __future__ import annotations
+
+import json
+import re
+from dataclasses import dataclass, field
+from datetime import datetime, timezone
+from pathlib import Path
+from typing import List, Optional, Sequence, Tuple
+
+from tree_sitter import Node
+
+from . import failures, reproducible
+from .core import ParsedFile, _end_row, iter_source_files, normalize_comment, parse_file
+from .export.sarif import SarifLocation, SarifResult, SarifRule, sarif_to_json
+from .rewrite import Edit, FileRewrite, apply_edits
+
+SARIF_RULES = {
+    "missing": SarifRule(
+        "headers/missing", "MissingLicenseHeader", "File has no license header",
+        "The file's leading comments name no license: no SPDX-License-Identifier, copyright line, "
+        "or license text.",
+        tags=("licensing",),
+    ),
+    "mismatch": SarifRule(
+        "headers/mismatch", "LicenseMismatch", "License header names another license",
+        "The file's header names a different license than the one the project expects.",
+        tags=("licensing",),
+    ),
+}
+
+# Line-comment prefix per language (default `//`); the block-only languages use `_BLOCK_COMMENTS`.
+LINE_COMMENTS = {
+    **dict.fromkeys(("python", "ruby", "perl", "bash", "powershell", "r", "yaml", "toml", "julia", "nim"), "#"),
+    **dict.fromkeys(("lua", "sql", "haskell", "elm", "ada"), "--"),
+    **dict.fromkeys(("erlang", "latex"), "%"),
+    **dict.fromkeys(("clojure", "ini", "asm"), ";"),
+}
+# (opening line, line prefix, closing line)
+_BLOCK_COMMENTS = {
+    **dict.fromkeys(("css", "scss"), ("/*", " * ", " */")),
+    **dict.fromkeys(("html", "xml"), ("<!--", "  ", "-->")),
+    **dict.fromkeys(("ocaml", "ocaml_interface"), ("(*", "   ", "*)")),
+}
+# Languages without comments, or whose leading comments are not the file's header.
+_UNSUPPORTED = {"json", "markdown", "notebook"}
+
+TEMPLATE_FIELDS = ("year", "holder", "license", "path")
+
+_SPDX = re.compile(r"SPDX-License-Identifier:\s*(?P<id>[^\s*]+(?:\s+(?:AND|OR|WITH)\s+[^\s*]+)*)")
+_COPYRIGHT = re.compile(r"(?:\bcopyright\b|\(c\)\s*\d|©)", re.IGNORECASE)
+# Opening words of license texts, by the SPDX identifier they imply.
+_LICENSE_TEXTS: List[Tuple[re.Pattern, str]] = [
+    (re.compile(r"Apache License,?\s+Version 2\.0", re.I), "Apache-2.0"),
+    (re.compile(r"GNU Affero General Public License", re.I), "AGPL"),
+    (re.compile(r"GNU (?:Lesser|Library) General Public License", re.I), "LGPL"),
+    (re.compile(r"GNU General Public License", re.I), "GPL"),
+    (re.compile(r"Mozilla Public License,?\s+v(?:ersion|\.)?\s*2\.0", re.I), "MPL-2.0"),
+    (re.compile(r"Eclipse Public License", re.I), "EPL"),
+    (re.compile(r"Boost Software License", re.I), "BSL-1.0"),
+    (re.compile(r"\bMIT License\b|Permission is hereby granted, free of charge", re.I), "MIT"),
+    (re.compile(r"Permission to use, copy, modify, and(?:/or)? distribute", re.I), "ISC"),
+    (re.compile(r"Redistribution and use in source and binary forms", re.I), "BSD"),
+    (re.compile(r"This is free and unencumbered software", re.I), "Unlicense"),
+]
+_GPL_VERSION = re.compile(r"version\s+(\d(?:\.\d)?)", re.I)
+# Leading lines that must stay first: a shebang, and what a file's parser reads before anything else.
+_CODING = re.compile(rb"^[ \t\f]*#.*?coding[:=]")
+_PRELUDE = (b"#!", b"<?php", b"<?xml", b"<!doctype", b"<!DOCTYPE")
+# Leading comments that are tool directives rather than part of a header.
+_NOT_HEADER = re.compile(r"^(?:#!|//go:build|// ?\+build|#.*coding[:=]|/\*\s*eslint|// ?@ts-)")
+
+
+def comment_style(language: str) -> Optional[Tuple[str, str, str]]:
+    """(opening line, line prefix, closing line) of a header comment; empty opening/closing for line comments."""
+    if language in _UNSUPPORTED:
+        return None
+    if language in _BLOCK_COMMENTS:
+        return _BLOCK_COMMENTS[language]
+    return "", LINE_COMMENTS.get(language, "//") + " ", ""
+
+
+@dataclass
+class FileHeader:
+    """The license header of one file, or why it is missing."""
+
+    parsed: ParsedFile
+    label: str
+    start_line: Optional[int] = None
+    end_line: Optional[int] = None
+    license: Optional[str] = None  # the SPDX expression, else the license its text implies
+    spdx: List[str] = field(default_factory=list)
+    copyright: List[str] = field(default_factory=list)
+    problem: Optional[str] = None  # "missing" or "mismatch"
+    expected: Optional[str] = None
+
+    @property
+    def present(self) -> bool:
+        return self.start_line is not None
+
+    @property
+    def message(self) -> str:
+        if self.problem == "missing":
+            return "no license header"
+        if self.problem == "mismatch":
+            return f"license header names {self.license or 'no license'}, expected {self.expected}"
+        return f"license header: {self.license or 'copyright only'}"
+
+    def to_dict(self) -> dict:
+        return {
+            "path": self.label,
+            "language": self.parsed.language,
+            "start_line": self.start_line,
+            "end_line": self.end_line,
+            "license": self.license,
+            "spdx": self.spdx,
+            "copyright": self.copyright,
+            "problem": self.problem,
+        }
+
+    def fix(self, template: str, holder: Optional[str] = None, year: Optional[int] = None) -> Optional[FileRewrite]:
+        """The file with `template` (see `render_template`) inserted as its header, or None when it has one."""
+        if self.problem != "missing":
+            return None
+        style = comment_style(self.parsed.language)
+        if style is None:
+            return None
+        source = self.parsed.source
+        # This year, or `SOURCE_DATE_EPOCH`'s under --deterministic.
+        year = year or datetime.fromtimestamp(reproducible.now(), timezone.utc).year
+        text = render_template(template, year=year, holder=holder, license=self.expected, path=self.label)
+        offset = _insert_offset(source)
+        newline = "\r\n" if b"\r\n" in source[:4096] else "\n"
+        header = _as_comment(text, style, newline)
+        rest = source[offset:]
+        if rest.strip() and not rest.startswith((b"\n", b"\r\n")):
+            header += newline  # a blank line between the header and the code
+        line = source.count(b"\n", 0, offset) + 1
+        edits = [Edit(offset, offset, header, line, line)]
+        return FileRewrite(self.parsed.path, source, apply_edits(source, edits), edits)
+
+
+def render_template(template: str, **values) -> str:
+    """Fill `{year}`, `{holder}`, `{license}`, and `{path}`; other braces are left as written."""
+
+    def fill(match: re.Match) -> str:
+        value = values.get(match.group(1))
+        if value is None:
+            raise ValueError(f"Header template uses {{{match.group(1)}}}, which has no value (see --holder, --license)")
+        return str(value)
+
+    return re.sub(r"\{(" + "|".join(TEMPLATE_FIELDS) + r")\}", fill, template)
+
+
+def default_template(holder: Optional[str], license: Optional[str]) -> Optional[str]:
+    """`Copyright {year} {holder}` and `SPDX-License-Identifier: {license}`, for whichever is given."""
+    lines = ["Copyright {year} {holder}"] if holder else []
+    if license:
+        lines.append("SPDX-License-Identifier: {license}")
+    return "\n".join(lines) or None
+
+
+def _as_comment(text: str, style: Tuple[str, str, str], newline: str) -> str:
+    opening, prefix, closing = style
+    lines = [opening] if opening else []
+    lines += [(prefix + line).rstrip() if line.strip() else prefix.rstrip() for line in text.strip("\n").splitlines()]
+    if closing:
+        lines.append(closing)
+    return newline.join(lines) + newline
+
+
+def _insert_offset(source: bytes) -> int:
+    """Where a header goes: after a shebang, an encoding cookie, `<?php`, or an XML declaration/doctype."""
+    offset = 0
+    for index, line in enumerate(source.splitlines(keepends=True)[:3]):
+        stripped = line.lstrip()
+        if stripped.startswith(_PRELUDE) or (index < 2 and _CODING.match(line)):
+            offset += len(line)
+            continue
+        break
+    if offset and not source[:offset].endswith(b"\n"):
+        return len(source)  # the prelude is the whole file, without a final newline
+    return offset
+
+
+def _top_level(root: Node) -> List[Node]:
+    nodes = []
+    for node in root.children:
+        nodes.extend(node.children if node.type == "prolog" else [node])  # XML keeps its leading comments there
+    return nodes
+
+
+def _leading_comments(parsed: ParsedFile) -> List[Node]:
+    """The comment nodes before the file's first code, tool directives left out."""
+    found = []
+    for node in _top_level(parsed.root):
+        if "comment" not in node.type.lower():
+            if node.type in {"hash_bang_line", "php_tag", "shebang", "doctype", "XMLDecl"}:
+                continue
+            break
+        if not _NOT_HEADER.match(parsed.text(node).strip()):
+            found.append(node)
+    return found
+
+
+def detect_license(text: str) -> Tuple[Optional[str], List[str], List[str]]:
+    """(license, SPDX expressions, copyright lines) named in a header's comment text."""
+    spdx = [m.group("id").strip() for m in _SPDX.finditer(text)]
+    copyright = [line.strip() for line in text.splitlines() if _COPYRIGHT.search(line) and "SPDX" not in line]
+    if spdx:
+        return spdx[0], spdx, copyright
+    for pattern, license in _LICENSE_TEXTS:
+        if pattern.search(text):
+            version = _GPL_VERSION.search(text) if license.endswith("GPL") else None
+            if version:
+                license += "-" + (version.group(1) if "." in version.group(1) else version.group(1) + ".0")
+            return license, spdx, copyright
+    return None, spdx, copyright
+
+
+def _names_license(text: str) -> bool:
+    license, spdx, copyright = detect_license(text)
+    return bool(license or spdx or copyright)
+
+
+def file_header(parsed: ParsedFile, label: str, expected: Optional[str] = None) -> FileHeader:
+    """The license header of `parsed`; with `expected` (an SPDX identifier), a header naming another is a mismatch."""
+    header = FileHeader(parsed, label, expected=expected)
+    comments = _leading_comments(parsed)
+    texts = [normalize_comment(parsed.text(node)) for node in comments]
+    license, spdx, copyright = detect_license("\n".join(texts))
+    if license or spdx or copyright:
+        # The header runs from the first comment that names a license or copyright.
+        first = next(i for i, text in enumerate(texts) if _names_license(text))
+        header.start_line = comments[first].start_point[0] + 1
+        header.end_line = _end_row(comments[-1]) + 1
+        header.license, header.spdx, header.copyright = license, spdx, copyright
+        if expected and expected not in spdx and license != expected:
+            header.problem = "mismatch"
+    else:
+        header.problem = "missing"
+    return header
+
+
+def check_headers(
+    root: Path,
+    include: Sequence[str] | None = None,
+    exclude: Sequence[str] | None = None,
+    expected: Optional[str] = None,
+) -> List[FileHeader]:
+    """The header of every file under `root` (or of a single file) whose language has comments."""
+    root = Path(root)
+    if root.is_file():
+        parsed_files = [(parse_file(root), root.name)]
+    else:
+        base = root.resolve()
+        parsed_files = []
+        for path in iter_source_files(base, include, exclude):
+            try:
+                parsed = parse_file(path)
+            except (ValueError, RuntimeError, OSError) as exc:
+                failures.record(path, exc)
+                continue
+            parsed_files.append((parsed, path.relative_to(base).as_posix()))
+    return [
+        file_header(parsed, label, expected)
+        for parsed, label in parsed_files
+        if comment_style(parsed.language) is not None
+    ]
+
+
+def header_fixes(
+    headers: Sequence[FileHeader], template: str, holder: Optional[str] = None, year: Optional[int] = None
+) -> List[FileRewrite]:
+    """One rewrite per file without a license header, inserting `template` at its top."""
+    return [fix for fix in (h.fix(template, holder, year) for h in headers) if fix is not None and fix.changed]
+
+
+# ---------------------------------------------------------------------------
+# Output
+# ---------------------------------------------------------------------------
+
+
+def _ordered(headers: Sequence[FileHeader], everything: bool) -> List[FileHeader]:
+    return sorted((h for h in headers if everything or h.problem), key=lambda h: h.label)
+
+
+def headers_to_json(headers: Sequence[FileHeader], everything: bool = False) -> str:
+    return json.dumps([h.to_dict() for h in _ordered(headers, everything)], indent=2)
+
+
+def headers_to_text(headers: Sequence[FileHeader], everything: bool = False) -> str:
+    lines = []
+    for h in _ordered(headers, everything):
+        where = f"{h.label}:{h.start_line}" if h.present else h.label
+        lines.append(f"{where}: {h.message}")
+    return "\n".join(lines) + ("\n" if lines else "")
+
+
+def headers_to_sarif(headers: Sequence[FileHeader], root: Optional[Path] = None) -> str:
+    results = [
+        SarifResult(SARIF_RULES[h.problem], h.message, SarifLocation(h.label, h.start_line or 1), (h.problem,))
+        for h in _ordered(headers, False)
+    ]
+    return sarif_to_json(results, list(SARIF_RULES.values()), root)
+
+
+__all__ = [
+    "LINE_COMMENTS",
+    "SARIF_RULES",
+    "TEMPLATE_FIELDS",
+    "FileHeader",
+    "check_headers",
+    "comment_style",
+    "default_template",
+    "detect_license",
+    "file_header",
+    "header_fixes",
+    "headers_to_json",
+    "headers_to_sarif",
+    "headers_to_text",
+    "render_template",
+]
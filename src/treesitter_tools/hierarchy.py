@@ -0,0 +1,230 @@
+"""
+Type hierarchy: subtype -> supertype relations across a codebase.
+
+Classes contribute the bases they declare (`extends`/`implements` in TypeScript, Java,
+C#, ...; base classes in Python), Rust contributes `impl Trait for Type` blocks, and Go
+contributes struct embedding and interface embedding (`embeds`). Go's implicit interface
+satisfaction is not a declared relation; see `goimpl` for that.
+"""
+
+from __future__ import annotations
+
+import json
+from dataclasses import dataclass
+from pathlib import Path
+from typing import Dict, Iterator, List, Optional, Sequence, Set, Tuple
+
+from . import failures
+from .core import ParsedFile, class_heritage, class_kind, iter_class_nodes, iter_source_files, parse_file
+from .goimpl import GoInterface, GoType, _interface_from, _struct_embeds
+from .index import _rust_trait_impls
+
+RELATIONS = ("extends", "implements", "embeds")
+DIRECTIONS = ("supertypes", "subtypes")
+# Edge styles in DOT output, by relation.
+_DOT_STYLES = {"extends": "solid", "implements": "dashed", "embeds": "dotted"}
+_PASSIVE = {"extends": "extended by", "implements": "implemented by", "embeds": "embedded by"}
+
+
+@dataclass
+class TypeDecl:
+    name: str
+    kind: str  # class, struct, interface, or trait
+    path: str
+    line: int
+    language: str
+
+    def to_dict(self) -> dict:
+        return {"name": self.name, "kind": self.kind, "path": self.path, "line": self.line, "language": self.language}
+
+
+@dataclass
+class Relation:
+    subtype: str
+    supertype: str
+    relation: str  # one of RELATIONS
+    path: str
+    line: int
+    supertype_path: Optional[str] = None  # set when the supertype is declared exactly once
+    supertype_line: Optional[int] = None
+
+    def to_dict(self) -> dict:
+        return {
+            "subtype": self.subtype,
+            "supertype": self.supertype,
+            "relation": self.relation,
+            "path": self.path,
+            "line": self.line,
+            "supertype_path": self.supertype_path,
+            "supertype_line": self.supertype_line,
+        }
+
+
+def _go_embeds(cls_node, parsed: ParsedFile, label: str) -> List[str]:
+    type_node = cls_node.child_by_field_name("type")
+    if type_node is None:
+        return []
+    if type_node.type == "interface_type":
+        iface = GoInterface("", "", "", label, 0)
+        _interface_from(type_node, iface, parsed.source)
+        return [ref.rpartition(".")[2] for ref in iface.embeds]
+    go_type = GoType("", "", "", label, 0)
+    _struct_embeds(type_node, go_type, parsed.source)
+    return [name for _, name, _ in go_type.embedded]
+
+
+class TypeHierarchy:
+    """Type declarations and the relations between them, matched by unqualified type name."""
+
+    def __init__(self) -> None:
+        self.types: List[TypeDecl] = []
+        self.relations: List[Relation] = []
+        self._by_name: Dict[str, List[TypeDecl]] = {}
+
+    def add_file(self, parsed: ParsedFile, label: Optional[str] = None) -> None:
+        label = label or parsed.path.as_posix()
+        for cls in iter_class_nodes(parsed):
+            decl = TypeDecl(cls.name, class_kind(cls.node), label, cls.node.start_point[0] + 1, parsed.language)
+            self.types.append(decl)
+            self._by_name.setdefault(decl.name, []).append(decl)
+            if parsed.language == "go":
+                bases = [(name, "embeds") for name in _go_embeds(cls.node, parsed, label)]
+            else:
+                bases = class_heritage(cls, parsed)
+            for name, relation in bases:
+                self.relations.append(Relation(cls.name, name, relation, label, decl.line))
+        if parsed.language == "rust":
+            for type_name, trait, line in _rust_trait_impls(parsed):
+                self.relations.append(Relation(type_name, trait, "implements", label, line))
+
+    def resolve(self) -> None:
+        """Point relations at their supertype's declaration when the name is unambiguous."""
+        for relation in self.relations:
+            candidates = self._by_name.get(relation.supertype, [])
+            if len(candidates) == 1:
+                relation.supertype_path = candidates[0].path
+                relation.supertype_line = candidates[0].line
+
+    def _known(self, name: str) -> bool:
+        return name in self._by_name or any(name in (r.subtype, r.supertype) for r in self.relations)
+
+    def walk(self, name: str, direction: str, transitive: bool = False) -> Iterator[Tuple[int, Relation]]:
+        """(depth, relation) pairs depth-first from `name`, each type visited once; depth starts at 0."""
+        if direction not in DIRECTIONS:
+            raise ValueError(f"Unsupported direction '{direction}' (expected {' or '.join(DIRECTIONS)})")
+        name = name.rpartition(".")[2].rpartition("::")[2]
+        if not self._known(name):
+            raise ValueError(f"No type named '{name}'")
+        up = direction == "supertypes"
+        seen: Set[str] = {name}
+
+        def visit(current: str, depth: int) -> Iterator[Tuple[int, Relation]]:
+            for relation in self.relations:
+                if (relation.subtype if up else relation.supertype) != current:
+                    continue
+                yield depth, relation
+                other = relation.supertype if up else relation.subtype
+                if transitive and other not in seen:
+                    seen.add(other)
+                    yield from visit(other, depth + 1)
+
+        return visit(name, 0)
+
+    def supertypes(self, name: str, transitive: bool = False) -> List[Relation]:
+        """Relations from `name` to what it extends, implements, or embeds (and theirs, if `transitive`)."""
+        return [relation for _, relation in self.walk(name, "supertypes", transitive)]
+
+    def subtypes(self, name: str, transitive: bool = False) -> List[Relation]:
+        """Relations from the types that extend, implement, or embed `name` (and theirs, if `transitive`)."""
+        return [relation for _, relation in self.walk(name, "subtypes", transitive)]
+
+    def to_dict(self) -> dict:
+        return {"types": [t.to_dict() for t in self.types], "relations": [r.to_dict() for r in self.relations]}
+
+    def to_json(self) -> str:
+        return json.dumps(self.to_dict(), indent=2)
+
+    def to_text(self) -> str:
+        return "".join(
+            f"{r.subtype} {r.relation} {r.supertype}  ({r.path}:{r.line})\n" for r in self.relations
+        )
+
+    def to_dot(self, relations: Optional[Sequence[Relation]] = None) -> str:
+        """Graphviz digraph with arrows pointing at supertypes; types not declared here are dashed."""
+        relations = self.relations if relations is None else relations
+        lines = ["digraph hierarchy {", "  rankdir=BT;", "  node [shape=box];"]
+        names: List[str] = []
+        for r in relations:
+            for name in (r.subtype, r.supertype):
+                if name not in names:
+                    names.append(name)
+        for name in names:
+            style = "" if name in self._by_name else " [style=dashed]"
+            lines.append(f"  {json.dumps(name)}{style};")
+        seen = set()
+        for r in relations:
+            key = (r.subtype, r.supertype, r.relation)
+            if key in seen:
+                continue
+            seen.add(key)
+            lines.append(
+                f"  {json.dumps(r.subtype)} -> {json.dumps(r.supertype)} [label={r.relation}, style={_DOT_STYLES[r.relation]}];"
+            )
+        lines.append("}")
+        return "\n".join(lines) + "\n"
+
+
+def walk_to_json(hierarchy: TypeHierarchy, name: str, direction: str, transitive: bool = False) -> str:
+    return json.dumps(
+        [{**r.to_dict(), "depth": depth} for depth, r in hierarchy.walk(name, direction, transitive)], indent=2
+    )
+
+
+def walk_to_text(hierarchy: TypeHierarchy, name: str, direction: str, transitive: bool = False) -> str:
+    """An indented tree rooted at `name`."""
+    up = direction == "supertypes"
+    lines = [name]
+    for depth, r in hierarchy.walk(name, direction, transitive):
+        other = r.supertype if up else r.subtype
+        verb = r.relation if up else _PASSIVE[r.relation]
+        if up:
+            where = f"{r.supertype_path}:{r.supertype_line}" if r.supertype_path else None
+        else:
+            where = f"{r.path}:{r.line}"
+        lines.append("  " * (depth + 1) + f"{verb} {other}" + (f"  ({where})" if where else ""))
+    return "\n".join(lines) + "\n"
+
+
+def build_hierarchy(
+    root: Path,
+    include: Sequence[str] | None = None,
+    exclude: Sequence[str] | None = None,
+) -> TypeHierarchy:
+    """Parse every recognised file under `root` (or a single file) and collect type relations."""
+    root = Path(root)
+    hierarchy = TypeHierarchy()
+    if root.is_file():
+        hierarchy.add_file(parse_file(root))
+    else:
+        base = root.resolve()
+        for path in iter_source_files(base, include, exclude):
+            try:
+                parsed = parse_file(path)
+            except (ValueError, RuntimeError, OSError) as exc:
+                failures.record(path, exc)
+                continue
+            hierarchy.add_file(parsed, path.relative_to(base).as_posix())
+    hierarchy.resolve()
+    return hierarchy
+
+
+__all__ = [
+    "DIRECTIONS",
+    "RELATIONS",
+    "Relation",
+    "TypeDecl",
+    "TypeHierarchy",
+    "build_hierarchy",
+    "walk_to_json",
+    "walk_to_text",
+]
@@ -0,0 +1,218 @@
+"""Per-function metrics at every commit of a git range, as a time series."""
+
+from __future__ import annotations
+
+import csv
+import fnmatch
+import io
+import json
+from dataclasses import dataclass, field
+from datetime import datetime, timezone
+from pathlib import Path
+from typing import Dict, List, Optional, Sequence, Tuple
+
+from .apidiff import _read_blobs
+from .core import ParsedFile, _match_any, detect_language, parse_source
+from .gitdiff import _git, repo_root
+from .metrics import FunctionMetrics, function_metrics
+
+# The metrics each point of a series carries, in column order.
+HISTORY_METRICS = ("loc", "sloc", "cyclomatic", "cognitive", "max_nesting")
+
+
+@dataclass
+class Revision:
+    commit: str
+    time: str  # committer date, UTC
+    author: str
+    subject: str
+
+    def to_dict(self) -> dict:
+        return {"commit": self.commit[:12], "time": self.time, "author": self.author, "subject": self.subject}
+
+
+@dataclass
+class SymbolHistory:
+    path: str
+    name: str
+    points: List[Optional[FunctionMetrics]]  # one per revision; None where the function did not exist
+
+    @property
+    def first_seen(self) -> int:
+        return next(i for i, p in enumerate(self.points) if p is not None)
+
+    @property
+    def last_seen(self) -> int:
+        return max(i for i, p in enumerate(self.points) if p is not None)
+
+    def to_dict(self, revisions: Sequence[Revision]) -> dict:
+        series = []
+        for revision, point in zip(revisions, self.points):
+            entry: dict = {"commit": revision.commit[:12], "exists": point is not None}
+            if point is not None:
+                entry.update({m: getattr(point, m) for m in HISTORY_METRICS})
+            series.append(entry)
+        return {
+            "path": self.path,
+            "name": self.name,
+            "first_seen": revisions[self.first_seen].commit[:12],
+            "last_seen": revisions[self.last_seen].commit[:12],
+            "series": series,
+        }
+
+
+@dataclass
+class History:
+    revisions: List[Revision] = field(default_factory=list)
+    symbols: List[SymbolHistory] = field(default_factory=list)
+
+    def to_json(self) -> str:
+        return json.dumps(
+            {
+                "revisions": [r.to_dict() for r in self.revisions],
+                "symbols": [s.to_dict(self.revisions) for s in self.symbols],
+            },
+            indent=2,
+        )
+
+    def to_csv(self) -> str:
+        """One row per (revision, function): the long format charting tools ingest directly."""
+        buffer = io.StringIO()
+        writer = csv.writer(buffer, lineterminator="\n")
+        writer.writerow(["commit", "time", "path", "name", "exists", *HISTORY_METRICS])
+        for symbol in self.symbols:
+            for revision, point in zip(self.revisions, symbol.points):
+                values = [getattr(point, m) for m in HISTORY_METRICS] if point else [""] * len(HISTORY_METRICS)
+                exists = int(point is not None)
+                writer.writerow([revision.commit[:12], revision.time, symbol.path, symbol.name, exists, *values])
+        return buffer.getvalue()
+
+    def to_text(self) -> str:
+        """Each function's metrics at the revisions where they changed."""
+        header = ("commit", "date", "loc", "sloc", "cyclomatic", "cognitive", "nesting")
+        lines = []
+        for symbol in self.symbols:
+            present = sum(1 for p in symbol.points if p is not None)
+            lines.append(f"{symbol.path} {symbol.name} ({present} of {len(self.revisions)} revisions)")
+            rows = [header]
+            previous: Optional[tuple] = None
+            for revision, point in zip(self.revisions, symbol.points):
+                values = tuple(getattr(point, m) for m in HISTORY_METRICS) if point is not None else None
+                if values == previous:
+                    continue
+                cells = [str(v) for v in values] if values is not None else ["removed"]
+                rows.append((revision.commit[:12], revision.time[:10], *cells))
+                previous = values
+            widths = [max(len(row[i]) for row in rows if len(row) == len(header)) for i in range(len(header))]
+            for row in rows:
+                cells = [c.ljust(widths[i]) if i < 2 else c.rjust(widths[i]) for i, c in enumerate(row)]
+                lines.append("  " + "  ".join(cells).rstrip())
+        lines.append(f"{len(self.symbols)} functions over {len(self.revisions)} revisions")
+        return "\n".join(lines) + "\n"
+
+
+def list_revisions(
+    repo: Path, revisions: str = "HEAD", path: str = "", max_count: Optional[int] = None
+) -> List[Revision]:
+    """First-parent commits of `revisions` (a `git log` range) that touched `path`, oldest first."""
+    args = ["log", "--first-parent", "--reverse", "--format=%H%x00%ct%x00%an%x00%s"]
+    if max_count is not None:
+        args.append(f"--max-count={max_count}")  # applied before --reverse: the newest N
+    args += [revisions, "--", path or "."]
+    try:
+        output = _git(repo, *args).decode("utf-8", "replace")
+    except ValueError:
+        raise ValueError(f"Unknown git revision range: {revisions}") from None
+    result = []
+    for row in output.splitlines():
+        commit, stamp, author, subject = row.split("\0", 3)
+        time = datetime.fromtimestamp(int(stamp), timezone.utc).strftime("%Y-%m-%dT%H:%M:%SZ")
+        result.append(Revision(commit, time, author, subject))
+    return result
+
+
+def _tree(repo: Path, commit: str, prefix: str) -> Dict[str, str]:
+    """Repo-relative path -> blob id for every file under `prefix` at `commit`."""
+    listing = _git(repo, "ls-tree", "-r", "-z", commit, "--", prefix or ".")
+    tree = {}
+    for entry in listing.decode("utf-8", "surrogateescape").split("\0"):
+        meta, _, rel = entry.partition("\t")
+        parts = meta.split()
+        if len(parts) == 3 and parts[1] == "blob":
+            tree[rel] = parts[2]
+    return tree
+
+
+def _selected(metrics: FunctionMetrics, patterns: Sequence[str]) -> bool:
+    short = metrics.name.rsplit(".", 1)[-1]
+    candidates = (short, metrics.name, f"{metrics.path}:{metrics.name}")
+    return any(fnmatch.fnmatchcase(c, p) for p in patterns for c in candidates)
+
+
+def symbol_history(
+    path: Path,
+    revisions: str = "HEAD",
+    include: Sequence[str] | None = None,
+    exclude: Sequence[str] | None = None,
+    symbols: Sequence[str] | None = None,
+    max_count: Optional[int] = None,
+) -> History:
+    """
+    Metrics of every function under `path` at each commit of `revisions`, read
+    straight from git. A function is identified by its file and qualified name,
+    so a renamed function or file starts a new series. `symbols` keeps only
+    functions whose name, qualified name, or `path:qualified name` matches one of
+    the glob patterns.
+    """
+    path = Path(path).resolve()
+    repo = repo_root(path)
+    prefix = path.relative_to(repo).as_posix()
+    prefix = "" if prefix == "." else prefix
+    history = History(list_revisions(repo, revisions, prefix, max_count))
+    include = include or ["**/*"]
+    strip = len(prefix) + 1 if prefix and path.is_dir() else 0
+    # Unchanged files keep their blob id, so each version is parsed once.
+    measured: Dict[Tuple[str, str], List[FunctionMetrics]] = {}
+    series: Dict[Tuple[str, str], SymbolHistory] = {}
+    for index, revision in enumerate(history.revisions):
+        wanted: Dict[str, Tuple[str, str]] = {}
+        for rel, blob in _tree(repo, revision.commit, prefix).items():
+            label = rel[strip:] if strip else rel
+            if not _match_any(include, label) or (exclude and _match_any(exclude, label)):
+                continue
+            if detect_language(Path(label)):
+                wanted[rel] = (label, blob)
+        missing = [rel for rel, (label, blob) in wanted.items() if (blob, label) not in measured]
+        for rel, source in _read_blobs(repo, revision.commit, missing):
+            label, blob = wanted[rel]
+            measured[(blob, label)] = []
+            if b"\x00" in source[:8192]:
+                continue
+            language = detect_language(Path(label))
+            try:
+                parsed = ParsedFile(repo / rel, language, source, parse_source(source, language))
+            except (ValueError, RuntimeError):
+                continue
+            measured[(blob, label)] = function_metrics(parsed, label)
+        for label, blob in wanted.values():
+            for metrics in measured.get((blob, label), []):
+                if symbols and not _selected(metrics, symbols):
+                    continue
+                key = (label, metrics.name)
+                entry = series.get(key)
+                if entry is None:
+                    entry = series[key] = SymbolHistory(label, metrics.name, [None] * len(history.revisions))
+                if entry.points[index] is None:  # overloads: the first declaration wins
+                    entry.points[index] = metrics
+    history.symbols = sorted(series.values(), key=lambda s: (s.path, s.points[s.last_seen].start_line, s.name))
+    return history
+
+
+__all__ = [
+    "HISTORY_METRICS",
+    "History",
+    "Revision",
+    "SymbolHistory",
+    "list_revisions",
+    "symbol_history",
+]
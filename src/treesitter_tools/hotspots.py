@@ -0,0 +1,184 @@
+"""Function-level ownership (git blame) and churn, ranked against complexity."""
+
+from __future__ import annotations
+
+import json
+from collections import Counter
+from dataclasses import dataclass
+from datetime import datetime, timezone
+from pathlib import Path
+from typing import List, Optional, Sequence, Tuple
+
+from . import failures
+from .core import iter_source_files, parse_file
+from .gitdiff import _git, repo_root
+from .metrics import FunctionMetrics, function_metrics
+
+METRICS = ("cyclomatic", "cognitive")
+
+_UNCOMMITTED = "0" * 40
+
+
+@dataclass
+class BlameLine:
+    commit: str
+    author: str
+    time: int
+
+
+@dataclass
+class Ownership:
+    last_modified: Optional[str] = None  # UTC timestamp of the newest committed line
+    last_commit: Optional[str] = None
+    primary_author: Optional[str] = None
+    author_share: float = 0.0  # fraction of committed lines written by primary_author
+    authors: int = 0
+    churn: int = 0  # commits that touched the function's lines
+
+    def to_dict(self) -> dict:
+        return {
+            "last_modified": self.last_modified,
+            "last_commit": self.last_commit,
+            "primary_author": self.primary_author,
+            "author_share": self.author_share,
+            "authors": self.authors,
+            "churn": self.churn,
+        }
+
+
+@dataclass
+class Hotspot:
+    metrics: FunctionMetrics
+    ownership: Ownership
+    score: int
+
+    def to_dict(self) -> dict:
+        return {**self.metrics.to_dict(), **self.ownership.to_dict(), "score": self.score}
+
+
+def blame_file(repo: Path, rel_path: str) -> List[BlameLine]:
+    """Per-line blame of the working-tree file (index 0 is line 1)."""
+    output = _git(repo, "blame", "--line-porcelain", "--", rel_path).decode("utf-8", "replace")
+    lines: List[BlameLine] = []
+    commit, author, time = "", "", 0
+    for row in output.splitlines():
+        if row.startswith("\t"):
+            lines.append(BlameLine(commit, author, time))
+        elif row.startswith("author "):
+            author = row[len("author "):]
+        elif row.startswith("author-time "):
+            time = int(row.split()[1])
+        else:
+            # Each line's group starts with "<sha> <orig line> <final line> [<count>]".
+            head = row.split(" ", 1)[0]
+            if len(head) == 40 and all(c in "0123456789abcdef" for c in head):
+                commit = head
+    return lines
+
+
+def ownership(blame: Sequence[BlameLine], start_line: int, end_line: int) -> Ownership:
+    """Last change, primary author, and author count for lines `start_line`..`end_line`."""
+    committed = [b for b in blame[start_line - 1 : end_line] if b.commit != _UNCOMMITTED]
+    if not committed:
+        return Ownership()
+    newest = max(committed, key=lambda b: (b.time, b.commit))
+    counts = Counter(b.author for b in committed)
+    author, lines = sorted(counts.items(), key=lambda item: (-item[1], item[0]))[0]
+    return Ownership(
+        last_modified=datetime.fromtimestamp(newest.time, timezone.utc).strftime("%Y-%m-%dT%H:%M:%SZ"),
+        last_commit=newest.commit[:12],
+        primary_author=author,
+        author_share=round(lines / len(committed), 3),
+        authors=len(counts),
+    )
+
+
+def churn(repo: Path, rel_path: str, start_line: int, end_line: int, days: Optional[int] = None) -> int:
+    """Number of commits (reachable from HEAD) whose changes touched the line range."""
+    args = ["log", f"-L{start_line},{end_line}:{rel_path}", "--format=%x00%H", "--no-color", "--no-ext-diff"]
+    if days is not None:
+        args.append(f"--since={days}.days.ago")
+    try:
+        output = _git(repo, *args).decode("utf-8", "replace")
+    except ValueError:
+        return 0  # lines not present at HEAD (e.g. uncommitted code)
+    return sum(1 for row in output.splitlines() if row.startswith("\0"))
+
+
+def collect_hotspots(
+    root: Path,
+    include: Sequence[str] | None = None,
+    exclude: Sequence[str] | None = None,
+    metric: str = "cyclomatic",
+    days: Optional[int] = None,
+) -> List[Hotspot]:
+    """Every function under `root` with ownership, churn, and `score = metric x churn`, highest first."""
+    if metric not in METRICS:
+        raise ValueError(f"Unknown metric '{metric}' (expected one of {', '.join(METRICS)})")
+    root = Path(root)
+    repo = repo_root(root)
+    if root.is_file():
+        targets: List[Tuple[Path, str]] = [(root.resolve(), root.as_posix())]
+    else:
+        base = root.resolve()
+        targets = [(p, p.relative_to(base).as_posix()) for p in iter_source_files(base, include, exclude)]
+    results: List[Hotspot] = []
+    for path, label in targets:
+        try:
+            parsed = parse_file(path)
+        except (ValueError, RuntimeError, OSError) as exc:
+            failures.record(path, exc)
+            continue
+        functions = function_metrics(parsed, label)
+        if not functions:
+            continue
+        rel = path.relative_to(repo).as_posix()
+        try:
+            blame = blame_file(repo, rel)
+        except ValueError:
+            blame = []  # untracked file: no history yet
+        for fn in functions:
+            owner = ownership(blame, fn.start_line, fn.end_line)
+            if blame:
+                owner.churn = churn(repo, rel, fn.start_line, fn.end_line, days)
+            results.append(Hotspot(fn, owner, getattr(fn, metric) * owner.churn))
+    results.sort(key=lambda h: (-h.score, h.metrics.path, h.metrics.start_line))
+    return results
+
+
+def hotspots_to_json(hotspots: Sequence[Hotspot]) -> str:
+    return json.dumps([h.to_dict() for h in hotspots], indent=2)
+
+
+def hotspots_to_text(hotspots: Sequence[Hotspot], metric: str = "cyclomatic") -> str:
+    rows = [("score", "churn", metric, "author", "last modified", "function")]
+    for h in hotspots:
+        m, o = h.metrics, h.ownership
+        rows.append((
+            str(h.score),
+            str(o.churn),
+            str(getattr(m, metric)),
+            o.primary_author or "-",
+            (o.last_modified or "-")[:10],
+            f"{m.path}:{m.start_line} {m.name}",
+        ))
+    widths = [max(len(row[i]) for row in rows) for i in range(5)]
+    lines = []
+    for row in rows:
+        cells = [row[i].rjust(widths[i]) if i < 3 else row[i].ljust(widths[i]) for i in range(5)]
+        lines.append("  ".join(cells + [row[5]]))
+    return "\n".join(lines) + "\n"
+
+
+__all__ = [
+    "BlameLine",
+    "Hotspot",
+    "METRICS",
+    "Ownership",
+    "blame_file",
+    "churn",
+    "collect_hotspots",
+    "hotspots_to_json",
+    "hotspots_to_text",
+    "ownership",
+]
@@ -0,0 +1,320 @@
+"""
+Ignore files for directory walks.
+
+Directory walks skip the paths git would ignore, using the rules from:
+
+- the global excludes file (`core.excludesFile` in the global git config, else
+  `$XDG_CONFIG_HOME/git/ignore`),
+- the repository's `.git/info/exclude`,
+- every `.gitignore` from the repository top down to the file's directory, and
+- `.tstoolsignore` files (same syntax), which apply inside or outside a git
+  repository and override a `.gitignore` in the same directory.
+
+Later sources win over earlier ones, and deeper files over shallower ones, so a
+nested `!pattern` can re-include what a parent file ignored. As in git, nothing
+inside an ignored directory can be re-included, so walks prune those directories
+without listing them. `.git` directories are always skipped.
+
+Walks respect ignore files while `RESPECT_IGNORES` is set (the global
+`--no-ignore` flag clears it).
+"""
+
+from __future__ import annotations
+
+import os
+import re
+from dataclasses import dataclass
+from pathlib import Path
+from typing import Dict, Iterator, List, Optional, Sequence, Tuple
+
+# Cleared by the CLI's global `--no-ignore` flag; consulted by `core.iter_source_files`.
+RESPECT_IGNORES = True
+
+IGNORE_FILE = ".tstoolsignore"
+# Per-directory ignore files, lowest precedence first.
+PER_DIRECTORY_FILES = (".gitignore", IGNORE_FILE)
+
+
+@dataclass
+class IgnorePattern:
+    """One line of an ignore file, relative to the directory holding that file."""
+
+    pattern: str  # as written, for diagnostics
+    regex: "re.Pattern[str]"
+    negated: bool = False
+    directory_only: bool = False
+
+    def matches(self, rel_path: str, is_dir: bool) -> bool:
+        if self.directory_only and not is_dir:
+            return False
+        return self.regex.match(rel_path) is not None
+
+
+def _class_end(pattern: str, start: int) -> int:
+    """Index of the `]` closing the bracket expression opened at `start`, or -1."""
+    i = start + 1
+    if i < len(pattern) and pattern[i] in "!^":
+        i += 1
+    if i < len(pattern) and pattern[i] == "]":
+        i += 1
+    while i < len(pattern) and pattern[i] != "]":
+        i += 1
+    return i if i < len(pattern) else -1
+
+
+def _translate(pattern: str) -> str:
+    out = []
+    i, n = 0, len(pattern)
+    while i < n:
+        c = pattern[i]
+        if c == "*":
+            if pattern.startswith("**", i) and (i == 0 or pattern[i - 1] == "/"):
+                if i + 2 == n:  # trailing "/**" (or a bare "**"): everything inside
+                    out.append(".*")
+                    i += 2
+                    continue
+                if pattern[i + 2] == "/":  # "**/": zero or more directories
+                    out.append("(?:.*/)?")
+                    i += 3
+                    continue
+            while i < n and pattern[i] == "*":  # any other run of stars is one "*"
+                i += 1
+            out.append("[^/]*")
+            continue
+        if c == "?":
+            out.append("[^/]")
+        elif c == "[":
+            end = _class_end(pattern, i)
+            if end < 0:
+                out.append(re.escape(c))
+            else:
+                body = pattern[i + 1 : end]
+                negate = body[:1] in ("!", "^")
+                body = body[1:] if negate else body
+                body = body.replace("\\", "\\\\").replace("[", "\\[")
+                out.append(f"[{'^/' if negate else ''}{body}]")
+                i = end
+        elif c == "\\" and i + 1 < n:
+            i += 1
+            out.append(re.escape(pattern[i]))
+        else:
+            out.append(re.escape(c))
+        i += 1
+    return "".join(out)
+
+
+def compile_pattern(line: str) -> Optional[IgnorePattern]:
+    """The pattern on one ignore-file line, or None for a blank line or comment."""
+    line = line.rstrip("\r\n")
+    if not line or line.startswith("#"):
+        return None
+    # Trailing spaces are dropped unless escaped with a backslash.
+    stripped = line.rstrip(" ")
+    if stripped.endswith("\\") and len(stripped) < len(line):
+        stripped += " "
+    line = stripped
+    negated = line.startswith("!")
+    if negated:
+        line = line[1:]
+    elif line.startswith("\\!") or line.startswith("\\#"):
+        line = line[1:]
+    directory_only = line.endswith("/") and not line.endswith("\\/")
+    line = line.rstrip("/") if directory_only else line
+    if not line:
+        return None
+    # A slash anywhere but the end anchors the pattern to the ignore file's directory;
+    # otherwise it matches a name at any depth.
+    anchored = "/" in line
+    body = _translate(line.lstrip("/") if anchored else line)
+    prefix = "" if anchored else "(?:.*/)?"
+    return IgnorePattern(line, re.compile(f"^{prefix}{body}$", re.DOTALL), negated, directory_only)
+
+
+def parse_ignore(text: str) -> List[IgnorePattern]:
+    return [p for p in (compile_pattern(line) for line in text.splitlines()) if p is not None]
+
+
+def _read_patterns(path: Path) -> List[IgnorePattern]:
+    try:
+        text = path.read_text(encoding="utf-8", errors="replace")
+    except OSError:
+        return []
+    return parse_ignore(text)
+
+
+def _config_excludes_file(path: Path) -> Optional[str]:
+    """`core.excludesFile` from one git config file, if set there."""
+    try:
+        lines = path.read_text(encoding="utf-8", errors="replace").splitlines()
+    except OSError:
+        return None
+    section, value = "", None
+    for raw in lines:
+        line = raw.strip()
+        if not line or line[0] in "#;":
+            continue
+        if line.startswith("["):
+            section = line[1:].split("]", 1)[0].strip().lower()
+            continue
+        name, sep, rest = line.partition("=")
+        if section == "core" and sep and name.strip().lower() == "excludesfile":
+            value = rest.strip().strip('"')
+    return value
+
+
+def global_excludes_file() -> Optional[Path]:
+    """The global excludes file git would read, if one is configured or exists at its default path."""
+    xdg = Path(os.environ.get("XDG_CONFIG_HOME") or Path.home() / ".config") / "git"
+    override = os.environ.get("GIT_CONFIG_GLOBAL")
+    configs = [Path(override)] if override else [xdg / "config", Path.home() / ".gitconfig"]
+    configured = None
+    for config in configs:  # later files win, as in git
+        configured = _config_excludes_file(config) or configured
+    path = Path(os.path.expanduser(configured)) if configured else xdg / "ignore"
+    return path if path.is_file() else None
+
+
+def repository_root(path: Path) -> Optional[Path]:
+    """The nearest directory at or above `path` holding a `.git` entry."""
+    for candidate in (path, *path.parents):
+        if (candidate / ".git").exists():
+            return candidate
+    return None
+
+
+_Rules = List[Tuple[Path, List[IgnorePattern]]]
+
+
+class IgnoreRules:
+    """
+    The ignore rules that apply under `root`: global and repository excludes, plus
+    every per-directory ignore file, read lazily as the walk reaches its directory.
+    """
+
+    def __init__(self, root: Path):
+        self.root = Path(root).resolve()
+        self.repository = repository_root(self.root)
+        self._base: _Rules = []
+        top = self.repository or self.root
+        excludes = global_excludes_file()
+        if excludes is not None:
+            self._base.append((top, _read_patterns(excludes)))
+        if self.repository is not None:
+            self._base.append((top, _read_patterns(self.repository / ".git" / "info" / "exclude")))
+        self._directories: Dict[Path, _Rules] = {}
+        # Ignore files between the repository top and the walk root still apply.
+        self._ancestors: _Rules = []
+        if self.repository is not None and self.repository != self.root:
+            chain = [p for p in self.root.parents if self.repository in p.parents or p == self.repository]
+            for directory in reversed(chain):
+                self._ancestors.extend(self._own(directory))
+
+    def _own(self, directory: Path) -> _Rules:
+        """The rules of the ignore files in `directory` itself."""
+        cached = self._directories.get(directory)
+        if cached is None:
+            cached = []
+            for name in PER_DIRECTORY_FILES:
+                path = directory / name
+                if path.is_file():
+                    cached.append((directory, _read_patterns(path)))
+            self._directories[directory] = cached
+        return cached
+
+    def _applicable(self, directory: Path) -> _Rules:
+        rules = self._base + self._ancestors
+        try:
+            parts = directory.relative_to(self.root).parts
+        except ValueError:
+            return rules
+        current = self.root
+        rules = rules + self._own(current)
+        for part in parts:
+            current = current / part
+            rules = rules + self._own(current)
+        return rules
+
+    def _decide(self, path: Path, is_dir: bool, rules: _Rules) -> bool:
+        ignored = False
+        for base, patterns in rules:
+            try:
+                rel = path.relative_to(base).as_posix()
+            except ValueError:
+                continue
+            for pattern in patterns:
+                if pattern.matches(rel, is_dir):
+                    ignored = not pattern.negated
+        return ignored
+
+    def is_ignored(self, path: Path, is_dir: Optional[bool] = None) -> bool:
+        """Whether `path` (absolute, under `root`) is ignored itself or through an ignored directory."""
+        path = Path(path)
+        try:
+            parts = path.relative_to(self.root).parts
+        except ValueError:
+            return False
+        current = self.root
+        for index, part in enumerate(parts):
+            last = index == len(parts) - 1
+            if not last and part == ".git":
+                return True
+            candidate = current / part
+            if last:
+                return part == ".git" or self._decide(
+                    candidate, candidate.is_dir() if is_dir is None else is_dir, self._applicable(current)
+                )
+            if self._decide(candidate, True, self._applicable(current)):
+                return True
+            current = candidate
+        return False
+
+    def walk(self) -> Iterator[Path]:
+        """Every file under `root` that is not ignored, in sorted order, skipping ignored directories."""
+        yield from sorted(self._walk(self.root, self._applicable(self.root)))
+
+    def _walk(self, directory: Path, rules: _Rules) -> Iterator[Path]:
+        try:
+            entries = list(os.scandir(directory))
+        except OSError:
+            return
+        for entry in entries:
+            path = directory / entry.name
+            is_dir = entry.is_dir(follow_symlinks=False)
+            if entry.name == ".git" or self._decide(path, is_dir, rules):
+                continue
+            if is_dir:
+                yield from self._walk(path, rules + self._own(path))
+            else:
+                yield path
+
+
+def walk_files(root: Path) -> Iterator[Path]:
+    """Files under `root` in sorted order: all of them, or those not ignored while `RESPECT_IGNORES` is set."""
+    root = Path(root).resolve()
+    if not RESPECT_IGNORES:
+        yield from (p for p in sorted(root.rglob("*")) if p.is_file())
+        return
+    yield from IgnoreRules(root).walk()
+
+
+def filter_ignored(root: Path, paths: Sequence[Path]) -> List[Path]:
+    """`paths` (absolute, under `root`) without the ignored ones while `RESPECT_IGNORES` is set."""
+    if not RESPECT_IGNORES:
+        return list(paths)
+    rules = IgnoreRules(root)
+    return [p for p in paths if not rules.is_ignored(p)]
+
+
+__all__ = [
+    "IGNORE_FILE",
+    "PER_DIRECTORY_FILES",
+    "RESPECT_IGNORES",
+    "IgnorePattern",
+    "IgnoreRules",
+    "compile_pattern",
+    "filter_ignored",
+    "global_excludes_file",
+    "parse_ignore",
+    "repository_root",
+    "walk_files",
+]
@@ -0,0 +1,522 @@
+"""
+Unused and duplicate imports, per file, with fixes that remove them.
+
+An import is unused when nothing in its file refers to the name it binds. Python and
+JavaScript/TypeScript go through `resolver.scopes`, so a parameter or local that
+shadows the import doesn't count as a use. Go and Java match the name against the
+file's identifiers. An import repeating an earlier one in the same file (same module,
+same name, same alias) is a duplicate. Wildcard, blank (`_`), dot, and side-effect
+imports bind nothing checkable and are never reported.
+"""
+
+from __future__ import annotations
+
+import json
+import re
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Callable, Dict, Iterator, List, Optional, Sequence, Set, Tuple
+
+from tree_sitter import Node
+
+from . import failures
+from .core import ParsedFile, iter_source_files, parse_file
+from .export.sarif import SarifLocation, SarifResult, SarifRule, sarif_to_json
+from .rewrite import Edit, FileRewrite, apply_edits
+
+SUPPORTED_LANGUAGES = ("python", "javascript", "typescript", "tsx", "go", "java")
+
+SARIF_RULES = {
+    "unused": SarifRule(
+        "imports/unused", "UnusedImport", "Imported name is never used",
+        "Nothing in the file refers to the name this import binds, so the import can be removed. "
+        "A module imported only for its side effects should be imported without binding a name.",
+        tags=("maintainability", "imports"),
+    ),
+    "duplicate": SarifRule(
+        "imports/duplicate", "DuplicateImport", "Import repeats an earlier one",
+        "The same name is already imported from the same module earlier in the file.",
+        tags=("maintainability", "imports"),
+    ),
+}
+
+_JS_LANGUAGES = {"javascript", "typescript", "tsx"}
+_GO_VERSION = re.compile(r"^v\d+$")
+_IDENTIFIER = re.compile(r"[A-Za-z_$][\w$]*")
+# Javadoc names types in `{@link Type}`, `@see Type`, and `@throws Type`.
+_JAVADOC_REFERENCE = re.compile(r"(?:@link|@linkplain|@see|@throws|@exception)\s+([A-Za-z_$][\w$]*)")
+_NOQA = re.compile(rb"#\s*noqa\b")
+
+
+@dataclass
+class ImportedName:
+    """One name an import statement binds."""
+
+    name: str  # the local name
+    module: str  # what it is imported from, as written
+    imported: str  # the imported name (`x` of `from m import x as y`; the module for `import m`)
+    item: Node  # the removable piece: an alias, specifier, or spec; the statement if it binds one name
+    statement: Node
+    binding: Optional[Node] = None  # the declaring identifier, for scope-resolved languages
+
+    @property
+    def key(self) -> Tuple[str, str, str]:
+        return self.module, self.imported, self.name
+
+
+@dataclass
+class ImportProblem:
+    kind: str  # "unused" or "duplicate"
+    name: str
+    module: str
+    path: str
+    line: int
+    column: int
+    duplicate_of: Optional[int] = None  # line of the earlier import
+
+    @property
+    def message(self) -> str:
+        if self.kind == "duplicate":
+            return f"'{self.name}' from '{self.module}' is already imported on line {self.duplicate_of}"
+        if self.module == self.name:
+            return f"'{self.name}' is imported but never used"
+        return f"'{self.name}' is imported from '{self.module}' but never used"
+
+    def to_dict(self) -> dict:
+        data = {
+            "kind": self.kind,
+            "name": self.name,
+            "module": self.module,
+            "path": self.path,
+            "line": self.line,
+            "column": self.column,
+        }
+        if self.duplicate_of is not None:
+            data["duplicate_of"] = self.duplicate_of
+        return data
+
+
+@dataclass
+class FileImports:
+    """The imports of one file and what is wrong with them."""
+
+    parsed: ParsedFile
+    label: str
+    imports: List[ImportedName] = field(default_factory=list)
+    problems: List[ImportProblem] = field(default_factory=list)
+    removals: List[ImportedName] = field(default_factory=list)  # the imports `fix` removes
+
+    def fix(self) -> Optional[FileRewrite]:
+        """The file with every reported import removed, or None when there is nothing to remove."""
+        if not self.removals:
+            return None
+        source = self.parsed.source
+        edits = _removal_edits(self.removals, source, self.parsed.language)
+        return FileRewrite(self.parsed.path, source, apply_edits(source, edits), edits)
+
+
+def _unquote(text: str) -> str:
+    return text.strip().strip("\"'`")
+
+
+def _walk(node: Node) -> Iterator[Node]:
+    stack = [node]
+    while stack:
+        current = stack.pop()
+        yield current
+        stack.extend(reversed(current.children))
+
+
+# ---------------------------------------------------------------------------
+# Imports per language
+# ---------------------------------------------------------------------------
+
+
+def _python_name(parsed: ParsedFile, item: Node, statement: Node, module: Optional[str]) -> Optional[ImportedName]:
+    if item.type == "aliased_import":
+        target, alias = item.child_by_field_name("name"), item.child_by_field_name("alias")
+        if target is None or alias is None:
+            return None
+        imported = parsed.text(target)
+        return ImportedName(parsed.text(alias), module or imported, imported, item, statement, alias)
+    if item.type != "dotted_name" or not item.named_children:
+        return None
+    if module is None:  # `import a.b` binds `a`
+        first = item.named_children[0]
+        return ImportedName(parsed.text(first), parsed.text(item), parsed.text(item), item, statement, first)
+    return ImportedName(parsed.text(item), module, parsed.text(item), item, statement, item.named_children[0])
+
+
+def _python_imports(parsed: ParsedFile) -> Iterator[ImportedName]:
+    if parsed.path.name == "__init__.py":
+        return  # a package's imports are its re-exports
+    for node in _walk(parsed.root):
+        if node.type == "import_statement":
+            module = None
+        elif node.type == "import_from_statement":
+            module_node = node.child_by_field_name("module_name")
+            if module_node is None or any(c.type == "wildcard_import" for c in node.children):
+                continue
+            module = parsed.text(module_node)
+        else:
+            continue
+        for item in node.children_by_field_name("name"):
+            imported = _python_name(parsed, item, node, module)
+            if imported is not None:
+                yield imported
+
+
+def _js_imports(parsed: ParsedFile) -> Iterator[ImportedName]:
+    for statement in _walk(parsed.root):
+        if statement.type != "import_statement":
+            continue
+        source = statement.child_by_field_name("source")
+        clause = next((c for c in statement.named_children if c.type == "import_clause"), None)
+        if source is None or clause is None:
+            continue  # `import "./setup"`, or TypeScript's `import x = require(...)`
+        module = _unquote(parsed.text(source))
+        for part in clause.named_children:
+            if part.type == "identifier":
+                yield ImportedName(parsed.text(part), module, "default", part, statement, part)
+            elif part.type == "namespace_import":
+                name = next((c for c in part.named_children if c.type == "identifier"), None)
+                if name is not None:
+                    yield ImportedName(parsed.text(name), module, "*", part, statement, name)
+            elif part.type == "named_imports":
+                for specifier in part.named_children:
+                    if specifier.type != "import_specifier":
+                        continue
+                    target = specifier.child_by_field_name("name")
+                    alias = specifier.child_by_field_name("alias")
+                    local = alias or target
+                    if target is not None and local is not None:
+                        yield ImportedName(parsed.text(local), module, parsed.text(target), specifier, statement, local)
+
+
+def go_package_name(path: str) -> Optional[str]:
+    """
+    The name a Go import path is used by when it has no alias: its last element without a
+    major version (`/v2`, `.v3`) or `go-` prefix. None when that is not an identifier.
+    """
+    parts = [p for p in path.split("/") if p]
+    if len(parts) > 1 and _GO_VERSION.match(parts[-1]):
+        parts.pop()
+    if not parts:
+        return None
+    name = re.sub(r"\.v\d+$", "", parts[-1])
+    name = re.sub(r"^go-|-go$", "", name)
+    return name if re.fullmatch(r"[A-Za-z_]\w*", name) else None
+
+
+def _go_imports(parsed: ParsedFile) -> Iterator[ImportedName]:
+    for declaration in parsed.root.named_children:
+        if declaration.type != "import_declaration":
+            continue
+        specs = [c for c in declaration.named_children if c.type == "import_spec"]
+        for spec_list in (c for c in declaration.named_children if c.type == "import_spec_list"):
+            specs.extend(c for c in spec_list.named_children if c.type == "import_spec")
+        for spec in specs:
+            path_node = spec.child_by_field_name("path")
+            if path_node is None:
+                continue
+            path = _unquote(parsed.text(path_node))
+            alias = spec.child_by_field_name("name")
+            if alias is not None and alias.type in {"blank_identifier", "dot"}:
+                continue
+            name = parsed.text(alias) if alias is not None else go_package_name(path)
+            if name is not None:
+                yield ImportedName(name, path, parsed.text(alias) if alias is not None else "", spec, declaration)
+
+
+def _java_imports(parsed: ParsedFile) -> Iterator[ImportedName]:
+    for statement in parsed.root.named_children:
+        if statement.type != "import_declaration" or any(c.type == "asterisk" for c in statement.children):
+            continue
+        target = next((c for c in statement.named_children if c.type in {"scoped_identifier", "identifier"}), None)
+        if target is None:
+            continue
+        path = parsed.text(target)
+        static = any(c.type == "static" for c in statement.children)
+        yield ImportedName(path.rsplit(".", 1)[-1], path, "static" if static else "", statement, statement)
+
+
+_EXTRACTORS: Dict[str, Callable[[ParsedFile], Iterator[ImportedName]]] = {
+    "python": _python_imports,
+    "javascript": _js_imports,
+    "typescript": _js_imports,
+    "tsx": _js_imports,
+    "go": _go_imports,
+    "java": _java_imports,
+}
+
+
+# ---------------------------------------------------------------------------
+# Uses
+# ---------------------------------------------------------------------------
+
+
+def _scoped_uses(parsed: ParsedFile, imports: Sequence[ImportedName]) -> Set[int]:
+    """Ids of the binding identifiers (of `imports`) that some reference resolves to."""
+    from .resolver.scopes import FileScopes
+
+    scopes = FileScopes(parsed)
+    declared = {o.node.id: o.binding for o in scopes.occurrences if o.declaration and o.binding is not None}
+    referenced = {o.binding.key for o in scopes.occurrences if not o.declaration and o.binding is not None}
+    used = set()
+    for imported in imports:
+        binding = declared.get(imported.binding.id) if imported.binding is not None else None
+        if binding is None or binding.key in referenced:
+            used.add(imported.binding.id if imported.binding is not None else imported.item.id)
+    if parsed.language == "python":
+        # `__all__ = ["name"]` and quoted annotations (`"Foo"`) name imports in strings.
+        words = {w for n in _walk(parsed.root) if n.type == "string" for w in _IDENTIFIER.findall(parsed.text(n))}
+        used.update(i.binding.id for i in imports if i.binding is not None and i.name in words)
+    elif parsed.language in _JS_LANGUAGES:
+        # The classic JSX transform compiles `<div/>` to `React.createElement`.
+        if any(n.type in {"jsx_element", "jsx_self_closing_element"} for n in _walk(parsed.root)):
+            used.update(i.binding.id for i in imports if i.binding is not None and i.name == "React")
+    return used
+
+
+def _names_outside_imports(parsed: ParsedFile, identifiers: Set[str], skip: Set[str]) -> Set[str]:
+    names = set()
+    stack = [parsed.root]
+    while stack:
+        node = stack.pop()
+        if node.type in skip:
+            continue
+        if node.type in identifiers:
+            names.add(parsed.text(node))
+        elif node.type == "block_comment":
+            names.update(_JAVADOC_REFERENCE.findall(parsed.text(node)))
+        stack.extend(node.children)
+    return names
+
+
+def _is_used(parsed: ParsedFile, imports: Sequence[ImportedName]) -> Callable[[ImportedName], bool]:
+    if parsed.language in {"python", *_JS_LANGUAGES}:
+        used = _scoped_uses(parsed, imports)
+        return lambda i: (i.binding.id if i.binding is not None else i.item.id) in used
+    if parsed.language == "go":
+        names = _names_outside_imports(parsed, {"identifier", "package_identifier"}, {"import_declaration"})
+    else:
+        names = _names_outside_imports(
+            parsed, {"identifier", "type_identifier"}, {"import_declaration", "package_declaration"}
+        )
+    return lambda i: i.name in names
+
+
+def _noqa(parsed: ParsedFile, imported: ImportedName) -> bool:
+    """Python's `# noqa` on the import's line keeps an intentionally unused import."""
+    if parsed.language != "python":
+        return False
+    source = parsed.source
+    end = source.find(b"\n", imported.item.end_byte)
+    return bool(_NOQA.search(source, imported.statement.start_byte, end if end != -1 else len(source)))
+
+
+def file_imports(parsed: ParsedFile, label: str) -> FileImports:
+    """Check the imports of one parsed file."""
+    report = FileImports(parsed, label)
+    extractor = _EXTRACTORS.get(parsed.language)
+    if extractor is None:
+        return report
+    report.imports = list(extractor(parsed))
+    if not report.imports:
+        return report
+    used = _is_used(parsed, report.imports)
+    first: Dict[tuple, ImportedName] = {}
+    for imported in report.imports:
+        where = imported.binding or imported.item
+        line, column = where.start_point[0] + 1, where.start_point[1] + 1
+        earlier = first.setdefault(imported.key, imported)
+        if earlier is not imported:
+            report.problems.append(ImportProblem(
+                "duplicate", imported.name, imported.module, label, line, column, earlier.item.start_point[0] + 1,
+            ))
+            report.removals.append(imported)
+        elif not used(imported) and not _noqa(parsed, imported):
+            report.problems.append(ImportProblem("unused", imported.name, imported.module, label, line, column))
+            report.removals.append(imported)
+    return report
+
+
+def check_imports(
+    root: Path,
+    include: Sequence[str] | None = None,
+    exclude: Sequence[str] | None = None,
+) -> List[FileImports]:
+    """Check every supported file under `root` (or a single file); files without problems are left out."""
+    root = Path(root)
+    if root.is_file():
+        parsed_files = [(parse_file(root), root.name)]
+    else:
+        base = root.resolve()
+        parsed_files = []
+        for path in iter_source_files(base, include, exclude):
+            try:
+                parsed = parse_file(path)
+            except (ValueError, RuntimeError, OSError) as exc:
+                failures.record(path, exc)
+                continue
+            if parsed.language in SUPPORTED_LANGUAGES:
+                parsed_files.append((parsed, path.relative_to(base).as_posix()))
+    reports = [file_imports(parsed, label) for parsed, label in parsed_files]
+    return [r for r in reports if r.problems]
+
+
+# ---------------------------------------------------------------------------
+# Fixes
+# ---------------------------------------------------------------------------
+
+# Nodes holding a list of removable items, and which of their children are the items.
+_ITEM_LISTS: Dict[str, Callable[[Node], List[Node]]] = {
+    "import_statement": lambda n: n.children_by_field_name("name")
+    or [c for c in n.named_children if c.type == "import_clause"],
+    "import_from_statement": lambda n: n.children_by_field_name("name"),
+    "import_clause": lambda n: list(n.named_children),
+    "named_imports": lambda n: [c for c in n.named_children if c.type == "import_specifier"],
+    "import_declaration": lambda n: [c for c in n.named_children if c.type in {"import_spec", "import_spec_list"}],
+    "import_spec_list": lambda n: [c for c in n.named_children if c.type == "import_spec"],
+}
+
+
+def _items(node: Node) -> List[Node]:
+    items = _ITEM_LISTS.get(node.type)
+    return items(node) if items is not None else []
+
+
+def _removed(node: Node, doomed: Set[int]) -> bool:
+    """True when `node` goes: it was reported, or every item it holds was."""
+    if node.id in doomed:
+        return True
+    items = _items(node)
+    return bool(items) and all(_removed(i, doomed) for i in items)
+
+
+def _whole_lines(node: Node, source: bytes) -> Optional[Tuple[int, int]]:
+    """The full lines `node` occupies (with a trailing `,`/`;` and comment), if nothing else is on them."""
+    start = source.rfind(b"\n", 0, node.start_byte) + 1
+    if source[start:node.start_byte].strip():
+        return None
+    end = node.end_byte
+    while end < len(source) and source[end:end + 1] in b",; \t":
+        end += 1
+    if source[end:end + 1] == b"#" or source[end:end + 2] == b"//":
+        end = source.find(b"\n", end)
+        end = len(source) if end == -1 else end
+    if end < len(source) and source[end:end + 1] not in b"\r\n":
+        return None
+    newline = source.find(b"\n", end)
+    return start, (len(source) if newline == -1 else newline + 1)
+
+
+def _statement_span(node: Node, source: bytes) -> Tuple[int, int]:
+    lines = _whole_lines(node, source)
+    if lines is not None:
+        return lines
+    end = node.end_byte  # `import os; x = 1`: drop the statement and its separator
+    while end < len(source) and source[end:end + 1] in b" \t":
+        end += 1
+    if source[end:end + 1] == b";":
+        end += 1
+        while end < len(source) and source[end:end + 1] in b" \t":
+            end += 1
+    return node.start_byte, end
+
+
+def _item_spans(container: Node, doomed: Set[int], source: bytes) -> Iterator[Tuple[int, int]]:
+    items = _items(container)
+    removed = [_removed(i, doomed) for i in items]
+    first_kept = removed.index(False)
+    for i, item in enumerate(items):
+        if not removed[i]:
+            if _items(item):
+                yield from _item_spans(item, doomed, source)
+            continue
+        lines = _whole_lines(item, source)
+        if lines is not None:
+            yield lines
+        elif i < first_kept:
+            yield item.start_byte, items[i + 1].start_byte  # `a, ` of `a, b`
+        else:
+            yield items[i - 1].end_byte, item.end_byte  # `, b` of `a, b`
+
+
+def _removal_edits(doomed: Sequence[ImportedName], source: bytes, language: str) -> List[Edit]:
+    ids = {i.item.id for i in doomed}
+    statements = {i.statement.id: i.statement for i in doomed}
+    spans: List[Tuple[int, int]] = []
+    emptied: List[Node] = []  # a Python block losing all its statements keeps a `pass`
+    for statement in statements.values():
+        if not _removed(statement, ids):
+            spans.extend(_item_spans(statement, ids, source))
+            continue
+        block = statement.parent
+        if language == "python" and block is not None and block.type == "block":
+            kept = [c for c in block.named_children if c.type != "comment"]
+            if all(c.id in statements and _removed(c, ids) for c in kept) and kept[0].id == statement.id:
+                emptied.append(statement)
+                continue
+        spans.append(_statement_span(statement, source))
+    merged: List[List[int]] = []
+    for start, end in sorted(spans):
+        if merged and start <= merged[-1][1]:
+            merged[-1][1] = max(merged[-1][1], end)
+        else:
+            merged.append([start, end])
+    edits = [(start, end, "") for start, end in merged]
+    edits.extend((s.start_byte, s.end_byte, "pass") for s in emptied)
+    return [
+        Edit(start, end, text, source.count(b"\n", 0, start) + 1, source.count(b"\n", 0, end) + 1)
+        for start, end, text in sorted(edits)
+    ]
+
+
+def import_fixes(reports: Sequence[FileImports]) -> List[FileRewrite]:
+    """One rewrite per file removing its unused and duplicate imports."""
+    return [fix for fix in (r.fix() for r in reports) if fix is not None and fix.changed]
+
+
+# ---------------------------------------------------------------------------
+# Output
+# ---------------------------------------------------------------------------
+
+
+def _problems(reports: Sequence[FileImports]) -> List[ImportProblem]:
+    return sorted((p for r in reports for p in r.problems), key=lambda p: (p.path, p.line, p.column))
+
+
+def imports_to_json(reports: Sequence[FileImports]) -> str:
+    return json.dumps([p.to_dict() for p in _problems(reports)], indent=2)
+
+
+def imports_to_text(reports: Sequence[FileImports]) -> str:
+    lines = [f"{p.path}:{p.line}:{p.column}: {p.kind} import: {p.message}" for p in _problems(reports)]
+    return "\n".join(lines) + ("\n" if lines else "")
+
+
+def imports_to_sarif(reports: Sequence[FileImports], root: Optional[Path] = None) -> str:
+    results = [
+        SarifResult(
+            SARIF_RULES[p.kind], p.message, SarifLocation(p.path, p.line, p.column), (p.kind, p.module, p.name),
+        )
+        for p in _problems(reports)
+    ]
+    return sarif_to_json(results, list(SARIF_RULES.values()), root)
+
+
+__all__ = [
+    "SARIF_RULES",
+    "SUPPORTED_LANGUAGES",
+    "FileImports",
+    "ImportProblem",
+    "ImportedName",
+    "check_imports",
+    "file_imports",
+    "go_package_name",
+    "import_fixes",
+    "imports_to_json",
+    "imports_to_sarif",
+    "imports_to_text",
+]
@@ -0,0 +1,167 @@
+"""Incremental re-parsing with an on-disk cache keyed by file content hash."""
+
+from __future__ import annotations
+
+import hashlib
+from dataclasses import dataclass
+from pathlib import Path
+from typing import Dict, List, Optional, Tuple
+
+from tree_sitter import Tree
+
+from .cache import ResultCache
+from .core import (
+    CodeSymbol,
+    get_parser,
+    source_language,
+    symbols_from_tree,
+)
+from .memory import Source, parse_mapped, read_source
+
+
+def content_hash(source: bytes) -> str:
+    return hashlib.sha256(source).hexdigest()
+
+
+def _point_at(source: bytes, offset: int) -> Tuple[int, int]:
+    """Tree-sitter point (row, byte column) for a byte offset."""
+    row = source.count(b"\n", 0, offset)
+    line_start = source.rfind(b"\n", 0, offset) + 1
+    return (row, offset - line_start)
+
+
+def _edit_tree(tree: Tree, old: bytes, new: bytes) -> None:
+    """Describe the change from `old` to `new` as a single edit on `tree`."""
+    limit = min(len(old), len(new))
+    prefix = 0
+    while prefix < limit and old[prefix] == new[prefix]:
+        prefix += 1
+    suffix = 0
+    while suffix < limit - prefix and old[-1 - suffix] == new[-1 - suffix]:
+        suffix += 1
+    old_end = len(old) - suffix
+    new_end = len(new) - suffix
+    tree.edit(
+        start_byte=prefix,
+        old_end_byte=old_end,
+        new_end_byte=new_end,
+        start_point=_point_at(old, prefix),
+        old_end_point=_point_at(old, old_end),
+        new_end_point=_point_at(new, new_end),
+    )
+
+
+@dataclass
+class SessionStats:
+    cache_hits: int = 0
+    reparsed: int = 0
+    incremental: int = 0
+
+    def to_dict(self) -> dict:
+        return {
+            "cache_hits": self.cache_hits,
+            "reparsed": self.reparsed,
+            "incremental": self.incremental,
+        }
+
+
+@dataclass
+class _LiveTree:
+    source: bytes
+    language: str
+    tree: Tree
+
+
+class IncrementalSession:
+    """
+    Re-run symbol extraction while only re-parsing files whose content changed.
+
+    Tree-sitter trees cannot be serialized, so the on-disk cache (see `cache`)
+    stores the extraction result per content hash, grammar, and language spec. Within a single session the live
+    trees are also kept in memory, so a changed file is re-parsed incrementally
+    against its previous tree instead of from scratch.
+    """
+
+    def __init__(
+        self,
+        cache_dir: Optional[Path] = None,
+        max_chunk_size: Optional[int] = None,
+        keep_trees: bool = True,
+    ):
+        self.cache_dir = Path(cache_dir) if cache_dir else None
+        self.cache = ResultCache(self.cache_dir) if self.cache_dir else None
+        self.max_chunk_size = max_chunk_size
+        self.keep_trees = keep_trees
+        self.stats = SessionStats()
+        self._trees: Dict[Path, _LiveTree] = {}
+
+    def _cache_key(self, source: Source, language: str) -> str:
+        return self.cache.key("symbols", source, language, options={"max_chunk_size": self.max_chunk_size})
+
+    def _load_cached(self, key: str) -> Optional[List[CodeSymbol]]:
+        if self.cache is None:
+            return None
+        payload = self.cache.get(key)
+        if payload is None:
+            return None
+        return [CodeSymbol.from_dict(item) for item in payload.get("symbols", [])]
+
+    def _store_cached(self, key: str, language: str, symbols: List[CodeSymbol]) -> None:
+        if self.cache is not None:
+            self.cache.put(key, {"language": language, "symbols": [sym.to_dict() for sym in symbols]})
+
+    def parse(self, path: Path, source: bytes, language: str) -> Tree:
+        """Parse `source`, reusing the previous tree for `path` when available."""
+        parser = get_parser(language)
+        live = self._trees.get(path)
+        if live is not None and live.language == language:
+            _edit_tree(live.tree, live.source, source)
+            tree = parser.parse(source, live.tree)
+            self.stats.incremental += 1
+        else:
+            tree = parser.parse(source)
+        if self.keep_trees:
+            self._trees[path] = _LiveTree(source=source, language=language, tree=tree)
+        return tree
+
+    def extract(self, path: Path, language: Optional[str] = None) -> List[CodeSymbol]:
+        """Extract symbols from `path`, skipping the parse when its content is cached."""
+        return self.extract_with_encoding(path, language)[0]
+
+    def extract_with_encoding(self, path: Path, language: Optional[str] = None) -> Tuple[List[CodeSymbol], str]:
+        """`extract` and the encoding `path` was read as (see `charsets`)."""
+        path = Path(path)
+        language = source_language(path, language)
+        with read_source(path) as decoded:
+            source = decoded.data
+            mapped = not isinstance(source, bytes)
+            live = None if mapped else self._trees.get(path)
+            if live is not None and live.source == source and live.language == language:
+                self.stats.cache_hits += 1
+                return symbols_from_tree(live.tree.root_node, source, language, self.max_chunk_size), decoded.encoding
+
+            key = self._cache_key(source, language) if self.cache is not None else None
+            cached = self._load_cached(key) if key is not None else None
+            if cached is not None:
+                self.stats.cache_hits += 1
+                return cached, decoded.encoding
+
+            if mapped:
+                # Large files are never kept as live trees: parse, extract, and let the tree go.
+                self._trees.pop(path, None)
+                tree = parse_mapped(get_parser(language), source)
+            else:
+                tree = self.parse(path, source, language)
+            self.stats.reparsed += 1
+            symbols = symbols_from_tree(tree.root_node, source, language, self.max_chunk_size)
+            del tree
+        if key is not None:
+            self._store_cached(key, language, symbols)
+        return symbols, decoded.encoding
+
+    def forget(self, path: Path) -> None:
+        """Drop the in-memory tree for `path` (e.g. after the file was deleted)."""
+        self._trees.pop(Path(path), None)
+
+
+__all__ = ["IncrementalSession", "SessionStats", "content_hash"]
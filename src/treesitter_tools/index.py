@@ -0,0 +1,712 @@
+"""Persistent SQLite index of symbols, references, and type relationships."""
+
+from __future__ import annotations
+
+import hashlib
+import json
+import re
+import sqlite3
+from dataclasses import dataclass
+from pathlib import Path
+from typing import Dict, List, Optional, Sequence, Set
+
+from . import charsets, failures, redact
+from .apisurface import module_name
+from .cache import grammar_version
+from .callgraph import _receiver_type, iter_call_sites
+from .core import (
+    ParsedFile,
+    _extract_docstring,
+    _signature_snippet,
+    class_kind,
+    class_supertypes,
+    go_interface_methods,
+    iter_class_nodes,
+    iter_function_nodes,
+    iter_go_instantiations,
+    iter_source_files,
+    parse_file,
+)
+
+SCHEMA_VERSION = 4
+
+SCHEMA = """
+CREATE TABLE IF NOT EXISTS meta (
+    key TEXT PRIMARY KEY,
+    value TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS files (
+    id INTEGER PRIMARY KEY,
+    path TEXT NOT NULL UNIQUE,
+    language TEXT NOT NULL,
+    grammar TEXT NOT NULL,
+    sha256 TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    mtime REAL NOT NULL
+);
+CREATE TABLE IF NOT EXISTS symbols (
+    id INTEGER PRIMARY KEY,
+    file_id INTEGER NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    name TEXT NOT NULL,
+    qualified_name TEXT NOT NULL,
+    container TEXT,
+    start_line INTEGER NOT NULL,
+    end_line INTEGER NOT NULL,
+    signature TEXT,
+    docstring TEXT
+);
+CREATE TABLE IF NOT EXISTS refs (
+    id INTEGER PRIMARY KEY,
+    file_id INTEGER NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    caller TEXT NOT NULL,
+    receiver TEXT,
+    receiver_type TEXT,
+    line INTEGER NOT NULL,
+    column INTEGER NOT NULL,
+    type_arguments TEXT  -- JSON list, for instantiations of generics (`Map[int, string](...)`, `List[T]`)
+);
+CREATE TABLE IF NOT EXISTS supertypes (
+    file_id INTEGER NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+    type_name TEXT NOT NULL,
+    supertype TEXT NOT NULL,
+    line INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS interface_methods (
+    symbol_id INTEGER NOT NULL REFERENCES symbols(id) ON DELETE CASCADE,
+    name TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS symbols_name ON symbols(name);
+CREATE INDEX IF NOT EXISTS symbols_qualified ON symbols(qualified_name);
+CREATE INDEX IF NOT EXISTS refs_name ON refs(name);
+CREATE INDEX IF NOT EXISTS supertypes_name ON supertypes(supertype);
+"""
+
+QUERY_KINDS = ("defs", "refs", "callers", "implementations")
+CLASS_KINDS = ("class", "struct", "interface", "trait")
+# Lines directly above a definition that belong to it: comments, decorators, annotations, attributes.
+_DOC_LINE = re.compile(r"^\s*(//|/\*|\*|--|;|@|#(?:[\s!\[]|$))")
+
+
+class GrammarMismatchError(ValueError):
+    """The index holds files parsed with a grammar other than the installed one."""
+
+
+@dataclass
+class IndexStats:
+    added: int = 0
+    updated: int = 0
+    removed: int = 0
+    unchanged: int = 0
+
+    def to_dict(self) -> dict:
+        return {"added": self.added, "updated": self.updated, "removed": self.removed, "unchanged": self.unchanged}
+
+
+@dataclass
+class GcStats:
+    files: int = 0  # indexed files whose source no longer exists
+    embeddings: int = 0  # embedding rows of deleted files
+    vectors: int = 0  # vectors no embedding row refers to
+    merged: int = 0  # duplicate vectors of identical chunks folded into one
+    shared: int = 0  # embedding rows reusing the vector of an identical chunk
+    bytes_before: int = 0
+    bytes_after: int = 0
+
+    @property
+    def reclaimed(self) -> int:
+        return max(0, self.bytes_before - self.bytes_after)
+
+    def to_dict(self) -> dict:
+        return {
+            "files": self.files,
+            "embeddings": self.embeddings,
+            "vectors": self.vectors,
+            "merged": self.merged,
+            "shared": self.shared,
+            "bytes_before": self.bytes_before,
+            "bytes_after": self.bytes_after,
+            "reclaimed": self.reclaimed,
+        }
+
+
+def _split_qualified(name: str) -> tuple[Optional[str], str]:
+    container, sep, member = name.rpartition(".")
+    return (container, member) if sep else (None, name)
+
+
+def _doc_start(lines: List[str], start_line: int) -> int:
+    """First line of the comment/decorator block that ends right above `start_line` (1-based)."""
+    row = start_line - 1
+    while row > 0 and _DOC_LINE.match(lines[row - 1]):
+        row -= 1
+    return row + 1
+
+
+def _go_instantiations(node, parsed: ParsedFile) -> list:
+    """Instantiations of generics in a Go declaration; a method's receiver (`l *List[T]`) declares, so it is skipped."""
+    receiver = node.child_by_field_name("receiver")
+    return list(iter_go_instantiations(node, parsed.source, {receiver.id} if receiver is not None else ()))
+
+
+def _rust_trait_impls(parsed: ParsedFile) -> List[tuple[str, str, int]]:
+    """(type, trait, line) for every `impl Trait for Type` block."""
+    found = []
+    stack = [parsed.root]
+    while stack:
+        node = stack.pop()
+        if node.type == "impl_item":
+            trait = node.child_by_field_name("trait")
+            type_node = node.child_by_field_name("type")
+            if trait is not None and type_node is not None:
+                found.append((
+                    parsed.text(type_node).split("<")[0].strip(),
+                    parsed.text(trait).split("<")[0].split("::")[-1].strip(),
+                    node.start_point[0] + 1,
+                ))
+        stack.extend(reversed(node.children))
+    return found
+
+
+class SymbolIndex:
+    """
+    On-disk index built from parsed files, queryable without re-parsing.
+
+    Files are re-indexed only when their size/mtime and content hash change, so
+    repeated `update` calls on a large tree are cheap.
+    """
+
+    def __init__(self, db_path: Path):
+        self.db_path = Path(db_path)
+        self.db_path.parent.mkdir(parents=True, exist_ok=True)
+        self.conn = sqlite3.connect(str(self.db_path))
+        self.conn.row_factory = sqlite3.Row
+        self.conn.execute("PRAGMA foreign_keys = ON")
+        self._ensure_schema()
+
+    def _ensure_schema(self) -> None:
+        self.conn.executescript(SCHEMA)
+        row = self.conn.execute("SELECT value FROM meta WHERE key = 'schema_version'").fetchone()
+        if row is not None and row["value"] != str(SCHEMA_VERSION):
+            with self.conn:
+                for table in ("interface_methods", "supertypes", "refs", "symbols", "files", "meta"):
+                    self.conn.execute(f"DROP TABLE IF EXISTS {table}")
+            self.conn.executescript(SCHEMA)
+        with self.conn:
+            self.conn.execute(
+                "INSERT OR REPLACE INTO meta(key, value) VALUES ('schema_version', ?)", (str(SCHEMA_VERSION),)
+            )
+
+    def close(self) -> None:
+        self.conn.close()
+
+    def __enter__(self) -> "SymbolIndex":
+        return self
+
+    def __exit__(self, *exc) -> None:
+        self.close()
+
+    # -- building -----------------------------------------------------------
+
+    def update(
+        self,
+        root: Path,
+        include: Sequence[str] | None = None,
+        exclude: Sequence[str] | None = None,
+        prefix: str = "",
+    ) -> IndexStats:
+        """
+        Bring the index in line with `root`: add new files, refresh changed ones, drop deleted ones.
+        With a `prefix` (one root of a workspace, see `workspace.index_workspace`) files are
+        stored as `prefix + path` and only files under that prefix are dropped.
+        """
+        base = Path(root).resolve()
+        stats = IndexStats()
+        stale = self.stale_grammars()
+        known = {
+            row["path"]: row
+            for row in self.conn.execute("SELECT id, path, language, sha256, size, mtime FROM files")
+            if row["path"].startswith(prefix)
+        }
+        seen: Set[str] = set()
+        with self.conn:
+            if not prefix:
+                self.conn.execute("INSERT OR REPLACE INTO meta(key, value) VALUES ('root', ?)", (base.as_posix(),))
+                self.conn.execute("DELETE FROM meta WHERE key = 'roots'")
+            for path in iter_source_files(base, include, exclude):
+                label = prefix + path.relative_to(base).as_posix()
+                try:
+                    stat = path.stat()
+                    row = known.get(label)
+                    if row is not None and row["language"] in stale:
+                        row = dict(row, sha256=None)  # parsed with another grammar: re-index even if unchanged
+                    elif row is not None and row["size"] == stat.st_size and row["mtime"] == stat.st_mtime:
+                        seen.add(label)
+                        stats.unchanged += 1
+                        continue
+                    parsed = parse_file(path)
+                except (ValueError, RuntimeError, OSError) as exc:
+                    failures.record(path, exc)
+                    continue
+                seen.add(label)
+                digest = hashlib.sha256(parsed.source).hexdigest()
+                if row is not None and row["sha256"] == digest:
+                    self.conn.execute(
+                        "UPDATE files SET mtime = ?, size = ? WHERE id = ?", (stat.st_mtime, stat.st_size, row["id"])
+                    )
+                    stats.unchanged += 1
+                    continue
+                if row is not None:
+                    self.conn.execute("DELETE FROM files WHERE id = ?", (row["id"],))
+                    stats.updated += 1
+                else:
+                    stats.added += 1
+                self._insert_file(parsed, label, digest, stat.st_size, stat.st_mtime)
+            for label, row in known.items():
+                if label not in seen:
+                    self.conn.execute("DELETE FROM files WHERE id = ?", (row["id"],))
+                    stats.removed += 1
+        return stats
+
+    def stale_grammars(self) -> Dict[str, List[str]]:
+        """Languages with files indexed under a grammar other than the installed one -> those grammars."""
+        stale: Dict[str, List[str]] = {}
+        for row in self.conn.execute("SELECT DISTINCT language, grammar FROM files ORDER BY language, grammar"):
+            try:
+                current = grammar_version(row["language"])
+            except RuntimeError:
+                current = None  # the grammar is gone altogether
+            if row["grammar"] != current:
+                stale.setdefault(row["language"], []).append(row["grammar"])
+        return stale
+
+    def check_grammars(self) -> None:
+        """Raise `GrammarMismatchError` rather than answer from files parsed with a different grammar."""
+        stale = self.stale_grammars()
+        if stale:
+            raise GrammarMismatchError(
+                f"{self.db_path} was built with other grammar versions for {', '.join(sorted(stale))}; "
+                "run `treesitter-tools index build` to re-index those files"
+            )
+
+    def retain_prefixes(self, names: Sequence[str]) -> int:
+        """Drop files outside every `<name>/` prefix (a name of "." keeps everything); returns how many."""
+        if "." in names:
+            return 0
+        stale = [
+            row["id"] for row in self.conn.execute("SELECT id, path FROM files")
+            if not any(row["path"].startswith(f"{name}/") for name in names)
+        ]
+        with self.conn:
+            self.conn.executemany("DELETE FROM files WHERE id = ?", [(i,) for i in stale])
+        return len(stale)
+
+    def drop_missing(self, root: Optional[Path] = None) -> int:
+        """Drop files whose source is gone (under `root`, default their recorded location); returns how many."""
+        gone = [
+            row["id"] for row in self.conn.execute("SELECT id, path FROM files")
+            if not (Path(root) / row["path"] if root is not None else self.resolve_label(row["path"])).is_file()
+        ]
+        with self.conn:
+            self.conn.executemany("DELETE FROM files WHERE id = ?", [(i,) for i in gone])
+        return len(gone)
+
+    def set_roots(self, base: Path, roots: Dict[str, Path]) -> None:
+        """Record a workspace: labels resolve against `base`, or against the root whose name prefixes them."""
+        with self.conn:
+            self.conn.execute("INSERT OR REPLACE INTO meta(key, value) VALUES ('root', ?)", (Path(base).as_posix(),))
+            self.conn.execute(
+                "INSERT OR REPLACE INTO meta(key, value) VALUES ('roots', ?)",
+                (json.dumps({name: Path(path).as_posix() for name, path in roots.items()}),),
+            )
+
+    def resolve_label(self, label: str) -> Path:
+        """The file on disk behind a stored path label."""
+        meta = {row["key"]: row["value"] for row in self.conn.execute("SELECT key, value FROM meta")}
+        for name, path in json.loads(meta.get("roots", "{}")).items():
+            if name != "." and label.startswith(f"{name}/"):
+                return Path(path) / label[len(name) + 1:]
+        return Path(meta.get("root", ".")) / label  # a root named "." is the base itself
+
+    def _insert_file(self, parsed: ParsedFile, label: str, digest: str, size: int, mtime: float) -> None:
+        cur = self.conn.execute(
+            "INSERT INTO files(path, language, grammar, sha256, size, mtime) VALUES (?, ?, ?, ?, ?, ?)",
+            (label, parsed.language, grammar_version(parsed.language), digest, size, mtime),
+        )
+        file_id = cur.lastrowid
+        source, language, root = parsed.source, parsed.language, parsed.root
+        for cls in iter_class_nodes(parsed):
+            cur = self.conn.execute(
+                "INSERT INTO symbols(file_id, kind, name, qualified_name, container, start_line, end_line, signature, docstring)"
+                " VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+                (
+                    file_id, class_kind(cls.node), cls.name, cls.qualified_name, cls.container,
+                    cls.node.start_point[0] + 1, cls.node.end_point[0] + 1,
+                    _signature_snippet(cls.node, source, language),
+                    _extract_docstring(cls.node, source, language, root),
+                ),
+            )
+            line = cls.node.start_point[0] + 1
+            for supertype in class_supertypes(cls, parsed):
+                self.conn.execute(
+                    "INSERT INTO supertypes(file_id, type_name, supertype, line) VALUES (?, ?, ?, ?)",
+                    (file_id, cls.name, supertype, line),
+                )
+            for method in go_interface_methods(cls, parsed):
+                self.conn.execute(
+                    "INSERT INTO interface_methods(symbol_id, name) VALUES (?, ?)", (cur.lastrowid, method)
+                )
+            if language == "go":
+                self._insert_instantiations(file_id, cls.qualified_name, _go_instantiations(cls.node, parsed))
+        if language == "rust":
+            for type_name, trait, line in _rust_trait_impls(parsed):
+                self.conn.execute(
+                    "INSERT INTO supertypes(file_id, type_name, supertype, line) VALUES (?, ?, ?, ?)",
+                    (file_id, type_name, trait, line),
+                )
+        functions = list(iter_function_nodes(parsed, closures=True))
+        by_node = {fn.node.id: fn for fn in functions}
+        for fn in functions:
+            self.conn.execute(
+                "INSERT INTO symbols(file_id, kind, name, qualified_name, container, start_line, end_line, signature, docstring)"
+                " VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+                (
+                    file_id, "method" if fn.container and not fn.closure else "function", fn.name,
+                    fn.qualified_name, fn.container,
+                    fn.node.start_point[0] + 1, fn.node.end_point[0] + 1,
+                    _signature_snippet(fn.node, source, language), _extract_docstring(fn.node, source, language, root),
+                ),
+            )
+            instantiations = _go_instantiations(fn.node, parsed) if language == "go" else []
+            type_arguments = {i.node.start_point: i.type_arguments for i in instantiations}
+            calls = set()
+            for site in iter_call_sites(fn.node, parsed, closures=True):
+                position = site.name_node.start_point
+                calls.add(position)
+                arguments = type_arguments.get(position)
+                invoked = by_node.get(site.name_node.id)  # `func() { ... }()` calls the closure
+                self.conn.execute(
+                    "INSERT INTO refs(file_id, name, caller, receiver, receiver_type, line, column, type_arguments)"
+                    " VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+                    (
+                        file_id, invoked.name if invoked is not None else site.name, fn.qualified_name, site.receiver,
+                        _receiver_type(fn, site.receiver, language),
+                        position[0] + 1, position[1] + 1, json.dumps(arguments) if arguments is not None else None,
+                    ),
+                )
+            self._insert_instantiations(file_id, fn.qualified_name, instantiations, calls)
+
+    def _insert_instantiations(
+        self, file_id: int, owner: str, instantiations: list, calls: Set[tuple] = frozenset()
+    ) -> None:
+        """Refs for instantiated generics (`List[int]`, `Pair[K, V]{}`) other than the `calls` already recorded."""
+        for instantiation in instantiations:
+            position = instantiation.node.start_point
+            if position in calls:
+                continue
+            self.conn.execute(
+                "INSERT INTO refs(file_id, name, caller, receiver, receiver_type, line, column, type_arguments)"
+                " VALUES (?, ?, ?, ?, NULL, ?, ?, ?)",
+                (
+                    file_id, instantiation.name, owner, instantiation.qualifier, position[0] + 1, position[1] + 1,
+                    json.dumps(instantiation.type_arguments),
+                ),
+            )
+
+    # -- querying -----------------------------------------------------------
+
+    def defs(self, name: str, kind: Optional[str] = None) -> List[dict]:
+        """Definitions whose name or qualified name (`Type.method`) equals `name`."""
+        sql = (
+            "SELECT s.kind, s.name, s.qualified_name, s.container, f.path, f.language, s.start_line, s.end_line,"
+            " s.signature, s.docstring FROM symbols s JOIN files f ON f.id = s.file_id"
+            " WHERE (s.name = ? OR s.qualified_name = ?)"
+        )
+        params: list = [name, name]
+        if kind:
+            sql += " AND s.kind = ?"
+            params.append(kind)
+        sql += " ORDER BY f.path, s.start_line, s.qualified_name"
+        return [dict(row) for row in self.conn.execute(sql, params)]
+
+    def definitions(self) -> List[dict]:
+        """Every definition in the index (the `defs` columns), by path and line."""
+        sql = (
+            "SELECT s.kind, s.name, s.qualified_name, s.container, f.path, f.language, s.start_line, s.end_line,"
+            " s.signature, s.docstring FROM symbols s JOIN files f ON f.id = s.file_id"
+            " ORDER BY f.path, s.start_line, s.qualified_name"
+        )
+        return [dict(row) for row in self.conn.execute(sql)]
+
+    def references(self) -> List[dict]:
+        """Every reference in the index (the `refs` columns), by path and position."""
+        sql = (
+            "SELECT r.name, r.caller, r.receiver, r.receiver_type, f.path, r.line, r.column, r.type_arguments"
+            " FROM refs r JOIN files f ON f.id = r.file_id ORDER BY f.path, r.line, r.column"
+        )
+        rows = [dict(row) for row in self.conn.execute(sql)]
+        for row in rows:
+            row["type_arguments"] = json.loads(row["type_arguments"]) if row["type_arguments"] else None
+        return rows
+
+    def search(self, text: str, limit: int = 100) -> List[dict]:
+        """Definitions whose name contains `text` (case-insensitive), exact and prefix matches first."""
+        escaped = text.replace("\\", "\\\\").replace("%", "\\%").replace("_", "\\_")
+        sql = (
+            "SELECT s.kind, s.name, s.qualified_name, s.container, f.path, f.language, s.start_line, s.end_line,"
+            " s.signature, s.docstring FROM symbols s JOIN files f ON f.id = s.file_id"
+            " WHERE s.name LIKE ? ESCAPE '\\'"
+            " ORDER BY lower(s.name) != lower(?), s.name NOT LIKE ? ESCAPE '\\', length(s.name), f.path, s.start_line"
+            " LIMIT ?"
+        )
+        return [dict(row) for row in self.conn.execute(sql, (f"%{escaped}%", text, f"{escaped}%", limit))]
+
+    def refs(self, name: str) -> List[dict]:
+        """
+        Call sites naming `name`. For `Type.method`, calls with a different known
+        receiver type are excluded; calls whose receiver type is unknown are kept.
+        """
+        container, member = _split_qualified(name)
+        sql = (
+            "SELECT r.name, r.caller, r.receiver, r.receiver_type, f.path, r.line, r.column, r.type_arguments"
+            " FROM refs r JOIN files f ON f.id = r.file_id WHERE r.name = ?"
+        )
+        params: list = [member]
+        if container:
+            sql += " AND (r.receiver_type = ? OR r.receiver_type IS NULL)"
+            params.append(container)
+        sql += " ORDER BY f.path, r.line, r.column"
+        rows = [dict(row) for row in self.conn.execute(sql, params)]
+        for row in rows:
+            row["type_arguments"] = json.loads(row["type_arguments"]) if row["type_arguments"] else None
+        return rows
+
+    def callers(self, name: str) -> List[dict]:
+        """Functions that call `name`, with their definition site and number of calls."""
+        container, member = _split_qualified(name)
+        sql = (
+            "SELECT r.caller, f.path, MIN(s.start_line) AS line, COUNT(DISTINCT r.id) AS calls"
+            " FROM refs r JOIN files f ON f.id = r.file_id"
+            " LEFT JOIN symbols s ON s.file_id = r.file_id AND s.qualified_name = r.caller"
+            " WHERE r.name = ?"
+        )
+        params: list = [member]
+        if container:
+            sql += " AND (r.receiver_type = ? OR r.receiver_type IS NULL)"
+            params.append(container)
+        sql += " GROUP BY r.caller, f.path ORDER BY f.path, line, r.caller"
+        return [dict(row) for row in self.conn.execute(sql, params)]
+
+    def implementations(self, name: str) -> List[dict]:
+        """
+        Types that extend/implement `name`: declared supertypes, Rust `impl Trait for`,
+        and Go structs whose method set (same package directory) covers the interface.
+        """
+        results: Dict[tuple, dict] = {}
+        for row in self.conn.execute(
+            "SELECT t.type_name, f.path, t.line FROM supertypes t JOIN files f ON f.id = t.file_id"
+            " WHERE t.supertype = ?",
+            (name,),
+        ):
+            results[(row["path"], row["type_name"])] = {
+                "name": row["type_name"], "path": row["path"], "line": row["line"], "via": "declared",
+            }
+        for iface in self.conn.execute(
+            "SELECT s.id, f.path FROM symbols s JOIN files f ON f.id = s.file_id"
+            " WHERE s.kind = 'interface' AND f.language = 'go' AND (s.name = ? OR s.qualified_name = ?)",
+            (name, name),
+        ):
+            required = {r["name"] for r in self.conn.execute(
+                "SELECT name FROM interface_methods WHERE symbol_id = ?", (iface["id"],)
+            )}
+            if not required:
+                continue
+            for struct in self._go_method_sets():
+                if required <= struct["methods"]:
+                    results[(struct["path"], struct["name"])] = {
+                        "name": struct["name"], "path": struct["path"], "line": struct["line"], "via": "method_set",
+                    }
+        return sorted(results.values(), key=lambda r: (r["path"], r["line"], r["name"]))
+
+    def _go_method_sets(self) -> List[dict]:
+        methods: Dict[tuple, Set[str]] = {}
+        for row in self.conn.execute(
+            "SELECT s.name, s.container, f.path FROM symbols s JOIN files f ON f.id = s.file_id"
+            " WHERE f.language = 'go' AND s.kind = 'method'"
+        ):
+            directory = row["path"].rpartition("/")[0]
+            methods.setdefault((directory, row["container"]), set()).add(row["name"])
+        structs = []
+        for row in self.conn.execute(
+            "SELECT s.name, f.path, s.start_line FROM symbols s JOIN files f ON f.id = s.file_id"
+            " WHERE f.language = 'go' AND s.kind = 'struct' ORDER BY f.path, s.start_line"
+        ):
+            directory = row["path"].rpartition("/")[0]
+            structs.append({
+                "name": row["name"], "path": row["path"], "line": row["start_line"],
+                "methods": methods.get((directory, row["name"]), set()),
+            })
+        return structs
+
+    def _enclosing(self, row: dict) -> Optional[dict]:
+        """The declaration of a method's container: in the same file if there is one, else the only one."""
+        if not row["container"]:
+            return None
+        placeholders = ", ".join("?" for _ in CLASS_KINDS)
+        rows = [dict(r) for r in self.conn.execute(
+            "SELECT s.kind, s.qualified_name, f.path, s.start_line, s.end_line FROM symbols s"
+            f" JOIN files f ON f.id = s.file_id WHERE s.qualified_name = ? AND s.kind IN ({placeholders})"
+            " ORDER BY f.path, s.start_line",
+            (row["container"], *CLASS_KINDS),
+        )]
+        local = [r for r in rows if r["path"] == row["path"] and r["start_line"] <= row["start_line"] <= r["end_line"]]
+        if local:
+            return local[-1]
+        return rows[0] if len(rows) == 1 else None
+
+    def get(
+        self,
+        name: str,
+        kind: Optional[str] = None,
+        context: int = 0,
+        doc: bool = False,
+        enclosing: bool = False,
+    ) -> List[dict]:
+        """
+        Exact source of every definition matching `name`, read from the indexed files
+        without parsing them. `name` is a name or `Type.method`, optionally prefixed
+        with the file (`app/db.py:Store.save`, extension optional) or its module as
+        `apisurface.module_name` spells it (`app.db:Store.save`). `doc` widens the
+        snippet upward over the comments and decorators directly above it, `context`
+        adds that many lines on each side, and `enclosing` attaches the container's
+        declaration line. Results whose file changed since indexing have `stale` set.
+        Raises `GrammarMismatchError` when the index was built with other grammars.
+        With redaction on, secrets matching its patterns are masked anywhere in the text.
+        """
+        self.check_grammars()
+        name = name.replace("::", ".")  # Rust/C++ paths name the same symbols
+        prefix, sep, rest = name.rpartition(":")
+        rows = self.defs(rest if sep else name, kind)
+        if sep:
+            rows = [
+                r for r in rows
+                if prefix in {r["path"], r["path"].rpartition(".")[0], module_name(r["path"], r["language"])}
+            ]
+        digests = {
+            row["path"]: row["sha256"] for row in self.conn.execute("SELECT path, sha256 FROM files")
+        }
+        sources: Dict[str, Optional[bytes]] = {}
+        results = []
+        for row in rows:
+            path = row["path"]
+            if path not in sources:
+                try:
+                    sources[path] = charsets.read_file(self.resolve_label(path)).data
+                except OSError:
+                    sources[path] = None
+            data = sources[path]
+            result = dict(row)
+            result["stale"] = data is None or hashlib.sha256(data).hexdigest() != digests.get(path)
+            if data is None:
+                result.update(source=None, source_start_line=None, source_end_line=None, enclosing=None)
+                results.append(result)
+                continue
+            lines = data.decode("utf-8", errors="replace").splitlines(keepends=True)
+            start = _doc_start(lines, row["start_line"]) if doc else row["start_line"]
+            start = max(1, start - context)
+            end = min(len(lines), row["end_line"] + context)
+            result["source"] = redact.apply_text("".join(lines[start - 1:end]))
+            for key in ("signature", "docstring"):
+                if result[key]:
+                    result[key] = redact.apply_text(result[key])
+            result["source_start_line"] = start
+            result["source_end_line"] = end
+            result["enclosing"] = None
+            if enclosing:
+                parent = self._enclosing(row)
+                if parent is not None and 0 < parent["start_line"] <= len(lines):
+                    same_file = parent["path"] == path
+                    parent["source"] = redact.apply_text(lines[parent["start_line"] - 1]) if same_file else None
+                    result["enclosing"] = parent
+            results.append(result)
+        return results
+
+    def query(self, kind: str, name: str) -> List[dict]:
+        """Dispatch one of `QUERY_KINDS` by name (used by the CLI); see `check_grammars`."""
+        if kind not in QUERY_KINDS:
+            raise ValueError(f"Unknown index query '{kind}' (expected one of {', '.join(QUERY_KINDS)})")
+        self.check_grammars()
+        return getattr(self, kind)(name)
+
+
+def snippet_to_text(result: dict) -> str:
+    """One `get` result as source text, under its container's declaration line when that was requested."""
+    source = result["source"] or ""
+    parent = result.get("enclosing")
+    if not parent or not parent.get("source") or parent["start_line"] >= result["source_start_line"]:
+        return source
+    text = parent["source"]
+    if parent["start_line"] + 1 < result["source_start_line"]:
+        first = source.splitlines()[0] if source else ""
+        text += first[: len(first) - len(first.lstrip())] + "...\n"
+    return text + source
+
+
+def build_index(
+    root: Path,
+    db_path: Path,
+    include: Sequence[str] | None = None,
+    exclude: Sequence[str] | None = None,
+) -> IndexStats:
+    """Create or refresh the index at `db_path` for the tree under `root`."""
+    with SymbolIndex(db_path) as index:
+        return index.update(root, include, exclude)
+
+
+def gc_index(db_path: Path, root: Optional[Path] = None, vacuum: bool = True) -> GcStats:
+    """
+    Compact the database `index build` and `embed` write: drop the files and embeddings
+    whose source is gone from `root` (default: the root `index build` recorded), vectors
+    nothing refers to any more, and duplicate vectors of identical chunks, then VACUUM
+    so the freed pages go back to the filesystem.
+    """
+    from .embeddings import EmbeddingStore
+
+    db_path = Path(db_path)
+    if not db_path.is_file():
+        raise ValueError(f"No index at {db_path}")
+    stats = GcStats(bytes_before=db_path.stat().st_size)
+    with SymbolIndex(db_path) as index:
+        base = Path(root) if root is not None else index.resolve_label("")
+        if not base.is_dir():
+            # Everything would look deleted; refuse rather than empty the index.
+            raise ValueError(f"{base} is not a directory; pass the tree the index was built from with --root")
+        stats.files = index.drop_missing(root)
+        locate = (lambda label: base / label) if root is not None else index.resolve_label
+        with EmbeddingStore(db_path) as store:
+            stats.merged = store.merged
+            stats.embeddings = store.drop_paths([p for p in store.paths() if not locate(p).is_file()])
+            stats.vectors = store.collect_vectors()
+            stats.shared = store.shared()
+    if vacuum:
+        conn = sqlite3.connect(str(db_path))
+        try:
+            conn.execute("VACUUM")
+        finally:
+            conn.close()
+    stats.bytes_after = db_path.stat().st_size
+    return stats
+
+
+__all__ = [
+    "CLASS_KINDS",
+    "QUERY_KINDS",
+    "GcStats",
+    "GrammarMismatchError",
+    "IndexStats",
+    "SymbolIndex",
+    "build_index",
+    "gc_index",
+    "snippet_to_text",
+]
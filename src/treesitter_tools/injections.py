@@ -0,0 +1,145 @@
+"""
+Embedded languages (tree-sitter injections): SQL in string literals, JavaScript and
+CSS in HTML `<script>`/`<style>` elements, HTML in Go template sources.
+
+Regions come from each host language's bundled `injections.scm` query, written with
+the standard capture names: `@injection.content` marks the embedded code, the
+language is set with `(#set! injection.language "sql")` or captured as
+`@injection.language`, and `(#set! injection.combined)` parses every region of a
+pattern as one document. Embedded code is parsed over the host source with
+`included_ranges`, so its nodes carry host lines, columns, and byte offsets.
+"""
+
+from __future__ import annotations
+
+import re
+from dataclasses import dataclass
+from typing import Dict, Iterator, List, Optional, Tuple
+
+from tree_sitter import Node, Parser, Point, Query, QueryCursor, Range
+
+from .core import LANGUAGE_MAPPINGS, load_language
+from .detect import MODELINE_ALIASES
+from .memory import Source, parse_mapped
+from .querylib import compile_query, find_query
+
+QUERY_NAME = "injections"
+
+# Injections inside injections (a <script> in the HTML of a Go template) stop this deep.
+MAX_DEPTH = 3
+
+# Literal nodes captured whole; their quotes and prefixes are not part of the embedded code.
+_QUOTED_TYPES = {"raw_string_literal", "interpreted_string_literal", "string", "string_literal", "template_string"}
+_OPENING = re.compile(rb"""^[A-Za-z@$]*(\"\"\"|'''|"|'|`)""")
+
+_QUERIES: Dict[str, Optional[Query]] = {}
+
+
+@dataclass
+class Injection:
+    language: str
+    ranges: List[Range]  # host ranges holding the embedded code, in source order
+
+    @property
+    def start_line(self) -> int:
+        return self.ranges[0].start_point[0] + 1
+
+
+def injection_query(language: str) -> Optional[Query]:
+    """The compiled `injections.scm` of a host language, or None when it has none (or it does not compile)."""
+    if language not in _QUERIES:
+        query = None
+        if find_query(language, QUERY_NAME) is not None:
+            try:
+                query = compile_query(language, QUERY_NAME)
+            except (RuntimeError, ValueError):
+                query = None  # grammar unavailable, or a node kind this grammar version lacks
+        _QUERIES[language] = query
+    return _QUERIES[language]
+
+
+def _language_name(text: str) -> str:
+    name = text.strip().strip("\"'`").lower()
+    name = MODELINE_ALIASES.get(name, name)
+    return LANGUAGE_MAPPINGS.get(name, name)
+
+
+def _content_range(node: Node, source: Source) -> Optional[Range]:
+    """`node`'s range, without the quotes when it is a whole string literal."""
+    if node.type not in _QUOTED_TYPES:
+        return Range(node.start_point, node.end_point, node.start_byte, node.end_byte)
+    text = source[node.start_byte : node.end_byte]
+    match = _OPENING.match(text)
+    if match is None:
+        return Range(node.start_point, node.end_point, node.start_byte, node.end_byte)
+    opening, closing = match.end(), len(match.group(1)) if text.endswith(match.group(1)) else 0
+    start, end = node.start_byte + opening, node.end_byte - closing
+    if end <= start:
+        return None
+    return Range(
+        Point(node.start_point[0], node.start_point[1] + opening),
+        Point(node.end_point[0], node.end_point[1] - closing),
+        start,
+        end,
+    )
+
+
+def find_injections(root: Node, source: Source, language: str) -> List[Injection]:
+    """
+    Embedded regions of a host tree. A region matched by several patterns takes the
+    language of the first one, so more specific patterns go first in the query.
+    """
+    query = injection_query(language)
+    if query is None:
+        return []
+    found: Dict[Tuple[int, int], Tuple[int, str, Range]] = {}
+    combined: Dict[Tuple[int, str], List[Range]] = {}
+    for pattern_index, captures in QueryCursor(query).matches(root):
+        settings = query.pattern_settings(pattern_index)
+        name = settings.get("injection.language")
+        if "injection.language" in captures:
+            node = captures["injection.language"][0]
+            name = source[node.start_byte : node.end_byte].decode("utf-8", "replace")
+        if not name:
+            continue
+        name = _language_name(name)
+        for node in captures.get("injection.content", ()):
+            region = _content_range(node, source)
+            if region is None:
+                continue
+            if "injection.combined" in settings:
+                combined.setdefault((pattern_index, name), []).append(region)
+                continue
+            key = (region.start_byte, region.end_byte)
+            if key not in found or pattern_index < found[key][0]:
+                found[key] = (pattern_index, name, region)
+    injections = [Injection(name, [region]) for _, name, region in found.values()]
+    for (_, name), regions in combined.items():
+        unique = sorted({(r.start_byte, r.end_byte): r for r in regions}.values(), key=lambda r: r.start_byte)
+        injections.append(Injection(name, unique))
+    injections.sort(key=lambda i: i.ranges[0].start_byte)
+    return injections
+
+
+def parse_injection(injection: Injection, source: Source) -> Optional[Node]:
+    """Parse an embedded region over the host source; None when its grammar is unavailable."""
+    try:
+        parser = Parser(load_language(injection.language), included_ranges=injection.ranges)
+    except (RuntimeError, ValueError):
+        return None
+    return parse_mapped(parser, source).root_node
+
+
+def injected_trees(root: Node, source: Source, language: str, depth: int = MAX_DEPTH) -> Iterator[Tuple[str, Node]]:
+    """(language, root node) of every embedded region, nested injections included, in source order."""
+    if depth <= 0:
+        return
+    for injection in find_injections(root, source, language):
+        embedded = parse_injection(injection, source)
+        if embedded is None:
+            continue
+        yield injection.language, embedded
+        yield from injected_trees(embedded, source, injection.language, depth - 1)
+
+
+__all__ = ["MAX_DEPTH", "QUERY_NAME", "Injection", "find_injections", "injected_trees", "injection_query", "parse_injection"]
@@ -0,0 +1,146 @@
+"""Pluggable per-language specs: node kinds, file extensions, and bundled query files."""
+
+from __future__ import annotations
+
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Callable, FrozenSet, List, Optional, Tuple
+
+from tree_sitter import Language
+
+QUERIES_DIR = Path(__file__).parent / "queries"
+
+
+@dataclass(frozen=True)
+class LanguageSpec:
+    """
+    Everything the extractors need to know about one language.
+
+    Register a spec with `core.register_language` to add a language or replace a
+    built-in one; every command (symbols, scan, chunk, callgraph, ...) reads the
+    node tables populated from it.
+    """
+
+    name: str
+    extensions: Tuple[str, ...] = ()
+    function_nodes: FrozenSet[str] = frozenset()
+    class_nodes: FrozenSet[str] = frozenset()
+    import_nodes: FrozenSet[str] = frozenset()
+    call_nodes: FrozenSet[str] = frozenset()
+    receiver_names: FrozenSet[str] = frozenset()
+    # Lambdas, closures, and function expressions: reported as functions of their own,
+    # named `<parent>$anon<N>` when nothing binds them to a name.
+    closure_nodes: FrozenSet[str] = frozenset()
+    # Grammar name in tree-sitter-language-pack when it differs from `name`.
+    grammar: Optional[str] = None
+    # Custom grammar loader, e.g. for a grammar compiled outside the language pack.
+    loader: Optional[Callable[[], Language]] = field(default=None, compare=False)
+    # How docstrings are found: "docstring" (first string in body), "rust_doc" (`///`),
+    # or "leading_comment" (comment block directly above the declaration).
+    doc_style: Optional[str] = None
+    # Directory holding `<query>.scm` files (defaults to the bundled queries/<name>).
+    query_dir: Optional[Path] = None
+
+    @property
+    def queries_path(self) -> Path:
+        return self.query_dir if self.query_dir is not None else QUERIES_DIR / self.name
+
+    def available_queries(self) -> List[str]:
+        if not self.queries_path.is_dir():
+            return []
+        return sorted(p.stem for p in self.queries_path.glob("*.scm"))
+
+    def load_query(self, name: str) -> str:
+        path = self.queries_path / f"{name}.scm"
+        if not path.is_file():
+            available = ", ".join(self.available_queries()) or "none"
+            raise ValueError(f"No '{name}' query for {self.name} (available: {available})")
+        return path.read_text(encoding="utf-8")
+
+    def to_dict(self) -> dict:
+        return {
+            "name": self.name,
+            "grammar": self.grammar or self.name,
+            "extensions": list(self.extensions),
+            "function_nodes": sorted(self.function_nodes),
+            "class_nodes": sorted(self.class_nodes),
+            "import_nodes": sorted(self.import_nodes),
+            "closure_nodes": sorted(self.closure_nodes),
+            "queries": self.available_queries(),
+        }
+
+
+_TS_FUNCTIONS = frozenset(
+    {"function_declaration", "generator_function_declaration", "method_definition", "arrow_function"}
+)
+_TS_CLASSES = frozenset(
+    {"class_declaration", "abstract_class_declaration", "interface_declaration", "enum_declaration"}
+)
+_JS_CALLS = frozenset({"call_expression"})
+_JS_CLOSURES = frozenset({"arrow_function", "function_expression", "function"})
+
+BUILTIN_SPECS = (
+    LanguageSpec(
+        name="python",
+        extensions=("py", "pyi", "python"),
+        function_nodes=frozenset({"function_definition", "async_function_definition", "decorated_definition"}),
+        class_nodes=frozenset({"class_definition", "decorated_definition"}),
+        import_nodes=frozenset({"import_statement", "import_from_statement", "future_import_statement"}),
+        call_nodes=frozenset({"call"}),
+        receiver_names=frozenset({"self", "cls"}),
+        closure_nodes=frozenset({"lambda"}),
+        doc_style="docstring",
+    ),
+    LanguageSpec(
+        name="javascript",
+        extensions=("js", "jsx", "mjs", "cjs"),
+        function_nodes=frozenset(
+            {"function_declaration", "generator_function_declaration", "method_definition", "arrow_function"}
+        ),
+        class_nodes=frozenset({"class_declaration"}),
+        import_nodes=frozenset({"import_statement"}),
+        call_nodes=_JS_CALLS,
+        receiver_names=frozenset({"this"}),
+        closure_nodes=_JS_CLOSURES,
+        doc_style="leading_comment",
+    ),
+    LanguageSpec(
+        name="typescript",
+        extensions=("ts", "mts", "cts"),
+        function_nodes=_TS_FUNCTIONS,
+        class_nodes=_TS_CLASSES,
+        import_nodes=frozenset({"import_statement"}),
+        call_nodes=_JS_CALLS,
+        receiver_names=frozenset({"this"}),
+        closure_nodes=_JS_CLOSURES,
+        doc_style="leading_comment",
+    ),
+    LanguageSpec(
+        name="tsx",
+        extensions=("tsx",),
+        function_nodes=_TS_FUNCTIONS,
+        class_nodes=_TS_CLASSES,
+        import_nodes=frozenset({"import_statement"}),
+        call_nodes=_JS_CALLS,
+        receiver_names=frozenset({"this"}),
+        closure_nodes=_JS_CLOSURES,
+        doc_style="leading_comment",
+        query_dir=QUERIES_DIR / "typescript",
+    ),
+    LanguageSpec(
+        name="rust",
+        extensions=("rs",),
+        function_nodes=frozenset({"function_item"}),
+        # impl_item stays a class node so `impl` blocks are still reported as before.
+        class_nodes=frozenset({"struct_item", "enum_item", "trait_item", "impl_item"}),
+        import_nodes=frozenset({"use_declaration", "extern_crate_declaration"}),
+        call_nodes=frozenset({"call_expression"}),
+        receiver_names=frozenset({"self"}),
+        closure_nodes=frozenset({"closure_expression"}),
+        doc_style="rust_doc",
+    ),
+    # Jupyter notebooks parse as JSON; their symbols come from the code cells (see `documents`).
+    LanguageSpec(name="notebook", extensions=("ipynb",), grammar="json"),
+)
+
+__all__ = ["BUILTIN_SPECS", "QUERIES_DIR", "LanguageSpec"]
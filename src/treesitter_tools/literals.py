@@ -0,0 +1,323 @@
+"""String literals with their enclosing function and syntactic context, for i18n audits and secret scanning."""
+
+from __future__ import annotations
+
+import json
+import re
+from dataclasses import dataclass
+from pathlib import Path
+from typing import Iterator, List, Optional, Pattern, Sequence, Tuple
+
+from tree_sitter import Node
+
+from . import failures
+from .core import ParsedFile, iter_function_nodes, iter_source_files, parse_file
+
+STRING_NODE_TYPES = {
+    "string",  # Python, JavaScript, Ruby, Bash
+    "template_string",
+    "string_literal",  # Rust, Java, C/C++, C#, Kotlin
+    "interpreted_string_literal",  # Go
+    "raw_string_literal",
+    "text_block",  # Java """..."""
+    "verbatim_string_literal",  # C# @"..."
+    "interpolated_string_expression",  # C# $"..."
+    "encapsed_string",  # PHP
+}
+
+CALL_NODE_TYPES = {
+    "call",
+    "call_expression",
+    "method_invocation",
+    "invocation_expression",
+    "macro_invocation",
+    "new_expression",
+    "object_creation_expression",
+}
+
+# Declarations and assignments: node type -> field holding the assigned name.
+ASSIGNMENT_TARGETS = {
+    "assignment": "left",
+    "augmented_assignment": "left",
+    "assignment_expression": "left",
+    "augmented_assignment_expression": "left",
+    "variable_declarator": "name",
+    "short_var_declaration": "left",
+    "var_spec": "name",
+    "const_spec": "name",
+    "let_declaration": "pattern",
+    "const_item": "name",
+    "static_item": "name",
+    "init_declarator": "declarator",
+    "public_field_definition": "name",
+    "field_definition": "property",
+}
+
+# Nodes a literal is climbed through to reach the construct that gives it meaning.
+_TRANSPARENT = {
+    "argument_list",
+    "arguments",
+    "value_argument",
+    "parenthesized_expression",
+    "concatenated_string",
+    "expression_list",
+    "literal_element",
+    "as_expression",
+    "satisfies_expression",
+    "type_assertion",
+    "non_null_expression",
+    "token_tree",  # Rust macro arguments
+}
+
+_IMPORT_TYPES = {"import_statement", "import_from_statement", "import_spec", "import_declaration", "export_statement"}
+_PAIR_TYPES = {"pair", "keyed_element"}
+_BINARY_TYPES = {"binary_expression", "binary_operator"}
+_COMPARISON_OPERATORS = {"==", "!=", "===", "!==", "<", ">", "<=", ">="}
+
+MAX_TARGET_LENGTH = 80
+
+
+@dataclass
+class StringLiteral:
+    path: str
+    line: int  # 1-based
+    column: int  # 1-based
+    end_line: int
+    value: str  # contents without quotes or prefixes; escape sequences as written
+    raw: str
+    node_type: str
+    function: Optional[str]  # qualified name of the innermost enclosing function
+    # "call", "keyword", "assignment", "key", "value", "comparison", "return", "import",
+    # "docstring", "statement", or the parent node type when none of those applies.
+    context: str
+    target: Optional[str] = None  # callee, keyword, assigned name, dictionary key, or compared operand
+
+    def to_dict(self) -> dict:
+        return {
+            "path": self.path,
+            "line": self.line,
+            "column": self.column,
+            "end_line": self.end_line,
+            "value": self.value,
+            "raw": self.raw,
+            "node_type": self.node_type,
+            "function": self.function,
+            "context": self.context,
+            "target": self.target,
+        }
+
+
+_OPENING = re.compile(r"""^([A-Za-z@$]*)(#*)(\"\"\"|'''|"|'|`)""")
+
+
+def literal_value(raw: str) -> str:
+    """The contents of a literal: prefixes (`r`, `b`, `f`, `@`, `$`, `u8`), quotes, and raw delimiters removed."""
+    match = _OPENING.match(raw)
+    if match is None:
+        return raw
+    prefix, hashes, quote = match.groups()
+    body = raw[match.end():]
+    closing = quote + hashes
+    if body.endswith(closing) and len(body) >= len(closing):
+        body = body[: len(body) - len(closing)]
+    if "R" in prefix and quote == '"' and "(" in body:
+        # C++ raw string: R"delim(contents)delim"
+        delimiter = body[: body.index("(")]
+        if body.endswith(")" + delimiter):
+            body = body[len(delimiter) + 1 : len(body) - len(delimiter) - 1]
+    return body
+
+
+def _collapse(text: str) -> str:
+    text = " ".join(text.split())
+    return text if len(text) <= MAX_TARGET_LENGTH else text[: MAX_TARGET_LENGTH - 3] + "..."
+
+
+def _is_docstring(statement: Node) -> bool:
+    parent = statement.parent
+    if parent is None or parent.type not in {"module", "block"} or len(statement.named_children) != 1:
+        return False
+    if parent.type == "block" and (parent.parent is None or parent.parent.type not in {"function_definition", "class_definition"}):
+        return False
+    return parent.named_children[0] == statement
+
+
+def _call_target(call: Node, parsed: ParsedFile) -> Optional[str]:
+    for field_name in ("function", "macro", "constructor", "type"):
+        callee = call.child_by_field_name(field_name)
+        if callee is not None:
+            return _collapse(parsed.text(callee))
+    # Java `obj.method(...)`: everything before the argument list.
+    arguments = call.child_by_field_name("arguments")
+    if arguments is None:
+        return None
+    return _collapse(parsed.source[call.start_byte : arguments.start_byte].decode("utf-8", "replace"))
+
+
+def _operator(node: Node, parsed: ParsedFile) -> Optional[str]:
+    operator = node.child_by_field_name("operator")
+    return parsed.text(operator) if operator is not None else None
+
+
+def _transparent(node: Node, parsed: ParsedFile) -> bool:
+    if node.type in _BINARY_TYPES:
+        return _operator(node, parsed) == "+"  # concatenation; anything else is a comparison or arithmetic
+    return node.type in _TRANSPARENT
+
+
+def literal_context(node: Node, parsed: ParsedFile) -> Tuple[str, Optional[str]]:
+    """(context, target) of a string literal: what construct it feeds and the name involved."""
+    child, parent = node, node.parent
+    while parent is not None and _transparent(parent, parsed):
+        child, parent = parent, parent.parent
+    if parent is None:
+        return "statement", None
+    kind = parent.type
+    if kind in CALL_NODE_TYPES:
+        return "call", _call_target(parent, parsed)
+    if kind == "keyword_argument" or kind == "named_argument":
+        name = parent.child_by_field_name("name") or parent.named_children[0]
+        return "keyword", _collapse(parsed.text(name))
+    if kind in ASSIGNMENT_TARGETS:
+        target = parent.child_by_field_name(ASSIGNMENT_TARGETS[kind])
+        if target is not None and target != child:
+            return "assignment", _collapse(parsed.text(target))
+    if kind in _PAIR_TYPES:
+        key = parent.child_by_field_name("key") or parent.named_children[0]
+        if key == child:
+            return "key", None
+        return "value", _collapse(literal_value(parsed.text(key)))
+    if kind == "comparison_operator" or (kind in _BINARY_TYPES and _operator(parent, parsed) in _COMPARISON_OPERATORS):
+        others = [n for n in parent.named_children if n != child and n.type not in STRING_NODE_TYPES]
+        return "comparison", _collapse(parsed.text(others[0])) if others else None
+    if kind in {"return_statement", "return_expression"}:
+        return "return", None
+    if kind in _IMPORT_TYPES:
+        return "import", None
+    if kind == "expression_statement":
+        if parsed.language == "python" and _is_docstring(parent):
+            return "docstring", None
+        return "statement", None
+    return kind, None
+
+
+def _outermost(node: Node) -> bool:
+    """False for a literal nested inside another (an f-string or template interpolation)."""
+    current = node.parent
+    while current is not None:
+        if current.type in STRING_NODE_TYPES:
+            return False
+        current = current.parent
+    return True
+
+
+def file_literals(parsed: ParsedFile, label: str) -> List[StringLiteral]:
+    """Every outermost string literal in `parsed`, in source order."""
+    functions = sorted(
+        ((fn.node.start_byte, fn.node.end_byte, fn.qualified_name) for fn in iter_function_nodes(parsed)),
+        key=lambda span: span[0],
+    )
+    found: List[StringLiteral] = []
+    stack = [parsed.root]
+    while stack:
+        node = stack.pop()
+        if node.type in STRING_NODE_TYPES and _outermost(node):
+            enclosing = None
+            for start, end, name in functions:
+                if start > node.start_byte:
+                    break
+                if node.end_byte <= end:
+                    enclosing = name  # later spans that still contain the literal are nested deeper
+            raw = parsed.text(node)
+            context, target = literal_context(node, parsed)
+            found.append(StringLiteral(
+                label,
+                node.start_point[0] + 1,
+                node.start_point[1] + 1,
+                node.end_point[0] + 1,
+                literal_value(raw),
+                raw,
+                node.type,
+                enclosing,
+                context,
+                target,
+            ))
+            continue
+        stack.extend(reversed(node.children))
+    return found
+
+
+def _keep(literal: StringLiteral, min_length: int, pattern: Optional[Pattern], docstrings: bool) -> bool:
+    if len(literal.value) < min_length:
+        return False
+    if not docstrings and literal.context == "docstring":
+        return False
+    return pattern is None or pattern.search(literal.value) is not None
+
+
+def iter_literals(
+    root: Path,
+    include: Sequence[str] | None = None,
+    exclude: Sequence[str] | None = None,
+    min_length: int = 0,
+    pattern: Optional[str] = None,
+    docstrings: bool = True,
+) -> Iterator[StringLiteral]:
+    """
+    String literals under a file or directory, filtered by minimum length (of the
+    unquoted value) and a regular expression searched in the value.
+    """
+    try:
+        compiled = re.compile(pattern) if pattern is not None else None
+    except re.error as e:
+        raise ValueError(f"Invalid --match pattern: {e}") from None
+    root = Path(root)
+    if root.is_file():
+        targets = [(root, root.as_posix())]
+    else:
+        base = root.resolve()
+        targets = [(p, p.relative_to(base).as_posix()) for p in iter_source_files(base, include, exclude)]
+    for path, label in targets:
+        try:
+            parsed = parse_file(path)
+        except (ValueError, RuntimeError, OSError) as exc:
+            failures.record(path, exc)
+            continue
+        for literal in file_literals(parsed, label):
+            if _keep(literal, min_length, compiled, docstrings):
+                yield literal
+
+
+def literals_to_json(literals: Sequence[StringLiteral]) -> str:
+    return json.dumps([s.to_dict() for s in literals], indent=2, ensure_ascii=False)
+
+
+def literals_to_ndjson(literals: Sequence[StringLiteral]) -> str:
+    return "".join(json.dumps(s.to_dict(), ensure_ascii=False) + "\n" for s in literals)
+
+
+def literals_to_text(literals: Sequence[StringLiteral]) -> str:
+    """`path:line:column [function] context(target) value`, one literal per line, newlines escaped."""
+    lines = []
+    for s in literals:
+        where = f"{s.path}:{s.line}:{s.column}"
+        context = f"{s.context}({s.target})" if s.target else s.context
+        function = f" [{s.function}]" if s.function else ""
+        value = s.value.replace("\n", "\\n").replace("\t", "\\t")
+        lines.append(f"{where}{function} {context} {value}")
+    return "".join(line + "\n" for line in lines)
+
+
+__all__ = [
+    "ASSIGNMENT_TARGETS",
+    "CALL_NODE_TYPES",
+    "STRING_NODE_TYPES",
+    "StringLiteral",
+    "file_literals",
+    "iter_literals",
+    "literal_context",
+    "literal_value",
+    "literals_to_json",
+    "literals_to_ndjson",
+    "literals_to_text",
+]
@@ -0,0 +1,305 @@
+"""
+A minimal Language Server (`serve --lsp`): document symbols, folding and selection
+ranges, go-to-definition, and workspace symbols for every bundled grammar, as a
+fallback for languages without a mature server.
+
+Messages use the LSP base protocol (Content-Length framing, JSON-RPC 2.0) over
+stdio. Open buffers are parsed from their in-editor text (full-document sync) and
+re-parsed incrementally on change; definitions and workspace symbols come from the
+SQLite symbol index (see `index`), refreshed when the server starts and on save.
+"""
+
+from __future__ import annotations
+
+import sys
+from dataclasses import dataclass
+from pathlib import Path
+from typing import Any, BinaryIO, Dict, List, Optional
+from urllib.parse import unquote, urlparse
+
+from . import __version__, charsets
+from .batch import INVALID_FRAME, BatchError, read_message, write_message
+from .core import LANGUAGE_SPECS, ParsedFile, detect_language, iter_class_nodes, iter_function_nodes, parse_file
+from .editor import (
+    ENCODINGS,
+    SYMBOL_KINDS,
+    _character,
+    _range,
+    byte_offset,
+    document_symbols,
+    folding_ranges,
+    selection_ranges,
+)
+from .incremental import IncrementalSession
+from .index import SymbolIndex
+from .mcp_server import INVALID_PARAMS, INVALID_REQUEST, METHOD_NOT_FOUND, PARSE_ERROR
+
+SERVER_NOT_INITIALIZED = -32002
+REQUEST_FAILED = -32803
+DEFAULT_DB = Path(".treesitter-tools") / "index.db"
+WORKSPACE_SYMBOL_LIMIT = 200
+
+
+class _RPCError(Exception):
+    def __init__(self, code: int, message: str):
+        super().__init__(message)
+        self.code = code
+
+
+def uri_to_path(uri: str) -> Path:
+    parsed = urlparse(uri)
+    if parsed.scheme != "file":
+        raise _RPCError(INVALID_PARAMS, f"Only file:// URIs are supported: {uri}")
+    path = unquote(parsed.path)
+    if len(path) > 2 and path[0] == "/" and path[2] == ":":  # file:///C:/...
+        path = path[1:]
+    return Path(path)
+
+
+@dataclass
+class _Document:
+    path: Path
+    language: str
+    source: bytes
+    version: Optional[int] = None
+
+
+class LSPServer:
+    """Serves one workspace; `root` is replaced by the client's `rootUri` when it sends one."""
+
+    def __init__(self, root: Path, db_path: Path = DEFAULT_DB):
+        self.root = Path(root).resolve()
+        self.db_path = Path(db_path)
+        self.index: Optional[SymbolIndex] = None
+        self.documents: Dict[str, _Document] = {}
+        self.trees = IncrementalSession()
+        self.encoding = "utf-16"
+        self.initialized = False
+        self.shutdown_requested = False
+        self.exited = False
+
+    # -- lifecycle ----------------------------------------------------------
+
+    def _initialize(self, params: dict) -> dict:
+        root_uri = params.get("rootUri") or next(iter(params.get("workspaceFolders") or []), {}).get("uri")
+        if root_uri:
+            self.root = uri_to_path(root_uri).resolve()
+        offered = ((params.get("capabilities") or {}).get("general") or {}).get("positionEncodings") or []
+        self.encoding = next((e for e in offered if e in ENCODINGS), "utf-16")  # the client's order is its preference
+        self.initialized = True
+        return {
+            "capabilities": {
+                "positionEncoding": self.encoding,
+                "textDocumentSync": {"openClose": True, "change": 1, "save": True},
+                "documentSymbolProvider": True,
+                "foldingRangeProvider": True,
+                "selectionRangeProvider": True,
+                "definitionProvider": True,
+                "workspaceSymbolProvider": True,
+            },
+            "serverInfo": {"name": "treesitter-tools", "version": __version__},
+        }
+
+    def _open_index(self) -> None:
+        db_path = self.db_path if self.db_path.is_absolute() else self.root / self.db_path
+        self.index = SymbolIndex(db_path)
+        self.refresh_index()
+
+    def refresh_index(self) -> None:
+        if self.index is not None:
+            self.index.update(self.root)
+
+    def close(self) -> None:
+        if self.index is not None:
+            self.index.close()
+            self.index = None
+
+    # -- documents ----------------------------------------------------------
+
+    def _did_open(self, params: dict) -> None:
+        item = params["textDocument"]
+        path = uri_to_path(item["uri"])
+        language = detect_language(path) or (item.get("languageId") if item.get("languageId") in LANGUAGE_SPECS else None)
+        if language:
+            self.documents[item["uri"]] = _Document(path, language, item["text"].encode("utf-8"), item.get("version"))
+
+    def _did_change(self, params: dict) -> None:
+        document = self.documents.get(params["textDocument"]["uri"])
+        changes = params.get("contentChanges") or []
+        if document is None or not changes:
+            return
+        document.source = changes[-1]["text"].encode("utf-8")  # full sync: the last change is the whole text
+        document.version = params["textDocument"].get("version")
+
+    def _did_close(self, params: dict) -> None:
+        document = self.documents.pop(params["textDocument"]["uri"], None)
+        if document is not None:
+            self.trees.forget(document.path)
+
+    def _parsed(self, uri: str) -> ParsedFile:
+        document = self.documents.get(uri)
+        if document is None:  # not open in the editor: parse what is on disk
+            path = uri_to_path(uri)
+            if not path.is_file():
+                raise _RPCError(REQUEST_FAILED, f"Document is not open and does not exist: {uri}")
+            return parse_file(path)
+        tree = self.trees.parse(document.path, document.source, document.language)
+        return ParsedFile(document.path, document.language, document.source, tree.root_node)
+
+    # -- requests -----------------------------------------------------------
+
+    def _word_at(self, parsed: ParsedFile, position: dict) -> Optional[str]:
+        offset = byte_offset(parsed.source, position["line"], position["character"], self.encoding)
+        for at in (offset, offset - 1):  # the cursor may sit just after the word
+            node = parsed.root.descendant_for_byte_range(at, at) if at >= 0 else None
+            if node is not None and node.child_count == 0 and node.is_named:
+                text = parsed.text(node)
+                if text and (text[0].isalpha() or text[0] in "_$"):
+                    return text
+        return None
+
+    def _local_definitions(self, parsed: ParsedFile, uri: str, name: str) -> List[dict]:
+        found = []
+        nodes = [(fn.node, fn.name) for fn in iter_function_nodes(parsed)]
+        nodes += [(cls.node, cls.name) for cls in iter_class_nodes(parsed)]
+        for node, node_name in nodes:
+            if node_name == name:
+                name_node = node.child_by_field_name("name") or node
+                found.append({"uri": uri, "range": _range(parsed, name_node, self.encoding)})
+        return sorted(found, key=lambda d: (d["range"]["start"]["line"], d["range"]["start"]["character"]))
+
+    def _location(self, row: dict) -> dict:
+        """An index row as a Location, narrowed to the name on the definition's first line when it is there."""
+        path = self.root / row["path"]
+        line = row["start_line"] - 1
+        try:
+            text = charsets.read_file(path).data.split(b"\n")[line]
+        except (OSError, IndexError):
+            text = b""
+        name = row["name"].encode("utf-8")
+        column = text.find(name)
+        start, end = (column, column + len(name)) if column >= 0 else (0, 0)
+        return {
+            "uri": path.as_uri(),
+            "range": {
+                "start": {"line": line, "character": _character(text, 0, start, self.encoding)},
+                "end": {"line": line, "character": _character(text, 0, end, self.encoding)},
+            },
+        }
+
+    def _definition(self, params: dict) -> List[dict]:
+        uri = params["textDocument"]["uri"]
+        parsed = self._parsed(uri)
+        name = self._word_at(parsed, params["position"])
+        if not name:
+            return []
+        local = self._local_definitions(parsed, uri, name)
+        if local:
+            return local
+        rows = self.index.defs(name) if self.index is not None else []
+        return [self._location(row) for row in rows]
+
+    def _workspace_symbol(self, params: dict) -> List[dict]:
+        if self.index is None:
+            return []
+        symbols = []
+        for row in self.index.search(params.get("query") or "", WORKSPACE_SYMBOL_LIMIT):
+            symbol = {"name": row["name"], "kind": SYMBOL_KINDS.get(row["kind"], SYMBOL_KINDS["class"]), "location": self._location(row)}
+            if row["container"]:
+                symbol["containerName"] = row["container"]
+            symbols.append(symbol)
+        return symbols
+
+    def _request(self, method: str, params: dict) -> Any:
+        if method == "shutdown":
+            self.shutdown_requested = True
+            return None
+        if method == "textDocument/documentSymbol":
+            parsed = self._parsed(params["textDocument"]["uri"])
+            return [s.to_dict() for s in document_symbols(parsed, self.encoding)]
+        if method == "textDocument/foldingRange":
+            parsed = self._parsed(params["textDocument"]["uri"])
+            return [r.to_dict() for r in folding_ranges(parsed, self.encoding)]
+        if method == "textDocument/selectionRange":
+            parsed = self._parsed(params["textDocument"]["uri"])
+            positions = [(p["line"], p["character"]) for p in params["positions"]]
+            return [r.to_dict() for r in selection_ranges(parsed, positions, self.encoding)]
+        if method == "textDocument/definition":
+            return self._definition(params)
+        if method == "workspace/symbol":
+            return self._workspace_symbol(params)
+        raise _RPCError(METHOD_NOT_FOUND, f"Method not found: {method}")
+
+    def _notification(self, method: str, params: dict) -> None:
+        if method == "initialized":
+            self._open_index()
+        elif method == "textDocument/didOpen":
+            self._did_open(params)
+        elif method == "textDocument/didChange":
+            self._did_change(params)
+        elif method == "textDocument/didClose":
+            self._did_close(params)
+        elif method == "textDocument/didSave":
+            self.refresh_index()
+        elif method == "exit":
+            self.exited = True
+        # Other notifications ($/cancelRequest, $/setTrace, ...) are ignored.
+
+    def handle(self, message: Any) -> Optional[dict]:
+        """Process one JSON-RPC message; returns the response, or None for notifications."""
+        if not isinstance(message, dict) or message.get("jsonrpc") != "2.0" or "method" not in message:
+            return _error_response(message.get("id") if isinstance(message, dict) else None, INVALID_REQUEST, "Invalid request")
+        method = message["method"]
+        params = message.get("params") or {}
+        if "id" not in message:
+            try:
+                self._notification(method, params)
+            except (_RPCError, KeyError, TypeError, ValueError, OSError):
+                pass  # notifications have no response to carry an error
+            return None
+        msg_id = message["id"]
+        try:
+            if method == "initialize":
+                result = self._initialize(params)
+            elif not self.initialized:
+                raise _RPCError(SERVER_NOT_INITIALIZED, "Server not initialized")
+            elif self.shutdown_requested:
+                raise _RPCError(INVALID_REQUEST, "Server is shutting down")
+            else:
+                result = self._request(method, params)
+        except _RPCError as exc:
+            return _error_response(msg_id, exc.code, str(exc))
+        except (KeyError, TypeError) as exc:
+            return _error_response(msg_id, INVALID_PARAMS, f"Invalid params: {exc}")
+        except (ValueError, RuntimeError, OSError) as exc:
+            return _error_response(msg_id, REQUEST_FAILED, str(exc))
+        return {"jsonrpc": "2.0", "id": msg_id, "result": result}
+
+    def serve(self, stdin: Optional[BinaryIO] = None, stdout: Optional[BinaryIO] = None) -> int:
+        """Answer messages until `exit` or end of input; returns the exit code LSP prescribes."""
+        stdin = stdin or sys.stdin.buffer
+        stdout = stdout or sys.stdout.buffer
+        try:
+            while not self.exited:
+                try:
+                    message = read_message(stdin)
+                except BatchError as exc:
+                    write_message(stdout, _error_response(None, PARSE_ERROR, str(exc)))
+                    if exc.code == INVALID_FRAME:
+                        break
+                    continue
+                if message is None:
+                    break
+                response = self.handle(message)
+                if response is not None:
+                    write_message(stdout, response)
+        finally:
+            self.close()
+        return 0 if self.shutdown_requested else 1
+
+
+def _error_response(msg_id: Any, code: int, message: str) -> dict:
+    return {"jsonrpc": "2.0", "id": msg_id, "error": {"code": code, "message": message}}
+
+
+__all__ = ["DEFAULT_DB", "LSPServer", "uri_to_path"]
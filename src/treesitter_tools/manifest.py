@@ -0,0 +1,349 @@
+"""
+Repository manifests: a JSON fingerprint of a tree (per-file content hash, language,
+symbol counts, syntax hash, and public-API hash, rolled up per package) that two
+snapshots can be compared by without re-reading either tree.
+
+Three hashes tell apart how much a file changed:
+
+- `sha256`: the bytes. Any edit changes it.
+- `syntax_hash`: the tokens without comments and layout, so reformatting or
+  re-commenting a file leaves it unchanged.
+- `api_hash`: the file's public declarations and their signatures (see
+  `apisurface`), without line numbers, so only a change to what callers see moves it.
+
+Packages are those of `apisurface.module_name` (the directory for Go/Java-style
+languages, else the module path); their hashes combine the files they contain, so
+moving a public function between two files of one package is not an API change.
+"""
+
+from __future__ import annotations
+
+import hashlib
+import json
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Dict, List, Optional, Sequence
+
+from . import overlay
+from .apisurface import ApiDeclaration, file_api, module_name
+from .core import ParsedFile, iter_class_nodes, iter_function_nodes, iter_source_files, parse_file
+from .testmap import is_test_file
+
+MANIFEST_VERSION = 1
+# How far a file or package changed, least to most.
+CHANGE_LEVELS = ("formatting", "code", "api")
+
+
+def _digest(parts: Sequence[str]) -> str:
+    digest = hashlib.blake2b(digest_size=16)
+    for part in parts:
+        digest.update(part.encode("utf-8"))
+        digest.update(b"\0")
+    return digest.hexdigest()
+
+
+def syntax_hash(parsed: ParsedFile) -> str:
+    """Hash of the file's tokens, skipping comments and whitespace between them."""
+    digest = hashlib.blake2b(digest_size=16)
+    stack = [parsed.root]
+    while stack:
+        node = stack.pop()
+        if "comment" in node.type:
+            continue
+        if node.child_count == 0:
+            digest.update(parsed.source[node.start_byte : node.end_byte])
+            digest.update(b"\0")
+            continue
+        stack.extend(reversed(node.children))
+    return digest.hexdigest()
+
+
+def _api_lines(declarations: Sequence[ApiDeclaration]) -> List[str]:
+    return sorted(f"{d.kind}\t{d.name}\t{d.signature}" for d in declarations)
+
+
+@dataclass
+class FileEntry:
+    path: str
+    language: str
+    sha256: str
+    size: int
+    syntax_hash: Optional[str] = None
+    api_hash: Optional[str] = None  # None when the file declares no public API (or is a test)
+    functions: int = 0
+    classes: int = 0
+    public: int = 0  # public declarations
+    test: bool = False
+    error: Optional[str] = None  # why the file could not be parsed
+    api: List[str] = field(default_factory=list, repr=False)  # sorted declaration lines, not serialized
+
+    def to_dict(self) -> dict:
+        data = {
+            "path": self.path,
+            "language": self.language,
+            "sha256": self.sha256,
+            "size": self.size,
+            "syntax_hash": self.syntax_hash,
+            "api_hash": self.api_hash,
+            "symbols": {"functions": self.functions, "classes": self.classes, "public": self.public},
+        }
+        if self.test:
+            data["test"] = True
+        if self.error:
+            data["error"] = self.error
+        return data
+
+    @classmethod
+    def from_dict(cls, data: dict) -> "FileEntry":
+        counts = data.get("symbols", {})
+        return cls(
+            path=data["path"],
+            language=data["language"],
+            sha256=data["sha256"],
+            size=data.get("size", 0),
+            syntax_hash=data.get("syntax_hash"),
+            api_hash=data.get("api_hash"),
+            functions=counts.get("functions", 0),
+            classes=counts.get("classes", 0),
+            public=counts.get("public", 0),
+            test=data.get("test", False),
+            error=data.get("error"),
+        )
+
+
+@dataclass
+class PackageEntry:
+    name: str
+    language: str
+    files: List[str]
+    content_hash: str
+    syntax_hash: str
+    api_hash: str
+
+    def to_dict(self) -> dict:
+        return {
+            "name": self.name,
+            "language": self.language,
+            "files": self.files,
+            "content_hash": self.content_hash,
+            "syntax_hash": self.syntax_hash,
+            "api_hash": self.api_hash,
+        }
+
+    @classmethod
+    def from_dict(cls, data: dict) -> "PackageEntry":
+        return cls(
+            data["name"], data["language"], list(data["files"]),
+            data["content_hash"], data["syntax_hash"], data["api_hash"],
+        )
+
+
+@dataclass
+class Manifest:
+    files: List[FileEntry] = field(default_factory=list)
+    packages: List[PackageEntry] = field(default_factory=list)
+    version: int = MANIFEST_VERSION
+
+    @property
+    def fingerprint(self) -> str:
+        """Hash of every file's path and content: equal fingerprints mean identical trees."""
+        return _digest([f"{f.path}\t{f.sha256}" for f in self.files])
+
+    @property
+    def api_fingerprint(self) -> str:
+        """Hash of every package's public API."""
+        return _digest([f"{p.language}\t{p.name}\t{p.api_hash}" for p in self.packages])
+
+    def to_dict(self) -> dict:
+        return {
+            "version": self.version,
+            "fingerprint": self.fingerprint,
+            "api_fingerprint": self.api_fingerprint,
+            "files": [f.to_dict() for f in self.files],
+            "packages": [p.to_dict() for p in self.packages],
+        }
+
+    def to_json(self) -> str:
+        return json.dumps(self.to_dict(), indent=2)
+
+    @classmethod
+    def from_dict(cls, data: dict) -> "Manifest":
+        version = data.get("version")
+        if version != MANIFEST_VERSION:
+            raise ValueError(f"Unsupported manifest version {version} (expected {MANIFEST_VERSION})")
+        return cls(
+            [FileEntry.from_dict(f) for f in data.get("files", [])],
+            [PackageEntry.from_dict(p) for p in data.get("packages", [])],
+            version,
+        )
+
+
+def _file_entry(path: Path, label: str) -> FileEntry:
+    source = overlay.read_bytes(path)
+    entry = FileEntry(label, "unknown", hashlib.sha256(source).hexdigest(), len(source))
+    try:
+        parsed = parse_file(path)
+    except (ValueError, RuntimeError) as exc:
+        entry.error = str(exc)
+        return entry
+    entry.language = parsed.language
+    entry.syntax_hash = syntax_hash(parsed)
+    entry.functions = sum(1 for _ in iter_function_nodes(parsed))
+    entry.classes = sum(1 for _ in iter_class_nodes(parsed))
+    entry.test = is_test_file(label, parsed.language)
+    if not entry.test:
+        entry.api = _api_lines(file_api(parsed, label))
+        entry.public = len(entry.api)
+        entry.api_hash = _digest(entry.api) if entry.api else None
+    return entry
+
+
+def _packages(files: Sequence[FileEntry]) -> List[PackageEntry]:
+    grouped: Dict[tuple, List[FileEntry]] = {}
+    for entry in files:
+        if entry.error is None and not entry.test:
+            grouped.setdefault((module_name(entry.path, entry.language), entry.language), []).append(entry)
+    packages = []
+    for (name, language), members in sorted(grouped.items()):
+        packages.append(PackageEntry(
+            name,
+            language,
+            [m.path for m in members],
+            _digest([f"{m.path}\t{m.sha256}" for m in members]),
+            _digest([f"{m.path}\t{m.syntax_hash}" for m in members]),
+            _digest(sorted(line for m in members for line in m.api)),
+        ))
+    return packages
+
+
+def build_manifest(root: Path, include: Sequence[str] | None = None, exclude: Sequence[str] | None = None) -> Manifest:
+    """The manifest of a file or of every recognised file under a directory."""
+    root = Path(root)
+    if root.is_file():
+        targets = [(root, root.name)]
+    else:
+        base = root.resolve()
+        targets = [(p, p.relative_to(base).as_posix()) for p in iter_source_files(base, include, exclude)]
+    files = sorted((_file_entry(path, label) for path, label in targets), key=lambda f: f.path)
+    return Manifest(files, _packages(files))
+
+
+def load_manifest(path: Path) -> Manifest:
+    try:
+        data = json.loads(Path(path).read_text(encoding="utf-8"))
+    except json.JSONDecodeError as exc:
+        raise ValueError(f"{path} is not a manifest: {exc}") from None
+    if not isinstance(data, dict):
+        raise ValueError(f"{path} is not a manifest")
+    return Manifest.from_dict(data)
+
+
+# --- comparison ------------------------------------------------------------
+
+
+@dataclass
+class Change:
+    change: str  # "added", "removed", or "modified"
+    name: str  # file path or package name
+    language: str
+    level: Optional[str] = None  # for "modified": one of CHANGE_LEVELS
+
+    def to_dict(self) -> dict:
+        data = {"change": self.change, "name": self.name, "language": self.language}
+        if self.level:
+            data["level"] = self.level
+        return data
+
+
+@dataclass
+class ManifestDiff:
+    files: List[Change] = field(default_factory=list)
+    packages: List[Change] = field(default_factory=list)
+
+    @property
+    def changed_packages(self) -> List[Change]:
+        """Packages that were added, removed, or changed beyond formatting."""
+        return [p for p in self.packages if p.level != "formatting"]
+
+    def summary(self) -> dict:
+        counts: Dict[str, int] = {}
+        for change in self.packages:
+            key = change.level if change.change == "modified" else change.change
+            counts[key] = counts.get(key, 0) + 1
+        return {"files": len(self.files), "packages": counts}
+
+    def to_json(self) -> str:
+        return json.dumps(
+            {
+                "summary": self.summary(),
+                "packages": [p.to_dict() for p in self.packages],
+                "files": [f.to_dict() for f in self.files],
+            },
+            indent=2,
+        )
+
+    def to_text(self) -> str:
+        lines = []
+        for title, changes in (("packages", self.packages), ("files", self.files)):
+            if not changes:
+                continue
+            lines.append(f"{title}:")
+            for c in changes:
+                lines.append(f"  {c.level or c.change:<10} {c.name} ({c.language})")
+        if not lines:
+            return "No changes\n"
+        counts = ", ".join(f"{n} {key}" for key, n in self.summary()["packages"].items())
+        lines.append(f"{len(self.files)} files changed; packages: {counts or 'none'}")
+        return "\n".join(lines) + "\n"
+
+
+def _level(before, after) -> Optional[str]:
+    """How much an entry changed between two manifests, or None if not at all."""
+    hash_name = "sha256" if isinstance(before, FileEntry) else "content_hash"
+    if getattr(before, hash_name) == getattr(after, hash_name):
+        return None
+    if before.api_hash != after.api_hash:
+        return "api"
+    if before.syntax_hash != after.syntax_hash or before.syntax_hash is None:
+        return "code"
+    return "formatting"
+
+
+def _compare(before: Dict[tuple, object], after: Dict[tuple, object]) -> List[Change]:
+    changes = []
+    for key in sorted(set(before) | set(after)):
+        name, language = key
+        if key not in before:
+            changes.append(Change("added", name, language))
+        elif key not in after:
+            changes.append(Change("removed", name, language))
+        else:
+            level = _level(before[key], after[key])
+            if level is not None:
+                changes.append(Change("modified", name, language, level))
+    return changes
+
+
+def diff_manifests(before: Manifest, after: Manifest) -> ManifestDiff:
+    """Files and packages added, removed, or modified (with how far) from `before` to `after`."""
+    # A file whose language changed is one removed and one added.
+    files = _compare({(f.path, f.language): f for f in before.files}, {(f.path, f.language): f for f in after.files})
+    packages = _compare(
+        {(p.name, p.language): p for p in before.packages}, {(p.name, p.language): p for p in after.packages}
+    )
+    return ManifestDiff(files, packages)
+
+
+__all__ = [
+    "CHANGE_LEVELS",
+    "MANIFEST_VERSION",
+    "Change",
+    "FileEntry",
+    "Manifest",
+    "ManifestDiff",
+    "PackageEntry",
+    "build_manifest",
+    "diff_manifests",
+    "load_manifest",
+    "syntax_hash",
+]
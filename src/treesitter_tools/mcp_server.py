@@ -0,0 +1,254 @@
+"""Model Context Protocol server (JSON-RPC 2.0 over stdio) exposing the extraction tools."""
+
+from __future__ import annotations
+
+import json
+import sys
+from dataclasses import dataclass
+from pathlib import Path
+from typing import Any, Callable, Dict, List, Optional, TextIO
+
+from . import __version__
+from .callgraph import build_call_graph
+from .chunker import ChunkOptions, chunk_parsed
+from .core import extract_symbols, iter_function_nodes, parse_file, run_query
+
+SUPPORTED_PROTOCOL_VERSIONS = ("2025-06-18", "2025-03-26", "2024-11-05")
+
+PARSE_ERROR = -32700
+INVALID_REQUEST = -32600
+METHOD_NOT_FOUND = -32601
+INVALID_PARAMS = -32602
+
+
+class ToolError(Exception):
+    """A tool failed in a way the client should see as a tool result (`isError`)."""
+
+
+@dataclass
+class Tool:
+    name: str
+    description: str
+    input_schema: dict
+    handler: Callable[[dict], Any]
+
+    def to_dict(self) -> dict:
+        return {"name": self.name, "description": self.description, "inputSchema": self.input_schema}
+
+
+def _schema(properties: Dict[str, dict], required: List[str]) -> dict:
+    return {"type": "object", "properties": properties, "required": required, "additionalProperties": False}
+
+
+_PATH = {"type": "string", "description": "File path relative to the server root"}
+_LANGUAGE = {"type": "string", "description": "Override the detected Tree-sitter language"}
+
+
+class MCPServer:
+    """
+    Serves tool calls against files under `root`.
+
+    Every path argument is resolved relative to `root` and rejected if it escapes
+    it, so an agent cannot read arbitrary files through the server.
+    """
+
+    def __init__(self, root: Path):
+        self.root = Path(root).resolve()
+        self.tools: Dict[str, Tool] = {tool.name: tool for tool in self._tools()}
+
+    def _tools(self) -> List[Tool]:
+        return [
+            Tool(
+                "extract_symbols",
+                "List functions and classes in a file with line ranges, signatures, and docstrings.",
+                _schema(
+                    {"path": _PATH, "language": _LANGUAGE,
+                     "include_content": {"type": "boolean", "description": "Include full source of each symbol"}},
+                    ["path"],
+                ),
+                self._extract_symbols,
+            ),
+            Tool(
+                "get_function_body",
+                "Return the source of a function or method by name (`name` or `Type.name`).",
+                _schema({"path": _PATH, "name": {"type": "string"}, "language": _LANGUAGE}, ["path", "name"]),
+                self._get_function_body,
+            ),
+            Tool(
+                "find_references",
+                "Find call sites of a function/method under a directory (default: the server root).",
+                _schema(
+                    {"name": {"type": "string", "description": "Callee name or Type.method"},
+                     "path": {"type": "string", "description": "File or directory relative to the server root"}},
+                    ["name"],
+                ),
+                self._find_references,
+            ),
+            Tool(
+                "chunk_file",
+                "Split a file into semantic chunks along declaration boundaries.",
+                _schema(
+                    {"path": _PATH, "language": _LANGUAGE,
+                     "max_tokens": {"type": "integer", "minimum": 1},
+                     "overlap": {"type": "integer", "minimum": 0}},
+                    ["path"],
+                ),
+                self._chunk_file,
+            ),
+            Tool(
+                "query",
+                "Run a Tree-sitter S-expression query against a file and return its captures.",
+                _schema({"path": _PATH, "query": {"type": "string"}, "language": _LANGUAGE}, ["path", "query"]),
+                self._query,
+            ),
+        ]
+
+    # -- tool handlers ------------------------------------------------------
+
+    def _resolve(self, value: Optional[str], must_be_file: bool = True) -> Path:
+        path = (self.root / (value or ".")).resolve()
+        try:
+            path.relative_to(self.root)
+        except ValueError:
+            raise ToolError(f"Path is outside the server root: {value}") from None
+        if must_be_file and not path.is_file():
+            raise ToolError(f"No such file: {value}")
+        if not path.exists():
+            raise ToolError(f"No such path: {value}")
+        return path
+
+    def _extract_symbols(self, args: dict) -> Any:
+        symbols = extract_symbols(self._resolve(args["path"]), args.get("language"))
+        include_content = bool(args.get("include_content"))
+        result = []
+        for sym in symbols:
+            data = sym.to_dict()
+            if not include_content:
+                data.pop("content", None)
+            result.append(data)
+        return result
+
+    def _get_function_body(self, args: dict) -> Any:
+        parsed = parse_file(self._resolve(args["path"]), args.get("language"))
+        name = args["name"]
+        for fn in iter_function_nodes(parsed):
+            if name in (fn.name, fn.qualified_name):
+                return {
+                    "name": fn.qualified_name,
+                    "start_line": fn.node.start_point[0] + 1,
+                    "end_line": fn.node.end_point[0] + 1,
+                    "content": parsed.text(fn.node),
+                }
+        raise ToolError(f"Function '{name}' not found in {args['path']}")
+
+    def _find_references(self, args: dict) -> Any:
+        target = self._resolve(args.get("path"), must_be_file=False)
+        graph = build_call_graph(target)
+        name = args["name"]
+        qualified = "." in name
+        refs = []
+        for edge in graph.edges:
+            if edge.callee == name or (not qualified and edge.callee.rpartition(".")[2] == name):
+                data = edge.to_dict()
+                if target.is_file():
+                    data["file"] = target.relative_to(self.root).as_posix()
+                elif target != self.root:
+                    data["file"] = (target / edge.file).relative_to(self.root).as_posix()
+                refs.append(data)
+        return refs
+
+    def _chunk_file(self, args: dict) -> Any:
+        options = ChunkOptions(max_tokens=int(args.get("max_tokens", 512)), overlap_lines=int(args.get("overlap", 0)))
+        path = self._resolve(args["path"])
+        parsed = parse_file(path, args.get("language"))
+        return [chunk.to_dict() for chunk in chunk_parsed(parsed, options, path.relative_to(self.root).as_posix())]
+
+    def _query(self, args: dict) -> Any:
+        return run_query(self._resolve(args["path"]), args["query"], args.get("language"))
+
+    # -- JSON-RPC -----------------------------------------------------------
+
+    def _call_tool(self, params: dict) -> dict:
+        tool = self.tools.get(params.get("name"))
+        if tool is None:
+            raise _RPCError(INVALID_PARAMS, f"Unknown tool: {params.get('name')}")
+        args = params.get("arguments") or {}
+        missing = [key for key in tool.input_schema["required"] if key not in args]
+        if missing:
+            raise _RPCError(INVALID_PARAMS, f"Missing required arguments: {', '.join(missing)}")
+        try:
+            result = tool.handler(args)
+        except (ToolError, ValueError, RuntimeError, OSError) as exc:
+            return {"content": [{"type": "text", "text": f"Error: {exc}"}], "isError": True}
+        text = json.dumps(result, indent=2)
+        return {"content": [{"type": "text", "text": text}], "isError": False}
+
+    def _initialize(self, params: dict) -> dict:
+        requested = params.get("protocolVersion")
+        version = requested if requested in SUPPORTED_PROTOCOL_VERSIONS else SUPPORTED_PROTOCOL_VERSIONS[0]
+        return {
+            "protocolVersion": version,
+            "capabilities": {"tools": {"listChanged": False}},
+            "serverInfo": {"name": "treesitter-tools", "version": __version__},
+        }
+
+    def handle(self, message: Any) -> Optional[dict]:
+        """Process one JSON-RPC message; returns the response, or None for notifications."""
+        if not isinstance(message, dict) or message.get("jsonrpc") != "2.0" or "method" not in message:
+            return _error_response(message.get("id") if isinstance(message, dict) else None,
+                                   INVALID_REQUEST, "Invalid request")
+        msg_id = message.get("id")
+        is_notification = "id" not in message
+        method = message["method"]
+        params = message.get("params") or {}
+        try:
+            if method == "initialize":
+                result = self._initialize(params)
+            elif method == "ping":
+                result = {}
+            elif method == "tools/list":
+                result = {"tools": [tool.to_dict() for tool in self.tools.values()]}
+            elif method == "tools/call":
+                result = self._call_tool(params)
+            elif method.startswith("notifications/"):
+                return None
+            else:
+                raise _RPCError(METHOD_NOT_FOUND, f"Method not found: {method}")
+        except _RPCError as exc:
+            return None if is_notification else _error_response(msg_id, exc.code, str(exc))
+        if is_notification:
+            return None
+        return {"jsonrpc": "2.0", "id": msg_id, "result": result}
+
+    def serve(self, stdin: TextIO = sys.stdin, stdout: TextIO = sys.stdout) -> None:
+        """Read newline-delimited JSON-RPC messages until EOF, answering each on stdout."""
+        for line in stdin:
+            line = line.strip()
+            if not line:
+                continue
+            try:
+                message = json.loads(line)
+            except json.JSONDecodeError as exc:
+                response: Optional[Any] = _error_response(None, PARSE_ERROR, f"Parse error: {exc}")
+            else:
+                if isinstance(message, list):
+                    responses = [r for r in (self.handle(m) for m in message) if r is not None]
+                    response = responses or None
+                else:
+                    response = self.handle(message)
+            if response is not None:
+                stdout.write(json.dumps(response) + "\n")
+                stdout.flush()
+
+
+class _RPCError(Exception):
+    def __init__(self, code: int, message: str):
+        super().__init__(message)
+        self.code = code
+
+
+def _error_response(msg_id: Any, code: int, message: str) -> dict:
+    return {"jsonrpc": "2.0", "id": msg_id, "error": {"code": code, "message": message}}
+
+
+__all__ = ["MCPServer", "Tool", "ToolError"]
@@ -0,0 +1,134 @@
+"""Memory-conscious file reading for very large sources: mmap, size guards, and peak RSS reporting."""
+
+from __future__ import annotations
+
+import mmap
+import re
+import sys
+from contextlib import contextmanager
+from pathlib import Path
+from typing import Iterator, Optional, Union
+
+from tree_sitter import Parser, Tree
+
+from . import charsets, overlay
+from .failures import FileTooLargeError
+
+try:  # not available on Windows
+    import resource
+except ImportError:  # pragma: no cover - platform dependent
+    resource = None  # type: ignore[assignment]
+
+# Files at least this large are memory-mapped instead of read into a bytes object.
+MMAP_THRESHOLD = 1 << 20
+
+# Bytes handed to the parser per read callback when parsing a mapped file.
+READ_CHUNK = 1 << 16
+
+Source = Union[bytes, mmap.mmap]
+
+_UNITS = {"": 1, "b": 1, "k": 1 << 10, "kb": 1 << 10, "kib": 1 << 10, "m": 1 << 20, "mb": 1 << 20, "mib": 1 << 20,
+          "g": 1 << 30, "gb": 1 << 30, "gib": 1 << 30}
+_SIZE = re.compile(r"^\s*(\d+(?:\.\d+)?)\s*([a-zA-Z]*)\s*$")
+
+
+def parse_size(text: str) -> int:
+    """Bytes in a size such as `50MB`, `512k`, `1.5GiB`, or `4096` (units are binary)."""
+    match = _SIZE.match(text)
+    unit = match.group(2).lower() if match else None
+    if match is None or unit not in _UNITS:
+        raise ValueError(f"Invalid size '{text}' (expected e.g. 4096, 512KB, 50MB, 1GB)")
+    return int(float(match.group(1)) * _UNITS[unit])
+
+
+def format_size(size: int) -> str:
+    for unit, factor in (("GiB", 1 << 30), ("MiB", 1 << 20), ("KiB", 1 << 10)):
+        if size >= factor:
+            return f"{size / factor:.1f} {unit}"
+    return f"{size} B"
+
+
+def check_file_size(path: Path, max_file_size: Optional[int]) -> None:
+    """Raise FileTooLargeError (a ValueError) when `path` is larger than `max_file_size` bytes (no limit when None)."""
+    if max_file_size is None:
+        return
+    size = overlay.size(path)
+    if size > max_file_size:
+        raise FileTooLargeError(
+            f"Skipping {path}: {format_size(size)} exceeds --max-file-size ({format_size(max_file_size)})"
+        )
+
+
+@contextmanager
+def read_source(path: Path, threshold: Optional[int] = None) -> Iterator[charsets.Decoded]:
+    """
+    The contents of `path` and the encoding they were read as. The `data` is a read-only
+    mmap for files of at least `threshold` bytes (default `MMAP_THRESHOLD`), otherwise
+    plain bytes. A mapping is only valid inside the `with` block, so copy out (slice)
+    anything that must outlive it. Files not in UTF-8 are transcoded (see `charsets`),
+    and so read into memory whatever their size.
+    """
+    overlaid = overlay.contents(path)
+    if overlaid is not None:
+        yield charsets.canonical(overlaid)
+        return
+    threshold = MMAP_THRESHOLD if threshold is None else threshold
+    with Path(path).open("rb") as handle:
+        size = handle.seek(0, 2)
+        if size < threshold or size == 0:  # empty files cannot be mapped
+            handle.seek(0)
+            yield charsets.canonical(handle.read())
+            return
+        mapped = mmap.mmap(handle.fileno(), 0, access=mmap.ACCESS_READ)
+        try:
+            yield charsets.canonical(mapped)
+        finally:
+            mapped.close()
+
+
+def parse_mapped(parser: Parser, source: Source) -> Tree:
+    """Parse `source`, feeding a mapping to the parser in chunks so it is never copied whole."""
+    if isinstance(source, bytes):
+        return parser.parse(source)
+    return parser.parse(lambda offset, _point: source[offset : offset + READ_CHUNK])
+
+
+def peak_rss() -> Optional[int]:
+    """High-water mark of this process's resident memory in bytes (None where unsupported)."""
+    return _max_rss(resource.RUSAGE_SELF) if resource is not None else None
+
+
+def peak_worker_rss() -> Optional[int]:
+    """Largest peak resident memory of any finished worker process, in bytes."""
+    return _max_rss(resource.RUSAGE_CHILDREN) if resource is not None else None
+
+
+def _max_rss(who: int) -> Optional[int]:
+    value = resource.getrusage(who).ru_maxrss
+    if not value:
+        return None
+    return value if sys.platform == "darwin" else value * 1024  # kilobytes everywhere but macOS
+
+
+def memory_watermark() -> str:
+    """`Peak RSS: 212.4 MiB (workers: 96.0 MiB)`, for the end of a scan."""
+    own, workers = peak_rss(), peak_worker_rss()
+    if own is None:
+        return "Peak RSS: unavailable on this platform"
+    line = f"Peak RSS: {format_size(own)}"
+    if workers is not None:
+        line += f" (workers: {format_size(workers)})"
+    return line
+
+
+__all__ = [
+    "MMAP_THRESHOLD",
+    "check_file_size",
+    "format_size",
+    "memory_watermark",
+    "parse_mapped",
+    "parse_size",
+    "peak_rss",
+    "peak_worker_rss",
+    "read_source",
+]
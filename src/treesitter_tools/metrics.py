@@ -0,0 +1,336 @@
+"""Per-function complexity and size metrics computed from the syntax tree."""
+
+from __future__ import annotations
+
+import csv
+import io
+import json
+from dataclasses import dataclass
+from pathlib import Path
+from typing import Collection, Dict, Iterable, List, Optional, Sequence
+
+from tree_sitter import Node
+
+from . import failures
+from .core import (
+    DEFAULT_FUNCTION_NODE_TYPES,
+    FUNCTION_NODE_TYPES,
+    ParsedFile,
+    iter_function_nodes,
+    iter_source_files,
+    parse_file,
+)
+from .export.sarif import SarifLocation, SarifResult, SarifRule, sarif_to_json
+
+# Node kinds are nearly disjoint across grammars, so one table serves every language.
+BRANCH_NODE_TYPES = {
+    "if_statement", "elif_clause", "if_expression",
+    "for_statement", "for_in_statement", "enhanced_for_statement", "for_expression",
+    "while_statement", "while_expression", "do_statement",
+    "except_clause", "catch_clause",
+    "conditional_expression", "ternary_expression",
+    "case_clause", "switch_case", "expression_case", "type_case", "communication_case",
+    "case_statement", "match_arm", "switch_block_statement_group", "when_entry",
+    "for_in_clause", "if_clause",
+}
+
+# Structures that add a cognitive-complexity increment and raise the nesting level.
+NESTING_NODE_TYPES = {
+    "if_statement", "if_expression",
+    "for_statement", "for_in_statement", "enhanced_for_statement", "for_expression",
+    "while_statement", "while_expression", "do_statement", "loop_expression",
+    "switch_statement", "expression_switch_statement", "type_switch_statement", "select_statement",
+    "switch_expression", "match_expression", "match_statement",
+    "except_clause", "catch_clause",
+    "conditional_expression", "ternary_expression",
+}
+
+IF_NODE_TYPES = {"if_statement", "if_expression"}
+ELSE_NODE_TYPES = {"elif_clause", "else_clause"}
+LOGICAL_OPERATORS = {"&&", "||", "??", "and", "or"}
+COMMENT_NODE_TYPES = {"comment", "line_comment", "block_comment"}
+
+METRIC_NAMES = ("cyclomatic", "cognitive", "max_nesting", "loc", "sloc")
+
+SARIF_RULES = {
+    "cyclomatic": SarifRule(
+        "complexity/cyclomatic", "CyclomaticComplexity", "Function exceeds the cyclomatic complexity limit",
+        "Cyclomatic complexity counts independent paths: one plus each branch, loop, case, and boolean operator.",
+        tags=("maintainability",),
+    ),
+    "cognitive": SarifRule(
+        "complexity/cognitive", "CognitiveComplexity", "Function exceeds the cognitive complexity limit",
+        "Cognitive complexity weighs control flow by how deeply it is nested, approximating how hard code is to read.",
+        tags=("maintainability",),
+    ),
+    "max_nesting": SarifRule(
+        "complexity/nesting", "NestingDepth", "Function nests control flow too deeply",
+        tags=("maintainability",),
+    ),
+    "loc": SarifRule("size/loc", "FunctionLength", "Function has too many lines", tags=("maintainability",)),
+    "sloc": SarifRule(
+        "size/sloc", "FunctionSourceLines", "Function has too many source lines (excluding blanks and comments)",
+        tags=("maintainability",),
+    ),
+}
+
+
+@dataclass
+class FunctionMetrics:
+    path: str
+    name: str
+    start_line: int
+    end_line: int
+    loc: int
+    sloc: int
+    cyclomatic: int
+    cognitive: int
+    max_nesting: int
+
+    def to_dict(self) -> dict:
+        return {
+            "path": self.path,
+            "name": self.name,
+            "start_line": self.start_line,
+            "end_line": self.end_line,
+            "loc": self.loc,
+            "sloc": self.sloc,
+            "cyclomatic": self.cyclomatic,
+            "cognitive": self.cognitive,
+            "max_nesting": self.max_nesting,
+        }
+
+
+@dataclass
+class Violation:
+    metrics: FunctionMetrics
+    metric: str
+    value: int
+    limit: int
+
+    def to_dict(self) -> dict:
+        return {**self.metrics.to_dict(), "metric": self.metric, "value": self.value, "limit": self.limit}
+
+
+def _logical_operator(node: Node, source: bytes) -> Optional[str]:
+    if node.type not in {"binary_expression", "boolean_operator"}:
+        return None
+    op = node.child_by_field_name("operator")
+    if op is None:
+        return None
+    text = source[op.start_byte:op.end_byte].decode("utf-8", "replace")
+    return text if text in LOGICAL_OPERATORS else None
+
+
+def _is_else_if(node: Node) -> bool:
+    """True for the `if` of an `else if` (Go/Java put it in the parent's `alternative`)."""
+    parent = node.parent
+    if parent is None:
+        return False
+    if parent.type == "else_clause":
+        return True
+    if parent.type in IF_NODE_TYPES:
+        alternative = parent.child_by_field_name("alternative")
+        return alternative is not None and alternative.id == node.id
+    return False
+
+
+class _Analyzer:
+    def __init__(self, root: Node, source: bytes, func_nodes: set):
+        self.root = root
+        self.source = source
+        self.func_nodes = func_nodes
+        self.cyclomatic = 1
+        self.cognitive = 0
+        self.max_nesting = 0
+
+    def run(self) -> None:
+        for child in self.root.children:
+            self._visit(child, 0, in_nested_function=False)
+
+    def _visit(self, node: Node, nesting: int, in_nested_function: bool) -> None:
+        t = node.type
+        if t in self.func_nodes:
+            # Nested functions/closures: raise nesting for cognitive, but keep their
+            # branches out of this function's cyclomatic count.
+            for child in node.children:
+                self._visit(child, nesting + 1, True)
+            return
+
+        child_nesting = nesting
+        if not in_nested_function and t in BRANCH_NODE_TYPES:
+            self.cyclomatic += 1
+
+        op = _logical_operator(node, self.source)
+        if op is not None:
+            if not in_nested_function:
+                self.cyclomatic += 1
+            if _logical_operator(node.parent, self.source) != op:
+                self.cognitive += 1
+
+        if t in ELSE_NODE_TYPES:
+            if t == "elif_clause" or (node.parent is not None and node.parent.type in IF_NODE_TYPES):
+                self.cognitive += 1
+        elif t in IF_NODE_TYPES and _is_else_if(node):
+            # `else if` already counted by its else_clause, or is flat in Go/Java.
+            if node.parent.type != "else_clause":
+                self.cognitive += 1
+            child_nesting = nesting
+            self._plain_else(node)
+        elif t in NESTING_NODE_TYPES:
+            self.cognitive += 1 + nesting
+            child_nesting = nesting + 1
+            self.max_nesting = max(self.max_nesting, child_nesting)
+            if t in IF_NODE_TYPES:
+                self._plain_else(node)
+
+        for child in node.children:
+            self._visit(child, child_nesting, in_nested_function)
+
+    def _plain_else(self, node: Node) -> None:
+        """`else { ... }` attached directly via the alternative field (Go/Java) counts +1."""
+        alternative = node.child_by_field_name("alternative")
+        if alternative is not None and alternative.type not in IF_NODE_TYPES | ELSE_NODE_TYPES:
+            self.cognitive += 1
+
+
+def _source_lines(node: Node) -> int:
+    """Lines holding at least one non-comment token."""
+    rows = set()
+    stack = [node]
+    while stack:
+        current = stack.pop()
+        if current.type in COMMENT_NODE_TYPES:
+            continue
+        if current.child_count == 0:
+            rows.update(range(current.start_point[0], current.end_point[0] + 1))
+        else:
+            stack.extend(current.children)
+    return len(rows)
+
+
+def function_metrics(parsed: ParsedFile, label: Optional[str] = None) -> List[FunctionMetrics]:
+    """Metrics for every function/method in an already-parsed file."""
+    label = label or parsed.path.as_posix()
+    func_nodes = FUNCTION_NODE_TYPES.get(parsed.language, DEFAULT_FUNCTION_NODE_TYPES) - {"decorated_definition"}
+    results: List[FunctionMetrics] = []
+    for fn in iter_function_nodes(parsed):
+        analyzer = _Analyzer(fn.node, parsed.source, func_nodes)
+        analyzer.run()
+        start, end = fn.node.start_point[0] + 1, fn.node.end_point[0] + 1
+        results.append(
+            FunctionMetrics(
+                path=label,
+                name=fn.qualified_name,
+                start_line=start,
+                end_line=end,
+                loc=end - start + 1,
+                sloc=_source_lines(fn.node),
+                cyclomatic=analyzer.cyclomatic,
+                cognitive=analyzer.cognitive,
+                max_nesting=analyzer.max_nesting,
+            )
+        )
+    return results
+
+
+def collect_metrics(
+    root: Path,
+    include: Sequence[str] | None = None,
+    exclude: Sequence[str] | None = None,
+    only: Optional[Collection[Path]] = None,
+) -> List[FunctionMetrics]:
+    """Metrics for a single file or every recognised file under a directory."""
+    root = Path(root)
+    if root.is_file():
+        return function_metrics(parse_file(root)) if only is None or root.resolve() in only else []
+    base = root.resolve()
+    results: List[FunctionMetrics] = []
+    for path in iter_source_files(base, include, exclude, only):
+        try:
+            parsed = parse_file(path)
+        except (ValueError, RuntimeError, OSError) as exc:
+            failures.record(path, exc)
+            continue
+        results.extend(function_metrics(parsed, path.relative_to(base).as_posix()))
+    return results
+
+
+def parse_thresholds(specs: Iterable[str]) -> Dict[str, int]:
+    """Parse `metric=limit` specs; a bare number limits cyclomatic complexity."""
+    limits: Dict[str, int] = {}
+    for spec in specs:
+        metric, sep, value = spec.partition("=")
+        if not sep:
+            metric, value = "cyclomatic", spec
+        metric = metric.strip().replace("-", "_")
+        if metric == "nesting":
+            metric = "max_nesting"
+        if metric not in METRIC_NAMES:
+            raise ValueError(f"Unknown metric '{metric}' (expected one of {', '.join(METRIC_NAMES)})")
+        try:
+            limits[metric] = int(value)
+        except ValueError:
+            raise ValueError(f"Threshold for '{metric}' must be an integer, got '{value}'") from None
+    return limits
+
+
+def find_violations(metrics: Iterable[FunctionMetrics], limits: Dict[str, int]) -> List[Violation]:
+    violations: List[Violation] = []
+    for item in metrics:
+        for metric, limit in limits.items():
+            value = getattr(item, metric)
+            if value > limit:
+                violations.append(Violation(item, metric, value, limit))
+    return violations
+
+
+_METRIC_LABELS = {
+    "cyclomatic": "cyclomatic complexity",
+    "cognitive": "cognitive complexity",
+    "max_nesting": "nesting depth",
+    "loc": "length in lines",
+    "sloc": "source lines",
+}
+
+
+def violations_to_sarif(violations: Iterable[Violation], root: Optional[Path] = None) -> str:
+    """Threshold violations as SARIF warnings, one rule per metric."""
+    results = []
+    for v in violations:
+        m = v.metrics
+        results.append(SarifResult(
+            SARIF_RULES[v.metric],
+            f"'{m.name}': {_METRIC_LABELS[v.metric]} is {v.value} (limit {v.limit})",
+            SarifLocation(m.path, m.start_line, end_line=m.end_line),
+            (m.name,),
+            properties={"value": v.value, "limit": v.limit},
+        ))
+    return sarif_to_json(results, list(SARIF_RULES.values()), root)
+
+
+def metrics_to_json(metrics: Iterable[FunctionMetrics]) -> str:
+    return json.dumps([m.to_dict() for m in metrics], indent=2)
+
+
+def metrics_to_csv(metrics: Iterable[FunctionMetrics]) -> str:
+    buffer = io.StringIO()
+    writer = csv.DictWriter(buffer, fieldnames=list(FunctionMetrics.__dataclass_fields__), lineterminator="\n")
+    writer.writeheader()
+    for item in metrics:
+        writer.writerow(item.to_dict())
+    return buffer.getvalue()
+
+
+__all__ = [
+    "SARIF_RULES",
+    "FunctionMetrics",
+    "Violation",
+    "collect_metrics",
+    "find_violations",
+    "function_metrics",
+    "metrics_to_csv",
+    "metrics_to_json",
+    "parse_thresholds",
+    "violations_to_sarif",
+]
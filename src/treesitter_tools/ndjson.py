@@ -0,0 +1,71 @@
+"""Newline-delimited JSON output for streaming large scans without holding results in memory."""
+
+from __future__ import annotations
+
+import json
+import time
+from typing import Callable, Iterator, Optional, TextIO
+
+from . import schema
+from .core import FileSymbols
+from .failures import PARSE_FAILURE
+
+
+class NDJSONWriter:
+    """
+    Write one compact JSON object per line.
+
+    The stream is flushed every `flush_every` records and at least every
+    `flush_interval` seconds while records arrive, so consumers tailing an output
+    file see progress and a crash loses at most one batch. `on_flush` runs after
+    each flush (checkpointed jobs commit their progress there).
+    """
+
+    def __init__(
+        self,
+        stream: TextIO,
+        flush_every: int = 1000,
+        flush_interval: float = 1.0,
+        clock: Callable[[], float] = time.monotonic,
+        on_flush: Optional[Callable[[], None]] = None,
+    ):
+        self.stream = stream
+        self.flush_every = max(flush_every, 1)
+        self.flush_interval = flush_interval
+        self.clock = clock
+        self.on_flush = on_flush
+        self.count = 0
+        self._pending = 0
+        self._last_flush = clock()
+
+    def write(self, record: dict) -> None:
+        self.stream.write(json.dumps(record, ensure_ascii=False) + "\n")
+        self.count += 1
+        self._pending += 1
+        if self._pending >= self.flush_every or self.clock() - self._last_flush >= self.flush_interval:
+            self.flush()
+
+    def flush(self) -> None:
+        self.stream.flush()
+        self._pending = 0
+        self._last_flush = self.clock()
+        if self.on_flush is not None:
+            self.on_flush()
+
+
+def report_records(report: FileSymbols, per_symbol: bool = False) -> Iterator[dict]:
+    """Records for one scanned file: the file report itself, or one record per symbol."""
+    if not per_symbol:
+        yield schema.conform("file", report.to_dict())
+        return
+    path = report.path.as_posix()
+    if report.error:
+        record = {"path": path, "language": report.language, **report.provenance(), "error": report.error,
+                  "error_kind": report.error_kind or PARSE_FAILURE}
+        yield schema.conform("symbol-record", record)
+    for sym in report.symbols:
+        record = {"path": path, "language": report.language, **report.provenance(), **sym.to_dict()}
+        yield schema.conform("symbol-record", record)
+
+
+__all__ = ["NDJSONWriter", "report_records"]
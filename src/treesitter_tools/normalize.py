@@ -0,0 +1,342 @@
+"""
+One symbol schema for every language: a precise kind from a fixed set, the chain of
+enclosing declarations, a fully-qualified name, and a visibility level, so a consumer
+of a polyglot scan reads the same fields whatever the language.
+
+- `kind`: one of `KINDS`. Functions become "method" inside a class-like declaration
+  (or with a Go receiver) and "constructor" for `__init__`/`constructor`/`new`/...;
+  classes become "struct", "interface", "trait", "enum", or (Rust) "impl".
+- `container`: names of the enclosing classes, impls, and functions, outermost first.
+- `qualified_name`: `<module>:<container...>.<name>`, the module as
+  `apisurface.module_name` has it (`pkg.mod` for Python, the directory for Go/Java,
+  `src/app` for JS), so names are unique across a repository.
+- `visibility`: one of `VISIBILITIES`, from the language's own rules: access
+  modifiers, `pub`, `export`, Go's capitalisation, Python's leading underscore (and
+  `__all__`: top-level names a module leaves out of it are "internal"), C's `static`.
+  Declarations local to a function body are "private", and a language's implicit
+  default applies when nothing is written (Java members are package-private, i.e.
+  "internal"; C# members are "private"; C++ class members are "private" until an
+  access specifier).
+"""
+
+from __future__ import annotations
+
+import re
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Collection, Dict, Iterable, Iterator, List, Optional, Sequence, Set, Tuple
+
+from tree_sitter import Node
+
+from .apisurface import module_name
+from .core import (
+    CLASS_NODE_TYPES,
+    DEFAULT_FUNCTION_NODE_TYPES,
+    FUNCTION_NODE_TYPES,
+    CodeSymbol,
+    ParsedFile,
+    _go_receiver,
+    _identifier_from,
+    _node_text,
+    class_kind,
+    function_name,
+    is_anonymous,
+    parse_file,
+)
+from .editor import CONSTRUCTOR_NAMES
+
+KINDS = ("class", "struct", "interface", "trait", "enum", "impl", "function", "method", "constructor")
+VISIBILITIES = ("public", "protected", "internal", "private")
+FUNCTION_KINDS = frozenset({"function", "method", "constructor"})
+MODULE_SEPARATOR = ":"
+
+_MODIFIER_NODES = {
+    "modifiers", "modifier", "visibility_modifier", "accessibility_modifier", "access_modifier",
+    "storage_class_specifier",
+}
+_VISIBILITY_WORD = re.compile(r"\b(public|protected|internal|private|static)\b")
+_JS_LANGUAGES = {"javascript", "typescript", "tsx"}
+# Visibility when nothing is written, for members of a type and for top-level declarations.
+_MEMBER_DEFAULT = {"java": "internal", "csharp": "private"}
+_TOP_LEVEL_DEFAULT = {"java": "internal", "csharp": "internal"}
+
+
+@dataclass
+class SymbolInfo:
+    """A declaration in the unified schema."""
+
+    kind: str
+    name: str
+    module: str
+    container: List[str] = field(default_factory=list)
+    visibility: str = "public"
+    start_line: int = 0
+    end_line: int = 0
+    language: str = ""
+
+    @property
+    def qualified_name(self) -> str:
+        return f"{self.module}{MODULE_SEPARATOR}{'.'.join([*self.container, self.name])}"
+
+    def to_dict(self) -> dict:
+        return {
+            "kind": self.kind,
+            "name": self.name,
+            "qualified_name": self.qualified_name,
+            "container": self.container,
+            "visibility": self.visibility,
+            "language": self.language,
+            "start_line": self.start_line,
+            "end_line": self.end_line,
+        }
+
+
+@dataclass
+class _Scope:
+    kind: str  # a KINDS value
+    name: str
+    label: str  # as it appears in members' container chains: an impl by its type
+    hidden: bool = False  # inside a function body
+
+
+def _modifier_words(node: Node, source: bytes) -> List[str]:
+    words = []
+    for child in node.children:
+        if child.type in _MODIFIER_NODES:
+            words.extend(_VISIBILITY_WORD.findall(_node_text(child, source)))
+    return words
+
+
+def _cpp_access(node: Node, source: bytes, default: str) -> str:
+    """The access specifier in force at a C++ class member."""
+    member = node
+    while member.parent is not None and member.parent.type != "field_declaration_list":
+        member = member.parent
+    if member.parent is None:
+        return default
+    access = default
+    for sibling in member.parent.children:
+        if sibling.start_byte >= member.start_byte:
+            break
+        if sibling.type == "access_specifier":
+            access = _node_text(sibling, source).strip().rstrip(":").strip()
+    return access
+
+
+def _visibility(node: Node, name: str, language: str, parent: Optional[_Scope], source: bytes) -> str:
+    if parent is not None and parent.hidden:
+        return "private"
+    member = parent is not None and parent.kind not in FUNCTION_KINDS
+    if language == "python":
+        return "private" if name.startswith("_") and not name.endswith("__") else "public"
+    if language == "go":
+        return "public" if name[:1].isupper() else "internal"
+    if language == "rust":
+        if parent is not None and (parent.kind == "trait" or (parent.kind == "impl" and " for " in parent.name)):
+            return "public"  # trait items and trait-impl methods are as visible as the trait
+        for child in node.children:
+            if child.type == "visibility_modifier":
+                return "public" if _node_text(child, source).strip() == "pub" else "internal"
+        return "private"
+    if language in {"c", "cpp"}:
+        if language == "cpp" and member:
+            return _cpp_access(node, source, "public" if parent.kind == "struct" else "private")
+        return "internal" if "static" in _modifier_words(node, source) else "public"
+    if language in _JS_LANGUAGES and name.startswith("#"):
+        return "private"
+    words = [w for w in _modifier_words(node, source) if w != "static"]
+    if words:
+        return words[0]
+    if member and parent.kind == "interface":
+        return "public"
+    if language in _JS_LANGUAGES and not member:
+        ancestor = node.parent
+        while ancestor is not None:
+            if ancestor.type == "export_statement":
+                return "public"
+            ancestor = ancestor.parent
+        return "internal"  # module-local
+    return (_MEMBER_DEFAULT if member else _TOP_LEVEL_DEFAULT).get(language, "public")
+
+
+def _python_all(parsed: ParsedFile) -> Optional[Set[str]]:
+    """The names a Python module lists in a literal `__all__`, or None when it has none (or builds it)."""
+    names: Optional[Set[str]] = None
+    for statement in parsed.root.children:
+        assignment = statement.named_children[0] if statement.type == "expression_statement" else None
+        if assignment is None or assignment.type not in {"assignment", "augmented_assignment"}:
+            continue
+        left, right = assignment.child_by_field_name("left"), assignment.child_by_field_name("right")
+        if left is None or parsed.text(left) != "__all__":
+            continue
+        if right is None or right.type not in {"list", "tuple"}:
+            return None
+        items = set()
+        for item in right.named_children:
+            parts = [c for c in item.named_children if c.type not in {"string_start", "string_end"}]
+            if item.type != "string" or any(c.type != "string_content" for c in parts):
+                return None
+            items.add("".join(parsed.text(c) for c in parts))
+        names = items if names is None or assignment.type == "assignment" else names | items
+    return names
+
+
+def _class_scope(node: Node, parsed: ParsedFile, parent: Optional[_Scope]) -> _Scope:
+    hidden = parent is not None and parent.hidden
+    if node.type == "impl_item":
+        type_node = node.child_by_field_name("type")
+        trait = node.child_by_field_name("trait")
+        type_name = parsed.text(type_node).split("<")[0].strip() if type_node is not None else "<anonymous>"
+        if trait is not None:
+            return _Scope("impl", f"impl {parsed.text(trait).split('<')[0].strip()} for {type_name}", type_name, hidden)
+        return _Scope("impl", f"impl {type_name}", type_name, hidden)
+    name_node = node.child_by_field_name("name")
+    name = parsed.text(name_node) if name_node is not None else _identifier_from(node, parsed.source) or "<anonymous>"
+    return _Scope(_kind_of_class(node), name, name, hidden)
+
+
+def _kind_of_class(node: Node) -> str:
+    if node.type == "impl_item":
+        return "impl"
+    if "enum" in node.type:
+        return "enum"
+    return class_kind(node)
+
+
+def symbol_infos(parsed: ParsedFile, rel_path: Optional[str] = None) -> List[SymbolInfo]:
+    """Every class-like declaration and function of `parsed` in the unified schema, in source order."""
+    language = parsed.language
+    func_nodes = FUNCTION_NODE_TYPES.get(language, DEFAULT_FUNCTION_NODE_TYPES) - {"decorated_definition"}
+    class_nodes = CLASS_NODE_TYPES.get(language, set()) - {"decorated_definition"}
+    module = module_name(rel_path or parsed.path.as_posix(), language)
+    infos: List[SymbolInfo] = []
+
+    def visit(node: Node, scopes: List[_Scope]) -> None:
+        outer = node.parent if node.parent is not None and node.parent.type == "decorated_definition" else node
+        parent = scopes[-1] if scopes else None
+        scope = None
+        if node.type in class_nodes and not (
+            language == "go" and node.type == "type_spec"
+            and (node.child_by_field_name("type") is None
+                 or node.child_by_field_name("type").type not in {"struct_type", "interface_type"})
+        ):
+            scope = _class_scope(node, parsed, parent)
+        elif node.type in func_nodes:
+            name = function_name(node, parsed.source)
+            container = [s.label for s in scopes]
+            if language == "go" and node.type == "method_declaration":
+                _, receiver = _go_receiver(node, parsed.source)
+                container = [receiver] if receiver else []
+                kind = "method"
+            elif parent is not None and parent.kind not in FUNCTION_KINDS:
+                kind = "constructor" if name in CONSTRUCTOR_NAMES else "method"
+            else:
+                kind = "function"
+            infos.append(SymbolInfo(
+                kind, name, module, container, _visibility(node, name, language, parent, parsed.source),
+                outer.start_point[0] + 1, outer.end_point[0] + 1, language,
+            ))
+            scope = _Scope(kind, name, name, hidden=True)
+            for child in node.children:
+                visit(child, [*scopes, scope])
+            return
+        if scope is not None:
+            container = [s.label for s in scopes]
+            if scope.kind == "impl":  # as visible as the type it extends
+                visibility = "private" if scope.hidden else "public"
+            else:
+                visibility = _visibility(node, scope.name, language, parent, parsed.source)
+            infos.append(SymbolInfo(
+                scope.kind, scope.name, module, container, visibility,
+                outer.start_point[0] + 1, outer.end_point[0] + 1, language,
+            ))
+            scopes = [*scopes, scope]
+        for child in node.children:
+            visit(child, scopes)
+
+    visit(parsed.root, [])
+    if language == "python":
+        # A module with `__all__` exports only what it lists; its other public names are module-internal.
+        exported = _python_all(parsed)
+        for info in infos if exported is not None else ():
+            if not info.container and info.visibility == "public" and info.name not in exported:
+                info.visibility = "internal"
+    infos.sort(key=lambda i: (i.start_line, -i.end_line))
+    return infos
+
+
+def _coarse(kind: str) -> str:
+    return "function" if kind in FUNCTION_KINDS else "class"
+
+
+def _matched(
+    symbols: Sequence[CodeSymbol], parsed: ParsedFile, rel_path: Optional[str]
+) -> Iterator[Tuple[CodeSymbol, SymbolInfo]]:
+    """(symbol, its declaration) for the symbols `symbol_infos` finds; embedded code is left out."""
+    by_line: Dict[tuple, List[SymbolInfo]] = {}
+    for info in symbol_infos(parsed, rel_path):
+        by_line.setdefault((info.start_line, _coarse(info.kind)), []).append(info)
+    chunked: Dict[str, SymbolInfo] = {}
+    for symbol in symbols:
+        if symbol.language is not None:
+            continue
+        if symbol.chunk_index:
+            info = chunked.get(symbol.name)
+        else:
+            candidates = by_line.get((symbol.start_line, _coarse(symbol.kind)), [])
+            # A closure is never its enclosing function, even when both start on one line.
+            closure = symbol.parent is not None or is_anonymous(symbol.name)
+            fallback = candidates[0] if candidates and not closure else None
+            info = next((c for c in candidates if c.name == symbol.name), fallback)
+            if info is not None and symbol.chunk_index == 0:
+                chunked[symbol.name] = info
+        if info is not None:
+            yield symbol, info
+
+
+def normalize_symbols(symbols: Sequence[CodeSymbol], parsed: ParsedFile, rel_path: Optional[str] = None) -> None:
+    """
+    Rewrite `symbols` (as `extract_symbols` returned them for `parsed`) into the unified
+    schema: a precise `kind`, plus `container`, `qualified_name`, and `visibility`.
+    Symbols of embedded code (a `<script>` in HTML) are left as they are.
+    """
+    for symbol, info in _matched(symbols, parsed, rel_path):
+        symbol.kind = info.kind
+        symbol.container = list(info.container)
+        symbol.qualified_name = info.qualified_name
+        symbol.visibility = info.visibility
+
+
+def annotate_visibility(symbols: Sequence[CodeSymbol], parsed: ParsedFile, rel_path: Optional[str] = None) -> None:
+    """Set only the `visibility` of `symbols`, keeping their extracted kinds (`--visibility` without `--normalize`)."""
+    for symbol, info in _matched(symbols, parsed, rel_path):
+        symbol.visibility = info.visibility
+
+
+def filter_visibility(symbols: Iterable[CodeSymbol], levels: Collection[str]) -> List[CodeSymbol]:
+    """
+    The symbols whose `visibility` is one of `levels` (see `annotate_visibility`).
+    Symbols without one, such as embedded code, are dropped.
+    """
+    unknown = sorted(set(levels) - set(VISIBILITIES))
+    if unknown:
+        raise ValueError(f"Unknown visibility {', '.join(unknown)} (expected {', '.join(VISIBILITIES)})")
+    return [s for s in symbols if s.visibility in levels]
+
+
+def file_symbol_infos(path: Path, language: Optional[str] = None, rel_path: Optional[str] = None) -> List[SymbolInfo]:
+    return symbol_infos(parse_file(path, language), rel_path)
+
+
+__all__ = [
+    "FUNCTION_KINDS",
+    "KINDS",
+    "MODULE_SEPARATOR",
+    "VISIBILITIES",
+    "SymbolInfo",
+    "annotate_visibility",
+    "file_symbol_infos",
+    "filter_visibility",
+    "normalize_symbols",
+    "symbol_infos",
+]
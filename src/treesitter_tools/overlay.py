@@ -0,0 +1,121 @@
+"""
+Overlays: unsaved editor buffers layered over the files on disk.
+
+An overlay maps a path to the contents it should be analyzed with. While overlays are
+active (`--overlay PATH=CONTENTS_FILE`, or `Overlay` from Python), every read of source
+for parsing (symbols, queries, chunks, metrics, language and binary detection, size
+limits, cache keys) sees the overlay's contents instead of the file's, and directory
+walks include overlaid files that do not exist on disk yet. Paths match after
+resolving, so `src/app.py` and `./src/app.py` name the same file.
+
+Overlays never reach the disk: commands that rewrite files (`imports --fix`,
+`headers --fix`, `rename --in-place`) turn them off, and `rewrite`, `apply`, `get`, and
+the persistent symbol index read what is saved.
+"""
+
+from __future__ import annotations
+
+from contextlib import contextmanager
+from pathlib import Path
+from typing import Dict, Iterable, Iterator, List, Mapping, Optional, Union
+
+from . import charsets
+
+Contents = Union[bytes, str]
+
+
+def _key(path) -> Path:
+    return Path(path).resolve()
+
+
+class Overlay:
+    """Path -> contents, for files whose in-memory version differs from the saved one."""
+
+    def __init__(self, files: Optional[Mapping[Union[str, Path], Contents]] = None):
+        self.files: Dict[Path, bytes] = {}
+        for path, contents in (files or {}).items():
+            self.set(path, contents)
+
+    @classmethod
+    def from_specs(cls, specs: Iterable[str]) -> "Overlay":
+        """Overlays from `PATH=CONTENTS_FILE` specs, reading each contents file now."""
+        overlay = cls()
+        for spec in specs:
+            path, sep, contents_file = spec.partition("=")
+            if not sep or not path or not contents_file:
+                raise ValueError(f"Invalid overlay '{spec}' (expected PATH=CONTENTS_FILE)")
+            try:
+                overlay.set(path, Path(contents_file).read_bytes())
+            except OSError as exc:
+                raise ValueError(f"Cannot read overlay contents for {path}: {exc}") from exc
+        return overlay
+
+    def set(self, path, contents: Contents) -> None:
+        self.files[_key(path)] = contents.encode("utf-8") if isinstance(contents, str) else bytes(contents)
+
+    def remove(self, path) -> None:
+        self.files.pop(_key(path), None)
+
+    def get(self, path) -> Optional[bytes]:
+        return self.files.get(_key(path)) if self.files else None
+
+    def paths_under(self, root: Path) -> List[Path]:
+        root = _key(root)
+        return sorted(p for p in self.files if root in p.parents)
+
+    def __len__(self) -> int:
+        return len(self.files)
+
+
+# Set by the CLI (`--overlay`) or `applied`; None reads every file from disk.
+ACTIVE: Optional[Overlay] = None
+
+
+def contents(path) -> Optional[bytes]:
+    """The overlaid contents of `path`, or None to read it from disk."""
+    return ACTIVE.get(path) if ACTIVE is not None else None
+
+
+def read_bytes(path) -> bytes:
+    """`path`'s contents as analysis sees them: its overlay, else the file on disk, as UTF-8 (see `charsets`)."""
+    data = contents(path)
+    return charsets.canonical(data if data is not None else Path(path).read_bytes()).data
+
+
+def is_file(path) -> bool:
+    return contents(path) is not None or Path(path).is_file()
+
+
+def size(path) -> int:
+    data = contents(path)
+    return len(data) if data is not None else Path(path).stat().st_size
+
+
+def added_under(root: Path) -> List[Path]:
+    """Overlaid files under `root` that are not on disk, for directory walks to add."""
+    if ACTIVE is None:
+        return []
+    return [p for p in ACTIVE.paths_under(root) if not p.exists()]
+
+
+@contextmanager
+def applied(overlay: Union[Overlay, Mapping[Union[str, Path], Contents]]) -> Iterator[Overlay]:
+    """Make `overlay` the active overlay inside the `with` block, restoring the previous one after."""
+    global ACTIVE
+    previous = ACTIVE
+    ACTIVE = overlay if isinstance(overlay, Overlay) else Overlay(overlay)
+    try:
+        yield ACTIVE
+    finally:
+        ACTIVE = previous
+
+
+__all__ = [
+    "Overlay",
+    "added_under",
+    "applied",
+    "contents",
+    "is_file",
+    "read_bytes",
+    "size",
+]
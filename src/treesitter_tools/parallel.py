@@ -0,0 +1,158 @@
+"""
+Process-pool helpers for parsing many files concurrently with ordered, bounded output.
+
+Worker processes are not assumed to inherit anything: under the spawn and forkserver
+start methods (the defaults on macOS, and on Linux from Python 3.14) they start from a
+fresh interpreter. The run-wide settings the CLI keeps in module globals travel to them
+as a `WorkerSettings` snapshot instead, so `--jobs N` scans exactly as `--jobs 1` does.
+"""
+
+from __future__ import annotations
+
+import signal
+from collections import deque
+from concurrent.futures import Future, ProcessPoolExecutor
+from dataclasses import dataclass, field
+from multiprocessing.context import BaseContext
+from pathlib import Path
+from typing import Callable, Deque, Dict, Iterable, Iterator, List, Optional, Tuple, TypeVar
+
+from . import charsets, core, generated, grammars, ignore, overlay, preproc, querylib, redact, reproducible, schema
+from .core import FileSymbols, scan_file
+from .incremental import IncrementalSession
+
+T = TypeVar("T")
+R = TypeVar("R")
+
+# One session per worker process so the on-disk cache is shared without sharing memory.
+_WORKER_SESSION: Optional[IncrementalSession] = None
+
+# How worker processes are started (a `multiprocessing` context); None uses the platform default.
+MP_CONTEXT: Optional[BaseContext] = None
+
+
+@dataclass
+class WorkerSettings:
+    """
+    The process-wide settings a scan depends on, taken in the parent (`capture`) and
+    restored in each worker (`apply`). Languages registered from Python with a custom
+    `loader` are not carried over; `--grammar-dir` directories are loaded again.
+    """
+
+    encoding: Optional[str] = None  # `charsets.FORCED`
+    overlay: Optional[overlay.Overlay] = None
+    redactor: Optional[redact.Redactor] = None  # without it, workers would emit secrets unmasked
+    preprocessor: bool = False
+    deterministic: bool = False
+    schema_version: Optional[str] = None
+    skip_generated: bool = False
+    skip_vendored: bool = False
+    respect_ignores: bool = True
+    grammar_dirs: List[Path] = field(default_factory=list)
+    query_dirs: List[Path] = field(default_factory=list)
+    language_mappings: Dict[str, str] = field(default_factory=dict)  # with the project config's overrides
+
+    @classmethod
+    def capture(cls) -> "WorkerSettings":
+        return cls(
+            encoding=charsets.FORCED,
+            overlay=overlay.ACTIVE,
+            redactor=redact.ACTIVE,
+            preprocessor=preproc.ENABLED,
+            deterministic=reproducible.ENABLED,
+            schema_version=schema.PINNED,
+            skip_generated=generated.SKIP_GENERATED,
+            skip_vendored=generated.SKIP_VENDORED,
+            respect_ignores=ignore.RESPECT_IGNORES,
+            grammar_dirs=list(grammars.GRAMMAR_DIRS),
+            query_dirs=list(querylib.QUERY_DIRS),
+            language_mappings=dict(core.LANGUAGE_MAPPINGS),
+        )
+
+    def apply(self) -> None:
+        charsets.FORCED = self.encoding
+        overlay.ACTIVE = self.overlay
+        redact.ACTIVE = self.redactor
+        preproc.ENABLED = self.preprocessor
+        reproducible.ENABLED = self.deterministic
+        schema.PINNED = self.schema_version
+        generated.SKIP_GENERATED = self.skip_generated
+        generated.SKIP_VENDORED = self.skip_vendored
+        ignore.RESPECT_IGNORES = self.respect_ignores
+        for directory in self.grammar_dirs:
+            grammars.load_grammar_dir(directory)
+        querylib.QUERY_DIRS[:] = self.query_dirs
+        core.LANGUAGE_MAPPINGS.clear()
+        core.LANGUAGE_MAPPINGS.update(self.language_mappings)
+
+
+def ordered_map(
+    func: Callable[[T], R],
+    items: Iterable[T],
+    jobs: int,
+    max_in_flight: Optional[int] = None,
+    initializer: Optional[Callable[..., None]] = None,
+    initargs: Tuple = (),
+) -> Iterator[R]:
+    """
+    Like `executor.map`, but never holds more than `max_in_flight` pending results.
+
+    Results are yielded in input order, so output stays deterministic regardless of
+    which worker finishes first. The bound keeps memory flat on huge inputs because
+    at most that many parsed-but-unconsumed results exist at a time.
+    """
+    limit = max(max_in_flight or jobs * 2, 1)
+    pending: Deque[Future] = deque()
+    with ProcessPoolExecutor(
+        max_workers=jobs, mp_context=MP_CONTEXT, initializer=initializer, initargs=initargs
+    ) as pool:
+        for item in items:
+            if len(pending) >= limit:
+                yield pending.popleft().result()
+            pending.append(pool.submit(func, item))
+        while pending:
+            yield pending.popleft().result()
+
+
+def _init_worker(settings: WorkerSettings, cache_dir: Optional[str], max_chunk_size: Optional[int]) -> None:
+    global _WORKER_SESSION
+    # Ctrl-C reaches the whole process group; the parent decides how to stop (see `checkpoint.GracefulStop`).
+    signal.signal(signal.SIGINT, signal.SIG_IGN)
+    settings.apply()
+    _WORKER_SESSION = IncrementalSession(cache_dir, max_chunk_size, keep_trees=False) if cache_dir else None
+
+
+def _scan_task(args: Tuple[Path, Optional[int], Optional[int]]) -> Tuple[Optional[FileSymbols], int, int]:
+    path, max_chunk_size, max_file_size = args
+    session = _WORKER_SESSION
+    if session is None:
+        return scan_file(path, max_chunk_size, max_file_size=max_file_size), 0, 0
+    hits, reparsed = session.stats.cache_hits, session.stats.reparsed
+    report = scan_file(path, max_chunk_size, session, max_file_size)
+    return report, session.stats.cache_hits - hits, session.stats.reparsed - reparsed
+
+
+def scan_parallel(
+    paths: Iterable[Path],
+    jobs: int,
+    max_chunk_size: Optional[int] = None,
+    session: Optional[IncrementalSession] = None,
+    max_in_flight: Optional[int] = None,
+    max_file_size: Optional[int] = None,
+) -> Iterator[Optional[FileSymbols]]:
+    """Parallel counterpart of `scan_file` over `paths`; cache stats are folded into `session`."""
+    cache_dir = str(session.cache_dir) if session is not None and session.cache_dir else None
+    if session is not None:
+        max_chunk_size = session.max_chunk_size
+    tasks = ((path, max_chunk_size, max_file_size) for path in paths)
+    initargs = (WorkerSettings.capture(), cache_dir, max_chunk_size)
+    for report, hits, reparsed in ordered_map(
+        _scan_task, tasks, jobs, max_in_flight, initializer=_init_worker, initargs=initargs
+    ):
+        if session is not None:
+            session.stats.cache_hits += hits
+            session.stats.reparsed += reparsed
+        yield report
+
+
+__all__ = ["MP_CONTEXT", "WorkerSettings", "ordered_map", "scan_parallel"]
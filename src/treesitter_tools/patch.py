@@ -0,0 +1,275 @@
+"""
+Apply structured edits (byte range + replacement) to files: the engine behind
+`rewrite --in-place`, `rename --in-place`, and `apply`.
+
+An edit set is JSON, `{"format": 1, "files": [{"path", "sha256", "edits": [...]}]}`,
+where `sha256` is the digest of the content the edits were computed against and each
+edit is `rewrite.Edit.to_dict()`. Several sets (say, a rewrite and a rename) can be
+applied together. Nothing is written unless every file applies cleanly: edits that
+overlap, ranges past the end of a file, and files that changed since the edits were
+computed are conflicts. Writes go to a temporary file next to each target, which then
+replaces it; if a replace fails, the files already replaced are restored.
+"""
+
+from __future__ import annotations
+
+import hashlib
+import json
+import os
+import shutil
+import tempfile
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Callable, Dict, List, Optional, Sequence, Union
+
+from . import charsets
+from .rewrite import Edit, FileRewrite, apply_edits
+
+EDITS_FORMAT = 1
+
+
+def cwd_label(path: Path) -> str:
+    """`path` relative to the working directory, as diffs and edit sets name files."""
+    try:
+        return Path(os.path.relpath(path)).as_posix()
+    except ValueError:
+        return path.as_posix()  # different drive on Windows
+
+
+def _sha256(data: bytes) -> str:
+    return hashlib.sha256(data).hexdigest()
+
+
+@dataclass
+class FileEdits:
+    path: Path
+    edits: List[Edit]
+    sha256: Optional[str] = None  # of the content the edits were computed against; None skips the check
+
+    def to_dict(self, label: Optional[str] = None) -> dict:
+        return {
+            "path": label or self.path.as_posix(),
+            "sha256": self.sha256,
+            "edits": [e.to_dict() for e in self.edits],
+        }
+
+
+@dataclass
+class Conflict:
+    path: Path
+    reason: str  # "overlap", "stale", "out_of_range", or "missing"
+    message: str
+    line: Optional[int] = None
+
+    def __str__(self) -> str:
+        where = f"{cwd_label(self.path)}:{self.line}" if self.line else cwd_label(self.path)
+        return f"{where}: {self.message}"
+
+    def to_dict(self) -> dict:
+        return {"path": cwd_label(self.path), "line": self.line, "reason": self.reason, "message": self.message}
+
+
+@dataclass
+class PatchPlan:
+    files: List[FileRewrite] = field(default_factory=list)
+    conflicts: List[Conflict] = field(default_factory=list)
+
+    @property
+    def edits(self) -> int:
+        return sum(len(f.edits) for f in self.files)
+
+    def diff(self, label: Callable[[Path], str] = cwd_label) -> str:
+        return "".join(f.diff(label(f.path)) for f in self.files)
+
+
+def edit_set(rewrites: Sequence[FileRewrite]) -> List[FileEdits]:
+    """The edits of `rewrite`/`rename` results, pinned to the content they were computed on."""
+    return [FileEdits(r.path, list(r.edits), _sha256(r.original)) for r in rewrites if r.edits]
+
+
+def edits_to_json(files: Sequence[FileEdits], label: Callable[[Path], str] = cwd_label) -> str:
+    return json.dumps({"format": EDITS_FORMAT, "files": [f.to_dict(label(f.path)) for f in files]}, indent=2)
+
+
+def _edit(data: dict, where: str) -> Edit:
+    try:
+        edit = Edit(
+            start_byte=int(data["start_byte"]),
+            end_byte=int(data["end_byte"]),
+            replacement=str(data["replacement"]),
+            start_line=int(data.get("start_line") or 0),
+            end_line=int(data.get("end_line") or 0),
+        )
+    except (KeyError, TypeError, ValueError) as e:
+        raise ValueError(f"{where}: invalid edit ({e})") from None
+    if not 0 <= edit.start_byte <= edit.end_byte:
+        raise ValueError(f"{where}: invalid byte range {edit.start_byte}-{edit.end_byte}")
+    return edit
+
+
+def load_edits(text: Union[str, bytes], base: Optional[Path] = None, source: str = "edits") -> List[FileEdits]:
+    """Parse an edit set; relative paths are resolved against `base` (default: the working directory)."""
+    try:
+        data = json.loads(text)
+    except json.JSONDecodeError as e:
+        raise ValueError(f"{source}: not JSON ({e})") from None
+    if not isinstance(data, dict) or not isinstance(data.get("files"), list):
+        raise ValueError(f"{source}: expected an object with a 'files' list")
+    if data.get("format", EDITS_FORMAT) != EDITS_FORMAT:
+        raise ValueError(f"{source}: unsupported edit set format {data.get('format')!r} (expected {EDITS_FORMAT})")
+    files = []
+    for i, entry in enumerate(data["files"]):
+        where = f"{source}: files[{i}]"
+        if not isinstance(entry, dict) or not isinstance(entry.get("path"), str):
+            raise ValueError(f"{where}: missing 'path'")
+        path = Path(entry["path"])
+        if not path.is_absolute():
+            path = (base or Path.cwd()) / path
+        edits = [_edit(e, f"{where}.edits[{j}]") for j, e in enumerate(entry.get("edits") or [])]
+        files.append(FileEdits(path, edits, entry.get("sha256")))
+    return files
+
+
+def _line(source: bytes, offset: int) -> int:
+    return source.count(b"\n", 0, offset) + 1
+
+
+def _overlaps(path: Path, edits: List[Edit], source: bytes) -> List[Conflict]:
+    """Conflicts between `edits` (sorted by start), each against the furthest-reaching edit before it."""
+    conflicts = []
+    reach: Optional[Edit] = None
+    for edit in edits:
+        if reach is not None:
+            # An insertion where another edit starts has no defined order either.
+            inserts = reach.start_byte == reach.end_byte or edit.start_byte == edit.end_byte
+            if edit.start_byte < reach.end_byte or (edit.start_byte == reach.start_byte and inserts):
+                conflicts.append(Conflict(
+                    path, "overlap",
+                    f"edit at bytes {edit.start_byte}-{edit.end_byte} overlaps the edit at bytes "
+                    f"{reach.start_byte}-{reach.end_byte}",
+                    _line(source, edit.start_byte),
+                ))
+        if reach is None or edit.end_byte > reach.end_byte:
+            reach = edit
+    return conflicts
+
+
+def plan_patch(files: Sequence[FileEdits]) -> PatchPlan:
+    """
+    Merge edit sets per file (dropping exact duplicates), check them against the files
+    on disk, and compute each file's new content. Nothing is written.
+    """
+    merged: Dict[Path, List[FileEdits]] = {}
+    for entry in files:
+        merged.setdefault(entry.path.resolve(), []).append(entry)
+    plan = PatchPlan()
+    for path in sorted(merged):
+        entries = merged[path]
+        try:
+            source = charsets.read_file(path).data
+        except OSError as e:
+            plan.conflicts.append(Conflict(path, "missing", f"cannot read file ({e.strerror or e})"))
+            continue
+        digest = _sha256(source)
+        if any(e.sha256 and e.sha256 != digest for e in entries):
+            plan.conflicts.append(Conflict(path, "stale", "file changed since the edits were computed"))
+            continue
+        unique = {(e.start_byte, e.end_byte, e.replacement): e for entry in entries for e in entry.edits}
+        edits = sorted(unique.values(), key=lambda e: (e.start_byte, e.end_byte))
+        past_end = [e for e in edits if e.end_byte > len(source)]
+        if past_end:
+            plan.conflicts.append(Conflict(
+                path, "out_of_range",
+                f"edit ends at byte {past_end[0].end_byte}, past the end of the file ({len(source)} bytes)",
+            ))
+            continue
+        overlaps = _overlaps(path, edits, source)
+        if overlaps:
+            plan.conflicts.extend(overlaps)
+            continue
+        rewritten = apply_edits(source, edits)
+        if rewritten != source:
+            plan.files.append(FileRewrite(path=path, original=source, rewritten=rewritten, edits=edits))
+    return plan
+
+
+def _write_temp(path: Path, data: bytes) -> str:
+    fd, tmp = tempfile.mkstemp(dir=path.parent, prefix=f".{path.name}.", suffix=".tmp")
+    try:
+        with os.fdopen(fd, "wb") as f:
+            f.write(data)
+            f.flush()
+            os.fsync(f.fileno())
+        shutil.copymode(path, tmp)
+    except BaseException:
+        os.unlink(tmp)
+        raise
+    return tmp
+
+
+def write_files(rewrites: Sequence[FileRewrite]) -> None:
+    """
+    Replace every file's content with `rewritten`, all or nothing. Raises OSError when a
+    file changed since it was read, the new text cannot be written in the file's encoding
+    (contents are UTF-8 and are written back as the file was encoded; see `charsets`),
+    or a write fails, after restoring any file already replaced.
+    """
+    temps: List[str] = []
+    saved: Dict[Path, bytes] = {}
+    try:
+        for rewrite in rewrites:
+            data = saved[rewrite.path] = rewrite.path.read_bytes()
+            decoded = charsets.decode(data)
+            if decoded.data != rewrite.original:
+                raise OSError(f"{rewrite.path} changed while the patch was being applied")
+            try:
+                encoded = charsets.encode(rewrite.rewritten, decoded.encoding, decoded.bom)
+            except ValueError as e:
+                raise OSError(f"Cannot write {rewrite.path}: {e}") from e
+            temps.append(_write_temp(rewrite.path, encoded))
+        for rewrite in rewrites:
+            if rewrite.path.read_bytes() != saved[rewrite.path]:
+                raise OSError(f"{rewrite.path} changed while the patch was being applied")
+        done: List[FileRewrite] = []
+        for rewrite, tmp in zip(rewrites, temps):
+            try:
+                os.replace(tmp, rewrite.path)
+            except OSError as e:
+                failed = []
+                for written in done:
+                    try:
+                        os.replace(_write_temp(written.path, saved[written.path]), written.path)
+                    except OSError:
+                        failed.append(written.path.as_posix())
+                restored = f"restored {len(done) - len(failed)} files already written"
+                if failed:
+                    restored += f"; could not restore {', '.join(failed)}"
+                raise OSError(f"Writing {rewrite.path} failed ({e.strerror or e}); {restored}") from e
+            done.append(rewrite)
+    finally:
+        for tmp in temps:
+            if os.path.exists(tmp):
+                os.unlink(tmp)
+
+
+def apply_patch(files: Sequence[FileEdits], dry_run: bool = False) -> PatchPlan:
+    """Plan `files` and, unless `dry_run` or there are conflicts, write the result atomically."""
+    plan = plan_patch(files)
+    if not dry_run and not plan.conflicts:
+        write_files(plan.files)
+    return plan
+
+
+__all__ = [
+    "Conflict",
+    "EDITS_FORMAT",
+    "FileEdits",
+    "PatchPlan",
+    "apply_patch",
+    "cwd_label",
+    "edit_set",
+    "edits_to_json",
+    "load_edits",
+    "plan_patch",
+    "write_files",
+]
@@ -0,0 +1,100 @@
+"""Human-readable query output: captures with ranges and highlighted source context."""
+
+from __future__ import annotations
+
+from typing import Dict, List, Sequence, Tuple
+
+# Foreground colours cycled across capture names (bold for visibility on dark/light themes).
+_PALETTE = ("\x1b[1;31m", "\x1b[1;32m", "\x1b[1;34m", "\x1b[1;35m", "\x1b[1;36m", "\x1b[1;33m")
+_RESET = "\x1b[0m"
+_DIM = "\x1b[2m"
+
+
+def capture_colors(matches: Sequence[dict]) -> Dict[str, str]:
+    """Stable colour per capture name, in order of first appearance."""
+    colors: Dict[str, str] = {}
+    for match in matches:
+        for cap in match["captures"]:
+            if cap["name"] not in colors:
+                colors[cap["name"]] = _PALETTE[len(colors) % len(_PALETTE)]
+    return colors
+
+
+def _line_spans(cap: dict, lines: List[bytes]) -> List[Tuple[int, int, int]]:
+    """(line index, start col, end col) byte spans a capture covers on each source line."""
+    spans = []
+    first, last = cap["start_line"] - 1, cap["end_line"] - 1
+    for row in range(first, min(last, len(lines) - 1) + 1):
+        start = cap["start_column"] - 1 if row == first else 0
+        end = cap["end_column"] - 1 if row == last else len(lines[row])
+        if end > start or first == last:
+            spans.append((row, start, end))
+    return spans
+
+
+def _decorate(line: bytes, spans: List[Tuple[int, int, str]], highlight: bool) -> Tuple[str, str]:
+    """Source line with capture spans coloured (or an underline row of carets)."""
+    if highlight:
+        out, pos = [], 0
+        for start, end, color in sorted(spans):
+            start = max(start, pos)
+            if end <= start:
+                continue
+            out.append(line[pos:start].decode("utf-8", "replace"))
+            out.append(color + line[start:end].decode("utf-8", "replace") + _RESET)
+            pos = end
+        out.append(line[pos:].decode("utf-8", "replace"))
+        return "".join(out), ""
+    text = line.decode("utf-8", "replace")
+    underline, pos = [], 0
+    for ch in text:
+        covered = any(start <= pos < max(end, start + 1) for start, end, _ in spans)
+        underline.append("^" if covered else " ")
+        pos += len(ch.encode("utf-8"))
+    return text, "".join(underline).rstrip()
+
+
+def render_matches(matches: Sequence[dict], source: bytes, highlight: bool = False, context: int = 0) -> str:
+    """
+    One block per match: each capture with its node type, line:column range, and byte
+    range, followed by the matched source lines (plus `context` lines either side)
+    with captures coloured (`highlight`) or underlined with `^`.
+    """
+    lines = source.split(b"\n")
+    if len(lines) > 1 and lines[-1] == b"":
+        lines.pop()
+    colors = capture_colors(matches)
+    width = len(str(len(lines)))
+    blocks = []
+    for number, match in enumerate(matches, 1):
+        out = [f"match {number} (pattern {match['pattern_index']})"]
+        per_line: Dict[int, List[Tuple[int, int, str]]] = {}
+        for cap in match["captures"]:
+            label = f"@{cap['name']}"
+            if highlight:
+                label = colors[cap["name"]] + label + _RESET
+            text = cap["text"] if "\n" not in cap["text"] else cap["text"].split("\n", 1)[0] + " ..."
+            out.append(
+                f"  {label} {cap['type']} {cap['start_line']}:{cap['start_column']}-"
+                f"{cap['end_line']}:{cap['end_column']} bytes {cap['start_byte']}-{cap['end_byte']} {text!r}"
+            )
+            for row, start, end in _line_spans(cap, lines):
+                per_line.setdefault(row, []).append((start, end, colors[cap["name"]]))
+        if per_line:
+            first = max(min(per_line) - context, 0)
+            last = min(max(per_line) + context, len(lines) - 1)
+            for row in range(first, last + 1):
+                code, underline = _decorate(lines[row], per_line.get(row, []), highlight)
+                gutter = f"{row + 1:>{width}} | "
+                if highlight and row not in per_line:
+                    out.append(f"{_DIM}{gutter}{_RESET}{code}")
+                else:
+                    out.append(f"{gutter}{code}")
+                if underline.strip():
+                    out.append(" " * width + " | " + underline)
+        blocks.append("\n".join(out))
+    summary = f"{len(matches)} match{'es' if len(matches) != 1 else ''}"
+    return "\n\n".join(blocks + [summary]) + "\n"
+
+
+__all__ = ["capture_colors", "render_matches"]
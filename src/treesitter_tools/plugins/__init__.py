@@ -0,0 +1,31 @@
+"""
+Custom analyzers: third-party checks that receive parsed trees and emit findings.
+
+Python analyzers subclass `Analyzer` and are loaded from `module:attr` targets or
+from installed packages' `treesitter_tools.analyzers` entry points; analyzers in
+any other language run as subprocesses speaking JSON-RPC (see `rpc`).
+"""
+
+from .base import LEVELS, Analyzer, Finding, Package, PluginError, RunInfo
+from .loader import ENTRY_POINT_GROUP, command_analyzer, entry_point_analyzers, import_analyzer
+from .rpc import PROTOCOL_VERSION, SubprocessAnalyzer
+from .runner import FAILING_LEVELS, AnalysisReport, AnalyzerError, run_analyzers
+
+__all__ = [
+    "ENTRY_POINT_GROUP",
+    "FAILING_LEVELS",
+    "LEVELS",
+    "PROTOCOL_VERSION",
+    "AnalysisReport",
+    "Analyzer",
+    "AnalyzerError",
+    "Finding",
+    "Package",
+    "PluginError",
+    "RunInfo",
+    "SubprocessAnalyzer",
+    "command_analyzer",
+    "entry_point_analyzers",
+    "import_analyzer",
+    "run_analyzers",
+]
@@ -0,0 +1,142 @@
+"""The analyzer interface: lifecycle hooks that receive parsed trees and return findings."""
+
+from __future__ import annotations
+
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Collection, Dict, Iterable, List, Optional, Tuple
+
+from tree_sitter import Node
+
+from ..core import ParsedFile
+
+LEVELS = ("error", "warning", "note")
+
+
+class PluginError(RuntimeError):
+    """An analyzer could not be loaded, or broke its protocol."""
+
+
+@dataclass
+class Finding:
+    rule: str  # analyzer-defined id, e.g. "no-print"
+    message: str
+    path: str  # label of the file, as in the other commands' output
+    line: int
+    column: Optional[int] = None
+    end_line: Optional[int] = None
+    end_column: Optional[int] = None
+    level: str = "warning"  # one of LEVELS
+    properties: Dict[str, object] = field(default_factory=dict)
+    analyzer: Optional[str] = None  # filled in by the runner
+
+    @classmethod
+    def at(cls, node: Node, path: str, rule: str, message: str, level: str = "warning", **properties) -> "Finding":
+        """A finding covering `node`, with 1-based lines and columns."""
+        return cls(
+            rule=rule,
+            message=message,
+            path=path,
+            line=node.start_point[0] + 1,
+            column=node.start_point[1] + 1,
+            end_line=node.end_point[0] + 1,
+            end_column=node.end_point[1] + 1,
+            level=level,
+            properties=dict(properties),
+        )
+
+    @classmethod
+    def from_dict(cls, data: dict, default_path: Optional[str] = None) -> "Finding":
+        """Validate a finding sent by a subprocess analyzer (see `rpc`)."""
+        if not isinstance(data, dict):
+            raise ValueError("finding must be an object")
+        rule, message, path = data.get("rule"), data.get("message"), data.get("path", default_path)
+        if not isinstance(rule, str) or not rule or not isinstance(message, str) or not isinstance(path, str):
+            raise ValueError("finding needs string 'rule', 'message', and 'path'")
+        level = data.get("level", "warning")
+        if level not in LEVELS:
+            raise ValueError(f"finding level must be one of {', '.join(LEVELS)}, not {level!r}")
+        numbers = {}
+        for key in ("line", "column", "end_line", "end_column"):
+            value = data.get(key, 1 if key == "line" else None)
+            if value is not None and (isinstance(value, bool) or not isinstance(value, int) or value < 1):
+                raise ValueError(f"finding '{key}' must be a positive integer")
+            numbers[key] = value
+        properties = data.get("properties") or {}
+        if not isinstance(properties, dict):
+            raise ValueError("finding 'properties' must be an object")
+        return cls(rule=rule, message=message, path=path, level=level, properties=properties, **numbers)
+
+    def to_dict(self) -> dict:
+        data = {
+            "analyzer": self.analyzer,
+            "rule": self.rule,
+            "level": self.level,
+            "message": self.message,
+            "path": self.path,
+            "line": self.line,
+            "column": self.column,
+            "end_line": self.end_line,
+            "end_column": self.end_column,
+        }
+        if self.properties:
+            data["properties"] = dict(self.properties)
+        return data
+
+    def to_text(self) -> str:
+        where = f"{self.path}:{self.line}" + (f":{self.column}" if self.column else "")
+        return f"{where}: {self.level}: {self.message} [{self.analyzer}/{self.rule}]"
+
+
+@dataclass
+class Package:
+    """The files of one directory, passed to `Analyzer.check_package` after each was checked."""
+
+    name: str  # directory label, "." for the analysed root
+    files: List[Tuple[str, ParsedFile]] = field(default_factory=list)  # (label, parsed file)
+
+
+@dataclass(frozen=True)
+class RunInfo:
+    root: Path  # directory labels are relative to (the current directory for file arguments)
+    paths: Tuple[str, ...]  # every file that will be analysed, by label
+
+
+class Analyzer:
+    """
+    Base class for analyzers. Each hook returns (or yields) findings; all are
+    optional. For one run the hooks are called in this order:
+
+    - `start` once, before any file is parsed;
+    - `check_file` for each file in a directory, then `check_package` for that directory;
+    - `finish` once, after the last directory.
+
+    `name` prefixes the analyzer's rules in output; `rules` maps rule ids to one-line
+    descriptions for SARIF; `languages`, when set, limits `check_file` and the files
+    of each `Package` to those languages.
+    """
+
+    name: str = ""
+    rules: Dict[str, str] = {}
+    languages: Optional[Collection[str]] = None
+
+    def start(self, run: RunInfo) -> Iterable[Finding]:
+        return ()
+
+    def check_file(self, path: str, parsed: ParsedFile) -> Iterable[Finding]:
+        return ()
+
+    def check_package(self, package: Package) -> Iterable[Finding]:
+        return ()
+
+    def finish(self) -> Iterable[Finding]:
+        return ()
+
+    def close(self) -> None:
+        """Release resources (subprocesses); called once the run is over, even after errors."""
+
+    def accepts(self, language: str) -> bool:
+        return self.languages is None or language in self.languages
+
+
+__all__ = ["LEVELS", "Analyzer", "Finding", "Package", "PluginError", "RunInfo"]
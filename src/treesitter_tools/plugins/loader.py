@@ -0,0 +1,92 @@
+"""Finding analyzers: `module:attr` targets, installed entry points, and subprocess commands."""
+
+from __future__ import annotations
+
+import importlib
+import shlex
+import sys
+from importlib.metadata import entry_points
+from pathlib import Path
+from typing import Any, Dict, List, Optional, Sequence, Union
+
+from .base import Analyzer, PluginError
+from .rpc import SubprocessAnalyzer
+
+# Packages register analyzers under this entry-point group, e.g. in pyproject.toml:
+#   [project.entry-points."treesitter_tools.analyzers"]
+#   acme = "acme_checks:AcmeAnalyzer"
+ENTRY_POINT_GROUP = "treesitter_tools.analyzers"
+
+
+def _instantiate(target: Any, label: str, options: Optional[Dict[str, object]], default_name: str) -> Analyzer:
+    """An `Analyzer` instance from a class, a factory, or an instance; unnamed ones get `default_name`."""
+    if isinstance(target, Analyzer):
+        if options:
+            raise PluginError(f"{label}: options need an analyzer class or factory, not an instance")
+        analyzer = target
+    elif callable(target):
+        try:
+            analyzer = target(**(options or {}))
+        except TypeError as exc:
+            raise PluginError(f"{label}: {exc}") from exc
+    else:
+        analyzer = target
+    if not isinstance(analyzer, Analyzer):
+        raise PluginError(f"{label}: not a treesitter_tools.plugins.Analyzer ({type(analyzer).__name__})")
+    if not analyzer.name:
+        analyzer.name = default_name
+    return analyzer
+
+
+def import_analyzer(
+    target: str, options: Optional[Dict[str, object]] = None, search_path: Optional[Path] = None
+) -> Analyzer:
+    """
+    Load `package.module:Attribute`. `search_path` (the config file's directory) is
+    importable while the module loads, so checks can live in the repository itself.
+    """
+    module_name, _, attribute = target.partition(":")
+    if not module_name or not attribute:
+        raise PluginError(f"{target}: expected 'module:attribute'")
+    added = search_path is not None and str(search_path) not in sys.path
+    if added:
+        sys.path.insert(0, str(search_path))
+    try:
+        module = importlib.import_module(module_name)
+    except ImportError as exc:
+        raise PluginError(f"{target}: cannot import {module_name}: {exc}") from exc
+    finally:
+        if added:
+            sys.path.remove(str(search_path))
+    obj: Any = module
+    for part in attribute.split("."):
+        if not hasattr(obj, part):
+            raise PluginError(f"{target}: {module_name} has no attribute {attribute!r}")
+        obj = getattr(obj, part)
+    return _instantiate(obj, target, options, attribute.rsplit(".", 1)[-1])
+
+
+def command_analyzer(
+    command: Union[str, Sequence[str]],
+    name: Optional[str] = None,
+    options: Optional[Dict[str, object]] = None,
+    cwd: Optional[Path] = None,
+) -> SubprocessAnalyzer:
+    """A subprocess analyzer; a string command is split like a shell would."""
+    argv = shlex.split(command) if isinstance(command, str) else list(command)
+    return SubprocessAnalyzer(argv, name, options, cwd)
+
+
+def entry_point_analyzers() -> List[Analyzer]:
+    """One analyzer per installed `treesitter_tools.analyzers` entry point, by entry-point name."""
+    analyzers = []
+    for entry in sorted(entry_points(group=ENTRY_POINT_GROUP), key=lambda e: e.name):
+        try:
+            target = entry.load()
+        except Exception as exc:  # a broken plugin package must not look like our bug
+            raise PluginError(f"{entry.name}: cannot load {entry.value}: {exc}") from exc
+        analyzers.append(_instantiate(target, entry.value, None, entry.name))
+    return analyzers
+
+
+__all__ = ["ENTRY_POINT_GROUP", "command_analyzer", "entry_point_analyzers", "import_analyzer"]
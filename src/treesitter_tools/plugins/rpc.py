@@ -0,0 +1,169 @@
+"""
+Analyzers in other languages: a subprocess speaking JSON-RPC 2.0 over stdin/stdout,
+one JSON message per line.
+
+The tool sends `initialize`, then `file` and `package` for each directory, then
+`finish` and `shutdown`, waiting for each response. Every response's result can
+carry `findings`, a list of finding objects (see README "Analyzer Plugins"). The
+process's stderr is passed through, so it can log freely there.
+"""
+
+from __future__ import annotations
+
+import json
+import subprocess
+import threading
+from pathlib import Path
+from queue import Empty, Queue
+from typing import Any, Dict, Iterable, List, Optional, Sequence
+
+from .. import __version__
+from ..astdump import tree_to_dict
+from ..core import ParsedFile
+from .base import Analyzer, Finding, Package, PluginError, RunInfo
+
+PROTOCOL_VERSION = 1
+
+# Seconds to wait for any one response before the analyzer is considered hung.
+DEFAULT_TIMEOUT = 60.0
+
+
+class SubprocessAnalyzer(Analyzer):
+    def __init__(
+        self,
+        command: Sequence[str],
+        name: Optional[str] = None,
+        options: Optional[Dict[str, object]] = None,
+        cwd: Optional[Path] = None,
+        timeout: float = DEFAULT_TIMEOUT,
+    ):
+        if not command:
+            raise PluginError("analyzer command is empty")
+        self.command = list(command)
+        # `python checks.py --strict` is "checks" until the analyzer names itself
+        self.name = name or Path(next((a for a in reversed(self.command) if not a.startswith("-")), self.command[0])).stem
+        self.options = dict(options or {})
+        self.cwd = cwd
+        self.timeout = timeout
+        self.rules = {}
+        self.send_tree = False  # set by the analyzer's `initialize` result
+        self._process: Optional[subprocess.Popen] = None
+        self._lines: "Queue[Optional[str]]" = Queue()
+        self._next_id = 0
+
+    def _launch(self) -> None:
+        try:
+            self._process = subprocess.Popen(
+                self.command,
+                cwd=self.cwd,
+                stdin=subprocess.PIPE,
+                stdout=subprocess.PIPE,
+                text=True,
+                encoding="utf-8",
+                bufsize=1,
+            )
+        except OSError as exc:
+            raise PluginError(f"cannot start {' '.join(self.command)}: {exc}") from exc
+        threading.Thread(target=self._read, args=(self._process.stdout,), daemon=True).start()
+
+    def _read(self, stream) -> None:
+        for line in stream:
+            self._lines.put(line)
+        self._lines.put(None)  # end of output
+
+    def _call(self, method: str, params: dict) -> Any:
+        process = self._process
+        if process is None or process.poll() is not None:
+            raise PluginError(f"not running (exit status {process.returncode if process else None})")
+        self._next_id += 1
+        request = {"jsonrpc": "2.0", "id": self._next_id, "method": method, "params": params}
+        try:
+            process.stdin.write(json.dumps(request) + "\n")
+            process.stdin.flush()
+        except OSError as exc:
+            raise PluginError(f"{method}: cannot write request: {exc}") from exc
+        while True:
+            try:
+                line = self._lines.get(timeout=self.timeout)
+            except Empty:
+                raise PluginError(f"{method}: no response within {self.timeout:g}s") from None
+            if line is None:
+                raise PluginError(f"exited during {method} (status {process.wait()})")
+            if not line.strip():
+                continue
+            try:
+                message = json.loads(line)
+            except json.JSONDecodeError as exc:
+                raise PluginError(f"{method}: invalid JSON on stdout: {exc}") from exc
+            if not isinstance(message, dict) or message.get("id") != self._next_id:
+                continue  # a notification, or a stale response
+            if "error" in message:
+                error = message["error"]
+                text = error.get("message", error) if isinstance(error, dict) else error
+                raise PluginError(f"{method} failed: {text}")
+            return message.get("result")
+
+    def _findings(self, method: str, result: Any, default_path: Optional[str] = None) -> List[Finding]:
+        if result is None:
+            return []
+        if not isinstance(result, dict):
+            raise PluginError(f"{method}: result must be an object")
+        try:
+            return [Finding.from_dict(item, default_path) for item in result.get("findings") or []]
+        except ValueError as exc:
+            raise PluginError(f"{method}: {exc}") from exc
+
+    def start(self, run: RunInfo) -> Iterable[Finding]:
+        self._launch()
+        result = self._call(
+            "initialize",
+            {
+                "protocol": PROTOCOL_VERSION,
+                "tool": {"name": "treesitter-tools", "version": __version__},
+                "root": run.root.as_posix(),
+                "paths": list(run.paths),
+                "options": self.options,
+            },
+        )
+        if isinstance(result, dict):
+            self.name = result.get("name") or self.name
+            if isinstance(result.get("rules"), dict):
+                self.rules = {str(k): str(v) for k, v in result["rules"].items()}
+            if isinstance(result.get("languages"), list):
+                self.languages = set(result["languages"])
+            self.send_tree = bool(result.get("tree"))
+        return self._findings("initialize", result)
+
+    def check_file(self, path: str, parsed: ParsedFile) -> Iterable[Finding]:
+        params = {"path": path, "language": parsed.language, "source": parsed.source.decode("utf-8", "replace")}
+        if self.send_tree:
+            params["tree"] = tree_to_dict(parsed, path)
+        return self._findings("file", self._call("file", params), path)
+
+    def check_package(self, package: Package) -> Iterable[Finding]:
+        files = [{"path": label, "language": parsed.language} for label, parsed in package.files]
+        return self._findings("package", self._call("package", {"name": package.name, "files": files}))
+
+    def finish(self) -> Iterable[Finding]:
+        return self._findings("finish", self._call("finish", {}))
+
+    def close(self) -> None:
+        process = self._process
+        if process is None:
+            return
+        try:
+            if process.poll() is None:
+                try:
+                    self._call("shutdown", {})
+                except PluginError:
+                    pass  # exiting is all that is left to ask of it
+            process.stdin.close()
+            process.wait(timeout=self.timeout)
+        except (OSError, subprocess.TimeoutExpired):
+            process.kill()
+            process.wait()
+        finally:
+            self._process = None
+
+
+__all__ = ["DEFAULT_TIMEOUT", "PROTOCOL_VERSION", "SubprocessAnalyzer"]
@@ -0,0 +1,176 @@
+"""Drive analyzers over files and directories and collect their findings."""
+
+from __future__ import annotations
+
+import json
+from dataclasses import dataclass, field
+from pathlib import Path, PurePosixPath
+from typing import Callable, Dict, Iterable, List, Optional, Sequence, Tuple
+
+from .. import failures
+from ..core import ParsedFile, iter_source_files, parse_file
+from ..export.sarif import SarifLocation, SarifResult, SarifRule, sarif_to_json
+from .base import Analyzer, Finding, Package, PluginError, RunInfo
+
+# Levels that make `analyze` exit 1 (notes are informational).
+FAILING_LEVELS = {"error", "warning"}
+
+
+@dataclass
+class AnalyzerError:
+    analyzer: str
+    message: str
+    path: Optional[str] = None  # the file being checked, when the failure was per-file
+
+    def to_dict(self) -> dict:
+        return {"analyzer": self.analyzer, "path": self.path, "message": self.message}
+
+    def to_text(self) -> str:
+        return f"{self.analyzer}: {self.path + ': ' if self.path else ''}{self.message}"
+
+
+@dataclass
+class AnalysisReport:
+    analyzers: List[Analyzer] = field(default_factory=list)
+    checked: int = 0
+    findings: List[Finding] = field(default_factory=list)
+    errors: List[AnalyzerError] = field(default_factory=list)
+
+    @property
+    def failed(self) -> bool:
+        return any(f.level in FAILING_LEVELS for f in self.findings)
+
+    def to_json(self) -> str:
+        return json.dumps(
+            {
+                "analyzers": [a.name for a in self.analyzers],
+                "checked": self.checked,
+                "findings": [f.to_dict() for f in self.findings],
+                "errors": [e.to_dict() for e in self.errors],
+            },
+            indent=2,
+        )
+
+    def to_text(self) -> str:
+        return "".join(f.to_text() + "\n" for f in self.findings)
+
+    def to_sarif(self, root: Optional[Path] = None) -> str:
+        described = {a.name: a.rules for a in self.analyzers}
+        rules: Dict[str, SarifRule] = {}
+        results = []
+        for f in self.findings:
+            rule_id = f"{f.analyzer}/{f.rule}"
+            if rule_id not in rules:
+                name = "".join(part.capitalize() for part in f.rule.replace("_", "-").split("-") if part)
+                description = described.get(f.analyzer, {}).get(f.rule) or f.rule
+                rules[rule_id] = SarifRule(rule_id, name or f.rule, description, tags=("plugin", f.analyzer))
+            location = SarifLocation(f.path, f.line, f.column, f.end_line, f.end_column)
+            results.append(SarifResult(rules[rule_id], f.message, location, (f.message,), f.level, properties=f.properties))
+        return sarif_to_json(results, list(rules.values()), root)
+
+    def summary(self) -> str:
+        text = (
+            f"{len(self.analyzers)} analyzers checked {self.checked} files: "
+            f"{len(self.findings)} findings"
+        )
+        if self.errors:
+            text += f", {len(self.errors)} analyzer errors"
+        return text
+
+
+def _targets(
+    paths: Sequence[Path], include: Sequence[str] | None, exclude: Sequence[str] | None
+) -> List[Tuple[Path, str, bool]]:
+    """(file, label, from a directory walk) for every file argument and walked file."""
+    targets = []
+    for path in paths:
+        path = Path(path)
+        if path.is_dir():
+            base = path.resolve()
+            targets.extend((p, (path / p.relative_to(base)).as_posix(), True) for p in iter_source_files(base, include, exclude))
+        else:
+            targets.append((path, path.as_posix(), False))
+    return targets
+
+
+def _package_name(label: str) -> str:
+    return PurePosixPath(label).parent.as_posix()
+
+
+class _Run:
+    """Calls hooks, recording failures; an analyzer that breaks its protocol is dropped."""
+
+    def __init__(self, report: AnalysisReport):
+        self.report = report
+        self.active: List[Analyzer] = list(report.analyzers)
+
+    def call(self, analyzer: Analyzer, hook: Callable[[], Optional[Iterable[Finding]]], path: Optional[str] = None) -> None:
+        try:
+            findings = list(hook() or ())
+        except PluginError as exc:
+            self.report.errors.append(AnalyzerError(analyzer.name, str(exc), path))
+            self.active.remove(analyzer)
+            analyzer.close()
+            return
+        except Exception as exc:  # a failing check must not abort the others
+            self.report.errors.append(AnalyzerError(analyzer.name, f"{type(exc).__name__}: {exc}", path))
+            return
+        for finding in findings:
+            finding.analyzer = analyzer.name
+        self.report.findings.extend(findings)
+
+
+def run_analyzers(
+    paths: Sequence[Path],
+    analyzers: Sequence[Analyzer],
+    include: Sequence[str] | None = None,
+    exclude: Sequence[str] | None = None,
+    language: Optional[str] = None,
+    max_file_size: Optional[int] = None,
+    root: Optional[Path] = None,
+) -> AnalysisReport:
+    """
+    Run `analyzers` over files and directories: `start`, then each directory's files
+    (`check_file`) followed by the directory itself (`check_package`), then `finish`.
+    Files of unknown languages and unparseable files are skipped. Analyzers are
+    closed when the run ends.
+    """
+    report = AnalysisReport(analyzers=list(analyzers))
+    targets = _targets(paths, include, exclude)
+    packages: Dict[str, List[Tuple[Path, str, bool]]] = {}
+    for target in targets:
+        packages.setdefault(_package_name(target[1]), []).append(target)
+    run = _Run(report)
+    info = RunInfo(Path(root) if root is not None else Path.cwd(), tuple(label for _, label, _ in targets))
+    try:
+        for analyzer in list(run.active):
+            run.call(analyzer, lambda: analyzer.start(info))
+        for name in sorted(packages):
+            files: List[Tuple[str, ParsedFile]] = []
+            for path, label, walked in packages[name]:
+                try:
+                    parsed = parse_file(path, None if walked else language, max_file_size)
+                except (ValueError, RuntimeError, OSError) as exc:
+                    failures.record(path, exc)
+                    continue
+                report.checked += 1
+                files.append((label, parsed))
+                for analyzer in list(run.active):
+                    if analyzer.accepts(parsed.language):
+                        run.call(analyzer, lambda: analyzer.check_file(label, parsed), label)
+            if not files:
+                continue
+            for analyzer in list(run.active):
+                package = Package(name, [(label, p) for label, p in files if analyzer.accepts(p.language)])
+                if package.files:
+                    run.call(analyzer, lambda: analyzer.check_package(package))
+            del files  # trees of one directory at a time
+        for analyzer in list(run.active):
+            run.call(analyzer, analyzer.finish)
+    finally:
+        for analyzer in report.analyzers:
+            analyzer.close()
+    return report
+
+
+__all__ = ["FAILING_LEVELS", "AnalysisReport", "AnalyzerError", "run_analyzers"]
@@ -0,0 +1,212 @@
+"""
+Position conversions between byte offsets (what Tree-sitter and the JSON output use),
+code-point ("rune") offsets, UTF-16 code units (LSP's default), and line/column pairs.
+
+- Lines and characters are zero-based. Lines end at `\\n`; a `\\r` right before it
+  belongs to the line break, so CRLF files give the same line numbers as the JSON
+  output and columns never count the `\\r`. A lone `\\r` is not a line break (as in
+  Tree-sitter rows).
+- A leading UTF-8 byte-order mark is not text: characters on the first line are
+  counted from after it, as editors count them, while byte offsets and Tree-sitter
+  points still include it.
+- Offsets inside a multi-byte character snap back to its first byte; positions past a
+  line's end clamp to it. Invalid UTF-8 bytes count as one character each.
+
+Units are named as LSP's `PositionEncodingKind`: "utf-8" (bytes), "utf-16" (code
+units), and "utf-32" (code points); "byte", "rune", and "codepoint" are accepted too.
+"""
+
+from __future__ import annotations
+
+from bisect import bisect_right
+from dataclasses import dataclass
+from typing import List, Tuple
+
+BOM = b"\xef\xbb\xbf"
+UNITS = ("utf-8", "utf-16", "utf-32")
+_ALIASES = {
+    "utf8": "utf-8", "byte": "utf-8", "bytes": "utf-8",
+    "utf16": "utf-16",
+    "utf32": "utf-32", "rune": "utf-32", "runes": "utf-32", "codepoint": "utf-32", "codepoints": "utf-32",
+}
+
+
+def normalize_unit(unit: str) -> str:
+    """The canonical name of `unit`; ValueError for unknown units."""
+    name = unit.lower()
+    name = _ALIASES.get(name, name)
+    if name not in UNITS:
+        raise ValueError(f"Unsupported unit '{unit}' (expected {', '.join(UNITS)})")
+    return name
+
+
+def _decode(text: bytes) -> str:
+    # surrogateescape keeps one character per invalid byte and round-trips exactly.
+    return text.decode("utf-8", "surrogateescape")
+
+
+def _width(char: str, unit: str) -> int:
+    if unit == "utf-8":
+        return len(char.encode("utf-8", "surrogateescape"))
+    if unit == "utf-16":
+        return 2 if ord(char) > 0xFFFF else 1
+    return 1
+
+
+def units(text: bytes, unit: str = "utf-16") -> int:
+    """Length of UTF-8 `text` in `unit`s."""
+    unit = normalize_unit(unit)
+    if unit == "utf-8":
+        return len(text)
+    decoded = _decode(text)
+    if unit == "utf-32":
+        return len(decoded)
+    return len(decoded) + sum(1 for char in decoded if ord(char) > 0xFFFF)
+
+
+def _char_start(source: bytes, offset: int) -> int:
+    """`offset`, moved back to the first byte of the character it falls in."""
+    start = offset
+    while start > 0 and offset - start < 3 and start < len(source) and 0x80 <= source[start] < 0xC0:
+        start -= 1
+    return start if start < len(source) and source[start] >= 0xC0 else offset
+
+
+def _advance(text: bytes, amount: int, unit: str) -> int:
+    """Bytes of `text` covering its first `amount` units, stopping before a character that would overshoot."""
+    if unit == "utf-8":
+        return _char_start(text, min(max(amount, 0), len(text)))
+    count = consumed = 0
+    for char in _decode(text):
+        width = _width(char, unit)
+        if count + width > amount:
+            break
+        count += width
+        consumed += _width(char, "utf-8")
+    return consumed
+
+
+@dataclass(frozen=True)
+class Position:
+    line: int
+    character: int
+
+    def to_dict(self) -> dict:
+        return {"line": self.line, "character": self.character}
+
+
+class LineIndex:
+    """Line table of one source text; build once and convert many positions against it."""
+
+    def __init__(self, source: bytes):
+        self.source = bytes(source)
+        self.bom = len(BOM) if self.source.startswith(BOM) else 0
+        self.line_starts: List[int] = [0]
+        newline = self.source.find(b"\n")
+        while newline >= 0:
+            self.line_starts.append(newline + 1)
+            newline = self.source.find(b"\n", newline + 1)
+
+    @property
+    def line_count(self) -> int:
+        return len(self.line_starts)
+
+    def line_start(self, line: int) -> int:
+        """Byte offset where `line`'s text starts (after the BOM on the first line)."""
+        return self.bom if line == 0 else self.line_starts[line]
+
+    def line_end(self, line: int) -> int:
+        """Byte offset where `line`'s text ends, before its `\\n` or `\\r\\n`."""
+        end = self.line_starts[line + 1] - 1 if line + 1 < self.line_count else len(self.source)
+        if end > self.line_start(line) and self.source[end - 1 : end] == b"\r" and end < len(self.source):
+            end -= 1
+        return end
+
+    def line_text(self, line: int) -> bytes:
+        return self.source[self.line_start(line) : self.line_end(line)]
+
+    def line_of(self, offset: int) -> int:
+        """The line holding byte `offset` (clamped to the text)."""
+        return bisect_right(self.line_starts, min(max(offset, 0), len(self.source))) - 1
+
+    # -- byte offsets <-> line/character ------------------------------------
+
+    def position(self, offset: int, unit: str = "utf-16") -> Position:
+        """Line and character of byte `offset`."""
+        unit = normalize_unit(unit)
+        offset = _char_start(self.source, min(max(offset, 0), len(self.source)))
+        line = self.line_of(offset)
+        start, end = self.line_start(line), self.line_end(line)
+        return Position(line, units(self.source[start : min(max(offset, start), end)], unit))
+
+    def offset(self, line: int, character: int, unit: str = "utf-16") -> int:
+        """Byte offset of a line/character position."""
+        unit = normalize_unit(unit)
+        if line < 0:
+            return 0
+        if line >= self.line_count:
+            return len(self.source)
+        start = self.line_start(line)
+        return start + _advance(self.line_text(line), character, unit)
+
+    def convert(self, line: int, character: int, from_unit: str, to_unit: str) -> Position:
+        """The same position with its character counted in another unit."""
+        return self.position(self.offset(line, character, from_unit), to_unit)
+
+    # -- Tree-sitter points -------------------------------------------------
+
+    def point(self, offset: int) -> Tuple[int, int]:
+        """Tree-sitter (row, byte column) of byte `offset`: the raw line start, BOM included."""
+        offset = min(max(offset, 0), len(self.source))
+        line = self.line_of(offset)
+        return line, offset - self.line_starts[line]
+
+    def point_offset(self, row: int, column: int) -> int:
+        """Byte offset of a Tree-sitter point."""
+        if row < 0:
+            return 0
+        if row >= self.line_count:
+            return len(self.source)
+        return min(self.line_starts[row] + max(column, 0), len(self.source))
+
+    # -- whole-text offsets -------------------------------------------------
+
+    def from_byte(self, offset: int, unit: str = "utf-32") -> int:
+        """
+        Byte `offset` as an offset into the decoded text: a Python string index for
+        "utf-32", a JavaScript string index for "utf-16" (both without the BOM).
+        """
+        unit = normalize_unit(unit)
+        if unit == "utf-8":
+            return min(max(offset, 0), len(self.source))
+        offset = _char_start(self.source, min(max(offset, 0), len(self.source)))
+        return units(self.source[self.bom : max(offset, self.bom)], unit)
+
+    def to_byte(self, offset: int, unit: str = "utf-32") -> int:
+        """Byte offset of a decoded-text offset (the inverse of `from_byte`)."""
+        unit = normalize_unit(unit)
+        if unit == "utf-8":
+            return min(max(offset, 0), len(self.source))
+        return self.bom + _advance(self.source[self.bom :], offset, unit)
+
+
+def position_at(source: bytes, offset: int, unit: str = "utf-16") -> Position:
+    """One-off `LineIndex(source).position(offset, unit)`."""
+    return LineIndex(source).position(offset, unit)
+
+
+def offset_at(source: bytes, line: int, character: int, unit: str = "utf-16") -> int:
+    """One-off `LineIndex(source).offset(line, character, unit)`."""
+    return LineIndex(source).offset(line, character, unit)
+
+
+__all__ = [
+    "BOM",
+    "UNITS",
+    "LineIndex",
+    "Position",
+    "normalize_unit",
+    "offset_at",
+    "position_at",
+    "units",
+]
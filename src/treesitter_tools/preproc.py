@@ -0,0 +1,257 @@
+"""
+Best-effort symbols of C/C++ code full of `#if`/`#ifdef` conditionals.
+
+Tree-sitter sees the preprocessor conditionals of a file but not which branch a build
+takes, so code whose branches split a declaration (two alternative function heads
+sharing one body, an `extern "C" {` opened under `#ifdef __cplusplus`) comes out as
+ERROR nodes. With `ENABLED` (the global `--preprocessor` flag), a file with
+conditionals is parsed once per branch index instead: variant `k` keeps the `k`-th
+branch of every conditional (the last one of shorter conditionals) and blanks the
+other branches and every conditional directive, so each variant is plain C and all
+branches of the file are seen. The variants' symbols are merged by position, and
+each one guarded by a conditional gets its `condition`, written as C: `defined(_WIN32)`,
+`!defined(_WIN32) && HAVE_EPOLL`. An include guard around the whole file is not a
+condition.
+
+Blanking keeps every byte offset and line number, and symbol content is read back
+from the original source, so a function whose head depends on a branch still shows
+every branch of its text.
+"""
+
+from __future__ import annotations
+
+import re
+from dataclasses import dataclass, field
+from typing import Dict, List, Optional, Tuple
+
+LANGUAGES = ("c", "cpp", "objc")
+
+# Set by the CLI's global `--preprocessor` flag; consulted by `core.symbols_from_tree`.
+ENABLED = False
+
+_DIRECTIVE = re.compile(rb"^\s*#\s*(ifdef|ifndef|if|elifdef|elifndef|elif|else|endif)\b(.*)$", re.S)
+_DEFINE = re.compile(rb"^\s*#\s*define\s+(\w+)\s*$")
+_COMMENT = re.compile(r"/\*.*?\*/|//.*$")
+
+
+@dataclass
+class Branch:
+    condition: Optional[str]  # None for the body of an include guard
+    start_line: int  # first and last line of the branch's code (1-based, directives excluded)
+    end_line: int
+    children: List["Conditional"] = field(default_factory=list)
+
+
+@dataclass
+class Conditional:
+    """One `#if ... #endif`: its branches in order, and the lines of its directives."""
+
+    branches: List[Branch] = field(default_factory=list)
+    directives: List[Tuple[int, int]] = field(default_factory=list)
+
+
+def _logical_lines(lines: List[bytes]) -> List[Tuple[int, int, bytes]]:
+    """(first line, last line, text) of each line with its backslash continuations, outside block comments."""
+    found = []
+    row, in_comment = 0, False
+    while row < len(lines):
+        first, text = row, lines[row].rstrip(b"\r\n")
+        while text.endswith(b"\\") and row + 1 < len(lines):
+            row += 1
+            text = text[:-1] + lines[row].rstrip(b"\r\n")
+        if not in_comment:
+            found.append((first + 1, row + 1, text))
+        # Track `/* ... */` across lines so a commented-out `#if` is not a directive.
+        code = text.split(b"//", 1)[0] if not in_comment else text
+        while True:
+            if in_comment:
+                end = code.find(b"*/")
+                if end < 0:
+                    break
+                in_comment, code = False, code[end + 2 :]
+            else:
+                start = code.find(b"/*")
+                if start < 0:
+                    break
+                in_comment, code = True, code[start + 2 :]
+        row += 1
+    return found
+
+
+def _expression(text: bytes) -> str:
+    return " ".join(_COMMENT.sub(" ", text.decode("utf-8", errors="replace")).split())
+
+
+def _negate(condition: str) -> str:
+    if condition.startswith("!defined(") and condition.endswith(")") and "(" not in condition[9:]:
+        return condition[1:]
+    if re.fullmatch(r"defined\(\w+\)|\w+", condition):
+        return "!" + condition
+    return f"!({condition})"
+
+
+def _conjoin(parts: List[str]) -> str:
+    if len(parts) == 1:
+        return parts[0]
+    return " && ".join(f"({p})" if "||" in p or "?" in p else p for p in parts)
+
+
+def _test(keyword: str, argument: str) -> Optional[str]:
+    """What one directive tests (None for `#else`)."""
+    if keyword in ("ifdef", "elifdef"):
+        return f"defined({argument})"
+    if keyword in ("ifndef", "elifndef"):
+        return f"!defined({argument})"
+    return None if keyword == "else" else argument
+
+
+def conditional_tree(source: bytes) -> List[Conditional]:
+    """
+    The top-level conditionals of `source`, each with its nested ones. Unbalanced
+    directives are tolerated: a stray `#endif` is ignored and an unterminated `#if`
+    runs to the end of the file.
+    """
+    lines = source.splitlines(keepends=True)
+    roots: List[Conditional] = []
+    stack: List[Tuple[Conditional, List[str]]] = []  # open conditionals, with what their branches test
+
+    for first, last, text in _logical_lines(lines):
+        match = _DIRECTIVE.match(text)
+        if not match:
+            continue
+        keyword, argument = match.group(1).decode(), _expression(match.group(2))
+        test = _test(keyword, argument)
+        if keyword in ("if", "ifdef", "ifndef"):
+            conditional = Conditional(branches=[Branch(test, last + 1, last)], directives=[(first, last)])
+            (stack[-1][0].branches[-1].children if stack else roots).append(conditional)
+            stack.append((conditional, [test]))
+            continue
+        if not stack:
+            continue
+        conditional, tests = stack[-1]
+        conditional.branches[-1].end_line = first - 1
+        conditional.directives.append((first, last))
+        if keyword == "endif":
+            stack.pop()
+            continue
+        # A later branch runs only when every earlier test failed.
+        condition = _conjoin([*map(_negate, tests), *([test] if test else [])])
+        conditional.branches.append(Branch(condition, last + 1, last))
+        tests.append(test or "1")
+    for conditional, _ in stack:
+        conditional.branches[-1].end_line = len(lines)
+    _mark_include_guard(roots, lines)
+    return roots
+
+
+def _mark_include_guard(roots: List[Conditional], lines: List[bytes]) -> None:
+    """Drop the condition of an `#ifndef X` / `#define X` ... `#endif` wrapping the whole file."""
+    if len(roots) != 1 or len(roots[0].branches) != 1:
+        return
+    guard = roots[0]
+    branch = guard.branches[0]
+    opening = lines[guard.directives[0][0] - 1]
+    match = re.match(rb"^\s*#\s*ifndef\s+(\w+)", opening)
+    if not match:
+        return
+    following = [lines[i].strip() for i in range(branch.start_line - 1, min(branch.end_line, len(lines)))]
+    following = [line for line in following if line]
+    if not following or _DEFINE.match(following[0]) is None or _DEFINE.match(following[0]).group(1) != match.group(1):
+        return
+    before = [lines[i].strip() for i in range(guard.directives[0][0] - 1)]
+    after = [lines[i].strip() for i in range(guard.directives[-1][1], len(lines))]
+    if all(not line or line.startswith((b"//", b"/*", b"*")) for line in before + after):
+        branch.condition = None
+
+
+def _variant_count(conditionals: List[Conditional]) -> int:
+    count = 0
+    for conditional in conditionals:
+        count = max(count, len(conditional.branches))
+        for branch in conditional.branches:
+            count = max(count, _variant_count(branch.children))
+    return count
+
+
+def _blank(buffer: bytearray, offsets: List[int], first: int, last: int) -> None:
+    """Overwrite lines `first`..`last` (1-based) with spaces, keeping the line breaks."""
+    for row in range(first - 1, min(last, len(offsets) - 1)):
+        for i in range(offsets[row], offsets[row + 1]):
+            if buffer[i] not in (0x0A, 0x0D):
+                buffer[i] = 0x20
+
+
+def _variant(
+    source: bytes, offsets: List[int], conditionals: List[Conditional], index: int
+) -> Tuple[bytes, Dict[int, str]]:
+    """Variant `index` of `source`, and the condition of each line inside a kept, guarded branch."""
+    buffer = bytearray(source)
+    guarded: Dict[int, str] = {}
+
+    def keep(items: List[Conditional], outer: List[str]) -> None:
+        for conditional in items:
+            for first, last in conditional.directives:
+                _blank(buffer, offsets, first, last)
+            chosen = min(index, len(conditional.branches) - 1)
+            for i, branch in enumerate(conditional.branches):
+                if i != chosen:
+                    _blank(buffer, offsets, branch.start_line, branch.end_line)
+                    continue
+                conditions = [*outer, *([branch.condition] if branch.condition else [])]
+                if conditions:
+                    for line in range(branch.start_line, branch.end_line + 1):
+                        guarded[line] = _conjoin(conditions)
+                keep(branch.children, conditions)
+
+    keep(conditionals, [])
+    return bytes(buffer), guarded
+
+
+def _original_content(symbol, variant: bytes, source: bytes, offsets: List[int]) -> Optional[str]:
+    """The symbol's text as the unblanked file has it (None when it cannot be located)."""
+    if not symbol.content or symbol.start_line >= len(offsets):
+        return None
+    text = symbol.content.encode("utf-8")
+    start = variant.find(text.split(b"\n", 1)[0], offsets[symbol.start_line - 1], offsets[symbol.start_line])
+    if start < 0:
+        return None
+    return source[start : start + len(text)].decode("utf-8", errors="replace")
+
+
+def _is_plain_guard(conditional: Conditional) -> bool:
+    """An include guard with no conditionals inside: nothing to do."""
+    return conditional.branches[0].condition is None and not conditional.branches[0].children
+
+
+def conditional_symbols(source: bytes, language: str, max_chunk_size: Optional[int] = None):
+    """
+    Symbols of every branch of the conditionals in `source` (see the module doc), or
+    None when it has none, in which case the file is extracted as usual.
+    """
+    from . import redact
+    from .core import get_parser, symbols_from_tree
+
+    source = bytes(source)  # large files arrive memory-mapped
+    conditionals = conditional_tree(source)
+    if not conditionals or (len(conditionals) == 1 and _is_plain_guard(conditionals[0])):
+        return None
+    offsets = [0]
+    for line in source.splitlines(keepends=True):
+        offsets.append(offsets[-1] + len(line))
+    parser = get_parser(language)
+    merged: Dict[tuple, object] = {}
+    for index in range(_variant_count(conditionals)):
+        variant, guarded = _variant(source, offsets, conditionals, index)
+        for symbol in symbols_from_tree(parser.parse(variant).root_node, variant, language, max_chunk_size):
+            key = (symbol.kind, symbol.name, symbol.start_line, symbol.chunk_index)
+            if key in merged:
+                continue
+            original = _original_content(symbol, variant, source, offsets)
+            if original is not None:
+                symbol.content = redact.apply_text(original)
+            symbol.condition = guarded.get(symbol.start_line)
+            merged[key] = symbol
+    return sorted(merged.values(), key=lambda s: (s.start_line, -s.end_line, s.chunk_index or 0))
+
+
+__all__ = ["ENABLED", "LANGUAGES", "Branch", "Conditional", "conditional_symbols", "conditional_tree"]
@@ -0,0 +1,199 @@
+"""
+Terminal rendering of extracted symbols (`--format pretty`): a header per symbol, then
+its source with line numbers, ANSI-coloured from the language's `highlights` query.
+
+Bodies are collapsed so a file's symbols can be read top to bottom: the body of a
+symbol that is listed on its own (a method inside a class) shows only its first line
+inside the enclosing symbol, and a body longer than `max_lines` keeps its opening and
+closing lines around a `⋮ N lines` marker.
+"""
+
+from __future__ import annotations
+
+from dataclasses import replace
+from pathlib import Path
+from typing import Dict, List, Optional, Sequence
+
+from tree_sitter import QueryCursor
+
+from .core import CodeSymbol, FileSymbols, ParsedFile, parse_file
+from .querylib import compile_query, find_query
+
+# Colours by the first part of a highlight capture name (`function.method.call` -> `function`).
+THEME: Dict[str, str] = {
+    "keyword": "\x1b[35m",
+    "function": "\x1b[34m",
+    "type": "\x1b[36m",
+    "constructor": "\x1b[36m",
+    "string": "\x1b[32m",
+    "number": "\x1b[33m",
+    "boolean": "\x1b[33m",
+    "constant": "\x1b[33m",
+    "comment": "\x1b[2;3m",
+    "property": "\x1b[36m",
+    "attribute": "\x1b[33m",
+    "tag": "\x1b[34m",
+}
+_BOLD = "\x1b[1m"
+_DIM = "\x1b[2m"
+_RESET = "\x1b[0m"
+_CHANGE_MARKERS = {"added": "+", "modified": "~"}
+
+
+def highlight_styles(parsed: ParsedFile) -> List[Optional[str]]:
+    """The ANSI colour of every source byte (None for uncoloured bytes)."""
+    styles: List[Optional[str]] = [None] * len(parsed.source)
+    if find_query(parsed.language, "highlights") is None:
+        return styles
+    spans = []
+    for pattern_index, captures in QueryCursor(compile_query(parsed.language, "highlights")).matches(parsed.root):
+        for name, nodes in captures.items():
+            color = THEME.get(name.split(".", 1)[0])
+            if color is None:
+                continue
+            for node in nodes:
+                spans.append((node.start_byte - node.end_byte, pattern_index, node.start_byte, node.end_byte, color))
+    # Larger nodes first so the nodes inside them paint over them; for the same node,
+    # later patterns take precedence, as the bundled queries are written.
+    for _, _, start, end, color in sorted(spans):
+        styles[start:end] = [color] * (end - start)
+    return styles
+
+
+def _paint(line: bytes, styles: Sequence[Optional[str]]) -> str:
+    out, start = [], 0
+    for i in range(1, len(line) + 1):
+        if i == len(line) or styles[i] != styles[start]:
+            text = line[start:i].decode("utf-8", "replace")
+            out.append(f"{styles[start]}{text}{_RESET}" if styles[start] and text.strip() else text)
+            start = i
+    return "".join(out)
+
+
+class _Renderer:
+    def __init__(self, parsed: ParsedFile, color: bool, max_lines: int):
+        self.parsed = parsed
+        self.color = color
+        self.max_lines = max_lines
+        self.lines = parsed.source.split(b"\n")
+        self.styles = highlight_styles(parsed) if color else None
+        self.starts = [0]
+        for text in self.lines[:-1]:
+            self.starts.append(self.starts[-1] + len(text) + 1)
+        self.width = len(str(len(self.lines)))
+
+    def _style(self, text: str, code: str) -> str:
+        return f"{code}{text}{_RESET}" if self.color else text
+
+    def code_line(self, row: int) -> str:
+        """Line `row` (1-based) with its gutter."""
+        line = self.lines[row - 1].rstrip(b"\r")
+        gutter = self._style(f"{row:>{self.width}} │ ", _DIM)
+        if self.styles is None:
+            return gutter + line.decode("utf-8", "replace")
+        start = self.starts[row - 1]
+        return gutter + _paint(line, self.styles[start : start + len(line)])
+
+    def marker(self, hidden: int, note: str = "") -> str:
+        text = f"{' ' * self.width} ⋮ {hidden} line{'s' if hidden != 1 else ''}{note}"
+        return self._style(text, _DIM)
+
+    def header(self, symbol: CodeSymbol, label: str) -> str:
+        name = f"{symbol.kind} {self._style(symbol.name, _BOLD)}"
+        where = self._style(f"{label}:{symbol.start_line}-{symbol.end_line}", _DIM)
+        extra = f" [{_CHANGE_MARKERS.get(symbol.change, '')}{symbol.change}]" if symbol.change else ""
+        return f"{name}  {where}{extra}"
+
+    def symbol(self, symbol: CodeSymbol, nested: Sequence[CodeSymbol], label: str) -> List[str]:
+        out = [self.header(symbol, label)]
+        if symbol.doc:
+            out.append(self._style(f"  {symbol.doc.splitlines()[0]}", _DIM))
+        rows: List[object] = []  # line numbers, or (hidden count, note) markers
+        row = symbol.start_line
+        for inner in nested:
+            if inner.start_line < row or inner.end_line > symbol.end_line:
+                continue
+            rows.extend(range(row, inner.start_line + 1))
+            if inner.end_line > inner.start_line:
+                rows.append((inner.end_line - inner.start_line, f" ({inner.name}, shown below)"))
+            row = inner.end_line + 1
+        rows.extend(range(row, min(symbol.end_line, len(self.lines)) + 1))
+        if self.max_lines and len(rows) > self.max_lines:
+            head = max(self.max_lines - 1, 1)
+            tail = rows[-1:] if isinstance(rows[-1], int) else []  # the closing line, unless it is folded
+            hidden = rows[head : len(rows) - len(tail)]
+            count = sum(1 if isinstance(item, int) else item[0] for item in hidden)
+            rows = rows[:head] + [(count, "")] + tail
+        for item in rows:
+            out.append(self.code_line(item) if isinstance(item, int) else self.marker(*item))
+        return out
+
+
+def _nested(symbols: Sequence[CodeSymbol], outer: CodeSymbol) -> List[CodeSymbol]:
+    """The outermost other symbols that lie strictly inside `outer`, in source order."""
+    inside = [
+        s for s in symbols
+        if s is not outer
+        and outer.start_line <= s.start_line and s.end_line <= outer.end_line
+        and (s.start_line, s.end_line) != (outer.start_line, outer.end_line)
+    ]
+    inside.sort(key=lambda s: (s.start_line, -s.end_line))
+    outermost: List[CodeSymbol] = []
+    for s in inside:
+        if not outermost or s.start_line > outermost[-1].end_line:
+            outermost.append(s)
+    return outermost
+
+
+def _whole(symbols: Sequence[CodeSymbol]) -> List[CodeSymbol]:
+    """`symbols` with the chunks of a chunked symbol merged back into one."""
+    merged: List[CodeSymbol] = []
+    for symbol in symbols:
+        if symbol.chunk_index and merged and merged[-1].name == symbol.name:
+            merged[-1] = replace(merged[-1], end_line=max(merged[-1].end_line, symbol.end_line))
+        elif not symbol.chunk_index:
+            merged.append(symbol)
+    return merged
+
+
+def render_symbols(
+    symbols: Sequence[CodeSymbol],
+    parsed: ParsedFile,
+    label: Optional[str] = None,
+    color: bool = True,
+    max_lines: int = 20,
+) -> str:
+    """Pretty listing of `symbols` extracted from `parsed`; `max_lines=0` never collapses long bodies."""
+    label = label or parsed.path.as_posix()
+    renderer = _Renderer(parsed, color, max_lines)
+    symbols = _whole(symbols)
+    blocks = ["\n".join(renderer.symbol(s, _nested(symbols, s), label)) for s in symbols]
+    return "\n\n".join(blocks) + ("\n" if blocks else "")
+
+
+def render_reports(
+    reports: Sequence[FileSymbols], root: Optional[Path] = None, color: bool = True, max_lines: int = 20
+) -> str:
+    """`render_symbols` for each file of a scan, under a rule naming the file."""
+    sections = []
+    for report in reports:
+        if not report.symbols:
+            continue
+        label = report.path.as_posix()
+        if root is not None:
+            try:
+                label = report.path.relative_to(Path(root).resolve()).as_posix()
+            except ValueError:
+                pass
+        try:
+            parsed = parse_file(report.path, report.language)
+        except (ValueError, RuntimeError, OSError):
+            continue
+        rule = f"── {label} " + "─" * max(0, 60 - len(label))
+        sections.append((f"{_BOLD}{rule}{_RESET}" if color else rule) + "\n" + render_symbols(
+            report.symbols, parsed, label, color, max_lines
+        ))
+    return "\n".join(sections)
+
+
+__all__ = ["THEME", "highlight_styles", "render_reports", "render_symbols"]
@@ -0,0 +1,19 @@
+[
+  (function_declaration)
+  (method_declaration)
+  (func_literal)
+  (block)
+  (type_declaration)
+  (field_declaration_list)
+  (interface_type)
+  (import_declaration)
+  (const_declaration)
+  (var_declaration)
+  (composite_literal)
+  (literal_value)
+  (argument_list)
+  (expression_switch_statement)
+  (type_switch_statement)
+  (select_statement)
+  (comment)
+] @fold
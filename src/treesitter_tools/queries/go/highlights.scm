@@ -0,0 +1,41 @@
+; Later patterns take precedence over earlier ones for the same node.
+(identifier) @variable
+(type_identifier) @type
+(field_identifier) @property
+(package_identifier) @module
+(label_name) @label
+
+(function_declaration name: (identifier) @function)
+(method_declaration name: (field_identifier) @function.method)
+(method_elem name: (field_identifier) @function.method)
+(call_expression function: (identifier) @function.call)
+(call_expression function: (selector_expression field: (field_identifier) @function.method.call))
+((call_expression function: (identifier) @function.builtin)
+  (#any-of? @function.builtin
+    "append" "cap" "clear" "close" "complex" "copy" "delete" "imag" "len" "make" "max" "min"
+    "new" "panic" "print" "println" "real" "recover"))
+(parameter_declaration name: (identifier) @variable.parameter)
+(variadic_parameter_declaration name: (identifier) @variable.parameter)
+(const_spec name: (identifier) @constant)
+
+[(true) (false)] @boolean
+[(nil) (iota)] @constant.builtin
+[(int_literal) (float_literal) (imaginary_literal)] @number
+[(interpreted_string_literal) (raw_string_literal)] @string
+(rune_literal) @character
+(escape_sequence) @string.escape
+(comment) @comment
+
+[
+  "break" "case" "chan" "const" "continue" "default" "defer" "else" "fallthrough" "for" "func"
+  "go" "goto" "if" "import" "interface" "map" "package" "range" "return" "select" "struct"
+  "switch" "type" "var"
+] @keyword
+
+[
+  "+" "-" "*" "/" "%" "=" ":=" "==" "!=" "<" "<=" ">" ">=" "&&" "||" "!" "&" "|" "^" "<<" ">>"
+  "&^" "+=" "-=" "++" "--" "<-" "..."
+] @operator
+
+["(" ")" "[" "]" "{" "}"] @punctuation.bracket
+["," "." ":" ";"] @punctuation.delimiter
@@ -0,0 +1,12 @@
+; html/template and text/template sources: template.New("page").Parse(`<p>{{.Name}}</p>`)
+(call_expression
+  function: (selector_expression field: (field_identifier) @_method)
+  arguments: (argument_list . [(raw_string_literal) (interpreted_string_literal)] @injection.content)
+  (#eq? @_method "Parse")
+  (#match? @injection.content "<[a-zA-Z!/]")
+  (#set! injection.language "html"))
+
+; SQL statements in string literals: db.Query(`SELECT ...`), const q = "UPDATE ..."
+(([(raw_string_literal) (interpreted_string_literal)] @injection.content)
+  (#match? @injection.content "^.\\s*(?i:select|insert|update|delete|with|create|alter|drop|merge|replace)\\s")
+  (#set! injection.language "sql"))
@@ -0,0 +1,17 @@
+; Scopes, the names they bind, and references resolving to them.
+[(source_file) (function_declaration) (method_declaration) (func_literal) (block)
+ (if_statement) (for_statement) (expression_switch_statement) (type_switch_statement)
+ (select_statement)] @local.scope
+
+(parameter_declaration name: (identifier) @local.definition)
+(variadic_parameter_declaration name: (identifier) @local.definition)
+(short_var_declaration left: (expression_list (identifier) @local.definition))
+(var_spec name: (identifier) @local.definition)
+(const_spec name: (identifier) @local.definition)
+(range_clause left: (expression_list (identifier) @local.definition))
+(function_declaration name: (identifier) @local.definition)
+(type_spec name: (type_identifier) @local.definition)
+(import_spec name: (package_identifier) @local.definition)
+
+(identifier) @local.reference
+(type_identifier) @local.reference
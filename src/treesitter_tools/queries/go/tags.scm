@@ -0,0 +1,12 @@
+; Definitions and references in the tree-sitter tags format (`tags` command, code navigation).
+(function_declaration name: (identifier) @name) @definition.function
+(method_declaration name: (field_identifier) @name) @definition.method
+(type_spec name: (type_identifier) @name type: (struct_type)) @definition.class
+(type_spec name: (type_identifier) @name type: (interface_type)) @definition.interface
+(type_spec name: (type_identifier) @name) @definition.type
+(method_elem name: (field_identifier) @name) @definition.method
+(package_clause (package_identifier) @name) @definition.module
+
+(call_expression function: (identifier) @name) @reference.call
+(call_expression function: (selector_expression field: (field_identifier) @name)) @reference.call
+(type_identifier) @name @reference.type
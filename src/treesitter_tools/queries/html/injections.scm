@@ -0,0 +1,17 @@
+; <script lang="ts"> before plain scripts: the first pattern matching a region wins.
+(script_element
+  (start_tag) @_tag
+  (raw_text) @injection.content
+  (#match? @_tag "lang=[\"']?ts")
+  (#set! injection.language "typescript"))
+
+; Scripts, except data blocks and client-side templates.
+(script_element
+  (start_tag) @_tag
+  (raw_text) @injection.content
+  (#not-match? @_tag "type=[\"']?(text/(template|x-template|html)|application/(ld\\+)?json|importmap)")
+  (#set! injection.language "javascript"))
+
+(style_element
+  (raw_text) @injection.content
+  (#set! injection.language "css"))
@@ -0,0 +1,6 @@
+; Declarations plus `const f = () => {}` bindings.
+(function_declaration name: (identifier) @name) @definition.function
+(generator_function_declaration name: (identifier) @name) @definition.function
+(method_definition name: (property_identifier) @name) @definition.method
+(class_declaration name: (identifier) @name) @definition.class
+(variable_declarator name: (identifier) @name value: (arrow_function)) @definition.function
@@ -0,0 +1,12 @@
+[
+  (statement_block)
+  (class_body)
+  (object)
+  (array)
+  (arguments)
+  (formal_parameters)
+  (template_string)
+  (switch_body)
+  (import_statement)
+  (comment)
+] @fold
@@ -0,0 +1,42 @@
+; Later patterns take precedence over earlier ones for the same node.
+(identifier) @variable
+((identifier) @constructor (#match? @constructor "^[A-Z]"))
+((identifier) @constant (#match? @constant "^[A-Z][A-Z0-9_]+$"))
+(property_identifier) @property
+(shorthand_property_identifier) @property
+
+(function_declaration name: (identifier) @function)
+(generator_function_declaration name: (identifier) @function)
+(method_definition name: (property_identifier) @function.method)
+(variable_declarator name: (identifier) @function value: (arrow_function))
+(call_expression function: (identifier) @function.call)
+(call_expression function: (member_expression property: (property_identifier) @function.method.call))
+(class_declaration name: (identifier) @type)
+(new_expression constructor: (identifier) @constructor)
+(formal_parameters (identifier) @variable.parameter)
+(arrow_function parameter: (identifier) @variable.parameter)
+
+[(this) (super)] @variable.builtin
+[(true) (false)] @boolean
+[(null) (undefined)] @constant.builtin
+(number) @number
+[(string) (template_string)] @string
+(template_substitution ["${" "}"] @punctuation.special)
+(regex) @string.regexp
+(escape_sequence) @string.escape
+(comment) @comment
+
+[
+  "as" "async" "await" "break" "case" "catch" "class" "const" "continue" "debugger" "default"
+  "delete" "do" "else" "export" "extends" "finally" "for" "from" "function" "get" "if" "import"
+  "in" "instanceof" "let" "new" "of" "return" "set" "static" "switch" "throw" "try" "typeof"
+  "var" "void" "while" "with" "yield"
+] @keyword
+
+[
+  "+" "-" "*" "/" "%" "**" "=" "==" "===" "!=" "!==" "<" "<=" ">" ">=" "&&" "||" "??" "!"
+  "+=" "-=" "++" "--" "=>" "..." "&" "|" "^" "~" "<<" ">>"
+] @operator
+
+["(" ")" "[" "]" "{" "}"] @punctuation.bracket
+["," "." ":" ";"] @punctuation.delimiter
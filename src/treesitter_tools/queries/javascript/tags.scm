@@ -0,0 +1,12 @@
+; Definitions and references in the tree-sitter tags format (`tags` command, code navigation).
+(function_declaration name: (identifier) @name) @definition.function
+(generator_function_declaration name: (identifier) @name) @definition.function
+(class_declaration name: (identifier) @name) @definition.class
+(method_definition name: (property_identifier) @name) @definition.method
+(lexical_declaration (variable_declarator name: (identifier) @name value: (arrow_function))) @definition.function
+(variable_declaration (variable_declarator name: (identifier) @name value: (arrow_function))) @definition.function
+(pair key: (property_identifier) @name value: (arrow_function)) @definition.function
+
+(call_expression function: (identifier) @name) @reference.call
+(call_expression function: (member_expression property: (property_identifier) @name)) @reference.call
+(new_expression constructor: (identifier) @name) @reference.class
@@ -0,0 +1,3 @@
+; Top-level and nested definitions; @name is the identifier, the outer capture the whole node.
+(function_definition name: (identifier) @name) @definition.function
+(class_definition name: (identifier) @name) @definition.class
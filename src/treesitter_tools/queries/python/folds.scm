@@ -0,0 +1,26 @@
+[
+  (function_definition)
+  (class_definition)
+  (decorated_definition)
+  (if_statement)
+  (elif_clause)
+  (else_clause)
+  (for_statement)
+  (while_statement)
+  (try_statement)
+  (except_clause)
+  (finally_clause)
+  (with_statement)
+  (import_from_statement)
+  (parameters)
+  (argument_list)
+  (list)
+  (tuple)
+  (set)
+  (dictionary)
+  (list_comprehension)
+  (set_comprehension)
+  (dictionary_comprehension)
+  (generator_expression)
+  (string)
+] @fold
@@ -0,0 +1,41 @@
+; Later patterns take precedence over earlier ones for the same node.
+(identifier) @variable
+((identifier) @type (#match? @type "^[A-Z]"))
+((identifier) @constant (#match? @constant "^[A-Z][A-Z0-9_]+$"))
+((identifier) @variable.builtin (#any-of? @variable.builtin "self" "cls"))
+
+(attribute attribute: (identifier) @property)
+(type (identifier) @type)
+(class_definition name: (identifier) @type)
+(function_definition name: (identifier) @function)
+(decorator (identifier) @function.decorator)
+(call function: (identifier) @function.call)
+(call function: (attribute attribute: (identifier) @function.method.call))
+
+(parameters (identifier) @variable.parameter)
+(default_parameter name: (identifier) @variable.parameter)
+(typed_parameter (identifier) @variable.parameter)
+(typed_default_parameter name: (identifier) @variable.parameter)
+(keyword_argument name: (identifier) @variable.parameter)
+
+[(true) (false)] @boolean
+(none) @constant.builtin
+[(integer) (float)] @number
+(string) @string
+(escape_sequence) @string.escape
+(interpolation ["{" "}"] @punctuation.special)
+(comment) @comment
+
+[
+  "and" "as" "assert" "async" "await" "break" "class" "continue" "def" "del" "elif" "else"
+  "except" "finally" "for" "from" "global" "if" "import" "in" "is" "lambda" "nonlocal"
+  "not" "or" "pass" "raise" "return" "try" "while" "with" "yield"
+] @keyword
+
+[
+  "+" "-" "*" "/" "//" "%" "**" "@" "=" "==" "!=" "<" "<=" ">" ">=" "+=" "-=" "*=" "/="
+  "&" "|" "^" "~" "<<" ">>" "->" ":="
+] @operator
+
+["(" ")" "[" "]" "{" "}"] @punctuation.bracket
+["," "." ":" ";"] @punctuation.delimiter
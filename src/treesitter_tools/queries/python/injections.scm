@@ -0,0 +1,4 @@
+; SQL statements in string literals: cursor.execute("SELECT ...")
+((string (string_content) @injection.content)
+  (#match? @injection.content "^\\s*(?i:select|insert|update|delete|with|create|alter|drop|merge|replace)\\s")
+  (#set! injection.language "sql"))
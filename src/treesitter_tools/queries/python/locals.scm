@@ -0,0 +1,21 @@
+; Scopes, the names they bind, and references resolving to them.
+[(module) (function_definition) (class_definition) (lambda) (list_comprehension)
+ (set_comprehension) (dictionary_comprehension) (generator_expression)] @local.scope
+
+(parameters (identifier) @local.definition)
+(default_parameter name: (identifier) @local.definition)
+(typed_parameter (identifier) @local.definition)
+(typed_default_parameter name: (identifier) @local.definition)
+(lambda_parameters (identifier) @local.definition)
+(function_definition name: (identifier) @local.definition)
+(class_definition name: (identifier) @local.definition)
+(assignment left: (identifier) @local.definition)
+(assignment left: (pattern_list (identifier) @local.definition))
+(for_statement left: (identifier) @local.definition)
+(for_in_clause left: (identifier) @local.definition)
+(with_item value: (as_pattern alias: (as_pattern_target (identifier) @local.definition)))
+(import_statement name: (dotted_name . (identifier) @local.definition))
+(import_from_statement name: (dotted_name (identifier) @local.definition))
+(aliased_import alias: (identifier) @local.definition)
+
+(identifier) @local.reference
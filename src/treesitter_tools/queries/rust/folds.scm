@@ -0,0 +1,19 @@
+[
+  (mod_item)
+  (function_item)
+  (struct_item)
+  (enum_item)
+  (union_item)
+  (trait_item)
+  (impl_item)
+  (macro_definition)
+  (declaration_list)
+  (field_declaration_list)
+  (enum_variant_list)
+  (block)
+  (match_block)
+  (use_declaration)
+  (arguments)
+  (parameters)
+  (block_comment)
+] @fold
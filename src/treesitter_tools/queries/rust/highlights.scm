@@ -0,0 +1,48 @@
+; Later patterns take precedence over earlier ones for the same node.
+(identifier) @variable
+((identifier) @constant (#match? @constant "^[A-Z][A-Z0-9_]+$"))
+(type_identifier) @type
+(primitive_type) @type.builtin
+(field_identifier) @property
+(shorthand_field_identifier) @property
+(lifetime (identifier) @label)
+
+(function_item name: (identifier) @function)
+(function_signature_item name: (identifier) @function)
+(call_expression function: (identifier) @function.call)
+(call_expression function: (field_expression field: (field_identifier) @function.method.call))
+(call_expression function: (scoped_identifier name: (identifier) @function.call))
+(generic_function function: (identifier) @function.call)
+(macro_invocation macro: (identifier) @function.macro "!" @function.macro)
+(macro_definition name: (identifier) @function.macro)
+(parameter pattern: (identifier) @variable.parameter)
+(closure_parameters (identifier) @variable.parameter)
+(mod_item name: (identifier) @module)
+(scoped_identifier path: (identifier) @module)
+
+(self) @variable.builtin
+(boolean_literal) @boolean
+[(integer_literal) (float_literal)] @number
+[(string_literal) (raw_string_literal)] @string
+(char_literal) @character
+(escape_sequence) @string.escape
+[(line_comment) (block_comment)] @comment
+(attribute_item) @attribute
+(inner_attribute_item) @attribute
+
+[
+  "as" "async" "await" "break" "const" "continue" "dyn" "else" "enum" "extern" "fn" "for" "if"
+  "impl" "in" "let" "loop" "match" "mod" "move" "pub" "ref" "return" "static" "struct" "trait"
+  "type" "union" "unsafe" "use" "where" "while"
+] @keyword
+(crate) @keyword
+(super) @keyword
+(mutable_specifier) @keyword
+
+[
+  "+" "-" "*" "/" "%" "=" "==" "!=" "<" "<=" ">" ">=" "&&" "||" "!" "&" "|" "^" "<<" ">>"
+  "+=" "-=" "*=" "/=" "->" "=>" ".." "..=" "?"
+] @operator
+
+["(" ")" "[" "]" "{" "}"] @punctuation.bracket
+["," "." ":" "::" ";"] @punctuation.delimiter
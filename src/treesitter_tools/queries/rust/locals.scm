@@ -0,0 +1,17 @@
+; Scopes, the names they bind, and references resolving to them.
+[(source_file) (function_item) (closure_expression) (block) (impl_item) (trait_item)
+ (for_expression) (match_arm) (if_expression) (while_expression)] @local.scope
+
+(parameter pattern: (identifier) @local.definition)
+(closure_parameters (identifier) @local.definition)
+(let_declaration pattern: (identifier) @local.definition)
+(tuple_pattern (identifier) @local.definition)
+(for_expression pattern: (identifier) @local.definition)
+(function_item name: (identifier) @local.definition)
+(const_item name: (identifier) @local.definition)
+(static_item name: (identifier) @local.definition)
+(use_declaration argument: (identifier) @local.definition)
+(use_as_clause alias: (identifier) @local.definition)
+(use_list (identifier) @local.definition)
+
+(identifier) @local.reference
@@ -0,0 +1,14 @@
+[
+  (statement_block)
+  (class_body)
+  (enum_body)
+  (object)
+  (object_type)
+  (array)
+  (arguments)
+  (formal_parameters)
+  (template_string)
+  (switch_body)
+  (import_statement)
+  (comment)
+] @fold
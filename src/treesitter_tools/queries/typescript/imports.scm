@@ -0,0 +1,5 @@
+(import_statement source: (string) @name) @import
+((call_expression
+   function: (identifier) @_fn
+   arguments: (arguments . (string) @name)) @import
+ (#eq? @_fn "require"))
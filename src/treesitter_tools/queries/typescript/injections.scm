@@ -0,0 +1,18 @@
+; Tagged templates: sql`SELECT ...`, html`<p>...</p>`, css`a { ... }`
+(call_expression
+  function: (identifier) @_tag
+  arguments: (template_string) @injection.content
+  (#eq? @_tag "sql")
+  (#set! injection.language "sql"))
+
+(call_expression
+  function: (identifier) @_tag
+  arguments: (template_string) @injection.content
+  (#eq? @_tag "html")
+  (#set! injection.language "html"))
+
+(call_expression
+  function: (identifier) @_tag
+  arguments: (template_string) @injection.content
+  (#eq? @_tag "css")
+  (#set! injection.language "css"))
@@ -0,0 +1,20 @@
+; Scopes, the names they bind, and references resolving to them; shared by TypeScript and TSX.
+[(program) (statement_block) (function_declaration) (generator_function_declaration)
+ (arrow_function) (method_definition) (for_statement) (for_in_statement) (catch_clause)] @local.scope
+
+(required_parameter pattern: (identifier) @local.definition)
+(optional_parameter pattern: (identifier) @local.definition)
+(arrow_function parameter: (identifier) @local.definition)
+(variable_declarator name: (identifier) @local.definition)
+(function_declaration name: (identifier) @local.definition)
+(generator_function_declaration name: (identifier) @local.definition)
+(class_declaration name: (type_identifier) @local.definition)
+(enum_declaration name: (identifier) @local.definition)
+(for_in_statement left: (identifier) @local.definition)
+(catch_clause parameter: (identifier) @local.definition)
+(import_clause (identifier) @local.definition)
+(namespace_import (identifier) @local.definition)
+(import_specifier !alias name: (identifier) @local.definition)
+(import_specifier alias: (identifier) @local.definition)
+
+(identifier) @local.reference
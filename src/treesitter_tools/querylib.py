@@ -0,0 +1,139 @@
+"""
+Query library: the bundled `.scm` files of every language (tags, highlights, locals,
+folds, plus the definitions/imports/injections queries the extractors use), with
+user directories (`--query-dir`) taking precedence over them.
+
+A query directory holds one subdirectory per language, `DIR/<language>/<name>.scm`,
+the same layout as the bundled `queries/` directory.
+"""
+
+from __future__ import annotations
+
+from dataclasses import dataclass
+from pathlib import Path
+from typing import Dict, List, Optional, Sequence, Tuple
+
+from tree_sitter import Query
+
+from .core import LANGUAGE_SPECS, get_language_spec, load_language
+from .languages import QUERIES_DIR
+
+# The curated editor-style queries; every bundled language ships all of them.
+QUERY_KINDS = ("tags", "highlights", "locals", "folds")
+
+# User query directories, highest precedence first.
+QUERY_DIRS: List[Path] = []
+
+_COMPILED: Dict[Tuple[str, Path], Tuple[float, Query]] = {}
+
+
+@dataclass(frozen=True)
+class QueryFile:
+    language: str
+    name: str
+    path: Path
+    origin: str  # "user" (a --query-dir) or "bundled" (the language spec's directory)
+
+    def to_dict(self) -> dict:
+        return {"language": self.language, "name": self.name, "origin": self.origin, "path": self.path.as_posix()}
+
+
+def add_query_dir(path: Path) -> None:
+    """Search `path` before the bundled queries (and before directories added earlier)."""
+    path = Path(path)
+    if not path.is_dir():
+        raise ValueError(f"Query directory not found: {path}")
+    if path not in QUERY_DIRS:
+        QUERY_DIRS.insert(0, path)
+
+
+def _bundled_dir(language: str) -> Path:
+    spec = get_language_spec(language)
+    return spec.queries_path if spec is not None else QUERIES_DIR / language
+
+
+def _search_path(language: str) -> List[Tuple[Path, str]]:
+    return [(d / language, "user") for d in QUERY_DIRS] + [(_bundled_dir(language), "bundled")]
+
+
+def find_query(language: str, name: str) -> Optional[QueryFile]:
+    """The `name` query of `language` that takes effect, or None."""
+    for directory, origin in _search_path(language):
+        path = directory / f"{name}.scm"
+        if path.is_file():
+            return QueryFile(language, name, path, origin)
+    return None
+
+
+def _languages() -> List[str]:
+    names = set(LANGUAGE_SPECS)
+    for directory in [QUERIES_DIR, *QUERY_DIRS]:
+        if directory.is_dir():
+            names.update(p.name for p in directory.iterdir() if p.is_dir() and any(p.glob("*.scm")))
+    return sorted(names)
+
+
+def list_queries(language: Optional[str] = None) -> List[QueryFile]:
+    """The effective queries of one language (or all), sorted; user files shadow bundled ones."""
+    found: List[QueryFile] = []
+    for lang in [language] if language else _languages():
+        names = set()
+        for directory, _ in _search_path(lang):
+            if directory.is_dir():
+                names.update(p.stem for p in directory.glob("*.scm"))
+        for name in sorted(names):
+            query = find_query(lang, name)
+            if query is not None:
+                found.append(query)
+    return found
+
+
+def load_query(language: str, name: str) -> str:
+    """Source text of a query; ValueError naming the available ones when there is none."""
+    query = find_query(language, name)
+    if query is None:
+        available = ", ".join(q.name for q in list_queries(language)) or "none"
+        raise ValueError(f"No '{name}' query for {language} (available: {available})")
+    return query.path.read_text(encoding="utf-8")
+
+
+def compile_query(language: str, name: str) -> Query:
+    """`load_query`, compiled for the language's grammar; cached until the file changes."""
+    found = find_query(language, name)
+    if found is None:
+        load_query(language, name)  # raises with the available names
+    key = (language, found.path)
+    mtime = found.path.stat().st_mtime
+    cached = _COMPILED.get(key)
+    if cached is None or cached[0] != mtime:
+        cached = (mtime, Query(load_language(language), found.path.read_text(encoding="utf-8")))
+        _COMPILED[key] = cached
+    return cached[1]
+
+
+def check_queries(languages: Optional[Sequence[str]] = None) -> List[Tuple[QueryFile, str]]:
+    """(query, error) for every effective query that does not compile; grammars that cannot load are skipped."""
+    problems = []
+    for query in list_queries() if not languages else [q for lang in languages for q in list_queries(lang)]:
+        try:
+            language = load_language(query.language)
+        except RuntimeError:
+            continue
+        try:
+            Query(language, query.path.read_text(encoding="utf-8"))
+        except (ValueError, RuntimeError) as exc:  # QueryError subclasses ValueError
+            problems.append((query, str(exc)))
+    return problems
+
+
+__all__ = [
+    "QUERY_DIRS",
+    "QUERY_KINDS",
+    "QueryFile",
+    "add_query_dir",
+    "check_queries",
+    "compile_query",
+    "find_query",
+    "list_queries",
+    "load_query",
+]
@@ -0,0 +1,171 @@
+"""
+Redaction: mask secrets in string literals before extracted code leaves the machine.
+
+When enabled (`--redact`, `--redact-pattern`, or `redact:` in the project config),
+every parsed file's source is rewritten right after parsing: inside string literal
+nodes, text matching a secret pattern is overwritten with a mask of the same byte
+length (`<aws-access-key>****...`), so offsets, columns, and line numbers in every
+downstream result still line up, and symbol contents, chunks, literals, snippets, ...
+never carry the secret. Newlines inside a match (a PEM block) are kept.
+
+A pattern with a `secret` group masks only that group: the password of a connection
+string, not its host. Text read without a parse tree (`get`) is masked wherever a
+pattern matches.
+"""
+
+from __future__ import annotations
+
+import re
+from dataclasses import dataclass
+from typing import Iterable, List, Optional, Pattern, Tuple
+
+from tree_sitter import Node
+
+MASK_CHAR = "*"
+
+BUILTIN_PATTERNS = (
+    ("aws-access-key", r"\b(?:AKIA|ASIA)[0-9A-Z]{16}\b"),
+    ("github-token", r"\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})"),
+    ("gitlab-token", r"\bglpat-[A-Za-z0-9_\-]{20,}"),
+    ("slack-token", r"\bxox[abprs]-[A-Za-z0-9\-]{10,}"),
+    ("stripe-key", r"\b[rs]k_(?:live|test)_[A-Za-z0-9]{16,}"),
+    ("google-api-key", r"\bAIza[0-9A-Za-z_\-]{35}"),
+    ("api-key", r"\bsk-(?:ant-|proj-)?[A-Za-z0-9_\-]{20,}"),
+    ("jwt", r"\beyJ[A-Za-z0-9_\-]{5,}\.eyJ[A-Za-z0-9_\-]{5,}\.[A-Za-z0-9_\-]{10,}"),
+    ("private-key", r"-----BEGIN (?:[A-Z]+ )*PRIVATE KEY-----(?:.|\n)*?(?:-----END (?:[A-Z]+ )*PRIVATE KEY-----|$)"),
+    ("connection-string", r"\b[A-Za-z][A-Za-z0-9+.\-]*://[^\s:/@'\"`]+:(?P<secret>[^\s@'\"`]+)@"),
+    ("password", r"(?i)\b(?:password|passwd|pwd)\s*=\s*(?P<secret>[^;'\"`\s]+)"),
+)
+
+# Set by the CLI (`--redact`, `--redact-pattern`, config `redact:`); None leaves sources as they are.
+ACTIVE: Optional["Redactor"] = None
+
+
+@dataclass
+class SecretPattern:
+    name: str
+    regex: Pattern[str]
+
+    def spans(self, text: str) -> Iterable[Tuple[int, int]]:
+        for match in self.regex.finditer(text):
+            if "secret" in self.regex.groupindex and match.group("secret") is not None:
+                yield match.span("secret")
+            elif match.end() > match.start():
+                yield match.span()
+
+
+def compile_pattern(pattern: str, name: str = "custom") -> SecretPattern:
+    """A user pattern; raises ValueError for an invalid regex."""
+    try:
+        return SecretPattern(name, re.compile(pattern))
+    except re.error as exc:
+        raise ValueError(f"Invalid redaction pattern {pattern!r}: {exc}") from exc
+
+
+def builtin_patterns() -> List[SecretPattern]:
+    return [compile_pattern(pattern, name) for name, pattern in BUILTIN_PATTERNS]
+
+
+def _mask(text: str, name: str) -> str:
+    """`text` with every character but newlines masked, labelled with `name` where it fits."""
+    label = f"<{name}>"
+    masked = re.sub(r"[^\n]", MASK_CHAR, text)
+    first = masked.split("\n", 1)[0]
+    if len(label) <= len(first):
+        masked = label + masked[len(label):]
+    return masked
+
+
+class Redactor:
+    """Secret patterns applied to text or to the string literals of a parsed source."""
+
+    def __init__(self, patterns: Iterable[SecretPattern]):
+        self.patterns = list(patterns)
+        self.count = 0  # masks applied so far
+
+    @classmethod
+    def from_options(cls, extra: Iterable[str] = (), builtins: bool = True) -> "Redactor":
+        patterns = builtin_patterns() if builtins else []
+        return cls(patterns + [compile_pattern(p) for p in extra])
+
+    def _spans(self, text: str) -> List[Tuple[int, int, str]]:
+        """Non-overlapping matches, earliest (then longest) first."""
+        found = sorted(
+            ((start, end, p.name) for p in self.patterns for start, end in p.spans(text)),
+            key=lambda s: (s[0], -s[1]),
+        )
+        spans: List[Tuple[int, int, str]] = []
+        for start, end, name in found:
+            if not spans or start >= spans[-1][1]:
+                spans.append((start, end, name))
+        return spans
+
+    def redact_text(self, text: str) -> str:
+        """`text` with every pattern match masked; the length (in characters) is unchanged."""
+        spans = self._spans(text)
+        if not spans:
+            return text
+        parts, last = [], 0
+        for start, end, name in spans:
+            parts.append(text[last:start])
+            parts.append(_mask(text[start:end], name))
+            last = end
+        parts.append(text[last:])
+        self.count += len(spans)
+        return "".join(parts)
+
+    def redact_source(self, source: bytes, root: Node) -> bytes:
+        """`source` with matches inside string literal nodes masked byte for byte."""
+        from .literals import STRING_NODE_TYPES  # literals imports core, which imports this module
+
+        edits: List[Tuple[int, int, bytes]] = []
+        stack = [root]
+        while stack:
+            node = stack.pop()
+            if node.type in STRING_NODE_TYPES:
+                raw = bytes(source[node.start_byte:node.end_byte])
+                text = raw.decode("utf-8", errors="surrogateescape")
+                for start, end, name in self._spans(text):
+                    begin = node.start_byte + len(text[:start].encode("utf-8", errors="surrogateescape"))
+                    secret = text[start:end].encode("utf-8", errors="surrogateescape")
+                    # Multi-byte characters become several mask bytes, so the length in bytes holds.
+                    mask = _mask(secret.decode("latin-1"), name).encode("latin-1")
+                    edits.append((begin, begin + len(secret), mask))
+                continue  # nested literals (template substitutions) are covered by the outer span
+            stack.extend(node.children)
+        if not edits:
+            return source
+        data = bytearray(source)
+        for start, end, mask in edits:
+            data[start:end] = mask
+        self.count += len(edits)
+        return bytes(data)
+
+
+def apply(source: bytes, root: Node) -> bytes:
+    """The active redactor's view of `source` (unchanged when redaction is off)."""
+    if ACTIVE is None:
+        return source
+    return ACTIVE.redact_source(source, root)
+
+
+def apply_text(text: str) -> str:
+    return text if ACTIVE is None else ACTIVE.redact_text(text)
+
+
+def fingerprint() -> Optional[List[List[str]]]:
+    """The active patterns, for cache keys: results cached under one setting never serve another."""
+    return None if ACTIVE is None else [[p.name, p.regex.pattern] for p in ACTIVE.patterns]
+
+
+__all__ = [
+    "BUILTIN_PATTERNS",
+    "MASK_CHAR",
+    "Redactor",
+    "SecretPattern",
+    "apply",
+    "apply_text",
+    "builtin_patterns",
+    "compile_pattern",
+    "fingerprint",
+]
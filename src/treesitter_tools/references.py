@@ -0,0 +1,325 @@
+"""
+References to a name, classified by how they use it: `read`, `write` (assigned,
+augmented, incremented, deleted, or written into by element: `self.items[k] = v`),
+or `call`. The classification comes from the identifier's parent nodes, so it tells
+`self.count += 1` from `return self.count` and from `self.count()` without type
+information, and answers "which functions mutate field X" where a plain list of
+references cannot.
+
+Names are matched by text, not resolved: `count` as a local and as a field of any
+object are both found, and `member` tells the `obj.count` accesses apart. Positions
+that declare the name (definitions, parameters, `let`/`var`/`const` declarators,
+imports, keyword-argument names, object keys) are not references. Assignments in
+Python and Go's `:=`, which declare implicitly, count as writes.
+"""
+
+from __future__ import annotations
+
+import json
+from dataclasses import dataclass
+from pathlib import Path
+from typing import Collection, Dict, Iterator, List, Optional, Sequence, Tuple
+
+from tree_sitter import Node
+
+from . import failures
+from .core import ParsedFile, iter_function_nodes, iter_source_files, parse_file
+from .resolver.resolve import PARAMETER_NODE_TYPES
+
+ACCESS_KINDS = ("read", "write", "call")
+
+IDENTIFIER_TYPES = {
+    "identifier",
+    "field_identifier",  # Go, Rust, C/C++ members
+    "property_identifier",  # JavaScript/TypeScript members
+    "private_property_identifier",  # JavaScript `#field`
+    "shorthand_property_identifier",  # JavaScript `{name}` (reads `name`)
+    "type_identifier",
+}
+
+# Member accesses: node type -> field holding the member's name.
+MEMBER_FIELDS = {
+    "attribute": "attribute",  # Python
+    "member_expression": "property",  # JavaScript/TypeScript
+    "selector_expression": "field",  # Go
+    "field_expression": "field",  # Rust, C/C++
+    "field_access": "field",  # Java
+}
+
+# Calls: node type -> field holding the callee.
+CALL_TARGETS = {
+    "call": "function",
+    "call_expression": "function",
+    "new_expression": "constructor",
+    "object_creation_expression": "type",
+    "macro_invocation": "macro",
+}
+
+# Writes: node type -> field holding what is written (None: any child).
+WRITE_TARGETS: Dict[str, Optional[str]] = {
+    "assignment": "left",  # Python
+    "augmented_assignment": "left",
+    "for_statement": "left",
+    "for_in_clause": "left",
+    "named_expression": "name",
+    "delete_statement": None,
+    "assignment_expression": "left",  # JavaScript/TypeScript, Java, C/C++, Rust
+    "augmented_assignment_expression": "left",
+    "compound_assignment_expr": "left",  # Rust
+    "update_expression": None,  # `x++`, `--x`
+    "for_in_statement": "left",
+    "assignment_statement": "left",  # Go
+    "short_var_declaration": "left",
+    "inc_statement": None,
+    "dec_statement": None,
+    "range_clause": "left",
+}
+
+# Element accesses: node type -> field holding the container, which an element write mutates.
+ELEMENT_OF = {
+    "subscript": "value",
+    "subscript_expression": "object",
+    "index_expression": "operand",
+    "array_access": "array",
+}
+
+# Nodes an expression is climbed through to reach the construct using it.
+_TRANSPARENT = {
+    "parenthesized_expression",
+    "expression_list",
+    "pattern_list",
+    "tuple_pattern",
+    "list_pattern",
+    "list_splat_pattern",
+    "array_pattern",
+    "literal_element",
+    "non_null_expression",
+}
+
+# Parent node type suffixes whose name-like fields declare the name there.
+_DECLARING_SUFFIXES = ("_definition", "_declaration", "_declarator", "_item", "_spec", "_signature")
+_NAME_FIELDS = {"name", "declarator", "pattern", "property", "alias"}
+_NOT_REFERENCES = {
+    ("keyword_argument", "name"),
+    ("pair", "key"),
+    ("keyed_element", "key"),
+    ("import_specifier", "name"),
+    ("export_specifier", "alias"),
+}
+_PARAMETER_FIELDS = {None, "name", "pattern"}
+_NAME_LISTS = {"global_statement", "nonlocal_statement"}
+_IMPORT_TYPES = {
+    "import_statement",
+    "import_from_statement",
+    "import_declaration",
+    "import_spec",
+    "use_declaration",
+    "package_clause",
+    "package_declaration",
+    "preproc_include",
+}
+
+
+def _field_of(parent: Node, child: Node) -> Optional[str]:
+    for i, node in enumerate(parent.children):
+        if node == child:
+            return parent.field_name_for_child(i)
+    return None
+
+
+def _climb(node: Node) -> Tuple[Node, Optional[Node], Optional[str]]:
+    """(child, parent, field): the first construct above `node` that is not a grouping or pattern node."""
+    child, parent = node, node.parent
+    while parent is not None and parent.type in _TRANSPARENT:
+        child, parent = parent, parent.parent
+    return child, parent, _field_of(parent, child) if parent is not None else None
+
+
+def _inside(node: Node, types: set) -> bool:
+    current = node.parent
+    while current is not None:
+        if current.type in types:
+            return True
+        current = current.parent
+    return False
+
+
+def _declares(node: Node, parent: Node, field_name: Optional[str]) -> bool:
+    kind = parent.type
+    if kind in PARAMETER_NODE_TYPES and field_name in _PARAMETER_FIELDS:
+        return True
+    if field_name in _NAME_FIELDS and kind.endswith(_DECLARING_SUFFIXES):
+        return True
+    if (kind, field_name) in _NOT_REFERENCES or kind in _NAME_LISTS:
+        return True
+    return _inside(node, _IMPORT_TYPES)
+
+
+def is_member(node: Node) -> bool:
+    """Whether identifier `node` names a member reached through an object (`obj.name`)."""
+    parent = node.parent
+    if parent is None:
+        return False
+    field_name = _field_of(parent, node)
+    if parent.type == "method_invocation":  # Java: `name` is a member when there is an `object`
+        return field_name == "name" and parent.child_by_field_name("object") is not None
+    return parent.type in MEMBER_FIELDS and MEMBER_FIELDS[parent.type] == field_name
+
+
+def reference_access(node: Node) -> Optional[str]:
+    """
+    "read", "write", or "call" for an identifier that refers to something, from its
+    parent nodes; None where it declares a name instead.
+    """
+    parent = node.parent
+    if parent is None:
+        return "read"
+    field_name = _field_of(parent, node)
+    if parent.type == "method_invocation" and field_name == "name":  # Java `obj.m()`
+        return "call"
+    if parent.type in MEMBER_FIELDS and MEMBER_FIELDS[parent.type] == field_name:
+        target = parent  # `obj.name` is what gets called or assigned
+    elif _declares(node, parent, field_name):
+        return None
+    else:
+        target = node
+    child, parent, field_name = _climb(target)
+    if parent is None:
+        return "read"
+    if parent.type in CALL_TARGETS and CALL_TARGETS[parent.type] == field_name:
+        return "call"
+    while parent is not None and parent.type in ELEMENT_OF and ELEMENT_OF[parent.type] == field_name:
+        child, parent, field_name = _climb(parent)  # `items[k] = v` writes into `items`
+    if parent is not None and parent.type in WRITE_TARGETS and WRITE_TARGETS[parent.type] in (None, field_name):
+        return "write"
+    return "read"
+
+
+@dataclass
+class Reference:
+    path: str
+    line: int  # 1-based
+    column: int  # 1-based
+    name: str
+    access: str  # "read", "write", or "call"
+    member: bool  # reached through an object: `obj.name`, `obj->name`, `pkg.Name`
+    function: Optional[str]  # qualified name of the innermost enclosing function
+    code: str  # the source line, stripped
+
+    def to_dict(self) -> dict:
+        return {
+            "path": self.path,
+            "line": self.line,
+            "column": self.column,
+            "name": self.name,
+            "access": self.access,
+            "member": self.member,
+            "function": self.function,
+            "code": self.code,
+        }
+
+
+def file_references(
+    parsed: ParsedFile,
+    label: str,
+    names: Collection[str],
+    access: Collection[str] = (),
+    member: Optional[bool] = None,
+) -> List[Reference]:
+    """
+    References in `parsed` to any of `names`, in source order; only those with an
+    `access` in `access` (when given) and, with `member` set, only member accesses
+    (True) or only plain names (False).
+    """
+    functions = sorted(
+        ((fn.node.start_byte, fn.node.end_byte, fn.qualified_name) for fn in iter_function_nodes(parsed)),
+        key=lambda span: span[0],
+    )
+    lines = parsed.source.splitlines()
+    found: List[Reference] = []
+    stack = [parsed.root]
+    while stack:
+        node = stack.pop()
+        stack.extend(reversed(node.children))
+        if node.type not in IDENTIFIER_TYPES or parsed.text(node) not in names:
+            continue
+        how = reference_access(node)
+        if how is None or (access and how not in access):
+            continue
+        through = is_member(node)
+        if member is not None and through != member:
+            continue
+        enclosing = None
+        for start, end, name in functions:
+            if start > node.start_byte:
+                break
+            if node.end_byte <= end:
+                enclosing = name  # later spans that still contain the identifier are nested deeper
+        row = node.start_point[0]
+        code = lines[row].decode("utf-8", errors="replace").strip() if row < len(lines) else ""
+        found.append(Reference(
+            label, row + 1, node.start_point[1] + 1, parsed.text(node), how, through, enclosing, code,
+        ))
+    return found
+
+
+def iter_references(
+    root: Path,
+    names: Sequence[str],
+    include: Sequence[str] | None = None,
+    exclude: Sequence[str] | None = None,
+    access: Collection[str] = (),
+    member: Optional[bool] = None,
+) -> Iterator[Reference]:
+    """References to `names` in a file or every source file under a directory (see `file_references`)."""
+    unknown = sorted(set(access) - set(ACCESS_KINDS))
+    if unknown:
+        raise ValueError(f"Unknown access '{unknown[0]}' (expected {', '.join(ACCESS_KINDS)})")
+    root = Path(root)
+    if root.is_file():
+        targets = [(root, root.as_posix())]
+    else:
+        base = root.resolve()
+        targets = [(p, p.relative_to(base).as_posix()) for p in iter_source_files(base, include, exclude)]
+    for path, label in targets:
+        try:
+            parsed = parse_file(path)
+        except (ValueError, RuntimeError, OSError) as exc:
+            failures.record(path, exc)
+            continue
+        yield from file_references(parsed, label, set(names), access, member)
+
+
+def references_to_json(references: Sequence[Reference]) -> str:
+    return json.dumps([r.to_dict() for r in references], indent=2, ensure_ascii=False)
+
+
+def references_to_ndjson(references: Sequence[Reference]) -> str:
+    return "".join(json.dumps(r.to_dict(), ensure_ascii=False) + "\n" for r in references)
+
+
+def references_to_text(references: Sequence[Reference]) -> str:
+    """`path:line:column [function] access code`, one reference per line."""
+    lines = []
+    for r in references:
+        function = f" [{r.function}]" if r.function else ""
+        lines.append(f"{r.path}:{r.line}:{r.column}{function} {r.access} {r.code}")
+    return "".join(line + "\n" for line in lines)
+
+
+__all__ = [
+    "ACCESS_KINDS",
+    "CALL_TARGETS",
+    "ELEMENT_OF",
+    "IDENTIFIER_TYPES",
+    "MEMBER_FIELDS",
+    "WRITE_TARGETS",
+    "Reference",
+    "file_references",
+    "is_member",
+    "iter_references",
+    "reference_access",
+    "references_to_json",
+    "references_to_ndjson",
+    "references_to_text",
+]
@@ -0,0 +1,294 @@
+"""
+Region-of-interest extraction: the declarations around a line range or a position.
+
+Tools that only hold a stack-trace frame (`handler.go:133:7`) or a diff hunk
+(`handler.go:120-180`) get back the smallest named declaration(s) enclosing it, with
+their source, instead of parsing the file themselves. A position, or a range inside
+one declaration, yields the innermost declaration containing it; a range that spans
+several top-level declarations (or module-level code between them) yields, per
+declaration it touches, the innermost one containing the part of the range inside it.
+Lines and columns are 1-based; columns count bytes, like Tree-sitter points and
+`resolve`.
+
+For coverage and crash-report tooling that needs to aggregate by symbol rather than by
+line, `SymbolMap` attributes any number of lines of one file to their enclosing
+declaration, function, and type after a single parse.
+"""
+
+from __future__ import annotations
+
+import re
+from dataclasses import dataclass
+from pathlib import Path
+from typing import Iterable, List, Optional, Sequence
+
+from . import redact
+from .core import CodeSymbol, ParsedFile, _enclosing_names, assign_symbol_ids, parse_file, symbols_from_tree
+from .index import _doc_start
+from .normalize import FUNCTION_KINDS, KINDS, normalize_symbols
+from .rename import parse_location
+
+_RANGE = re.compile(r"^(?P<path>.+):(?P<start>\d+)(?:-(?P<end>\d+)|,(?P<count>\d+))$")
+
+
+@dataclass
+class Target:
+    path: Path
+    start_line: int
+    end_line: int
+    column: Optional[int] = None
+
+    @property
+    def label(self) -> str:
+        where = f"{self.start_line}" if self.start_line == self.end_line else f"{self.start_line}-{self.end_line}"
+        return f"{self.path.as_posix()}:{where}" + (f":{self.column}" if self.column is not None else "")
+
+
+def parse_range(spec: str) -> Target:
+    """`PATH:START-END`, or a diff hunk's `PATH:START,COUNT`."""
+    match = _RANGE.match(spec)
+    if not match:
+        raise ValueError(f"Expected PATH:START-END or PATH:START,COUNT, got '{spec}'")
+    start = int(match["start"])
+    end = int(match["end"]) if match["end"] is not None else start + max(int(match["count"]), 1) - 1
+    if start < 1 or end < start:
+        raise ValueError(f"Invalid line range in '{spec}' (lines are 1-based, START <= END)")
+    return Target(Path(match["path"]), start, end)
+
+
+def parse_position(spec: str) -> Target:
+    """`PATH:LINE[:COLUMN]`, as compilers and stack traces print it."""
+    path, line, column = parse_location(spec)
+    if line < 1 or (column is not None and column < 1):
+        raise ValueError(f"Invalid position in '{spec}' (lines and columns are 1-based)")
+    return Target(path, line, line, column)
+
+
+def _span(symbol: CodeSymbol) -> int:
+    return symbol.end_line - symbol.start_line
+
+
+def _innermost(symbols: Sequence[CodeSymbol], start_line: int, end_line: int) -> Optional[CodeSymbol]:
+    containing = [s for s in symbols if s.start_line <= start_line and end_line <= s.end_line]
+    return min(containing, key=lambda s: (_span(s), -s.start_line), default=None)
+
+
+def enclosing_symbols(symbols: Sequence[CodeSymbol], start_line: int, end_line: int) -> List[CodeSymbol]:
+    """The smallest declaration(s) among `symbols` enclosing lines `start_line`..`end_line` (see the module doc)."""
+    single = _innermost(symbols, start_line, end_line)
+    if single is not None:
+        return [single]
+    touched = [s for s in symbols if s.start_line <= end_line and start_line <= s.end_line]
+    outermost = [
+        s for s in touched
+        if not any(o is not s and o.start_line <= s.start_line and s.end_line <= o.end_line for o in touched)
+    ]
+    found: List[CodeSymbol] = []
+    for symbol in sorted(outermost, key=lambda s: s.start_line):
+        inner = _innermost(symbols, max(start_line, symbol.start_line), min(end_line, symbol.end_line))
+        if inner is not None and all(inner is not f for f in found):
+            found.append(inner)
+    return found
+
+
+def _at_column(parsed: ParsedFile, symbols: Sequence[CodeSymbol], line: int, column: int) -> List[CodeSymbol]:
+    """The symbols whose node contains the byte at `line`:`column` (several declarations can share a line)."""
+    node = parsed.root.descendant_for_point_range((line - 1, column - 1), (line - 1, column - 1))
+    spans, texts = set(), set()
+    while node is not None:
+        spans.add((node.start_point[0] + 1, node.end_point[0] + 1))
+        texts.add(parsed.source[node.start_byte : node.end_byte].decode("utf-8", errors="replace"))
+        node = node.parent
+    around = [s for s in symbols if (s.start_line, s.end_line) in spans]
+    # Siblings on one line share their span; the text tells them apart (unless redaction changed it).
+    return [s for s in around if s.content in texts] or around
+
+
+def _named_symbols(parsed: ParsedFile) -> List[CodeSymbol]:
+    """The file's normalized symbols, each with a qualified name."""
+    symbols = symbols_from_tree(parsed.root, parsed.source, parsed.language)
+    normalize_symbols(symbols, parsed)
+    for symbol, name in zip(symbols, _enclosing_names(symbols)):
+        symbol.qualified_name = symbol.qualified_name or name
+    return symbols
+
+
+def extract_regions(
+    target: Target,
+    context: int = 0,
+    doc: bool = False,
+    enclosing: bool = False,
+    language: Optional[str] = None,
+    max_file_size: Optional[int] = None,
+) -> List[dict]:
+    """
+    The declarations enclosing `target`, shaped like `SymbolIndex.get` results: kind,
+    names, lines, signature, and `source` (widened by `doc` over the comments and
+    decorators directly above, plus `context` lines on each side), with `enclosing`
+    set to the container's declaration line when requested. Empty when the target
+    lies outside every declaration (imports, module-level statements).
+    """
+    parsed = parse_file(target.path, language, max_file_size)
+    symbols = _named_symbols(parsed)
+    lines = parsed.source.decode("utf-8", errors="replace").splitlines(keepends=True)
+    if target.start_line > len(lines):
+        raise ValueError(f"{target.label} is past the end of the file ({len(lines)} lines)")
+    candidates = symbols
+    if target.column is not None and parsed.language not in ("markdown", "notebook"):
+        candidates = _at_column(parsed, symbols, target.start_line, target.column) or symbols
+    results = []
+    for symbol in enclosing_symbols(candidates, target.start_line, target.end_line):
+        start = _doc_start(lines, symbol.start_line) if doc else symbol.start_line
+        start = max(1, start - context)
+        end = min(len(lines), symbol.end_line + context)
+        result = {
+            "path": target.path.as_posix(),
+            "language": symbol.language or parsed.language,
+            "kind": symbol.kind,
+            "name": symbol.name,
+            "qualified_name": symbol.qualified_name,
+            "start_line": symbol.start_line,
+            "end_line": symbol.end_line,
+            "signature": redact.apply_text(symbol.signature) if symbol.signature else symbol.signature,
+            "source": redact.apply_text("".join(lines[start - 1:end])),
+            "source_start_line": start,
+            "source_end_line": end,
+            "enclosing": None,
+        }
+        if enclosing:
+            parent = _innermost(
+                [s for s in symbols if s is not symbol and s.kind not in FUNCTION_KINDS and _span(s) > _span(symbol)],
+                symbol.start_line, symbol.end_line,
+            )
+            if parent is not None:
+                result["enclosing"] = {
+                    "kind": parent.kind,
+                    "qualified_name": parent.qualified_name,
+                    "path": result["path"],
+                    "start_line": parent.start_line,
+                    "end_line": parent.end_line,
+                    "source": redact.apply_text(lines[parent.start_line - 1]),
+                }
+        results.append(result)
+    return results
+
+
+def _reference(symbol: Optional[CodeSymbol]) -> Optional[dict]:
+    if symbol is None:
+        return None
+    return {
+        "kind": symbol.kind,
+        "name": symbol.name,
+        "qualified_name": symbol.qualified_name,
+        "start_line": symbol.start_line,
+        "end_line": symbol.end_line,
+        "id": symbol.id,
+    }
+
+
+@dataclass
+class LineAttribution:
+    path: str
+    line: int
+    symbol: Optional[CodeSymbol]  # the innermost declaration containing the line
+    function: Optional[CodeSymbol]  # the innermost function, method, or constructor
+    type: Optional[CodeSymbol]  # the innermost class, struct, interface, trait, enum, or impl
+
+    def to_dict(self) -> dict:
+        return {
+            "path": self.path,
+            "line": self.line,
+            "symbol": _reference(self.symbol),
+            "function": self.function.qualified_name if self.function is not None else None,
+            "type": self.type.qualified_name if self.type is not None else None,
+        }
+
+
+class SymbolMap:
+    """
+    Line -> enclosing declaration lookups for one file. Each line's innermost symbol
+    (and function, and type) is worked out once, so attributing thousands of covered or
+    crashing lines costs one parse. Symbols carry their content-defined `id`, which
+    stays the same when code above them moves. Lines outside every declaration, or past
+    the end of a file that changed since the lines were recorded, map to None.
+    """
+
+    def __init__(self, parsed: ParsedFile, label: Optional[str] = None):
+        self.path = label or parsed.path.as_posix()
+        self.symbols = _named_symbols(parsed)
+        assign_symbol_ids(self.symbols, self.path)
+        self.line_count = parsed.root.end_point[0] + 1
+        self._innermost = self._paint(self.symbols)
+        self._function = self._paint([s for s in self.symbols if s.kind in FUNCTION_KINDS])
+        self._type = self._paint([s for s in self.symbols if s.kind in KINDS and s.kind not in FUNCTION_KINDS])
+
+    @classmethod
+    def load(
+        cls,
+        path: Path,
+        language: Optional[str] = None,
+        max_file_size: Optional[int] = None,
+        label: Optional[str] = None,
+    ) -> "SymbolMap":
+        return cls(parse_file(Path(path), language, max_file_size), label or Path(path).as_posix())
+
+    def _paint(self, symbols: Sequence[CodeSymbol]) -> List[Optional[CodeSymbol]]:
+        # Wider symbols first, so nested ones overwrite their lines; same ties as `_innermost`.
+        owners: List[Optional[CodeSymbol]] = [None] * (self.line_count + 1)
+        for symbol in sorted(symbols, key=lambda s: (-_span(s), s.start_line)):
+            for line in range(max(symbol.start_line, 1), min(symbol.end_line, self.line_count) + 1):
+                owners[line] = symbol
+        return owners
+
+    def _check(self, line: int) -> bool:
+        if line < 1:
+            raise ValueError(f"Invalid line {line} (lines are 1-based)")
+        return line <= self.line_count
+
+    def symbol_at(self, line: int) -> Optional[CodeSymbol]:
+        """The innermost declaration containing `line`."""
+        return self._innermost[line] if self._check(line) else None
+
+    def attribute(self, line: int) -> LineAttribution:
+        if not self._check(line):
+            return LineAttribution(self.path, line, None, None, None)
+        return LineAttribution(self.path, line, self._innermost[line], self._function[line], self._type[line])
+
+    def attribute_lines(self, lines: Iterable[int]) -> List[LineAttribution]:
+        """One attribution per entry of `lines`, in the same order."""
+        return [self.attribute(line) for line in lines]
+
+
+def group_attributions(attributions: Iterable[LineAttribution]) -> List[dict]:
+    """
+    Attributions aggregated by symbol: `{path, symbol, function, type, count, lines}`
+    per symbol (and per file for lines outside every declaration), most hits first.
+    """
+    groups: dict = {}
+    for item in attributions:
+        key = (item.path, id(item.symbol) if item.symbol is not None else None)
+        group = groups.get(key)
+        if group is None:
+            group = groups[key] = {**item.to_dict(), "count": 0, "lines": []}
+            del group["line"]
+        group["count"] += 1
+        if item.line not in group["lines"]:
+            group["lines"].append(item.line)
+    for group in groups.values():
+        group["lines"].sort()
+    return sorted(
+        groups.values(),
+        key=lambda g: (-g["count"], g["path"], g["symbol"]["start_line"] if g["symbol"] else 0),
+    )
+
+
+__all__ = [
+    "LineAttribution",
+    "SymbolMap",
+    "Target",
+    "enclosing_symbols",
+    "extract_regions",
+    "group_attributions",
+    "parse_position",
+    "parse_range",
+]
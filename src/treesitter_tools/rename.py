@@ -0,0 +1,368 @@
+"""Scope-aware rename of a declaration and every reference to it within its package."""
+
+from __future__ import annotations
+
+import keyword
+import posixpath
+import re
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Dict, Iterator, List, Optional, Tuple
+
+from tree_sitter import Node
+
+from .core import parse_file
+from .patch import cwd_label as _label
+from .rewrite import Edit, FileRewrite, apply_edits
+from .resolver.package import package_files
+from .resolver.scopes import SCOPE_RULES, Binding, FileScopes, Occurrence, Scope
+
+_NAME = re.compile(r"^[A-Za-z_][A-Za-z0-9_]*$")
+_JS_NAME = re.compile(r"^[A-Za-z_$][A-Za-z0-9_$]*$")
+
+# Reserved words a new name must not collide with (Python uses the keyword module).
+KEYWORDS = {
+    "go": set(
+        "break case chan const continue default defer else fallthrough for func go goto if import interface map "
+        "package range return select struct switch type var".split()
+    ),
+    "javascript": set(
+        "await break case catch class const continue debugger default delete do else enum export extends false "
+        "finally for function if import in instanceof let new null return super switch this throw true try typeof "
+        "var void while with yield".split()
+    ),
+    "rust": set(
+        "as async await break const continue crate dyn else enum extern false fn for if impl in let loop match mod "
+        "move mut pub ref return self Self static struct super trait true type unsafe use where while".split()
+    ),
+    "java": set(
+        "abstract assert boolean break byte case catch char class const continue default do double else enum "
+        "extends final finally float for goto if implements import instanceof int interface long native new "
+        "package private protected public return short static strictfp super switch synchronized this throw "
+        "throws transient try void volatile while true false null".split()
+    ),
+    "c": set(
+        "auto break case char const continue default do double else enum extern float for goto if inline int long "
+        "register restrict return short signed sizeof static struct switch typedef union unsigned void volatile "
+        "while".split()
+    ),
+}
+KEYWORDS["typescript"] = KEYWORDS["tsx"] = KEYWORDS["javascript"] | {"interface", "type", "implements"}
+KEYWORDS["cpp"] = KEYWORDS["c"] | set(
+    "bool catch class delete explicit false friend mutable namespace new nullptr operator private protected "
+    "public template this throw true try typename using virtual".split()
+)
+
+_JS_LANGUAGES = {"javascript", "typescript", "tsx"}
+
+
+@dataclass
+class RenameResult:
+    old_name: str
+    new_name: str
+    files: List[FileRewrite] = field(default_factory=list)
+
+    @property
+    def occurrences(self) -> int:
+        return sum(len(f.edits) for f in self.files)
+
+    def patch(self) -> str:
+        """Unified diff of every changed file, with paths relative to the working directory."""
+        return "".join(f.diff(_label(f.path)) for f in self.files)
+
+
+def _loc(scopes: FileScopes, node: Node) -> str:
+    return f"{_label(scopes.parsed.path)}:{node.start_point[0] + 1}:{node.start_point[1] + 1}"
+
+
+def check_name(name: str, language: str) -> None:
+    pattern = _JS_NAME if language in _JS_LANGUAGES else _NAME
+    if not pattern.match(name):
+        raise ValueError(f"'{name}' is not a valid {language} identifier")
+    reserved = keyword.iskeyword(name) if language == "python" else name in KEYWORDS.get(language, ())
+    if reserved:
+        raise ValueError(f"'{name}' is a reserved word in {language}")
+
+
+def _walk(node: Node) -> Iterator[Node]:
+    stack = [node]
+    while stack:
+        current = stack.pop()
+        yield current
+        stack.extend(reversed(current.children))
+
+
+def _import_alias_needed(node: Node) -> bool:
+    """True when `node` declares a name by importing it unaliased (`from m import x`, `import {x}`)."""
+    parent = node.parent
+    if parent is None:
+        return False
+    grand = parent.parent
+    if parent.type == "dotted_name" and grand is not None and grand.type in {"import_statement", "import_from_statement"}:
+        if len(parent.named_children) > 1:
+            raise ValueError("Cannot rename a dotted module import; alias it first (`import a.b as c`)")
+        return True
+    if parent.type == "import_specifier":
+        return parent.child_by_field_name("alias") is None
+    if parent.type in {"use_declaration", "use_list"}:
+        return True
+    return parent.type == "scoped_identifier" and grand is not None and grand.type in {"use_declaration", "use_list"}
+
+
+def _replacement(node: Node, old: str, new: str, alias: bool) -> str:
+    if alias:
+        return f"{old} as {new}"
+    parent = node.parent
+    shorthand = node.type in {"shorthand_property_identifier", "shorthand_property_identifier_pattern"}
+    if parent is not None and node.type == "identifier":
+        shorthand = shorthand or parent.type == "shorthand_field_initializer" or parent.type == "field_pattern"
+    return f"{old}: {new}" if shorthand else new
+
+
+class _Plan:
+    def __init__(self, old: str, new: str):
+        self.old = old
+        self.new = new
+        self.files: Dict[Path, FileScopes] = {}
+        self.edits: Dict[Path, Dict[int, Edit]] = {}
+
+    def add(self, scopes: FileScopes, node: Node, alias: bool = False) -> None:
+        path = scopes.parsed.path
+        self.files[path] = scopes
+        self.edits.setdefault(path, {})[node.start_byte] = Edit(
+            start_byte=node.start_byte,
+            end_byte=node.end_byte,
+            replacement=_replacement(node, self.old, self.new, alias),
+            start_line=node.start_point[0] + 1,
+            end_line=node.end_point[0] + 1,
+        )
+
+    def add_variable(
+        self, scopes: FileScopes, occurrences: List[Occurrence], scope: Scope, local_import: bool = True
+    ) -> None:
+        """
+        Rename a whole variable, refusing when the new name would change what any identifier means.
+        With `local_import`, an unaliased import keeps the imported name and gains an alias.
+        """
+        conflict = _conflict(scopes, scope, occurrences, self.new)
+        if conflict:
+            raise ValueError(f"Cannot rename '{self.old}' to '{self.new}': {conflict}")
+        for occurrence in occurrences:
+            alias = local_import and occurrence.declaration and _import_alias_needed(occurrence.node)
+            self.add(scopes, occurrence.node, alias)
+
+    def result(self) -> RenameResult:
+        files = []
+        for path in sorted(self.edits):
+            source = self.files[path].parsed.source
+            edits = sorted(self.edits[path].values(), key=lambda e: e.start_byte)
+            files.append(FileRewrite(path=path, original=source, rewritten=apply_edits(source, edits), edits=edits))
+        return RenameResult(self.old, self.new, files)
+
+
+def _conflict(scopes: FileScopes, scope: Scope, occurrences: List[Occurrence], new: str) -> Optional[str]:
+    declared = scope.bindings.get(new)
+    if declared:
+        return f"'{new}' is already declared in the same scope at {_loc(scopes, declared[0].node)}"
+    for occurrence in occurrences:
+        if occurrence.declaration:
+            continue
+        other = scopes.resolve(new, occurrence.scope, occurrence.node.start_byte)
+        if other is not None and not other.scope.encloses(scope):
+            return (
+                f"'{new}' declared at {_loc(scopes, other.node)} would shadow the reference at "
+                f"{_loc(scopes, occurrence.node)}"
+            )
+    for occurrence in scopes.occurrences:
+        if occurrence.name != new or occurrence.declaration or not scope.encloses(occurrence.scope):
+            continue
+        binding = occurrence.binding
+        if binding is None or (binding.scope is not scope and binding.scope.encloses(scope)):
+            return f"the existing reference to '{new}' at {_loc(scopes, occurrence.node)} would be captured"
+    return None
+
+
+def _python_imports_home(spec: str, home: Path) -> bool:
+    if spec.startswith("."):
+        dots = len(spec) - len(spec.lstrip("."))
+        rest = spec[dots:]
+        if dots != 1:
+            return False
+        return rest == "" if home.name == "__init__.py" else rest == home.stem
+    return home.name != "__init__.py" and spec.split(".")[-1] == home.stem
+
+
+def _js_imports_home(spec: str, home: Path) -> bool:
+    if not spec.startswith("."):
+        return False
+    target = posixpath.normpath(spec)
+    return "/" not in target and target in {home.name, home.stem}
+
+
+def _occurrence_for(scopes: FileScopes, node: Node) -> Optional[Occurrence]:
+    for occurrence in scopes.occurrences:
+        if occurrence.node == node:
+            return occurrence
+    return None
+
+
+def _module_members(plan: _Plan, scopes: FileScopes, module: Optional[Occurrence], member_types: set) -> None:
+    """Rename `module.old` accesses where `module` is bound to an import of the definition's file."""
+    if module is None or module.binding is None:
+        return
+    key = module.binding.key
+    for node in _walk(scopes.parsed.root):
+        if node.type not in member_types:
+            continue
+        obj = node.child_by_field_name("object")
+        member = node.child_by_field_name("attribute" if node.type == "attribute" else "property")
+        if obj is None or member is None or scopes.parsed.text(member) != plan.old:
+            continue
+        occurrence = _occurrence_for(scopes, obj)
+        if occurrence is not None and occurrence.binding is not None and occurrence.binding.key == key:
+            plan.add(scopes, member)
+
+
+def _imported_variable(plan: _Plan, scopes: FileScopes, ident: Node) -> None:
+    """An unaliased import of the old name: rename it and every use of the local binding."""
+    occurrence = _occurrence_for(scopes, ident)
+    if occurrence is None or occurrence.binding is None:
+        plan.add(scopes, ident)
+        return
+    plan.add_variable(scopes, list(scopes.references(occurrence.binding)), occurrence.binding.scope, local_import=False)
+
+
+def _python_importers(plan: _Plan, home: Path, scopes: FileScopes) -> None:
+    text = scopes.parsed.text
+    for node in _walk(scopes.parsed.root):
+        if node.type == "import_from_statement":
+            module = node.child_by_field_name("module_name")
+            if module is None:
+                continue
+            spec = text(module)
+            for name in node.children_by_field_name("name"):
+                target = name.child_by_field_name("name") if name.type == "aliased_import" else name
+                alias = name.child_by_field_name("alias") if name.type == "aliased_import" else None
+                if target is None or not target.named_children:
+                    continue
+                ident = target.named_children[0]
+                if _python_imports_home(spec, home) and text(target) == plan.old:
+                    if alias is None:
+                        _imported_variable(plan, scopes, ident)
+                    else:
+                        plan.add(scopes, ident)
+                elif spec == "." and text(target) == home.stem and home.name != "__init__.py":
+                    # `from . import module`: rename `module.old` accesses.
+                    _module_members(plan, scopes, _occurrence_for(scopes, alias or ident), {"attribute"})
+        elif node.type == "import_statement":
+            for name in node.children_by_field_name("name"):
+                target = name.child_by_field_name("name") if name.type == "aliased_import" else name
+                alias = name.child_by_field_name("alias") if name.type == "aliased_import" else None
+                if target is None or len(target.named_children) != 1 or not _python_imports_home(text(target), home):
+                    continue
+                _module_members(plan, scopes, _occurrence_for(scopes, alias or target.named_children[0]), {"attribute"})
+
+
+def _js_importers(plan: _Plan, home: Path, scopes: FileScopes) -> None:
+    text = scopes.parsed.text
+    for node in _walk(scopes.parsed.root):
+        if node.type not in {"import_statement", "export_statement"}:
+            continue
+        source = node.child_by_field_name("source")
+        if source is None or not _js_imports_home(text(source).strip("\"'`"), home):
+            continue
+        for child in _walk(node):
+            if child.type in {"import_specifier", "export_specifier"}:
+                name = child.child_by_field_name("name")
+                if name is None or text(name) != plan.old:
+                    continue
+                if child.type == "import_specifier" and child.child_by_field_name("alias") is None:
+                    _imported_variable(plan, scopes, name)
+                else:
+                    plan.add(scopes, name)
+            elif child.type == "namespace_import":
+                idents = [c for c in child.named_children if c.type == "identifier"]
+                if idents:
+                    _module_members(plan, scopes, _occurrence_for(scopes, idents[0]), {"member_expression"})
+
+
+def rename_symbol(
+    path: Path,
+    line: int,
+    column: Optional[int],
+    new_name: str,
+    language: Optional[str] = None,
+) -> RenameResult:
+    """
+    Rename the identifier at `path:line[:column]` (1-based): its declaration and
+    every reference resolving to it. File-level names are followed into the rest
+    of the package: all files of the directory for Go, Java, and C/C++, and
+    importing files in the same directory for Python and JavaScript/TypeScript.
+    Raises ValueError when the new name would collide with or capture another name.
+    """
+    path = Path(path)
+    parsed = parse_file(path, language)
+    rules = SCOPE_RULES.get(parsed.language)
+    if rules is None:
+        raise ValueError(f"Renaming is not supported for {parsed.language}")
+    check_name(new_name, parsed.language)
+    home = FileScopes(parsed, rules)
+    occurrence = home.occurrence_at(line, column)
+    where = f"{_label(path)}:{line}" + (f":{column}" if column is not None else "")
+    if occurrence is None:
+        raise ValueError(f"No renamable identifier at {where}")
+    old = occurrence.name
+    if new_name == old:
+        raise ValueError(f"'{old}' already has that name")
+    binding: Optional[Binding] = occurrence.binding
+    if binding is not None and binding.scope.kind == "class":
+        raise ValueError(f"'{old}' is a class member; renaming members needs type information and is not supported")
+    plan = _Plan(old, new_name)
+
+    package_level = binding is None or binding.scope is home.root
+    imported = binding is not None and any(
+        o.declaration and o.binding is binding and _import_alias_needed(o.node) for o in home.occurrences
+    )
+    if not package_level or imported or rules.package == "file":
+        if binding is None:
+            raise ValueError(f"'{old}' at {where} is not declared in this file")
+        plan.add_variable(home, list(home.references(binding)), binding.scope)
+        return plan.result()
+
+    if rules.package == "directory":
+        files = [home] + package_files(home)
+        if binding is None and not any(old in scopes.root.bindings for scopes in files):
+            raise ValueError(f"'{old}' at {where} is not declared in this package")
+        for scopes in files:
+            occurrences = [
+                o for o in scopes.occurrences if o.name == old and (o.binding is None or o.binding.scope is scopes.root)
+            ]
+            if occurrences:
+                plan.add_variable(scopes, occurrences, scopes.root)
+        return plan.result()
+
+    # Python / JavaScript: the module's own file plus same-directory files importing the name.
+    if binding is None:
+        raise ValueError(f"'{old}' at {where} is not declared in this file")
+    plan.add_variable(home, list(home.references(binding)), binding.scope)
+    importers = _python_importers if parsed.language == "python" else _js_importers
+    for scopes in package_files(home):
+        importers(plan, path, scopes)
+    return plan.result()
+
+
+def parse_location(location: str) -> Tuple[Path, int, Optional[int]]:
+    """Split `PATH:LINE[:COLUMN]`."""
+    parts = location.rsplit(":", 2)
+    try:
+        if len(parts) == 3 and parts[1].isdigit() and parts[2].isdigit():
+            return Path(parts[0]), int(parts[1]), int(parts[2])
+        head, _, tail = location.rpartition(":")
+        if head and tail.isdigit():
+            return Path(head), int(tail), None
+    except ValueError:
+        pass
+    raise ValueError(f"Expected PATH:LINE[:COLUMN], got '{location}'")
+
+
+__all__ = ["KEYWORDS", "RenameResult", "check_name", "parse_location", "rename_symbol"]
@@ -0,0 +1,372 @@
+"""
+Interactive shell for exploring syntax trees and developing queries: load a file (or
+paste a snippet), run queries against it, point at a position to see the node there,
+walk to its parent or children, and print subtrees as S-expressions.
+
+Built on `cmd.Cmd`, so line editing, `help`, and tab completion of commands come from
+readline where it is available; history is kept in a file between sessions. Lines and
+columns are 1-based, as in query output, with columns counted in characters.
+"""
+
+from __future__ import annotations
+
+import cmd
+import os
+import shlex
+from pathlib import Path
+from typing import List, Optional, TextIO
+
+from tree_sitter import Node, Query
+
+from .astdump import node_to_dict, tree_to_sexp
+from .core import ParsedFile, extract_symbols, load_language, parse_file, parse_source, query_tree
+from .playground import render_matches
+from .positions import LineIndex
+from .querylib import load_query
+
+DEFAULT_HISTORY = Path.home() / ".treesitter_tools_history"
+HISTORY_LENGTH = 1000
+# Printed node text is cut to this many characters.
+MAX_NODE_TEXT = 200
+# A line holding only this ends a pasted snippet (`parse LANGUAGE`).
+END_OF_SNIPPET = "."
+
+INTRO = "treesitter-tools repl: `load PATH`, then `query`, `at LINE:COL`, `tree`; `help` lists commands."
+
+
+def _balanced(text: str) -> bool:
+    """Whether every parenthesis and bracket outside strings and comments is closed."""
+    depth, in_string, escaped = 0, False, False
+    for line in text.splitlines():
+        for ch in line:
+            if in_string:
+                if escaped:
+                    escaped = False
+                elif ch == "\\":
+                    escaped = True
+                elif ch == '"':
+                    in_string = False
+            elif ch == '"':
+                in_string = True
+            elif ch == ";":
+                break  # comment to end of line
+            elif ch in "([":
+                depth += 1
+            elif ch in ")]":
+                depth -= 1
+    return depth <= 0 and not in_string
+
+
+def _clip(text: str) -> str:
+    return text if len(text) <= MAX_NODE_TEXT else text[: MAX_NODE_TEXT - 3] + "..."
+
+
+class Repl(cmd.Cmd):
+    """The `treesitter-tools repl` shell; `stdin`/`stdout` default to the terminal."""
+
+    prompt = "ts> "
+    continuation_prompt = "... "
+
+    def __init__(self, stdin: Optional[TextIO] = None, stdout: Optional[TextIO] = None, highlight: bool = False):
+        super().__init__(stdin=stdin, stdout=stdout)
+        if stdin is not None:
+            self.use_rawinput = False
+        self.highlight = highlight
+        self.parsed: Optional[ParsedFile] = None
+        self.label = ""
+        self.node: Optional[Node] = None  # the node `tree`, `text`, `parent`, and `child` act on
+        self.language_override: Optional[str] = None
+
+    # -- helpers ------------------------------------------------------------
+
+    def _print(self, text: str = "") -> None:
+        self.stdout.write(text if text.endswith("\n") else text + "\n")
+
+    def _error(self, message: str) -> None:
+        self._print(f"Error: {message}")
+
+    def _read_line(self, prompt: str) -> Optional[str]:
+        if self.use_rawinput:
+            try:
+                return input(prompt)
+            except EOFError:
+                return None
+        self.stdout.write(prompt)
+        self.stdout.flush()
+        line = self.stdin.readline()
+        return line.rstrip("\r\n") if line else None
+
+    def _require(self) -> ParsedFile:
+        if self.parsed is None:
+            raise ValueError("Nothing loaded; use `load PATH` or `parse LANGUAGE` first")
+        return self.parsed
+
+    def _set(self, parsed: ParsedFile, label: str) -> None:
+        self.parsed, self.label, self.node = parsed, label, parsed.root
+        errors = " (has syntax errors; see `errors`)" if parsed.root.has_error else ""
+        self._print(f"Loaded {label} ({parsed.language}, {len(parsed.source)} bytes, {len(parsed.source.splitlines())} lines){errors}")
+
+    def _describe(self, node: Node) -> str:
+        parsed = self._require()
+        field = None
+        if node.parent is not None:
+            for index, child in enumerate(node.parent.children):
+                if child.id == node.id:
+                    field = node.parent.field_name_for_child(index)
+                    break
+        index = LineIndex(parsed.source)
+        start = index.position(node.start_byte, "utf-32")
+        end = index.position(node.end_byte, "utf-32")
+        chain = []
+        ancestor = node.parent
+        while ancestor is not None:
+            chain.append(ancestor.type)
+            ancestor = ancestor.parent
+        lines = [
+            f"{node.type}{f' (field: {field})' if field else ''}"
+            f"  {start.line + 1}:{start.character + 1}-{end.line + 1}:{end.character + 1}"
+            f"  bytes {node.start_byte}-{node.end_byte}"
+            f"  {node.named_child_count} named children{'  ERROR' if node.is_error else ''}",
+        ]
+        if chain:
+            lines.append("in: " + " > ".join(reversed(chain)))
+        lines.append(_clip(parsed.text(node)))
+        return "\n".join(lines)
+
+    def _run_query(self, text: str) -> None:
+        parsed = self._require()
+        matches = query_tree(parsed.root, parsed.source, Query(load_language(parsed.language), text))
+        self._print(render_matches(matches, parsed.source, highlight=self.highlight))
+
+    # -- cmd.Cmd hooks --------------------------------------------------------
+
+    def onecmd(self, line: str) -> bool:
+        try:
+            return super().onecmd(line)
+        except (ValueError, RuntimeError, OSError, IndexError) as exc:  # QueryError subclasses ValueError
+            self._error(str(exc))
+            return False
+
+    def emptyline(self) -> bool:
+        return False  # don't repeat the last command
+
+    def default(self, line: str) -> None:
+        name = line.split()[0]
+        self._error(f"Unknown command '{name}' (`help` lists commands)")
+
+    # -- commands -------------------------------------------------------------
+
+    def do_load(self, arg: str) -> None:
+        """load PATH [LANGUAGE]: parse a file (language detected unless given); it becomes the current tree."""
+        args = shlex.split(arg)
+        if not args:
+            raise ValueError("Usage: load PATH [LANGUAGE]")
+        path = Path(args[0]).expanduser()
+        self.language_override = args[1] if len(args) > 1 else None
+        self._set(parse_file(path, self.language_override), str(path))
+
+    def do_reload(self, arg: str) -> None:
+        """reload: parse the current file again (after editing it elsewhere)."""
+        parsed = self._require()
+        if not parsed.path.is_file():
+            raise ValueError("The current tree was pasted, not loaded from a file")
+        self._set(parse_file(parsed.path, self.language_override or parsed.language), self.label)
+
+    def do_parse(self, arg: str) -> None:
+        """parse LANGUAGE: paste source lines, ending with a line holding only `.`; they become the current tree."""
+        language = arg.strip()
+        if not language:
+            raise ValueError("Usage: parse LANGUAGE")
+        load_language(language)  # fail before reading the snippet
+        lines: List[str] = []
+        while True:
+            line = self._read_line(self.continuation_prompt)
+            if line is None or line == END_OF_SNIPPET:
+                break
+            lines.append(line)
+        source = ("\n".join(lines) + "\n").encode("utf-8")
+        self._set(ParsedFile(Path("<snippet>"), language, source, parse_source(source, language)), "<snippet>")
+
+    def do_query(self, arg: str) -> None:
+        """query PATTERN: run a query on the current tree; unbalanced parentheses continue on the next lines."""
+        text = arg
+        while not text.strip() or not _balanced(text):
+            line = self._read_line(self.continuation_prompt)
+            if line is None:
+                break
+            text += "\n" + line
+        if not text.strip():
+            raise ValueError("Usage: query PATTERN")
+        self._run_query(text)
+
+    def do_named(self, arg: str) -> None:
+        """named NAME: run a library query (tags, highlights, locals, folds, ...) on the current tree."""
+        parsed = self._require()
+        if not arg.strip():
+            raise ValueError("Usage: named NAME")
+        self._run_query(load_query(parsed.language, arg.strip()))
+
+    def do_at(self, arg: str) -> None:
+        """at LINE[:COL] (or LINE COL): select the smallest named node at a position and describe it."""
+        parsed = self._require()
+        parts = arg.replace(":", " ").split()
+        if not parts or len(parts) > 2 or not all(p.isdigit() for p in parts):
+            raise ValueError("Usage: at LINE[:COL]")
+        line, column = int(parts[0]), int(parts[1]) if len(parts) > 1 else 1
+        offset = LineIndex(parsed.source).offset(line - 1, column - 1, "utf-32")
+        self.node = parsed.root.named_descendant_for_byte_range(offset, offset) or parsed.root
+        self._print(self._describe(self.node))
+
+    def do_node(self, arg: str) -> None:
+        """node: describe the current node."""
+        self._require()
+        self._print(self._describe(self.node))
+
+    def do_parent(self, arg: str) -> None:
+        """parent: select the current node's parent."""
+        self._require()
+        if self.node.parent is None:
+            raise ValueError("Already at the root")
+        self.node = self.node.parent
+        self._print(self._describe(self.node))
+
+    def do_child(self, arg: str) -> None:
+        """child N: select the current node's Nth named child (from 0); `child` alone lists them."""
+        self._require()
+        children = self.node.named_children
+        if not arg.strip():
+            for index, child in enumerate(children):
+                first_line = (self.parsed.text(child).splitlines() or [""])[0]
+                self._print(f"{index}: {child.type}  {_clip(first_line)}")
+            return
+        if not arg.strip().isdigit() or int(arg) >= len(children):
+            raise ValueError(f"No named child {arg.strip()} (the node has {len(children)})")
+        self.node = children[int(arg)]
+        self._print(self._describe(self.node))
+
+    def do_root(self, arg: str) -> None:
+        """root: select the root of the current tree."""
+        self.node = self._require().root
+        self._print(self._describe(self.node))
+
+    def do_tree(self, arg: str) -> None:
+        """tree [DEPTH] [--all]: print the current node's subtree as an S-expression (named nodes unless --all)."""
+        parsed = self._require()
+        args = arg.split()
+        named_only = "--all" not in args
+        depths = [a for a in args if a != "--all"]
+        if depths and not depths[0].isdigit():
+            raise ValueError("Usage: tree [DEPTH] [--all]")
+        max_depth = int(depths[0]) if depths else None
+        self._print(tree_to_sexp({"root": node_to_dict(self.node, parsed.source, max_depth, named_only)}))
+
+    def do_text(self, arg: str) -> None:
+        """text: print the current node's source."""
+        self._print(self._require().text(self.node))
+
+    def do_errors(self, arg: str) -> None:
+        """errors: list ERROR and MISSING nodes of the current tree."""
+        parsed = self._require()
+        found = 0
+        stack = [parsed.root]
+        while stack:
+            node = stack.pop()
+            if node.is_error or node.is_missing:
+                found += 1
+                label = f"MISSING {node.type}" if node.is_missing else "ERROR"
+                self._print(f"{label} at {node.start_point[0] + 1}:{node.start_point[1] + 1}: {_clip(parsed.text(node))}")
+            if node.has_error:
+                stack.extend(reversed(node.children))
+        if not found:
+            self._print("No syntax errors")
+
+    def do_symbols(self, arg: str) -> None:
+        """symbols: list the functions and classes of the current file."""
+        parsed = self._require()
+        if not parsed.path.is_file():
+            raise ValueError("symbols needs a file; use `load PATH`")
+        for symbol in extract_symbols(parsed.path, parsed.language):
+            self._print(f"{symbol.kind} {symbol.name}  {symbol.start_line}-{symbol.end_line}")
+
+    def do_highlight(self, arg: str) -> None:
+        """highlight on|off: colour query captures with ANSI codes."""
+        if arg.strip() not in {"on", "off"}:
+            raise ValueError("Usage: highlight on|off")
+        self.highlight = arg.strip() == "on"
+
+    def do_quit(self, arg: str) -> bool:
+        """quit: leave the shell (also `exit` or Ctrl-D)."""
+        return True
+
+    do_exit = do_quit
+
+    def do_EOF(self, arg: str) -> bool:
+        self._print()
+        return True
+
+    def complete_load(self, text, line, begidx, endidx):
+        directory, _, prefix = text.rpartition("/")
+        base = Path(directory or ".").expanduser()
+        try:
+            names = sorted(p.name + ("/" if p.is_dir() else "") for p in base.iterdir() if p.name.startswith(prefix))
+        except OSError:
+            return []
+        return [f"{directory}/{name}" if directory else name for name in names]
+
+
+def _setup_history(history: Optional[Path]):
+    """Load readline history; returns a function that saves it, or None without readline."""
+    try:
+        import readline
+    except ImportError:  # pragma: no cover - e.g. Windows without pyreadline
+        return None
+    if history is None:
+        return None
+    try:
+        readline.read_history_file(history)
+    except OSError:
+        pass
+    readline.set_history_length(HISTORY_LENGTH)
+
+    def save() -> None:
+        try:
+            history.parent.mkdir(parents=True, exist_ok=True)
+            readline.write_history_file(history)
+        except OSError:
+            pass
+
+    return save
+
+
+def run_repl(
+    path: Optional[Path] = None,
+    language: Optional[str] = None,
+    history: Optional[Path] = DEFAULT_HISTORY,
+    highlight: bool = False,
+    stdin: Optional[TextIO] = None,
+    stdout: Optional[TextIO] = None,
+) -> None:
+    """Run the shell until `quit` or end of input, with `path` loaded first if given."""
+    shell = Repl(stdin, stdout, highlight)
+    interactive = stdin is None and os.isatty(0)
+    save = _setup_history(history) if interactive else None
+    if not interactive:
+        shell.prompt = ""
+        shell.continuation_prompt = ""
+    if path is not None:
+        shell.onecmd(f"load {shlex.quote(str(path))}" + (f" {language}" if language else ""))
+    intro = INTRO if interactive else None
+    try:
+        while True:
+            try:
+                shell.cmdloop(intro)
+                break
+            except KeyboardInterrupt:  # drop the line being typed, keep the session
+                shell._print("^C")
+                intro = None
+    finally:
+        if save is not None:
+            save()
+
+
+__all__ = ["DEFAULT_HISTORY", "Repl", "run_repl"]
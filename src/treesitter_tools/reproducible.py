@@ -0,0 +1,94 @@
+"""
+Reproducible runs: byte-identical output from the same command on the same tree.
+
+Directory walks are always sorted and `--jobs` yields results in input order, so most
+output is already stable. `ENABLED` (the global `--deterministic` flag) removes what
+still varies between runs:
+
+- the clock: ages and timestamps are measured against `SOURCE_DATE_EPOCH` (the
+  reproducible-builds convention) when it is set, and against the Unix epoch otherwise;
+- measurements: durations are reported as zero and memory peaks as unknown;
+- string hashing, which Python salts per process so that sets of names iterate in a
+  different order every run: `pin_hash_seed` restarts the CLI with `PYTHONHASHSEED=0`.
+"""
+
+from __future__ import annotations
+
+import os
+import sys
+import time
+from typing import Optional, Sequence
+
+# Set by the CLI's global `--deterministic` flag.
+ENABLED = False
+
+FLAG = "--deterministic"
+ENV_VAR = "TREESITTER_TOOLS_DETERMINISTIC"
+EPOCH_VAR = "SOURCE_DATE_EPOCH"
+SEED_VAR = "PYTHONHASHSEED"
+
+
+def source_date_epoch() -> Optional[int]:
+    """`SOURCE_DATE_EPOCH` in seconds, or None when unset; a value that is not an integer raises ValueError."""
+    value = os.environ.get(EPOCH_VAR, "").strip()
+    if not value:
+        return None
+    try:
+        return int(value)
+    except ValueError:
+        raise ValueError(f"{EPOCH_VAR} must be a number of seconds since 1970, not {value!r}") from None
+
+
+def now() -> float:
+    """The current time, or the fixed one under `ENABLED`."""
+    if not ENABLED:
+        return time.time()
+    return float(source_date_epoch() or 0)
+
+
+def time_ns() -> int:
+    return int(now() * 1_000_000_000) if ENABLED else time.time_ns()
+
+
+def perf_counter() -> float:
+    """`time.perf_counter`, frozen under `ENABLED` so every measured duration is zero."""
+    return 0.0 if ENABLED else time.perf_counter()
+
+
+def measured(value):
+    """A measurement that differs between runs (a memory peak), or None under `ENABLED`."""
+    return None if ENABLED else value
+
+
+def requested(argv: Sequence[str]) -> bool:
+    """Whether the command line or the environment asks for a deterministic run."""
+    if FLAG in argv:
+        return True
+    return os.environ.get(ENV_VAR, "").strip().lower() in ("1", "true", "yes", "on")
+
+
+def hash_seed_pinned() -> bool:
+    return not sys.flags.hash_randomization or os.environ.get(SEED_VAR, "random") != "random"
+
+
+def pin_hash_seed(argv: Sequence[str]) -> None:
+    """Re-run this process as `argv` with string hashing fixed, unless it already is."""
+    if hash_seed_pinned():
+        return
+    os.environ[SEED_VAR] = "0"  # worker processes inherit it
+    sys.stdout.flush()
+    sys.stderr.flush()
+    os.execv(sys.executable, [sys.executable, *argv])
+
+
+__all__ = [
+    "ENABLED",
+    "hash_seed_pinned",
+    "measured",
+    "now",
+    "perf_counter",
+    "pin_hash_seed",
+    "requested",
+    "source_date_epoch",
+    "time_ns",
+]
@@ -0,0 +1,28 @@
+"""
+Name resolution: scope trees per file, and the definition each identifier refers
+to (a parameter, a local, a package-level declaration, or an imported one).
+"""
+
+from .package import JS_EXTENSIONS, go_package, js_module_file, package_files, python_module_file
+from .resolve import MAX_IMPORT_HOPS, PARAMETER_NODE_TYPES, Location, Resolution, Resolver, resolve_at
+from .scopes import SCOPE_RULES, Binding, FileScopes, Occurrence, Scope, ScopeRules
+
+__all__ = [
+    "JS_EXTENSIONS",
+    "MAX_IMPORT_HOPS",
+    "PARAMETER_NODE_TYPES",
+    "SCOPE_RULES",
+    "Binding",
+    "FileScopes",
+    "Location",
+    "Occurrence",
+    "Resolution",
+    "Resolver",
+    "Scope",
+    "ScopeRules",
+    "go_package",
+    "js_module_file",
+    "package_files",
+    "python_module_file",
+    "resolve_at",
+]
@@ -0,0 +1,92 @@
+"""Files that share file-level names with a given file: its package, or the modules it imports."""
+
+from __future__ import annotations
+
+import posixpath
+from pathlib import Path
+from typing import List, Optional
+
+from ..core import ParsedFile, detect_language, parse_file
+from .scopes import SCOPE_RULES, FileScopes
+
+JS_EXTENSIONS = (".ts", ".tsx", ".js", ".jsx", ".mjs", ".cjs")
+
+
+def go_package(parsed: ParsedFile) -> Optional[str]:
+    for child in parsed.root.children:
+        if child.type == "package_clause":
+            for node in child.named_children:
+                return parsed.text(node)
+    return None
+
+
+def package_files(home: FileScopes) -> List[FileScopes]:
+    """Other files of the same language family in the definition's directory (and Go package)."""
+    path = home.parsed.path
+    others = []
+    for candidate in sorted(path.parent.iterdir()):
+        if not candidate.is_file() or candidate.resolve() == path.resolve():
+            continue
+        language = detect_language(candidate)
+        if language is None or SCOPE_RULES.get(language) is not home.rules:
+            continue
+        try:
+            parsed = parse_file(candidate, language)
+        except (ValueError, RuntimeError, OSError):
+            continue
+        if language == "go" and go_package(parsed) != go_package(home.parsed):
+            continue
+        others.append(FileScopes(parsed, home.rules))
+    return others
+
+
+def _python_import_root(directory: Path) -> Path:
+    """The directory absolute imports start from: above the outermost package (`__init__.py`) directory."""
+    while (directory / "__init__.py").is_file() and directory.parent != directory:
+        directory = directory.parent
+    return directory
+
+
+def python_module_file(spec: str, importer: Path) -> Optional[Path]:
+    """The local file `import spec` / `from spec import ...` in `importer` loads, if it exists."""
+    if spec.startswith("."):
+        dots = len(spec) - len(spec.lstrip("."))
+        base = importer.parent
+        for _ in range(dots - 1):
+            base = base.parent
+        rest = spec[dots:]
+    else:
+        base = _python_import_root(importer.parent)
+        rest = spec
+    if not rest:
+        init = base / "__init__.py"
+        return init if init.is_file() else None
+    target = base.joinpath(*rest.split("."))
+    for candidate in (target.with_name(target.name + ".py"), target / "__init__.py"):
+        if candidate.is_file():
+            return candidate
+    return None
+
+
+def js_module_file(spec: str, importer: Path) -> Optional[Path]:
+    """The local file a relative `import ... from spec` in `importer` loads, trying the usual extensions."""
+    if not spec.startswith("."):
+        return None  # bare specifiers come from node_modules
+    target = importer.parent / posixpath.normpath(spec)
+    if target.is_file():
+        return target
+    if target.suffix == ".js":
+        # TypeScript ESM imports name the compiled file: `./util.js` is `./util.ts`.
+        for extension in (".ts", ".tsx"):
+            if target.with_suffix(extension).is_file():
+                return target.with_suffix(extension)
+    for extension in JS_EXTENSIONS:
+        if target.with_name(target.name + extension).is_file():
+            return target.with_name(target.name + extension)
+    for extension in JS_EXTENSIONS:
+        if (target / f"index{extension}").is_file():
+            return target / f"index{extension}"
+    return None
+
+
+__all__ = ["JS_EXTENSIONS", "go_package", "js_module_file", "package_files", "python_module_file"]
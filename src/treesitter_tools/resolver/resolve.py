@@ -0,0 +1,284 @@
+"""Resolve an identifier to its definition: in its scope chain, its package, or the module it was imported from."""
+
+from __future__ import annotations
+
+import json
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Dict, List, Optional, Tuple
+
+from tree_sitter import Node
+
+from ..core import parse_file
+from .package import js_module_file, package_files, python_module_file
+from .scopes import SCOPE_RULES, Binding, FileScopes, Occurrence
+
+# Nodes whose identifiers are function parameters (Python, JS/TS, Go, Rust, Java, C/C++).
+PARAMETER_NODE_TYPES = {
+    "parameters",
+    "lambda_parameters",
+    "typed_parameter",
+    "default_parameter",
+    "typed_default_parameter",
+    "formal_parameters",
+    "required_parameter",
+    "optional_parameter",
+    "parameter_list",
+    "parameter_declaration",
+    "variadic_parameter_declaration",
+    "parameter",
+    "closure_parameters",
+    "formal_parameter",
+    "inferred_parameters",
+}
+
+_IMPORT_STATEMENTS = {"import_statement", "import_from_statement"}
+
+# Re-exports followed before giving up (`from .impl import x` in an `__init__.py`, and so on).
+MAX_IMPORT_HOPS = 8
+
+
+@dataclass(frozen=True)
+class Location:
+    path: Path
+    line: int  # 1-based
+    column: int  # 1-based
+    access: Optional[str] = None  # references: "read", "write", or "call" (see `references.reference_access`)
+
+    @classmethod
+    def of(cls, path: Path, node: Node, access: Optional[str] = None) -> "Location":
+        return cls(Path(path), node.start_point[0] + 1, node.start_point[1] + 1, access)
+
+    def __str__(self) -> str:
+        return f"{self.path.as_posix()}:{self.line}:{self.column}"
+
+    def to_dict(self) -> dict:
+        data = {"path": self.path.as_posix(), "line": self.line, "column": self.column}
+        if self.access is not None:
+            data["access"] = self.access
+        return data
+
+
+@dataclass
+class Resolution:
+    name: str
+    # "parameter", "local", "package" (file- or package-level), "member" (class body),
+    # "import" (followed to the imported declaration), "module", or "unresolved".
+    kind: str
+    reference: Location
+    definition: Optional[Location] = None
+    imported_at: Optional[Location] = None  # the import that brings the name into the file
+    references: List[Location] = field(default_factory=list)
+
+    def to_dict(self) -> dict:
+        return {
+            "name": self.name,
+            "kind": self.kind,
+            "reference": self.reference.to_dict(),
+            "definition": self.definition.to_dict() if self.definition else None,
+            "imported_at": self.imported_at.to_dict() if self.imported_at else None,
+            "references": [loc.to_dict() for loc in self.references],
+        }
+
+    def to_json(self) -> str:
+        return json.dumps(self.to_dict(), indent=2)
+
+    def to_text(self) -> str:
+        if self.definition is None:
+            lines = [f"{self.reference}: '{self.name}' is unresolved (builtin, global, or from another package)"]
+        else:
+            lines = [f"{self.reference}: '{self.name}' ({self.kind}) -> {self.definition}"]
+        if self.imported_at is not None:
+            lines.append(f"  imported at {self.imported_at}")
+        if self.references:
+            lines.append(f"  references ({len(self.references)}):")
+            lines.extend(f"    {loc}" + (f" {loc.access}" if loc.access else "") for loc in self.references)
+        return "\n".join(lines) + "\n"
+
+
+def _under(node: Node, types: set, stop: Node) -> Optional[Node]:
+    """The nearest ancestor of `node` with a type in `types`, not looking past `stop`."""
+    current = node.parent
+    while current is not None and current != stop:
+        if current.type in types:
+            return current
+        current = current.parent
+    return None
+
+
+class Resolver:
+    """
+    Resolves identifiers across files, caching each file's scopes. File-level names
+    are looked up in the rest of the package for Go, Java, and C/C++ (every file of
+    the directory) and followed through imports for Python and JavaScript/TypeScript.
+    """
+
+    def __init__(self):
+        self._files: Dict[Path, FileScopes] = {}
+        self._packages: Dict[Path, List[FileScopes]] = {}
+
+    def scopes(self, path: Path, language: Optional[str] = None) -> FileScopes:
+        key = Path(path).resolve()
+        if key not in self._files:
+            parsed = parse_file(path, language)
+            if parsed.language not in SCOPE_RULES:
+                raise ValueError(f"Scope analysis is not supported for {parsed.language}")
+            self._files[key] = FileScopes(parsed)
+        return self._files[key]
+
+    def package(self, home: FileScopes) -> List[FileScopes]:
+        """`home` and the other files of its package (just `home` outside directory-package languages)."""
+        key = home.parsed.path.resolve()
+        if key not in self._packages:
+            others = package_files(home) if home.rules.package == "directory" else []
+            for scopes in others:
+                self._files.setdefault(scopes.parsed.path.resolve(), scopes)
+            self._packages[key] = [home] + [self._files[s.parsed.path.resolve()] for s in others]
+        return self._packages[key]
+
+    def resolve_at(
+        self, path: Path, line: int, column: Optional[int] = None, language: Optional[str] = None,
+        references: bool = False,
+    ) -> Resolution:
+        """Resolve the identifier at `path:line[:column]` (1-based; no column: the line's first declaration)."""
+        home = self.scopes(path, language)
+        occurrence = home.occurrence_at(line, column)
+        if occurrence is None:
+            where = f"{Path(path).as_posix()}:{line}" + (f":{column}" if column is not None else "")
+            raise ValueError(f"No identifier at {where}")
+        return self.resolve(home, occurrence, references)
+
+    def resolve(self, home: FileScopes, occurrence: Occurrence, references: bool = False) -> Resolution:
+        path = home.parsed.path
+        result = Resolution(occurrence.name, "unresolved", Location.of(path, occurrence.node))
+        binding = occurrence.binding
+        owner = home
+        if binding is None and home.rules.package == "directory":
+            owner, binding = self._package_binding(home, occurrence.name)
+        if binding is None:
+            return result
+        result.definition = Location.of(owner.parsed.path, binding.node)
+        result.kind = self._kind(owner, binding)
+        if references:
+            result.references = self._references(owner, binding)
+        statement = _under(binding.node, _IMPORT_STATEMENTS, binding.scope.node)
+        if statement is not None:
+            result.imported_at = result.definition
+            followed = self._follow_import(owner, binding.node, statement, MAX_IMPORT_HOPS)
+            if followed is not None:
+                result.kind, result.definition = followed
+            else:
+                result.kind = "import"
+        return result
+
+    def _package_binding(self, home: FileScopes, name: str) -> Tuple[FileScopes, Optional[Binding]]:
+        for scopes in self.package(home)[1:]:
+            bindings = scopes.root.bindings.get(name)
+            if bindings:
+                return scopes, bindings[0]
+        return home, None
+
+    def _kind(self, scopes: FileScopes, binding: Binding) -> str:
+        if binding.scope is scopes.root:
+            return "package"
+        if binding.scope.kind == "class":
+            return "member"
+        node = binding.node
+        parent = node.parent
+        if parent is not None and parent.type == "arrow_function" and parent.child_by_field_name("parameter") == node:
+            return "parameter"
+        if _under(node, PARAMETER_NODE_TYPES, binding.scope.node) is not None:
+            return "parameter"
+        return "local"
+
+    def _references(self, owner: FileScopes, binding: Binding) -> List[Location]:
+        from ..references import reference_access
+
+        def at(path: Path, node: Node) -> Location:
+            return Location.of(path, node, reference_access(node) or "read")
+
+        found = [at(owner.parsed.path, o.node) for o in owner.references(binding) if not o.declaration]
+        if binding.scope is owner.root and owner.rules.package == "directory":
+            for scopes in self.package(owner):
+                if scopes is owner:
+                    continue
+                found.extend(
+                    at(scopes.parsed.path, o.node)
+                    for o in scopes.occurrences
+                    if o.name == binding.name and not o.declaration and o.binding is None
+                )
+        return sorted(found, key=lambda loc: (loc.path.as_posix(), loc.line, loc.column))
+
+    def _follow_import(
+        self, scopes: FileScopes, ident: Node, statement: Node, hops: int
+    ) -> Optional[Tuple[str, Location]]:
+        """(kind, definition) of what an import binds, when it comes from a local file."""
+        if scopes.parsed.language == "python":
+            target, name = self._python_import(scopes, ident, statement)
+        else:
+            target, name = self._js_import(scopes, ident, statement)
+        if target is None:
+            return None
+        if name is None:
+            return "module", Location(target, 1, 1)
+        try:
+            other = self.scopes(target)
+        except (ValueError, RuntimeError, OSError):
+            return None
+        bindings = other.root.bindings.get(name)
+        if not bindings:
+            if name == "default":
+                for child in other.parsed.root.children:
+                    if child.type == "export_statement" and any(c.type == "default" for c in child.children):
+                        return "import", Location.of(target, child)
+            # Defined dynamically (`__getattr__`, `export *`): the module is as close as we get.
+            return "import", Location(target, 1, 1)
+        binding = bindings[0]
+        inner = _under(binding.node, _IMPORT_STATEMENTS, other.root.node)
+        if inner is not None and hops > 0:
+            followed = self._follow_import(other, binding.node, inner, hops - 1)
+            if followed is not None:
+                return followed
+        return "import", Location.of(target, binding.node)
+
+    def _python_import(self, scopes: FileScopes, ident: Node, statement: Node) -> Tuple[Optional[Path], Optional[str]]:
+        text = scopes.parsed.text
+        path = scopes.parsed.path
+        parent = ident.parent
+        if statement.type == "import_statement":
+            # `import a.b` binds the package `a`; `import a.b as c` binds the module `a.b`.
+            dotted = parent.child_by_field_name("name") if parent.type == "aliased_import" else parent
+            spec = text(dotted) if parent.type == "aliased_import" else text(ident)
+            return python_module_file(spec, path), None
+        module = statement.child_by_field_name("module_name")
+        if module is None:
+            return None, None
+        original = text(parent.child_by_field_name("name")) if parent.type == "aliased_import" else text(ident)
+        spec = text(module)
+        # `from . import sibling` imports a module, not a name.
+        submodule = python_module_file(f"{spec}{'' if spec.endswith('.') else '.'}{original}", path)
+        if submodule is not None:
+            return submodule, None
+        return python_module_file(spec, path), original
+
+    def _js_import(self, scopes: FileScopes, ident: Node, statement: Node) -> Tuple[Optional[Path], Optional[str]]:
+        source = statement.child_by_field_name("source")
+        if source is None:
+            return None, None
+        target = js_module_file(scopes.parsed.text(source).strip("\"'`"), scopes.parsed.path)
+        parent = ident.parent
+        if parent is not None and parent.type == "import_specifier":
+            return target, scopes.parsed.text(parent.child_by_field_name("name"))
+        if parent is not None and parent.type == "namespace_import":
+            return target, None
+        return target, "default"
+
+
+def resolve_at(
+    path: Path, line: int, column: Optional[int] = None, language: Optional[str] = None, references: bool = False
+) -> Resolution:
+    """Resolve the identifier at `path:line[:column]` to its definition; see `Resolver`."""
+    return Resolver().resolve_at(path, line, column, language, references)
+
+
+__all__ = ["MAX_IMPORT_HOPS", "PARAMETER_NODE_TYPES", "Location", "Resolution", "Resolver", "resolve_at"]
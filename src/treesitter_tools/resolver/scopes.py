@@ -0,0 +1,674 @@
+"""Lexical scopes: which declaration each identifier in a file refers to."""
+
+from __future__ import annotations
+
+from dataclasses import dataclass, field
+from typing import Callable, Dict, Iterator, List, Optional, Tuple
+
+from tree_sitter import Node
+
+from ..core import ParsedFile
+
+# What `declare` may answer for an identifier:
+#   "current"  binds in the innermost scope, visible throughout it (hoisted)
+#   "outer"    binds in the scope enclosing the declaring construct (a function's own name)
+#   "function" binds in the nearest function (or module) scope, like JavaScript `var`
+#   "local"    binds in the innermost scope from the end of the declaring statement on
+#   "short"    Go `:=`: like "local" unless the scope already declares the name
+#   "ignore"   neither a declaration nor a reference
+# and None for an ordinary reference.
+Declare = Callable[[Node, Node, Node, Optional[str]], Optional[str]]
+
+
+@dataclass(frozen=True)
+class ScopeRules:
+    identifiers: frozenset
+    scopes: Dict[str, str]  # node type -> "module", "function", "class", or "block"
+    # Pattern nodes an identifier is climbed through to find its declaring construct,
+    # with the field it must sit in ("!name": any field but that one; None: any field).
+    patterns: Dict[str, Optional[str]]
+    declare: Declare
+    is_reference: Callable[[Node], bool]
+    # Names declared in a class body are visible to methods nested in it (not in Python).
+    class_visible: bool = True
+    # How file-level names reach other files: "directory" (shared by every file of the
+    # package directory), "module" (through imports), or "file" (not followed).
+    package: str = "file"
+
+
+def _field_of(parent: Node, child: Node) -> Optional[str]:
+    for i, node in enumerate(parent.children):
+        if node == child:
+            return parent.field_name_for_child(i)
+    return None
+
+
+def _field_ok(rule: Optional[str], name: Optional[str]) -> bool:
+    if rule is None:
+        return True
+    if rule.startswith("!"):
+        return name != rule[1:]
+    return name == rule
+
+
+def _climb(node: Node, patterns: Dict[str, Optional[str]]) -> Tuple[Optional[Node], Node]:
+    child, parent = node, node.parent
+    while parent is not None and parent.type in patterns and _field_ok(patterns[parent.type], _field_of(parent, child)):
+        child, parent = parent, parent.parent
+    return parent, child
+
+
+def _has_ancestor(node: Node, types: set, stop: set = frozenset()) -> bool:
+    current = node.parent
+    while current is not None and current.type not in stop:
+        if current.type in types:
+            return True
+        current = current.parent
+    return False
+
+
+# ---------------------------------------------------------------------------
+# Python
+# ---------------------------------------------------------------------------
+
+_PY_CURRENT_FIELDS = {
+    ("assignment", "left"),
+    ("augmented_assignment", "left"),
+    ("for_statement", "left"),
+    ("for_in_clause", "left"),
+    ("named_expression", "name"),
+    ("aliased_import", "alias"),
+    ("as_pattern", "alias"),
+    ("default_parameter", "name"),
+    ("typed_default_parameter", "name"),
+}
+
+
+def _python_declare(ident: Node, construct: Node, child: Node, name: Optional[str]) -> Optional[str]:
+    kind = construct.type
+    if kind in {"function_definition", "class_definition"} and name == "name":
+        return "outer"
+    if (kind, name) in _PY_CURRENT_FIELDS or kind in {"parameters", "lambda_parameters", "as_pattern_target"}:
+        return "current"
+    if kind == "typed_parameter" and name != "type":
+        return "current"
+    if kind == "dotted_name" and construct.children[0] == ident and construct.parent is not None:
+        statement = construct.parent
+        # `import a.b` binds `a`; `from m import x` binds `x`.
+        if statement.type == "import_statement":
+            return "current"
+        if statement.type == "import_from_statement" and _field_of(statement, construct) == "name":
+            return "current"
+    return None
+
+
+def _python_is_reference(ident: Node) -> bool:
+    parent = ident.parent
+    if parent is None:
+        return True
+    if parent.type == "dotted_name":
+        return False  # module paths in imports
+    field_name = _field_of(parent, ident)
+    return (parent.type, field_name) not in {("attribute", "attribute"), ("keyword_argument", "name")}
+
+
+PYTHON_RULES = ScopeRules(
+    identifiers=frozenset({"identifier"}),
+    scopes={
+        "module": "module",
+        "function_definition": "function",
+        "lambda": "function",
+        "class_definition": "class",
+        "list_comprehension": "function",
+        "set_comprehension": "function",
+        "dictionary_comprehension": "function",
+        "generator_expression": "function",
+    },
+    patterns={
+        "pattern_list": None,
+        "tuple_pattern": None,
+        "list_pattern": None,
+        "list_splat_pattern": None,
+        "dictionary_splat_pattern": None,
+    },
+    declare=_python_declare,
+    is_reference=_python_is_reference,
+    class_visible=False,
+    package="module",
+)
+
+
+# ---------------------------------------------------------------------------
+# JavaScript / TypeScript
+# ---------------------------------------------------------------------------
+
+_JS_HOISTED_DEFINITIONS = {"function_declaration", "generator_function_declaration"}
+_JS_DEFINITIONS = {
+    "function_expression",
+    "function",
+    "generator_function",
+    "class_declaration",
+    "abstract_class_declaration",
+    "class",
+    "interface_declaration",
+    "type_alias_declaration",
+    "enum_declaration",
+}
+
+
+def _js_declare(ident: Node, construct: Node, child: Node, name: Optional[str]) -> Optional[str]:
+    kind = construct.type
+    if kind == "variable_declarator" and name == "name":
+        statement = construct.parent
+        return "function" if statement is not None and statement.type == "variable_declaration" else "current"
+    if kind in _JS_HOISTED_DEFINITIONS and name == "name":
+        return "outer"
+    if kind in _JS_DEFINITIONS and name == "name":
+        return "current"
+    if kind in {"formal_parameters", "import_clause", "namespace_import"}:
+        return "current"
+    if (kind, name) in {("arrow_function", "parameter"), ("catch_clause", "parameter")}:
+        return "current"
+    if kind in {"for_in_statement", "for_of_statement"} and name == "left":
+        declared = construct.child_by_field_name("kind")
+        if declared is None:
+            return None
+        return "function" if declared.type == "var" else "current"
+    if kind == "import_specifier":
+        if name == "alias" or construct.child_by_field_name("alias") is None:
+            return "current"
+    return None
+
+
+def _js_is_reference(ident: Node) -> bool:
+    parent = ident.parent
+    if parent is None:
+        return True
+    field_name = _field_of(parent, ident)
+    if parent.type == "import_specifier" and field_name == "name":
+        return False  # `import {x as y}`: `x` names the other module's export
+    return (parent.type, field_name) != ("export_specifier", "alias")
+
+
+JS_RULES = ScopeRules(
+    identifiers=frozenset(
+        {"identifier", "shorthand_property_identifier", "shorthand_property_identifier_pattern", "type_identifier"}
+    ),
+    scopes={
+        "program": "module",
+        "function_declaration": "function",
+        "generator_function_declaration": "function",
+        "function_expression": "function",
+        "function": "function",
+        "generator_function": "function",
+        "arrow_function": "function",
+        "method_definition": "function",
+        "statement_block": "block",
+        "for_statement": "block",
+        "for_in_statement": "block",
+        "for_of_statement": "block",
+        "catch_clause": "block",
+    },
+    patterns={
+        "object_pattern": None,
+        "array_pattern": None,
+        "pair_pattern": "value",
+        "assignment_pattern": "left",
+        "object_assignment_pattern": "left",
+        "rest_pattern": None,
+        "required_parameter": "pattern",
+        "optional_parameter": "pattern",
+    },
+    declare=_js_declare,
+    is_reference=_js_is_reference,
+    package="module",
+)
+
+
+# ---------------------------------------------------------------------------
+# Go
+# ---------------------------------------------------------------------------
+
+
+def _go_declare(ident: Node, construct: Node, child: Node, name: Optional[str]) -> Optional[str]:
+    kind = construct.type
+    if kind == "function_declaration" and name == "name":
+        return "outer"
+    if kind in {"type_spec", "type_alias", "const_spec", "var_spec"} and name == "name":
+        return "local"
+    if kind in {"parameter_declaration", "variadic_parameter_declaration", "type_parameter_declaration"} and name == "name":
+        return "current"
+    if kind in {"short_var_declaration", "receive_statement"} and name == "left":
+        return "short"
+    if kind == "range_clause" and name == "left":
+        return "short" if any(c.type == ":=" for c in construct.children) else None
+    if kind == "type_switch_statement" and name == "alias":
+        return "current"
+    return None
+
+
+def _go_is_reference(ident: Node) -> bool:
+    parent = ident.parent
+    if parent is not None and parent.type == "literal_element":
+        keyed = parent.parent
+        # `T{Field: v}`: the key names a struct field.
+        if keyed is not None and keyed.type == "keyed_element" and keyed.named_children[0] == parent:
+            return False
+    return True
+
+
+GO_RULES = ScopeRules(
+    identifiers=frozenset({"identifier", "type_identifier"}),
+    scopes={
+        "source_file": "module",
+        "function_declaration": "function",
+        "method_declaration": "function",
+        "func_literal": "function",
+        "block": "block",
+        "if_statement": "block",
+        "for_statement": "block",
+        "expression_switch_statement": "block",
+        "type_switch_statement": "block",
+        "select_statement": "block",
+        "expression_case": "block",
+        "type_case": "block",
+        "default_case": "block",
+        "communication_case": "block",
+    },
+    patterns={"expression_list": None},
+    declare=_go_declare,
+    is_reference=_go_is_reference,
+    package="directory",
+)
+
+
+# ---------------------------------------------------------------------------
+# Rust
+# ---------------------------------------------------------------------------
+
+_RUST_ITEMS = {"struct_item", "enum_item", "union_item", "type_item", "trait_item", "const_item", "static_item", "mod_item"}
+
+
+def _rust_declare(ident: Node, construct: Node, child: Node, name: Optional[str]) -> Optional[str]:
+    kind = construct.type
+    if kind == "function_item" and name == "name":
+        return "outer"
+    if kind in _RUST_ITEMS and name == "name":
+        return "current"
+    if kind == "let_declaration" and name == "pattern":
+        return "local"
+    if (kind, name) in {("parameter", "pattern"), ("for_expression", "pattern"), ("let_condition", "pattern")}:
+        return "current"
+    if kind in {"closure_parameters", "match_arm", "type_parameters"}:
+        return "current"
+    if kind == "constrained_type_parameter" and name == "left":
+        return "current"
+    if kind == "field_pattern" and name == "name" and ident.type == "identifier":
+        return "current"  # shorthand `Point { x, .. }` binds `x`
+    if kind == "use_as_clause":
+        return "current" if name == "alias" else "ignore"
+    if kind in {"use_declaration", "use_list"}:
+        return "current"
+    if kind == "scoped_identifier" and name == "name" and construct.parent is not None:
+        # `use a::b::c;` binds `c`, the last segment of the outermost path.
+        if construct.parent.type in {"use_declaration", "use_list"}:
+            return "current"
+    if kind == "enum_variant" and name == "name":
+        return "ignore"
+    return None
+
+
+def _in_use(node: Node) -> bool:
+    return _has_ancestor(node, {"use_declaration"}, stop={"block", "source_file"})
+
+
+def _rust_is_reference(ident: Node) -> bool:
+    parent = ident.parent
+    if parent is None:
+        return True
+    if parent.type in {"scoped_identifier", "scoped_type_identifier"}:
+        # `path::name`: only the leading segment is resolved lexically.
+        return _field_of(parent, ident) != "name" and not _in_use(parent)
+    return parent.type != "field_pattern"
+
+
+RUST_RULES = ScopeRules(
+    identifiers=frozenset({"identifier", "type_identifier"}),
+    scopes={
+        "source_file": "module",
+        "function_item": "function",
+        "closure_expression": "function",
+        "block": "block",
+        "for_expression": "block",
+        "while_expression": "block",
+        "if_expression": "block",
+        "match_arm": "block",
+        "impl_item": "block",
+        "trait_item": "block",
+    },
+    patterns={
+        "tuple_pattern": None,
+        "slice_pattern": None,
+        "tuple_struct_pattern": "!type",
+        "struct_pattern": "!type",
+        "field_pattern": "pattern",
+        "mut_pattern": None,
+        "ref_pattern": None,
+        "reference_pattern": None,
+        "or_pattern": None,
+        "captured_pattern": None,
+        "match_pattern": "!condition",
+    },
+    declare=_rust_declare,
+    is_reference=_rust_is_reference,
+)
+
+
+# ---------------------------------------------------------------------------
+# Java
+# ---------------------------------------------------------------------------
+
+_JAVA_TYPES = {
+    "class_declaration",
+    "interface_declaration",
+    "enum_declaration",
+    "record_declaration",
+    "annotation_type_declaration",
+}
+
+
+def _java_declare(ident: Node, construct: Node, child: Node, name: Optional[str]) -> Optional[str]:
+    kind = construct.type
+    if kind in _JAVA_TYPES and name == "name":
+        return "current"
+    if kind == "method_declaration" and name == "name":
+        return "outer"
+    if kind == "variable_declarator" and name == "name":
+        statement = construct.parent
+        return "local" if statement is not None and statement.type == "local_variable_declaration" else "current"
+    if kind in {"formal_parameter", "catch_formal_parameter", "enhanced_for_statement", "resource"} and name == "name":
+        return "current"
+    if kind in {"lambda_expression", "inferred_parameters"} and name in {None, "parameters"}:
+        return "current"
+    if kind == "type_parameter":
+        return "current"
+    if kind == "enum_constant" and name == "name":
+        return "ignore"
+    return None
+
+
+def _java_is_reference(ident: Node) -> bool:
+    parent = ident.parent
+    if parent is None:
+        return True
+    field_name = _field_of(parent, ident)
+    if (parent.type, field_name) in {("field_access", "field"), ("method_invocation", "name")}:
+        return False  # members, resolved through types
+    if parent.type in {"scoped_identifier", "scoped_type_identifier"} and parent.named_children[0] != ident:
+        return False
+    if parent.type in {"labeled_statement", "break_statement", "continue_statement", "method_reference"}:
+        return False
+    return not _has_ancestor(ident, {"import_declaration", "package_declaration"}, stop={"program"})
+
+
+JAVA_RULES = ScopeRules(
+    identifiers=frozenset({"identifier", "type_identifier"}),
+    scopes={
+        "program": "module",
+        "class_body": "class",
+        "interface_body": "class",
+        "enum_body": "class",
+        "method_declaration": "function",
+        "constructor_declaration": "function",
+        "lambda_expression": "function",
+        "block": "block",
+        "for_statement": "block",
+        "enhanced_for_statement": "block",
+        "catch_clause": "block",
+        "try_with_resources_statement": "block",
+    },
+    patterns={},
+    declare=_java_declare,
+    is_reference=_java_is_reference,
+    package="directory",
+)
+
+
+# ---------------------------------------------------------------------------
+# C / C++
+# ---------------------------------------------------------------------------
+
+
+def _c_declare(ident: Node, construct: Node, child: Node, name: Optional[str]) -> Optional[str]:
+    kind = construct.type
+    if kind == "function_definition" and name == "declarator":
+        return "outer"
+    if kind == "declaration" and name == "declarator":
+        return "local"
+    if kind == "parameter_declaration" and name == "declarator":
+        # Prototype parameters are scoped to the prototype; they never clash with anything.
+        return "current" if _has_ancestor(construct, {"function_definition"}, stop={"declaration"}) else "ignore"
+    if kind in {"type_definition", "for_range_loop"} and name == "declarator":
+        return "current"
+    if kind in {"struct_specifier", "union_specifier", "enum_specifier", "class_specifier"} and name == "name":
+        # Only the definition (`struct s { ... }`) declares the tag; `struct s x;` refers to it.
+        return "current" if construct.child_by_field_name("body") is not None else None
+    if kind in {"enumerator", "preproc_def", "preproc_function_def"} and name == "name":
+        return "current"
+    if kind == "preproc_params":
+        return "ignore"
+    return None
+
+
+def _c_is_reference(ident: Node) -> bool:
+    parent = ident.parent
+    return parent is None or not (parent.type == "qualified_identifier" and _field_of(parent, ident) == "name")
+
+
+C_RULES = ScopeRules(
+    identifiers=frozenset({"identifier", "type_identifier"}),
+    scopes={
+        "translation_unit": "module",
+        "function_definition": "function",
+        "lambda_expression": "function",
+        "compound_statement": "block",
+        "for_statement": "block",
+        "for_range_loop": "block",
+        "if_statement": "block",
+        "while_statement": "block",
+        "switch_statement": "block",
+        "catch_clause": "block",
+    },
+    patterns={
+        "init_declarator": "declarator",
+        "pointer_declarator": "declarator",
+        "array_declarator": "declarator",
+        "function_declarator": "declarator",
+        "parenthesized_declarator": None,
+        "reference_declarator": None,
+        "attributed_declarator": "!attribute",
+    },
+    declare=_c_declare,
+    is_reference=_c_is_reference,
+    package="directory",
+)
+
+
+SCOPE_RULES: Dict[str, ScopeRules] = {
+    "python": PYTHON_RULES,
+    "javascript": JS_RULES,
+    "typescript": JS_RULES,
+    "tsx": JS_RULES,
+    "go": GO_RULES,
+    "rust": RUST_RULES,
+    "java": JAVA_RULES,
+    "c": C_RULES,
+    "cpp": C_RULES,
+}
+
+
+# ---------------------------------------------------------------------------
+# Scope tree
+# ---------------------------------------------------------------------------
+
+
+@dataclass(eq=False)
+class Scope:
+    node: Node
+    kind: str
+    parent: Optional["Scope"] = None
+    bindings: Dict[str, List["Binding"]] = field(default_factory=dict)
+    # Python `global` / `nonlocal` declarations: name -> "global" or "nonlocal".
+    redirects: Dict[str, str] = field(default_factory=dict)
+
+    def encloses(self, other: "Scope") -> bool:
+        """True when `other` is this scope or nested inside it."""
+        current: Optional[Scope] = other
+        while current is not None:
+            if current is self:
+                return True
+            current = current.parent
+        return False
+
+
+@dataclass(eq=False)
+class Binding:
+    name: str
+    node: Node  # the declaring identifier
+    scope: Scope
+    visible_from: int = 0  # byte offset; 0 for hoisted declarations
+
+    @property
+    def key(self) -> tuple:
+        """Identity of the variable: hoisted declarations of a name in one scope are one variable."""
+        if self.visible_from:
+            return (self.scope.node.id, self.name, self.visible_from)
+        return (self.scope.node.id, self.name)
+
+
+@dataclass(eq=False)
+class Occurrence:
+    node: Node
+    name: str
+    scope: Scope  # innermost scope containing the identifier
+    binding: Optional[Binding] = None  # None: a free name (global, builtin, or another file's)
+    declaration: bool = False
+
+
+class FileScopes:
+    """Scopes, declarations, and resolved identifier occurrences of one parsed file."""
+
+    def __init__(self, parsed: ParsedFile, rules: Optional[ScopeRules] = None):
+        rules = rules or SCOPE_RULES.get(parsed.language)
+        if rules is None:
+            raise ValueError(f"Scope analysis is not supported for {parsed.language}")
+        self.parsed = parsed
+        self.rules = rules
+        self.root = Scope(parsed.root, "module")
+        self.scopes: Dict[int, Scope] = {parsed.root.id: self.root}
+        self.occurrences: List[Occurrence] = []
+        self._build()
+
+    def _scope_for(self, node: Node) -> Scope:
+        current: Optional[Node] = node
+        while current is not None:
+            scope = self.scopes.get(current.id)
+            if scope is not None:
+                return scope
+            current = current.parent
+        return self.root
+
+    def _bind(self, scope: Scope, name: str, node: Node, visible_from: int = 0) -> Binding:
+        if scope.redirects.get(name) == "global":
+            scope = self.root
+        if scope.kind == "module":
+            visible_from = 0
+        binding = Binding(name, node, scope, visible_from)
+        scope.bindings.setdefault(name, []).append(binding)
+        return binding
+
+    def _build(self) -> None:
+        rules = self.rules
+        stack = [self.root.node]
+        pending: List[Occurrence] = []
+        while stack:
+            node = stack.pop()
+            if node.id not in self.scopes and node.type in rules.scopes and node.parent is not None:
+                self.scopes[node.id] = Scope(node, rules.scopes[node.type], self._scope_for(node.parent))
+            if node.type in rules.identifiers:
+                self._visit_identifier(node, pending)
+            stack.extend(reversed(node.children))
+        for occurrence in pending:
+            occurrence.binding = self.resolve(occurrence.name, occurrence.scope, occurrence.node.start_byte)
+        self.occurrences.sort(key=lambda o: o.node.start_byte)
+
+    def _visit_identifier(self, node: Node, pending: List[Occurrence]) -> None:
+        name = self.parsed.text(node)
+        scope = self._scope_for(node)
+        parent = node.parent
+        if parent is not None and parent.type in {"global_statement", "nonlocal_statement"}:
+            scope.redirects[name] = "global" if parent.type == "global_statement" else "nonlocal"
+        construct, child = _climb(node, self.rules.patterns)
+        how = self.rules.declare(node, construct, child, _field_of(construct, child)) if construct is not None else None
+        if how == "ignore":
+            return
+        if how == "short":
+            existing = scope.bindings.get(name, ())
+            how = None if any(b.visible_from <= node.start_byte for b in existing) else "local"
+        if how is None:
+            if self.rules.is_reference(node):
+                occurrence = Occurrence(node, name, scope)
+                self.occurrences.append(occurrence)
+                pending.append(occurrence)
+            return
+        target = scope
+        visible_from = 0
+        if how == "outer":
+            own = self.scopes.get(construct.id)
+            target = own.parent if own is not None and own.parent is not None else scope
+        elif how == "function":
+            while target.kind not in {"function", "module"} and target.parent is not None:
+                target = target.parent
+        elif how == "local":
+            visible_from = construct.end_byte
+        binding = self._bind(target, name, node, visible_from)
+        self.occurrences.append(Occurrence(node, name, scope, binding, declaration=True))
+
+    def resolve(self, name: str, scope: Scope, position: int) -> Optional[Binding]:
+        """The declaration `name` refers to at byte `position` inside `scope`, or None if free."""
+        current: Optional[Scope] = scope
+        first = True
+        while current is not None:
+            redirect = current.redirects.get(name)
+            if redirect == "global":
+                current, first = self.root, False
+                redirect = None
+            if redirect == "nonlocal":
+                current, first = current.parent, False
+                continue
+            if current.kind != "class" or first or self.rules.class_visible:
+                visible = [b for b in current.bindings.get(name, ()) if b.visible_from <= position]
+                if visible:
+                    return max(visible, key=lambda b: b.visible_from)
+            current, first = current.parent, False
+        return None
+
+    def occurrence_at(self, line: int, column: Optional[int] = None) -> Optional[Occurrence]:
+        """Identifier at a 1-based position; without a column, the first declaration on the line."""
+        row = line - 1
+        on_line = [o for o in self.occurrences if o.node.start_point[0] == row]
+        if column is None:
+            declared = [o for o in on_line if o.declaration]
+            return (declared or on_line or [None])[0]
+        col = column - 1
+        for occurrence in on_line:
+            if occurrence.node.start_point[1] <= col <= occurrence.node.end_point[1]:
+                return occurrence
+        return None
+
+    def references(self, binding: Binding) -> Iterator[Occurrence]:
+        key = binding.key
+        return (o for o in self.occurrences if o.binding is not None and o.binding.key == key)
+
+
+__all__ = ["SCOPE_RULES", "Binding", "FileScopes", "Occurrence", "Scope", "ScopeRules"]
@@ -0,0 +1,172 @@
+"""Structural search & replace driven by Tree-sitter queries."""
+
+from __future__ import annotations
+
+import difflib
+import re
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Dict, List, Optional, Sequence
+
+from tree_sitter import Node, Query, QueryCursor
+
+from . import charsets
+from .core import detect_language, iter_source_files, load_language, parse_source, source_language
+
+TARGET_CAPTURE = "match"
+_PLACEHOLDER = re.compile(r"@@|@([A-Za-z_][A-Za-z0-9_.\-]*)")
+
+
+@dataclass
+class Edit:
+    start_byte: int
+    end_byte: int
+    replacement: str
+    start_line: int
+    end_line: int
+
+    def to_dict(self) -> dict:
+        return {
+            "start_byte": self.start_byte,
+            "end_byte": self.end_byte,
+            "start_line": self.start_line,
+            "end_line": self.end_line,
+            "replacement": self.replacement,
+        }
+
+
+@dataclass
+class FileRewrite:
+    path: Path
+    original: bytes
+    rewritten: bytes
+    edits: List[Edit] = field(default_factory=list)
+
+    @property
+    def changed(self) -> bool:
+        return self.original != self.rewritten
+
+    def diff(self, label: Optional[str] = None) -> str:
+        label = label or self.path.as_posix()
+        before = self.original.decode("utf-8", "replace").splitlines(keepends=True)
+        after = self.rewritten.decode("utf-8", "replace").splitlines(keepends=True)
+        return "".join(difflib.unified_diff(before, after, f"a/{label}", f"b/{label}"))
+
+
+def render_template(template: str, captures: Dict[str, str]) -> str:
+    """Substitute `@name` placeholders with capture text (`@@` is a literal `@`)."""
+
+    def replace(match: re.Match) -> str:
+        if match.group(0) == "@@":
+            return "@"
+        name = match.group(1)
+        # Allow trailing punctuation such as `@args.` to stay outside the name.
+        while name not in captures and name and name[-1] in ".-":
+            name = name[:-1]
+        if name not in captures:
+            raise ValueError(f"Template references unknown capture @{match.group(1)}")
+        return captures[name] + match.group(1)[len(name):]
+
+    return _PLACEHOLDER.sub(replace, template)
+
+
+def apply_edits(source: bytes, edits: Sequence[Edit]) -> bytes:
+    """Apply non-overlapping edits (in any order) to `source`."""
+    out = bytearray()
+    cursor = 0
+    for edit in sorted(edits, key=lambda e: e.start_byte):
+        if edit.start_byte < cursor:
+            raise ValueError(f"Overlapping edit at byte {edit.start_byte}")
+        out += source[cursor:edit.start_byte]
+        out += edit.replacement.encode("utf-8")
+        cursor = edit.end_byte
+    out += source[cursor:]
+    return bytes(out)
+
+
+def _span(nodes: Sequence[Node]) -> tuple[int, int, Node, Node]:
+    first = min(nodes, key=lambda n: n.start_byte)
+    last = max(nodes, key=lambda n: n.end_byte)
+    return first.start_byte, last.end_byte, first, last
+
+
+def find_edits(source: bytes, language: str, query: str, template: str, target: str = TARGET_CAPTURE) -> List[Edit]:
+    """Compute the edits produced by `query`/`template` on `source` (first match wins on overlap)."""
+    root = parse_source(source, language)
+    cursor = QueryCursor(Query(load_language(language), query))
+    edits: List[Edit] = []
+    taken_until = -1
+    candidates = []
+    for _, captures in cursor.matches(root):
+        if not captures:
+            continue
+        texts = {}
+        for name, nodes in captures.items():
+            start, end, _, _ = _span(nodes)
+            texts[name] = source[start:end].decode("utf-8", "replace")
+        target_nodes = captures.get(target) or [n for nodes in captures.values() for n in nodes]
+        start, end, first, last = _span(target_nodes)
+        candidates.append((start, -end, end, first, last, texts))
+    for start, _, end, first, last, texts in sorted(candidates, key=lambda c: (c[0], c[1])):
+        if start < taken_until:
+            continue
+        edits.append(
+            Edit(
+                start_byte=start,
+                end_byte=end,
+                replacement=render_template(template, texts),
+                start_line=first.start_point[0] + 1,
+                end_line=last.end_point[0] + 1,
+            )
+        )
+        taken_until = end
+    return edits
+
+
+def rewrite_file(path: Path, query: str, template: str, language: Optional[str] = None,
+                 target: str = TARGET_CAPTURE) -> FileRewrite:
+    path = Path(path)
+    language = source_language(path, language)
+    source = charsets.read_file(path).data
+    edits = find_edits(source, language, query, template, target)
+    return FileRewrite(path=path, original=source, rewritten=apply_edits(source, edits), edits=edits)
+
+
+def compile_rewrite(language: str, query: str, template: str) -> Query:
+    """Compile `query` and check every template placeholder names one of its captures."""
+    ts_query = Query(load_language(language), query)
+    names = {ts_query.capture_name(i) for i in range(ts_query.capture_count)}
+    render_template(template, {name: "" for name in names})
+    return ts_query
+
+
+def rewrite_paths(
+    root: Path,
+    language: str,
+    query: str,
+    template: str,
+    include: Sequence[str] | None = None,
+    exclude: Sequence[str] | None = None,
+    target: str = TARGET_CAPTURE,
+) -> List[FileRewrite]:
+    """Rewrite every `language` file under `root` (or `root` itself); only changed files are returned."""
+    root = Path(root)
+    compile_rewrite(language, query, template)
+    if root.is_file():
+        result = rewrite_file(root, query, template, language, target)
+        return [result] if result.changed else []
+    results: List[FileRewrite] = []
+    for path in iter_source_files(root, include, exclude):
+        if detect_language(path) != language:
+            continue
+        try:
+            result = rewrite_file(path, query, template, language, target)
+        except (ValueError, OSError):
+            # Binary/unreadable files are skipped like in `scan`.
+            continue
+        if result.changed:
+            results.append(result)
+    return results
+
+
+__all__ = ["Edit", "FileRewrite", "apply_edits", "compile_rewrite", "find_edits", "render_template", "rewrite_file", "rewrite_paths"]
@@ -0,0 +1,424 @@
+"""
+Versioned JSON Schemas (draft 2020-12) for the JSON/NDJSON records the tool emits.
+
+Schema versions are `MAJOR.MINOR`. Within a major version, records only evolve
+compatibly: a minor version may add optional properties, never remove, rename,
+retype, or newly require one. Each property records the version that introduced
+it, so the schema of every supported version can be rebuilt and output can be
+narrowed to it: with `--schema-version 1.0` (see `PINNED`) records drop the
+properties added later, so a pipeline validating against the 1.0 schemas (which
+reject unknown properties) keeps passing after an upgrade.
+"""
+
+from __future__ import annotations
+
+import json
+from dataclasses import dataclass, field, replace
+from typing import Any, Dict, Iterable, List, Optional, Sequence, Tuple
+
+from .failures import KINDS
+
+DIALECT = "https://json-schema.org/draft/2020-12/schema"
+VERSIONS = ("1.0", "1.1", "1.2", "1.3", "1.4", "1.5", "1.6", "1.7", "1.8", "1.9", "1.10")
+SCHEMA_VERSION = VERSIONS[-1]
+CHANGES = {
+    "1.0": "Initial versioned records.",
+    "1.1": "Adds file.root (workspace scans), symbol.type_parameters and symbol.instantiations (Go generics), "
+    "and index-reference.type_arguments.",
+    "1.2": "Adds symbol.parent and symbol.captures, and function.parent and function.captures (closures).",
+    "1.3": "Adds symbol.summary (--summarize).",
+    "1.4": "Adds symbol.block and symbol.cell_id (code in Markdown files and notebooks).",
+    "1.5": "Adds file.error_kind and symbol-record.error_kind (the category of a file's error).",
+    "1.6": "Adds symbol.id and chunk.id (content-defined IDs, stable across edits elsewhere in the file).",
+    "1.7": "Adds symbol.condition (the #if condition guarding a C/C++ declaration, with --preprocessor).",
+    "1.8": "Adds query-match.path (the file a match is in, when querying a directory).",
+    "1.9": "Adds chunk.parent_id, chunk.prev_id, chunk.next_id, and chunk.file_id (chunk links, with --links).",
+    "1.10": "Adds file.encoding (the encoding of a file transcoded to UTF-8).",
+}
+
+# Set by the CLI (`--schema-version`); None emits the current version.
+PINNED: Optional[str] = None
+
+
+def _version_key(version: str) -> Tuple[int, int]:
+    major, _, minor = version.partition(".")
+    return int(major), int(minor or 0)
+
+
+def check_version(version: str) -> str:
+    """
+    The supported version `version` names (a bare major means its newest minor);
+    ValueError listing the supported versions otherwise.
+    """
+    text = version.strip()
+    if text in VERSIONS:
+        return text
+    newest = [v for v in VERSIONS if v.split(".")[0] == text]
+    if newest:
+        return newest[-1]
+    raise ValueError(f"Unsupported schema version '{version}' (supported: {', '.join(VERSIONS)})")
+
+
+def _nullable(kind: str) -> dict:
+    return {"type": [kind, "null"]}
+
+
+_STRING = {"type": "string"}
+_BOOLEAN = {"type": "boolean"}
+_COUNT = {"type": "integer", "minimum": 0}
+_LINE = {"type": "integer", "minimum": 1}
+_STRINGS = {"type": "array", "items": _STRING}
+
+
+@dataclass
+class Prop:
+    name: str
+    schema: Any  # a schema, or the name of a record embedded here
+    required: bool = False
+    since: str = "1.0"
+    array: bool = False  # an array of `schema`
+    description: Optional[str] = None
+
+
+@dataclass
+class Record:
+    name: str
+    description: str
+    commands: Sequence[str]  # outputs the record appears in
+    props: List[Prop] = field(default_factory=list)
+
+
+RECORDS: Dict[str, Record] = {}
+
+
+def _register(name: str, description: str, commands: Sequence[str], props: List[Prop]) -> None:
+    RECORDS[name] = Record(name, description, tuple(commands), props)
+
+
+_SYMBOL_PROPS = [
+    Prop("kind", _STRING, True, description="function/class, or one of the normalized kinds with --normalize"),
+    Prop("name", _STRING, True),
+    Prop("start_line", _LINE, True),
+    Prop("end_line", _LINE, True),
+    Prop("signature", _nullable("string"), True),
+    Prop("docstring", _nullable("string"), True),
+    Prop("doc", _nullable("string"), True),
+    Prop("trailing_comment", _nullable("string"), True),
+    Prop("content", _nullable("string"), True),
+    Prop("elided", {"enum": ["truncated", "omitted"]}),
+    Prop("change", {"enum": ["added", "modified"]}),
+    Prop("language", _STRING, description="Set on symbols of embedded code"),
+    Prop("body_hash", _STRING),
+    Prop("type_parameters", "type-parameter", since="1.1", array=True),
+    Prop("instantiations", "instantiation", since="1.1", array=True),
+    Prop("parent", _STRING, since="1.2", description="Closures: qualified name of the enclosing function"),
+    Prop("captures", _STRINGS, since="1.2", description="Closures: enclosing-function variables the body uses"),
+    Prop("summary", _STRING, since="1.3", description="Functions: one-line description from --summarize"),
+    Prop("block", _COUNT, since="1.4", description="Markdown and notebooks: index of the code block or cell"),
+    Prop("cell_id", _STRING, since="1.4", description="Notebooks: id of the cell"),
+    Prop("id", _STRING, since="1.6", description="Hash of path, qualified name, and layout-normalized content"),
+    Prop("condition", _STRING, since="1.7", description="C/C++ with --preprocessor: the guarding #if condition"),
+    Prop("qualified_name", _STRING),
+    Prop("container", _STRINGS),
+    Prop("visibility", {"enum": ["public", "protected", "internal", "private", None]}),
+    Prop("chunk_index", _COUNT),
+    Prop("chunk_count", {"type": "integer", "minimum": 1}),
+    Prop("parent_symbol", _nullable("string")),
+    Prop("overflow", _BOOLEAN),
+]
+_PROVENANCE = [Prop("generated", {"const": True}), Prop("vendored", {"const": True})]
+
+_register("type-parameter", "A Go type parameter and its constraint.", (), [
+    Prop("name", _STRING, True),
+    Prop("constraint", _nullable("string"), True, description="null for a method's receiver parameters"),
+])
+_register("instantiation", "A generic used with type arguments, like `pkg.List[int]`.", (), [
+    Prop("name", _STRING, True),
+    Prop("qualifier", _nullable("string"), True),
+    Prop("type_arguments", _STRINGS, True),
+])
+_ERROR_KIND = {"enum": list(KINDS)}
+_register("symbol", "A function, class, or other declaration.", ("symbols",), _SYMBOL_PROPS)
+_register("file", "One scanned file and its symbols.", ("scan", "scan --format ndjson", "workspace scan", "decode"), [
+    Prop("path", _STRING, True),
+    Prop("root", _STRING, since="1.1", description="Name of the workspace root the file came from"),
+    Prop("language", _STRING, True),
+    Prop("encoding", _STRING, since="1.10", description="Set when the file was not UTF-8: the encoding it was read as"),
+    *_PROVENANCE,
+    Prop("symbols", "symbol", True, array=True),
+    Prop("error", _STRING),
+    Prop("error_kind", _ERROR_KIND, since="1.5", description="Category of error: " + ", ".join(KINDS)),
+])
+_register("symbol-record", "One symbol with its file, or a file's error.", ("scan --format ndjson --per-symbol",), [
+    Prop("path", _STRING, True),
+    Prop("language", _STRING, True),
+    *_PROVENANCE,
+    Prop("error", _STRING),
+    Prop("error_kind", _ERROR_KIND, since="1.5"),
+    *(replace(prop, required=False) for prop in _SYMBOL_PROPS),
+])
+_register("chunk", "An embedding chunk.", ("chunk", "decode"), [
+    Prop("path", _STRING, True),
+    Prop("language", _STRING, True),
+    Prop("index", _COUNT, True),
+    Prop("kind", _STRING, True),
+    Prop("name", _STRING, True),
+    Prop("start_line", _LINE, True),
+    Prop("end_line", _LINE, True),
+    Prop("token_count", _COUNT, True),
+    Prop("context", _STRING, True),
+    Prop("content", _STRING, True),
+    Prop("part", {"type": "integer", "minimum": 1}, description="With part_count, for a declaration split in parts"),
+    Prop("part_count", {"type": "integer", "minimum": 1}),
+    Prop("id", _STRING, since="1.6", description="Hash of path, name, part, and layout-normalized content"),
+    Prop("parent_id", _STRING, since="1.9", description="With --links: the enclosing class outline or file chunk"),
+    Prop("prev_id", _STRING, since="1.9", description="With --links: the previous chunk with the same parent"),
+    Prop("next_id", _STRING, since="1.9", description="With --links: the next chunk with the same parent"),
+    Prop("file_id", _STRING, since="1.9", description="With --links: the file's outline chunk"),
+])
+_register("capture", "A node captured by a query pattern.", (), [
+    Prop("name", _STRING, True),
+    Prop("type", _STRING, True),
+    Prop("text", _STRING, True),
+    Prop("start_line", _LINE, True),
+    Prop("end_line", _LINE, True),
+    Prop("start_column", _LINE, True),
+    Prop("end_column", _LINE, True),
+    Prop("start_byte", _COUNT, True),
+    Prop("end_byte", _COUNT, True),
+])
+_register("query-match", "One match of a Tree-sitter query.", ("query",), [
+    Prop("path", _STRING, since="1.8", description="Directory queries: the file, relative to the directory"),
+    Prop("pattern_index", _COUNT, True),
+    Prop("captures", "capture", True, array=True),
+])
+_register("function", "A function definition in the call graph.", (), [
+    Prop("name", _STRING, True),
+    Prop("qualified_name", _STRING, True),
+    Prop("receiver_type", _nullable("string"), True),
+    Prop("file", _STRING, True),
+    Prop("line", _LINE, True),
+    Prop("parent", _STRING, since="1.2", description="Closures: qualified name of the enclosing function"),
+    Prop("captures", _STRINGS, since="1.2"),
+])
+_register("call-edge", "A call from one function to another.", (), [
+    Prop("caller", _STRING, True),
+    Prop("callee", _STRING, True),
+    Prop("file", _STRING, True),
+    Prop("line", _LINE, True),
+    Prop("column", {"type": ["integer", "null"], "minimum": 1}, True),
+    Prop("receiver", _nullable("string"), True),
+    Prop("receiver_type", _nullable("string"), True),
+    Prop("callee_file", _nullable("string"), True, description="Set when the callee resolved to one definition"),
+    Prop("callee_line", {"type": ["integer", "null"], "minimum": 1}, True),
+])
+_register("callgraph", "Function definitions and call edges.", ("callgraph",), [
+    Prop("functions", "function", True, array=True),
+    Prop("edges", "call-edge", True, array=True),
+])
+_register("index-definition", "A definition found in the symbol index.", ("index query defs",), [
+    Prop("kind", _STRING, True),
+    Prop("name", _STRING, True),
+    Prop("qualified_name", _STRING, True),
+    Prop("container", _nullable("string"), True),
+    Prop("path", _STRING, True),
+    Prop("language", _STRING, True),
+    Prop("start_line", _LINE, True),
+    Prop("end_line", _LINE, True),
+    Prop("signature", _nullable("string"), True),
+    Prop("docstring", _nullable("string"), True),
+])
+_register("index-reference", "A reference (call or generic instantiation) in the symbol index.", ("index query refs",), [
+    Prop("name", _STRING, True),
+    Prop("caller", _STRING, True),
+    Prop("receiver", _nullable("string"), True),
+    Prop("receiver_type", _nullable("string"), True),
+    Prop("path", _STRING, True),
+    Prop("line", _LINE, True),
+    Prop("column", _LINE, True),
+    Prop("type_arguments", {"type": ["array", "null"], "items": _STRING}, since="1.1"),
+])
+_register("index-caller", "A function calling the queried name.", ("index query callers",), [
+    Prop("caller", _STRING, True),
+    Prop("path", _STRING, True),
+    Prop("line", {"type": ["integer", "null"], "minimum": 1}, True),
+    Prop("calls", {"type": "integer", "minimum": 1}, True),
+])
+_register("index-implementation", "A type implementing the queried interface or base.", ("index query implementations",), [
+    Prop("name", _STRING, True),
+    Prop("path", _STRING, True),
+    Prop("line", _LINE, True),
+    Prop("via", {"enum": ["declared", "method_set"]}, True),
+])
+
+# `index query KIND` -> the record its rows follow.
+INDEX_RECORDS = {
+    "defs": "index-definition",
+    "refs": "index-reference",
+    "callers": "index-caller",
+    "implementations": "index-implementation",
+}
+
+
+def _props(name: str, version: str) -> List[Prop]:
+    if name not in RECORDS:
+        raise ValueError(f"Unknown record '{name}' (expected one of {', '.join(sorted(RECORDS))})")
+    limit = _version_key(version)
+    return [p for p in RECORDS[name].props if _version_key(p.since) <= limit]
+
+
+def _object_schema(name: str, version: str) -> dict:
+    properties: Dict[str, Any] = {}
+    for prop in _props(name, version):
+        value = _object_schema(prop.schema, version) if isinstance(prop.schema, str) else dict(prop.schema)
+        if prop.array:
+            value = {"type": "array", "items": value}
+        if prop.description:
+            value = {**value, "description": prop.description}
+        properties[prop.name] = value
+    return {
+        "type": "object",
+        "description": RECORDS[name].description,
+        "properties": properties,
+        "required": [p.name for p in _props(name, version) if p.required],
+        "additionalProperties": False,
+    }
+
+
+def schema_for(name: str, version: Optional[str] = None) -> dict:
+    """The JSON Schema of record `name` at `version` (default: the current one)."""
+    version = check_version(version or SCHEMA_VERSION)
+    return {
+        "$schema": DIALECT,
+        "$id": f"urn:treesitter-tools:schema:{version}:{name}",
+        "title": name,
+        **_object_schema(name, version),
+        "x-schema-version": version,
+    }
+
+
+def bundle(version: Optional[str] = None) -> dict:
+    """Every top-level record's schema at `version`, under `$defs`."""
+    version = check_version(version or SCHEMA_VERSION)
+    return {
+        "$schema": DIALECT,
+        "$id": f"urn:treesitter-tools:schema:{version}",
+        "x-schema-version": version,
+        "$defs": {r["record"]: schema_for(r["record"], version) for r in list_records(version)},
+    }
+
+
+def list_records(version: Optional[str] = None) -> List[dict]:
+    """Top-level records (not the ones only embedded in others) and the outputs they appear in."""
+    version = check_version(version or SCHEMA_VERSION)
+    return [
+        {"record": r.name, "description": r.description, "commands": list(r.commands)}
+        for r in RECORDS.values()
+        if r.commands
+    ]
+
+
+def _narrow(value: Any, schema: dict) -> Any:
+    if isinstance(value, dict) and "properties" in schema:
+        properties = schema["properties"]
+        return {k: _narrow(v, properties[k]) for k, v in value.items() if k in properties}
+    if isinstance(value, list) and isinstance(schema.get("items"), dict):
+        return [_narrow(item, schema["items"]) for item in value]
+    return value
+
+
+def conform(name: str, data: Any, version: Optional[str] = None) -> Any:
+    """
+    `data` (one record of type `name`, or a list of them) as the pinned schema version
+    (`version`, else `PINNED`) describes it, without the properties added since.
+    Unpinned output is returned as it is.
+    """
+    version = version or PINNED
+    if version is None or version == SCHEMA_VERSION:
+        return data
+    schema = _object_schema(name, version)
+    if isinstance(data, list):
+        return [_narrow(item, schema) for item in data]
+    return _narrow(data, schema)
+
+
+def _type_ok(value: Any, kind: str) -> bool:
+    if kind == "null":
+        return value is None
+    if kind == "integer":
+        return isinstance(value, int) and not isinstance(value, bool)
+    if kind == "number":
+        return isinstance(value, (int, float)) and not isinstance(value, bool)
+    return isinstance(value, {"string": str, "boolean": bool, "array": list, "object": dict}[kind])
+
+
+def _errors(value: Any, schema: dict, where: str) -> Iterable[str]:
+    kinds = schema.get("type")
+    if kinds is not None:
+        kinds = [kinds] if isinstance(kinds, str) else kinds
+        if not any(_type_ok(value, kind) for kind in kinds):
+            yield f"{where}: expected {' or '.join(kinds)}, got {json.dumps(value)[:40]}"
+            return
+    if "enum" in schema and value not in schema["enum"]:
+        yield f"{where}: {json.dumps(value)} is not one of {json.dumps(schema['enum'])}"
+    if "const" in schema and value != schema["const"]:
+        yield f"{where}: expected {json.dumps(schema['const'])}"
+    if isinstance(value, int) and not isinstance(value, bool) and value < schema.get("minimum", value):
+        yield f"{where}: {value} is below the minimum {schema['minimum']}"
+    if isinstance(value, list) and isinstance(schema.get("items"), dict):
+        for i, item in enumerate(value):
+            yield from _errors(item, schema["items"], f"{where}[{i}]")
+    if isinstance(value, dict) and "properties" in schema:
+        for key in schema.get("required", []):
+            if key not in value:
+                yield f"{where}: missing required property '{key}'"
+        for key, item in value.items():
+            if key in schema["properties"]:
+                yield from _errors(item, schema["properties"][key], f"{where}.{key}")
+            elif schema.get("additionalProperties") is False:
+                yield f"{where}: unexpected property '{key}'"
+
+
+def validate(name: str, data: Any, version: Optional[str] = None) -> List[str]:
+    """
+    Problems with `data` as a record of type `name` at `version` (empty when it
+    conforms). Covers the keywords these schemas use, not all of JSON Schema.
+    """
+    return list(_errors(data, schema_for(name, version), name))
+
+
+def compatibility_problems(old: str, new: str) -> List[str]:
+    """Ways the records of schema `new` break consumers of `old` (the evolution rules, checked)."""
+    problems = []
+    for name in RECORDS:
+        before = {p.name: p for p in _props(name, old)}
+        after = {p.name: p for p in _props(name, new)}
+        for prop_name, prop in before.items():
+            later = after.get(prop_name)
+            if later is None:
+                problems.append(f"{name}.{prop_name} removed")
+            elif (later.schema, later.array) != (prop.schema, prop.array):
+                problems.append(f"{name}.{prop_name} changed type")
+            elif later.required and not prop.required:
+                problems.append(f"{name}.{prop_name} became required")
+        for prop_name in after.keys() - before.keys():
+            if after[prop_name].required and _version_key(old)[0] == _version_key(new)[0]:
+                problems.append(f"{name}.{prop_name} added as required")
+    return problems
+
+
+__all__ = [
+    "CHANGES",
+    "DIALECT",
+    "INDEX_RECORDS",
+    "RECORDS",
+    "SCHEMA_VERSION",
+    "VERSIONS",
+    "bundle",
+    "check_version",
+    "compatibility_problems",
+    "conform",
+    "list_records",
+    "schema_for",
+    "validate",
+]
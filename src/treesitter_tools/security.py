@@ -0,0 +1,328 @@
+"""
+Security-sensitive API usage: rule packs of Tree-sitter queries that flag risky
+patterns (code evaluated from strings, shell commands, SQL built from strings,
+broken hashes, disabled TLS verification, ...), each with a severity.
+
+Packs are YAML files: a `languages` list and `rules`, each with an `id`, a
+`severity` (low, medium, high), a `message`, optional `help` and `cwe`, and a
+`query` whose `@finding` capture is the node reported (without one, the largest
+captured node). Packs for Go, Python, and JavaScript/TypeScript ship in `rules/`;
+`--rules FILE` adds more.
+
+A comment containing `nosec` suppresses findings on its own line, or on the next
+line when the comment is alone on its line. `nosec go/sql-concat, go/shell-exec`
+suppresses only those rules. Suppressed findings are left out of text output and
+marked in JSON (`suppressed`) and SARIF (an in-source suppression).
+"""
+
+from __future__ import annotations
+
+import fnmatch
+import json
+import re
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Dict, Iterator, List, Optional, Sequence, Tuple
+
+import yaml
+from tree_sitter import Node, Query, QueryCursor
+
+from . import failures
+from .core import ParsedFile, iter_source_files, load_language, parse_file
+from .export.sarif import SarifLocation, SarifResult, SarifRule, sarif_to_json
+
+SEVERITIES = ("low", "medium", "high")
+RULES_DIR = Path(__file__).with_name("rules")
+
+# SARIF level and GitHub `security-severity` score of each severity.
+_SARIF_LEVELS = {"low": "note", "medium": "warning", "high": "error"}
+_SCORES = {"low": 3.0, "medium": 5.5, "high": 8.0}
+
+_RULE_ID = r"[\w.-]+/[\w./-]+"
+_NOSEC = re.compile(rf"(?:#|//|/\*|--)\s*nosec\b(?:[:\s]+(?P<rules>{_RULE_ID}(?:\s*,\s*{_RULE_ID})*))?")
+
+
+@dataclass(frozen=True)
+class Rule:
+    id: str
+    severity: str
+    message: str
+    query: str
+    languages: Tuple[str, ...]
+    help: Optional[str] = None
+    cwe: Optional[int] = None
+    source: str = "bundled"  # the pack file, for user packs
+
+    def to_dict(self) -> dict:
+        return {
+            "id": self.id,
+            "severity": self.severity,
+            "languages": list(self.languages),
+            "message": self.message,
+            "help": self.help,
+            "cwe": self.cwe,
+            "source": self.source,
+        }
+
+    def sarif(self) -> SarifRule:
+        name = "".join(part.capitalize() for part in re.split(r"[/_-]", self.id) if part)
+        tags = ["security"] + ([f"external/cwe/cwe-{self.cwe}"] if self.cwe else [])
+        return SarifRule(
+            self.id, name, self.message, self.help, _SARIF_LEVELS[self.severity], tags, _SCORES[self.severity]
+        )
+
+
+def load_pack(path: Path, source: Optional[str] = None) -> List[Rule]:
+    """The rules of one YAML pack; ValueError naming the pack and rule for a malformed one."""
+    path = Path(path)
+    try:
+        data = yaml.safe_load(path.read_text(encoding="utf-8"))
+    except yaml.YAMLError as exc:
+        raise ValueError(f"{path}: invalid YAML: {exc}") from exc
+    except OSError as exc:
+        raise ValueError(f"Cannot read rule pack {path}: {exc}") from exc
+    if not isinstance(data, dict) or not isinstance(data.get("rules"), list):
+        raise ValueError(f"{path}: expected a mapping with a 'rules' list")
+    default_languages = data.get("languages") or []
+    rules = []
+    for number, entry in enumerate(data["rules"], 1):
+        where = f"{path}: rule {number}"
+        if not isinstance(entry, dict):
+            raise ValueError(f"{where}: expected a mapping")
+        missing = [k for k in ("id", "severity", "message", "query") if not entry.get(k)]
+        if missing:
+            raise ValueError(f"{where}: missing {', '.join(repr(k) for k in missing)}")
+        if entry["severity"] not in SEVERITIES:
+            raise ValueError(f"{where}: unknown severity '{entry['severity']}' (expected {', '.join(SEVERITIES)})")
+        languages = entry.get("languages") or default_languages
+        if not languages:
+            raise ValueError(f"{where}: no 'languages' for the rule or the pack")
+        rules.append(Rule(
+            str(entry["id"]), entry["severity"], str(entry["message"]).strip(), entry["query"], tuple(languages),
+            str(entry["help"]).strip() if entry.get("help") else None, entry.get("cwe"),
+            source or path.as_posix(),
+        ))
+    return rules
+
+
+def bundled_rules() -> List[Rule]:
+    return [rule for pack in sorted(RULES_DIR.glob("*.yaml")) for rule in load_pack(pack, "bundled")]
+
+
+def select_rules(
+    rules: Sequence[Rule], patterns: Sequence[str] = (), severity: str = "low"
+) -> List[Rule]:
+    """`rules` whose id matches one of `patterns` (globs; all when empty) at or above `severity`."""
+    if severity not in SEVERITIES:
+        raise ValueError(f"Unknown severity '{severity}' (expected {', '.join(SEVERITIES)})")
+    seen: Dict[str, Rule] = {}
+    for rule in rules:
+        if rule.id in seen:
+            raise ValueError(f"Rule '{rule.id}' is defined twice ({seen[rule.id].source} and {rule.source})")
+        seen[rule.id] = rule
+    floor = SEVERITIES.index(severity)
+    selected = [
+        r for r in rules
+        if SEVERITIES.index(r.severity) >= floor and (not patterns or any(fnmatch.fnmatch(r.id, p) for p in patterns))
+    ]
+    unmatched = [p for p in patterns if not any(fnmatch.fnmatch(r.id, p) for r in rules)]
+    if unmatched:
+        raise ValueError(f"No rule matches '{unmatched[0]}'")
+    return selected
+
+
+_COMPILED: Dict[Tuple[str, str], Query] = {}
+
+
+def compile_rule(rule: Rule, language: str) -> Query:
+    key = (rule.id, language)
+    if key not in _COMPILED:
+        try:
+            _COMPILED[key] = Query(load_language(language), rule.query)
+        except ValueError as exc:  # QueryError subclasses ValueError
+            raise ValueError(f"Rule '{rule.id}' does not compile for {language}: {exc}") from exc
+    return _COMPILED[key]
+
+
+@dataclass
+class Finding:
+    rule: Rule
+    path: str
+    line: int  # 1-based
+    column: int  # 1-based
+    end_line: int
+    end_column: int
+    code: str  # the first source line of the finding, stripped
+    suppressed: Optional[str] = None  # the suppressing comment
+
+    def to_dict(self) -> dict:
+        return {
+            "rule": self.rule.id,
+            "severity": self.rule.severity,
+            "message": self.rule.message,
+            "cwe": self.rule.cwe,
+            "path": self.path,
+            "line": self.line,
+            "column": self.column,
+            "end_line": self.end_line,
+            "end_column": self.end_column,
+            "code": self.code,
+            "suppressed": self.suppressed is not None,
+        }
+
+    def to_text(self) -> str:
+        return f"{self.path}:{self.line}:{self.column}: {self.rule.severity}: {self.rule.message} [{self.rule.id}]"
+
+
+def suppressions(source: bytes) -> Dict[int, Tuple[Optional[frozenset], str]]:
+    """1-based line -> (suppressed rule ids, None for all; the comment) for the lines `nosec` comments cover."""
+    covered: Dict[int, Tuple[Optional[frozenset], str]] = {}
+    for number, raw in enumerate(source.splitlines(), 1):
+        text = raw.decode("utf-8", errors="replace")
+        match = _NOSEC.search(text)
+        if match is None:
+            continue
+        rules = frozenset(r.strip() for r in match.group("rules").split(",")) if match.group("rules") else None
+        comment = text[match.start():].strip()
+        covered[number] = (rules, comment)
+        if not text[:match.start()].strip():  # a comment alone on its line covers the next one
+            covered.setdefault(number + 1, (rules, comment))
+    return covered
+
+
+def _reported(captures: Dict[str, List[Node]]) -> Node:
+    if captures.get("finding"):
+        return captures["finding"][0]
+    return max((n for nodes in captures.values() for n in nodes), key=lambda n: n.end_byte - n.start_byte)
+
+
+def file_findings(parsed: ParsedFile, label: str, rules: Sequence[Rule]) -> List[Finding]:
+    """Findings of `rules` (those for the file's language) in `parsed`, in source order."""
+    lines = parsed.source.splitlines()
+    covered = suppressions(parsed.source)
+    found: Dict[Tuple[str, int, int], Finding] = {}
+    for rule in rules:
+        if parsed.language not in rule.languages:
+            continue
+        for _, captures in QueryCursor(compile_rule(rule, parsed.language)).matches(parsed.root):
+            if not captures:
+                continue
+            node = _reported(captures)
+            key = (rule.id, node.start_byte, node.end_byte)
+            if key in found:
+                continue
+            row = node.start_point[0]
+            code = lines[row].decode("utf-8", errors="replace").strip() if row < len(lines) else ""
+            finding = Finding(
+                rule, label, row + 1, node.start_point[1] + 1, node.end_point[0] + 1, node.end_point[1] + 1, code
+            )
+            ids, comment = covered.get(finding.line, (frozenset(), None))
+            if comment is not None and (ids is None or rule.id in ids):
+                finding.suppressed = comment
+            found[key] = finding
+    return sorted(found.values(), key=lambda f: (f.line, f.column, f.rule.id))
+
+
+@dataclass
+class SecurityReport:
+    rules: List[Rule]
+    findings: List[Finding] = field(default_factory=list)
+    checked: int = 0
+
+    @property
+    def active(self) -> List[Finding]:
+        return [f for f in self.findings if f.suppressed is None]
+
+    def to_json(self) -> str:
+        return json.dumps(
+            {
+                "rules": [r.id for r in self.rules],
+                "checked": self.checked,
+                "findings": [f.to_dict() for f in self.findings],
+            },
+            indent=2,
+        )
+
+    def to_text(self) -> str:
+        return "".join(f.to_text() + "\n" for f in self.active)
+
+    def to_sarif(self, root: Optional[Path] = None) -> str:
+        described = {r.id: r.sarif() for r in self.rules}
+        results = [
+            SarifResult(
+                described[f.rule.id],
+                f.rule.message,
+                SarifLocation(f.path, f.line, f.column, f.end_line, f.end_column),
+                (f.code,),
+                suppression=f.suppressed,
+            )
+            for f in self.findings
+        ]
+        return sarif_to_json(results, list(described.values()), root)
+
+    def summary(self) -> str:
+        counts = {s: sum(1 for f in self.active if f.rule.severity == s) for s in reversed(SEVERITIES)}
+        text = ", ".join(f"{n} {s}" for s, n in counts.items() if n) or "no findings"
+        suppressed = len(self.findings) - len(self.active)
+        return f"{len(self.rules)} rules checked {self.checked} files: {text}" + (
+            f" ({suppressed} suppressed)" if suppressed else ""
+        )
+
+
+def scan_security(
+    root: Path,
+    rules: Sequence[Rule],
+    include: Sequence[str] | None = None,
+    exclude: Sequence[str] | None = None,
+) -> SecurityReport:
+    """Run `rules` over a file or every source file under a directory (labels relative to it)."""
+    root = Path(root)
+    if root.is_file():
+        targets = [(root, root.as_posix())]
+    else:
+        base = root.resolve()
+        targets = [(p, p.relative_to(base).as_posix()) for p in iter_source_files(base, include, exclude)]
+    languages = {language for rule in rules for language in rule.languages}
+    report = SecurityReport(list(rules))
+    for path, label in targets:
+        try:
+            parsed = parse_file(path)
+        except (ValueError, RuntimeError, OSError) as exc:
+            failures.record(path, exc)
+            continue
+        if parsed.language not in languages:
+            continue
+        report.checked += 1
+        report.findings.extend(file_findings(parsed, label, rules))
+    return report
+
+
+def check_rules(rules: Sequence[Rule]) -> Iterator[Tuple[Rule, str, str]]:
+    """(rule, language, error) for every rule that does not compile; grammars that cannot load are skipped."""
+    for rule in rules:
+        for language in rule.languages:
+            try:
+                load_language(language)
+            except RuntimeError:
+                continue
+            try:
+                compile_rule(rule, language)
+            except ValueError as exc:
+                yield rule, language, str(exc)
+
+
+__all__ = [
+    "RULES_DIR",
+    "SEVERITIES",
+    "Finding",
+    "Rule",
+    "SecurityReport",
+    "bundled_rules",
+    "check_rules",
+    "compile_rule",
+    "file_findings",
+    "load_pack",
+    "scan_security",
+    "select_rules",
+    "suppressions",
+]
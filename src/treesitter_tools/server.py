@@ -0,0 +1,475 @@
+"""Long-lived HTTP (JSON) and gRPC daemon that keeps grammars and parsers warm."""
+
+from __future__ import annotations
+
+import json
+import threading
+import time
+from collections import OrderedDict
+from contextlib import contextmanager
+from dataclasses import dataclass, field
+from http.server import BaseHTTPRequestHandler, ThreadingHTTPServer
+from pathlib import Path
+from queue import Empty, Full, LifoQueue
+from typing import Any, Callable, Dict, Iterator, Optional, Tuple
+
+from tree_sitter import Language, Parser, Query
+
+from . import __version__, charsets
+from .core import detect_language, is_binary_file, load_language, query_tree, symbols_from_tree
+from .telemetry import PROMETHEUS_CONTENT_TYPE, MetricsRegistry, Tracer
+
+# Upper bound on request bodies so a single client cannot exhaust memory.
+MAX_BODY_BYTES = 32 * 1024 * 1024
+
+GRPC_SERVICE = "treesitter_tools.v1.TreeSitterTools"
+
+
+class ServiceError(Exception):
+    """Request failure carrying an HTTP status (mapped to a gRPC code for gRPC clients)."""
+
+    def __init__(self, status: int, message: str):
+        super().__init__(message)
+        self.status = status
+
+
+class ParserPool:
+    """
+    Per-language pools of `Parser` instances.
+
+    Parsers are not thread-safe, so each request checks one out for the duration
+    of a parse. Up to `size` parsers per language are kept warm; bursts beyond that
+    create temporary parsers instead of blocking.
+    """
+
+    def __init__(self, size: int = 4):
+        self.size = max(size, 1)
+        self._languages: Dict[str, Language] = {}
+        self._pools: Dict[str, LifoQueue] = {}
+        self._lock = threading.Lock()
+        self.hits = 0  # checkouts served by a warm parser
+        self.misses = 0
+        self.grammar_errors: Dict[str, int] = {}  # language -> failed grammar loads
+
+    def language(self, name: str) -> Language:
+        with self._lock:
+            if name not in self._languages:
+                try:
+                    self._languages[name] = load_language(name)
+                except (ValueError, RuntimeError):
+                    self.grammar_errors[name] = self.grammar_errors.get(name, 0) + 1
+                    raise
+                self._pools[name] = LifoQueue(maxsize=self.size)
+            return self._languages[name]
+
+    @contextmanager
+    def parser(self, name: str) -> Iterator[Parser]:
+        language = self.language(name)
+        pool = self._pools[name]
+        try:
+            parser = pool.get_nowait()
+            warm = True
+        except Empty:
+            parser = Parser()
+            parser.language = language
+            warm = False
+        with self._lock:
+            if warm:
+                self.hits += 1
+            else:
+                self.misses += 1
+        try:
+            yield parser
+        finally:
+            try:
+                pool.put_nowait(parser)
+            except Full:
+                pass  # the pool is already warm; let this parser go
+
+    def warm(self) -> list:
+        with self._lock:
+            return sorted(self._languages)
+
+
+class _QueryCache:
+    """Small LRU of compiled queries keyed by (language, query text)."""
+
+    def __init__(self, capacity: int = 256):
+        self.capacity = capacity
+        self._items: "OrderedDict[Tuple[str, str], Query]" = OrderedDict()
+        self._lock = threading.Lock()
+        self.hits = 0
+        self.misses = 0
+
+    def get(self, language: str, grammar: Language, text: str) -> Query:
+        key = (language, text)
+        with self._lock:
+            if key in self._items:
+                self._items.move_to_end(key)
+                self.hits += 1
+                return self._items[key]
+            self.misses += 1
+        compiled = Query(grammar, text)
+        with self._lock:
+            self._items[key] = compiled
+            while len(self._items) > self.capacity:
+                self._items.popitem(last=False)
+        return compiled
+
+
+@dataclass
+class ServiceStats:
+    started: float = field(default_factory=time.time)
+    requests: Dict[str, int] = field(default_factory=dict)
+    errors: int = 0
+
+    def to_dict(self) -> dict:
+        return {
+            "uptime_seconds": round(time.time() - self.started, 3),
+            "requests": dict(sorted(self.requests.items())),
+            "errors": self.errors,
+        }
+
+
+class ToolService:
+    """
+    Transport-independent request handlers shared by the HTTP and gRPC servers.
+
+    Requests carry either inline `source` (with `language` or a `path` hint for
+    detection) or a `path` under `root`; paths outside `root` are refused. Every
+    call is counted and timed in `registry` (served as Prometheus `/metrics`) and
+    runs inside an OpenTelemetry span when the API is installed.
+    """
+
+    def __init__(self, root: Path, pool_size: int = 4):
+        self.root = Path(root).resolve()
+        self.pool = ParserPool(pool_size)
+        self.queries = _QueryCache()
+        self.stats = ServiceStats()
+        self._stats_lock = threading.Lock()
+        self.methods: Dict[str, Callable[[dict], dict]] = {
+            "parse": self.parse,
+            "extract": self.extract,
+            "query": self.query,
+        }
+        self.tracer = Tracer()
+        self.registry = MetricsRegistry()
+        self._instrument()
+
+    def _instrument(self) -> None:
+        r = self.registry
+        r.gauge("treesitter_tools_build_info", "Running version", ["version"], lambda: {(__version__,): 1})
+        r.gauge("treesitter_tools_uptime_seconds", "Seconds since the service started",
+                collect=lambda: {(): round(time.time() - self.stats.started, 3)})
+        r.gauge("treesitter_tools_warm_languages", "Languages with a loaded grammar",
+                collect=lambda: {(): len(self.pool.warm())})
+        self.requests = r.counter(
+            "treesitter_tools_requests_total", "Requests by method, transport, and HTTP-style status",
+            ["method", "transport", "status"],
+        )
+        self.latency = r.histogram(
+            "treesitter_tools_request_duration_seconds", "Request latency", ["method", "transport"]
+        )
+        self.parses = r.counter("treesitter_tools_parses_total", "Source files parsed", ["language"])
+        self.parse_latency = r.histogram("treesitter_tools_parse_duration_seconds", "Parse time", ["language"])
+        self.parse_bytes = r.counter("treesitter_tools_parsed_bytes_total", "Bytes of source parsed", ["language"])
+        self.syntax_errors = r.counter(
+            "treesitter_tools_syntax_errors_total", "Parses whose tree contains ERROR or MISSING nodes", ["language"]
+        )
+        r.counter(
+            "treesitter_tools_grammar_errors_total", "Grammar loads that failed", ["language"],
+            lambda: {(name,): n for name, n in self.pool.grammar_errors.items()},
+        )
+        r.counter(
+            "treesitter_tools_cache_requests_total", "Parser pool checkouts and compiled-query lookups",
+            ["cache", "result"],
+            lambda: {
+                ("parser", "hit"): self.pool.hits, ("parser", "miss"): self.pool.misses,
+                ("query", "hit"): self.queries.hits, ("query", "miss"): self.queries.misses,
+            },
+        )
+
+    def metrics(self) -> str:
+        """The Prometheus text exposition of every counter, gauge, and histogram."""
+        return self.registry.render()
+
+    def call(self, method: str, payload: Any, transport: str = "direct", carrier: Optional[dict] = None) -> dict:
+        """
+        Run `method`. `transport` labels the metrics and names the span ("http",
+        "grpc", or "direct"); `carrier` holds the request headers or metadata a
+        W3C `traceparent` is read from.
+        """
+        known = method in self.methods
+        if transport == "grpc":
+            name = f"{GRPC_SERVICE}/{method.capitalize()}"
+        else:
+            name = f"POST /v1/{method}" if transport == "http" else f"treesitter_tools.{method}"
+        attributes = {"treesitter_tools.method": method, "treesitter_tools.transport": transport}
+        started = time.perf_counter()
+        status = 200
+        with self.tracer.span(name, attributes, carrier, server=transport != "direct") as span:
+            try:
+                return self._dispatch(method, payload)
+            except ServiceError as exc:
+                status = exc.status
+                raise
+            except Exception:
+                status = 500
+                raise
+            finally:
+                span.set_attribute("treesitter_tools.status", status)
+                label = method if known else "unknown"  # keeps arbitrary paths out of the label set
+                self.requests.inc(method=label, transport=transport, status=str(status))
+                self.latency.observe(time.perf_counter() - started, method=label, transport=transport)
+
+    def _dispatch(self, method: str, payload: Any) -> dict:
+        handler = self.methods.get(method)
+        if handler is None:
+            raise ServiceError(404, f"Unknown method: {method}")
+        if not isinstance(payload, dict):
+            raise ServiceError(400, "Request body must be a JSON object")
+        with self._stats_lock:
+            self.stats.requests[method] = self.stats.requests.get(method, 0) + 1
+        try:
+            return handler(payload)
+        except ServiceError:
+            self._count_error()
+            raise
+        except (ValueError, RuntimeError) as exc:
+            self._count_error()
+            raise ServiceError(400, str(exc)) from exc
+        except OSError as exc:
+            self._count_error()
+            raise ServiceError(404, str(exc)) from exc
+        except Exception as exc:  # a badly shaped payload (`"max_chunk_size": "x"`) must still get a reply
+            self._count_error()
+            raise ServiceError(500, f"Internal error: {type(exc).__name__}: {exc}") from exc
+
+    def _count_error(self) -> None:
+        with self._stats_lock:
+            self.stats.errors += 1
+
+    def _load(self, payload: dict) -> Tuple[bytes, str]:
+        hint = payload.get("path")
+        if "source" in payload:
+            source = payload["source"]
+            if not isinstance(source, str):
+                raise ServiceError(400, "'source' must be a string")
+            data = source.encode("utf-8")
+        elif hint:
+            path = (self.root / hint).resolve()
+            try:
+                path.relative_to(self.root)
+            except ValueError:
+                raise ServiceError(403, f"Path is outside the server root: {hint}") from None
+            if not path.is_file():
+                raise ServiceError(404, f"No such file: {hint}")
+            if is_binary_file(path):
+                raise ServiceError(400, f"Refusing to parse binary file: {hint}")
+            data = charsets.canonical(path.read_bytes()).data
+        else:
+            raise ServiceError(400, "Provide 'source' or 'path'")
+        language = detect_language(Path(hint or ""), payload.get("language"))
+        if not language:
+            raise ServiceError(400, "Cannot detect language; pass 'language'")
+        return data, language
+
+    def _parse(self, payload: dict):
+        source, language = self._load(payload)
+        attributes = {"treesitter_tools.language": language, "treesitter_tools.bytes": len(source)}
+        with self.tracer.span("parse", attributes) as span:
+            started = time.perf_counter()
+            with self.pool.parser(language) as parser:
+                tree = parser.parse(source)
+            self.parse_latency.observe(time.perf_counter() - started, language=language)
+            root = tree.root_node
+            span.set_attribute("treesitter_tools.has_error", root.has_error)
+        self.parses.inc(language=language)
+        self.parse_bytes.inc(len(source), language=language)
+        if root.has_error:
+            self.syntax_errors.inc(language=language)
+        return root, source, language
+
+    def parse(self, payload: dict) -> dict:
+        root, _, language = self._parse(payload)
+        result = {
+            "language": language,
+            "type": root.type,
+            "has_error": root.has_error,
+            "start_line": root.start_point[0] + 1,
+            "end_line": root.end_point[0] + 1,
+            "child_count": root.child_count,
+        }
+        if payload.get("sexp"):
+            result["sexp"] = str(root)
+        return result
+
+    def extract(self, payload: dict) -> dict:
+        root, source, language = self._parse(payload)
+        symbols = symbols_from_tree(root, source, language, payload.get("max_chunk_size"))
+        include_content = bool(payload.get("include_content"))
+        out = []
+        for sym in symbols:
+            data = sym.to_dict()
+            if not include_content:
+                data.pop("content", None)
+            out.append(data)
+        return {"language": language, "symbols": out}
+
+    def query(self, payload: dict) -> dict:
+        text = payload.get("query")
+        if not isinstance(text, str) or not text.strip():
+            raise ServiceError(400, "'query' is required")
+        root, source, language = self._parse(payload)
+        compiled = self.queries.get(language, self.pool.language(language), text)
+        return {"language": language, "matches": query_tree(root, source, compiled)}
+
+    def health(self) -> dict:
+        caches = {
+            "parser": {"hits": self.pool.hits, "misses": self.pool.misses},
+            "query": {"hits": self.queries.hits, "misses": self.queries.misses},
+        }
+        return {
+            "status": "ok",
+            "version": __version__,
+            "warm_languages": self.pool.warm(),
+            **self.stats.to_dict(),
+            "caches": caches,
+        }
+
+
+def _handler_class(service: ToolService, api: bool = True):
+    class Handler(BaseHTTPRequestHandler):
+        server_version = f"treesitter-tools/{__version__}"
+        protocol_version = "HTTP/1.1"
+
+        def log_message(self, format, *args):  # noqa: A002 - signature fixed by BaseHTTPRequestHandler
+            # Keep stdout/stderr quiet; the daemon is usually run under a supervisor.
+            pass
+
+        def _send(self, status: int, body: dict) -> None:
+            self._write(status, json.dumps(body).encode("utf-8"), "application/json")
+
+        def _write(self, status: int, data: bytes, content_type: str) -> None:
+            self.send_response(status)
+            self.send_header("Content-Type", content_type)
+            self.send_header("Content-Length", str(len(data)))
+            self.end_headers()
+            self.wfile.write(data)
+
+        def do_GET(self):
+            if self.path in {"/healthz", "/v1/health"}:
+                self._send(200, service.health())
+            elif self.path == "/metrics":
+                self._write(200, service.metrics().encode("utf-8"), PROMETHEUS_CONTENT_TYPE)
+            else:
+                self._send(404, {"error": f"Not found: {self.path}"})
+
+        def _content_length(self) -> Optional[int]:
+            """The request body's length, or None after answering a request whose length is unusable."""
+            header = self.headers.get("Content-Length")
+            if header is None:
+                if "Transfer-Encoding" not in self.headers:
+                    return 0
+                status, error = 411, "Content-Length is required"
+            else:
+                try:
+                    length = int(header)
+                except ValueError:
+                    length = -1
+                if 0 <= length <= MAX_BODY_BYTES:
+                    return length
+                if length > MAX_BODY_BYTES:
+                    status, error = 413, "Request body too large"
+                else:
+                    status, error = 400, f"Invalid Content-Length: {header!r}"
+            self.close_connection = True  # the unread body would be taken for the next request
+            self._send(status, {"error": error})
+            return None
+
+        def do_POST(self):
+            if not api or not self.path.startswith("/v1/"):
+                self._send(404, {"error": f"Not found: {self.path}"})
+                return
+            length = self._content_length()
+            if length is None:
+                return
+            try:
+                payload = json.loads(self.rfile.read(length) or b"{}")
+            except ValueError as exc:  # invalid JSON, or a body that is not UTF-8
+                self._send(400, {"error": f"Invalid JSON: {exc}"})
+                return
+            headers = {k.lower(): v for k, v in self.headers.items()}  # propagators look up lowercase keys
+            try:
+                self._send(200, service.call(self.path[len("/v1/"):], payload, "http", headers))
+            except ServiceError as exc:
+                self._send(exc.status, {"error": str(exc)})
+
+    return Handler
+
+
+def make_http_server(service: ToolService, host: str = "127.0.0.1", port: int = 8765) -> ThreadingHTTPServer:
+    """Create (but don't start) the HTTP server; `port=0` picks a free port."""
+    server = ThreadingHTTPServer((host, port), _handler_class(service))
+    server.daemon_threads = True
+    return server
+
+
+def make_metrics_server(service: ToolService, host: str = "127.0.0.1", port: int = 9464) -> ThreadingHTTPServer:
+    """An HTTP server with only `GET /metrics` and `/healthz`, for scraping a gRPC-only daemon."""
+    server = ThreadingHTTPServer((host, port), _handler_class(service, api=False))
+    server.daemon_threads = True
+    return server
+
+
+def make_grpc_server(service: ToolService, host: str = "127.0.0.1", port: int = 50051, workers: int = 8):
+    """
+    Create (but don't start) a gRPC server for `GRPC_SERVICE` with unary methods
+    Parse, Extract, Query, and Health.
+
+    Requires the optional `grpcio` package. Messages are UTF-8 JSON objects with the
+    same fields as the HTTP API (no .proto compilation needed on either side).
+    """
+    try:
+        import grpc
+    except ImportError as exc:
+        raise RuntimeError("gRPC serving requires the optional 'grpcio' package (pip install grpcio)") from exc
+    from concurrent import futures
+
+    codes = {400: grpc.StatusCode.INVALID_ARGUMENT, 403: grpc.StatusCode.PERMISSION_DENIED,
+             404: grpc.StatusCode.NOT_FOUND}
+
+    def unary(method: Optional[str]):
+        def handle(request, context):
+            if method is None:
+                return service.health()
+            try:
+                return service.call(method, request, "grpc", dict(context.invocation_metadata()))
+            except ServiceError as exc:
+                context.abort(codes.get(exc.status, grpc.StatusCode.INTERNAL), str(exc))
+
+        return grpc.unary_unary_rpc_method_handler(
+            handle,
+            request_deserializer=lambda data: json.loads(data or b"{}"),
+            response_serializer=lambda obj: json.dumps(obj).encode("utf-8"),
+        )
+
+    handlers = {"Parse": unary("parse"), "Extract": unary("extract"), "Query": unary("query"), "Health": unary(None)}
+    server = grpc.server(futures.ThreadPoolExecutor(max_workers=workers))
+    server.add_generic_rpc_handlers((grpc.method_handlers_generic_handler(GRPC_SERVICE, handlers),))
+    bound = server.add_insecure_port(f"{host}:{port}")
+    if not bound:
+        raise RuntimeError(f"Could not bind gRPC server to {host}:{port}")
+    server.bound_port = bound
+    return server
+
+
+__all__ = [
+    "GRPC_SERVICE",
+    "ParserPool",
+    "ServiceError",
+    "ToolService",
+    "make_grpc_server",
+    "make_http_server",
+    "make_metrics_server",
+]
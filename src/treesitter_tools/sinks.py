@@ -0,0 +1,387 @@
+"""Output sinks: where a command's report goes (stdout, a file, gzip, S3-compatible storage, a webhook)."""
+
+from __future__ import annotations
+
+import datetime
+import gzip
+import hashlib
+import hmac
+import os
+import sys
+import tempfile
+import time
+import urllib.error
+import urllib.parse
+import urllib.request
+from pathlib import Path
+from typing import BinaryIO, Callable, Dict, Mapping, Optional
+
+# Uploads are spooled in memory up to this size, then to a temporary file.
+SPOOL_BYTES = 8 * 1024 * 1024
+
+WEBHOOK_TOKEN_ENV = "TREESITTER_TOOLS_WEBHOOK_TOKEN"
+
+
+class SinkError(OSError):
+    """An output destination could not be opened or the upload failed."""
+
+
+class Sink:
+    """
+    A write-once destination for command output. Text is written UTF-8 encoded.
+    Use as a context manager: on success `close()` finishes the output (uploads
+    for remote sinks); on an exception `abort()` discards it instead.
+    """
+
+    name = "<sink>"
+
+    def write(self, text: str) -> None:
+        self.write_bytes(text.encode("utf-8"))
+
+    def write_bytes(self, data: bytes) -> None:
+        raise NotImplementedError
+
+    def flush(self) -> None:
+        pass
+
+    def close(self) -> None:
+        pass
+
+    def abort(self) -> None:
+        self.close()
+
+    def __enter__(self) -> "Sink":
+        return self
+
+    def __exit__(self, exc_type, exc, tb) -> None:
+        if exc_type is None:
+            self.close()
+        else:
+            self.abort()
+
+    def __str__(self) -> str:
+        return self.name
+
+
+class StdoutSink(Sink):
+    name = "<stdout>"
+
+    def write(self, text: str) -> None:
+        sys.stdout.write(text)
+
+    def write_bytes(self, data: bytes) -> None:
+        buffer = getattr(sys.stdout, "buffer", None)
+        if buffer is None:
+            sys.stdout.write(data.decode("utf-8", "replace"))
+        else:
+            sys.stdout.flush()
+            buffer.write(data)
+
+    def flush(self) -> None:
+        sys.stdout.flush()
+
+    def close(self) -> None:
+        self.flush()
+
+
+class FileSink(Sink):
+    """
+    A local file. With `append_at`, an existing file is cut back to that many
+    bytes and written after them (how a resumed job drops uncommitted output).
+    """
+
+    def __init__(self, path: Path, append_at: Optional[int] = None):
+        self.path = Path(path)
+        self.name = str(path)
+        if append_at is None:
+            self._file: BinaryIO = self.path.open("wb")
+        else:
+            self._file = self.path.open("r+b")
+            self._file.truncate(append_at)
+            self._file.seek(append_at)
+
+    def write_bytes(self, data: bytes) -> None:
+        self._file.write(data)
+
+    def flush(self) -> None:
+        self._file.flush()
+
+    def tell(self) -> int:
+        return self._file.tell()
+
+    def sync(self) -> None:
+        """Flush and fsync, so everything written so far survives a crash."""
+        self._file.flush()
+        os.fsync(self._file.fileno())
+
+    def close(self) -> None:
+        self._file.close()
+
+
+class GzipSink(Sink):
+    """Compress everything written and pass it on to another sink."""
+
+    def __init__(self, inner: Sink):
+        self.inner = inner
+        self.name = inner.name
+        if isinstance(inner, _BufferedUpload):
+            inner.content_type = "application/gzip"
+        self._gzip = gzip.GzipFile(fileobj=_SinkWriter(inner), mode="wb", mtime=0)
+
+    def write_bytes(self, data: bytes) -> None:
+        self._gzip.write(data)
+
+    def flush(self) -> None:
+        # A sync flush lets `zcat` on a growing file see every record written so far.
+        self._gzip.flush()
+        self.inner.flush()
+
+    def close(self) -> None:
+        self._gzip.close()
+        self.inner.close()
+
+    def abort(self) -> None:
+        self.inner.abort()
+
+
+class _SinkWriter:
+    """The file-like object GzipFile writes its compressed stream to."""
+
+    def __init__(self, sink: Sink):
+        self.sink = sink
+
+    def write(self, data: bytes) -> int:
+        self.sink.write_bytes(bytes(data))
+        return len(data)
+
+    def flush(self) -> None:
+        pass
+
+
+def _sniff_content_type(head: bytes) -> str:
+    stripped = head.lstrip()
+    if stripped[:1] in (b"{", b"["):
+        return "application/json"
+    try:
+        head.decode("utf-8")
+    except UnicodeDecodeError:
+        return "application/octet-stream"
+    return "text/plain; charset=utf-8"
+
+
+class _BufferedUpload(Sink):
+    """Collects the output and sends it in one request when closed."""
+
+    retries = 3
+    backoff = 1.0  # seconds; doubles after every failed attempt
+    timeout = 60.0
+
+    def __init__(self, sleep: Callable[[float], None] = time.sleep):
+        self._buffer = tempfile.SpooledTemporaryFile(max_size=SPOOL_BYTES)
+        self._sha256 = hashlib.sha256()
+        self._head = b""
+        self._closed = False
+        self.content_type: Optional[str] = None
+        self.size = 0
+        self.sleep = sleep
+
+    def write_bytes(self, data: bytes) -> None:
+        if len(self._head) < 512:
+            self._head += data[: 512 - len(self._head)]
+        self._buffer.write(data)
+        self._sha256.update(data)
+        self.size += len(data)
+
+    def close(self) -> None:
+        if self._closed:
+            return
+        self._closed = True
+        try:
+            self._send_with_retries()
+        finally:
+            self._buffer.close()
+
+    def abort(self) -> None:
+        self._closed = True
+        self._buffer.close()
+
+    def _request(self) -> urllib.request.Request:
+        raise NotImplementedError
+
+    def _send_with_retries(self) -> None:
+        if self.content_type is None:
+            self.content_type = _sniff_content_type(self._head)
+        delay = self.backoff
+        for attempt in range(self.retries + 1):
+            self._buffer.seek(0)
+            request = self._request()
+            try:
+                with urllib.request.urlopen(request, timeout=self.timeout) as response:
+                    response.read()
+                return
+            except urllib.error.HTTPError as exc:
+                detail = exc.read().decode("utf-8", "replace")[:200]
+                error = SinkError(f"HTTP {exc.code} from {self.name}: {detail}")
+                if not (exc.code == 429 or exc.code >= 500):
+                    raise error from exc
+            except (urllib.error.URLError, TimeoutError, ConnectionError) as exc:
+                error = SinkError(f"Cannot reach {self.name}: {exc}")
+            if attempt < self.retries:
+                self.sleep(delay)
+                delay *= 2
+        raise error
+
+
+class WebhookSink(_BufferedUpload):
+    """POST the whole output to an HTTP(S) endpoint."""
+
+    def __init__(self, url: str, headers: Optional[Mapping[str, str]] = None, **kwargs):
+        super().__init__(**kwargs)
+        self.url = url
+        parts = urllib.parse.urlsplit(url)
+        # Query strings often carry tokens; keep them out of log lines.
+        self.name = urllib.parse.urlunsplit((parts.scheme, parts.netloc, parts.path, "", ""))
+        self.headers = dict(headers or {})
+
+    def _request(self) -> urllib.request.Request:
+        headers = {"Content-Type": self.content_type, "Content-Length": str(self.size), **self.headers}
+        return urllib.request.Request(self.url, data=self._buffer, headers=headers, method="POST")
+
+
+class S3Sink(_BufferedUpload):
+    """
+    PUT the output as one object to S3 or an S3-compatible store (MinIO, R2, GCS
+    interop), signed with AWS Signature Version 4. A custom `endpoint` is
+    addressed path-style (`<endpoint>/<bucket>/<key>`), AWS virtual-hosted style.
+    """
+
+    def __init__(
+        self,
+        bucket: str,
+        key: str,
+        access_key: str,
+        secret_key: str,
+        region: str = "us-east-1",
+        endpoint: Optional[str] = None,
+        session_token: Optional[str] = None,
+        clock: Callable[[], datetime.datetime] = lambda: datetime.datetime.now(datetime.timezone.utc),
+        **kwargs,
+    ):
+        super().__init__(**kwargs)
+        if not bucket or not key:
+            raise SinkError("S3 output needs s3://BUCKET/KEY")
+        self.bucket = bucket
+        self.key = key
+        self.access_key = access_key
+        self.secret_key = secret_key
+        self.region = region
+        self.endpoint = endpoint.rstrip("/") if endpoint else None
+        self.session_token = session_token
+        self.clock = clock
+        self.name = f"s3://{bucket}/{key}"
+
+    def _url(self) -> str:
+        path = urllib.parse.quote(self.key, safe="/~")
+        if self.endpoint:
+            return f"{self.endpoint}/{self.bucket}/{path}"
+        return f"https://{self.bucket}.s3.{self.region}.amazonaws.com/{path}"
+
+    def signed_headers(self, url: str) -> Dict[str, str]:
+        parts = urllib.parse.urlsplit(url)
+        now = self.clock()
+        amz_date = now.strftime("%Y%m%dT%H%M%SZ")
+        day = now.strftime("%Y%m%d")
+        payload_hash = self._sha256.hexdigest()
+        headers = {"host": parts.netloc, "x-amz-content-sha256": payload_hash, "x-amz-date": amz_date}
+        if self.session_token:
+            headers["x-amz-security-token"] = self.session_token
+        names = sorted(headers)
+        canonical = "\n".join(
+            ["PUT", parts.path, "", *(f"{name}:{headers[name]}" for name in names), "", ";".join(names), payload_hash]
+        )
+        scope = f"{day}/{self.region}/s3/aws4_request"
+        to_sign = "\n".join(
+            ["AWS4-HMAC-SHA256", amz_date, scope, hashlib.sha256(canonical.encode("utf-8")).hexdigest()]
+        )
+        key = f"AWS4{self.secret_key}".encode("utf-8")
+        for part in (day, self.region, "s3", "aws4_request"):
+            key = hmac.new(key, part.encode("utf-8"), hashlib.sha256).digest()
+        signature = hmac.new(key, to_sign.encode("utf-8"), hashlib.sha256).hexdigest()
+        headers["Authorization"] = (
+            f"AWS4-HMAC-SHA256 Credential={self.access_key}/{scope}, "
+            f"SignedHeaders={';'.join(names)}, Signature={signature}"
+        )
+        del headers["host"]  # urllib sends it; it only needs to be signed
+        return headers
+
+    def _request(self) -> urllib.request.Request:
+        url = self._url()
+        headers = {"Content-Type": self.content_type, "Content-Length": str(self.size), **self.signed_headers(url)}
+        return urllib.request.Request(url, data=self._buffer, headers=headers, method="PUT")
+
+
+def _s3_from_env(target: str, env: Mapping[str, str]) -> S3Sink:
+    parts = urllib.parse.urlsplit(target)
+    access_key = env.get("AWS_ACCESS_KEY_ID")
+    secret_key = env.get("AWS_SECRET_ACCESS_KEY")
+    if not access_key or not secret_key:
+        raise SinkError(f"Set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY to write {target}")
+    return S3Sink(
+        parts.netloc,
+        parts.path.lstrip("/"),
+        access_key,
+        secret_key,
+        region=env.get("AWS_REGION") or env.get("AWS_DEFAULT_REGION") or "us-east-1",
+        endpoint=env.get("AWS_ENDPOINT_URL_S3") or env.get("AWS_ENDPOINT_URL"),
+        session_token=env.get("AWS_SESSION_TOKEN"),
+    )
+
+
+def is_remote(target: str) -> bool:
+    return target.split("://", 1)[0].lower() in {"s3", "http", "https"} and "://" in target
+
+
+def local_path(target: Optional[str]) -> Optional[Path]:
+    """The file a target writes to, or None for stdout and remote targets."""
+    if target is None or target == "-" or is_remote(str(target)):
+        return None
+    return Path(target)
+
+
+def open_sink(target: Optional[str], env: Optional[Mapping[str, str]] = None) -> Sink:
+    """
+    Open an output target: None or `-` for stdout, `s3://BUCKET/KEY`, an
+    `http(s)://` webhook URL, or a file path. A target ending in `.gz` is
+    gzip-compressed, whichever kind it is. S3 credentials, region, and endpoint
+    come from the standard AWS_* variables; webhooks send
+    `Authorization: Bearer $TREESITTER_TOOLS_WEBHOOK_TOKEN` when it is set.
+    """
+    env = os.environ if env is None else env
+    if target is None or target == "-":
+        return StdoutSink()
+    target = str(target)
+    scheme = target.split("://", 1)[0].lower() if "://" in target else ""
+    if scheme == "s3":
+        sink: Sink = _s3_from_env(target, env)
+    elif scheme in {"http", "https"}:
+        token = env.get(WEBHOOK_TOKEN_ENV)
+        sink = WebhookSink(target, {"Authorization": f"Bearer {token}"} if token else None)
+    else:
+        sink = FileSink(Path(target))
+    compressed = urllib.parse.urlsplit(target).path if scheme else target
+    return GzipSink(sink) if compressed.endswith(".gz") else sink
+
+
+__all__ = [
+    "FileSink",
+    "GzipSink",
+    "S3Sink",
+    "Sink",
+    "SinkError",
+    "StdoutSink",
+    "WEBHOOK_TOKEN_ENV",
+    "WebhookSink",
+    "is_remote",
+    "local_path",
+    "open_sink",
+]
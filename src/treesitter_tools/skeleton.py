@@ -0,0 +1,120 @@
+"""Structural skeletons: source with function bodies elided, everything else kept."""
+
+from __future__ import annotations
+
+import json
+from dataclasses import dataclass
+from pathlib import Path
+from typing import Collection, List, Optional, Sequence, Tuple
+
+from tree_sitter import Node
+
+from . import failures
+from .core import ParsedFile, _python_docstring_node, iter_function_nodes, iter_source_files, parse_file
+
+BRACE_PLACEHOLDER = "{ ... }"
+PLACEHOLDER = "..."
+
+
+@dataclass
+class Skeleton:
+    path: str
+    language: str
+    text: str
+    elided: int
+
+    def to_dict(self) -> dict:
+        return {"path": self.path, "language": self.language, "elided": self.elided, "skeleton": self.text}
+
+
+def _replacement(body: Node, parsed: ParsedFile) -> Optional[Tuple[int, int, str]]:
+    text = parsed.text(body)
+    if parsed.language == "python":
+        docstring = _python_docstring_node(body.parent) if body.parent is not None else None
+        if docstring is not None:
+            statement = docstring.parent if docstring.parent is not None and docstring.parent.type == "expression_statement" else docstring
+            if statement.end_byte >= body.end_byte:
+                return None  # docstring-only body is already a skeleton
+            indent = " " * statement.start_point[1]
+            return statement.end_byte, body.end_byte, f"\n{indent}{PLACEHOLDER}"
+        return body.start_byte, body.end_byte, PLACEHOLDER
+    if text.startswith("{"):
+        return (None if text == BRACE_PLACEHOLDER else (body.start_byte, body.end_byte, BRACE_PLACEHOLDER))
+    return body.start_byte, body.end_byte, PLACEHOLDER
+
+
+def skeleton_source(parsed: ParsedFile) -> Tuple[str, int]:
+    """`parsed` with every outermost function body replaced; returns (text, bodies elided)."""
+    edits: List[Tuple[int, int, str]] = []
+    for fn in sorted(iter_function_nodes(parsed), key=lambda f: f.node.start_byte):
+        body = fn.node.child_by_field_name("body")
+        if body is None:
+            continue
+        if edits and body.start_byte < edits[-1][1]:
+            continue  # nested inside a body that is already elided
+        edit = _replacement(body, parsed)
+        if edit is not None:
+            edits.append(edit)
+    out = parsed.source
+    for start, end, replacement in reversed(edits):
+        out = out[:start] + replacement.encode("utf-8") + out[end:]
+    return out.decode("utf-8", "replace"), len(edits)
+
+
+def skeleton_file(path: Path, language: Optional[str] = None, label: Optional[str] = None) -> Skeleton:
+    parsed = parse_file(path, language)
+    text, elided = skeleton_source(parsed)
+    return Skeleton(label or Path(path).as_posix(), parsed.language, text, elided)
+
+
+def collect_skeletons(
+    root: Path,
+    include: Sequence[str] | None = None,
+    exclude: Sequence[str] | None = None,
+    language: Optional[str] = None,
+    only: Optional[Collection[Path]] = None,
+) -> List[Skeleton]:
+    """Skeletons for a single file or every recognised file under a directory."""
+    root = Path(root)
+    if root.is_file():
+        return [skeleton_file(root, language)] if only is None or root.resolve() in only else []
+    base = root.resolve()
+    results = []
+    for path in iter_source_files(base, include, exclude, only):
+        try:
+            results.append(skeleton_file(path, label=path.relative_to(base).as_posix()))
+        except (ValueError, RuntimeError, OSError) as exc:
+            failures.record(path, exc)
+            continue
+    return results
+
+
+def skeletons_to_text(skeletons: Sequence[Skeleton]) -> str:
+    if len(skeletons) == 1:
+        return skeletons[0].text
+    parts = []
+    for sk in skeletons:
+        parts.append(f"==> {sk.path} <==\n{sk.text.rstrip()}\n")
+    return "\n".join(parts)
+
+
+def skeletons_to_markdown(skeletons: Sequence[Skeleton]) -> str:
+    parts = []
+    for sk in skeletons:
+        parts.append(f"### {sk.path}\n\n```{sk.language}\n{sk.text.rstrip()}\n```\n")
+    return "\n".join(parts)
+
+
+def skeletons_to_json(skeletons: Sequence[Skeleton]) -> str:
+    return json.dumps([sk.to_dict() for sk in skeletons], indent=2)
+
+
+__all__ = [
+    "Skeleton",
+    "collect_skeletons",
+    "skeleton_file",
+    "skeleton_source",
+    "skeletons_to_json",
+    "skeletons_to_markdown",
+    "skeletons_to_text",
+]
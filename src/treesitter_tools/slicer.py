@@ -0,0 +1,384 @@
+"""Dependency slices: a function with the types, constants, and helpers it transitively uses, as one source bundle."""
+
+from __future__ import annotations
+
+import json
+from collections import deque
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Deque, Dict, List, Optional, Sequence, Set, Tuple
+
+from tree_sitter import Node
+
+from .bundle import _Definition, _identifiers, _Project, parse_sources, relevant_imports
+from .core import ParsedFile
+from .headers import LINE_COMMENTS
+
+ROLES = ("target", "type", "value", "function")
+
+# Top-level declarations binding constants, variables, and type aliases (named by `_bound_values`).
+VALUE_NODE_TYPES = {
+    "python": {"expression_statement"},
+    "go": {"const_declaration", "var_declaration", "type_declaration"},
+    "javascript": {"lexical_declaration", "variable_declaration"},
+    "typescript": {"lexical_declaration", "variable_declaration", "type_alias_declaration"},
+    "tsx": {"lexical_declaration", "variable_declaration", "type_alias_declaration"},
+    "rust": {"const_item", "static_item", "type_item"},
+    "c": {"preproc_def", "type_definition"},
+    "cpp": {"preproc_def", "type_definition", "alias_declaration"},
+}
+# Nodes that wrap a definition in the statement declaring it.
+_WRAPPERS = {
+    "decorated_definition",
+    "export_statement",
+    "variable_declarator",
+    "lexical_declaration",
+    "variable_declaration",
+    "type_declaration",
+    "var_declaration",
+}
+# Go declarations that group several specs; a spec sliced out of a group gets its keyword back.
+_GO_GROUPS = {"type_declaration", "var_declaration"}
+_GO_SPEC_KEYWORDS = {"type_spec": "type", "type_alias": "type", "var_spec": "var"}
+_FUNCTION_VALUES = {"arrow_function", "function_expression", "function", "generator_function", "lambda"}
+_VALUE_IDENTIFIERS = {"identifier", "constant"}
+_TYPE_KINDS = {"class", "struct", "interface", "trait", "type"}
+
+
+@dataclass
+class SliceItem:
+    role: str  # "target", "type", "value", or "function"
+    depth: int  # reference steps from the target (0 for the target)
+    kind: str
+    name: str  # qualified
+    path: str
+    language: str
+    start_line: int
+    end_line: int
+    content: str
+
+    def to_dict(self) -> dict:
+        return {
+            "role": self.role,
+            "depth": self.depth,
+            "kind": self.kind,
+            "name": self.name,
+            "path": self.path,
+            "language": self.language,
+            "start_line": self.start_line,
+            "end_line": self.end_line,
+            "content": self.content,
+        }
+
+
+@dataclass
+class SourceSlice:
+    symbol: str
+    path: str
+    start_line: int
+    end_line: int
+    items: List[SliceItem] = field(default_factory=list)  # grouped by file, in source order
+    imports: Dict[str, List[str]] = field(default_factory=dict)  # path -> import statements the slice uses
+    external: List[str] = field(default_factory=list)  # calls that resolve to nothing in the repo
+
+    def files(self) -> List[Tuple[str, str, List[SliceItem]]]:
+        """(path, language, items) per file in the slice, the target's file first."""
+        grouped: Dict[str, List[SliceItem]] = {}
+        for item in self.items:
+            grouped.setdefault(item.path, []).append(item)
+        return [(path, items[0].language, items) for path, items in grouped.items()]
+
+    def to_dict(self) -> dict:
+        return {
+            "symbol": self.symbol,
+            "path": self.path,
+            "start_line": self.start_line,
+            "end_line": self.end_line,
+            "imports": self.imports,
+            "external": self.external,
+            "items": [item.to_dict() for item in self.items],
+        }
+
+    def to_json(self) -> str:
+        return json.dumps(self.to_dict(), indent=2)
+
+    def to_source(self) -> str:
+        """The slice as one source listing: a comment naming each file, then its imports and definitions."""
+        parts = []
+        for index, (path, language, items) in enumerate(self.files()):
+            prefix = LINE_COMMENTS.get(language, "//")
+            if index == 0:
+                header = [f"{prefix} Slice of {self.symbol} ({len(self.items)} definitions)"]
+                if self.external:
+                    header.append(f"{prefix} Outside the slice: {', '.join(self.external)}")
+                parts.append("\n".join(header))
+            parts.append(f"{prefix} {path}")
+            if self.imports.get(path):
+                parts.append("\n".join(self.imports[path]))
+            parts.extend(item.content for item in items)
+        return "\n\n".join(parts) + "\n"
+
+    def to_markdown(self) -> str:
+        parts = [f"# Slice of `{self.symbol}`\n"]
+        if self.external:
+            parts.append("Outside the slice: " + ", ".join(f"`{name}`" for name in self.external) + "\n")
+        for path, language, items in self.files():
+            blocks = (["\n".join(self.imports[path])] if self.imports.get(path) else []) + [i.content for i in items]
+            body = "\n\n".join(blocks)
+            parts.append(f"## {path}\n\n```{language}\n{body}\n```\n")
+        return "\n".join(parts)
+
+
+class _SliceProject(_Project):
+    """A `_Project` that also knows the top-level constants, variables, and type aliases of each file."""
+
+    def __init__(self, files: Sequence[Tuple[ParsedFile, str]]):
+        super().__init__(files)
+        self.values: Dict[str, List[_Definition]] = {}
+        for parsed, label in files:
+            declared = VALUE_NODE_TYPES.get(parsed.language, set())
+            for node in parsed.root.named_children:
+                if node.type == "export_statement" and node.child_by_field_name("declaration") is not None:
+                    node = node.child_by_field_name("declaration")
+                if node.type not in declared:
+                    continue
+                for name, kind, declaration in _bound_values(node, parsed):
+                    definition = _Definition(parsed, label, declaration, declaration, name, name, kind)
+                    self.values.setdefault(name, []).append(definition)
+
+    def referenced_values(self, definition: _Definition) -> List[_Definition]:
+        found: List[_Definition] = []
+        names = _value_names(definition.node, definition.parsed)
+        types = _type_names(definition.node, definition.parsed)
+        for name in sorted(names | types):
+            candidates = [
+                d for d in self.values.get(name, ())
+                if _key(d) != _key(definition)
+                and d.parsed.language == definition.parsed.language
+                and (name in names or d.kind == "type")
+            ]
+            # Prefer a declaration in the same file, then the same directory.
+            directory = Path(definition.label).parent
+            candidates.sort(key=lambda d: (d.label != definition.label, Path(d.label).parent != directory, d.label))
+            if candidates and candidates[0] not in found:
+                found.append(candidates[0])
+        return found
+
+    def referenced_functions(self, definition: _Definition) -> List[_Definition]:
+        """What it calls (for a class, what its methods call), and same-file functions it names without calling."""
+        found: List[_Definition] = []
+        for caller in self._functions_in(definition):
+            for callee in self.callees(caller):
+                if callee not in found:
+                    found.append(callee)
+        names = _value_names(definition.node, definition.parsed)
+        for candidate in self.definitions:
+            if (
+                candidate.label == definition.label
+                and candidate.kind == "function"
+                and candidate.name in names
+                and not _within(candidate.node, definition.node)
+                and candidate not in found
+            ):
+                found.append(candidate)
+        return found
+
+    def external_calls(self, definition: _Definition) -> Set[str]:
+        """Calls made in `definition` that resolve to no definition in the project."""
+        callers = {d.qualified_name for d in self._functions_in(definition)}
+        return {
+            f"{edge.receiver}.{edge.callee}" if edge.receiver else edge.callee
+            for edge in self.graph.edges
+            if edge.file == definition.label and edge.caller in callers and not edge.resolved
+        }
+
+    def _functions_in(self, definition: _Definition) -> List[_Definition]:
+        return [
+            d for d in self.definitions
+            if d.label == definition.label and d.kind in {"function", "method"} and _within(d.node, definition.node)
+        ]
+
+
+def _bound_values(node: Node, parsed: ParsedFile) -> List[Tuple[str, str, Node]]:
+    """(name, kind, declaration) of each constant, variable, or type alias a top-level declaration binds."""
+    found: List[Tuple[str, str, Node]] = []
+    if parsed.language == "python":
+        assignment = node.named_children[0] if node.named_children else None
+        if assignment is not None and assignment.type == "assignment":
+            target = assignment.child_by_field_name("left")
+            if target is not None and target.type == "identifier":
+                name = parsed.text(target)
+                found.append((name, "constant" if name.isupper() else "variable", node))
+    elif parsed.language == "go":
+        kind = {"const_declaration": "constant", "var_declaration": "variable", "type_declaration": "type"}[node.type]
+        for spec in node.named_children:
+            shape = spec.child_by_field_name("type")
+            if spec.type == "type_spec" and shape is not None and shape.type in {"struct_type", "interface_type"}:
+                continue  # a struct or interface: already a class-like definition
+            # Grouped constants share `iota`, so each of them slices to the whole group.
+            for name in spec.children_by_field_name("name"):
+                found.append((parsed.text(name), kind, node if kind == "constant" else spec))
+    elif node.type in {"lexical_declaration", "variable_declaration"}:
+        kind = "constant" if node.children and parsed.text(node.children[0]) == "const" else "variable"
+        for declarator in node.named_children:
+            name, value = declarator.child_by_field_name("name"), declarator.child_by_field_name("value")
+            if declarator.type != "variable_declarator" or name is None or name.type != "identifier":
+                continue
+            if value is not None and value.type in _FUNCTION_VALUES:
+                continue  # a function: already a definition of its own
+            found.append((parsed.text(name), kind, node))
+    elif node.type == "type_definition":
+        for declarator in node.children_by_field_name("declarator"):
+            if declarator.type == "type_identifier":
+                found.append((parsed.text(declarator), "type", node))
+    else:
+        name = node.child_by_field_name("name")
+        if name is not None:
+            kind = "constant" if node.type in {"preproc_def", "const_item", "static_item"} else "type"
+            found.append((parsed.text(name), kind, node))
+    return found
+
+
+def _declaration(node: Node) -> Node:
+    """
+    The statement that declares `node`, with its decorators, `export`, the `const f =` of
+    a function expression, and the `var`/`type` keyword of an ungrouped Go declaration.
+    """
+    while node.parent is not None and node.parent.type in _WRAPPERS:
+        parent = node.parent
+        if parent.type == "variable_declarator":
+            value = parent.child_by_field_name("value")
+            if value is None or (value.start_byte, value.end_byte) != (node.start_byte, node.end_byte):
+                break
+        if parent.type in _GO_GROUPS and len(parent.named_children) > 1:
+            break
+        node = parent
+    return node
+
+
+def _key(definition: _Definition) -> Tuple[str, int, int]:
+    node = _declaration(definition.node)
+    return definition.label, node.start_byte, node.end_byte
+
+
+def _nested(key: Tuple[str, int, int], spans: Set[Tuple[str, int, int]]) -> bool:
+    label, start, end = key
+    return any(other[0] == label and other[1] <= start and end <= other[2] and other != key for other in spans)
+
+
+def _within(inner: Node, outer: Node) -> bool:
+    return outer.start_byte <= inner.start_byte and inner.end_byte <= outer.end_byte
+
+
+def _value_names(node: Node, parsed: ParsedFile) -> Set[str]:
+    """Identifiers under `node` that can read a top-level name: not attributes or keyword-argument names."""
+    names: Set[str] = set()
+    stack = [node]
+    while stack:
+        current = stack.pop()
+        stack.extend(current.children)
+        if current.type not in _VALUE_IDENTIFIERS:
+            continue
+        parent = current.parent
+        if parent is not None and parent.type in {"attribute", "keyword_argument"}:
+            field_name = "attribute" if parent.type == "attribute" else "name"
+            named = parent.child_by_field_name(field_name)
+            if named is not None and (named.start_byte, named.end_byte) == (current.start_byte, current.end_byte):
+                continue
+        names.add(parsed.text(current))
+    return names
+
+
+def _type_names(node: Node, parsed: ParsedFile) -> Set[str]:
+    names: Set[str] = set()
+    stack = [node]
+    while stack:
+        current = stack.pop()
+        if current.type == "type_identifier":
+            names.add(parsed.text(current))
+        stack.extend(current.children)
+    return names
+
+
+def _role(definition: _Definition) -> str:
+    if definition.kind in {"function", "method"}:
+        return "function"
+    return "type" if definition.kind in _TYPE_KINDS else "value"
+
+
+def _item(definition: _Definition, role: str, depth: int) -> SliceItem:
+    node = _declaration(definition.node)
+    text = definition.parsed.text(node)
+    keyword = _GO_SPEC_KEYWORDS.get(node.type)
+    return SliceItem(
+        role=role,
+        depth=depth,
+        kind=definition.kind,
+        name=definition.qualified_name,
+        path=definition.label,
+        language=definition.parsed.language,
+        start_line=node.start_point[0] + 1,
+        end_line=node.end_point[0] + 1,
+        content=f"{keyword} {text}" if keyword else text,  # one spec of a grouped Go declaration
+    )
+
+
+def build_slice(
+    root: Path,
+    symbol: str,
+    file: Optional[str] = None,
+    depth: Optional[int] = None,
+    include: Sequence[str] | None = None,
+    exclude: Sequence[str] | None = None,
+) -> SourceSlice:
+    """
+    The definitions `symbol` (a name or `Type.method`) needs, from the sources under
+    `root`: the types, top-level constants and variables, and functions it references,
+    then the ones those reference, until nothing new is reached (or after `depth` steps).
+    A definition nested in another one of the slice (a method of a class it needs) is
+    left to its container, so every line appears once.
+    """
+    if depth is not None and depth < 1:
+        raise ValueError("depth must be at least 1")
+    project = _SliceProject(parse_sources(root, include, exclude))
+    target = project.find(symbol, file)
+
+    reached: List[Tuple[_Definition, str, int]] = [(target, "target", 0)]
+    seen = {_key(target)}
+    queue: Deque[Tuple[_Definition, int]] = deque([(target, 0)])
+    external: Set[str] = set()
+    while queue:
+        definition, distance = queue.popleft()
+        external |= project.external_calls(definition)
+        if depth is not None and distance >= depth:
+            continue
+        found = project.referenced_types(definition) + project.referenced_values(definition)
+        for dependency in found + project.referenced_functions(definition):
+            if _key(dependency) in seen:
+                continue
+            seen.add(_key(dependency))
+            reached.append((dependency, _role(dependency), distance + 1))
+            queue.append((dependency, distance + 1))
+
+    spans = {_key(d) for d, _, _ in reached}
+    kept = [entry for entry in reached if not _nested(_key(entry[0]), spans)]
+    # The target's file first, then the others by path; source order within a file.
+    kept.sort(key=lambda entry: (entry[0].label != target.label, _key(entry[0])))
+
+    node = _declaration(target.node)
+    result = SourceSlice(target.qualified_name, target.label, node.start_point[0] + 1, node.end_point[0] + 1)
+    used: Dict[str, Set[str]] = {}
+    parsed: Dict[str, ParsedFile] = {}
+    for definition, role, distance in kept:
+        result.items.append(_item(definition, role, distance))
+        used.setdefault(definition.label, set()).update(_identifiers(definition.node, definition.parsed))
+        parsed[definition.label] = definition.parsed
+    for label, names in used.items():
+        imports = relevant_imports(parsed[label], names)
+        if imports:
+            result.imports[label] = imports
+    result.external = sorted(external)
+    return result
+
+
+__all__ = ["ROLES", "VALUE_NODE_TYPES", "SliceItem", "SourceSlice", "build_slice"]
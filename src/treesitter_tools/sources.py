@@ -0,0 +1,174 @@
+"""
+Input sources: release archives (`.tar.gz`, `.zip`, ...) and `git+https://...@ref`
+URLs given where a command expects a directory are unpacked or cloned into a
+temporary workspace, which is removed when the process exits.
+
+An archive holding a single top-level directory (the usual `pkg-1.0/` layout)
+resolves to that directory, so reported paths are relative to the project.
+"""
+
+from __future__ import annotations
+
+import atexit
+import os
+import shutil
+import subprocess
+import tarfile
+import tempfile
+import zipfile
+from pathlib import Path, PurePosixPath
+from typing import Dict, List, Optional, Tuple
+from urllib.parse import urlsplit
+
+ARCHIVE_SUFFIXES = (".tar.gz", ".tgz", ".tar.bz2", ".tbz2", ".tar.xz", ".txz", ".tar", ".zip")
+GIT_PREFIX = "git+"
+GIT_TIMEOUT = 600  # seconds for one clone or fetch
+
+_WORKSPACES: Dict[str, Path] = {}
+_TEMP_DIRS: List[Path] = []
+
+
+class SourceError(OSError):
+    """An archive could not be unpacked or a repository could not be cloned."""
+
+
+def is_archive(value: str) -> bool:
+    return value.lower().endswith(ARCHIVE_SUFFIXES)
+
+
+def is_input_source(value: str) -> bool:
+    """True for git URLs and archive paths, which `materialize` turns into directories."""
+    return value.startswith(GIT_PREFIX) or (is_archive(value) and Path(value).is_file())
+
+
+def _cleanup() -> None:
+    for path in _TEMP_DIRS:
+        shutil.rmtree(path, ignore_errors=True)
+    _TEMP_DIRS.clear()
+    _WORKSPACES.clear()
+
+
+def _workspace() -> Path:
+    if not _TEMP_DIRS:
+        atexit.register(_cleanup)
+    path = Path(tempfile.mkdtemp(prefix="treesitter-tools-"))
+    _TEMP_DIRS.append(path)
+    return path
+
+
+def _safe_member(name: str) -> bool:
+    parts = PurePosixPath(name.replace("\\", "/")).parts
+    return bool(parts) and not name.startswith(("/", "\\")) and ".." not in parts and ":" not in parts[0]
+
+
+def _extract_tar(archive: Path, dest: Path) -> None:
+    try:
+        with tarfile.open(archive) as tar:
+            members = []
+            for member in tar.getmembers():
+                if not _safe_member(member.name):
+                    raise SourceError(f"Refusing to extract {archive}: unsafe member path {member.name!r}")
+                if member.isfile() or member.isdir():  # links and devices are skipped
+                    members.append(member)
+            if hasattr(tarfile, "data_filter"):
+                tar.extractall(dest, members, filter="data")
+            else:
+                tar.extractall(dest, members)
+    except (tarfile.TarError, EOFError) as exc:
+        raise SourceError(f"Cannot read archive {archive}: {exc}") from exc
+
+
+def _extract_zip(archive: Path, dest: Path) -> None:
+    try:
+        with zipfile.ZipFile(archive) as zf:
+            for name in zf.namelist():
+                if not _safe_member(name):
+                    raise SourceError(f"Refusing to extract {archive}: unsafe member path {name!r}")
+            zf.extractall(dest)
+    except zipfile.BadZipFile as exc:
+        raise SourceError(f"Cannot read archive {archive}: {exc}") from exc
+
+
+def _project_dir(dest: Path) -> Path:
+    entries = [p for p in dest.iterdir() if p.name not in {"__MACOSX", "pax_global_header"}]
+    return entries[0] if len(entries) == 1 and entries[0].is_dir() else dest
+
+
+def extract_archive(archive: Path) -> Path:
+    """Unpack `archive` into a temporary workspace; returns the project directory inside it."""
+    archive = Path(archive)
+    dest = _workspace()
+    if archive.name.lower().endswith(".zip"):
+        _extract_zip(archive, dest)
+    else:
+        _extract_tar(archive, dest)
+    return _project_dir(dest)
+
+
+def parse_git_url(value: str) -> Tuple[str, Optional[str]]:
+    """(clone URL, ref) of `git+<url>[@ref]`; the ref may be a branch, tag, or commit."""
+    url = value[len(GIT_PREFIX):] if value.startswith(GIT_PREFIX) else value
+    if url.startswith("-"):
+        raise SourceError(f"Invalid git URL {value!r}")
+    parts = urlsplit(url)
+    if parts.scheme not in {"https", "http", "ssh", "file", "git"}:
+        raise SourceError(f"Unsupported git URL {value!r} (expected git+https://, git+ssh://, or git+file://)")
+    path, at, ref = parts.path.rpartition("@")
+    if not at or not path:
+        return url, None
+    if not ref:
+        raise SourceError(f"Empty ref in git URL {value!r}")
+    if ref.startswith("-"):
+        # git reads options even after positional arguments (`@--upload-pack=...`).
+        raise SourceError(f"Invalid ref {ref!r} in git URL {value!r}")
+    return parts._replace(path=path).geturl(), ref
+
+
+def _git(cwd: Path, *args: str) -> None:
+    env = dict(os.environ, GIT_TERMINAL_PROMPT="0")
+    try:
+        result = subprocess.run(["git", *args], cwd=cwd, capture_output=True, env=env, timeout=GIT_TIMEOUT, check=False)
+    except FileNotFoundError:
+        raise SourceError("git+ URLs need the 'git' executable on PATH") from None
+    except subprocess.TimeoutExpired:
+        raise SourceError(f"git {args[0]} timed out after {GIT_TIMEOUT}s") from None
+    if result.returncode != 0:
+        message = result.stderr.decode("utf-8", "replace").strip().splitlines()
+        raise SourceError(message[-1] if message else f"git {' '.join(args)} failed")
+
+
+def clone_git(value: str) -> Path:
+    """Shallow-clone `git+<url>[@ref]` into a temporary workspace."""
+    url, ref = parse_git_url(value)
+    dest = _workspace() / "repo"
+    if ref is None:
+        _git(dest.parent, "clone", "--depth", "1", "--quiet", "--", url, str(dest))
+        return dest
+    # init + fetch handles branches, tags, and commit hashes alike.
+    dest.mkdir()
+    _git(dest, "init", "--quiet")
+    _git(dest, "fetch", "--depth", "1", "--quiet", "--", url, ref)
+    _git(dest, "checkout", "--quiet", "FETCH_HEAD")
+    return dest
+
+
+def materialize(value: str) -> Path:
+    """
+    A local directory for an input source (see `is_input_source`); repeated calls
+    with the same source share one workspace.
+    """
+    if value not in _WORKSPACES:
+        _WORKSPACES[value] = clone_git(value) if value.startswith(GIT_PREFIX) else extract_archive(Path(value))
+    return _WORKSPACES[value]
+
+
+__all__ = [
+    "ARCHIVE_SUFFIXES",
+    "SourceError",
+    "clone_git",
+    "extract_archive",
+    "is_archive",
+    "is_input_source",
+    "materialize",
+    "parse_git_url",
+]
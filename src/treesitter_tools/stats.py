@@ -0,0 +1,231 @@
+"""Codebase statistics: files per language, function counts, and size distributions."""
+
+from __future__ import annotations
+
+import json
+import math
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Collection, Dict, List, Optional, Sequence, Tuple
+
+from . import failures
+from .chunker import TokenCounter, estimate_tokens
+from .core import ParsedFile, detect_language, iter_function_nodes, iter_source_files, parse_file
+from .metrics import FunctionMetrics, function_metrics
+
+PERCENTILES = (50, 90, 99)
+# Upper bounds (inclusive) of the histogram buckets; the last bucket is open-ended.
+LINE_BUCKETS = (5, 10, 25, 50, 100, 200, 500)
+TOKEN_BUCKETS = (32, 64, 128, 256, 512, 1024, 2048, 4096)
+
+
+@dataclass
+class LanguageStats:
+    files: int = 0
+    lines: int = 0
+    bytes: int = 0
+    functions: int = 0
+
+    def to_dict(self) -> dict:
+        return {"files": self.files, "lines": self.lines, "bytes": self.bytes, "functions": self.functions}
+
+
+@dataclass
+class FileStats:
+    path: str
+    language: str
+    lines: int
+    bytes: int
+    functions: int
+
+    def to_dict(self) -> dict:
+        return {
+            "path": self.path, "language": self.language, "lines": self.lines, "bytes": self.bytes,
+            "functions": self.functions,
+        }
+
+
+@dataclass
+class FunctionStats:
+    metrics: FunctionMetrics
+    tokens: int
+
+    def to_dict(self) -> dict:
+        m = self.metrics
+        return {
+            "path": m.path, "name": m.name, "start_line": m.start_line, "lines": m.loc, "tokens": self.tokens,
+            "max_nesting": m.max_nesting,
+        }
+
+
+def percentile(values: Sequence[int], pct: float) -> int:
+    """Nearest-rank percentile of sorted `values` (0 when empty)."""
+    if not values:
+        return 0
+    rank = max(1, math.ceil(pct / 100 * len(values)))
+    return values[rank - 1]
+
+
+def distribution(values: Sequence[int]) -> dict:
+    ordered = sorted(values)
+    summary = {"min": ordered[0] if ordered else 0}
+    summary.update({f"p{p}": percentile(ordered, p) for p in PERCENTILES})
+    summary["max"] = ordered[-1] if ordered else 0
+    summary["mean"] = round(sum(ordered) / len(ordered), 1) if ordered else 0
+    return summary
+
+
+def histogram(values: Sequence[int], bounds: Sequence[int]) -> List[dict]:
+    """Counts per bucket, labelled `1-5`, `6-10`, ..., `501+`."""
+    counts = [0] * (len(bounds) + 1)
+    for value in values:
+        counts[next((i for i, bound in enumerate(bounds) if value <= bound), len(bounds))] += 1
+    labels = [f"{low + 1}-{high}" for low, high in zip((0, *bounds), bounds)] + [f"{bounds[-1] + 1}+"]
+    return [{"bucket": label, "count": count} for label, count in zip(labels, counts)]
+
+
+@dataclass
+class RepoStats:
+    root: str
+    languages: Dict[str, LanguageStats] = field(default_factory=dict)
+    files: List[FileStats] = field(default_factory=list)
+    functions: List[FunctionStats] = field(default_factory=list)
+    skipped: int = 0  # source files that were binary, too large, or failed to parse
+    top: int = 10
+
+    @property
+    def lines(self) -> int:
+        return sum(f.lines for f in self.files)
+
+    def _top(self, key) -> List[FunctionStats]:
+        return sorted(self.functions, key=lambda f: (-key(f), f.metrics.path, f.metrics.start_line))[: self.top]
+
+    def to_dict(self) -> dict:
+        lines = [f.metrics.loc for f in self.functions]
+        tokens = [f.tokens for f in self.functions]
+        return {
+            "root": self.root,
+            "files": len(self.files),
+            "lines": self.lines,
+            "functions": len(self.functions),
+            "skipped": self.skipped,
+            "languages": {name: s.to_dict() for name, s in sorted(self.languages.items(), key=lambda i: (-i[1].files, i[0]))},
+            "function_lines": {**distribution(lines), "histogram": histogram(lines, LINE_BUCKETS)},
+            "function_tokens": {**distribution(tokens), "histogram": histogram(tokens, TOKEN_BUCKETS)},
+            "deepest_nesting": [f.to_dict() for f in self._top(lambda f: f.metrics.max_nesting)],
+            "longest_functions": [f.to_dict() for f in self._top(lambda f: f.metrics.loc)],
+            "largest_files": [
+                f.to_dict() for f in sorted(self.files, key=lambda f: (-f.lines, f.path))[: self.top]
+            ],
+        }
+
+    def to_json(self) -> str:
+        return json.dumps(self.to_dict(), indent=2)
+
+    def to_text(self) -> str:
+        data = self.to_dict()
+        out = [f"{data['files']} files, {data['lines']} lines, {data['functions']} functions in {self.root}"]
+        if self.skipped:
+            out[0] += f" ({self.skipped} files skipped)"
+        out += ["", _table(
+            ("language", "files", "lines", "functions"),
+            [(name, s["files"], s["lines"], s["functions"]) for name, s in data["languages"].items()],
+        )]
+        for key, title in (("function_lines", "Function length (lines)"), ("function_tokens", "Function size (tokens)")):
+            dist = data[key]
+            out += ["", f"{title}: " + "  ".join(f"{k}={dist[k]}" for k in ("min", "p50", "p90", "p99", "max", "mean"))]
+            out.append(_bars(dist["histogram"]))
+        sections = (
+            ("Deepest nesting", "deepest_nesting", "max_nesting"),
+            ("Longest functions", "longest_functions", "lines"),
+        )
+        for title, key, column in sections:
+            if data[key]:
+                out += ["", f"{title}:", _table(
+                    (column, "function"), [(f[column], f"{f['path']}:{f['start_line']} {f['name']}") for f in data[key]]
+                )]
+        if data["largest_files"]:
+            out += ["", "Largest files:", _table(
+                ("lines", "bytes", "file"), [(f["lines"], f["bytes"], f["path"]) for f in data["largest_files"]]
+            )]
+        return "\n".join(out) + "\n"
+
+
+def _table(header: Tuple[str, ...], rows: Sequence[Tuple]) -> str:
+    """Left-aligned text columns, numbers right-aligned; the last column is not padded."""
+    cells = [tuple(str(c) for c in header)] + [tuple(str(c) for c in row) for row in rows]
+    widths = [max(len(row[i]) for row in cells) for i in range(len(header) - 1)]
+    numeric = [all(isinstance(row[i], int) for row in rows) for i in range(len(header))]
+    lines = []
+    for row in cells:
+        padded = [row[i].rjust(widths[i]) if numeric[i] else row[i].ljust(widths[i]) for i in range(len(widths))]
+        lines.append("  ".join(padded + [row[-1]]).rstrip())
+    return "\n".join("  " + line for line in lines)
+
+
+def _bars(buckets: List[dict], width: int = 40) -> str:
+    peak = max((b["count"] for b in buckets), default=0) or 1
+    label_width = max(len(b["bucket"]) for b in buckets)
+    return "\n".join(
+        f"  {b['bucket'].rjust(label_width)}  {str(b['count']).rjust(len(str(peak)))}  {'#' * math.ceil(b['count'] * width / peak)}".rstrip()
+        for b in buckets
+    )
+
+
+def _add_file(stats: RepoStats, parsed: ParsedFile, label: str, count: TokenCounter) -> None:
+    metrics = function_metrics(parsed, label)
+    nodes = list(iter_function_nodes(parsed))  # the same functions, in the same order, as `metrics`
+    functions = [FunctionStats(m, count(parsed.text(fn.node))) for m, fn in zip(metrics, nodes)]
+    lines = parsed.source.count(b"\n") + (1 if parsed.source and not parsed.source.endswith(b"\n") else 0)
+    stats.files.append(FileStats(label, parsed.language, lines, len(parsed.source), len(functions)))
+    stats.functions.extend(functions)
+    language = stats.languages.setdefault(parsed.language, LanguageStats())
+    language.files += 1
+    language.lines += lines
+    language.bytes += len(parsed.source)
+    language.functions += len(functions)
+
+
+def collect_stats(
+    root: Path,
+    include: Sequence[str] | None = None,
+    exclude: Sequence[str] | None = None,
+    count: TokenCounter = estimate_tokens,
+    top: int = 10,
+    only: Optional[Collection[Path]] = None,
+    max_file_size: Optional[int] = None,
+) -> RepoStats:
+    """Statistics for one file or every recognised file under a directory."""
+    root = Path(root)
+    stats = RepoStats(root.as_posix(), top=top)
+    if root.is_file():
+        targets = [(root, root.as_posix())] if only is None or root.resolve() in only else []
+    else:
+        base = root.resolve()
+        targets = [(p, p.relative_to(base).as_posix()) for p in iter_source_files(base, include, exclude, only)]
+    for path, label in targets:
+        if detect_language(path) is None:
+            continue  # not source code (README, images, ...)
+        try:
+            parsed = parse_file(path, max_file_size=max_file_size)
+        except (ValueError, RuntimeError, OSError) as exc:
+            failures.record(path, exc)
+            stats.skipped += 1
+            continue
+        _add_file(stats, parsed, label, count)
+    return stats
+
+
+__all__ = [
+    "LINE_BUCKETS",
+    "PERCENTILES",
+    "TOKEN_BUCKETS",
+    "FileStats",
+    "FunctionStats",
+    "LanguageStats",
+    "RepoStats",
+    "collect_stats",
+    "distribution",
+    "histogram",
+    "percentile",
+]
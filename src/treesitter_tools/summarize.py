@@ -0,0 +1,254 @@
+"""
+One-line natural-language summaries of extracted functions from a chat-completion endpoint.
+
+Any OpenAI-compatible `/chat/completions` endpoint works: a local server (Ollama,
+llama.cpp, vLLM, LM Studio) or a hosted one. Summaries are cached by the function's
+`body_hash`, so renaming, moving, or re-commenting a function reuses its summary,
+and a failing endpoint never fails the scan: the functions it could not summarize
+simply have no `summary`.
+"""
+
+from __future__ import annotations
+
+import hashlib
+import json
+import threading
+import time
+import urllib.error
+import urllib.request
+from collections import deque
+from concurrent.futures import Future, ThreadPoolExecutor
+from dataclasses import dataclass
+from pathlib import Path
+from typing import Callable, Dict, Iterable, Iterator, List, Optional, Sequence
+
+from .cache import ResultCache
+from .core import CodeSymbol, FileSymbols
+
+DEFAULT_ENDPOINT = "http://127.0.0.1:11434/v1"  # Ollama's OpenAI-compatible API
+DEFAULT_MODEL = "qwen2.5-coder:1.5b"
+# Bump when the prompt changes, so cached summaries written from the old one are not served.
+PROMPT_VERSION = 1
+PROMPT = (
+    "Summarize what this {language} function does in one sentence of at most 20 words. "
+    "Describe its behaviour, not its name or signature. Reply with the sentence only.\n\n{code}"
+)
+MAX_CODE_CHARS = 6000
+MAX_SUMMARY_CHARS = 200
+
+# Takes a prompt, returns the model's reply.
+CompleteFn = Callable[[str], str]
+
+
+class SummaryError(RuntimeError):
+    """The endpoint failed or returned something unusable for one function."""
+
+
+@dataclass
+class SummarizerConfig:
+    url: str = DEFAULT_ENDPOINT
+    model: str = DEFAULT_MODEL
+    api_key: Optional[str] = None
+    timeout: float = 60.0
+    retries: int = 2
+    backoff: float = 1.0  # seconds; doubles after every failed attempt
+    max_tokens: int = 64
+
+
+def http_completer(config: SummarizerConfig, sleep: Callable[[float], None] = time.sleep) -> CompleteFn:
+    """POST one user message to `<url>/chat/completions`, retrying 429/5xx and network errors."""
+    url = config.url.rstrip("/")
+    if not url.endswith("/chat/completions"):
+        url += "/chat/completions"
+    headers = {"Content-Type": "application/json"}
+    if config.api_key:
+        headers["Authorization"] = f"Bearer {config.api_key}"
+
+    def complete(prompt: str) -> str:
+        payload = json.dumps({
+            "model": config.model,
+            "messages": [{"role": "user", "content": prompt}],
+            "temperature": 0,
+            "max_tokens": config.max_tokens,
+        }).encode("utf-8")
+        delay = config.backoff
+        for attempt in range(config.retries + 1):
+            request = urllib.request.Request(url, data=payload, headers=headers, method="POST")
+            try:
+                with urllib.request.urlopen(request, timeout=config.timeout) as response:
+                    data = json.loads(response.read().decode("utf-8"))
+                return data["choices"][0]["message"]["content"]
+            except urllib.error.HTTPError as exc:
+                detail = exc.read().decode("utf-8", "replace")[:200]
+                error = SummaryError(f"HTTP {exc.code} from {url}: {detail}")
+                if exc.code != 429 and exc.code < 500:
+                    raise error from exc
+            except (urllib.error.URLError, TimeoutError, ConnectionError) as exc:
+                error = SummaryError(f"Cannot reach {url}: {exc}")
+            except (KeyError, IndexError, TypeError, ValueError) as exc:
+                raise SummaryError(f"Unexpected response from {url}: {exc}") from exc
+            if attempt < config.retries:
+                sleep(delay)
+                delay *= 2
+        raise error
+
+    return complete
+
+
+def clean_summary(reply: str) -> str:
+    """The first non-empty line of `reply`, without quotes, list markers, or a "Summary:" label."""
+    for line in reply.strip().splitlines():
+        line = line.strip().lstrip("-•* ").strip("`\"' ")
+        if line.lower().startswith("summary:"):
+            line = line[len("summary:"):].strip("*`\"' ")
+        if line:
+            return line[:MAX_SUMMARY_CHARS].rstrip()
+    return ""
+
+
+@dataclass
+class SummaryStats:
+    summarized: int = 0  # functions summarized by the endpoint in this run
+    cached: int = 0  # functions whose summary came from the cache
+    failed: int = 0  # functions left without a summary after an endpoint error
+    skipped: int = 0  # functions not sent because the endpoint was given up on
+    last_error: Optional[str] = None
+
+    def to_dict(self) -> dict:
+        data = {"summarized": self.summarized, "cached": self.cached, "failed": self.failed, "skipped": self.skipped}
+        if self.last_error:
+            data["last_error"] = self.last_error
+        return data
+
+
+class Summarizer:
+    """
+    Attaches `summary` to the functions of symbol reports, `jobs` requests at a time.
+
+    After `max_failures` consecutive endpoint errors the endpoint is given up on
+    and the remaining functions are passed through unsummarized (`stats.skipped`).
+    """
+
+    def __init__(
+        self,
+        complete: CompleteFn,
+        model: str = DEFAULT_MODEL,
+        cache_dir: Optional[Path] = None,
+        jobs: int = 4,
+        max_failures: int = 5,
+    ):
+        self.complete = complete
+        self.model = model
+        self.cache = ResultCache(cache_dir) if cache_dir is not None else None
+        self.jobs = max(1, jobs)
+        self.max_failures = max_failures
+        self.stats = SummaryStats()
+        self._lock = threading.Lock()
+        self._failures = 0
+        self._known: Dict[str, Future] = {}  # body hash -> summary, shared by duplicates in flight
+
+    @property
+    def gave_up(self) -> bool:
+        return self.max_failures > 0 and self._failures >= self.max_failures
+
+    def _key(self, digest: str) -> str:
+        salt = json.dumps({"kind": "summary", "model": self.model, "prompt": PROMPT_VERSION, "body_hash": digest})
+        return hashlib.sha256(salt.encode("utf-8")).hexdigest()
+
+    def _summarize(self, digest: str, code: str, language: str) -> Optional[str]:
+        key = self._key(digest)
+        if self.cache is not None:
+            cached = self.cache.get(key)
+            if isinstance(cached, str):
+                with self._lock:
+                    self.stats.cached += 1
+                return cached
+        if self.gave_up:
+            with self._lock:
+                self.stats.skipped += 1
+            return None
+        prompt = PROMPT.format(language=language or "source", code=code[:MAX_CODE_CHARS])
+        try:
+            summary = clean_summary(self.complete(prompt))
+            if not summary:
+                raise SummaryError("Empty reply")
+        except Exception as exc:  # any failure skips this function, never the scan
+            with self._lock:
+                self._failures += 1
+                self.stats.failed += 1
+                self.stats.last_error = str(exc)
+            return None
+        with self._lock:
+            self._failures = 0
+            self.stats.summarized += 1
+        if self.cache is not None:
+            self.cache.put(key, summary)
+        return summary
+
+    def _submit(self, pool: ThreadPoolExecutor, report: FileSymbols) -> List[tuple]:
+        """(future, symbols) per distinct function body of `report`; chunks of one function share a body."""
+        groups: Dict[str, List[CodeSymbol]] = {}
+        for symbol in report.symbols:
+            if symbol.body_hash and symbol.content is not None:
+                groups.setdefault(symbol.body_hash, []).append(symbol)
+        pending = []
+        for digest, symbols in groups.items():
+            code = "".join(s.content for s in sorted(symbols, key=lambda s: s.chunk_index or 0))
+            language = symbols[0].language or report.language
+            with self._lock:
+                future = self._known.get(digest)
+                if future is None:
+                    future = self._known[digest] = pool.submit(self._summarize, digest, code, language)
+            pending.append((future, symbols))
+        return pending
+
+    def summarize(self, reports: Iterable[FileSymbols], window: Optional[int] = None) -> Iterator[FileSymbols]:
+        """
+        Yield `reports` in order with their functions summarized. Up to `window`
+        reports (default 8 x jobs) are in flight, so a streamed scan keeps streaming.
+        """
+        window = window or 8 * self.jobs
+        queue: deque = deque()
+        with ThreadPoolExecutor(max_workers=self.jobs, thread_name_prefix="summarize") as pool:
+            for report in reports:
+                queue.append((report, self._submit(pool, report)))
+                while len(queue) > window:
+                    yield self._finish(*queue.popleft())
+            while queue:
+                yield self._finish(*queue.popleft())
+
+    def _finish(self, report: FileSymbols, pending: Sequence[tuple]) -> FileSymbols:
+        for future, symbols in pending:
+            summary = future.result()
+            for symbol in symbols:
+                symbol.summary = summary
+            with self._lock:
+                self._known.pop(symbols[0].body_hash, None)  # later duplicates go through the cache
+        return report
+
+
+def summarize_reports(
+    reports: Iterable[FileSymbols],
+    config: Optional[SummarizerConfig] = None,
+    cache_dir: Optional[Path] = None,
+    jobs: int = 4,
+) -> List[FileSymbols]:
+    """Summarize every function of `reports` through the endpoint `config` describes (all at once)."""
+    config = config or SummarizerConfig()
+    summarizer = Summarizer(http_completer(config), config.model, cache_dir, jobs)
+    return list(summarizer.summarize(reports))
+
+
+__all__ = [
+    "DEFAULT_ENDPOINT",
+    "DEFAULT_MODEL",
+    "PROMPT",
+    "PROMPT_VERSION",
+    "Summarizer",
+    "SummarizerConfig",
+    "SummaryError",
+    "SummaryStats",
+    "clean_summary",
+    "http_completer",
+    "summarize_reports",
+]
@@ -0,0 +1,148 @@
+"""Generate ctags (universal-ctags extended format) and etags (Emacs TAGS) files."""
+
+from __future__ import annotations
+
+import re
+from dataclasses import dataclass
+from pathlib import Path
+from typing import Collection, Dict, Iterable, List, Optional, Sequence
+
+from tree_sitter import Node
+
+from . import failures
+from .core import ParsedFile, class_kind, iter_class_nodes, iter_function_nodes, iter_source_files, parse_file
+
+CTAGS_HEADER = [
+    "!_TAG_FILE_FORMAT\t2\t/extended format; --format=1 will not append ;\" to lines/",
+    "!_TAG_FILE_SORTED\t1\t/0=unsorted, 1=sorted, 2=foldcase/",
+    "!_TAG_PROGRAM_NAME\ttreesitter-tools\t//",
+]
+
+_WHITESPACE = re.compile(r"\s+")
+
+
+@dataclass
+class Tag:
+    name: str
+    path: str
+    line: int
+    kind: str
+    line_text: str
+    byte_offset: int
+    scope_kind: Optional[str] = None
+    scope: Optional[str] = None
+    signature: Optional[str] = None
+
+
+def _signature(node: Node, parsed: ParsedFile) -> Optional[str]:
+    params = node.child_by_field_name("parameters")
+    if params is None:
+        declarator = node.child_by_field_name("declarator")
+        if declarator is not None:
+            params = declarator.child_by_field_name("parameters")
+    if params is None:
+        return None
+    return _WHITESPACE.sub(" ", parsed.text(params)).strip()
+
+
+def _line_at(parsed: ParsedFile, node: Node) -> tuple[str, int]:
+    """Text of the line `node` starts on and that line's byte offset."""
+    start = parsed.source.rfind(b"\n", 0, node.start_byte) + 1
+    end = parsed.source.find(b"\n", node.start_byte)
+    if end == -1:
+        end = len(parsed.source)
+    return parsed.source[start:end].decode("utf-8", "replace").rstrip("\r"), start
+
+
+def file_tags(parsed: ParsedFile, label: Optional[str] = None) -> List[Tag]:
+    """Tags for every function, method, and type declared in `parsed`."""
+    label = label or parsed.path.as_posix()
+    tags: List[Tag] = []
+    type_kinds: Dict[str, str] = {}
+    for cls in iter_class_nodes(parsed):
+        kind = class_kind(cls.node)
+        type_kinds.setdefault(cls.name, kind)
+        text, offset = _line_at(parsed, cls.node)
+        tags.append(Tag(cls.name, label, cls.node.start_point[0] + 1, kind, text, offset, scope=cls.container))
+    for fn in iter_function_nodes(parsed):
+        text, offset = _line_at(parsed, fn.node)
+        tags.append(
+            Tag(
+                name=fn.name,
+                path=label,
+                line=fn.node.start_point[0] + 1,
+                kind="method" if fn.container else "function",
+                line_text=text,
+                byte_offset=offset,
+                scope=fn.container,
+                signature=_signature(fn.node, parsed),
+            )
+        )
+    for tag in tags:
+        if tag.scope:
+            tag.scope_kind = type_kinds.get(tag.scope, "class")
+    return tags
+
+
+def collect_tags(
+    root: Path,
+    include: Sequence[str] | None = None,
+    exclude: Sequence[str] | None = None,
+    only: Optional[Collection[Path]] = None,
+) -> List[Tag]:
+    """Tags for a file (path as given) or every recognised file under a directory (paths relative to it)."""
+    root = Path(root)
+    if root.is_file():
+        return file_tags(parse_file(root), root.as_posix())
+    base = root.resolve()
+    tags: List[Tag] = []
+    for path in iter_source_files(base, include, exclude, only):
+        try:
+            parsed = parse_file(path)
+        except (ValueError, RuntimeError, OSError) as exc:
+            failures.record(path, exc)
+            continue
+        tags.extend(file_tags(parsed, path.relative_to(base).as_posix()))
+    return tags
+
+
+def _escape_pattern(text: str) -> str:
+    return text.replace("\\", "\\\\").replace("/", "\\/")
+
+
+def _escape_field(text: str) -> str:
+    return text.replace("\\", "\\\\").replace("\t", "\\t")
+
+
+def to_ctags(tags: Iterable[Tag]) -> str:
+    """Render a sorted universal-ctags file with kind/line/scope/signature extension fields."""
+    lines = list(CTAGS_HEADER)
+    for tag in sorted(tags, key=lambda t: (t.name, t.path, t.line)):
+        fields = [f"kind:{tag.kind}", f"line:{tag.line}"]
+        if tag.scope:
+            fields.append(f"{tag.scope_kind}:{_escape_field(tag.scope)}")
+        if tag.signature:
+            fields.append(f"signature:{_escape_field(tag.signature)}")
+        address = f"/^{_escape_pattern(tag.line_text)}$/;\""
+        lines.append("\t".join([tag.name, tag.path, address, *fields]))
+    return "\n".join(lines) + "\n"
+
+
+def to_etags(tags: Iterable[Tag]) -> str:
+    """Render an Emacs TAGS file: one form-feed section per source file."""
+    by_path: Dict[str, List[Tag]] = {}
+    for tag in tags:
+        by_path.setdefault(tag.path, []).append(tag)
+    out: List[str] = []
+    for path in sorted(by_path):
+        entries = []
+        for tag in sorted(by_path[path], key=lambda t: (t.line, t.name)):
+            end = tag.line_text.find(tag.name)
+            pattern = tag.line_text[: end + len(tag.name)] if end != -1 else tag.line_text
+            entries.append(f"{pattern}\x7f{tag.name}\x01{tag.line},{tag.byte_offset}\n")
+        body = "".join(entries)
+        out.append(f"\x0c\n{path},{len(body.encode('utf-8'))}\n{body}")
+    return "".join(out)
+
+
+__all__ = ["Tag", "collect_tags", "file_tags", "to_ctags", "to_etags"]
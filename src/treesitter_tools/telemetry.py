@@ -0,0 +1,246 @@
+"""Prometheus metrics and OpenTelemetry spans for the long-lived servers."""
+
+from __future__ import annotations
+
+import math
+import os
+import threading
+from contextlib import contextmanager
+from typing import Callable, Dict, Iterator, List, Optional, Sequence, Tuple
+
+PROMETHEUS_CONTENT_TYPE = "text/plain; version=0.0.4; charset=utf-8"
+
+# Seconds; parses of typical files land in the low buckets, whole-file extracts in the high ones.
+DEFAULT_BUCKETS = (0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1.0, 2.5, 5.0, 10.0)
+
+# Label values -> sample value, for metrics read from live state at scrape time.
+Collector = Callable[[], Dict[Tuple[str, ...], float]]
+
+
+def _escape(value: str) -> str:
+    return value.replace("\\", "\\\\").replace("\n", "\\n").replace('"', '\\"')
+
+
+def _number(value: float) -> str:
+    if value == math.inf:
+        return "+Inf"
+    return str(int(value)) if float(value).is_integer() else repr(float(value))
+
+
+def _labels(names: Sequence[str], values: Sequence[str], extra: str = "") -> str:
+    pairs = [f'{n}="{_escape(str(v))}"' for n, v in zip(names, values)]
+    if extra:
+        pairs.append(extra)
+    return "{" + ",".join(pairs) + "}" if pairs else ""
+
+
+class _Family:
+    kind = "untyped"
+
+    def __init__(self, name: str, help: str, labels: Sequence[str] = (), collect: Optional[Collector] = None):
+        self.name = name
+        self.help = help
+        self.labels = tuple(labels)
+        self.collect = collect
+        self._lock = threading.Lock()
+
+    def _key(self, labels: Dict[str, str]) -> Tuple[str, ...]:
+        if set(labels) != set(self.labels):
+            raise ValueError(f"{self.name} takes labels {', '.join(self.labels) or '(none)'}")
+        return tuple(str(labels[n]) for n in self.labels)
+
+    def samples(self) -> List[str]:
+        raise NotImplementedError
+
+    def render(self) -> List[str]:
+        return [f"# HELP {self.name} {_escape(self.help)}", f"# TYPE {self.name} {self.kind}", *self.samples()]
+
+
+class Counter(_Family):
+    kind = "counter"
+
+    def __init__(self, *args, **kwargs):
+        super().__init__(*args, **kwargs)
+        self._values: Dict[Tuple[str, ...], float] = {}
+
+    def inc(self, amount: float = 1, **labels: str) -> None:
+        key = self._key(labels)
+        with self._lock:
+            self._values[key] = self._values.get(key, 0) + amount
+
+    def value(self, **labels: str) -> float:
+        values = self.collect() if self.collect is not None else self._values
+        return values.get(self._key(labels), 0)
+
+    def samples(self) -> List[str]:
+        if self.collect is not None:
+            values = self.collect()
+        else:
+            with self._lock:
+                values = dict(self._values)
+        return [f"{self.name}{_labels(self.labels, key)} {_number(v)}" for key, v in sorted(values.items())]
+
+
+class Gauge(Counter):
+    kind = "gauge"
+
+
+class Histogram(_Family):
+    kind = "histogram"
+
+    def __init__(self, *args, buckets: Sequence[float] = DEFAULT_BUCKETS, **kwargs):
+        super().__init__(*args, **kwargs)
+        self.buckets = tuple(sorted(buckets)) + (math.inf,)
+        self._values: Dict[Tuple[str, ...], Tuple[List[int], float]] = {}
+
+    def observe(self, value: float, **labels: str) -> None:
+        key = self._key(labels)
+        with self._lock:
+            counts, total = self._values.get(key) or ([0] * len(self.buckets), 0.0)
+            for i, bound in enumerate(self.buckets):
+                if value <= bound:
+                    counts[i] += 1
+            self._values[key] = (counts, total + value)
+
+    def count(self, **labels: str) -> int:
+        with self._lock:
+            entry = self._values.get(self._key(labels))
+        return entry[0][-1] if entry else 0
+
+    def samples(self) -> List[str]:
+        with self._lock:
+            values = {k: (list(c), s) for k, (c, s) in self._values.items()}
+        lines = []
+        for key, (counts, total) in sorted(values.items()):
+            for bound, count in zip(self.buckets, counts):
+                le = 'le="' + _number(bound) + '"'
+                lines.append(f"{self.name}_bucket{_labels(self.labels, key, le)} {count}")
+            lines.append(f"{self.name}_sum{_labels(self.labels, key)} {_number(total)}")
+            lines.append(f"{self.name}_count{_labels(self.labels, key)} {counts[-1]}")
+        return lines
+
+
+class MetricsRegistry:
+    """Metric families rendered in the Prometheus text exposition format."""
+
+    def __init__(self):
+        self._families: Dict[str, _Family] = {}
+
+    def _add(self, family: _Family) -> _Family:
+        if family.name in self._families:
+            raise ValueError(f"Metric already registered: {family.name}")
+        self._families[family.name] = family
+        return family
+
+    def counter(self, name: str, help: str, labels: Sequence[str] = (), collect: Optional[Collector] = None) -> Counter:
+        return self._add(Counter(name, help, labels, collect))
+
+    def gauge(self, name: str, help: str, labels: Sequence[str] = (), collect: Optional[Collector] = None) -> Gauge:
+        return self._add(Gauge(name, help, labels, collect))
+
+    def histogram(
+        self, name: str, help: str, labels: Sequence[str] = (), buckets: Sequence[float] = DEFAULT_BUCKETS
+    ) -> Histogram:
+        return self._add(Histogram(name, help, labels, buckets=buckets))
+
+    def render(self) -> str:
+        lines = [line for family in self._families.values() for line in family.render()]
+        return "\n".join(lines) + "\n"
+
+
+class _NoSpan:
+    """Stands in for a span when OpenTelemetry is not installed."""
+
+    def set_attribute(self, key: str, value) -> None:
+        pass
+
+
+class Tracer:
+    """
+    Spans through the OpenTelemetry API when it is installed, no-ops otherwise.
+
+    Without a configured SDK (see `configure_tracing`) the API's spans are
+    non-recording, so instrumented code costs almost nothing. An embedding
+    application that sets its own tracer provider gets the spans too.
+    """
+
+    def __init__(self, name: str = "treesitter_tools"):
+        try:
+            from opentelemetry import propagate, trace
+        except ImportError:
+            self._tracer = None
+            return
+        from . import __version__
+
+        self._propagate = propagate
+        self._kinds = {True: trace.SpanKind.SERVER, False: trace.SpanKind.INTERNAL}
+        self._tracer = trace.get_tracer(name, __version__)
+
+    @property
+    def available(self) -> bool:
+        return self._tracer is not None
+
+    @contextmanager
+    def span(
+        self, name: str, attributes: Optional[dict] = None, carrier: Optional[dict] = None, server: bool = False
+    ) -> Iterator:
+        """A span named `name`, continuing the trace whose `traceparent` is in `carrier` (request headers)."""
+        if self._tracer is None:
+            yield _NoSpan()
+            return
+        context = self._propagate.extract(carrier) if carrier is not None else None
+        with self._tracer.start_as_current_span(
+            name, context=context, kind=self._kinds[server], attributes=attributes
+        ) as span:
+            yield span
+
+
+def configure_tracing(service_name: str = "treesitter-tools", exporter: Optional[str] = None):
+    """
+    Install an OpenTelemetry SDK tracer provider that batches spans to `exporter`:
+    "otlp" (the default, configured by the standard OTEL_EXPORTER_OTLP_* variables)
+    or "console". OTEL_TRACES_EXPORTER and OTEL_SERVICE_NAME are honoured. Returns
+    the provider; call `.shutdown()` to flush on exit.
+    """
+    try:
+        from opentelemetry import trace
+        from opentelemetry.sdk.resources import Resource
+        from opentelemetry.sdk.trace import TracerProvider
+        from opentelemetry.sdk.trace.export import BatchSpanProcessor, ConsoleSpanExporter
+    except ImportError as exc:
+        raise RuntimeError(
+            "Tracing requires the optional OpenTelemetry packages (pip install 'treesitter-tools[otel]')"
+        ) from exc
+    exporter = exporter or os.environ.get("OTEL_TRACES_EXPORTER", "otlp")
+    if exporter == "console":
+        span_exporter = ConsoleSpanExporter()
+    elif exporter == "otlp":
+        try:
+            from opentelemetry.exporter.otlp.proto.http.trace_exporter import OTLPSpanExporter
+        except ImportError:
+            try:
+                from opentelemetry.exporter.otlp.proto.grpc.trace_exporter import OTLPSpanExporter
+            except ImportError as exc:
+                raise RuntimeError(
+                    "The OTLP exporter is not installed (pip install opentelemetry-exporter-otlp-proto-http)"
+                ) from exc
+        span_exporter = OTLPSpanExporter()
+    else:
+        raise ValueError(f"Unknown trace exporter '{exporter}' (expected otlp or console)")
+    resource = Resource.create({"service.name": os.environ.get("OTEL_SERVICE_NAME", service_name)})
+    provider = TracerProvider(resource=resource)
+    provider.add_span_processor(BatchSpanProcessor(span_exporter))
+    trace.set_tracer_provider(provider)
+    return provider
+
+
+__all__ = [
+    "DEFAULT_BUCKETS",
+    "PROMETHEUS_CONTENT_TYPE",
+    "Counter",
+    "Gauge",
+    "Histogram",
+    "MetricsRegistry",
+    "Tracer",
+    "configure_tracing",
+]
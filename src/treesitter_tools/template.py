@@ -0,0 +1,464 @@
+"""
+`--format template`: records rendered through a Go `text/template`-style template,
+one rendering per record followed by a newline, so a command can print grep-like
+lines, CSV, or org-mode tables directly:
+
+    {{.Kind}} {{.Name}} {{.File}}:{{.Line}}
+    {{.Path}},{{csv .Name}},{{.StartLine}}
+    {{range .Captures}}{{$.Path}}:{{.StartLine}} {{.Text}}{{"\\n"}}{{end}}
+
+The supported subset: `{{.Field}}` and chains (`{{.A.B}}`), `{{.}}`, `{{$}}` and
+`{{$.Field}}` (the record itself), string, number, and boolean constants,
+pipelines (`{{.Name | upper}}`), `if`/`else if`/`else`, `range`, and `with` blocks
+ending in `end`, `{{/* comments */}}`, and `{{-`/`-}}` whitespace trimming.
+Functions: printf (Go verbs), print, len, index, join, json, csv, upper, lower,
+trim, eq, ne, lt, le, gt, ge, and, or, not.
+
+Fields are the record's JSON properties in CamelCase (`.StartLine` is `start_line`;
+the exact property name works too), plus the aliases `.File` (`path`) and `.Line`
+(`start_line`). A template is checked against the record's schema before anything
+is rendered, so a misspelt field is an error rather than empty output; properties
+the record leaves out render as nothing.
+"""
+
+from __future__ import annotations
+
+import json
+import re
+from dataclasses import dataclass, field
+from typing import Any, Callable, Dict, Iterable, List, Optional, Tuple
+
+from . import schema
+
+ALIASES = {"file": "path", "line": "start_line"}
+
+
+class TemplateError(ValueError):
+    pass
+
+
+def _snake(name: str) -> str:
+    return re.sub(r"(?<=[a-z0-9])(?=[A-Z])|(?<=[A-Z])(?=[A-Z][a-z])", "_", name).lower()
+
+
+def _camel(key: str) -> str:
+    return "".join(part[:1].upper() + part[1:] for part in key.split("_"))
+
+
+# --- Parsing -----------------------------------------------------------------
+
+_TOKEN = re.compile(
+    r"""\s*(?:
+      (?P<string>"(?:[^"\\]|\\.)*")
+    | (?P<raw>`[^`]*`)
+    | (?P<var>\$(?:\.[A-Za-z_]\w*)*)
+    | (?P<field>(?:\.[A-Za-z_]\w*)+|\.)
+    | (?P<number>-?\d+(?:\.\d+)?)
+    | (?P<ident>[A-Za-z_]\w*)
+    | (?P<punct>[|()])
+    )""",
+    re.VERBOSE,
+)
+
+
+@dataclass
+class _Operand:
+    kind: str  # "field" (from dot), "var" (from the record), "const", "func", or "pipe"
+    value: Any  # field names, the constant, the function name, or a nested pipeline
+
+
+@dataclass
+class _Pipeline:
+    commands: List[List[_Operand]]
+
+
+@dataclass
+class _Block:
+    keyword: str  # "if", "range", or "with"
+    pipeline: _Pipeline
+    body: List[Any] = field(default_factory=list)
+    otherwise: List[Any] = field(default_factory=list)
+    chained: bool = False  # `otherwise` holds an `else if` block, closed by the same `end`
+
+
+def _tokens(action: str) -> List[Tuple[str, str]]:
+    tokens, pos = [], 0
+    while pos < len(action):
+        if action[pos:].strip() == "":
+            break
+        match = _TOKEN.match(action, pos)
+        if match is None or match.end() == pos:
+            raise TemplateError(f"template: unexpected {action[pos:].strip()[:1]!r} in {{{{{action.strip()}}}}}")
+        tokens.append((match.lastgroup, match.group(match.lastgroup)))
+        pos = match.end()
+    return tokens
+
+
+def _constant(kind: str, text: str) -> Any:
+    if kind == "raw":
+        return text[1:-1]
+    if kind == "string":
+        try:
+            return json.loads(text)
+        except ValueError:
+            raise TemplateError(f"template: bad string constant {text}") from None
+    return float(text) if "." in text else int(text)
+
+
+def _parse_pipeline(tokens: List[Tuple[str, str]], pos: int, action: str) -> Tuple[_Pipeline, int]:
+    commands: List[List[_Operand]] = [[]]
+    while pos < len(tokens):
+        kind, text = tokens[pos]
+        if kind == "punct" and text == ")":
+            break
+        pos += 1
+        if kind == "punct" and text == "|":
+            if not commands[-1]:
+                raise TemplateError(f"template: missing command before '|' in {{{{{action}}}}}")
+            commands.append([])
+        elif kind == "punct":
+            nested, pos = _parse_pipeline(tokens, pos, action)
+            if pos >= len(tokens) or tokens[pos] != ("punct", ")"):
+                raise TemplateError(f"template: unclosed '(' in {{{{{action}}}}}")
+            pos += 1
+            commands[-1].append(_Operand("pipe", nested))
+        elif kind == "field":
+            commands[-1].append(_Operand("field", [] if text == "." else text[1:].split(".")))
+        elif kind == "var":
+            commands[-1].append(_Operand("var", text[2:].split(".") if len(text) > 1 else []))
+        elif kind == "ident" and text in ("true", "false", "nil"):
+            commands[-1].append(_Operand("const", {"true": True, "false": False, "nil": None}[text]))
+        elif kind == "ident":
+            if text not in FUNCTIONS:
+                raise TemplateError(f"template: function \"{text}\" not defined")
+            commands[-1].append(_Operand("func", text))
+        else:
+            commands[-1].append(_Operand("const", _constant(kind, text)))
+    if not commands[-1]:
+        raise TemplateError(f"template: missing value in {{{{{action}}}}}")
+    return _Pipeline(commands), pos
+
+
+def _split(text: str) -> Iterable[Tuple[str, str]]:
+    """("text", chunk) and ("action", contents) parts, with `{{-`/`-}}` trimming applied."""
+    pos, trim_next = 0, False
+    while True:
+        start = text.find("{{", pos)
+        chunk = text[pos:] if start < 0 else text[pos:start]
+        if trim_next:
+            chunk = chunk.lstrip()
+        if start < 0:
+            if chunk:
+                yield "text", chunk
+            return
+        end = text.find("}}", start + 2)
+        if end < 0:
+            raise TemplateError("template: unclosed action (missing '}}')")
+        action = text[start + 2:end]
+        if action.startswith("- "):
+            chunk, action = chunk.rstrip(), action[2:]
+        trim_next = action.endswith(" -")
+        if trim_next:
+            action = action[:-2]
+        if chunk:
+            yield "text", chunk
+        yield "action", action.strip()
+        pos = end + 2
+
+
+def _parse(text: str) -> List[Any]:
+    root: List[Any] = []
+    stack: List[Tuple[_Block, List[Any]]] = []  # open blocks, and the list their next nodes go into
+    current = root
+    for kind, part in _split(text):
+        if kind == "text":
+            current.append(part)
+            continue
+        if part.startswith("/*"):
+            if not part.endswith("*/"):
+                raise TemplateError("template: unclosed comment")
+            continue
+        keyword, _, rest = part.partition(" ")
+        if keyword == "end":
+            if not stack:
+                raise TemplateError("template: unexpected {{end}}")
+            stack.pop()
+            current = stack[-1][1] if stack else root
+            while stack and stack[-1][0].chained and current is stack[-1][0].otherwise:
+                stack.pop()
+                current = stack[-1][1] if stack else root
+            continue
+        if keyword == "else":
+            if not stack or current is stack[-1][0].otherwise:
+                raise TemplateError("template: unexpected {{else}}")
+            block = stack[-1][0]
+            current = block.otherwise
+            stack[-1] = (block, current)
+            inner, _, condition = rest.strip().partition(" ")
+            if inner == "if" and condition:
+                block.chained = True
+                nested = _Block("if", _parse_action(condition))
+                current.append(nested)
+                stack.append((nested, nested.body))
+                current = nested.body
+            elif rest.strip():
+                raise TemplateError(f"template: unexpected {{{{{part}}}}}")
+            continue
+        if keyword in ("if", "range", "with"):
+            if not rest.strip():
+                raise TemplateError(f"template: missing value for {keyword}")
+            block = _Block(keyword, _parse_action(rest))
+            current.append(block)
+            stack.append((block, block.body))
+            current = block.body
+            continue
+        current.append(_parse_action(part))
+    if stack:
+        raise TemplateError(f"template: unexpected end of template (unclosed {{{{{stack[-1][0].keyword}}}}})")
+    return root
+
+
+def _parse_action(action: str) -> _Pipeline:
+    tokens = _tokens(action)
+    pipeline, pos = _parse_pipeline(tokens, 0, action)
+    if pos < len(tokens):
+        raise TemplateError(f"template: unexpected ')' in {{{{{action}}}}}")
+    return pipeline
+
+
+# --- Values and functions ----------------------------------------------------
+
+def _text(value: Any) -> str:
+    if value is None:
+        return ""
+    if isinstance(value, bool):
+        return "true" if value else "false"
+    if isinstance(value, (list, tuple)):
+        return "[" + " ".join(_text(v) for v in value) + "]"
+    if isinstance(value, dict):
+        return json.dumps(value, ensure_ascii=False)
+    return str(value)
+
+
+def _truth(value: Any) -> bool:
+    return value is not None and value is not False and value != 0 and value != "" and value != [] and value != {}
+
+
+_VERB = re.compile(r"%([-+# 0]*\d*(?:\.\d+)?)([a-zA-Z%])")
+
+
+def _printf(fmt: str, *args: Any) -> str:
+    remaining = list(args)
+
+    def one(match: re.Match) -> str:
+        flags, verb = match.groups()
+        if verb == "%":
+            return "%"
+        if not remaining:
+            return f"%!{verb}(MISSING)"
+        value = remaining.pop(0)
+        if verb in "vst":
+            return ("%" + flags + "s") % _text(value)
+        if verb == "q":
+            return ("%" + flags + "s") % json.dumps(_text(value), ensure_ascii=False)
+        try:
+            return ("%" + flags + verb) % value
+        except (TypeError, ValueError):
+            return f"%!{verb}({_text(value)})"
+
+    return _VERB.sub(one, fmt)
+
+
+def _print(*args: Any) -> str:
+    """Go's `fmt.Sprint`: a space goes between two operands only when neither is a string."""
+    out: List[str] = []
+    for i, arg in enumerate(args):
+        if i and not isinstance(arg, str) and not isinstance(args[i - 1], str):
+            out.append(" ")
+        out.append(_text(arg))
+    return "".join(out)
+
+
+def _csv(value: Any) -> str:
+    text = _text(value)
+    return '"' + text.replace('"', '""') + '"' if any(c in text for c in ',"\n\r') else text
+
+
+def _index(value: Any, *keys: Any) -> Any:
+    for key in keys:
+        try:
+            value = value[key]
+        except (IndexError, KeyError, TypeError):
+            return None
+    return value
+
+
+FUNCTIONS: Dict[str, Callable[..., Any]] = {
+    "printf": _printf,
+    "print": _print,
+    "len": lambda value: len(value) if value is not None else 0,
+    "index": _index,
+    "join": lambda items, sep: sep.join(_text(i) for i in items or []),
+    "json": lambda value: json.dumps(value, ensure_ascii=False),
+    "csv": _csv,
+    "upper": lambda value: _text(value).upper(),
+    "lower": lambda value: _text(value).lower(),
+    "trim": lambda value: _text(value).strip(),
+    "eq": lambda a, *others: any(a == o for o in others),
+    "ne": lambda a, b: a != b,
+    "lt": lambda a, b: a < b,
+    "le": lambda a, b: a <= b,
+    "gt": lambda a, b: a > b,
+    "ge": lambda a, b: a >= b,
+    "not": lambda value: not _truth(value),
+    "and": lambda *values: next((v for v in values if not _truth(v)), values[-1]),
+    "or": lambda *values: next((v for v in values if _truth(v)), values[-1]),
+}
+
+
+# --- Checking and rendering --------------------------------------------------
+
+def _key(data: dict, name: str) -> Optional[str]:
+    for key in (name, _snake(name), ALIASES.get(_snake(name))):
+        if key is not None and key in data:
+            return key
+    return None
+
+
+def _lookup(value: Any, names: List[str]) -> Any:
+    for name in names:
+        if value is None:
+            return None
+        if not isinstance(value, dict):
+            raise TemplateError(f"template: can't evaluate field {name} in {type(value).__name__} {_text(value)!r}")
+        key = _key(value, name)
+        value = value[key] if key is not None else None
+    return value
+
+
+def _prop(record: str, name: str) -> schema.Prop:
+    props = {p.name: p for p in schema.RECORDS[record].props}
+    for key in (name, _snake(name), ALIASES.get(_snake(name))):
+        if key in props:
+            return props[key]
+    fields = ", ".join(_camel(p) for p in props)
+    raise TemplateError(f"template: {record} has no field .{name} (fields: {fields})")
+
+
+def _check_fields(record: Optional[str], names: List[str]) -> Tuple[Optional[str], bool]:
+    """The record the chain `names` ends on (None: not a record, or unknown), and whether it is an array."""
+    array = False
+    for name in names:
+        if record is None or array:
+            return None, False
+        prop = _prop(record, name)
+        record = prop.schema if isinstance(prop.schema, str) else None
+        array = prop.array
+    return record, array
+
+
+def _check_pipeline(pipeline: _Pipeline, dot: Optional[str], root: Optional[str]) -> Tuple[Optional[str], bool]:
+    result: Tuple[Optional[str], bool] = (None, False)
+    for command in pipeline.commands:
+        for operand in command:
+            if operand.kind == "field":
+                result = _check_fields(dot, operand.value)
+            elif operand.kind == "var":
+                result = _check_fields(root, operand.value)
+            elif operand.kind == "pipe":
+                result = _check_pipeline(operand.value, dot, root)
+        if len(command) > 1 or command[0].kind in ("func", "const"):
+            result = (None, False)
+    return result
+
+
+def _check(nodes: List[Any], dot: Optional[str], root: Optional[str]) -> None:
+    for node in nodes:
+        if isinstance(node, _Pipeline):
+            _check_pipeline(node, dot, root)
+        elif isinstance(node, _Block):
+            record, array = _check_pipeline(node.pipeline, dot, root)
+            if node.keyword == "range":
+                inner = record if array else None
+            elif node.keyword == "with":
+                inner = None if array else record
+            else:
+                inner = dot
+            _check(node.body, inner, root)
+            _check(node.otherwise, dot, root)
+
+
+class Template:
+    """A parsed template, rendered once per record."""
+
+    def __init__(self, text: str, record: Optional[str] = None):
+        self.text = text
+        self.nodes = _parse(text)
+        if record is not None:
+            _check(self.nodes, record, record)
+
+    def _run(self, pipeline: _Pipeline, dot: Any, root: Any) -> Any:
+        value: Any = None
+        for i, command in enumerate(pipeline.commands):
+            args = [self._operand(op, dot, root) for op in command[1:]]
+            if i > 0:
+                args.append(value)
+            head = command[0]
+            if head.kind == "func":
+                try:
+                    value = FUNCTIONS[head.value](*args)
+                except TypeError as exc:
+                    raise TemplateError(f"template: wrong arguments for {head.value}: {exc}") from None
+            elif args:
+                raise TemplateError(f"template: {_text(head.value)} is not a function")
+            else:
+                value = self._operand(head, dot, root)
+        return value
+
+    def _operand(self, operand: _Operand, dot: Any, root: Any) -> Any:
+        if operand.kind == "field":
+            return _lookup(dot, operand.value)
+        if operand.kind == "var":
+            return _lookup(root, operand.value)
+        if operand.kind == "pipe":
+            return self._run(operand.value, dot, root)
+        if operand.kind == "func":
+            return FUNCTIONS[operand.value]()
+        return operand.value
+
+    def _render(self, nodes: List[Any], dot: Any, root: Any, out: List[str]) -> None:
+        for node in nodes:
+            if isinstance(node, str):
+                out.append(node)
+            elif isinstance(node, _Pipeline):
+                out.append(_text(self._run(node, dot, root)))
+            else:
+                value = self._run(node.pipeline, dot, root)
+                if node.keyword == "range" and _truth(value):
+                    items = value.values() if isinstance(value, dict) else value
+                    for item in items:
+                        self._render(node.body, item, root, out)
+                elif node.keyword != "range" and _truth(value):
+                    self._render(node.body, dot if node.keyword == "if" else value, root, out)
+                else:
+                    self._render(node.otherwise, dot, root, out)
+
+    def render(self, record: Any) -> str:
+        out: List[str] = []
+        self._render(self.nodes, record, record, out)
+        return "".join(out)
+
+    def render_all(self, records: Iterable[Any]) -> str:
+        """Each record's rendering followed by a newline."""
+        return "".join(self.render(record) + "\n" for record in records)
+
+
+def check_template(text: Optional[str], fmt: str) -> None:
+    """ValueError unless `--template` is given exactly when `--format template` is."""
+    if fmt == "template" and not text:
+        raise ValueError("--format template needs --template TEXT")
+    if fmt != "template" and text:
+        raise ValueError("--template needs --format template")
+
+
+__all__ = ["ALIASES", "FUNCTIONS", "Template", "TemplateError", "check_template"]
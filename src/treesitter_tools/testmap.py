@@ -0,0 +1,320 @@
+"""Test discovery and a test-to-source map: which exported symbols no test references."""
+
+from __future__ import annotations
+
+import fnmatch
+import json
+import re
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Dict, Iterator, List, Optional, Sequence, Set, Tuple
+
+from tree_sitter import Node
+
+from . import failures
+from .core import ParsedFile, iter_function_nodes, iter_source_files, parse_file
+from .unused import REFERENCE_NODE_TYPES, Definition, file_definitions
+
+_JS_LANGUAGES = {"javascript", "typescript", "tsx"}
+_GO_TEST = re.compile(r"^(Test|Benchmark|Fuzz|Example)([A-Z_0-9]|$)")
+_JS_TEST_CALLS = {"it", "test"}
+_JS_SUITE_CALLS = {"describe", "suite", "context"}
+
+# Symbol kinds whose coverage is reported; constants and type aliases rarely have tests of their own.
+COVERED_KINDS = {"function", "method", "class", "struct"}
+
+
+def is_test_file(rel_path: str, language: str) -> bool:
+    name = rel_path.rpartition("/")[2]
+    if language == "go":
+        return name.endswith("_test.go")
+    if language == "python":
+        return fnmatch.fnmatch(name, "test_*.py") or fnmatch.fnmatch(name, "*_test.py")
+    if language in _JS_LANGUAGES:
+        return ".test." in name or ".spec." in name or "/__tests__/" in f"/{rel_path}"
+    return False
+
+
+def _is_support(rel_path: str) -> bool:
+    """Neither production code nor tests: conftest.py, test data, fixtures, mocks."""
+    parts = rel_path.split("/")
+    if parts[-1] == "conftest.py":
+        return True
+    return any(part in {"testdata", "fixtures", "__mocks__"} for part in parts[:-1])
+
+
+@dataclass
+class DiscoveredTest:
+    name: str  # pytest-style id within the file: `test_x`, `TestA::test_x`, `suite > case`
+    path: str
+    line: int
+    end_line: int
+    framework: str  # "go", "pytest", or "jest"
+    node: Node
+    references: List[str] = field(default_factory=list)
+
+    @property
+    def id(self) -> str:
+        return f"{self.path}::{self.name}"
+
+    def to_dict(self) -> dict:
+        return {
+            "id": self.id,
+            "name": self.name,
+            "path": self.path,
+            "line": self.line,
+            "end_line": self.end_line,
+            "framework": self.framework,
+            "references": self.references,
+        }
+
+
+@dataclass
+class SymbolCoverage:
+    definition: Definition
+    tests: List[str] = field(default_factory=list)
+
+    def to_dict(self) -> dict:
+        return {**self.definition.to_dict(), "tests": self.tests}
+
+
+@dataclass
+class CoverageReport:
+    tests: List[DiscoveredTest] = field(default_factory=list)
+    symbols: List[SymbolCoverage] = field(default_factory=list)
+
+    @property
+    def untested(self) -> List[SymbolCoverage]:
+        return [s for s in self.symbols if s.definition.exported and not s.tests]
+
+    def summary(self) -> dict:
+        exported = [s for s in self.symbols if s.definition.exported]
+        tested = len(exported) - len(self.untested)
+        return {
+            "tests": len(self.tests),
+            "exported_symbols": len(exported),
+            "tested": tested,
+            "untested": len(self.untested),
+            "coverage_intent": round(tested / len(exported), 3) if exported else 1.0,
+        }
+
+    def to_json(self) -> str:
+        return json.dumps(
+            {
+                "summary": self.summary(),
+                "tests": [t.to_dict() for t in self.tests],
+                "symbols": [s.to_dict() for s in self.symbols],
+                "untested": [s.definition.to_dict() for s in self.untested],
+            },
+            indent=2,
+        )
+
+    def to_text(self) -> str:
+        summary = self.summary()
+        lines = [
+            f"{summary['tests']} tests; {summary['tested']}/{summary['exported_symbols']} exported symbols "
+            f"referenced by a test ({summary['coverage_intent']:.0%})"
+        ]
+        if self.untested:
+            lines.append("Untested exported symbols:")
+            for item in self.untested:
+                d = item.definition
+                lines.append(f"  {d.path}:{d.line}: {d.kind} {d.qualified_name}")
+        return "\n".join(lines) + "\n"
+
+
+def _python_tests(parsed: ParsedFile) -> Iterator[Tuple[str, Node]]:
+    for node in parsed.root.named_children:
+        target = node.child_by_field_name("definition") if node.type == "decorated_definition" else node
+        if target is None:
+            continue
+        name_node = target.child_by_field_name("name")
+        if name_node is None:
+            continue
+        name = parsed.text(name_node)
+        if target.type == "function_definition" and name.startswith("test"):
+            yield name, node
+        elif target.type == "class_definition" and name.startswith("Test"):
+            body = target.child_by_field_name("body")
+            for member in body.named_children if body is not None else ():
+                method = member.child_by_field_name("definition") if member.type == "decorated_definition" else member
+                method_name = method.child_by_field_name("name") if method is not None else None
+                if method is not None and method.type == "function_definition" and method_name is not None:
+                    if parsed.text(method_name).startswith("test"):
+                        yield f"{name}::{parsed.text(method_name)}", member
+
+
+def _go_tests(parsed: ParsedFile) -> Iterator[Tuple[str, Node]]:
+    for node in parsed.root.named_children:
+        if node.type == "function_declaration":
+            name_node = node.child_by_field_name("name")
+            if name_node is not None and _GO_TEST.match(parsed.text(name_node)):
+                yield parsed.text(name_node), node
+
+
+def _js_callee(node: Node, parsed: ParsedFile) -> Optional[str]:
+    """`it`, `it.only`, `test.each(...)`: the base name of a test-framework call."""
+    function = node.child_by_field_name("function")
+    while function is not None:
+        if function.type == "identifier":
+            return parsed.text(function)
+        if function.type == "member_expression":
+            function = function.child_by_field_name("object")
+        elif function.type == "call_expression":
+            function = function.child_by_field_name("function")
+        else:
+            return None
+    return None
+
+
+def _js_title(node: Node, parsed: ParsedFile) -> Optional[str]:
+    arguments = node.child_by_field_name("arguments")
+    if arguments is None or not arguments.named_children:
+        return None
+    first = arguments.named_children[0]
+    if first.type not in {"string", "template_string"}:
+        return None
+    return parsed.text(first)[1:-1]
+
+
+def _js_tests(parsed: ParsedFile) -> Iterator[Tuple[str, Node]]:
+    def visit(node: Node, suites: List[str]) -> Iterator[Tuple[str, Node]]:
+        for child in node.named_children:
+            if child.type == "call_expression":
+                callee = _js_callee(child, parsed)
+                title = _js_title(child, parsed) if callee else None
+                if title is not None and callee in _JS_TEST_CALLS:
+                    yield " > ".join(suites + [title]), child
+                    continue
+                if title is not None and callee in _JS_SUITE_CALLS:
+                    yield from visit(child, suites + [title])
+                    continue
+            yield from visit(child, suites)
+
+    return visit(parsed.root, [])
+
+
+def discover_tests(parsed: ParsedFile, label: str) -> List[DiscoveredTest]:
+    """Test functions (Go), pytest tests and `Test*` methods, and Jest `it`/`test` cases in a test file."""
+    if parsed.language == "go":
+        found, framework = _go_tests(parsed), "go"
+    elif parsed.language == "python":
+        found, framework = _python_tests(parsed), "pytest"
+    elif parsed.language in _JS_LANGUAGES:
+        found, framework = _js_tests(parsed), "jest"
+    else:
+        return []
+    return [
+        DiscoveredTest(name, label, node.start_point[0] + 1, node.end_point[0] + 1, framework, node)
+        for name, node in found
+    ]
+
+
+def _names_in(node: Node, parsed: ParsedFile) -> Set[str]:
+    names = set()
+    stack = [node]
+    while stack:
+        current = stack.pop()
+        if current.type in REFERENCE_NODE_TYPES:
+            names.add(parsed.text(current))
+        stack.extend(current.children)
+    return names
+
+
+def _helper_closure(tests: List[DiscoveredTest], parsed: ParsedFile) -> Dict[str, Set[str]]:
+    """Names each test references, including through helper functions defined in the same file."""
+    spans = [(t.node.start_byte, t.node.end_byte) for t in tests]
+    helpers: Dict[str, Set[str]] = {}
+    # Fixtures count as helpers too: a pytest test names them as parameters.
+    for fn in iter_function_nodes(parsed):
+        if fn.anonymous or any(lo <= fn.node.start_byte < hi for lo, hi in spans):
+            continue
+        helpers.setdefault(fn.name, set()).update(_names_in(fn.node, parsed))
+    closures = {}
+    for test in tests:
+        names = _names_in(test.node, parsed)
+        pending = [n for n in names if n in helpers]
+        seen: Set[str] = set()
+        while pending:
+            helper = pending.pop()
+            if helper in seen:
+                continue
+            seen.add(helper)
+            for name in helpers[helper] - names:
+                names.add(name)
+                if name in helpers:
+                    pending.append(name)
+        closures[test.id] = names - set(helpers)
+    return closures
+
+
+def _package(path: str) -> str:
+    return path.rpartition("/")[0] or "."
+
+
+def _family(language: str) -> str:
+    return "javascript" if language in _JS_LANGUAGES else language
+
+
+def map_tests(
+    root: Path,
+    include: Sequence[str] | None = None,
+    exclude: Sequence[str] | None = None,
+) -> CoverageReport:
+    """
+    Discover tests under `root` and map each to the production symbols it names.
+    A test references a symbol when an identifier in its body (or in a helper it
+    calls from the same file) has the symbol's name; Go tests only see their own
+    package directory. This is coverage intent, not executed coverage.
+    """
+    base = Path(root).resolve()
+    production: List[Tuple[Definition, str]] = []
+    test_files: List[Tuple[ParsedFile, str]] = []
+    for path in iter_source_files(base, include, exclude):
+        label = path.relative_to(base).as_posix()
+        if _is_support(label):
+            continue
+        try:
+            parsed = parse_file(path)
+        except (ValueError, RuntimeError, OSError) as exc:
+            failures.record(path, exc)
+            continue
+        if is_test_file(label, parsed.language):
+            test_files.append((parsed, label))
+        elif label.split("/")[0] not in {"tests", "test"}:
+            family = _family(parsed.language)
+            production.extend((d, family) for d in file_definitions(parsed, label) if d.kind in COVERED_KINDS)
+
+    report = CoverageReport()
+    by_name: Dict[Tuple[str, str], List[SymbolCoverage]] = {}
+    for defn, family in production:
+        item = SymbolCoverage(defn)
+        report.symbols.append(item)
+        by_name.setdefault((family, defn.name), []).append(item)
+
+    for parsed, label in test_files:
+        tests = discover_tests(parsed, label)
+        closures = _helper_closure(tests, parsed)
+        for test in tests:
+            for name in sorted(closures[test.id]):
+                for item in by_name.get((_family(parsed.language), name), ()):
+                    defn = item.definition
+                    if parsed.language == "go" and _package(defn.path) != _package(label):
+                        continue
+                    item.tests.append(test.id)
+                    test.references.append(f"{defn.path}:{defn.qualified_name}")
+            test.references.sort()
+        report.tests.extend(tests)
+    report.symbols.sort(key=lambda s: (s.definition.path, s.definition.line, s.definition.qualified_name))
+    return report
+
+
+__all__ = [
+    "COVERED_KINDS",
+    "CoverageReport",
+    "DiscoveredTest",
+    "SymbolCoverage",
+    "discover_tests",
+    "is_test_file",
+    "map_tests",
+]
@@ -0,0 +1,310 @@
+"""
+Code-health treemap: the tree of a repository (directory -> file -> function) with each
+function sized by its source lines and colored by complexity or churn, as a JSON tree
+or one self-contained HTML page (no external scripts, styles, or fonts) to share in
+code-health reviews.
+
+Files without functions are left out, and a directory holding nothing but one other
+directory is merged with it (`src/app`). A directory or file's `value` is the highest
+value of a function inside it, so a hot spot shows at every level of the map.
+"""
+
+from __future__ import annotations
+
+import html
+import json
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Dict, Iterable, List, Optional, Sequence, Tuple
+
+from .metrics import FunctionMetrics, collect_metrics
+
+COLORS = ("complexity", "churn")
+
+# Detail values at or above which a function is drawn fully red; other details scale to their maximum.
+RED_AT = {"cyclomatic": 20, "cognitive": 30, "max_nesting": 6}
+
+
+@dataclass
+class TreemapNode:
+    name: str
+    kind: str  # "directory", "file", or "function"
+    path: str
+    size: int = 0  # source lines of the functions beneath
+    value: int = 0  # the color metric (highest beneath, for directories and files)
+    line: Optional[int] = None
+    details: Dict[str, object] = field(default_factory=dict)
+    children: List["TreemapNode"] = field(default_factory=list)
+
+    def to_dict(self) -> dict:
+        data = {"name": self.name, "kind": self.kind, "path": self.path, "size": self.size, "value": self.value}
+        if self.line is not None:
+            data["line"] = self.line
+        if self.details:
+            data["details"] = self.details
+        if self.children:
+            data["children"] = [child.to_dict() for child in self.children]
+        return data
+
+    def functions(self) -> Iterable["TreemapNode"]:
+        if self.kind == "function":
+            yield self
+        for child in self.children:
+            yield from child.functions()
+
+
+def _details(metrics: FunctionMetrics) -> Dict[str, object]:
+    return {
+        "loc": metrics.loc,
+        "sloc": metrics.sloc,
+        "cyclomatic": metrics.cyclomatic,
+        "cognitive": metrics.cognitive,
+        "max_nesting": metrics.max_nesting,
+    }
+
+
+def _finish(node: TreemapNode) -> None:
+    """Sum sizes, take the highest values, sort biggest first, and merge single-directory chains."""
+    for child in node.children:
+        _finish(child)
+    while node.kind == "directory" and len(node.children) == 1 and node.children[0].kind == "directory" and node.path:
+        only = node.children[0]
+        node.name, node.path, node.children = f"{node.name}/{only.name}", only.path, only.children
+    if node.children:
+        node.size = sum(child.size for child in node.children)
+        node.value = max(child.value for child in node.children)
+    node.children.sort(key=lambda child: (-child.size, child.name, child.line or 0))
+
+
+def build_treemap(name: str, functions: Sequence[Tuple[FunctionMetrics, int, Dict[str, object]]]) -> TreemapNode:
+    """The tree of `functions`, each given with its color value and extra details (e.g. churn)."""
+    root = TreemapNode(name, "directory", "")
+    directories: Dict[str, TreemapNode] = {"": root}
+    files: Dict[str, TreemapNode] = {}
+    for metrics, value, extra in functions:
+        path = metrics.path
+        parent = root
+        parts = path.split("/")
+        for depth in range(1, len(parts)):
+            prefix = "/".join(parts[:depth])
+            if prefix not in directories:
+                directories[prefix] = TreemapNode(parts[depth - 1], "directory", prefix)
+                parent.children.append(directories[prefix])
+            parent = directories[prefix]
+        if path not in files:
+            files[path] = TreemapNode(parts[-1], "file", path)
+            parent.children.append(files[path])
+        files[path].children.append(TreemapNode(
+            metrics.name, "function", path, size=max(metrics.sloc, 1), value=value, line=metrics.start_line,
+            details={**_details(metrics), **extra},
+        ))
+    _finish(root)
+    return root
+
+
+def collect_treemap(
+    root: Path,
+    include: Sequence[str] | None = None,
+    exclude: Sequence[str] | None = None,
+    color: str = "complexity",
+    metric: str = "cyclomatic",
+    days: Optional[int] = None,
+) -> TreemapNode:
+    """
+    The treemap of every function under `root`, colored by `metric` or, with `color`
+    "churn", by the commits that touched each function (see `hotspots.churn`).
+    """
+    from .hotspots import METRICS, collect_hotspots
+
+    if color not in COLORS:
+        raise ValueError(f"Unknown color '{color}' (expected {' or '.join(COLORS)})")
+    if metric not in METRICS:
+        raise ValueError(f"Unknown metric '{metric}' (expected {' or '.join(METRICS)})")
+    root = Path(root)
+    if color == "churn":
+        entries = [
+            (h.metrics, h.ownership.churn, {"churn": h.ownership.churn, "primary_author": h.ownership.primary_author})
+            for h in collect_hotspots(root, include, exclude, metric, days)
+        ]
+    else:
+        entries = [(m, getattr(m, metric), {}) for m in collect_metrics(root, include, exclude)]
+    return build_treemap(root.resolve().name, entries)
+
+
+def treemap_to_json(tree: TreemapNode) -> str:
+    return json.dumps(tree.to_dict(), indent=2)
+
+
+_STYLE = (
+    "body{font:14px/1.4 system-ui,sans-serif;margin:0;color:#222;display:flex;flex-direction:column;height:100vh}"
+    "header{padding:.5rem 1rem;display:flex;gap:1.5rem;align-items:center;flex-wrap:wrap;border-bottom:1px solid #ddd}"
+    "h1{font-size:16px;margin:0}#crumbs a{color:#0550ae;cursor:pointer}#crumbs a:hover{text-decoration:underline}"
+    "#legend{display:flex;align-items:center;gap:.4rem;font-size:12px}"
+    "#scale{width:10rem;height:.7rem;background:linear-gradient(90deg,hsl(120,60%,55%),hsl(60,60%,55%),hsl(0,60%,55%))}"
+    "#map{position:relative;flex:1;margin:.5rem;overflow:hidden}"
+    ".cell{position:absolute;box-sizing:border-box;border:1px solid #fff;overflow:hidden;font-size:11px;cursor:pointer}"
+    ".group{background:#eee;border-color:#bbb}.group>.label{font-weight:600;background:#ddd}"
+    ".label{padding:0 3px;white-space:nowrap;overflow:hidden;text-overflow:ellipsis;height:16px}"
+)
+
+_SCRIPT = r"""
+const tree = JSON.parse(document.getElementById("treemap-data").textContent);
+const config = JSON.parse(document.getElementById("treemap-config").textContent);
+const map = document.getElementById("map"), crumbs = document.getElementById("crumbs");
+const select = document.getElementById("color"), high = document.getElementById("high");
+const HEADER = 16, MIN_SIDE = 24;
+let key = config.color, stack = [tree];
+
+(function link(node, parent) {
+  node.parent = parent;
+  (node.children || []).forEach(child => link(child, node));
+})(tree, null);
+
+function leaves(node) {
+  return node.children ? node.children.flatMap(leaves) : [node];
+}
+function valueOf(node) {
+  if (!node.children) return Number((node.details || {})[key]) || 0;
+  if (node.cache === undefined || node.cacheKey !== key) {
+    node.cache = Math.max(0, ...node.children.map(valueOf));
+    node.cacheKey = key;
+  }
+  return node.cache;
+}
+function domain() {
+  return config.red_at[key] || Math.max(1, ...leaves(tree).map(valueOf));
+}
+function colorOf(node, top) {
+  const t = Math.min(valueOf(node) / top, 1);
+  return `hsl(${Math.round(120 * (1 - t))},60%,${node.children ? 88 : 55}%)`;
+}
+
+// Squarified treemap (Bruls, Huizing, van Wijk): rows whose rectangles stay close to square.
+function worst(row, side, scale) {
+  const areas = row.map(n => n.size * scale), sum = areas.reduce((a, b) => a + b, 0);
+  return Math.max(side * side * Math.max(...areas) / (sum * sum), sum * sum / (side * side * Math.min(...areas)));
+}
+function place(row, rect, scale, out) {
+  const sum = row.reduce((s, n) => s + n.size * scale, 0);
+  let [x, y, w, h] = rect;
+  if (w >= h) {
+    const width = sum / h;
+    for (const n of row) { const nh = n.size * scale / width; out.push([n, x, y, width, nh]); y += nh; }
+    return [rect[0] + width, rect[1], w - width, h];
+  }
+  const height = sum / w;
+  for (const n of row) { const nw = n.size * scale / height; out.push([n, x, y, nw, height]); x += nw; }
+  return [rect[0], rect[1] + height, w, h - height];
+}
+function squarify(nodes, rect) {
+  const total = nodes.reduce((s, n) => s + n.size, 0), out = [];
+  if (!total || rect[2] <= 0 || rect[3] <= 0) return out;
+  const scale = rect[2] * rect[3] / total;
+  let row = [];
+  for (const node of nodes) {
+    const side = Math.min(rect[2], rect[3]);
+    if (row.length && worst(row.concat([node]), side, scale) > worst(row, side, scale)) {
+      rect = place(row, rect, scale, out);
+      row = [];
+    }
+    row.push(node);
+  }
+  if (row.length) place(row, rect, scale, out);
+  return out;
+}
+
+function describe(node) {
+  const where = node.line ? `${node.path}:${node.line}` : node.path || node.name;
+  const details = Object.entries(node.details || {}).filter(([, v]) => v !== null).map(([k, v]) => `${k}: ${v}`);
+  return [`${node.name} (${node.kind})`, where, `${node.size} source lines`, `${key}: ${valueOf(node)}`, ...details]
+    .join("\n");
+}
+function draw(node, x, y, w, h, top, into) {
+  const cell = document.createElement("div");
+  cell.className = node.children ? "cell group" : "cell";
+  Object.assign(cell.style, {left: `${x}px`, top: `${y}px`, width: `${w}px`, height: `${h}px`});
+  cell.style.background = colorOf(node, top);
+  cell.title = describe(node);
+  const label = document.createElement("div");
+  label.className = "label";
+  label.textContent = node.name;
+  cell.appendChild(label);
+  cell.addEventListener("click", event => {
+    event.stopPropagation();
+    const target = node.children ? node : node.parent;
+    if (target && target !== stack[stack.length - 1]) { stack.push(target); render(); }
+  });
+  into.appendChild(cell);
+  if (node.children && w > MIN_SIDE && h > MIN_SIDE + HEADER) {
+    for (const [child, cx, cy, cw, ch] of squarify(node.children, [1, HEADER, w - 2, h - HEADER - 1])) {
+      draw(child, cx, cy, cw, ch, top, cell);
+    }
+  }
+}
+function render() {
+  const current = stack[stack.length - 1], top = domain();
+  map.replaceChildren();
+  crumbs.replaceChildren();
+  stack.forEach((node, i) => {
+    const a = document.createElement(i < stack.length - 1 ? "a" : "span");
+    a.textContent = node.name;
+    if (i < stack.length - 1) a.addEventListener("click", () => { stack = stack.slice(0, i + 1); render(); });
+    crumbs.append(...(i ? [" / ", a] : [a]));
+  });
+  high.textContent = config.red_at[key] ? `${top}+` : String(top);
+  const rect = [0, 0, map.clientWidth, map.clientHeight];
+  for (const [child, x, y, w, h] of squarify(current.children || [current], rect)) draw(child, x, y, w, h, top, map);
+}
+
+const keys = Object.keys(leaves(tree)[0]?.details || {}).filter(k => typeof leaves(tree)[0].details[k] === "number");
+for (const name of keys) {
+  const option = document.createElement("option");
+  option.value = option.textContent = name;
+  option.selected = name === key;
+  select.appendChild(option);
+}
+select.addEventListener("change", () => { key = select.value; render(); });
+window.addEventListener("resize", render);
+render();
+"""
+
+
+def _json_script(element_id: str, data) -> str:
+    text = json.dumps(data, separators=(",", ":")).replace("<", "\\u003c")  # no `</script>` inside
+    return f'<script type="application/json" id="{element_id}">{text}</script>'
+
+
+def treemap_to_html(tree: TreemapNode, color: str = "complexity", metric: str = "cyclomatic",
+                    title: Optional[str] = None) -> str:
+    """A single HTML page: zoom by clicking a directory or file, hover for details, recolor by any metric."""
+    title = title or f"{tree.name} code health"
+    functions = sum(1 for _ in tree.functions())
+    body = [
+        "<header>",
+        f"<h1>{html.escape(title)}</h1>",
+        '<div id="crumbs"></div>',
+        f"<div>{functions} functions, {tree.size} source lines; click to zoom</div>",
+        '<label>Color by <select id="color"></select></label>',
+        '<div id="legend"><span>0</span><div id="scale"></div><span id="high"></span></div>',
+        "</header>",
+        '<div id="map"></div>',
+        _json_script("treemap-data", tree.to_dict()),
+        _json_script("treemap-config", {"color": "churn" if color == "churn" else metric, "red_at": RED_AT}),
+        f"<script>{_SCRIPT}</script>",
+    ]
+    head = [
+        "<!DOCTYPE html>", '<html lang="en">', "<head>", '<meta charset="utf-8">',
+        f"<title>{html.escape(title)}</title>", f"<style>{_STYLE}</style>", "</head>", "<body>",
+    ]
+    return "\n".join([*head, *body, "</body>", "</html>"]) + "\n"
+
+
+__all__ = [
+    "COLORS",
+    "RED_AT",
+    "TreemapNode",
+    "build_treemap",
+    "collect_treemap",
+    "treemap_to_html",
+    "treemap_to_json",
+]
@@ -0,0 +1,347 @@
+"""Dead-code detection: definitions that nothing in the tree refers to."""
+
+from __future__ import annotations
+
+import fnmatch
+import json
+from dataclasses import dataclass
+from pathlib import Path
+from typing import Dict, Iterable, Iterator, List, Optional, Sequence, Set, Tuple
+
+from tree_sitter import Node
+
+from . import failures
+from .core import (
+    ParsedFile,
+    class_kind,
+    iter_class_nodes,
+    iter_function_nodes,
+    iter_source_files,
+    parse_file,
+)
+from .export.sarif import SarifLocation, SarifResult, SarifRule, sarif_to_json
+
+# Leaf nodes that can name a definition at a use site.
+REFERENCE_NODE_TYPES = {
+    "identifier",
+    "type_identifier",
+    "field_identifier",
+    "property_identifier",
+    "shorthand_property_identifier",
+    "shorthand_property_identifier_pattern",
+    "constant",
+}
+
+# Names that are called by runtimes, test runners, or protocols rather than by code.
+DEFAULT_ENTRY_POINTS = (
+    "main",
+    "init",
+    "__*__",
+    "test_*",
+    "Test*",
+    "Benchmark*",
+    "Example*",
+    "Fuzz*",
+    "setUp",
+    "tearDown",
+    "setUpClass",
+    "tearDownClass",
+    "constructor",
+)
+
+SARIF_RULES = {
+    "unexported": SarifRule(
+        "unused/unexported", "UnusedDefinition", "Unexported definition is never used",
+        "Nothing in the analysed tree refers to this function, type, or constant and it is not exported: "
+        "it is most likely dead code.",
+        tags=("maintainability", "dead-code"),
+    ),
+    "exported": SarifRule(
+        "unused/exported", "UnreferencedExport", "Exported definition is not referenced in the tree",
+        "No code in the analysed tree refers to this export. It may still be used by other packages, "
+        "so check the public API before removing it.",
+        "note", ("maintainability", "dead-code"),
+    ),
+}
+
+_JS_LANGUAGES = {"javascript", "typescript", "tsx"}
+_FUNCTION_VALUES = {"arrow_function", "function", "function_expression", "generator_function"}
+
+
+@dataclass
+class Definition:
+    kind: str
+    name: str
+    qualified_name: str
+    path: str
+    line: int
+    exported: bool
+    name_node: Optional[Node]
+    node: Node
+
+    @property
+    def package(self) -> str:
+        return self.path.rpartition("/")[0] or "."
+
+    def to_dict(self) -> dict:
+        return {
+            "kind": self.kind,
+            "name": self.qualified_name,
+            "path": self.path,
+            "line": self.line,
+            "exported": self.exported,
+        }
+
+
+def _is_exported(node: Node, name: str, language: str) -> bool:
+    if language == "go":
+        return name[:1].isupper()
+    if language == "rust":
+        return any(child.type == "visibility_modifier" for child in node.children)
+    if language in _JS_LANGUAGES:
+        for child in node.children:
+            if child.type == "accessibility_modifier" and child.text in {b"private", b"protected"}:
+                return False
+        if name.startswith("#"):
+            return False
+        parent = node.parent
+        while parent is not None:
+            if parent.type == "export_statement":
+                return True
+            parent = parent.parent
+        return False
+    return not name.startswith("_")
+
+
+def _top_level(node: Node) -> bool:
+    parent = node.parent
+    while parent is not None and parent.type in {"export_statement", "expression_statement", "lexical_declaration"}:
+        parent = parent.parent
+    return parent is not None and parent.parent is None
+
+
+def _constants(parsed: ParsedFile) -> Iterator[Tuple[str, Node, Node]]:
+    """(kind, declaration node, name node) for constants and non-struct type aliases."""
+    language = parsed.language
+    stack = [parsed.root]
+    while stack:
+        node = stack.pop()
+        stack.extend(reversed(node.children))
+        if language == "go":
+            if node.type == "const_spec":
+                for child in node.children_by_field_name("name"):
+                    yield "constant", node, child
+            elif node.type == "type_spec":
+                type_node = node.child_by_field_name("type")
+                name_node = node.child_by_field_name("name")
+                if name_node is not None and (type_node is None or type_node.type not in {"struct_type", "interface_type"}):
+                    yield "type", node, name_node
+        elif language == "rust":
+            if node.type in {"const_item", "static_item", "type_item"}:
+                name_node = node.child_by_field_name("name")
+                if name_node is not None:
+                    yield ("type" if node.type == "type_item" else "constant"), node, name_node
+        elif language == "python":
+            if node.type == "assignment" and _top_level(node):
+                left = node.child_by_field_name("left")
+                if left is not None and left.type == "identifier":
+                    name = parsed.text(left)
+                    if name.isupper() and name != "__all__":
+                        yield "constant", node, left
+        elif language in _JS_LANGUAGES:
+            if node.type == "lexical_declaration" and node.child_count and node.children[0].type == "const" \
+                    and _top_level(node):
+                for declarator in node.named_children:
+                    if declarator.type != "variable_declarator":
+                        continue
+                    name_node = declarator.child_by_field_name("name")
+                    value = declarator.child_by_field_name("value")
+                    if name_node is None or name_node.type != "identifier":
+                        continue
+                    if value is not None and value.type in _FUNCTION_VALUES:
+                        continue  # reported as a function
+                    yield "constant", node, name_node
+            elif node.type == "type_alias_declaration":
+                name_node = node.child_by_field_name("name")
+                if name_node is not None:
+                    yield "type", node, name_node
+
+
+def file_definitions(parsed: ParsedFile, label: str) -> List[Definition]:
+    """Functions, methods, types, and constants declared in `parsed`."""
+    language = parsed.language
+    found: List[Definition] = []
+    for cls in iter_class_nodes(parsed):
+        name_node = cls.node.child_by_field_name("name")
+        found.append(Definition(
+            class_kind(cls.node), cls.name, cls.qualified_name, label, cls.node.start_point[0] + 1,
+            _is_exported(cls.node, cls.name, language), name_node, cls.node,
+        ))
+    for fn in iter_function_nodes(parsed):
+        if fn.anonymous:
+            continue
+        parent = fn.node.parent
+        if parent is not None and parent.type == "decorated_definition":
+            # Decorators usually register the function somewhere (routes, CLI commands, fixtures).
+            continue
+        name_node = fn.node.child_by_field_name("name")
+        if name_node is None and parent is not None and parent.type == "variable_declarator":
+            name_node = parent.child_by_field_name("name")
+        decl = fn.node
+        if language in _JS_LANGUAGES and fn.node.type == "arrow_function" and parent is not None:
+            decl = parent.parent or parent
+        found.append(Definition(
+            "method" if fn.container else "function", fn.name, fn.qualified_name, label,
+            fn.node.start_point[0] + 1, _is_exported(decl, fn.name, language), name_node, fn.node,
+        ))
+    for kind, node, name_node in _constants(parsed):
+        name = parsed.text(name_node)
+        found.append(Definition(
+            kind, name, name, label, node.start_point[0] + 1, _is_exported(node, name, language), name_node, node,
+        ))
+    return found
+
+
+def file_references(parsed: ParsedFile) -> Iterator[Tuple[str, int]]:
+    """(name, start_byte) for every identifier-like leaf in `parsed`."""
+    stack = [parsed.root]
+    while stack:
+        node = stack.pop()
+        if node.type in REFERENCE_NODE_TYPES:
+            yield parsed.text(node), node.start_byte
+        elif node.type == "string" and parsed.language == "python" and node.parent is not None \
+                and node.parent.type == "list":
+            # `__all__ = ["name", ...]` and similar registries keep names alive.
+            yield parsed.text(node).strip("\"'"), node.start_byte
+        stack.extend(reversed(node.children))
+
+
+def load_allowlist(path: Path) -> List[str]:
+    """Read allowlist patterns: one per line, `#` starts a comment."""
+    patterns = []
+    for line in Path(path).read_text(encoding="utf-8").splitlines():
+        line = line.split("#", 1)[0].strip()
+        if line:
+            patterns.append(line)
+    return patterns
+
+
+def _allowed(defn: Definition, patterns: Sequence[str]) -> bool:
+    candidates = (defn.name, defn.qualified_name, f"{defn.path}:{defn.qualified_name}")
+    return any(fnmatch.fnmatchcase(c, p) for p in patterns for c in candidates)
+
+
+def find_unused(
+    root: Path,
+    include: Sequence[str] | None = None,
+    exclude: Sequence[str] | None = None,
+    allow: Iterable[str] = (),
+    use_default_entry_points: bool = True,
+) -> List[Definition]:
+    """
+    Definitions under `root` with no reference outside their own body.
+
+    Exported names count as used if referenced anywhere in the tree; unexported
+    ones only if referenced within their package (directory). Methods count as used
+    when any call or identifier shares their name, since dispatch through
+    interfaces and overrides can't be resolved syntactically.
+    """
+    root = Path(root)
+    parsed_files: List[Tuple[ParsedFile, str]] = []
+    if root.is_file():
+        parsed_files.append((parse_file(root), root.name))
+    else:
+        base = root.resolve()
+        for path in iter_source_files(base, include, exclude):
+            try:
+                parsed_files.append((parse_file(path), path.relative_to(base).as_posix()))
+            except (ValueError, RuntimeError, OSError) as exc:
+                failures.record(path, exc)
+                continue
+
+    definitions: List[Definition] = []
+    refs: Dict[str, List[Tuple[str, int]]] = {}
+    for parsed, label in parsed_files:
+        defs = file_definitions(parsed, label)
+        definitions.extend(defs)
+        own_names: Set[int] = {d.name_node.start_byte for d in defs if d.name_node is not None}
+        for name, start in file_references(parsed):
+            if start in own_names:
+                continue
+            refs.setdefault(name, []).append((label, start))
+
+    patterns = list(allow)
+    if use_default_entry_points:
+        patterns.extend(DEFAULT_ENTRY_POINTS)
+
+    unused = []
+    for defn in definitions:
+        if _allowed(defn, patterns):
+            continue
+        package = defn.package
+        used = False
+        for path, start in refs.get(defn.name, ()):
+            if path == defn.path and defn.node.start_byte <= start < defn.node.end_byte:
+                continue  # recursion or self-reference
+            if defn.exported or defn.kind == "method" or (path.rpartition("/")[0] or ".") == package:
+                used = True
+                break
+        if not used:
+            unused.append(defn)
+    unused.sort(key=lambda d: (d.package, d.path, d.line, d.qualified_name))
+    return unused
+
+
+def group_by_package(unused: Sequence[Definition]) -> List[dict]:
+    """Per-package report splitting exported-but-unreferenced from unexported-and-unused."""
+    packages: Dict[str, dict] = {}
+    for defn in unused:
+        entry = packages.setdefault(
+            defn.package, {"package": defn.package, "exported_unreferenced": [], "unexported_unused": []}
+        )
+        key = "exported_unreferenced" if defn.exported else "unexported_unused"
+        entry[key].append(defn.to_dict())
+    return [packages[name] for name in sorted(packages)]
+
+
+def unused_to_json(unused: Sequence[Definition]) -> str:
+    return json.dumps(group_by_package(unused), indent=2)
+
+
+def unused_to_sarif(unused: Sequence[Definition], root: Optional[Path] = None) -> str:
+    """Unexported dead code as warnings and unreferenced exports as notes."""
+    results = []
+    for defn in unused:
+        column = defn.name_node.start_point[1] + 1 if defn.name_node is not None else None
+        results.append(SarifResult(
+            SARIF_RULES["exported" if defn.exported else "unexported"],
+            f"{defn.kind.capitalize()} '{defn.qualified_name}' is never referenced",
+            SarifLocation(defn.path, defn.line, column),
+            (defn.kind, defn.qualified_name),
+        ))
+    return sarif_to_json(results, list(SARIF_RULES.values()), root)
+
+
+def unused_to_text(unused: Sequence[Definition]) -> str:
+    lines = []
+    for package in group_by_package(unused):
+        lines.append(f"{package['package']}:")
+        for key, label in (("exported_unreferenced", "exported"), ("unexported_unused", "unexported")):
+            for item in package[key]:
+                lines.append(f"  {item['path']}:{item['line']}: {label} {item['kind']} {item['name']}")
+    return "\n".join(lines) + ("\n" if lines else "")
+
+
+__all__ = [
+    "DEFAULT_ENTRY_POINTS",
+    "SARIF_RULES",
+    "Definition",
+    "file_definitions",
+    "file_references",
+    "find_unused",
+    "group_by_package",
+    "load_allowlist",
+    "unused_to_json",
+    "unused_to_sarif",
+    "unused_to_text",
+]
@@ -0,0 +1,202 @@
+"""Watch a directory and stream symbol-level changes as they happen."""
+
+from __future__ import annotations
+
+import queue
+import time
+from pathlib import Path
+from typing import Callable, Dict, Iterable, List, Optional, Sequence
+
+from . import ignore
+from .core import CodeSymbol, _match_any, detect_language, iter_source_files
+from .incremental import IncrementalSession
+
+Event = dict
+EventSink = Callable[[Event], None]
+
+
+def _symbol_keys(symbols: Iterable[CodeSymbol]) -> Dict[str, dict]:
+    """Key symbols by kind + name, numbering repeats so overloads/chunks stay distinct."""
+    keyed: Dict[str, dict] = {}
+    seen: Dict[str, int] = {}
+    for sym in symbols:
+        base = f"{sym.kind}:{sym.name}"
+        count = seen.get(base, 0)
+        seen[base] = count + 1
+        keyed[base if count == 0 else f"{base}#{count}"] = sym.to_dict()
+    return keyed
+
+
+def diff_symbols(path: str, old: Dict[str, dict], new: Dict[str, dict]) -> List[Event]:
+    """Compute added/removed/changed events between two keyed symbol maps."""
+    events: List[Event] = []
+    for key in sorted(old.keys() - new.keys()):
+        events.append({"event": "removed", "path": path, "symbol": old[key]})
+    for key in sorted(new.keys() - old.keys()):
+        events.append({"event": "added", "path": path, "symbol": new[key]})
+    for key in sorted(old.keys() & new.keys()):
+        if old[key] != new[key]:
+            fields = sorted(f for f in new[key] if old[key].get(f) != new[key].get(f))
+            events.append({"event": "changed", "path": path, "symbol": new[key], "fields": fields})
+    return events
+
+
+def _mtime(path: Path) -> Optional[float]:
+    """`path`'s modification time, or None once it has vanished (deleted between listing and stat)."""
+    try:
+        return path.stat().st_mtime
+    except OSError:
+        return None
+
+
+class SymbolWatcher:
+    """Tracks per-file symbols under `root` and reports what changed on refresh."""
+
+    def __init__(
+        self,
+        root: Path,
+        include: Sequence[str] | None = None,
+        exclude: Sequence[str] | None = None,
+        session: Optional[IncrementalSession] = None,
+    ):
+        self.root = Path(root).resolve()
+        self.include = list(include or ["**/*"])
+        self.exclude = list(exclude or [])
+        self.session = session or IncrementalSession()
+        self._symbols: Dict[Path, Dict[str, dict]] = {}
+        self._mtimes: Dict[Path, float] = {}
+
+    def _label(self, path: Path) -> str:
+        return path.relative_to(self.root).as_posix()
+
+    def matches(self, path: Path) -> bool:
+        try:
+            rel = Path(path).resolve().relative_to(self.root).as_posix()
+        except ValueError:
+            return False
+        if not _match_any(self.include, rel):
+            return False
+        return not (self.exclude and _match_any(self.exclude, rel))
+
+    def _extract(self, path: Path) -> Optional[Dict[str, dict]]:
+        if not detect_language(path):
+            return None
+        try:
+            return _symbol_keys(self.session.extract(path))
+        except (ValueError, RuntimeError, OSError):
+            return None
+
+    def start(self) -> List[Event]:
+        """Index every matching file; returns `added` events for the initial state."""
+        events: List[Event] = []
+        for path in iter_source_files(self.root, self.include, self.exclude):
+            mtime = _mtime(path)
+            if mtime is None:
+                continue
+            self._mtimes[path] = mtime
+            symbols = self._extract(path)
+            if symbols is None:
+                continue
+            self._symbols[path] = symbols
+            events.extend(diff_symbols(self._label(path), {}, symbols))
+        return events
+
+    def refresh(self, paths: Iterable[Path]) -> List[Event]:
+        """Re-extract `paths` (created, modified, or deleted) and diff against the last state."""
+        events: List[Event] = []
+        # Re-read each time so edits to ignore files take effect without a restart.
+        rules = ignore.IgnoreRules(self.root) if ignore.RESPECT_IGNORES else None
+        for path in sorted({Path(p).resolve() for p in paths}):
+            if not self.matches(path) or (rules is not None and rules.is_ignored(path)):
+                continue
+            old = self._symbols.get(path, {})
+            mtime = _mtime(path) if path.is_file() else None
+            if mtime is not None:
+                self._mtimes[path] = mtime
+                new = self._extract(path)
+                if new is None:
+                    continue
+                self._symbols[path] = new
+            else:
+                new = {}
+                self._symbols.pop(path, None)
+                self._mtimes.pop(path, None)
+                self.session.forget(path)
+            events.extend(diff_symbols(self._label(path), old, new))
+        return events
+
+    def changed_since_last_poll(self) -> List[Path]:
+        """Stat-based change detection used when no native notifier is available."""
+        current = {p: _mtime(p) for p in iter_source_files(self.root, self.include, self.exclude)}
+        current = {p: mtime for p, mtime in current.items() if mtime is not None}  # vanished count as removed
+        changed = [p for p, mtime in current.items() if self._mtimes.get(p) != mtime]
+        changed.extend(p for p in self._mtimes if p not in current)
+        return changed
+
+    def poll(self) -> List[Event]:
+        return self.refresh(self.changed_since_last_poll())
+
+
+def _native_observer(root: Path, changes: "queue.Queue[Path]"):
+    """Start a watchdog observer if the optional dependency is installed."""
+    try:
+        from watchdog.events import FileSystemEventHandler
+        from watchdog.observers import Observer
+    except ImportError:
+        return None
+
+    class _Handler(FileSystemEventHandler):
+        def on_any_event(self, event):  # pragma: no cover - exercised with watchdog only
+            if event.is_directory:
+                return
+            changes.put(Path(event.src_path))
+            dest = getattr(event, "dest_path", None)
+            if dest:
+                changes.put(Path(dest))
+
+    observer = Observer()
+    observer.schedule(_Handler(), str(root), recursive=True)
+    observer.start()
+    return observer
+
+
+def watch(
+    watcher: SymbolWatcher,
+    sink: EventSink,
+    interval: float = 0.5,
+    initial: bool = True,
+    use_native: bool = True,
+    stop: Optional[Callable[[], bool]] = None,
+) -> None:
+    """
+    Emit symbol events to `sink` until `stop()` returns True (or forever).
+
+    Filesystem notifications come from `watchdog` when available; otherwise the
+    tree is re-stat'ed every `interval` seconds. Notifications are batched per
+    interval so editors that write files in several steps produce one update.
+    """
+    events = watcher.start()
+    if initial:
+        for event in events:
+            sink(event)
+    changes: "queue.Queue[Path]" = queue.Queue()
+    observer = _native_observer(watcher.root, changes) if use_native else None
+    try:
+        while not (stop and stop()):
+            time.sleep(interval)
+            if observer is None:
+                events = watcher.poll()
+            else:
+                batch = []
+                while not changes.empty():
+                    batch.append(changes.get_nowait())
+                events = watcher.refresh(batch)
+            for event in events:
+                sink(event)
+    finally:
+        if observer is not None:
+            observer.stop()
+            observer.join()
+
+
+__all__ = ["SymbolWatcher", "diff_symbols", "watch"]
@@ -0,0 +1,246 @@
+"""
+Multi-root workspaces: several project roots scanned or indexed as one tree.
+
+A workspace is a list of roots, either given on the command line or read from a
+`.treesitter-tools-workspace.yaml` file:
+
+    roots:
+      - services/api                      # just a path
+      - path: web
+        include: ["src/**"]
+        exclude: ["**/*.test.ts"]
+        languages: {mjs: javascript}
+      - path: ../shared/proto
+        name: proto                       # label in merged output (default: the path)
+
+Each root's settings start from its own `.treesitter-tools.yaml` (when it has one) and
+the workspace entry overrides them key by key. Merged output labels every file as
+`<name>/<path under the root>`, where the name defaults to the root's path relative to
+the workspace file (or the current directory), so labels still resolve as paths in a
+monorepo.
+"""
+
+from __future__ import annotations
+
+import json
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Any, Dict, Iterator, List, Optional, Sequence
+
+import yaml
+
+from .config import CONFIG_NAMES, ConfigError, language_overrides, load_config
+from .core import FileSymbols, iter_scan_directory
+
+WORKSPACE_NAMES = (".treesitter-tools-workspace.yaml", ".treesitter-tools-workspace.yml")
+ROOT_KEYS = {"path", "name", "include", "exclude", "languages"}
+
+
+@dataclass
+class WorkspaceRoot:
+    name: str
+    path: Path  # absolute
+    include: Optional[List[str]] = None
+    exclude: Optional[List[str]] = None
+    languages: Dict[str, str] = field(default_factory=dict)
+
+    def label(self, path: Path) -> str:
+        """`path` (under this root) as it appears in merged output."""
+        rel = Path(path).resolve().relative_to(self.path).as_posix()
+        return rel if self.name == "." else f"{self.name}/{rel}"
+
+    def to_dict(self) -> dict:
+        return {
+            "name": self.name,
+            "path": self.path.as_posix(),
+            "include": self.include,
+            "exclude": self.exclude,
+            "languages": dict(sorted(self.languages.items())),
+        }
+
+
+@dataclass
+class Workspace:
+    base: Path  # directory labels are relative to
+    roots: List[WorkspaceRoot] = field(default_factory=list)
+    path: Optional[Path] = None  # the workspace file, if one was read
+
+    def to_dict(self) -> dict:
+        return {
+            "path": self.path.as_posix() if self.path else None,
+            "base": self.base.as_posix(),
+            "roots": [root.to_dict() for root in self.roots],
+        }
+
+    def to_json(self) -> str:
+        return json.dumps(self.to_dict(), indent=2)
+
+
+def find_workspace(start: Path) -> Optional[Path]:
+    """Nearest workspace file in `start` or one of its parents."""
+    start = Path(start).resolve()
+    for directory in (start, *start.parents):
+        for name in WORKSPACE_NAMES:
+            candidate = directory / name
+            if candidate.is_file():
+                return candidate
+    return None
+
+
+def _name_for(path: Path, base: Path) -> str:
+    try:
+        return path.relative_to(base).as_posix()
+    except ValueError:
+        return path.name
+
+
+def _string_list(value: Any, key: str, problems: List[str]) -> Optional[List[str]]:
+    if isinstance(value, str):
+        value = [value]
+    if not isinstance(value, list) or not all(isinstance(v, str) and v for v in value):
+        problems.append(f"'{key}' must be a glob or a list of globs")
+        return None
+    return value
+
+
+def _make_root(path: Path, base: Path, entry: Dict[str, Any], context: str, problems: List[str]) -> Optional[WorkspaceRoot]:
+    path = path.resolve()
+    if not path.is_dir():
+        problems.append(f"'{context}': not a directory: {path}")
+        return None
+    root = WorkspaceRoot(str(entry.get("name") or _name_for(path, base)), path)
+    for name in CONFIG_NAMES:
+        if (path / name).is_file():
+            try:
+                config = load_config(path / name)
+            except (ConfigError, OSError) as exc:
+                problems.append(f"'{context}': {exc}")
+                return None
+            root.include, root.exclude = config.include, config.exclude
+            root.languages = dict(config.languages)
+            break
+    for key in ("include", "exclude"):
+        if key in entry:
+            setattr(root, key, _string_list(entry[key], f"{context}.{key}", problems))
+    languages = entry.get("languages", {})
+    if not isinstance(languages, dict) or not all(isinstance(v, str) and v for v in languages.values()):
+        problems.append(f"'{context}.languages' must map file extensions to language names")
+    else:
+        root.languages.update({str(ext): language for ext, language in languages.items()})
+    return root
+
+
+def _check_names(roots: Sequence[WorkspaceRoot], problems: List[str]) -> None:
+    seen: Dict[str, Path] = {}
+    for root in roots:
+        if root.name in seen:
+            problems.append(f"roots {seen[root.name]} and {root.path} share the name '{root.name}'; set 'name' on one")
+        seen[root.name] = root.path
+
+
+def parse_workspace(data: Any, path: Path) -> Workspace:
+    """Validate raw workspace data read from `path`; relative root paths resolve against its directory."""
+    base = path.resolve().parent
+    workspace = Workspace(base=base, path=path)
+    problems: List[str] = []
+    if not isinstance(data, dict) or not isinstance(data.get("roots"), list) or not data["roots"]:
+        raise ConfigError(path, ["expected a mapping with a non-empty 'roots' list"])
+    for key in sorted(set(data) - {"roots"}):
+        problems.append(f"unknown key '{key}' (expected roots)")
+    for i, entry in enumerate(data["roots"]):
+        context = f"roots[{i}]"
+        if isinstance(entry, str):
+            entry = {"path": entry}
+        if not isinstance(entry, dict) or not isinstance(entry.get("path"), str):
+            problems.append(f"'{context}' must be a path or a mapping with 'path'")
+            continue
+        for key in sorted(set(entry) - ROOT_KEYS):
+            problems.append(f"unknown key '{context}.{key}' (expected one of {', '.join(sorted(ROOT_KEYS))})")
+        root = _make_root(base / entry["path"], base, entry, context, problems)
+        if root is not None:
+            workspace.roots.append(root)
+    _check_names(workspace.roots, problems)
+    if problems:
+        raise ConfigError(path, problems)
+    return workspace
+
+
+def load_workspace(path: Path) -> Workspace:
+    path = Path(path)
+    try:
+        data = yaml.safe_load(path.read_text(encoding="utf-8"))
+    except yaml.YAMLError as exc:
+        raise ConfigError(path, [f"invalid YAML: {exc}"]) from exc
+    return parse_workspace(data, path)
+
+
+def workspace_from_paths(paths: Sequence[Path], base: Optional[Path] = None) -> Workspace:
+    """A workspace of `paths`, each with the settings of its own config file; names are relative to `base` (the cwd)."""
+    base = Path(base or Path.cwd()).resolve()
+    workspace = Workspace(base=base)
+    problems: List[str] = []
+    for path in paths:
+        root = _make_root(Path(path), base, {}, str(path), problems)
+        if root is not None:
+            workspace.roots.append(root)
+    _check_names(workspace.roots, problems)
+    if problems:
+        raise ValueError("; ".join(problems))
+    return workspace
+
+
+def scan_workspace(
+    workspace: Workspace,
+    max_chunk_size: Optional[int] = None,
+    max_file_size: Optional[int] = None,
+    include: Optional[Sequence[str]] = None,
+    exclude: Sequence[str] = (),
+) -> Iterator[FileSymbols]:
+    """
+    Reports for every root in turn, with paths relabelled `<name>/<path>` and `root` set.
+    `include` replaces each root's own include globs when given; `exclude` adds to its excludes.
+    """
+    for root in workspace.roots:
+        with language_overrides(root.languages):
+            reports = iter_scan_directory(
+                root.path, include or root.include, [*(root.exclude or []), *exclude], max_chunk_size,
+                max_file_size=max_file_size,
+            )
+            for report in reports:
+                report.path = Path(root.label(report.path))
+                report.root = root.name
+                yield report
+
+
+def index_workspace(index, workspace: Workspace):
+    """
+    Bring a `SymbolIndex` in line with every root of `workspace`, labelling files
+    `<name>/<path>`. Files of roots no longer in the workspace are dropped.
+    Returns the combined `IndexStats`.
+    """
+    from .index import IndexStats
+
+    total = IndexStats()
+    for root in workspace.roots:
+        with language_overrides(root.languages):
+            stats = index.update(root.path, root.include, root.exclude, prefix="" if root.name == "." else f"{root.name}/")
+        for key in ("added", "updated", "removed", "unchanged"):
+            setattr(total, key, getattr(total, key) + getattr(stats, key))
+    total.removed += index.retain_prefixes([r.name for r in workspace.roots])
+    index.set_roots(workspace.base, {root.name: root.path for root in workspace.roots})
+    return total
+
+
+__all__ = [
+    "ROOT_KEYS",
+    "WORKSPACE_NAMES",
+    "Workspace",
+    "WorkspaceRoot",
+    "find_workspace",
+    "index_workspace",
+    "language_overrides",
+    "load_workspace",
+    "parse_workspace",
+    "scan_workspace",
+    "workspace_from_paths",
+]
@@ -0,0 +1,57 @@
+"""Shared test helpers: `run_cli` runs the CLI in-process (import it with `from conftest import run_cli`)."""
+
+import os
+import traceback
+from dataclasses import dataclass
+from pathlib import Path
+from typing import Mapping, Optional, Sequence, Union
+
+from typer.testing import CliRunner
+
+from treesitter_tools import cli
+from treesitter_tools.parallel import WorkerSettings
+
+
+@dataclass
+class CliResult:
+    """What a `treesitter-tools` process would have produced, shaped like `subprocess.CompletedProcess`."""
+
+    returncode: int
+    stdout: Union[str, bytes]
+    stderr: Union[str, bytes]
+
+
+def run_cli(
+    args: Sequence,
+    cwd: Optional[Path] = None,
+    stdin: Union[str, bytes, None] = None,
+    env: Optional[Mapping[str, Optional[str]]] = None,
+    text: bool = True,
+) -> CliResult:
+    """
+    `treesitter-tools ARGS` run in `cwd`, reading `stdin`, with `env` overriding the
+    environment (None unsets a variable). Output is bytes unless `text`. The process-wide
+    settings the CLI sets (`--redact`, `--encoding`, `--overlay`, ...) are restored after
+    the run, so one test's flags never reach the next test.
+    """
+    settings = WorkerSettings.capture()
+    previous = Path.cwd()
+    try:
+        try:
+            argv = cli.command_line([str(arg) for arg in args])
+        except ValueError as e:
+            message = f"Error: {e}\n"
+            return CliResult(1, "" if text else b"", message if text else message.encode("utf-8"))
+        if cwd is not None:
+            os.chdir(cwd)
+        result = CliRunner().invoke(cli.app, argv, input=stdin, env=env)
+    finally:
+        os.chdir(previous)
+        settings.apply()
+    stderr = result.stderr_bytes or b""
+    if result.exception is not None and not isinstance(result.exception, SystemExit):
+        # What the interpreter would have printed for an uncaught exception.
+        stderr += "".join(traceback.format_exception(*result.exc_info)).encode("utf-8")
+    if text:
+        return CliResult(result.exit_code, result.stdout, stderr.decode("utf-8", "replace"))
+    return CliResult(result.exit_code, result.stdout_bytes, stderr)
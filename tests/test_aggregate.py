@@ -0,0 +1,60 @@
+"""Tests for summarizing query captures across files."""
+
+import json
+
+import pytest
+from conftest import run_cli
+
+from treesitter_tools.aggregate import aggregate_captures, check_group_by, groups_to_text
+
+
+def _match(*captures):
+    return {"pattern_index": 0, "captures": [{"name": name, "text": text} for name, text in captures]}
+
+
+RESULTS = [
+    ("api/handler.go", [_match(("pkg", "fmt"), ("fn", "Println")), _match(("pkg", "fmt"), ("fn", "Printf"))]),
+    ("api/routes.go", [_match(("pkg", "fmt"), ("fn", "Println"))]),
+    ("main.go", [_match(("pkg", "log"), ("fn", "Println"))]),
+]
+
+
+def test_aggregate_captures():
+    by_text = aggregate_captures(RESULTS, ["text"], captures=["fn"])
+    assert [g.to_dict() for g in by_text] == [
+        {"text": "Println", "count": 3, "distinct": 1, "files": 3},
+        {"text": "Printf", "count": 1, "distinct": 1, "files": 1},
+    ]
+    per_dir = aggregate_captures(RESULTS, ["dir", "capture"], sort="key")
+    assert [(g.key["dir"], g.key["capture"], g.count, len(g.texts)) for g in per_dir] == [
+        (".", "fn", 1, 1), (".", "pkg", 1, 1), ("api", "fn", 3, 2), ("api", "pkg", 3, 1),
+    ]
+    table = groups_to_text(aggregate_captures(RESULTS, ["file"], captures=["pkg"]), ["file"])
+    assert table.splitlines() == [
+        "count\tdistinct\tfiles\tfile", "2\t1\t1\tapi/handler.go", "1\t1\t1\tapi/routes.go", "1\t1\t1\tmain.go",
+    ]
+    with pytest.raises(ValueError, match="Unknown group key 'package'"):
+        check_group_by(["package"])
+    with pytest.raises(ValueError, match="given once"):
+        aggregate_captures(RESULTS, ["text", "text"])
+
+
+def test_cli_query_group_by(tmp_path):
+    (tmp_path / "pkg").mkdir()
+    (tmp_path / "pkg" / "a.py").write_text("import os\nos.getcwd()\nprint(os.sep)\n", encoding="utf-8")
+    (tmp_path / "b.py").write_text("print(1)\n", encoding="utf-8")
+    (tmp_path / "notes.txt").write_text("print(2)\n", encoding="utf-8")
+    calls = "(call function: (_) @callee)"
+    result = run_cli(["query", ".", calls, "--group-by", "text", "--format", "json"], cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    assert json.loads(result.stdout) == [
+        {"text": "print", "count": 2, "distinct": 1, "files": 2},
+        {"text": "os.getcwd", "count": 1, "distinct": 1, "files": 1},
+    ]
+
+    matches = run_cli(["query", ".", calls, "--include", "pkg/**"], cwd=tmp_path)
+    assert matches.returncode == 0, matches.stderr
+    assert {m["path"] for m in json.loads(matches.stdout)} == {"pkg/a.py"}
+
+    table = run_cli(["query", "pkg/a.py", calls, "--group-by", "dir", "--format", "text"], cwd=tmp_path)
+    assert table.stdout.splitlines()[1] == "2\t2\t1\tpkg"
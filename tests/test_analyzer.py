@@ -0,0 +1,106 @@
+"""Tests for the embeddable analyzer: concurrency and cancellation."""
+
+import threading
+import time
+from concurrent.futures import ThreadPoolExecutor
+from pathlib import Path
+
+import pytest
+
+from treesitter_tools import api
+from treesitter_tools.analyzer import Analyzer, AnalyzerOptions, Cancelled, Context, DeadlineExceeded
+from treesitter_tools.core import get_parser, scan_directory
+
+
+@pytest.fixture
+def tree(tmp_path):
+    for index in range(12):
+        package = tmp_path / f"pkg{index % 3}"
+        package.mkdir(exist_ok=True)
+        (package / f"mod{index}.py").write_text(
+            f"class Model{index}:\n    def run(self):\n        return {index}\n\n\ndef helper{index}():\n    pass\n",
+            encoding="utf-8",
+        )
+    (tmp_path / "main.go").write_text("package main\n\nfunc main() {}\n", encoding="utf-8")
+    return tmp_path
+
+
+def _dump(reports):
+    return [report.to_dict() for report in reports]
+
+
+def test_extract_dir_matches_scan_directory(tree):
+    expected = _dump(scan_directory(tree))
+    assert _dump(Analyzer().extract_dir(tree)) == expected
+    assert _dump(api.new_analyzer(jobs=4, max_in_flight=2).extract_dir(tree)) == expected
+    python_only = api.new_analyzer(AnalyzerOptions(include=["**/*.py"], exclude=["pkg0/**"]))
+    assert len(python_only.extract_dir(tree)) == 8
+    with pytest.raises(ValueError, match="not both"):
+        api.new_analyzer(AnalyzerOptions(), jobs=2)
+    with pytest.raises(ValueError, match="jobs"):
+        AnalyzerOptions(jobs=0)
+
+
+def test_concurrent_use(tree):
+    analyzer = api.new_analyzer(jobs=2)
+    expected = _dump(scan_directory(tree))
+    with ThreadPoolExecutor(max_workers=8) as pool:
+        results = list(pool.map(lambda _: _dump(analyzer.extract_dir(tree)), range(16)))
+    assert all(result == expected for result in results)
+    path = tree / "pkg1" / "mod1.py"
+    with ThreadPoolExecutor(max_workers=4) as pool:
+        names = list(pool.map(lambda _: [s.name for s in analyzer.extract_file(path)], range(32)))
+    assert names == [["Model1", "run", "helper1"]] * 32
+
+
+def test_parsers_are_per_thread():
+    parsers = []
+    thread = threading.Thread(target=lambda: parsers.append(get_parser("python")))
+    thread.start()
+    thread.join()
+    assert parsers[0] is not get_parser("python")
+    assert get_parser("python") is get_parser("python")
+
+
+def test_cancellation(tree):
+    analyzer = api.new_analyzer(jobs=3)
+    ctx = Context()
+    seen = []
+    with pytest.raises(Cancelled):
+        for report in analyzer.iter_extract_dir(tree, ctx):
+            seen.append(report)
+            ctx.cancel()
+    assert 1 <= len(seen) < 13
+
+    cancelled = Context()
+    cancelled.cancel()
+    with pytest.raises(Cancelled, match="cancelled"):
+        Analyzer().extract_dir(tree, cancelled)
+    child = Context(timeout=60, parent=cancelled)
+    with pytest.raises(Cancelled):
+        analyzer.query_file(tree / "main.go", "(function_declaration) @f", child)
+
+    expired = Context(timeout=0.01)
+    time.sleep(0.02)
+    assert expired.done and isinstance(expired.err(), TimeoutError)
+    with pytest.raises(DeadlineExceeded):
+        analyzer.extract_file(tree / "main.go", expired)
+    assert Context(parent=Context(timeout=5)).deadline is not None
+    assert analyzer.query_file(tree / "main.go", "(function_declaration) @f", Context(timeout=60))
+
+
+def test_failure_reports_are_per_thread(tree):
+    (tree / "pkg0" / "blob.py").write_bytes(b"x = 1\x00\x01\x02\n")
+    (tree / "pkg1" / "blob.py").write_bytes(b"y = 2\x00\x01\x02\n")
+    analyzer = api.new_analyzer(jobs=2)
+    barrier = threading.Barrier(2)
+
+    def collect(package):
+        with api.collect_failures() as report:
+            barrier.wait()
+            analyzer.extract_dir(tree / package)
+        return [f.path for f in report.failures]
+
+    with ThreadPoolExecutor(max_workers=2) as pool:
+        found = list(pool.map(collect, ["pkg0", "pkg1"]))
+    assert [[Path(p).parent.name for p in paths] for paths in found] == [["pkg0"], ["pkg1"]]
@@ -0,0 +1,91 @@
+"""Tests for API breaking-change detection between revisions."""
+
+import json
+import subprocess
+
+import pytest
+from conftest import run_cli
+
+from treesitter_tools.apidiff import EXIT_ADDITIVE, EXIT_BREAKING, compare_refs
+
+
+def _git(cwd, *args):
+    subprocess.run(["git", *args], cwd=cwd, check=True, capture_output=True)
+
+
+def _commit(repo, files, message):
+    for name, text in files.items():
+        path = repo / name
+        path.parent.mkdir(parents=True, exist_ok=True)
+        if text is None:
+            path.unlink()
+        else:
+            path.write_text(text, encoding="utf-8")
+    _git(repo, "add", "-A")
+    _git(repo, "commit", "-q", "-m", message)
+    _git(repo, "tag", message)
+
+
+@pytest.fixture
+def repo(tmp_path):
+    _git(tmp_path, "init", "-q", "-b", "main")
+    _git(tmp_path, "config", "user.email", "dev@example.com")
+    _git(tmp_path, "config", "user.name", "dev")
+    _commit(tmp_path, {
+        "lib/calc.py": "def add(a, b):\n    return a + b\n\n\ndef sub(a, b):\n    return a - b\n\n\n"
+                       "def _helper():\n    pass\n",
+        "lib/shapes.py": "class Square:\n    def area(self):\n        return 1\n",
+    }, "v1")
+    # Additive only: new function, private helper changed, method body edited.
+    _commit(tmp_path, {
+        "lib/calc.py": "def add(a, b):\n    return a + b\n\n\ndef sub(a, b):\n    return a - b\n\n\n"
+                       "def mul(a, b):\n    return a * b\n\n\ndef _helper(x):\n    pass\n",
+        "lib/shapes.py": "class Square:\n    def area(self):\n        return 2\n",
+    }, "v2")
+    # Breaking: sub removed, add gained a required parameter.
+    _commit(tmp_path, {
+        "lib/calc.py": "def add(a, b, c):\n    return a + b + c\n\n\ndef mul(a, b):\n    return a * b\n",
+    }, "v3")
+    return tmp_path
+
+
+def test_additive_changes_are_compatible(repo):
+    diff = compare_refs(repo, "v1", "v2")
+    assert [(c.change, c.module, c.name) for c in diff.changes] == [("added", "lib.calc", "mul")]
+    assert diff.exit_code() == 0
+    assert diff.exit_code(strict=True) == EXIT_ADDITIVE
+
+
+def test_removed_and_changed_signatures_are_breaking(repo):
+    diff = compare_refs(repo, "v2", "v3")
+    assert [(c.change, c.name, c.before, c.after) for c in diff.breaking] == [
+        ("changed", "add", "def add(a, b)", "def add(a, b, c)"),
+        ("removed", "sub", "def sub(a, b)", None),
+    ]
+    assert diff.exit_code() == EXIT_BREAKING
+    assert "v2 -> v3: 2 breaking, 0 additive" in diff.to_text()
+
+
+def test_working_tree_is_the_default_head(repo):
+    (repo / "lib" / "shapes.py").write_text("class Square:\n    pass\n", encoding="utf-8")
+    diff = compare_refs(repo / "lib", "v3")
+    assert diff.head == "working tree"
+    assert [(c.change, c.module, c.name) for c in diff.changes] == [("removed", "shapes", "Square.area")]
+
+
+def test_unknown_revision(repo):
+    with pytest.raises(ValueError, match="Unknown git revision: nope"):
+        compare_refs(repo, "nope")
+
+
+def test_cli_exit_codes(repo):
+    def run(*args):
+        return run_cli(["api-diff", *args, "--path", str(repo)])
+
+    assert run("v1", "v2").returncode == 0
+    assert run("v1", "v2", "--strict").returncode == 3
+    result = run("v1", "v3", "--format", "json")
+    assert result.returncode == 2
+    data = json.loads(result.stdout)
+    assert data["summary"] == {"breaking": 2, "additive": 1}
+    assert run("nope").returncode == 1
@@ -0,0 +1,144 @@
+"""Tests for the public API surface extractor."""
+
+import json
+
+from treesitter_tools.apisurface import api_to_json, api_to_text, collect_api, module_name
+
+PYTHON = '''MAX_SIZE = 10
+_LIMIT = 3
+
+
+def add(a: int,
+        b: int = 2) -> int:
+    def helper():
+        pass
+    return a + b
+
+
+def _private():
+    pass
+
+
+class Meter(Base):
+    """Doc."""
+
+    def __init__(self, scale):
+        self.scale = scale
+
+    @property
+    def value(self) -> float:
+        return 1.0
+
+    def _reset(self):
+        pass
+
+
+class _Hidden:
+    def visible(self):
+        pass
+'''
+
+GO = '''package server
+
+// Max is the limit.
+const Max = 10
+
+const internal = 1
+
+type Server struct {
+	Addr string
+	// port is private
+	port int
+	Handler
+}
+
+type impl struct{}
+
+func (s *Server) Start(ctx context.Context) error {
+	return nil
+}
+
+func (i *impl) Run() {}
+
+func New(addr string) *Server {
+	return &Server{Addr: addr}
+}
+'''
+
+TYPESCRIPT = '''export interface Shape {
+  // area in m2
+  area(): number;
+  name: string;
+}
+
+export const makeSquare = (side: number): Shape => {
+  return { area: () => side * side, name: "square" };
+};
+
+export function scale(shape: Shape, factor = 2): Shape {
+  return shape;
+}
+
+function internal() {}
+
+export const VERSION = "1.0";
+'''
+
+
+def _write(root, files):
+    for name, text in files.items():
+        path = root / name
+        path.parent.mkdir(parents=True, exist_ok=True)
+        path.write_text(text, encoding="utf-8")
+
+
+def test_module_names():
+    assert module_name("src/pkg/calc.py", "python") == "pkg.calc"
+    assert module_name("pkg/__init__.py", "python") == "pkg"
+    assert module_name("internal/server/server.go", "go") == "internal/server"
+    assert module_name("main.go", "go") == "."
+    assert module_name("src/lib/index.ts", "typescript") == "src/lib"
+
+
+def test_python_module_surface(tmp_path):
+    _write(tmp_path, {"pkg/calc.py": PYTHON, "tests/test_calc.py": "def test_add():\n    pass\n"})
+    assert api_to_text(collect_api(tmp_path)) == (
+        "module pkg.calc (python)\n"
+        "  MAX_SIZE = 10\n"
+        "  class Meter(Base)\n"
+        "    def __init__(self, scale)\n"
+        "    @property def value(self) -> float\n"
+        "  def add(a: int, b: int = 2) -> int\n"
+    )
+    with_tests = collect_api(tmp_path, include_tests=True)
+    assert [m.module for m in with_tests] == ["pkg.calc", "tests.test_calc"]
+
+
+def test_go_package_surface(tmp_path):
+    _write(tmp_path, {"server/server.go": GO})
+    (module,) = collect_api(tmp_path)
+    assert (module.module, module.language) == ("server", "go")
+    assert [(d.kind, d.name, d.signature) for d in module.declarations] == [
+        ("constant", "Max", "const Max = 10"),
+        ("function", "New", "func New(addr string) *Server"),
+        ("struct", "Server", "type Server struct { Addr string; Handler }"),
+        ("method", "Server.Start", "func (s *Server) Start(ctx context.Context) error"),
+    ]
+
+
+def test_typescript_surface_json(tmp_path):
+    _write(tmp_path, {"src/shapes.ts": TYPESCRIPT})
+    data = json.loads(api_to_json(collect_api(tmp_path)))
+    assert [m["module"] for m in data] == ["src/shapes"]
+    declarations = {d["name"]: d for d in data[0]["declarations"]}
+    assert sorted(declarations) == ["Shape", "VERSION", "makeSquare", "scale"]
+    assert declarations["Shape"]["signature"] == "interface Shape { area(): number; name: string; }"
+    assert declarations["makeSquare"]["signature"] == "const makeSquare = (side: number): Shape =>"
+    assert declarations["scale"] == {
+        "kind": "function",
+        "name": "scale",
+        "signature": "function scale(shape: Shape, factor = 2): Shape",
+        "path": "src/shapes.ts",
+        "line": 11,
+    }
+    assert declarations["VERSION"]["signature"] == 'const VERSION = "1.0"'
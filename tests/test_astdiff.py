@@ -0,0 +1,76 @@
+"""Tests for the syntax-aware diff."""
+
+from treesitter_tools.astdiff import changes_to_text, diff_files
+
+OLD_GO = """\
+package cache
+
+// Get returns a value.
+func (c *Cache) Get(key string) string {
+	return c.data[key]
+}
+
+func load(path string) error {
+	f, err := open(path)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func obsolete() {}
+"""
+
+NEW_GO = """\
+package cache
+
+// Get returns the cached value.
+func (c *Cache) Get(key string, fallback string) string {
+	return c.data[key]
+}
+
+func loadConfig(path string) error {
+	f, err := open(path)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func Evict() {}
+"""
+
+
+def _write(tmp_path, name, text):
+    path = tmp_path / name
+    path.write_text(text, encoding="utf-8")
+    return path
+
+
+def test_go_semantic_changes(tmp_path):
+    old = _write(tmp_path, "old.go", OLD_GO)
+    new = _write(tmp_path, "new.go", NEW_GO)
+    changes = {c.name: c for c in diff_files(old, new)}
+    assert changes["Cache.Get"].change == "signature_changed"
+    assert not changes["Cache.Get"].body_changed
+    assert changes["loadConfig"].change == "renamed"
+    assert changes["loadConfig"].old_name == "load"
+    assert changes["Evict"].change == "added"
+    assert changes["obsolete"].change == "removed"
+    assert "> function load -> loadConfig" in changes_to_text(list(changes.values()))
+
+
+def test_formatting_only_is_no_change(tmp_path):
+    old = _write(tmp_path, "a.py", "def f(a, b):\n    return a + b\n")
+    new = _write(tmp_path, "b.py", "# comment\ndef f( a,b ):\n\n    return (a + b)  # sum\n")
+    changes = diff_files(old, new)
+    # Parentheses are real tokens; only they should register.
+    assert [(c.name, c.change) for c in changes] == [("f", "body_changed")]
+    same = _write(tmp_path, "c.py", "def f(a,   b):\n        return a+b\n")
+    assert diff_files(old, same) == []
+
+
+def test_method_change_does_not_flag_class(tmp_path):
+    old = _write(tmp_path, "a.py", "class A:\n    def m(self):\n        return 1\n")
+    new = _write(tmp_path, "b.py", "class A:\n    def m(self):\n        return 2\n")
+    assert [(c.kind, c.name, c.change) for c in diff_files(old, new)] == [("function", "A.m", "body_changed")]
@@ -0,0 +1,66 @@
+"""Tests for AST export formats."""
+
+import json
+
+from conftest import run_cli
+
+from treesitter_tools.astdump import SCHEMA, tree_to_dict, tree_to_dot, tree_to_sexp
+from treesitter_tools.core import parse_file
+
+
+def _parse(tmp_path, text="def add(a, b):\n    return a + b\n"):
+    path = tmp_path / "m.py"
+    path.write_text(text, encoding="utf-8")
+    return parse_file(path)
+
+
+def test_json_shape_has_fields_and_ranges(tmp_path):
+    tree = tree_to_dict(_parse(tmp_path), "m.py")
+    assert tree["schema"] == SCHEMA
+    assert tree["version"] == 1
+    root = tree["root"]
+    assert root["type"] == "module"
+    assert root["start"] == {"line": 1, "column": 1, "byte": 0}
+    func = root["children"][0]
+    assert func["type"] == "function_definition"
+    name = next(c for c in func["children"] if c["field"] == "name")
+    assert name == {
+        "type": "identifier",
+        "named": True,
+        "field": "name",
+        "start": {"line": 1, "column": 5, "byte": 4},
+        "end": {"line": 1, "column": 8, "byte": 7},
+        "text": "add",
+        "children": [],
+    }
+    assert any(not c["named"] and c["type"] == "def" for c in func["children"])
+
+
+def test_depth_and_named_only(tmp_path):
+    tree = tree_to_dict(_parse(tmp_path), "m.py", max_depth=1, named_only=True)
+    func = tree["root"]["children"][0]
+    assert func["truncated"] is True
+    assert func["children"] == []
+    assert func["child_count"] == 3  # name, parameters, body
+
+
+def test_sexp_and_dot(tmp_path):
+    tree = tree_to_dict(_parse(tmp_path), "m.py", named_only=True)
+    sexp = tree_to_sexp(tree)
+    assert sexp.startswith("(module [0, 0] - [2, 0]\n  (function_definition [0, 0] - [1, 16]\n")
+    assert "name: (identifier [0, 4] - [0, 7])" in sexp
+    dot = tree_to_dot(tree_to_dict(_parse(tmp_path), "m.py"))
+    assert dot.startswith("digraph ast {")
+    assert '[label="name"]' in dot
+    assert "style=dashed" in dot
+
+
+def test_cli_ast_formats(tmp_path):
+    path = tmp_path / "m.py"
+    path.write_text("x = 1\n", encoding="utf-8")
+    result = run_cli(["ast", str(path)])
+    assert result.returncode == 0, result.stderr
+    assert json.loads(result.stdout)["language"] == "python"
+    result = run_cli(["ast", str(path), "--format", "sexp", "--depth", "0"])
+    assert result.returncode == 0
+    assert result.stdout == "(module [0, 0] - [1, 0]\n  ...)\n"
@@ -0,0 +1,74 @@
+"""Tests for the length-prefixed stdin batch mode."""
+
+import io
+import json
+
+import pytest
+from conftest import run_cli
+
+from treesitter_tools.batch import BatchError, BatchSession, read_message, write_message
+
+
+def _frames(*messages):
+    stream = io.BytesIO()
+    for message in messages:
+        if isinstance(message, bytes):
+            stream.write(message)
+        else:
+            write_message(stream, message)
+    return stream.getvalue()
+
+
+def _responses(data):
+    stream = io.BytesIO(data)
+    found = []
+    while True:
+        message = read_message(stream)
+        if message is None:
+            return found
+        found.append(message)
+
+
+def test_framing_round_trip():
+    data = _frames({"id": 1, "text": "héllo"}, b"\r\n", {"id": 2})
+    assert _responses(data) == [{"id": 1, "text": "héllo"}, {"id": 2}]
+    with pytest.raises(BatchError) as info:
+        read_message(io.BytesIO(b"Content-Length: 10\r\n\r\n{}"))
+    assert info.value.code == "invalid-frame"
+
+
+def test_unsaved_buffer_and_incremental_reparse(tmp_path):
+    (tmp_path / "app.py").write_text("def on_disk():\n    pass\n", encoding="utf-8")
+    session = BatchSession(tmp_path)
+    disk = session.handle({"id": 1, "command": "symbols", "path": "app.py"})
+    assert [s["name"] for s in disk["result"]] == ["on_disk"]
+    edited = session.handle({"id": 2, "command": "symbols", "path": "app.py", "content": "def unsaved():\n    pass\n"})
+    assert edited["ok"] and [s["name"] for s in edited["result"]] == ["unsaved"]
+    assert "content" not in edited["result"][0]
+    broken = session.handle({"id": 3, "command": "diagnostics", "path": "app.py", "content": "def unsaved(:\n"})
+    assert broken["result"] and broken["result"][0]["path"] == "app.py"
+
+
+def test_errors_are_per_request(tmp_path):
+    session = BatchSession(tmp_path)
+    assert session.handle({"id": 1, "command": "nope", "path": "a.py"})["error"]["code"] == "unknown-command"
+    assert session.handle({"id": 2, "command": "symbols"})["error"]["code"] == "invalid-request"
+    assert session.handle({"id": 3, "command": "symbols", "path": "../outside.py"})["error"]["code"] == "invalid-request"
+    missing = session.handle({"id": 4, "command": "query", "path": "a.py", "content": "x = 1\n"})
+    assert missing["error"]["code"] == "invalid-request"
+
+
+def test_serve_cli(tmp_path):
+    stdin = _frames(
+        {"id": "a", "command": "ping"},
+        {"id": "b", "command": "query", "path": "buffer.js", "content": "function f() {}\n",
+         "options": {"query": "(function_declaration name: (identifier) @name)"}},
+        {"id": "c", "command": "shutdown"},
+        {"id": "d", "command": "ping"},
+    )
+    result = run_cli(["serve", "--stdin-batch", "--root", str(tmp_path)], stdin=stdin, text=False)
+    assert result.returncode == 0, result.stderr
+    responses = _responses(result.stdout)
+    assert [r["id"] for r in responses] == ["a", "b", "c"]
+    assert "symbols" in responses[0]["result"]["commands"]
+    assert responses[1]["result"][0]["captures"][0]["text"] == "f"
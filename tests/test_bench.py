@@ -0,0 +1,86 @@
+"""Tests for the benchmark command and pprof output."""
+
+import gzip
+import json
+import time
+from pathlib import Path
+
+from conftest import run_cli
+
+from treesitter_tools.bench import BenchReport, LanguageBench, Sampler, WorkerBench, compare
+from treesitter_tools.export.pprof import encode_profile
+from treesitter_tools.export.protowire import iter_fields
+
+
+def _profile_fields(data):
+    fields = {}
+    for number, _wire, value in iter_fields(gzip.decompress(data)):
+        fields.setdefault(number, []).append(value)
+    return fields
+
+
+def _spin(seconds):
+    end = time.perf_counter() + seconds
+    while time.perf_counter() < end:
+        pass
+
+
+def test_encode_profile_shares_functions_and_strings():
+    leaf = ("parse", "bench.py", 10, 12)
+    samples = {
+        (leaf, ("main", "cli.py", 1, 5)): [3, 15_000_000],
+        (leaf, ("main", "cli.py", 1, 6)): [1, 5_000_000],
+    }
+    fields = _profile_fields(encode_profile(samples, 5_000_000, 20_000_000))
+    strings = [s.decode() for s in fields[6]]
+    assert strings[0] == "" and strings.count("main") == 1 and strings.count("bench.py") == 1
+    assert len(fields[2]) == 2 and len(fields[4]) == 3 and len(fields[5]) == 2
+    assert fields[10] == [20_000_000] and fields[12] == [5_000_000]
+    assert [strings[dict((n, v) for n, _w, v in iter_fields(t))[1]] for t in fields[1]] == ["samples", "wall"]
+
+
+def test_sampler_records_the_calling_stack():
+    with Sampler(0.001) as sampler:
+        _spin(0.05)
+    assert sampler.samples
+    names = {frame[0] for stack in sampler.samples for frame in stack}
+    assert "_spin" in names and "test_sampler_records_the_calling_stack" in names
+    assert sum(wall for _count, wall in sampler.samples.values()) > 0
+
+
+def test_report_text_and_baseline():
+    python = LanguageBench("python", files=4, bytes=2 << 20, parse_seconds=0.5, extract_seconds=0.25,
+                           query_seconds={"tags": 0.125}, peak_rss=64 << 20, grammar={"fingerprint": "aaa"})
+    report = BenchReport(Path("corpus"), repeat=3, languages=[python], workers=[WorkerBench(1, 4, 2 << 20, 1.0)])
+    data = report.to_dict()
+    assert data["languages"][0]["parse_mb_per_s"] == 4.0 and data["total"]["files_per_s"] == 8.0
+    compare(report, {"languages": [{"language": "python", "parse_mb_per_s": 2.0, "grammar": {"fingerprint": "bbb"}}]})
+    assert python.baseline == {"parse_mb_per_s": 2.0, "parse_change": 1.0, "grammar_changed": True}
+    lines = report.to_text().splitlines()
+    assert lines[0] == "Benchmark of corpus (best of 3 runs)"
+    assert lines[2].split() == ["language", "files", "size", "parse", "MB/s", "files/s", "extract", "tags", "peak",
+                                "RSS", "vs", "baseline"]
+    assert lines[3].split()[:8] == ["python", "4", "2.0", "MiB", "0.500s", "4.00", "8", "0.250s"]
+    assert lines[3].endswith("+100.0% (grammar changed)")
+    assert "jobs=1" in lines[-1] and "x1.00" in lines[-1]
+
+
+def test_cli_bench(tmp_path):
+    (tmp_path / "app.py").write_text("def main():\n    return helper()\n\n\ndef helper():\n    return 1\n", encoding="utf-8")
+    (tmp_path / "lib.go").write_text("package lib\n\nfunc Add(a, b int) int { return a + b }\n", encoding="utf-8")
+    result = run_cli(["bench", ".", "--format", "json", "--repeat", "2", "--jobs", "1", "--jobs", "2",
+                      "--profile", "cpu.pb.gz"], cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    report = json.loads(result.stdout)
+    assert [(b["language"], b["files"]) for b in report["languages"]] == [("go", 1), ("python", 1)]
+    assert all("tags" in b["query_seconds"] and b["peak_rss"] for b in report["languages"])
+    assert [w["jobs"] for w in report["workers"]] == [1, 2]
+    assert "Wrote pprof profile" in result.stderr and (tmp_path / "cpu.pb.gz").read_bytes()[:2] == b"\x1f\x8b"
+
+    (tmp_path / "before.json").write_text(result.stdout, encoding="utf-8")
+    result = run_cli(["bench", ".", "-l", "python", "--baseline", "before.json"], cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    assert "vs baseline" in result.stdout and "go" not in result.stdout.split("\n", 2)[2]
+
+    result = run_cli(["bench", ".", "-q", "nonexistent"], cwd=tmp_path)
+    assert result.returncode == 1 and "No 'nonexistent' query" in result.stderr
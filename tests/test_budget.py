@@ -0,0 +1,53 @@
+"""Tests for token-budgeted symbol output."""
+
+import pytest
+
+from treesitter_tools.budget import fit_symbols, get_tokenizer, truncate_text
+from treesitter_tools.chunker import estimate_tokens
+from treesitter_tools.core import CodeSymbol
+
+
+def _symbol(name, body_lines, doc=None):
+    content = f"def {name}():\n" + "".join(f"    x = {i}  # padding padding\n" for i in range(body_lines))
+    return CodeSymbol("function", name, 1, body_lines + 1, f"def {name}():", doc, content=content, doc=doc)
+
+
+def test_fits_without_changes():
+    symbols = [_symbol("a", 2), _symbol("b", 3)]
+    report = fit_symbols([symbols], 10_000)
+    assert report.full_bodies == 2 and report.omitted_bodies == 0
+    assert all(s.elided is None for s in symbols)
+    assert report.used_tokens <= 10_000
+
+
+def test_prefers_signatures_and_small_bodies():
+    big, small = _symbol("big", 200), _symbol("small", 2)
+    symbols = [big, small]
+    report = fit_symbols([symbols], 300)
+    assert report.within_budget
+    assert small.elided is None
+    assert big.elided == "truncated"
+    assert big.content.rstrip().endswith("more lines)")
+
+
+def test_drops_docs_then_symbols():
+    groups = [[_symbol(f"f{i}", 1, doc="A long docstring " * 20) for i in range(10)]]
+    report = fit_symbols(groups, 60)
+    assert report.dropped_docs
+    assert report.dropped_symbols > 0
+    assert all(s.doc is None for s in groups[0])
+    assert report.within_budget
+
+
+def test_truncate_text_marker():
+    text = "".join(f"line {i}\n" for i in range(50))
+    trimmed, remaining = truncate_text(text, 20, estimate_tokens)
+    assert remaining > 0
+    assert estimate_tokens(trimmed) <= 20
+    assert trimmed.endswith(f"... ({remaining} more lines)\n")
+
+
+def test_tokenizer_lookup():
+    assert get_tokenizer("heuristic")("abcd") == 1
+    with pytest.raises(ValueError):
+        get_tokenizer("nope")
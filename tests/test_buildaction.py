@@ -0,0 +1,99 @@
+"""Tests for build-system actions: param files, content-addressed outputs, and the persistent worker."""
+
+import hashlib
+import io
+import json
+
+import pytest
+from conftest import run_cli
+
+from treesitter_tools.buildaction import (
+    WorkerSession,
+    WorkResponse,
+    decode_request,
+    encode_response,
+    expand_command_line,
+    expand_params,
+)
+from treesitter_tools.export.protowire import delimited, int_field, iter_fields, read_delimited, str_field
+
+
+def _echo(args):
+    return (0 if args[:1] != ["fail"] else 3), " ".join(args) + "\n"
+
+
+def test_expand_params(tmp_path):
+    params = tmp_path / "app.params"
+    params.write_text("src/app.py\n--out\nout dir/app.json\n", encoding="utf-8")
+    assert expand_params(["action", "--param-file", str(params), "-f", "json"]) == [
+        "action", "src/app.py", "--out", "out dir/app.json", "-f", "json",
+    ]
+    assert expand_params([f"--param-file={params}"]) == ["src/app.py", "--out", "out dir/app.json"]
+    # `@FILE` only where Bazel writes it: after `action`, and in WorkRequests.
+    assert expand_params([f"@{params}"]) == [f"@{params}"]
+    assert expand_command_line(["rewrite", "-r", f"@{params}"]) == ["rewrite", "-r", f"@{params}"]
+    assert expand_command_line(["--deterministic", "action", f"@{params}"])[2:4] == ["src/app.py", "--out"]
+    # Only the subcommand counts, not an argument (or an option's value) that happens to be `action`.
+    assert expand_command_line(["query", "action", f"@{params}"]) == ["query", "action", f"@{params}"]
+    assert expand_command_line(["--encoding", "action", f"@{params}"], {"--encoding"})[2] == f"@{params}"
+    assert expand_command_line(["--encoding", "latin-1", "action", f"@{params}"], {"--encoding"})[3] == "src/app.py"
+    with pytest.raises(ValueError, match="parameter file"):
+        expand_params(["--param-file", str(tmp_path / "missing")])
+
+
+def test_proto_worker(tmp_path):
+    (tmp_path / "args").write_text("a\nb\n", encoding="utf-8")
+    requests = b"".join(
+        delimited(message) for message in [
+            str_field(1, "x") + str_field(1, "y"),
+            str_field(1, "@args") + int_field(3, 7) + str_field(6, str(tmp_path)),
+            str_field(1, "fail") + int_field(3, 8),
+            int_field(3, 8) + int_field(4, 1),  # cancel: already answered, no response
+        ]
+    )
+    assert decode_request(str_field(1, "fail") + int_field(3, 8) + int_field(5, 10)).verbosity == 10
+    out = io.BytesIO()
+    WorkerSession(_echo).serve(io.BytesIO(requests), out)
+    out.seek(0)
+    responses = []
+    while (message := read_delimited(out)) is not None:
+        responses.append({number: value for number, _wire, value in iter_fields(message)})
+    assert responses == [{2: b"x y\n"}, {2: b"a b\n", 3: 7}, {1: 3, 2: b"fail\n", 3: 8}]
+    assert encode_response(WorkResponse(exit_code=-1))[:2] == b"\x08\xff"  # int32 varint, sign-extended
+
+
+def test_json_worker():
+    requests = '{"arguments": ["x"], "requestId": 1}\n{\n  "arguments": ["fail"]\n}\n'
+    out = io.BytesIO()
+    WorkerSession(_echo).serve(io.BytesIO(requests.encode()), out)
+    assert [json.loads(line) for line in out.getvalue().splitlines()] == [
+        {"exitCode": 0, "output": "x\n", "requestId": 1},
+        {"exitCode": 3, "output": "fail\n", "requestId": 0},
+    ]
+
+
+def test_cli_action(tmp_path):
+    (tmp_path / "src").mkdir()
+    (tmp_path / "src" / "app.py").write_text("def main():\n    return 1\n", encoding="utf-8")
+    result = run_cli(["action", "src/app.py", "--out", "out/app.json"], cwd=tmp_path, text=False)
+    assert result.returncode == 0, result.stderr
+    data = (tmp_path / "out" / "app.json").read_bytes()
+    (record,) = json.loads(data)
+    assert record["path"] == "src/app.py" and [s["name"] for s in record["symbols"]] == ["main"]
+
+    (tmp_path / "app.params").write_text("src/app.py\n--out-dir\ncas\n", encoding="utf-8")
+    stored = run_cli(["action", "@app.params"], cwd=tmp_path, text=False)
+    assert stored.returncode == 0, stored.stderr
+    path = stored.stdout.decode().strip()
+    assert path == f"cas/{hashlib.sha256(data).hexdigest()}.json" and (tmp_path / path).read_bytes() == data
+
+    requests = (
+        json.dumps({"arguments": ["src/app.py", "--out", "w.json", "--kind", "chunks"], "requestId": 1}) + "\n"
+        + json.dumps({"arguments": ["src/app.py"], "requestId": 2}) + "\n"
+    )
+    worker = run_cli(["action", "--persistent_worker"], cwd=tmp_path, stdin=requests.encode(), text=False)
+    assert worker.returncode == 0, worker.stderr
+    first, second = [json.loads(line) for line in worker.stdout.splitlines()]
+    assert first == {"exitCode": 0, "output": "", "requestId": 1}
+    assert json.loads((tmp_path / "w.json").read_text(encoding="utf-8"))[0]["name"] == "main"
+    assert second["exitCode"] == 1 and "--out" in second["output"]
@@ -0,0 +1,97 @@
+"""Tests for context bundles."""
+
+import json
+
+import pytest
+
+from treesitter_tools.bundle import build_bundle, relevant_imports
+from treesitter_tools.core import parse_file
+
+MODELS = '''\
+class Order:
+    """A customer order."""
+
+    def __init__(self, total):
+        self.total = total
+'''
+
+PRICING = '''\
+import math
+import os
+from models import Order
+
+
+def round_up(value):
+    return math.ceil(value)
+
+
+def discount(order: Order) -> float:
+    """Ten percent off, rounded up."""
+    return round_up(order.total * 0.9)
+
+
+def checkout(order):
+    return discount(order)
+
+
+def main():
+    return checkout(Order(10))
+'''
+
+
+@pytest.fixture
+def project(tmp_path):
+    (tmp_path / "models.py").write_text(MODELS, encoding="utf-8")
+    (tmp_path / "pricing.py").write_text(PRICING, encoding="utf-8")
+    return tmp_path
+
+
+def test_bundle_gathers_definition_types_calls_and_imports(project):
+    bundle = build_bundle(project, "discount")
+    roles = [(item.role, item.name, item.hops) for item in bundle.items]
+    assert roles == [
+        ("definition", "discount", 0),
+        ("type", "Order", 0),
+        ("callee", "round_up", 1),
+        ("caller", "checkout", 1),
+    ]
+    definition = bundle.items[0]
+    assert (definition.path, definition.start_line, definition.doc) == ("pricing.py", 10, "Ten percent off, rounded up.")
+    assert bundle.items[1].path == "models.py"
+    assert bundle.imports == {"pricing.py": ["from models import Order"]}
+    markdown = bundle.to_markdown()
+    assert markdown.startswith("# Context for `discount`\n")
+    assert "## Callers\n" in markdown and "def checkout(order):" in markdown
+
+
+def test_two_hops_reach_further(project):
+    bundle = build_bundle(project, "discount", hops=2)
+    assert ("caller", "main", 2) in [(item.role, item.name, item.hops) for item in bundle.items]
+    with pytest.raises(ValueError, match="hops"):
+        build_bundle(project, "discount", hops=3)
+
+
+def test_budget_trims_furthest_bodies_first(project):
+    full = build_bundle(project, "discount", hops=2)
+    budget = full.used_tokens - 20
+    trimmed = build_bundle(project, "discount", hops=2, max_tokens=budget)
+    assert trimmed.used_tokens <= budget
+    assert trimmed.items[0].content is not None and trimmed.items[0].elided is None
+    assert trimmed.items[-1].elided in {"truncated", "omitted"}
+    payload = json.loads(trimmed.to_json())
+    assert payload["max_tokens"] == budget
+
+
+def test_unknown_and_ambiguous_symbols(project):
+    (project / "other.py").write_text("def discount():\n    pass\n", encoding="utf-8")
+    with pytest.raises(ValueError, match="ambiguous"):
+        build_bundle(project, "discount")
+    assert build_bundle(project, "discount", file="other.py").items[0].path == "other.py"
+    with pytest.raises(ValueError, match="No definition"):
+        build_bundle(project, "missing")
+
+
+def test_relevant_imports_for_go(tmp_path):
+    path = tmp_path / "main.go"
+    path.write_text('package main\n\nimport (\n\t"fmt"\n\t"os"\n)\n', encoding="utf-8")
+    assert relevant_imports(parse_file(path), {"fmt", "Println"}) == ["package main", 'import "fmt"']
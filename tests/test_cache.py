@@ -0,0 +1,98 @@
+"""Tests for the content-addressed result cache."""
+
+import dataclasses
+import json
+
+from conftest import run_cli
+
+from treesitter_tools import core
+from treesitter_tools.cache import ResultCache, _checksum, query_version
+from treesitter_tools.incremental import IncrementalSession
+
+
+def test_corrupt_entries_are_discarded(tmp_path):
+    cache = ResultCache(tmp_path)
+    key = "ab" * 32
+    assert cache.get(key) is None
+    cache.put(key, [{"name": "café"}])
+    assert cache.get(key) == [{"name": "café"}]
+
+    path = cache.path(key)
+    path.write_text(path.read_text(encoding="utf-8").replace("café", "cafe"), encoding="utf-8")
+    assert cache.get(key) is None
+    assert not path.exists()
+    assert cache.stats.to_dict() == {"hits": 1, "misses": 2, "invalid": 1}
+
+
+def test_key_covers_language_spec_and_queries(tmp_path):
+    cache = ResultCache(tmp_path)
+    source = b"def foo():\n    return 1\n"
+    base = cache.key("symbols", source, "python")
+    assert cache.key("symbols", source, "python") == base
+    assert cache.key("symbols", source + b"\n", "python") != base
+    assert cache.key("symbols", source, "python", options={"max_chunk_size": 10}) != base
+    assert cache.key("query", source, "python", queries=["(identifier) @id"]) != cache.key(
+        "query", source, "python", queries=["(string) @s"]
+    )
+    assert query_version("python") != query_version("python", ["(identifier) @id"])
+
+    original = core.get_language_spec("python")
+    core.register_language(dataclasses.replace(original, function_nodes=frozenset({"lambda"})))
+    try:
+        assert cache.key("symbols", source, "python") != base
+    finally:
+        core.register_language(original)
+    assert cache.key("symbols", source, "python") == base
+
+
+def test_session_skips_parsing_on_verified_hit(tmp_path):
+    src = tmp_path / "a.py"
+    src.write_text("def foo():\n    return 1\n", encoding="utf-8")
+    cache_dir = tmp_path / "cache"
+    IncrementalSession(cache_dir).extract(src)
+
+    entry = next(cache_dir.glob("*/*.json"))
+    data = json.loads(entry.read_text(encoding="utf-8"))
+    data["result"]["symbols"][0]["name"] = "tampered"
+    entry.write_text(json.dumps(data), encoding="utf-8")
+
+    session = IncrementalSession(cache_dir)
+    assert [s.name for s in session.extract(src)] == ["foo"]  # checksum mismatch: parsed again
+    assert session.stats.reparsed == 1
+    assert session.cache.stats.invalid == 1
+
+
+def _poison(cache_dir, result):
+    """Rewrite every entry with a valid checksum over `result`, so only a cache hit can return it."""
+    for entry in cache_dir.glob("*/*.json"):
+        data = json.loads(entry.read_text(encoding="utf-8"))
+        entry.write_text(json.dumps({"key": data["key"], "sha256": _checksum(result), "result": result}), encoding="utf-8")
+
+
+def test_query_cache_and_no_cache(tmp_path):
+    src = tmp_path / "a.py"
+    src.write_text("x = 1\n", encoding="utf-8")
+    cache_dir = tmp_path / "cache"
+    args = ["query", str(src), "(identifier) @id", "--cache-dir", str(cache_dir)]
+    first = run_cli(args)
+    assert first.returncode == 0, first.stderr
+    _poison(cache_dir, [])
+
+    assert json.loads(run_cli(args).stdout) == []
+    assert json.loads(run_cli(args + ["--no-cache"]).stdout) == json.loads(first.stdout)
+    other = run_cli(["query", str(src), "(integer) @n", "--cache-dir", str(cache_dir)])
+    assert json.loads(other.stdout)[0]["captures"][0]["text"] == "1"
+
+
+def test_scan_uses_config_cache_dir(tmp_path):
+    (tmp_path / ".treesitter-tools.yaml").write_text("cache_dir: .cache\n", encoding="utf-8")
+    (tmp_path / "a.py").write_text("def foo():\n    pass\n", encoding="utf-8")
+    run_cli(["scan", ".", "--include", "*.py"], cwd=tmp_path)
+    result = run_cli(["scan", ".", "--include", "*.py"], cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    assert "Cache: 1 hits, 0 re-parsed." in result.stderr
+    assert any((tmp_path / ".cache").glob("*/*.json"))
+
+    result = run_cli(["scan", ".", "--include", "*.py", "--no-cache"], cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    assert "Cache:" not in result.stderr
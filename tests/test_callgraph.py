@@ -0,0 +1,56 @@
+"""Tests for call graph extraction."""
+
+from pathlib import Path
+
+from treesitter_tools.callgraph import build_call_graph
+
+
+def _edge(graph, caller, callee):
+    return next(e for e in graph.edges if e.caller == caller and e.callee == callee)
+
+
+def test_python_cross_file_edges(tmp_path):
+    (tmp_path / "util.py").write_text("def helper():\n    return 1\n", encoding="utf-8")
+    (tmp_path / "main.py").write_text(
+        "from util import helper\n\ndef run():\n    helper()\n    print('x')\n",
+        encoding="utf-8",
+    )
+    graph = build_call_graph(tmp_path)
+
+    edge = _edge(graph, "run", "helper")
+    assert edge.file == "main.py"
+    assert edge.line == 4
+    assert edge.callee_file == "util.py"
+    assert not _edge(graph, "run", "print").resolved
+
+
+def test_python_self_calls_resolve_receiver_type(tmp_path):
+    (tmp_path / "mod.py").write_text(
+        "class A:\n    def go(self):\n        self.step()\n    def step(self):\n        pass\n\n"
+        "class B:\n    def step(self):\n        pass\n",
+        encoding="utf-8",
+    )
+    graph = build_call_graph(tmp_path)
+    edge = _edge(graph, "A.go", "A.step")
+    assert edge.receiver_type == "A"
+    assert edge.callee_line == 4
+
+
+def test_go_method_receiver(tmp_path):
+    (tmp_path / "cache.go").write_text(
+        "package cache\n\ntype Cache struct{}\n\n"
+        "func (c *Cache) Get() { c.load() }\n\n"
+        "func (c *Cache) load() {}\n",
+        encoding="utf-8",
+    )
+    graph = build_call_graph(tmp_path)
+    edge = _edge(graph, "Cache.Get", "Cache.load")
+    assert edge.receiver == "c"
+    assert edge.receiver_type == "Cache"
+
+
+def test_dot_output(tmp_path):
+    (tmp_path / "a.py").write_text("def a():\n    b()\n\ndef b():\n    pass\n", encoding="utf-8")
+    dot = build_call_graph(tmp_path).to_dot(resolved_only=True)
+    assert dot.startswith("digraph callgraph {")
+    assert '"a" -> "b";' in dot
@@ -0,0 +1,126 @@
+"""Tests for control-flow graph construction."""
+
+import json
+
+import pytest
+from conftest import run_cli
+
+from treesitter_tools.cfg import file_cfgs
+
+
+def _graph(tmp_path, name, text, function=None):
+    path = tmp_path / name
+    path.write_text(text, encoding="utf-8")
+    [graph] = file_cfgs(path, function)
+    return graph
+
+
+def _edges(graph):
+    """(source first line, target first line or entry/exit, kind) triples."""
+    def name(block_id):
+        block = graph.block(block_id)
+        return block.kind if block.kind != "block" else block.statements[0].text
+    return {(name(e.source), name(e.target), e.kind) for e in graph.edges}
+
+
+def test_python_branches_and_early_return(tmp_path):
+    graph = _graph(
+        tmp_path, "a.py",
+        "def f(a, b):\n    if a:\n        return 1\n    elif b:\n        x()\n    else:\n        y()\n    z()\n",
+    )
+    edges = _edges(graph)
+    assert ("if a:", "return 1", "true") in edges
+    assert ("return 1", "exit", "return") in edges
+    assert ("if a:", "elif b:", "false") in edges
+    assert ("elif b:", "x()", "true") in edges and ("elif b:", "y()", "false") in edges
+    assert ("x()", "z()", "next") in edges and ("y()", "z()", "next") in edges
+    assert len(list(graph.paths())) == 3
+    assert graph.unreachable() == []
+
+
+def test_python_loop_break_continue_and_dead_code(tmp_path):
+    graph = _graph(
+        tmp_path, "b.py",
+        "def f(items):\n    for item in items:\n        if item:\n            break\n        continue\n"
+        "        dead()\n    done()\n",
+    )
+    edges = _edges(graph)
+    assert ("for item in items:", "if item:", "true") in edges
+    assert ("for item in items:", "done()", "false") in edges
+    assert ("break", "done()", "break") in edges
+    assert ("continue", "for item in items:", "continue") in edges
+    assert [b.statements[0].text for b in graph.unreachable()] == ["dead()"]
+    assert not graph.to_dict()["blocks"][graph.unreachable()[0].id]["reachable"]
+
+
+def test_while_true_only_exits_through_break(tmp_path):
+    graph = _graph(tmp_path, "c.py", "def f():\n    while True:\n        if g():\n            break\n    h()\n")
+    assert ("while True:", "h()", "false") not in _edges(graph)
+    assert ("break", "h()", "break") in _edges(graph)
+
+
+def test_try_edges_reach_handler_and_finally(tmp_path):
+    graph = _graph(
+        tmp_path, "d.py",
+        "def f():\n    try:\n        a()\n        raise E\n    except E:\n        b()\n    finally:\n        c()\n    d()\n",
+    )
+    edges = _edges(graph)
+    assert ("a()", "except E:", "raise") in edges
+    assert ("except E:", "finally:", "next") in edges
+    assert ("finally:", "d()", "next") in edges
+
+
+def test_javascript_switch_fallthrough(tmp_path):
+    graph = _graph(
+        tmp_path, "s.js",
+        "function f(v) {\n  switch (v) {\n    case 1:\n    case 2:\n      a();\n      break;\n    case 3:\n      b();\n  }\n"
+        "  return v;\n}\n",
+    )
+    cases = {e.label: e.target for e in graph.edges if e.kind == "case"}
+    assert cases["1"] == cases["2"]  # the empty case falls into the next one
+    edges = _edges(graph)
+    assert ("break;", "return v;", "break") in edges
+    assert ("b();", "return v;", "next") in edges
+    assert any(e.kind == "no_match" for e in graph.edges)
+
+
+def test_go_labeled_break_and_bare_for(tmp_path):
+    graph = _graph(
+        tmp_path, "l.go",
+        "package p\n\nfunc f(xs []int) {\nouter:\n\tfor {\n\t\tfor _, x := range xs {\n\t\t\tif x > 0 {\n"
+        "\t\t\t\tbreak outer\n\t\t\t}\n\t\t}\n\t}\n\tdone()\n}\n",
+    )
+    edges = _edges(graph)
+    assert ("break outer", "done()", "break") in edges
+    assert not any(s == "for {" and k == "false" for s, _, k in edges)
+
+
+def test_function_filter_and_unsupported_language(tmp_path):
+    path = tmp_path / "m.py"
+    path.write_text("class A:\n    def f(self):\n        pass\n\ndef g():\n    pass\n", encoding="utf-8")
+    assert [g.name for g in file_cfgs(path)] == ["A.f", "g"]
+    assert [g.name for g in file_cfgs(path, "A.f")] == ["A.f"]
+    with pytest.raises(ValueError, match="No function named 'h'"):
+        file_cfgs(path, "h")
+    css = tmp_path / "x.css"
+    css.write_text("a { color: red; }\n", encoding="utf-8")
+    with pytest.raises(ValueError, match="not supported"):
+        file_cfgs(css)
+
+
+def test_cli_cfg_json_and_dot(tmp_path):
+    path = tmp_path / "a.py"
+    path.write_text("def f(x):\n    if x:\n        return 1\n    return 2\n", encoding="utf-8")
+    result = run_cli(["cfg", str(path)])
+    assert result.returncode == 0, result.stderr
+    [graph] = json.loads(result.stdout)
+    assert graph["name"] == "f" and graph["entry"] == 0 and graph["exit"] == 1
+    assert {e["kind"] for e in graph["edges"]} >= {"true", "false", "return"}
+
+    result = run_cli(["cfg", str(path), "--format", "dot"])
+    assert result.returncode == 0, result.stderr
+    assert result.stdout.startswith("digraph cfg {") and "subgraph cluster_0" in result.stdout
+
+    result = run_cli(["cfg", str(path), "--function", "missing"])
+    assert result.returncode == 1
+    assert "No function named 'missing'" in result.stderr
@@ -0,0 +1,91 @@
+"""Tests for source encoding detection and transcoding."""
+
+import json
+from pathlib import Path
+
+import pytest
+from conftest import run_cli
+
+from treesitter_tools import charsets
+from treesitter_tools.core import extract_symbols, is_binary_file, run_query, scan_file
+from treesitter_tools.patch import write_files
+from treesitter_tools.rename import rename_symbol
+from treesitter_tools.rewrite import FileRewrite
+
+PYTHON = 'def café():\n    return "naïve"\n'
+
+
+@pytest.mark.parametrize("stored, detected, bom", [
+    ("utf-8", "utf-8", b""),
+    ("utf-8-sig", "utf-8-sig", b"\xef\xbb\xbf"),
+    ("utf-16", "utf-16-le", b"\xff\xfe"),
+    ("utf-16-be", "utf-16-be", b""),
+    ("utf-32", "utf-32-le", b"\xff\xfe\x00\x00"),
+    ("cp1252", "cp1252", b""),
+])
+def test_decode(stored, detected, bom):
+    decoded = charsets.decode(PYTHON.encode(stored))
+    assert (decoded.encoding, decoded.bom) == (detected, bom)
+    assert decoded.data == PYTHON.encode("utf-8")
+    assert charsets.encode(decoded.data, decoded.encoding, decoded.bom) == PYTHON.encode(stored)
+
+
+def test_heuristics():
+    assert charsets.decode('# -*- coding: koi8-r -*-\nx = "привет"\n'.encode("koi8-r")).encoding == "koi8-r"
+    assert charsets.decode(b'x = "\x81\xe9"\n').encoding == "latin-1"  # 0x81 is undefined in Windows-1252
+    assert charsets.decode(b"import os\x00").encoding == "utf-8"  # binary, left alone
+    assert charsets.wide_encoding("x = 1\n".encode("utf-16-le")) == "utf-16-le"
+    assert charsets.wide_encoding(b"import os\x00") is None
+    assert charsets.normalize("LATIN1") == "latin-1" and charsets.normalize("windows-1252") == "cp1252"
+    with pytest.raises(ValueError, match="Unknown encoding 'klingon'"):
+        charsets.normalize("klingon")
+    with pytest.raises(ValueError, match="cannot be encoded as latin-1"):
+        charsets.encode("π = 3\n".encode("utf-8"), "latin-1")
+
+
+def test_source_offset():
+    decoded = charsets.decode(PYTHON.encode("utf-16"))
+    offset = PYTHON.encode("utf-8").index(b"return")
+    assert charsets.source_offset(decoded, offset) == PYTHON.encode("utf-16").index("return".encode("utf-16-le"))
+
+
+def test_transcoded_sources(tmp_path):
+    for name, encoding in (("latin.py", "cp1252"), ("wide.py", "utf-16")):
+        path = tmp_path / name
+        path.write_bytes(PYTHON.encode(encoding))
+        assert not is_binary_file(path)
+        assert [s.name for s in extract_symbols(path)] == ["café"]
+        assert scan_file(path).encoding == ("cp1252" if encoding == "cp1252" else "utf-16-le")
+        (capture,) = run_query(path, "(string) @s")[0]["captures"]
+        assert capture["text"] == '"naïve"' and capture["start_byte"] == PYTHON.encode("utf-8").index(b'"')
+
+
+def test_edits_keep_the_encoding(tmp_path):
+    path = tmp_path / "wide.py"
+    path.write_bytes("total = 0\nprint(total, 'ü')\n".encode("utf-16"))
+    write_files(rename_symbol(path, 1, 1, "count").files)
+    assert path.read_bytes() == "count = 0\nprint(count, 'ü')\n".encode("utf-16")
+    latin = tmp_path / "latin.py"
+    latin.write_bytes("total = 'ü'\n".encode("latin-1"))
+    greek = FileRewrite(latin, "total = 'ü'\n".encode("utf-8"), "total = 'σ'\n".encode("utf-8"))
+    with pytest.raises(OSError, match="cannot be encoded as cp1252"):
+        write_files([greek])
+    assert latin.read_bytes() == "total = 'ü'\n".encode("latin-1")
+
+
+def test_cli_encodings(tmp_path):
+    (tmp_path / "latin.py").write_bytes(PYTHON.encode("cp1252"))
+    (tmp_path / "plain.py").write_text("def plain():\n    pass\n", encoding="utf-8")
+    result = run_cli(["scan", ".", "--format", "json"], cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    files = {Path(f["path"]).name: f for f in json.loads(result.stdout)}
+    assert files["latin.py"]["encoding"] == "cp1252" and "encoding" not in files["plain.py"]
+    assert files["latin.py"]["symbols"][0]["name"] == "café"
+
+    detected = run_cli(["detect", "."], cwd=tmp_path).stdout.splitlines()
+    assert detected == ["latin.py: python (extension) [encoding: cp1252]", "plain.py: python (extension)"]
+
+    forced = run_cli(["--encoding", "utf-8", "symbols", "latin.py"], cwd=tmp_path)
+    assert json.loads(forced.stdout)[0]["name"] != "café"
+    bad = run_cli(["--encoding", "klingon", "symbols", "latin.py"], cwd=tmp_path)
+    assert bad.returncode == 1 and "Unknown encoding 'klingon'" in bad.stderr
@@ -0,0 +1,127 @@
+"""Tests for checkpointed, resumable, and throttled scans."""
+
+import json
+import os
+import signal
+import subprocess
+import sys
+import time
+from pathlib import Path
+
+import pytest
+from conftest import run_cli
+
+from treesitter_tools.checkpoint import Checkpoint, CheckpointError, GracefulStop, throttle
+
+
+def _project(tmp_path, count=5):
+    src = tmp_path / "src"
+    src.mkdir()
+    for i in range(count):
+        (src / f"mod{i}.py").write_text(f"def f{i}():\n    return {i}\n", encoding="utf-8")
+    return src
+
+
+def test_checkpoint_round_trip(tmp_path):
+    path = tmp_path / "job.ckpt"
+    job = Checkpoint.create(path, {"command": "scan", "root": "/r"})
+    job.mark("a.py")
+    job.commit(10)
+    job.mark("b.py")
+    job.commit(25)
+    with path.open("a", encoding="utf-8") as handle:
+        handle.write('{"offset": 40, "pa')  # crashed mid-commit
+
+    loaded = Checkpoint.load(path)
+    assert loaded.completed == {"a.py", "b.py"} and loaded.offset == 25 and not loaded.complete
+    loaded.check({"command": "scan", "root": "/r"})
+    with pytest.raises(CheckpointError, match="different job"):
+        loaded.check({"command": "scan", "root": "/elsewhere"})
+
+    (tmp_path / "bogus").write_text("not json\n", encoding="utf-8")
+    with pytest.raises(CheckpointError, match="not a treesitter-tools checkpoint"):
+        Checkpoint.load(tmp_path / "bogus")
+
+
+def test_throttle_spaces_items():
+    now = [0.0]
+    slept = []
+
+    def sleep(seconds):
+        slept.append(round(seconds, 3))
+        now[0] += seconds
+
+    assert list(throttle(range(3), 4, clock=lambda: now[0], sleep=sleep)) == [0, 1, 2]
+    assert slept == [0.25, 0.25]
+    assert list(throttle(range(3), None)) == [0, 1, 2]
+
+
+def test_graceful_stop_records_signal():
+    with GracefulStop() as stop:
+        os.kill(os.getpid(), signal.SIGTERM)
+        assert stop.requested and stop.exit_code == 128 + signal.SIGTERM
+    assert signal.getsignal(signal.SIGTERM) is signal.SIG_DFL
+
+
+def test_scan_resumes_without_duplicates(tmp_path):
+    _project(tmp_path)
+    args = ["scan", "src", "-f", "ndjson", "--output", "out.ndjson", "--checkpoint", "job.ckpt", "--flush-every", "1"]
+    result = run_cli(args, cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    lines = (tmp_path / "job.ckpt").read_text(encoding="utf-8").splitlines()
+    assert json.loads(lines[-1]) == {"complete": True}
+    assert run_cli(args, cwd=tmp_path).returncode == 1  # refuses to clobber the job
+    assert "nothing to resume" in run_cli(args + ["--resume"], cwd=tmp_path).stderr
+
+    # Simulate a crash: keep the header and the commits for the first two files,
+    # and leave half a record of the third in the output.
+    kept, done, offset = [lines[0]], set(), 0
+    for line in lines[1:]:
+        commit = json.loads(line)
+        if "paths" not in commit or len(done) == 2:
+            break
+        kept.append(line)
+        done.update(commit["paths"])
+        offset = commit["offset"]
+    assert len(done) == 2
+    (tmp_path / "job.ckpt").write_text("\n".join(kept) + "\n", encoding="utf-8")
+    output = (tmp_path / "out.ndjson").read_bytes()
+    (tmp_path / "out.ndjson").write_bytes(output[:offset] + b'{"path": "src/mo')
+
+    result = run_cli(args + ["--resume"], cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    assert "Resuming: 2 files already done." in result.stderr
+    records = [json.loads(line) for line in (tmp_path / "out.ndjson").read_text(encoding="utf-8").splitlines()]
+    assert sorted(Path(r["path"]).name for r in records) == [f"mod{i}.py" for i in range(5)]
+
+
+def test_checkpoint_option_errors(tmp_path):
+    _project(tmp_path, 1)
+    assert "--resume needs --checkpoint" in run_cli(["scan", "src", "--resume"], cwd=tmp_path).stderr
+    result = run_cli(["scan", "src", "--checkpoint", "job.ckpt"], cwd=tmp_path)
+    assert result.returncode == 1 and "--format ndjson or proto" in result.stderr
+    args = ["scan", "src", "-f", "ndjson", "--output", "out.ndjson.gz", "--checkpoint", "job.ckpt"]
+    result = run_cli(args, cwd=tmp_path)
+    assert result.returncode == 1 and "local, uncompressed file" in result.stderr
+
+
+def test_sigterm_stops_a_streaming_scan(tmp_path):
+    _project(tmp_path, 200)
+    # A separate process on purpose: SIGTERM must reach the CLI, not the test runner.
+    cmd = [sys.executable, "-m", "treesitter_tools.cli", "scan", "src", "-f", "ndjson", "--output", "out.ndjson",
+           "--checkpoint", "job.ckpt", "--rate", "20"]
+    env = os.environ.copy()
+    env["PYTHONPATH"] = str(Path(__file__).parent.parent / "src") + os.pathsep + env.get("PYTHONPATH", "")
+    process = subprocess.Popen(cmd, cwd=tmp_path, env=env, stdout=subprocess.PIPE, stderr=subprocess.PIPE, text=True)
+    while not (tmp_path / "out.ndjson").exists() or not (tmp_path / "out.ndjson").stat().st_size:
+        time.sleep(0.05)
+    process.send_signal(signal.SIGTERM)
+    _, stderr = process.communicate(timeout=60)
+    assert process.returncode == 128 + signal.SIGTERM and "Stopped by SIGTERM" in stderr
+
+    done = Checkpoint.load(tmp_path / "job.ckpt").completed
+    assert 0 < len(done) < 200
+    result = run_cli(["scan", "src", "-f", "ndjson", "--output", "out.ndjson", "--checkpoint", "job.ckpt", "--resume"],
+                     cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    assert len((tmp_path / "out.ndjson").read_text(encoding="utf-8").splitlines()) == 200
@@ -0,0 +1,105 @@
+"""Tests for the semantic chunker."""
+
+from pathlib import Path
+
+from treesitter_tools.chunker import ChunkOptions, chunk_directory, chunk_file
+
+
+def _write(tmp_path: Path, name: str, content: str) -> Path:
+    f = tmp_path / name
+    f.write_text(content, encoding="utf-8")
+    return f
+
+
+def test_one_chunk_per_declaration_with_import_context(tmp_path):
+    f = _write(
+        tmp_path,
+        "mod.py",
+        "import os\n\nX = 1\n\ndef foo():\n    return os.sep\n\nclass Bar:\n    def baz(self):\n        pass\n",
+    )
+    chunks = chunk_file(f)
+    names = [(c.kind, c.name) for c in chunks]
+    assert names == [("module", "<module>"), ("function", "foo"), ("class", "Bar")]
+    assert all(c.context == "import os" for c in chunks)
+    assert chunks[1].content.startswith("def foo():")
+    assert chunks[1].start_line == 5
+
+
+def test_oversized_class_splits_into_methods_with_class_header(tmp_path):
+    body = "".join(f"    def m{i}(self):\n        return {i}\n\n" for i in range(20))
+    f = _write(tmp_path, "big.py", "class Big:\n" + body)
+    chunks = chunk_file(f, ChunkOptions(max_tokens=40))
+    assert len(chunks) > 1
+    assert all(c.kind == "method" for c in chunks)
+    assert chunks[0].name == "Big.m0"
+    assert all(c.context == "class Big:" for c in chunks)
+
+
+def test_oversized_function_splits_on_statements_without_breaking_lines(tmp_path):
+    stmts = "".join(f"    value_{i} = compute({i})\n" for i in range(60))
+    f = _write(tmp_path, "long.py", "def long():\n" + stmts)
+    chunks = chunk_file(f, ChunkOptions(max_tokens=80))
+    assert len(chunks) > 1
+    assert {c.name for c in chunks} == {"long"}
+    assert all(c.part_count == len(chunks) for c in chunks)
+    assert all(c.context.endswith("def long():") for c in chunks)
+    for c in chunks:
+        assert c.content.endswith("\n")
+        assert c.token_count <= 80
+    assert "".join(c.content for c in chunks) == f.read_text(encoding="utf-8")
+
+
+def test_overlap_repeats_previous_lines(tmp_path):
+    stmts = "".join(f"    value_{i} = compute({i})\n" for i in range(60))
+    f = _write(tmp_path, "long.py", "def long():\n" + stmts)
+    plain = chunk_file(f, ChunkOptions(max_tokens=80))
+    overlapped = chunk_file(f, ChunkOptions(max_tokens=80, overlap_lines=2))
+    assert overlapped[1].start_line == plain[1].start_line - 2
+
+
+def test_go_package_and_imports_in_context(tmp_path):
+    f = _write(
+        tmp_path,
+        "main.go",
+        'package main\n\nimport "fmt"\n\ntype Cache struct{}\n\nfunc (c *Cache) Get() { fmt.Println() }\n',
+    )
+    chunks = chunk_file(f, ChunkOptions(include_context=True))
+    assert chunks[0].context == 'package main\nimport "fmt"'
+    assert [(c.kind, c.name) for c in chunks] == [("class", "Cache"), ("method", "Cache.Get")]
+
+
+def test_chunk_directory_uses_relative_paths(tmp_path):
+    _write(tmp_path, "a.py", "def a():\n    pass\n")
+    chunks = chunk_directory(tmp_path, include=["**/*.py"])
+    assert chunks[0].path == "a.py"
+    assert chunks[0].index == 0
+
+
+def test_chunk_ids_survive_edits_to_other_chunks(tmp_path):
+    source = "import os\n\ndef keep():\n    return os.sep\n\ndef change():\n    return 1\n"
+    f = _write(tmp_path, "mod.py", source)
+    before = {c.name: c.id for c in chunk_file(f)}
+    _write(tmp_path, "mod.py", "import os\n\ndef added():\n    pass\n\n" + source[11:].replace("return 1", "return 2"))
+    after = {c.name: c.id for c in chunk_file(f)}
+    assert after["keep"] == before["keep"]
+    assert after["change"] != before["change"]
+    assert chunk_file(f)[0].to_dict()["id"] == after["added"]
+
+
+def test_links_connect_outlines_parents_and_siblings(tmp_path):
+    body = "".join(f"    def m{i}(self):\n        return {i}\n\n" for i in range(20))
+    f = _write(tmp_path, "mod.py", "import os\n\nX = 1\n\ndef foo():\n    return os.sep\n\nclass Big:\n" + body)
+    assert not any(c.parent_id or c.kind == "file" for c in chunk_file(f, ChunkOptions(max_tokens=40)))
+    chunks = chunk_file(f, ChunkOptions(max_tokens=40, links=True))
+    top, methods = chunks[:4], chunks[4:]
+    assert [c.kind for c in top] == ["file", "module", "function", "class"]
+    assert top[0].content == "import os\n\ndef foo():\nclass Big:"
+    assert top[3].content.startswith("class Big:\n    def m0(self):\n    def m1(self):\n")
+    assert len(methods) == 20 and all(c.kind == "method" for c in methods)
+    file_id, outline_id = top[0].id, top[3].id
+    assert top[0].parent_id is None and top[0].file_id is None
+    assert all(c.parent_id == file_id for c in top[1:]) and all(c.parent_id == outline_id for c in methods)
+    assert all(c.file_id == file_id for c in chunks[1:])
+    assert [(c.prev_id, c.next_id) for c in top[1:]] == [(None, top[2].id), (top[1].id, top[3].id), (top[2].id, None)]
+    assert methods[0].prev_id is None and methods[0].next_id == methods[1].id and methods[-1].next_id is None
+    assert methods[5].to_dict()["parent_id"] == outline_id
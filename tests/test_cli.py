@@ -1,30 +1,9 @@
 """Integration tests for CLI commands."""
 
 import json
-import subprocess
-import sys
 from pathlib import Path
 import pytest
-
-
-def run_cli(args, cwd=None):
-    """Helper to run treesitter-tools CLI."""
-    import os
-    
-    cmd = [sys.executable, "-m", "treesitter_tools.cli"] + args
-    env = os.environ.copy()
-    # Add src directory to PYTHONPATH
-    src_dir = Path(__file__).parent.parent / "src"
-    env["PYTHONPATH"] = str(src_dir) + os.pathsep + env.get("PYTHONPATH", "")
-    
-    result = subprocess.run(
-        cmd,
-        cwd=cwd or Path.cwd(),
-        capture_output=True,
-        text=True,
-        env=env,
-    )
-    return result
+from conftest import run_cli
 
 
 def test_cli_symbols_basic(tmp_path):
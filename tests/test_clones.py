@@ -0,0 +1,96 @@
+"""Tests for AST-fingerprint clone detection."""
+
+import json
+
+from treesitter_tools.clones import CloneLocation, _Candidate, clones_to_json, clones_to_text, find_clones, group_clones
+
+ORIGINAL = """\
+def total_price(items, tax):
+    subtotal = 0
+    for item in items:
+        if item.quantity > 0:
+            subtotal += item.price * item.quantity
+    return subtotal * (1 + tax)
+"""
+
+# Same code with a comment and different spacing: a Type-1 clone.
+REFORMATTED = """\
+def total_price(items, tax):
+    # sum positive lines
+    subtotal = 0
+    for item in items:
+        if item.quantity>0:
+            subtotal += item.price*item.quantity
+    return subtotal * (1 + tax)
+"""
+
+# Identifiers and literals changed: a Type-2 clone.
+RENAMED = """\
+def order_value(rows, rate):
+    acc = 1
+    for row in rows:
+        if row.count > 5:
+            acc += row.cost * row.count
+    return acc * (2 + rate)
+"""
+
+UNRELATED = """\
+def greet(name):
+    return "hello " + name
+"""
+
+
+def _write(tmp_path, files):
+    for name, text in files.items():
+        (tmp_path / name).write_text(text, encoding="utf-8")
+
+
+def test_type1_clone_ignores_layout_and_comments(tmp_path):
+    _write(tmp_path, {"a.py": ORIGINAL, "b.py": REFORMATTED, "c.py": UNRELATED})
+    groups = find_clones(tmp_path, min_nodes=20)
+    assert len(groups) == 1
+    group = groups[0]
+    assert group.clone_type == 1
+    assert [(loc.path, loc.node_type) for loc in group.locations] == [
+        ("a.py", "function_definition"),
+        ("b.py", "function_definition"),
+    ]
+
+
+def test_type2_clone_and_nested_groups_are_subsumed(tmp_path):
+    _write(tmp_path, {"a.py": ORIGINAL, "b.py": RENAMED})
+    groups = find_clones(tmp_path, min_nodes=10)
+    # Only the whole function is reported, not each matching loop/statement inside it.
+    assert len(groups) == 1
+    assert groups[0].clone_type == 2
+    assert groups[0].lines == 6
+
+
+def test_min_nodes_threshold(tmp_path):
+    _write(tmp_path, {"a.py": ORIGINAL, "b.py": RENAMED})
+    assert find_clones(tmp_path, min_nodes=1000) == []
+
+
+def test_group_keeps_partially_covered_groups():
+    def cand(fp, path, start, end, nodes):
+        return ("python", _Candidate(fp, fp + path, nodes, CloneLocation(path, start, end, "block")))
+
+    groups = group_clones(
+        [
+            cand("outer", "a.py", 1, 10, 50),
+            cand("outer", "b.py", 1, 10, 50),
+            cand("inner", "a.py", 2, 4, 20),
+            cand("inner", "b.py", 2, 4, 20),
+            cand("inner", "c.py", 7, 9, 20),
+        ]
+    )
+    assert [g.fingerprint for g in groups] == ["outer", "inner"]
+    assert [loc.path for loc in groups[1].locations] == ["a.py", "b.py", "c.py"]
+    payload = json.loads(clones_to_json(groups))
+    assert payload[0]["type"] == 2
+    assert payload[1]["lines"] == 3
+    assert clones_to_text(groups[:1]).splitlines() == [
+        "Clone group 1: type-2, 2 copies, 50 nodes, 10 lines",
+        "  a.py:1-10 block",
+        "  b.py:1-10 block",
+    ]
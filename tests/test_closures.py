@@ -0,0 +1,116 @@
+"""Tests for closures and anonymous functions as first-class symbols."""
+
+import json
+
+from conftest import run_cli
+
+from treesitter_tools import schema
+from treesitter_tools.callgraph import build_call_graph
+from treesitter_tools.core import extract_symbols, iter_function_nodes, parse_file
+from treesitter_tools.index import SymbolIndex
+
+CACHE_GO = """\
+package cache
+
+func NewCache(size int) *Cache {
+\tc := &Cache{}
+\tgo func() {
+\t\tc.evict(size)
+\t\tfunc() { cleanup() }()
+\t}()
+\tlog := func(msg string) { fmt.Println(msg) }
+\tlog("ready")
+\treturn c
+}
+
+func (c *Cache) Each(f func(string)) {
+\tc.forEach(func(key string) { f(key) })
+}
+"""
+
+HANDLERS_JS = """\
+function register(app, db) {
+  app.get("/", (req, res) => res.send(db.load()));
+  const render = (page) => page.toString();
+}
+[1, 2].forEach(function (n) { show(n); });
+"""
+
+SORT_PY = """\
+def sort_by(items, field):
+    return sorted(items, key=lambda item: getattr(item, field))
+
+
+square = lambda x: x * x
+"""
+
+
+def _write(tmp_path, name, text):
+    path = tmp_path / name
+    path.write_text(text, encoding="utf-8")
+    return path
+
+
+def _closures(path):
+    return {s.name: s for s in extract_symbols(path) if s.parent is not None or "$anon" in s.name}
+
+
+def test_go_closures_are_named_and_linked(tmp_path):
+    closures = _closures(_write(tmp_path, "cache.go", CACHE_GO))
+    assert sorted(closures) == ["Each$anon1", "NewCache$anon1", "NewCache$anon1$anon1", "log"]
+    goroutine = closures["NewCache$anon1"]
+    assert goroutine.kind == "function" and goroutine.start_line == 5
+    assert goroutine.parent == "NewCache" and goroutine.captures == ["c", "size"]
+    assert closures["NewCache$anon1$anon1"].parent == "NewCache$anon1"
+    assert closures["log"].captures == []  # uses only its own parameter
+    each = closures["Each$anon1"]
+    assert each.parent == "Cache.Each" and each.captures == ["f"]
+
+
+def test_iter_function_nodes_opt_in(tmp_path):
+    parsed = parse_file(_write(tmp_path, "cache.go", CACHE_GO))
+    assert [fn.qualified_name for fn in iter_function_nodes(parsed)] == ["NewCache", "Cache.Each"]
+    functions = {fn.qualified_name: fn for fn in iter_function_nodes(parsed, closures=True)}
+    assert functions["Cache.Each$anon1"].receiver == "c"  # the method's receiver is in scope
+    assert functions["Cache.Each$anon1"].anonymous and not functions["log"].anonymous
+
+
+def test_javascript_and_python_closures(tmp_path):
+    js = _closures(_write(tmp_path, "handlers.js", HANDLERS_JS))
+    assert sorted(js) == ["$anon1", "register$anon1", "render"]
+    assert js["register$anon1"].parent == "register" and js["register$anon1"].captures == ["db"]
+    assert js["$anon1"].parent is None  # top level
+
+    py = _closures(_write(tmp_path, "sort.py", SORT_PY))
+    assert py["sort_by$anon1"].captures == ["field"]
+    assert "square" in [s.name for s in extract_symbols(tmp_path / "sort.py")]
+
+
+def test_calls_inside_closures_belong_to_them(tmp_path):
+    _write(tmp_path, "cache.go", CACHE_GO)
+    graph = build_call_graph(tmp_path)
+    calls = {(e.caller, e.callee) for e in graph.edges}
+    assert ("NewCache$anon1", "evict") in calls and ("NewCache$anon1$anon1", "cleanup") in calls
+    assert not any(caller == "NewCache" and callee in {"evict", "cleanup"} for caller, callee in calls)
+    assert ("NewCache", "log") in calls and ("NewCache", "NewCache$anon1") in calls  # invoked in place
+    functions = {d.qualified_name: d.to_dict() for d in graph.definitions}
+    assert functions["NewCache$anon1"]["parent"] == "NewCache"
+    assert functions["NewCache$anon1"]["captures"] == ["c", "size"]
+    for data in graph.to_dict()["functions"]:
+        assert schema.validate("function", data) == []
+
+
+def test_index_and_schema(tmp_path):
+    _write(tmp_path, "cache.go", CACHE_GO)
+    with SymbolIndex(tmp_path / "index.db") as index:
+        index.update(tmp_path)
+        assert [r["kind"] for r in index.query("defs", "Each$anon1")] == ["function"]
+        assert {r["caller"] for r in index.query("callers", "cleanup")} == {"NewCache$anon1$anon1"}
+
+    result = run_cli(["symbols", "cache.go"], cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    symbols = json.loads(result.stdout)
+    for data in symbols:
+        assert schema.validate("symbol", data) == []
+    pinned = run_cli(["--schema-version", "1.1", "symbols", "cache.go"], cwd=tmp_path)
+    assert '"captures"' in result.stdout and '"captures"' not in pinned.stdout
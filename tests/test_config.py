@@ -0,0 +1,98 @@
+"""Tests for the project config file."""
+
+import json
+
+import pytest
+from conftest import run_cli
+
+from treesitter_tools.config import ConfigError, find_config, load_config
+
+CONFIG = """\
+include: ["src/**"]
+exclude: "**/skip_*.py"
+languages:
+  pyx: python
+chunk:
+  max_tokens: 64
+queries:
+  funcs:
+    language: python
+    query: "(function_definition name: (identifier) @name)"
+format:
+  scan: ndjson
+"""
+
+
+def test_load_and_find(tmp_path):
+    path = tmp_path / ".treesitter-tools.yaml"
+    path.write_text(CONFIG, encoding="utf-8")
+    (tmp_path / "nested").mkdir()
+    assert find_config(tmp_path / "nested") == path
+    config = load_config(path)
+    assert config.exclude == ["**/skip_*.py"]
+    assert config.command_defaults("chunk", ["include", "max_tokens", "overlap"]) == {
+        "include": ["src/**"], "max_tokens": 64,
+    }
+    assert config.command_defaults("scan", ["fmt"]) == {"fmt": "ndjson"}
+    assert config.query_for("funcs", "python").startswith("(function_definition")
+    assert config.query_for("funcs", "go") is None
+
+
+def test_validation_reports_every_problem(tmp_path):
+    path = tmp_path / "bad.yaml"
+    path.write_text("bogus: 1\nchunk:\n  max_tokens: 0\nqueries:\n  q:\n    file: missing.scm\n", encoding="utf-8")
+    with pytest.raises(ConfigError) as info:
+        load_config(path)
+    problems = " | ".join(info.value.problems)
+    assert "bogus" in problems and "max_tokens" in problems and "missing.scm" in problems
+
+
+def test_flag_beats_config(tmp_path):
+    (tmp_path / ".treesitter-tools.yaml").write_text(CONFIG, encoding="utf-8")
+    (tmp_path / "src").mkdir()
+    (tmp_path / "src" / "keep.pyx").write_text("def kept():\n    pass\n", encoding="utf-8")
+    (tmp_path / "src" / "skip_me.py").write_text("def skipped():\n    pass\n", encoding="utf-8")
+    (tmp_path / "other.py").write_text("def other():\n    pass\n", encoding="utf-8")
+
+    result = run_cli(["scan", "."], cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    records = [json.loads(line) for line in result.stdout.splitlines()]
+    assert [r["path"] for r in records] == ["src/keep.pyx"]
+
+    result = run_cli(["scan", ".", "--include", "*.py", "--format", "json"], cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    assert [r["path"] for r in json.loads(result.stdout)] == ["other.py"]
+
+    result = run_cli(["query", "src/keep.pyx", "--named", "funcs"], cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    assert json.loads(result.stdout)[0]["captures"][0]["text"] == "kept"
+
+
+def test_config_validate_command(tmp_path):
+    (tmp_path / ".treesitter-tools.yaml").write_text("format:\n  metrics: xml\n", encoding="utf-8")
+    result = run_cli(["config", "validate"], cwd=tmp_path)
+    assert result.returncode == 1
+    assert "format.metrics" in result.stderr
+    (tmp_path / ".treesitter-tools.yaml").write_text(CONFIG, encoding="utf-8")
+    result = run_cli(["config", "validate"], cwd=tmp_path)
+    assert result.returncode == 0
+    assert result.stdout.strip().endswith("OK")
+
+
+def test_in_process_runs_do_not_leak_config(tmp_path, monkeypatch):
+    from typer.testing import CliRunner
+
+    from treesitter_tools.cli import app
+    from treesitter_tools.core import LANGUAGE_MAPPINGS
+
+    configured, plain = tmp_path / "configured", tmp_path / "plain"
+    for directory in (configured, plain):
+        directory.mkdir()
+        (directory / "m.py").write_text("def f():\n    pass\n", encoding="utf-8")
+    (configured / ".treesitter-tools.yaml").write_text(CONFIG, encoding="utf-8")
+    runner = CliRunner()
+    monkeypatch.chdir(configured)
+    assert runner.invoke(app, ["query", "m.py", "--named", "funcs"]).exit_code == 0
+    assert "pyx" not in LANGUAGE_MAPPINGS  # the overrides ended with the command
+    monkeypatch.chdir(plain)
+    assert runner.invoke(app, ["query", "m.py", "--named", "funcs"]).exit_code == 1
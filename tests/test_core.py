@@ -1,6 +1,6 @@
 from pathlib import Path
 
-from treesitter_tools.core import extract_symbols, run_query, scan_directory
+from treesitter_tools.core import CodeSymbol, assign_symbol_ids, extract_symbols, run_query, scan_directory
 
 
 def test_extract_symbols_python(tmp_path: Path) -> None:
@@ -36,3 +36,67 @@ def test_scan_directory(tmp_path: Path) -> None:
     reports = scan_directory(root, include=["**/*.py"])
     assert len(reports) == 1
     assert reports[0].symbols
+
+
+def _hash_of(tmp_path: Path, name: str, text: str) -> str:
+    src = tmp_path / name
+    src.write_text(text, encoding="utf-8")
+    [symbol] = [s for s in extract_symbols(src) if s.kind == "function"]
+    return symbol.body_hash
+
+
+def test_body_hash_ignores_names_layout_and_comments(tmp_path: Path) -> None:
+    base = _hash_of(tmp_path, "a.py", "def add(a, b):\n    return a + b\n")
+    renamed = _hash_of(tmp_path, "b.py", "\n\n# moved here\ndef plus(a, b):\n    # sum\n    return a+b  # done\n")
+    changed = _hash_of(tmp_path, "c.py", "def add(a, b):\n    return a - b\n")
+    assert base and base == renamed
+    assert changed != base
+
+
+def test_body_hash_in_symbol_dict(tmp_path: Path) -> None:
+    src = tmp_path / "demo.py"
+    src.write_text("class A:\n    def f(self):\n        return 1\n", encoding="utf-8")
+    by_kind = {s.kind: s.to_dict() for s in extract_symbols(src)}
+    assert "body_hash" not in by_kind["class"]
+    assert len(by_kind["function"]["body_hash"]) == 32
+
+
+def _symbol(kind, name, start, end, content, **extra):
+    return CodeSymbol(kind, name, start, end, None, None, content=content, **extra)
+
+
+def test_symbol_ids_follow_qualified_name_and_content() -> None:
+    symbols = [
+        _symbol("class", "Cache", 1, 6, "class Cache:\n    def get(self): ...\n    def put(self): ..."),
+        _symbol("function", "get", 2, 3, "def get(self): ..."),
+        _symbol("function", "put", 4, 5, "def put(self): ..."),
+        _symbol("function", "get", 8, 9, "def get(self): ..."),  # module-level, same text as Cache.get
+    ]
+    assign_symbol_ids(symbols, "store.py")
+    ids = [s.id for s in symbols]
+    assert len(set(ids)) == 4 and all(len(i) == 32 for i in ids)
+    assert symbols[1].to_dict()["id"] == ids[1]
+
+    # Lines shift, the body is re-indented, a sibling changes: Cache.get keeps its ID.
+    moved = [
+        _symbol("class", "Cache", 11, 16, "class Cache:\n    def get(self): ...\n    def put(self): return 1"),
+        _symbol("function", "get", 12, 13, "    def get(self): ...   \n\n"),
+        _symbol("function", "put", 14, 15, "def put(self): return 1"),
+    ]
+    assign_symbol_ids(moved, "store.py")
+    assert moved[1].id == ids[1]
+    assert moved[2].id != ids[2] and moved[0].id != ids[0]
+
+    twins = [_symbol("function", "f", 1, 1, "def f(): pass"), _symbol("function", "f", 3, 3, "def f(): pass")]
+    assign_symbol_ids(twins, "twins.py")
+    assert twins[0].id != twins[1].id
+
+
+def test_scan_assigns_symbol_ids(tmp_path: Path) -> None:
+    (tmp_path / "a.py").write_text("def one():\n    return 1\n\ndef two():\n    return 2\n", encoding="utf-8")
+    before = {s.name: s.id for s in scan_directory(tmp_path)[0].symbols}
+    (tmp_path / "a.py").write_text("import os\n\n\ndef one():\n    return 1\n\ndef two():\n    return 3\n",
+                                   encoding="utf-8")
+    after = {s.name: s.id for s in scan_directory(tmp_path)[0].symbols}
+    assert after["one"] == before["one"]
+    assert after["two"] != before["two"]
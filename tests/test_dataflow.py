@@ -0,0 +1,105 @@
+"""Tests for intra-procedural parameter flow summaries."""
+
+import json
+
+from conftest import run_cli
+
+from treesitter_tools.dataflow import analyze_flows
+
+
+PYTHON = """\
+import os
+
+CACHE = {}
+
+
+class Runner:
+    def run(self, cmd, verbose):
+        full = "sh -c " + cmd
+        os.system(full)
+        return verbose
+
+
+def store(key, value, unused):
+    global LAST
+    LAST = value
+    CACHE[key] = value
+    return None
+
+
+def shadow(name):
+    name = "fixed"
+    print(name)
+
+
+def loop(items):
+    total = 0
+    acc = 0
+    for item in items:
+        acc = total
+        total = item
+    return acc
+"""
+
+
+def _by_name(tmp_path, name, text):
+    (tmp_path / name).write_text(text, encoding="utf-8")
+    return {s.function: s for s in analyze_flows(tmp_path / name)}
+
+
+def test_python_returns_calls_and_globals(tmp_path):
+    flows = _by_name(tmp_path, "app.py", PYTHON)
+    run = flows["Runner.run"]
+    assert run.parameters == ["cmd", "verbose"]  # `self` is not an input
+    assert run.returns == ["verbose"]
+    assert [(c.callee, c.argument, c.parameters) for c in run.calls] == [("os.system", 0, ["cmd"])]
+
+    store = flows["store"]
+    assert store.returns == []
+    assert {(w.name, tuple(w.parameters)) for w in store.global_writes} == {("LAST", ("value",)), ("CACHE[key]", ("value",))}
+    assert store.parameters == ["key", "value", "unused"]
+
+
+def test_taint_is_flow_insensitive(tmp_path):
+    flows = _by_name(tmp_path, "app.py", PYTHON)
+    # `name` is overwritten before the call, but taint is never cleared.
+    assert [c.callee for c in flows["shadow"].calls] == ["print"]
+    # `acc` only picks up `items` through the loop's second iteration.
+    assert flows["loop"].returns == ["items"]
+
+
+def test_javascript_arrow_and_free_global(tmp_path):
+    flows = _by_name(
+        tmp_path, "app.js",
+        "const twice = (x) => x * 2;\n"
+        "function save(req, res) {\n  window.last = req.body;\n  res.send(req.query.id);\n}\n",
+    )
+    assert flows["twice"].returns == ["x"]
+    save = flows["save"]
+    assert [(w.name, w.parameters) for w in save.global_writes] == [("window.last", ["req"])]
+    assert [(c.callee, c.parameters) for c in save.calls] == [("res.send", ["req"])]
+
+
+def test_go_receiver_is_not_a_parameter(tmp_path):
+    flows = _by_name(
+        tmp_path, "db.go",
+        "package db\n\nvar last string\n\n"
+        "func (s *Store) Query(q string) string {\n\tlast = q\n\treturn s.run(q)\n}\n",
+    )
+    query = flows["Store.Query"]
+    assert query.parameters == ["q"]
+    assert query.returns == ["q"]
+    assert [w.name for w in query.global_writes] == ["last"]
+
+
+def test_cli_dataflow_json_and_flows_only(tmp_path):
+    (tmp_path / "app.py").write_text(PYTHON, encoding="utf-8")
+    result = run_cli(["dataflow", str(tmp_path), "--format", "json", "--flows-only"])
+    assert result.returncode == 0, result.stderr
+    names = [s["function"] for s in json.loads(result.stdout)]
+    assert "Runner.run" in names and "store" in names
+
+    result = run_cli(["dataflow", str(tmp_path / "app.py"), "--function", "Runner.run"])
+    assert result.returncode == 0, result.stderr
+    assert "cmd -> os.system arg 0" in result.stdout
+    assert "verbose -> return" in result.stdout
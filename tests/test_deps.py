@@ -0,0 +1,80 @@
+"""Tests for the package dependency graph."""
+
+import json
+
+from treesitter_tools.deps import DependencyGraph, Package, build_dependency_graph
+
+
+def _graph(edges):
+    graph = DependencyGraph()
+    for source, target in edges:
+        graph.packages.setdefault(source, Package(source)).imports.add(target)
+        graph.packages.setdefault(target, Package(target)).importers.add(source)
+        graph.edges[(source, target)] = 1
+    return graph
+
+
+def test_cycles_are_strongly_connected_components():
+    graph = _graph([("a", "b"), ("b", "c"), ("c", "a"), ("c", "d"), ("d", "e"), ("e", "d")])
+    assert graph.cycles() == [["a", "b", "c"], ["d", "e"]]
+    assert graph.packages["c"].fan_out == 2
+    assert graph.packages["e"].instability == 0.5
+
+
+def test_dot_and_mermaid_mark_cycle_edges():
+    graph = _graph([("a", "b"), ("b", "a"), ("b", "c")])
+    dot = graph.to_dot()
+    assert '"a" -> "b" [color=red];' in dot
+    assert '"b" -> "c";' in dot
+    mermaid = graph.to_mermaid()
+    assert mermaid.startswith("graph LR\n")
+    assert "linkStyle 0,1 stroke:red" in mermaid
+
+
+def test_go_packages_resolve_through_go_mod(tmp_path):
+    (tmp_path / "go.mod").write_text("module example.com/app\n\ngo 1.22\n", encoding="utf-8")
+    (tmp_path / "store").mkdir()
+    (tmp_path / "api").mkdir()
+    (tmp_path / "main.go").write_text(
+        'package main\n\nimport (\n    "fmt"\n    "example.com/app/api"\n)\n\nfunc main() { fmt.Println(api.X) }\n',
+        encoding="utf-8",
+    )
+    (tmp_path / "api" / "api.go").write_text(
+        'package api\n\nimport "example.com/app/store"\n\nvar X = store.Y\n', encoding="utf-8"
+    )
+    (tmp_path / "store" / "store.go").write_text(
+        'package store\n\nimport "example.com/app/api"\n\nvar Y = 1\nvar _ = api.X\n', encoding="utf-8"
+    )
+    graph = build_dependency_graph(tmp_path)
+    data = json.loads(graph.to_json())
+    packages = {p["name"]: p for p in data["packages"]}
+    assert packages["."]["imports"] == ["api"]
+    assert packages["."]["external"] == ["fmt"]
+    assert packages["api"]["fan_in"] == 2
+    assert data["cycles"] == [["api", "store"]]
+
+
+def test_python_and_js_imports_resolve_to_directories(tmp_path):
+    (tmp_path / "app").mkdir()
+    (tmp_path / "app" / "__init__.py").write_text("", encoding="utf-8")
+    (tmp_path / "app" / "util").mkdir()
+    (tmp_path / "app" / "util" / "__init__.py").write_text("", encoding="utf-8")
+    (tmp_path / "app" / "util" / "text.py").write_text("import os\n", encoding="utf-8")
+    (tmp_path / "app" / "main.py").write_text(
+        "from .util import text\nimport app.util.text\nimport requests\n", encoding="utf-8"
+    )
+    (tmp_path / "web").mkdir()
+    (tmp_path / "web" / "lib").mkdir()
+    (tmp_path / "web" / "lib" / "format.ts").write_text("export const f = 1;\n", encoding="utf-8")
+    (tmp_path / "web" / "index.ts").write_text(
+        "import { f } from './lib/format';\nimport React from 'react';\nimport x from '@scope/pkg/sub';\n",
+        encoding="utf-8",
+    )
+    graph = build_dependency_graph(tmp_path)
+    assert graph.packages["app"].imports == {"app/util"}
+    assert graph.edges[("app", "app/util")] == 2
+    assert graph.packages["app"].external == {"requests"}
+    assert graph.packages["app/util"].external == {"os"}
+    assert graph.packages["web"].imports == {"web/lib"}
+    assert graph.packages["web"].external == {"react", "@scope/pkg"}
+    assert graph.cycles() == []
@@ -0,0 +1,66 @@
+"""Tests for content- and name-based language detection."""
+
+import pytest
+
+from treesitter_tools import core
+from treesitter_tools.detect import detect_file, filename_language, guess_language, modeline_language, shebang_language
+
+
+@pytest.mark.parametrize(
+    "line, language",
+    [
+        (b"#!/usr/bin/env python3\n", "python"),
+        (b"#! /usr/bin/python3.12 -u\n", "python"),
+        (b"#!/bin/sh -e\n", "bash"),
+        (b"#!/usr/bin/env -S node --no-warnings\n", "javascript"),
+        (b"#!/usr/bin/env LANG=C ruby\n", "ruby"),
+        (b"#!/usr/bin/make -f\n", "make"),
+        (b"#!/usr/bin/unknown\n", None),
+        (b"# not a shebang\n", None),
+    ],
+)
+def test_shebang_language(line, language):
+    assert shebang_language(line) == language
+
+
+def test_modeline_language():
+    assert modeline_language(b"# -*- mode: python; coding: utf-8 -*-\n") == "python"
+    assert modeline_language(b"; -*- Makefile -*-\n") == "make"
+    assert modeline_language(b"# -*- coding: utf-8 -*-\n") is None
+    assert modeline_language(b"echo hi\n", b"echo bye\n# vim: set ft=sh ts=4:\n") == "bash"
+    assert modeline_language(b"// vim: filetype=javascript\n") == "javascript"
+
+
+def test_filename_language():
+    assert filename_language("Dockerfile") == "dockerfile"
+    assert filename_language("deploy/Dockerfile.prod") == "dockerfile"
+    assert filename_language("GNUmakefile") == "make"
+    assert filename_language("rules.mk") == "make"
+    assert filename_language("pkg/BUILD.bazel") == "starlark"
+    assert filename_language("defs.bzl") == "starlark"
+    assert filename_language("CMakeLists.txt") == "cmake"
+    assert filename_language("notes.txt") is None
+
+
+def test_guess_language_reads_content(tmp_path):
+    (tmp_path / "deploy").write_text("#!/usr/bin/env bash\nset -e\n", encoding="utf-8")
+    (tmp_path / "page").write_text("<?php echo 1;\n", encoding="utf-8")
+    (tmp_path / "settings").write_text("# vim: ft=conf\n", encoding="utf-8")
+    (tmp_path / "blob").write_bytes(b"#!/bin/sh\n\x00\x01")
+    assert guess_language(tmp_path / "deploy").to_dict() == {"language": "bash", "method": "shebang"}
+    assert guess_language(tmp_path / "page").method == "content"
+    assert guess_language(tmp_path / "settings") is None  # not a language we parse
+    assert guess_language(tmp_path / "blob") is None
+    assert guess_language(tmp_path / "missing") is None
+
+
+def test_extension_wins_and_core_falls_back(tmp_path):
+    script = tmp_path / "tool.py"
+    script.write_text("#!/usr/bin/env ruby\n", encoding="utf-8")
+    assert detect_file(script, core.LANGUAGE_MAPPINGS).method == "extension"
+    assert core.detect_language(script) == "python"
+    runner = tmp_path / "run"
+    runner.write_text("#!/usr/bin/env python3\n\ndef main():\n    pass\n", encoding="utf-8")
+    assert core.detect_language(runner) == "python"
+    reports = core.scan_directory(tmp_path, include=["run"])
+    assert [s.name for s in reports[0].symbols] == ["main"]
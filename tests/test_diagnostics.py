@@ -0,0 +1,64 @@
+"""Tests for ERROR/MISSING node diagnostics."""
+
+import json
+
+from conftest import run_cli
+
+from treesitter_tools.core import parse_file
+from treesitter_tools.diagnostics import check_paths, file_diagnostics
+
+
+def test_clean_file_has_no_diagnostics(tmp_path):
+    path = tmp_path / "ok.py"
+    path.write_text("def ok():\n    return 1\n", encoding="utf-8")
+    assert file_diagnostics(parse_file(path)) == []
+
+
+def test_error_node_reports_range_and_expected(tmp_path):
+    path = tmp_path / "bad.py"
+    path.write_text("def ok():\n    return 1\n\ndef broken(:\n    pass\n", encoding="utf-8")
+    found = file_diagnostics(parse_file(path), "bad.py")
+    assert found
+    first = found[0]
+    assert first.path == "bad.py"
+    assert first.kind in {"error", "missing"}
+    assert first.line == 4
+    assert first.column >= 1
+    assert (first.end_line, first.end_column) >= (first.line, first.column)
+
+
+def test_missing_node_reported(tmp_path):
+    path = tmp_path / "main.go"
+    path.write_text("package main\n\nfunc main() {\n    x := []int{1, 2\n}\n", encoding="utf-8")
+    found = file_diagnostics(parse_file(path))
+    assert found
+    assert all(d.line >= 4 for d in found)
+
+
+def test_check_paths_skips_unknown_files(tmp_path):
+    (tmp_path / "notes.txt").write_text("def (", encoding="utf-8")
+    (tmp_path / "ok.py").write_text("x = 1\n", encoding="utf-8")
+    report = check_paths([tmp_path / "notes.txt", tmp_path])
+    assert report.checked == 1
+    assert report.diagnostics == []
+
+
+def test_cli_diagnostics_exit_codes(tmp_path):
+    bad = tmp_path / "bad.py"
+    bad.write_text("def broken(:\n    pass\n", encoding="utf-8")
+    result = run_cli(["diagnostics", str(bad)])
+    assert result.returncode == 1
+    assert result.stdout.startswith(f"{bad.as_posix()}:1:")
+    assert "Checked 1 files" in result.stderr
+
+    result = run_cli(["diagnostics", str(bad), "--format", "json", "--exit-zero"])
+    assert result.returncode == 0
+    data = json.loads(result.stdout)
+    assert data["checked"] == 1
+    assert data["files_with_errors"] == 1
+
+    good = tmp_path / "good.py"
+    good.write_text("x = 1\n", encoding="utf-8")
+    result = run_cli(["diagnostics", str(good)])
+    assert result.returncode == 0
+    assert result.stdout == ""
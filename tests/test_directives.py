@@ -0,0 +1,88 @@
+"""Tests for comment directive scanning."""
+
+import os
+import subprocess
+
+from treesitter_tools.directives import collect_directives, directives_to_text, parse_directives
+
+PY_SOURCE = """\
+# TODO(ann): split this module
+import os  # noqa: F401
+
+
+class Loader:
+    def load(self, path):
+        # FIXME @bob - handle missing files
+        return open(path).read()  # type: ignore[return-value]
+"""
+
+GO_SOURCE = """\
+package main
+
+//go:generate stringer -type=Pill
+type Pill int
+
+func run() {
+\t//nolint:errcheck // best effort
+\tcleanup()
+}
+"""
+
+
+def _git(cwd, *args, author="Ann", date="2024-01-01T00:00:00+00:00"):
+    subprocess.run(
+        ["git", "-c", f"user.name={author}", "-c", f"user.email={author.lower()}@example.com", *args],
+        cwd=cwd,
+        check=True,
+        capture_output=True,
+        env={**os.environ, "GIT_AUTHOR_DATE": date, "GIT_COMMITTER_DATE": date},
+    )
+
+
+def test_parse_directives():
+    assert list(parse_directives("# TODO(ann): split")) == [(0, 2, "marker", "TODO", "split", "ann", [])]
+    assert list(parse_directives("//nolint:errcheck,gosec // legacy")) == [
+        (0, 0, "suppression", "nolint", "legacy", None, ["errcheck", "gosec"])
+    ]
+    assert list(parse_directives("// eslint-disable-next-line no-console -- debug")) == [
+        (0, 3, "suppression", "eslint-disable-next-line", "debug", None, ["no-console"])
+    ]
+    assert [d[3:6] for d in parse_directives("/*\n * HACK[carol] temporary\n */")] == [("HACK", "temporary", "carol")]
+    # Prose that mentions a marker is not one.
+    assert list(parse_directives("# see the TODO list")) == []
+
+
+def test_python_directives_attach_to_code(tmp_path):
+    (tmp_path / "loader.py").write_text(PY_SOURCE, encoding="utf-8")
+    found = {d.tag: d for d in collect_directives(tmp_path, git=False)}
+    todo = found["TODO"]
+    assert (todo.line, todo.column, todo.assignee, todo.text, todo.symbol) == (1, 3, "ann", "split this module", None)
+    assert todo.node.type == "import_statement"
+    assert (found["noqa"].rules, found["noqa"].node.line) == (["F401"], 2)
+    fixme = found["FIXME"]
+    assert (fixme.assignee, fixme.text, fixme.symbol) == ("bob", "handle missing files", "Loader.load")
+    assert (fixme.node.type, fixme.node.line) == ("return_statement", 8)
+    assert found["type-ignore"].rules == ["return-value"]
+
+
+def test_go_directives_and_filters(tmp_path):
+    (tmp_path / "main.go").write_text(GO_SOURCE, encoding="utf-8")
+    found = collect_directives(tmp_path, git=False)
+    assert [(d.tag, d.category, d.line) for d in found] == [("go:generate", "pragma", 3), ("nolint", "suppression", 7)]
+    nolint = found[1]
+    assert (nolint.rules, nolint.text, nolint.symbol, nolint.node.line) == (["errcheck"], "best effort", "run", 8)
+    assert [d.tag for d in collect_directives(tmp_path, categories=["pragma"], git=False)] == ["go:generate"]
+    assert [d.tag for d in collect_directives(tmp_path, tags=["NOLINT"], git=False)] == ["nolint"]
+
+
+def test_owner_and_age_from_git(tmp_path):
+    _git(tmp_path, "init", "-q")
+    (tmp_path / "loader.py").write_text(PY_SOURCE, encoding="utf-8")
+    _git(tmp_path, "add", "-A")
+    _git(tmp_path, "commit", "-q", "-m", "one")
+    (tmp_path / "loader.py").write_text(PY_SOURCE + "# HACK: uncommitted\n", encoding="utf-8")
+    now = 1704067200 + 10 * 86400  # ten days after the commit
+    found = {d.tag: d for d in collect_directives(tmp_path, now=lambda: now)}
+    assert (found["TODO"].owner, found["TODO"].committed, found["TODO"].age_days) == ("Ann", "2024-01-01", 10)
+    assert found["HACK"].owner is None and found["HACK"].age_days is None
+    assert "loader.py:1:3 TODO(ann) split this module (Ann, 10d)\n" in directives_to_text(found.values())
@@ -0,0 +1,131 @@
+"""Tests for the static documentation generator."""
+
+from conftest import run_cli
+
+from treesitter_tools.docs import build_site
+from treesitter_tools.index import SymbolIndex
+
+COLL_GO = """\
+package coll
+
+// List holds items. Build one with [New]; see also `Pair`.
+type List[T any] struct {
+\titems []T
+}
+
+// Push appends v.
+func (l *List[T]) Push(v T) { l.items = append(l.items, v) }
+
+// New returns an empty List.
+func New[T any]() List[T] { return List[T]{} }
+
+type Pair struct{ Key string }
+
+func helper() {}
+"""
+
+MAIN_GO = """\
+package main
+
+// Run builds a list.
+func Run() {
+\tl := coll.New[int]()
+\tl.Push(1)
+}
+"""
+
+UTIL_PY = '''\
+"""Utilities."""
+
+
+class Store:
+    """Keeps <values> & returns them via `Store.get`."""
+
+    def get(self, key):
+        """Return the value for key."""
+        return self._read(key)
+
+    def _read(self, key):
+        return key
+
+
+def _private():
+    pass
+'''
+
+
+def _project(tmp_path):
+    (tmp_path / "coll").mkdir()
+    (tmp_path / "coll" / "coll.go").write_text(COLL_GO, encoding="utf-8")
+    (tmp_path / "main.go").write_text(MAIN_GO, encoding="utf-8")
+    (tmp_path / "util.py").write_text(UTIL_PY, encoding="utf-8")
+    return tmp_path
+
+
+def _site(tmp_path, **options):
+    with SymbolIndex(tmp_path / "index.db") as index:
+        index.update(_project(tmp_path))
+        return build_site(index, **options)
+
+
+def test_packages_and_exported_entries(tmp_path):
+    site = _site(tmp_path)
+    packages = {p.name: p for p in site.packages}
+    assert sorted(packages) == [".", "coll", "util"]
+    coll = packages["coll"]
+    assert [e.qualified_name for e in coll.entries] == ["List", "New", "Pair"]  # helper is unexported
+    assert [m.qualified_name for m in coll.entries[0].members] == ["List.Push"]
+    store = packages["util"].entries[0]
+    assert store.qualified_name == "Store" and [m.name for m in store.members] == ["get"]  # _read is private
+    assert coll.entries[1].summary == "New returns an empty List."
+
+
+def test_private_option(tmp_path):
+    site = _site(tmp_path, private=True)
+    names = {e.qualified_name for e in site.entries()}
+    assert {"helper", "_private", "Store._read"} <= names
+
+
+def test_references_link_across_packages(tmp_path):
+    site = _site(tmp_path)
+    entries = {e.qualified_name: e for e in site.entries()}
+    assert entries["New"] in entries["Run"].uses
+    assert entries["Run"] in entries["New"].used_by
+    assert entries["List.Push"] in entries["Run"].uses  # receiver type unknown; the only Push
+    assert site.resolve("coll.New", entries["Run"].package) is entries["New"]
+
+
+def test_markdown_pages(tmp_path):
+    pages = _site(tmp_path, source_url="https://example.com/{path}#L{line}").pages("markdown")
+    assert set(pages) == {"index.md", "coll.md", "_root.md", "util.md"}
+    assert "| [`coll`](coll.md) | go | 4 |" in pages["index.md"]
+    coll = pages["coll.md"]
+    assert "List holds items. Build one with [New](#New); see also [`Pair`](#Pair)." in coll
+    assert '<a id="List.Push"></a>\n\n### method `List.Push`' in coll
+    assert "Used by: [`Run`](_root.md#Run)" in coll
+    assert "Source: [coll/coll.go:4](https://example.com/coll/coll.go#L4)" in coll
+    assert "```go\nfunc New[T any]() List[T] { return List[T]{} }\n```" in coll
+
+
+def test_html_pages_escape_and_link(tmp_path):
+    pages = _site(tmp_path).pages("html")
+    util = pages["util.html"]
+    assert util.startswith("<!DOCTYPE html>")
+    assert 'Keeps &lt;values&gt; &amp; returns them via <a href="#Store.get"><code>Store.get</code></a>.' in util
+    assert '<h3 id="Store.get">method <code>Store.get</code></h3>' in util
+    assert '<a href="#List">List</a>[T]' in pages["coll.html"]
+
+
+def test_cli_docs(tmp_path):
+    _project(tmp_path)
+    result = run_cli(["docs", ".", "--output-dir", "site", "--format", "html"], cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    assert "Wrote 4 pages (3 packages" in result.stderr
+    assert sorted(p.name for p in (tmp_path / "site").iterdir()) == ["_root.html", "coll.html", "index.html", "util.html"]
+
+    result = run_cli(["docs", ".", "--db", "docs.db", "-o", "md"], cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    assert (tmp_path / "docs.db").exists() and (tmp_path / "md" / "index.md").exists()
+
+    result = run_cli(["docs", ".", "--format", "pdf"], cwd=tmp_path)
+    assert result.returncode == 1 and "Unsupported format 'pdf'" in result.stderr
@@ -117,3 +117,65 @@ public class Foo {
     foo_class = next(s for s in symbols if s.name == "Foo")
     assert foo_class.docstring is not None
     assert "Javadoc comment" in foo_class.docstring
+
+
+def test_doc_field_go_comment_block(tmp_path):
+    content = """package main
+
+// Cache stores values.
+// It is safe for concurrent use.
+type Cache struct{}
+
+// unrelated
+
+func (c *Cache) Get() int { return 1 } // fast path
+"""
+    f = tmp_path / "cache.go"
+    f.write_text(content, encoding="utf-8")
+    symbols = {s.name: s for s in api.list_symbols(f)}
+    assert symbols["Cache"].doc == "Cache stores values.\nIt is safe for concurrent use."
+    assert symbols["Get"].doc is None
+    assert symbols["Get"].trailing_comment == "fast path"
+
+
+def test_doc_field_jsdoc_and_export(tmp_path):
+    content = """/**
+ * Add two numbers.
+ * @param {number} a
+ */
+export function add(a, b) { return a + b; }
+"""
+    f = tmp_path / "math.js"
+    f.write_text(content, encoding="utf-8")
+    (sym,) = api.list_symbols(f)
+    assert sym.doc == "Add two numbers.\n@param {number} a"
+
+
+def test_doc_field_python_docstring_dedented(tmp_path):
+    content = '''
+@decorator
+def foo():
+    """Summary line.
+
+    Detail:
+        indented item.
+    """
+    pass
+'''
+    f = tmp_path / "mod.py"
+    f.write_text(content, encoding="utf-8")
+    (sym,) = api.list_symbols(f)
+    assert sym.doc == "Summary line.\n\nDetail:\n    indented item."
+    assert sym.to_dict()["doc"] == sym.doc
+
+
+def test_doc_field_rust_skips_attributes(tmp_path):
+    content = """// not a doc comment
+/// Parses input.
+#[inline]
+pub fn parse() {}
+"""
+    f = tmp_path / "lib.rs"
+    f.write_text(content, encoding="utf-8")
+    (sym,) = api.list_symbols(f)
+    assert sym.doc == "Parses input."
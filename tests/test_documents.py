@@ -0,0 +1,113 @@
+"""Tests for symbols of code in Markdown files and Jupyter notebooks."""
+
+import json
+from pathlib import Path
+
+from conftest import run_cli
+
+from treesitter_tools.core import CodeSymbol, detect_language, extract_symbols
+from treesitter_tools.documents import block_language, notebook_cells
+
+DOC = """# Tutorial
+
+Install it, then:
+
+```python
+def greet(name):
+    return "hi " + name
+```
+
+```console
+$ greet world
+```
+
+- A list item with code:
+
+  ```js
+  function add(a, b) { return a + b; }
+  ```
+
+> ```{python}
+> class Point:
+>     pass
+> ```
+"""
+
+
+def _notebook(*cells, language="python"):
+    return json.dumps({
+        "nbformat": 4,
+        "nbformat_minor": 5,
+        "metadata": {"kernelspec": {"name": "python3", "language": language}},
+        "cells": list(cells),
+    })
+
+
+def _cell(source, cell_type="code", cell_id=None, **metadata):
+    cell = {"cell_type": cell_type, "source": source, "metadata": metadata}
+    if cell_id:
+        cell["id"] = cell_id
+    return cell
+
+
+def test_block_language():
+    assert block_language("python") == "python"
+    assert block_language("{python echo=FALSE}") == "python"
+    assert block_language('js title="add.js"') == "javascript"
+    assert block_language("  ") is None
+
+
+def test_markdown_blocks(tmp_path):
+    doc = tmp_path / "tutorial.md"
+    doc.write_text(DOC, encoding="utf-8")
+    symbols = {s.name: s for s in extract_symbols(doc)}
+    assert set(symbols) == {"greet", "add", "Point"}
+    greet, add, point = symbols["greet"], symbols["add"], symbols["Point"]
+    assert (greet.language, greet.block, greet.start_line, greet.end_line) == ("python", 0, 6, 7)
+    assert (add.language, add.block, add.start_line) == ("javascript", 2, 17)
+    assert (point.kind, point.block, point.start_line) == ("class", 3, 21)
+    assert point.content.startswith("class Point:\n    pass")
+    assert greet.to_dict()["block"] == 0 and "cell_id" not in greet.to_dict()
+    assert CodeSymbol.from_dict(add.to_dict()).block == 2
+
+
+def test_notebook_cells():
+    notebook = _notebook(
+        _cell("# Title", "markdown"),
+        _cell(["%matplotlib inline\n", "!pip install x\n", "def f():\n", "    return 1\n"], cell_id="a1"),
+        _cell("%%bash\nfoo() { echo hi; }\n", cell_id="b2"),
+        _cell("%%time\nx = 1\n"),
+    )
+    blocks = notebook_cells(notebook.encode("utf-8"))
+    assert [(b.index, b.language, b.cell_id) for b in blocks] == [(1, "python", "a1"), (2, "bash", "b2"),
+                                                                  (3, "python", None)]
+    assert blocks[0].code == "\n\ndef f():\n    return 1\n"
+    assert blocks[1].code == "\nfoo() { echo hi; }\n"
+
+
+def test_notebook_symbols(tmp_path):
+    path = tmp_path / "analysis.ipynb"
+    path.write_text(_notebook(
+        _cell("Intro", "markdown"),
+        _cell("import math\n\n\ndef area(r):\n    return math.pi * r * r\n", cell_id="c-area"),
+        _cell("function show(x) { console.log(x); }", cell_id="c-js", vscode={"languageId": "javascript"}),
+    ), encoding="utf-8")
+    assert detect_language(path) == "notebook"
+    symbols = extract_symbols(path)
+    assert [(s.name, s.language, s.block, s.cell_id, s.start_line) for s in symbols] == [
+        ("area", "python", 1, "c-area", 4),
+        ("show", "javascript", 2, "c-js", 1),
+    ]
+
+
+def test_scan_reports_document_provenance(tmp_path):
+    (tmp_path / "docs").mkdir()
+    (tmp_path / "docs" / "guide.md").write_text(DOC, encoding="utf-8")
+    (tmp_path / "docs" / "broken.ipynb").write_text("{not json", encoding="utf-8")
+    result = run_cli(["scan", "docs", "--format", "ndjson", "--verbose"], cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    reports = {Path(r["path"]).name: r for r in map(json.loads, result.stdout.splitlines())}
+    guide = reports["guide.md"]
+    assert guide["language"] == "markdown"
+    assert [(s["name"], s["block"]) for s in guide["symbols"]] == [("greet", 0), ("add", 2), ("Point", 3)]
+    assert "Not a Jupyter notebook" in result.stdout + result.stderr
@@ -0,0 +1,98 @@
+"""Tests for LSP-shaped folding ranges, document symbols, and selection ranges."""
+
+import json
+
+from treesitter_tools.core import parse_file
+import pytest
+from conftest import run_cli
+
+from treesitter_tools.editor import document_symbols, folding_ranges, selection_range
+
+PY_SOURCE = '''\
+import os
+# first
+# second
+class Greeter:
+    @staticmethod
+    def make(
+        name,
+    ):
+        return Greeter()
+
+    def __init__(self):
+        self.names = [
+            "é",
+        ]
+
+
+def main():
+    pass
+'''
+
+
+def _parsed(tmp_path, name="app.py", source=PY_SOURCE):
+    path = tmp_path / name
+    path.write_text(source, encoding="utf-8")
+    return parse_file(path)
+
+
+def test_document_symbols_nest(tmp_path):
+    symbols = [s.to_dict() for s in document_symbols(_parsed(tmp_path))]
+    assert [(s["name"], s["kind"]) for s in symbols] == [("Greeter", 5), ("main", 12)]
+    children = symbols[0]["children"]
+    assert [(c["name"], c["kind"]) for c in children] == [("make", 6), ("__init__", 9)]
+    assert children[0]["range"]["start"] == {"line": 4, "character": 4}  # the decorator line
+    assert children[0]["selectionRange"]["start"] == {"line": 5, "character": 8}
+    assert children[0]["detail"] == "( name, )"
+    assert "children" not in symbols[1]
+
+
+def test_folding_ranges(tmp_path):
+    ranges = [r.to_dict() for r in folding_ranges(_parsed(tmp_path))]
+    assert {"startLine": 1, "endLine": 2, "kind": "comment"} in ranges
+    spans = [(r["startLine"], r["endLine"]) for r in ranges]
+    assert (3, 13) in spans and (11, 13) in spans and (16, 17) in spans
+    assert all(r["endLine"] > r["startLine"] for r in ranges)
+
+
+def test_utf16_characters(tmp_path):
+    parsed = _parsed(tmp_path, source='s = "😀"; t = [\n  1]\n')
+    utf16 = {(r.start_line, r.end_line): r for r in folding_ranges(parsed)}[(0, 1)]
+    utf8 = {(r.start_line, r.end_line): r for r in folding_ranges(parsed, "utf-8")}[(0, 1)]
+    assert (utf16.start_character, utf8.start_character) == (14, 16)
+
+
+def test_rust_impl_groups_methods(tmp_path):
+    source = "struct S;\nimpl Drop for S {\n    fn drop(&mut self) {}\n}\n"
+    symbols = document_symbols(_parsed(tmp_path, "lib.rs", source))
+    assert [s.name for s in symbols] == ["S", "impl Drop for S"]
+    assert [(c.name, c.kind) for c in symbols[1].children] == [("drop", 6)]
+
+
+def test_selection_range_expands_to_file(tmp_path):
+    selection = selection_range(_parsed(tmp_path), 8, 17)  # inside `Greeter` of `return Greeter()`
+    steps = selection.chain()
+    assert steps[0].node_type == "identifier"
+    assert steps[0].range == {"start": {"line": 8, "character": 15}, "end": {"line": 8, "character": 22}}
+    types = [s.node_type for s in steps]
+    assert types.index("call") < types.index("return_statement") < types.index("function_definition")
+    assert types.index("function_definition") < types.index("class_definition") < types.index("module") == len(types) - 1
+    ranges = [(s.range["start"]["line"], s.range["start"]["character"], s.range["end"]["line"], s.range["end"]["character"]) for s in steps]
+    assert len(set(ranges)) == len(ranges)  # every step grows the selection
+    nested = selection.to_dict()
+    assert nested["range"] == steps[0].range and nested["parent"]["range"] == steps[1].range
+    with pytest.raises(ValueError, match="Unsupported encoding"):
+        selection_range(_parsed(tmp_path), 0, 0, "latin-1")
+
+
+def test_cli(tmp_path):
+    (tmp_path / "app.py").write_text(PY_SOURCE, encoding="utf-8")
+    for command in ("folding-ranges", "document-symbols"):
+        result = run_cli([command, "app.py"], cwd=tmp_path)
+        assert result.returncode == 0, result.stderr
+        assert json.loads(result.stdout)
+
+    result = run_cli(["selection-range", "app.py", "--line", "8", "--character", "17"], cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    steps = json.loads(result.stdout)
+    assert steps[0]["type"] == "identifier" and steps[-1]["type"] == "module"
@@ -0,0 +1,158 @@
+"""Tests for the embeddings pipeline."""
+
+import json
+import threading
+from http.server import BaseHTTPRequestHandler, ThreadingHTTPServer
+
+import pytest
+
+from treesitter_tools.chunker import Chunk
+from treesitter_tools.embeddings import (
+    EmbeddingError,
+    EmbeddingStore,
+    EndpointConfig,
+    embed_chunks,
+    http_embedder,
+    pack_vector,
+    unpack_vector,
+)
+
+
+def _chunk(path, index, content):
+    return Chunk(path=path, language="python", kind="function", name=f"f{index}", start_line=1,
+                 end_line=2, content=content, index=index, token_count=3)
+
+
+def _fake_embed(calls, fail_after=None):
+    def embed(texts):
+        if fail_after is not None and len(calls) >= fail_after:
+            raise EmbeddingError("boom")
+        calls.append(list(texts))
+        return [[float(len(t)), 1.0] for t in texts]
+    return embed
+
+
+def test_vector_round_trip():
+    assert unpack_vector(pack_vector([0.5, -1.0, 2.25])) == [0.5, -1.0, 2.25]
+
+
+def test_resume_after_failure_and_skip_unchanged(tmp_path):
+    db = tmp_path / "index.db"
+    chunks = [_chunk("a.py", i, f"def f{i}(): pass") for i in range(5)]
+    calls = []
+    with EmbeddingStore(db) as store:
+        stats = embed_chunks(chunks, store, _fake_embed(calls, fail_after=1), "m", batch_size=2)
+        assert stats.failed == "boom"
+        assert stats.embedded == 2
+        assert stats.removed == 0
+
+    calls = []
+    with EmbeddingStore(db) as store:
+        stats = embed_chunks(chunks, store, _fake_embed(calls), "m", batch_size=2)
+        assert (stats.embedded, stats.skipped, stats.failed) == (3, 2, None)
+        assert [len(batch) for batch in calls] == [2, 1]
+        rows = store.vectors("m")
+        assert [(r["path"], r["chunk_index"]) for r in rows] == [("a.py", i) for i in range(5)]
+        assert rows[0]["vector"] == [float(len("def f0(): pass")), 1.0]
+
+
+def test_changed_and_removed_chunks(tmp_path):
+    db = tmp_path / "index.db"
+    with EmbeddingStore(db) as store:
+        embed_chunks([_chunk("a.py", 0, "x"), _chunk("b.py", 0, "y")], store, _fake_embed([]), "m")
+        calls = []
+        stats = embed_chunks([_chunk("a.py", 0, "x changed")], store, _fake_embed(calls), "m")
+        assert stats.embedded == 1
+        assert stats.removed == 1
+        assert calls == [["x changed"]]
+        assert [r["path"] for r in store.vectors("m")] == ["a.py"]
+
+
+class _Handler(BaseHTTPRequestHandler):
+    responses = []
+    requests = []
+
+    def do_POST(self):
+        body = json.loads(self.rfile.read(int(self.headers["Content-Length"])))
+        type(self).requests.append((self.path, self.headers.get("Authorization"), body))
+        status, payload = type(self).responses.pop(0)
+        self.send_response(status)
+        self.send_header("Content-Type", "application/json")
+        self.end_headers()
+        self.wfile.write(json.dumps(payload).encode("utf-8"))
+
+    def log_message(self, *args):
+        pass
+
+
+@pytest.fixture
+def endpoint():
+    _Handler.responses, _Handler.requests = [], []
+    server = ThreadingHTTPServer(("127.0.0.1", 0), _Handler)
+    thread = threading.Thread(target=server.serve_forever, daemon=True)
+    thread.start()
+    yield f"http://127.0.0.1:{server.server_address[1]}/v1"
+    server.shutdown()
+    server.server_close()
+
+
+def test_http_embedder_retries_server_errors(endpoint):
+    _Handler.responses = [
+        (503, {"error": "busy"}),
+        (200, {"data": [{"index": 1, "embedding": [2.0]}, {"index": 0, "embedding": [1.0]}]}),
+    ]
+    sleeps = []
+    embed = http_embedder(EndpointConfig(url=endpoint, model="m", api_key="k", retries=2), sleep=sleeps.append)
+    assert embed(["a", "b"]) == [[1.0], [2.0]]
+    assert sleeps == [1.0]
+    path, auth, body = _Handler.requests[-1]
+    assert path == "/v1/embeddings"
+    assert auth == "Bearer k"
+    assert body == {"model": "m", "input": ["a", "b"]}
+
+
+def test_http_embedder_client_error_is_not_retried(endpoint):
+    _Handler.responses = [(400, {"error": "bad model"})]
+    embed = http_embedder(EndpointConfig(url=endpoint, retries=3), sleep=lambda _: None)
+    with pytest.raises(EmbeddingError, match="HTTP 400"):
+        embed(["a"])
+    assert len(_Handler.requests) == 1
+
+
+def test_identical_chunks_share_a_vector(tmp_path):
+    db = tmp_path / "index.db"
+    with EmbeddingStore(db) as store:
+        embed_chunks([_chunk("a.py", 0, "same"), _chunk("b.py", 0, "same")], store, _fake_embed([]), "m")
+        assert store.conn.execute("SELECT COUNT(*) FROM vectors").fetchone()[0] == 1
+        assert [r["vector"] for r in store.vectors("m")] == [[4.0, 1.0], [4.0, 1.0]]
+        embed_chunks([_chunk("a.py", 0, "same")], store, _fake_embed([]), "m")
+        assert store.collect_vectors() == 0  # a.py still uses it
+        embed_chunks([], store, _fake_embed([]), "m")
+        assert store.collect_vectors() == 1
+
+
+def test_inline_vectors_are_moved_to_the_shared_table(tmp_path):
+    import sqlite3
+
+    db = tmp_path / "index.db"
+    conn = sqlite3.connect(str(db))
+    conn.execute(
+        "CREATE TABLE embeddings (id INTEGER PRIMARY KEY, model TEXT NOT NULL, path TEXT NOT NULL,"
+        " chunk_index INTEGER NOT NULL, kind TEXT NOT NULL, name TEXT NOT NULL, start_line INTEGER NOT NULL,"
+        " end_line INTEGER NOT NULL, token_count INTEGER NOT NULL, sha256 TEXT NOT NULL,"
+        " dimensions INTEGER NOT NULL, vector BLOB NOT NULL, UNIQUE (model, path, chunk_index))"
+    )
+    conn.execute("CREATE INDEX embeddings_path ON embeddings(path)")
+    for path in ("a.py", "b.py", "c.py"):
+        conn.execute(
+            "INSERT INTO embeddings VALUES (NULL, 'm', ?, 0, 'function', 'f', 1, 2, 3, ?, 1, ?)",
+            (path, "h2" if path == "c.py" else "h1", pack_vector([2.0 if path == "c.py" else 1.0])),
+        )
+    conn.commit()
+    conn.close()
+    with EmbeddingStore(db) as store:
+        assert store.merged == 1
+        assert [(r["path"], r["vector"]) for r in store.vectors("m")] == [("a.py", [1.0]), ("b.py", [1.0]),
+                                                                          ("c.py", [2.0])]
+    with EmbeddingStore(db) as store:
+        assert store.merged == 0 and store.shared() == 1
@@ -0,0 +1,108 @@
+"""Tests for per-file failure categories, the failure report, and --strict."""
+
+import json
+from pathlib import Path
+
+import pytest
+from conftest import run_cli
+
+from treesitter_tools import failures
+from treesitter_tools.core import parse_file, scan_directory
+from treesitter_tools.failures import (
+    BinaryFileError,
+    FailureReport,
+    FileTooLargeError,
+    GrammarUnavailableError,
+    NotSourceError,
+    category_of,
+    collect_failures,
+)
+from treesitter_tools.chunker import ChunkOptions, chunk_directory
+
+
+def _tree(root):
+    (root / "src").mkdir()
+    (root / "src" / "ok.py").write_text("def ok():\n    return 1\n", encoding="utf-8")
+    (root / "src" / "blob.py").write_bytes(b"import os\x00\x01")
+    (root / "src" / "huge.py").write_text("x = 1\n" * 400, encoding="utf-8")
+    (root / "src" / "README").write_text("not code\n", encoding="utf-8")
+    (root / "src" / "logo.png").write_bytes(b"\x89PNG\x00\x00")
+
+
+def test_category_of():
+    assert category_of(FileTooLargeError("big")) == "too_large"
+    assert category_of(BinaryFileError("nul")) == "binary"
+    assert category_of(GrammarUnavailableError("no grammar")) == "unsupported_language"
+    assert category_of(NotSourceError("README")) == "not_source"
+    assert category_of(PermissionError("denied")) == "unreadable"
+    assert category_of(UnicodeDecodeError("utf-8", b"\xff", 0, 1, "bad")) == "unreadable"
+    assert category_of(KeyError("bug")) == "parse_failure"
+    # Callers that caught ValueError/RuntimeError before still do.
+    assert isinstance(BinaryFileError("x"), ValueError) and isinstance(GrammarUnavailableError("x"), RuntimeError)
+
+
+def test_failure_report():
+    report = FailureReport()
+    report.record("a.py", BinaryFileError("nul"))
+    report.record("a.py", OSError("second failure of the same file"))
+    report.record("b.py", FileTooLargeError("big"))
+    report.record("c.py", OSError("gone"))
+    report.record("README", NotSourceError("not source"))
+    report.add("d.py", "no-such-category", "boom")
+    assert len(report) == 4
+    assert report.counts() == {"unreadable": 1, "too_large": 1, "binary": 1, "parse_failure": 1}
+    assert report.summary() == "1 unreadable, 1 too_large, 1 binary, 1 parse_failure"
+    data = report.to_dict()
+    assert data["total"] == 4
+    assert data["failures"][0] == {"path": "a.py", "category": "binary", "message": "nul"}
+
+
+def test_parse_file_raises_typed_errors(tmp_path):
+    binary = tmp_path / "blob.py"
+    binary.write_bytes(b"import os\x00")
+    image = tmp_path / "logo.png"
+    image.write_bytes(b"\x89PNG\x00")
+    with pytest.raises(BinaryFileError, match="Refusing to parse binary file"):
+        parse_file(binary)
+    with pytest.raises(NotSourceError, match="Refusing to parse binary file"):
+        parse_file(image)
+    with pytest.raises(FileTooLargeError, match="exceeds --max-file-size"):
+        parse_file(binary, max_file_size=4)
+
+
+def test_walks_record_failures(tmp_path):
+    _tree(tmp_path)
+    assert failures.ACTIVE is None
+    with collect_failures() as report:
+        reports = scan_directory(tmp_path / "src", max_file_size=1000)
+    assert failures.ACTIVE is None
+    kinds = {r.path.name: r.error_kind for r in reports if r.error}
+    assert kinds == {"blob.py": "binary", "huge.py": "too_large", "README": "not_source", "logo.png": "not_source"}
+    assert next(r for r in reports if r.path.name == "huge.py").to_dict()["error_kind"] == "too_large"
+    assert {Path(f.path).name: f.category for f in report.failures} == {"blob.py": "binary", "huge.py": "too_large"}
+
+    with collect_failures() as report:
+        chunks = chunk_directory(tmp_path / "src", ChunkOptions())
+    assert {c.path for c in chunks} >= {"ok.py"}
+    assert [Path(f.path).name for f in report.failures] == ["blob.py"]
+
+
+def test_cli_strict_and_error_report(tmp_path):
+    _tree(tmp_path)
+    relaxed = run_cli(["scan", "src", "--max-file-size", "1KB"], cwd=tmp_path)
+    assert relaxed.returncode == 0, relaxed.stderr
+    assert "Encountered 4 errors (1 too_large, 1 binary, 2 not_source)" in relaxed.stderr
+
+    result = run_cli(["--strict", "--error-report", "failures.json", "scan", "src", "--format", "ndjson"],
+                     cwd=tmp_path)
+    assert result.returncode == 1
+    records = [json.loads(line) for line in result.stdout.splitlines()]
+    assert {Path(r["path"]).name: r.get("error_kind") for r in records}["blob.py"] == "binary"
+    assert "--strict: 1 files could not be read or parsed" in result.stderr
+    report = json.loads((tmp_path / "failures.json").read_text(encoding="utf-8"))
+    assert report["counts"] == {"binary": 1}
+    assert Path(report["failures"][0]["path"]).name == "blob.py"
+
+    chunk = run_cli(["chunk", "src"], cwd=tmp_path)
+    assert chunk.returncode == 0, chunk.stderr
+    assert "Skipped 1 files: 1 binary" in chunk.stderr
@@ -0,0 +1,80 @@
+"""Tests for generated-code and vendored-path detection."""
+
+import json
+from pathlib import Path
+
+import pytest
+from conftest import run_cli
+
+from treesitter_tools.generated import generated_reason, is_generated, is_vendored
+
+
+@pytest.mark.parametrize(
+    "name, head",
+    [
+        ("api.go", b"// Code generated by protoc-gen-go. DO NOT EDIT.\npackage api\n"),
+        ("schema.ts", b"/**\n * @generated SignedSource<<abc>>\n */\nexport type T = 1;\n"),
+        ("Form.cs", b"//------\n// <auto-generated>\n//     Tool version 4.0\n// </auto-generated>\n"),
+        ("0001_initial.py", b"# -*- coding: utf-8 -*-\n# Generated by Django 4.2 on 2023-01-01\n"),
+        ("bundle.js", b"var a=1;" * 200),
+        ("service.pb.go", b"package service\n"),
+        ("messages_pb2.py", b"import sys\n"),
+    ],
+)
+def test_generated_files(name, head):
+    assert is_generated(Path(name), head)
+
+
+def test_ordinary_files_are_not_generated():
+    assert generated_reason(Path("app.py"), b'"""Helpers for generated reports."""\nNAME = "@generated"\n') is None
+    assert generated_reason(Path("app.js"), b"function add(a, b) {\n  return a + b;\n}\n" * 50) is None
+    assert generated_reason(Path("service.pb.go"), b"") == "file name matches *.pb.go"
+
+
+def test_vendored_paths():
+    assert is_vendored("vendor/github.com/pkg/errors/errors.go")
+    assert is_vendored("web/node_modules/left-pad/index.js")
+    assert is_vendored("src/third_party/zlib/inflate.c")
+    assert not is_vendored("vendor.go")
+    assert not is_vendored("src/vendoring/plan.py")
+
+
+def _project(tmp_path):
+    (tmp_path / "app.py").write_text("def main():\n    return 1\n", encoding="utf-8")
+    (tmp_path / "models_gen.py").write_text("# Code generated by sqlc. DO NOT EDIT.\ndef query():\n    pass\n", encoding="utf-8")
+    (tmp_path / "vendor" / "lib").mkdir(parents=True)
+    (tmp_path / "vendor" / "lib" / "dep.py").write_text("def helper():\n    pass\n", encoding="utf-8")
+    return tmp_path
+
+
+def test_scan_marks_generated_and_vendored(tmp_path):
+    result = run_cli(["scan", str(_project(tmp_path))])
+    assert result.returncode == 0, result.stderr
+    reports = {Path(r["path"]).name: r for r in json.loads(result.stdout)}
+    assert reports["models_gen.py"]["generated"] is True
+    assert reports["dep.py"]["vendored"] is True
+    assert "generated" not in reports["app.py"] and "vendored" not in reports["app.py"]
+
+    result = run_cli(["scan", str(tmp_path), "--format", "ndjson", "--per-symbol"])
+    records = [json.loads(line) for line in result.stdout.splitlines()]
+    assert [r["name"] for r in records if r.get("generated")] == ["query"]
+
+
+def test_skip_flags_drop_files(tmp_path):
+    _project(tmp_path)
+    result = run_cli(["--skip-generated", "--skip-vendored", "scan", str(tmp_path)])
+    assert result.returncode == 0, result.stderr
+    assert [Path(r["path"]).name for r in json.loads(result.stdout)] == ["app.py"]
+
+    result = run_cli(["--skip-vendored", "scan", str(tmp_path)])
+    assert sorted(Path(r["path"]).name for r in json.loads(result.stdout)) == ["app.py", "models_gen.py"]
+
+
+def test_detect_reports_markers(tmp_path):
+    _project(tmp_path)
+    result = run_cli(["detect", str(tmp_path), "--format", "json"])
+    assert result.returncode == 0, result.stderr
+    rows = {Path(r["path"]).name: r for r in json.loads(result.stdout)}
+    assert rows["models_gen.py"]["generated"] is True
+    assert rows["dep.py"]["vendored"] is True
+    assert "generated" not in rows["app.py"]
@@ -0,0 +1,76 @@
+"""Tests for --since git change detection."""
+
+import subprocess
+
+import pytest
+
+from treesitter_tools.core import extract_symbols, scan_directory
+from treesitter_tools.gitdiff import FileChange, changed_since
+
+
+def _git(cwd, *args):
+    subprocess.run(["git", *args], cwd=cwd, check=True, capture_output=True)
+
+
+@pytest.fixture
+def repo(tmp_path):
+    _git(tmp_path, "init", "-q", "-b", "main")
+    _git(tmp_path, "config", "user.email", "dev@example.com")
+    _git(tmp_path, "config", "user.name", "dev")
+    (tmp_path / "keep.py").write_text("def keep():\n    return 1\n", encoding="utf-8")
+    (tmp_path / "edit.py").write_text(
+        "def first():\n    return 1\n\n\ndef second():\n    return 2\n", encoding="utf-8"
+    )
+    (tmp_path / "gone.py").write_text("x = 1\n", encoding="utf-8")
+    _git(tmp_path, "add", "-A")
+    _git(tmp_path, "commit", "-q", "-m", "base")
+    _git(tmp_path, "checkout", "-q", "-b", "feature")
+    (tmp_path / "edit.py").write_text(
+        "def first():\n    return 1\n\n\ndef second():\n    return 22\n\n\ndef third():\n    return 3\n",
+        encoding="utf-8",
+    )
+    (tmp_path / "new.py").write_text("def fresh():\n    pass\n", encoding="utf-8")
+    (tmp_path / "gone.py").unlink()
+    _git(tmp_path, "add", "-A")
+    _git(tmp_path, "commit", "-q", "-m", "change")
+    return tmp_path
+
+
+def test_changed_files_and_hunks(repo):
+    changes = changed_since("main", repo)
+    assert {p.name for p in changes.paths} == {"edit.py", "new.py"}
+    edit = changes.files[(repo / "edit.py").resolve()]
+    assert edit.status == "modified"
+    assert edit.old_path == "edit.py"
+    assert edit.hunks == [(6, 10)]
+    assert changes.files[(repo / "new.py").resolve()].status == "added"
+
+
+def test_unknown_ref(repo):
+    with pytest.raises(ValueError, match="Unknown git ref"):
+        changed_since("no-such-branch", repo)
+
+
+def test_touches_ranges_and_deletions():
+    change = FileChange(path=None, status="modified", hunks=[(10, 12)], deletions=[20])
+    assert change.touches(1, 10)
+    assert change.touches(12, 15)
+    assert not change.touches(13, 19)
+    assert change.touches(18, 25)
+    assert not change.touches(15, 20)  # lines removed right after the range
+
+
+def test_symbol_markers(repo):
+    changes = changed_since("main", repo)
+    symbols = extract_symbols(repo / "edit.py")
+    changes.annotate(repo / "edit.py", symbols)
+    marks = {s.name: s.change for s in symbols}
+    assert marks == {"first": None, "second": "modified", "third": "added"}
+    assert symbols[1].to_dict()["change"] == "modified"
+    assert "change" not in symbols[0].to_dict()
+
+
+def test_scan_only_walks_changed_files(repo):
+    changes = changed_since("main", repo)
+    reports = scan_directory(repo, only=changes.paths)
+    assert sorted(r.path.name for r in reports) == ["edit.py", "new.py"]
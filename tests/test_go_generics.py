@@ -0,0 +1,94 @@
+"""Tests for Go type parameters, constraints, and instantiations."""
+
+from treesitter_tools.callgraph import build_call_graph
+from treesitter_tools.core import extract_symbols
+from treesitter_tools.index import SymbolIndex
+
+GO_GENERICS = """\
+package coll
+
+// Map applies f to every element.
+func Map[
+\tT any,
+\tU any,
+](xs []T, f func(T) U) []U {
+\tout := make([]U, 0, len(xs))
+\tfor _, x := range xs {
+\t\tout = append(out, f(x))
+\t}
+\treturn out
+}
+
+type Pair[K comparable, V any] struct {
+\tKey   K
+\tValue V
+}
+
+type List[T any] struct {
+\titems []T
+\tindex map[string]Pair[string, T]
+}
+
+func (l *List[T]) Push(v T) { l.items = append(l.items, v) }
+
+func Keys[K comparable, V any](m map[K]V) List[K] {
+\tvar out List[K]
+\tnames := Map[int, string]([]int{1}, itoa)
+\t_ = Pair[string, int]{Key: names[0]}
+\treturn out
+}
+
+func itoa(i int) string { return "" }
+"""
+
+
+def _symbols(tmp_path):
+    path = tmp_path / "coll.go"
+    path.write_text(GO_GENERICS, encoding="utf-8")
+    return {s.name: s for s in extract_symbols(path)}
+
+
+def test_type_parameters_and_constraints(tmp_path):
+    symbols = _symbols(tmp_path)
+    assert symbols["Map"].type_parameters == [{"name": "T", "constraint": "any"}, {"name": "U", "constraint": "any"}]
+    assert symbols["Keys"].type_parameters == [
+        {"name": "K", "constraint": "comparable"}, {"name": "V", "constraint": "any"},
+    ]
+    assert symbols["Pair"].type_parameters[0] == {"name": "K", "constraint": "comparable"}
+    assert symbols["Push"].type_parameters == [{"name": "T", "constraint": None}]
+    assert symbols["itoa"].type_parameters is None and "type_parameters" not in symbols["itoa"].to_dict()
+
+
+def test_multiline_type_parameter_list_is_one_signature(tmp_path):
+    symbols = _symbols(tmp_path)
+    assert symbols["Map"].signature == "func Map[T any, U any](xs []T, f func(T) U) []U { ..."
+    assert symbols["Keys"].signature == "func Keys[K comparable, V any](m map[K]V) List[K] { ..."
+    assert symbols["itoa"].signature == 'func itoa(i int) string { return "" }'
+
+
+def test_signature_instantiations(tmp_path):
+    symbols = _symbols(tmp_path)
+    assert symbols["Keys"].instantiations == [{"name": "List", "qualifier": None, "type_arguments": ["K"]}]
+    assert symbols["List"].instantiations == [{"name": "Pair", "qualifier": None, "type_arguments": ["string", "T"]}]
+    assert symbols["Push"].instantiations is None  # the receiver declares, it does not instantiate
+
+
+def test_instantiation_sites_are_references(tmp_path):
+    (tmp_path / "coll.go").write_text(GO_GENERICS, encoding="utf-8")
+    with SymbolIndex(tmp_path / "index.db") as index:
+        index.update(tmp_path)
+        calls = index.refs("Map")
+        assert [(r["caller"], r["line"], r["type_arguments"]) for r in calls] == [("Keys", 29, ["int", "string"])]
+        assert [(r["caller"], r["line"], r["type_arguments"]) for r in index.refs("Pair")] == [
+            ("List", 22, ["string", "T"]), ("Keys", 30, ["string", "int"]),
+        ]
+        assert [r["line"] for r in index.refs("List")] == [27, 28]
+        assert index.refs("itoa") == []  # passed, not called
+        assert index.defs("Map")[0]["signature"].startswith("func Map[T any, U any](")
+
+
+def test_instantiated_call_resolves_in_call_graph(tmp_path):
+    (tmp_path / "coll.go").write_text(GO_GENERICS, encoding="utf-8")
+    graph = build_call_graph(tmp_path)
+    edge = next(e for e in graph.edges if e.caller == "Keys" and e.callee == "Map")
+    assert edge.resolved and edge.callee_line == 4
@@ -0,0 +1,72 @@
+"""Tests for the Go interface implementation finder."""
+
+from treesitter_tools.goimpl import implementers, load_universe, satisfied_interfaces
+
+GO_STORE = """\
+package store
+
+type Getter interface {
+    Get(key string) (string, bool)
+}
+
+type GetCloser interface {
+    Getter
+    Close() error
+}
+
+type Cache struct{}
+
+func (c *Cache) Get(key string) (string, bool) { return "", false }
+
+func (c Cache) Close() error { return nil }
+
+func (c Cache) Read(p []byte) (n int, err error) { return 0, nil }
+
+type Wrapped struct {
+    *Cache
+}
+
+type Broken struct{}
+
+func (b Broken) Get(key int) (string, bool) { return "", false }
+"""
+
+
+def _universe(tmp_path):
+    (tmp_path / "store").mkdir()
+    (tmp_path / "store" / "store.go").write_text(GO_STORE, encoding="utf-8")
+    return load_universe(tmp_path)
+
+
+def test_implementers(tmp_path):
+    universe = _universe(tmp_path)
+    found = {r["type"]: r for r in implementers(universe, "GetCloser")}
+    assert set(found) == {"*store.Cache", "store.Wrapped"}
+    assert [(d["method"], d["status"]) for d in found["*store.Cache"]["methods"]] == [
+        ("Close", "match"), ("Get", "match"),
+    ]
+
+    readers = implementers(universe, "io.Reader")
+    assert [r["type"] for r in readers] == ["store.Cache", "store.Wrapped"]
+
+
+def test_partial_details(tmp_path):
+    universe = _universe(tmp_path)
+    near = {r["type"]: r for r in implementers(universe, "store.Getter", partial=True)}
+    broken = near["*store.Broken"]
+    assert broken["implements"] is False
+    assert broken["methods"][0]["status"] == "signature_mismatch"
+    assert broken["methods"][0]["type"] == "Get(int) (string, bool)"
+
+
+def test_satisfied_interfaces(tmp_path):
+    universe = _universe(tmp_path)
+    value = {r["interface"] for r in satisfied_interfaces(universe, "Cache")}
+    assert "store.Getter" not in value  # Get has a pointer receiver
+    assert {"io.Reader", "io.Closer"} <= value
+    pointer = {r["interface"] for r in satisfied_interfaces(universe, "*Cache")}
+    assert {"store.Getter", "store.GetCloser", "io.Reader"} <= pointer
+
+    details = satisfied_interfaces(universe, "Cache", partial=True)
+    getter = next(r for r in details if r["interface"] == "store.Getter")
+    assert getter["methods"][0]["status"] == "pointer_receiver"
@@ -0,0 +1,94 @@
+"""Tests for runtime grammar manifests."""
+
+import json
+from pathlib import Path
+
+import pytest
+
+from treesitter_tools.core import LANGUAGE_MAPPINGS, LANGUAGE_SPECS, detect_language, load_language
+from treesitter_tools.grammars import (
+    load_grammar_dir,
+    pin_grammars,
+    query_node_kinds,
+    read_lock,
+    read_manifest,
+    verify_grammars,
+)
+
+
+def _write_manifest(tmp_path, entries):
+    (tmp_path / "grammars.json").write_text(json.dumps({"grammars": entries}), encoding="utf-8")
+
+
+def test_manifest_registers_language(tmp_path, monkeypatch):
+    monkeypatch.setattr("treesitter_tools.core.LANGUAGE_SPECS", dict(LANGUAGE_SPECS))
+    monkeypatch.setattr("treesitter_tools.core.LANGUAGE_MAPPINGS", dict(LANGUAGE_MAPPINGS))
+    _write_manifest(tmp_path, [{
+        "name": "toy",
+        "file": "toy.wasm",
+        "extensions": [".toy"],
+        "function_nodes": ["func"],
+        "queries": "queries/toy",
+    }])
+    specs = load_grammar_dir(tmp_path)
+    assert [s.name for s in specs] == ["toy"]
+    assert specs[0].queries_path == tmp_path / "queries" / "toy"
+    assert detect_language(Path("a.toy")) == "toy"
+    with pytest.raises(RuntimeError, match="not found"):
+        load_language("toy")
+
+
+@pytest.mark.parametrize(
+    "entries, message",
+    [
+        ([{"file": "x.wasm", "extensions": ["x"]}], "name"),
+        ([{"name": "x", "extensions": ["x"]}], "file"),
+        ([{"name": "x", "file": "x.wasm", "extensions": []}], "extensions"),
+        ([{"name": "x", "file": "x.wasm", "extensions": ["x"], "doc_style": "bogus"}], "doc_style"),
+    ],
+)
+def test_manifest_validation(tmp_path, entries, message):
+    _write_manifest(tmp_path, entries)
+    with pytest.raises(ValueError, match=message):
+        read_manifest(tmp_path)
+
+
+def test_missing_manifest(tmp_path):
+    with pytest.raises(ValueError, match="grammars.json"):
+        read_manifest(tmp_path)
+
+
+def test_query_node_kinds_skip_strings_comments_and_predicates():
+    text = '; (comment_kind) here\n((identifier) @x (#eq? @x "(not_a_kind)"))\n(call function: (_) "(") @c\n'
+    assert query_node_kinds(text) == ["call", "identifier"]
+
+
+def test_pin_and_verify(tmp_path, monkeypatch):
+    lock = pin_grammars(["python"])
+    assert list(lock["grammars"]) == ["python"]
+    assert lock["grammars"]["python"]["abi"] and lock["grammars"]["python"]["fingerprint"]
+    assert verify_grammars(lock).errors == []
+
+    (tmp_path / "grammars.lock").write_text(json.dumps(lock), encoding="utf-8")
+    assert read_lock(tmp_path / "grammars.lock") == lock
+    lock["grammars"]["python"].update(fingerprint="0" * 16, version="0.0.1")
+    problems = verify_grammars(lock).errors
+    assert [(p.kind, p.language) for p in problems] == [("mismatch", "python")]
+    assert "version '0.0.1' -> " in problems[0].message
+    assert [(p.language, p.kind) for p in verify_grammars(lock, ["python", "go"]).errors] == [
+        ("go", "unpinned"), ("python", "mismatch")
+    ]
+
+    (tmp_path / "q" / "python").mkdir(parents=True)
+    (tmp_path / "q" / "python" / "extra.scm").write_text("(function_definition (no_such_kind)) @f\n", encoding="utf-8")
+    monkeypatch.setattr("treesitter_tools.querylib.QUERY_DIRS", [tmp_path / "q"])
+    problems = verify_grammars(languages=["python"]).errors
+    assert [p.kind for p in problems] == ["query"] and "no_such_kind" in problems[0].message
+
+
+def test_read_lock_errors(tmp_path):
+    with pytest.raises(ValueError, match="grammars pin"):
+        read_lock(tmp_path / "grammars.lock")
+    (tmp_path / "grammars.lock").write_text('{"version": 9}', encoding="utf-8")
+    with pytest.raises(ValueError, match="not a version 1 grammar lock"):
+        read_lock(tmp_path / "grammars.lock")
@@ -0,0 +1,81 @@
+"""Tests for the graph database exporter."""
+
+import json
+from pathlib import Path
+
+from conftest import run_cli
+
+from treesitter_tools.export.graphdb import PropertyGraph, build_property_graph
+
+
+def _project(tmp_path: Path) -> Path:
+    root = tmp_path / "proj"
+    (root / "app" / "db").mkdir(parents=True)
+    (root / "app" / "db" / "__init__.py").write_text("", encoding="utf-8")
+    (root / "app" / "db" / "store.py").write_text(
+        "class Store(Exception):\n    def write(self, row):\n        return row\n\n\n"
+        "def save(row):\n    return Store().write(row)\n",
+        encoding="utf-8",
+    )
+    (root / "app" / "handlers.py").write_text(
+        "import json\nfrom .db import store\n\n\nclass Handler(Base):\n    pass\n\n\nclass Base:\n    pass\n\n\n"
+        "def handle(request):\n    return save(request)\n",
+        encoding="utf-8",
+    )
+    return root
+
+
+def _edges(graph, edge_type):
+    return {(e.source, e.target) for e in graph.edges if e.type == edge_type}
+
+
+def test_graph_links_files_symbols_calls_imports_and_types(tmp_path):
+    graph = build_property_graph(_project(tmp_path))
+    handle = "function:app/handlers.py:13:handle"
+    save = "function:app/db/store.py:6:save"
+    write = "function:app/db/store.py:2:Store.write"
+    store = "type:app/db/store.py:1:Store"
+    assert graph.nodes[handle].properties["language"] == "python"
+    assert ("package:app", "file:app/handlers.py") in _edges(graph, "CONTAINS")
+    assert {("file:app/handlers.py", handle), ("file:app/db/store.py", store)} <= _edges(graph, "DEFINES")
+    assert _edges(graph, "HAS_METHOD") == {(store, write)}
+    assert {(handle, save), (save, write)} <= _edges(graph, "CALLS")
+    assert _edges(graph, "IMPORTS") == {("file:app/handlers.py", "external:json"), ("file:app/handlers.py", "package:app/db")}
+    assert _edges(graph, "EXTENDS") == {
+        ("type:app/handlers.py:5:Handler", "type:app/handlers.py:9:Base"),
+        (store, "type:Exception"),
+    }
+    assert graph.nodes["type:Exception"].properties == {"name": "Exception", "external": True}
+
+
+def test_cypher_statements():
+    graph = PropertyGraph()
+    graph.add_node("file:a.py", "File", name="a.py", path="a.py")
+    graph.add_node('function:a.py:1:say "hi"', "Function", name='say "hi"', line=1, async_=None)
+    graph.add_edge("file:a.py", 'function:a.py:1:say "hi"', "DEFINES")
+    lines = graph.to_cypher().splitlines()
+    assert lines[0].startswith("CREATE CONSTRAINT code_id IF NOT EXISTS FOR (n:Code)")
+    assert lines[2] == 'MERGE (n:Code:File {id: "file:a.py"}) SET n += {name: "a.py", path: "a.py"};'
+    assert lines[3] == 'MERGE (n:Code:Function {id: "function:a.py:1:say \\"hi\\""}) SET n += {name: "say \\"hi\\"", line: 1};'
+    assert lines[4] == (
+        'MATCH (a:Code {id: "file:a.py"}), (b:Code {id: "function:a.py:1:say \\"hi\\""}) MERGE (a)-[:DEFINES]->(b);'
+    )
+    assert graph.to_cypher("memgraph").startswith("CREATE CONSTRAINT ON (n:Code) ASSERT n.id IS UNIQUE;")
+    assert graph.to_cypher(schema=False).startswith("MERGE")
+
+
+def test_cli_graph_export(tmp_path):
+    root = _project(tmp_path)
+    result = run_cli(["graph-export", ".", "--format", "jgf"], cwd=root)
+    assert result.returncode == 0, result.stderr
+    graph = json.loads(result.stdout)["graph"]
+    assert graph["directed"] is True and graph["label"] == "proj"
+    assert graph["nodes"]["function:app/handlers.py:13:handle"]["metadata"]["type"] == "Function"
+    assert {"source": "function:app/handlers.py:13:handle", "target": "function:app/db/store.py:6:save",
+            "relation": "CALLS", "metadata": {"path": "app/handlers.py", "line": 14, "column": 12}} in graph["edges"]
+
+    result = run_cli(["graph-export", ".", "--dialect", "memgraph", "--no-schema"], cwd=root)
+    assert result.returncode == 0, result.stderr
+    assert result.stdout.startswith("MERGE (n:Code:Package")
+    result = run_cli(["graph-export", ".", "--format", "graphml"], cwd=root)
+    assert result.returncode == 1 and "Unsupported format" in result.stderr
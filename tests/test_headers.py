@@ -0,0 +1,114 @@
+"""Tests for license header detection and insertion."""
+
+import json
+
+import pytest
+from conftest import run_cli
+
+from treesitter_tools import reproducible
+from treesitter_tools.core import parse_file
+from treesitter_tools.headers import (
+    check_headers,
+    default_template,
+    detect_license,
+    file_header,
+    header_fixes,
+    render_template,
+)
+
+
+def _tree(root):
+    (root / "src").mkdir()
+    (root / "src" / "licensed.go").write_text(
+        "// Copyright 2024 Acme Inc.\n// SPDX-License-Identifier: Apache-2.0\n\npackage app\n", encoding="utf-8"
+    )
+    (root / "src" / "mit.js").write_text(
+        "/*\n * MIT License\n *\n * Permission is hereby granted, free of charge, ...\n */\nexport const x = 1;\n",
+        encoding="utf-8",
+    )
+    (root / "src" / "tool.py").write_text(
+        "#!/usr/bin/env python\n# -*- coding: utf-8 -*-\nimport os\n", encoding="utf-8"
+    )
+    (root / "src" / "style.css").write_text("body { margin: 0; }\n", encoding="utf-8")
+    (root / "src" / "data.json").write_text("{}\n", encoding="utf-8")
+
+
+def test_detect_license():
+    assert detect_license("Copyright (c) 2020 Acme\nSPDX-License-Identifier: Apache-2.0 OR MIT") == (
+        "Apache-2.0 OR MIT", ["Apache-2.0 OR MIT"], ["Copyright (c) 2020 Acme"],
+    )
+    assert detect_license("Licensed under the Apache License, Version 2.0 (the \"License\")")[0] == "Apache-2.0"
+    assert detect_license("GNU Lesser General Public License as published ... version 2.1")[0] == "LGPL-2.1"
+    assert detect_license("GNU General Public License ... either version 3 of the License")[0] == "GPL-3.0"
+    assert detect_license("Helpers for parsing dates.") == (None, [], [])
+
+
+def test_render_template():
+    template = default_template("Acme {Inc}", "MIT")
+    assert render_template(template, year=2026, holder="Acme {Inc}", license="MIT") == (
+        "Copyright 2026 Acme {Inc}\nSPDX-License-Identifier: MIT"
+    )
+    assert default_template(None, None) is None
+    with pytest.raises(ValueError, match="--holder"):
+        render_template("Copyright {holder}", year=2026, holder=None)
+
+
+def test_headers(tmp_path):
+    _tree(tmp_path)
+    headers = {h.label: h for h in check_headers(tmp_path / "src", expected="Apache-2.0")}
+    assert set(headers) == {"licensed.go", "mit.js", "tool.py", "style.css"}  # JSON has no comments
+    go = headers["licensed.go"]
+    assert (go.start_line, go.end_line, go.license, go.copyright, go.problem) == (
+        1, 2, "Apache-2.0", ["Copyright 2024 Acme Inc."], None,
+    )
+    assert headers["mit.js"].license == "MIT" and headers["mit.js"].problem == "mismatch"
+    assert headers["tool.py"].problem == "missing"  # the shebang and coding cookie are no header
+
+
+def test_header_fixes(tmp_path):
+    _tree(tmp_path)
+    headers = check_headers(tmp_path / "src", expected="Apache-2.0")
+    template = default_template("Acme Inc.", "Apache-2.0")
+    fixes = {f.path.name: f for f in header_fixes(headers, template, "Acme Inc.", 2026)}
+    assert set(fixes) == {"tool.py", "style.css"}  # a mismatched license is never rewritten
+    assert fixes["tool.py"].rewritten.decode() == (
+        "#!/usr/bin/env python\n# -*- coding: utf-8 -*-\n"
+        "# Copyright 2026 Acme Inc.\n# SPDX-License-Identifier: Apache-2.0\n\nimport os\n"
+    )
+    assert fixes["style.css"].rewritten.decode().startswith("/*\n * Copyright 2026 Acme Inc.\n")
+    (edit,) = fixes["tool.py"].edits
+    assert (edit.start_line, edit.start_byte == edit.end_byte) == (3, True)
+    # Once inserted, the header is found.
+    path = tmp_path / "src" / "tool.py"
+    path.write_bytes(fixes["tool.py"].rewritten)
+    assert file_header(parse_file(path), "tool.py", "Apache-2.0").problem is None
+
+
+def test_header_year_is_reproducible(tmp_path, monkeypatch):
+    _tree(tmp_path)
+    monkeypatch.setattr(reproducible, "ENABLED", True)
+    monkeypatch.setenv("SOURCE_DATE_EPOCH", "946684800")  # 2000-01-01
+    headers = check_headers(tmp_path / "src", expected="Apache-2.0")
+    fixes = header_fixes(headers, default_template("Acme Inc.", "Apache-2.0"), "Acme Inc.")
+    assert all(b"Copyright 2000 Acme Inc." in f.rewritten for f in fixes)
+
+
+def test_cli_headers(tmp_path):
+    _tree(tmp_path)
+    result = run_cli(["headers", "src", "--license", "Apache-2.0"], cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    assert result.stdout.splitlines() == [
+        "mit.js:1: license header names MIT, expected Apache-2.0",
+        "style.css: no license header",
+        "tool.py: no license header",
+    ]
+    listing = json.loads(run_cli(["headers", "src", "--all", "--format", "json"], cwd=tmp_path).stdout)
+    assert [h["path"] for h in listing] == ["licensed.go", "mit.js", "style.css", "tool.py"]
+
+    missing = run_cli(["headers", "src", "--format", "diff"], cwd=tmp_path)
+    assert missing.returncode == 1 and "Nothing to insert" in missing.stderr
+    fixed = run_cli(["headers", "src", "--holder", "Acme Inc.", "--year", "2026", "--fix"], cwd=tmp_path)
+    assert fixed.returncode == 0, fixed.stderr
+    style = (tmp_path / "src" / "style.css").read_text(encoding="utf-8")
+    assert style.startswith("/*\n * Copyright 2026 Acme Inc.\n */")
+    assert run_cli(["headers", "src", "--check"], cwd=tmp_path).returncode == 0
@@ -0,0 +1,85 @@
+"""Tests for type hierarchy extraction."""
+
+import json
+
+import pytest
+from conftest import run_cli
+
+from treesitter_tools.hierarchy import build_hierarchy
+
+
+def _project(tmp_path):
+    (tmp_path / "animals.py").write_text(
+        "import abc\n\n\nclass Animal(abc.ABC):\n    pass\n\n\nclass Dog(Animal, metaclass=abc.ABCMeta):\n    pass\n\n\n"
+        "class Puppy(Dog):\n    pass\n",
+        encoding="utf-8",
+    )
+    (tmp_path / "shapes.ts").write_text(
+        "interface Shape { area(): number }\n"
+        "interface Solid extends Shape { volume(): number }\n"
+        "class Base {}\n"
+        "class Cube extends Base implements Solid, Shape {\n  area() { return 6; }\n  volume() { return 1; }\n}\n",
+        encoding="utf-8",
+    )
+    (tmp_path / "store.go").write_text(
+        "package store\n\n"
+        "type Reader interface { Read() error }\n"
+        "type ReadCloser interface {\n\tReader\n\tio.Closer\n}\n"
+        "type Base struct{ id int }\n"
+        "type Item struct {\n\tBase\n\t*sync.Mutex\n\tname string\n}\n",
+        encoding="utf-8",
+    )
+    return tmp_path
+
+
+def _edges(relations):
+    return {(r.subtype, r.relation, r.supertype) for r in relations}
+
+
+def test_relations_per_language(tmp_path):
+    graph = build_hierarchy(_project(tmp_path))
+    edges = _edges(graph.relations)
+    assert {("Animal", "extends", "ABC"), ("Dog", "extends", "Animal"), ("Puppy", "extends", "Dog")} <= edges
+    assert ("Dog", "extends", "ABCMeta") not in edges  # keyword arguments are not bases
+    assert {("Solid", "extends", "Shape"), ("Cube", "extends", "Base"), ("Cube", "implements", "Solid")} <= edges
+    assert {("ReadCloser", "embeds", "Reader"), ("ReadCloser", "embeds", "Closer")} <= edges
+    assert {("Item", "embeds", "Base"), ("Item", "embeds", "Mutex")} <= edges
+    kinds = {t.name: t.kind for t in graph.types if t.path == "store.go"}
+    assert kinds == {"Reader": "interface", "ReadCloser": "interface", "Base": "struct", "Item": "struct"}
+
+
+def test_supertypes_and_subtypes(tmp_path):
+    graph = build_hierarchy(_project(tmp_path), include=["**/*.py"])
+    assert _edges(graph.supertypes("Puppy")) == {("Puppy", "extends", "Dog")}
+    assert [r.supertype for r in graph.supertypes("Puppy", transitive=True)] == ["Dog", "Animal", "ABC"]
+    assert [r.subtype for r in graph.subtypes("Animal", transitive=True)] == ["Dog", "Puppy"]
+    relation = graph.supertypes("Dog")[0]
+    assert (relation.supertype_path, relation.supertype_line) == ("animals.py", 4)
+    assert graph.supertypes("abc.ABC") == []  # known only as a supertype
+    with pytest.raises(ValueError, match="No type named 'Cat'"):
+        graph.subtypes("Cat")
+
+
+def test_cli_hierarchy(tmp_path):
+    _project(tmp_path)
+    result = run_cli(["hierarchy", str(tmp_path), "--subtypes", "Shape", "--transitive"])
+    assert result.returncode == 0, result.stderr
+    rows = json.loads(result.stdout)
+    assert {(r["subtype"], r["depth"]) for r in rows} == {("Solid", 0), ("Cube", 0), ("Cube", 1)}
+
+    result = run_cli(["hierarchy", str(tmp_path), "--supertypes", "Puppy", "--transitive", "--format", "text"])
+    assert result.stdout.splitlines() == [
+        "Puppy",
+        "  extends Dog  (animals.py:8)",
+        "    extends Animal  (animals.py:4)",
+        "      extends ABC",
+    ]
+
+    result = run_cli(["hierarchy", str(tmp_path), "--format", "dot"])
+    assert result.stdout.startswith("digraph hierarchy {")
+    assert '"Item" -> "Base" [label=embeds, style=dotted];' in result.stdout
+    assert '"Mutex" [style=dashed];' in result.stdout
+
+    result = run_cli(["hierarchy", str(tmp_path), "--supertypes", "Nope"])
+    assert result.returncode == 1
+    assert "No type named 'Nope'" in result.stderr
@@ -0,0 +1,91 @@
+"""Tests for per-function metrics across git history."""
+
+import csv
+import io
+import json
+import subprocess
+
+from conftest import run_cli
+
+from treesitter_tools.history import list_revisions, symbol_history
+
+ONE = "def simple():\n    return 1\n\n\ndef branchy(x):\n    if x:\n        return 1\n    return 2\n"
+TWO = (
+    "def simple():\n    return 1\n\n\ndef branchy(x):\n    if x:\n        return 1\n"
+    "    elif x is None:\n        return 3\n    return 2\n"
+)
+FOUR = "def branchy(x):\n    if x:\n        return 1\n    elif x is None:\n        return 3\n    return 2\n"
+
+
+def _git(cwd, *args, author="Ann"):
+    subprocess.run(
+        ["git", "-c", f"user.name={author}", "-c", f"user.email={author.lower()}@example.com", *args],
+        cwd=cwd,
+        check=True,
+        capture_output=True,
+    )
+
+
+def _repo(tmp_path):
+    """Four commits: add mod.py, grow branchy, touch only the README, delete simple."""
+    _git(tmp_path, "init", "-q")
+    (tmp_path / "src").mkdir()
+    (tmp_path / "src" / "mod.py").write_text(ONE, encoding="utf-8")
+    _git(tmp_path, "add", "-A")
+    _git(tmp_path, "commit", "-q", "-m", "one")
+    (tmp_path / "src" / "mod.py").write_text(TWO, encoding="utf-8")
+    _git(tmp_path, "commit", "-q", "-am", "two", author="Bob")
+    (tmp_path / "README").write_text("docs\n", encoding="utf-8")
+    _git(tmp_path, "add", "-A")
+    _git(tmp_path, "commit", "-q", "-m", "three")
+    (tmp_path / "src" / "mod.py").write_text(FOUR, encoding="utf-8")
+    _git(tmp_path, "commit", "-q", "-am", "four")
+    return tmp_path
+
+
+def test_list_revisions_filters_by_path(tmp_path):
+    repo = _repo(tmp_path)
+    assert [r.subject for r in list_revisions(repo)] == ["one", "two", "three", "four"]
+    assert [r.subject for r in list_revisions(repo, path="src")] == ["one", "two", "four"]
+    assert [r.subject for r in list_revisions(repo, "HEAD~2..HEAD", "src")] == ["four"]
+    assert [r.author for r in list_revisions(repo, path="src", max_count=2)] == ["Bob", "Ann"]
+
+
+def test_series_track_metrics_and_existence(tmp_path):
+    history = symbol_history(_repo(tmp_path) / "src")
+    assert [r.subject for r in history.revisions] == ["one", "two", "four"]
+    series = {s.name: s for s in history.symbols}
+    assert [p.cyclomatic if p else None for p in series["branchy"].points] == [2, 3, 3]
+    assert [p.loc if p else None for p in series["branchy"].points] == [4, 6, 6]
+    assert [p is not None for p in series["simple"].points] == [True, True, False]
+    assert series["simple"].path == "mod.py" and series["simple"].last_seen == 1
+
+    data = series["simple"].to_dict(history.revisions)
+    assert data["series"][2] == {"commit": history.revisions[2].commit[:12], "exists": False}
+    assert data["series"][0]["cyclomatic"] == 1
+
+
+def test_symbol_filter(tmp_path):
+    repo = _repo(tmp_path)
+    assert [s.name for s in symbol_history(repo, symbols=["branch*"]).symbols] == ["branchy"]
+    assert [s.name for s in symbol_history(repo, symbols=["src/mod.py:simple"]).symbols] == ["simple"]
+
+
+def test_cli_history(tmp_path):
+    repo = _repo(tmp_path)
+    result = run_cli(["history", "--path", "src", "--format", "csv"], cwd=repo)
+    assert result.returncode == 0, result.stderr
+    rows = list(csv.DictReader(io.StringIO(result.stdout)))
+    assert len(rows) == 6  # two functions x three revisions
+    gone = [r for r in rows if r["name"] == "simple" and r["exists"] == "0"]
+    assert len(gone) == 1 and gone[0]["cyclomatic"] == ""
+
+    text = run_cli(["history", "-s", "simple"], cwd=repo)
+    assert "src/mod.py simple (2 of 4 revisions)" in text.stdout
+    assert "removed" in text.stdout
+
+    data = json.loads(run_cli(["history", "HEAD~1..", "--format", "json"], cwd=repo).stdout)
+    assert [r["subject"] for r in data["revisions"]] == ["four"]
+
+    bad = run_cli(["history", "no-such-ref"], cwd=repo)
+    assert bad.returncode == 1 and "Unknown git revision range: no-such-ref" in bad.stderr
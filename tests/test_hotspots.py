@@ -0,0 +1,65 @@
+"""Tests for blame ownership, churn, and hotspot ranking."""
+
+import subprocess
+
+from treesitter_tools.hotspots import BlameLine, blame_file, churn, collect_hotspots, ownership
+
+ORIGINAL = "def simple():\n    return 1\n\n\ndef branchy(x):\n    if x:\n        return 1\n    return 2\n"
+EDITED = (
+    "def simple():\n    return 1\n\n\ndef branchy(x):\n    if x:\n        return 1\n"
+    "    elif x is None:\n        return 3\n    return 2\n"
+)
+
+
+def _git(cwd, *args, author="Ann"):
+    subprocess.run(
+        ["git", "-c", f"user.name={author}", "-c", f"user.email={author.lower()}@example.com", *args],
+        cwd=cwd,
+        check=True,
+        capture_output=True,
+    )
+
+
+def _repo(tmp_path):
+    _git(tmp_path, "init", "-q")
+    (tmp_path / "mod.py").write_text(ORIGINAL, encoding="utf-8")
+    _git(tmp_path, "add", "-A")
+    _git(tmp_path, "commit", "-q", "-m", "one")
+    (tmp_path / "mod.py").write_text(EDITED, encoding="utf-8")
+    _git(tmp_path, "commit", "-q", "-am", "two", author="Bob")
+    return tmp_path
+
+
+def test_ownership_prefers_majority_author():
+    blame = [
+        BlameLine("a" * 40, "Ann", 100),
+        BlameLine("b" * 40, "Bob", 300),
+        BlameLine("a" * 40, "Ann", 100),
+        BlameLine("0" * 40, "Not Committed Yet", 400),
+    ]
+    owner = ownership(blame, 1, 4)
+    assert owner.primary_author == "Ann"
+    assert owner.author_share == 0.667
+    assert owner.authors == 2
+    assert owner.last_commit == "b" * 12
+    assert owner.last_modified == "1970-01-01T00:05:00Z"
+
+
+def test_blame_and_churn(tmp_path):
+    repo = _repo(tmp_path)
+    blame = blame_file(repo, "mod.py")
+    assert len(blame) == len(EDITED.splitlines())
+    assert blame[7].author == "Bob"
+    assert churn(repo, "mod.py", 1, 2) == 1
+    assert churn(repo, "mod.py", 5, 10) == 2
+
+
+def test_collect_hotspots_ranks_by_score(tmp_path):
+    repo = _repo(tmp_path)
+    results = collect_hotspots(repo)
+    assert [h.metrics.name for h in results] == ["branchy", "simple"]
+    top = results[0].to_dict()
+    assert top["churn"] == 2
+    assert top["score"] == top["cyclomatic"] * 2
+    assert top["primary_author"] == "Ann"
+    assert top["authors"] == 2
@@ -0,0 +1,101 @@
+"""Tests for .gitignore-aware directory walks."""
+
+import json
+
+import pytest
+from conftest import run_cli
+
+from treesitter_tools import ignore
+from treesitter_tools.core import iter_source_files
+
+
+def _files(root, names):
+    for name in names:
+        path = root / name
+        path.parent.mkdir(parents=True, exist_ok=True)
+        path.write_text("x = 1\n", encoding="utf-8")
+
+
+@pytest.fixture
+def repo(tmp_path, monkeypatch):
+    monkeypatch.setenv("XDG_CONFIG_HOME", str(tmp_path / "config"))
+    monkeypatch.setenv("GIT_CONFIG_GLOBAL", str(tmp_path / "config" / "gitconfig"))
+    root = tmp_path / "repo"
+    (root / ".git" / "info").mkdir(parents=True)
+    _files(root, [
+        "app.py", "node_modules/dep/index.js", "build/out.py", "src/main.py", "src/gen/keep.py",
+        "src/gen/drop.py", "docs/conf.py", "scratch.py", "notes.py",
+    ])
+    (root / ".gitignore").write_text("node_modules/\n/build\n# comment\ngen/\n", encoding="utf-8")
+    (root / "src" / ".gitignore").write_text("!gen/\ngen/drop.py\n", encoding="utf-8")
+    (root / ".git" / "info" / "exclude").write_text("scratch.py\n", encoding="utf-8")
+    (root / ".tstoolsignore").write_text("docs/\n", encoding="utf-8")
+    return root
+
+
+def _walk(root):
+    return [p.relative_to(root).as_posix() for p in iter_source_files(root, ["**/*.py"])]
+
+
+@pytest.mark.parametrize(
+    "pattern,path,is_dir,expected",
+    [
+        ("*.log", "a/b/x.log", False, True),
+        ("/build", "src/build", True, False),
+        ("build/", "src/build", False, False),
+        ("doc/*.txt", "doc/a/b.txt", False, False),
+        ("doc/**/*.txt", "doc/a/b.txt", False, True),
+        ("**/cache", "a/cache", True, True),
+        ("abc/**", "abc", True, False),
+        ("*.py[co]", "m.pyc", False, True),
+        ("\\#lit", "#lit", False, True),
+    ],
+)
+def test_patterns(pattern, path, is_dir, expected):
+    assert ignore.compile_pattern(pattern).matches(path, is_dir) is expected
+
+
+def test_walk_respects_every_ignore_source(repo, tmp_path):
+    (tmp_path / "config" / "git").mkdir(parents=True)
+    (tmp_path / "config" / "git" / "ignore").write_text("notes.py\n", encoding="utf-8")
+    # A nested negation re-includes gen/ under src/ only; an ignored directory is never entered.
+    assert _walk(repo) == ["app.py", "src/gen/keep.py", "src/main.py"]
+    assert _walk(repo / "src") == ["gen/keep.py", "main.py"]
+    rules = ignore.IgnoreRules(repo)
+    assert rules.is_ignored(repo / "node_modules" / "dep" / "index.js")
+    assert rules.is_ignored(repo / "docs" / "conf.py") and not rules.is_ignored(repo / "app.py")
+
+
+def test_configured_global_excludes_file(repo, tmp_path):
+    excludes = tmp_path / "my-excludes"
+    excludes.write_text("app.py\n", encoding="utf-8")
+    (tmp_path / "config" / "gitconfig").parent.mkdir(parents=True, exist_ok=True)
+    (tmp_path / "config" / "gitconfig").write_text(f"[core]\n\texcludesFile = {excludes}\n", encoding="utf-8")
+    assert "app.py" not in _walk(repo)
+
+
+def test_only_paths_and_no_ignore(repo, monkeypatch):
+    only = [(repo / "src" / "gen" / "drop.py").resolve(), (repo / "src" / "main.py").resolve()]
+    assert [p.name for p in iter_source_files(repo, only=only)] == ["main.py"]
+    monkeypatch.setattr(ignore, "RESPECT_IGNORES", False)
+    assert "node_modules/dep/index.js" in [p.relative_to(repo).as_posix() for p in iter_source_files(repo)]
+
+
+def test_tstoolsignore_outside_a_repository(tmp_path):
+    _files(tmp_path, ["a.py", "fixtures/b.py"])
+    (tmp_path / ".tstoolsignore").write_text("fixtures/\n", encoding="utf-8")
+    assert _walk(tmp_path) == ["a.py"]
+
+
+def _label(root, report):
+    return (root / report["path"]).resolve().relative_to(root.resolve()).as_posix()
+
+
+def test_cli_no_ignore(repo, tmp_path):
+    env = {"XDG_CONFIG_HOME": str(tmp_path / "config"), "GIT_CONFIG_GLOBAL": str(tmp_path / "config" / "gitconfig")}
+    result = run_cli(["scan", ".", "--include", "**/*.py"], cwd=repo, env=env)
+    assert result.returncode == 0, result.stderr
+    assert sorted(_label(repo, f) for f in json.loads(result.stdout)) == ["app.py", "notes.py", "src/gen/keep.py", "src/main.py"]
+    result = run_cli(["--no-ignore", "scan", ".", "--include", "**/*.py"], cwd=repo, env=env)
+    assert result.returncode == 0, result.stderr
+    assert "build/out.py" in {_label(repo, f) for f in json.loads(result.stdout)}
@@ -0,0 +1,139 @@
+"""Tests for unused and duplicate import detection and its fixes."""
+
+import json
+
+from conftest import run_cli
+
+from treesitter_tools.core import parse_file
+from treesitter_tools.imports import check_imports, file_imports, go_package_name, import_fixes
+
+VIEWS_PY = """\
+import os
+import sys, json
+from typing import TYPE_CHECKING, List, Optional
+from typing import List
+
+if TYPE_CHECKING:
+    from app.models import User
+
+__all__ = ["settings"]
+from app import settings
+
+
+def load(path: str) -> "User":
+    json = {}
+    return sys.argv, json, List
+"""
+
+APP_TSX = """\
+import React, { useState, useMemo as memo } from "react";
+import * as api from "./api";
+import type { Props } from "./types";
+import "./styles.css";
+
+export function App(props: Props) {
+  const [count] = useState(0);
+  return <div>{count}</div>;
+}
+"""
+
+MAIN_GO = """\
+package main
+
+import (
+\t"fmt"
+\t"os"
+\tyaml "gopkg.in/yaml.v3"
+\t_ "net/http/pprof"
+\t"fmt"
+)
+
+func main() {
+\tfmt.Println(yaml.Marshal)
+}
+"""
+
+APP_JAVA = """\
+import java.util.List;
+import java.util.Map;
+import java.io.IOException;
+import java.io.*;
+
+/** Reads lists; see {@link IOException}. */
+class App {
+    List<String> names;
+}
+"""
+
+
+def _problems(tmp_path, name, text):
+    path = tmp_path / name
+    path.write_text(text, encoding="utf-8")
+    report = file_imports(parse_file(path), name)
+    return report, [(p.kind, p.name, p.line) for p in report.problems]
+
+
+def test_python_unused_and_duplicates(tmp_path):
+    report, problems = _problems(tmp_path, "views.py", VIEWS_PY)
+    # `json` is shadowed by the local; `User` is used in a quoted annotation; `settings` is in __all__.
+    assert problems == [("unused", "os", 1), ("unused", "json", 2), ("unused", "Optional", 3), ("duplicate", "List", 4)]
+    assert report.problems[3].duplicate_of == 3
+
+    fixed = report.fix().rewritten.decode()
+    assert fixed.startswith("import sys\nfrom typing import TYPE_CHECKING, List\n\nif TYPE_CHECKING:")
+
+
+def test_python_fix_keeps_blocks_valid(tmp_path):
+    report, problems = _problems(tmp_path, "lazy.py", "def f():\n    import os  # lazy\n    import os\n\n\nx = 1\n")
+    assert problems == [("unused", "os", 2), ("duplicate", "os", 3)]
+    assert report.fix().rewritten.decode() == "def f():\n    pass  # lazy\n\n\nx = 1\n"
+    assert _problems(tmp_path, "keep.py", "import readline  # noqa: F401\n")[1] == []
+
+
+def test_typescript_imports(tmp_path):
+    report, problems = _problems(tmp_path, "App.tsx", APP_TSX)
+    # React counts as used by JSX; Props is used as a type; the CSS import binds nothing.
+    assert problems == [("unused", "memo", 1), ("unused", "api", 2)]
+    fixed = report.fix().rewritten.decode()
+    assert fixed.startswith('import React, { useState } from "react";\nimport type { Props }')
+
+
+def test_go_imports(tmp_path):
+    report, problems = _problems(tmp_path, "main.go", MAIN_GO)
+    assert problems == [("unused", "os", 5), ("duplicate", "fmt", 8)]
+    assert report.fix().rewritten.decode().startswith(
+        'package main\n\nimport (\n\t"fmt"\n\tyaml "gopkg.in/yaml.v3"\n\t_ "net/http/pprof"\n)\n'
+    )
+    assert go_package_name("github.com/mattn/go-sqlite3") == "sqlite3"
+    assert go_package_name("github.com/org/lib/v2") == "lib"
+    assert go_package_name("example.com/some.pkg") is None
+
+
+def test_java_imports(tmp_path):
+    report, problems = _problems(tmp_path, "App.java", APP_JAVA)
+    assert problems == [("unused", "Map", 2)]  # IOException is referenced from Javadoc
+    assert report.fix().rewritten.decode().startswith("import java.util.List;\nimport java.io.IOException;\n")
+
+
+def test_check_imports_and_cli(tmp_path):
+    (tmp_path / "views.py").write_text(VIEWS_PY, encoding="utf-8")
+    (tmp_path / "clean.py").write_text("import os\nprint(os.sep)\n", encoding="utf-8")
+    reports = check_imports(tmp_path)
+    assert [r.label for r in reports] == ["views.py"]
+    assert len(import_fixes(reports)) == 1
+
+    result = run_cli(["imports", ".", "--format", "json"], cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    assert [p["name"] for p in json.loads(result.stdout)] == ["os", "json", "Optional", "List"]
+
+    result = run_cli(["imports", ".", "--check"], cwd=tmp_path)
+    assert result.returncode == 1
+    assert "views.py:1:8: unused import: 'os' is imported but never used" in result.stdout
+
+    diff = run_cli(["imports", ".", "--format", "diff"], cwd=tmp_path)
+    assert "-import os\n" in diff.stdout and (tmp_path / "views.py").read_text() == VIEWS_PY
+
+    fixed = run_cli(["imports", ".", "--fix"], cwd=tmp_path)
+    assert fixed.returncode == 0, fixed.stderr
+    assert "Removed 4 imports in 1 files" in fixed.stderr
+    assert run_cli(["imports", ".", "--check"], cwd=tmp_path).returncode == 0
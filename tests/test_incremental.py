@@ -0,0 +1,58 @@
+"""Tests for the incremental session and its on-disk cache."""
+
+from pathlib import Path
+
+from treesitter_tools import core
+from treesitter_tools.incremental import IncrementalSession
+
+
+def test_session_reuses_cache_across_sessions(tmp_path):
+    src = tmp_path / "a.py"
+    src.write_text("def foo():\n    return 1\n", encoding="utf-8")
+    cache = tmp_path / "cache"
+
+    first = IncrementalSession(cache)
+    symbols = first.extract(src)
+    assert first.stats.reparsed == 1
+    assert [s.name for s in symbols] == ["foo"]
+
+    second = IncrementalSession(cache)
+    cached = second.extract(src)
+    assert second.stats.reparsed == 0
+    assert second.stats.cache_hits == 1
+    assert cached == symbols
+
+
+def test_session_reparses_changed_file_incrementally(tmp_path):
+    src = tmp_path / "a.py"
+    src.write_text("def foo():\n    return 1\n", encoding="utf-8")
+    session = IncrementalSession()
+
+    session.extract(src)
+    src.write_text("def foo():\n    return 1\n\ndef bar():\n    return 2\n", encoding="utf-8")
+    symbols = session.extract(src)
+
+    assert {s.name for s in symbols} == {"foo", "bar"}
+    assert session.stats.incremental == 1
+    assert session.stats.reparsed == 2
+
+
+def test_session_matches_direct_extraction(tmp_path):
+    src = tmp_path / "a.go"
+    src.write_text("package main\n\nfunc Run() {}\n", encoding="utf-8")
+    session = IncrementalSession(tmp_path / "cache")
+    assert session.extract(src) == core.extract_symbols(src)
+
+
+def test_scan_directory_with_session(tmp_path):
+    root = tmp_path / "proj"
+    root.mkdir()
+    (root / "a.py").write_text("def foo(): pass\n", encoding="utf-8")
+    cache = tmp_path / "cache"
+
+    core.scan_directory(root, session=IncrementalSession(cache))
+    warm = IncrementalSession(cache)
+    reports = core.scan_directory(root, session=warm)
+
+    assert reports[0].symbols[0].name == "foo"
+    assert warm.stats.reparsed == 0
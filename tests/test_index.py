@@ -0,0 +1,201 @@
+"""Tests for the persistent SQLite symbol index."""
+
+import json
+import os
+
+import pytest
+from conftest import run_cli
+
+from treesitter_tools.index import GrammarMismatchError, SymbolIndex, snippet_to_text
+
+GO_STORE = """\
+package store
+
+type Store interface {
+	Get(key string) string
+	Put(key, value string)
+}
+
+type Mem struct{}
+
+func (m *Mem) Get(key string) string { return key }
+
+func (m *Mem) Put(key, value string) {}
+
+type ReadOnly struct{}
+
+func (r *ReadOnly) Get(key string) string { return "" }
+"""
+
+GO_MAIN = """\
+package store
+
+func run(m *Mem) {
+	m.Get("a")
+	m.Put("a", "b")
+}
+"""
+
+PY_SOURCE = """\
+class Base:
+    pass
+
+
+class Child(Base):
+    def save(self):
+        self.validate()
+
+    def validate(self):
+        return True
+"""
+
+
+SERVICE = """\
+import functools
+
+
+class Service:
+    name = "svc"
+
+    # Cached: computed once per instance.
+    @functools.cache
+    def total(self):
+        return 42
+"""
+
+
+def _write_project(root):
+    (root / "store").mkdir()
+    (root / "store" / "store.go").write_text(GO_STORE, encoding="utf-8")
+    (root / "store" / "run.go").write_text(GO_MAIN, encoding="utf-8")
+    (root / "models.py").write_text(PY_SOURCE, encoding="utf-8")
+
+
+def test_defs_refs_callers(tmp_path):
+    _write_project(tmp_path)
+    with SymbolIndex(tmp_path / "idx" / "index.db") as index:
+        stats = index.update(tmp_path)
+        assert stats.added == 3
+        defs = index.defs("Mem.Get")
+        assert [(d["path"], d["start_line"], d["kind"]) for d in defs] == [("store/store.go", 10, "method")]
+        refs = index.refs("Child.validate")
+        assert [(r["caller"], r["line"], r["receiver_type"]) for r in refs] == [("Child.save", 7, "Child")]
+        callers = index.callers("Get")
+        assert [(c["caller"], c["path"], c["line"], c["calls"]) for c in callers] == [("run", "store/run.go", 3, 1)]
+
+
+def test_implementations(tmp_path):
+    _write_project(tmp_path)
+    with SymbolIndex(tmp_path / "index.db") as index:
+        index.update(tmp_path)
+        assert [(i["name"], i["via"]) for i in index.implementations("Store")] == [("Mem", "method_set")]
+        assert [(i["name"], i["via"]) for i in index.implementations("Base")] == [("Child", "declared")]
+
+
+def test_update_is_incremental(tmp_path):
+    _write_project(tmp_path)
+    db = tmp_path / "index.db"
+    with SymbolIndex(db) as index:
+        index.update(tmp_path)
+    with SymbolIndex(db) as index:
+        assert index.update(tmp_path).to_dict() == {"added": 0, "updated": 0, "removed": 0, "unchanged": 3}
+        models = tmp_path / "models.py"
+        models.write_text(PY_SOURCE + "\n\ndef helper():\n    pass\n", encoding="utf-8")
+        os.utime(models, (1, 1))
+        (tmp_path / "store" / "run.go").unlink()
+        stats = index.update(tmp_path)
+        assert (stats.updated, stats.removed, stats.unchanged) == (1, 1, 1)
+        assert index.defs("helper")
+        assert index.callers("Get") == []
+
+
+def test_grammar_upgrade_invalidates_files(tmp_path, monkeypatch):
+    _write_project(tmp_path)
+    db = tmp_path / "index.db"
+    with SymbolIndex(db) as index:
+        index.update(tmp_path)
+    from treesitter_tools import index as index_module
+
+    real = index_module.grammar_version
+    monkeypatch.setattr(index_module, "grammar_version", lambda lang: "upgraded" if lang == "go" else real(lang))
+    with SymbolIndex(db) as index:
+        assert list(index.stale_grammars()) == ["go"]
+        with pytest.raises(GrammarMismatchError, match="for go; run `treesitter-tools index build`"):
+            index.query("defs", "Store")
+        with pytest.raises(GrammarMismatchError):
+            index.get("Store")
+        assert index.update(tmp_path).to_dict() == {"added": 0, "updated": 2, "removed": 0, "unchanged": 1}
+        assert index.stale_grammars() == {}
+        assert index.query("defs", "Store")
+
+
+def test_get_returns_exact_source(tmp_path):
+    _write_project(tmp_path)
+    (tmp_path / "app").mkdir()
+    (tmp_path / "app" / "service.py").write_text(SERVICE, encoding="utf-8")
+    with SymbolIndex(tmp_path / "index.db") as index:
+        index.update(tmp_path)
+        [get] = index.get("Mem.Get")
+        assert get["source"] == "func (m *Mem) Get(key string) string { return key }\n"
+        assert (get["source_start_line"], get["stale"]) == (10, False)
+        assert [r["path"] for r in index.get("Get")] == ["store/store.go", "store/store.go"]
+        assert [r["start_line"] for r in index.get("store/store:Get", kind="method")] == [10, 16]
+
+        [total] = index.get("app.service:Service.total", doc=True, enclosing=True)
+        assert total["source"].startswith("    # Cached: computed once per instance.\n    @functools.cache\n")
+        assert total["enclosing"]["source"] == "class Service:\n"
+        assert snippet_to_text(total).startswith("class Service:\n    ...\n    # Cached")
+        [context] = index.get("app/service.py:total", context=1)
+        assert context["source"] == SERVICE.split("\n", 7)[7]
+
+        (tmp_path / "app" / "service.py").write_text(SERVICE.replace("42", "43"), encoding="utf-8")
+        assert index.get("total")[0]["stale"] is True
+
+
+def test_cli_get(tmp_path):
+    _write_project(tmp_path)
+    db = tmp_path / "index.db"
+    assert run_cli(["index", "build", ".", "--db", str(db)], cwd=tmp_path).returncode == 0
+    result = run_cli(["get", "Child.validate", "--db", str(db), "--enclosing"], cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    assert result.stdout == "class Child(Base):\n    ...\n    def validate(self):\n        return True\n"
+    result = run_cli(["get", "Get", "--db", str(db), "--format", "json"], cwd=tmp_path)
+    assert [r["qualified_name"] for r in json.loads(result.stdout)] == ["Mem.Get", "ReadOnly.Get"]
+    result = run_cli(["get", "Get", "--db", str(db)], cwd=tmp_path)
+    assert result.stdout.startswith("==> store/store.go:10-10 Mem.Get <==\n")
+    result = run_cli(["get", "missing", "--db", str(db)], cwd=tmp_path)
+    assert result.returncode == 1 and "No definition of 'missing'" in result.stderr
+
+
+def test_gc_drops_deleted_files_and_vectors(tmp_path):
+    from treesitter_tools.chunker import Chunk
+    from treesitter_tools.embeddings import EmbeddingStore
+
+    _write_project(tmp_path)
+    db = tmp_path / "index.db"
+    assert run_cli(["index", "build", ".", "--db", str(db)], cwd=tmp_path).returncode == 0
+    chunks = [
+        Chunk("models.py", "python", "class", "Base", 1, 2, "class Base: pass", index=0),
+        Chunk("store/run.go", "go", "function", "run", 3, 6, "class Base: pass", index=0),  # same text
+        Chunk("store/run.go", "go", "function", "run", 3, 6, "func run() {}", index=1),
+    ]
+    with EmbeddingStore(db) as store:
+        store.store("m", chunks, [[1.0], [1.0], [2.0]])
+        assert store.shared() == 1
+    (tmp_path / "store" / "run.go").unlink()
+
+    result = run_cli(["index", "gc", "--db", str(db)], cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    stats = json.loads(result.stdout)
+    assert {k: stats[k] for k in ("files", "embeddings", "vectors", "merged", "shared")} == {
+        "files": 1, "embeddings": 2, "vectors": 1, "merged": 0, "shared": 0,
+    }
+    assert stats["reclaimed"] == stats["bytes_before"] - stats["bytes_after"] >= 0
+    assert "Removed 1 files, 2 embeddings, 1 vectors" in result.stderr
+    with SymbolIndex(db) as index:
+        assert index.defs("run") == [] and index.defs("Mem.Get")
+    with EmbeddingStore(db) as store:
+        assert [(r["path"], r["vector"]) for r in store.vectors("m")] == [("models.py", [1.0])]
+
+    result = run_cli(["index", "gc", "--db", str(db), "--root", str(tmp_path / "elsewhere")], cwd=tmp_path)
+    assert result.returncode == 1 and "not a directory" in result.stderr
@@ -0,0 +1,87 @@
+"""Tests for embedded-language parsing (injection queries)."""
+
+from treesitter_tools.core import extract_symbols, parse_file
+from treesitter_tools.diagnostics import file_diagnostics
+from treesitter_tools.injections import find_injections, injection_query
+
+
+def _injections(path):
+    parsed = parse_file(path)
+    return parsed, find_injections(parsed.root, parsed.source, parsed.language)
+
+
+def test_go_sql_literal_is_injected_without_quotes(tmp_path):
+    path = tmp_path / "store.go"
+    path.write_text(
+        'package store\n\nconst byID = "SELECT id, name FROM users WHERE id = 1"\n\nconst greeting = "hello"\n',
+        encoding="utf-8",
+    )
+    parsed, found = _injections(path)
+    assert [i.language for i in found] == ["sql"]
+    region = found[0].ranges[0]
+    assert parsed.source[region.start_byte : region.end_byte].startswith(b"SELECT")
+    assert parsed.source[region.start_byte : region.end_byte].endswith(b"= 1")
+    assert found[0].start_line == 3
+
+
+def test_go_template_parse_is_html(tmp_path):
+    path = tmp_path / "page.go"
+    path.write_text(
+        'package page\n\nimport "html/template"\n\n'
+        'var page = template.Must(template.New("page").Parse(`<p>{{.Name}}</p>`))\n',
+        encoding="utf-8",
+    )
+    _, found = _injections(path)
+    assert [i.language for i in found] == ["html"]
+
+
+def test_html_script_and_style(tmp_path):
+    path = tmp_path / "index.html"
+    path.write_text(
+        "<html>\n<style>p { color: red; }</style>\n"
+        "<script>\nfunction greet(name) {\n  return 'hi ' + name;\n}\n</script>\n"
+        '<script type="application/json">{"a": 1}</script>\n'
+        '<script lang="ts">\nfunction typed(x: number): number { return x; }\n</script>\n</html>\n',
+        encoding="utf-8",
+    )
+    _, found = _injections(path)
+    assert [i.language for i in found] == ["css", "javascript", "typescript"]
+
+
+def test_embedded_symbols_keep_host_lines(tmp_path):
+    path = tmp_path / "index.html"
+    path.write_text(
+        "<html>\n<body>\n<script>\nfunction greet(name) {\n  return 'hi ' + name;\n}\n</script>\n</body>\n</html>\n",
+        encoding="utf-8",
+    )
+    symbols = extract_symbols(path)
+    greet = next(s for s in symbols if s.name == "greet")
+    assert greet.language == "javascript"
+    assert (greet.start_line, greet.end_line) == (4, 6)
+    assert greet.to_dict()["language"] == "javascript"
+
+
+def test_host_symbols_have_no_language(tmp_path):
+    path = tmp_path / "mod.py"
+    path.write_text("def ok():\n    return 1\n", encoding="utf-8")
+    symbols = extract_symbols(path)
+    assert [s.language for s in symbols] == [None]
+    assert "language" not in symbols[0].to_dict()
+
+
+def test_embedded_syntax_errors_are_reported(tmp_path):
+    path = tmp_path / "index.html"
+    path.write_text("<html>\n<script>\nfunction broken( {\n</script>\n</html>\n", encoding="utf-8")
+    found = file_diagnostics(parse_file(path), "index.html")
+    assert found
+    assert all(d.language == "javascript" for d in found)
+    assert found[0].line == 3
+    assert found[0].to_text().endswith("[javascript]")
+    assert file_diagnostics(parse_file(path), "index.html", injections=False) == []
+
+
+def test_language_without_injections_query(tmp_path):
+    assert injection_query("rust") is None
+    path = tmp_path / "lib.rs"
+    path.write_text('const Q: &str = "SELECT 1";\n', encoding="utf-8")
+    assert _injections(path)[1] == []
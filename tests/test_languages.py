@@ -0,0 +1,77 @@
+"""Tests for pluggable language specs."""
+
+from pathlib import Path
+
+import pytest
+
+from treesitter_tools import core
+from treesitter_tools.core import detect_language, extract_symbols, get_language_spec, register_language, run_query
+from treesitter_tools.languages import BUILTIN_SPECS, LanguageSpec
+
+
+def test_builtin_specs_registered():
+    for name in ("python", "javascript", "typescript", "tsx", "rust"):
+        spec = get_language_spec(name)
+        assert spec is not None
+        assert "definitions" in spec.available_queries()
+    assert detect_language(Path("App.tsx")) == "tsx"
+    assert detect_language(Path("mod.mjs")) == "javascript"
+
+
+@pytest.mark.parametrize("spec", BUILTIN_SPECS, ids=lambda s: s.name)
+def test_bundled_queries_compile(spec):
+    language = core.load_language(spec.name)
+    for name in spec.available_queries():
+        core.Query(language, spec.load_query(name))
+
+
+def test_tsx_component(tmp_path):
+    f = tmp_path / "App.tsx"
+    f.write_text(
+        "interface Props { name: string }\n"
+        "export function App({ name }: Props) {\n  return <div>{name}</div>;\n}\n"
+        "const Footer = () => <footer />;\n",
+        encoding="utf-8",
+    )
+    names = {(s.kind, s.name) for s in extract_symbols(f)}
+    assert ("class", "Props") in names
+    assert ("function", "App") in names
+    assert ("function", "Footer") in names
+    defs = run_query(f, get_language_spec("tsx").load_query("definitions"))
+    assert {c["text"] for m in defs for c in m["captures"] if c["name"] == "name"} >= {"Props", "App", "Footer"}
+
+
+def test_rust_traits_and_enums(tmp_path):
+    f = tmp_path / "lib.rs"
+    f.write_text(
+        "/// Shapes.\npub trait Shape { fn area(&self) -> f64; }\npub enum Kind { A, B }\n",
+        encoding="utf-8",
+    )
+    symbols = {s.name: s for s in extract_symbols(f)}
+    assert symbols["Shape"].kind == "class"
+    assert "Shapes." in symbols["Shape"].docstring
+    assert symbols["Kind"].kind == "class"
+
+
+def test_register_custom_language(tmp_path, monkeypatch):
+    for table in ("LANGUAGE_SPECS", "LANGUAGE_MAPPINGS", "FUNCTION_NODE_TYPES", "CLASS_NODE_TYPES", "IMPORT_NODE_TYPES"):
+        monkeypatch.setattr(core, table, dict(getattr(core, table)))
+    queries = tmp_path / "queries"
+    queries.mkdir()
+    (queries / "definitions.scm").write_text("(function_definition) @definition.function\n", encoding="utf-8")
+    register_language(
+        LanguageSpec(
+            name="pyish",
+            extensions=("pyish",),
+            function_nodes=frozenset({"function_definition"}),
+            grammar="python",
+            query_dir=queries,
+        )
+    )
+    f = tmp_path / "x.pyish"
+    f.write_text("def hello():\n    pass\n", encoding="utf-8")
+    assert detect_language(f) == "pyish"
+    assert [s.name for s in extract_symbols(f)] == ["hello"]
+    assert get_language_spec("pyish").available_queries() == ["definitions"]
+    with pytest.raises(ValueError, match="No 'missing' query"):
+        get_language_spec("pyish").load_query("missing")
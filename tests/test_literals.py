@@ -0,0 +1,91 @@
+"""Tests for string literal extraction."""
+
+import json
+
+import pytest
+
+from treesitter_tools.literals import iter_literals, literal_value, literals_to_json, literals_to_text
+
+PY_SOURCE = '''\
+"""Settings loader."""
+
+API_KEY = "sk-live-0123456789abcdef"
+
+
+def greet(user):
+    """Say hello."""
+    if user.role == "admin":
+        return _("Welcome back, admin")
+    return connect(host="db.internal", label=f"hi {user.name}")
+'''
+
+JS_SOURCE = """\
+import { t } from "./i18n";
+
+const config = { endpoint: "https://api.example.com", retries: 3 };
+
+export function title(name) {
+  return t(`Hello ${name}`);
+}
+"""
+
+
+@pytest.fixture
+def project(tmp_path):
+    (tmp_path / "settings.py").write_text(PY_SOURCE, encoding="utf-8")
+    (tmp_path / "title.js").write_text(JS_SOURCE, encoding="utf-8")
+    return tmp_path
+
+
+def _by_value(literals):
+    return {s.value: s for s in literals}
+
+
+def test_python_literals_have_context_and_function(project):
+    found = _by_value(iter_literals(project / "settings.py"))
+    key = found["sk-live-0123456789abcdef"]
+    assert (key.line, key.column, key.function, key.context, key.target) == (3, 11, None, "assignment", "API_KEY")
+    assert (found["admin"].context, found["admin"].target) == ("comparison", "user.role")
+    welcome = found["Welcome back, admin"]
+    assert (welcome.function, welcome.context, welcome.target) == ("greet", "call", "_")
+    assert (found["db.internal"].context, found["db.internal"].target) == ("keyword", "host")
+    assert found["hi {user.name}"].raw == 'f"hi {user.name}"'
+    assert found["Say hello."].context == "docstring"
+    assert found["Settings loader."].context == "docstring"
+
+
+def test_javascript_literals(project):
+    found = _by_value(iter_literals(project / "title.js"))
+    assert found["./i18n"].context == "import"
+    assert (found["https://api.example.com"].context, found["https://api.example.com"].target) == ("value", "endpoint")
+    template = found["Hello ${name}"]
+    assert (template.node_type, template.function, template.context, template.target) == (
+        "template_string", "title", "call", "t",
+    )
+
+
+def test_filters(project):
+    long_values = {s.value for s in iter_literals(project, min_length=15, docstrings=False)}
+    assert long_values == {"sk-live-0123456789abcdef", "Welcome back, admin", "https://api.example.com"}
+    secrets = list(iter_literals(project, pattern=r"^sk-(live|test)-"))
+    assert [(s.path, s.line) for s in secrets] == [("settings.py", 3)]
+    with pytest.raises(ValueError, match="Invalid --match"):
+        list(iter_literals(project, pattern="("))
+
+
+def test_literal_value_strips_prefixes_and_delimiters():
+    assert literal_value('rb"\\d+"') == "\\d+"
+    assert literal_value('r#"say "hi""#') == 'say "hi"'
+    assert literal_value('R"sql(SELECT 1)sql"') == "SELECT 1"
+    assert literal_value('@"C:\\temp"') == "C:\\temp"
+    assert literal_value('"""\n  block\n"""') == "\n  block\n"
+    assert literal_value("`raw`") == "raw"
+
+
+def test_output_formats(project):
+    literals = list(iter_literals(project / "settings.py", docstrings=False))
+    text = literals_to_text(literals)
+    assert "settings.py:9:18 [greet] call(_) Welcome back, admin\n" in text
+    payload = json.loads(literals_to_json(literals))
+    assert payload[0]["value"] == "sk-live-0123456789abcdef"
+    assert set(payload[0]) >= {"path", "line", "column", "function", "context", "target", "raw"}
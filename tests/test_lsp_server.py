@@ -0,0 +1,104 @@
+"""Tests for the minimal LSP server."""
+
+import io
+
+from treesitter_tools.batch import read_message, write_message
+from treesitter_tools.lsp_server import LSPServer
+
+HELPERS = """\
+def shared_helper(x):
+    return x * 2
+"""
+
+MAIN = """\
+from helpers import shared_helper
+
+
+class Greeter:
+    def hi(self):
+        return shared_helper(1)
+
+
+def main():
+    Greeter().hi()
+"""
+
+
+def _request(server, method, params, msg_id=1):
+    return server.handle({"jsonrpc": "2.0", "id": msg_id, "method": method, "params": params})
+
+
+def _started(tmp_path):
+    (tmp_path / "helpers.py").write_text(HELPERS, encoding="utf-8")
+    server = LSPServer(tmp_path)
+    init = _request(server, "initialize", {"rootUri": tmp_path.as_uri(), "capabilities": {}})
+    assert init["result"]["capabilities"]["positionEncoding"] == "utf-16"
+    server.handle({"jsonrpc": "2.0", "method": "initialized", "params": {}})
+    uri = (tmp_path / "main.py").as_uri()
+    server.handle({"jsonrpc": "2.0", "method": "textDocument/didOpen", "params": {
+        "textDocument": {"uri": uri, "languageId": "python", "version": 1, "text": MAIN}}})
+    return server, uri
+
+
+def test_requires_initialize(tmp_path):
+    response = _request(LSPServer(tmp_path), "textDocument/documentSymbol", {"textDocument": {"uri": "file:///x.py"}})
+    assert response["error"]["code"] == -32002
+
+
+def test_document_symbols_and_folds_use_the_buffer(tmp_path):
+    server, uri = _started(tmp_path)  # main.py was never written to disk
+    symbols = _request(server, "textDocument/documentSymbol", {"textDocument": {"uri": uri}})["result"]
+    assert [s["name"] for s in symbols] == ["Greeter", "main"]
+    assert symbols[0]["children"][0]["name"] == "hi"
+    folds = _request(server, "textDocument/foldingRange", {"textDocument": {"uri": uri}})["result"]
+    assert {"startLine": 3, "endLine": 5} == {k: folds[0][k] for k in ("startLine", "endLine")}
+
+    server.handle({"jsonrpc": "2.0", "method": "textDocument/didChange", "params": {
+        "textDocument": {"uri": uri, "version": 2}, "contentChanges": [{"text": MAIN.replace("main", "entry")}]}})
+    symbols = _request(server, "textDocument/documentSymbol", {"textDocument": {"uri": uri}})["result"]
+    assert [s["name"] for s in symbols] == ["Greeter", "entry"]
+
+
+def test_selection_range(tmp_path):
+    server, uri = _started(tmp_path)
+    result = _request(server, "textDocument/selectionRange", {
+        "textDocument": {"uri": uri}, "positions": [{"line": 5, "character": 16}]})["result"][0]
+    ranges = []
+    while result:
+        ranges.append(result["range"])
+        result = result.get("parent")
+    assert ranges[0] == {"start": {"line": 5, "character": 15}, "end": {"line": 5, "character": 28}}
+    assert ranges[-1]["start"] == {"line": 0, "character": 0}
+    assert len(ranges) > 4
+
+
+def test_definition_local_and_indexed(tmp_path):
+    server, uri = _started(tmp_path)
+    local = _request(server, "textDocument/definition", {
+        "textDocument": {"uri": uri}, "position": {"line": 9, "character": 5}})["result"]
+    assert local == [{"uri": uri, "range": {"start": {"line": 3, "character": 6}, "end": {"line": 3, "character": 13}}}]
+    indexed = _request(server, "textDocument/definition", {
+        "textDocument": {"uri": uri}, "position": {"line": 5, "character": 28}})["result"]
+    assert indexed == [{"uri": (tmp_path / "helpers.py").as_uri(),
+                        "range": {"start": {"line": 0, "character": 4}, "end": {"line": 0, "character": 17}}}]
+
+
+def test_workspace_symbol(tmp_path):
+    server, _ = _started(tmp_path)
+    symbols = _request(server, "workspace/symbol", {"query": "helper"})["result"]
+    assert [(s["name"], s["kind"]) for s in symbols] == [("shared_helper", 12)]
+
+
+def test_serve_lifecycle(tmp_path):
+    stdin = io.BytesIO()
+    write_message(stdin, {"jsonrpc": "2.0", "id": 1, "method": "initialize", "params": {"capabilities": {}}})
+    write_message(stdin, {"jsonrpc": "2.0", "id": 2, "method": "shutdown"})
+    write_message(stdin, {"jsonrpc": "2.0", "id": 3, "method": "workspace/symbol", "params": {"query": ""}})
+    write_message(stdin, {"jsonrpc": "2.0", "method": "exit"})
+    stdin.seek(0)
+    stdout = io.BytesIO()
+    assert LSPServer(tmp_path).serve(stdin, stdout) == 0
+    stdout.seek(0)
+    responses = [read_message(stdout) for _ in range(3)]
+    assert [r["id"] for r in responses] == [1, 2, 3]
+    assert responses[2]["error"]["code"] == -32600
@@ -0,0 +1,79 @@
+"""Tests for repository manifests and their comparison."""
+
+import json
+
+from conftest import run_cli
+
+from treesitter_tools.manifest import build_manifest, diff_manifests, load_manifest
+
+PY_SOURCE = "def greet(name):\n    return 'hi ' + name\n\n\ndef _helper():\n    return 1\n"
+
+
+def _tree(root, files):
+    for name, text in files.items():
+        path = root / name
+        path.parent.mkdir(parents=True, exist_ok=True)
+        path.write_text(text, encoding="utf-8")
+    return build_manifest(root)
+
+
+def _levels(diff):
+    return {c.name: c.level or c.change for c in diff.packages}, {c.name: c.level or c.change for c in diff.files}
+
+
+def test_manifest_entries(tmp_path):
+    manifest = _tree(tmp_path, {"pkg/app.py": PY_SOURCE, "tests/test_app.py": "def test_x():\n    pass\n"})
+    data = manifest.to_dict()
+    app = next(f for f in data["files"] if f["path"] == "pkg/app.py")
+    assert app["language"] == "python" and len(app["sha256"]) == 64
+    assert app["symbols"] == {"functions": 2, "classes": 0, "public": 1}
+    assert app["api_hash"] is not None
+    test = next(f for f in data["files"] if f["path"] == "tests/test_app.py")
+    assert test["test"] is True and test["api_hash"] is None
+    assert [p["name"] for p in data["packages"]] == ["pkg.app"]
+    assert build_manifest(tmp_path).fingerprint == manifest.fingerprint
+
+
+def test_change_levels(tmp_path):
+    before = _tree(tmp_path, {"app.py": PY_SOURCE, "util.py": "X = 1\n", "old.py": "def f():\n    pass\n"})
+    (tmp_path / "old.py").unlink()
+    after = _tree(tmp_path, {
+        "app.py": "# greeting helpers\ndef greet(name):\n    return  'hi ' + name\n\n\ndef _helper():\n    return 1\n",
+        "util.py": "X = 2\n",
+        "new.py": "def g():\n    pass\n",
+    })
+    packages, files = _levels(diff_manifests(before, after))
+    assert files["app.py"] == "formatting"
+    assert files["util.py"] == "api"  # a constant's value is part of its signature
+    assert files["old.py"] == "removed" and files["new.py"] == "added"
+    assert packages["app"] == "formatting" and packages["old"] == "removed"
+
+    later = _tree(tmp_path, {"app.py": PY_SOURCE.replace("return 1", "return 2")})
+    assert _levels(diff_manifests(build_manifest(tmp_path), later)) == ({}, {})
+    packages, _ = _levels(diff_manifests(after, later))
+    assert packages["app"] == "code"
+
+
+def test_moving_a_declaration_within_a_go_package_is_not_an_api_change(tmp_path):
+    before = _tree(tmp_path, {"p/a.go": "package p\n\nfunc A() int { return 1 }\n", "p/b.go": "package p\n"})
+    after = _tree(tmp_path, {"p/a.go": "package p\n", "p/b.go": "package p\n\nfunc A() int { return 1 }\n"})
+    packages, files = _levels(diff_manifests(before, after))
+    assert files == {"p/a.go": "api", "p/b.go": "api"}
+    assert packages == {"p": "code"}
+
+
+def test_cli_manifest_and_diff(tmp_path):
+    (tmp_path / "app.py").write_text(PY_SOURCE, encoding="utf-8")
+    result = run_cli(["manifest", ".", "--output", "before.json"], cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    assert load_manifest(tmp_path / "before.json").fingerprint == json.loads((tmp_path / "before.json").read_text())["fingerprint"]
+
+    (tmp_path / "app.py").write_text(PY_SOURCE.replace("name):", "name, loud=False):"), encoding="utf-8")
+    result = run_cli(["manifest-diff", "before.json", ".", "--format", "json"], cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    diff = json.loads(result.stdout)
+    assert {"change": "modified", "name": "app", "language": "python", "level": "api"} in diff["packages"]
+
+    (tmp_path / "bad.json").write_text("[]", encoding="utf-8")
+    result = run_cli(["manifest-diff", "bad.json", "."], cwd=tmp_path)
+    assert result.returncode == 1 and "not a manifest" in result.stderr
@@ -0,0 +1,70 @@
+"""Tests for the MCP stdio server."""
+
+import io
+import json
+
+from treesitter_tools.mcp_server import MCPServer
+
+PY_SOURCE = """\
+class Greeter:
+    def hi(self):
+        return "hi"
+
+
+def main():
+    Greeter().hi()
+"""
+
+
+def _call(server, name, arguments, msg_id=1):
+    response = server.handle(
+        {"jsonrpc": "2.0", "id": msg_id, "method": "tools/call", "params": {"name": name, "arguments": arguments}}
+    )
+    return response["result"]
+
+
+def test_initialize_and_list(tmp_path):
+    server = MCPServer(tmp_path)
+    init = server.handle({"jsonrpc": "2.0", "id": 1, "method": "initialize",
+                          "params": {"protocolVersion": "2024-11-05", "capabilities": {}}})
+    assert init["result"]["protocolVersion"] == "2024-11-05"
+    assert "tools" in init["result"]["capabilities"]
+    assert server.handle({"jsonrpc": "2.0", "method": "notifications/initialized"}) is None
+    tools = server.handle({"jsonrpc": "2.0", "id": 2, "method": "tools/list"})["result"]["tools"]
+    names = {tool["name"] for tool in tools}
+    assert {"extract_symbols", "get_function_body", "find_references", "chunk_file"} <= names
+    unknown = server.handle({"jsonrpc": "2.0", "id": 3, "method": "nope"})
+    assert unknown["error"]["code"] == -32601
+
+
+def test_tools(tmp_path):
+    (tmp_path / "app.py").write_text(PY_SOURCE, encoding="utf-8")
+    server = MCPServer(tmp_path)
+    symbols = json.loads(_call(server, "extract_symbols", {"path": "app.py"})["content"][0]["text"])
+    assert [s["name"] for s in symbols] == ["Greeter", "hi", "main"]
+    assert "content" not in symbols[0]
+    body = json.loads(_call(server, "get_function_body", {"path": "app.py", "name": "Greeter.hi"})["content"][0]["text"])
+    assert body["start_line"] == 2 and 'return "hi"' in body["content"]
+    refs = json.loads(_call(server, "find_references", {"name": "hi"})["content"][0]["text"])
+    assert [(r["caller"], r["file"], r["line"]) for r in refs] == [("main", "app.py", 7)]
+    chunks = json.loads(_call(server, "chunk_file", {"path": "app.py", "max_tokens": 64})["content"][0]["text"])
+    assert chunks and chunks[0]["path"] == "app.py"
+
+
+def test_errors_are_tool_results(tmp_path):
+    server = MCPServer(tmp_path)
+    outside = _call(server, "extract_symbols", {"path": "../etc/passwd"})
+    assert outside["isError"] and "outside" in outside["content"][0]["text"]
+    missing = server.handle({"jsonrpc": "2.0", "id": 9, "method": "tools/call",
+                             "params": {"name": "get_function_body", "arguments": {"path": "x.py"}}})
+    assert missing["error"]["code"] == -32602
+
+
+def test_serve_stdio(tmp_path):
+    server = MCPServer(tmp_path)
+    stdin = io.StringIO('{"jsonrpc": "2.0", "id": 1, "method": "ping"}\nnot json\n')
+    stdout = io.StringIO()
+    server.serve(stdin, stdout)
+    lines = [json.loads(line) for line in stdout.getvalue().splitlines()]
+    assert lines[0] == {"jsonrpc": "2.0", "id": 1, "result": {}}
+    assert lines[1]["error"]["code"] == -32700
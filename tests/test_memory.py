@@ -0,0 +1,60 @@
+"""Tests for mapped reading, file size guards, and memory reporting."""
+
+import mmap
+
+import pytest
+
+from treesitter_tools import core, memory
+from treesitter_tools.incremental import IncrementalSession
+from treesitter_tools.memory import check_file_size, format_size, memory_watermark, parse_size, read_source
+
+SOURCE = "def foo():\n    return 1\n\n\nclass Bar:\n    pass\n"
+
+
+def test_parse_size_units():
+    assert parse_size("4096") == 4096
+    assert parse_size("512KB") == 512 * 1024
+    assert parse_size("50mb") == 50 * 1024 * 1024
+    assert parse_size("1.5 GiB") == 3 * 1024**3 // 2
+    with pytest.raises(ValueError, match="Invalid size"):
+        parse_size("ten megs")
+    assert format_size(50 * 1024 * 1024) == "50.0 MiB"
+
+
+def test_read_source_maps_large_files(tmp_path):
+    path = tmp_path / "a.py"
+    path.write_text(SOURCE, encoding="utf-8")
+    with read_source(path) as decoded:
+        assert decoded.data == SOURCE.encode("utf-8") and decoded.encoding == "utf-8"
+    with read_source(path, threshold=16) as decoded:
+        source = decoded.data
+        assert isinstance(source, mmap.mmap)
+        assert source[:3] == b"def"
+    assert source.closed
+
+
+def test_mapped_extraction_matches_read(tmp_path, monkeypatch):
+    path = tmp_path / "a.py"
+    path.write_text(SOURCE, encoding="utf-8")
+    expected = core.extract_symbols(path)
+    monkeypatch.setattr(memory, "MMAP_THRESHOLD", 16)
+    monkeypatch.setattr(memory, "READ_CHUNK", 7)  # several parser reads per file
+    assert core.extract_symbols(path) == expected
+    session = IncrementalSession(tmp_path / "cache")
+    assert session.extract(path) == expected
+    assert session._trees == {}
+
+
+def test_max_file_size_skips_large_files(tmp_path):
+    (tmp_path / "small.py").write_text("def a(): pass\n", encoding="utf-8")
+    (tmp_path / "big.py").write_text(SOURCE * 10, encoding="utf-8")
+    with pytest.raises(ValueError, match="exceeds --max-file-size"):
+        check_file_size(tmp_path / "big.py", 100)
+    reports = core.scan_directory(tmp_path, max_file_size=100)
+    by_name = {r.path.name: r for r in reports}
+    assert [s.name for s in by_name["small.py"].symbols] == ["a"]
+    assert "exceeds --max-file-size" in by_name["big.py"].error
+
+
+def test_memory_watermark():
+    assert memory_watermark().startswith("Peak RSS: ")
@@ -0,0 +1,84 @@
+"""Tests for per-function complexity metrics."""
+
+import pytest
+
+from treesitter_tools.metrics import collect_metrics, find_violations, metrics_to_csv, parse_thresholds
+
+PY_SOURCE = """\
+def simple():
+    return 1
+
+
+def branchy(x, y):
+    # a comment line
+    if x and y:
+        for i in x:
+            pass
+    elif x:
+        pass
+    else:
+        pass
+"""
+
+GO_SOURCE = """\
+package main
+
+func classify(n int) string {
+	if n < 0 {
+		return "neg"
+	} else if n == 0 {
+		return "zero"
+	} else {
+		return "pos"
+	}
+}
+"""
+
+
+def _by_name(results):
+    return {m.name: m for m in results}
+
+
+def test_python_metrics(tmp_path):
+    src = tmp_path / "m.py"
+    src.write_text(PY_SOURCE, encoding="utf-8")
+    metrics = _by_name(collect_metrics(src))
+    assert metrics["simple"].cyclomatic == 1
+    assert metrics["simple"].cognitive == 0
+    branchy = metrics["branchy"]
+    assert branchy.cyclomatic == 5  # if, and, for, elif
+    assert branchy.cognitive == 6  # if(1) + and(1) + for(2) + elif(1) + else(1)
+    assert branchy.max_nesting == 2
+    assert branchy.loc == 9
+    assert branchy.sloc == 8  # comment-only line excluded
+
+
+def test_go_else_if_is_flat(tmp_path):
+    src = tmp_path / "main.go"
+    src.write_text(GO_SOURCE, encoding="utf-8")
+    classify = _by_name(collect_metrics(src))["classify"]
+    assert classify.cyclomatic == 3
+    assert classify.cognitive == 3  # if(1) + else if(1) + else(1)
+    assert classify.max_nesting == 1
+
+
+def test_directory_paths_are_relative(tmp_path):
+    (tmp_path / "pkg").mkdir()
+    (tmp_path / "pkg" / "m.py").write_text(PY_SOURCE, encoding="utf-8")
+    (tmp_path / "notes.txt").write_text("no code here", encoding="utf-8")
+    results = collect_metrics(tmp_path)
+    assert {m.path for m in results} == {"pkg/m.py"}
+    header = metrics_to_csv(results).splitlines()[0]
+    assert header.startswith("path,name,start_line")
+
+
+def test_thresholds(tmp_path):
+    src = tmp_path / "m.py"
+    src.write_text(PY_SOURCE, encoding="utf-8")
+    results = collect_metrics(src)
+    limits = parse_thresholds(["4", "nesting=5"])
+    assert limits == {"cyclomatic": 4, "max_nesting": 5}
+    violations = find_violations(results, limits)
+    assert [(v.metrics.name, v.metric, v.value) for v in violations] == [("branchy", "cyclomatic", 5)]
+    with pytest.raises(ValueError, match="Unknown metric"):
+        parse_thresholds(["speed=3"])
@@ -0,0 +1,66 @@
+"""Tests for streaming NDJSON scan output."""
+
+import io
+import json
+from pathlib import Path
+
+from conftest import run_cli
+
+from treesitter_tools.core import CodeSymbol, FileSymbols
+from treesitter_tools.ndjson import NDJSONWriter, report_records
+
+
+class _CountingStream(io.StringIO):
+    def __init__(self):
+        super().__init__()
+        self.flushes = 0
+
+    def flush(self):
+        self.flushes += 1
+        super().flush()
+
+
+def test_writer_flushes_by_count_and_time():
+    now = [0.0]
+    stream = _CountingStream()
+    writer = NDJSONWriter(stream, flush_every=2, flush_interval=10.0, clock=lambda: now[0])
+    writer.write({"a": 1})
+    assert stream.flushes == 0
+    writer.write({"a": 2})
+    assert stream.flushes == 1
+    now[0] = 11.0
+    writer.write({"a": 3})
+    assert stream.flushes == 2
+    assert [json.loads(line) for line in stream.getvalue().splitlines()] == [{"a": 1}, {"a": 2}, {"a": 3}]
+
+
+def test_per_symbol_records():
+    sym = CodeSymbol(kind="function", name="f", start_line=1, end_line=2, signature="def f():", docstring=None)
+    report = FileSymbols(path=Path("pkg/a.py"), language="python", symbols=[sym])
+    records = list(report_records(report, per_symbol=True))
+    assert records == [{"path": "pkg/a.py", "language": "python", **sym.to_dict()}]
+    assert list(report_records(report)) == [report.to_dict()]
+
+
+def test_scan_ndjson_output_file(tmp_path):
+    src = tmp_path / "src"
+    src.mkdir()
+    (src / "a.py").write_text("def foo():\n    pass\n\nclass Bar:\n    pass\n", encoding="utf-8")
+    (src / "b.py").write_text("def baz():\n    pass\n", encoding="utf-8")
+    out = src / "symbols.ndjson"
+    result = run_cli(["scan", str(src), "--format", "ndjson", "--per-symbol", "--output", str(out)])
+    assert result.returncode == 0, result.stderr
+    records = [json.loads(line) for line in out.read_text(encoding="utf-8").splitlines()]
+    assert [r["name"] for r in records] == ["foo", "Bar", "baz"]
+    assert all(r["content"] is None for r in records)
+    # The output file itself must not be scanned.
+    assert not any(r["path"].endswith("symbols.ndjson") for r in records)
+
+
+def test_scan_ndjson_stdout(tmp_path):
+    (tmp_path / "a.py").write_text("def foo():\n    pass\n", encoding="utf-8")
+    result = run_cli(["scan", str(tmp_path), "--format", "ndjson"])
+    assert result.returncode == 0
+    lines = result.stdout.splitlines()
+    assert len(lines) == 1
+    assert json.loads(lines[0])["symbols"][0]["name"] == "foo"
@@ -0,0 +1,232 @@
+"""Tests for the cross-language symbol schema."""
+
+import json
+from pathlib import Path
+
+from conftest import run_cli
+
+from treesitter_tools.core import ParsedFile, extract_symbols, parse_source
+from treesitter_tools.normalize import (
+    KINDS,
+    VISIBILITIES,
+    annotate_visibility,
+    filter_visibility,
+    normalize_symbols,
+    symbol_infos,
+)
+
+PYTHON = '''class Outer:
+    class Inner:
+        def __init__(self):
+            pass
+
+        def _hidden(self):
+            pass
+
+
+def top():
+    def local():
+        pass
+    return local
+
+
+@decorator
+def decorated():
+    pass
+'''
+
+GO = '''package server
+
+type Server struct{}
+
+type handler interface {
+	Serve()
+}
+
+func (s *Server) Start() error { return nil }
+
+func (s *Server) stop() {}
+
+func New() *Server { return nil }
+'''
+
+RUST = '''pub struct Point { x: i32 }
+
+impl Point {
+    pub fn new() -> Self { Point { x: 0 } }
+    fn norm(&self) -> i32 { self.x }
+}
+
+impl Display for Point {
+    fn fmt(&self, f: &mut Formatter) -> Result { Ok(()) }
+}
+
+pub(crate) fn helper() {}
+
+trait Shape {
+    fn area(&self) -> f64 { 0.0 }
+}
+'''
+
+TYPESCRIPT = '''export class Store {
+    private load() {}
+    protected save() {}
+    #secret() {}
+    fetch() {}
+}
+
+function local() {}
+
+export interface Shape {}
+'''
+
+JAVA = '''package p;
+
+public class Box {
+    public int get() { return 1; }
+    void reset() {}
+    private static void helper() {}
+}
+
+interface Shape {
+    double area();
+}
+'''
+
+
+def _parsed(source, language):
+    data = source.encode("utf-8")
+    return ParsedFile(Path("input"), language, data, parse_source(data, language))
+
+
+def _table(source, language, rel_path):
+    infos = symbol_infos(_parsed(source, language), rel_path)
+    assert all(i.kind in KINDS and i.visibility in VISIBILITIES for i in infos)
+    return {i.qualified_name: (i.kind, i.visibility) for i in infos}
+
+
+def test_python():
+    table = _table(PYTHON, "python", "src/pkg/mod.py")
+    assert table == {
+        "pkg.mod:Outer": ("class", "public"),
+        "pkg.mod:Outer.Inner": ("class", "public"),
+        "pkg.mod:Outer.Inner.__init__": ("constructor", "public"),
+        "pkg.mod:Outer.Inner._hidden": ("method", "private"),
+        "pkg.mod:top": ("function", "public"),
+        "pkg.mod:top.local": ("function", "private"),
+        "pkg.mod:decorated": ("function", "public"),
+    }
+    decorated = next(i for i in symbol_infos(_parsed(PYTHON, "python"), "mod.py") if i.name == "decorated")
+    assert decorated.start_line == 16 and decorated.container == []
+
+
+def test_python_all_limits_exports():
+    source = (
+        '__all__ = ["api"]\n__all__ += ("Model",)\n\n'
+        "def api(): pass\n\ndef helper(): pass\n\nclass Model:\n    def run(self): pass\n"
+    )
+    table = _table(source, "python", "mod.py")
+    assert table == {
+        "mod:api": ("function", "public"),
+        "mod:helper": ("function", "internal"),
+        "mod:Model": ("class", "public"),
+        "mod:Model.run": ("method", "public"),
+    }
+    assert _table("__all__ = names()\n\ndef helper(): pass\n", "python", "mod.py")["mod:helper"][1] == "public"
+
+
+def test_go():
+    assert _table(GO, "go", "server/server.go") == {
+        "server:Server": ("struct", "public"),
+        "server:handler": ("interface", "internal"),
+        "server:Server.Start": ("method", "public"),
+        "server:Server.stop": ("method", "internal"),
+        "server:New": ("function", "public"),
+    }
+
+
+def test_rust():
+    assert _table(RUST, "rust", "src/geo.rs") == {
+        "src/geo:Point": ("struct", "public"),
+        "src/geo:impl Point": ("impl", "public"),
+        "src/geo:Point.new": ("constructor", "public"),
+        "src/geo:Point.norm": ("method", "private"),
+        "src/geo:impl Display for Point": ("impl", "public"),
+        "src/geo:Point.fmt": ("method", "public"),
+        "src/geo:helper": ("function", "internal"),
+        "src/geo:Shape": ("trait", "private"),
+        "src/geo:Shape.area": ("method", "public"),
+    }
+
+
+def test_typescript():
+    assert _table(TYPESCRIPT, "typescript", "src/store.ts") == {
+        "src/store:Store": ("class", "public"),
+        "src/store:Store.load": ("method", "private"),
+        "src/store:Store.save": ("method", "protected"),
+        "src/store:Store.#secret": ("method", "private"),
+        "src/store:Store.fetch": ("method", "public"),
+        "src/store:local": ("function", "internal"),
+        "src/store:Shape": ("interface", "public"),
+    }
+
+
+def test_java():
+    assert _table(JAVA, "java", "src/p/Box.java") == {
+        "src/p:Box": ("class", "public"),
+        "src/p:Box.get": ("method", "public"),
+        "src/p:Box.reset": ("method", "internal"),
+        "src/p:Box.helper": ("method", "private"),
+        "src/p:Shape": ("interface", "internal"),
+        "src/p:Shape.area": ("method", "public"),
+    }
+
+
+def test_normalize_symbols_covers_chunks(tmp_path):
+    path = tmp_path / "mod.py"
+    path.write_text("class A:\n    def run(self):\n" + "        x = 1\n" * 20, encoding="utf-8")
+    symbols = extract_symbols(path, max_chunk_size=80)
+    normalize_symbols(symbols, _parsed(path.read_text(), "python"), "mod.py")
+    runs = [s for s in symbols if s.name == "run"]
+    assert len(runs) > 1
+    assert {(s.kind, s.qualified_name, tuple(s.container)) for s in runs} == {("method", "mod:A.run", ("A",))}
+    assert symbols[0].to_dict()["visibility"] == "public"
+
+
+def test_cli_normalize(tmp_path):
+    (tmp_path / "pkg").mkdir()
+    (tmp_path / "pkg" / "app.py").write_text(PYTHON, encoding="utf-8")
+    result = run_cli(["scan", ".", "--normalize"], cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    [report] = json.loads(result.stdout)
+    init = next(s for s in report["symbols"] if s["name"] == "__init__")
+    assert init["kind"] == "constructor" and init["container"] == ["Outer", "Inner"]
+    assert init["qualified_name"] == "pkg.app:Outer.Inner.__init__"
+
+    result = run_cli(["symbols", "pkg/app.py"], cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    assert all("qualified_name" not in s for s in json.loads(result.stdout))
+    result = run_cli(["symbols", "pkg/app.py", "--normalize"], cwd=tmp_path)
+    assert {s["qualified_name"]: s["visibility"] for s in json.loads(result.stdout)}["pkg.app:Outer.Inner._hidden"] == "private"
+
+
+def test_visibility_filters(tmp_path):
+    path = tmp_path / "mod.py"
+    path.write_text(PYTHON, encoding="utf-8")
+    symbols = extract_symbols(path)
+    annotate_visibility(symbols, _parsed(PYTHON, "python"), "mod.py")
+    hidden = next(s for s in symbols if s.name == "_hidden")
+    assert (hidden.kind, hidden.visibility, hidden.qualified_name) == ("function", "private", None)
+    assert hidden.to_dict()["visibility"] == "private"
+    assert [s.name for s in filter_visibility(symbols, ["private"])] == ["_hidden", "local"]
+
+    (tmp_path / "server.go").write_text(GO, encoding="utf-8")
+    result = run_cli(["scan", ".", "--only-public", "--include", "*.go"], cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    [report] = json.loads(result.stdout)
+    assert {s["name"]: s["visibility"] for s in report["symbols"]} == {"Server": "public", "Start": "public",
+                                                                      "New": "public"}
+    result = run_cli(["symbols", "mod.py", "--visibility", "private", "--normalize"], cwd=tmp_path)
+    assert [s["qualified_name"] for s in json.loads(result.stdout)] == ["mod:Outer.Inner._hidden", "mod:top.local"]
+    result = run_cli(["symbols", "mod.py", "--visibility", "secret"], cwd=tmp_path)
+    assert result.returncode == 1 and "Unknown --visibility secret" in result.stderr
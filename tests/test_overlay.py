@@ -0,0 +1,50 @@
+"""Tests for unsaved-buffer overlays."""
+
+import json
+
+import pytest
+from conftest import run_cli
+
+from treesitter_tools import overlay
+from treesitter_tools.core import extract_symbols, iter_source_files, run_query
+from treesitter_tools.overlay import Overlay, applied
+
+
+def test_overlay_reads(tmp_path, monkeypatch):
+    monkeypatch.chdir(tmp_path)
+    (tmp_path / "saved.py").write_text("x = 1\n", encoding="utf-8")
+    (tmp_path / "buffer").write_text("y = 2\n", encoding="utf-8")
+    layered = Overlay.from_specs(["./saved.py=buffer"])
+    layered.set("new.py", "z = 3\n")
+    assert len(layered) == 2 and layered.get(tmp_path / "saved.py") == b"y = 2\n"
+    assert overlay.read_bytes("saved.py") == b"x = 1\n"  # nothing active
+    with applied(layered):
+        assert overlay.read_bytes("saved.py") == b"y = 2\n" and overlay.size("new.py") == 6
+        assert overlay.is_file("new.py") and overlay.added_under(tmp_path) == [tmp_path / "new.py"]
+    assert overlay.ACTIVE is None and not overlay.is_file("new.py")
+    with pytest.raises(ValueError, match="PATH=CONTENTS_FILE"):
+        Overlay.from_specs(["saved.py"])
+    with pytest.raises(ValueError, match="Cannot read overlay contents for saved.py"):
+        Overlay.from_specs(["saved.py=missing"])
+
+
+def test_overlay_analysis(tmp_path):
+    app = tmp_path / "app.py"
+    app.write_text("def saved():\n    pass\n", encoding="utf-8")
+    with applied({app: "def unsaved():\n    pass\n", tmp_path / "pkg" / "new.py": "class Fresh:\n    pass\n"}):
+        assert [s.name for s in extract_symbols(app)] == ["unsaved"]
+        assert run_query(app, "(identifier) @id")[0]["captures"][0]["text"] == "unsaved"
+        assert [p.name for p in iter_source_files(tmp_path)] == ["app.py", "new.py"]
+    assert [s.name for s in extract_symbols(app)] == ["saved"]
+
+
+def test_cli_overlay(tmp_path):
+    (tmp_path / "app.py").write_text("def saved():\n    pass\n", encoding="utf-8")
+    (tmp_path / "buf").write_text("def unsaved():\n    pass\n", encoding="utf-8")
+    result = run_cli(["--overlay", "app.py=buf", "symbols", "app.py"], cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    assert [s["name"] for s in json.loads(result.stdout)] == ["unsaved"]
+    assert (tmp_path / "app.py").read_text(encoding="utf-8").startswith("def saved")
+
+    bad = run_cli(["--overlay", "app.py=nope", "symbols", "app.py"], cwd=tmp_path)
+    assert bad.returncode == 1 and "Cannot read overlay contents" in bad.stderr
@@ -0,0 +1,52 @@
+"""Tests for parallel scanning."""
+
+import multiprocessing
+from pathlib import Path
+
+from treesitter_tools import core, overlay, parallel
+from treesitter_tools.incremental import IncrementalSession
+from treesitter_tools.parallel import ordered_map
+
+
+def _project(tmp_path: Path) -> Path:
+    root = tmp_path / "proj"
+    root.mkdir()
+    for i in range(12):
+        (root / f"m{i:02d}.py").write_text(f"def f{i}():\n    return {i}\n", encoding="utf-8")
+    (root / "bad.py").write_bytes(b"\x00\x00")
+    return root
+
+
+def test_ordered_map_preserves_input_order_with_bound():
+    assert list(ordered_map(abs, [-5, 4, -3, 2, -1], jobs=2, max_in_flight=1)) == [5, 4, 3, 2, 1]
+
+
+def test_parallel_scan_matches_sequential(tmp_path):
+    root = _project(tmp_path)
+    sequential = core.scan_directory(root)
+    parallel = core.scan_directory(root, jobs=3, max_in_flight=2)
+    assert [r.to_dict() for r in parallel] == [r.to_dict() for r in sequential]
+    assert any(r.error for r in parallel)
+
+
+def test_parallel_scan_shares_disk_cache(tmp_path):
+    root = _project(tmp_path)
+    cache = tmp_path / "cache"
+    cold = IncrementalSession(cache)
+    core.scan_directory(root, session=cold, jobs=2)
+    assert cold.stats.reparsed == 12
+
+    warm = IncrementalSession(cache)
+    core.scan_directory(root, session=warm, jobs=2)
+    assert warm.stats.reparsed == 0
+    assert warm.stats.cache_hits == 12
+
+
+def test_spawned_workers_scan_with_the_parent_settings(tmp_path, monkeypatch):
+    root = _project(tmp_path)
+    monkeypatch.setattr(parallel, "MP_CONTEXT", multiprocessing.get_context("spawn"))
+    with overlay.applied({root / "m00.py": "def edited():\n    pass\n"}):
+        sequential = core.scan_directory(root)
+        spawned = core.scan_directory(root, jobs=2)
+    assert [r.to_dict() for r in spawned] == [r.to_dict() for r in sequential]
+    assert {Path(r.path).name: r for r in spawned}["m00.py"].symbols[0].name == "edited"
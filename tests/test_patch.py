@@ -0,0 +1,123 @@
+"""Tests for the patch-application engine and the apply command."""
+
+import json
+import os
+from pathlib import Path
+
+import pytest
+from conftest import run_cli
+
+from treesitter_tools import patch
+from treesitter_tools.patch import FileEdits, apply_patch, edit_set, load_edits, plan_patch, write_files
+from treesitter_tools.rewrite import Edit, FileRewrite
+
+GO_PRINTLN = (
+    '(call_expression function: (selector_expression operand: (identifier) @pkg field: (field_identifier) @fn)'
+    ' arguments: (argument_list) @args (#eq? @pkg "fmt") (#eq? @fn "Println"))'
+)
+
+
+def _file(tmp_path, name, text):
+    path = tmp_path / name
+    path.write_text(text, encoding="utf-8")
+    return path
+
+
+def _edits(path, *spans, sha256=None):
+    return FileEdits(path, [Edit(start, end, text, 1, 1) for start, end, text in spans], sha256)
+
+
+def test_merges_sets_and_drops_duplicates(tmp_path):
+    path = _file(tmp_path, "a.txt", "hello world\n")
+    plan = plan_patch([_edits(path, (0, 5, "HELLO")), _edits(path, (0, 5, "HELLO"), (6, 11, "there"))])
+    assert plan.conflicts == [] and plan.edits == 2
+    assert plan.files[0].rewritten == b"HELLO there\n"
+    assert path.read_text() == "hello world\n"  # planning writes nothing
+
+
+def test_conflicts(tmp_path):
+    path = _file(tmp_path, "a.txt", "one\ntwo\nthree\n")
+    plan = plan_patch([_edits(path, (0, 12, "x"), (4, 7, "TWO"), (8, 9, "T"))])
+    assert [(c.reason, c.line) for c in plan.conflicts] == [("overlap", 2), ("overlap", 3)]
+    assert plan.files == []
+
+    plan = plan_patch([_edits(path, (4, 4, "a")), _edits(path, (4, 7, "b"))])
+    assert [c.reason for c in plan.conflicts] == ["overlap"]  # insert vs. replace at one point
+    assert plan_patch([_edits(path, (0, 3, "1"), (3, 3, "!"))]).conflicts == []  # adjacent is fine
+
+    assert [c.reason for c in plan_patch([_edits(path, (10, 99, "x"))]).conflicts] == ["out_of_range"]
+    assert [c.reason for c in plan_patch([_edits(path, (0, 1, "x"), sha256="0" * 64)]).conflicts] == ["stale"]
+    assert [c.reason for c in plan_patch([_edits(tmp_path / "gone.txt", (0, 1, "x"))]).conflicts] == ["missing"]
+
+
+def test_apply_is_all_or_nothing(tmp_path):
+    good = _file(tmp_path, "good.txt", "abc\n")
+    bad = _file(tmp_path, "bad.txt", "xyz\n")
+    plan = apply_patch([_edits(good, (0, 1, "A")), _edits(bad, (0, 2, "1"), (1, 3, "2"))])
+    assert len(plan.conflicts) == 1 and good.read_text() == "abc\n"
+
+    apply_patch([_edits(good, (0, 1, "A"))], dry_run=True)
+    assert good.read_text() == "abc\n"
+    apply_patch([_edits(good, (0, 1, "A"))])
+    assert good.read_text() == "Abc\n"
+
+
+def test_failed_write_rolls_back(tmp_path, monkeypatch):
+    first = _file(tmp_path, "first.txt", "1\n")
+    second = _file(tmp_path, "second.txt", "2\n")
+    rewrites = [FileRewrite(first, b"1\n", b"one\n"), FileRewrite(second, b"2\n", b"two\n")]
+    replace = os.replace
+
+    def flaky_replace(src, dst):
+        if Path(dst) == second:
+            raise PermissionError(13, "Permission denied")
+        replace(src, dst)
+
+    monkeypatch.setattr(patch.os, "replace", flaky_replace)
+    with pytest.raises(OSError, match="restored 1 files"):
+        write_files(rewrites)
+    assert first.read_text() == "1\n" and second.read_text() == "2\n"
+    assert sorted(p.name for p in tmp_path.iterdir()) == ["first.txt", "second.txt"]  # no temp files left
+
+
+def test_write_refuses_concurrent_change(tmp_path):
+    path = _file(tmp_path, "a.txt", "edited elsewhere\n")
+    with pytest.raises(OSError, match="changed while"):
+        write_files([FileRewrite(path, b"original\n", b"new\n")])
+    assert path.read_text() == "edited elsewhere\n"
+
+
+def test_edit_set_round_trip(tmp_path):
+    path = _file(tmp_path, "a.txt", "abc\n")
+    rewrite = FileRewrite(path, b"abc\n", b"xbc\n", [Edit(0, 1, "x", 1, 1)])
+    text = patch.edits_to_json(edit_set([rewrite]), lambda p: p.name)
+    loaded = load_edits(text, base=tmp_path)
+    assert loaded[0].path == path and loaded[0].edits == rewrite.edits and loaded[0].sha256
+    with pytest.raises(ValueError, match="unsupported edit set format"):
+        load_edits('{"format": 2, "files": []}')
+    with pytest.raises(ValueError, match="invalid byte range"):
+        load_edits('{"files": [{"path": "a", "edits": [{"start_byte": 5, "end_byte": 1, "replacement": ""}]}]}')
+
+
+def test_cli_rewrite_edits_then_apply(tmp_path):
+    main = _file(tmp_path, "main.go", 'package main\n\nfunc main() {\n\tfmt.Println("a")\n}\n')
+    result = run_cli(["rewrite", ".", "-l", "go", "-q", GO_PRINTLN, "-r", "log.Info@args", "--format", "edits"],
+                     cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    edits = json.loads(result.stdout)
+    assert edits["files"][0]["path"] == "main.go" and len(edits["files"][0]["edits"]) == 1
+
+    preview = run_cli(["apply", "-", "--dry-run"], cwd=tmp_path, stdin=result.stdout)
+    assert preview.returncode == 0, preview.stderr
+    assert '+\tlog.Info("a")' in preview.stdout and "Would apply 1 edits to 1 files" in preview.stderr
+    assert "fmt.Println" in main.read_text()
+
+    (tmp_path / "edits.json").write_text(result.stdout, encoding="utf-8")
+    applied = run_cli(["apply", "edits.json"], cwd=tmp_path)
+    assert applied.returncode == 0, applied.stderr
+    assert 'log.Info("a")' in main.read_text()
+
+    stale = run_cli(["apply", "edits.json"], cwd=tmp_path)
+    assert stale.returncode == 1
+    assert "main.go: file changed since the edits were computed" in stale.stderr
+    assert "no files were written" in stale.stderr
@@ -0,0 +1,83 @@
+"""Tests for query playground text output."""
+
+from conftest import run_cli
+
+from treesitter_tools.core import run_query
+from treesitter_tools.playground import render_matches
+
+GO_SOURCE = """\
+package main
+
+// Compute does work.
+func Compute(x int) int {
+    return x * 2
+}
+"""
+
+QUERY = "(function_declaration name: (identifier) @name) @func"
+
+
+def test_query_captures_include_ranges(tmp_path):
+    path = tmp_path / "main.go"
+    path.write_text(GO_SOURCE, encoding="utf-8")
+    matches = run_query(path, QUERY)
+    name = next(c for m in matches for c in m["captures"] if c["name"] == "name")
+    assert name["type"] == "identifier"
+    assert (name["start_line"], name["start_column"], name["end_column"]) == (4, 6, 13)
+    assert GO_SOURCE.encode("utf-8")[name["start_byte"]:name["end_byte"]] == b"Compute"
+
+
+def test_render_matches_underlines_captures():
+    matches = [
+        {
+            "pattern_index": 0,
+            "captures": [
+                {
+                    "name": "name",
+                    "type": "identifier",
+                    "text": "Compute",
+                    "start_line": 4,
+                    "end_line": 4,
+                    "start_column": 6,
+                    "end_column": 13,
+                    "start_byte": 41,
+                    "end_byte": 48,
+                }
+            ],
+        }
+    ]
+    text = render_matches(matches, GO_SOURCE.encode("utf-8"), context=1)
+    lines = text.splitlines()
+    assert lines[0] == "match 1 (pattern 0)"
+    assert lines[1] == "  @name identifier 4:6-4:13 bytes 41-48 'Compute'"
+    assert "3 | // Compute does work." in lines
+    assert "4 | func Compute(x int) int {" in lines
+    assert "  |      ^^^^^^^" in lines
+    assert "5 |     return x * 2" in lines
+    assert lines[-1] == "1 match"
+    assert "\x1b[" not in text
+
+    colored = render_matches(matches, GO_SOURCE.encode("utf-8"), highlight=True)
+    assert "\x1b[1;31mCompute\x1b[0m" in colored
+
+
+def test_cli_query_file_text_format(tmp_path):
+    path = tmp_path / "main.go"
+    path.write_text(GO_SOURCE, encoding="utf-8")
+    query_file = tmp_path / "q.scm"
+    query_file.write_text(QUERY, encoding="utf-8")
+    result = run_cli(["query", "--lang", "go", "--query-file", str(query_file), str(path), "--format", "text"])
+    assert result.returncode == 0, result.stderr
+    assert "@name identifier 4:6-4:13" in result.stdout
+    assert "@func function_declaration 4:1-6:2" in result.stdout
+    assert result.stdout.rstrip().endswith("1 match")
+
+
+def test_cli_query_rejects_query_and_query_file(tmp_path):
+    path = tmp_path / "main.go"
+    path.write_text(GO_SOURCE, encoding="utf-8")
+    query_file = tmp_path / "q.scm"
+    query_file.write_text(QUERY, encoding="utf-8")
+    result = run_cli(["query", str(path), QUERY, "--query-file", str(query_file)])
+    assert result.returncode == 1
+    assert "only one of" in result.stderr
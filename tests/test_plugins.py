@@ -0,0 +1,185 @@
+"""Tests for custom analyzer plugins (in-process and JSON-RPC subprocess)."""
+
+import json
+import sys
+import textwrap
+from pathlib import Path
+
+import pytest
+from conftest import run_cli
+
+from treesitter_tools.config import ConfigError, parse_config
+from treesitter_tools.plugins import Analyzer, Finding, PluginError, import_analyzer, run_analyzers
+from treesitter_tools.plugins.loader import command_analyzer
+
+
+class NoPrint(Analyzer):
+    name = "acme"
+    rules = {"no-print": "print() calls belong in the CLI layer"}
+
+    def __init__(self):
+        self.events = []
+
+    def start(self, run):
+        self.events.append(("start", run.paths))
+
+    def check_file(self, path, parsed):
+        self.events.append(("file", path))
+        stack = [parsed.root]
+        while stack:
+            node = stack.pop()
+            if node.type == "call" and parsed.text(node.child_by_field_name("function")) == "print":
+                yield Finding.at(node, path, "no-print", "print() call")
+            stack.extend(node.children)
+
+    def check_package(self, package):
+        self.events.append(("package", package.name, [label for label, _ in package.files]))
+
+    def finish(self):
+        self.events.append(("finish",))
+        return [Finding("summary", "done", ".", 1, level="note")]
+
+
+def _project(tmp_path):
+    (tmp_path / "pkg").mkdir()
+    (tmp_path / "pkg" / "a.py").write_text("def f():\n    print('x')\n", encoding="utf-8")
+    (tmp_path / "pkg" / "sub").mkdir()
+    (tmp_path / "pkg" / "sub" / "b.py").write_text("def g():\n    return 1\n", encoding="utf-8")
+    (tmp_path / "pkg" / "c.py").write_text("print(1)\n", encoding="utf-8")
+    return tmp_path / "pkg"
+
+
+def test_lifecycle_order_and_findings(tmp_path):
+    root = _project(tmp_path)
+    analyzer = NoPrint()
+    report = run_analyzers([root], [analyzer])
+    assert report.checked == 3
+    assert [e[0] for e in analyzer.events] == ["start", "file", "file", "package", "file", "package", "finish"]
+    assert analyzer.events[3][2] == [f"{root.as_posix()}/a.py", f"{root.as_posix()}/c.py"]
+    found = [(f.rule, Path(f.path).name, f.line, f.analyzer) for f in report.findings]
+    assert found == [("no-print", "a.py", 2, "acme"), ("no-print", "c.py", 1, "acme"), ("summary", ".", 1, "acme")]
+    assert report.failed
+
+
+def test_language_filter_and_failing_hook(tmp_path):
+    root = _project(tmp_path)
+    (root / "notes.js").write_text("function f() { print(1); }\n", encoding="utf-8")
+
+    class Broken(Analyzer):
+        name = "broken"
+        languages = {"python"}
+
+        def check_file(self, path, parsed):
+            if path.endswith("c.py"):
+                raise KeyError("boom")
+            return [Finding("seen", parsed.language, path, 1, level="note")]
+
+    report = run_analyzers([root], [Broken()])
+    assert {f.message for f in report.findings} == {"python"}
+    assert len(report.errors) == 1 and report.errors[0].path.endswith("c.py")
+    assert not report.failed  # notes only
+
+
+def test_import_analyzer_from_search_path(tmp_path):
+    (tmp_path / "acme_checks.py").write_text(
+        "from treesitter_tools.plugins import Analyzer\n\n"
+        "class Checks(Analyzer):\n"
+        "    def __init__(self, strict=False):\n"
+        "        self.strict = strict\n",
+        encoding="utf-8",
+    )
+    analyzer = import_analyzer("acme_checks:Checks", {"strict": True}, tmp_path)
+    assert analyzer.name == "Checks" and analyzer.strict
+    with pytest.raises(PluginError):
+        import_analyzer("acme_checks:Missing", search_path=tmp_path)
+    with pytest.raises(PluginError):
+        import_analyzer("acme_checks:Checks", {"unknown": 1}, tmp_path)
+
+
+PLUGIN = textwrap.dedent(
+    """
+    import json, sys
+
+    for line in sys.stdin:
+        request = json.loads(line)
+        method, params = request["method"], request["params"]
+        result = {}
+        if method == "initialize":
+            result = {"name": "todo", "rules": {"todo": "TODO left in code"}, "tree": True}
+        elif method == "file":
+            assert params["tree"]["root"]["type"] == "module"
+            result = {"findings": [
+                {"rule": "todo", "message": "TODO", "line": i}
+                for i, text in enumerate(params["source"].splitlines(), 1) if "TODO" in text
+            ]}
+        elif method == "package":
+            result = {"findings": [{"rule": "files", "message": str(len(params["files"])), "path": params["name"],
+                                    "level": "note"}]}
+        elif method == "finish" and "--fail" in sys.argv:
+            print(json.dumps({"jsonrpc": "2.0", "id": request["id"], "error": {"code": 1, "message": "nope"}}))
+            sys.stdout.flush()
+            continue
+        print(json.dumps({"jsonrpc": "2.0", "id": request["id"], "result": result}))
+        sys.stdout.flush()
+        if method == "shutdown":
+            break
+    """
+)
+
+
+def test_subprocess_analyzer(tmp_path):
+    script = tmp_path / "todo_plugin.py"
+    script.write_text(PLUGIN, encoding="utf-8")
+    source = tmp_path / "src"
+    source.mkdir()
+    (source / "m.py").write_text("x = 1\n# TODO: remove\n", encoding="utf-8")
+    report = run_analyzers([source], [command_analyzer([sys.executable, str(script)])])
+    assert report.errors == []
+    assert [(f.analyzer, f.rule, f.line) for f in report.findings] == [("todo", "todo", 2), ("todo", "files", 1)]
+    sarif = json.loads(report.to_sarif(tmp_path))
+    rules = sarif["runs"][0]["tool"]["driver"]["rules"]
+    assert [r["id"] for r in rules] == ["todo/todo", "todo/files"]
+    assert rules[0]["shortDescription"]["text"] == "TODO left in code"
+
+    failing = run_analyzers([source], [command_analyzer([sys.executable, str(script), "--fail"])])
+    assert [(e.analyzer, e.message) for e in failing.errors] == [("todo", "finish failed: nope")]
+
+
+def test_missing_command_is_reported(tmp_path):
+    (tmp_path / "m.py").write_text("x = 1\n", encoding="utf-8")
+    report = run_analyzers([tmp_path], [command_analyzer("definitely-not-a-real-analyzer --x")])
+    assert len(report.errors) == 1 and "cannot start" in report.errors[0].message
+
+
+def test_config_analyzers(tmp_path):
+    config = parse_config(
+        {"analyzers": ["acme_checks:Checks", {"command": "./lint --json", "name": "lint", "options": {"x": 1}}]},
+        tmp_path / ".treesitter-tools.yaml",
+    )
+    assert [a.module for a in config.analyzers] == ["acme_checks:Checks", None]
+    assert config.analyzers[1].command == ["./lint", "--json"]
+    with pytest.raises(ConfigError) as info:
+        parse_config({"analyzers": [{"name": "x"}, {"module": "nocolon"}]}, tmp_path / "c.yaml")
+    assert len(info.value.problems) == 2
+
+
+def test_analyze_cli(tmp_path):
+    _project(tmp_path)
+    (tmp_path / "checks.py").write_text(
+        "from treesitter_tools.plugins import Analyzer, Finding\n\n"
+        "class Big(Analyzer):\n"
+        "    name = 'size'\n"
+        "    def check_file(self, path, parsed):\n"
+        "        return [Finding('lines', 'lines: %d' % parsed.source.count(b'\\n'), path, 1)]\n",
+        encoding="utf-8",
+    )
+    result = run_cli(["analyze", "pkg", "-a", "checks:Big", "--no-entry-points"], cwd=tmp_path)
+    assert result.returncode == 1
+    assert "pkg/a.py:1: warning: lines: 2 [size/lines]" in result.stdout
+    result = run_cli(
+        ["analyze", "pkg", "-a", "checks:Big", "--no-entry-points", "--format", "json", "--exit-zero"], cwd=tmp_path
+    )
+    assert result.returncode == 0
+    assert json.loads(result.stdout)["analyzers"] == ["size"]
+    result = run_cli(["analyze", "pkg", "--no-entry-points"], cwd=tmp_path)
+    assert result.returncode == 1 and "No analyzers configured" in result.stderr
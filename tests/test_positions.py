@@ -0,0 +1,90 @@
+"""Tests for byte offset / UTF-16 / code point / line-column conversions."""
+
+import json
+
+import pytest
+from conftest import run_cli
+
+from treesitter_tools.positions import LineIndex, Position, normalize_unit, offset_at, position_at, units
+
+# BOM, "a", U+1F600 (4 bytes, 2 UTF-16 units), "b", CRLF, "x", "é" (2 bytes), LF, "last"
+SOURCE = "﻿a\U0001F600b\r\nxé\nlast".encode("utf-8")
+
+
+def test_units():
+    assert units("\U0001F600".encode("utf-8"), "utf-16") == 2
+    assert units("\U0001F600".encode("utf-8"), "rune") == 1
+    assert units("\U0001F600".encode("utf-8"), "byte") == 4
+    assert units(b"a\xffb", "utf-16") == 3  # an invalid byte is one character
+    assert normalize_unit("UTF16") == "utf-16"
+    with pytest.raises(ValueError, match="Unsupported unit 'ucs2'"):
+        normalize_unit("ucs2")
+
+
+def test_lines_handle_crlf_and_bom():
+    index = LineIndex(SOURCE)
+    assert index.line_count == 3
+    assert [index.line_text(line) for line in range(3)] == [
+        "a\U0001F600b".encode("utf-8"), "xé".encode("utf-8"), b"last",
+    ]
+    assert index.line_start(0) == 3  # after the BOM
+    assert index.line_of(9) == 0 and index.line_of(11) == 1
+
+
+@pytest.mark.parametrize(
+    "offset, utf16, utf8, utf32",
+    [
+        (0, (0, 0), (0, 0), (0, 0)),  # inside the BOM
+        (3, (0, 0), (0, 0), (0, 0)),
+        (4, (0, 1), (0, 1), (0, 1)),
+        (6, (0, 1), (0, 1), (0, 1)),  # inside the emoji: snaps to its start
+        (8, (0, 3), (0, 5), (0, 2)),
+        (9, (0, 4), (0, 6), (0, 3)),
+        (10, (0, 4), (0, 6), (0, 3)),  # the \r of CRLF is the line break
+        (13, (1, 1), (1, 1), (1, 1)),  # inside "é"
+        (14, (1, 2), (1, 3), (1, 2)),
+        (99, (2, 4), (2, 4), (2, 4)),
+    ],
+)
+def test_position(offset, utf16, utf8, utf32):
+    index = LineIndex(SOURCE)
+    assert index.position(offset) == Position(*utf16)
+    assert index.position(offset, "utf-8") == Position(*utf8)
+    assert index.position(offset, "utf-32") == Position(*utf32)
+
+
+def test_offset_round_trips_and_clamps():
+    index = LineIndex(SOURCE)
+    for offset in (3, 4, 8, 9, 11, 12, 14, 16, 19):
+        for unit in ("utf-8", "utf-16", "utf-32"):
+            pos = index.position(offset, unit)
+            assert index.offset(pos.line, pos.character, unit) == offset
+    assert index.offset(0, 2) == 4  # between the surrogates: start of the emoji
+    assert index.offset(0, 99) == 9  # past the end of the line: before the CRLF
+    assert index.offset(1, 2, "byte") == 12  # inside "é"
+    assert index.offset(-1, 5) == 0 and index.offset(7, 0) == len(SOURCE)
+    assert index.convert(0, 3, "utf-16", "utf-32") == Position(0, 2)
+    assert offset_at(SOURCE, 2, 1) == 16 and position_at(SOURCE, 16) == Position(2, 1)
+
+
+def test_points_and_whole_text_offsets():
+    index = LineIndex(SOURCE)
+    assert index.point(8) == (0, 8)  # Tree-sitter columns include the BOM
+    assert index.point_offset(1, 2) == 13
+    text = SOURCE.decode("utf-8-sig")
+    assert index.from_byte(14, "utf-32") == text.index("\n", 5)
+    assert index.to_byte(text.index("last"), "rune") == 15
+    assert index.from_byte(9, "utf-16") == 4
+    assert index.to_byte(4, "utf-16") == 9
+
+
+def test_position_command(tmp_path):
+    path = tmp_path / "a.txt"
+    path.write_bytes(SOURCE)
+    result = run_cli(["position", str(path), "--line", "0", "--character", "3"])
+    assert result.returncode == 0, result.stderr
+    data = json.loads(result.stdout)
+    assert data["offset"] == 8
+    assert data["positions"]["utf-32"] == {"line": 0, "character": 2}
+    assert data["offsets"] == {"utf-8": 8, "utf-16": 3, "utf-32": 2}
+    assert run_cli(["position", str(path)]).returncode == 1
@@ -0,0 +1,85 @@
+"""Tests for the best-effort C/C++ mode that parses every preprocessor branch."""
+
+import json
+
+import pytest
+from conftest import run_cli
+
+from treesitter_tools import preproc
+from treesitter_tools.core import extract_symbols
+from treesitter_tools.preproc import conditional_tree
+
+SOURCE = """#ifndef POLL_H
+#define POLL_H
+
+#ifdef _WIN32
+int open_handle(HANDLE h) {
+#elif defined(__APPLE__)
+int open_handle(int fd, int flags) {
+#else
+int open_handle(int fd) {
+#endif
+    return 0;
+}
+
+/* #if NOT_A_DIRECTIVE */
+#if HAVE_EPOLL || HAVE_KQUEUE
+void poll_wait(void) {}
+#ifndef NO_TIMERS
+void poll_timer(void) {}
+#endif
+#endif
+
+int shared(void) { return 1; }
+
+#endif
+"""
+
+
+@pytest.fixture
+def preprocessor():
+    preproc.ENABLED = True
+    yield
+    preproc.ENABLED = False
+
+
+def test_conditional_tree():
+    (guard,) = conditional_tree(SOURCE.encode())
+    assert [b.condition for b in guard.branches] == [None]  # the include guard is no condition
+    heads, poll = guard.branches[0].children
+    assert [(b.condition, b.start_line, b.end_line) for b in heads.branches] == [
+        ("defined(_WIN32)", 5, 5),
+        ("!defined(_WIN32) && defined(__APPLE__)", 7, 7),
+        ("!defined(_WIN32) && !defined(__APPLE__)", 9, 9),
+    ]
+    assert poll.branches[0].condition == "HAVE_EPOLL || HAVE_KQUEUE"
+    assert poll.branches[0].children[0].branches[0].condition == "!defined(NO_TIMERS)"
+    # Unbalanced directives do not raise.
+    assert len(conditional_tree(b"#endif\n#if A\nint x;\n")) == 1
+
+
+def test_symbols_of_every_branch(tmp_path, preprocessor):
+    path = tmp_path / "poll.c"
+    path.write_text(SOURCE, encoding="utf-8")
+    symbols = [s for s in extract_symbols(path) if s.kind == "function"]
+    assert [(s.name, s.start_line, s.condition) for s in symbols] == [
+        ("open_handle", 5, "defined(_WIN32)"),
+        ("open_handle", 7, "!defined(_WIN32) && defined(__APPLE__)"),
+        ("open_handle", 9, "!defined(_WIN32) && !defined(__APPLE__)"),
+        ("poll_wait", 16, "HAVE_EPOLL || HAVE_KQUEUE"),
+        ("poll_timer", 18, "(HAVE_EPOLL || HAVE_KQUEUE) && !defined(NO_TIMERS)"),
+        ("shared", 22, None),
+    ]
+    # The content is the original text, blanked branches included.
+    assert "#elif defined(__APPLE__)" in symbols[0].content
+    assert symbols[0].to_dict()["condition"] == "defined(_WIN32)" and "condition" not in symbols[-1].to_dict()
+
+
+def test_cli_preprocessor_flag(tmp_path):
+    (tmp_path / "poll.c").write_text(SOURCE, encoding="utf-8")
+    plain = json.loads(run_cli(["symbols", "poll.c"], cwd=tmp_path).stdout)
+    assert all("condition" not in s for s in plain)
+    result = run_cli(["--preprocessor", "symbols", "poll.c"], cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    heads = [s for s in json.loads(result.stdout) if s["name"] == "open_handle"]
+    assert [s["condition"] for s in heads][-1] == "!defined(_WIN32) && !defined(__APPLE__)"
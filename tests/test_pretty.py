@@ -0,0 +1,60 @@
+"""Tests for pretty (highlighted, line-numbered) symbol output."""
+
+from conftest import run_cli
+
+from treesitter_tools.core import extract_symbols, parse_file
+from treesitter_tools.pretty import THEME, render_symbols
+
+SOURCE = '''\
+class Greeter:
+    def hello(self, name):
+        message = "hi " + name
+        return message
+
+    def bye(self):
+        pass
+'''
+
+
+def _render(tmp_path, source=SOURCE, **kwargs):
+    path = tmp_path / "greeter.py"
+    path.write_text(source, encoding="utf-8")
+    return render_symbols(extract_symbols(path), parse_file(path), "greeter.py", **kwargs)
+
+
+def test_plain_output_folds_nested_symbols(tmp_path):
+    text = _render(tmp_path, color=False)
+    assert "\x1b[" not in text
+    assert "class Greeter  greeter.py:1-7" in text
+    assert "1 │ class Greeter:" in text
+    assert "⋮ 2 lines (hello, shown below)" in text
+    assert "3 │         message = \"hi \" + name" in text  # in hello's own listing
+
+
+def test_highlighting_uses_the_highlights_query(tmp_path):
+    text = _render(tmp_path, color=True)
+    assert THEME["keyword"] + "def" in text
+    assert THEME["string"] + '"hi "' in text
+
+
+def test_long_bodies_collapse(tmp_path):
+    source = "def f():\n" + "    x = 1\n" * 30
+    text = _render(tmp_path, source, color=False, max_lines=5)
+    assert "⋮ 26 lines" in text
+    assert "31 │     x = 1" in text and "\n 5 │" not in text
+    assert "⋮" not in _render(tmp_path, source, color=False, max_lines=0)
+
+
+def test_cli_pretty_formats(tmp_path):
+    (tmp_path / "greeter.py").write_text(SOURCE, encoding="utf-8")
+    result = run_cli(["symbols", "greeter.py", "--format", "pretty"], cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    assert "function hello" in result.stdout and "\x1b[" not in result.stdout
+
+    result = run_cli(["scan", ".", "--format", "pretty", "--highlight"], cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    assert "── greeter.py" in result.stdout and "\x1b[" in result.stdout
+
+    result = run_cli(["symbols", "greeter.py", "--format", "yaml"], cwd=tmp_path)
+    assert result.returncode == 1
+    assert "Unsupported format" in result.stderr
@@ -0,0 +1,69 @@
+"""Tests for the bundled query library and --query-dir overrides."""
+
+import json
+
+import pytest
+from conftest import run_cli
+
+from treesitter_tools import api, querylib
+from treesitter_tools.core import load_language, run_query
+from treesitter_tools.querylib import QUERY_KINDS, add_query_dir, compile_query, find_query, list_queries, load_query
+
+LANGUAGES = ("python", "javascript", "typescript", "tsx", "rust", "go")
+
+
+@pytest.fixture(autouse=True)
+def _no_user_dirs(monkeypatch):
+    monkeypatch.setattr(querylib, "QUERY_DIRS", [])
+
+
+@pytest.mark.parametrize("language", LANGUAGES)
+def test_curated_queries_compile(language):
+    load_language(language)
+    names = {q.name for q in list_queries(language)}
+    assert set(QUERY_KINDS) <= names
+    for kind in QUERY_KINDS:
+        compile_query(language, kind)
+
+
+def test_highlights_and_tags_on_python(tmp_path):
+    f = tmp_path / "m.py"
+    f.write_text("class Greeter:\n    def hello(self, name):\n        return print(name)\n", encoding="utf-8")
+    tags = run_query(f, load_query("python", "tags"))
+    names = {c["text"] for m in tags for c in m["captures"] if c["name"] == "name"}
+    assert {"Greeter", "hello", "print"} <= names
+    highlights = run_query(f, load_query("python", "highlights"))
+    captured = {(c["name"], c["text"]) for m in highlights for c in m["captures"]}
+    assert ("keyword", "def") in captured
+    assert ("function", "hello") in captured
+
+
+def test_user_dir_overrides_and_adds(tmp_path):
+    user = tmp_path / "queries"
+    (user / "python").mkdir(parents=True)
+    (user / "python" / "highlights.scm").write_text("(comment) @comment\n", encoding="utf-8")
+    (user / "python" / "todos.scm").write_text("(comment) @todo\n", encoding="utf-8")
+    add_query_dir(user)
+    assert find_query("python", "highlights").origin == "user"
+    assert load_query("python", "highlights") == "(comment) @comment\n"
+    by_name = {q.name: q.origin for q in api.list_queries("python")}
+    assert by_name["todos"] == "user" and by_name["folds"] == "bundled"
+    assert api.load_query("python", "todos") == "(comment) @todo\n"
+    with pytest.raises(ValueError, match="No 'missing' query for python"):
+        load_query("python", "missing")
+    with pytest.raises(ValueError, match="not found"):
+        add_query_dir(tmp_path / "nope")
+
+
+def test_queries_cli(tmp_path):
+    user = tmp_path / "queries"
+    (user / "python").mkdir(parents=True)
+    (user / "python" / "folds.scm").write_text("(no_such_node) @fold\n", encoding="utf-8")
+    result = run_cli(["queries", "python", "--format", "json"])
+    assert result.returncode == 0
+    assert {row["name"] for row in json.loads(result.stdout)} >= set(QUERY_KINDS)
+    result = run_cli(["--query-dir", str(user), "queries", "python", "--check"])
+    assert result.returncode == 1
+    assert "folds\tuser" in result.stdout and "ERROR" in result.stdout
+    result = run_cli(["queries", "rust", "--show", "folds"])
+    assert result.returncode == 0 and "@fold" in result.stdout
@@ -0,0 +1,81 @@
+"""Tests for the binary (protobuf) record stream."""
+
+import gzip
+import io
+import json
+from pathlib import Path
+
+import pytest
+from conftest import run_cli
+
+from treesitter_tools.chunker import Chunk
+from treesitter_tools.core import CodeSymbol, FileSymbols
+from treesitter_tools.export import records
+from treesitter_tools.export.protowire import bytes_field, delimited, int_field
+
+
+def _report():
+    symbols = [
+        CodeSymbol("function", "f", 1, 3, "def f():", None, content="", doc="Doc.", body_hash="ab12"),
+        CodeSymbol("function", "g", 5, 40, None, None, chunk_index=0, chunk_count=2, parent_symbol="g", overflow=True),
+        CodeSymbol("class", "Été", 42, 50, None, None, change="added"),
+    ]
+    return FileSymbols(Path("src/app.py"), "python", symbols, vendored=True)
+
+
+def test_round_trip_keeps_every_field():
+    report = _report()
+    chunk = Chunk("src/app.py", "python", "function", "f", 1, 3, "def f(): pass", "import os", 5, 2, 0, 2)
+    chunk.parent_id, chunk.next_id, chunk.file_id = "c1", "c3", "c0"
+    buffer = io.BytesIO()
+    writer = records.RecordWriter(buffer)
+    writer.write_file(report)
+    writer.write_file(FileSymbols(Path("bad.py"), "unknown", [], error="boom"))
+    writer.write_chunk(chunk)
+    buffer.seek(0)
+    decoded = list(records.iter_records(buffer))
+    assert decoded[0].to_dict() == report.to_dict()
+    assert decoded[0].symbols[0].content == ""  # present-but-empty is not null
+    assert decoded[0].symbols[1].signature is None
+    assert decoded[1].error == "boom"
+    assert decoded[2] == chunk
+    assert writer.count == 3
+
+
+def test_gzip_files_and_bad_streams(tmp_path):
+    path = tmp_path / "symbols.pb.gz"
+    path.write_bytes(gzip.compress(records.encode_files([_report()])))
+    [report] = records.read_records(path)
+    assert report.symbols[2].name == "Été"
+
+    with pytest.raises(ValueError, match="does not start with a header"):
+        list(records.iter_records(io.BytesIO(b"")))
+    newer = delimited(bytes_field(records.HEADER_FIELD, int_field(1, records.FORMAT_VERSION + 1)))
+    with pytest.raises(ValueError, match="Unsupported record format version"):
+        list(records.iter_records(io.BytesIO(newer)))
+    truncated = records.encode_files([_report()])[:-5]
+    with pytest.raises(ValueError, match="Truncated"):
+        list(records.iter_records(io.BytesIO(truncated)))
+
+
+def test_schema_ships_with_the_package():
+    schema = records.SCHEMA_PATH.read_text(encoding="utf-8")
+    assert "package treesitter_tools.records.v1;" in schema and "message Record" in schema
+
+
+def test_cli_scan_and_chunk_proto_decode_to_json(tmp_path):
+    (tmp_path / "app.py").write_text("def f():\n    return 1\n\nclass A:\n    pass\n", encoding="utf-8")
+    ndjson = run_cli(["scan", ".", "--format", "ndjson"], cwd=tmp_path)
+    assert ndjson.returncode == 0, ndjson.stderr
+    result = run_cli(["scan", ".", "--format", "proto", "--output", "out.pb"], cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    decoded = run_cli(["decode", "out.pb"], cwd=tmp_path)
+    assert decoded.returncode == 0, decoded.stderr
+    assert [json.loads(line) for line in decoded.stdout.splitlines()] == [
+        json.loads(line) for line in ndjson.stdout.splitlines()
+    ]
+
+    piped = run_cli(["chunk", "app.py", "--format", "proto"], cwd=tmp_path, text=False)
+    assert piped.returncode == 0, piped.stderr
+    chunks = list(records.iter_records(io.BytesIO(piped.stdout)))
+    assert [c.name for c in chunks] == [c["name"] for c in json.loads(run_cli(["chunk", "app.py"], cwd=tmp_path).stdout)]
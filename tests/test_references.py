@@ -0,0 +1,104 @@
+"""Tests for references classified as reads, writes, and calls."""
+
+import json
+
+import pytest
+from conftest import run_cli
+
+from treesitter_tools.core import parse_file
+from treesitter_tools.references import file_references, iter_references
+from treesitter_tools.resolver import resolve_at
+
+PYTHON = '''class Counter:
+    def __init__(self):
+        self.count = 0
+        self.items = {}
+
+    def bump(self, count=1):
+        self.count += count
+        self.items[count] = self.count
+        return self.report(self.count)
+
+    def report(self, value):
+        del self.items[value]
+        return value
+'''
+
+GO = '''package main
+
+type Counter struct {
+	count int
+}
+
+func (c *Counter) Inc() int {
+	c.count++
+	total, count := c.count, 1
+	return total + count
+}
+'''
+
+JS = '''class Cart {
+  add(item) {
+    this.total = this.total + item.price;
+    this.items.push(item);
+    count++;
+  }
+}
+'''
+
+
+def _accesses(tmp_path, name, source, names, **kwargs):
+    path = tmp_path / name
+    path.write_text(source, encoding="utf-8")
+    return [(r.line, r.access, r.member) for r in file_references(parse_file(path), name, names, **kwargs)]
+
+
+def test_python_accesses(tmp_path):
+    refs = _accesses(tmp_path, "counter.py", PYTHON, {"count"})
+    # `count=1` declares the parameter; `self.count` and the plain `count` are both found
+    assert refs == [
+        (3, "write", True), (7, "write", True), (7, "read", False), (8, "read", False), (8, "read", True),
+        (9, "read", True),
+    ]
+    assert _accesses(tmp_path, "counter.py", PYTHON, {"items"}) == [(4, "write", True), (8, "write", True),
+                                                                      (12, "write", True)]
+    assert _accesses(tmp_path, "counter.py", PYTHON, {"report"}) == [(9, "call", True)]
+    parsed = parse_file(tmp_path / "counter.py")
+    writes = file_references(parsed, "counter.py", {"count"}, access={"write"}, member=True)
+    assert [r.function for r in writes] == ["Counter.__init__", "Counter.bump"]
+    assert writes[1].code == "self.count += count"
+
+
+def test_go_and_js_accesses(tmp_path):
+    # The struct field declaration is not a reference; `:=` writes.
+    assert _accesses(tmp_path, "counter.go", GO, {"count"}) == [
+        (8, "write", True), (9, "write", False), (9, "read", True), (10, "read", False),
+    ]
+    assert _accesses(tmp_path, "cart.js", JS, {"total", "push", "count"}) == [
+        (3, "write", True), (3, "read", True), (4, "call", True), (5, "write", False),
+    ]
+
+
+def test_resolve_references_carry_access(tmp_path):
+    path = tmp_path / "app.js"
+    path.write_text("function run() {\n  let n = 0;\n  n += 1;\n  log(n);\n}\n", encoding="utf-8")
+    result = resolve_at(path, 2, 7, references=True)
+    assert [(r.line, r.access) for r in result.references] == [(3, "write"), (4, "read")]
+    assert result.to_dict()["references"][0]["access"] == "write"
+
+
+def test_cli_references(tmp_path):
+    (tmp_path / "pkg").mkdir()
+    (tmp_path / "pkg" / "counter.py").write_text(PYTHON, encoding="utf-8")
+    result = run_cli(["references", "count", ".", "--access", "write", "--member"], cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    assert result.stdout.splitlines() == [
+        "pkg/counter.py:3:14 [Counter.__init__] write self.count = 0",
+        "pkg/counter.py:7:14 [Counter.bump] write self.count += count",
+    ]
+    data = json.loads(run_cli(["references", "report", "--format", "json"], cwd=tmp_path).stdout)
+    assert [(r["access"], r["function"]) for r in data] == [("call", "Counter.bump")]
+    bad = run_cli(["references", "count", "--access", "mutate"], cwd=tmp_path)
+    assert bad.returncode == 1 and "Unknown access 'mutate'" in bad.stderr
+    with pytest.raises(ValueError, match="expected read, write, call"):
+        list(iter_references(tmp_path, ["count"], access=["mutate"]))
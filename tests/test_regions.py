@@ -0,0 +1,141 @@
+"""Tests for extracting the declarations around a line range or position."""
+
+import json
+from pathlib import Path
+
+import pytest
+from conftest import run_cli
+
+from treesitter_tools.core import CodeSymbol
+from treesitter_tools.regions import (
+    LineAttribution,
+    SymbolMap,
+    enclosing_symbols,
+    extract_regions,
+    group_attributions,
+    parse_position,
+    parse_range,
+)
+
+SOURCE = '''import os
+
+
+class Cache:
+    """Keeps values."""
+
+    def get(self, key):
+        value = self.data.get(key)
+        return value
+
+    # Remove a key.
+    def drop(self, key):
+        self.data.pop(key)
+
+
+def helper():
+    return os.getcwd()
+'''
+
+
+def _symbol(name, start, end, kind="function"):
+    return CodeSymbol(kind, name, start, end, None, None)
+
+
+def test_parse_targets():
+    assert parse_range("svc/handler.go:120-180").end_line == 180
+    hunk = parse_range("a.py:10,5")
+    assert (hunk.start_line, hunk.end_line, hunk.label) == (10, 14, "a.py:10-14")
+    at = parse_position("C:/src/app.go:133:7")
+    assert (at.path, at.start_line, at.column, at.label) == (Path("C:/src/app.go"), 133, 7, "C:/src/app.go:133:7")
+    assert parse_position("app.py:12").column is None
+    with pytest.raises(ValueError, match="START <= END"):
+        parse_range("a.py:20-10")
+    with pytest.raises(ValueError, match="Expected PATH:START-END"):
+        parse_range("a.py")
+
+
+def test_enclosing_symbols():
+    cls, get, drop, helper = (_symbol("Cache", 4, 13, "class"), _symbol("get", 7, 9), _symbol("drop", 11, 13),
+                              _symbol("helper", 16, 17))
+    symbols = [cls, get, drop, helper]
+    assert enclosing_symbols(symbols, 8, 8) == [get]
+    assert enclosing_symbols(symbols, 8, 12) == [cls]  # both methods: the class encloses the range
+    assert enclosing_symbols(symbols, 12, 17) == [drop, helper]  # across top-level declarations
+    assert enclosing_symbols(symbols, 1, 2) == []
+
+
+def test_extract_regions(tmp_path):
+    path = tmp_path / "cache.py"
+    path.write_text(SOURCE, encoding="utf-8")
+    (region,) = extract_regions(parse_position(f"{path}:8"))
+    assert (region["qualified_name"], region["start_line"], region["end_line"]) == ("Cache.get", 7, 9)
+    assert region["source"].startswith("    def get(self, key):")
+
+    (drop,) = extract_regions(parse_range(f"{path}:13-13"), context=1, doc=True, enclosing=True)
+    assert drop["source_start_line"] == 10 and drop["source"].splitlines()[1] == "    # Remove a key."
+    assert drop["enclosing"]["source"] == "class Cache:\n"
+
+    assert extract_regions(parse_position(f"{path}:1")) == []
+
+    # A column picks between declarations sharing a line.
+    script = tmp_path / "min.js"
+    script.write_text("function a() { return 1; } function b() { return 2; }\n", encoding="utf-8")
+    assert [r["name"] for r in extract_regions(parse_position(f"{script}:1:5"))] == ["a"]
+    assert [r["name"] for r in extract_regions(parse_position(f"{script}:1:40"))] == ["b"]
+
+
+def test_cli_extract(tmp_path):
+    (tmp_path / "cache.py").write_text(SOURCE, encoding="utf-8")
+    result = run_cli(["extract", "--range", "cache.py:12-17", "--at", "cache.py:9:9", "--format", "json"], cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    found = json.loads(result.stdout)
+    assert [entry["target"] for entry in found] == ["cache.py:12-17", "cache.py:9:9"]
+    assert [r["qualified_name"] for r in found[0]["regions"]] == ["Cache.drop", "helper"]
+
+    text = run_cli(["extract", "--at", "cache.py:17", "--enclosing"], cwd=tmp_path)
+    assert text.stdout == "def helper():\n    return os.getcwd()\n"
+
+    missing = run_cli(["extract", "--at", "cache.py:1"], cwd=tmp_path)
+    assert missing.returncode == 1 and "No declaration encloses cache.py:1" in missing.stderr
+
+
+def test_symbol_map(tmp_path):
+    path = tmp_path / "cache.py"
+    path.write_text(SOURCE, encoding="utf-8")
+    symbols = SymbolMap.load(path, label="cache.py")
+    assert symbols.symbol_at(8).qualified_name == "Cache.get"
+    assert symbols.symbol_at(5).qualified_name == "Cache" and symbols.symbol_at(1) is None
+    get, docstring, imports, gone = symbols.attribute_lines([8, 5, 1, 500])
+    assert get.to_dict()["symbol"]["id"] == symbols.symbol_at(7).id
+    assert (get.to_dict()["function"], get.to_dict()["type"]) == ("Cache.get", "Cache")
+    assert (docstring.function, docstring.type.name) == (None, "Cache")
+    assert imports.symbol is None and gone.symbol is None
+    with pytest.raises(ValueError, match="1-based"):
+        symbols.symbol_at(0)
+
+
+def test_group_attributions():
+    get, helper = _symbol("get", 7, 9), _symbol("helper", 16, 17)
+    get.qualified_name, helper.qualified_name = "Cache.get", "helper"
+    groups = group_attributions([
+        LineAttribution("a.py", line, symbol, symbol, None)
+        for line, symbol in [(17, helper), (8, get), (9, get), (8, get), (1, None)]
+    ])
+    assert [(g["function"], g["count"], g["lines"]) for g in groups] == [
+        ("Cache.get", 3, [8, 9]), (None, 1, [1]), ("helper", 1, [17]),
+    ]
+
+
+def test_cli_attribute(tmp_path):
+    (tmp_path / "cache.py").write_text(SOURCE, encoding="utf-8")
+    result = run_cli(["attribute", "cache.py:8:3", "cache.py:1", "cache.py:17"], cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    assert result.stdout.splitlines() == ["cache.py:8\tCache.get", "cache.py:1\t-", "cache.py:17\thelper"]
+
+    stdin = "cache.py:8\ncache.py:9\nmissing.py:3\n"
+    grouped = run_cli(["attribute", "--stdin", "--group", "--format", "json"], cwd=tmp_path, stdin=stdin)
+    assert grouped.returncode == 0, grouped.stderr
+    assert "No such file: missing.py" in grouped.stderr
+    first, second = json.loads(grouped.stdout)
+    assert (first["symbol"]["qualified_name"], first["count"], first["lines"]) == ("Cache.get", 2, [8, 9])
+    assert (second["path"], second["symbol"]) == ("missing.py", None)
@@ -0,0 +1,193 @@
+"""Tests for scope-aware rename."""
+
+import pytest
+
+from treesitter_tools.rename import parse_location, rename_symbol
+from treesitter_tools.resolver import FileScopes
+from treesitter_tools.core import parse_file
+
+PY_MODULE = """\
+total = 0
+
+
+def add(total, n):
+    return total + n
+
+
+def bump(n):
+    global total
+    total = add(total, n)
+    return total
+
+
+def shadowed():
+    total = 5
+    return [total for total in range(total)]
+"""
+
+
+def test_python_rename_respects_shadowing(tmp_path):
+    path = tmp_path / "counter.py"
+    path.write_text(PY_MODULE, encoding="utf-8")
+    result = rename_symbol(path, 1, 1, "count")
+    rewritten = result.files[0].rewritten.decode()
+    assert rewritten.startswith("count = 0\n")
+    assert "def add(total, n):\n    return total + n" in rewritten
+    assert "    global count\n    count = add(count, n)\n    return count" in rewritten
+    assert "    total = 5\n    return [total for total in range(total)]" in rewritten
+    assert result.occurrences == 5
+
+
+def test_python_local_rename_from_reference(tmp_path):
+    path = tmp_path / "counter.py"
+    path.write_text(PY_MODULE, encoding="utf-8")
+    # Pointing at a use of the parameter renames that parameter only.
+    result = rename_symbol(path, 5, 12, "acc")
+    rewritten = result.files[0].rewritten.decode()
+    assert "def add(acc, n):\n    return acc + n" in rewritten
+    assert rewritten.startswith("total = 0\n")
+
+
+def test_python_rename_follows_same_directory_imports(tmp_path):
+    (tmp_path / "util.py").write_text("def helper():\n    return 1\n", encoding="utf-8")
+    (tmp_path / "main.py").write_text(
+        "from .util import helper\nfrom . import util\nimport util as u\n\n"
+        "print(helper(), util.helper(), u.helper())\n",
+        encoding="utf-8",
+    )
+    (tmp_path / "alias.py").write_text("from util import helper as h\n\nh()\n", encoding="utf-8")
+    result = rename_symbol(tmp_path / "util.py", 1, None, "assist")
+    files = {f.path.name: f.rewritten.decode() for f in result.files}
+    assert files["util.py"].startswith("def assist():")
+    assert files["main.py"] == (
+        "from .util import assist\nfrom . import util\nimport util as u\n\n"
+        "print(assist(), util.assist(), u.assist())\n"
+    )
+    assert files["alias.py"] == "from util import assist as h\n\nh()\n"
+
+
+def test_renaming_an_import_locally_adds_an_alias(tmp_path):
+    path = tmp_path / "job.py"
+    path.write_text("from os import path\n\nprint(path.join('a'))\n", encoding="utf-8")
+    result = rename_symbol(path, 3, 7, "osp")
+    assert result.files[0].rewritten.decode() == "from os import path as osp\n\nprint(osp.join('a'))\n"
+
+
+@pytest.mark.parametrize(
+    "new_name, message",
+    [
+        ("add", "already declared"),
+        ("print", "would be captured"),
+        ("class", "reserved word"),
+        ("9lives", "not a valid"),
+    ],
+)
+def test_conflicts_are_refused(tmp_path, new_name, message):
+    path = tmp_path / "counter.py"
+    path.write_text(PY_MODULE + "\nprint(total)\n", encoding="utf-8")
+    with pytest.raises(ValueError, match=message):
+        rename_symbol(path, 1, 1, new_name)
+
+
+def test_inner_declaration_would_shadow(tmp_path):
+    path = tmp_path / "m.py"
+    path.write_text("x = 1\n\n\ndef f():\n    y = 2\n    return x + y\n", encoding="utf-8")
+    with pytest.raises(ValueError, match="would shadow"):
+        rename_symbol(path, 1, 1, "y")
+
+
+def test_members_are_refused(tmp_path):
+    path = tmp_path / "m.py"
+    path.write_text("class A:\n    def run(self):\n        return 1\n", encoding="utf-8")
+    with pytest.raises(ValueError, match="class member"):
+        rename_symbol(path, 2, 9, "go")
+
+
+GO_A = """\
+package shapes
+
+func Area(w, h int) int {
+	return w * h
+}
+"""
+
+GO_B = """\
+package shapes
+
+func Square(n int) int {
+	Area := 0
+	_ = Area
+	return n
+}
+
+func Double(n int) int {
+	return 2 * Area(n, n)
+}
+"""
+
+
+def test_go_rename_spans_the_package_directory(tmp_path):
+    (tmp_path / "area.go").write_text(GO_A, encoding="utf-8")
+    (tmp_path / "square.go").write_text(GO_B, encoding="utf-8")
+    (tmp_path / "other_test.go").write_text("package shapes_test\n\nvar Area = 1\n", encoding="utf-8")
+    result = rename_symbol(tmp_path / "area.go", 3, 6, "Surface")
+    files = {f.path.name: f.rewritten.decode() for f in result.files}
+    assert set(files) == {"area.go", "square.go"}
+    assert "func Surface(w, h int) int" in files["area.go"]
+    # The local `Area` in Square shadows the function and is left alone.
+    assert "\tArea := 0\n\t_ = Area\n" in files["square.go"]
+    assert "return 2 * Surface(n, n)" in files["square.go"]
+
+
+def test_go_block_scoping(tmp_path):
+    path = tmp_path / "loop.go"
+    path.write_text(
+        "package main\n\nfunc f() int {\n\tx := 1\n\tif true {\n\t\tx := 2\n\t\t_ = x\n\t}\n\treturn x\n}\n",
+        encoding="utf-8",
+    )
+    result = rename_symbol(path, 4, 2, "y")
+    assert result.files[0].rewritten.decode() == (
+        "package main\n\nfunc f() int {\n\ty := 1\n\tif true {\n\t\tx := 2\n\t\t_ = x\n\t}\n\treturn y\n}\n"
+    )
+
+
+def test_javascript_shorthand_and_imports(tmp_path):
+    (tmp_path / "config.js").write_text(
+        "export const port = 80;\nexport function make() {\n  return { port };\n}\n", encoding="utf-8"
+    )
+    (tmp_path / "app.js").write_text(
+        "import { port } from './config';\nfunction run(port) {\n  return port;\n}\nrun(port);\n", encoding="utf-8"
+    )
+    result = rename_symbol(tmp_path / "config.js", 1, 14, "listenPort")
+    files = {f.path.name: f.rewritten.decode() for f in result.files}
+    assert files["config.js"] == (
+        "export const listenPort = 80;\nexport function make() {\n  return { port: listenPort };\n}\n"
+    )
+    assert files["app.js"] == (
+        "import { listenPort } from './config';\nfunction run(port) {\n  return port;\n}\nrun(listenPort);\n"
+    )
+
+
+def test_rust_let_shadowing_is_sequential(tmp_path):
+    path = tmp_path / "main.rs"
+    path.write_text("fn main() {\n    let v = 1;\n    let v = v + 1;\n    println!(\"{}\", v);\n}\n", encoding="utf-8")
+    result = rename_symbol(path, 2, 9, "first")
+    assert result.files[0].rewritten.decode() == (
+        "fn main() {\n    let first = 1;\n    let v = first + 1;\n    println!(\"{}\", v);\n}\n"
+    )
+
+
+def test_scopes_resolve_python_class_bodies(tmp_path):
+    path = tmp_path / "m.py"
+    path.write_text("y = 1\n\n\nclass A:\n    y = 2\n\n    def m(self):\n        return y\n", encoding="utf-8")
+    scopes = FileScopes(parse_file(path))
+    use = scopes.occurrence_at(8, 16)
+    # Class-level names are not visible inside methods.
+    assert use.binding.scope is scopes.root
+
+
+def test_parse_location():
+    assert parse_location("src/a.py:3:7") == (parse_location("src/a.py:3")[0], 3, 7)
+    assert parse_location("src/a.py:3")[2] is None
+    with pytest.raises(ValueError):
+        parse_location("src/a.py")
@@ -0,0 +1,71 @@
+"""Tests for the interactive shell."""
+
+import io
+
+from conftest import run_cli
+
+from treesitter_tools.repl import Repl, _balanced
+
+SOURCE = "def greet(name):\n    return 'hi ' + name\n\n\nclass Box:\n    pass\n"
+
+
+def _session(script):
+    stdout = io.StringIO()
+    shell = Repl(io.StringIO(script), stdout)
+    shell.prompt = shell.continuation_prompt = ""
+    shell.cmdloop()
+    return stdout.getvalue()
+
+
+def test_balanced():
+    assert _balanced("(function_definition name: (identifier) @n)")
+    assert not _balanced("(function_definition\n  name: (identifier")
+    assert _balanced('((string) @s (#eq? @s ")"))')
+    assert _balanced("(a) ; unmatched ( in a comment")
+
+
+def test_load_query_and_navigate(tmp_path):
+    path = tmp_path / "app.py"
+    path.write_text(SOURCE, encoding="utf-8")
+    out = _session(
+        f"load {path}\n"
+        "query (function_definition\n  name: (identifier) @name)\n"
+        "at 2:20\n"
+        "parent\n"
+        "tree 1\n"
+        "child\n"
+        "symbols\n"
+        "quit\n"
+    )
+    assert "(python, 63 bytes, 6 lines)" in out
+    assert "@name" in out and "greet" in out and "1 match\n" in out
+    assert "identifier (field: right)  2:20-2:24" in out
+    assert "in: module > function_definition > block > return_statement > binary_operator" in out
+    assert "binary_operator  2:12-2:24" in out and "(binary_operator [1, 11] - [1, 23]" in out
+    assert "0: string  'hi '" in out
+    assert "function greet  1-2" in out and "class Box  5-6" in out
+
+
+def test_snippets_and_errors():
+    out = _session(
+        "query (identifier) @x\n"
+        "parse python\n"
+        "x = (1,\n"
+        ".\n"
+        "errors\n"
+        "query (nonexistent_node) @x\n"
+        "frobnicate\n"
+        "EOF\n"
+    )
+    assert "Error: Nothing loaded" in out
+    assert "Loaded <snippet> (python" in out and "has syntax errors" in out
+    assert "MISSING" in out or "ERROR at" in out
+    assert out.count("Error:") == 3 and "Unknown command 'frobnicate'" in out
+
+
+def test_cli_repl_reads_commands_from_stdin(tmp_path):
+    (tmp_path / "app.py").write_text(SOURCE, encoding="utf-8")
+    result = run_cli(["repl", "app.py", "--no-history"], cwd=tmp_path, stdin="at 5:7\ntext\n")
+    assert result.returncode == 0, result.stderr
+    assert "identifier (field: name)  5:7-5:10" in result.stdout
+    assert result.stdout.rstrip().endswith("Box")
@@ -0,0 +1,66 @@
+"""Tests for --deterministic runs: fixed clock, zeroed measurements, fixed string hashing."""
+
+import json
+import os
+import subprocess
+import sys
+from pathlib import Path
+
+import pytest
+from conftest import run_cli
+
+from treesitter_tools import reproducible
+
+SRC_DIR = Path(__file__).parent.parent / "src"
+
+
+def _env(**extra):
+    env = {k: v for k, v in os.environ.items() if k not in ("PYTHONHASHSEED", "SOURCE_DATE_EPOCH")}
+    env["PYTHONPATH"] = str(SRC_DIR) + os.pathsep + env.get("PYTHONPATH", "")
+    env.update(extra)
+    return env
+
+
+def test_clock(monkeypatch):
+    monkeypatch.delenv("SOURCE_DATE_EPOCH", raising=False)
+    assert reproducible.now() > 1_600_000_000 and reproducible.perf_counter() > 0
+    monkeypatch.setattr(reproducible, "ENABLED", True)
+    assert (reproducible.now(), reproducible.time_ns(), reproducible.perf_counter()) == (0.0, 0, 0.0)
+    assert reproducible.measured(123) is None
+    monkeypatch.setenv("SOURCE_DATE_EPOCH", "1700000000")
+    assert reproducible.now() == 1_700_000_000.0 and reproducible.time_ns() == 1_700_000_000 * 10**9
+    monkeypatch.setenv("SOURCE_DATE_EPOCH", "yesterday")
+    with pytest.raises(ValueError, match="SOURCE_DATE_EPOCH"):
+        reproducible.now()
+
+
+def test_requested(monkeypatch):
+    monkeypatch.delenv("TREESITTER_TOOLS_DETERMINISTIC", raising=False)
+    assert reproducible.requested(["--deterministic", "scan", "."])
+    assert not reproducible.requested(["scan", "."])
+    monkeypatch.setenv("TREESITTER_TOOLS_DETERMINISTIC", "1")
+    assert reproducible.requested(["scan", "."])
+
+
+def test_pin_hash_seed():
+    code = (
+        "import sys; from treesitter_tools import reproducible; "
+        "reproducible.pin_hash_seed(sys.orig_argv[1:]); print(hash('treesitter'), sys.flags.hash_randomization)"
+    )
+    runs = {subprocess.run([sys.executable, "-c", code], capture_output=True, text=True, env=_env()).stdout
+            for _ in range(3)}
+    assert len(runs) == 1 and runs.pop().split()[1] == "0"
+
+
+def test_cli_deterministic(tmp_path, monkeypatch):
+    monkeypatch.delenv("SOURCE_DATE_EPOCH", raising=False)
+    (tmp_path / "app.py").write_text("def main():\n    return 1\n", encoding="utf-8")
+    result = run_cli(["--deterministic", "bench", ".", "--format", "json"], cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    (python,) = json.loads(result.stdout)["languages"]
+    assert (python["files"], python["parse_seconds"], python["parse_mb_per_s"], python["peak_rss"]) == (1, 0, 0, None)
+    again = run_cli(["bench", ".", "--format", "json"], cwd=tmp_path, env={"TREESITTER_TOOLS_DETERMINISTIC": "1"})
+    assert again.stdout == result.stdout
+
+    bad = run_cli(["--deterministic", "symbols", "app.py"], cwd=tmp_path, env={"SOURCE_DATE_EPOCH": "soon"})
+    assert bad.returncode == 1 and "SOURCE_DATE_EPOCH" in bad.stderr
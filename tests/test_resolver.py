@@ -0,0 +1,117 @@
+"""Tests for scope and binding resolution."""
+
+import json
+
+import pytest
+
+from treesitter_tools.resolver import Resolver, python_module_file, resolve_at
+
+PY_MODULE = """\
+LIMIT = 10
+
+
+def clamp(value, limit=LIMIT):
+    scaled = value * 2
+    return min(scaled, limit)
+
+
+class Box:
+    size = 3
+
+    def grow(self):
+        return self.size + LIMIT
+"""
+
+
+def test_python_parameter_local_and_package(tmp_path):
+    path = tmp_path / "calc.py"
+    path.write_text(PY_MODULE, encoding="utf-8")
+    parameter = resolve_at(path, 6, 24)
+    assert (parameter.name, parameter.kind) == ("limit", "parameter")
+    assert (parameter.definition.line, parameter.definition.column) == (4, 18)
+    local = resolve_at(path, 6, 16)
+    assert (local.kind, local.definition.line) == ("local", 5)
+    package = resolve_at(path, 4, 24)
+    assert (package.name, package.kind, package.definition.line) == ("LIMIT", "package", 1)
+    # Python class bodies are not visible from methods: LIMIT is the module constant.
+    assert resolve_at(path, 13, 28).definition.line == 1
+
+
+def test_builtins_are_unresolved(tmp_path):
+    path = tmp_path / "calc.py"
+    path.write_text(PY_MODULE, encoding="utf-8")
+    result = resolve_at(path, 6, 12)
+    assert (result.name, result.kind, result.definition) == ("min", "unresolved", None)
+    assert "unresolved" in result.to_text()
+
+
+def test_references_are_listed(tmp_path):
+    path = tmp_path / "calc.py"
+    path.write_text(PY_MODULE, encoding="utf-8")
+    result = resolve_at(path, 1, None, references=True)
+    assert [(r.line, r.column) for r in result.references] == [(4, 24), (13, 28)]
+
+
+def test_python_import_is_followed_to_its_definition(tmp_path):
+    package = tmp_path / "pkg"
+    package.mkdir()
+    (package / "__init__.py").write_text("from .impl import helper\n", encoding="utf-8")
+    (package / "impl.py").write_text("def helper():\n    return 1\n", encoding="utf-8")
+    main = tmp_path / "main.py"
+    main.write_text("from pkg import helper as h\nimport pkg.impl as impl\n\nh()\nimpl.helper()\n", encoding="utf-8")
+    result = resolve_at(main, 4, 1)
+    assert result.kind == "import"
+    assert result.definition.path == package / "impl.py"
+    assert (result.definition.line, result.definition.column) == (1, 5)
+    assert (result.imported_at.path, result.imported_at.line) == (main, 1)
+    module = resolve_at(main, 5, 1)
+    assert (module.kind, module.definition.path) == ("module", package / "impl.py")
+
+
+def test_python_module_file(tmp_path):
+    package = tmp_path / "pkg"
+    (package / "sub").mkdir(parents=True)
+    for name in ("__init__.py", "a.py", "sub/__init__.py", "sub/b.py"):
+        (package / name).write_text("", encoding="utf-8")
+    importer = package / "sub" / "b.py"
+    assert python_module_file(".", importer) == package / "sub" / "__init__.py"
+    assert python_module_file("..a", importer) == package / "a.py"
+    assert python_module_file("pkg.sub", importer) == package / "sub" / "__init__.py"
+    assert python_module_file("missing", importer) is None
+
+
+def test_javascript_import_and_arrow_parameter(tmp_path):
+    (tmp_path / "util.ts").write_text("export function format(x: number) {\n  return `${x}`;\n}\n", encoding="utf-8")
+    (tmp_path / "view.js").write_text("export default function render() {}\n", encoding="utf-8")
+    main = tmp_path / "main.ts"
+    main.write_text(
+        "import { format as fmt } from './util.js';\nimport render from './view';\n\n"
+        "const show = n => fmt(n);\nrender();\n",
+        encoding="utf-8",
+    )
+    imported = resolve_at(main, 4, 19)
+    assert (imported.kind, imported.definition.path, imported.definition.line) == ("import", tmp_path / "util.ts", 1)
+    assert resolve_at(main, 4, 23).kind == "parameter"
+    default = resolve_at(main, 5, 1)
+    assert (default.definition.path, default.definition.line) == (tmp_path / "view.js", 1)
+
+
+def test_go_names_resolve_across_package_files(tmp_path):
+    (tmp_path / "a.go").write_text("package shapes\n\nfunc Area(w, h int) int {\n\treturn scale(w * h)\n}\n", encoding="utf-8")
+    (tmp_path / "b.go").write_text("package shapes\n\nfunc scale(v int) int { return v }\n", encoding="utf-8")
+    (tmp_path / "other.go").write_text("package main\n\nfunc scale() {}\n", encoding="utf-8")
+    resolver = Resolver()
+    result = resolver.resolve_at(tmp_path / "a.go", 4, 9, references=True)
+    assert (result.kind, result.definition.path, result.definition.line) == ("package", tmp_path / "b.go", 3)
+    assert [(r.path.name, r.line) for r in result.references] == [("a.go", 4)]
+    assert resolver.resolve_at(tmp_path / "a.go", 4, 15).kind == "parameter"
+
+
+def test_json_output_and_missing_identifier(tmp_path):
+    path = tmp_path / "calc.py"
+    path.write_text(PY_MODULE, encoding="utf-8")
+    payload = json.loads(resolve_at(path, 5, 5).to_json())
+    assert payload["kind"] == "local"
+    assert payload["definition"] == {"path": path.as_posix(), "line": 5, "column": 5}
+    with pytest.raises(ValueError, match="No identifier"):
+        resolve_at(path, 2, 1)
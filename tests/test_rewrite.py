@@ -0,0 +1,55 @@
+"""Tests for query-driven structural rewrites."""
+
+from pathlib import Path
+
+import pytest
+
+from treesitter_tools.rewrite import Edit, apply_edits, render_template, rewrite_file, rewrite_paths
+
+GO_PRINTLN = (
+    '(call_expression function: (selector_expression operand: (identifier) @pkg field: (field_identifier) @fn)'
+    ' arguments: (argument_list) @args (#eq? @pkg "fmt") (#eq? @fn "Println"))'
+)
+
+
+def test_render_template():
+    assert render_template("log.Info@args", {"args": "(x)"}) == "log.Info(x)"
+    assert render_template("@@@a", {"a": "b"}) == "@b"
+    with pytest.raises(ValueError, match="unknown capture"):
+        render_template("@missing", {})
+
+
+def test_apply_edits_rejects_overlap():
+    with pytest.raises(ValueError, match="Overlapping"):
+        apply_edits(b"abcdef", [Edit(0, 3, "x", 1, 1), Edit(2, 4, "y", 1, 1)])
+
+
+def test_go_rewrite_preserves_formatting(tmp_path):
+    src = tmp_path / "main.go"
+    src.write_text(
+        'package main\n\nfunc main() {\n\t// keep me\n\tfmt.Println("a",  1)\n\tfmt.Printf("b")\n}\n',
+        encoding="utf-8",
+    )
+    result = rewrite_file(src, GO_PRINTLN, "log.Info@args")
+    text = result.rewritten.decode()
+    assert 'log.Info("a",  1)' in text
+    assert 'fmt.Printf("b")' in text
+    assert "// keep me" in text
+    assert result.edits[0].start_line == 5
+
+
+def test_rewrite_paths_diff_and_language_filter(tmp_path):
+    (tmp_path / "a.py").write_text("print(1)\nprint(2)\n", encoding="utf-8")
+    (tmp_path / "b.js").write_text("print(1)\n", encoding="utf-8")
+    query = '(call function: (identifier) @f (#eq? @f "print") arguments: (_) @a) @match'
+    results = rewrite_paths(tmp_path, "python", query, "logger.info@a")
+    assert [r.path.name for r in results] == ["a.py"]
+    diff = results[0].diff("a.py")
+    assert "-print(1)" in diff and "+logger.info(1)" in diff
+    assert (tmp_path / "a.py").read_text(encoding="utf-8") == "print(1)\nprint(2)\n"
+
+
+def test_rewrite_paths_validates_template_up_front(tmp_path):
+    (tmp_path / "a.py").write_text("print(1)\n", encoding="utf-8")
+    with pytest.raises(ValueError, match="unknown capture"):
+        rewrite_paths(tmp_path, "python", "(call) @match", "@nope")
@@ -0,0 +1,67 @@
+"""Tests for SARIF output."""
+
+import json
+
+from treesitter_tools.clones import find_clones, clones_to_sarif
+from treesitter_tools.diagnostics import check_paths
+from treesitter_tools.export.sarif import FINGERPRINT_KEY, SarifLocation, SarifResult, SarifRule, sarif_log
+from treesitter_tools.metrics import collect_metrics, find_violations, violations_to_sarif
+from treesitter_tools.unused import find_unused, unused_to_sarif
+
+RULE = SarifRule("demo/rule", "DemoRule", "A demo rule")
+
+
+def _run(payload: str) -> dict:
+    log = json.loads(payload)
+    assert log["version"] == "2.1.0"
+    assert len(log["runs"]) == 1
+    return log["runs"][0]
+
+
+def test_log_structure_and_fingerprints(tmp_path):
+    results = [
+        SarifResult(RULE, "first", SarifLocation("src/a b.py", 3, 5), ("x",)),
+        SarifResult(RULE, "again", SarifLocation("src/a b.py", 9, 5), ("x",)),
+        SarifResult(RULE, "moved", SarifLocation("src/a b.py", 40, 1), ("y",)),
+    ]
+    run = sarif_log(results, [RULE], tmp_path)["runs"][0]
+    assert run["tool"]["driver"]["rules"][0]["defaultConfiguration"] == {"level": "warning"}
+    assert run["originalUriBaseIds"]["%SRCROOT%"]["uri"] == tmp_path.resolve().as_uri() + "/"
+    first, second, third = run["results"]
+    assert first["locations"][0]["physicalLocation"]["artifactLocation"] == {"uri": "src/a%20b.py", "uriBaseId": "%SRCROOT%"}
+    assert second["partialFingerprints"][FINGERPRINT_KEY] == first["partialFingerprints"][FINGERPRINT_KEY] + ":2"
+    moved = SarifResult(RULE, "moved", SarifLocation("src/a b.py", 1, 1), ("y",))
+    assert third["partialFingerprints"][FINGERPRINT_KEY] == moved.fingerprint
+
+
+def test_diagnostics_sarif(tmp_path):
+    bad = tmp_path / "bad.py"
+    bad.write_text("def broken(:\n    pass\n", encoding="utf-8")
+    run = _run(check_paths([bad]).to_sarif(tmp_path))
+    assert {r["id"] for r in run["tool"]["driver"]["rules"]} == {"syntax-error", "missing-token"}
+    assert run["results"] and all(r["level"] == "error" for r in run["results"])
+
+
+def test_metrics_sarif_reports_violations_only(tmp_path):
+    (tmp_path / "a.py").write_text(
+        "def branchy(x):\n    if x:\n        return 1\n    elif x > 2:\n        return 2\n    return 3\n\n\ndef flat():\n    return 0\n",
+        encoding="utf-8",
+    )
+    violations = find_violations(collect_metrics(tmp_path), {"cyclomatic": 2})
+    run = _run(violations_to_sarif(violations, tmp_path))
+    [result] = run["results"]
+    assert result["ruleId"] == "complexity/cyclomatic"
+    assert result["message"]["text"] == "'branchy': cyclomatic complexity is 3 (limit 2)"
+    assert result["properties"] == {"value": 3, "limit": 2}
+
+
+def test_unused_and_clones_sarif(tmp_path):
+    body = "    total = 0\n    for item in items:\n        if item > 0:\n            total += item * 2\n    return total\n"
+    (tmp_path / "a.py").write_text(f"def one(items):\n{body}\n\ndef _two(items):\n{body}", encoding="utf-8")
+    unused = _run(unused_to_sarif(find_unused(tmp_path), tmp_path))
+    levels = {r["message"]["text"]: r["level"] for r in unused["results"]}
+    assert levels["Function '_two' is never referenced"] == "warning"
+    assert levels["Function 'one' is never referenced"] == "note"
+    clones = _run(clones_to_sarif(find_clones(tmp_path, min_nodes=10), tmp_path))
+    assert clones["results"][0]["ruleId"] == "clone/type-2"  # the names differ
+    assert clones["results"][0]["relatedLocations"][0]["message"] == {"text": "copy"}
@@ -0,0 +1,122 @@
+"""Tests for the versioned output schemas and --schema-version."""
+
+import json
+
+import pytest
+from conftest import run_cli
+
+from treesitter_tools import schema
+from treesitter_tools.chunker import ChunkOptions, chunk_file
+from treesitter_tools.core import extract_symbols
+from treesitter_tools.index import SymbolIndex
+
+GO_SOURCE = """\
+package coll
+
+type List[T any] struct {
+\titems []T
+}
+
+func Keys[K comparable](m map[K]int) List[K] {
+\treturn New[K]()
+}
+
+func New[T any]() List[T] { return List[T]{} }
+"""
+
+
+@pytest.fixture(autouse=True)
+def _default_schema_version(monkeypatch):
+    monkeypatch.delenv("TREESITTER_TOOLS_SCHEMA_VERSION", raising=False)
+
+
+def test_versions_evolve_compatibly():
+    for old, new in zip(schema.VERSIONS, schema.VERSIONS[1:]):
+        assert schema.compatibility_problems(old, new) == [], (old, new)
+    assert set(schema.CHANGES) == set(schema.VERSIONS)
+
+
+def test_check_version():
+    assert schema.check_version("1.0") == "1.0"
+    assert schema.check_version("1") == schema.VERSIONS[-1]
+    try:
+        schema.check_version("0.9")
+    except ValueError as e:
+        assert "supported: 1.0" in str(e)
+    else:
+        raise AssertionError("0.9 accepted")
+
+
+def test_schema_document_shape():
+    doc = schema.schema_for("index-reference", "1.0")
+    assert doc["$schema"] == schema.DIALECT
+    assert doc["$id"] == "urn:treesitter-tools:schema:1.0:index-reference"
+    assert "type_arguments" not in doc["properties"] and doc["additionalProperties"] is False
+    assert "type_arguments" in schema.schema_for("index-reference")["properties"]
+    symbols = schema.schema_for("file")["properties"]["symbols"]
+    assert symbols["items"]["properties"]["type_parameters"]["items"]["required"] == ["name", "constraint"]
+
+
+def test_real_output_validates(tmp_path):
+    path = tmp_path / "coll.go"
+    path.write_text(GO_SOURCE, encoding="utf-8")
+    symbols = [s.to_dict() for s in extract_symbols(path)]
+    assert any("type_parameters" in s for s in symbols)
+    for data in symbols:
+        assert schema.validate("symbol", data) == []
+    for chunk in chunk_file(path, ChunkOptions()):
+        assert schema.validate("chunk", chunk.to_dict()) == []
+    with SymbolIndex(tmp_path / "index.db") as index:
+        index.update(tmp_path)
+        for kind, record in schema.INDEX_RECORDS.items():
+            for row in index.query(kind, "New" if kind != "implementations" else "List"):
+                assert schema.validate(record, row) == []
+
+
+def test_validate_reports_problems():
+    problems = schema.validate("query-match", {"pattern_index": -1, "captures": [{"name": 1}], "extra": True})
+    assert "query-match.pattern_index: -1 is below the minimum 0" in problems
+    assert "query-match: unexpected property 'extra'" in problems
+    assert any(p.startswith("query-match.captures[0].name: expected string") for p in problems)
+    assert "query-match.captures[0]: missing required property 'type'" in problems
+
+
+def test_pinned_output_drops_newer_properties(tmp_path):
+    (tmp_path / "coll.go").write_text(GO_SOURCE, encoding="utf-8")
+    current = run_cli(["scan", ".", "--format", "json"], cwd=tmp_path)
+    assert current.returncode == 0, current.stderr
+    assert "type_parameters" in current.stdout
+
+    pinned = run_cli(["--schema-version", "1.0", "scan", ".", "--format", "json"], cwd=tmp_path)
+    assert pinned.returncode == 0, pinned.stderr
+    reports = json.loads(pinned.stdout)
+    assert "type_parameters" not in pinned.stdout and "instantiations" not in pinned.stdout
+    assert schema.validate("file", reports[0], "1.0") == []
+    assert schema.validate("file", json.loads(current.stdout)[0], "1.0") != []
+
+    lines = run_cli(["--schema-version", "1.0", "scan", ".", "--format", "ndjson", "--per-symbol"], cwd=tmp_path)
+    assert lines.returncode == 0, lines.stderr
+    for line in lines.stdout.splitlines():
+        assert schema.validate("symbol-record", json.loads(line), "1.0") == []
+
+
+def test_cli_schema_print_and_list(tmp_path):
+    result = run_cli(["schema", "print", "chunk", "--version", "1.0"])
+    assert result.returncode == 0, result.stderr
+    assert json.loads(result.stdout)["$id"] == "urn:treesitter-tools:schema:1.0:chunk"
+
+    result = run_cli(["--schema-version", "1.0", "schema", "print"])
+    bundle = json.loads(result.stdout)
+    assert bundle["x-schema-version"] == "1.0" and "index-reference" in bundle["$defs"]
+    assert "root" not in bundle["$defs"]["file"]["properties"]
+
+    result = run_cli(["schema", "list", "--format", "json"])
+    listing = json.loads(result.stdout)
+    assert listing["current"] == schema.SCHEMA_VERSION
+    assert [v["version"] for v in listing["versions"]] == list(schema.VERSIONS)
+    assert "type-parameter" not in [r["record"] for r in listing["records"]]  # embedded only
+
+    result = run_cli(["schema", "print", "nonexistent"])
+    assert result.returncode == 1 and "Unknown record 'nonexistent'" in result.stderr
+    result = run_cli(["--schema-version", "2.0", "schema", "list"])
+    assert result.returncode == 1 and "Unsupported schema version '2.0'" in result.stderr
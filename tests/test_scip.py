@@ -0,0 +1,47 @@
+"""Tests for the SCIP exporter."""
+
+from pathlib import Path
+
+from treesitter_tools.export import scip
+
+
+def _project(tmp_path: Path) -> Path:
+    root = tmp_path / "proj"
+    root.mkdir()
+    (root / "main.py").write_text(
+        'class Greeter:\n    def hi(self):\n        """Say hi."""\n        return greet()\n\n'
+        "def greet():\n    return 'hi'\n",
+        encoding="utf-8",
+    )
+    return root
+
+
+def test_symbol_monikers_escape_descriptors():
+    package = scip.PackageInfo(name="proj", version="1.0")
+    symbol = scip.make_symbol(package, "src/main.py", [("Greeter", "#"), ("hi", "().")])
+    assert symbol == "scip-treesitter . proj 1.0 src/`main.py`/Greeter#hi()."
+
+
+def test_index_contains_definitions_and_references(tmp_path):
+    index = scip.build_index(_project(tmp_path), package_version="1.0")
+    doc = index["documents"][0]
+    assert doc["relative_path"] == "main.py"
+
+    infos = {info["display_name"]: info for info in doc["symbols"]}
+    assert infos["Greeter"]["kind"] == scip.KIND_CLASS
+    assert infos["hi"]["kind"] == scip.KIND_METHOD
+    assert infos["hi"]["documentation"] == ["Say hi."]
+    assert infos["hi"]["enclosing_symbol"] == infos["Greeter"]["symbol"]
+
+    greet = infos["greet"]["symbol"]
+    refs = [o for o in doc["occurrences"] if o["symbol"] == greet and o["symbol_roles"] == scip.SYMBOL_ROLE_READ_ACCESS]
+    assert refs and refs[0]["range"] == [3, 15, 20]
+
+
+def test_encode_index_is_protobuf(tmp_path):
+    index = scip.build_index(_project(tmp_path))
+    data = scip.encode_index(index)
+    # Field 1 (metadata), wire type 2.
+    assert data[0] == 0x0A
+    assert b"treesitter-tools" in data
+    assert b"main.py" in data
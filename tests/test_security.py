@@ -0,0 +1,120 @@
+"""Tests for the security rule packs."""
+
+import json
+from pathlib import Path
+
+import pytest
+from conftest import run_cli
+
+from treesitter_tools.security import bundled_rules, check_rules, load_pack, select_rules, suppressions
+
+ARTIFACTS = Path(__file__).parent / "artifacts"
+
+GO = '''package store
+
+import (
+	"crypto/md5"
+	"crypto/tls"
+	"database/sql"
+	"os/exec"
+)
+
+func Find(db *sql.DB, name string) {
+	db.Query("SELECT * FROM users WHERE name = '" + name + "'")
+	// nosec go/sql-concat: fixed table list
+	db.Query("SELECT * FROM " + name)
+	exec.Command("sh", "-c", name).Run()
+	_ = md5.Sum([]byte(name))
+	_ = &tls.Config{InsecureSkipVerify: true} // nosec
+}
+'''
+
+PYTHON = '''import hashlib
+import subprocess
+
+def run(cmd, user):
+    eval(cmd)
+    subprocess.run(cmd, shell=True)
+    cursor.execute("SELECT * FROM users WHERE id = %s" % user)
+    return hashlib.md5(user.encode()).hexdigest()  # nosec py/eval
+'''
+
+
+def test_bundled_rules_compile():
+    rules = bundled_rules()
+    assert {r.id.split("/")[0] for r in rules} == {"go", "py", "js"}
+    assert all(r.severity in {"low", "medium", "high"} and r.cwe for r in rules)
+    assert [(rule.id, language, error) for rule, language, error in check_rules(rules)] == []
+
+
+def test_load_pack_errors(tmp_path):
+    pack = tmp_path / "pack.yaml"
+    pack.write_text("languages: [go]\nrules:\n  - id: x/one\n    severity: critical\n    message: m\n    query: (a)\n")
+    with pytest.raises(ValueError, match="rule 1: unknown severity 'critical'"):
+        load_pack(pack)
+    pack.write_text("rules:\n  - id: x/one\n    severity: low\n    message: m\n")
+    with pytest.raises(ValueError, match="rule 1: missing 'query'"):
+        load_pack(pack)
+    pack.write_text("rules:\n  - id: x/one\n    severity: low\n    message: m\n    query: (a)\n")
+    with pytest.raises(ValueError, match="no 'languages'"):
+        load_pack(pack)
+
+
+def test_select_rules():
+    rules = bundled_rules()
+    assert {r.severity for r in select_rules(rules, ["go/*"], "high")} == {"high"}
+    assert [r.id for r in select_rules(rules, ["py/eval"])] == ["py/eval"]
+    with pytest.raises(ValueError, match="No rule matches 'rb/\\*'"):
+        select_rules(rules, ["rb/*"])
+    with pytest.raises(ValueError, match="defined twice"):
+        select_rules(rules + rules[:1])
+
+
+def test_suppressions():
+    covered = suppressions(b"a()  # nosec py/eval, py/weak-hash\n// nosec: reviewed\nb()\n-- nosec\n")
+    assert covered[1] == (frozenset({"py/eval", "py/weak-hash"}), "# nosec py/eval, py/weak-hash")
+    assert covered[2] == covered[3] == (None, "// nosec: reviewed")
+    assert 4 in covered and 5 in covered and 6 not in covered
+
+
+def test_cli_security(tmp_path):
+    (tmp_path / "store.go").write_text(GO, encoding="utf-8")
+    (tmp_path / "run.py").write_text(PYTHON, encoding="utf-8")
+    result = run_cli(["security", "."], cwd=tmp_path)
+    assert result.returncode == 1, result.stderr
+    found = [line.rsplit("[", 1)[1].rstrip("]") for line in result.stdout.splitlines()]
+    assert found == [
+        "py/eval", "py/shell-exec", "py/sql-concat", "py/weak-hash",
+        "go/weak-crypto", "go/sql-concat", "go/shell-exec",
+    ]
+    assert result.stdout.splitlines()[0] == "run.py:5:5: high: Code evaluated from a string [py/eval]"
+    assert "(2 suppressed)" in result.stderr
+
+    data = json.loads(run_cli(["security", ".", "--format", "json", "--rule", "go/*"], cwd=tmp_path).stdout)
+    assert [(f["rule"], f["line"], f["suppressed"]) for f in data["findings"]] == [
+        ("go/weak-crypto", 4, False), ("go/sql-concat", 11, False), ("go/sql-concat", 13, True),
+        ("go/shell-exec", 14, False), ("go/insecure-tls", 16, True),
+    ]
+
+    sarif = json.loads(run_cli(["security", ".", "--format", "sarif", "--severity", "high"], cwd=tmp_path).stdout)
+    rules = {r["id"]: r for r in sarif["runs"][0]["tool"]["driver"]["rules"]}
+    assert rules["go/sql-concat"]["properties"] == {
+        "tags": ["security", "external/cwe/cwe-89"], "security-severity": "8.0",
+    }
+    results = sarif["runs"][0]["results"]
+    assert {r["level"] for r in results} == {"error"}
+    assert [r["suppressions"][0]["justification"] for r in results if "suppressions" in r] == [
+        "// nosec go/sql-concat: fixed table list", "// nosec",
+    ]
+
+    clean = run_cli(["security", "store.go", "--rule", "go/weak-tls-version"], cwd=tmp_path)
+    assert clean.returncode == 0 and clean.stdout == ""
+
+
+def test_sample_hash():
+    result = run_cli(["security", str(ARTIFACTS / "sample_complex.go"), "--rule", "go/hand-rolled-hash"])
+    assert result.returncode == 1, result.stderr
+    assert result.stdout.split(": ", 1)[1] == (
+        "low: Hand-rolled multiplicative hash (h = K*h + x) is not collision-resistant [go/hand-rolled-hash]\n"
+    )
+    assert result.stdout.split(":")[1] == "37"
@@ -0,0 +1,176 @@
+"""Tests for the HTTP daemon and its parser pool."""
+
+import http.client
+import json
+import threading
+import urllib.error
+import urllib.request
+
+import pytest
+
+from treesitter_tools.server import ParserPool, ServiceError, ToolService, make_http_server, make_metrics_server
+
+PY_SOURCE = """\
+class Greeter:
+    def hi(self):
+        return "hi"
+"""
+
+
+@pytest.fixture
+def base_url(tmp_path):
+    (tmp_path / "app.py").write_text(PY_SOURCE, encoding="utf-8")
+    server = make_http_server(ToolService(tmp_path), port=0)
+    thread = threading.Thread(target=server.serve_forever, daemon=True)
+    thread.start()
+    yield f"http://127.0.0.1:{server.server_address[1]}"
+    server.shutdown()
+    server.server_close()
+
+
+def _post(url, body):
+    request = urllib.request.Request(url, data=json.dumps(body).encode("utf-8"), method="POST")
+    try:
+        with urllib.request.urlopen(request) as response:
+            return response.status, json.loads(response.read())
+    except urllib.error.HTTPError as exc:
+        return exc.code, json.loads(exc.read())
+
+
+def test_extract_and_query(base_url):
+    status, body = _post(f"{base_url}/v1/extract", {"path": "app.py"})
+    assert status == 200
+    names = {s["name"] for s in body["symbols"]}
+    assert {"Greeter", "hi"} <= names
+    assert all("content" not in s for s in body["symbols"])
+
+    status, body = _post(
+        f"{base_url}/v1/query",
+        {"source": "def f():\n    pass\n", "language": "python",
+         "query": "(function_definition name: (identifier) @name)"},
+    )
+    assert status == 200
+    assert body["matches"][0]["captures"][0]["text"] == "f"
+
+
+def test_parse_and_health(base_url):
+    status, body = _post(f"{base_url}/v1/parse", {"path": "app.py", "sexp": True})
+    assert status == 200
+    assert body["language"] == "python"
+    assert body["has_error"] is False
+    assert body["sexp"].startswith("(module")
+
+    with urllib.request.urlopen(f"{base_url}/healthz") as response:
+        health = json.loads(response.read())
+    assert health["status"] == "ok"
+    assert "python" in health["warm_languages"]
+    assert health["requests"]["parse"] == 1
+
+
+def test_errors(base_url):
+    assert _post(f"{base_url}/v1/extract", {"path": "../outside.py"})[0] == 403
+    assert _post(f"{base_url}/v1/extract", {"path": "missing.py"})[0] == 404
+    assert _post(f"{base_url}/v1/extract", {"source": "x = 1"})[0] == 400
+    assert _post(f"{base_url}/v1/nope", {})[0] == 404
+    status, body = _post(f"{base_url}/v1/query", {"path": "app.py", "query": "(not_a_node) @x"})
+    assert status == 400
+    assert "error" in body
+
+
+def _raw_post(base_url, body, headers):
+    host, port = base_url[len("http://"):].split(":")
+    connection = http.client.HTTPConnection(host, int(port), timeout=5)
+    try:
+        connection.putrequest("POST", "/v1/parse")
+        for name, value in headers.items():
+            connection.putheader(name, value)
+        connection.endheaders(body)
+        response = connection.getresponse()
+        return response.status, json.loads(response.read())
+    finally:
+        connection.close()
+
+
+def test_bad_bodies_get_a_reply(base_url):
+    assert _raw_post(base_url, b"{}", {"Content-Length": "two"})[0] == 400
+    assert _raw_post(base_url, b"{}", {"Content-Length": "-1"})[0] == 400
+    assert _raw_post(base_url, b"", {"Transfer-Encoding": "chunked"})[0] == 411
+    not_utf8 = b'{"source": "\xff"}'
+    status, body = _raw_post(base_url, not_utf8, {"Content-Length": str(len(not_utf8))})
+    assert status == 400 and "Invalid JSON" in body["error"]
+
+
+def test_handler_errors_get_a_reply(tmp_path):
+    service = ToolService(tmp_path)
+    service.methods["boom"] = lambda payload: payload["missing"]
+    server = make_http_server(service, port=0)
+    threading.Thread(target=server.serve_forever, daemon=True).start()
+    try:
+        status, body = _post(f"http://127.0.0.1:{server.server_address[1]}/v1/boom", {})
+        assert status == 500 and "KeyError" in body["error"]
+        assert service.health()["errors"] == 1
+    finally:
+        server.shutdown()
+        server.server_close()
+
+
+def test_parser_pool_reuses_parsers():
+    pool = ParserPool(size=1)
+    with pool.parser("python") as first:
+        pass
+    with pool.parser("python") as again:
+        assert again is first
+        with pool.parser("python") as concurrent:
+            assert concurrent is not again
+
+
+def test_service_rejects_non_object(tmp_path):
+    with pytest.raises(ServiceError):
+        ToolService(tmp_path).call("parse", [])
+
+
+def test_metrics_endpoint(base_url):
+    _post(f"{base_url}/v1/parse", {"path": "app.py"})
+    _post(f"{base_url}/v1/parse", {"source": "def broken(:\n", "language": "python"})
+    _post(f"{base_url}/v1/query", {"path": "app.py", "query": "(identifier) @id"})
+    _post(f"{base_url}/v1/query", {"path": "app.py", "query": "(identifier) @id"})
+    _post(f"{base_url}/v1/extract", {"path": "missing.py"})
+    _post(f"{base_url}/v1/no/such/method", {})
+
+    with urllib.request.urlopen(f"{base_url}/metrics") as response:
+        assert response.headers["Content-Type"].startswith("text/plain; version=0.0.4")
+        text = response.read().decode("utf-8")
+    assert 'treesitter_tools_requests_total{method="parse",transport="http",status="200"} 2' in text
+    assert 'treesitter_tools_requests_total{method="extract",transport="http",status="404"} 1' in text
+    assert 'treesitter_tools_requests_total{method="unknown",transport="http",status="404"} 1' in text
+    assert 'treesitter_tools_parses_total{language="python"} 4' in text
+    assert 'treesitter_tools_syntax_errors_total{language="python"} 1' in text
+    assert 'treesitter_tools_cache_requests_total{cache="query",result="hit"} 1' in text
+    assert 'treesitter_tools_request_duration_seconds_count{method="query",transport="http"} 2' in text
+    assert '# TYPE treesitter_tools_parse_duration_seconds histogram' in text
+
+    with urllib.request.urlopen(f"{base_url}/healthz") as response:
+        health = json.loads(response.read())
+    assert health["caches"]["query"] == {"hits": 1, "misses": 1}
+
+
+def test_grammar_errors_are_counted(tmp_path):
+    service = ToolService(tmp_path)
+    with pytest.raises(ServiceError):
+        service.call("parse", {"source": "x", "language": "no-such-language"})
+    assert 'treesitter_tools_grammar_errors_total{language="no-such-language"} 1' in service.metrics()
+    assert 'method="parse",transport="direct",status="400"' in service.metrics()
+
+
+def test_metrics_server_serves_only_metrics(tmp_path):
+    server = make_metrics_server(ToolService(tmp_path), port=0)
+    thread = threading.Thread(target=server.serve_forever, daemon=True)
+    thread.start()
+    url = f"http://127.0.0.1:{server.server_address[1]}"
+    try:
+        with urllib.request.urlopen(f"{url}/metrics") as response:
+            assert "treesitter_tools_uptime_seconds" in response.read().decode("utf-8")
+        assert _post(f"{url}/v1/parse", {"source": "x = 1", "language": "python"})[0] == 404
+    finally:
+        server.shutdown()
+        server.server_close()
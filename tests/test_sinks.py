@@ -0,0 +1,129 @@
+"""Tests for output sinks."""
+
+import datetime
+import gzip
+import hashlib
+import threading
+from http.server import BaseHTTPRequestHandler, ThreadingHTTPServer
+from pathlib import Path
+
+import pytest
+from conftest import run_cli
+
+from treesitter_tools.sinks import FileSink, GzipSink, S3Sink, SinkError, StdoutSink, WebhookSink, local_path, open_sink
+
+
+@pytest.fixture
+def server():
+    """A local endpoint that records requests and answers with the queued status codes."""
+    received, statuses = [], []
+
+    class Handler(BaseHTTPRequestHandler):
+        def _handle(self):
+            body = self.rfile.read(int(self.headers["Content-Length"]))
+            received.append((self.command, self.path, self.headers, body))
+            self.send_response(statuses.pop(0) if statuses else 200)
+            self.end_headers()
+
+        do_POST = do_PUT = _handle
+
+        def log_message(self, *args):
+            pass
+
+    httpd = ThreadingHTTPServer(("127.0.0.1", 0), Handler)
+    thread = threading.Thread(target=httpd.serve_forever, daemon=True)
+    thread.start()
+    yield f"http://127.0.0.1:{httpd.server_port}", received, statuses
+    httpd.shutdown()
+
+
+def test_open_sink_dispatch(tmp_path):
+    env = {"AWS_ACCESS_KEY_ID": "AK", "AWS_SECRET_ACCESS_KEY": "SK"}
+    assert isinstance(open_sink(None), StdoutSink)
+    assert isinstance(open_sink("-"), StdoutSink)
+    assert isinstance(open_sink("s3://bucket/report.json", env=env), S3Sink)
+    assert isinstance(open_sink("https://example.com/hook", env={}), WebhookSink)
+    sink = open_sink(str(tmp_path / "out.json"))
+    assert isinstance(sink, FileSink)
+    sink.close()
+    assert isinstance(open_sink("s3://bucket/report.json.gz", env=env).inner, S3Sink)
+    assert local_path("s3://bucket/key") is None and local_path("-") is None
+    assert local_path("out/report.json") == Path("out/report.json")
+    with pytest.raises(SinkError, match="AWS_ACCESS_KEY_ID"):
+        open_sink("s3://bucket/key", env={})
+
+
+def test_gzip_file_sink(tmp_path):
+    target = tmp_path / "report.json.gz"
+    with open_sink(str(target)) as sink:
+        assert isinstance(sink, GzipSink)
+        sink.write('{"a": 1}\n')
+        sink.flush()
+        sink.write('{"b": 2}\n')
+    assert gzip.decompress(target.read_bytes()) == b'{"a": 1}\n{"b": 2}\n'
+
+
+def test_webhook_retries_and_redacts_query(server):
+    url, received, statuses = server
+    statuses.append(503)
+    sink = open_sink(f"{url}/ingest?key=secret", env={"TREESITTER_TOOLS_WEBHOOK_TOKEN": "tok"})
+    sink.sleep = lambda seconds: None
+    with sink:
+        sink.write('[{"name": "foo"}]')
+    assert str(sink) == f"{url}/ingest"
+    assert len(received) == 2
+    method, path, headers, body = received[-1]
+    assert (method, path, body) == ("POST", "/ingest?key=secret", b'[{"name": "foo"}]')
+    assert headers["Authorization"] == "Bearer tok"
+    assert headers["Content-Type"] == "application/json"
+
+
+def test_webhook_client_error_is_not_retried(server):
+    url, received, statuses = server
+    statuses.append(400)
+    sink = WebhookSink(f"{url}/ingest", sleep=lambda seconds: None)
+    sink.write("digraph {}\n")
+    with pytest.raises(SinkError, match="HTTP 400"):
+        sink.close()
+    assert len(received) == 1
+
+
+def test_aborted_upload_sends_nothing(server):
+    url, received, _ = server
+    with pytest.raises(KeyError):
+        with open_sink(f"{url}/ingest", env={}) as sink:
+            sink.write("partial")
+            raise KeyError("boom")
+    assert received == []
+
+
+def test_s3_put_is_path_style_and_signed(server):
+    url, received, _ = server
+    env = {"AWS_ACCESS_KEY_ID": "AK", "AWS_SECRET_ACCESS_KEY": "SK", "AWS_REGION": "eu-west-1",
+           "AWS_ENDPOINT_URL": url, "AWS_SESSION_TOKEN": "session"}
+    sink = open_sink("s3://ci-results/runs/42/symbols.ndjson", env=env)
+    sink.clock = lambda: datetime.datetime(2024, 5, 1, 12, 0, 0, tzinfo=datetime.timezone.utc)
+    with sink:
+        sink.write('{"path": "a.py"}\n')
+    method, path, headers, body = received[0]
+    assert (method, path) == ("PUT", "/ci-results/runs/42/symbols.ndjson")
+    assert headers["x-amz-content-sha256"] == hashlib.sha256(body).hexdigest()
+    assert headers["x-amz-date"] == "20240501T120000Z"
+    assert headers["x-amz-security-token"] == "session"
+    assert headers["Authorization"].startswith(
+        "AWS4-HMAC-SHA256 Credential=AK/20240501/eu-west-1/s3/aws4_request, "
+        "SignedHeaders=host;x-amz-content-sha256;x-amz-date;x-amz-security-token, Signature="
+    )
+
+
+def test_s3_default_endpoint_is_virtual_hosted():
+    sink = S3Sink("bucket", "a b/c.json", "AK", "SK", region="us-west-2")
+    assert sink._url() == "https://bucket.s3.us-west-2.amazonaws.com/a%20b/c.json"
+
+
+def test_cli_writes_gzip_output(tmp_path):
+    (tmp_path / "a.py").write_text("def foo():\n    bar()\n\ndef bar():\n    pass\n", encoding="utf-8")
+    out = tmp_path / "graph.json.gz"
+    result = run_cli(["callgraph", tmp_path / "a.py", "--output", out])
+    assert result.returncode == 0, result.stderr
+    assert b'"bar"' in gzip.decompress(out.read_bytes())
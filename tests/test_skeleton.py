@@ -0,0 +1,56 @@
+"""Tests for the skeleton command."""
+
+from treesitter_tools.skeleton import collect_skeletons, skeleton_file
+
+PY_SOURCE = '''\
+MAX = 3
+
+
+class Greeter:
+    """Says hello."""
+
+    def hi(self, name: str) -> str:
+        """Greet someone."""
+        return f"hi {name}"
+
+    def bye(self):
+        def inner():
+            return 1
+        return inner()
+'''
+
+GO_SOURCE = """\
+package main
+
+// Answer is the answer.
+const Answer = 42
+
+// Compute does work.
+func Compute(x int) int {
+    y := x * 2
+    return y
+}
+"""
+
+
+def test_python_skeleton_keeps_docstrings(tmp_path):
+    path = tmp_path / "greet.py"
+    path.write_text(PY_SOURCE, encoding="utf-8")
+    sk = skeleton_file(path)
+    assert sk.elided == 2
+    assert '    def hi(self, name: str) -> str:\n        """Greet someone."""\n        ...\n' in sk.text
+    assert "    def bye(self):\n        ...\n" in sk.text
+    assert "inner" not in sk.text
+    assert sk.text.startswith("MAX = 3")
+    assert '"""Says hello."""' in sk.text
+
+
+def test_go_skeleton_and_directory(tmp_path):
+    (tmp_path / "main.go").write_text(GO_SOURCE, encoding="utf-8")
+    (tmp_path / "greet.py").write_text(PY_SOURCE, encoding="utf-8")
+    skeletons = collect_skeletons(tmp_path)
+    assert [sk.path for sk in skeletons] == ["greet.py", "main.go"]
+    go = skeletons[1].text
+    assert "func Compute(x int) int { ... }" in go
+    assert "// Compute does work." in go and "const Answer = 42" in go
+    assert "y :=" not in go
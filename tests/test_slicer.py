@@ -0,0 +1,166 @@
+"""Tests for dependency slices."""
+
+import json
+
+import pytest
+from conftest import run_cli
+
+from treesitter_tools.slicer import build_slice
+
+MODELS = '''\
+TAX_RATE = 0.2
+
+
+class Order:
+    def __init__(self, total):
+        self.total = total
+
+    def taxed(self):
+        return apply_rate(self.total, TAX_RATE)
+
+
+def apply_rate(value, rate):
+    return value * (1 + rate)
+
+
+def unused():
+    return 0
+'''
+
+PRICING = '''\
+import math
+import os
+from models import Order
+
+DISCOUNT = 0.9
+LIMIT = 100
+
+
+def round_up(value):
+    return math.ceil(value)
+
+
+def discount(order: Order) -> float:
+    return round_up(order.taxed() * DISCOUNT)
+
+
+def main():
+    return discount(Order(LIMIT))
+'''
+
+SHAPES = '''\
+package shapes
+
+import (
+\t"fmt"
+\t"math"
+\t"os"
+)
+
+type Unit int
+
+const (
+\tMetric Unit = iota
+\tImperial
+)
+
+const Scale = 2.0
+
+type Circle struct {
+\tRadius float64
+\tUnit   Unit
+}
+
+func (c Circle) Area() float64 {
+\treturn math.Pi * c.Radius * c.Radius * Scale
+}
+
+func Describe(c Circle) string {
+\tif c.Unit == Imperial {
+\t\treturn fmt.Sprintf("%.1f sq in", c.Area())
+\t}
+\treturn fmt.Sprintf("%.1f m2", c.Area())
+}
+
+func Unrelated() {}
+'''
+
+
+@pytest.fixture
+def project(tmp_path):
+    (tmp_path / "models.py").write_text(MODELS, encoding="utf-8")
+    (tmp_path / "pricing.py").write_text(PRICING, encoding="utf-8")
+    return tmp_path
+
+
+def test_slice_follows_references_transitively(project):
+    sliced = build_slice(project, "discount")
+    assert [(item.path, item.role, item.name, item.depth) for item in sliced.items] == [
+        ("pricing.py", "value", "DISCOUNT", 1),
+        ("pricing.py", "function", "round_up", 1),
+        ("pricing.py", "target", "discount", 0),
+        ("models.py", "value", "TAX_RATE", 2),
+        ("models.py", "type", "Order", 1),
+        ("models.py", "function", "apply_rate", 2),
+    ]
+    assert sliced.imports == {"pricing.py": ["import math", "from models import Order"]}
+    assert sliced.external == ["math.ceil"]
+    assert (sliced.path, sliced.start_line, sliced.end_line) == ("pricing.py", 13, 14)
+
+    source = sliced.to_source()
+    assert source.startswith("# Slice of discount (6 definitions)\n# Outside the slice: math.ceil\n\n# pricing.py\n")
+    assert "import os" not in source and "def unused" not in source and "LIMIT" not in source
+    assert source.index("DISCOUNT = 0.9") < source.index("def round_up") < source.index("# models.py")
+
+
+def test_depth_limits_the_slice(project):
+    names = [item.name for item in build_slice(project, "discount", depth=1).items]
+    assert names == ["DISCOUNT", "round_up", "discount", "Order"]
+    with pytest.raises(ValueError, match="depth"):
+        build_slice(project, "discount", depth=0)
+    with pytest.raises(ValueError, match="No definition"):
+        build_slice(project, "missing")
+
+
+def test_method_slices_to_its_class(project):
+    sliced = build_slice(project, "Order.taxed")
+    assert [(item.role, item.name) for item in sliced.items] == [
+        ("value", "TAX_RATE"), ("type", "Order"), ("function", "apply_rate"),
+    ]
+    assert (sliced.symbol, sliced.start_line) == ("Order.taxed", 8)
+
+
+def test_go_slice(tmp_path):
+    (tmp_path / "shapes.go").write_text(SHAPES, encoding="utf-8")
+    sliced = build_slice(tmp_path, "Describe")
+    assert [(item.role, item.name, item.depth) for item in sliced.items] == [
+        ("type", "Unit", 2),
+        ("value", "Imperial", 1),
+        ("value", "Scale", 2),
+        ("type", "Circle", 1),
+        ("function", "Circle.Area", 1),
+        ("target", "Describe", 0),
+    ]
+    contents = [item.content for item in sliced.items]
+    assert contents[0] == "type Unit int"
+    assert contents[1] == "const (\n\tMetric Unit = iota\n\tImperial\n)"
+    assert contents[3].startswith("type Circle struct {")
+    assert sliced.imports == {"shapes.go": ["package shapes", 'import "fmt"', 'import "math"']}
+    assert sliced.external == ["fmt.Sprintf"]
+    assert "Unrelated" not in sliced.to_source()
+
+
+def test_cli_slice(project):
+    result = run_cli(["slice", "discount", ".", "--format", "json"], cwd=project)
+    assert result.returncode == 0, result.stderr
+    payload = json.loads(result.stdout)
+    assert payload["symbol"] == "discount" and payload["external"] == ["math.ceil"]
+    models = [item["name"] for item in payload["items"] if item["path"] == "models.py"]
+    assert models == ["TAX_RATE", "Order", "apply_rate"]
+
+    markdown = run_cli(["slice", "discount", ".", "--depth", "1", "--format", "markdown"], cwd=project).stdout
+    assert markdown.startswith("# Slice of `discount`\n")
+    assert "## models.py\n\n```python\nclass Order:" in markdown
+
+    bad = run_cli(["slice", "discount", ".", "--format", "xml"], cwd=project)
+    assert bad.returncode == 1 and "Unsupported format 'xml'" in bad.stderr
@@ -0,0 +1,85 @@
+"""Tests for archive and git+ URL input sources."""
+
+import io
+import json
+import shutil
+import subprocess
+import tarfile
+import zipfile
+
+import pytest
+from conftest import run_cli
+
+from treesitter_tools.sources import SourceError, is_input_source, materialize, parse_git_url
+
+
+def _tarball(tmp_path, members):
+    archive = tmp_path / "pkg-1.0.tar.gz"
+    with tarfile.open(archive, "w:gz") as tar:
+        for name, text in members.items():
+            data = text.encode("utf-8")
+            info = tarfile.TarInfo(name)
+            info.size = len(data)
+            tar.addfile(info, io.BytesIO(data))
+    return archive
+
+
+def test_tarball_resolves_to_its_project_directory(tmp_path):
+    archive = _tarball(tmp_path, {"pkg-1.0/src/app.py": "def f():\n    pass\n", "pkg-1.0/README": "hi\n"})
+    assert is_input_source(str(archive))
+    root = materialize(str(archive))
+    assert root.name == "pkg-1.0" and (root / "src" / "app.py").is_file()
+    assert materialize(str(archive)) == root
+
+
+def test_zip_without_a_single_top_directory(tmp_path):
+    archive = tmp_path / "sources.zip"
+    with zipfile.ZipFile(archive, "w") as zf:
+        zf.writestr("a.py", "x = 1\n")
+        zf.writestr("lib/b.py", "y = 2\n")
+    root = materialize(str(archive))
+    assert sorted(p.name for p in root.iterdir()) == ["a.py", "lib"]
+
+
+def test_unsafe_members_are_refused(tmp_path):
+    archive = _tarball(tmp_path, {"../escape.py": "x = 1\n"})
+    with pytest.raises(SourceError, match="unsafe member"):
+        materialize(str(archive))
+    assert not (tmp_path.parent / "escape.py").exists()
+
+
+def test_parse_git_url():
+    assert parse_git_url("git+https://github.com/org/repo.git@v1.2") == ("https://github.com/org/repo.git", "v1.2")
+    assert parse_git_url("git+ssh://git@github.com/org/repo@main") == ("ssh://git@github.com/org/repo", "main")
+    assert parse_git_url("git+https://github.com/org/repo") == ("https://github.com/org/repo", None)
+    with pytest.raises(SourceError):
+        parse_git_url("git+ftp://example.com/repo")
+    with pytest.raises(SourceError, match="Invalid ref"):
+        parse_git_url("git+https://example.com/repo@--upload-pack=touch /tmp/pwned")
+
+
+@pytest.mark.skipif(shutil.which("git") is None, reason="git not installed")
+def test_git_url_at_ref(tmp_path):
+    repo = tmp_path / "upstream"
+    repo.mkdir()
+    git = ["git", "-C", str(repo), "-c", "user.email=t@example.com", "-c", "user.name=t"]
+    (repo / "m.py").write_text("def old():\n    pass\n", encoding="utf-8")
+    subprocess.run(["git", "init", "-q", str(repo)], check=True)
+    subprocess.run(git + ["add", "."], check=True)
+    subprocess.run(git + ["commit", "-qm", "one"], check=True)
+    subprocess.run(git + ["tag", "v1"], check=True)
+    (repo / "m.py").write_text("def new():\n    pass\n", encoding="utf-8")
+    subprocess.run(git + ["commit", "-qam", "two"], check=True)
+    checkout = materialize(f"git+{repo.as_uri()}@v1")
+    assert "old" in (checkout / "m.py").read_text(encoding="utf-8")
+    with pytest.raises(SourceError):
+        materialize(f"git+{repo.as_uri()}@no-such-ref")
+
+
+def test_cli_accepts_archive(tmp_path):
+    archive = _tarball(tmp_path, {"pkg-1.0/app.py": "def f():\n    pass\n"})
+    result = run_cli(["metrics", str(archive), "--format", "json"], cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    assert [m["name"] for m in json.loads(result.stdout)] == ["f"]
+    result = run_cli(["metrics", "git+ftp://example.com/x"], cwd=tmp_path)
+    assert result.returncode == 2 and "Unsupported git URL" in result.stderr
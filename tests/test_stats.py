@@ -0,0 +1,47 @@
+"""Tests for the stats command."""
+
+import json
+
+from conftest import run_cli
+
+from treesitter_tools.stats import collect_stats, distribution, histogram, percentile
+
+
+def _project(tmp_path):
+    (tmp_path / "app.py").write_text(
+        "def short():\n    return 1\n\n\ndef nested(x):\n    if x:\n        for i in x:\n            while i:\n"
+        "                i -= 1\n    return x\n",
+        encoding="utf-8",
+    )
+    (tmp_path / "util.js").write_text("function one() { return 1; }\n", encoding="utf-8")
+    (tmp_path / "NOTES.txt").write_text("not source code\n", encoding="utf-8")
+    (tmp_path / "blob.py").write_bytes(b"\x00\x01binary")
+    return tmp_path
+
+
+def test_percentiles_and_histogram():
+    assert [percentile(list(range(1, 101)), p) for p in (50, 90, 99)] == [50, 90, 99]
+    assert distribution([]) == {"min": 0, "p50": 0, "p90": 0, "p99": 0, "max": 0, "mean": 0}
+    buckets = histogram([1, 5, 6, 11, 900], (5, 10))
+    assert buckets == [{"bucket": "1-5", "count": 2}, {"bucket": "6-10", "count": 1}, {"bucket": "11+", "count": 2}]
+
+
+def test_collect_stats(tmp_path):
+    data = collect_stats(_project(tmp_path), top=1).to_dict()
+    assert data["functions"] == 3 and data["skipped"] == 1
+    assert data["languages"]["python"] == {"files": 1, "lines": 10, "bytes": len((tmp_path / "app.py").read_bytes()), "functions": 2}
+    assert data["languages"]["javascript"]["functions"] == 1
+    assert data["function_lines"]["max"] == 6 and data["function_lines"]["p50"] == 2
+    assert [f["name"] for f in data["deepest_nesting"]] == ["nested"]
+    assert data["deepest_nesting"][0]["max_nesting"] == 3
+    assert [f["path"] for f in data["largest_files"]] == ["app.py"]
+    assert sum(b["count"] for b in data["function_tokens"]["histogram"]) == 3
+
+
+def test_stats_cli(tmp_path):
+    _project(tmp_path)
+    result = run_cli(["stats", "."], cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    assert "3 functions" in result.stdout and "Function length (lines): min=1" in result.stdout
+    result = run_cli(["stats", ".", "--format", "json", "--top", "2"], cwd=tmp_path)
+    assert len(json.loads(result.stdout)["longest_functions"]) == 2
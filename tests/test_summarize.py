@@ -0,0 +1,134 @@
+"""Tests for the function-summary enrichment stage."""
+
+import json
+import threading
+from http.server import BaseHTTPRequestHandler, ThreadingHTTPServer
+from pathlib import Path
+
+import pytest
+from conftest import run_cli
+
+from treesitter_tools.core import CodeSymbol, FileSymbols
+from treesitter_tools.summarize import Summarizer, SummarizerConfig, SummaryError, clean_summary, http_completer
+
+
+def _function(name, digest, content, **extra):
+    return CodeSymbol("function", name, 1, 2, None, None, content=content, body_hash=digest, **extra)
+
+
+def _report(path, *symbols):
+    return FileSymbols(Path(path), "python", list(symbols))
+
+
+def test_clean_summary():
+    assert clean_summary('"Summary: Adds two numbers."\n\nLonger text.') == "Adds two numbers."
+    assert clean_summary("**Summary:** Parses the header.") == "Parses the header."
+    assert clean_summary("\n- `Returns x.`") == "Returns x."
+    assert clean_summary("  \n") == ""
+
+
+def test_summaries_are_cached_by_body_hash(tmp_path):
+    prompts = []
+
+    def complete(prompt):
+        prompts.append(prompt)
+        return "Doubles its argument."
+
+    reports = [
+        _report("a.py", _function("double", "h1", "def double(x): return 2 * x"),
+                CodeSymbol("class", "C", 3, 4, None, None, content="class C: pass")),
+        _report("b.py", _function("twice", "h1", "def twice(x): return 2 * x")),  # same body, renamed
+    ]
+    summarizer = Summarizer(complete, "m", cache_dir=tmp_path, jobs=2)
+    out = list(summarizer.summarize(reports))
+    assert [[s.summary for s in r.symbols] for r in out] == [["Doubles its argument.", None], ["Doubles its argument."]]
+    assert len(prompts) == 1 and "python function" in prompts[0] and "return 2 * x" in prompts[0]
+    assert out[0].symbols[0].to_dict()["summary"] == "Doubles its argument."
+
+    again = Summarizer(lambda prompt: pytest.fail("should be cached"), "m", cache_dir=tmp_path)
+    fresh = [_report("a.py", _function("double", "h1", "def double(x): return 2 * x"))]
+    assert next(again.summarize(fresh)).symbols[0].summary == "Doubles its argument."
+    assert again.stats.to_dict() == {"summarized": 0, "cached": 1, "failed": 0, "skipped": 0}
+
+
+def test_chunks_are_summarized_as_one_body():
+    prompts = []
+    chunks = [
+        _function("big", "h", "def big():\n", chunk_index=0, chunk_count=2, overflow=True),
+        _function("big", "h", "    return 1\n", chunk_index=1, chunk_count=2, overflow=True),
+    ]
+    summarizer = Summarizer(lambda prompt: prompts.append(prompt) or "Returns one.", "m")
+    report = next(summarizer.summarize([_report("a.py", *reversed(chunks))]))
+    assert [s.summary for s in report.symbols] == ["Returns one.", "Returns one."]
+    assert "def big():\n    return 1\n" in prompts[0]
+
+
+def test_failures_are_skipped_and_the_endpoint_given_up_on():
+    def complete(prompt):
+        raise SummaryError("endpoint down")
+
+    reports = [_report(f"m{i}.py", _function(f"f{i}", f"h{i}", f"def f{i}(): pass")) for i in range(5)]
+    summarizer = Summarizer(complete, "m", jobs=1, max_failures=2)
+    out = list(summarizer.summarize(reports, window=1))
+    assert [r.symbols[0].summary for r in out] == [None] * 5
+    assert summarizer.gave_up
+    assert summarizer.stats.to_dict() == {"summarized": 0, "cached": 0, "failed": 2, "skipped": 3,
+                                          "last_error": "endpoint down"}
+
+
+class _Handler(BaseHTTPRequestHandler):
+    requests = []
+
+    def do_POST(self):
+        body = json.loads(self.rfile.read(int(self.headers["Content-Length"])))
+        type(self).requests.append((self.path, self.headers.get("Authorization"), body))
+        payload = {"choices": [{"message": {"role": "assistant", "content": "Greets the caller by name."}}]}
+        self.send_response(200)
+        self.send_header("Content-Type", "application/json")
+        self.end_headers()
+        self.wfile.write(json.dumps(payload).encode("utf-8"))
+
+    def log_message(self, *args):
+        pass
+
+
+@pytest.fixture
+def endpoint():
+    _Handler.requests = []
+    server = ThreadingHTTPServer(("127.0.0.1", 0), _Handler)
+    thread = threading.Thread(target=server.serve_forever, daemon=True)
+    thread.start()
+    yield f"http://127.0.0.1:{server.server_address[1]}/v1"
+    server.shutdown()
+    server.server_close()
+
+
+def test_http_completer(endpoint):
+    complete = http_completer(SummarizerConfig(url=endpoint, model="tiny", api_key="k"))
+    assert complete("hello") == "Greets the caller by name."
+    path, auth, body = _Handler.requests[-1]
+    assert (path, auth) == ("/v1/chat/completions", "Bearer k")
+    assert body["model"] == "tiny" and body["messages"] == [{"role": "user", "content": "hello"}]
+
+
+def test_cli_summarize(tmp_path, endpoint):
+    (tmp_path / "src").mkdir()
+    (tmp_path / "src" / "greet.py").write_text("def greet(name):\n    return 'hi ' + name\n", encoding="utf-8")
+    args = ["symbols", "src/greet.py", "--summarize", "--summary-endpoint", endpoint, "--cache-dir", "cache"]
+    result = run_cli(args, cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    assert json.loads(result.stdout)[0]["summary"] == "Greets the caller by name."
+    assert "Summaries: 1 new, 0 cached" in result.stderr
+
+    scan = run_cli(["scan", "src", "--format", "ndjson", "--summarize", "--summary-endpoint", endpoint,
+                    "--cache-dir", "cache"], cwd=tmp_path)
+    assert scan.returncode == 0, scan.stderr
+    assert json.loads(scan.stdout.splitlines()[0])["symbols"][0]["summary"] == "Greets the caller by name."
+    assert "0 new, 1 cached" in scan.stderr
+    assert len(_Handler.requests) == 1
+
+    down = run_cli(["symbols", "src/greet.py", "--summarize", "--summary-endpoint", "http://127.0.0.1:9/v1"],
+                   cwd=tmp_path)
+    assert down.returncode == 0, down.stderr
+    assert "summary" not in json.loads(down.stdout)[0]
+    assert "1 failed" in down.stderr and "Cannot reach" in down.stderr
@@ -0,0 +1,49 @@
+"""Tests for ctags/etags generation."""
+
+from treesitter_tools.tags import collect_tags, to_ctags, to_etags
+
+PY_SOURCE = """\
+class Greeter:
+    def hi(self, name):
+        return f"hi/{name}"
+
+
+def main():
+    Greeter().hi("x")
+"""
+
+
+def test_ctags_fields_and_sorting(tmp_path):
+    (tmp_path / "app.py").write_text(PY_SOURCE, encoding="utf-8")
+    lines = to_ctags(collect_tags(tmp_path)).splitlines()
+    assert lines[0].startswith("!_TAG_FILE_FORMAT\t2")
+    entries = [line.split("\t") for line in lines if not line.startswith("!_TAG_")]
+    assert [e[0] for e in entries] == ["Greeter", "hi", "main"]
+    hi = entries[1]
+    assert hi[1] == "app.py"
+    assert hi[2] == '/^    def hi(self, name):$/;"'
+    assert hi[3:] == ["kind:method", "line:2", "class:Greeter", "signature:(self, name)"]
+    assert entries[0][3:] == ["kind:class", "line:1"]
+
+
+def test_go_scope_uses_struct_kind(tmp_path):
+    (tmp_path / "c.go").write_text(
+        "package c\n\ntype Cache struct{}\n\nfunc (c *Cache) Get(k string) string { return k }\n",
+        encoding="utf-8",
+    )
+    entries = [line.split("\t") for line in to_ctags(collect_tags(tmp_path)).splitlines() if not line.startswith("!")]
+    get = next(e for e in entries if e[0] == "Get")
+    assert "struct:Cache" in get and "signature:(k string)" in get
+    cache = next(e for e in entries if e[0] == "Cache")
+    assert "kind:struct" in cache
+
+
+def test_etags_sections(tmp_path):
+    (tmp_path / "app.py").write_text(PY_SOURCE, encoding="utf-8")
+    text = to_etags(collect_tags(tmp_path))
+    header, body = text.split("\n", 2)[1], text.split("\n", 2)[2]
+    name, size = header.rsplit(",", 1)
+    assert name == "app.py"
+    assert int(size) == len(body.encode("utf-8"))
+    assert "class Greeter\x7fGreeter\x011,0\n" in body
+    assert "    def hi\x7fhi\x012,15\n" in body
@@ -0,0 +1,51 @@
+"""Tests for the Prometheus registry and the optional tracer."""
+
+import pytest
+
+from treesitter_tools.telemetry import MetricsRegistry, Tracer
+
+
+def test_counter_and_gauge_exposition():
+    registry = MetricsRegistry()
+    hits = registry.counter("demo_hits_total", "Hits by path", ["path"])
+    hits.inc(path="/a")
+    hits.inc(2, path='say "hi"\n')
+    registry.gauge("demo_up", "Always one", collect=lambda: {(): 1})
+    assert registry.render() == (
+        "# HELP demo_hits_total Hits by path\n"
+        "# TYPE demo_hits_total counter\n"
+        'demo_hits_total{path="/a"} 1\n'
+        'demo_hits_total{path="say \\"hi\\"\\n"} 2\n'
+        "# HELP demo_up Always one\n"
+        "# TYPE demo_up gauge\n"
+        "demo_up 1\n"
+    )
+    assert hits.value(path="/a") == 1
+    with pytest.raises(ValueError):
+        hits.inc(method="get")  # wrong label names
+    with pytest.raises(ValueError):
+        registry.counter("demo_up", "duplicate")
+
+
+def test_histogram_buckets_are_cumulative():
+    registry = MetricsRegistry()
+    latency = registry.histogram("demo_seconds", "Latency", ["op"], buckets=(0.1, 1))
+    for value in (0.05, 0.5, 3):
+        latency.observe(value, op="x")
+    lines = registry.render().splitlines()[2:]
+    assert lines == [
+        'demo_seconds_bucket{op="x",le="0.1"} 1',
+        'demo_seconds_bucket{op="x",le="1"} 2',
+        'demo_seconds_bucket{op="x",le="+Inf"} 3',
+        'demo_seconds_sum{op="x"} 3.55',
+        'demo_seconds_count{op="x"} 3',
+    ]
+    assert latency.count(op="x") == 3
+
+
+def test_tracer_spans_without_an_sdk():
+    tracer = Tracer()
+    carrier = {"traceparent": "00-" + "1" * 32 + "-" + "2" * 16 + "-01"}
+    with tracer.span("outer", {"k": "v"}, carrier=carrier, server=True):
+        with tracer.span("inner") as span:
+            span.set_attribute("done", True)  # a no-op or non-recording span, never an error
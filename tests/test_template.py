@@ -0,0 +1,71 @@
+"""Tests for --format template."""
+
+import pytest
+from conftest import run_cli
+
+from treesitter_tools.template import Template, TemplateError, check_template
+
+SYMBOL = {"path": "pkg/app.py", "language": "python", "kind": "function", "name": "run", "start_line": 3,
+          "end_line": 5, "signature": None, "container": ["App", "Inner"]}
+MATCH = {"path": "app.py", "pattern_index": 0, "captures": [
+    {"name": "fn", "text": "print", "start_line": 2}, {"name": "arg", "text": "a, b", "start_line": 2},
+]}
+
+
+def _render(text, record=SYMBOL, name="symbol-record"):
+    return Template(text, name).render(record)
+
+
+def test_fields_and_functions():
+    assert _render("{{.Kind}} {{.Name}} {{.File}}:{{.Line}}") == "function run pkg/app.py:3"
+    assert _render("{{.end_line}} {{.EndLine | printf \"%03d\"}} {{join .Container \"::\"}}") == "5 005 App::Inner"
+    printed = _render('{{printf "%-8s|%q" .Kind .Name}} {{upper .Language}} {{len .Container}}')
+    assert printed == 'function|"run" PYTHON 2'
+    assert _render("{{.Signature}}|{{.Docstring}}|{{.Container}}") == "||[App Inner]"
+    assert _render('{{if .Signature}}sig{{else if eq .Kind "class" "function"}}def{{else}}?{{end}}') == "def"
+    assert _render("{{with .Container}}{{index . 0}}{{else}}top{{end}}") == "App"
+    assert _render("{{/* name */}}{{.Name -}}  \n  {{- \"\\t\"}}!") == "run\t!"
+    captures = "{{range .Captures}}{{$.Path}}:{{.StartLine}} {{.Name}}={{csv .Text}};{{end}}"
+    assert _render(captures, MATCH, "query-match") == 'app.py:2 fn=print;app.py:2 arg="a, b";'
+    assert Template("{{.Name}}").render_all([SYMBOL, {"name": "stop"}]) == "run\nstop\n"
+
+
+def test_print_spaces_operands_like_go():
+    assert _render("{{print .Line .EndLine}}") == "3 5"
+    assert _render('{{print .Name .Line ":" .EndLine}}') == "run3:5"
+
+
+def test_template_errors():
+    with pytest.raises(TemplateError, match=r"symbol-record has no field \.Nmae \(fields: Path, Language"):
+        Template("{{.Nmae}}", "symbol-record")
+    with pytest.raises(TemplateError, match=r"capture has no field \.Path"):
+        Template("{{range .Captures}}{{.Path}}{{end}}", "query-match")
+    with pytest.raises(TemplateError, match="unclosed {{if}}"):
+        Template("{{if .Name}}x")
+    with pytest.raises(TemplateError, match='function "shout" not defined'):
+        Template("{{shout .Name}}")
+    with pytest.raises(ValueError, match="needs --template"):
+        check_template(None, "template")
+    with pytest.raises(ValueError, match="needs --format template"):
+        check_template("{{.Name}}", "json")
+
+
+def test_cli_template(tmp_path):
+    (tmp_path / "pkg").mkdir()
+    (tmp_path / "pkg" / "app.py").write_text("class App:\n    def run(self):\n        print(1)\n", encoding="utf-8")
+    line = "{{.Kind}} {{.Name}} {{.File}}:{{.Line}}"
+    result = run_cli(["symbols", "pkg/app.py", "--format", "template", "--template", line], cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    assert result.stdout.splitlines() == ["class App pkg/app.py:1", "function run pkg/app.py:2"]
+
+    scanned = run_cli(["scan", ".", "--format", "template", "--template", "{{.Name}},{{.File}}"], cwd=tmp_path)
+    assert scanned.returncode == 0, scanned.stderr
+    rows = [row.split(",") for row in scanned.stdout.splitlines()]
+    assert [name for name, _ in rows] == ["App", "run"] and all(p.endswith("pkg/app.py") for _, p in rows)
+
+    calls = "(call function: (_) @callee)"
+    query = ["query", "pkg/app.py", calls, "--format", "template", "--template", "{{.Path}} {{len .Captures}}"]
+    assert run_cli(query, cwd=tmp_path).stdout == "pkg/app.py 1\n"
+
+    bad = run_cli(["symbols", "pkg/app.py", "--format", "template", "--template", "{{.Nmae}}"], cwd=tmp_path)
+    assert bad.returncode == 1 and "has no field .Nmae" in bad.stderr
@@ -0,0 +1,72 @@
+"""Tests for test discovery and the test-to-source map."""
+
+import json
+
+from treesitter_tools.core import parse_file
+from treesitter_tools.testmap import discover_tests, is_test_file, map_tests
+
+
+def _write(root, files):
+    for name, text in files.items():
+        path = root / name
+        path.parent.mkdir(parents=True, exist_ok=True)
+        path.write_text(text, encoding="utf-8")
+
+
+def test_is_test_file():
+    assert is_test_file("pkg/parse_test.go", "go")
+    assert not is_test_file("pkg/parse.go", "go")
+    assert is_test_file("tests/test_cli.py", "python")
+    assert is_test_file("app/cli_test.py", "python")
+    assert is_test_file("src/app.spec.ts", "typescript")
+    assert is_test_file("src/__tests__/app.js", "javascript")
+    assert not is_test_file("src/app.js", "javascript")
+
+
+def test_python_mapping_through_helpers_and_fixtures(tmp_path):
+    _write(tmp_path, {
+        "app/calc.py": "def add(a, b):\n    return a + b\n\n\ndef sub(a, b):\n    return a - b\n\n\n"
+                       "def _private():\n    pass\n\n\nclass Meter:\n    def read(self):\n        return 1\n",
+        "tests/test_calc.py": "import pytest\nfrom app.calc import add, Meter\n\n\n@pytest.fixture\ndef meter():\n"
+                              "    return Meter()\n\n\ndef check(x):\n    assert add(x, 1)\n\n\n"
+                              "def test_add():\n    check(1)\n\n\nclass TestMeter:\n    def test_read(self, meter):\n"
+                              "        assert meter.read() == 1\n",
+    })
+    report = map_tests(tmp_path)
+    ids = [t.id for t in report.tests]
+    assert ids == ["tests/test_calc.py::test_add", "tests/test_calc.py::TestMeter::test_read"]
+    by_name = {s.definition.qualified_name: s for s in report.symbols}
+    assert by_name["add"].tests == ["tests/test_calc.py::test_add"]
+    assert by_name["Meter"].tests == ["tests/test_calc.py::TestMeter::test_read"]
+    assert by_name["Meter.read"].tests == ["tests/test_calc.py::TestMeter::test_read"]
+    # `_private` is not exported, so only `sub` is reported.
+    assert [s.definition.qualified_name for s in report.untested] == ["sub"]
+    summary = json.loads(report.to_json())["summary"]
+    assert summary == {"tests": 2, "exported_symbols": 4, "tested": 3, "untested": 1, "coverage_intent": 0.75}
+    assert "app/calc.py:5: function sub" in report.to_text()
+
+
+def test_go_tests_only_see_their_package(tmp_path):
+    _write(tmp_path, {
+        "a/a.go": "package a\n\nfunc Parse() int { return 1 }\n\nfunc helper() {}\n",
+        "b/b.go": "package b\n\nfunc Parse() int { return 2 }\n",
+        "a/a_test.go": "package a\n\nimport \"testing\"\n\nfunc TestParse(t *testing.T) {\n\tParse()\n}\n\n"
+                       "func BenchmarkParse(b *testing.B) {}\n\nfunc Testify() {}\n",
+    })
+    report = map_tests(tmp_path)
+    assert [t.name for t in report.tests] == ["TestParse", "BenchmarkParse"]
+    assert report.tests[0].references == ["a/a.go:Parse"]
+    assert [s.definition.path for s in report.untested] == ["b/b.go"]
+
+
+def test_jest_suites_and_cases(tmp_path):
+    _write(tmp_path, {
+        "src/math.js": "export function square(x) { return x * x; }\nexport function cube(x) { return x ** 3; }\n",
+        "src/math.test.js": "import { square } from './math';\n\ndescribe('math', () => {\n"
+                            "  it('squares', () => {\n    expect(square(2)).toBe(4);\n  });\n"
+                            "  test.each([1, 2])('runs %i', (n) => {});\n});\n",
+    })
+    parsed = parse_file(tmp_path / "src/math.test.js")
+    assert [t.name for t in discover_tests(parsed, "src/math.test.js")] == ["math > squares", "math > runs %i"]
+    report = map_tests(tmp_path)
+    assert [s.definition.name for s in report.untested] == ["cube"]
@@ -0,0 +1,69 @@
+"""Tests for the code-health treemap report."""
+
+import json
+import re
+
+from conftest import run_cli
+
+from treesitter_tools.metrics import FunctionMetrics
+from treesitter_tools.treemap import build_treemap, treemap_to_html
+
+
+def _fn(path, name, sloc, cyclomatic, line=1):
+    return FunctionMetrics(path, name, line, line + sloc, sloc, sloc, cyclomatic, cyclomatic, 1)
+
+
+def _tree():
+    return build_treemap("repo", [
+        (_fn("src/app/a.py", "small", 4, 2), 2, {}),
+        (_fn("src/app/a.py", "big", 30, 25, 10), 25, {}),
+        (_fn("src/app/util/b.py", "helper", 6, 7), 7, {"churn": 3}),
+        (_fn("main.go", "main", 2, 1), 1, {}),
+    ])
+
+
+def test_build_treemap():
+    tree = _tree()
+    assert (tree.name, tree.size, tree.value) == ("repo", 42, 25)
+    app, main = tree.children  # biggest first
+    assert (app.name, app.kind, app.path, app.size, app.value) == ("src/app", "directory", "src/app", 40, 25)
+    assert (main.kind, main.children[0].name) == ("file", "main")
+    a, util = app.children
+    assert [f.name for f in a.children] == ["big", "small"] and util.children[0].kind == "file"
+    big = a.to_dict()["children"][0]
+    assert big == {
+        "name": "big", "kind": "function", "path": "src/app/a.py", "size": 30, "value": 25, "line": 10,
+        "details": {"loc": 30, "sloc": 30, "cyclomatic": 25, "cognitive": 25, "max_nesting": 1},
+    }
+    assert util.children[0].children[0].details["churn"] == 3
+
+
+def test_treemap_to_html():
+    page = treemap_to_html(_tree(), title="Health </script> check")
+    assert page.startswith("<!DOCTYPE html>") and "<title>Health &lt;/script&gt; check</title>" in page
+    assert page.count("</script>") == 3  # the embedded data cannot close its script element
+    assert not re.search(r'<(script|link|img)[^>]+(src|href)=', page)  # nothing loaded from elsewhere
+    data = re.search(r'<script type="application/json" id="treemap-data">(.*?)</script>', page, re.S).group(1)
+    assert json.loads(data)["size"] == 42
+    assert '"color":"cyclomatic"' in page
+
+
+def test_cli_report(tmp_path):
+    (tmp_path / "pkg").mkdir()
+    (tmp_path / "pkg" / "calc.py").write_text(
+        "def sign(x):\n    if x > 0:\n        return 1\n    if x < 0:\n        return -1\n    return 0\n",
+        encoding="utf-8",
+    )
+    (tmp_path / "README.md").write_text("# no functions\n", encoding="utf-8")
+    result = run_cli(["report", "."], cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    tree = json.loads(result.stdout)
+    (pkg,) = tree["children"]
+    assert pkg["path"] == "pkg" and pkg["children"][0]["children"][0]["value"] == 3
+
+    result = run_cli(["report", ".", "--html", "--output", "health.html"], cwd=tmp_path)
+    assert result.returncode == 0, result.stderr
+    assert "treemap of 1 functions" in result.stdout
+    assert "1 functions, " in (tmp_path / "health.html").read_text(encoding="utf-8")
+    bad = run_cli(["report", ".", "--color", "age"], cwd=tmp_path)
+    assert bad.returncode == 1 and "Unknown color" in bad.stderr
@@ -0,0 +1,71 @@
+"""Tests for unreferenced symbol detection."""
+
+from treesitter_tools.unused import find_unused, group_by_package, load_allowlist
+
+GO_LIB = """\
+package lib
+
+const defaultSize = 4
+const MaxSize = 10
+
+type Cache struct{}
+
+func NewCache() *Cache { return &Cache{} }
+
+func (c *Cache) Get() int { return helper() }
+
+func helper() int { return MaxSize }
+
+func unusedHelper() int { return unusedHelper() }
+
+func Orphan() {}
+"""
+
+GO_MAIN = """\
+package main
+
+import "example/lib"
+
+func main() {
+    c := lib.NewCache()
+    c.Get()
+}
+"""
+
+
+def _names(found):
+    return {d.qualified_name for d in found}
+
+
+def test_go_unused(tmp_path):
+    (tmp_path / "lib").mkdir()
+    (tmp_path / "lib" / "lib.go").write_text(GO_LIB, encoding="utf-8")
+    (tmp_path / "main.go").write_text(GO_MAIN, encoding="utf-8")
+    found = find_unused(tmp_path)
+    names = _names(found)
+    assert {"defaultSize", "unusedHelper", "Orphan"} <= names
+    assert not names & {"main", "NewCache", "Cache", "Cache.Get", "helper", "MaxSize"}
+
+    report = group_by_package(found)
+    lib = next(p for p in report if p["package"] == "lib")
+    assert [d["name"] for d in lib["exported_unreferenced"]] == ["Orphan"]
+    assert {d["name"] for d in lib["unexported_unused"]} == {"defaultSize", "unusedHelper"}
+
+
+def test_python_allowlist(tmp_path):
+    (tmp_path / "app.py").write_text(
+        "TIMEOUT = 3\n\n"
+        "def _private():\n    pass\n\n"
+        "def handler():\n    pass\n\n"
+        "def test_it():\n    pass\n\n"
+        "@decorator\ndef registered():\n    pass\n",
+        encoding="utf-8",
+    )
+    allow_file = tmp_path / "allow.txt"
+    allow_file.write_text("# reflection\nhandl*\n", encoding="utf-8")
+    found = find_unused(tmp_path, include=["*.py"], allow=load_allowlist(allow_file))
+    assert _names(found) == {"TIMEOUT", "_private"}
+    assert {d.qualified_name: d.exported for d in found} == {"TIMEOUT": True, "_private": False}
+
+    found = find_unused(tmp_path, include=["*.py"], use_default_entry_points=False)
+    assert "test_it" in _names(found)
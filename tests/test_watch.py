@@ -0,0 +1,69 @@
+"""Tests for watch-mode symbol diffing (no background threads)."""
+
+from pathlib import Path
+
+from treesitter_tools import watch as watch_module
+from treesitter_tools.watch import SymbolWatcher, diff_symbols, watch
+
+
+def test_start_reports_initial_symbols(tmp_path):
+    (tmp_path / "a.py").write_text("def foo():\n    pass\n", encoding="utf-8")
+    watcher = SymbolWatcher(tmp_path)
+    events = watcher.start()
+    assert [(e["event"], e["path"], e["symbol"]["name"]) for e in events] == [("added", "a.py", "foo")]
+
+
+def test_refresh_reports_added_changed_removed(tmp_path):
+    src = tmp_path / "a.py"
+    src.write_text("def foo():\n    return 1\n\ndef gone():\n    pass\n", encoding="utf-8")
+    watcher = SymbolWatcher(tmp_path)
+    watcher.start()
+
+    src.write_text("def foo():\n    return 2\n\ndef new():\n    pass\n", encoding="utf-8")
+    events = {(e["event"], e["symbol"]["name"]) for e in watcher.refresh([src])}
+    assert events == {("changed", "foo"), ("removed", "gone"), ("added", "new")}
+
+
+def test_deleted_file_removes_its_symbols(tmp_path):
+    src = tmp_path / "a.py"
+    src.write_text("def foo():\n    pass\n", encoding="utf-8")
+    watcher = SymbolWatcher(tmp_path)
+    watcher.start()
+    src.unlink()
+    events = watcher.poll()
+    assert [(e["event"], e["symbol"]["name"]) for e in events] == [("removed", "foo")]
+
+
+def test_files_vanishing_mid_poll_count_as_removed(tmp_path, monkeypatch):
+    src = tmp_path / "a.py"
+    src.write_text("def foo():\n    pass\n", encoding="utf-8")
+    watcher = SymbolWatcher(tmp_path)
+    watcher.start()
+    src.unlink()
+    # Listed by the directory walk, deleted before it could be stat'ed.
+    monkeypatch.setattr(watch_module, "iter_source_files", lambda *args: iter([src.resolve()]))
+    assert watcher.changed_since_last_poll() == [src.resolve()]
+    assert [(e["event"], e["symbol"]["name"]) for e in watcher.poll()] == [("removed", "foo")]
+    assert SymbolWatcher(tmp_path).start() == []
+
+
+def test_excluded_paths_are_ignored(tmp_path):
+    (tmp_path / "skip.py").write_text("def foo():\n    pass\n", encoding="utf-8")
+    watcher = SymbolWatcher(tmp_path, exclude=["skip.py"])
+    assert watcher.start() == []
+    assert watcher.refresh([tmp_path / "skip.py"]) == []
+
+
+def test_changed_event_lists_fields():
+    old = {"function:foo": {"name": "foo", "content": "a", "start_line": 1}}
+    new = {"function:foo": {"name": "foo", "content": "b", "start_line": 1}}
+    events = diff_symbols("a.py", old, new)
+    assert events[0]["fields"] == ["content"]
+
+
+def test_watch_loop_polls_until_stopped(tmp_path):
+    (tmp_path / "a.py").write_text("def foo():\n    pass\n", encoding="utf-8")
+    seen = []
+    ticks = iter([False, True])
+    watch(SymbolWatcher(tmp_path), seen.append, interval=0, use_native=False, stop=lambda: next(ticks))
+    assert [e["event"] for e in seen] == ["added"]
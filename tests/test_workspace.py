@@ -0,0 +1,107 @@
+"""Tests for multi-root workspaces."""
+
+import json
+
+import pytest
+from conftest import run_cli
+
+from treesitter_tools.config import ConfigError
+from treesitter_tools.core import LANGUAGE_MAPPINGS
+from treesitter_tools.index import SymbolIndex
+from treesitter_tools.workspace import (
+    index_workspace,
+    load_workspace,
+    scan_workspace,
+    workspace_from_paths,
+)
+
+WORKSPACE = """\
+roots:
+  - services/api
+  - path: web
+    include: ["src/**"]
+    languages: {jsx: javascript}
+  - path: ../shared
+    name: shared
+"""
+
+
+def _write(path, text):
+    path.parent.mkdir(parents=True, exist_ok=True)
+    path.write_text(text, encoding="utf-8")
+
+
+@pytest.fixture
+def monorepo(tmp_path):
+    repo = tmp_path / "repo"
+    _write(repo / "services" / "api" / "app.py", "def handler():\n    pass\n")
+    _write(repo / "services" / "api" / "tests" / "test_app.py", "def test_handler():\n    pass\n")
+    _write(repo / "services" / "api" / ".treesitter-tools.yaml", "exclude: ['tests/**']\n")
+    _write(repo / "web" / "src" / "view.jsx", "function View() { return 1; }\n")
+    _write(repo / "web" / "scripts" / "build.js", "function build() {}\n")
+    _write(tmp_path / "shared" / "util.go", "package util\n\nfunc Help() {}\n")
+    _write(repo / ".treesitter-tools-workspace.yaml", WORKSPACE)
+    return repo
+
+
+def _paths(reports):
+    return {r.path.as_posix(): r.root for r in reports}
+
+
+def test_load_workspace_applies_root_configs(monorepo):
+    workspace = load_workspace(monorepo / ".treesitter-tools-workspace.yaml")
+    api, web, shared = workspace.roots
+    assert (api.name, api.exclude, api.include) == ("services/api", ["tests/**"], None)
+    assert (web.include, web.languages) == (["src/**"], {"jsx": "javascript"})
+    assert shared.name == "shared" and shared.path == (monorepo.parent / "shared").resolve()
+
+
+def test_scan_merges_roots_with_labels(monorepo):
+    before = dict(LANGUAGE_MAPPINGS)
+    reports = list(scan_workspace(load_workspace(monorepo / ".treesitter-tools-workspace.yaml")))
+    assert _paths(reports) == {
+        "services/api/app.py": "services/api",
+        "web/src/view.jsx": "web",
+        "shared/util.go": "shared",
+    }
+    assert next(r for r in reports if r.root == "web").language == "javascript"
+    assert LANGUAGE_MAPPINGS == before
+    assert reports[0].to_dict()["root"] == "services/api"
+
+
+def test_invalid_workspace_reports_every_problem(tmp_path):
+    _write(tmp_path / "a" / "x.py", "x = 1\n")
+    _write(tmp_path / "b" / "a" / "y.py", "y = 1\n")
+    path = tmp_path / "ws.yaml"
+    path.write_text("roots:\n  - missing\n  - path: a\n    bogus: 1\n  - path: b/a\n    name: a\n", encoding="utf-8")
+    with pytest.raises(ConfigError) as info:
+        load_workspace(path)
+    problems = " | ".join(info.value.problems)
+    assert "not a directory" in problems and "bogus" in problems and "share the name 'a'" in problems
+
+
+def test_index_workspace_and_get(monorepo):
+    workspace = load_workspace(monorepo / ".treesitter-tools-workspace.yaml")
+    with SymbolIndex(monorepo / "index.db") as index:
+        assert index_workspace(index, workspace).added == 3
+        assert [d["path"] for d in index.defs("Help")] == ["shared/util.go"]
+        assert index.get("Help")[0]["source"] == "func Help() {}\n"
+        assert index_workspace(index, workspace).unchanged == 3
+        stats = index_workspace(index, workspace_from_paths([monorepo / "web"], base=monorepo))
+        assert stats.removed == 2 and [d["path"] for d in index.defs("View")] == ["web/src/view.jsx"]
+
+
+def test_cli_workspace(monorepo):
+    result = run_cli(["workspace", "scan"], cwd=monorepo / "web")
+    assert result.returncode == 0, result.stderr
+    assert {r["path"] for r in json.loads(result.stdout)} == {"services/api/app.py", "web/src/view.jsx", "shared/util.go"}
+
+    result = run_cli(["workspace", "scan", "services/api", "web", "--format", "ndjson"], cwd=monorepo)
+    assert result.returncode == 0, result.stderr
+    records = [json.loads(line) for line in result.stdout.splitlines()]
+    assert {r["path"] for r in records} == {"services/api/app.py", "web/scripts/build.js", "web/src/view.jsx"}
+
+    result = run_cli(["workspace", "show"], cwd=monorepo)
+    assert [r["name"] for r in json.loads(result.stdout)["roots"]] == ["services/api", "web", "shared"]
+    result = run_cli(["workspace", "scan"], cwd=monorepo.parent)
+    assert result.returncode == 1 and "No .treesitter-tools-workspace.yaml found" in result.stderr